@@ -0,0 +1,254 @@
+package oidc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-core-go-stdlib/internal/user"
+)
+
+// AuthorizeRequest is a validated authorization request. Scope is
+// normalised to single spaces.
+type AuthorizeRequest struct {
+	Client        *Client
+	RedirectURI   string
+	Scope         string
+	State         string
+	Nonce         string
+	CodeChallenge string
+}
+
+// The sign-in form is protected by a token that is fresh for every render:
+// a random key is sent in a short-lived cookie and the form carries the
+// HMAC of its hidden fields under that key. A cross-site POST cannot read
+// the cookie, and a form rendered for one request cannot be replayed with
+// different fields.
+const (
+	authorizeCSRFCookie = "oidc_authorize_csrf"
+	authorizeCSRFTTL    = 10 * time.Minute
+)
+
+// authorizeError is an error that is returned to the client's redirect URI
+// (RFC 6749 section 4.1.2.1).
+type authorizeError struct {
+	code        string
+	description string
+}
+
+var authorizePage = template.Must(template.New("authorize").Parse(`<!doctype html>
+<html>
+<head><meta charset="utf-8"><title>Sign in</title></head>
+<body>
+<h1>Sign in to {{.Client.Name}}</h1>
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+<form method="post" action="authorize">
+<input type="hidden" name="response_type" value="code">
+<input type="hidden" name="client_id" value="{{.Client.ID}}">
+<input type="hidden" name="redirect_uri" value="{{.RedirectURI}}">
+<input type="hidden" name="scope" value="{{.Scope}}">
+<input type="hidden" name="state" value="{{.State}}">
+<input type="hidden" name="nonce" value="{{.Nonce}}">
+<input type="hidden" name="code_challenge" value="{{.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="S256">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<p><label>Username or email <input name="username" autocomplete="username" required></label></p>
+<p><label>Password <input type="password" name="password" autocomplete="current-password" required></label></p>
+{{if .Client.FirstParty}}<p><button type="submit" name="decision" value="allow">Sign in</button></p>
+{{else}}<p>{{.Client.Name}} is requesting access to:</p>
+<ul>{{range .Scopes}}<li>{{.}}</li>{{end}}</ul>
+<p><button type="submit" name="decision" value="allow">Allow</button>
+<button type="submit" name="decision" value="deny" formnovalidate>Deny</button></p>
+{{end}}</form>
+</body>
+</html>
+`))
+
+// Authorize implements the authorization endpoint for the authorization
+// code flow with PKCE (S256 only). GET renders the sign-in form; POST
+// authenticates the user and, for clients that are not first-party,
+// records the consent decision before redirecting back with a code. A POST
+// without the CSRF token of the form it was rendered with is refused.
+func (h *Handler) Authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+	form := r.Form
+	if r.Method == http.MethodPost {
+		form = r.PostForm
+	}
+	client, err := h.svc.Client(r.Context(), form.Get("client_id"))
+	if errors.Is(err, ErrInvalidClient) {
+		// never redirect to an unverified client
+		http.Error(w, "invalid_client", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+	redirectURI := form.Get("redirect_uri")
+	if !client.AllowsRedirect(redirectURI) {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	req, authErr := parseAuthorizeRequest(client, redirectURI, form)
+	if authErr != nil {
+		h.authorizeRedirect(w, r, req, url.Values{"error": {authErr.code}, "error_description": {authErr.description}})
+		return
+	}
+	if r.Method != http.MethodPost {
+		// there is no sign-in session to reuse, so prompt=none always fails
+		if slices.Contains(strings.Fields(form.Get("prompt")), "none") {
+			h.authorizeRedirect(w, r, req, url.Values{"error": {"login_required"}})
+			return
+		}
+		h.renderAuthorize(w, r, req, http.StatusOK, "")
+		return
+	}
+
+	if !verifyAuthorizeCSRF(r, req) {
+		// never redirect on a forged or stale form; a real user signs in again
+		h.renderAuthorize(w, r, req, http.StatusForbidden, "This sign-in form has expired. Please try again.")
+		return
+	}
+	if form.Get("decision") != "allow" {
+		clearAuthorizeCSRF(w, r)
+		h.authorizeRedirect(w, r, req, url.Values{"error": {"access_denied"}})
+		return
+	}
+	u, err := h.userSvc.AuthenticatePassword(r.Context(), form.Get("username"), form.Get("password"))
+	if err != nil {
+		msg := "Invalid username or password."
+		switch {
+		case errors.Is(err, user.ErrLocked):
+			msg = "This account is locked. Try again later."
+		case errors.Is(err, user.ErrDisabled):
+			msg = "This account is disabled."
+		case errors.Is(err, user.ErrMustResetPassword):
+			msg = "Your password must be reset before you can sign in."
+		case !errors.Is(err, user.ErrBadCredentials):
+			http.Error(w, "server_error", http.StatusInternalServerError)
+			return
+		}
+		h.renderAuthorize(w, r, req, http.StatusUnauthorized, msg)
+		return
+	}
+	code, err := h.svc.CreateAuthCode(r.Context(), u.ID, req, time.Now())
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+	clearAuthorizeCSRF(w, r)
+	h.authorizeRedirect(w, r, req, url.Values{"code": {code}})
+}
+
+// parseAuthorizeRequest validates the parameters that are reported back to
+// the client. The client and redirect URI must already be verified.
+func parseAuthorizeRequest(client *Client, redirectURI string, form url.Values) (*AuthorizeRequest, *authorizeError) {
+	req := &AuthorizeRequest{
+		Client:        client,
+		RedirectURI:   redirectURI,
+		Scope:         strings.Join(strings.Fields(form.Get("scope")), " "),
+		State:         form.Get("state"),
+		Nonce:         form.Get("nonce"),
+		CodeChallenge: form.Get("code_challenge"),
+	}
+	if form.Get("response_type") != "code" {
+		return req, &authorizeError{"unsupported_response_type", "only response_type=code is supported"}
+	}
+	if form.Get("code_challenge_method") != "S256" || len(req.CodeChallenge) != 43 {
+		return req, &authorizeError{"invalid_request", "PKCE with code_challenge_method=S256 is required"}
+	}
+	if !slices.Contains(strings.Fields(req.Scope), "openid") || !client.AllowsScope(req.Scope) {
+		return req, &authorizeError{"invalid_scope", "scope must include openid and only scopes registered for the client"}
+	}
+	return req, nil
+}
+
+// renderAuthorize renders the sign-in form with a new CSRF key, so every
+// form is only good for the request it was rendered for.
+func (h *Handler) renderAuthorize(w http.ResponseWriter, r *http.Request, req *AuthorizeRequest, status int, msg string) {
+	key, err := randomToken()
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     authorizeCSRFCookie,
+		Value:    key,
+		Path:     r.URL.Path,
+		MaxAge:   int(authorizeCSRFTTL / time.Second),
+		Secure:   strings.HasPrefix(h.issuer, "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = authorizePage.Execute(w, struct {
+		*AuthorizeRequest
+		Scopes    []string
+		Error     string
+		CSRFToken string
+	}{req, strings.Fields(req.Scope), msg, authorizeCSRFToken(key, req)})
+}
+
+// authorizeCSRFToken binds the hidden fields of the form to key.
+func authorizeCSRFToken(key string, req *AuthorizeRequest) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	for _, field := range []string{req.Client.ID, req.RedirectURI, req.Scope, req.State, req.Nonce, req.CodeChallenge} {
+		mac.Write([]byte(field))
+		mac.Write([]byte{0})
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyAuthorizeCSRF checks the csrf_token of a posted form against the
+// key in the cookie and the request the form was posted for.
+func verifyAuthorizeCSRF(r *http.Request, req *AuthorizeRequest) bool {
+	cookie, err := r.Cookie(authorizeCSRFCookie)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	return hmac.Equal([]byte(authorizeCSRFToken(cookie.Value, req)), []byte(r.PostForm.Get("csrf_token")))
+}
+
+// clearAuthorizeCSRF drops the key once the form has been answered.
+func clearAuthorizeCSRF(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: authorizeCSRFCookie, Path: r.URL.Path, MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteStrictMode})
+}
+
+// authorizeRedirect sends the response back to the client's redirect URI,
+// echoing state and identifying the issuer (RFC 9207).
+func (h *Handler) authorizeRedirect(w http.ResponseWriter, r *http.Request, req *AuthorizeRequest, params url.Values) {
+	u, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	q := u.Query()
+	for k, v := range params {
+		if v[0] != "" {
+			q.Set(k, v[0])
+		}
+	}
+	if req.State != "" {
+		q.Set("state", req.State)
+	}
+	q.Set("iss", h.issuer)
+	u.RawQuery = q.Encode()
+	status := http.StatusFound
+	if r.Method == http.MethodPost {
+		status = http.StatusSeeOther
+	}
+	http.Redirect(w, r, u.String(), status)
+}
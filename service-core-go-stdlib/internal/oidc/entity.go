@@ -1,11 +1,68 @@
 package oidc
 
-import "time"
+import (
+	"crypto"
+	"slices"
+	"strings"
+	"time"
+)
 
-// SigningKey holds an in-memory signing key for issuing tokens.
+const (
+	ClientPublic       = "public"
+	ClientConfidential = "confidential"
+)
+
+// Client is a registered OAuth client. Confidential clients authenticate
+// at the token endpoint with their secret; public clients rely on PKCE.
+// FirstParty clients skip the consent screen, and the password grant is
+// only accepted for clients with AllowPasswordGrant set.
+type Client struct {
+	ID                 string
+	Name               string
+	Type               string
+	SecretHash         string
+	RedirectURIs       []string
+	Scopes             []string
+	FirstParty         bool
+	AllowPasswordGrant bool
+}
+
+// AllowsRedirect reports whether uri exactly matches a registered redirect URI.
+func (c *Client) AllowsRedirect(uri string) bool {
+	return uri != "" && slices.Contains(c.RedirectURIs, uri)
+}
+
+// AllowsScope reports whether every space-separated scope is registered
+// for the client.
+func (c *Client) AllowsScope(scope string) bool {
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(c.Scopes, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// Grant describes what a set of tokens is issued for.
+type Grant struct {
+	ClientID string
+	Scope    string
+	// Nonce and AuthTime come from the authorization request and are copied
+	// into the ID token when set.
+	Nonce    string
+	AuthTime time.Time
+}
+
+// SigningKey holds a decrypted signing key. It signs tokens between
+// ActiveFrom and ActiveUntil and is published in the JWKS until RetireAt.
 type SigningKey struct {
-	Kid       string
-	CreatedAt time.Time
+	Kid         string
+	Alg         string
+	Private     crypto.Signer
+	ActiveFrom  time.Time
+	ActiveUntil time.Time
+	RetireAt    time.Time
+	CreatedAt   time.Time
 }
 
 // RefreshSession represents a persisted refresh session (minimal skeleton).
@@ -13,5 +70,6 @@ type RefreshSession struct {
 	ID        int64     `db:"id"`
 	UserID    int64     `db:"user_id"`
 	ClientID  string    `db:"client_id"`
+	Scope     string    `db:"scope"`
 	ExpiresAt time.Time `db:"expires_at"`
 }
@@ -2,8 +2,10 @@ package oidc
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -11,6 +13,7 @@ import (
 
 	"github.com/jmoiron/sqlx"
 	"github.com/ovaphlow/pitchfork/service-core-go-stdlib/internal/user"
+	"github.com/ovaphlow/pitchfork/service-core-go-stdlib/internal/user/entity"
 )
 
 type Handler struct {
@@ -30,98 +33,157 @@ func NewHandler(db *sqlx.DB, issuer string) (*Handler, error) {
 
 func (h *Handler) Discovery(w http.ResponseWriter, r *http.Request) {
 	out := map[string]any{
-		"issuer":            h.issuer,
-		"jwks_uri":          h.issuer + "/jwks.json",
-		"token_endpoint":    h.issuer + "/token",
-		"userinfo_endpoint": h.issuer + "/userinfo",
+		"issuer":                                         h.issuer,
+		"authorization_endpoint":                         h.issuer + "/authorize",
+		"jwks_uri":                                       h.issuer + "/jwks.json",
+		"token_endpoint":                                 h.issuer + "/token",
+		"userinfo_endpoint":                              h.issuer + "/userinfo",
+		"id_token_signing_alg_values_supported":          h.svc.SigningAlgorithms(),
+		"response_types_supported":                       []string{"code"},
+		"grant_types_supported":                          []string{"authorization_code", "refresh_token"},
+		"code_challenge_methods_supported":               []string{"S256"},
+		"token_endpoint_auth_methods_supported":          []string{"client_secret_basic", "client_secret_post", "none"},
+		"subject_types_supported":                        []string{"public"},
+		"authorization_response_iss_parameter_supported": true,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(out)
 }
 
 func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request) {
-	jwks, _ := h.svc.JWKS()
+	jwks, err := h.svc.JWKS(r.Context())
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(jwks)
 }
 
+// Token implements the token endpoint. Clients authenticate with
+// client_secret_basic or client_secret_post, or send only client_id when
+// public. The password grant is accepted only for clients registered with
+// allow_password_grant.
 func (h *Handler) Token(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "invalid request", http.StatusBadRequest)
+		tokenError(w, http.StatusBadRequest, "invalid_request")
 		return
 	}
-	grant := r.Form.Get("grant_type")
-	if grant != "password" && grant != "refresh_token" {
-		http.Error(w, "unsupported_grant_type", http.StatusBadRequest)
+	clientID, secret, basic := r.BasicAuth()
+	if basic {
+		// RFC 6749 section 2.3.1: credentials are form-encoded before base64
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	client, err := h.svc.AuthenticateClient(r.Context(), clientID, secret)
+	if errors.Is(err, ErrInvalidClient) {
+		if basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+		}
+		tokenError(w, http.StatusUnauthorized, "invalid_client")
 		return
 	}
-	clientID := r.Form.Get("client_id")
-	if grant == "password" {
-		username := r.Form.Get("username")
-		password := r.Form.Get("password")
-		// authenticate
-		u, err := h.userSvc.AuthenticatePassword(r.Context(), username, password)
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+
+	var u *entity.MinimalAuthView
+	var grant Grant
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		userID, g, err := h.svc.ExchangeAuthCode(r.Context(), client, r.PostForm.Get("code"), r.PostForm.Get("redirect_uri"), r.PostForm.Get("code_verifier"))
 		if err != nil {
-			http.Error(w, "invalid_grant", http.StatusUnauthorized)
+			tokenGrantError(w, err)
 			return
 		}
-		// issue tokens (also returns refresh token)
-		idTok, accessTok, refreshTok, err := h.svc.IssueTokens(r.Context(), u, clientID, 15*time.Minute)
-		if err != nil {
-			http.Error(w, "server_error", http.StatusInternalServerError)
+		if u, err = h.userSvc.GetMinimalAuthView(r.Context(), userID); err != nil {
+			tokenError(w, http.StatusBadRequest, "invalid_grant")
 			return
 		}
-		resp := map[string]any{
-			"access_token":  accessTok,
-			"id_token":      idTok,
-			"refresh_token": refreshTok,
-			"token_type":    "Bearer",
-			"expires_in":    900,
+		grant = g
+	case "refresh_token":
+		rt := r.PostForm.Get("refresh_token")
+		if rt == "" {
+			tokenError(w, http.StatusBadRequest, "invalid_request")
+			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(resp)
+		session, ok := h.svc.ValidateRefreshToken(r.Context(), rt)
+		if !ok || session.ClientID != client.ID {
+			tokenError(w, http.StatusBadRequest, "invalid_grant")
+			return
+		}
+		// load minimal view for the user
+		if u, err = h.userSvc.GetMinimalAuthView(r.Context(), session.UserID); err != nil {
+			tokenError(w, http.StatusBadRequest, "invalid_grant")
+			return
+		}
+		// rotate refresh token: revoke old and issue new
+		if err := h.svc.RevokeRefreshToken(r.Context(), rt); err != nil {
+			// best-effort; if revoke fails, reject to avoid issuing new token
+			tokenError(w, http.StatusBadRequest, "invalid_grant")
+			return
+		}
+		grant = Grant{ClientID: session.ClientID, Scope: session.Scope}
+	case "password":
+		if !client.AllowPasswordGrant {
+			tokenError(w, http.StatusBadRequest, "unauthorized_client")
+			return
+		}
+		scope := strings.Join(strings.Fields(r.PostForm.Get("scope")), " ")
+		if scope == "" {
+			scope = "openid"
+		}
+		if !client.AllowsScope(scope) {
+			tokenError(w, http.StatusBadRequest, "invalid_scope")
+			return
+		}
+		if u, err = h.userSvc.AuthenticatePassword(r.Context(), r.PostForm.Get("username"), r.PostForm.Get("password")); err != nil {
+			tokenError(w, http.StatusBadRequest, "invalid_grant")
+			return
+		}
+		grant = Grant{ClientID: client.ID, Scope: scope, AuthTime: time.Now()}
+	default:
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type")
 		return
 	}
 
-	// refresh_token grant
-	rt := r.Form.Get("refresh_token")
-	if rt == "" {
-		http.Error(w, "invalid_request", http.StatusBadRequest)
-		return
-	}
-	session, ok := h.svc.ValidateRefreshToken(r.Context(), rt)
-	if !ok {
-		http.Error(w, "invalid_grant", http.StatusUnauthorized)
-		return
-	}
-	// load minimal view for the user
-	v, err := h.userSvc.GetMinimalAuthView(r.Context(), session.UserID)
-	if err != nil {
-		http.Error(w, "invalid_grant", http.StatusUnauthorized)
-		return
-	}
-	// rotate refresh token: revoke old and issue new
-	if err := h.svc.RevokeRefreshToken(r.Context(), rt); err != nil {
-		// best-effort; if revoke fails, reject to avoid issuing new token
-		http.Error(w, "invalid_grant", http.StatusUnauthorized)
-		return
-	}
-	idTok, accessTok, newRT, err := h.svc.IssueTokens(r.Context(), v, session.ClientID, 15*time.Minute)
+	idTok, accessTok, refreshTok, err := h.svc.IssueTokens(r.Context(), u, grant, 15*time.Minute)
 	if err != nil {
-		http.Error(w, "server_error", http.StatusInternalServerError)
+		tokenError(w, http.StatusInternalServerError, "server_error")
 		return
 	}
 	resp := map[string]any{
 		"access_token":  accessTok,
 		"id_token":      idTok,
-		"refresh_token": newRT,
+		"refresh_token": refreshTok,
 		"token_type":    "Bearer",
 		"expires_in":    900,
+		"scope":         grant.Scope,
 	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
 	_ = json.NewEncoder(w).Encode(resp)
 }
 
+// tokenError writes an RFC 6749 section 5.2 error response.
+func tokenError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+func tokenGrantError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrInvalidGrant) {
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+	tokenError(w, http.StatusInternalServerError, "server_error")
+}
+
 func (h *Handler) Userinfo(w http.ResponseWriter, r *http.Request) {
 	auth := r.Header.Get("Authorization")
 	if auth == "" || !strings.HasPrefix(strings.ToLower(auth), "bearer ") {
@@ -131,9 +193,7 @@ func (h *Handler) Userinfo(w http.ResponseWriter, r *http.Request) {
 	token := strings.TrimSpace(auth[len("bearer "):])
 	// parse and verify
 	claims := map[string]any{}
-	_, err := jwt.ParseWithClaims(token, jwt.MapClaims(claims), func(t *jwt.Token) (any, error) {
-		return h.svc.PublicKey(), nil
-	})
+	_, err := jwt.ParseWithClaims(token, jwt.MapClaims(claims), h.svc.KeyFunc(r.Context()), jwt.WithValidMethods(h.svc.SigningAlgorithms()))
 	if err != nil {
 		http.Error(w, "invalid_token", http.StatusUnauthorized)
 		return
@@ -204,9 +264,7 @@ func (h *Handler) Introspect(w http.ResponseWriter, r *http.Request) {
 
 	// Try JWT access token
 	claims := jwt.MapClaims{}
-	tkn, err := jwt.ParseWithClaims(token, jwt.MapClaims(claims), func(t *jwt.Token) (any, error) {
-		return h.svc.PublicKey(), nil
-	})
+	tkn, err := jwt.ParseWithClaims(token, jwt.MapClaims(claims), h.svc.KeyFunc(r.Context()), jwt.WithValidMethods(h.svc.SigningAlgorithms()))
 	if err == nil && tkn.Valid {
 		out := map[string]any{"active": true}
 		// copy common registered claims if present
@@ -0,0 +1,136 @@
+package oidc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var csrfFieldPattern = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+func newTestHandler() *Handler {
+	svc := newTestService()
+	return &Handler{svc: svc, issuer: svc.issuer}
+}
+
+func authorizeQuery() url.Values {
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"spa"},
+		"redirect_uri":          {"https://spa.example.test/callback"},
+		"scope":                 {"openid profile"},
+		"state":                 {"s-1"},
+		"nonce":                 {"n-1"},
+		"code_challenge":        {testChallenge},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+func TestAuthorizeRejectsRedirectMismatch(t *testing.T) {
+	for _, redirectURI := range []string{"", "https://spa.example.test/callback/", "https://evil.example.test/callback"} {
+		q := authorizeQuery()
+		q.Set("redirect_uri", redirectURI)
+		rec := httptest.NewRecorder()
+		newTestHandler().Authorize(rec, httptest.NewRequest(http.MethodGet, "/authorize?"+q.Encode(), nil))
+		if rec.Code != http.StatusBadRequest || rec.Header().Get("Location") != "" {
+			t.Fatalf("redirect_uri %q: status = %d, location = %q", redirectURI, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+}
+
+func TestAuthorizeRequiresCSRFTokenOnPost(t *testing.T) {
+	h := newTestHandler()
+	rec := httptest.NewRecorder()
+	h.Authorize(rec, httptest.NewRequest(http.MethodGet, "/authorize?"+authorizeQuery().Encode(), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("render status = %d", rec.Code)
+	}
+	var cookie *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == authorizeCSRFCookie {
+			cookie = c
+		}
+	}
+	match := csrfFieldPattern.FindStringSubmatch(rec.Body.String())
+	if cookie == nil || !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode || cookie.Path != "/authorize" || match == nil {
+		t.Fatalf("csrf cookie = %+v, form field found = %v", cookie, match != nil)
+	}
+	token := match[1]
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		modify func(url.Values)
+		status int
+	}{
+		{"missing cookie", nil, nil, http.StatusForbidden},
+		{"missing token", cookie, func(f url.Values) { f.Del("csrf_token") }, http.StatusForbidden},
+		{"cookie of another form", &http.Cookie{Name: authorizeCSRFCookie, Value: "other"}, nil, http.StatusForbidden},
+		{"altered state", cookie, func(f url.Values) { f.Set("state", "s-2") }, http.StatusForbidden},
+		{"altered challenge", cookie, func(f url.Values) { f.Set("code_challenge", strings.Repeat("A", 43)) }, http.StatusForbidden},
+		{"valid", cookie, nil, http.StatusSeeOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := authorizeQuery()
+			form.Set("csrf_token", token)
+			form.Set("decision", "deny")
+			if tt.modify != nil {
+				tt.modify(form)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/authorize", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			rec := httptest.NewRecorder()
+			h.Authorize(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusSeeOther {
+				location, err := url.Parse(rec.Header().Get("Location"))
+				if err != nil || location.Query().Get("error") != "access_denied" || location.Query().Get("state") != "s-1" {
+					t.Fatalf("location = %q", rec.Header().Get("Location"))
+				}
+				return
+			}
+			// a refused form is rendered again with a fresh token instead of redirecting
+			if rec.Header().Get("Location") != "" || !csrfFieldPattern.MatchString(rec.Body.String()) {
+				t.Fatalf("refused post: location = %q", rec.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestTokenRefusesPasswordGrantWithoutFlag(t *testing.T) {
+	tests := []struct {
+		name     string
+		clientID string
+		status   int
+		code     string
+	}{
+		{"client without allow_password_grant", "spa", http.StatusBadRequest, "unauthorized_client"},
+		{"unknown client", "nobody", http.StatusUnauthorized, "invalid_client"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"grant_type": {"password"}, "client_id": {tt.clientID}, "username": {"alice"}, "password": {"secret"}, "scope": {"openid"}}
+			req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			newTestHandler().Token(rec, req)
+			var body map[string]string
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if rec.Code != tt.status || body["error"] != tt.code {
+				t.Fatalf("token = %d %v, want %d %s", rec.Code, body, tt.status, tt.code)
+			}
+		})
+	}
+}
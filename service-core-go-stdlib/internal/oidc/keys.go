@@ -0,0 +1,381 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	repo "github.com/ovaphlow/pitchfork/service-core-go-stdlib/internal/oidc/repo"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+const (
+	defaultKeyRotationPeriod = 30 * 24 * time.Hour
+	defaultKeyRetireAfter    = 24 * time.Hour
+	// keySyncInterval bounds how stale the in-memory key set may be, so that
+	// keys added by another replica are picked up and rotation happens on
+	// time without a separate scheduler.
+	keySyncInterval = time.Minute
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeyConfig controls how signing keys are generated, stored and rotated.
+type KeyConfig struct {
+	// Algorithm is used for new keys: RS256, ES256 or EdDSA. Changing it
+	// rotates to a new key at the next sync; keys of the old algorithm stay
+	// published until they retire.
+	Algorithm string
+	// EncryptionKey is the 32-byte AES-256-GCM key that seals private keys
+	// at rest.
+	EncryptionKey []byte
+	// RotationPeriod is how long each key signs tokens.
+	RotationPeriod time.Duration
+	// RetireAfter is how long a key stays in the JWKS after it stops
+	// signing, and how early the next key is published before it starts.
+	// It must exceed the longest token lifetime and the JWKS cache time of
+	// relying parties.
+	RetireAfter time.Duration
+}
+
+// KeyConfigFromEnv reads OIDC_SIGNING_ALG, OIDC_KEY_ENCRYPTION_KEY (base64),
+// OIDC_KEY_ROTATION_PERIOD and OIDC_KEY_RETIRE_AFTER (Go durations).
+func KeyConfigFromEnv() (KeyConfig, error) {
+	cfg := KeyConfig{
+		Algorithm:      os.Getenv("OIDC_SIGNING_ALG"),
+		RotationPeriod: defaultKeyRotationPeriod,
+		RetireAfter:    defaultKeyRetireAfter,
+	}
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = AlgRS256
+	}
+	if raw := strings.TrimSpace(os.Getenv("OIDC_KEY_ENCRYPTION_KEY")); raw != "" {
+		key, err := base64.StdEncoding.DecodeString(raw)
+		if err != nil {
+			return KeyConfig{}, fmt.Errorf("OIDC_KEY_ENCRYPTION_KEY must be base64: %w", err)
+		}
+		cfg.EncryptionKey = key
+	}
+	for name, target := range map[string]*time.Duration{
+		"OIDC_KEY_ROTATION_PERIOD": &cfg.RotationPeriod,
+		"OIDC_KEY_RETIRE_AFTER":    &cfg.RetireAfter,
+	} {
+		if raw := strings.TrimSpace(os.Getenv(name)); raw != "" {
+			d, err := time.ParseDuration(raw)
+			if err != nil {
+				return KeyConfig{}, fmt.Errorf("%s must be a Go duration: %w", name, err)
+			}
+			*target = d
+		}
+	}
+	return cfg, cfg.validate()
+}
+
+func (c KeyConfig) validate() error {
+	switch c.Algorithm {
+	case AlgRS256, AlgES256, AlgEdDSA:
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q (want RS256, ES256 or EdDSA)", c.Algorithm)
+	}
+	if len(c.EncryptionKey) != 32 {
+		return errors.New("OIDC_KEY_ENCRYPTION_KEY must decode to 32 bytes")
+	}
+	if c.RetireAfter <= 0 || c.RotationPeriod <= c.RetireAfter {
+		return errors.New("OIDC key rotation period must be longer than the retire window")
+	}
+	return nil
+}
+
+type signingKeyStore interface {
+	Rotate(ctx context.Context, now time.Time, next func([]repo.SigningKeyRow) (*repo.SigningKeyRow, error)) ([]repo.SigningKeyRow, error)
+}
+
+// keyManager keeps the published signing keys in memory and syncs them
+// with the database, rotating when the active key nears the end of its
+// window.
+type keyManager struct {
+	repo signingKeyStore
+	cfg  KeyConfig
+	aead cipher.AEAD
+
+	mu       sync.Mutex
+	keys     []*SigningKey
+	syncedAt time.Time
+}
+
+func newKeyManager(r signingKeyStore, cfg KeyConfig) (*keyManager, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(cfg.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &keyManager{repo: r, cfg: cfg, aead: aead}, nil
+}
+
+// published returns every key that has not retired, syncing with the
+// database at most once per keySyncInterval. If a sync fails, the keys from
+// the last successful sync keep being served and the next attempt waits
+// for the following interval, so an unreachable database is not retried
+// on every request.
+func (m *keyManager) published(ctx context.Context) ([]*SigningKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if m.keys != nil && now.Sub(m.syncedAt) < keySyncInterval {
+		return m.keys, nil
+	}
+	keys, err := m.sync(ctx, now)
+	if err != nil {
+		if m.keys != nil {
+			m.syncedAt = now
+			return m.keys, nil
+		}
+		return nil, err
+	}
+	m.keys, m.syncedAt = keys, now
+	return keys, nil
+}
+
+// signingKey returns the active key, preferring the configured algorithm
+// and then the most recently activated key.
+func (m *keyManager) signingKey(ctx context.Context) (*SigningKey, error) {
+	keys, err := m.published(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var best *SigningKey
+	for _, k := range keys {
+		if now.Before(k.ActiveFrom) || !now.Before(k.ActiveUntil) {
+			continue
+		}
+		if best == nil {
+			best = k
+			continue
+		}
+		preferred, bestPreferred := k.Alg == m.cfg.Algorithm, best.Alg == m.cfg.Algorithm
+		if (preferred && !bestPreferred) || (preferred == bestPreferred && k.ActiveFrom.After(best.ActiveFrom)) {
+			best = k
+		}
+	}
+	if best == nil {
+		return nil, errors.New("no active signing key")
+	}
+	return best, nil
+}
+
+// keyFunc verifies tokens against any published key, matched by kid, and
+// only with that key's own algorithm.
+func (m *keyManager) keyFunc(ctx context.Context) jwt.Keyfunc {
+	return func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		keys, err := m.published(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			if k.Kid != kid || !time.Now().Before(k.RetireAt) {
+				continue
+			}
+			if t.Method.Alg() != k.Alg {
+				return nil, fmt.Errorf("token algorithm %s does not match key %s", t.Method.Alg(), kid)
+			}
+			return k.Private.Public(), nil
+		}
+		return nil, ErrUnknownKey
+	}
+}
+
+func (m *keyManager) sync(ctx context.Context, now time.Time) ([]*SigningKey, error) {
+	rows, err := m.repo.Rotate(ctx, now, func(existing []repo.SigningKeyRow) (*repo.SigningKeyRow, error) {
+		return m.nextKey(existing, now)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rotate signing keys: %w", err)
+	}
+	keys := make([]*SigningKey, 0, len(rows))
+	for _, row := range rows {
+		k, err := m.open(row)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// nextKey decides whether a key must be added. A key of the configured
+// algorithm must cover now; once the last one has less than RetireAfter
+// left, the next key is published early with its window starting where the
+// last one ends, so relying parties have it cached before it signs.
+func (m *keyManager) nextKey(existing []repo.SigningKeyRow, now time.Time) (*repo.SigningKeyRow, error) {
+	covered := false
+	var last *repo.SigningKeyRow
+	for i := range existing {
+		row := &existing[i]
+		if row.Alg != m.cfg.Algorithm || !row.ActiveUntil.After(now) {
+			continue
+		}
+		if !now.Before(row.ActiveFrom) {
+			covered = true
+		}
+		if last == nil || row.ActiveUntil.After(last.ActiveUntil) {
+			last = row
+		}
+	}
+	switch {
+	case !covered:
+		return m.generate(now, now)
+	case last.ActiveUntil.Sub(now) <= m.cfg.RetireAfter:
+		return m.generate(last.ActiveUntil, now)
+	default:
+		return nil, nil
+	}
+}
+
+func (m *keyManager) generate(activeFrom time.Time, now time.Time) (*repo.SigningKeyRow, error) {
+	var private crypto.Signer
+	var err error
+	switch m.cfg.Algorithm {
+	case AlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("generate %s key: %w", m.cfg.Algorithm, err)
+	}
+	kid, err := thumbprint(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, m.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	activeUntil := activeFrom.Add(m.cfg.RotationPeriod)
+	return &repo.SigningKeyRow{
+		Kid:         kid,
+		Alg:         m.cfg.Algorithm,
+		PrivateKey:  m.aead.Seal(nonce, nonce, der, keyAAD(kid, m.cfg.Algorithm)),
+		ActiveFrom:  activeFrom,
+		ActiveUntil: activeUntil,
+		RetireAt:    activeUntil.Add(m.cfg.RetireAfter),
+		CreatedAt:   now,
+	}, nil
+}
+
+// open decrypts a stored key. The kid and algorithm are bound as
+// additional data, so a sealed key copied to another row fails to open.
+func (m *keyManager) open(row repo.SigningKeyRow) (*SigningKey, error) {
+	size := m.aead.NonceSize()
+	if len(row.PrivateKey) < size {
+		return nil, fmt.Errorf("signing key %s: sealed key too short", row.Kid)
+	}
+	der, err := m.aead.Open(nil, row.PrivateKey[:size], row.PrivateKey[size:], keyAAD(row.Kid, row.Alg))
+	if err != nil {
+		return nil, fmt.Errorf("signing key %s: decrypt: %w", row.Kid, err)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("signing key %s: %w", row.Kid, err)
+	}
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s: unsupported key type", row.Kid)
+	}
+	return &SigningKey{
+		Kid:         row.Kid,
+		Alg:         row.Alg,
+		Private:     private,
+		ActiveFrom:  row.ActiveFrom,
+		ActiveUntil: row.ActiveUntil,
+		RetireAt:    row.RetireAt,
+		CreatedAt:   row.CreatedAt,
+	}, nil
+}
+
+func keyAAD(kid, alg string) []byte {
+	return []byte(alg + "." + kid)
+}
+
+func signingMethod(alg string) jwt.SigningMethod {
+	switch alg {
+	case AlgES256:
+		return jwt.SigningMethodES256
+	case AlgEdDSA:
+		return jwt.SigningMethodEdDSA
+	default:
+		return jwt.SigningMethodRS256
+	}
+}
+
+// jwk returns the public JSON Web Key members of a key, without kid, alg
+// and use.
+func jwk(pub crypto.PublicKey) (map[string]any, error) {
+	enc := base64.RawURLEncoding.EncodeToString
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		return map[string]any{"kty": "RSA", "n": enc(p.N.Bytes()), "e": enc(big.NewInt(int64(p.E)).Bytes())}, nil
+	case *ecdsa.PublicKey:
+		// coordinates are padded to the curve size as RFC 7518 requires
+		x, y := make([]byte, 32), make([]byte, 32)
+		p.X.FillBytes(x)
+		p.Y.FillBytes(y)
+		return map[string]any{"kty": "EC", "crv": "P-256", "x": enc(x), "y": enc(y)}, nil
+	case ed25519.PublicKey:
+		return map[string]any{"kty": "OKP", "crv": "Ed25519", "x": enc(p)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+// thumbprint is the RFC 7638 JWK thumbprint, used as the kid.
+func thumbprint(pub crypto.PublicKey) (string, error) {
+	members, err := jwk(pub)
+	if err != nil {
+		return "", err
+	}
+	// encoding/json sorts map keys, which gives the required member order
+	b, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(h[:]), nil
+}
@@ -0,0 +1,415 @@
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	repo "github.com/ovaphlow/pitchfork/service-core-go-stdlib/internal/oidc/repo"
+)
+
+var testAlgorithms = []string{AlgRS256, AlgES256, AlgEdDSA}
+
+// memorySigningKeys mirrors SigningKeyRepo.Rotate: retired keys are
+// dropped, the rest are passed to next ordered by active_from and the
+// returned key is stored.
+type memorySigningKeys struct {
+	mu    sync.Mutex
+	rows  []repo.SigningKeyRow
+	err   error
+	calls int
+}
+
+func (m *memorySigningKeys) Rotate(ctx context.Context, now time.Time, next func([]repo.SigningKeyRow) (*repo.SigningKeyRow, error)) ([]repo.SigningKeyRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.rows = slices.DeleteFunc(m.rows, func(row repo.SigningKeyRow) bool { return !row.RetireAt.After(now) })
+	slices.SortFunc(m.rows, func(a, b repo.SigningKeyRow) int { return a.ActiveFrom.Compare(b.ActiveFrom) })
+	key, err := next(slices.Clone(m.rows))
+	if err != nil {
+		return nil, err
+	}
+	if key != nil {
+		m.rows = append(m.rows, *key)
+	}
+	return slices.Clone(m.rows), nil
+}
+
+func testKeyConfig(alg string) KeyConfig {
+	return KeyConfig{
+		Algorithm:      alg,
+		EncryptionKey:  bytes.Repeat([]byte{7}, 32),
+		RotationPeriod: defaultKeyRotationPeriod,
+		RetireAfter:    defaultKeyRetireAfter,
+	}
+}
+
+func newTestKeyManager(t *testing.T, cfg KeyConfig, store *memorySigningKeys) *keyManager {
+	t.Helper()
+	m, err := newKeyManager(store, cfg)
+	if err != nil {
+		t.Fatalf("newKeyManager: %v", err)
+	}
+	return m
+}
+
+// storedKey generates a sealed key of the manager's algorithm for the
+// given active window.
+func storedKey(t *testing.T, m *keyManager, activeFrom, activeUntil time.Time) repo.SigningKeyRow {
+	t.Helper()
+	row, err := m.generate(activeFrom, activeFrom)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	row.ActiveUntil = activeUntil
+	row.RetireAt = activeUntil.Add(m.cfg.RetireAfter)
+	return *row
+}
+
+func TestSealedKeyRoundTrip(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	for _, alg := range testAlgorithms {
+		m := newTestKeyManager(t, testKeyConfig(alg), &memorySigningKeys{})
+		row, err := m.generate(now, now)
+		if err != nil {
+			t.Fatalf("%s: generate: %v", alg, err)
+		}
+		k, err := m.open(*row)
+		if err != nil {
+			t.Fatalf("%s: open: %v", alg, err)
+		}
+		kid, err := thumbprint(k.Private.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if k.Kid != row.Kid || kid != row.Kid || k.Alg != alg {
+			t.Fatalf("%s: opened kid %q alg %q, thumbprint %q, want %q", alg, k.Kid, k.Alg, kid, row.Kid)
+		}
+		if !k.ActiveFrom.Equal(now) || !k.ActiveUntil.Equal(now.Add(defaultKeyRotationPeriod)) || !k.RetireAt.Equal(now.Add(defaultKeyRotationPeriod+defaultKeyRetireAfter)) {
+			t.Fatalf("%s: window %v..%v retire %v", alg, k.ActiveFrom, k.ActiveUntil, k.RetireAt)
+		}
+	}
+}
+
+func TestOpenRejectsTamperedKey(t *testing.T) {
+	m := newTestKeyManager(t, testKeyConfig(AlgES256), &memorySigningKeys{})
+	other := newTestKeyManager(t, testKeyConfig(AlgRS256), &memorySigningKeys{})
+	foreign := testKeyConfig(AlgES256)
+	foreign.EncryptionKey = bytes.Repeat([]byte{8}, 32)
+	now := time.Now()
+	row, err := m.generate(now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name    string
+		manager *keyManager
+		tamper  func(r *repo.SigningKeyRow)
+	}{
+		// kid and alg are bound as additional data
+		{"other kid", m, func(r *repo.SigningKeyRow) { r.Kid = "another-kid" }},
+		{"other alg", other, func(r *repo.SigningKeyRow) { r.Alg = AlgRS256 }},
+		{"flipped byte", m, func(r *repo.SigningKeyRow) { r.PrivateKey[len(r.PrivateKey)-1] ^= 1 }},
+		{"truncated", m, func(r *repo.SigningKeyRow) { r.PrivateKey = r.PrivateKey[:4] }},
+		{"other encryption key", newTestKeyManager(t, foreign, &memorySigningKeys{}), func(r *repo.SigningKeyRow) {}},
+	}
+	for _, tc := range cases {
+		tampered := *row
+		tampered.PrivateKey = bytes.Clone(row.PrivateKey)
+		tc.tamper(&tampered)
+		if _, err := tc.manager.open(tampered); err == nil {
+			t.Fatalf("%s: open succeeded", tc.name)
+		}
+	}
+}
+
+func TestNextKey(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	period, retire := defaultKeyRotationPeriod, defaultKeyRetireAfter
+	window := func(alg string, from, until time.Time) repo.SigningKeyRow {
+		return repo.SigningKeyRow{Kid: alg + from.String(), Alg: alg, ActiveFrom: from, ActiveUntil: until, RetireAt: until.Add(retire)}
+	}
+	cases := []struct {
+		name     string
+		existing []repo.SigningKeyRow
+		// wantFrom is the active_from of the new key; zero means none.
+		wantFrom time.Time
+	}{
+		{"no keys", nil, now},
+		{"only another algorithm", []repo.SigningKeyRow{window(AlgRS256, now.Add(-time.Hour), now.Add(period))}, now},
+		{"mid window", []repo.SigningKeyRow{window(AlgES256, now.Add(-time.Hour), now.Add(period-time.Hour))}, time.Time{}},
+		{"just outside the retire window", []repo.SigningKeyRow{window(AlgES256, now.Add(-period), now.Add(retire+time.Second))}, time.Time{}},
+		// within RetireAfter of the end, the next key is published to start where it ends
+		{"retire window reached", []repo.SigningKeyRow{window(AlgES256, now.Add(retire-period), now.Add(retire))}, now.Add(retire)},
+		{"near the end", []repo.SigningKeyRow{window(AlgES256, now.Add(-period+time.Hour), now.Add(time.Hour))}, now.Add(time.Hour)},
+		{"next key already published", []repo.SigningKeyRow{
+			window(AlgES256, now.Add(-period+time.Hour), now.Add(time.Hour)),
+			window(AlgES256, now.Add(time.Hour), now.Add(period+time.Hour)),
+		}, time.Time{}},
+		// a pre-published key alone does not cover now
+		{"only a future key", []repo.SigningKeyRow{window(AlgES256, now.Add(time.Hour), now.Add(period))}, now},
+		{"active window ended, not yet retired", []repo.SigningKeyRow{window(AlgES256, now.Add(-period), now)}, now},
+	}
+	m := newTestKeyManager(t, testKeyConfig(AlgES256), &memorySigningKeys{})
+	for _, tc := range cases {
+		got, err := m.nextKey(tc.existing, now)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if tc.wantFrom.IsZero() {
+			if got != nil {
+				t.Fatalf("%s: new key from %v, want none", tc.name, got.ActiveFrom)
+			}
+			continue
+		}
+		if got == nil {
+			t.Fatalf("%s: no new key, want one from %v", tc.name, tc.wantFrom)
+		}
+		if got.Alg != AlgES256 || !got.ActiveFrom.Equal(tc.wantFrom) || !got.ActiveUntil.Equal(tc.wantFrom.Add(period)) ||
+			!got.RetireAt.Equal(tc.wantFrom.Add(period+retire)) || !got.CreatedAt.Equal(now) {
+			t.Fatalf("%s: new key %s %v..%v retire %v", tc.name, got.Alg, got.ActiveFrom, got.ActiveUntil, got.RetireAt)
+		}
+	}
+}
+
+func TestKeyRotationLifecycle(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	period, retire := defaultKeyRotationPeriod, defaultKeyRetireAfter
+	store := &memorySigningKeys{}
+	m := newTestKeyManager(t, testKeyConfig(AlgRS256), store)
+	// a retired key, one in its grace period and the active one
+	retired := storedKey(t, m, now.Add(-2*period), now.Add(-retire-time.Minute))
+	grace := storedKey(t, m, now.Add(-period), now.Add(-time.Minute))
+	current := storedKey(t, m, now.Add(-time.Minute), now.Add(period-time.Minute))
+	store.rows = []repo.SigningKeyRow{retired, grace, current}
+
+	keys, err := m.published(ctx)
+	if err != nil {
+		t.Fatalf("published: %v", err)
+	}
+	if kids := kidsOf(keys); !slices.Equal(kids, []string{grace.Kid, current.Kid}) {
+		t.Fatalf("published kids = %v, want the grace and current keys", kids)
+	}
+	signing, err := m.signingKey(ctx)
+	if err != nil || signing.Kid != current.Kid {
+		t.Fatalf("signing key = %v, %v, want the current key", signing, err)
+	}
+	if _, err := parseWith(ctx, m, signWith(t, m, grace)); err != nil {
+		t.Fatalf("token of a key in its grace period: %v", err)
+	}
+	if _, err := parseWith(ctx, m, signWith(t, m, retired)); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("token of a retired key: err = %v, want ErrUnknownKey", err)
+	}
+
+	// near the end of its window the next key is published but does not sign yet
+	store.rows = []repo.SigningKeyRow{storedKey(t, m, now.Add(-period+time.Hour), now.Add(time.Hour))}
+	m.keys = nil
+	keys, err = m.published(ctx)
+	if err != nil {
+		t.Fatalf("published: %v", err)
+	}
+	if len(keys) != 2 || !keys[1].ActiveFrom.Equal(keys[0].ActiveUntil) {
+		t.Fatalf("published %d keys, want the current key and the next one starting when it ends", len(keys))
+	}
+	signing, err = m.signingKey(ctx)
+	if err != nil || signing.Kid != keys[0].Kid {
+		t.Fatalf("signing key = %v, %v, want the current key until its window ends", signing, err)
+	}
+}
+
+func TestPublishedServesLastKeysWhenSyncFails(t *testing.T) {
+	ctx := context.Background()
+	store := &memorySigningKeys{}
+	m := newTestKeyManager(t, testKeyConfig(AlgEdDSA), store)
+	good, err := m.published(ctx)
+	if err != nil {
+		t.Fatalf("published: %v", err)
+	}
+
+	store.err = errors.New("database unavailable")
+	m.syncedAt = m.syncedAt.Add(-keySyncInterval)
+	for range 3 {
+		keys, err := m.published(ctx)
+		if err != nil || !slices.Equal(kidsOf(keys), kidsOf(good)) {
+			t.Fatalf("published = %v, %v, want the last synced keys", kidsOf(keys), err)
+		}
+	}
+	// the failed attempt counts as a sync, so later requests wait for the
+	// next interval instead of retrying the database
+	if store.calls != 2 {
+		t.Fatalf("Rotate called %d times, want 2", store.calls)
+	}
+
+	store.err = nil
+	m.syncedAt = m.syncedAt.Add(-keySyncInterval)
+	if _, err := m.published(ctx); err != nil || store.calls != 3 {
+		t.Fatalf("published after the interval: %v, Rotate called %d times, want 3", err, store.calls)
+	}
+}
+
+func TestPublishedFailsWithoutKeys(t *testing.T) {
+	store := &memorySigningKeys{err: errors.New("database unavailable")}
+	m := newTestKeyManager(t, testKeyConfig(AlgES256), store)
+	if _, err := m.published(context.Background()); !errors.Is(err, store.err) {
+		t.Fatalf("published: err = %v, want the sync error", err)
+	}
+}
+
+func TestJWKSPublishesThumbprintKids(t *testing.T) {
+	ctx := context.Background()
+	members := map[string][]string{
+		"RSA": {"e", "kty", "n"},
+		"EC":  {"crv", "kty", "x", "y"},
+		"OKP": {"crv", "kty", "x"},
+	}
+	cases := []struct {
+		alg, kty, crv string
+	}{
+		{AlgRS256, "RSA", ""},
+		{AlgES256, "EC", "P-256"},
+		{AlgEdDSA, "OKP", "Ed25519"},
+	}
+	for _, tc := range cases {
+		s := &OIDCService{keys: newTestKeyManager(t, testKeyConfig(tc.alg), &memorySigningKeys{})}
+		set, err := s.JWKS(ctx)
+		if err != nil {
+			t.Fatalf("%s: JWKS: %v", tc.alg, err)
+		}
+		keys := set["keys"].([]any)
+		if len(keys) != 1 {
+			t.Fatalf("%s: %d keys, want 1", tc.alg, len(keys))
+		}
+		key := keys[0].(map[string]any)
+		if key["kty"] != tc.kty || key["alg"] != tc.alg || key["use"] != "sig" || (tc.crv != "" && key["crv"] != tc.crv) {
+			t.Fatalf("%s: key = %v", tc.alg, key)
+		}
+		// RFC 7638: the required members in lexicographic order, without whitespace
+		var canonical bytes.Buffer
+		canonical.WriteByte('{')
+		for i, name := range members[tc.kty] {
+			if i > 0 {
+				canonical.WriteByte(',')
+			}
+			fmt.Fprintf(&canonical, "%q:%q", name, key[name])
+		}
+		canonical.WriteByte('}')
+		sum := sha256.Sum256(canonical.Bytes())
+		if want := base64.RawURLEncoding.EncodeToString(sum[:]); key["kid"] != want {
+			t.Fatalf("%s: kid = %v, want thumbprint %s", tc.alg, key["kid"], want)
+		}
+	}
+}
+
+// TestThumbprintRFC7638Example checks the example of RFC 7638 section 3.1.
+func TestThumbprintRFC7638Example(t *testing.T) {
+	n, err := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, err := thumbprint(&rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Fatalf("thumbprint = %s", kid)
+	}
+}
+
+func TestKeyFuncVerifiesEachAlgorithm(t *testing.T) {
+	ctx := context.Background()
+	for _, alg := range testAlgorithms {
+		m := newTestKeyManager(t, testKeyConfig(alg), &memorySigningKeys{})
+		key, err := m.signingKey(ctx)
+		if err != nil {
+			t.Fatalf("%s: signing key: %v", alg, err)
+		}
+		token, err := parseWith(ctx, m, signWith(t, m, repo.SigningKeyRow{Kid: key.Kid, Alg: key.Alg}))
+		if err != nil || !token.Valid || token.Method.Alg() != alg {
+			t.Fatalf("%s: verify: %v", alg, err)
+		}
+	}
+}
+
+func TestKeyFuncRejectsUnknownKeys(t *testing.T) {
+	ctx := context.Background()
+	m := newTestKeyManager(t, testKeyConfig(AlgES256), &memorySigningKeys{})
+	stranger := newTestKeyManager(t, testKeyConfig(AlgES256), &memorySigningKeys{})
+	key, err := stranger.signingKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseWith(ctx, m, signWith(t, stranger, repo.SigningKeyRow{Kid: key.Kid, Alg: key.Alg})); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("unknown kid: err = %v, want ErrUnknownKey", err)
+	}
+
+	// a known kid with another algorithm (an RS256 token naming the ES256 key)
+	own, err := m.signingKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := newTestKeyManager(t, testKeyConfig(AlgRS256), &memorySigningKeys{})
+	rsKey, err := rs.signingKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "1"})
+	forged.Header["kid"] = own.Kid
+	signed, err := forged.SignedString(rsKey.Private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseWith(ctx, m, signed); err == nil {
+		t.Fatal("token with a mismatched algorithm was accepted")
+	}
+}
+
+// signWith signs a token with the key the manager's store holds for row.
+func signWith(t *testing.T, m *keyManager, row repo.SigningKeyRow) string {
+	t.Helper()
+	if row.PrivateKey == nil {
+		store := m.repo.(*memorySigningKeys)
+		i := slices.IndexFunc(store.rows, func(r repo.SigningKeyRow) bool { return r.Kid == row.Kid })
+		if i < 0 {
+			t.Fatalf("no stored key %s", row.Kid)
+		}
+		row = store.rows[i]
+	}
+	k, err := m.open(row)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	token := jwt.NewWithClaims(signingMethod(k.Alg), jwt.MapClaims{"sub": "1", "exp": time.Now().Add(time.Minute).Unix()})
+	token.Header["kid"] = k.Kid
+	signed, err := token.SignedString(k.Private)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return signed
+}
+
+func parseWith(ctx context.Context, m *keyManager, token string) (*jwt.Token, error) {
+	return jwt.Parse(token, m.keyFunc(ctx), jwt.WithValidMethods([]string{AlgRS256, AlgES256, AlgEdDSA}))
+}
+
+func kidsOf(keys []*SigningKey) []string {
+	kids := make([]string, 0, len(keys))
+	for _, k := range keys {
+		kids = append(kids, k.Kid)
+	}
+	return kids
+}
@@ -0,0 +1,72 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// AuthCodeRow is an issued authorization code. Only the SHA-256 hash of the
+// code is stored.
+type AuthCodeRow struct {
+	CodeHash      string    `db:"code_hash"`
+	ClientID      string    `db:"client_id"`
+	UserID        int64     `db:"user_id"`
+	RedirectURI   string    `db:"redirect_uri"`
+	Scope         string    `db:"scope"`
+	Nonce         string    `db:"nonce"`
+	CodeChallenge string    `db:"code_challenge"`
+	AuthTime      time.Time `db:"auth_time"`
+	ExpiresAt     time.Time `db:"expires_at"`
+}
+
+type AuthCodeRepo struct {
+	db *sqlx.DB
+}
+
+func NewAuthCodeRepo(db *sqlx.DB) *AuthCodeRepo {
+	return &AuthCodeRepo{db: db}
+}
+
+// EnsureTable creates the authorization code table if not exists (idempotent).
+func (r *AuthCodeRepo) EnsureTable(ctx context.Context) error {
+	const ddl = `
+CREATE TABLE IF NOT EXISTS oidc_auth_codes (
+  code_hash TEXT PRIMARY KEY,
+  client_id TEXT NOT NULL,
+  user_id BIGINT NOT NULL,
+  redirect_uri TEXT NOT NULL,
+  scope TEXT NOT NULL,
+  nonce TEXT NOT NULL DEFAULT '',
+  code_challenge TEXT NOT NULL,
+  auth_time TIMESTAMPTZ NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_oidc_auth_codes_expires_at ON oidc_auth_codes(expires_at);
+`
+	_, err := r.db.ExecContext(ctx, ddl)
+	return err
+}
+
+// Save stores a new code and purges codes that expired without being used.
+func (r *AuthCodeRepo) Save(ctx context.Context, code AuthCodeRow) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM oidc_auth_codes WHERE expires_at < $1`, time.Now()); err != nil {
+		return err
+	}
+	insert := `INSERT INTO oidc_auth_codes (code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge, auth_time, expires_at) VALUES (:code_hash, :client_id, :user_id, :redirect_uri, :scope, :nonce, :code_challenge, :auth_time, :expires_at)`
+	_, err := r.db.NamedExecContext(ctx, insert, code)
+	return err
+}
+
+// Consume deletes the code and returns it, so that a code can be exchanged
+// at most once even under concurrent requests. It returns sql.ErrNoRows for
+// an unknown or already used code; expiry is left to the caller.
+func (r *AuthCodeRepo) Consume(ctx context.Context, codeHash string) (*AuthCodeRow, error) {
+	var row AuthCodeRow
+	query := `DELETE FROM oidc_auth_codes WHERE code_hash = $1 RETURNING code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge, auth_time, expires_at`
+	if err := r.db.GetContext(ctx, &row, query, codeHash); err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
@@ -0,0 +1,69 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ClientRow is a registered OAuth client. SecretHash is the hex SHA-256 of
+// the client secret and is NULL for public clients.
+type ClientRow struct {
+	ClientID           string         `db:"client_id"`
+	Name               string         `db:"client_name"`
+	Type               string         `db:"client_type"`
+	SecretHash         sql.NullString `db:"secret_hash"`
+	RedirectURIs       pq.StringArray `db:"redirect_uris"`
+	Scopes             pq.StringArray `db:"scopes"`
+	FirstParty         bool           `db:"first_party"`
+	AllowPasswordGrant bool           `db:"allow_password_grant"`
+	CreatedAt          time.Time      `db:"created_at"`
+	DisabledAt         sql.NullTime   `db:"disabled_at"`
+}
+
+type ClientRepo struct {
+	db *sqlx.DB
+}
+
+func NewClientRepo(db *sqlx.DB) *ClientRepo {
+	return &ClientRepo{db: db}
+}
+
+// EnsureTable creates the client registry if not exists (idempotent).
+// Clients are registered by inserting rows, for example:
+//
+//	INSERT INTO oidc_clients (client_id, client_name, client_type, secret_hash, redirect_uris, scopes)
+//	VALUES ('crm', 'CRM', 'confidential', encode(sha256('secret'::bytea), 'hex'),
+//	        '{https://crm.example.com/callback}', '{openid,profile,email}');
+func (r *ClientRepo) EnsureTable(ctx context.Context) error {
+	const ddl = `
+CREATE TABLE IF NOT EXISTS oidc_clients (
+  client_id TEXT PRIMARY KEY,
+  client_name TEXT NOT NULL DEFAULT '',
+  client_type TEXT NOT NULL CHECK (client_type IN ('public', 'confidential')),
+  secret_hash TEXT,
+  redirect_uris TEXT[] NOT NULL DEFAULT '{}',
+  scopes TEXT[] NOT NULL DEFAULT '{openid}',
+  first_party BOOLEAN NOT NULL DEFAULT FALSE,
+  allow_password_grant BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  disabled_at TIMESTAMPTZ,
+  CHECK ((client_type = 'confidential') = (secret_hash IS NOT NULL))
+);
+`
+	_, err := r.db.ExecContext(ctx, ddl)
+	return err
+}
+
+// Get returns the client, or sql.ErrNoRows if it is not registered.
+func (r *ClientRepo) Get(ctx context.Context, clientID string) (*ClientRow, error) {
+	var row ClientRow
+	query := `SELECT client_id, client_name, client_type, secret_hash, redirect_uris, scopes, first_party, allow_password_grant, created_at, disabled_at FROM oidc_clients WHERE client_id = $1`
+	if err := r.db.GetContext(ctx, &row, query, clientID); err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
@@ -7,15 +7,6 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
-// NOTE: expected table schema (Postgres example):
-// CREATE TABLE oidc_refresh_sessions (
-//   token TEXT PRIMARY KEY,
-//   id BIGSERIAL,
-//   user_id BIGINT NOT NULL,
-//   client_id TEXT,
-//   expires_at TIMESTAMP WITH TIME ZONE NOT NULL
-// );
-
 type RefreshRepo struct {
 	db *sqlx.DB
 }
@@ -24,28 +15,46 @@ func NewRefreshRepo(db *sqlx.DB) *RefreshRepo {
 	return &RefreshRepo{db: db}
 }
 
-func (r *RefreshRepo) Save(ctx context.Context, token string, userID int64, clientID string, expiresAt time.Time) (int64, error) {
+// EnsureTable creates the refresh session table if not exists (idempotent)
+// and adds the scope column to tables created before scopes were recorded.
+func (r *RefreshRepo) EnsureTable(ctx context.Context) error {
+	const ddl = `
+CREATE TABLE IF NOT EXISTS oidc_refresh_sessions (
+  token TEXT PRIMARY KEY,
+  id BIGSERIAL,
+  user_id BIGINT NOT NULL,
+  client_id TEXT,
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+ALTER TABLE oidc_refresh_sessions ADD COLUMN IF NOT EXISTS scope TEXT NOT NULL DEFAULT '';
+`
+	_, err := r.db.ExecContext(ctx, ddl)
+	return err
+}
+
+func (r *RefreshRepo) Save(ctx context.Context, token string, userID int64, clientID, scope string, expiresAt time.Time) (int64, error) {
 	// try to insert; return id or error
-	query := `INSERT INTO oidc_refresh_sessions (token, user_id, client_id, expires_at) VALUES ($1, $2, $3, $4) RETURNING id`
+	query := `INSERT INTO oidc_refresh_sessions (token, user_id, client_id, scope, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`
 	var id int64
-	row := r.db.QueryRowxContext(ctx, query, token, userID, clientID, expiresAt)
+	row := r.db.QueryRowxContext(ctx, query, token, userID, clientID, scope, expiresAt)
 	if err := row.Scan(&id); err != nil {
 		return 0, err
 	}
 	return id, nil
 }
 
-func (r *RefreshRepo) Get(ctx context.Context, token string) (int64, int64, string, time.Time, error) {
+func (r *RefreshRepo) Get(ctx context.Context, token string) (int64, int64, string, string, time.Time, error) {
 	var id int64
 	var userID int64
 	var clientID string
+	var scope string
 	var expiresAt time.Time
-	query := `SELECT id, user_id, client_id, expires_at FROM oidc_refresh_sessions WHERE token = $1`
+	query := `SELECT id, user_id, client_id, scope, expires_at FROM oidc_refresh_sessions WHERE token = $1`
 	row := r.db.QueryRowxContext(ctx, query, token)
-	if err := row.Scan(&id, &userID, &clientID, &expiresAt); err != nil {
-		return 0, 0, "", time.Time{}, err
+	if err := row.Scan(&id, &userID, &clientID, &scope, &expiresAt); err != nil {
+		return 0, 0, "", "", time.Time{}, err
 	}
-	return id, userID, clientID, expiresAt, nil
+	return id, userID, clientID, scope, expiresAt, nil
 }
 
 func (r *RefreshRepo) Delete(ctx context.Context, token string) error {
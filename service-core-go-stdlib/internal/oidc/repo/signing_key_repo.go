@@ -0,0 +1,90 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// signingKeyLockID is the pg_advisory_xact_lock key that serialises key
+// rotation across replicas.
+const signingKeyLockID = 0x6f6964636b657973
+
+// SigningKeyRow is a stored signing key. PrivateKey is the AES-GCM sealed
+// PKCS#8 encoding; the repository never sees the plain key.
+type SigningKeyRow struct {
+	Kid         string    `db:"kid"`
+	Alg         string    `db:"alg"`
+	PrivateKey  []byte    `db:"private_key"`
+	ActiveFrom  time.Time `db:"active_from"`
+	ActiveUntil time.Time `db:"active_until"`
+	RetireAt    time.Time `db:"retire_at"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+type SigningKeyRepo struct {
+	db *sqlx.DB
+}
+
+func NewSigningKeyRepo(db *sqlx.DB) *SigningKeyRepo {
+	return &SigningKeyRepo{db: db}
+}
+
+// EnsureTable creates the signing key table if not exists (idempotent).
+// A key signs tokens between active_from and active_until and stays in the
+// JWKS until retire_at, so tokens it signed can still be verified.
+func (r *SigningKeyRepo) EnsureTable(ctx context.Context) error {
+	const ddl = `
+CREATE TABLE IF NOT EXISTS oidc_signing_keys (
+  kid TEXT PRIMARY KEY,
+  alg TEXT NOT NULL,
+  private_key BYTEA NOT NULL,
+  active_from TIMESTAMPTZ NOT NULL,
+  active_until TIMESTAMPTZ NOT NULL,
+  retire_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_oidc_signing_keys_retire_at ON oidc_signing_keys(retire_at);
+`
+	_, err := r.db.ExecContext(ctx, ddl)
+	return err
+}
+
+// Rotate deletes retired keys, passes the remaining keys (ordered by
+// active_from) to next and stores the key it returns, if any. It runs under
+// an advisory lock so that two replicas never both add a key.
+func (r *SigningKeyRepo) Rotate(ctx context.Context, now time.Time, next func([]SigningKeyRow) (*SigningKeyRow, error)) ([]SigningKeyRow, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, signingKeyLockID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM oidc_signing_keys WHERE retire_at <= $1`, now); err != nil {
+		return nil, err
+	}
+	rows := []SigningKeyRow{}
+	query := `SELECT kid, alg, private_key, active_from, active_until, retire_at, created_at FROM oidc_signing_keys ORDER BY active_from`
+	if err := tx.SelectContext(ctx, &rows, query); err != nil {
+		return nil, err
+	}
+	key, err := next(rows)
+	if err != nil {
+		return nil, err
+	}
+	if key != nil {
+		insert := `INSERT INTO oidc_signing_keys (kid, alg, private_key, active_from, active_until, retire_at, created_at) VALUES (:kid, :alg, :private_key, :active_from, :active_until, :retire_at, :created_at)`
+		if _, err := tx.NamedExecContext(ctx, insert, key); err != nil {
+			return nil, err
+		}
+		rows = append(rows, *key)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,194 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// envTestDatabaseURL names the Postgres DSN the repository tests run
+// against. The DSN must allow CREATE SCHEMA; the tests skip when it is
+// unset, so `go test ./...` never needs a database.
+const envTestDatabaseURL = "CORE_TEST_DATABASE_URL"
+
+// openTestDB connects to a fresh schema that is dropped on cleanup.
+func openTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	dsn := os.Getenv(envTestDatabaseURL)
+	if dsn == "" {
+		t.Skipf("%s is not set", envTestDatabaseURL)
+	}
+	admin, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatalf("connect test database: %v", err)
+	}
+	schema := fmt.Sprintf("core_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatalf("create test schema: %v", err)
+	}
+	// lib/pq sends unknown DSN parameters as run-time parameters, so every
+	// pooled connection starts in the test schema.
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			t.Fatalf("parse %s: %v", envTestDatabaseURL, err)
+		}
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		dsn = u.String()
+	} else {
+		dsn += " search_path=" + schema
+	}
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatalf("connect test schema: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+	return db
+}
+
+func newTestSigningKeyRepo(t *testing.T) *SigningKeyRepo {
+	t.Helper()
+	r := NewSigningKeyRepo(openTestDB(t))
+	if err := r.EnsureTable(context.Background()); err != nil {
+		t.Fatalf("EnsureTable: %v", err)
+	}
+	return r
+}
+
+func testSigningKey(kid string, activeFrom time.Time) *SigningKeyRow {
+	activeUntil := activeFrom.Add(30 * 24 * time.Hour)
+	return &SigningKeyRow{
+		Kid:         kid,
+		Alg:         "ES256",
+		PrivateKey:  []byte("sealed-" + kid),
+		ActiveFrom:  activeFrom,
+		ActiveUntil: activeUntil,
+		RetireAt:    activeUntil.Add(24 * time.Hour),
+		CreatedAt:   activeFrom,
+	}
+}
+
+func kids(rows []SigningKeyRow) string {
+	out := make([]string, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, row.Kid)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestSigningKeyRepoRotate(t *testing.T) {
+	ctx := context.Background()
+	r := newTestSigningKeyRepo(t)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	first := testSigningKey("k1", now)
+	earlier := testSigningKey("k0", now.Add(-time.Hour))
+	errNext := errors.New("next failed")
+
+	steps := []struct {
+		name string
+		now  time.Time
+		// add is returned by next; seen is the kids next must receive.
+		add      *SigningKeyRow
+		nextErr  error
+		seen     string
+		wantRows string
+	}{
+		{"empty table", now, first, nil, "", "k1"},
+		{"nothing to add", now, nil, nil, "k1", "k1"},
+		// a failing next rolls the transaction back without storing its key
+		{"next fails", now, testSigningKey("k2", now), errNext, "k1", ""},
+		{"ordered by active_from", now, earlier, nil, "k1", "k1,k0"},
+		{"stored order", now, nil, nil, "k0,k1", "k0,k1"},
+		// keys past retire_at are deleted before next sees them
+		{"retired keys deleted", earlier.RetireAt, nil, nil, "k1", "k1"},
+	}
+	for _, step := range steps {
+		var seen []SigningKeyRow
+		rows, err := r.Rotate(ctx, step.now, func(existing []SigningKeyRow) (*SigningKeyRow, error) {
+			seen = existing
+			return step.add, step.nextErr
+		})
+		if step.nextErr != nil {
+			if !errors.Is(err, step.nextErr) {
+				t.Fatalf("%s: err = %v, want %v", step.name, err, step.nextErr)
+			}
+		} else if err != nil {
+			t.Fatalf("%s: Rotate: %v", step.name, err)
+		}
+		if got := kids(seen); got != step.seen {
+			t.Fatalf("%s: next saw %q, want %q", step.name, got, step.seen)
+		}
+		if got := kids(rows); got != step.wantRows {
+			t.Fatalf("%s: rows = %q, want %q", step.name, got, step.wantRows)
+		}
+	}
+
+	rows, err := r.Rotate(ctx, now, func([]SigningKeyRow) (*SigningKeyRow, error) { return nil, nil })
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("rows = %q, want only k1", kids(rows))
+	}
+	got := rows[0]
+	if got.Alg != first.Alg || string(got.PrivateKey) != string(first.PrivateKey) || !got.ActiveFrom.Equal(first.ActiveFrom) ||
+		!got.ActiveUntil.Equal(first.ActiveUntil) || !got.RetireAt.Equal(first.RetireAt) || !got.CreatedAt.Equal(first.CreatedAt) {
+		t.Fatalf("stored k1 = %+v, want %+v", got, *first)
+	}
+}
+
+// TestSigningKeyRepoRotateSerializes: replicas rotating at the same time
+// must not both add a key; the advisory lock makes the later one see the
+// key the earlier one stored.
+func TestSigningKeyRepoRotateSerializes(t *testing.T) {
+	ctx := context.Background()
+	r := newTestSigningKeyRepo(t)
+	now := time.Now().UTC()
+
+	const replicas = 4
+	var wg sync.WaitGroup
+	errs := make(chan error, replicas)
+	for i := range replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := r.Rotate(ctx, now, func(existing []SigningKeyRow) (*SigningKeyRow, error) {
+				if len(existing) > 0 {
+					return nil, nil
+				}
+				return testSigningKey(fmt.Sprintf("replica-%d", i), now), nil
+			})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Rotate: %v", err)
+		}
+	}
+	rows, err := r.Rotate(ctx, now, func([]SigningKeyRow) (*SigningKeyRow, error) { return nil, nil })
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("rows = %q, want exactly one key", kids(rows))
+	}
+}
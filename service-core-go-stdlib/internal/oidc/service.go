@@ -3,12 +3,14 @@ package oidc
 import (
 	"context"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
 	"encoding/base64"
-	"encoding/json"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"math/big"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -19,61 +21,235 @@ import (
 
 // OIDCService manages signing keys and token issuance.
 type OIDCService struct {
-	key    *rsa.PrivateKey
-	kid    string
+	keys   *keyManager
 	issuer string
 	// DB-backed refresh repository
-	refreshRepo *repo.RefreshRepo
+	refreshRepo  *repo.RefreshRepo
+	clientRepo   clientStore
+	authCodeRepo authCodeStore
 }
 
+// clientStore and authCodeStore are the parts of the client and
+// authorization code repositories the authorization code flow uses.
+type clientStore interface {
+	Get(ctx context.Context, clientID string) (*repo.ClientRow, error)
+}
+
+type authCodeStore interface {
+	Save(ctx context.Context, code repo.AuthCodeRow) error
+	Consume(ctx context.Context, codeHash string) (*repo.AuthCodeRow, error)
+}
+
+// authCodeTTL is how long an authorization code can be exchanged.
+const authCodeTTL = time.Minute
+
+var (
+	ErrInvalidClient = errors.New("invalid client")
+	ErrInvalidGrant  = errors.New("invalid grant")
+)
+
+// NewOIDCService loads the signing keys from the database, creating the
+// first key if there is none. Key settings come from KeyConfigFromEnv.
 func NewOIDCService(db *sqlx.DB, issuer string) (*OIDCService, error) {
-	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	cfg, err := KeyConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	keyRepo := repo.NewSigningKeyRepo(db)
+	if err := keyRepo.EnsureTable(context.Background()); err != nil {
+		return nil, err
+	}
+	km, err := newKeyManager(keyRepo, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := km.published(context.Background()); err != nil {
+		return nil, err
+	}
+	clientRepo := repo.NewClientRepo(db)
+	authCodeRepo := repo.NewAuthCodeRepo(db)
+	s := &OIDCService{
+		keys:         km,
+		issuer:       issuer,
+		refreshRepo:  repo.NewRefreshRepo(db),
+		clientRepo:   clientRepo,
+		authCodeRepo: authCodeRepo,
+	}
+	for _, ensure := range []func(context.Context) error{s.refreshRepo.EnsureTable, clientRepo.EnsureTable, authCodeRepo.EnsureTable} {
+		if err := ensure(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// JWKS returns every signing key that has not retired, including keys that
+// are published ahead of their active window.
+func (s *OIDCService) JWKS(ctx context.Context) (map[string]any, error) {
+	keys, err := s.keys.published(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]any, 0, len(keys))
+	for _, k := range keys {
+		j, err := jwk(k.Private.Public())
+		if err != nil {
+			return nil, err
+		}
+		j["use"] = "sig"
+		j["alg"] = k.Alg
+		j["kid"] = k.Kid
+		out = append(out, j)
+	}
+	return map[string]any{"keys": out}, nil
+}
+
+// KeyFunc resolves the verification key of a token by its kid.
+func (s *OIDCService) KeyFunc(ctx context.Context) jwt.Keyfunc {
+	return s.keys.keyFunc(ctx)
+}
+
+// SigningAlgorithms lists the algorithms tokens may be signed with.
+func (s *OIDCService) SigningAlgorithms() []string {
+	return []string{AlgRS256, AlgES256, AlgEdDSA}
+}
+
+// Client returns an enabled registered client, or ErrInvalidClient.
+func (s *OIDCService) Client(ctx context.Context, clientID string) (*Client, error) {
+	if clientID == "" {
+		return nil, ErrInvalidClient
+	}
+	row, err := s.clientRepo.Get(ctx, clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidClient
+	}
 	if err != nil {
 		return nil, err
 	}
-	// generate simple kid as base64 of SHA256 of public key
-	pubBytes, _ := json.Marshal(k.PublicKey)
-	h := sha256.Sum256(pubBytes)
-	kid := base64.RawURLEncoding.EncodeToString(h[:8])
-	r := repo.NewRefreshRepo(db)
-	return &OIDCService{key: k, kid: kid, issuer: issuer, refreshRepo: r}, nil
+	if row.DisabledAt.Valid {
+		return nil, ErrInvalidClient
+	}
+	return &Client{
+		ID:                 row.ClientID,
+		Name:               row.Name,
+		Type:               row.Type,
+		SecretHash:         row.SecretHash.String,
+		RedirectURIs:       row.RedirectURIs,
+		Scopes:             row.Scopes,
+		FirstParty:         row.FirstParty,
+		AllowPasswordGrant: row.AllowPasswordGrant,
+	}, nil
 }
 
-// JWKS returns a minimal JWKS containing the public key.
-func (s *OIDCService) JWKS() (map[string]any, error) {
-	pub := s.key.PublicKey
-	n := base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
-	// encode exponent using big.Int to get minimal big-endian bytes
-	e := base64.RawURLEncoding.EncodeToString(new(big.Int).SetInt64(int64(pub.E)).Bytes())
-	jwk := map[string]any{
-		"kty": "RSA",
-		"use": "sig",
-		"alg": "RS256",
-		"kid": s.kid,
-		"n":   n,
-		"e":   e,
+// AuthenticateClient checks the credentials sent to the token endpoint.
+// Confidential clients must present their secret; public clients must not
+// send one.
+func (s *OIDCService) AuthenticateClient(ctx context.Context, clientID, secret string) (*Client, error) {
+	c, err := s.Client(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	switch c.Type {
+	case ClientConfidential:
+		h := sha256.Sum256([]byte(secret))
+		if secret == "" || subtle.ConstantTimeCompare([]byte(hex.EncodeToString(h[:])), []byte(strings.ToLower(c.SecretHash))) != 1 {
+			return nil, ErrInvalidClient
+		}
+	case ClientPublic:
+		if secret != "" {
+			return nil, ErrInvalidClient
+		}
+	default:
+		return nil, ErrInvalidClient
 	}
-	return map[string]any{"keys": []any{jwk}}, nil
+	return c, nil
 }
 
-// PublicKey returns the RSA public key for verification.
-func (s *OIDCService) PublicKey() *rsa.PublicKey {
-	return &s.key.PublicKey
+// CreateAuthCode stores a one-time authorization code for an approved
+// authorization request and returns it. Only its hash is persisted.
+func (s *OIDCService) CreateAuthCode(ctx context.Context, userID int64, req *AuthorizeRequest, authTime time.Time) (string, error) {
+	code, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	err = s.authCodeRepo.Save(ctx, repo.AuthCodeRow{
+		CodeHash:      hashToken(code),
+		ClientID:      req.Client.ID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         req.Scope,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      authTime,
+		ExpiresAt:     authTime.Add(authCodeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return code, nil
 }
 
-func bigIntToBytes(i int) []byte {
-	// retained for backward-compat if called elsewhere; convert via big.Int
-	return new(big.Int).SetInt64(int64(i)).Bytes()
+// ExchangeAuthCode consumes a code issued to client and checks the
+// redirect URI and PKCE verifier. The code is consumed even if a check
+// fails, so a stolen code cannot be retried.
+func (s *OIDCService) ExchangeAuthCode(ctx context.Context, client *Client, code, redirectURI, verifier string) (int64, Grant, error) {
+	row, err := s.authCodeRepo.Consume(ctx, hashToken(code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, Grant{}, ErrInvalidGrant
+	}
+	if err != nil {
+		return 0, Grant{}, err
+	}
+	if row.ClientID != client.ID || row.RedirectURI != redirectURI || !time.Now().Before(row.ExpiresAt) || !verifyPKCE(row.CodeChallenge, verifier) {
+		return 0, Grant{}, ErrInvalidGrant
+	}
+	return row.UserID, Grant{ClientID: row.ClientID, Scope: row.Scope, Nonce: row.Nonce, AuthTime: row.AuthTime}, nil
+}
+
+// verifyPKCE checks an RFC 7636 code_verifier against its S256 challenge.
+func verifyPKCE(challenge, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	for _, c := range verifier {
+		if !isUnreserved(c) {
+			return false
+		}
+	}
+	h := sha256.Sum256([]byte(verifier))
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(h[:])), []byte(challenge)) == 1
+}
+
+func isUnreserved(c rune) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.ContainsRune("-._~", c)
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
 }
 
 // IssueTokens creates an id_token and access_token for the given user.
-func (s *OIDCService) IssueTokens(ctx context.Context, u *entity.MinimalAuthView, audience string, ttl time.Duration) (idToken string, accessToken string, refreshToken string, err error) {
+func (s *OIDCService) IssueTokens(ctx context.Context, u *entity.MinimalAuthView, grant Grant, ttl time.Duration) (idToken string, accessToken string, refreshToken string, err error) {
+	key, err := s.keys.signingKey(ctx)
+	if err != nil {
+		return "", "", "", err
+	}
+	method := signingMethod(key.Alg)
 	now := time.Now()
 	// ID Token
 	idClaims := jwt.MapClaims{
 		"iss":            s.issuer,
 		"sub":            fmt.Sprintf("%d", u.ID),
-		"aud":            audience,
+		"aud":            grant.ClientID,
 		"exp":            now.Add(ttl).Unix(),
 		"iat":            now.Unix(),
 		"v":              u.Version,
@@ -81,9 +257,15 @@ func (s *OIDCService) IssueTokens(ctx context.Context, u *entity.MinimalAuthView
 		"email":          u.Email,
 		"email_verified": u.EmailVerified,
 	}
-	idTok := jwt.NewWithClaims(jwt.SigningMethodRS256, idClaims)
-	idTok.Header["kid"] = s.kid
-	signedID, err := idTok.SignedString(s.key)
+	if grant.Nonce != "" {
+		idClaims["nonce"] = grant.Nonce
+	}
+	if !grant.AuthTime.IsZero() {
+		idClaims["auth_time"] = grant.AuthTime.Unix()
+	}
+	idTok := jwt.NewWithClaims(method, idClaims)
+	idTok.Header["kid"] = key.Kid
+	signedID, err := idTok.SignedString(key.Private)
 	if err != nil {
 		return "", "", "", err
 	}
@@ -92,31 +274,33 @@ func (s *OIDCService) IssueTokens(ctx context.Context, u *entity.MinimalAuthView
 	accessClaims := jwt.MapClaims{
 		"iss":       s.issuer,
 		"sub":       fmt.Sprintf("%d", u.ID),
-		"aud":       audience,
+		"aud":       grant.ClientID,
 		"exp":       now.Add(ttl).Unix(),
 		"iat":       now.Unix(),
 		"v":         u.Version,
 		"user_type": u.UserType,
+		"client_id": grant.ClientID,
+		"scope":     grant.Scope,
 	}
-	access := jwt.NewWithClaims(jwt.SigningMethodRS256, accessClaims)
-	access.Header["kid"] = s.kid
-	signedAccess, err := access.SignedString(s.key)
+	access := jwt.NewWithClaims(method, accessClaims)
+	access.Header["kid"] = key.Kid
+	signedAccess, err := access.SignedString(key.Private)
 	if err != nil {
 		return "", "", "", err
 	}
 
 	// create a simple opaque refresh token and persist session in DB
-	rtBytes := make([]byte, 32)
-	if _, err := rand.Read(rtBytes); err != nil {
+	refresh, err := randomToken()
+	if err != nil {
 		return "", "", "", err
 	}
-	refresh := base64.RawURLEncoding.EncodeToString(rtBytes)
 	rs := RefreshSession{
 		UserID:    u.ID,
-		ClientID:  audience,
+		ClientID:  grant.ClientID,
+		Scope:     grant.Scope,
 		ExpiresAt: now.Add(30 * 24 * time.Hour),
 	}
-	id, err := s.refreshRepo.Save(ctx, refresh, rs.UserID, rs.ClientID, rs.ExpiresAt)
+	id, err := s.refreshRepo.Save(ctx, refresh, rs.UserID, rs.ClientID, rs.Scope, rs.ExpiresAt)
 	if err != nil {
 		return "", "", "", err
 	}
@@ -127,11 +311,11 @@ func (s *OIDCService) IssueTokens(ctx context.Context, u *entity.MinimalAuthView
 
 // ValidateRefreshToken checks an opaque refresh token and returns the session if valid.
 func (s *OIDCService) ValidateRefreshToken(ctx context.Context, token string) (*RefreshSession, bool) {
-	id, userID, clientID, expiresAt, err := s.refreshRepo.Get(ctx, token)
+	id, userID, clientID, scope, expiresAt, err := s.refreshRepo.Get(ctx, token)
 	if err != nil {
 		return nil, false
 	}
-	rs := RefreshSession{ID: id, UserID: userID, ClientID: clientID, ExpiresAt: expiresAt}
+	rs := RefreshSession{ID: id, UserID: userID, ClientID: clientID, Scope: scope, ExpiresAt: expiresAt}
 	if rs.ExpiresAt.Before(time.Now()) {
 		return nil, false
 	}
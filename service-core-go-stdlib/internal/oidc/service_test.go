@@ -0,0 +1,144 @@
+package oidc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	repo "github.com/ovaphlow/pitchfork/service-core-go-stdlib/internal/oidc/repo"
+)
+
+// The verifier and challenge of RFC 7636 appendix B.
+const (
+	testVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+type memoryClients map[string]*repo.ClientRow
+
+func (m memoryClients) Get(ctx context.Context, clientID string) (*repo.ClientRow, error) {
+	row, ok := m[clientID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return row, nil
+}
+
+type memoryAuthCodes struct {
+	mu    sync.Mutex
+	codes map[string]repo.AuthCodeRow
+}
+
+func (m *memoryAuthCodes) Save(ctx context.Context, code repo.AuthCodeRow) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.codes[code.CodeHash] = code
+	return nil
+}
+
+func (m *memoryAuthCodes) Consume(ctx context.Context, codeHash string) (*repo.AuthCodeRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	row, ok := m.codes[codeHash]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	delete(m.codes, codeHash)
+	return &row, nil
+}
+
+func newTestService() *OIDCService {
+	return &OIDCService{
+		issuer: "https://id.example.test",
+		clientRepo: memoryClients{
+			"spa": {ClientID: "spa", Name: "SPA", Type: ClientPublic, RedirectURIs: []string{"https://spa.example.test/callback"}, Scopes: []string{"openid", "profile"}},
+			"cli": {ClientID: "cli", Name: "CLI", Type: ClientPublic, RedirectURIs: []string{"http://127.0.0.1/callback"}, Scopes: []string{"openid"}},
+		},
+		authCodeRepo: &memoryAuthCodes{codes: map[string]repo.AuthCodeRow{}},
+	}
+}
+
+func TestVerifyPKCE(t *testing.T) {
+	tests := []struct {
+		name      string
+		challenge string
+		verifier  string
+		want      bool
+	}{
+		{"matching verifier", testChallenge, testVerifier, true},
+		{"wrong verifier", testChallenge, strings.Replace(testVerifier, "d", "e", 1), false},
+		{"challenge as verifier", testChallenge, testChallenge, false},
+		{"too short", testChallenge, testVerifier[:42], false},
+		{"too long", testChallenge, strings.Repeat("a", 129), false},
+		{"reserved character", testChallenge, testVerifier[:42] + "+", false},
+		{"empty challenge", "", testVerifier, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyPKCE(tt.challenge, tt.verifier); got != tt.want {
+				t.Fatalf("verifyPKCE() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExchangeAuthCode(t *testing.T) {
+	tests := []struct {
+		name        string
+		clientID    string
+		redirectURI string
+		verifier    string
+		authTime    time.Time
+		reuse       bool
+		wantErr     error
+	}{
+		{name: "valid", clientID: "spa", redirectURI: "https://spa.example.test/callback", verifier: testVerifier},
+		{name: "reused code", clientID: "spa", redirectURI: "https://spa.example.test/callback", verifier: testVerifier, reuse: true, wantErr: ErrInvalidGrant},
+		{name: "redirect mismatch", clientID: "spa", redirectURI: "https://spa.example.test/other", verifier: testVerifier, wantErr: ErrInvalidGrant},
+		{name: "other client", clientID: "cli", redirectURI: "https://spa.example.test/callback", verifier: testVerifier, wantErr: ErrInvalidGrant},
+		{name: "wrong verifier", clientID: "spa", redirectURI: "https://spa.example.test/callback", verifier: strings.Repeat("a", 43), wantErr: ErrInvalidGrant},
+		{name: "expired", clientID: "spa", redirectURI: "https://spa.example.test/callback", verifier: testVerifier, authTime: time.Now().Add(-2 * authCodeTTL), wantErr: ErrInvalidGrant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc := newTestService()
+			spa, err := svc.Client(ctx, "spa")
+			if err != nil {
+				t.Fatalf("client: %v", err)
+			}
+			authTime := tt.authTime
+			if authTime.IsZero() {
+				authTime = time.Now()
+			}
+			code, err := svc.CreateAuthCode(ctx, 42, &AuthorizeRequest{Client: spa, RedirectURI: "https://spa.example.test/callback", Scope: "openid", Nonce: "n-1", CodeChallenge: testChallenge}, authTime)
+			if err != nil {
+				t.Fatalf("create code: %v", err)
+			}
+			client, err := svc.Client(ctx, tt.clientID)
+			if err != nil {
+				t.Fatalf("client: %v", err)
+			}
+			if tt.reuse {
+				if _, _, err := svc.ExchangeAuthCode(ctx, client, code, tt.redirectURI, tt.verifier); err != nil {
+					t.Fatalf("first exchange: %v", err)
+				}
+			}
+			userID, grant, err := svc.ExchangeAuthCode(ctx, client, code, tt.redirectURI, tt.verifier)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("exchange error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (userID != 42 || grant.ClientID != "spa" || grant.Nonce != "n-1" || grant.Scope != "openid") {
+				t.Fatalf("exchange = %d, %+v", userID, grant)
+			}
+			// a failed check consumes the code as well
+			if _, _, err := svc.ExchangeAuthCode(ctx, spa, code, "https://spa.example.test/callback", testVerifier); !errors.Is(err, ErrInvalidGrant) {
+				t.Fatalf("exchange after use error = %v", err)
+			}
+		})
+	}
+}
@@ -201,6 +201,12 @@ func RegisterRoutes(logger *zap.SugaredLogger, db *sqlx.DB) http.Handler {
 		mux.HandleFunc("GET /jwks.json", func(w http.ResponseWriter, r *http.Request) {
 			oidcHandler.JWKS(w, r)
 		})
+		mux.HandleFunc("GET /authorize", func(w http.ResponseWriter, r *http.Request) {
+			oidcHandler.Authorize(w, r)
+		})
+		mux.HandleFunc("POST /authorize", func(w http.ResponseWriter, r *http.Request) {
+			oidcHandler.Authorize(w, r)
+		})
 		mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
 			oidcHandler.Token(w, r)
 		})
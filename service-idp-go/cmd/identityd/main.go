@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/db/migrations"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/auditlog"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/config"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/httpapi"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/ldap"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/logging"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/oidc"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/password"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/verification"
+)
+
+const usage = "usage: identityd [serve | migrate [status] | recover-admin -identifier ACCOUNT | import-subjects [-dry-run] [-format json|csv] FILE | export-subjects [-format json|csv] | purge | verify-audit-log FILE]"
+
+const shutdownTimeout = 10 * time.Second
+
+const auditDispatchInterval = 5 * time.Second
+
+func main() {
+	runContext, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	os.Exit(run(runContext, os.Args[1:], os.Getenv, os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run dispatches the subcommand and returns the process exit code. Usage
+// errors exit 2; every other failure exits 1.
+func run(ctx context.Context, args []string, lookup func(string) string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	command := "serve"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	switch command {
+	case "serve":
+		if len(args) != 0 {
+			break
+		}
+		return runServe(ctx, lookup, stdout, stderr)
+	case "migrate":
+		return runMigrate(ctx, args, lookup, stdout, stderr)
+	case "recover-admin":
+		return runRecoverAdmin(ctx, args, lookup, stdin, stdout, stderr)
+	case "import-subjects":
+		return runImportSubjects(ctx, args, lookup, stdout, stderr)
+	case "export-subjects":
+		return runExportSubjects(ctx, args, lookup, stdout, stderr)
+	case "purge":
+		if len(args) != 0 {
+			break
+		}
+		return runPurge(ctx, lookup, stdout, stderr)
+	case "verify-audit-log":
+		return runVerifyAuditLog(args, stdout, stderr)
+	}
+	fmt.Fprintln(stderr, usage)
+	return 2
+}
+
+func runServe(ctx context.Context, lookup func(string) string, stdout io.Writer, stderr io.Writer) int {
+	configuration, err := config.LoadFromLookup(lookup)
+	if err != nil {
+		slog.New(slog.NewTextHandler(stderr, nil)).Error("load configuration", "error", err)
+		return 1
+	}
+	logger, closeLogger, err := logging.New("logs", stdout)
+	if err != nil {
+		slog.New(slog.NewTextHandler(stderr, nil)).Error("open log directory", "error", err)
+		return 1
+	}
+	defer closeLogger()
+
+	databaseConnection, err := openDatabase(ctx, logger, configuration)
+	if err != nil {
+		logger.Error("open database", "error", err)
+		return 1
+	}
+	defer databaseConnection.Close()
+
+	created, err := identity.EnsureBootstrap(ctx, databaseConnection, identity.BootstrapInput{
+		Identifier: configuration.BootstrapIdentifier,
+		Password:   configuration.BootstrapPassword,
+	})
+	if err != nil {
+		logger.Error("bootstrap administrator", "error", err)
+		return 1
+	}
+	if created {
+		logger.Info("bootstrap administrator created", "identifier", configuration.BootstrapIdentifier)
+	}
+
+	provider, err := newOIDCProvider(ctx, databaseConnection, configuration)
+	if err != nil {
+		logger.Error("configure OIDC", "error", err)
+		return 1
+	}
+	if provider == nil {
+		logger.Info("OIDC disabled; set IDENTITYD_OIDC_SIGNING_KEY_FILE to enable it")
+		logger.Warn("SCIM provisioning disabled; it needs OIDC, so /scim/v2 answers 503")
+	}
+
+	verificationSender, err := newVerificationSender(logger, configuration)
+	if err != nil {
+		logger.Error("configure verification sender", "error", err)
+		return 1
+	}
+
+	passwordPolicy, err := newPasswordPolicy(configuration)
+	if err != nil {
+		logger.Error("configure password policy", "error", err)
+		return 1
+	}
+
+	directories, err := newDirectories(configuration)
+	if err != nil {
+		logger.Error("configure LDAP directory", "error", err)
+		return 1
+	}
+
+	auditSinks, closeAuditSinks, err := newAuditSinks(configuration)
+	if err != nil {
+		logger.Error("configure audit sinks", "error", err)
+		return 1
+	}
+	stopAuditDispatcher := startAuditDispatcher(ctx, logger, databaseConnection, auditSinks)
+	defer func() {
+		stopAuditDispatcher()
+		closeAuditSinks()
+	}()
+
+	server := &http.Server{
+		Addr: configuration.Address,
+		Handler: httpapi.NewMux(databaseConnection, httpapi.Options{
+			SessionSettings: identity.SessionSettings{
+				TTL:     configuration.SessionTTL,
+				IdleTTL: configuration.SessionIdleTTL,
+			},
+			LoginThrottle:         loginThrottleSettings(configuration),
+			PasswordPolicy:        passwordPolicy,
+			SecureSessionCookie:   configuration.SecureSessionCookie,
+			TrustedProxyPrefixes:  configuration.TrustedProxyPrefixes,
+			CorsOrigins:           configuration.CorsOrigins,
+			OIDC:                  provider,
+			VerificationSender:    verificationSender,
+			PasswordResetNotifier: verificationSender,
+			Directories:           directories,
+		}),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	serverErrors := make(chan error, 1)
+	go func() {
+		serverErrors <- server.ListenAndServe()
+	}()
+	logger.Info("identityd listening", "address", configuration.Address)
+
+	select {
+	case err := <-serverErrors:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("serve HTTP", "error", err)
+			return 1
+		}
+	case <-ctx.Done():
+		shutdownContext, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownContext); err != nil {
+			logger.Error("shutdown HTTP", "error", err)
+			return 1
+		}
+		logger.Info("identityd stopped")
+	}
+	return 0
+}
+
+// openDatabase opens the configured SQLite database and applies the pending
+// embedded migrations.
+func openDatabase(ctx context.Context, logger *slog.Logger, configuration config.Config) (*sql.DB, error) {
+	databaseConnection, err := database.OpenSQLite(ctx, configuration.DatabasePath)
+	if err != nil {
+		return nil, err
+	}
+	result, err := database.Migrate(ctx, databaseConnection, migrations.Files)
+	if err != nil {
+		databaseConnection.Close()
+		return nil, err
+	}
+	if result.Applied > 0 {
+		logger.Info("migrations applied", "count", result.Applied)
+	}
+	return databaseConnection, nil
+}
+
+// newOIDCProvider returns nil when no signing key is configured.
+func newOIDCProvider(ctx context.Context, databaseConnection *sql.DB, configuration config.Config) (*oidc.Provider, error) {
+	if configuration.OIDCSigningKeyFile == "" {
+		return nil, nil
+	}
+	signer, err := oidc.LoadSigner(configuration.OIDCSigningKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := oidc.NewProvider(databaseConnection, oidc.Settings{
+		Issuer:               configuration.PublicURL.String(),
+		Signer:               signer,
+		AccessTokenTTL:       configuration.OIDCAccessTokenTTL,
+		AuthorizationCodeTTL: configuration.OIDCAuthorizationCodeTTL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := provider.RegisterSigningKey(ctx); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// newVerificationSender returns a development sender for verification codes
+// and password reset tokens; the log sender writes them to the service log
+// and the file sender appends them to a JSON Lines file.
+func newVerificationSender(logger *slog.Logger, configuration config.Config) (verification.Sender, error) {
+	if configuration.VerificationSender == "file" {
+		return verification.NewFileSender(configuration.VerificationFile)
+	}
+	return verification.LogSender{Logger: logger}, nil
+}
+
+// newPasswordPolicy merges the optional local denylist file into the
+// embedded list of common passwords.
+func newPasswordPolicy(configuration config.Config) (identity.PasswordPolicy, error) {
+	denylist := password.CommonPasswords()
+	if configuration.PasswordDenylistFile != "" {
+		file, err := os.Open(configuration.PasswordDenylistFile)
+		if err != nil {
+			return identity.PasswordPolicy{}, fmt.Errorf("open password denylist: %w", err)
+		}
+		defer file.Close()
+		local, err := password.ParseDenylist(file)
+		if err != nil {
+			return identity.PasswordPolicy{}, err
+		}
+		denylist = denylist.Merge(local)
+	}
+	return identity.PasswordPolicy{
+		MinimumLength: configuration.PasswordMinimumLength,
+		Denylist:      denylist,
+		HistorySize:   configuration.PasswordHistorySize,
+		MaximumAge:    configuration.PasswordMaximumAge,
+	}, nil
+}
+
+// newDirectories returns no directories when IDENTITYD_LDAP_URL is unset.
+func newDirectories(configuration config.Config) ([]identity.Directory, error) {
+	if configuration.LDAPURL == "" {
+		return nil, nil
+	}
+	authenticator, err := ldap.New(ldap.Config{
+		URL:           configuration.LDAPURL,
+		BindDN:        configuration.LDAPBindDN,
+		BindPassword:  configuration.LDAPBindPassword,
+		BaseDN:        configuration.LDAPBaseDN,
+		UserAttribute: configuration.LDAPUserAttribute,
+		// ldap:// URLs use StartTLS unless plaintext is explicitly allowed.
+		InsecurePlaintext: configuration.LDAPInsecurePlaintext,
+	})
+	if err != nil {
+		return nil, err
+	}
+	groupRoles := make([]identity.DirectoryGroupRole, 0, len(configuration.LDAPGroupRoles))
+	for _, groupRole := range configuration.LDAPGroupRoles {
+		groupRoles = append(groupRoles, identity.DirectoryGroupRole{Group: groupRole.Group, RoleCode: groupRole.RoleCode})
+	}
+	return []identity.Directory{{
+		Name:          "ldap",
+		Domains:       configuration.LDAPDomains,
+		Authenticator: authenticator,
+		GroupRoles:    groupRoles,
+	}}, nil
+}
+
+// newAuditSinks opens the optional hash-chained log file and webhook that
+// receive a copy of every audit event. The returned function closes them.
+func newAuditSinks(configuration config.Config) ([]identity.AuditSink, func(), error) {
+	var sinks []identity.AuditSink
+	closeSinks := func() {}
+	if configuration.AuditLogFile != "" {
+		fileSink, err := auditlog.OpenFile(configuration.AuditLogFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		sinks = append(sinks, fileSink)
+		closeSinks = func() { fileSink.Close() }
+	}
+	if configuration.AuditWebhookURL != "" {
+		sinks = append(sinks, auditlog.NewWebhook(configuration.AuditWebhookURL, configuration.AuditWebhookSecret, nil))
+	}
+	return sinks, closeSinks, nil
+}
+
+// startAuditDispatcher drains the audit outbox into sinks every few seconds
+// until ctx is done. The returned function stops it and waits for an
+// in-flight delivery to finish, so the sinks can be closed afterwards.
+func startAuditDispatcher(ctx context.Context, logger *slog.Logger, databaseConnection *sql.DB, sinks []identity.AuditSink) func() {
+	if len(sinks) == 0 {
+		return func() {}
+	}
+	dispatchContext, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(auditDispatchInterval)
+		defer ticker.Stop()
+		for {
+			if _, err := identity.DispatchAuditEvents(dispatchContext, databaseConnection, sinks); err != nil && dispatchContext.Err() == nil {
+				logger.Warn("dispatch audit events", "error", err)
+			}
+			select {
+			case <-dispatchContext.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-stopped
+	}
+}
+
+func loginThrottleSettings(configuration config.Config) identity.LoginThrottleSettings {
+	return identity.LoginThrottleSettings{
+		Secret:          configuration.LoginThrottleSecret,
+		FailureLimit:    configuration.LoginThrottleFailureLimit,
+		Window:          configuration.LoginThrottleWindow,
+		LockoutDuration: configuration.LoginThrottleLockout,
+	}
+}
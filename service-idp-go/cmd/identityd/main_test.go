@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/auditlog"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+func TestRunRejectsInvalidUsage(t *testing.T) {
+	for _, args := range [][]string{{"sideways"}, {"serve", "extra"}, {"migrate", "down"}, {"migrate", "status", "extra"}, {"recover-admin"}, {"import-subjects"}, {"import-subjects", "-format", "xml", "subjects.json"}, {"export-subjects", "-format", "xml"}, {"purge", "extra"}, {"verify-audit-log"}} {
+		var stderr bytes.Buffer
+		code := run(context.Background(), args, testLookup(t), strings.NewReader(""), &bytes.Buffer{}, &stderr)
+		if code != 2 || !strings.Contains(stderr.String(), "usage: identityd") {
+			t.Fatalf("args %q: code = %d stderr = %q, want the usage and exit 2", args, code, stderr.String())
+		}
+	}
+}
+
+func TestRunServeFailsOnInvalidConfiguration(t *testing.T) {
+	var stderr bytes.Buffer
+	code := run(context.Background(), nil, func(string) string { return "" }, strings.NewReader(""), &bytes.Buffer{}, &stderr)
+	if code != 1 || !strings.Contains(stderr.String(), "IDENTITYD_LOGIN_THROTTLE_SECRET") {
+		t.Fatalf("code = %d stderr = %q", code, stderr.String())
+	}
+}
+
+func TestRunMigrateReportsSchemaVersion(t *testing.T) {
+	lookup := testLookup(t)
+
+	var pending bytes.Buffer
+	if code := run(context.Background(), []string{"migrate", "status"}, lookup, strings.NewReader(""), &pending, &bytes.Buffer{}); code != 0 {
+		t.Fatalf("migrate status code = %d", code)
+	}
+	if !strings.Contains(pending.String(), "identity_subjects") || !strings.Contains(pending.String(), "pending") || !strings.HasSuffix(pending.String(), "schema version: none\n") {
+		t.Fatalf("pending status = %q", pending.String())
+	}
+
+	var applied bytes.Buffer
+	if code := run(context.Background(), []string{"migrate"}, lookup, strings.NewReader(""), &applied, &bytes.Buffer{}); code != 0 {
+		t.Fatalf("migrate code = %d", code)
+	}
+	if !strings.HasPrefix(applied.String(), "applied ") || strings.HasSuffix(applied.String(), "schema version: none\n") {
+		t.Fatalf("migrate output = %q", applied.String())
+	}
+
+	var status bytes.Buffer
+	run(context.Background(), []string{"migrate", "status"}, lookup, strings.NewReader(""), &status, &bytes.Buffer{})
+	if strings.Contains(status.String(), "pending") {
+		t.Fatalf("status after migrate = %q", status.String())
+	}
+}
+
+func TestRunRecoverAdminAndPurge(t *testing.T) {
+	lookup := testLookup(t)
+	databaseConnection, err := database.OpenSQLite(context.Background(), lookup("IDENTITYD_DATABASE_PATH"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer databaseConnection.Close()
+	if code := run(context.Background(), []string{"migrate"}, lookup, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{}); code != 0 {
+		t.Fatalf("migrate code = %d", code)
+	}
+	if _, err := identity.EnsureBootstrap(context.Background(), databaseConnection, identity.BootstrapInput{
+		Identifier: "admin",
+		Password:   "correct horse battery staple",
+	}); err != nil {
+		t.Fatalf("ensure bootstrap: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"recover-admin", "-identifier", "admin"}, lookup, strings.NewReader("a recovered sufficiently long password\n"), &stdout, &stderr)
+	if code != 0 || !strings.Contains(stdout.String(), "recovered admin") {
+		t.Fatalf("recover-admin code = %d stdout = %q stderr = %q", code, stdout.String(), stderr.String())
+	}
+	// 恢复密码过短时拒绝执行，不写入审计事件。
+	if code := run(context.Background(), []string{"recover-admin", "-identifier", "admin"}, lookup, strings.NewReader("short\n"), &bytes.Buffer{}, &bytes.Buffer{}); code != 1 {
+		t.Fatalf("recover-admin with short password code = %d", code)
+	}
+
+	stdout.Reset()
+	if code := run(context.Background(), []string{"purge"}, lookup, strings.NewReader(""), &stdout, &bytes.Buffer{}); code != 0 || !strings.HasPrefix(stdout.String(), "purged ") {
+		t.Fatalf("purge code = %d stdout = %q", code, stdout.String())
+	}
+
+	var recoveryAudits, purgeAudits int
+	if err := databaseConnection.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM identity_audit_events WHERE event_action = '管理员恢复'),
+			(SELECT COUNT(*) FROM identity_audit_events WHERE event_action = '维护清理')
+	`).Scan(&recoveryAudits, &purgeAudits); err != nil {
+		t.Fatalf("count operator audits: %v", err)
+	}
+	if recoveryAudits != 1 || purgeAudits != 1 {
+		t.Fatalf("operator audits = recovery:%d purge:%d", recoveryAudits, purgeAudits)
+	}
+}
+
+func TestRunImportAndExportSubjects(t *testing.T) {
+	lookup := testLookup(t)
+	if code := run(context.Background(), []string{"migrate"}, lookup, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{}); code != 0 {
+		t.Fatalf("migrate code = %d", code)
+	}
+	path := filepath.Join(t.TempDir(), "subjects.csv")
+	if err := os.WriteFile(path, []byte("display_name,account,password\n张三,zhangsan,generate\n"), 0o600); err != nil {
+		t.Fatalf("write import file: %v", err)
+	}
+
+	var stdout bytes.Buffer
+	if code := run(context.Background(), []string{"import-subjects", "-dry-run", path}, lookup, strings.NewReader(""), &stdout, &bytes.Buffer{}); code != 0 || !strings.Contains(stdout.String(), "dry run: 1 rows are valid") {
+		t.Fatalf("dry run code = %d stdout = %q", code, stdout.String())
+	}
+	stdout.Reset()
+	if code := run(context.Background(), []string{"import-subjects", path}, lookup, strings.NewReader(""), &stdout, &bytes.Buffer{}); code != 0 || !strings.Contains(stdout.String(), "imported 1 subjects") {
+		t.Fatalf("import code = %d stdout = %q", code, stdout.String())
+	}
+	// 重复导入时账号冲突，整批拒绝。
+	stdout.Reset()
+	if code := run(context.Background(), []string{"import-subjects", path}, lookup, strings.NewReader(""), &stdout, &bytes.Buffer{}); code != 1 || !strings.Contains(stdout.String(), "already in use") {
+		t.Fatalf("repeated import code = %d stdout = %q", code, stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run(context.Background(), []string{"export-subjects", "-format", "csv"}, lookup, strings.NewReader(""), &stdout, &bytes.Buffer{}); code != 0 || !strings.Contains(stdout.String(), ",zhangsan,") {
+		t.Fatalf("export code = %d stdout = %q", code, stdout.String())
+	}
+}
+
+func TestRunVerifyAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := auditlog.OpenFile(path)
+	if err != nil {
+		t.Fatalf("open audit log: %v", err)
+	}
+	if err := sink.Write(context.Background(), identity.AuditEvent{ID: "01JAUDIT000000000000000001", EventAction: "登录", Outcome: "成功", Metadata: []byte("{}")}); err != nil {
+		t.Fatalf("write audit event: %v", err)
+	}
+	sink.Close()
+
+	var stdout bytes.Buffer
+	if code := run(context.Background(), []string{"verify-audit-log", path}, nil, strings.NewReader(""), &stdout, &bytes.Buffer{}); code != 0 || !strings.HasPrefix(stdout.String(), "verified 1 audit events; chain head ") {
+		t.Fatalf("verify code = %d stdout = %q", code, stdout.String())
+	}
+	content, _ := os.ReadFile(path)
+	if err := os.WriteFile(path, bytes.Replace(content, []byte("成功"), []byte("失败"), 1), 0o600); err != nil {
+		t.Fatalf("tamper audit log: %v", err)
+	}
+	var stderr bytes.Buffer
+	if code := run(context.Background(), []string{"verify-audit-log", path}, nil, strings.NewReader(""), &bytes.Buffer{}, &stderr); code != 1 || !strings.Contains(stderr.String(), "line 1 was modified") {
+		t.Fatalf("tampered verify code = %d stderr = %q", code, stderr.String())
+	}
+}
+
+func testLookup(t *testing.T) func(string) string {
+	t.Helper()
+	values := map[string]string{
+		"IDENTITYD_DATABASE_PATH":         filepath.Join(t.TempDir(), "identityd.sqlite"),
+		"IDENTITYD_LOGIN_THROTTLE_SECRET": "test-login-throttle-secret-with-at-least-32-bytes",
+	}
+	return func(key string) string {
+		return values[key]
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/db/migrations"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/auditlog"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/config"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+// runMigrate implements `identityd migrate`, which applies the pending
+// embedded migrations, and `identityd migrate status`, which only reports
+// them and the current schema version.
+func runMigrate(ctx context.Context, args []string, lookup func(string) string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) > 1 || (len(args) == 1 && args[0] != "status") {
+		fmt.Fprintln(stderr, usage)
+		return 2
+	}
+	logger := slog.New(slog.NewTextHandler(stderr, nil))
+	configuration, err := config.LoadFromLookup(lookup)
+	if err != nil {
+		logger.Error("load configuration", "error", err)
+		return 1
+	}
+	databaseConnection, err := database.OpenSQLite(ctx, configuration.DatabasePath)
+	if err != nil {
+		logger.Error("open database", "error", err)
+		return 1
+	}
+	defer databaseConnection.Close()
+
+	if len(args) == 0 {
+		result, err := database.Migrate(ctx, databaseConnection, migrations.Files)
+		if err != nil {
+			logger.Error("migrate", "error", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "applied %d migrations\n", result.Applied)
+	}
+	statuses, err := database.MigrationStatuses(ctx, databaseConnection, migrations.Files)
+	if err != nil {
+		logger.Error("migrate status", "error", err)
+		return 1
+	}
+	if len(args) == 1 {
+		printMigrationStatuses(stdout, statuses)
+	}
+	fmt.Fprintf(stdout, "schema version: %s\n", schemaVersion(statuses))
+	return 0
+}
+
+// runRecoverAdmin implements `identityd recover-admin -identifier ACCOUNT`.
+// The new password is read from the first line of stdin so it never appears
+// in the process list or shell history; the subject must change it at the
+// next sign-in.
+func runRecoverAdmin(ctx context.Context, args []string, lookup func(string) string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("recover-admin", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	identifier := flags.String("identifier", "", "account identifier of the subject to recover")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 || *identifier == "" {
+		fmt.Fprintln(stderr, usage)
+		return 2
+	}
+	logger := slog.New(slog.NewTextHandler(stderr, nil))
+	configuration, err := config.LoadFromLookup(lookup)
+	if err != nil {
+		logger.Error("load configuration", "error", err)
+		return 1
+	}
+	password, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		logger.Error("read recovery password", "error", err)
+		return 1
+	}
+	password = strings.TrimRight(password, "\r\n")
+
+	databaseConnection, err := openDatabase(ctx, logger, configuration)
+	if err != nil {
+		logger.Error("open database", "error", err)
+		return 1
+	}
+	defer databaseConnection.Close()
+
+	result, err := identity.RecoverAdministrator(ctx, databaseConnection, loginThrottleSettings(configuration), identity.RecoverAdministratorInput{
+		Identifier: *identifier,
+		Password:   password,
+	})
+	if err != nil {
+		logger.Error("recover administrator", "identifier", *identifier, "error", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "recovered %s (subject %s, reenabled=%t, role_granted=%t, second_factor_reset=%t); the password must be changed at the next sign-in\n",
+		result.Subject.Identifier, result.Subject.ID, result.Reenabled, result.RoleGranted, result.SecondFactorReset)
+	return 0
+}
+
+// runImportSubjects implements `identityd import-subjects FILE`. The format
+// follows the file extension unless -format is given. Generated passwords are
+// printed once, so the output has to be handed over securely. Audit events
+// record no actor, as for other operator commands.
+func runImportSubjects(ctx context.Context, args []string, lookup func(string) string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("import-subjects", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dryRun := flags.Bool("dry-run", false, "validate every row without importing")
+	format := flags.String("format", "", "json or csv; defaults to the file extension")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		fmt.Fprintln(stderr, usage)
+		return 2
+	}
+	path := flags.Arg(0)
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	if !slices.Contains(identity.SubjectTransferFormats, *format) {
+		fmt.Fprintln(stderr, usage)
+		return 2
+	}
+	logger := slog.New(slog.NewTextHandler(stderr, nil))
+	configuration, err := config.LoadFromLookup(lookup)
+	if err != nil {
+		logger.Error("load configuration", "error", err)
+		return 1
+	}
+	passwordPolicy, err := newPasswordPolicy(configuration)
+	if err != nil {
+		logger.Error("configure password policy", "error", err)
+		return 1
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		logger.Error("open import file", "error", err)
+		return 1
+	}
+	defer file.Close()
+	records, err := identity.DecodeSubjectImport(*format, file)
+	if err != nil {
+		logger.Error("read import file", "error", err)
+		return 1
+	}
+
+	databaseConnection, err := openDatabase(ctx, logger, configuration)
+	if err != nil {
+		logger.Error("open database", "error", err)
+		return 1
+	}
+	defer databaseConnection.Close()
+
+	result, err := identity.ImportSubjects(ctx, databaseConnection, "", identity.ImportSubjectsInput{
+		Records: records,
+		DryRun:  *dryRun,
+	}, passwordPolicy)
+	if len(result.Rows) > 0 {
+		printImportRows(stdout, result.Rows)
+	}
+	if err != nil {
+		logger.Error("import subjects", "error", err)
+		return 1
+	}
+	switch {
+	case result.DryRun && !result.Valid:
+		fmt.Fprintln(stdout, "dry run found invalid rows; nothing was imported")
+		return 1
+	case result.DryRun:
+		fmt.Fprintf(stdout, "dry run: %d rows are valid\n", len(result.Rows))
+	default:
+		fmt.Fprintf(stdout, "imported %d subjects in batch %s; generated passwords must be changed at the first sign-in\n", len(result.Rows), result.BatchID)
+	}
+	return 0
+}
+
+// runExportSubjects implements `identityd export-subjects`, which writes
+// every subject with its identifiers and roles to stdout.
+func runExportSubjects(ctx context.Context, args []string, lookup func(string) string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("export-subjects", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "json", "json or csv")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 || !slices.Contains(identity.SubjectTransferFormats, *format) {
+		fmt.Fprintln(stderr, usage)
+		return 2
+	}
+	logger := slog.New(slog.NewTextHandler(stderr, nil))
+	configuration, err := config.LoadFromLookup(lookup)
+	if err != nil {
+		logger.Error("load configuration", "error", err)
+		return 1
+	}
+	databaseConnection, err := openDatabase(ctx, logger, configuration)
+	if err != nil {
+		logger.Error("open database", "error", err)
+		return 1
+	}
+	defer databaseConnection.Close()
+
+	subjects, err := identity.ExportSubjects(ctx, databaseConnection)
+	if err != nil {
+		logger.Error("export subjects", "error", err)
+		return 1
+	}
+	if err := identity.EncodeSubjectExport(*format, stdout, subjects); err != nil {
+		logger.Error("write subject export", "error", err)
+		return 1
+	}
+	return 0
+}
+
+// runPurge implements `identityd purge`, intended for a periodic timer.
+func runPurge(ctx context.Context, lookup func(string) string, stdout io.Writer, stderr io.Writer) int {
+	logger := slog.New(slog.NewTextHandler(stderr, nil))
+	configuration, err := config.LoadFromLookup(lookup)
+	if err != nil {
+		logger.Error("load configuration", "error", err)
+		return 1
+	}
+	databaseConnection, err := openDatabase(ctx, logger, configuration)
+	if err != nil {
+		logger.Error("open database", "error", err)
+		return 1
+	}
+	defer databaseConnection.Close()
+
+	result, err := identity.Purge(ctx, databaseConnection, loginThrottleSettings(configuration))
+	if err != nil {
+		logger.Error("purge", "error", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "purged %d sessions, %d login throttles, %d authorization codes, %d verification codes, %d password reset tokens, %d machine client secrets, %d audit outbox events\n",
+		result.Sessions, result.LoginThrottles, result.AuthorizationCodes, result.IdentifierVerifications, result.PasswordResetTokens, result.MachineClientSecrets, result.AuditOutboxEvents)
+	return 0
+}
+
+// runVerifyAuditLog implements `identityd verify-audit-log FILE`. It reads
+// no configuration, so a copy of the log can be checked away from the host
+// that wrote it.
+func runVerifyAuditLog(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintln(stderr, usage)
+		return 2
+	}
+	logger := slog.New(slog.NewTextHandler(stderr, nil))
+	file, err := os.Open(args[0])
+	if err != nil {
+		logger.Error("open audit log", "error", err)
+		return 1
+	}
+	defer file.Close()
+
+	result, err := auditlog.Verify(file)
+	if err != nil {
+		logger.Error("verify audit log", "error", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "verified %d audit events; chain head %s\n", result.Events, result.LastHash)
+	return 0
+}
+
+// schemaVersion is the version of the last applied migration, or "none".
+func schemaVersion(statuses []database.MigrationStatus) string {
+	version := "none"
+	for _, status := range statuses {
+		if status.Applied {
+			version = status.Version
+		}
+	}
+	return version
+}
+
+func printImportRows(out io.Writer, rows []identity.ImportSubjectRow) {
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ROW\tIDENTIFIER\tSUBJECT\tGENERATED PASSWORD\tERRORS")
+	for _, row := range rows {
+		subjectID, generatedPassword, errs := "-", "-", "-"
+		if row.SubjectID != "" {
+			subjectID = row.SubjectID
+		}
+		if row.GeneratedPassword != "" {
+			generatedPassword = row.GeneratedPassword
+		}
+		if len(row.Errors) > 0 {
+			errs = strings.Join(row.Errors, "; ")
+		}
+		fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%s\n", row.Row, row.Identifier, subjectID, generatedPassword, errs)
+	}
+	_ = table.Flush()
+}
+
+func printMigrationStatuses(out io.Writer, statuses []database.MigrationStatus) {
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+	for _, status := range statuses {
+		state, appliedAt := "pending", "-"
+		if status.Applied {
+			state = "applied"
+			appliedAt = status.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+	}
+	_ = table.Flush()
+}
@@ -0,0 +1,27 @@
+CREATE TABLE oidc_clients (
+    id TEXT PRIMARY KEY CHECK(length(id) = 26),
+    client_id TEXT NOT NULL UNIQUE CHECK(length(client_id) BETWEEN 3 AND 64),
+    display_name TEXT NOT NULL,
+    status TEXT NOT NULL CHECK(status IN ('启用', '禁用')),
+    metadata TEXT NOT NULL DEFAULT '{}'
+        CHECK(json_valid(metadata))
+        CHECK(json_type(metadata) = 'object'),
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE oidc_client_redirect_uris (
+    oidc_client_id TEXT NOT NULL
+        REFERENCES oidc_clients(id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL CHECK(length(redirect_uri) BETWEEN 1 AND 2048),
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY(oidc_client_id, redirect_uri)
+);
+
+CREATE TABLE oidc_client_scopes (
+    oidc_client_id TEXT NOT NULL
+        REFERENCES oidc_clients(id) ON DELETE CASCADE,
+    scope TEXT NOT NULL CHECK(scope IN ('openid', 'profile')),
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY(oidc_client_id, scope)
+);
@@ -0,0 +1,19 @@
+CREATE TABLE oidc_authorization_codes (
+    id TEXT PRIMARY KEY CHECK(length(id) = 26),
+    code_hash BLOB NOT NULL UNIQUE CHECK(length(code_hash) = 32),
+    oidc_client_id TEXT NOT NULL
+        REFERENCES oidc_clients(id) ON DELETE RESTRICT,
+    subject_id TEXT NOT NULL
+        REFERENCES identity_subjects(id) ON DELETE RESTRICT,
+    subject_security_version INTEGER NOT NULL CHECK(subject_security_version >= 1),
+    redirect_uri TEXT NOT NULL,
+    scope TEXT NOT NULL,
+    nonce TEXT,
+    code_challenge TEXT NOT NULL CHECK(length(code_challenge) = 43),
+    code_challenge_method TEXT NOT NULL CHECK(code_challenge_method = 'S256'),
+    expires_at DATETIME NOT NULL,
+    consumed_at DATETIME,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX oidc_authorization_codes_expires_at_idx ON oidc_authorization_codes(expires_at);
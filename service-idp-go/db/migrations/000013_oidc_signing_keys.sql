@@ -0,0 +1,9 @@
+CREATE TABLE oidc_signing_keys (
+    key_id TEXT PRIMARY KEY CHECK(length(key_id) = 43),
+    algorithm TEXT NOT NULL CHECK(algorithm = 'RS256'),
+    public_jwk TEXT NOT NULL
+        CHECK(json_valid(public_jwk))
+        CHECK(json_type(public_jwk) = 'object'),
+    created_at DATETIME NOT NULL,
+    retired_at DATETIME
+);
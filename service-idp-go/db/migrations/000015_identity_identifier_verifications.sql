@@ -0,0 +1,11 @@
+CREATE TABLE identity_identifier_verifications (
+    identifier_id TEXT PRIMARY KEY CHECK(length(identifier_id) = 26)
+        REFERENCES identity_identifiers(id) ON DELETE CASCADE,
+    code_hash BLOB NOT NULL CHECK(length(code_hash) = 32),
+    attempts INTEGER NOT NULL DEFAULT 0 CHECK(attempts >= 0),
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX identity_identifier_verifications_expires_at_idx
+    ON identity_identifier_verifications(expires_at);
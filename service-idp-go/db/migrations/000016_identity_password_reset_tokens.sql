@@ -0,0 +1,18 @@
+CREATE TABLE identity_password_reset_tokens (
+    id TEXT PRIMARY KEY CHECK(length(id) = 26),
+    subject_id TEXT NOT NULL
+        REFERENCES identity_subjects(id) ON DELETE RESTRICT,
+    identifier_id TEXT NOT NULL
+        REFERENCES identity_identifiers(id) ON DELETE CASCADE,
+    token_hash BLOB NOT NULL UNIQUE CHECK(length(token_hash) = 32),
+    password_revision INTEGER NOT NULL CHECK(password_revision >= 1),
+    expires_at DATETIME NOT NULL,
+    consumed_at DATETIME,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX identity_password_reset_tokens_subject_idx
+    ON identity_password_reset_tokens(subject_id);
+
+CREATE INDEX identity_password_reset_tokens_expires_at_idx
+    ON identity_password_reset_tokens(expires_at);
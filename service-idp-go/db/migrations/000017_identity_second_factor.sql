@@ -0,0 +1,121 @@
+CREATE TABLE identity_totp_credentials (
+    subject_id TEXT PRIMARY KEY
+        REFERENCES identity_subjects(id) ON DELETE RESTRICT,
+    secret BLOB NOT NULL CHECK(length(secret) = 20),
+    status TEXT NOT NULL CHECK(status IN ('待确认', '已启用')),
+    last_used_step INTEGER NOT NULL DEFAULT 0 CHECK(last_used_step >= 0),
+    confirmed_at DATETIME,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    CHECK(
+        (status = '待确认' AND confirmed_at IS NULL) OR
+        (status = '已启用' AND confirmed_at IS NOT NULL)
+    )
+);
+
+CREATE TABLE identity_recovery_codes (
+    id TEXT PRIMARY KEY CHECK(length(id) = 26),
+    subject_id TEXT NOT NULL
+        REFERENCES identity_subjects(id) ON DELETE RESTRICT,
+    code_hash BLOB NOT NULL UNIQUE CHECK(length(code_hash) = 32),
+    consumed_at DATETIME,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX identity_recovery_codes_subject_idx ON identity_recovery_codes(subject_id);
+
+ALTER TABLE identity_roles
+    ADD COLUMN second_factor TEXT NOT NULL DEFAULT '可选'
+        CHECK(second_factor IN ('可选', '必需'));
+
+CREATE TABLE identity_sessions_next (
+    id TEXT PRIMARY KEY CHECK(length(id) = 26),
+    subject_id TEXT NOT NULL
+        REFERENCES identity_subjects(id) ON DELETE RESTRICT,
+    subject_security_version INTEGER NOT NULL CHECK(subject_security_version >= 1),
+    token_hash BLOB NOT NULL UNIQUE CHECK(length(token_hash) = 32),
+    csrf_token_hash BLOB NOT NULL CHECK(length(csrf_token_hash) = 32),
+    session_access TEXT NOT NULL CHECK(session_access IN ('完整', '仅改密', '待二次验证')),
+    authenticated_at DATETIME NOT NULL,
+    last_seen_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    idle_expires_at DATETIME NOT NULL,
+    revoked_at DATETIME,
+    revoked_reason TEXT CHECK(
+        revoked_reason IS NULL OR
+        revoked_reason IN ('用户退出', '主体禁用', '凭据变更', '权限收回', '管理员撤销')
+    ),
+    metadata TEXT NOT NULL DEFAULT '{}'
+        CHECK(json_valid(metadata))
+        CHECK(json_type(metadata) = 'object'),
+    created_at DATETIME NOT NULL,
+    CHECK(
+        (revoked_at IS NULL AND revoked_reason IS NULL) OR
+        (revoked_at IS NOT NULL AND revoked_reason IS NOT NULL)
+    )
+);
+
+INSERT INTO identity_sessions_next(
+    id, subject_id, subject_security_version, token_hash, csrf_token_hash,
+    session_access, authenticated_at, last_seen_at, expires_at, idle_expires_at,
+    revoked_at, revoked_reason, metadata, created_at
+)
+SELECT
+    id, subject_id, subject_security_version, token_hash, csrf_token_hash,
+    session_access, authenticated_at, last_seen_at, expires_at, idle_expires_at,
+    revoked_at, revoked_reason, metadata, created_at
+FROM identity_sessions;
+
+DROP TABLE identity_sessions;
+
+ALTER TABLE identity_sessions_next RENAME TO identity_sessions;
+
+CREATE INDEX identity_sessions_subject_idx ON identity_sessions(subject_id);
+
+CREATE TABLE identity_audit_events_next (
+    id TEXT PRIMARY KEY CHECK(length(id) = 26),
+    event_action TEXT NOT NULL CHECK(event_action IN (
+        '登录',
+        '退出登录',
+        '主体创建',
+        '主体状态变更',
+        '标识符变更',
+        '凭据变更',
+        '角色授予',
+        '角色撤销',
+        '会话撤销',
+        '管理员恢复',
+        '维护清理',
+        '客户端变更',
+        '角色创建',
+        '二次验证变更'
+    )),
+    outcome TEXT NOT NULL CHECK(outcome IN ('成功', '失败')),
+    actor_subject_id TEXT
+        REFERENCES identity_subjects(id) ON DELETE RESTRICT,
+    target_subject_id TEXT
+        REFERENCES identity_subjects(id) ON DELETE RESTRICT,
+    request_id TEXT,
+    source_hash BLOB CHECK(source_hash IS NULL OR length(source_hash) = 32),
+    metadata TEXT NOT NULL DEFAULT '{}'
+        CHECK(json_valid(metadata))
+        CHECK(json_type(metadata) = 'object'),
+    created_at DATETIME NOT NULL
+);
+
+INSERT INTO identity_audit_events_next(
+    id, event_action, outcome, actor_subject_id, target_subject_id,
+    request_id, source_hash, metadata, created_at
+)
+SELECT
+    id, event_action, outcome, actor_subject_id, target_subject_id,
+    request_id, source_hash, metadata, created_at
+FROM identity_audit_events;
+
+DROP TABLE identity_audit_events;
+
+ALTER TABLE identity_audit_events_next RENAME TO identity_audit_events;
+
+CREATE INDEX identity_audit_events_created_at_idx ON identity_audit_events(created_at);
+CREATE INDEX identity_audit_events_actor_subject_idx ON identity_audit_events(actor_subject_id);
+CREATE INDEX identity_audit_events_target_subject_idx ON identity_audit_events(target_subject_id);
@@ -0,0 +1,10 @@
+CREATE TABLE identity_password_history (
+    id TEXT PRIMARY KEY CHECK(length(id) = 26),
+    subject_id TEXT NOT NULL
+        REFERENCES identity_subjects(id) ON DELETE RESTRICT,
+    password_hash TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX identity_password_history_subject_idx
+    ON identity_password_history(subject_id, created_at);
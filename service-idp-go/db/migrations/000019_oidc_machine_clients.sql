@@ -0,0 +1,31 @@
+CREATE TABLE oidc_machine_clients (
+    id TEXT PRIMARY KEY CHECK(length(id) = 26),
+    client_id TEXT NOT NULL UNIQUE CHECK(length(client_id) BETWEEN 3 AND 64),
+    display_name TEXT NOT NULL,
+    status TEXT NOT NULL CHECK(status IN ('启用', '禁用')),
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE oidc_machine_client_scopes (
+    oidc_machine_client_id TEXT NOT NULL
+        REFERENCES oidc_machine_clients(id) ON DELETE CASCADE,
+    scope TEXT NOT NULL CHECK(length(scope) BETWEEN 3 AND 128),
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY(oidc_machine_client_id, scope)
+);
+
+CREATE TABLE oidc_machine_client_secrets (
+    id TEXT PRIMARY KEY CHECK(length(id) = 26),
+    oidc_machine_client_id TEXT NOT NULL
+        REFERENCES oidc_machine_clients(id) ON DELETE CASCADE,
+    secret_hash BLOB NOT NULL UNIQUE CHECK(length(secret_hash) = 32),
+    expires_at DATETIME,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX oidc_machine_client_secrets_client_idx
+    ON oidc_machine_client_secrets(oidc_machine_client_id);
+
+CREATE INDEX oidc_machine_client_secrets_expires_at_idx
+    ON oidc_machine_client_secrets(expires_at);
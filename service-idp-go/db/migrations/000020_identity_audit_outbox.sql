@@ -0,0 +1,31 @@
+CREATE TABLE identity_audit_outbox (
+    sequence INTEGER PRIMARY KEY AUTOINCREMENT,
+    audit_event_id TEXT NOT NULL UNIQUE
+        REFERENCES identity_audit_events(id) ON DELETE CASCADE,
+    created_at DATETIME NOT NULL
+);
+
+CREATE TABLE identity_audit_sink_cursors (
+    sink TEXT PRIMARY KEY CHECK(length(sink) BETWEEN 1 AND 64),
+    delivered_sequence INTEGER NOT NULL CHECK(delivered_sequence >= 0),
+    attempts INTEGER NOT NULL CHECK(attempts >= 0),
+    next_attempt_at DATETIME,
+    last_error TEXT,
+    updated_at DATETIME NOT NULL
+);
+
+-- Every audit event enters the outbox in the transaction that records it, so
+-- no code path can write the table without the external sinks seeing it.
+-- AUTOINCREMENT assigns sequences under SQLite's write lock, which makes
+-- sequence order commit order.
+CREATE TRIGGER identity_audit_events_outbox_insert
+AFTER INSERT ON identity_audit_events
+BEGIN
+    INSERT INTO identity_audit_outbox(audit_event_id, created_at)
+    VALUES (NEW.id, NEW.created_at);
+END;
+
+INSERT INTO identity_audit_outbox(audit_event_id, created_at)
+SELECT id, created_at
+FROM identity_audit_events
+ORDER BY id;
@@ -0,0 +1,8 @@
+-- An erased subject keeps its identity_subjects row, disabled, so audit
+-- events and other references stay valid; this table records that its
+-- personal data was removed and that it can never be enabled again.
+CREATE TABLE identity_subject_erasures (
+    subject_id TEXT PRIMARY KEY
+        REFERENCES identity_subjects(id) ON DELETE RESTRICT,
+    erased_at DATETIME NOT NULL
+);
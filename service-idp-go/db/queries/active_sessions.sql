@@ -2,3 +2,9 @@
 SELECT id,subject_id,subject_security_version,session_access,csrf_token_hash,expires_at
 FROM identity_sessions
 WHERE token_hash=? AND revoked_at IS NULL AND expires_at>? AND idle_expires_at>?;
+
+-- name: ListActiveSessionsBySubjectID :many
+SELECT id,session_access,authenticated_at,last_seen_at,expires_at,idle_expires_at,metadata
+FROM identity_sessions
+WHERE subject_id=? AND revoked_at IS NULL AND expires_at>? AND idle_expires_at>?
+ORDER BY last_seen_at DESC, id DESC;
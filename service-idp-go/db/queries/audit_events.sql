@@ -3,3 +3,17 @@ INSERT INTO identity_audit_events(
     id, event_action, outcome, actor_subject_id, target_subject_id,
     request_id, source_hash, metadata, created_at
 ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: ListAuditEvents :many
+SELECT id, event_action, outcome, actor_subject_id, target_subject_id,
+       request_id, source_hash, metadata, created_at
+FROM identity_audit_events
+WHERE (event_action = sqlc.narg(event_action) OR sqlc.narg(event_action) IS NULL)
+  AND (outcome = sqlc.narg(outcome) OR sqlc.narg(outcome) IS NULL)
+  AND (actor_subject_id = sqlc.narg(actor_subject_id) OR sqlc.narg(actor_subject_id) IS NULL)
+  AND (target_subject_id = sqlc.narg(target_subject_id) OR sqlc.narg(target_subject_id) IS NULL)
+  AND (created_at >= sqlc.narg(created_from) OR sqlc.narg(created_from) IS NULL)
+  AND (created_at < sqlc.narg(created_until) OR sqlc.narg(created_until) IS NULL)
+  AND (id < sqlc.narg(before_id) OR sqlc.narg(before_id) IS NULL)
+ORDER BY id DESC
+LIMIT sqlc.arg(row_limit);
@@ -0,0 +1,35 @@
+-- name: DeleteDeliveredAuditOutbox :execrows
+DELETE FROM identity_audit_outbox
+WHERE sequence <= COALESCE(
+    (SELECT MIN(delivered_sequence) FROM identity_audit_sink_cursors WHERE updated_at >= ?),
+    (SELECT MAX(sequence) FROM identity_audit_outbox)
+);
+
+-- name: EnsureAuditSinkCursor :exec
+INSERT INTO identity_audit_sink_cursors(sink, delivered_sequence, attempts, updated_at)
+VALUES (?, 0, 0, ?)
+ON CONFLICT(sink) DO NOTHING;
+
+-- name: GetAuditSinkCursor :one
+SELECT sink, delivered_sequence, attempts, next_attempt_at, last_error, updated_at
+FROM identity_audit_sink_cursors
+WHERE sink = ?;
+
+-- name: ListAuditOutboxEvents :many
+SELECT o.sequence, e.id, e.event_action, e.outcome, e.actor_subject_id,
+       e.target_subject_id, e.request_id, e.source_hash, e.metadata, e.created_at
+FROM identity_audit_outbox AS o
+JOIN identity_audit_events AS e ON e.id = o.audit_event_id
+WHERE o.sequence > ?
+ORDER BY o.sequence
+LIMIT ?;
+
+-- name: RecordAuditSinkDelivery :exec
+UPDATE identity_audit_sink_cursors
+SET delivered_sequence = ?, attempts = 0, next_attempt_at = NULL, last_error = NULL, updated_at = ?
+WHERE sink = ?;
+
+-- name: RecordAuditSinkFailure :exec
+UPDATE identity_audit_sink_cursors
+SET attempts = attempts + 1, next_attempt_at = ?, last_error = ?, updated_at = ?
+WHERE sink = ?;
@@ -13,3 +13,34 @@ WHERE subject_id=?;
 UPDATE identity_password_credentials
 SET password_hash=?,credential_status=?,password_revision=password_revision+1,changed_at=?,updated_at=?
 WHERE subject_id=? AND password_revision=?;
+
+-- name: ExpirePasswordCredential :execrows
+UPDATE identity_password_credentials
+SET credential_status=?,updated_at=?
+WHERE subject_id=? AND password_revision=? AND credential_status=?;
+
+-- name: RehashPasswordCredential :execrows
+UPDATE identity_password_credentials
+SET password_hash=?,updated_at=?
+WHERE subject_id=? AND password_revision=?;
+
+-- name: CreatePasswordHistoryEntry :exec
+INSERT INTO identity_password_history(id, subject_id, password_hash, created_at)
+VALUES (?, ?, ?, ?);
+
+-- name: ListPasswordHistoryHashes :many
+SELECT password_hash
+FROM identity_password_history
+WHERE subject_id=?
+ORDER BY created_at DESC, id DESC
+LIMIT ?;
+
+-- name: PrunePasswordHistory :execrows
+DELETE FROM identity_password_history
+WHERE subject_id=? AND id NOT IN (
+    SELECT id
+    FROM identity_password_history
+    WHERE subject_id=?
+    ORDER BY created_at DESC, id DESC
+    LIMIT ?
+);
@@ -0,0 +1,29 @@
+-- name: ListSubjectIdentifiers :many
+SELECT id,subject_id,identifier_type,identifier_value,normalized_value,identifier_usage,status,verified_at,created_at,updated_at
+FROM identity_identifiers
+WHERE subject_id=?
+ORDER BY created_at, id;
+
+-- name: GetSubjectIdentifier :one
+SELECT id,subject_id,identifier_type,identifier_value,normalized_value,identifier_usage,status,verified_at,created_at,updated_at
+FROM identity_identifiers
+WHERE id=? AND subject_id=?;
+
+-- name: CountOtherLoginIdentifiersByNormalizedValue :one
+SELECT COUNT(*)
+FROM identity_identifiers
+WHERE normalized_value=? AND identifier_usage IN (?,?) AND id<>?;
+
+-- name: UpdateIdentifierUsageAndStatus :execrows
+UPDATE identity_identifiers
+SET identifier_usage=?,status=?,updated_at=?
+WHERE id=? AND subject_id=?;
+
+-- name: MarkIdentifierVerified :execrows
+UPDATE identity_identifiers
+SET verified_at=?,updated_at=?
+WHERE id=?;
+
+-- name: DeleteSubjectIdentifier :execrows
+DELETE FROM identity_identifiers
+WHERE id=? AND subject_id=? AND identifier_usage<>?;
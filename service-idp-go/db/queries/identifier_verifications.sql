@@ -0,0 +1,23 @@
+-- name: GetIdentifierVerification :one
+SELECT identifier_id,code_hash,attempts,expires_at,created_at
+FROM identity_identifier_verifications
+WHERE identifier_id=?;
+
+-- name: UpsertIdentifierVerification :exec
+INSERT INTO identity_identifier_verifications(identifier_id, code_hash, attempts, expires_at, created_at)
+VALUES (?, ?, 0, ?, ?)
+ON CONFLICT(identifier_id) DO UPDATE
+SET code_hash=excluded.code_hash,attempts=0,expires_at=excluded.expires_at,created_at=excluded.created_at;
+
+-- name: IncrementIdentifierVerificationAttempts :execrows
+UPDATE identity_identifier_verifications
+SET attempts=attempts+1
+WHERE identifier_id=?;
+
+-- name: DeleteIdentifierVerification :exec
+DELETE FROM identity_identifier_verifications
+WHERE identifier_id=?;
+
+-- name: DeleteExpiredIdentifierVerifications :execrows
+DELETE FROM identity_identifier_verifications
+WHERE expires_at<=?;
@@ -0,0 +1,16 @@
+-- name: CreateAuthorizationCode :exec
+INSERT INTO oidc_authorization_codes(
+    id, code_hash, oidc_client_id, subject_id, subject_security_version,
+    redirect_uri, scope, nonce, code_challenge, code_challenge_method,
+    expires_at, consumed_at, created_at
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: ConsumeAuthorizationCode :one
+UPDATE oidc_authorization_codes
+SET consumed_at=?
+WHERE code_hash=? AND consumed_at IS NULL AND expires_at > ?
+RETURNING oidc_client_id, subject_id, subject_security_version, redirect_uri, scope, nonce, code_challenge;
+
+-- name: DeleteExpiredAuthorizationCodes :execrows
+DELETE FROM oidc_authorization_codes
+WHERE expires_at <= ?;
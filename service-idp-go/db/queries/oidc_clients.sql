@@ -0,0 +1,51 @@
+-- name: CreateClient :exec
+INSERT INTO oidc_clients(id, client_id, display_name, status, metadata, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetClientByClientID :one
+SELECT id, client_id, display_name, status, metadata, created_at, updated_at
+FROM oidc_clients
+WHERE client_id = ?;
+
+-- name: CountClients :one
+SELECT COUNT(*)
+FROM oidc_clients;
+
+-- name: ListClients :many
+SELECT id, client_id, display_name, status, metadata, created_at, updated_at
+FROM oidc_clients
+ORDER BY created_at DESC
+LIMIT ? OFFSET ?;
+
+-- name: UpdateClient :execrows
+UPDATE oidc_clients
+SET display_name=?,status=?,updated_at=?
+WHERE id=?;
+
+-- name: ListClientRedirectURIs :many
+SELECT redirect_uri
+FROM oidc_client_redirect_uris
+WHERE oidc_client_id = ?
+ORDER BY redirect_uri;
+
+-- name: CreateClientRedirectURI :exec
+INSERT INTO oidc_client_redirect_uris(oidc_client_id, redirect_uri, created_at)
+VALUES (?, ?, ?);
+
+-- name: DeleteClientRedirectURIs :exec
+DELETE FROM oidc_client_redirect_uris
+WHERE oidc_client_id = ?;
+
+-- name: ListClientScopes :many
+SELECT scope
+FROM oidc_client_scopes
+WHERE oidc_client_id = ?
+ORDER BY scope;
+
+-- name: CreateClientScope :exec
+INSERT INTO oidc_client_scopes(oidc_client_id, scope, created_at)
+VALUES (?, ?, ?);
+
+-- name: DeleteClientScopes :exec
+DELETE FROM oidc_client_scopes
+WHERE oidc_client_id = ?;
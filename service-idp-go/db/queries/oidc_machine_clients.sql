@@ -0,0 +1,62 @@
+-- name: CreateMachineClient :exec
+INSERT INTO oidc_machine_clients(id, client_id, display_name, status, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: GetMachineClientByClientID :one
+SELECT id, client_id, display_name, status, created_at, updated_at
+FROM oidc_machine_clients
+WHERE client_id = ?;
+
+-- name: CountMachineClients :one
+SELECT COUNT(*)
+FROM oidc_machine_clients;
+
+-- name: ListMachineClients :many
+SELECT id, client_id, display_name, status, created_at, updated_at
+FROM oidc_machine_clients
+ORDER BY created_at DESC
+LIMIT ? OFFSET ?;
+
+-- name: UpdateMachineClient :execrows
+UPDATE oidc_machine_clients
+SET display_name=?,status=?,updated_at=?
+WHERE id=?;
+
+-- name: ListMachineClientScopes :many
+SELECT scope
+FROM oidc_machine_client_scopes
+WHERE oidc_machine_client_id = ?
+ORDER BY scope;
+
+-- name: CreateMachineClientScope :exec
+INSERT INTO oidc_machine_client_scopes(oidc_machine_client_id, scope, created_at)
+VALUES (?, ?, ?);
+
+-- name: DeleteMachineClientScopes :exec
+DELETE FROM oidc_machine_client_scopes
+WHERE oidc_machine_client_id = ?;
+
+-- name: CreateMachineClientSecret :exec
+INSERT INTO oidc_machine_client_secrets(id, oidc_machine_client_id, secret_hash, expires_at, created_at)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: ListMachineClientSecrets :many
+SELECT id, expires_at, created_at
+FROM oidc_machine_client_secrets
+WHERE oidc_machine_client_id = ? AND (expires_at IS NULL OR expires_at > ?)
+ORDER BY created_at DESC;
+
+-- name: ExpireMachineClientSecrets :execrows
+UPDATE oidc_machine_client_secrets
+SET expires_at=?
+WHERE oidc_machine_client_id=? AND (expires_at IS NULL OR expires_at>?);
+
+-- name: GetMachineClientSecretByHash :one
+SELECT s.id, c.client_id, s.expires_at
+FROM oidc_machine_client_secrets s
+JOIN oidc_machine_clients c ON c.id = s.oidc_machine_client_id
+WHERE s.secret_hash = ?;
+
+-- name: DeleteExpiredMachineClientSecrets :execrows
+DELETE FROM oidc_machine_client_secrets
+WHERE expires_at<=?;
@@ -0,0 +1,15 @@
+-- name: UpsertSigningKey :exec
+INSERT INTO oidc_signing_keys(key_id, algorithm, public_jwk, created_at, retired_at)
+VALUES (?, ?, ?, ?, NULL)
+ON CONFLICT(key_id) DO UPDATE SET retired_at=NULL;
+
+-- name: RetireSigningKeysExcept :execrows
+UPDATE oidc_signing_keys
+SET retired_at=?
+WHERE key_id<>? AND retired_at IS NULL;
+
+-- name: ListPublishedSigningKeys :many
+SELECT key_id, public_jwk
+FROM oidc_signing_keys
+WHERE retired_at IS NULL OR retired_at > ?
+ORDER BY created_at DESC;
@@ -0,0 +1,29 @@
+-- name: GetPasswordResetTarget :one
+SELECT i.id,i.subject_id,i.normalized_value,c.password_revision
+FROM identity_identifiers i
+JOIN identity_subjects s ON s.id=i.subject_id
+JOIN identity_password_credentials c ON c.subject_id=i.subject_id
+WHERE i.identifier_type=? AND i.normalized_value=? AND i.status=? AND i.verified_at IS NOT NULL
+  AND s.status=? AND c.credential_status<>?;
+
+-- name: DeletePendingPasswordResetTokensBySubjectID :exec
+DELETE FROM identity_password_reset_tokens
+WHERE subject_id=? AND consumed_at IS NULL;
+
+-- name: CreatePasswordResetToken :exec
+INSERT INTO identity_password_reset_tokens(id, subject_id, identifier_id, token_hash, password_revision, expires_at, consumed_at, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetPasswordResetTokenByHash :one
+SELECT id,subject_id,identifier_id,token_hash,password_revision,expires_at,consumed_at,created_at
+FROM identity_password_reset_tokens
+WHERE token_hash=?;
+
+-- name: ConsumePasswordResetToken :execrows
+UPDATE identity_password_reset_tokens
+SET consumed_at=?
+WHERE id=? AND consumed_at IS NULL AND expires_at>?;
+
+-- name: DeleteExpiredPasswordResetTokens :execrows
+DELETE FROM identity_password_reset_tokens
+WHERE expires_at<=? OR consumed_at IS NOT NULL;
@@ -0,0 +1,34 @@
+-- name: GetRoleByID :one
+SELECT id, role_code, display_name, description, created_at, updated_at, second_factor
+FROM identity_roles
+WHERE id=?;
+
+-- name: ListRoleMembers :many
+SELECT subject_role.subject_id,profile.display_name
+FROM identity_subject_roles AS subject_role
+JOIN identity_profiles AS profile ON profile.subject_id=subject_role.subject_id
+WHERE subject_role.role_id=?
+ORDER BY subject_role.subject_id;
+
+-- name: ListSubjectIDsByDisplayName :many
+SELECT subject_id
+FROM identity_profiles
+WHERE display_name=?
+ORDER BY subject_id;
+
+-- name: ListSubjectRoles :many
+SELECT role.id,role.role_code
+FROM identity_subject_roles AS subject_role
+JOIN identity_roles AS role ON role.id=subject_role.role_id
+WHERE subject_role.subject_id=?
+ORDER BY role.role_code;
+
+-- name: TouchSubject :execrows
+UPDATE identity_subjects
+SET updated_at=?
+WHERE id=?;
+
+-- name: UpdateProfileDisplayName :execrows
+UPDATE identity_profiles
+SET display_name=?,updated_at=?
+WHERE subject_id=?;
@@ -4,3 +4,8 @@ FROM identity_subject_roles AS subject_role
 JOIN identity_roles AS role ON role.id = subject_role.role_id
 WHERE subject_role.subject_id = ?
   AND role.role_code = ?;
+
+-- name: DeleteSubjectRole :execrows
+DELETE FROM identity_subject_roles
+WHERE subject_id = ?
+  AND role_id = ?;
@@ -11,3 +11,17 @@ WHERE role_code = ?;
 -- name: AssignSubjectRole :exec
 INSERT INTO identity_subject_roles(id, subject_id, role_id, granted_by_subject_id, created_at)
 VALUES (?, ?, ?, ?, ?);
+
+-- name: CreateRole :exec
+INSERT INTO identity_roles(id, role_code, display_name, description, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: GetRoleByCode :one
+SELECT id, role_code, display_name, description, created_at, updated_at, second_factor
+FROM identity_roles
+WHERE role_code = ?;
+
+-- name: ListRoles :many
+SELECT id, role_code, display_name, description, created_at, updated_at, second_factor
+FROM identity_roles
+ORDER BY role_code;
@@ -0,0 +1,59 @@
+-- name: GetTOTPCredentialBySubjectID :one
+SELECT subject_id,secret,status,last_used_step,confirmed_at,created_at,updated_at
+FROM identity_totp_credentials
+WHERE subject_id=?;
+
+-- name: UpsertPendingTOTPCredential :execrows
+INSERT INTO identity_totp_credentials(subject_id, secret, status, last_used_step, confirmed_at, created_at, updated_at)
+VALUES (?, ?, ?, 0, NULL, ?, ?)
+ON CONFLICT(subject_id) DO UPDATE
+SET secret=excluded.secret,created_at=excluded.created_at,updated_at=excluded.updated_at
+WHERE identity_totp_credentials.status=excluded.status;
+
+-- name: EnableTOTPCredential :execrows
+UPDATE identity_totp_credentials
+SET status=?,last_used_step=?,confirmed_at=?,updated_at=?
+WHERE subject_id=? AND status=?;
+
+-- name: AdvanceTOTPLastUsedStep :execrows
+UPDATE identity_totp_credentials
+SET last_used_step=?,updated_at=?
+WHERE subject_id=? AND status=? AND last_used_step<?;
+
+-- name: DeleteTOTPCredentialBySubjectID :execrows
+DELETE FROM identity_totp_credentials
+WHERE subject_id=?;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO identity_recovery_codes(id, subject_id, code_hash, consumed_at, created_at)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: ConsumeRecoveryCode :execrows
+UPDATE identity_recovery_codes
+SET consumed_at=?
+WHERE subject_id=? AND code_hash=? AND consumed_at IS NULL;
+
+-- name: CountUnusedRecoveryCodesBySubjectID :one
+SELECT COUNT(*)
+FROM identity_recovery_codes
+WHERE subject_id=? AND consumed_at IS NULL;
+
+-- name: DeleteRecoveryCodesBySubjectID :execrows
+DELETE FROM identity_recovery_codes
+WHERE subject_id=?;
+
+-- name: CountSubjectRolesBySecondFactor :one
+SELECT COUNT(*)
+FROM identity_subject_roles AS subject_role
+JOIN identity_roles AS role ON role.id=subject_role.role_id
+WHERE subject_role.subject_id=? AND role.second_factor=?;
+
+-- name: UpdateRoleSecondFactor :execrows
+UPDATE identity_roles
+SET second_factor=?,updated_at=?
+WHERE role_code=?;
+
+-- name: UpdateActiveSessionAccess :execrows
+UPDATE identity_sessions
+SET session_access=?
+WHERE id=? AND session_access=? AND revoked_at IS NULL;
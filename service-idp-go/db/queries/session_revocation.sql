@@ -1,4 +1,13 @@
+-- name: RevokeActiveSessionByID :execrows
+UPDATE identity_sessions
+SET revoked_at=?,revoked_reason=?
+WHERE id=? AND subject_id=? AND revoked_at IS NULL;
+
 -- name: RevokeActiveSessionsBySubjectID :execrows
 UPDATE identity_sessions
 SET revoked_at=?,revoked_reason=?
 WHERE subject_id=? AND revoked_at IS NULL;
+
+-- name: DeleteExpiredSessions :execrows
+DELETE FROM identity_sessions
+WHERE revoked_at IS NOT NULL OR expires_at<=? OR idle_expires_at<=?;
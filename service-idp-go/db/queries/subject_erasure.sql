@@ -0,0 +1,30 @@
+-- name: AnonymizeSubjectIdentifiers :execrows
+UPDATE identity_identifiers
+SET identifier_value='erased-'||id,normalized_value='erased-'||id,status=?,verified_at=NULL,updated_at=?
+WHERE subject_id=?;
+
+-- name: ClearSessionMetadataBySubjectID :execrows
+UPDATE identity_sessions
+SET metadata='{}'
+WHERE subject_id=?;
+
+-- name: CreateSubjectErasure :exec
+INSERT INTO identity_subject_erasures(subject_id, erased_at)
+VALUES (?, ?);
+
+-- name: DeleteIdentifierVerificationsBySubjectID :execrows
+DELETE FROM identity_identifier_verifications
+WHERE identifier_id IN (SELECT id FROM identity_identifiers WHERE subject_id=?);
+
+-- name: DeletePasswordHistoryBySubjectID :execrows
+DELETE FROM identity_password_history
+WHERE subject_id=?;
+
+-- name: DeletePasswordResetTokensBySubjectID :execrows
+DELETE FROM identity_password_reset_tokens
+WHERE subject_id=?;
+
+-- name: RevokePasswordCredential :execrows
+UPDATE identity_password_credentials
+SET password_hash='',password_revision=password_revision+1,credential_status=?,changed_at=?,updated_at=?
+WHERE subject_id=?;
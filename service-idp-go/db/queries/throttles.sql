@@ -13,3 +13,11 @@ locked_until=excluded.locked_until,updated_at=excluded.updated_at;
 -- name: DeleteLoginThrottle :exec
 DELETE FROM identity_login_throttles
 WHERE identifier_hash=? AND source_hash=?;
+
+-- name: DeleteLoginThrottlesByIdentifierHash :execrows
+DELETE FROM identity_login_throttles
+WHERE identifier_hash=?;
+
+-- name: DeleteExpiredLoginThrottles :execrows
+DELETE FROM identity_login_throttles
+WHERE window_started_at<=? AND (locked_until IS NULL OR locked_until<=?);
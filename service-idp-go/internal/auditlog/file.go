@@ -0,0 +1,164 @@
+// Package auditlog holds the audit sinks that copy identityd's audit events
+// off the host's SQLite file: an append-only, hash-chained JSON Lines file
+// and an HTTP webhook. Both are fed by identity.DispatchAuditEvents.
+package auditlog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+// ErrTampered reports a log file whose hash chain does not verify: a line was
+// edited, removed, reordered or only partly written.
+var ErrTampered = errors.New("audit log hash chain broken")
+
+// genesisHash is the previous_hash of the first line.
+var genesisHash = strings.Repeat("0", sha256.Size*2)
+
+// fileRecord is one line of the log. Hash is the hex SHA-256 of PreviousHash
+// followed by the exact bytes of Event, so each line commits to every line
+// before it.
+type fileRecord struct {
+	Event        json.RawMessage `json:"event"`
+	PreviousHash string          `json:"previous_hash"`
+	Hash         string          `json:"hash"`
+}
+
+// FileSink appends audit events to a hash-chained JSON Lines file. It is safe
+// for concurrent use.
+type FileSink struct {
+	mu          sync.Mutex
+	file        *os.File
+	size        int64
+	lastHash    string
+	lastEventID string
+}
+
+// OpenFile opens or creates the log at path. An existing file is verified
+// first; a broken chain is refused rather than extended.
+func OpenFile(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	result, err := Verify(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("seek audit log: %w", err)
+	}
+	return &FileSink{file: file, size: size, lastHash: result.LastHash, lastEventID: result.LastEventID}, nil
+}
+
+func (sink *FileSink) Name() string {
+	return "file"
+}
+
+// Write appends event and syncs the file. An event equal to the last line is
+// a redelivery and is skipped. A failed append is truncated away so the
+// retry does not leave a partial line in the chain.
+func (sink *FileSink) Write(ctx context.Context, event identity.AuditEvent) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if event.ID == sink.lastEventID {
+		return nil
+	}
+	encodedEvent, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode audit event: %w", err)
+	}
+	hash := chainHash(sink.lastHash, encodedEvent)
+	line := make([]byte, 0, len(encodedEvent)+200)
+	line = append(line, `{"event":`...)
+	line = append(line, encodedEvent...)
+	line = append(line, `,"previous_hash":"`+sink.lastHash+`","hash":"`+hash+"\"}\n"...)
+
+	if _, err := sink.file.Write(line); err != nil {
+		sink.file.Truncate(sink.size)
+		return fmt.Errorf("append audit log: %w", err)
+	}
+	if err := sink.file.Sync(); err != nil {
+		sink.file.Truncate(sink.size)
+		return fmt.Errorf("sync audit log: %w", err)
+	}
+	sink.size += int64(len(line))
+	sink.lastHash = hash
+	sink.lastEventID = event.ID
+	return nil
+}
+
+func (sink *FileSink) Close() error {
+	return sink.file.Close()
+}
+
+// VerifyResult describes a verified log. LastHash is the chain head; an
+// operator who records it elsewhere can later detect a truncated tail, which
+// the chain alone cannot reveal.
+type VerifyResult struct {
+	Events      int
+	LastHash    string
+	LastEventID string
+}
+
+// Verify reads a log from the start and checks every line's link and hash.
+// Errors wrapping ErrTampered name the first bad line; other errors are read
+// failures.
+func Verify(reader io.Reader) (VerifyResult, error) {
+	result := VerifyResult{LastHash: genesisHash}
+	buffered := bufio.NewReader(reader)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := buffered.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				return result, fmt.Errorf("%w: line %d is incomplete", ErrTampered, lineNumber)
+			}
+			return result, nil
+		}
+		if err != nil {
+			return result, fmt.Errorf("read audit log: %w", err)
+		}
+
+		var record fileRecord
+		if err := json.Unmarshal(line, &record); err != nil || len(record.Event) == 0 {
+			return result, fmt.Errorf("%w: line %d is not an audit record", ErrTampered, lineNumber)
+		}
+		if record.PreviousHash != result.LastHash {
+			return result, fmt.Errorf("%w: line %d does not follow line %d", ErrTampered, lineNumber, lineNumber-1)
+		}
+		if record.Hash != chainHash(record.PreviousHash, record.Event) {
+			return result, fmt.Errorf("%w: line %d was modified", ErrTampered, lineNumber)
+		}
+		var event struct {
+			ID string `json:"id"`
+		}
+		if err := json.NewDecoder(bytes.NewReader(record.Event)).Decode(&event); err != nil {
+			return result, fmt.Errorf("%w: line %d holds no event ID", ErrTampered, lineNumber)
+		}
+		result.Events++
+		result.LastHash = record.Hash
+		result.LastEventID = event.ID
+	}
+}
+
+func chainHash(previousHash string, encodedEvent []byte) string {
+	digest := sha256.New()
+	digest.Write([]byte(previousHash))
+	digest.Write(encodedEvent)
+	return hex.EncodeToString(digest.Sum(nil))
+}
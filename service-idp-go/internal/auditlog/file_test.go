@@ -0,0 +1,99 @@
+package auditlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+func testAuditEvent(id string, action string) identity.AuditEvent {
+	return identity.AuditEvent{
+		ID:          id,
+		EventAction: action,
+		Outcome:     "成功",
+		Metadata:    json.RawMessage(`{"reason":"<script>"}`),
+		CreatedAt:   time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC),
+	}
+}
+
+func writeTestLog(t *testing.T, path string, events ...identity.AuditEvent) {
+	t.Helper()
+	sink, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("open audit log: %v", err)
+	}
+	defer sink.Close()
+	for _, event := range events {
+		if err := sink.Write(context.Background(), event); err != nil {
+			t.Fatalf("write audit event: %v", err)
+		}
+	}
+}
+
+func verifyTestLog(t *testing.T, path string) (VerifyResult, error) {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open audit log: %v", err)
+	}
+	defer file.Close()
+	return Verify(file)
+}
+
+// 重新打开后继续同一条哈希链；重复投递最后一条事件不会追加新行。
+func TestFileSinkChainsAcrossReopens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	writeTestLog(t, path, testAuditEvent("01JAUDIT000000000000000001", "登录"), testAuditEvent("01JAUDIT000000000000000002", "退出登录"))
+	writeTestLog(t, path, testAuditEvent("01JAUDIT000000000000000002", "退出登录"), testAuditEvent("01JAUDIT000000000000000003", "会话撤销"))
+
+	result, err := verifyTestLog(t, path)
+	if err != nil || result.Events != 3 || result.LastEventID != "01JAUDIT000000000000000003" || len(result.LastHash) != 64 {
+		t.Fatalf("verify = %#v, %v", result, err)
+	}
+	empty, err := Verify(strings.NewReader(""))
+	if err != nil || empty.Events != 0 || empty.LastHash != genesisHash {
+		t.Fatalf("verify empty log = %#v, %v", empty, err)
+	}
+}
+
+// 修改、删除、调换或截断某一行都会被校验发现，已损坏的文件不会被继续追加。
+func TestVerifyDetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	writeTestLog(t, path,
+		testAuditEvent("01JAUDIT000000000000000001", "登录"),
+		testAuditEvent("01JAUDIT000000000000000002", "角色授予"),
+		testAuditEvent("01JAUDIT000000000000000003", "退出登录"),
+	)
+	original, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	lines := bytes.SplitAfter(original, []byte("\n"))[:3]
+
+	for name, tampered := range map[string][]byte{
+		"modified":  bytes.Replace(original, []byte("角色授予"), []byte("角色撤销"), 1),
+		"removed":   bytes.Join([][]byte{lines[0], lines[2]}, nil),
+		"reordered": bytes.Join([][]byte{lines[1], lines[0], lines[2]}, nil),
+		"partial":   original[:len(original)-10],
+	} {
+		_, err := Verify(bytes.NewReader(tampered))
+		if !errors.Is(err, ErrTampered) {
+			t.Fatalf("%s: verify error = %v", name, err)
+		}
+	}
+
+	if err := os.WriteFile(path, original[:len(original)-10], 0o600); err != nil {
+		t.Fatalf("truncate audit log: %v", err)
+	}
+	if _, err := OpenFile(path); !errors.Is(err, ErrTampered) {
+		t.Fatalf("open tampered log error = %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package auditlog
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+const defaultWebhookTimeout = 10 * time.Second
+
+// WebhookSink POSTs each audit event as JSON to a collector. The body is
+// signed with HMAC-SHA256 in the X-Identityd-Signature header
+// ("sha256=<hex>"), and X-Identityd-Audit-Event carries the event ID so the
+// collector can drop the redeliveries that at-least-once delivery produces.
+// Retries are left to the dispatcher, which keeps undelivered events in the
+// outbox.
+type WebhookSink struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+// NewWebhook returns a sink posting to url. A nil client uses one with a
+// ten-second timeout.
+func NewWebhook(url string, secret []byte, client *http.Client) *WebhookSink {
+	if client == nil {
+		client = &http.Client{Timeout: defaultWebhookTimeout}
+	}
+	return &WebhookSink{url: url, secret: secret, client: client}
+}
+
+func (sink *WebhookSink) Name() string {
+	return "webhook"
+}
+
+// Write succeeds only on a 2xx response.
+func (sink *WebhookSink) Write(ctx context.Context, event identity.AuditEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode audit event: %w", err)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, sink.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build webhook request: %w", err)
+	}
+	signature := hmac.New(sha256.New, sink.secret)
+	signature.Write(body)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Identityd-Audit-Event", event.ID)
+	request.Header.Set("X-Identityd-Signature", "sha256="+hex.EncodeToString(signature.Sum(nil)))
+
+	response, err := sink.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("post audit event: %w", err)
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("post audit event: unexpected status %d", response.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package auditlog
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/db/migrations"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+const testWebhookSecret = "test-audit-webhook-secret-with-at-least-32-bytes"
+
+// collector stands in for the audit webhook: it answers 503 while down and
+// records the signed events it accepts.
+type collector struct {
+	mu       sync.Mutex
+	down     bool
+	received []identity.AuditEvent
+}
+
+func (collector *collector) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if collector.down {
+		responseWriter.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := io.ReadAll(request.Body)
+	mac := hmac.New(sha256.New, []byte(testWebhookSecret))
+	mac.Write(body)
+	if request.Header.Get("X-Identityd-Signature") != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		responseWriter.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var event identity.AuditEvent
+	if err := json.Unmarshal(body, &event); err != nil || request.Header.Get("X-Identityd-Audit-Event") != event.ID {
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	collector.received = append(collector.received, event)
+	responseWriter.WriteHeader(http.StatusAccepted)
+}
+
+func TestWebhookSinkSignsEventsAndRejectsFailures(t *testing.T) {
+	stand := &collector{}
+	server := httptest.NewServer(stand)
+	t.Cleanup(server.Close)
+
+	event := testAuditEvent("01JAUDIT000000000000000001", "登录")
+	if err := NewWebhook(server.URL, []byte(testWebhookSecret), nil).Write(context.Background(), event); err != nil {
+		t.Fatalf("write signed event: %v", err)
+	}
+	if err := NewWebhook(server.URL, []byte("a-different-secret-of-at-least-32-bytes"), nil).Write(context.Background(), event); err == nil {
+		t.Fatal("write with a wrong signature succeeded")
+	}
+	if len(stand.received) != 1 || stand.received[0].ID != event.ID {
+		t.Fatalf("received = %#v", stand.received)
+	}
+}
+
+// webhook 不可用期间事件留在出站表中，恢复后按提交顺序补投递。
+func TestDispatchRetriesWebhookFromOutbox(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection, err := database.OpenSQLite(ctx, filepath.Join(t.TempDir(), "identityd.sqlite"))
+	if err != nil {
+		t.Fatalf("open SQLite database: %v", err)
+	}
+	t.Cleanup(func() {
+		databaseConnection.Close()
+	})
+	if _, err := database.Migrate(ctx, databaseConnection, migrations.Files); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	if _, err := identity.EnsureBootstrap(ctx, databaseConnection, identity.BootstrapInput{Identifier: "admin", Password: "correct horse battery staple"}); err != nil {
+		t.Fatalf("ensure bootstrap: %v", err)
+	}
+
+	stand := &collector{down: true}
+	server := httptest.NewServer(stand)
+	t.Cleanup(server.Close)
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	fileSink, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("open audit log: %v", err)
+	}
+	t.Cleanup(func() {
+		fileSink.Close()
+	})
+	sinks := []identity.AuditSink{fileSink, NewWebhook(server.URL, []byte(testWebhookSecret), nil)}
+
+	if delivered, err := identity.DispatchAuditEvents(ctx, databaseConnection, sinks); delivered != 1 || err == nil {
+		t.Fatalf("dispatch while collector is down = %d, %v", delivered, err)
+	}
+	if _, err := identity.Purge(ctx, databaseConnection, identity.LoginThrottleSettings{Window: time.Minute, LockoutDuration: time.Minute}); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+
+	stand.mu.Lock()
+	stand.down = false
+	stand.mu.Unlock()
+	if _, err := databaseConnection.Exec(`UPDATE identity_audit_sink_cursors SET next_attempt_at = NULL`); err != nil {
+		t.Fatalf("end backoff: %v", err)
+	}
+	if delivered, err := identity.DispatchAuditEvents(ctx, databaseConnection, sinks); delivered != 3 || err != nil {
+		t.Fatalf("dispatch after recovery = %d, %v", delivered, err)
+	}
+	if len(stand.received) != 2 || stand.received[0].EventAction != "主体创建" || stand.received[1].EventAction != "维护清理" {
+		t.Fatalf("collector received = %#v", stand.received)
+	}
+	result, err := verifyTestLog(t, path)
+	if err != nil || result.Events != 2 || result.LastEventID != stand.received[1].ID {
+		t.Fatalf("verify audit log = %#v, %v", result, err)
+	}
+}
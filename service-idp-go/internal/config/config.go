@@ -8,6 +8,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/password"
 )
 
 const (
@@ -19,6 +21,10 @@ const (
 	defaultThrottleLockout  = 15 * time.Minute
 	defaultThrottleFailures = 5
 	defaultTrustedProxyCIDR = "127.0.0.1/32"
+	defaultOIDCAccessTTL    = 10 * time.Minute
+	defaultOIDCCodeTTL      = time.Minute
+	defaultVerificationFile = ".data/verification-codes.jsonl"
+	maximumPasswordHistory  = 24
 )
 
 type Config struct {
@@ -36,6 +42,32 @@ type Config struct {
 	PublicURL                 *url.URL
 	TrustedProxyPrefixes      []netip.Prefix
 	CorsOrigins               []string
+	OIDCSigningKeyFile        string
+	OIDCAccessTokenTTL        time.Duration
+	OIDCAuthorizationCodeTTL  time.Duration
+	VerificationSender        string
+	VerificationFile          string
+	PasswordMinimumLength     int
+	PasswordDenylistFile      string
+	PasswordHistorySize       int
+	PasswordMaximumAge        time.Duration
+	AuditLogFile              string
+	AuditWebhookURL           string
+	AuditWebhookSecret        []byte
+	LDAPURL                   string
+	LDAPDomains               []string
+	LDAPBindDN                string
+	LDAPBindPassword          string
+	LDAPBaseDN                string
+	LDAPUserAttribute         string
+	LDAPInsecurePlaintext     bool
+	LDAPGroupRoles            []LDAPGroupRole
+}
+
+// LDAPGroupRole grants RoleCode to the members of the directory group Group.
+type LDAPGroupRole struct {
+	RoleCode string
+	Group    string
 }
 
 func Load() (Config, error) {
@@ -91,6 +123,58 @@ func LoadFromLookup(lookup func(string) string) (Config, error) {
 		return Config{}, err
 	}
 
+	oidcSigningKeyFile := strings.TrimSpace(lookup("IDENTITYD_OIDC_SIGNING_KEY_FILE"))
+	if oidcSigningKeyFile != "" && publicURL == nil {
+		return Config{}, fmt.Errorf("IDENTITYD_OIDC_SIGNING_KEY_FILE requires IDENTITYD_PUBLIC_URL as the issuer")
+	}
+	oidcAccessTokenTTL, err := durationValue(lookup, "IDENTITYD_OIDC_ACCESS_TOKEN_TTL", defaultOIDCAccessTTL)
+	if err != nil {
+		return Config{}, err
+	}
+	oidcAuthorizationCodeTTL, err := durationValue(lookup, "IDENTITYD_OIDC_AUTHORIZATION_CODE_TTL", defaultOIDCCodeTTL)
+	if err != nil {
+		return Config{}, err
+	}
+
+	verificationSender := stringValue(lookup, "IDENTITYD_VERIFICATION_SENDER", "log")
+	if verificationSender != "log" && verificationSender != "file" {
+		return Config{}, fmt.Errorf("IDENTITYD_VERIFICATION_SENDER must be log or file")
+	}
+
+	passwordMinimumLength, err := positiveIntegerValue(lookup, "IDENTITYD_PASSWORD_MIN_LENGTH", password.MinimumLength)
+	if err != nil {
+		return Config{}, err
+	}
+	if passwordMinimumLength < password.MinimumLength {
+		return Config{}, fmt.Errorf("IDENTITYD_PASSWORD_MIN_LENGTH must be at least %d", password.MinimumLength)
+	}
+	passwordHistorySize, err := passwordHistoryValue(lookup("IDENTITYD_PASSWORD_HISTORY"))
+	if err != nil {
+		return Config{}, err
+	}
+	passwordMaximumAge, err := durationValue(lookup, "IDENTITYD_PASSWORD_MAX_AGE", 0)
+	if err != nil {
+		return Config{}, err
+	}
+
+	auditWebhookURL, auditWebhookSecret, err := auditWebhookValues(lookup("IDENTITYD_AUDIT_WEBHOOK_URL"), lookup("IDENTITYD_AUDIT_WEBHOOK_SECRET"))
+	if err != nil {
+		return Config{}, err
+	}
+
+	ldapURL, ldapDomains, err := ldapValues(lookup("IDENTITYD_LDAP_URL"), lookup("IDENTITYD_LDAP_DOMAINS"), lookup("IDENTITYD_LDAP_BASE_DN"))
+	if err != nil {
+		return Config{}, err
+	}
+	ldapGroupRoles, err := ldapGroupRoleValues(lookup("IDENTITYD_LDAP_GROUP_ROLES"))
+	if err != nil {
+		return Config{}, err
+	}
+	ldapInsecurePlaintext, err := booleanValue(lookup, "IDENTITYD_LDAP_INSECURE_PLAINTEXT", false)
+	if err != nil {
+		return Config{}, err
+	}
+
 	bootstrapIdentifier := strings.TrimSpace(lookup("IDENTITYD_BOOTSTRAP_IDENTIFIER"))
 	bootstrapPassword := lookup("IDENTITYD_BOOTSTRAP_PASSWORD")
 	if (bootstrapIdentifier == "") != (bootstrapPassword == "") {
@@ -112,9 +196,114 @@ func LoadFromLookup(lookup func(string) string) (Config, error) {
 		PublicURL:                 publicURL,
 		TrustedProxyPrefixes:      trustedProxyPrefixes,
 		CorsOrigins:               corsOrigins,
+		OIDCSigningKeyFile:        oidcSigningKeyFile,
+		OIDCAccessTokenTTL:        oidcAccessTokenTTL,
+		OIDCAuthorizationCodeTTL:  oidcAuthorizationCodeTTL,
+		VerificationSender:        verificationSender,
+		VerificationFile:          stringValue(lookup, "IDENTITYD_VERIFICATION_FILE", defaultVerificationFile),
+		PasswordMinimumLength:     passwordMinimumLength,
+		PasswordDenylistFile:      strings.TrimSpace(lookup("IDENTITYD_PASSWORD_DENYLIST_FILE")),
+		PasswordHistorySize:       passwordHistorySize,
+		PasswordMaximumAge:        passwordMaximumAge,
+		AuditLogFile:              strings.TrimSpace(lookup("IDENTITYD_AUDIT_LOG_FILE")),
+		AuditWebhookURL:           auditWebhookURL,
+		AuditWebhookSecret:        auditWebhookSecret,
+		LDAPURL:                   ldapURL,
+		LDAPDomains:               ldapDomains,
+		LDAPBindDN:                strings.TrimSpace(lookup("IDENTITYD_LDAP_BIND_DN")),
+		LDAPBindPassword:          lookup("IDENTITYD_LDAP_BIND_PASSWORD"),
+		LDAPBaseDN:                strings.TrimSpace(lookup("IDENTITYD_LDAP_BASE_DN")),
+		LDAPUserAttribute:         stringValue(lookup, "IDENTITYD_LDAP_USER_ATTRIBUTE", "userPrincipalName"),
+		LDAPInsecurePlaintext:     ldapInsecurePlaintext,
+		LDAPGroupRoles:            ldapGroupRoles,
 	}, nil
 }
 
+// passwordHistoryValue allows zero, which turns the reuse check off.
+func passwordHistoryValue(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 || parsed > maximumPasswordHistory {
+		return 0, fmt.Errorf("IDENTITYD_PASSWORD_HISTORY must be between 0 and %d", maximumPasswordHistory)
+	}
+	return parsed, nil
+}
+
+// auditWebhookValues requires a signing secret whenever a webhook is set, so
+// the collector can reject events that did not come from identityd.
+func auditWebhookValues(rawURL string, rawSecret string) (string, []byte, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	secret := []byte(strings.TrimSpace(rawSecret))
+	if rawURL == "" {
+		if len(secret) > 0 {
+			return "", nil, fmt.Errorf("IDENTITYD_AUDIT_WEBHOOK_SECRET requires IDENTITYD_AUDIT_WEBHOOK_URL")
+		}
+		return "", nil, nil
+	}
+
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" || parsedURL.User != nil {
+		return "", nil, fmt.Errorf("IDENTITYD_AUDIT_WEBHOOK_URL must be an absolute http or https URL")
+	}
+	if len(secret) < 32 {
+		return "", nil, fmt.Errorf("IDENTITYD_AUDIT_WEBHOOK_SECRET must contain at least 32 bytes")
+	}
+	return rawURL, secret, nil
+}
+
+// ldapValues requires the domains and base DN whenever a directory is set,
+// since without domains no login would ever reach it.
+func ldapValues(rawURL string, rawDomains string, baseDN string) (string, []string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	domains := []string{}
+	for _, part := range strings.Split(rawDomains, ",") {
+		if domain := strings.ToLower(strings.TrimSpace(part)); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	if rawURL == "" {
+		if len(domains) > 0 {
+			return "", nil, fmt.Errorf("IDENTITYD_LDAP_DOMAINS requires IDENTITYD_LDAP_URL")
+		}
+		return "", nil, nil
+	}
+
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || (parsedURL.Scheme != "ldap" && parsedURL.Scheme != "ldaps") || parsedURL.Hostname() == "" || parsedURL.User != nil {
+		return "", nil, fmt.Errorf("IDENTITYD_LDAP_URL must be an ldap or ldaps URL")
+	}
+	if len(domains) == 0 {
+		return "", nil, fmt.Errorf("IDENTITYD_LDAP_URL requires IDENTITYD_LDAP_DOMAINS")
+	}
+	if strings.TrimSpace(baseDN) == "" {
+		return "", nil, fmt.Errorf("IDENTITYD_LDAP_URL requires IDENTITYD_LDAP_BASE_DN")
+	}
+	return rawURL, domains, nil
+}
+
+// ldapGroupRoleValues parses role_code=group DN pairs separated by
+// semicolons, since group DNs themselves contain commas and equals signs.
+func ldapGroupRoleValues(value string) ([]LDAPGroupRole, error) {
+	groupRoles := []LDAPGroupRole{}
+	for _, part := range strings.Split(value, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		roleCode, group, found := strings.Cut(part, "=")
+		roleCode = strings.TrimSpace(roleCode)
+		group = strings.TrimSpace(group)
+		if !found || roleCode == "" || group == "" {
+			return nil, fmt.Errorf("IDENTITYD_LDAP_GROUP_ROLES must contain role_code=group DN pairs separated by semicolons")
+		}
+		groupRoles = append(groupRoles, LDAPGroupRole{RoleCode: roleCode, Group: group})
+	}
+	return groupRoles, nil
+}
+
 func corsOriginsValue(value string) ([]string, error) {
 	if strings.TrimSpace(value) == "" {
 		return []string{"http://localhost:4324", "http://127.0.0.1:4324"}, nil
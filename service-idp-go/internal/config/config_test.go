@@ -146,8 +146,203 @@ func TestLoadFromLookupRejectsMissingOrWeakLoginThrottleSecret(t *testing.T) {
 	}
 }
 
+func TestLoadFromLookupConfiguresOIDC(t *testing.T) {
+	configuration, err := LoadFromLookup(valuesLookup(map[string]string{
+		"IDENTITYD_LOGIN_THROTTLE_SECRET":       "test-login-throttle-secret-with-at-least-32-bytes",
+		"IDENTITYD_PUBLIC_URL":                  "https://identity.example.test/crate-api/identity/v1",
+		"IDENTITYD_OIDC_SIGNING_KEY_FILE":       "/run/secrets/identityd-oidc.pem",
+		"IDENTITYD_OIDC_ACCESS_TOKEN_TTL":       "5m",
+		"IDENTITYD_OIDC_AUTHORIZATION_CODE_TTL": "30s",
+	}))
+	if err != nil {
+		t.Fatalf("load OIDC configuration: %v", err)
+	}
+	if configuration.OIDCSigningKeyFile != "/run/secrets/identityd-oidc.pem" {
+		t.Fatalf("OIDC signing key file = %q", configuration.OIDCSigningKeyFile)
+	}
+	if configuration.OIDCAccessTokenTTL != 5*time.Minute || configuration.OIDCAuthorizationCodeTTL != 30*time.Second {
+		t.Fatalf("OIDC TTLs = %s, %s", configuration.OIDCAccessTokenTTL, configuration.OIDCAuthorizationCodeTTL)
+	}
+
+	defaults, err := LoadFromLookup(valuesLookup(map[string]string{
+		"IDENTITYD_LOGIN_THROTTLE_SECRET": "test-login-throttle-secret-with-at-least-32-bytes",
+	}))
+	if err != nil {
+		t.Fatalf("load defaults: %v", err)
+	}
+	if defaults.OIDCSigningKeyFile != "" || defaults.OIDCAccessTokenTTL != defaultOIDCAccessTTL || defaults.OIDCAuthorizationCodeTTL != defaultOIDCCodeTTL {
+		t.Fatalf("OIDC defaults = %q, %s, %s", defaults.OIDCSigningKeyFile, defaults.OIDCAccessTokenTTL, defaults.OIDCAuthorizationCodeTTL)
+	}
+
+	// 签名密钥需要 IDENTITYD_PUBLIC_URL 作为 issuer。
+	_, err = LoadFromLookup(valuesLookup(map[string]string{
+		"IDENTITYD_LOGIN_THROTTLE_SECRET": "test-login-throttle-secret-with-at-least-32-bytes",
+		"IDENTITYD_OIDC_SIGNING_KEY_FILE": "/run/secrets/identityd-oidc.pem",
+	}))
+	if err == nil || !strings.Contains(err.Error(), "IDENTITYD_PUBLIC_URL") {
+		t.Fatalf("missing issuer error = %v", err)
+	}
+}
+
+func TestLoadFromLookupConfiguresVerificationSender(t *testing.T) {
+	defaults, err := LoadFromLookup(valuesLookup(map[string]string{
+		"IDENTITYD_LOGIN_THROTTLE_SECRET": "test-login-throttle-secret-with-at-least-32-bytes",
+	}))
+	if err != nil {
+		t.Fatalf("load defaults: %v", err)
+	}
+	if defaults.VerificationSender != "log" || defaults.VerificationFile != defaultVerificationFile {
+		t.Fatalf("verification defaults = %q, %q", defaults.VerificationSender, defaults.VerificationFile)
+	}
+
+	configuration, err := LoadFromLookup(valuesLookup(map[string]string{
+		"IDENTITYD_LOGIN_THROTTLE_SECRET": "test-login-throttle-secret-with-at-least-32-bytes",
+		"IDENTITYD_VERIFICATION_SENDER":   "file",
+		"IDENTITYD_VERIFICATION_FILE":     "runtime/codes.jsonl",
+	}))
+	if err != nil {
+		t.Fatalf("load verification configuration: %v", err)
+	}
+	if configuration.VerificationSender != "file" || configuration.VerificationFile != "runtime/codes.jsonl" {
+		t.Fatalf("verification configuration = %q, %q", configuration.VerificationSender, configuration.VerificationFile)
+	}
+
+	// 仅支持开发用的 log 与 file 发送器。
+	_, err = LoadFromLookup(valuesLookup(map[string]string{
+		"IDENTITYD_LOGIN_THROTTLE_SECRET": "test-login-throttle-secret-with-at-least-32-bytes",
+		"IDENTITYD_VERIFICATION_SENDER":   "smtp",
+	}))
+	if err == nil || !strings.Contains(err.Error(), "IDENTITYD_VERIFICATION_SENDER") {
+		t.Fatalf("unknown sender error = %v", err)
+	}
+}
+
+func TestLoadFromLookupConfiguresPasswordPolicy(t *testing.T) {
+	defaults, err := LoadFromLookup(valuesLookup(map[string]string{
+		"IDENTITYD_LOGIN_THROTTLE_SECRET": "test-login-throttle-secret-with-at-least-32-bytes",
+	}))
+	if err != nil {
+		t.Fatalf("load defaults: %v", err)
+	}
+	if defaults.PasswordMinimumLength != 12 || defaults.PasswordDenylistFile != "" || defaults.PasswordHistorySize != 0 || defaults.PasswordMaximumAge != 0 {
+		t.Fatalf("password policy defaults = %d, %q, %d, %s", defaults.PasswordMinimumLength, defaults.PasswordDenylistFile, defaults.PasswordHistorySize, defaults.PasswordMaximumAge)
+	}
+
+	configuration, err := LoadFromLookup(valuesLookup(map[string]string{
+		"IDENTITYD_LOGIN_THROTTLE_SECRET":  "test-login-throttle-secret-with-at-least-32-bytes",
+		"IDENTITYD_PASSWORD_MIN_LENGTH":    "16",
+		"IDENTITYD_PASSWORD_DENYLIST_FILE": "/etc/identityd/denylist.txt",
+		"IDENTITYD_PASSWORD_HISTORY":       "5",
+		"IDENTITYD_PASSWORD_MAX_AGE":       "2160h",
+	}))
+	if err != nil {
+		t.Fatalf("load password policy: %v", err)
+	}
+	if configuration.PasswordMinimumLength != 16 || configuration.PasswordDenylistFile != "/etc/identityd/denylist.txt" || configuration.PasswordHistorySize != 5 || configuration.PasswordMaximumAge != 2160*time.Hour {
+		t.Fatalf("password policy = %d, %q, %d, %s", configuration.PasswordMinimumLength, configuration.PasswordDenylistFile, configuration.PasswordHistorySize, configuration.PasswordMaximumAge)
+	}
+
+	// 最小长度不能低于哈希下限，历史数量受存储上限约束。
+	for key, value := range map[string]string{
+		"IDENTITYD_PASSWORD_MIN_LENGTH": "8",
+		"IDENTITYD_PASSWORD_HISTORY":    "25",
+		"IDENTITYD_PASSWORD_MAX_AGE":    "-1h",
+	} {
+		_, err := LoadFromLookup(valuesLookup(map[string]string{
+			"IDENTITYD_LOGIN_THROTTLE_SECRET": "test-login-throttle-secret-with-at-least-32-bytes",
+			key:                               value,
+		}))
+		if err == nil || !strings.Contains(err.Error(), key) {
+			t.Fatalf("%s=%s error = %v", key, value, err)
+		}
+	}
+}
+
+func TestLoadFromLookupConfiguresLDAPDirectory(t *testing.T) {
+	defaults, err := LoadFromLookup(valuesLookup(map[string]string{
+		"IDENTITYD_LOGIN_THROTTLE_SECRET": "test-login-throttle-secret-with-at-least-32-bytes",
+	}))
+	if err != nil {
+		t.Fatalf("load defaults: %v", err)
+	}
+	if defaults.LDAPURL != "" || len(defaults.LDAPDomains) != 0 || len(defaults.LDAPGroupRoles) != 0 || defaults.LDAPInsecurePlaintext {
+		t.Fatalf("LDAP defaults = %q, %v, %v", defaults.LDAPURL, defaults.LDAPDomains, defaults.LDAPGroupRoles)
+	}
+
+	configuration, err := LoadFromLookup(valuesLookup(map[string]string{
+		"IDENTITYD_LOGIN_THROTTLE_SECRET": "test-login-throttle-secret-with-at-least-32-bytes",
+		"IDENTITYD_LDAP_URL":              "ldaps://dc.corp.example.test",
+		"IDENTITYD_LDAP_DOMAINS":          "Corp.Example.Test, example.test",
+		"IDENTITYD_LDAP_BIND_DN":          "CN=identityd,OU=Service,DC=corp,DC=example,DC=test",
+		"IDENTITYD_LDAP_BIND_PASSWORD":    "service password",
+		"IDENTITYD_LDAP_BASE_DN":          "DC=corp,DC=example,DC=test",
+		"IDENTITYD_LDAP_GROUP_ROLES":      "crm.viewer=CN=CRM Users,OU=Groups,DC=corp,DC=example,DC=test; identity.admin=CN=Identity Admins,OU=Groups,DC=corp,DC=example,DC=test",
+	}))
+	if err != nil {
+		t.Fatalf("load LDAP directory: %v", err)
+	}
+	if configuration.LDAPURL != "ldaps://dc.corp.example.test" || strings.Join(configuration.LDAPDomains, ",") != "corp.example.test,example.test" || configuration.LDAPUserAttribute != "userPrincipalName" || configuration.LDAPBindPassword != "service password" {
+		t.Fatalf("LDAP directory = %#v", configuration)
+	}
+	if len(configuration.LDAPGroupRoles) != 2 || configuration.LDAPGroupRoles[0] != (LDAPGroupRole{RoleCode: "crm.viewer", Group: "CN=CRM Users,OU=Groups,DC=corp,DC=example,DC=test"}) || configuration.LDAPGroupRoles[1].RoleCode != "identity.admin" {
+		t.Fatalf("LDAP group roles = %#v", configuration.LDAPGroupRoles)
+	}
+
+	// 目录必须同时配置域名和搜索起点，域名也不能脱离目录单独配置。
+	for name, values := range map[string]map[string]string{
+		"requires IDENTITYD_LDAP_DOMAINS": {"IDENTITYD_LDAP_URL": "ldap://dc.corp.example.test", "IDENTITYD_LDAP_BASE_DN": "DC=corp"},
+		"requires IDENTITYD_LDAP_BASE_DN": {"IDENTITYD_LDAP_URL": "ldap://dc.corp.example.test", "IDENTITYD_LDAP_DOMAINS": "corp.example.test"},
+		"requires IDENTITYD_LDAP_URL":     {"IDENTITYD_LDAP_DOMAINS": "corp.example.test"},
+		"IDENTITYD_LDAP_URL must":         {"IDENTITYD_LDAP_URL": "https://dc.corp.example.test", "IDENTITYD_LDAP_DOMAINS": "corp.example.test", "IDENTITYD_LDAP_BASE_DN": "DC=corp"},
+		"IDENTITYD_LDAP_GROUP_ROLES":      {"IDENTITYD_LDAP_GROUP_ROLES": "crm.viewer"},
+		"IDENTITYD_LDAP_INSECURE":         {"IDENTITYD_LDAP_INSECURE_PLAINTEXT": "yes"},
+	} {
+		values["IDENTITYD_LOGIN_THROTTLE_SECRET"] = "test-login-throttle-secret-with-at-least-32-bytes"
+		if _, err := LoadFromLookup(valuesLookup(values)); err == nil || !strings.Contains(err.Error(), name) {
+			t.Fatalf("%s error = %v", name, err)
+		}
+	}
+}
+
 func valuesLookup(values map[string]string) func(string) string {
 	return func(key string) string {
 		return values[key]
 	}
 }
+
+func TestLoadFromLookupConfiguresAuditSinks(t *testing.T) {
+	defaults, err := LoadFromLookup(valuesLookup(map[string]string{
+		"IDENTITYD_LOGIN_THROTTLE_SECRET": "test-login-throttle-secret-with-at-least-32-bytes",
+	}))
+	if err != nil {
+		t.Fatalf("load defaults: %v", err)
+	}
+	if defaults.AuditLogFile != "" || defaults.AuditWebhookURL != "" || defaults.AuditWebhookSecret != nil {
+		t.Fatalf("audit sink defaults = %q, %q", defaults.AuditLogFile, defaults.AuditWebhookURL)
+	}
+
+	configuration, err := LoadFromLookup(valuesLookup(map[string]string{
+		"IDENTITYD_LOGIN_THROTTLE_SECRET": "test-login-throttle-secret-with-at-least-32-bytes",
+		"IDENTITYD_AUDIT_LOG_FILE":        "/var/log/identityd/audit.jsonl",
+		"IDENTITYD_AUDIT_WEBHOOK_URL":     "https://siem.example.test/identityd",
+		"IDENTITYD_AUDIT_WEBHOOK_SECRET":  "test-audit-webhook-secret-with-at-least-32-bytes",
+	}))
+	if err != nil {
+		t.Fatalf("load audit sinks: %v", err)
+	}
+	if configuration.AuditLogFile != "/var/log/identityd/audit.jsonl" || configuration.AuditWebhookURL != "https://siem.example.test/identityd" || string(configuration.AuditWebhookSecret) != "test-audit-webhook-secret-with-at-least-32-bytes" {
+		t.Fatalf("audit sinks = %q, %q", configuration.AuditLogFile, configuration.AuditWebhookURL)
+	}
+
+	// webhook 必须配置签名密钥，密钥也不能脱离 webhook 单独配置。
+	for name, values := range map[string]map[string]string{
+		"IDENTITYD_AUDIT_WEBHOOK_SECRET":       {"IDENTITYD_AUDIT_WEBHOOK_URL": "https://siem.example.test/identityd"},
+		"IDENTITYD_AUDIT_WEBHOOK_URL":          {"IDENTITYD_AUDIT_WEBHOOK_URL": "ftp://siem.example.test", "IDENTITYD_AUDIT_WEBHOOK_SECRET": "test-audit-webhook-secret-with-at-least-32-bytes"},
+		"requires IDENTITYD_AUDIT_WEBHOOK_URL": {"IDENTITYD_AUDIT_WEBHOOK_SECRET": "test-audit-webhook-secret-with-at-least-32-bytes"},
+	} {
+		values["IDENTITYD_LOGIN_THROTTLE_SECRET"] = "test-login-throttle-secret-with-at-least-32-bytes"
+		if _, err := LoadFromLookup(valuesLookup(values)); err == nil || !strings.Contains(err.Error(), name) {
+			t.Fatalf("%s error = %v", name, err)
+		}
+	}
+}
@@ -48,6 +48,69 @@ func Migrate(ctx context.Context, database *sql.DB, migrationFiles fs.FS) (Migra
 	return result, nil
 }
 
+// MigrationStatus describes one embedded migration and whether the database
+// has applied it.
+type MigrationStatus struct {
+	Version   string
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// MigrationStatuses lists the embedded migrations in order without applying
+// anything. A database that was never migrated reports every migration as
+// pending.
+func MigrationStatuses(ctx context.Context, database *sql.DB, migrationFiles fs.FS) ([]MigrationStatus, error) {
+	entries, err := fs.ReadDir(migrationFiles, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read embedded migrations: %w", err)
+	}
+	migrationNames, err := migrationNames(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	appliedAt := make(map[string]time.Time)
+	var tableCount int
+	if err := database.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'").Scan(&tableCount); err != nil {
+		return nil, fmt.Errorf("check schema migrations table: %w", err)
+	}
+	if tableCount > 0 {
+		rows, err := database.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+		if err != nil {
+			return nil, fmt.Errorf("list applied migrations: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var version string
+			var applied time.Time
+			if err := rows.Scan(&version, &applied); err != nil {
+				return nil, fmt.Errorf("scan applied migration: %w", err)
+			}
+			appliedAt[version] = applied
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("list applied migrations: %w", err)
+		}
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrationNames))
+	for _, migrationName := range migrationNames {
+		version, err := migrationVersion(migrationName)
+		if err != nil {
+			return nil, err
+		}
+		applied, ok := appliedAt[version]
+		statuses = append(statuses, MigrationStatus{
+			Version:   version,
+			Name:      strings.TrimSuffix(migrationName[len(version)+1:], ".sql"),
+			Applied:   ok,
+			AppliedAt: applied,
+		})
+	}
+	return statuses, nil
+}
+
 func applyMigration(ctx context.Context, database *sql.DB, migrationFiles fs.FS, migrationName string) (bool, error) {
 	version, err := migrationVersion(migrationName)
 	if err != nil {
@@ -21,8 +21,8 @@ func TestMigrateAppliesPhaseOneSchemaOnce(t *testing.T) {
 	if err != nil {
 		t.Fatalf("first migration: %v", err)
 	}
-	if firstResult.Applied != 9 {
-		t.Fatalf("first applied count = %d, want 9", firstResult.Applied)
+	if firstResult.Applied != 21 {
+		t.Fatalf("first applied count = %d, want 21", firstResult.Applied)
 	}
 
 	secondResult, err := database.Migrate(context, databaseConnection, migrations.Files)
@@ -55,6 +55,28 @@ func TestMigrateAppliesPhaseOneSchemaOnce(t *testing.T) {
 	if tableCount != 9 {
 		t.Fatalf("Phase 1 table count = %d, want 9", tableCount)
 	}
+
+	var oidcTableCount int
+	if err := databaseConnection.QueryRowContext(context, `
+		SELECT COUNT(*)
+		FROM sqlite_master
+		WHERE type = 'table'
+		  AND name IN (
+				'oidc_clients',
+				'oidc_client_redirect_uris',
+				'oidc_client_scopes',
+				'oidc_authorization_codes',
+				'oidc_signing_keys',
+				'oidc_machine_clients',
+				'oidc_machine_client_scopes',
+				'oidc_machine_client_secrets'
+		  )
+	`).Scan(&oidcTableCount); err != nil {
+		t.Fatalf("count OIDC tables: %v", err)
+	}
+	if oidcTableCount != 8 {
+		t.Fatalf("OIDC table count = %d, want 8", oidcTableCount)
+	}
 }
 
 func TestOpenSQLiteConfiguresSafetyPragmas(t *testing.T) {
@@ -155,6 +177,34 @@ func TestMigrateRollsBackFailingMigration(t *testing.T) {
 	}
 }
 
+func TestMigrationStatusesReportPendingAndAppliedMigrations(t *testing.T) {
+	databaseConnection := testDatabase(t)
+	files := fstest.MapFS{
+		"000001_first.sql":  &fstest.MapFile{Data: []byte("CREATE TABLE first_table(id INTEGER);")},
+		"000002_second.sql": &fstest.MapFile{Data: []byte("CREATE TABLE second_table(id INTEGER);")},
+	}
+
+	// 尚未迁移的数据库没有 schema_migrations 表，全部报告为待执行。
+	pending, err := database.MigrationStatuses(context.Background(), databaseConnection, fs.FS(files))
+	if err != nil {
+		t.Fatalf("status before migration: %v", err)
+	}
+	if len(pending) != 2 || pending[0].Applied || pending[1].Applied || pending[0].Name != "first" {
+		t.Fatalf("pending statuses = %#v", pending)
+	}
+
+	if _, err := database.Migrate(context.Background(), databaseConnection, fstest.MapFS{"000001_first.sql": files["000001_first.sql"]}); err != nil {
+		t.Fatalf("apply first migration: %v", err)
+	}
+	statuses, err := database.MigrationStatuses(context.Background(), databaseConnection, fs.FS(files))
+	if err != nil {
+		t.Fatalf("status after migration: %v", err)
+	}
+	if !statuses[0].Applied || statuses[0].AppliedAt.IsZero() || statuses[1].Applied || statuses[1].Version != "000002" {
+		t.Fatalf("statuses = %#v", statuses)
+	}
+}
+
 func testDatabase(t *testing.T) *sql.DB {
 	t.Helper()
 	databaseConnection, err := database.OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "identityd.sqlite"))
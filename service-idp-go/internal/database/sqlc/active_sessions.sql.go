@@ -44,3 +44,57 @@ func (q *Queries) GetActiveSessionByTokenHash(ctx context.Context, arg GetActive
 	)
 	return i, err
 }
+
+const listActiveSessionsBySubjectID = `-- name: ListActiveSessionsBySubjectID :many
+SELECT id,session_access,authenticated_at,last_seen_at,expires_at,idle_expires_at,metadata
+FROM identity_sessions
+WHERE subject_id=? AND revoked_at IS NULL AND expires_at>? AND idle_expires_at>?
+ORDER BY last_seen_at DESC, id DESC
+`
+
+type ListActiveSessionsBySubjectIDParams struct {
+	SubjectID     string    `json:"subject_id"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	IdleExpiresAt time.Time `json:"idle_expires_at"`
+}
+
+type ListActiveSessionsBySubjectIDRow struct {
+	ID              string    `json:"id"`
+	SessionAccess   string    `json:"session_access"`
+	AuthenticatedAt time.Time `json:"authenticated_at"`
+	LastSeenAt      time.Time `json:"last_seen_at"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	IdleExpiresAt   time.Time `json:"idle_expires_at"`
+	Metadata        string    `json:"metadata"`
+}
+
+func (q *Queries) ListActiveSessionsBySubjectID(ctx context.Context, arg ListActiveSessionsBySubjectIDParams) ([]ListActiveSessionsBySubjectIDRow, error) {
+	rows, err := q.db.QueryContext(ctx, listActiveSessionsBySubjectID, arg.SubjectID, arg.ExpiresAt, arg.IdleExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListActiveSessionsBySubjectIDRow
+	for rows.Next() {
+		var i ListActiveSessionsBySubjectIDRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SessionAccess,
+			&i.AuthenticatedAt,
+			&i.LastSeenAt,
+			&i.ExpiresAt,
+			&i.IdleExpiresAt,
+			&i.Metadata,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
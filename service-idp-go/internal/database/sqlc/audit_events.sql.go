@@ -44,3 +44,71 @@ func (q *Queries) InsertAuditEvent(ctx context.Context, arg InsertAuditEventPara
 	)
 	return err
 }
+
+const listAuditEvents = `-- name: ListAuditEvents :many
+SELECT id, event_action, outcome, actor_subject_id, target_subject_id,
+       request_id, source_hash, metadata, created_at
+FROM identity_audit_events
+WHERE (event_action = ?1 OR ?1 IS NULL)
+  AND (outcome = ?2 OR ?2 IS NULL)
+  AND (actor_subject_id = ?3 OR ?3 IS NULL)
+  AND (target_subject_id = ?4 OR ?4 IS NULL)
+  AND (created_at >= ?5 OR ?5 IS NULL)
+  AND (created_at < ?6 OR ?6 IS NULL)
+  AND (id < ?7 OR ?7 IS NULL)
+ORDER BY id DESC
+LIMIT ?8
+`
+
+type ListAuditEventsParams struct {
+	EventAction     sql.NullString `json:"event_action"`
+	Outcome         sql.NullString `json:"outcome"`
+	ActorSubjectID  sql.NullString `json:"actor_subject_id"`
+	TargetSubjectID sql.NullString `json:"target_subject_id"`
+	CreatedFrom     sql.NullTime   `json:"created_from"`
+	CreatedUntil    sql.NullTime   `json:"created_until"`
+	BeforeID        sql.NullString `json:"before_id"`
+	RowLimit        int64          `json:"row_limit"`
+}
+
+func (q *Queries) ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]IdentityAuditEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listAuditEvents,
+		arg.EventAction,
+		arg.Outcome,
+		arg.ActorSubjectID,
+		arg.TargetSubjectID,
+		arg.CreatedFrom,
+		arg.CreatedUntil,
+		arg.BeforeID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []IdentityAuditEvent
+	for rows.Next() {
+		var i IdentityAuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.EventAction,
+			&i.Outcome,
+			&i.ActorSubjectID,
+			&i.TargetSubjectID,
+			&i.RequestID,
+			&i.SourceHash,
+			&i.Metadata,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -42,6 +42,56 @@ func (q *Queries) CreatePasswordCredential(ctx context.Context, arg CreatePasswo
 	return err
 }
 
+const createPasswordHistoryEntry = `-- name: CreatePasswordHistoryEntry :exec
+INSERT INTO identity_password_history(id, subject_id, password_hash, created_at)
+VALUES (?, ?, ?, ?)
+`
+
+type CreatePasswordHistoryEntryParams struct {
+	ID           string    `json:"id"`
+	SubjectID    string    `json:"subject_id"`
+	PasswordHash string    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (q *Queries) CreatePasswordHistoryEntry(ctx context.Context, arg CreatePasswordHistoryEntryParams) error {
+	_, err := q.db.ExecContext(ctx, createPasswordHistoryEntry,
+		arg.ID,
+		arg.SubjectID,
+		arg.PasswordHash,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const expirePasswordCredential = `-- name: ExpirePasswordCredential :execrows
+UPDATE identity_password_credentials
+SET credential_status=?,updated_at=?
+WHERE subject_id=? AND password_revision=? AND credential_status=?
+`
+
+type ExpirePasswordCredentialParams struct {
+	CredentialStatus   string    `json:"credential_status"`
+	UpdatedAt          time.Time `json:"updated_at"`
+	SubjectID          string    `json:"subject_id"`
+	PasswordRevision   int64     `json:"password_revision"`
+	CredentialStatus_2 string    `json:"credential_status_2"`
+}
+
+func (q *Queries) ExpirePasswordCredential(ctx context.Context, arg ExpirePasswordCredentialParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, expirePasswordCredential,
+		arg.CredentialStatus,
+		arg.UpdatedAt,
+		arg.SubjectID,
+		arg.PasswordRevision,
+		arg.CredentialStatus_2,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getPasswordCredentialBySubjectID = `-- name: GetPasswordCredentialBySubjectID :one
 SELECT subject_id,password_hash,password_revision,credential_status
 FROM identity_password_credentials
@@ -67,6 +117,93 @@ func (q *Queries) GetPasswordCredentialBySubjectID(ctx context.Context, subjectI
 	return i, err
 }
 
+const listPasswordHistoryHashes = `-- name: ListPasswordHistoryHashes :many
+SELECT password_hash
+FROM identity_password_history
+WHERE subject_id=?
+ORDER BY created_at DESC, id DESC
+LIMIT ?
+`
+
+type ListPasswordHistoryHashesParams struct {
+	SubjectID string `json:"subject_id"`
+	Limit     int64  `json:"limit"`
+}
+
+func (q *Queries) ListPasswordHistoryHashes(ctx context.Context, arg ListPasswordHistoryHashesParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listPasswordHistoryHashes, arg.SubjectID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var password_hash string
+		if err := rows.Scan(&password_hash); err != nil {
+			return nil, err
+		}
+		items = append(items, password_hash)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const prunePasswordHistory = `-- name: PrunePasswordHistory :execrows
+DELETE FROM identity_password_history
+WHERE subject_id=? AND id NOT IN (
+    SELECT id
+    FROM identity_password_history
+    WHERE subject_id=?
+    ORDER BY created_at DESC, id DESC
+    LIMIT ?
+)
+`
+
+type PrunePasswordHistoryParams struct {
+	SubjectID   string `json:"subject_id"`
+	SubjectID_2 string `json:"subject_id_2"`
+	Limit       int64  `json:"limit"`
+}
+
+func (q *Queries) PrunePasswordHistory(ctx context.Context, arg PrunePasswordHistoryParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, prunePasswordHistory, arg.SubjectID, arg.SubjectID_2, arg.Limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const rehashPasswordCredential = `-- name: RehashPasswordCredential :execrows
+UPDATE identity_password_credentials
+SET password_hash=?,updated_at=?
+WHERE subject_id=? AND password_revision=?
+`
+
+type RehashPasswordCredentialParams struct {
+	PasswordHash     string    `json:"password_hash"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	SubjectID        string    `json:"subject_id"`
+	PasswordRevision int64     `json:"password_revision"`
+}
+
+func (q *Queries) RehashPasswordCredential(ctx context.Context, arg RehashPasswordCredentialParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rehashPasswordCredential,
+		arg.PasswordHash,
+		arg.UpdatedAt,
+		arg.SubjectID,
+		arg.PasswordRevision,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const updatePasswordCredential = `-- name: UpdatePasswordCredential :execrows
 UPDATE identity_password_credentials
 SET password_hash=?,credential_status=?,password_revision=password_revision+1,changed_at=?,updated_at=?
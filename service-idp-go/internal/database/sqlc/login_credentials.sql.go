@@ -7,10 +7,11 @@ package sqlc
 
 import (
 	"context"
+	"time"
 )
 
 const getLoginCredentialByNormalizedIdentifier = `-- name: GetLoginCredentialByNormalizedIdentifier :one
-SELECT i.subject_id,c.password_hash,c.credential_status
+SELECT i.subject_id,c.password_hash,c.credential_status,c.password_revision,c.changed_at
 FROM identity_identifiers i JOIN identity_password_credentials c ON c.subject_id=i.subject_id
 WHERE i.normalized_value=? AND i.status=? AND i.identifier_usage IN(?,?)
 LIMIT 1
@@ -24,9 +25,11 @@ type GetLoginCredentialByNormalizedIdentifierParams struct {
 }
 
 type GetLoginCredentialByNormalizedIdentifierRow struct {
-	SubjectID        string `json:"subject_id"`
-	PasswordHash     string `json:"password_hash"`
-	CredentialStatus string `json:"credential_status"`
+	SubjectID        string    `json:"subject_id"`
+	PasswordHash     string    `json:"password_hash"`
+	CredentialStatus string    `json:"credential_status"`
+	PasswordRevision int64     `json:"password_revision"`
+	ChangedAt        time.Time `json:"changed_at"`
 }
 
 func (q *Queries) GetLoginCredentialByNormalizedIdentifier(ctx context.Context, arg GetLoginCredentialByNormalizedIdentifierParams) (GetLoginCredentialByNormalizedIdentifierRow, error) {
@@ -37,6 +40,12 @@ func (q *Queries) GetLoginCredentialByNormalizedIdentifier(ctx context.Context,
 		arg.IdentifierUsage_2,
 	)
 	var i GetLoginCredentialByNormalizedIdentifierRow
-	err := row.Scan(&i.SubjectID, &i.PasswordHash, &i.CredentialStatus)
+	err := row.Scan(
+		&i.SubjectID,
+		&i.PasswordHash,
+		&i.CredentialStatus,
+		&i.PasswordRevision,
+		&i.ChangedAt,
+	)
 	return i, err
 }
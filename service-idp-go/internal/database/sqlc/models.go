@@ -21,6 +21,21 @@ type IdentityAuditEvent struct {
 	CreatedAt       time.Time      `json:"created_at"`
 }
 
+type IdentityAuditOutbox struct {
+	Sequence     int64     `json:"sequence"`
+	AuditEventID string    `json:"audit_event_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type IdentityAuditSinkCursor struct {
+	Sink              string         `json:"sink"`
+	DeliveredSequence int64          `json:"delivered_sequence"`
+	Attempts          int64          `json:"attempts"`
+	NextAttemptAt     sql.NullTime   `json:"next_attempt_at"`
+	LastError         sql.NullString `json:"last_error"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+}
+
 type IdentityIdentifier struct {
 	ID              string       `json:"id"`
 	SubjectID       string       `json:"subject_id"`
@@ -34,6 +49,14 @@ type IdentityIdentifier struct {
 	UpdatedAt       time.Time    `json:"updated_at"`
 }
 
+type IdentityIdentifierVerification struct {
+	IdentifierID string    `json:"identifier_id"`
+	CodeHash     []byte    `json:"code_hash"`
+	Attempts     int64     `json:"attempts"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
 type IdentityLoginThrottle struct {
 	ID              string       `json:"id"`
 	IdentifierHash  []byte       `json:"identifier_hash"`
@@ -55,6 +78,24 @@ type IdentityPasswordCredential struct {
 	UpdatedAt        time.Time `json:"updated_at"`
 }
 
+type IdentityPasswordHistory struct {
+	ID           string    `json:"id"`
+	SubjectID    string    `json:"subject_id"`
+	PasswordHash string    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type IdentityPasswordResetToken struct {
+	ID               string       `json:"id"`
+	SubjectID        string       `json:"subject_id"`
+	IdentifierID     string       `json:"identifier_id"`
+	TokenHash        []byte       `json:"token_hash"`
+	PasswordRevision int64        `json:"password_revision"`
+	ExpiresAt        time.Time    `json:"expires_at"`
+	ConsumedAt       sql.NullTime `json:"consumed_at"`
+	CreatedAt        time.Time    `json:"created_at"`
+}
+
 type IdentityProfile struct {
 	SubjectID   string    `json:"subject_id"`
 	DisplayName string    `json:"display_name"`
@@ -62,13 +103,22 @@ type IdentityProfile struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+type IdentityRecoveryCode struct {
+	ID         string       `json:"id"`
+	SubjectID  string       `json:"subject_id"`
+	CodeHash   []byte       `json:"code_hash"`
+	ConsumedAt sql.NullTime `json:"consumed_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
 type IdentityRole struct {
-	ID          string    `json:"id"`
-	RoleCode    string    `json:"role_code"`
-	DisplayName string    `json:"display_name"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID           string    `json:"id"`
+	RoleCode     string    `json:"role_code"`
+	DisplayName  string    `json:"display_name"`
+	Description  string    `json:"description"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	SecondFactor string    `json:"second_factor"`
 }
 
 type IdentitySession struct {
@@ -98,6 +148,11 @@ type IdentitySubject struct {
 	UpdatedAt       time.Time    `json:"updated_at"`
 }
 
+type IdentitySubjectErasure struct {
+	SubjectID string    `json:"subject_id"`
+	ErasedAt  time.Time `json:"erased_at"`
+}
+
 type IdentitySubjectRole struct {
 	ID                 string         `json:"id"`
 	SubjectID          string         `json:"subject_id"`
@@ -105,3 +160,82 @@ type IdentitySubjectRole struct {
 	GrantedBySubjectID sql.NullString `json:"granted_by_subject_id"`
 	CreatedAt          time.Time      `json:"created_at"`
 }
+
+type IdentityTotpCredential struct {
+	SubjectID    string       `json:"subject_id"`
+	Secret       []byte       `json:"secret"`
+	Status       string       `json:"status"`
+	LastUsedStep int64        `json:"last_used_step"`
+	ConfirmedAt  sql.NullTime `json:"confirmed_at"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+type OidcAuthorizationCode struct {
+	ID                     string         `json:"id"`
+	CodeHash               []byte         `json:"code_hash"`
+	OidcClientID           string         `json:"oidc_client_id"`
+	SubjectID              string         `json:"subject_id"`
+	SubjectSecurityVersion int64          `json:"subject_security_version"`
+	RedirectUri            string         `json:"redirect_uri"`
+	Scope                  string         `json:"scope"`
+	Nonce                  sql.NullString `json:"nonce"`
+	CodeChallenge          string         `json:"code_challenge"`
+	CodeChallengeMethod    string         `json:"code_challenge_method"`
+	ExpiresAt              time.Time      `json:"expires_at"`
+	ConsumedAt             sql.NullTime   `json:"consumed_at"`
+	CreatedAt              time.Time      `json:"created_at"`
+}
+
+type OidcClient struct {
+	ID          string    `json:"id"`
+	ClientID    string    `json:"client_id"`
+	DisplayName string    `json:"display_name"`
+	Status      string    `json:"status"`
+	Metadata    string    `json:"metadata"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type OidcClientRedirectUri struct {
+	OidcClientID string    `json:"oidc_client_id"`
+	RedirectUri  string    `json:"redirect_uri"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type OidcClientScope struct {
+	OidcClientID string    `json:"oidc_client_id"`
+	Scope        string    `json:"scope"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type OidcMachineClient struct {
+	ID          string    `json:"id"`
+	ClientID    string    `json:"client_id"`
+	DisplayName string    `json:"display_name"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type OidcMachineClientScope struct {
+	OidcMachineClientID string    `json:"oidc_machine_client_id"`
+	Scope               string    `json:"scope"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+type OidcMachineClientSecret struct {
+	ID                  string       `json:"id"`
+	OidcMachineClientID string       `json:"oidc_machine_client_id"`
+	SecretHash          []byte       `json:"secret_hash"`
+	ExpiresAt           sql.NullTime `json:"expires_at"`
+	CreatedAt           time.Time    `json:"created_at"`
+}
+
+type OidcSigningKey struct {
+	KeyID     string       `json:"key_id"`
+	Algorithm string       `json:"algorithm"`
+	PublicJwk string       `json:"public_jwk"`
+	CreatedAt time.Time    `json:"created_at"`
+	RetiredAt sql.NullTime `json:"retired_at"`
+}
@@ -6,41 +6,137 @@ package sqlc
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
 type Querier interface {
+	AdvanceTOTPLastUsedStep(ctx context.Context, arg AdvanceTOTPLastUsedStepParams) (int64, error)
+	AnonymizeSubjectIdentifiers(ctx context.Context, arg AnonymizeSubjectIdentifiersParams) (int64, error)
 	AssignSubjectRole(ctx context.Context, arg AssignSubjectRoleParams) error
+	ClearSessionMetadataBySubjectID(ctx context.Context, subjectID string) (int64, error)
+	ConsumeAuthorizationCode(ctx context.Context, arg ConsumeAuthorizationCodeParams) (ConsumeAuthorizationCodeRow, error)
+	ConsumePasswordResetToken(ctx context.Context, arg ConsumePasswordResetTokenParams) (int64, error)
+	ConsumeRecoveryCode(ctx context.Context, arg ConsumeRecoveryCodeParams) (int64, error)
+	CountClients(ctx context.Context) (int64, error)
 	CountEnabledSubjectsByRoleCodeExcludingSubjectID(ctx context.Context, arg CountEnabledSubjectsByRoleCodeExcludingSubjectIDParams) (int64, error)
+	CountMachineClients(ctx context.Context) (int64, error)
+	CountOtherLoginIdentifiersByNormalizedValue(ctx context.Context, arg CountOtherLoginIdentifiersByNormalizedValueParams) (int64, error)
 	CountSubjectRoleAssignments(ctx context.Context, arg CountSubjectRoleAssignmentsParams) (int64, error)
+	CountSubjectRolesBySecondFactor(ctx context.Context, arg CountSubjectRolesBySecondFactorParams) (int64, error)
 	CountSubjects(ctx context.Context) (int64, error)
 	CountSubjectsForManagement(ctx context.Context) (int64, error)
+	CountUnusedRecoveryCodesBySubjectID(ctx context.Context, subjectID string) (int64, error)
+	CreateAuthorizationCode(ctx context.Context, arg CreateAuthorizationCodeParams) error
+	CreateClient(ctx context.Context, arg CreateClientParams) error
+	CreateClientRedirectURI(ctx context.Context, arg CreateClientRedirectURIParams) error
+	CreateClientScope(ctx context.Context, arg CreateClientScopeParams) error
 	CreateIdentifier(ctx context.Context, arg CreateIdentifierParams) error
+	CreateMachineClient(ctx context.Context, arg CreateMachineClientParams) error
+	CreateMachineClientScope(ctx context.Context, arg CreateMachineClientScopeParams) error
+	CreateMachineClientSecret(ctx context.Context, arg CreateMachineClientSecretParams) error
 	CreatePasswordCredential(ctx context.Context, arg CreatePasswordCredentialParams) error
+	CreatePasswordHistoryEntry(ctx context.Context, arg CreatePasswordHistoryEntryParams) error
+	CreatePasswordResetToken(ctx context.Context, arg CreatePasswordResetTokenParams) error
 	CreateProfile(ctx context.Context, arg CreateProfileParams) error
+	CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error
+	CreateRole(ctx context.Context, arg CreateRoleParams) error
 	CreateRoleIfAbsent(ctx context.Context, arg CreateRoleIfAbsentParams) error
 	CreateSession(ctx context.Context, arg CreateSessionParams) error
 	CreateSubject(ctx context.Context, arg CreateSubjectParams) error
+	CreateSubjectErasure(ctx context.Context, arg CreateSubjectErasureParams) error
+	DeleteClientRedirectURIs(ctx context.Context, oidcClientID string) error
+	DeleteClientScopes(ctx context.Context, oidcClientID string) error
+	DeleteDeliveredAuditOutbox(ctx context.Context, updatedAt time.Time) (int64, error)
+	DeleteExpiredAuthorizationCodes(ctx context.Context, expiresAt time.Time) (int64, error)
+	DeleteExpiredIdentifierVerifications(ctx context.Context, expiresAt time.Time) (int64, error)
+	DeleteExpiredLoginThrottles(ctx context.Context, arg DeleteExpiredLoginThrottlesParams) (int64, error)
+	DeleteExpiredMachineClientSecrets(ctx context.Context, expiresAt sql.NullTime) (int64, error)
+	DeleteExpiredPasswordResetTokens(ctx context.Context, expiresAt time.Time) (int64, error)
+	DeleteExpiredSessions(ctx context.Context, arg DeleteExpiredSessionsParams) (int64, error)
+	DeleteIdentifierVerification(ctx context.Context, identifierID string) error
+	DeleteIdentifierVerificationsBySubjectID(ctx context.Context, subjectID string) (int64, error)
 	DeleteLoginThrottle(ctx context.Context, arg DeleteLoginThrottleParams) error
+	DeleteLoginThrottlesByIdentifierHash(ctx context.Context, identifierHash []byte) (int64, error)
+	DeleteMachineClientScopes(ctx context.Context, oidcMachineClientID string) error
+	DeletePasswordHistoryBySubjectID(ctx context.Context, subjectID string) (int64, error)
+	DeletePasswordResetTokensBySubjectID(ctx context.Context, subjectID string) (int64, error)
+	DeletePendingPasswordResetTokensBySubjectID(ctx context.Context, subjectID string) error
+	DeleteRecoveryCodesBySubjectID(ctx context.Context, subjectID string) (int64, error)
+	DeleteSubjectIdentifier(ctx context.Context, arg DeleteSubjectIdentifierParams) (int64, error)
+	DeleteSubjectRole(ctx context.Context, arg DeleteSubjectRoleParams) (int64, error)
+	DeleteTOTPCredentialBySubjectID(ctx context.Context, subjectID string) (int64, error)
 	DisableSubject(ctx context.Context, arg DisableSubjectParams) (int64, error)
+	EnableSubject(ctx context.Context, arg EnableSubjectParams) (int64, error)
+	EnableTOTPCredential(ctx context.Context, arg EnableTOTPCredentialParams) (int64, error)
+	EnsureAuditSinkCursor(ctx context.Context, arg EnsureAuditSinkCursorParams) error
+	ExpireMachineClientSecrets(ctx context.Context, arg ExpireMachineClientSecretsParams) (int64, error)
+	ExpirePasswordCredential(ctx context.Context, arg ExpirePasswordCredentialParams) (int64, error)
 	GetActiveSessionByTokenHash(ctx context.Context, arg GetActiveSessionByTokenHashParams) (GetActiveSessionByTokenHashRow, error)
 	GetActiveSessionSubjectByTokenHash(ctx context.Context, tokenHash []byte) (string, error)
+	GetAuditSinkCursor(ctx context.Context, sink string) (IdentityAuditSinkCursor, error)
+	GetClientByClientID(ctx context.Context, clientID string) (OidcClient, error)
 	GetEnabledSubjectSecurityVersion(ctx context.Context, arg GetEnabledSubjectSecurityVersionParams) (int64, error)
 	GetIdentifierSubjectID(ctx context.Context, arg GetIdentifierSubjectIDParams) (string, error)
+	GetIdentifierVerification(ctx context.Context, identifierID string) (IdentityIdentifierVerification, error)
 	GetLoginCredentialByNormalizedIdentifier(ctx context.Context, arg GetLoginCredentialByNormalizedIdentifierParams) (GetLoginCredentialByNormalizedIdentifierRow, error)
 	GetLoginThrottle(ctx context.Context, arg GetLoginThrottleParams) (IdentityLoginThrottle, error)
+	GetMachineClientByClientID(ctx context.Context, clientID string) (OidcMachineClient, error)
+	GetMachineClientSecretByHash(ctx context.Context, secretHash []byte) (GetMachineClientSecretByHashRow, error)
 	GetPasswordCredentialBySubjectID(ctx context.Context, subjectID string) (GetPasswordCredentialBySubjectIDRow, error)
+	GetPasswordResetTarget(ctx context.Context, arg GetPasswordResetTargetParams) (GetPasswordResetTargetRow, error)
+	GetPasswordResetTokenByHash(ctx context.Context, tokenHash []byte) (IdentityPasswordResetToken, error)
+	GetRoleByCode(ctx context.Context, roleCode string) (IdentityRole, error)
+	GetRoleByID(ctx context.Context, id string) (IdentityRole, error)
 	GetRoleIDByCode(ctx context.Context, roleCode string) (string, error)
 	GetSubjectByID(ctx context.Context, id string) (IdentitySubject, error)
 	GetSubjectForManagement(ctx context.Context, arg GetSubjectForManagementParams) (GetSubjectForManagementRow, error)
+	GetSubjectIdentifier(ctx context.Context, arg GetSubjectIdentifierParams) (IdentityIdentifier, error)
+	GetTOTPCredentialBySubjectID(ctx context.Context, subjectID string) (IdentityTotpCredential, error)
 	IncrementEnabledSubjectSecurityVersion(ctx context.Context, arg IncrementEnabledSubjectSecurityVersionParams) (int64, error)
+	IncrementIdentifierVerificationAttempts(ctx context.Context, identifierID string) (int64, error)
 	InsertAuditEvent(ctx context.Context, arg InsertAuditEventParams) error
+	ListActiveSessionsBySubjectID(ctx context.Context, arg ListActiveSessionsBySubjectIDParams) ([]ListActiveSessionsBySubjectIDRow, error)
+	ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]IdentityAuditEvent, error)
+	ListAuditOutboxEvents(ctx context.Context, arg ListAuditOutboxEventsParams) ([]ListAuditOutboxEventsRow, error)
+	ListClientRedirectURIs(ctx context.Context, oidcClientID string) ([]string, error)
+	ListClientScopes(ctx context.Context, oidcClientID string) ([]string, error)
+	ListClients(ctx context.Context, arg ListClientsParams) ([]OidcClient, error)
+	ListMachineClientScopes(ctx context.Context, oidcMachineClientID string) ([]string, error)
+	ListMachineClientSecrets(ctx context.Context, arg ListMachineClientSecretsParams) ([]ListMachineClientSecretsRow, error)
+	ListMachineClients(ctx context.Context, arg ListMachineClientsParams) ([]OidcMachineClient, error)
+	ListPasswordHistoryHashes(ctx context.Context, arg ListPasswordHistoryHashesParams) ([]string, error)
+	ListPublishedSigningKeys(ctx context.Context, retiredAt sql.NullTime) ([]ListPublishedSigningKeysRow, error)
 	ListRoleCodesBySubjectID(ctx context.Context, subjectID string) ([]string, error)
+	ListRoleMembers(ctx context.Context, roleID string) ([]ListRoleMembersRow, error)
+	ListRoles(ctx context.Context) ([]IdentityRole, error)
+	ListSubjectIDsByDisplayName(ctx context.Context, displayName string) ([]string, error)
+	ListSubjectIdentifiers(ctx context.Context, subjectID string) ([]IdentityIdentifier, error)
+	ListSubjectRoles(ctx context.Context, subjectID string) ([]ListSubjectRolesRow, error)
 	ListSubjectsForManagement(ctx context.Context, arg ListSubjectsForManagementParams) ([]ListSubjectsForManagementRow, error)
+	MarkIdentifierVerified(ctx context.Context, arg MarkIdentifierVerifiedParams) (int64, error)
+	PrunePasswordHistory(ctx context.Context, arg PrunePasswordHistoryParams) (int64, error)
+	RecordAuditSinkDelivery(ctx context.Context, arg RecordAuditSinkDeliveryParams) error
+	RecordAuditSinkFailure(ctx context.Context, arg RecordAuditSinkFailureParams) error
+	RehashPasswordCredential(ctx context.Context, arg RehashPasswordCredentialParams) (int64, error)
+	RetireSigningKeysExcept(ctx context.Context, arg RetireSigningKeysExceptParams) (int64, error)
+	RevokeActiveSessionByID(ctx context.Context, arg RevokeActiveSessionByIDParams) (int64, error)
 	RevokeActiveSessionByTokenHash(ctx context.Context, arg RevokeActiveSessionByTokenHashParams) (int64, error)
 	RevokeActiveSessionsBySubjectID(ctx context.Context, arg RevokeActiveSessionsBySubjectIDParams) (int64, error)
+	RevokePasswordCredential(ctx context.Context, arg RevokePasswordCredentialParams) (int64, error)
 	TouchActiveSession(ctx context.Context, arg TouchActiveSessionParams) (int64, error)
+	TouchSubject(ctx context.Context, arg TouchSubjectParams) (int64, error)
+	UpdateActiveSessionAccess(ctx context.Context, arg UpdateActiveSessionAccessParams) (int64, error)
+	UpdateClient(ctx context.Context, arg UpdateClientParams) (int64, error)
+	UpdateIdentifierUsageAndStatus(ctx context.Context, arg UpdateIdentifierUsageAndStatusParams) (int64, error)
+	UpdateMachineClient(ctx context.Context, arg UpdateMachineClientParams) (int64, error)
 	UpdatePasswordCredential(ctx context.Context, arg UpdatePasswordCredentialParams) (int64, error)
+	UpdateProfileDisplayName(ctx context.Context, arg UpdateProfileDisplayNameParams) (int64, error)
+	UpdateRoleSecondFactor(ctx context.Context, arg UpdateRoleSecondFactorParams) (int64, error)
+	UpsertIdentifierVerification(ctx context.Context, arg UpsertIdentifierVerificationParams) error
 	UpsertLoginThrottle(ctx context.Context, arg UpsertLoginThrottleParams) error
+	UpsertPendingTOTPCredential(ctx context.Context, arg UpsertPendingTOTPCredentialParams) (int64, error)
+	UpsertSigningKey(ctx context.Context, arg UpsertSigningKeyParams) error
 }
 
 var _ Querier = (*Queries)(nil)
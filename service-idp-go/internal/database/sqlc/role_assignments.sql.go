@@ -28,3 +28,22 @@ func (q *Queries) CountSubjectRoleAssignments(ctx context.Context, arg CountSubj
 	err := row.Scan(&count)
 	return count, err
 }
+
+const deleteSubjectRole = `-- name: DeleteSubjectRole :execrows
+DELETE FROM identity_subject_roles
+WHERE subject_id = ?
+  AND role_id = ?
+`
+
+type DeleteSubjectRoleParams struct {
+	SubjectID string `json:"subject_id"`
+	RoleID    string `json:"role_id"`
+}
+
+func (q *Queries) DeleteSubjectRole(ctx context.Context, arg DeleteSubjectRoleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteSubjectRole, arg.SubjectID, arg.RoleID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -35,6 +35,32 @@ func (q *Queries) AssignSubjectRole(ctx context.Context, arg AssignSubjectRolePa
 	return err
 }
 
+const createRole = `-- name: CreateRole :exec
+INSERT INTO identity_roles(id, role_code, display_name, description, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?)
+`
+
+type CreateRoleParams struct {
+	ID          string    `json:"id"`
+	RoleCode    string    `json:"role_code"`
+	DisplayName string    `json:"display_name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (q *Queries) CreateRole(ctx context.Context, arg CreateRoleParams) error {
+	_, err := q.db.ExecContext(ctx, createRole,
+		arg.ID,
+		arg.RoleCode,
+		arg.DisplayName,
+		arg.Description,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+	)
+	return err
+}
+
 const createRoleIfAbsent = `-- name: CreateRoleIfAbsent :exec
 INSERT INTO identity_roles(id, role_code, display_name, description, created_at, updated_at)
 VALUES (?, ?, ?, ?, ?, ?)
@@ -62,6 +88,27 @@ func (q *Queries) CreateRoleIfAbsent(ctx context.Context, arg CreateRoleIfAbsent
 	return err
 }
 
+const getRoleByCode = `-- name: GetRoleByCode :one
+SELECT id, role_code, display_name, description, created_at, updated_at, second_factor
+FROM identity_roles
+WHERE role_code = ?
+`
+
+func (q *Queries) GetRoleByCode(ctx context.Context, roleCode string) (IdentityRole, error) {
+	row := q.db.QueryRowContext(ctx, getRoleByCode, roleCode)
+	var i IdentityRole
+	err := row.Scan(
+		&i.ID,
+		&i.RoleCode,
+		&i.DisplayName,
+		&i.Description,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.SecondFactor,
+	)
+	return i, err
+}
+
 const getRoleIDByCode = `-- name: GetRoleIDByCode :one
 SELECT id
 FROM identity_roles
@@ -74,3 +121,40 @@ func (q *Queries) GetRoleIDByCode(ctx context.Context, roleCode string) (string,
 	err := row.Scan(&id)
 	return id, err
 }
+
+const listRoles = `-- name: ListRoles :many
+SELECT id, role_code, display_name, description, created_at, updated_at, second_factor
+FROM identity_roles
+ORDER BY role_code
+`
+
+func (q *Queries) ListRoles(ctx context.Context) ([]IdentityRole, error) {
+	rows, err := q.db.QueryContext(ctx, listRoles)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []IdentityRole
+	for rows.Next() {
+		var i IdentityRole
+		if err := rows.Scan(
+			&i.ID,
+			&i.RoleCode,
+			&i.DisplayName,
+			&i.Description,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.SecondFactor,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
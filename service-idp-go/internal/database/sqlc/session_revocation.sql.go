@@ -8,8 +8,53 @@ package sqlc
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
+const deleteExpiredSessions = `-- name: DeleteExpiredSessions :execrows
+DELETE FROM identity_sessions
+WHERE revoked_at IS NOT NULL OR expires_at<=? OR idle_expires_at<=?
+`
+
+type DeleteExpiredSessionsParams struct {
+	ExpiresAt     time.Time `json:"expires_at"`
+	IdleExpiresAt time.Time `json:"idle_expires_at"`
+}
+
+func (q *Queries) DeleteExpiredSessions(ctx context.Context, arg DeleteExpiredSessionsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredSessions, arg.ExpiresAt, arg.IdleExpiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeActiveSessionByID = `-- name: RevokeActiveSessionByID :execrows
+UPDATE identity_sessions
+SET revoked_at=?,revoked_reason=?
+WHERE id=? AND subject_id=? AND revoked_at IS NULL
+`
+
+type RevokeActiveSessionByIDParams struct {
+	RevokedAt     sql.NullTime   `json:"revoked_at"`
+	RevokedReason sql.NullString `json:"revoked_reason"`
+	ID            string         `json:"id"`
+	SubjectID     string         `json:"subject_id"`
+}
+
+func (q *Queries) RevokeActiveSessionByID(ctx context.Context, arg RevokeActiveSessionByIDParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeActiveSessionByID,
+		arg.RevokedAt,
+		arg.RevokedReason,
+		arg.ID,
+		arg.SubjectID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const revokeActiveSessionsBySubjectID = `-- name: RevokeActiveSessionsBySubjectID :execrows
 UPDATE identity_sessions
 SET revoked_at=?,revoked_reason=?
@@ -51,6 +51,32 @@ func (q *Queries) DisableSubject(ctx context.Context, arg DisableSubjectParams)
 	return result.RowsAffected()
 }
 
+const enableSubject = `-- name: EnableSubject :execrows
+UPDATE identity_subjects
+SET status=?,security_version=security_version+1,disabled_at=NULL,updated_at=?
+WHERE id=? AND status=?
+`
+
+type EnableSubjectParams struct {
+	Status    string    `json:"status"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ID        string    `json:"id"`
+	Status_2  string    `json:"status_2"`
+}
+
+func (q *Queries) EnableSubject(ctx context.Context, arg EnableSubjectParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enableSubject,
+		arg.Status,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.Status_2,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getIdentifierSubjectID = `-- name: GetIdentifierSubjectID :one
 SELECT subject_id
 FROM identity_identifiers
@@ -70,10 +96,11 @@ func (q *Queries) GetIdentifierSubjectID(ctx context.Context, arg GetIdentifierS
 }
 
 const getSubjectForManagement = `-- name: GetSubjectForManagement :one
-SELECT s.id,s.status,s.security_version,p.display_name,i.identifier_value,s.created_at,s.updated_at
+SELECT s.id,s.status,s.security_version,p.display_name,i.identifier_value,s.created_at,s.updated_at,e.erased_at
 FROM identity_subjects s
 JOIN identity_profiles p ON p.subject_id=s.id
 JOIN identity_identifiers i ON i.subject_id=s.id
+LEFT JOIN identity_subject_erasures e ON e.subject_id=s.id
 WHERE s.id=? AND i.identifier_usage=?
 `
 
@@ -83,13 +110,14 @@ type GetSubjectForManagementParams struct {
 }
 
 type GetSubjectForManagementRow struct {
-	ID              string    `json:"id"`
-	Status          string    `json:"status"`
-	SecurityVersion int64     `json:"security_version"`
-	DisplayName     string    `json:"display_name"`
-	IdentifierValue string    `json:"identifier_value"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID              string       `json:"id"`
+	Status          string       `json:"status"`
+	SecurityVersion int64        `json:"security_version"`
+	DisplayName     string       `json:"display_name"`
+	IdentifierValue string       `json:"identifier_value"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+	ErasedAt        sql.NullTime `json:"erased_at"`
 }
 
 func (q *Queries) GetSubjectForManagement(ctx context.Context, arg GetSubjectForManagementParams) (GetSubjectForManagementRow, error) {
@@ -103,15 +131,17 @@ func (q *Queries) GetSubjectForManagement(ctx context.Context, arg GetSubjectFor
 		&i.IdentifierValue,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.ErasedAt,
 	)
 	return i, err
 }
 
 const listSubjectsForManagement = `-- name: ListSubjectsForManagement :many
-SELECT s.id,s.status,s.security_version,p.display_name,i.identifier_value,s.created_at,s.updated_at
+SELECT s.id,s.status,s.security_version,p.display_name,i.identifier_value,s.created_at,s.updated_at,e.erased_at
 FROM identity_subjects s
 JOIN identity_profiles p ON p.subject_id=s.id
 JOIN identity_identifiers i ON i.subject_id=s.id
+LEFT JOIN identity_subject_erasures e ON e.subject_id=s.id
 WHERE i.identifier_usage=?
 ORDER BY s.created_at DESC
 LIMIT ? OFFSET ?
@@ -124,13 +154,14 @@ type ListSubjectsForManagementParams struct {
 }
 
 type ListSubjectsForManagementRow struct {
-	ID              string    `json:"id"`
-	Status          string    `json:"status"`
-	SecurityVersion int64     `json:"security_version"`
-	DisplayName     string    `json:"display_name"`
-	IdentifierValue string    `json:"identifier_value"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID              string       `json:"id"`
+	Status          string       `json:"status"`
+	SecurityVersion int64        `json:"security_version"`
+	DisplayName     string       `json:"display_name"`
+	IdentifierValue string       `json:"identifier_value"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+	ErasedAt        sql.NullTime `json:"erased_at"`
 }
 
 func (q *Queries) ListSubjectsForManagement(ctx context.Context, arg ListSubjectsForManagementParams) ([]ListSubjectsForManagementRow, error) {
@@ -150,6 +181,7 @@ func (q *Queries) ListSubjectsForManagement(ctx context.Context, arg ListSubject
 			&i.IdentifierValue,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.ErasedAt,
 		); err != nil {
 			return nil, err
 		}
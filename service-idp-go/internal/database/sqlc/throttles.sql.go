@@ -11,6 +11,24 @@ import (
 	"time"
 )
 
+const deleteExpiredLoginThrottles = `-- name: DeleteExpiredLoginThrottles :execrows
+DELETE FROM identity_login_throttles
+WHERE window_started_at<=? AND (locked_until IS NULL OR locked_until<=?)
+`
+
+type DeleteExpiredLoginThrottlesParams struct {
+	WindowStartedAt time.Time    `json:"window_started_at"`
+	LockedUntil     sql.NullTime `json:"locked_until"`
+}
+
+func (q *Queries) DeleteExpiredLoginThrottles(ctx context.Context, arg DeleteExpiredLoginThrottlesParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredLoginThrottles, arg.WindowStartedAt, arg.LockedUntil)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deleteLoginThrottle = `-- name: DeleteLoginThrottle :exec
 DELETE FROM identity_login_throttles
 WHERE identifier_hash=? AND source_hash=?
@@ -26,6 +44,19 @@ func (q *Queries) DeleteLoginThrottle(ctx context.Context, arg DeleteLoginThrott
 	return err
 }
 
+const deleteLoginThrottlesByIdentifierHash = `-- name: DeleteLoginThrottlesByIdentifierHash :execrows
+DELETE FROM identity_login_throttles
+WHERE identifier_hash=?
+`
+
+func (q *Queries) DeleteLoginThrottlesByIdentifierHash(ctx context.Context, identifierHash []byte) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteLoginThrottlesByIdentifierHash, identifierHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getLoginThrottle = `-- name: GetLoginThrottle :one
 SELECT id,identifier_hash,source_hash,failed_count,window_started_at,locked_until,updated_at
 FROM identity_login_throttles
@@ -0,0 +1,251 @@
+package httpapi
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+const (
+	auditReaderRole             = "identity.audit.read"
+	defaultAuditEventListLimit  = 50
+	auditEventExportPageSize    = 500
+	auditEventCSVContentType    = "text/csv; charset=utf-8"
+	auditEventNDJSONContentType = "application/x-ndjson"
+)
+
+var auditEventCSVHeader = []string{
+	"id", "event_action", "outcome", "actor_subject_id", "target_subject_id",
+	"request_id", "source_hash", "metadata", "created_at",
+}
+
+// listAuditEvents serves one keyset page as JSON, the audit viewer for
+// browsers and its HTMX row fragments, or, with format=csv or format=ndjson,
+// every matching event as a download. All representations require the
+// identity.audit.read role; identity.admin alone does not grant it.
+func (handler Handler) listAuditEvents(responseWriter http.ResponseWriter, request *http.Request) {
+	setSubjectRepresentationVary(responseWriter)
+	format := request.URL.Query().Get("format")
+	if format == "" && wantsHTML(request) {
+		if _, ok := handler.requireRolePage(responseWriter, request, auditReaderRole); !ok {
+			return
+		}
+		handler.renderAuditEventsPage(responseWriter, request)
+		return
+	}
+	if _, ok := handler.requireRole(responseWriter, request, auditReaderRole); !ok {
+		return
+	}
+	input, err := auditEventFilters(request)
+	if err != nil {
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", err.Error())
+		return
+	}
+	switch format {
+	case "", "json":
+	case "csv", "ndjson":
+		handler.exportAuditEvents(responseWriter, request, input, format)
+		return
+	default:
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "format must be json, csv or ndjson")
+		return
+	}
+
+	result, err := identity.ListAuditEvents(request.Context(), handler.database, input)
+	if err != nil {
+		handler.writeAuditQueryError(responseWriter, request, err)
+		return
+	}
+	meta := map[string]any{"next_cursor": nil}
+	if result.NextCursor != "" {
+		meta["next_cursor"] = result.NextCursor
+	}
+	writeJSON(responseWriter, http.StatusOK, map[string]any{
+		"records": result.Events,
+		"meta":    meta,
+	})
+}
+
+// exportAuditEvents streams every event matching the filters, newest first,
+// by following the keyset cursor. A failure after the first page can only
+// truncate the download, because the status line has already been sent.
+func (handler Handler) exportAuditEvents(responseWriter http.ResponseWriter, request *http.Request, input identity.ListAuditEventsInput, format string) {
+	input.Limit = auditEventExportPageSize
+	result, err := identity.ListAuditEvents(request.Context(), handler.database, input)
+	if err != nil {
+		handler.writeAuditQueryError(responseWriter, request, err)
+		return
+	}
+
+	contentType := auditEventNDJSONContentType
+	if format == "csv" {
+		contentType = auditEventCSVContentType
+	}
+	responseWriter.Header().Set("Content-Type", contentType)
+	responseWriter.Header().Set("Content-Disposition", `attachment; filename="identity-audit-events.`+format+`"`)
+	responseWriter.Header().Set("Cache-Control", "no-store")
+	responseWriter.WriteHeader(http.StatusOK)
+
+	csvWriter := csv.NewWriter(responseWriter)
+	encoder := json.NewEncoder(responseWriter)
+	if format == "csv" {
+		_ = csvWriter.Write(auditEventCSVHeader)
+	}
+	for {
+		for _, event := range result.Events {
+			if format == "csv" {
+				err = csvWriter.Write(auditEventCSVRecord(event))
+			} else {
+				err = encoder.Encode(event)
+			}
+			if err != nil {
+				return
+			}
+		}
+		csvWriter.Flush()
+		if result.NextCursor == "" || csvWriter.Error() != nil {
+			return
+		}
+		input.Before = result.NextCursor
+		result, err = identity.ListAuditEvents(request.Context(), handler.database, input)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (handler Handler) renderAuditEventsPage(responseWriter http.ResponseWriter, request *http.Request) {
+	filters := auditEventFilterValues(request)
+	input, err := auditEventFilters(request)
+	var result identity.ListAuditEventsResult
+	if err == nil {
+		result, err = identity.ListAuditEvents(request.Context(), handler.database, input)
+		if err != nil && !errors.Is(err, identity.ErrInvalidAuditQuery) {
+			writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not list audit events")
+			return
+		}
+	}
+	rows := auditEventRowsData{Events: result.Events}
+	if result.NextCursor != "" {
+		nextQuery := filters.query()
+		nextQuery.Set("cursor", result.NextCursor)
+		rows.NextURL = identityPrefix + "/audit-events?" + nextQuery.Encode()
+	}
+
+	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if isHTMXRequest(request) {
+		if err != nil {
+			responseWriter.WriteHeader(http.StatusUnprocessableEntity)
+			auditEventsTemplate.ExecuteTemplate(responseWriter, "audit-event-error-row", nil)
+			return
+		}
+		auditEventsTemplate.ExecuteTemplate(responseWriter, "audit-event-rows", rows)
+		return
+	}
+	exportQuery := filters.query()
+	exportQuery.Set("format", "csv")
+	csvURL := identityPrefix + "/audit-events?" + exportQuery.Encode()
+	exportQuery.Set("format", "ndjson")
+	auditEventsTemplate.Execute(responseWriter, auditEventsPageData{
+		Filters:   filters,
+		Actions:   identity.AuditEventActions,
+		Rows:      rows,
+		CSVURL:    csvURL,
+		NDJSONURL: identityPrefix + "/audit-events?" + exportQuery.Encode(),
+		HasError:  err != nil,
+	})
+}
+
+func (handler Handler) writeAuditQueryError(responseWriter http.ResponseWriter, request *http.Request, err error) {
+	if errors.Is(err, identity.ErrInvalidAuditQuery) {
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", err.Error())
+		return
+	}
+	writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not list audit events")
+}
+
+// auditEventFilters parses the shared query parameters: event_action,
+// outcome, actor_subject_id, target_subject_id, from and until (RFC 3339),
+// cursor, and limit.
+func auditEventFilters(request *http.Request) (identity.ListAuditEventsInput, error) {
+	query := request.URL.Query()
+	limit, err := parseNonnegativeQueryInteger(request, "limit", defaultAuditEventListLimit)
+	if err != nil {
+		return identity.ListAuditEventsInput{}, errors.New("invalid limit")
+	}
+	input := identity.ListAuditEventsInput{
+		EventAction:     query.Get("event_action"),
+		Outcome:         query.Get("outcome"),
+		ActorSubjectID:  query.Get("actor_subject_id"),
+		TargetSubjectID: query.Get("target_subject_id"),
+		Before:          query.Get("cursor"),
+		Limit:           limit,
+	}
+	for key, destination := range map[string]*time.Time{"from": &input.CreatedFrom, "until": &input.CreatedUntil} {
+		value := query.Get(key)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return identity.ListAuditEventsInput{}, errors.New(key + " must be an RFC 3339 timestamp")
+		}
+		*destination = parsed
+	}
+	return input, nil
+}
+
+func auditEventFilterValues(request *http.Request) auditEventFilterData {
+	query := request.URL.Query()
+	return auditEventFilterData{
+		EventAction:     query.Get("event_action"),
+		Outcome:         query.Get("outcome"),
+		ActorSubjectID:  query.Get("actor_subject_id"),
+		TargetSubjectID: query.Get("target_subject_id"),
+		From:            query.Get("from"),
+		Until:           query.Get("until"),
+	}
+}
+
+func (filters auditEventFilterData) query() url.Values {
+	query := url.Values{}
+	for key, value := range map[string]string{
+		"event_action":      filters.EventAction,
+		"outcome":           filters.Outcome,
+		"actor_subject_id":  filters.ActorSubjectID,
+		"target_subject_id": filters.TargetSubjectID,
+		"from":              filters.From,
+		"until":             filters.Until,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	return query
+}
+
+func auditEventCSVRecord(event identity.AuditEvent) []string {
+	return []string{
+		event.ID,
+		event.EventAction,
+		event.Outcome,
+		stringOrEmpty(event.ActorSubjectID),
+		stringOrEmpty(event.TargetSubjectID),
+		stringOrEmpty(event.RequestID),
+		stringOrEmpty(event.SourceHash),
+		string(event.Metadata),
+		event.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+func stringOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,135 @@
+package httpapi_test
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/db/migrations"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/httpapi"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+func TestAuditEventsRequireAuditReaderRole(t *testing.T) {
+	databaseConnection, err := database.OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "identityd.sqlite"))
+	if err != nil {
+		t.Fatalf("open SQLite database: %v", err)
+	}
+	t.Cleanup(func() {
+		databaseConnection.Close()
+	})
+	if _, err := database.Migrate(context.Background(), databaseConnection, migrations.Files); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	if _, err := identity.EnsureBootstrap(context.Background(), databaseConnection, identity.BootstrapInput{
+		Identifier: "admin",
+		Password:   "correct horse battery staple",
+	}); err != nil {
+		t.Fatalf("ensure bootstrap: %v", err)
+	}
+	var administratorID string
+	if err := databaseConnection.QueryRow(`
+		SELECT subject_id
+		FROM identity_identifiers
+		WHERE identifier_type = '账号' AND normalized_value = 'admin'
+	`).Scan(&administratorID); err != nil {
+		t.Fatalf("read administrator ID: %v", err)
+	}
+	auditor, err := identity.CreateSubject(context.Background(), databaseConnection, administratorID, identity.CreateSubjectInput{
+		DisplayName: "审计员",
+		Identifier:  "auditor",
+		Password:    "a sufficiently long password",
+	}, identity.PasswordPolicy{})
+	if err != nil {
+		t.Fatalf("create auditor: %v", err)
+	}
+	if _, err := identity.GrantSubjectRole(context.Background(), databaseConnection, administratorID, auditor.ID, "identity.audit.read"); err != nil {
+		t.Fatalf("grant audit reader role: %v", err)
+	}
+
+	mux := httpapi.NewMux(databaseConnection, httpapi.Options{
+		SessionSettings: identity.SessionSettings{TTL: time.Hour, IdleTTL: 30 * time.Minute},
+		LoginThrottle:   testLoginThrottle,
+	})
+	adminSession, _ := loginCookies(t, mux, "admin", "correct horse battery staple")
+	auditorSession, _ := loginCookies(t, mux, "auditor", "a sufficiently long password")
+	get := func(session *http.Cookie, path string, accept string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			request.Header.Set("Accept", accept)
+		}
+		request.AddCookie(session)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		return response
+	}
+
+	// 管理员角色本身不包含审计查看权限。
+	assertProblemDetails(t, get(adminSession, "/crate-api/identity/v1/audit-events", ""), http.StatusForbidden, "not-authorized", "/crate-api/identity/v1/audit-events")
+
+	firstPage := get(auditorSession, "/crate-api/identity/v1/audit-events?limit=2", "")
+	var listed struct {
+		Records []identity.AuditEvent `json:"records"`
+		Meta    struct {
+			NextCursor *string `json:"next_cursor"`
+		} `json:"meta"`
+	}
+	if err := json.Unmarshal(firstPage.Body.Bytes(), &listed); err != nil || firstPage.Code != http.StatusOK || len(listed.Records) != 2 || listed.Meta.NextCursor == nil {
+		t.Fatalf("first audit page = %d %s", firstPage.Code, firstPage.Body.String())
+	}
+	secondPage := get(auditorSession, "/crate-api/identity/v1/audit-events?limit=2&cursor="+*listed.Meta.NextCursor, "")
+	if secondPage.Code != http.StatusOK || strings.Contains(secondPage.Body.String(), listed.Records[0].ID) {
+		t.Fatalf("second audit page = %d %s", secondPage.Code, secondPage.Body.String())
+	}
+
+	filtered := get(auditorSession, "/crate-api/identity/v1/audit-events?event_action=%E8%A7%92%E8%89%B2%E6%8E%88%E4%BA%88&target_subject_id="+auditor.ID, "")
+	if err := json.Unmarshal(filtered.Body.Bytes(), &listed); err != nil || len(listed.Records) != 1 || listed.Meta.NextCursor != nil {
+		t.Fatalf("filtered audit events = %d %s", filtered.Code, filtered.Body.String())
+	}
+	assertProblemDetails(t, get(auditorSession, "/crate-api/identity/v1/audit-events?from=yesterday", ""), http.StatusBadRequest, "invalid-request", "/crate-api/identity/v1/audit-events?from=yesterday")
+	assertProblemDetails(t, get(auditorSession, "/crate-api/identity/v1/audit-events?format=xml", ""), http.StatusBadRequest, "invalid-request", "/crate-api/identity/v1/audit-events?format=xml")
+
+	csvResponse := get(auditorSession, "/crate-api/identity/v1/audit-events?format=csv", "text/html")
+	if csvResponse.Code != http.StatusOK || !strings.HasPrefix(csvResponse.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("CSV export = %d %q", csvResponse.Code, csvResponse.Header().Get("Content-Type"))
+	}
+	records, err := csv.NewReader(csvResponse.Body).ReadAll()
+	if err != nil || len(records) < 4 || records[0][0] != "id" || len(records[1]) != 9 {
+		t.Fatalf("CSV export records = %q, err = %v", records, err)
+	}
+
+	ndjsonResponse := get(auditorSession, "/crate-api/identity/v1/audit-events?format=ndjson&outcome=%E6%88%90%E5%8A%9F", "")
+	lines := strings.Split(strings.TrimSpace(ndjsonResponse.Body.String()), "\n")
+	if ndjsonResponse.Code != http.StatusOK || len(lines) != len(records)-1 {
+		t.Fatalf("NDJSON export = %d, %d lines, want %d", ndjsonResponse.Code, len(lines), len(records)-1)
+	}
+	var exported identity.AuditEvent
+	if err := json.Unmarshal([]byte(lines[0]), &exported); err != nil || exported.ID == "" || exported.Outcome != "成功" {
+		t.Fatalf("NDJSON line = %s, err = %v", lines[0], err)
+	}
+
+	page := get(auditorSession, "/crate-api/identity/v1/audit-events?limit=2", "text/html")
+	if page.Code != http.StatusOK || !strings.Contains(page.Body.String(), "审计事件") || !strings.Contains(page.Body.String(), `hx-get="/crate-api/identity/v1/audit-events?cursor=`) {
+		t.Fatalf("audit page = %d %s", page.Code, page.Body.String())
+	}
+	fragmentRequest := httptest.NewRequest(http.MethodGet, "/crate-api/identity/v1/audit-events?limit=2&cursor="+listed.Records[0].ID, nil)
+	fragmentRequest.Header.Set("HX-Request", "true")
+	fragmentRequest.AddCookie(auditorSession)
+	fragment := httptest.NewRecorder()
+	mux.ServeHTTP(fragment, fragmentRequest)
+	if fragment.Code != http.StatusOK || strings.Contains(fragment.Body.String(), "<html") || !strings.Contains(fragment.Body.String(), "<tr id=\"audit-event-") {
+		t.Fatalf("audit fragment = %d %s", fragment.Code, fragment.Body.String())
+	}
+
+	adminPage := get(adminSession, "/crate-api/identity/v1/audit-events", "text/html")
+	if adminPage.Code != http.StatusForbidden {
+		t.Fatalf("administrator audit page status = %d", adminPage.Code)
+	}
+}
@@ -0,0 +1,121 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+type createClientRequest struct {
+	ClientID     string   `json:"client_id"`
+	DisplayName  string   `json:"display_name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+}
+
+type updateClientRequest struct {
+	DisplayName  *string  `json:"display_name"`
+	Status       *string  `json:"status"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+}
+
+func (handler Handler) listClients(responseWriter http.ResponseWriter, request *http.Request) {
+	if _, ok := handler.requireAdministrator(responseWriter, request); !ok {
+		return
+	}
+	limit, offset, err := subjectListPagination(request)
+	if err != nil {
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "invalid pagination")
+		return
+	}
+	result, err := identity.ListClients(request.Context(), handler.database, identity.ListClientsInput{Limit: limit, Offset: offset})
+	if err != nil {
+		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not list clients")
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, map[string]any{
+		"records": result.Clients,
+		"meta":    map[string]int64{"total": result.Total},
+	})
+}
+
+func (handler Handler) getClient(responseWriter http.ResponseWriter, request *http.Request) {
+	if _, ok := handler.requireAdministrator(responseWriter, request); !ok {
+		return
+	}
+	client, err := identity.GetClient(request.Context(), handler.database, request.PathValue("clientID"))
+	if err != nil {
+		handler.writeClientManagementError(responseWriter, request, err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, client)
+}
+
+func (handler Handler) createClient(responseWriter http.ResponseWriter, request *http.Request) {
+	session, ok := handler.requireAdministrator(responseWriter, request)
+	if !ok {
+		return
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	var input createClientRequest
+	if err := decodeJSON(request, responseWriter, &input); err != nil {
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "invalid JSON request")
+		return
+	}
+	client, err := identity.CreateClient(request.Context(), handler.database, session.SubjectID, identity.CreateClientInput{
+		ClientID:     input.ClientID,
+		DisplayName:  input.DisplayName,
+		RedirectURIs: input.RedirectURIs,
+		Scopes:       input.Scopes,
+	})
+	if err != nil {
+		handler.writeClientManagementError(responseWriter, request, err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusCreated, client)
+}
+
+func (handler Handler) updateClient(responseWriter http.ResponseWriter, request *http.Request) {
+	session, ok := handler.requireAdministrator(responseWriter, request)
+	if !ok {
+		return
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	var input updateClientRequest
+	if err := decodeJSON(request, responseWriter, &input); err != nil {
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "invalid JSON request")
+		return
+	}
+	client, err := identity.UpdateClient(request.Context(), handler.database, session.SubjectID, request.PathValue("clientID"), identity.UpdateClientInput{
+		DisplayName:  input.DisplayName,
+		Status:       input.Status,
+		RedirectURIs: input.RedirectURIs,
+		Scopes:       input.Scopes,
+	})
+	if err != nil {
+		handler.writeClientManagementError(responseWriter, request, err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, client)
+}
+
+func (handler Handler) writeClientManagementError(responseWriter http.ResponseWriter, request *http.Request, err error) {
+	switch {
+	case errors.Is(err, identity.ErrClientNotFound):
+		writeProblem(responseWriter, request, http.StatusNotFound, "client-not-found", "client not found")
+	case errors.Is(err, identity.ErrClientAlreadyExists):
+		writeProblem(responseWriter, request, http.StatusConflict, "client-already-exists", "client_id is already registered")
+	case errors.Is(err, identity.ErrInvalidClientInput):
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", err.Error())
+	default:
+		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not manage client")
+	}
+}
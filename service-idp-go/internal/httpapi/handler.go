@@ -8,11 +8,13 @@ import (
 	"io/fs"
 	"net/http"
 	"net/netip"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/oidc"
 	"github.com/ovaphlow/pitchfork/service-idp-go/web"
 )
 
@@ -40,26 +42,50 @@ const (
 type Options struct {
 	SessionSettings      identity.SessionSettings
 	LoginThrottle        identity.LoginThrottleSettings
+	PasswordPolicy       identity.PasswordPolicy
 	SecureSessionCookie  bool
 	TrustedProxyPrefixes []netip.Prefix
 	CorsOrigins          []string
+	// OIDC enables the discovery, JWKS, authorization, token and userinfo
+	// endpoints. Nil leaves them unregistered; client registration is
+	// available either way.
+	OIDC *oidc.Provider
+	// VerificationSender delivers identifier verification codes. Nil makes
+	// the verification endpoints answer 503.
+	VerificationSender identity.VerificationCodeSender
+	// PasswordResetNotifier delivers password reset tokens. Nil makes the
+	// password reset request endpoint answer 503.
+	PasswordResetNotifier identity.PasswordResetNotifier
+	// Directories take over password checks for login identifiers in their
+	// domains.
+	Directories []identity.Directory
 }
 
 type Handler struct {
-	database             *sql.DB
-	sessionSettings      identity.SessionSettings
-	loginThrottle        identity.LoginThrottleSettings
-	secureSessionCookie  bool
-	trustedProxyPrefixes []netip.Prefix
+	database              *sql.DB
+	sessionSettings       identity.SessionSettings
+	loginThrottle         identity.LoginThrottleSettings
+	passwordPolicy        identity.PasswordPolicy
+	secureSessionCookie   bool
+	trustedProxyPrefixes  []netip.Prefix
+	oidcProvider          *oidc.Provider
+	verificationSender    identity.VerificationCodeSender
+	passwordResetNotifier identity.PasswordResetNotifier
+	directories           []identity.Directory
 }
 
 func NewMux(database *sql.DB, options Options) http.Handler {
 	handler := Handler{
-		database:             database,
-		sessionSettings:      options.SessionSettings,
-		loginThrottle:        options.LoginThrottle,
-		secureSessionCookie:  options.SecureSessionCookie,
-		trustedProxyPrefixes: append([]netip.Prefix(nil), options.TrustedProxyPrefixes...),
+		database:              database,
+		sessionSettings:       options.SessionSettings,
+		loginThrottle:         options.LoginThrottle,
+		passwordPolicy:        options.PasswordPolicy,
+		secureSessionCookie:   options.SecureSessionCookie,
+		trustedProxyPrefixes:  append([]netip.Prefix(nil), options.TrustedProxyPrefixes...),
+		oidcProvider:          options.OIDC,
+		verificationSender:    options.VerificationSender,
+		passwordResetNotifier: options.PasswordResetNotifier,
+		directories:           append([]identity.Directory(nil), options.Directories...),
 	}
 	staticFiles, err := fs.Sub(web.StaticFiles, "static")
 	if err != nil {
@@ -71,15 +97,77 @@ func NewMux(database *sql.DB, options Options) http.Handler {
 	mux.HandleFunc("GET "+identityPrefix+"/session", handler.getCurrentSession)
 	mux.HandleFunc("GET "+identityPrefix+"/login", handler.loginPage)
 	mux.HandleFunc("POST "+identityPrefix+"/sessions", handler.createSession)
+	mux.HandleFunc("GET "+identityPrefix+"/sessions", handler.listOwnSessions)
 	mux.HandleFunc("DELETE "+identityPrefix+"/sessions/current", handler.deleteCurrentSession)
+	mux.HandleFunc("DELETE "+identityPrefix+"/sessions/{sessionID}", handler.revokeOwnSession)
 	mux.HandleFunc("GET "+identityPrefix+"/password", handler.passwordPage)
 	mux.HandleFunc("PATCH "+identityPrefix+"/password", handler.changePassword)
 	mux.HandleFunc("POST "+identityPrefix+"/password", handler.changePassword)
+	mux.HandleFunc("GET "+identityPrefix+"/password-reset", handler.passwordResetPage)
+	mux.HandleFunc("POST "+identityPrefix+"/password-resets", handler.requestPasswordReset)
+	mux.HandleFunc("POST "+identityPrefix+"/password-resets/confirm", handler.confirmPasswordReset)
+	mux.HandleFunc("GET "+identityPrefix+"/second-factor", handler.secondFactorPage)
+	mux.HandleFunc("POST "+identityPrefix+"/second-factor/totp", handler.startTOTPEnrollment)
+	mux.HandleFunc("POST "+identityPrefix+"/second-factor/totp/confirm", handler.confirmTOTPEnrollment)
+	mux.HandleFunc("POST "+identityPrefix+"/second-factor/verification", handler.verifySecondFactor)
 	mux.HandleFunc("GET "+identityPrefix+"/dashboard", handler.dashboard)
 	mux.HandleFunc("GET "+identityPrefix+"/subjects", handler.listSubjects)
 	mux.HandleFunc("POST "+identityPrefix+"/subjects", handler.createSubject)
+	mux.HandleFunc("POST "+identityPrefix+"/subjects/import", handler.importSubjects)
+	mux.HandleFunc("GET "+identityPrefix+"/subjects/export", handler.exportSubjects)
 	mux.HandleFunc("GET "+identityPrefix+"/subjects/{subjectID}", handler.getSubject)
 	mux.HandleFunc("PATCH "+identityPrefix+"/subjects/{subjectID}", handler.updateSubject)
+	mux.HandleFunc("DELETE "+identityPrefix+"/subjects/{subjectID}", handler.eraseSubject)
+	mux.HandleFunc("GET "+identityPrefix+"/subjects/{subjectID}/sessions", handler.listSubjectSessions)
+	mux.HandleFunc("DELETE "+identityPrefix+"/subjects/{subjectID}/sessions", handler.revokeSubjectSessions)
+	mux.HandleFunc("DELETE "+identityPrefix+"/subjects/{subjectID}/sessions/{sessionID}", handler.revokeSubjectSession)
+	mux.HandleFunc("GET "+identityPrefix+"/subjects/{subjectID}/identifiers", handler.listSubjectIdentifiers)
+	mux.HandleFunc("POST "+identityPrefix+"/subjects/{subjectID}/identifiers", handler.createSubjectIdentifier)
+	mux.HandleFunc("PATCH "+identityPrefix+"/subjects/{subjectID}/identifiers/{identifierID}", handler.updateSubjectIdentifier)
+	mux.HandleFunc("DELETE "+identityPrefix+"/subjects/{subjectID}/identifiers/{identifierID}", handler.deleteSubjectIdentifier)
+	mux.HandleFunc("POST "+identityPrefix+"/subjects/{subjectID}/identifiers/{identifierID}/verification", handler.startIdentifierVerification)
+	mux.HandleFunc("POST "+identityPrefix+"/subjects/{subjectID}/identifiers/{identifierID}/verification/confirm", handler.confirmIdentifierVerification)
+	mux.HandleFunc("GET "+identityPrefix+"/subjects/{subjectID}/second-factor", handler.getSubjectSecondFactor)
+	mux.HandleFunc("DELETE "+identityPrefix+"/subjects/{subjectID}/second-factor", handler.resetSubjectSecondFactor)
+	mux.HandleFunc("GET "+identityPrefix+"/subjects/{subjectID}/roles", handler.listSubjectRoles)
+	mux.HandleFunc("POST "+identityPrefix+"/subjects/{subjectID}/roles", handler.grantSubjectRole)
+	mux.HandleFunc("DELETE "+identityPrefix+"/subjects/{subjectID}/roles/{roleCode}", handler.revokeSubjectRole)
+	mux.HandleFunc("GET "+identityPrefix+"/roles", handler.listRoles)
+	mux.HandleFunc("POST "+identityPrefix+"/roles", handler.createRole)
+	mux.HandleFunc("PATCH "+identityPrefix+"/roles/{roleCode}", handler.updateRole)
+	mux.HandleFunc("GET "+identityPrefix+"/audit-events", handler.listAuditEvents)
+	mux.HandleFunc("GET "+identityPrefix+"/clients", handler.listClients)
+	mux.HandleFunc("POST "+identityPrefix+"/clients", handler.createClient)
+	mux.HandleFunc("GET "+identityPrefix+"/clients/{clientID}", handler.getClient)
+	mux.HandleFunc("PATCH "+identityPrefix+"/clients/{clientID}", handler.updateClient)
+	mux.HandleFunc("GET "+identityPrefix+"/machine-clients", handler.listMachineClients)
+	mux.HandleFunc("POST "+identityPrefix+"/machine-clients", handler.createMachineClient)
+	mux.HandleFunc("GET "+identityPrefix+"/machine-clients/{clientID}", handler.getMachineClient)
+	mux.HandleFunc("PATCH "+identityPrefix+"/machine-clients/{clientID}", handler.updateMachineClient)
+	mux.HandleFunc("POST "+identityPrefix+"/machine-clients/{clientID}/secrets", handler.rotateMachineClientSecret)
+	if handler.oidcProvider != nil {
+		mux.HandleFunc("GET "+identityPrefix+"/.well-known/openid-configuration", handler.openIDConfiguration)
+		mux.HandleFunc("GET "+identityPrefix+"/jwks", handler.jwks)
+		mux.HandleFunc("GET "+identityPrefix+"/authorize", handler.authorize)
+		mux.HandleFunc("POST "+identityPrefix+"/token", handler.token)
+		mux.HandleFunc("GET "+identityPrefix+"/userinfo", handler.userinfo)
+		mux.HandleFunc("POST "+identityPrefix+"/userinfo", handler.userinfo)
+	}
+	// The SCIM routes are registered without OIDC as well, so that a
+	// provisioning client gets a SCIM error telling it why instead of a
+	// bare 404; they need OIDC for the client's bearer token.
+	mux.HandleFunc("GET "+scimPrefix+"/ServiceProviderConfig", handler.scimEndpoint(handler.scimServiceProviderConfig))
+	mux.HandleFunc("GET "+scimPrefix+"/ResourceTypes", handler.scimEndpoint(handler.scimResourceTypes))
+	mux.HandleFunc("GET "+scimPrefix+"/Users", handler.scimEndpoint(handler.listSCIMUsers))
+	mux.HandleFunc("POST "+scimPrefix+"/Users", handler.scimEndpoint(handler.createSCIMUser))
+	mux.HandleFunc("GET "+scimPrefix+"/Users/{subjectID}", handler.scimEndpoint(handler.getSCIMUser))
+	mux.HandleFunc("PUT "+scimPrefix+"/Users/{subjectID}", handler.scimEndpoint(handler.replaceSCIMUser))
+	mux.HandleFunc("PATCH "+scimPrefix+"/Users/{subjectID}", handler.scimEndpoint(handler.patchSCIMUser))
+	mux.HandleFunc("GET "+scimPrefix+"/Groups", handler.scimEndpoint(handler.listSCIMGroups))
+	mux.HandleFunc("POST "+scimPrefix+"/Groups", handler.scimEndpoint(handler.createSCIMGroup))
+	mux.HandleFunc("GET "+scimPrefix+"/Groups/{roleID}", handler.scimEndpoint(handler.getSCIMGroup))
+	mux.HandleFunc("PUT "+scimPrefix+"/Groups/{roleID}", handler.scimEndpoint(handler.replaceSCIMGroup))
+	mux.HandleFunc("PATCH "+scimPrefix+"/Groups/{roleID}", handler.scimEndpoint(handler.patchSCIMGroup))
 	return corsHandler{next: problemDetailsHandler{next: mux}, allowedOrigins: options.CorsOrigins}
 }
 
@@ -93,30 +181,75 @@ func (handler Handler) health(responseWriter http.ResponseWriter, request *http.
 
 // getCurrentSession is the service-to-service identity boundary. Downstream
 // services forward the browser Cookie header here instead of interpreting the
-// opaque session token themselves.
+// opaque session token themselves. The role codes of the subject ride
+// along so a downstream service can authorize without a second call. A
+// downstream service guarding a write also forwards the X-CSRF-Token
+// header, which is checked against the session exactly like identityd's
+// own writes and confirmed with csrf_verified.
 func (handler Handler) getCurrentSession(responseWriter http.ResponseWriter, request *http.Request) {
 	session, err := handler.currentSession(request)
 	if err != nil || session.Access != "完整" {
 		writeProblem(responseWriter, request, http.StatusUnauthorized, "not-authenticated", "not authenticated")
 		return
 	}
-	writeJSON(responseWriter, http.StatusOK, map[string]string{
+	csrfForwarded := request.Header.Get("X-CSRF-Token") != ""
+	if csrfForwarded && !requestHasValidCSRFToken(request, session) {
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	subject, err := identity.GetSubject(request.Context(), handler.database, session.SubjectID)
+	if err != nil {
+		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not load session subject")
+		return
+	}
+	body := map[string]any{
 		"subject_id": session.SubjectID,
 		"access":     session.Access,
-	})
+		"roles":      subject.Roles,
+	}
+	if csrfForwarded {
+		body["csrf_verified"] = true
+	}
+	writeJSON(responseWriter, http.StatusOK, body)
 }
 
 func (handler Handler) loginPage(responseWriter http.ResponseWriter, request *http.Request) {
+	returnTo := loginReturnTo(request.URL.Query().Get("return_to"))
 	if session, err := handler.currentSession(request); err == nil {
-		destination := identityPrefix + "/dashboard"
-		if session.Access == "仅改密" {
-			destination = identityPrefix + "/password"
-		}
-		http.Redirect(responseWriter, request, destination, http.StatusSeeOther)
+		http.Redirect(responseWriter, request, loginDestination(session.Access, returnTo), http.StatusSeeOther)
 		return
 	}
 	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
-	loginTemplate.Execute(responseWriter, loginPageData{HasError: request.URL.Query().Get("error") == "1"})
+	loginTemplate.Execute(responseWriter, loginPageData{HasError: request.URL.Query().Get("error") == "1", ReturnTo: returnTo})
+}
+
+// loginDestination is where a browser goes after signing in: the second
+// factor page for a 待二次验证 session, which keeps returnTo, the password
+// page for a 仅改密 session, otherwise the pending authorization request or
+// the dashboard.
+func loginDestination(access string, returnTo string) string {
+	switch {
+	case access == "待二次验证":
+		location := identityPrefix + "/second-factor"
+		if returnTo != "" {
+			location += "?return_to=" + url.QueryEscape(returnTo)
+		}
+		return location
+	case access == "仅改密":
+		return identityPrefix + "/password"
+	case returnTo != "":
+		return returnTo
+	default:
+		return identityPrefix + "/dashboard"
+	}
+}
+
+func loginErrorLocation(returnTo string) string {
+	location := identityPrefix + "/login?error=1"
+	if returnTo != "" {
+		location += "&return_to=" + url.QueryEscape(returnTo)
+	}
+	return location
 }
 
 func (handler Handler) createSession(responseWriter http.ResponseWriter, request *http.Request) {
@@ -129,17 +262,30 @@ func (handler Handler) createSession(responseWriter http.ResponseWriter, request
 		http.Redirect(responseWriter, request, identityPrefix+"/login?error=1", http.StatusSeeOther)
 		return
 	}
-	login, err := identity.Login(request.Context(), handler.database, identity.LoginInput{
+	returnTo := loginReturnTo(request.Form.Get("return_to"))
+	input := identity.LoginInput{
 		Identifier:    request.Form.Get("identifier"),
 		Password:      request.Form.Get("password"),
 		SourceAddress: clientSourceAddress(request, handler.trustedProxyPrefixes),
-	}, handler.sessionSettings, handler.loginThrottle)
+		UserAgent:     request.UserAgent(),
+	}
+	var login identity.LoginResult
+	var err error
+	if directory, found := identity.MatchDirectory(handler.directories, input.Identifier); found {
+		login, err = identity.DirectoryLogin(request.Context(), handler.database, directory, input, handler.sessionSettings, handler.loginThrottle)
+	} else {
+		login, err = identity.Login(request.Context(), handler.database, input, handler.sessionSettings, handler.loginThrottle, handler.passwordPolicy)
+	}
+	if errors.Is(err, identity.ErrDirectoryUnavailable) {
+		writeProblem(responseWriter, request, http.StatusServiceUnavailable, "service-unavailable", "the directory cannot be reached")
+		return
+	}
 	if err != nil {
 		if jsonRequest {
 			writeProblem(responseWriter, request, http.StatusUnauthorized, "invalid-credentials", "账号或密码不正确")
 			return
 		}
-		http.Redirect(responseWriter, request, identityPrefix+"/login?error=1", http.StatusSeeOther)
+		http.Redirect(responseWriter, request, loginErrorLocation(returnTo), http.StatusSeeOther)
 		return
 	}
 	handler.setSessionCookies(responseWriter, login)
@@ -147,11 +293,7 @@ func (handler Handler) createSession(responseWriter http.ResponseWriter, request
 		writeJSON(responseWriter, http.StatusOK, map[string]string{"access": login.Access})
 		return
 	}
-	destination := identityPrefix + "/dashboard"
-	if login.Access == "仅改密" {
-		destination = identityPrefix + "/password"
-	}
-	http.Redirect(responseWriter, request, destination, http.StatusSeeOther)
+	http.Redirect(responseWriter, request, loginDestination(login.Access, returnTo), http.StatusSeeOther)
 }
 
 func (handler Handler) passwordPage(responseWriter http.ResponseWriter, request *http.Request) {
@@ -161,6 +303,10 @@ func (handler Handler) passwordPage(responseWriter http.ResponseWriter, request
 		http.Redirect(responseWriter, request, identityPrefix+"/login", http.StatusSeeOther)
 		return
 	}
+	if session.Access == "待二次验证" {
+		http.Redirect(responseWriter, request, loginDestination(session.Access, ""), http.StatusSeeOther)
+		return
+	}
 	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
 	passwordTemplate.Execute(responseWriter, passwordPageData{
 		CSRFToken:        requestCSRFToken(request),
@@ -186,6 +332,14 @@ func (handler Handler) changePassword(responseWriter http.ResponseWriter, reques
 		writeProblem(responseWriter, request, http.StatusUnauthorized, "not-authenticated", "not authenticated")
 		return
 	}
+	if session.Access == "待二次验证" {
+		if htmlRequest {
+			http.Redirect(responseWriter, request, loginDestination(session.Access, ""), http.StatusSeeOther)
+			return
+		}
+		writeRestrictedSessionProblem(responseWriter, request, session)
+		return
+	}
 	if !requestHasValidCSRFToken(request, session) {
 		if htmlRequest {
 			handler.redirectPasswordWithError(responseWriter, request)
@@ -212,7 +366,7 @@ func (handler Handler) changePassword(responseWriter http.ResponseWriter, reques
 	if err := identity.ChangePassword(request.Context(), handler.database, session.SubjectID, identity.ChangePasswordInput{
 		CurrentPassword: input.CurrentPassword,
 		NewPassword:     input.NewPassword,
-	}); err != nil {
+	}, handler.passwordPolicy); err != nil {
 		if htmlRequest {
 			handler.redirectPasswordWithError(responseWriter, request)
 			return
@@ -241,7 +395,7 @@ func (handler Handler) dashboard(responseWriter http.ResponseWriter, request *ht
 		return
 	}
 	if session.Access != "完整" {
-		writeProblem(responseWriter, request, http.StatusForbidden, "password-change-required", "password change required")
+		writeRestrictedSessionProblem(responseWriter, request, session)
 		return
 	}
 	administrator, err := identity.HasRole(request.Context(), handler.database, session.SubjectID, "identity.admin")
@@ -287,7 +441,7 @@ func (handler Handler) listSubjects(responseWriter http.ResponseWriter, request
 		handler.renderSubjectsPage(responseWriter, request, http.StatusOK)
 		return
 	}
-	if _, ok := handler.requireAdministrator(responseWriter, request); !ok {
+	if !handler.requireAdministratorOrClientScope(responseWriter, request, identity.SubjectsReadScope) {
 		return
 	}
 	limit, offset, err := subjectListPagination(request)
@@ -307,7 +461,7 @@ func (handler Handler) listSubjects(responseWriter http.ResponseWriter, request
 }
 
 func (handler Handler) getSubject(responseWriter http.ResponseWriter, request *http.Request) {
-	if _, ok := handler.requireAdministrator(responseWriter, request); !ok {
+	if !handler.requireAdministratorOrClientScope(responseWriter, request, identity.SubjectsReadScope) {
 		return
 	}
 	subject, err := identity.GetSubject(request.Context(), handler.database, request.PathValue("subjectID"))
@@ -364,7 +518,7 @@ func (handler Handler) createSubject(responseWriter http.ResponseWriter, request
 		DisplayName: input.DisplayName,
 		Identifier:  input.Identifier,
 		Password:    input.Password,
-	})
+	}, handler.passwordPolicy)
 	if err != nil {
 		if htmlRequest {
 			handler.redirectSubjectsWithError(responseWriter, request)
@@ -386,9 +540,12 @@ func (handler Handler) createSubject(responseWriter http.ResponseWriter, request
 	writeJSON(responseWriter, http.StatusCreated, subject)
 }
 
+// updateSubjectRequest carries exactly one change: a status, a temporary
+// password or a new display name.
 type updateSubjectRequest struct {
 	Status            string `json:"status"`
 	TemporaryPassword string `json:"temporary_password"`
+	DisplayName       string `json:"display_name"`
 }
 
 func (handler Handler) updateSubject(responseWriter http.ResponseWriter, request *http.Request) {
@@ -421,6 +578,7 @@ func (handler Handler) updateSubject(responseWriter http.ResponseWriter, request
 		input = updateSubjectRequest{
 			Status:            request.PostForm.Get("status"),
 			TemporaryPassword: request.PostForm.Get("temporary_password"),
+			DisplayName:       request.PostForm.Get("display_name"),
 		}
 	} else if err := decodeJSON(request, responseWriter, &input); err != nil {
 		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "invalid JSON request")
@@ -429,14 +587,23 @@ func (handler Handler) updateSubject(responseWriter http.ResponseWriter, request
 	subjectID := request.PathValue("subjectID")
 	var subject identity.Subject
 	var err error
+	// Renaming and enabling keep the subject's sessions; the other changes
+	// revoke them.
+	revokesSessions := true
 	switch {
-	case input.Status == "禁用" && input.TemporaryPassword == "":
+	case input.Status == "禁用" && input.TemporaryPassword == "" && input.DisplayName == "":
 		subject, err = identity.DisableSubject(request.Context(), handler.database, session.SubjectID, subjectID)
-	case input.Status == "" && input.TemporaryPassword != "":
-		err = identity.SetTemporaryPassword(request.Context(), handler.database, session.SubjectID, subjectID, input.TemporaryPassword)
+	case input.Status == "启用" && input.TemporaryPassword == "" && input.DisplayName == "":
+		revokesSessions = false
+		subject, err = identity.EnableSubject(request.Context(), handler.database, session.SubjectID, subjectID)
+	case input.Status == "" && input.TemporaryPassword != "" && input.DisplayName == "":
+		err = identity.SetTemporaryPassword(request.Context(), handler.database, session.SubjectID, subjectID, input.TemporaryPassword, handler.passwordPolicy)
 		if err == nil {
 			subject, err = identity.GetSubject(request.Context(), handler.database, subjectID)
 		}
+	case input.Status == "" && input.TemporaryPassword == "" && input.DisplayName != "":
+		revokesSessions = false
+		subject, err = identity.UpdateSubjectDisplayName(request.Context(), handler.database, session.SubjectID, subjectID, input.DisplayName)
 	default:
 		if htmlRequest {
 			handler.redirectSubjectsWithError(responseWriter, request)
@@ -453,7 +620,48 @@ func (handler Handler) updateSubject(responseWriter http.ResponseWriter, request
 		handler.writeSubjectManagementError(responseWriter, request, err)
 		return
 	}
-	if subject.ID == session.SubjectID {
+	handler.writeChangedSubject(responseWriter, request, session, subject, htmlRequest, revokesSessions)
+}
+
+// eraseSubject anonymises a subject and returns it with the status 已删除.
+func (handler Handler) eraseSubject(responseWriter http.ResponseWriter, request *http.Request) {
+	setSubjectRepresentationVary(responseWriter)
+	htmlRequest := wantsHTML(request)
+	var session identity.Session
+	var ok bool
+	if htmlRequest {
+		session, ok = handler.requireAdministratorPage(responseWriter, request)
+	} else {
+		session, ok = handler.requireAdministrator(responseWriter, request)
+	}
+	if !ok {
+		return
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		if htmlRequest {
+			handler.redirectSubjectsWithError(responseWriter, request)
+			return
+		}
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	subject, err := identity.EraseSubject(request.Context(), handler.database, session.SubjectID, request.PathValue("subjectID"))
+	if err != nil {
+		if htmlRequest {
+			handler.redirectSubjectsWithError(responseWriter, request)
+			return
+		}
+		handler.writeSubjectManagementError(responseWriter, request, err)
+		return
+	}
+	handler.writeChangedSubject(responseWriter, request, session, subject, htmlRequest, true)
+}
+
+// writeChangedSubject answers a subject change with the updated row or JSON.
+// When the change revoked the caller's own sessions it clears the cookies and
+// sends a browser to the login page instead.
+func (handler Handler) writeChangedSubject(responseWriter http.ResponseWriter, request *http.Request, session identity.Session, subject identity.Subject, htmlRequest bool, revokesSessions bool) {
+	if revokesSessions && subject.ID == session.SubjectID {
 		handler.clearSessionCookies(responseWriter)
 		if htmlRequest {
 			if isHTMXRequest(request) {
@@ -491,6 +699,8 @@ func (handler Handler) writePasswordChangeError(responseWriter http.ResponseWrit
 	switch {
 	case errors.Is(err, identity.ErrInvalidPasswordInput), errors.Is(err, identity.ErrIncorrectPassword):
 		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-password-change", "invalid password change")
+	case errors.Is(err, identity.ErrPasswordReused):
+		writeProblem(responseWriter, request, http.StatusBadRequest, "password-reused", "password was used recently")
 	case errors.Is(err, identity.ErrPasswordUpdateConflict):
 		writeProblem(responseWriter, request, http.StatusConflict, "password-change-conflict", "password changed concurrently")
 	case errors.Is(err, identity.ErrPasswordCredentialNotFound):
@@ -501,6 +711,10 @@ func (handler Handler) writePasswordChangeError(responseWriter http.ResponseWrit
 }
 
 func (handler Handler) requireAdministrator(responseWriter http.ResponseWriter, request *http.Request) (identity.Session, bool) {
+	return handler.requireRole(responseWriter, request, "identity.admin")
+}
+
+func (handler Handler) requireRole(responseWriter http.ResponseWriter, request *http.Request, roleCode string) (identity.Session, bool) {
 	session, err := handler.currentSession(request)
 	if err != nil {
 		handler.clearSessionCookies(responseWriter)
@@ -508,22 +722,36 @@ func (handler Handler) requireAdministrator(responseWriter http.ResponseWriter,
 		return identity.Session{}, false
 	}
 	if session.Access != "完整" {
-		writeProblem(responseWriter, request, http.StatusForbidden, "password-change-required", "password change required")
+		writeRestrictedSessionProblem(responseWriter, request, session)
 		return identity.Session{}, false
 	}
-	administrator, err := identity.HasRole(request.Context(), handler.database, session.SubjectID, "identity.admin")
+	authorized, err := identity.HasRole(request.Context(), handler.database, session.SubjectID, roleCode)
 	if err != nil {
 		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not authorize subject")
 		return identity.Session{}, false
 	}
-	if !administrator {
+	if !authorized {
 		writeProblem(responseWriter, request, http.StatusForbidden, "not-authorized", "not authorized")
 		return identity.Session{}, false
 	}
 	return session, true
 }
 
+// writeRestrictedSessionProblem rejects a session that is signed in but not
+// 完整, naming the step the subject still has to finish.
+func writeRestrictedSessionProblem(responseWriter http.ResponseWriter, request *http.Request, session identity.Session) {
+	if session.Access == "待二次验证" {
+		writeProblem(responseWriter, request, http.StatusForbidden, "second-factor-required", "second factor verification required")
+		return
+	}
+	writeProblem(responseWriter, request, http.StatusForbidden, "password-change-required", "password change required")
+}
+
 func (handler Handler) requireAdministratorPage(responseWriter http.ResponseWriter, request *http.Request) (identity.Session, bool) {
+	return handler.requireRolePage(responseWriter, request, "identity.admin")
+}
+
+func (handler Handler) requireRolePage(responseWriter http.ResponseWriter, request *http.Request, roleCode string) (identity.Session, bool) {
 	session, err := handler.currentSession(request)
 	if err != nil {
 		handler.clearSessionCookies(responseWriter)
@@ -531,15 +759,15 @@ func (handler Handler) requireAdministratorPage(responseWriter http.ResponseWrit
 		return identity.Session{}, false
 	}
 	if session.Access != "完整" {
-		writeProblem(responseWriter, request, http.StatusForbidden, "password-change-required", "password change required")
+		writeRestrictedSessionProblem(responseWriter, request, session)
 		return identity.Session{}, false
 	}
-	administrator, err := identity.HasRole(request.Context(), handler.database, session.SubjectID, "identity.admin")
+	authorized, err := identity.HasRole(request.Context(), handler.database, session.SubjectID, roleCode)
 	if err != nil {
 		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not authorize subject")
 		return identity.Session{}, false
 	}
-	if !administrator {
+	if !authorized {
 		writeProblem(responseWriter, request, http.StatusForbidden, "not-authorized", "not authorized")
 		return identity.Session{}, false
 	}
@@ -586,7 +814,9 @@ func (handler Handler) writeSubjectManagementError(responseWriter http.ResponseW
 	case errors.Is(err, identity.ErrPasswordUpdateConflict):
 		writeProblem(responseWriter, request, http.StatusConflict, "password-change-conflict", "password changed concurrently")
 	case errors.Is(err, identity.ErrLastAdministrator):
-		writeProblem(responseWriter, request, http.StatusForbidden, "last-administrator", "cannot disable the last enabled administrator")
+		writeProblem(responseWriter, request, http.StatusForbidden, "last-administrator", "cannot remove the last enabled administrator")
+	case errors.Is(err, identity.ErrSubjectErased):
+		writeProblem(responseWriter, request, http.StatusConflict, "subject-erased", "subject has been erased")
 	default:
 		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not manage subject")
 	}
@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -197,16 +198,36 @@ func TestLoginDashboardAndCSRFFlow(t *testing.T) {
 		t.Fatalf("current session status = %d, body = %s", currentSessionResponse.Code, currentSessionResponse.Body.String())
 	}
 	var currentSession struct {
-		SubjectID string `json:"subject_id"`
-		Access    string `json:"access"`
+		SubjectID string   `json:"subject_id"`
+		Access    string   `json:"access"`
+		Roles     []string `json:"roles"`
 	}
 	if err := json.Unmarshal(currentSessionResponse.Body.Bytes(), &currentSession); err != nil {
 		t.Fatalf("decode current session: %v", err)
 	}
-	if currentSession.SubjectID == "" || currentSession.Access != "完整" {
+	if currentSession.SubjectID == "" || currentSession.Access != "完整" || !slices.Contains(currentSession.Roles, "identity.admin") {
 		t.Fatalf("current session = %#v", currentSession)
 	}
 
+	// 下游服务代为校验写请求时转发 X-CSRF-Token，令牌必须与同一会话的 CSRF Cookie 一致。
+	for _, forwarded := range []struct {
+		token  string
+		status int
+	}{{csrfCookie.Value, http.StatusOK}, {"forged", http.StatusForbidden}} {
+		forwardedRequest := httptest.NewRequest(http.MethodGet, "/crate-api/identity/v1/session", nil)
+		forwardedRequest.AddCookie(sessionCookie)
+		forwardedRequest.AddCookie(csrfCookie)
+		forwardedRequest.Header.Set("X-CSRF-Token", forwarded.token)
+		forwardedResponse := httptest.NewRecorder()
+		mux.ServeHTTP(forwardedResponse, forwardedRequest)
+		if forwardedResponse.Code != forwarded.status || (forwarded.status == http.StatusOK && !strings.Contains(forwardedResponse.Body.String(), `"csrf_verified":true`)) {
+			t.Fatalf("forwarded CSRF %q: status = %d, body = %s", forwarded.token, forwardedResponse.Code, forwardedResponse.Body.String())
+		}
+	}
+	if strings.Contains(currentSessionResponse.Body.String(), "csrf_verified") {
+		t.Fatalf("current session without forwarded CSRF = %s", currentSessionResponse.Body.String())
+	}
+
 	dashboardRequest := httptest.NewRequest(http.MethodGet, "/crate-api/identity/v1/dashboard", nil)
 	dashboardRequest.AddCookie(sessionCookie)
 	dashboardResponse := httptest.NewRecorder()
@@ -308,6 +329,72 @@ func TestJSONLoginReturnsSessionAccessAndCookies(t *testing.T) {
 	assertProblemDetails(t, wrongPasswordResponse, http.StatusUnauthorized, "invalid-credentials", "/crate-api/identity/v1/sessions")
 }
 
+// directoryStub 接受任意非空密码，err 非空时模拟目录不可用。
+type directoryStub struct {
+	err error
+}
+
+func (directory *directoryStub) Authenticate(ctx context.Context, username string, password string) (identity.DirectoryAccount, error) {
+	if directory.err != nil {
+		return identity.DirectoryAccount{}, directory.err
+	}
+	if password == "" {
+		return identity.DirectoryAccount{}, identity.ErrInvalidCredentials
+	}
+	return identity.DirectoryAccount{DisplayName: "张三"}, nil
+}
+
+func TestJSONLoginRoutesDirectoryDomainsToDirectory(t *testing.T) {
+	databaseConnection, err := database.OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "identityd.sqlite"))
+	if err != nil {
+		t.Fatalf("open SQLite database: %v", err)
+	}
+	t.Cleanup(func() {
+		databaseConnection.Close()
+	})
+	if _, err := database.Migrate(context.Background(), databaseConnection, migrations.Files); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	if _, err := identity.EnsureBootstrap(context.Background(), databaseConnection, identity.BootstrapInput{
+		Identifier: "admin",
+		Password:   "correct horse battery staple",
+	}); err != nil {
+		t.Fatalf("ensure bootstrap: %v", err)
+	}
+
+	directory := &directoryStub{}
+	mux := httpapi.NewMux(databaseConnection, httpapi.Options{
+		SessionSettings: identity.SessionSettings{TTL: time.Hour, IdleTTL: 30 * time.Minute},
+		LoginThrottle:   testLoginThrottle,
+		Directories: []identity.Directory{{
+			Name:          "corp",
+			Domains:       []string{"corp.example.test"},
+			Authenticator: directory,
+		}},
+	})
+	login := func(identifier string, password string) *httptest.ResponseRecorder {
+		form := url.Values{"identifier": {identifier}, "password": {password}}
+		request := httptest.NewRequest(http.MethodPost, "/crate-api/identity/v1/sessions", strings.NewReader(form.Encode()))
+		request.Header.Set("Accept", "application/json")
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		return response
+	}
+
+	if response := login("zhangsan@corp.example.test", "directory password"); response.Code != http.StatusOK || len(response.Result().Cookies()) != 2 {
+		t.Fatalf("directory login status = %d, body = %s", response.Code, response.Body.String())
+	}
+	// 其他标识仍然使用本地密码。
+	if response := login("admin", "correct horse battery staple"); response.Code != http.StatusOK {
+		t.Fatalf("local login status = %d, body = %s", response.Code, response.Body.String())
+	}
+	assertProblemDetails(t, login("zhangsan@corp.example.test", ""), http.StatusUnauthorized, "invalid-credentials", "/crate-api/identity/v1/sessions")
+
+	directory.err = errors.New("connection refused")
+	assertProblemDetails(t, login("zhangsan@corp.example.test", "directory password"), http.StatusServiceUnavailable, "service-unavailable", "/crate-api/identity/v1/sessions")
+}
+
 func TestAdministratorSubjectManagementAPI(t *testing.T) {
 	databaseConnection, err := database.OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "identityd.sqlite"))
 	if err != nil {
@@ -432,6 +519,37 @@ func TestAdministratorSubjectManagementAPI(t *testing.T) {
 	if lastAdministratorResponse.Code != http.StatusForbidden {
 		t.Fatalf("last administrator disable status = %d, body = %s", lastAdministratorResponse.Code, lastAdministratorResponse.Body.String())
 	}
+
+	// 重新启用、改名和删除都使用同一主体资源。
+	changeSubject := func(method string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "/crate-api/identity/v1/subjects/"+created.ID, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("X-CSRF-Token", adminCSRF.Value)
+		request.AddCookie(adminSession)
+		request.AddCookie(adminCSRF)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		return response
+	}
+	var changed identity.Subject
+	enableResponse := changeSubject(http.MethodPatch, `{"status":"启用"}`)
+	if err := json.Unmarshal(enableResponse.Body.Bytes(), &changed); err != nil || enableResponse.Code != http.StatusOK || changed.Status != "启用" {
+		t.Fatalf("enable subject = %d %s", enableResponse.Code, enableResponse.Body.String())
+	}
+	renameResponse := changeSubject(http.MethodPatch, `{"display_name":"张三丰"}`)
+	if err := json.Unmarshal(renameResponse.Body.Bytes(), &changed); err != nil || renameResponse.Code != http.StatusOK || changed.DisplayName != "张三丰" || changed.SecurityVersion != created.SecurityVersion+2 {
+		t.Fatalf("rename subject = %d %s", renameResponse.Code, renameResponse.Body.String())
+	}
+	if response := changeSubject(http.MethodPatch, `{"status":"启用","display_name":"张三"}`); response.Code != http.StatusBadRequest {
+		t.Fatalf("combined subject update status = %d", response.Code)
+	}
+	eraseResponse := changeSubject(http.MethodDelete, "")
+	if err := json.Unmarshal(eraseResponse.Body.Bytes(), &changed); err != nil || eraseResponse.Code != http.StatusOK || changed.Status != "已删除" || strings.Contains(eraseResponse.Body.String(), "zhangsan") {
+		t.Fatalf("erase subject = %d %s", eraseResponse.Code, eraseResponse.Body.String())
+	}
+	if response := changeSubject(http.MethodPatch, `{"status":"启用"}`); response.Code != http.StatusConflict || !strings.Contains(response.Body.String(), "subject-erased") {
+		t.Fatalf("enable erased subject = %d %s", response.Code, response.Body.String())
+	}
 }
 
 func TestSubjectsPageServesEmbeddedAssetsAndHTMXFragments(t *testing.T) {
@@ -554,7 +672,7 @@ func TestTemporaryPasswordSessionCanOnlyChangePassword(t *testing.T) {
 		DisplayName: "张三",
 		Identifier:  "zhangsan",
 		Password:    "original sufficiently long password",
-	})
+	}, identity.PasswordPolicy{})
 	if err != nil {
 		t.Fatalf("create subject: %v", err)
 	}
@@ -0,0 +1,203 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+type createIdentifierRequest struct {
+	IdentifierType  string `json:"identifier_type"`
+	IdentifierValue string `json:"identifier_value"`
+	IdentifierUsage string `json:"identifier_usage"`
+}
+
+type updateIdentifierRequest struct {
+	IdentifierUsage *string `json:"identifier_usage"`
+	Status          *string `json:"status"`
+}
+
+type confirmIdentifierVerificationRequest struct {
+	Code string `json:"code"`
+}
+
+// listSubjectIdentifiers is available to administrators and to the subject
+// itself, which needs the identifier IDs to verify its own addresses.
+func (handler Handler) listSubjectIdentifiers(responseWriter http.ResponseWriter, request *http.Request) {
+	subjectID := request.PathValue("subjectID")
+	if _, ok := handler.requireSubjectOrAdministrator(responseWriter, request, subjectID); !ok {
+		return
+	}
+	identifiers, err := identity.ListSubjectIdentifiers(request.Context(), handler.database, subjectID)
+	if err != nil {
+		handler.writeIdentifierManagementError(responseWriter, request, err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, map[string]any{
+		"records": identifiers,
+		"meta":    map[string]int{"total": len(identifiers)},
+	})
+}
+
+func (handler Handler) createSubjectIdentifier(responseWriter http.ResponseWriter, request *http.Request) {
+	session, ok := handler.requireAdministrator(responseWriter, request)
+	if !ok {
+		return
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	var input createIdentifierRequest
+	if err := decodeJSON(request, responseWriter, &input); err != nil {
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "invalid JSON request")
+		return
+	}
+	identifier, err := identity.CreateSubjectIdentifier(request.Context(), handler.database, session.SubjectID, request.PathValue("subjectID"), identity.CreateIdentifierInput{
+		Type:  input.IdentifierType,
+		Value: input.IdentifierValue,
+		Usage: input.IdentifierUsage,
+	})
+	if err != nil {
+		handler.writeIdentifierManagementError(responseWriter, request, err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusCreated, identifier)
+}
+
+func (handler Handler) updateSubjectIdentifier(responseWriter http.ResponseWriter, request *http.Request) {
+	session, ok := handler.requireAdministrator(responseWriter, request)
+	if !ok {
+		return
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	var input updateIdentifierRequest
+	if err := decodeJSON(request, responseWriter, &input); err != nil {
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "invalid JSON request")
+		return
+	}
+	if input.IdentifierUsage == nil && input.Status == nil {
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "identifier_usage or status is required")
+		return
+	}
+	identifier, err := identity.UpdateSubjectIdentifier(request.Context(), handler.database, session.SubjectID, request.PathValue("subjectID"), request.PathValue("identifierID"), identity.UpdateIdentifierInput{
+		Usage:  input.IdentifierUsage,
+		Status: input.Status,
+	})
+	if err != nil {
+		handler.writeIdentifierManagementError(responseWriter, request, err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, identifier)
+}
+
+func (handler Handler) deleteSubjectIdentifier(responseWriter http.ResponseWriter, request *http.Request) {
+	session, ok := handler.requireAdministrator(responseWriter, request)
+	if !ok {
+		return
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	if err := identity.DeleteSubjectIdentifier(request.Context(), handler.database, session.SubjectID, request.PathValue("subjectID"), request.PathValue("identifierID")); err != nil {
+		handler.writeIdentifierManagementError(responseWriter, request, err)
+		return
+	}
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+// startIdentifierVerification sends a verification code to an 邮箱 or 手机号
+// identifier through the configured sender.
+func (handler Handler) startIdentifierVerification(responseWriter http.ResponseWriter, request *http.Request) {
+	subjectID := request.PathValue("subjectID")
+	session, ok := handler.requireSubjectOrAdministrator(responseWriter, request, subjectID)
+	if !ok {
+		return
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	if handler.verificationSender == nil {
+		writeProblem(responseWriter, request, http.StatusServiceUnavailable, "service-unavailable", "verification codes cannot be delivered")
+		return
+	}
+	expiresAt, err := identity.StartIdentifierVerification(request.Context(), handler.database, handler.verificationSender, subjectID, request.PathValue("identifierID"))
+	if err != nil {
+		handler.writeIdentifierManagementError(responseWriter, request, err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusAccepted, map[string]any{"expires_at": expiresAt})
+}
+
+func (handler Handler) confirmIdentifierVerification(responseWriter http.ResponseWriter, request *http.Request) {
+	subjectID := request.PathValue("subjectID")
+	session, ok := handler.requireSubjectOrAdministrator(responseWriter, request, subjectID)
+	if !ok {
+		return
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	var input confirmIdentifierVerificationRequest
+	if err := decodeJSON(request, responseWriter, &input); err != nil {
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "invalid JSON request")
+		return
+	}
+	identifier, err := identity.ConfirmIdentifierVerification(request.Context(), handler.database, session.SubjectID, subjectID, request.PathValue("identifierID"), input.Code)
+	if err != nil {
+		handler.writeIdentifierManagementError(responseWriter, request, err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, identifier)
+}
+
+// requireSubjectOrAdministrator accepts a 完整 session of the subject named
+// in the path or of an identity.admin holder.
+func (handler Handler) requireSubjectOrAdministrator(responseWriter http.ResponseWriter, request *http.Request, subjectID string) (identity.Session, bool) {
+	session, ok := handler.requireFullSession(responseWriter, request)
+	if !ok || session.SubjectID == subjectID {
+		return session, ok
+	}
+	authorized, err := identity.HasRole(request.Context(), handler.database, session.SubjectID, "identity.admin")
+	if err != nil {
+		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not authorize subject")
+		return identity.Session{}, false
+	}
+	if !authorized {
+		writeProblem(responseWriter, request, http.StatusForbidden, "not-authorized", "not authorized")
+		return identity.Session{}, false
+	}
+	return session, true
+}
+
+func (handler Handler) writeIdentifierManagementError(responseWriter http.ResponseWriter, request *http.Request, err error) {
+	switch {
+	case errors.Is(err, identity.ErrSubjectNotFound):
+		writeProblem(responseWriter, request, http.StatusNotFound, "subject-not-found", "subject not found")
+	case errors.Is(err, identity.ErrSubjectErased):
+		writeProblem(responseWriter, request, http.StatusConflict, "subject-erased", "subject has been erased")
+	case errors.Is(err, identity.ErrIdentifierNotFound):
+		writeProblem(responseWriter, request, http.StatusNotFound, "identifier-not-found", "identifier not found")
+	case errors.Is(err, identity.ErrIdentifierAlreadyExists):
+		writeProblem(responseWriter, request, http.StatusConflict, "identifier-already-exists", "identifier is already in use")
+	case errors.Is(err, identity.ErrInvalidIdentifierInput), errors.Is(err, identity.ErrVerificationNotSupported):
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", err.Error())
+	case errors.Is(err, identity.ErrPrimaryIdentifier):
+		writeProblem(responseWriter, request, http.StatusConflict, "primary-identifier", err.Error())
+	case errors.Is(err, identity.ErrIdentifierNotVerified):
+		writeProblem(responseWriter, request, http.StatusConflict, "identifier-not-verified", err.Error())
+	case errors.Is(err, identity.ErrInvalidVerificationCode):
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-verification-code", err.Error())
+	case errors.Is(err, identity.ErrVerificationTooFrequent):
+		writeProblem(responseWriter, request, http.StatusTooManyRequests, "verification-too-frequent", err.Error())
+	default:
+		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not manage identifiers")
+	}
+}
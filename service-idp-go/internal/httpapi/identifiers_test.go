@@ -0,0 +1,158 @@
+package httpapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/db/migrations"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/httpapi"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+type recordingSender struct {
+	codes []identity.VerificationCode
+}
+
+func (sender *recordingSender) SendVerificationCode(_ context.Context, code identity.VerificationCode) error {
+	sender.codes = append(sender.codes, code)
+	return nil
+}
+
+func TestIdentifierManagementAndVerificationAPI(t *testing.T) {
+	databaseConnection, err := database.OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "identityd.sqlite"))
+	if err != nil {
+		t.Fatalf("open SQLite database: %v", err)
+	}
+	t.Cleanup(func() {
+		databaseConnection.Close()
+	})
+	if _, err := database.Migrate(context.Background(), databaseConnection, migrations.Files); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	if _, err := identity.EnsureBootstrap(context.Background(), databaseConnection, identity.BootstrapInput{
+		Identifier: "admin",
+		Password:   "correct horse battery staple",
+	}); err != nil {
+		t.Fatalf("ensure bootstrap: %v", err)
+	}
+	var administratorID string
+	if err := databaseConnection.QueryRow(`
+		SELECT subject_id
+		FROM identity_identifiers
+		WHERE identifier_type = '账号' AND normalized_value = 'admin'
+	`).Scan(&administratorID); err != nil {
+		t.Fatalf("read administrator ID: %v", err)
+	}
+	created, err := identity.CreateSubject(context.Background(), databaseConnection, administratorID, identity.CreateSubjectInput{
+		DisplayName: "王五",
+		Identifier:  "wangwu",
+		Password:    "a sufficiently long password",
+	}, identity.PasswordPolicy{})
+	if err != nil {
+		t.Fatalf("create subject: %v", err)
+	}
+
+	sender := &recordingSender{}
+	mux := httpapi.NewMux(databaseConnection, httpapi.Options{
+		SessionSettings:    identity.SessionSettings{TTL: time.Hour, IdleTTL: 30 * time.Minute},
+		LoginThrottle:      testLoginThrottle,
+		VerificationSender: sender,
+	})
+	adminSession, adminCSRF := loginCookies(t, mux, "admin", "correct horse battery staple")
+	subjectSession, subjectCSRF := loginCookies(t, mux, "wangwu", "a sufficiently long password")
+	send := func(method string, path string, body string, session *http.Cookie, csrf *http.Cookie) *httptest.ResponseRecorder {
+		var reader io.Reader
+		if body != "" {
+			reader = strings.NewReader(body)
+		}
+		request := httptest.NewRequest(method, path, reader)
+		request.Header.Set("Content-Type", "application/json")
+		request.AddCookie(session)
+		if csrf != nil {
+			request.AddCookie(csrf)
+			request.Header.Set("X-CSRF-Token", csrf.Value)
+		}
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		return response
+	}
+
+	identifiersPath := "/crate-api/identity/v1/subjects/" + created.ID + "/identifiers"
+	emailBody := `{"identifier_type":"邮箱","identifier_value":"WangWu@Example.test","identifier_usage":"联系"}`
+	assertProblemDetails(t, send(http.MethodPost, identifiersPath, emailBody, subjectSession, subjectCSRF), http.StatusForbidden, "not-authorized", identifiersPath)
+	if response := send(http.MethodPost, identifiersPath, emailBody, adminSession, nil); response.Code != http.StatusForbidden {
+		t.Fatalf("create identifier without CSRF status = %d", response.Code)
+	}
+	createResponse := send(http.MethodPost, identifiersPath, emailBody, adminSession, adminCSRF)
+	var email identity.Identifier
+	if err := json.Unmarshal(createResponse.Body.Bytes(), &email); err != nil || createResponse.Code != http.StatusCreated || email.NormalizedValue != "wangwu@example.test" {
+		t.Fatalf("create email = %d %s", createResponse.Code, createResponse.Body.String())
+	}
+	assertProblemDetails(t, send(http.MethodPost, identifiersPath, emailBody, adminSession, adminCSRF), http.StatusConflict, "identifier-already-exists", identifiersPath)
+
+	// 主体本人可以查看自己的标识符，但不能查看他人的。
+	var listed struct {
+		Records []identity.Identifier `json:"records"`
+		Meta    struct {
+			Total int `json:"total"`
+		} `json:"meta"`
+	}
+	listResponse := send(http.MethodGet, identifiersPath, "", subjectSession, nil)
+	if err := json.Unmarshal(listResponse.Body.Bytes(), &listed); err != nil || listResponse.Code != http.StatusOK || listed.Meta.Total != 2 {
+		t.Fatalf("own identifiers = %d %s", listResponse.Code, listResponse.Body.String())
+	}
+	administratorIdentifiersPath := "/crate-api/identity/v1/subjects/" + administratorID + "/identifiers"
+	assertProblemDetails(t, send(http.MethodGet, administratorIdentifiersPath, "", subjectSession, nil), http.StatusForbidden, "not-authorized", administratorIdentifiersPath)
+
+	emailPath := identifiersPath + "/" + email.ID
+	assertProblemDetails(t, send(http.MethodPatch, emailPath, `{"identifier_usage":"辅助登录"}`, adminSession, adminCSRF), http.StatusConflict, "identifier-not-verified", emailPath)
+
+	verificationPath := emailPath + "/verification"
+	if response := send(http.MethodPost, verificationPath, "", subjectSession, subjectCSRF); response.Code != http.StatusAccepted {
+		t.Fatalf("start verification = %d %s", response.Code, response.Body.String())
+	}
+	assertProblemDetails(t, send(http.MethodPost, verificationPath, "", subjectSession, subjectCSRF), http.StatusTooManyRequests, "verification-too-frequent", verificationPath)
+	if len(sender.codes) != 1 || sender.codes[0].Address != "wangwu@example.test" {
+		t.Fatalf("sent codes = %#v", sender.codes)
+	}
+	confirmPath := verificationPath + "/confirm"
+	assertProblemDetails(t, send(http.MethodPost, confirmPath, `{"code":"not-a-code"}`, subjectSession, subjectCSRF), http.StatusBadRequest, "invalid-verification-code", confirmPath)
+	confirmResponse := send(http.MethodPost, confirmPath, `{"code":"`+sender.codes[0].Code+`"}`, subjectSession, subjectCSRF)
+	if err := json.Unmarshal(confirmResponse.Body.Bytes(), &email); err != nil || confirmResponse.Code != http.StatusOK || email.VerifiedAt == nil {
+		t.Fatalf("confirm verification = %d %s", confirmResponse.Code, confirmResponse.Body.String())
+	}
+
+	patchResponse := send(http.MethodPatch, emailPath, `{"identifier_usage":"辅助登录"}`, adminSession, adminCSRF)
+	if patchResponse.Code != http.StatusOK {
+		t.Fatalf("promote email to login = %d %s", patchResponse.Code, patchResponse.Body.String())
+	}
+	loginCookies(t, mux, "wangwu@example.test", "a sufficiently long password")
+
+	primaryPath := identifiersPath + "/" + listed.Records[0].ID
+	assertProblemDetails(t, send(http.MethodDelete, primaryPath, "", adminSession, adminCSRF), http.StatusConflict, "primary-identifier", primaryPath)
+	if response := send(http.MethodDelete, emailPath, "", adminSession, adminCSRF); response.Code != http.StatusNoContent {
+		t.Fatalf("delete email = %d %s", response.Code, response.Body.String())
+	}
+	assertProblemDetails(t, send(http.MethodDelete, emailPath, "", adminSession, adminCSRF), http.StatusNotFound, "identifier-not-found", emailPath)
+
+	// 未配置发送器时验证接口不可用。
+	withoutSender := httpapi.NewMux(databaseConnection, httpapi.Options{
+		SessionSettings: identity.SessionSettings{TTL: time.Hour, IdleTTL: 30 * time.Minute},
+		LoginThrottle:   testLoginThrottle,
+	})
+	request := httptest.NewRequest(http.MethodPost, verificationPath, nil)
+	request.AddCookie(subjectSession)
+	request.AddCookie(subjectCSRF)
+	request.Header.Set("X-CSRF-Token", subjectCSRF.Value)
+	response := httptest.NewRecorder()
+	withoutSender.ServeHTTP(response, request)
+	assertProblemDetails(t, response, http.StatusServiceUnavailable, "service-unavailable", verificationPath)
+}
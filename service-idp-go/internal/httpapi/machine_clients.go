@@ -0,0 +1,199 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/oidc"
+)
+
+type createMachineClientRequest struct {
+	ClientID    string   `json:"client_id"`
+	DisplayName string   `json:"display_name"`
+	Scopes      []string `json:"scopes"`
+}
+
+type updateMachineClientRequest struct {
+	DisplayName *string  `json:"display_name"`
+	Status      *string  `json:"status"`
+	Scopes      []string `json:"scopes"`
+}
+
+// rotateMachineClientSecretRequest sets how long the replaced secrets stay
+// valid, in seconds. Omitted, it defaults to identity.DefaultClientSecretOverlap.
+type rotateMachineClientSecretRequest struct {
+	OverlapSeconds *int64 `json:"overlap_seconds"`
+}
+
+func (handler Handler) listMachineClients(responseWriter http.ResponseWriter, request *http.Request) {
+	if _, ok := handler.requireAdministrator(responseWriter, request); !ok {
+		return
+	}
+	limit, offset, err := subjectListPagination(request)
+	if err != nil {
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "invalid pagination")
+		return
+	}
+	result, err := identity.ListMachineClients(request.Context(), handler.database, identity.ListMachineClientsInput{Limit: limit, Offset: offset})
+	if err != nil {
+		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not list machine clients")
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, map[string]any{
+		"records": result.Clients,
+		"meta":    map[string]int64{"total": result.Total},
+	})
+}
+
+func (handler Handler) getMachineClient(responseWriter http.ResponseWriter, request *http.Request) {
+	if _, ok := handler.requireAdministrator(responseWriter, request); !ok {
+		return
+	}
+	client, err := identity.GetMachineClient(request.Context(), handler.database, request.PathValue("clientID"))
+	if err != nil {
+		handler.writeMachineClientManagementError(responseWriter, request, err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, client)
+}
+
+// createMachineClient answers the first client_secret once; only its hash is
+// stored.
+func (handler Handler) createMachineClient(responseWriter http.ResponseWriter, request *http.Request) {
+	session, ok := handler.requireAdministrator(responseWriter, request)
+	if !ok {
+		return
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	var input createMachineClientRequest
+	if err := decodeJSON(request, responseWriter, &input); err != nil {
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "invalid JSON request")
+		return
+	}
+	credentials, err := identity.CreateMachineClient(request.Context(), handler.database, session.SubjectID, identity.CreateMachineClientInput{
+		ClientID:    input.ClientID,
+		DisplayName: input.DisplayName,
+		Scopes:      input.Scopes,
+	})
+	if err != nil {
+		handler.writeMachineClientManagementError(responseWriter, request, err)
+		return
+	}
+	responseWriter.Header().Set("Cache-Control", "no-store")
+	writeJSON(responseWriter, http.StatusCreated, credentials)
+}
+
+func (handler Handler) updateMachineClient(responseWriter http.ResponseWriter, request *http.Request) {
+	session, ok := handler.requireAdministrator(responseWriter, request)
+	if !ok {
+		return
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	var input updateMachineClientRequest
+	if err := decodeJSON(request, responseWriter, &input); err != nil {
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "invalid JSON request")
+		return
+	}
+	client, err := identity.UpdateMachineClient(request.Context(), handler.database, session.SubjectID, request.PathValue("clientID"), identity.UpdateMachineClientInput{
+		DisplayName: input.DisplayName,
+		Status:      input.Status,
+		Scopes:      input.Scopes,
+	})
+	if err != nil {
+		handler.writeMachineClientManagementError(responseWriter, request, err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, client)
+}
+
+// rotateMachineClientSecret issues a new secret and answers it once. The
+// body is optional.
+func (handler Handler) rotateMachineClientSecret(responseWriter http.ResponseWriter, request *http.Request) {
+	session, ok := handler.requireAdministrator(responseWriter, request)
+	if !ok {
+		return
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	overlap := identity.DefaultClientSecretOverlap
+	if request.ContentLength != 0 {
+		var input rotateMachineClientSecretRequest
+		if err := decodeJSON(request, responseWriter, &input); err != nil {
+			writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "invalid JSON request")
+			return
+		}
+		if input.OverlapSeconds != nil {
+			if *input.OverlapSeconds < 0 || *input.OverlapSeconds > int64(identity.MaximumClientSecretOverlap/time.Second) {
+				writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "overlap_seconds must be between 0 and 604800")
+				return
+			}
+			overlap = time.Duration(*input.OverlapSeconds) * time.Second
+		}
+	}
+	credentials, err := identity.RotateMachineClientSecret(request.Context(), handler.database, session.SubjectID, request.PathValue("clientID"), overlap)
+	if err != nil {
+		handler.writeMachineClientManagementError(responseWriter, request, err)
+		return
+	}
+	responseWriter.Header().Set("Cache-Control", "no-store")
+	writeJSON(responseWriter, http.StatusCreated, credentials)
+}
+
+func (handler Handler) writeMachineClientManagementError(responseWriter http.ResponseWriter, request *http.Request, err error) {
+	switch {
+	case errors.Is(err, identity.ErrMachineClientNotFound):
+		writeProblem(responseWriter, request, http.StatusNotFound, "client-not-found", "machine client not found")
+	case errors.Is(err, identity.ErrClientAlreadyExists):
+		writeProblem(responseWriter, request, http.StatusConflict, "client-already-exists", "client_id is already registered")
+	case errors.Is(err, identity.ErrInvalidMachineClientInput):
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", err.Error())
+	default:
+		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not manage machine client")
+	}
+}
+
+// requireAdministratorOrClientScope authorizes a read of identityd's API
+// either by an administrator session or, when the request carries a bearer
+// token, by a machine client token holding scope. A bearer token never falls
+// back to the session cookie.
+func (handler Handler) requireAdministratorOrClientScope(responseWriter http.ResponseWriter, request *http.Request, scope string) bool {
+	authorization := request.Header.Get("Authorization")
+	if authorization == "" || handler.oidcProvider == nil {
+		_, ok := handler.requireAdministrator(responseWriter, request)
+		return ok
+	}
+	accessToken, found := strings.CutPrefix(authorization, "Bearer ")
+	if !found || accessToken == "" {
+		responseWriter.Header().Set("WWW-Authenticate", `Bearer`)
+		writeProblem(responseWriter, request, http.StatusUnauthorized, "not-authenticated", "bearer access token required")
+		return false
+	}
+	token, err := handler.oidcProvider.AuthenticateClientToken(request.Context(), accessToken)
+	if errors.Is(err, oidc.ErrInvalidToken) {
+		responseWriter.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeProblem(responseWriter, request, http.StatusUnauthorized, "invalid-token", "invalid access token")
+		return false
+	}
+	if err != nil {
+		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not authorize client")
+		return false
+	}
+	if !slices.Contains(token.Scopes, scope) {
+		responseWriter.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+		writeProblem(responseWriter, request, http.StatusForbidden, "insufficient-scope", "the access token lacks scope "+scope)
+		return false
+	}
+	return true
+}
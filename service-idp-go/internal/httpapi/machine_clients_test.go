@@ -0,0 +1,142 @@
+package httpapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/oidc"
+	"github.com/ovaphlow/pitchfork/service-idp-go/pkg/accesstoken"
+)
+
+func TestMachineClientCredentialsGrant(t *testing.T) {
+	_, mux, _, _ := oidcTestMux(t)
+	adminSession, adminCSRF := loginCookies(t, mux, "admin", "correct horse battery staple")
+	sendAdmin := func(method string, path string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("X-CSRF-Token", adminCSRF.Value)
+		request.AddCookie(adminSession)
+		request.AddCookie(adminCSRF)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		return response
+	}
+
+	createResponse := sendAdmin(http.MethodPost, "/crate-api/identity/v1/machine-clients", `{"client_id":"prototyped","display_name":"演练原型服务","scopes":["identity.subjects.read","prototype.drills:write"]}`)
+	if createResponse.Code != http.StatusCreated || createResponse.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("create machine client status = %d; body = %s", createResponse.Code, createResponse.Body.String())
+	}
+	var created identity.MachineClientCredentials
+	if err := json.Unmarshal(createResponse.Body.Bytes(), &created); err != nil || created.ClientSecret == "" {
+		t.Fatalf("created machine client = %s, err = %v", createResponse.Body.String(), err)
+	}
+	getResponse := sendAdmin(http.MethodGet, "/crate-api/identity/v1/machine-clients/prototyped", "")
+	if getResponse.Code != http.StatusOK || strings.Contains(getResponse.Body.String(), created.ClientSecret) {
+		t.Fatalf("get machine client = %d %s", getResponse.Code, getResponse.Body.String())
+	}
+
+	assertOAuthError(t, clientCredentials(mux, "prototyped", "wrong", ""), http.StatusUnauthorized, "invalid_client")
+	assertOAuthError(t, clientCredentials(mux, "prototyped", created.ClientSecret, "identity.admin"), http.StatusBadRequest, "invalid_scope")
+
+	// 只申请部分 scope 时令牌只携带这些 scope。
+	narrowResponse := clientCredentials(mux, "prototyped", created.ClientSecret, "prototype.drills:write")
+	var narrow oidc.TokenResponse
+	if err := json.Unmarshal(narrowResponse.Body.Bytes(), &narrow); err != nil || narrowResponse.Code != http.StatusOK || narrow.Scope != "prototype.drills:write" || narrow.IDToken != "" {
+		t.Fatalf("narrow token = %d %s", narrowResponse.Code, narrowResponse.Body.String())
+	}
+	// aud 取自 scope 的资源前缀：只含 prototype scope 的令牌不是签给 identityd 的。
+	foreign := bearerRequest(mux, "/crate-api/identity/v1/subjects", narrow.AccessToken)
+	assertProblemDetails(t, foreign, http.StatusUnauthorized, "invalid-token", "/crate-api/identity/v1/subjects")
+	provisioningResponse := sendAdmin(http.MethodPost, "/crate-api/identity/v1/machine-clients", `{"client_id":"provisioner","display_name":"开通服务","scopes":["identity.provisioning"]}`)
+	var provisioner identity.MachineClientCredentials
+	if err := json.Unmarshal(provisioningResponse.Body.Bytes(), &provisioner); err != nil || provisioningResponse.Code != http.StatusCreated {
+		t.Fatalf("create provisioning client = %d %s", provisioningResponse.Code, provisioningResponse.Body.String())
+	}
+	var provisioning oidc.TokenResponse
+	if response := clientCredentials(mux, "provisioner", provisioner.ClientSecret, ""); json.Unmarshal(response.Body.Bytes(), &provisioning) != nil || response.Code != http.StatusOK {
+		t.Fatalf("provisioning token = %d %s", response.Code, response.Body.String())
+	}
+	insufficient := bearerRequest(mux, "/crate-api/identity/v1/subjects", provisioning.AccessToken)
+	assertProblemDetails(t, insufficient, http.StatusForbidden, "insufficient-scope", "/crate-api/identity/v1/subjects")
+
+	// client_secret_post 与 HTTP Basic 均可用；默认签发已登记的全部 scope。
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {"prototyped"}, "client_secret": {created.ClientSecret}}
+	postRequest := httptest.NewRequest(http.MethodPost, "/crate-api/identity/v1/token", strings.NewReader(form.Encode()))
+	postRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	postResponse := httptest.NewRecorder()
+	mux.ServeHTTP(postResponse, postRequest)
+	var tokens oidc.TokenResponse
+	if err := json.Unmarshal(postResponse.Body.Bytes(), &tokens); err != nil || postResponse.Code != http.StatusOK || tokens.Scope != "identity.subjects.read prototype.drills:write" {
+		t.Fatalf("token = %d %s", postResponse.Code, postResponse.Body.String())
+	}
+	subjectsResponse := bearerRequest(mux, "/crate-api/identity/v1/subjects", tokens.AccessToken)
+	if subjectsResponse.Code != http.StatusOK || !strings.Contains(subjectsResponse.Body.String(), `"total":1`) {
+		t.Fatalf("subjects with client token = %d %s", subjectsResponse.Code, subjectsResponse.Body.String())
+	}
+	// 机器客户端令牌不能当作主体令牌使用，也不能访问未开放的管理接口。
+	assertProblemDetails(t, userInfo(mux, tokens.AccessToken), http.StatusUnauthorized, "invalid-token", "/crate-api/identity/v1/userinfo")
+	assertProblemDetails(t, bearerRequest(mux, "/crate-api/identity/v1/roles", tokens.AccessToken), http.StatusUnauthorized, "not-authenticated", "/crate-api/identity/v1/roles")
+
+	// 其他服务可离线校验同一令牌。
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	verifier, err := accesstoken.NewVerifier(accesstoken.Config{Issuer: testIssuer, JWKSURL: server.URL + "/crate-api/identity/v1/jwks", Audience: "prototype"})
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	claims, err := verifier.Verify(context.Background(), tokens.AccessToken)
+	if err != nil || !claims.IsClient() || claims.ClientID != "prototyped" || !claims.HasScope("prototype.drills:write") || strings.Join(claims.Audience, " ") != "identity prototype" {
+		t.Fatalf("verified claims = %#v, %v", claims, err)
+	}
+	if _, err := verifier.Verify(context.Background(), provisioning.AccessToken); !errors.Is(err, accesstoken.ErrInvalidToken) {
+		t.Fatalf("verify a token for another audience: %v", err)
+	}
+
+	// 轮换时立即吊销旧密钥；禁用客户端后已签发的令牌在 identityd 上立即失效。
+	rotateResponse := sendAdmin(http.MethodPost, "/crate-api/identity/v1/machine-clients/prototyped/secrets", `{"overlap_seconds":0}`)
+	var rotated identity.MachineClientCredentials
+	if err := json.Unmarshal(rotateResponse.Body.Bytes(), &rotated); err != nil || rotateResponse.Code != http.StatusCreated || rotated.ClientSecret == "" {
+		t.Fatalf("rotate = %d %s", rotateResponse.Code, rotateResponse.Body.String())
+	}
+	assertOAuthError(t, clientCredentials(mux, "prototyped", created.ClientSecret, ""), http.StatusUnauthorized, "invalid_client")
+	if response := clientCredentials(mux, "prototyped", rotated.ClientSecret, ""); response.Code != http.StatusOK {
+		t.Fatalf("token with rotated secret = %d %s", response.Code, response.Body.String())
+	}
+	if response := sendAdmin(http.MethodPatch, "/crate-api/identity/v1/machine-clients/prototyped", `{"status":"禁用"}`); response.Code != http.StatusOK {
+		t.Fatalf("disable machine client = %d %s", response.Code, response.Body.String())
+	}
+	revoked := bearerRequest(mux, "/crate-api/identity/v1/subjects", tokens.AccessToken)
+	assertProblemDetails(t, revoked, http.StatusUnauthorized, "invalid-token", "/crate-api/identity/v1/subjects")
+	if _, err := verifier.Verify(context.Background(), "not.a.token"); !errors.Is(err, accesstoken.ErrInvalidToken) {
+		t.Fatalf("verify malformed token: %v", err)
+	}
+}
+
+func clientCredentials(handler http.Handler, clientID string, clientSecret string, scope string) *httptest.ResponseRecorder {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if scope != "" {
+		form.Set("scope", scope)
+	}
+	request := httptest.NewRequest(http.MethodPost, "/crate-api/identity/v1/token", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	return response
+}
+
+func bearerRequest(handler http.Handler, path string, accessToken string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	request.Header.Set("Accept", "application/json")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	return response
+}
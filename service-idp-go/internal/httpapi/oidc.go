@@ -0,0 +1,189 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/oidc"
+)
+
+const maximumTokenRequestBytes = 16 << 10
+
+func (handler Handler) openIDConfiguration(responseWriter http.ResponseWriter, request *http.Request) {
+	responseWriter.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(responseWriter, http.StatusOK, handler.oidcProvider.Discovery())
+}
+
+func (handler Handler) jwks(responseWriter http.ResponseWriter, request *http.Request) {
+	keys, err := handler.oidcProvider.JWKS(request.Context())
+	if err != nil {
+		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not load signing keys")
+		return
+	}
+	responseWriter.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(responseWriter, http.StatusOK, keys)
+}
+
+// authorize is the Authorization Code + PKCE entry point. An unknown client
+// or unregistered redirect URI is answered locally; every later error is
+// redirected to the client. Without a full browser session the request is
+// parked on the login page and resumed through return_to.
+func (handler Handler) authorize(responseWriter http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	authorization, err := handler.oidcProvider.ValidateAuthorizationRequest(request.Context(), oidc.AuthorizationRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		Nonce:               query.Get("nonce"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	})
+	var protocolError *oidc.Error
+	switch {
+	case errors.As(err, &protocolError):
+		http.Redirect(responseWriter, request, handler.oidcProvider.ErrorRedirect(authorization, protocolError), http.StatusFound)
+		return
+	case errors.Is(err, oidc.ErrInvalidClient):
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-client", "unknown or disabled client")
+		return
+	case errors.Is(err, oidc.ErrInvalidRedirectURI):
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-redirect-uri", "redirect_uri is not registered for the client")
+		return
+	case err != nil:
+		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not validate authorization request")
+		return
+	}
+
+	session, err := handler.currentSession(request)
+	if err != nil || session.Access != "完整" {
+		switch {
+		case query.Get("prompt") == "none":
+			http.Redirect(responseWriter, request, handler.oidcProvider.ErrorRedirect(authorization, &oidc.Error{Code: "login_required", Description: "no active session"}), http.StatusFound)
+		case err == nil:
+			http.Redirect(responseWriter, request, loginDestination(session.Access, request.URL.RequestURI()), http.StatusSeeOther)
+		default:
+			http.Redirect(responseWriter, request, identityPrefix+"/login?return_to="+url.QueryEscape(request.URL.RequestURI()), http.StatusSeeOther)
+		}
+		return
+	}
+	code, err := handler.oidcProvider.IssueAuthorizationCode(request.Context(), authorization, session.SubjectID)
+	if err != nil {
+		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not issue authorization code")
+		return
+	}
+	http.Redirect(responseWriter, request, handler.oidcProvider.AuthorizationRedirect(authorization, code), http.StatusFound)
+}
+
+// token redeems an authorization code or, for the client_credentials grant,
+// authenticates a machine client by HTTP Basic or the client_secret form
+// parameter. Its errors use the RFC 6749 JSON error body that OAuth clients
+// parse, not Problem Details.
+func (handler Handler) token(responseWriter http.ResponseWriter, request *http.Request) {
+	responseWriter = withoutProblemDetails(responseWriter)
+	responseWriter.Header().Set("Cache-Control", "no-store")
+	responseWriter.Header().Set("Pragma", "no-cache")
+	request.Body = http.MaxBytesReader(responseWriter, request.Body, maximumTokenRequestBytes)
+	if err := request.ParseForm(); err != nil {
+		writeOAuthError(responseWriter, http.StatusBadRequest, &oidc.Error{Code: "invalid_request", Description: "invalid form body"})
+		return
+	}
+	tokenRequest := oidc.TokenRequest{
+		GrantType:    request.PostForm.Get("grant_type"),
+		Code:         request.PostForm.Get("code"),
+		RedirectURI:  request.PostForm.Get("redirect_uri"),
+		ClientID:     request.PostForm.Get("client_id"),
+		ClientSecret: request.PostForm.Get("client_secret"),
+		CodeVerifier: request.PostForm.Get("code_verifier"),
+		Scope:        request.PostForm.Get("scope"),
+	}
+	basicClientID, basicClientSecret, basicAuthentication := request.BasicAuth()
+	if basicAuthentication {
+		// RFC 6749 section 2.3.1 form-encodes both values before Basic encoding.
+		clientID, idErr := url.QueryUnescape(basicClientID)
+		clientSecret, secretErr := url.QueryUnescape(basicClientSecret)
+		if idErr != nil || secretErr != nil || tokenRequest.ClientSecret != "" || (tokenRequest.ClientID != "" && tokenRequest.ClientID != clientID) {
+			writeOAuthError(responseWriter, http.StatusBadRequest, &oidc.Error{Code: "invalid_request", Description: "use exactly one client authentication method"})
+			return
+		}
+		tokenRequest.ClientID = clientID
+		tokenRequest.ClientSecret = clientSecret
+	}
+
+	var response oidc.TokenResponse
+	var err error
+	if tokenRequest.GrantType == "client_credentials" {
+		response, err = handler.oidcProvider.ExchangeClientCredentials(request.Context(), tokenRequest)
+	} else {
+		response, err = handler.oidcProvider.ExchangeAuthorizationCode(request.Context(), tokenRequest)
+	}
+	var protocolError *oidc.Error
+	switch {
+	case errors.As(err, &protocolError) && protocolError.Code == "invalid_client":
+		if basicAuthentication {
+			responseWriter.Header().Set("WWW-Authenticate", `Basic realm="identityd"`)
+		}
+		writeOAuthError(responseWriter, http.StatusUnauthorized, protocolError)
+		return
+	case errors.As(err, &protocolError):
+		writeOAuthError(responseWriter, http.StatusBadRequest, protocolError)
+		return
+	case err != nil:
+		writeOAuthError(responseWriter, http.StatusInternalServerError, &oidc.Error{Code: "server_error", Description: "could not issue tokens"})
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, response)
+}
+
+func (handler Handler) userinfo(responseWriter http.ResponseWriter, request *http.Request) {
+	responseWriter.Header().Set("Cache-Control", "no-store")
+	accessToken, found := strings.CutPrefix(request.Header.Get("Authorization"), "Bearer ")
+	if !found || accessToken == "" {
+		responseWriter.Header().Set("WWW-Authenticate", `Bearer`)
+		writeProblem(responseWriter, request, http.StatusUnauthorized, "not-authenticated", "bearer access token required")
+		return
+	}
+	userInfo, err := handler.oidcProvider.UserInfo(request.Context(), accessToken)
+	if errors.Is(err, oidc.ErrInvalidToken) {
+		responseWriter.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeProblem(responseWriter, request, http.StatusUnauthorized, "invalid-token", "invalid access token")
+		return
+	}
+	if err != nil {
+		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not load user info")
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, userInfo)
+}
+
+func writeOAuthError(responseWriter http.ResponseWriter, statusCode int, protocolError *oidc.Error) {
+	writeJSON(responseWriter, statusCode, map[string]string{
+		"error":             protocolError.Code,
+		"error_description": protocolError.Description,
+	})
+}
+
+// withoutProblemDetails bypasses the Problem Details rewrite for a protocol
+// endpoint whose error format is fixed by its specification.
+func withoutProblemDetails(responseWriter http.ResponseWriter) http.ResponseWriter {
+	if problemWriter, ok := responseWriter.(*problemDetailsResponseWriter); ok {
+		return problemWriter.ResponseWriter
+	}
+	return responseWriter
+}
+
+// loginReturnTo accepts only a local authorization-request URL as the
+// post-login destination, so return_to cannot become an open redirect.
+func loginReturnTo(value string) string {
+	if !strings.HasPrefix(value, identityPrefix+"/authorize?") || strings.ContainsAny(value, "\\\r\n") {
+		return ""
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Scheme != "" || parsed.Host != "" || parsed.Path != identityPrefix+"/authorize" {
+		return ""
+	}
+	return value
+}
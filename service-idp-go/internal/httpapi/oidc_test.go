@@ -0,0 +1,313 @@
+package httpapi_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/db/migrations"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/httpapi"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/oidc"
+)
+
+const (
+	testIssuer       = "https://identity.example.test/crate-api/identity/v1"
+	testRedirectURI  = "https://console.example.test/callback"
+	testCodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+func TestOIDCAuthorizationCodeFlowWithPKCE(t *testing.T) {
+	databaseConnection, mux, administratorID, signer := oidcTestMux(t)
+	subject, err := identity.CreateSubject(context.Background(), databaseConnection, administratorID, identity.CreateSubjectInput{
+		DisplayName: "张三",
+		Identifier:  "zhangsan",
+		Password:    "a sufficiently long password",
+	}, identity.PasswordPolicy{})
+	if err != nil {
+		t.Fatalf("create subject: %v", err)
+	}
+
+	adminSession, adminCSRF := loginCookies(t, mux, "admin", "correct horse battery staple")
+	createClient := httptest.NewRequest(http.MethodPost, "/crate-api/identity/v1/clients", strings.NewReader(`{"client_id":"command-console","display_name":"指挥控制台","redirect_uris":["`+testRedirectURI+`"],"scopes":["openid","profile"]}`))
+	createClient.Header.Set("Content-Type", "application/json")
+	createClient.Header.Set("X-CSRF-Token", adminCSRF.Value)
+	createClient.AddCookie(adminSession)
+	createClient.AddCookie(adminCSRF)
+	createClientResponse := httptest.NewRecorder()
+	mux.ServeHTTP(createClientResponse, createClient)
+	if createClientResponse.Code != http.StatusCreated {
+		t.Fatalf("create client status = %d; body = %s", createClientResponse.Code, createClientResponse.Body.String())
+	}
+
+	discoveryResponse := httptest.NewRecorder()
+	mux.ServeHTTP(discoveryResponse, httptest.NewRequest(http.MethodGet, "/crate-api/identity/v1/.well-known/openid-configuration", nil))
+	var discovery oidc.Discovery
+	if err := json.Unmarshal(discoveryResponse.Body.Bytes(), &discovery); err != nil || discoveryResponse.Code != http.StatusOK {
+		t.Fatalf("discovery status = %d, err = %v", discoveryResponse.Code, err)
+	}
+	if discovery.Issuer != testIssuer || discovery.TokenEndpoint != testIssuer+"/token" || discovery.JWKSURI != testIssuer+"/jwks" {
+		t.Fatalf("discovery = %#v", discovery)
+	}
+	jwksResponse := httptest.NewRecorder()
+	mux.ServeHTTP(jwksResponse, httptest.NewRequest(http.MethodGet, "/crate-api/identity/v1/jwks", nil))
+	var keySet oidc.JWKS
+	if err := json.Unmarshal(jwksResponse.Body.Bytes(), &keySet); err != nil || len(keySet.Keys) != 1 || keySet.Keys[0].KeyID != signer.KeyID() {
+		t.Fatalf("JWKS = %s, err = %v", jwksResponse.Body.String(), err)
+	}
+
+	// 未登录时授权请求被暂存到登录页，登录后回到原授权请求。
+	authorizePath := authorizationPath("command-console", testRedirectURI, "openid profile", "state-1")
+	anonymousResponse := httptest.NewRecorder()
+	mux.ServeHTTP(anonymousResponse, httptest.NewRequest(http.MethodGet, authorizePath, nil))
+	if anonymousResponse.Code != http.StatusSeeOther || anonymousResponse.Header().Get("Location") != "/crate-api/identity/v1/login?return_to="+url.QueryEscape(authorizePath) {
+		t.Fatalf("anonymous authorize = %d %q", anonymousResponse.Code, anonymousResponse.Header().Get("Location"))
+	}
+	loginPageResponse := httptest.NewRecorder()
+	mux.ServeHTTP(loginPageResponse, httptest.NewRequest(http.MethodGet, anonymousResponse.Header().Get("Location"), nil))
+	if !strings.Contains(loginPageResponse.Body.String(), `name="return_to"`) {
+		t.Fatalf("login page does not carry return_to: %s", loginPageResponse.Body.String())
+	}
+	sessionCookie, _ := loginCookiesWithReturnTo(t, mux, "zhangsan", "a sufficiently long password", authorizePath)
+
+	code := authorize(t, mux, sessionCookie, authorizePath, "state-1")
+	badVerifier := exchangeCode(mux, code, strings.Repeat("x", 43))
+	assertOAuthError(t, badVerifier, http.StatusBadRequest, "invalid_grant")
+	// 校验失败的授权码已被消费，不能再用正确的 verifier 重试。
+	assertOAuthError(t, exchangeCode(mux, code, testCodeVerifier), http.StatusBadRequest, "invalid_grant")
+
+	code = authorize(t, mux, sessionCookie, authorizePath, "state-1")
+	tokenResponse := exchangeCode(mux, code, testCodeVerifier)
+	if tokenResponse.Code != http.StatusOK || tokenResponse.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("token status = %d; body = %s", tokenResponse.Code, tokenResponse.Body.String())
+	}
+	var tokens oidc.TokenResponse
+	if err := json.Unmarshal(tokenResponse.Body.Bytes(), &tokens); err != nil {
+		t.Fatalf("decode token response: %v", err)
+	}
+	if tokens.TokenType != "Bearer" || tokens.AccessToken == "" || tokens.IDToken == "" || tokens.Scope != "openid profile" {
+		t.Fatalf("token response = %#v", tokens)
+	}
+	assertOAuthError(t, exchangeCode(mux, code, testCodeVerifier), http.StatusBadRequest, "invalid_grant")
+
+	userInfoResponse := userInfo(mux, tokens.AccessToken)
+	var info oidc.UserInfo
+	if err := json.Unmarshal(userInfoResponse.Body.Bytes(), &info); err != nil || userInfoResponse.Code != http.StatusOK {
+		t.Fatalf("userinfo status = %d; body = %s", userInfoResponse.Code, userInfoResponse.Body.String())
+	}
+	if info.Subject != subject.ID || info.PreferredUsername != "zhangsan" || info.Name != "张三" {
+		t.Fatalf("userinfo = %#v", info)
+	}
+
+	// 禁用主体会提升 security_version：未兑换的授权码和已签发的访问令牌同时失效。
+	pendingCode := authorize(t, mux, sessionCookie, authorizePath, "state-1")
+	if _, err := identity.DisableSubject(context.Background(), databaseConnection, administratorID, subject.ID); err != nil {
+		t.Fatalf("disable subject: %v", err)
+	}
+	revokedUserInfo := userInfo(mux, tokens.AccessToken)
+	assertProblemDetails(t, revokedUserInfo, http.StatusUnauthorized, "invalid-token", "/crate-api/identity/v1/userinfo")
+	if revokedUserInfo.Header().Get("WWW-Authenticate") == "" {
+		t.Fatal("revoked userinfo did not send WWW-Authenticate")
+	}
+	assertOAuthError(t, exchangeCode(mux, pendingCode, testCodeVerifier), http.StatusBadRequest, "invalid_grant")
+}
+
+func TestOIDCAuthorizeRejectsUnregisteredRedirectLocally(t *testing.T) {
+	databaseConnection, mux, administratorID, _ := oidcTestMux(t)
+	if _, err := identity.CreateClient(context.Background(), databaseConnection, administratorID, identity.CreateClientInput{
+		ClientID:     "command-console",
+		DisplayName:  "指挥控制台",
+		RedirectURIs: []string{testRedirectURI},
+	}); err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+
+	path := authorizationPath("command-console", "https://attacker.example.test/callback", "openid", "state-1")
+	response := httptest.NewRecorder()
+	mux.ServeHTTP(response, httptest.NewRequest(http.MethodGet, path, nil))
+	assertProblemDetails(t, response, http.StatusBadRequest, "invalid-redirect-uri", path)
+
+	// redirect_uri 可信之后，其余错误重定向回客户端并带上 state 与 iss。
+	missingOpenID := authorizationPath("command-console", testRedirectURI, "profile", "state-2")
+	redirected := httptest.NewRecorder()
+	mux.ServeHTTP(redirected, httptest.NewRequest(http.MethodGet, missingOpenID, nil))
+	location, err := url.Parse(redirected.Header().Get("Location"))
+	if redirected.Code != http.StatusFound || err != nil {
+		t.Fatalf("invalid scope authorize = %d %q", redirected.Code, redirected.Header().Get("Location"))
+	}
+	if query := location.Query(); query.Get("error") != "invalid_scope" || query.Get("state") != "state-2" || query.Get("iss") != testIssuer {
+		t.Fatalf("invalid scope redirect = %s", location)
+	}
+
+	// 非本地授权地址的 return_to 会被忽略，避免开放重定向。
+	form := url.Values{"identifier": {"admin"}, "password": {"correct horse battery staple"}, "return_to": {"https://attacker.example.test/"}}
+	login := httptest.NewRequest(http.MethodPost, "/crate-api/identity/v1/sessions", strings.NewReader(form.Encode()))
+	login.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	loginResponse := httptest.NewRecorder()
+	mux.ServeHTTP(loginResponse, login)
+	if location := loginResponse.Header().Get("Location"); location != "/crate-api/identity/v1/dashboard" {
+		t.Fatalf("login with foreign return_to redirect = %q", location)
+	}
+}
+
+func oidcTestMux(t *testing.T) (*sql.DB, http.Handler, string, *oidc.Signer) {
+	t.Helper()
+	databaseConnection, err := database.OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "identityd.sqlite"))
+	if err != nil {
+		t.Fatalf("open SQLite database: %v", err)
+	}
+	t.Cleanup(func() {
+		databaseConnection.Close()
+	})
+	if _, err := database.Migrate(context.Background(), databaseConnection, migrations.Files); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	if _, err := identity.EnsureBootstrap(context.Background(), databaseConnection, identity.BootstrapInput{
+		Identifier: "admin",
+		Password:   "correct horse battery staple",
+	}); err != nil {
+		t.Fatalf("ensure bootstrap: %v", err)
+	}
+	var administratorID string
+	if err := databaseConnection.QueryRow(`
+		SELECT subject_id
+		FROM identity_identifiers
+		WHERE identifier_type = '账号' AND normalized_value = 'admin'
+	`).Scan(&administratorID); err != nil {
+		t.Fatalf("read administrator ID: %v", err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate signing key: %v", err)
+	}
+	signer, err := oidc.NewSigner(key)
+	if err != nil {
+		t.Fatalf("new signer: %v", err)
+	}
+	provider, err := oidc.NewProvider(databaseConnection, oidc.Settings{
+		Issuer:               testIssuer,
+		Signer:               signer,
+		AccessTokenTTL:       10 * time.Minute,
+		AuthorizationCodeTTL: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+	if err := provider.RegisterSigningKey(context.Background()); err != nil {
+		t.Fatalf("register signing key: %v", err)
+	}
+	mux := httpapi.NewMux(databaseConnection, httpapi.Options{
+		SessionSettings: identity.SessionSettings{TTL: time.Hour, IdleTTL: 30 * time.Minute},
+		LoginThrottle:   testLoginThrottle,
+		OIDC:            provider,
+	})
+	return databaseConnection, mux, administratorID, signer
+}
+
+func authorizationPath(clientID string, redirectURI string, scope string, state string) string {
+	digest := sha256.Sum256([]byte(testCodeVerifier))
+	return "/crate-api/identity/v1/authorize?" + url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {scope},
+		"state":                 {state},
+		"nonce":                 {"nonce-1"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(digest[:])},
+		"code_challenge_method": {"S256"},
+	}.Encode()
+}
+
+func authorize(t *testing.T, handler http.Handler, sessionCookie *http.Cookie, path string, wantState string) string {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	request.AddCookie(sessionCookie)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	location, err := url.Parse(response.Header().Get("Location"))
+	if response.Code != http.StatusFound || err != nil {
+		t.Fatalf("authorize = %d %q", response.Code, response.Header().Get("Location"))
+	}
+	query := location.Query()
+	if !strings.HasPrefix(location.String(), testRedirectURI+"?") || query.Get("code") == "" || query.Get("state") != wantState || query.Get("iss") != testIssuer {
+		t.Fatalf("authorize redirect = %s", location)
+	}
+	return query.Get("code")
+}
+
+func exchangeCode(handler http.Handler, code string, verifier string) *httptest.ResponseRecorder {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {testRedirectURI},
+		"client_id":     {"command-console"},
+		"code_verifier": {verifier},
+	}
+	request := httptest.NewRequest(http.MethodPost, "/crate-api/identity/v1/token", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	return response
+}
+
+func userInfo(handler http.Handler, accessToken string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/crate-api/identity/v1/userinfo", nil)
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	return response
+}
+
+func assertOAuthError(t *testing.T, response *httptest.ResponseRecorder, wantStatus int, wantCode string) {
+	t.Helper()
+	if response.Code != wantStatus {
+		t.Fatalf("status = %d, want %d; body = %s", response.Code, wantStatus, response.Body.String())
+	}
+	if contentType := response.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+		t.Fatalf("OAuth error content type = %q", contentType)
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil || body.Error != wantCode {
+		t.Fatalf("OAuth error = %s, want %s", response.Body.String(), wantCode)
+	}
+}
+
+func loginCookiesWithReturnTo(t *testing.T, handler http.Handler, identifier string, password string, returnTo string) (*http.Cookie, *http.Cookie) {
+	t.Helper()
+	form := url.Values{"identifier": {identifier}, "password": {password}, "return_to": {returnTo}}
+	request := httptest.NewRequest(http.MethodPost, "/crate-api/identity/v1/sessions", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusSeeOther || response.Header().Get("Location") != returnTo {
+		t.Fatalf("login %s = %d %q, want %q", identifier, response.Code, response.Header().Get("Location"), returnTo)
+	}
+	var sessionCookie, csrfCookie *http.Cookie
+	for _, cookie := range response.Result().Cookies() {
+		switch cookie.Name {
+		case "identityd_session":
+			sessionCookie = cookie
+		case "identityd_csrf":
+			csrfCookie = cookie
+		}
+	}
+	if sessionCookie == nil || csrfCookie == nil {
+		t.Fatalf("login %s did not return session cookies", identifier)
+	}
+	return sessionCookie, csrfCookie
+}
@@ -0,0 +1,108 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+// passwordResetPage asks for the 邮箱 address, or for the new password when
+// the reset link carries a token.
+func (handler Handler) passwordResetPage(responseWriter http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	passwordResetTemplate.Execute(responseWriter, passwordResetPageData{
+		Token:     query.Get("token"),
+		Sent:      query.Get("sent") == "1",
+		Throttled: query.Get("throttled") == "1",
+		HasError:  query.Get("error") == "1",
+	})
+}
+
+// requestPasswordReset answers the same way whether or not the address
+// belongs to a subject and whether or not the delivery succeeds; only
+// throttling differs.
+func (handler Handler) requestPasswordReset(responseWriter http.ResponseWriter, request *http.Request) {
+	jsonRequest := wantsJSON(request)
+	if err := request.ParseForm(); err != nil {
+		if jsonRequest {
+			writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "invalid password reset request")
+			return
+		}
+		http.Redirect(responseWriter, request, identityPrefix+"/password-reset?error=1", http.StatusSeeOther)
+		return
+	}
+	if handler.passwordResetNotifier == nil {
+		if jsonRequest {
+			writeProblem(responseWriter, request, http.StatusServiceUnavailable, "service-unavailable", "password resets cannot be delivered")
+			return
+		}
+		http.Redirect(responseWriter, request, identityPrefix+"/password-reset?error=1", http.StatusSeeOther)
+		return
+	}
+	err := identity.RequestPasswordReset(request.Context(), handler.database, handler.passwordResetNotifier, handler.loginThrottle, identity.RequestPasswordResetInput{
+		Email:         request.Form.Get("email"),
+		SourceAddress: clientSourceAddress(request, handler.trustedProxyPrefixes),
+	})
+	switch {
+	case errors.Is(err, identity.ErrPasswordResetThrottled):
+		if jsonRequest {
+			writeProblem(responseWriter, request, http.StatusTooManyRequests, "password-reset-throttled", "too many password reset requests")
+			return
+		}
+		http.Redirect(responseWriter, request, identityPrefix+"/password-reset?throttled=1", http.StatusSeeOther)
+	case err != nil:
+		if jsonRequest {
+			writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not request password reset")
+			return
+		}
+		http.Redirect(responseWriter, request, identityPrefix+"/password-reset?error=1", http.StatusSeeOther)
+	case jsonRequest:
+		responseWriter.WriteHeader(http.StatusAccepted)
+	default:
+		http.Redirect(responseWriter, request, identityPrefix+"/password-reset?sent=1", http.StatusSeeOther)
+	}
+}
+
+func (handler Handler) confirmPasswordReset(responseWriter http.ResponseWriter, request *http.Request) {
+	jsonRequest := wantsJSON(request)
+	if err := request.ParseForm(); err != nil {
+		if jsonRequest {
+			writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "invalid password reset request")
+			return
+		}
+		http.Redirect(responseWriter, request, identityPrefix+"/password-reset?error=1", http.StatusSeeOther)
+		return
+	}
+	token := request.Form.Get("token")
+	err := identity.ResetPassword(request.Context(), handler.database, identity.ResetPasswordInput{
+		Token:       token,
+		NewPassword: request.Form.Get("new_password"),
+	}, handler.passwordPolicy)
+	if err != nil {
+		if !jsonRequest {
+			http.Redirect(responseWriter, request, identityPrefix+"/password-reset?error=1&token="+url.QueryEscape(token), http.StatusSeeOther)
+			return
+		}
+		switch {
+		case errors.Is(err, identity.ErrInvalidPasswordResetToken):
+			writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-reset-token", "password reset token is invalid or expired")
+		case errors.Is(err, identity.ErrInvalidPasswordInput):
+			writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-password-change", "invalid password change")
+		case errors.Is(err, identity.ErrPasswordReused):
+			writeProblem(responseWriter, request, http.StatusBadRequest, "password-reused", "password was used recently")
+		default:
+			writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not reset password")
+		}
+		return
+	}
+	// Any session in this browser was revoked with the others.
+	handler.clearSessionCookies(responseWriter)
+	if jsonRequest {
+		responseWriter.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Redirect(responseWriter, request, identityPrefix+"/login", http.StatusSeeOther)
+}
@@ -0,0 +1,157 @@
+package httpapi_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/db/migrations"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/httpapi"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+// recordingNotifier passes each delivered notice to the test, which waits
+// for it with next since delivery runs in the background.
+type recordingNotifier struct {
+	delivered chan identity.PasswordResetNotice
+}
+
+func (notifier *recordingNotifier) SendPasswordReset(_ context.Context, notice identity.PasswordResetNotice) error {
+	notifier.delivered <- notice
+	return nil
+}
+
+func (notifier *recordingNotifier) next(t *testing.T) identity.PasswordResetNotice {
+	t.Helper()
+	select {
+	case notice := <-notifier.delivered:
+		return notice
+	case <-time.After(5 * time.Second):
+		t.Fatal("password reset was not delivered")
+		return identity.PasswordResetNotice{}
+	}
+}
+
+func TestPasswordResetFormsAndJSONAPI(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection, err := database.OpenSQLite(ctx, filepath.Join(t.TempDir(), "identityd.sqlite"))
+	if err != nil {
+		t.Fatalf("open SQLite database: %v", err)
+	}
+	t.Cleanup(func() {
+		databaseConnection.Close()
+	})
+	if _, err := database.Migrate(ctx, databaseConnection, migrations.Files); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	if _, err := identity.EnsureBootstrap(ctx, databaseConnection, identity.BootstrapInput{
+		Identifier: "admin",
+		Password:   "correct horse battery staple",
+	}); err != nil {
+		t.Fatalf("ensure bootstrap: %v", err)
+	}
+	var administratorID string
+	if err := databaseConnection.QueryRow(`
+		SELECT subject_id
+		FROM identity_identifiers
+		WHERE identifier_type = '账号' AND normalized_value = 'admin'
+	`).Scan(&administratorID); err != nil {
+		t.Fatalf("read administrator ID: %v", err)
+	}
+	email, err := identity.CreateSubjectIdentifier(ctx, databaseConnection, administratorID, administratorID, identity.CreateIdentifierInput{
+		Type:  "邮箱",
+		Value: "admin@example.test",
+		Usage: "联系",
+	})
+	if err != nil {
+		t.Fatalf("create email: %v", err)
+	}
+	sender := &recordingSender{}
+	if _, err := identity.StartIdentifierVerification(ctx, databaseConnection, sender, administratorID, email.ID); err != nil {
+		t.Fatalf("start verification: %v", err)
+	}
+	if _, err := identity.ConfirmIdentifierVerification(ctx, databaseConnection, administratorID, administratorID, email.ID, sender.codes[0].Code); err != nil {
+		t.Fatalf("confirm verification: %v", err)
+	}
+
+	notifier := &recordingNotifier{delivered: make(chan identity.PasswordResetNotice, 16)}
+	mux := httpapi.NewMux(databaseConnection, httpapi.Options{
+		SessionSettings:       identity.SessionSettings{TTL: time.Hour, IdleTTL: 30 * time.Minute},
+		LoginThrottle:         testLoginThrottle,
+		PasswordResetNotifier: notifier,
+	})
+	post := func(path string, form url.Values, accept string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if accept != "" {
+			request.Header.Set("Accept", accept)
+		}
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		return response
+	}
+
+	loginPage := httptest.NewRecorder()
+	mux.ServeHTTP(loginPage, httptest.NewRequest(http.MethodGet, "/crate-api/identity/v1/login", nil))
+	if !strings.Contains(loginPage.Body.String(), `href="/crate-api/identity/v1/password-reset"`) {
+		t.Fatalf("login page has no reset link: %s", loginPage.Body.String())
+	}
+
+	// 表单提交无论地址是否存在都跳转到同一提示。
+	for _, address := range []string{"nobody@example.test", "admin@example.test"} {
+		response := post("/crate-api/identity/v1/password-resets", url.Values{"email": {address}}, "")
+		if response.Code != http.StatusSeeOther || response.Header().Get("Location") != "/crate-api/identity/v1/password-reset?sent=1" {
+			t.Fatalf("reset request for %s = %d %q", address, response.Code, response.Header().Get("Location"))
+		}
+	}
+	first := notifier.next(t)
+	if len(notifier.delivered) != 0 {
+		t.Fatalf("%d more notices than requests for registered addresses", len(notifier.delivered))
+	}
+	// 同一地址与来源的第三次申请达到上限，之后的申请被节流。
+	var latest identity.PasswordResetNotice
+	for range 2 {
+		if response := post("/crate-api/identity/v1/password-resets", url.Values{"email": {"admin@example.test"}}, "application/json"); response.Code != http.StatusAccepted {
+			t.Fatalf("JSON reset request = %d %s", response.Code, response.Body.String())
+		}
+		latest = notifier.next(t)
+	}
+	assertProblemDetails(t, post("/crate-api/identity/v1/password-resets", url.Values{"email": {"admin@example.test"}}, "application/json"), http.StatusTooManyRequests, "password-reset-throttled", "/crate-api/identity/v1/password-resets")
+
+	token := latest.Token
+	resetPage := httptest.NewRecorder()
+	mux.ServeHTTP(resetPage, httptest.NewRequest(http.MethodGet, "/crate-api/identity/v1/password-reset?token="+url.QueryEscape(token), nil))
+	if resetPage.Code != http.StatusOK || !strings.Contains(resetPage.Body.String(), `name="new_password"`) {
+		t.Fatalf("reset page = %d %s", resetPage.Code, resetPage.Body.String())
+	}
+
+	confirmPath := "/crate-api/identity/v1/password-resets/confirm"
+	assertProblemDetails(t, post(confirmPath, url.Values{"token": {first.Token}, "new_password": {"a brand new long password"}}, "application/json"), http.StatusBadRequest, "invalid-reset-token", confirmPath)
+	weak := post(confirmPath, url.Values{"token": {token}, "new_password": {"short"}}, "")
+	if weak.Code != http.StatusSeeOther || !strings.HasPrefix(weak.Header().Get("Location"), "/crate-api/identity/v1/password-reset?error=1&token=") {
+		t.Fatalf("weak password reset = %d %q", weak.Code, weak.Header().Get("Location"))
+	}
+	confirmed := post(confirmPath, url.Values{"token": {token}, "new_password": {"a brand new long password"}}, "")
+	if confirmed.Code != http.StatusSeeOther || confirmed.Header().Get("Location") != "/crate-api/identity/v1/login" {
+		t.Fatalf("confirm reset = %d %q", confirmed.Code, confirmed.Header().Get("Location"))
+	}
+	loginCookies(t, mux, "admin", "a brand new long password")
+
+	// 未配置通知器时申请接口不可用。
+	withoutNotifier := httpapi.NewMux(databaseConnection, httpapi.Options{
+		SessionSettings: identity.SessionSettings{TTL: time.Hour, IdleTTL: 30 * time.Minute},
+		LoginThrottle:   testLoginThrottle,
+	})
+	request := httptest.NewRequest(http.MethodPost, "/crate-api/identity/v1/password-resets", strings.NewReader("email=admin%40example.test"))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	response := httptest.NewRecorder()
+	withoutNotifier.ServeHTTP(response, request)
+	assertProblemDetails(t, response, http.StatusServiceUnavailable, "service-unavailable", "/crate-api/identity/v1/password-resets")
+}
@@ -0,0 +1,161 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+type createRoleRequest struct {
+	RoleCode    string `json:"role_code"`
+	DisplayName string `json:"display_name"`
+	Description string `json:"description"`
+}
+
+type updateRoleRequest struct {
+	SecondFactor string `json:"second_factor"`
+}
+
+type grantSubjectRoleRequest struct {
+	RoleCode string `json:"role_code"`
+}
+
+func (handler Handler) listRoles(responseWriter http.ResponseWriter, request *http.Request) {
+	if _, ok := handler.requireAdministrator(responseWriter, request); !ok {
+		return
+	}
+	roles, err := identity.ListRoles(request.Context(), handler.database)
+	if err != nil {
+		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not list roles")
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, map[string]any{
+		"records": roles,
+		"meta":    map[string]int{"total": len(roles)},
+	})
+}
+
+func (handler Handler) createRole(responseWriter http.ResponseWriter, request *http.Request) {
+	session, ok := handler.requireAdministrator(responseWriter, request)
+	if !ok {
+		return
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	var input createRoleRequest
+	if err := decodeJSON(request, responseWriter, &input); err != nil {
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "invalid JSON request")
+		return
+	}
+	role, err := identity.CreateRole(request.Context(), handler.database, session.SubjectID, identity.CreateRoleInput{
+		RoleCode:    input.RoleCode,
+		DisplayName: input.DisplayName,
+		Description: input.Description,
+	})
+	if err != nil {
+		handler.writeRoleManagementError(responseWriter, request, err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusCreated, role)
+}
+
+// updateRole changes the second factor requirement of a role. Holders'
+// existing sessions are not touched; the requirement applies at their next
+// sign-in.
+func (handler Handler) updateRole(responseWriter http.ResponseWriter, request *http.Request) {
+	session, ok := handler.requireAdministrator(responseWriter, request)
+	if !ok {
+		return
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	var input updateRoleRequest
+	if err := decodeJSON(request, responseWriter, &input); err != nil {
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "invalid JSON request")
+		return
+	}
+	role, err := identity.SetRoleSecondFactor(request.Context(), handler.database, session.SubjectID, request.PathValue("roleCode"), input.SecondFactor)
+	if err != nil {
+		handler.writeRoleManagementError(responseWriter, request, err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, role)
+}
+
+func (handler Handler) listSubjectRoles(responseWriter http.ResponseWriter, request *http.Request) {
+	if _, ok := handler.requireAdministrator(responseWriter, request); !ok {
+		return
+	}
+	subject, err := identity.GetSubject(request.Context(), handler.database, request.PathValue("subjectID"))
+	if err != nil {
+		handler.writeRoleManagementError(responseWriter, request, err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, map[string]any{
+		"records": subject.Roles,
+		"meta":    map[string]int{"total": len(subject.Roles)},
+	})
+}
+
+func (handler Handler) grantSubjectRole(responseWriter http.ResponseWriter, request *http.Request) {
+	session, ok := handler.requireAdministrator(responseWriter, request)
+	if !ok {
+		return
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	var input grantSubjectRoleRequest
+	if err := decodeJSON(request, responseWriter, &input); err != nil {
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "invalid JSON request")
+		return
+	}
+	subject, err := identity.GrantSubjectRole(request.Context(), handler.database, session.SubjectID, request.PathValue("subjectID"), input.RoleCode)
+	if err != nil {
+		handler.writeRoleManagementError(responseWriter, request, err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, subject)
+}
+
+func (handler Handler) revokeSubjectRole(responseWriter http.ResponseWriter, request *http.Request) {
+	session, ok := handler.requireAdministrator(responseWriter, request)
+	if !ok {
+		return
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	subject, err := identity.RevokeSubjectRole(request.Context(), handler.database, session.SubjectID, request.PathValue("subjectID"), request.PathValue("roleCode"))
+	if err != nil {
+		handler.writeRoleManagementError(responseWriter, request, err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, subject)
+}
+
+func (handler Handler) writeRoleManagementError(responseWriter http.ResponseWriter, request *http.Request, err error) {
+	switch {
+	case errors.Is(err, identity.ErrSubjectNotFound):
+		writeProblem(responseWriter, request, http.StatusNotFound, "subject-not-found", "subject not found")
+	case errors.Is(err, identity.ErrSubjectErased):
+		writeProblem(responseWriter, request, http.StatusConflict, "subject-erased", "subject has been erased")
+	case errors.Is(err, identity.ErrRoleNotFound):
+		writeProblem(responseWriter, request, http.StatusNotFound, "role-not-found", "role not found")
+	case errors.Is(err, identity.ErrRoleAlreadyExists):
+		writeProblem(responseWriter, request, http.StatusConflict, "role-already-exists", "role_code is already defined")
+	case errors.Is(err, identity.ErrInvalidRoleInput), errors.Is(err, identity.ErrInvalidSecondFactorInput):
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", err.Error())
+	case errors.Is(err, identity.ErrLastAdministrator):
+		writeProblem(responseWriter, request, http.StatusForbidden, "last-administrator", "cannot remove the last enabled administrator")
+	default:
+		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not manage roles")
+	}
+}
@@ -0,0 +1,119 @@
+package httpapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/db/migrations"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/httpapi"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+func TestAdministratorRoleManagementAPI(t *testing.T) {
+	databaseConnection, err := database.OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "identityd.sqlite"))
+	if err != nil {
+		t.Fatalf("open SQLite database: %v", err)
+	}
+	t.Cleanup(func() {
+		databaseConnection.Close()
+	})
+	if _, err := database.Migrate(context.Background(), databaseConnection, migrations.Files); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	if _, err := identity.EnsureBootstrap(context.Background(), databaseConnection, identity.BootstrapInput{
+		Identifier: "admin",
+		Password:   "correct horse battery staple",
+	}); err != nil {
+		t.Fatalf("ensure bootstrap: %v", err)
+	}
+	var administratorID string
+	if err := databaseConnection.QueryRow(`
+		SELECT subject_id
+		FROM identity_identifiers
+		WHERE identifier_type = '账号' AND normalized_value = 'admin'
+	`).Scan(&administratorID); err != nil {
+		t.Fatalf("read administrator ID: %v", err)
+	}
+	created, err := identity.CreateSubject(context.Background(), databaseConnection, administratorID, identity.CreateSubjectInput{
+		DisplayName: "张三",
+		Identifier:  "zhangsan",
+		Password:    "a sufficiently long password",
+	}, identity.PasswordPolicy{})
+	if err != nil {
+		t.Fatalf("create subject: %v", err)
+	}
+
+	mux := httpapi.NewMux(databaseConnection, httpapi.Options{
+		SessionSettings: identity.SessionSettings{TTL: time.Hour, IdleTTL: 30 * time.Minute},
+		LoginThrottle:   testLoginThrottle,
+	})
+	adminSession, adminCSRF := loginCookies(t, mux, "admin", "correct horse battery staple")
+	adminRequest := func(method string, path string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("X-CSRF-Token", adminCSRF.Value)
+		request.AddCookie(adminSession)
+		request.AddCookie(adminCSRF)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		return response
+	}
+
+	createResponse := adminRequest(http.MethodPost, "/crate-api/identity/v1/roles", `{"role_code":"trainova.instructor","display_name":"培训讲师","description":"可以编排训练课程"}`)
+	if createResponse.Code != http.StatusCreated {
+		t.Fatalf("create role status = %d; body = %s", createResponse.Code, createResponse.Body.String())
+	}
+	assertProblemDetails(t, adminRequest(http.MethodPost, "/crate-api/identity/v1/roles", `{"role_code":"trainova.instructor","display_name":"培训讲师"}`), http.StatusConflict, "role-already-exists", "/crate-api/identity/v1/roles")
+	assertProblemDetails(t, adminRequest(http.MethodPost, "/crate-api/identity/v1/roles", `{"role_code":"identity.operator","display_name":"运维"}`), http.StatusBadRequest, "invalid-request", "/crate-api/identity/v1/roles")
+
+	listResponse := adminRequest(http.MethodGet, "/crate-api/identity/v1/roles", "")
+	var listed struct {
+		Records []identity.Role `json:"records"`
+		Meta    struct {
+			Total int `json:"total"`
+		} `json:"meta"`
+	}
+	if err := json.Unmarshal(listResponse.Body.Bytes(), &listed); err != nil || listed.Meta.Total != 3 || len(listed.Records) != 3 {
+		t.Fatalf("role list = %s, err = %v", listResponse.Body.String(), err)
+	}
+
+	subjectRolesPath := "/crate-api/identity/v1/subjects/" + created.ID + "/roles"
+	grantResponse := adminRequest(http.MethodPost, subjectRolesPath, `{"role_code":"trainova.instructor"}`)
+	var granted identity.Subject
+	if err := json.Unmarshal(grantResponse.Body.Bytes(), &granted); err != nil || grantResponse.Code != http.StatusOK {
+		t.Fatalf("grant role status = %d; body = %s", grantResponse.Code, grantResponse.Body.String())
+	}
+	if len(granted.Roles) != 1 || granted.Roles[0] != "trainova.instructor" {
+		t.Fatalf("granted subject = %#v", granted)
+	}
+	assertProblemDetails(t, adminRequest(http.MethodPost, subjectRolesPath, `{"role_code":"trainova.missing"}`), http.StatusNotFound, "role-not-found", subjectRolesPath)
+
+	subjectRolesResponse := adminRequest(http.MethodGet, subjectRolesPath, "")
+	if subjectRolesResponse.Code != http.StatusOK || !strings.Contains(subjectRolesResponse.Body.String(), `"trainova.instructor"`) {
+		t.Fatalf("subject roles = %d %s", subjectRolesResponse.Code, subjectRolesResponse.Body.String())
+	}
+
+	revokeResponse := adminRequest(http.MethodDelete, subjectRolesPath+"/trainova.instructor", "")
+	var revoked identity.Subject
+	if err := json.Unmarshal(revokeResponse.Body.Bytes(), &revoked); err != nil || revokeResponse.Code != http.StatusOK || len(revoked.Roles) != 0 {
+		t.Fatalf("revoke role status = %d; body = %s", revokeResponse.Code, revokeResponse.Body.String())
+	}
+
+	lastAdministratorPath := "/crate-api/identity/v1/subjects/" + administratorID + "/roles/identity.admin"
+	assertProblemDetails(t, adminRequest(http.MethodDelete, lastAdministratorPath, ""), http.StatusForbidden, "last-administrator", lastAdministratorPath)
+
+	missingCSRF := httptest.NewRequest(http.MethodDelete, subjectRolesPath+"/trainova.instructor", nil)
+	missingCSRF.AddCookie(adminSession)
+	missingCSRFResponse := httptest.NewRecorder()
+	mux.ServeHTTP(missingCSRFResponse, missingCSRF)
+	if missingCSRFResponse.Code != http.StatusForbidden {
+		t.Fatalf("revoke role without CSRF status = %d", missingCSRFResponse.Code)
+	}
+}
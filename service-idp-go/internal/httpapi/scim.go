@@ -0,0 +1,970 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/oidc"
+)
+
+// The SCIM 2.0 endpoint (RFC 7643, RFC 7644) lets an HR system provision
+// subjects as Users and role memberships as Groups. It only accepts
+// client_credentials tokens holding identity.ProvisioningScope and answers
+// errors in the SCIM format instead of Problem Details.
+const (
+	scimPrefix             = identityPrefix + "/scim/v2"
+	scimMediaType          = "application/scim+json"
+	scimUserSchema         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimGroupSchema        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimListResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimErrorSchema        = "urn:ietf:params:scim:api:messages:2.0:Error"
+	scimResourceTypeSchema = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	scimConfigSchema       = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+
+	defaultSCIMPageSize int64 = 100
+)
+
+var (
+	scimFilterPattern = regexp.MustCompile(`^\s*([A-Za-z][\w.]*)\s+(?i:eq)\s+("(?:[^"\\]|\\.)*")\s*$`)
+	scimPathPattern   = regexp.MustCompile(`^([A-Za-z][\w:.-]*?)(?:\[(.+)\])?(?:\.(\w+))?$`)
+)
+
+type scimUser struct {
+	Schemas      []string         `json:"schemas"`
+	ID           string           `json:"id,omitempty"`
+	ExternalID   string           `json:"externalId,omitempty"`
+	UserName     string           `json:"userName"`
+	Name         *scimName        `json:"name,omitempty"`
+	DisplayName  string           `json:"displayName,omitempty"`
+	Active       *scimBool        `json:"active,omitempty"`
+	Password     string           `json:"password,omitempty"`
+	Emails       []scimMultiValue `json:"emails,omitempty"`
+	PhoneNumbers []scimMultiValue `json:"phoneNumbers,omitempty"`
+	Groups       []scimReference  `json:"groups,omitempty"`
+	Meta         *scimMeta        `json:"meta,omitempty"`
+}
+
+type scimName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+}
+
+type scimMultiValue struct {
+	Value   string   `json:"value"`
+	Type    string   `json:"type,omitempty"`
+	Primary scimBool `json:"primary,omitempty"`
+}
+
+type scimGroup struct {
+	Schemas     []string        `json:"schemas"`
+	ID          string          `json:"id,omitempty"`
+	DisplayName string          `json:"displayName"`
+	Members     []scimReference `json:"members"`
+	Meta        *scimMeta       `json:"meta,omitempty"`
+}
+
+type scimReference struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+type scimMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+	Version      string    `json:"version"`
+}
+
+type scimListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int64    `json:"totalResults"`
+	StartIndex   int64    `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    any      `json:"Resources"`
+}
+
+type scimPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []scimPatchOperation `json:"Operations"`
+}
+
+type scimPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// scimBool also accepts the "True" and "False" strings some provisioning
+// clients send for booleans.
+type scimBool bool
+
+func (value *scimBool) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		parsed, err := strconv.ParseBool(strings.ToLower(text))
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", text)
+		}
+		*value = scimBool(parsed)
+		return nil
+	}
+	var parsed bool
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+	*value = scimBool(parsed)
+	return nil
+}
+
+// scimRequestError is a client error with its SCIM status and scimType.
+type scimRequestError struct {
+	status   int
+	scimType string
+	detail   string
+}
+
+func (err scimRequestError) Error() string {
+	return err.detail
+}
+
+// scimPath is an attribute path such as emails[type eq "work"].value.
+type scimPath struct {
+	attribute    string
+	filter       string
+	filterValue  string
+	subAttribute string
+}
+
+func (handler Handler) scimServiceProviderConfig(responseWriter http.ResponseWriter, request *http.Request) {
+	writeSCIM(responseWriter, http.StatusOK, map[string]any{
+		"schemas":        []string{scimConfigSchema},
+		"patch":          map[string]bool{"supported": true},
+		"bulk":           map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]any{"supported": true, "maxResults": identity.MaximumProvisioningPageSize},
+		"changePassword": map[string]bool{"supported": false},
+		"sort":           map[string]bool{"supported": false},
+		"etag":           map[string]bool{"supported": true},
+		"authenticationSchemes": []map[string]string{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "client_credentials access token with scope " + identity.ProvisioningScope,
+		}},
+	})
+}
+
+func (handler Handler) scimResourceTypes(responseWriter http.ResponseWriter, request *http.Request) {
+	resourceTypes := []map[string]any{
+		{"schemas": []string{scimResourceTypeSchema}, "id": "User", "name": "User", "endpoint": "/Users", "schema": scimUserSchema},
+		{"schemas": []string{scimResourceTypeSchema}, "id": "Group", "name": "Group", "endpoint": "/Groups", "schema": scimGroupSchema},
+	}
+	writeSCIM(responseWriter, http.StatusOK, scimListResponse{
+		Schemas:      []string{scimListResponseSchema},
+		TotalResults: int64(len(resourceTypes)),
+		StartIndex:   1,
+		ItemsPerPage: len(resourceTypes),
+		Resources:    resourceTypes,
+	})
+}
+
+func (handler Handler) listSCIMUsers(responseWriter http.ResponseWriter, request *http.Request) {
+	if _, ok := handler.requireProvisioningClient(responseWriter, request); !ok {
+		return
+	}
+	startIndex, count := scimPagination(request)
+	filter, err := scimListFilter(request, map[string]string{
+		"id":                 identity.ProvisioningFilterID,
+		"username":           identity.ProvisioningFilterAccount,
+		"displayname":        identity.ProvisioningFilterDisplayName,
+		"externalid":         identity.ProvisioningFilterEmployeeNumber,
+		"emails":             identity.ProvisioningFilterEmail,
+		"emails.value":       identity.ProvisioningFilterEmail,
+		"phonenumbers":       identity.ProvisioningFilterPhoneNumber,
+		"phonenumbers.value": identity.ProvisioningFilterPhoneNumber,
+	})
+	if err != nil {
+		writeSCIMRequestError(responseWriter, err)
+		return
+	}
+	result, err := identity.ListProvisionedSubjects(request.Context(), handler.database, identity.ListProvisionedSubjectsInput{
+		Filter: filter,
+		Offset: startIndex - 1,
+		Limit:  count,
+	})
+	if err != nil {
+		writeSCIMError(responseWriter, http.StatusInternalServerError, "", "could not list users")
+		return
+	}
+	users := make([]scimUser, 0, len(result.Subjects))
+	for _, subject := range result.Subjects {
+		users = append(users, scimUserFromSubject(subject))
+	}
+	writeSCIM(responseWriter, http.StatusOK, scimListResponse{
+		Schemas:      []string{scimListResponseSchema},
+		TotalResults: result.Total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(users),
+		Resources:    users,
+	})
+}
+
+func (handler Handler) getSCIMUser(responseWriter http.ResponseWriter, request *http.Request) {
+	if _, ok := handler.requireProvisioningClient(responseWriter, request); !ok {
+		return
+	}
+	subject, err := identity.GetProvisionedSubject(request.Context(), handler.database, request.PathValue("subjectID"))
+	if err != nil {
+		writeSCIMProvisioningError(responseWriter, err)
+		return
+	}
+	if request.Header.Get("If-None-Match") == subject.Version {
+		responseWriter.Header().Set("ETag", subject.Version)
+		responseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeSCIMResource(responseWriter, http.StatusOK, subject.Version, scimUserFromSubject(subject))
+}
+
+// createSCIMUser answers 201 with the stored user. Without a password the
+// subject has to use a password reset before its first sign-in.
+func (handler Handler) createSCIMUser(responseWriter http.ResponseWriter, request *http.Request) {
+	clientID, ok := handler.requireProvisioningClient(responseWriter, request)
+	if !ok {
+		return
+	}
+	var user scimUser
+	if err := decodeSCIM(request, responseWriter, &user); err != nil {
+		writeSCIMError(responseWriter, http.StatusBadRequest, "invalidSyntax", "invalid JSON request")
+		return
+	}
+	subject, err := identity.CreateProvisionedSubject(request.Context(), handler.database, clientID, provisionSubjectInput(user), handler.passwordPolicy)
+	if err != nil {
+		writeSCIMProvisioningError(responseWriter, err)
+		return
+	}
+	responseWriter.Header().Set("Location", scimPrefix+"/Users/"+subject.ID)
+	writeSCIMResource(responseWriter, http.StatusCreated, subject.Version, scimUserFromSubject(subject))
+}
+
+func (handler Handler) replaceSCIMUser(responseWriter http.ResponseWriter, request *http.Request) {
+	clientID, ok := handler.requireProvisioningClient(responseWriter, request)
+	if !ok {
+		return
+	}
+	var user scimUser
+	if err := decodeSCIM(request, responseWriter, &user); err != nil {
+		writeSCIMError(responseWriter, http.StatusBadRequest, "invalidSyntax", "invalid JSON request")
+		return
+	}
+	subject, err := identity.ReplaceProvisionedSubject(request.Context(), handler.database, clientID, request.PathValue("subjectID"), scimIfMatch(request), provisionSubjectInput(user))
+	if err != nil {
+		writeSCIMProvisioningError(responseWriter, err)
+		return
+	}
+	writeSCIMResource(responseWriter, http.StatusOK, subject.Version, scimUserFromSubject(subject))
+}
+
+// patchSCIMUser applies the operations to the stored user and replaces it
+// with the result. Without If-Match the version read here is expected, so a
+// concurrent change is reported instead of overwritten.
+func (handler Handler) patchSCIMUser(responseWriter http.ResponseWriter, request *http.Request) {
+	clientID, ok := handler.requireProvisioningClient(responseWriter, request)
+	if !ok {
+		return
+	}
+	var patch scimPatchRequest
+	if err := decodeSCIM(request, responseWriter, &patch); err != nil || len(patch.Operations) == 0 {
+		writeSCIMError(responseWriter, http.StatusBadRequest, "invalidSyntax", "invalid PATCH request")
+		return
+	}
+	current, err := identity.GetProvisionedSubject(request.Context(), handler.database, request.PathValue("subjectID"))
+	if err != nil {
+		writeSCIMProvisioningError(responseWriter, err)
+		return
+	}
+	expectedVersion := scimIfMatch(request)
+	if expectedVersion == "" {
+		expectedVersion = current.Version
+	}
+	user := scimUserFromSubject(current)
+	for _, operation := range patch.Operations {
+		if err := applySCIMUserPatch(&user, operation); err != nil {
+			writeSCIMRequestError(responseWriter, err)
+			return
+		}
+	}
+	subject, err := identity.ReplaceProvisionedSubject(request.Context(), handler.database, clientID, current.ID, expectedVersion, provisionSubjectInput(user))
+	if err != nil {
+		writeSCIMProvisioningError(responseWriter, err)
+		return
+	}
+	writeSCIMResource(responseWriter, http.StatusOK, subject.Version, scimUserFromSubject(subject))
+}
+
+func (handler Handler) listSCIMGroups(responseWriter http.ResponseWriter, request *http.Request) {
+	if _, ok := handler.requireProvisioningClient(responseWriter, request); !ok {
+		return
+	}
+	startIndex, count := scimPagination(request)
+	filter, err := scimListFilter(request, map[string]string{
+		"id":          identity.ProvisioningFilterID,
+		"displayname": identity.ProvisioningFilterDisplayName,
+	})
+	if err != nil {
+		writeSCIMRequestError(responseWriter, err)
+		return
+	}
+	result, err := identity.ListProvisionedRoles(request.Context(), handler.database, identity.ListProvisionedRolesInput{
+		Filter: filter,
+		Offset: startIndex - 1,
+		Limit:  count,
+	})
+	if err != nil {
+		writeSCIMError(responseWriter, http.StatusInternalServerError, "", "could not list groups")
+		return
+	}
+	groups := make([]scimGroup, 0, len(result.Roles))
+	for _, role := range result.Roles {
+		groups = append(groups, scimGroupFromRole(role))
+	}
+	writeSCIM(responseWriter, http.StatusOK, scimListResponse{
+		Schemas:      []string{scimListResponseSchema},
+		TotalResults: result.Total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(groups),
+		Resources:    groups,
+	})
+}
+
+func (handler Handler) getSCIMGroup(responseWriter http.ResponseWriter, request *http.Request) {
+	if _, ok := handler.requireProvisioningClient(responseWriter, request); !ok {
+		return
+	}
+	role, err := identity.GetProvisionedRole(request.Context(), handler.database, request.PathValue("roleID"))
+	if err != nil {
+		writeSCIMProvisioningError(responseWriter, err)
+		return
+	}
+	if request.Header.Get("If-None-Match") == role.Version {
+		responseWriter.Header().Set("ETag", role.Version)
+		responseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeSCIMResource(responseWriter, http.StatusOK, role.Version, scimGroupFromRole(role))
+}
+
+// createSCIMGroup creates an application role whose role_code is the
+// group's displayName.
+func (handler Handler) createSCIMGroup(responseWriter http.ResponseWriter, request *http.Request) {
+	clientID, ok := handler.requireProvisioningClient(responseWriter, request)
+	if !ok {
+		return
+	}
+	var group scimGroup
+	if err := decodeSCIM(request, responseWriter, &group); err != nil {
+		writeSCIMError(responseWriter, http.StatusBadRequest, "invalidSyntax", "invalid JSON request")
+		return
+	}
+	role, err := identity.CreateProvisionedRole(request.Context(), handler.database, clientID, group.DisplayName, scimMemberIDs(group.Members))
+	if err != nil {
+		writeSCIMGroupError(responseWriter, err)
+		return
+	}
+	responseWriter.Header().Set("Location", scimPrefix+"/Groups/"+role.ID)
+	writeSCIMResource(responseWriter, http.StatusCreated, role.Version, scimGroupFromRole(role))
+}
+
+func (handler Handler) replaceSCIMGroup(responseWriter http.ResponseWriter, request *http.Request) {
+	clientID, ok := handler.requireProvisioningClient(responseWriter, request)
+	if !ok {
+		return
+	}
+	var group scimGroup
+	if err := decodeSCIM(request, responseWriter, &group); err != nil {
+		writeSCIMError(responseWriter, http.StatusBadRequest, "invalidSyntax", "invalid JSON request")
+		return
+	}
+	handler.replaceSCIMGroupMembers(responseWriter, request, clientID, scimIfMatch(request), group)
+}
+
+func (handler Handler) patchSCIMGroup(responseWriter http.ResponseWriter, request *http.Request) {
+	clientID, ok := handler.requireProvisioningClient(responseWriter, request)
+	if !ok {
+		return
+	}
+	var patch scimPatchRequest
+	if err := decodeSCIM(request, responseWriter, &patch); err != nil || len(patch.Operations) == 0 {
+		writeSCIMError(responseWriter, http.StatusBadRequest, "invalidSyntax", "invalid PATCH request")
+		return
+	}
+	current, err := identity.GetProvisionedRole(request.Context(), handler.database, request.PathValue("roleID"))
+	if err != nil {
+		writeSCIMGroupError(responseWriter, err)
+		return
+	}
+	expectedVersion := scimIfMatch(request)
+	if expectedVersion == "" {
+		expectedVersion = current.Version
+	}
+	group := scimGroupFromRole(current)
+	for _, operation := range patch.Operations {
+		if err := applySCIMGroupPatch(&group, operation); err != nil {
+			writeSCIMRequestError(responseWriter, err)
+			return
+		}
+	}
+	handler.replaceSCIMGroupMembers(responseWriter, request, clientID, expectedVersion, group)
+}
+
+// replaceSCIMGroupMembers stores the members of group. The displayName is
+// the role_code and cannot change.
+func (handler Handler) replaceSCIMGroupMembers(responseWriter http.ResponseWriter, request *http.Request, clientID string, expectedVersion string, group scimGroup) {
+	roleID := request.PathValue("roleID")
+	current, err := identity.GetProvisionedRole(request.Context(), handler.database, roleID)
+	if err != nil {
+		writeSCIMGroupError(responseWriter, err)
+		return
+	}
+	if group.DisplayName != "" && group.DisplayName != current.RoleCode {
+		writeSCIMError(responseWriter, http.StatusBadRequest, "mutability", "displayName cannot be changed")
+		return
+	}
+	role, err := identity.ReplaceProvisionedRoleMembers(request.Context(), handler.database, clientID, roleID, expectedVersion, scimMemberIDs(group.Members))
+	if err != nil {
+		writeSCIMGroupError(responseWriter, err)
+		return
+	}
+	writeSCIMResource(responseWriter, http.StatusOK, role.Version, scimGroupFromRole(role))
+}
+
+// scimEndpoint answers a SCIM route with a SCIM 503 while OIDC is
+// disabled: the provisioning client authenticates with an OIDC access
+// token.
+func (handler Handler) scimEndpoint(next http.HandlerFunc) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		if handler.oidcProvider == nil {
+			writeSCIMError(responseWriter, http.StatusServiceUnavailable, "", "SCIM provisioning requires OIDC to be enabled")
+			return
+		}
+		next(responseWriter, request)
+	}
+}
+
+// requireProvisioningClient authenticates the machine client behind a
+// bearer token holding identity.ProvisioningScope and returns its client_id.
+func (handler Handler) requireProvisioningClient(responseWriter http.ResponseWriter, request *http.Request) (string, bool) {
+	scheme, accessToken, found := strings.Cut(request.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || accessToken == "" {
+		responseWriter.Header().Set("WWW-Authenticate", `Bearer`)
+		writeSCIMError(responseWriter, http.StatusUnauthorized, "", "bearer access token required")
+		return "", false
+	}
+	token, err := handler.oidcProvider.AuthenticateClientToken(request.Context(), accessToken)
+	if errors.Is(err, oidc.ErrInvalidToken) {
+		responseWriter.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeSCIMError(responseWriter, http.StatusUnauthorized, "", "invalid access token")
+		return "", false
+	}
+	if err != nil {
+		writeSCIMError(responseWriter, http.StatusInternalServerError, "", "could not authorize client")
+		return "", false
+	}
+	if !slices.Contains(token.Scopes, identity.ProvisioningScope) {
+		responseWriter.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+identity.ProvisioningScope+`"`)
+		writeSCIMError(responseWriter, http.StatusForbidden, "", "the access token lacks scope "+identity.ProvisioningScope)
+		return "", false
+	}
+	return token.ClientID, true
+}
+
+func scimUserFromSubject(subject identity.ProvisionedSubject) scimUser {
+	active := scimBool(subject.Active)
+	user := scimUser{
+		Schemas:     []string{scimUserSchema},
+		ID:          subject.ID,
+		ExternalID:  subject.EmployeeNumber,
+		UserName:    subject.Account,
+		Name:        &scimName{Formatted: subject.DisplayName},
+		DisplayName: subject.DisplayName,
+		Active:      &active,
+		Meta: &scimMeta{
+			ResourceType: "User",
+			Created:      subject.CreatedAt,
+			LastModified: subject.UpdatedAt,
+			Location:     scimPrefix + "/Users/" + subject.ID,
+			Version:      subject.Version,
+		},
+	}
+	for index, email := range subject.Emails {
+		user.Emails = append(user.Emails, scimMultiValue{Value: email, Type: "work", Primary: scimBool(index == 0)})
+	}
+	for _, phoneNumber := range subject.PhoneNumbers {
+		user.PhoneNumbers = append(user.PhoneNumbers, scimMultiValue{Value: phoneNumber, Type: "work"})
+	}
+	for _, role := range subject.Roles {
+		user.Groups = append(user.Groups, scimReference{Value: role.ID, Ref: scimPrefix + "/Groups/" + role.ID, Display: role.RoleCode})
+	}
+	return user
+}
+
+// provisionSubjectInput maps a User onto a subject. The display name falls
+// back to name.formatted and then to userName; groups are read-only and an
+// omitted active means enabled.
+func provisionSubjectInput(user scimUser) identity.ProvisionSubjectInput {
+	input := identity.ProvisionSubjectInput{
+		Account:        user.UserName,
+		DisplayName:    user.DisplayName,
+		Active:         user.Active == nil || bool(*user.Active),
+		EmployeeNumber: user.ExternalID,
+		Emails:         []string{},
+		PhoneNumbers:   []string{},
+		Password:       user.Password,
+	}
+	if strings.TrimSpace(input.DisplayName) == "" && user.Name != nil {
+		input.DisplayName = user.Name.Formatted
+	}
+	if strings.TrimSpace(input.DisplayName) == "" {
+		input.DisplayName = user.UserName
+	}
+	for _, email := range user.Emails {
+		input.Emails = append(input.Emails, email.Value)
+	}
+	for _, phoneNumber := range user.PhoneNumbers {
+		input.PhoneNumbers = append(input.PhoneNumbers, phoneNumber.Value)
+	}
+	return input
+}
+
+func scimGroupFromRole(role identity.ProvisionedRole) scimGroup {
+	group := scimGroup{
+		Schemas:     []string{scimGroupSchema},
+		ID:          role.ID,
+		DisplayName: role.RoleCode,
+		Members:     make([]scimReference, 0, len(role.Members)),
+		Meta: &scimMeta{
+			ResourceType: "Group",
+			Created:      role.CreatedAt,
+			LastModified: role.UpdatedAt,
+			Location:     scimPrefix + "/Groups/" + role.ID,
+			Version:      role.Version,
+		},
+	}
+	for _, member := range role.Members {
+		group.Members = append(group.Members, scimReference{Value: member.SubjectID, Ref: scimPrefix + "/Users/" + member.SubjectID, Display: member.DisplayName})
+	}
+	return group
+}
+
+func scimMemberIDs(members []scimReference) []string {
+	memberIDs := make([]string, 0, len(members))
+	for _, member := range members {
+		if !slices.Contains(memberIDs, member.Value) {
+			memberIDs = append(memberIDs, member.Value)
+		}
+	}
+	return memberIDs
+}
+
+// applySCIMUserPatch applies one PATCH operation. Attributes identityd does
+// not store are ignored, as they are on POST and PUT.
+func applySCIMUserPatch(user *scimUser, operation scimPatchOperation) error {
+	op, err := scimPatchOp(operation)
+	if err != nil {
+		return err
+	}
+	if operation.Path == "" {
+		if op == "remove" {
+			return scimRequestError{http.StatusBadRequest, "noTarget", "remove requires a path"}
+		}
+		var attributes map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &attributes); err != nil {
+			return scimRequestError{http.StatusBadRequest, "invalidValue", "a PATCH without path requires an object value"}
+		}
+		for name, value := range attributes {
+			if err := setSCIMUserAttribute(user, op, name, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return setSCIMUserAttribute(user, op, operation.Path, operation.Value)
+}
+
+func setSCIMUserAttribute(user *scimUser, op string, path string, value json.RawMessage) error {
+	target, err := parseSCIMPath(path)
+	if err != nil {
+		return err
+	}
+	simple := target.filter == "" && target.subAttribute == ""
+	switch attribute := strings.ToLower(target.attribute); {
+	case attribute == "active" && simple:
+		if op == "remove" {
+			return scimRequestError{http.StatusBadRequest, "mutability", "active cannot be removed"}
+		}
+		user.Active = new(scimBool)
+		return decodeSCIMValue(value, user.Active)
+	case attribute == "username" && simple:
+		if op == "remove" {
+			return scimRequestError{http.StatusBadRequest, "mutability", "userName cannot be removed"}
+		}
+		return decodeSCIMValue(value, &user.UserName)
+	case attribute == "displayname" && simple:
+		return setSCIMString(&user.DisplayName, op, value)
+	case attribute == "externalid" && simple:
+		return setSCIMString(&user.ExternalID, op, value)
+	case attribute == "password" && simple:
+		return setSCIMString(&user.Password, op, value)
+	case attribute == "name" && target.filter == "":
+		if target.subAttribute == "" {
+			if op == "remove" {
+				user.Name = nil
+				return nil
+			}
+			user.Name = &scimName{}
+			return decodeSCIMValue(value, user.Name)
+		}
+		if user.Name == nil {
+			user.Name = &scimName{}
+		}
+		switch strings.ToLower(target.subAttribute) {
+		case "formatted":
+			return setSCIMString(&user.Name.Formatted, op, value)
+		case "familyname":
+			return setSCIMString(&user.Name.FamilyName, op, value)
+		case "givenname":
+			return setSCIMString(&user.Name.GivenName, op, value)
+		}
+		return nil
+	case attribute == "emails":
+		user.Emails, err = patchSCIMMultiValue(user.Emails, op, target, value)
+		return err
+	case attribute == "phonenumbers":
+		user.PhoneNumbers, err = patchSCIMMultiValue(user.PhoneNumbers, op, target, value)
+		return err
+	case attribute == "groups":
+		return scimRequestError{http.StatusBadRequest, "mutability", "groups are changed through the Groups endpoint"}
+	}
+	return nil
+}
+
+// patchSCIMMultiValue handles emails and phoneNumbers, including the
+// emails[type eq "work"].value form that sets the value of the matching
+// entry, or adds one when none matches.
+func patchSCIMMultiValue(values []scimMultiValue, op string, target scimPath, value json.RawMessage) ([]scimMultiValue, error) {
+	if target.filter == "" {
+		if target.subAttribute != "" {
+			return nil, scimRequestError{http.StatusBadRequest, "invalidPath", "a sub-attribute of a multi-valued attribute requires a filter"}
+		}
+		if op == "remove" {
+			return nil, nil
+		}
+		decoded, err := decodeSCIMMultiValues(value)
+		if err != nil {
+			return nil, err
+		}
+		if op == "add" {
+			return append(values, decoded...), nil
+		}
+		return decoded, nil
+	}
+
+	var matches func(scimMultiValue) bool
+	switch strings.ToLower(target.filter) {
+	case "value":
+		matches = func(item scimMultiValue) bool { return strings.EqualFold(item.Value, target.filterValue) }
+	case "type":
+		matches = func(item scimMultiValue) bool { return strings.EqualFold(item.Type, target.filterValue) }
+	default:
+		return nil, scimRequestError{http.StatusBadRequest, "invalidFilter", "only value and type can be filtered on"}
+	}
+	if op == "remove" {
+		return slices.DeleteFunc(values, matches), nil
+	}
+	if target.subAttribute != "" {
+		if !strings.EqualFold(target.subAttribute, "value") {
+			return values, nil
+		}
+		var newValue string
+		if err := decodeSCIMValue(value, &newValue); err != nil {
+			return nil, err
+		}
+		found := false
+		for index := range values {
+			if matches(values[index]) {
+				values[index].Value = newValue
+				found = true
+			}
+		}
+		if !found {
+			item := scimMultiValue{Value: newValue}
+			if strings.EqualFold(target.filter, "type") {
+				item.Type = target.filterValue
+			}
+			values = append(values, item)
+		}
+		return values, nil
+	}
+	decoded, err := decodeSCIMMultiValues(value)
+	if err != nil {
+		return nil, err
+	}
+	return append(slices.DeleteFunc(values, matches), decoded...), nil
+}
+
+// applySCIMGroupPatch supports displayName and the members add, remove and
+// replace operations, including a remove whose value lists the members.
+func applySCIMGroupPatch(group *scimGroup, operation scimPatchOperation) error {
+	op, err := scimPatchOp(operation)
+	if err != nil {
+		return err
+	}
+	if operation.Path == "" {
+		if op == "remove" {
+			return scimRequestError{http.StatusBadRequest, "noTarget", "remove requires a path"}
+		}
+		var attributes map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &attributes); err != nil {
+			return scimRequestError{http.StatusBadRequest, "invalidValue", "a PATCH without path requires an object value"}
+		}
+		for name, value := range attributes {
+			if err := setSCIMGroupAttribute(group, op, name, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return setSCIMGroupAttribute(group, op, operation.Path, operation.Value)
+}
+
+func setSCIMGroupAttribute(group *scimGroup, op string, path string, value json.RawMessage) error {
+	target, err := parseSCIMPath(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(target.attribute) {
+	case "displayname":
+		if op == "remove" {
+			return scimRequestError{http.StatusBadRequest, "mutability", "displayName cannot be removed"}
+		}
+		return decodeSCIMValue(value, &group.DisplayName)
+	case "members":
+		if target.subAttribute != "" {
+			return scimRequestError{http.StatusBadRequest, "invalidPath", "members sub-attributes cannot be changed"}
+		}
+		if target.filter != "" {
+			if op != "remove" || !strings.EqualFold(target.filter, "value") {
+				return scimRequestError{http.StatusBadRequest, "invalidPath", `only remove supports a members[value eq "..."] filter`}
+			}
+			group.Members = slices.DeleteFunc(group.Members, func(member scimReference) bool { return member.Value == target.filterValue })
+			return nil
+		}
+		if op == "remove" && len(value) == 0 {
+			group.Members = nil
+			return nil
+		}
+		var members []scimReference
+		if err := decodeSCIMValue(value, &members); err != nil {
+			return err
+		}
+		switch op {
+		case "add":
+			group.Members = append(group.Members, members...)
+		case "replace":
+			group.Members = members
+		case "remove":
+			removed := scimMemberIDs(members)
+			group.Members = slices.DeleteFunc(group.Members, func(member scimReference) bool { return slices.Contains(removed, member.Value) })
+		}
+	}
+	return nil
+}
+
+func scimPatchOp(operation scimPatchOperation) (string, error) {
+	op := strings.ToLower(operation.Op)
+	if op != "add" && op != "replace" && op != "remove" {
+		return "", scimRequestError{http.StatusBadRequest, "invalidSyntax", "op must be add, replace or remove"}
+	}
+	if op != "remove" && len(operation.Value) == 0 {
+		return "", scimRequestError{http.StatusBadRequest, "invalidValue", op + " requires a value"}
+	}
+	return op, nil
+}
+
+func parseSCIMPath(path string) (scimPath, error) {
+	match := scimPathPattern.FindStringSubmatch(strings.TrimSpace(path))
+	if match == nil {
+		return scimPath{}, scimRequestError{http.StatusBadRequest, "invalidPath", "invalid attribute path"}
+	}
+	target := scimPath{attribute: match[1], subAttribute: match[3]}
+	if match[2] != "" {
+		filter := scimFilterPattern.FindStringSubmatch(match[2])
+		if filter == nil {
+			return scimPath{}, scimRequestError{http.StatusBadRequest, "invalidFilter", `only "attribute eq value" filters are supported`}
+		}
+		target.filter = filter[1]
+		if err := json.Unmarshal([]byte(filter[2]), &target.filterValue); err != nil {
+			return scimPath{}, scimRequestError{http.StatusBadRequest, "invalidFilter", "invalid filter value"}
+		}
+	}
+	return target, nil
+}
+
+// scimListFilter parses the filter query parameter. Only one "attribute eq
+// value" comparison on an attribute in attributes, matched without regard to
+// case, is supported.
+func scimListFilter(request *http.Request, attributes map[string]string) (*identity.ProvisioningFilter, error) {
+	value := request.URL.Query().Get("filter")
+	if value == "" {
+		return nil, nil
+	}
+	match := scimFilterPattern.FindStringSubmatch(value)
+	if match == nil {
+		return nil, scimRequestError{http.StatusBadRequest, "invalidFilter", `only "attribute eq value" filters are supported`}
+	}
+	attribute, found := attributes[strings.ToLower(match[1])]
+	if !found {
+		return nil, scimRequestError{http.StatusBadRequest, "invalidFilter", "filtering on " + match[1] + " is not supported"}
+	}
+	filter := identity.ProvisioningFilter{Attribute: attribute}
+	if err := json.Unmarshal([]byte(match[2]), &filter.Value); err != nil {
+		return nil, scimRequestError{http.StatusBadRequest, "invalidFilter", "invalid filter value"}
+	}
+	return &filter, nil
+}
+
+// scimPagination reads startIndex and count. As RFC 7644 requires, values
+// out of range are clamped instead of rejected.
+func scimPagination(request *http.Request) (int64, int64) {
+	startIndex, err := strconv.ParseInt(request.URL.Query().Get("startIndex"), 10, 64)
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.ParseInt(request.URL.Query().Get("count"), 10, 64)
+	if err != nil {
+		count = defaultSCIMPageSize
+	}
+	return startIndex, min(max(count, 0), identity.MaximumProvisioningPageSize)
+}
+
+// scimIfMatch returns the version a write is conditional on; "*" and a
+// missing header make it unconditional.
+func scimIfMatch(request *http.Request) string {
+	value := strings.TrimSpace(request.Header.Get("If-Match"))
+	if value == "*" {
+		return ""
+	}
+	return value
+}
+
+func decodeSCIM(request *http.Request, responseWriter http.ResponseWriter, destination any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(responseWriter, request.Body, maximumJSONBodyBytes))
+	if err := decoder.Decode(destination); err != nil {
+		return err
+	}
+	if err := decoder.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return errors.New("request must contain one JSON value")
+	}
+	return nil
+}
+
+func decodeSCIMValue(value json.RawMessage, destination any) error {
+	if err := json.Unmarshal(value, destination); err != nil {
+		return scimRequestError{http.StatusBadRequest, "invalidValue", "invalid attribute value"}
+	}
+	return nil
+}
+
+// decodeSCIMMultiValues accepts an array or a single object.
+func decodeSCIMMultiValues(value json.RawMessage) ([]scimMultiValue, error) {
+	var values []scimMultiValue
+	if err := json.Unmarshal(value, &values); err == nil {
+		return values, nil
+	}
+	var single scimMultiValue
+	if err := decodeSCIMValue(value, &single); err != nil {
+		return nil, err
+	}
+	return []scimMultiValue{single}, nil
+}
+
+func setSCIMString(destination *string, op string, value json.RawMessage) error {
+	if op == "remove" {
+		*destination = ""
+		return nil
+	}
+	return decodeSCIMValue(value, destination)
+}
+
+func writeSCIM(responseWriter http.ResponseWriter, statusCode int, value any) {
+	responseWriter.Header().Set("Content-Type", scimMediaType)
+	responseWriter.WriteHeader(statusCode)
+	_ = json.NewEncoder(responseWriter).Encode(value)
+}
+
+func writeSCIMResource(responseWriter http.ResponseWriter, statusCode int, version string, value any) {
+	responseWriter.Header().Set("ETag", version)
+	writeSCIM(responseWriter, statusCode, value)
+}
+
+func writeSCIMError(responseWriter http.ResponseWriter, statusCode int, scimType string, detail string) {
+	body := map[string]any{
+		"schemas": []string{scimErrorSchema},
+		"status":  strconv.Itoa(statusCode),
+		"detail":  detail,
+	}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	writeSCIM(withoutProblemDetails(responseWriter), statusCode, body)
+}
+
+func writeSCIMRequestError(responseWriter http.ResponseWriter, err error) {
+	var requestError scimRequestError
+	if errors.As(err, &requestError) {
+		writeSCIMError(responseWriter, requestError.status, requestError.scimType, requestError.detail)
+		return
+	}
+	writeSCIMError(responseWriter, http.StatusBadRequest, "invalidValue", err.Error())
+}
+
+func writeSCIMProvisioningError(responseWriter http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, identity.ErrSubjectNotFound):
+		writeSCIMError(responseWriter, http.StatusNotFound, "", "user not found")
+	case errors.Is(err, identity.ErrRoleNotFound):
+		writeSCIMError(responseWriter, http.StatusNotFound, "", "group not found")
+	case errors.Is(err, identity.ErrVersionMismatch):
+		writeSCIMError(responseWriter, http.StatusPreconditionFailed, "", "the resource has changed")
+	case errors.Is(err, identity.ErrIdentifierAlreadyExists), errors.Is(err, identity.ErrRoleAlreadyExists):
+		writeSCIMError(responseWriter, http.StatusConflict, "uniqueness", err.Error())
+	case errors.Is(err, identity.ErrInvalidSubjectInput), errors.Is(err, identity.ErrInvalidRoleInput):
+		writeSCIMError(responseWriter, http.StatusBadRequest, "invalidValue", err.Error())
+	case errors.Is(err, identity.ErrReservedRole):
+		writeSCIMError(responseWriter, http.StatusForbidden, "", err.Error())
+	case errors.Is(err, identity.ErrLastAdministrator), errors.Is(err, identity.ErrSubjectErased):
+		writeSCIMError(responseWriter, http.StatusConflict, "", err.Error())
+	default:
+		writeSCIMError(responseWriter, http.StatusInternalServerError, "", "could not complete the provisioning request")
+	}
+}
+
+// writeSCIMGroupError reports an unknown member as an invalid value rather
+// than as a missing group.
+func writeSCIMGroupError(responseWriter http.ResponseWriter, err error) {
+	if errors.Is(err, identity.ErrSubjectNotFound) {
+		writeSCIMError(responseWriter, http.StatusBadRequest, "invalidValue", "a member is not a known user")
+		return
+	}
+	writeSCIMProvisioningError(responseWriter, err)
+}
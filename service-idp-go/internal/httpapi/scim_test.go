@@ -0,0 +1,189 @@
+package httpapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/httpapi"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/oidc"
+)
+
+func TestSCIMProvisioning(t *testing.T) {
+	databaseConnection, mux, administratorID, _ := oidcTestMux(t)
+	credentials, err := identity.CreateMachineClient(context.Background(), databaseConnection, administratorID, identity.CreateMachineClientInput{
+		ClientID:    "hr-sync",
+		DisplayName: "人事系统同步",
+		Scopes:      []string{identity.ProvisioningScope, identity.SubjectsReadScope},
+	})
+	if err != nil {
+		t.Fatalf("create machine client: %v", err)
+	}
+	token := func(scope string) string {
+		response := clientCredentials(mux, "hr-sync", credentials.ClientSecret, scope)
+		var tokens oidc.TokenResponse
+		if err := json.Unmarshal(response.Body.Bytes(), &tokens); err != nil || response.Code != http.StatusOK {
+			t.Fatalf("token = %d %s", response.Code, response.Body.String())
+		}
+		return tokens.AccessToken
+	}
+	accessToken := token(identity.ProvisioningScope)
+
+	// 缺少令牌或 scope 时返回 SCIM 错误格式，而不是 Problem Details。
+	assertSCIMError(t, scimRequest(mux, http.MethodGet, "/crate-api/identity/v1/scim/v2/Users", "", "", nil), http.StatusUnauthorized, "")
+	assertSCIMError(t, scimRequest(mux, http.MethodGet, "/crate-api/identity/v1/scim/v2/Users", token(identity.SubjectsReadScope), "", nil), http.StatusForbidden, "")
+
+	createResponse := scimRequest(mux, http.MethodPost, "/crate-api/identity/v1/scim/v2/Users", accessToken, `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "zhangsan",
+		"externalId": "E-1001",
+		"name": {"formatted": "张三", "familyName": "张", "givenName": "三"},
+		"active": "True",
+		"emails": [{"value": "zhangsan@example.test", "type": "work", "primary": true}],
+		"phoneNumbers": [{"value": "13800000001", "type": "mobile"}],
+		"title": "工程师"
+	}`, nil)
+	var created map[string]any
+	if err := json.Unmarshal(createResponse.Body.Bytes(), &created); err != nil || createResponse.Code != http.StatusCreated || created["displayName"] != "张三" || created["active"] != true {
+		t.Fatalf("create user = %d %s", createResponse.Code, createResponse.Body.String())
+	}
+	userID := created["id"].(string)
+	userPath := "/crate-api/identity/v1/scim/v2/Users/" + userID
+	createdVersion := createResponse.Header().Get("ETag")
+	if createResponse.Header().Get("Location") != userPath || !strings.HasPrefix(createdVersion, `W/"`) {
+		t.Fatalf("create headers = %v", createResponse.Header())
+	}
+	assertSCIMError(t, scimRequest(mux, http.MethodPost, "/crate-api/identity/v1/scim/v2/Users", accessToken, `{"userName":"ZhangSan"}`, nil), http.StatusConflict, "uniqueness")
+
+	listResponse := scimRequest(mux, http.MethodGet, "/crate-api/identity/v1/scim/v2/Users?filter="+strings.ReplaceAll(`userName eq "zhangsan"`, " ", "%20"), accessToken, "", nil)
+	if listResponse.Code != http.StatusOK || !strings.Contains(listResponse.Body.String(), `"totalResults":1`) || !strings.Contains(listResponse.Body.String(), userID) {
+		t.Fatalf("filtered users = %d %s", listResponse.Code, listResponse.Body.String())
+	}
+	assertSCIMError(t, scimRequest(mux, http.MethodGet, "/crate-api/identity/v1/scim/v2/Users?filter="+strings.ReplaceAll(`title co "x"`, " ", "%20"), accessToken, "", nil), http.StatusBadRequest, "invalidFilter")
+	if response := scimRequest(mux, http.MethodGet, userPath, accessToken, "", map[string]string{"If-None-Match": createdVersion}); response.Code != http.StatusNotModified {
+		t.Fatalf("conditional get = %d %s", response.Code, response.Body.String())
+	}
+
+	patchResponse := scimRequest(mux, http.MethodPatch, userPath, accessToken, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "path": "displayName", "value": "张三丰"},
+			{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "san.zhang@example.test"},
+			{"op": "remove", "path": "phoneNumbers"}
+		]
+	}`, map[string]string{"If-Match": createdVersion})
+	var patched map[string]any
+	if err := json.Unmarshal(patchResponse.Body.Bytes(), &patched); err != nil || patchResponse.Code != http.StatusOK || patched["displayName"] != "张三丰" || patched["phoneNumbers"] != nil || !strings.Contains(patchResponse.Body.String(), "san.zhang@example.test") {
+		t.Fatalf("patch user = %d %s", patchResponse.Code, patchResponse.Body.String())
+	}
+	// 过期的 If-Match 不会覆盖他人的修改。
+	stale := scimRequest(mux, http.MethodPatch, userPath, accessToken, `{"Operations":[{"op":"replace","path":"displayName","value":"张三"}]}`, map[string]string{"If-Match": createdVersion})
+	assertSCIMError(t, stale, http.StatusPreconditionFailed, "")
+	assertSCIMError(t, scimRequest(mux, http.MethodPatch, userPath, accessToken, `{"Operations":[{"op":"replace","path":"userName","value":"lisi"}]}`, nil), http.StatusBadRequest, "invalidValue")
+
+	groupResponse := scimRequest(mux, http.MethodPost, "/crate-api/identity/v1/scim/v2/Groups", accessToken, `{"displayName":"hr.engineering","members":[{"value":"`+userID+`"}]}`, nil)
+	var group map[string]any
+	if err := json.Unmarshal(groupResponse.Body.Bytes(), &group); err != nil || groupResponse.Code != http.StatusCreated || len(group["members"].([]any)) != 1 {
+		t.Fatalf("create group = %d %s", groupResponse.Code, groupResponse.Body.String())
+	}
+	groupPath := "/crate-api/identity/v1/scim/v2/Groups/" + group["id"].(string)
+	if response := scimRequest(mux, http.MethodGet, userPath, accessToken, "", nil); !strings.Contains(response.Body.String(), `"display":"hr.engineering"`) {
+		t.Fatalf("user groups = %s", response.Body.String())
+	}
+	removeResponse := scimRequest(mux, http.MethodPatch, groupPath, accessToken, `{"Operations":[{"op":"remove","path":"members[value eq \"`+userID+`\"]"}]}`, nil)
+	if removeResponse.Code != http.StatusOK || !strings.Contains(removeResponse.Body.String(), `"members":[]`) {
+		t.Fatalf("remove member = %d %s", removeResponse.Code, removeResponse.Body.String())
+	}
+
+	// 停用用户会撤销其会话；identity 命名空间的角色只能由管理员管理。
+	deactivate := scimRequest(mux, http.MethodPatch, userPath, accessToken, `{"Operations":[{"op":"replace","value":{"active":false}}]}`, nil)
+	if deactivate.Code != http.StatusOK || !strings.Contains(deactivate.Body.String(), `"active":false`) {
+		t.Fatalf("deactivate = %d %s", deactivate.Code, deactivate.Body.String())
+	}
+	adminGroups := scimRequest(mux, http.MethodGet, "/crate-api/identity/v1/scim/v2/Groups?filter="+strings.ReplaceAll(`displayName eq "identity.admin"`, " ", "%20"), accessToken, "", nil)
+	var adminList struct {
+		Resources []struct {
+			ID string `json:"id"`
+		}
+	}
+	if err := json.Unmarshal(adminGroups.Body.Bytes(), &adminList); err != nil || len(adminList.Resources) != 1 {
+		t.Fatalf("administrator group = %d %s", adminGroups.Code, adminGroups.Body.String())
+	}
+	assertSCIMError(t, scimRequest(mux, http.MethodPatch, "/crate-api/identity/v1/scim/v2/Groups/"+adminList.Resources[0].ID, accessToken, `{"Operations":[{"op":"add","path":"members","value":[{"value":"`+userID+`"}]}]}`, nil), http.StatusForbidden, "")
+
+	var scimEvents int
+	if err := databaseConnection.QueryRow(`
+		SELECT COUNT(*)
+		FROM identity_audit_events
+		WHERE json_extract(metadata, '$.client_id') = 'hr-sync' AND actor_subject_id IS NULL
+	`).Scan(&scimEvents); err != nil {
+		t.Fatalf("count SCIM audit events: %v", err)
+	}
+	// 创建用户 4 条，PATCH 4 条，角色创建与授予、撤销 3 条，停用 1 条。
+	if scimEvents != 12 {
+		t.Fatalf("SCIM audit events = %d, want 12", scimEvents)
+	}
+}
+
+// 未启用 OIDC 时 SCIM 路由仍然注册，以 SCIM 错误格式返回 503 而不是 404。
+func TestSCIMRequiresOIDC(t *testing.T) {
+	databaseConnection, err := database.OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "identityd.sqlite"))
+	if err != nil {
+		t.Fatalf("open SQLite database: %v", err)
+	}
+	t.Cleanup(func() {
+		databaseConnection.Close()
+	})
+	mux := httpapi.NewMux(databaseConnection, httpapi.Options{
+		SessionSettings: identity.SessionSettings{TTL: time.Hour, IdleTTL: 30 * time.Minute},
+		LoginThrottle:   testLoginThrottle,
+	})
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, "/crate-api/identity/v1/scim/v2/ServiceProviderConfig"},
+		{http.MethodGet, "/crate-api/identity/v1/scim/v2/Users"},
+		{http.MethodPost, "/crate-api/identity/v1/scim/v2/Users"},
+		{http.MethodPatch, "/crate-api/identity/v1/scim/v2/Groups/role-1"},
+	} {
+		assertSCIMError(t, scimRequest(mux, route.method, route.path, "token", "{}", nil), http.StatusServiceUnavailable, "")
+	}
+}
+
+func scimRequest(handler http.Handler, method string, path string, accessToken string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/scim+json")
+	if accessToken != "" {
+		request.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	return response
+}
+
+func assertSCIMError(t *testing.T, response *httptest.ResponseRecorder, wantStatus int, wantSCIMType string) {
+	t.Helper()
+	if response.Code != wantStatus || response.Header().Get("Content-Type") != "application/scim+json" {
+		t.Fatalf("status = %d %q, want %d; body = %s", response.Code, response.Header().Get("Content-Type"), wantStatus, response.Body.String())
+	}
+	var body struct {
+		Schemas  []string `json:"schemas"`
+		Status   string   `json:"status"`
+		SCIMType string   `json:"scimType"`
+	}
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode SCIM error: %v", err)
+	}
+	if len(body.Schemas) != 1 || body.Schemas[0] != "urn:ietf:params:scim:api:messages:2.0:Error" || body.Status != strconv.Itoa(wantStatus) || body.SCIMType != wantSCIMType {
+		t.Fatalf("SCIM error = %#v", body)
+	}
+}
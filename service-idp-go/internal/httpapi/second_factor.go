@@ -0,0 +1,237 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+// secondFactorPage asks a 待二次验证 session for its code, and otherwise
+// walks the subject through TOTP enrollment. A subject whose role requires
+// TOTP but who has not enrolled yet lands here with a 待二次验证 session too.
+func (handler Handler) secondFactorPage(responseWriter http.ResponseWriter, request *http.Request) {
+	session, err := handler.currentSession(request)
+	if err != nil {
+		handler.clearSessionCookies(responseWriter)
+		http.Redirect(responseWriter, request, identityPrefix+"/login", http.StatusSeeOther)
+		return
+	}
+	if session.Access == "仅改密" {
+		http.Redirect(responseWriter, request, identityPrefix+"/password", http.StatusSeeOther)
+		return
+	}
+	status, err := identity.GetSecondFactorStatus(request.Context(), handler.database, session.SubjectID)
+	if err != nil {
+		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not load second factor")
+		return
+	}
+	query := request.URL.Query()
+	handler.renderSecondFactorPage(responseWriter, http.StatusOK, secondFactorPageData{
+		CSRFToken: requestCSRFToken(request),
+		ReturnTo:  loginReturnTo(query.Get("return_to")),
+		Status:    status,
+		Verify:    session.Access == "待二次验证" && status.TOTP == "已启用",
+		Throttled: query.Get("throttled") == "1",
+		HasError:  query.Get("error") == "1",
+	})
+}
+
+// startTOTPEnrollment issues a new secret. The HTML form answers with the
+// secret and the confirmation form directly, because the secret is shown
+// only once.
+func (handler Handler) startTOTPEnrollment(responseWriter http.ResponseWriter, request *http.Request) {
+	jsonRequest := wantsJSON(request)
+	session, ok := handler.requireSecondFactorSession(responseWriter, request, jsonRequest)
+	if !ok {
+		return
+	}
+	returnTo := loginReturnTo(request.PostForm.Get("return_to"))
+	enrollment, err := identity.StartTOTPEnrollment(request.Context(), handler.database, session.SubjectID)
+	if err != nil {
+		if !jsonRequest {
+			http.Redirect(responseWriter, request, secondFactorLocation("error=1", returnTo), http.StatusSeeOther)
+			return
+		}
+		handler.writeSecondFactorError(responseWriter, request, err)
+		return
+	}
+	if jsonRequest {
+		writeJSON(responseWriter, http.StatusCreated, enrollment)
+		return
+	}
+	handler.renderSecondFactorPage(responseWriter, http.StatusOK, secondFactorPageData{
+		CSRFToken:  requestCSRFToken(request),
+		ReturnTo:   returnTo,
+		Status:     identity.SecondFactorStatus{TOTP: "待确认"},
+		Enrollment: &enrollment,
+	})
+}
+
+// confirmTOTPEnrollment enables TOTP and hands out the recovery codes, which
+// cannot be shown again.
+func (handler Handler) confirmTOTPEnrollment(responseWriter http.ResponseWriter, request *http.Request) {
+	jsonRequest := wantsJSON(request)
+	session, ok := handler.requireSecondFactorSession(responseWriter, request, jsonRequest)
+	if !ok {
+		return
+	}
+	returnTo := loginReturnTo(request.PostForm.Get("return_to"))
+	recoveryCodes, access, err := identity.ConfirmTOTPEnrollment(request.Context(), handler.database, session, request.PostForm.Get("code"))
+	if err != nil {
+		if !jsonRequest {
+			http.Redirect(responseWriter, request, secondFactorLocation("error=1", returnTo), http.StatusSeeOther)
+			return
+		}
+		handler.writeSecondFactorError(responseWriter, request, err)
+		return
+	}
+	if jsonRequest {
+		writeJSON(responseWriter, http.StatusOK, map[string]any{
+			"recovery_codes": recoveryCodes,
+			"access":         access,
+		})
+		return
+	}
+	handler.renderSecondFactorPage(responseWriter, http.StatusOK, secondFactorPageData{
+		Status:        identity.SecondFactorStatus{TOTP: "已启用"},
+		RecoveryCodes: recoveryCodes,
+		Continue:      loginDestination(access, returnTo),
+	})
+}
+
+func (handler Handler) verifySecondFactor(responseWriter http.ResponseWriter, request *http.Request) {
+	jsonRequest := wantsJSON(request)
+	session, ok := handler.requireSecondFactorSession(responseWriter, request, jsonRequest)
+	if !ok {
+		return
+	}
+	returnTo := loginReturnTo(request.PostForm.Get("return_to"))
+	access, err := identity.VerifySecondFactor(request.Context(), handler.database, session, handler.loginThrottle, identity.VerifySecondFactorInput{
+		Code:          request.PostForm.Get("code"),
+		SourceAddress: clientSourceAddress(request, handler.trustedProxyPrefixes),
+	})
+	if err != nil {
+		if jsonRequest {
+			handler.writeSecondFactorError(responseWriter, request, err)
+			return
+		}
+		if errors.Is(err, identity.ErrSecondFactorThrottled) {
+			http.Redirect(responseWriter, request, secondFactorLocation("throttled=1", returnTo), http.StatusSeeOther)
+			return
+		}
+		http.Redirect(responseWriter, request, secondFactorLocation("error=1", returnTo), http.StatusSeeOther)
+		return
+	}
+	if jsonRequest {
+		writeJSON(responseWriter, http.StatusOK, map[string]string{"access": access})
+		return
+	}
+	http.Redirect(responseWriter, request, loginDestination(access, returnTo), http.StatusSeeOther)
+}
+
+func (handler Handler) getSubjectSecondFactor(responseWriter http.ResponseWriter, request *http.Request) {
+	subjectID := request.PathValue("subjectID")
+	if _, ok := handler.requireSubjectOrAdministrator(responseWriter, request, subjectID); !ok {
+		return
+	}
+	status, err := identity.GetSecondFactorStatus(request.Context(), handler.database, subjectID)
+	if err != nil {
+		handler.writeSecondFactorError(responseWriter, request, err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, status)
+}
+
+// resetSubjectSecondFactor is the administrator path for a lost device.
+func (handler Handler) resetSubjectSecondFactor(responseWriter http.ResponseWriter, request *http.Request) {
+	session, ok := handler.requireAdministrator(responseWriter, request)
+	if !ok {
+		return
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	if err := identity.ResetSecondFactor(request.Context(), handler.database, session.SubjectID, request.PathValue("subjectID")); err != nil {
+		handler.writeSecondFactorError(responseWriter, request, err)
+		return
+	}
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+// requireSecondFactorSession accepts 完整 and 待二次验证 sessions, since
+// enrollment is how a subject satisfies a role that requires TOTP. A 仅改密
+// session has to change its password first.
+func (handler Handler) requireSecondFactorSession(responseWriter http.ResponseWriter, request *http.Request, jsonRequest bool) (identity.Session, bool) {
+	session, err := handler.currentSession(request)
+	if err != nil {
+		handler.clearSessionCookies(responseWriter)
+		if jsonRequest {
+			writeProblem(responseWriter, request, http.StatusUnauthorized, "not-authenticated", "not authenticated")
+			return identity.Session{}, false
+		}
+		http.Redirect(responseWriter, request, identityPrefix+"/login", http.StatusSeeOther)
+		return identity.Session{}, false
+	}
+	if session.Access == "仅改密" {
+		if jsonRequest {
+			writeRestrictedSessionProblem(responseWriter, request, session)
+			return identity.Session{}, false
+		}
+		http.Redirect(responseWriter, request, identityPrefix+"/password", http.StatusSeeOther)
+		return identity.Session{}, false
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		if jsonRequest {
+			writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+			return identity.Session{}, false
+		}
+		http.Redirect(responseWriter, request, secondFactorLocation("error=1", ""), http.StatusSeeOther)
+		return identity.Session{}, false
+	}
+	if err := request.ParseForm(); err != nil {
+		if jsonRequest {
+			writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "invalid second factor request")
+			return identity.Session{}, false
+		}
+		http.Redirect(responseWriter, request, secondFactorLocation("error=1", ""), http.StatusSeeOther)
+		return identity.Session{}, false
+	}
+	return session, true
+}
+
+func (handler Handler) renderSecondFactorPage(responseWriter http.ResponseWriter, statusCode int, data secondFactorPageData) {
+	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	responseWriter.Header().Set("Cache-Control", "no-store")
+	responseWriter.WriteHeader(statusCode)
+	secondFactorTemplate.Execute(responseWriter, data)
+}
+
+func secondFactorLocation(flag string, returnTo string) string {
+	location := identityPrefix + "/second-factor?" + flag
+	if returnTo != "" {
+		location += "&return_to=" + url.QueryEscape(returnTo)
+	}
+	return location
+}
+
+func (handler Handler) writeSecondFactorError(responseWriter http.ResponseWriter, request *http.Request, err error) {
+	switch {
+	case errors.Is(err, identity.ErrSubjectNotFound):
+		writeProblem(responseWriter, request, http.StatusNotFound, "subject-not-found", "subject not found")
+	case errors.Is(err, identity.ErrInvalidSecondFactorCode):
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-second-factor-code", "second factor code is invalid")
+	case errors.Is(err, identity.ErrSecondFactorThrottled):
+		writeProblem(responseWriter, request, http.StatusTooManyRequests, "second-factor-throttled", "too many second factor attempts")
+	case errors.Is(err, identity.ErrSecondFactorAlreadyEnabled):
+		writeProblem(responseWriter, request, http.StatusConflict, "second-factor-already-enabled", "TOTP is already enabled")
+	case errors.Is(err, identity.ErrSecondFactorNotEnrolled):
+		writeProblem(responseWriter, request, http.StatusConflict, "second-factor-not-enrolled", "TOTP is not enrolled")
+	case errors.Is(err, identity.ErrInvalidSession):
+		writeProblem(responseWriter, request, http.StatusConflict, "second-factor-not-pending", "session is not waiting for a second factor")
+	default:
+		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not manage second factor")
+	}
+}
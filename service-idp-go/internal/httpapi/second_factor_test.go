@@ -0,0 +1,154 @@
+package httpapi_test
+
+import (
+	"context"
+	"encoding/base32"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/db/migrations"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/httpapi"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/totp"
+)
+
+func TestSecondFactorEnrollmentVerificationAndReset(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection, err := database.OpenSQLite(ctx, filepath.Join(t.TempDir(), "identityd.sqlite"))
+	if err != nil {
+		t.Fatalf("open SQLite database: %v", err)
+	}
+	t.Cleanup(func() {
+		databaseConnection.Close()
+	})
+	if _, err := database.Migrate(ctx, databaseConnection, migrations.Files); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	if _, err := identity.EnsureBootstrap(ctx, databaseConnection, identity.BootstrapInput{
+		Identifier: "admin",
+		Password:   "correct horse battery staple",
+	}); err != nil {
+		t.Fatalf("ensure bootstrap: %v", err)
+	}
+	var administratorID string
+	if err := databaseConnection.QueryRow(`
+		SELECT subject_id
+		FROM identity_identifiers
+		WHERE identifier_type = '账号' AND normalized_value = 'admin'
+	`).Scan(&administratorID); err != nil {
+		t.Fatalf("read administrator ID: %v", err)
+	}
+
+	mux := httpapi.NewMux(databaseConnection, httpapi.Options{
+		SessionSettings: identity.SessionSettings{TTL: time.Hour, IdleTTL: 30 * time.Minute},
+		LoginThrottle:   testLoginThrottle,
+	})
+	send := func(method string, path string, sessionCookie *http.Cookie, csrfCookie *http.Cookie, contentType string, body string, accept string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.AddCookie(sessionCookie)
+		request.AddCookie(csrfCookie)
+		request.Header.Set("X-CSRF-Token", csrfCookie.Value)
+		if contentType != "" {
+			request.Header.Set("Content-Type", contentType)
+		}
+		if accept != "" {
+			request.Header.Set("Accept", accept)
+		}
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		return response
+	}
+	const formType = "application/x-www-form-urlencoded"
+
+	adminSession, adminCSRF := loginCookies(t, mux, "admin", "correct horse battery staple")
+	policyResponse := send(http.MethodPatch, "/crate-api/identity/v1/roles/identity.admin", adminSession, adminCSRF, "application/json", `{"second_factor":"必需"}`, "")
+	if policyResponse.Code != http.StatusOK || !strings.Contains(policyResponse.Body.String(), `"second_factor":"必需"`) {
+		t.Fatalf("role policy status = %d, body = %s", policyResponse.Code, policyResponse.Body.String())
+	}
+	invalidPolicyResponse := send(http.MethodPatch, "/crate-api/identity/v1/roles/identity.admin", adminSession, adminCSRF, "application/json", `{"second_factor":"总是"}`, "")
+	assertProblemDetails(t, invalidPolicyResponse, http.StatusBadRequest, "invalid-request", "/crate-api/identity/v1/roles/identity.admin")
+
+	// 角色要求二次验证后，登录只得到待二次验证会话。
+	pendingSession, pendingCSRF := loginCookiesWithRedirect(t, mux, "admin", "correct horse battery staple", "/crate-api/identity/v1/second-factor")
+	restrictedResponse := send(http.MethodGet, "/crate-api/identity/v1/subjects", pendingSession, pendingCSRF, "", "", "")
+	assertProblemDetails(t, restrictedResponse, http.StatusForbidden, "second-factor-required", "/crate-api/identity/v1/subjects")
+	pageResponse := send(http.MethodGet, "/crate-api/identity/v1/second-factor", pendingSession, pendingCSRF, "", "", "text/html")
+	if pageResponse.Code != http.StatusOK || !strings.Contains(pageResponse.Body.String(), "开始设置") {
+		t.Fatalf("second factor page status = %d, body = %s", pageResponse.Code, pageResponse.Body.String())
+	}
+
+	enrollmentResponse := send(http.MethodPost, "/crate-api/identity/v1/second-factor/totp", pendingSession, pendingCSRF, formType, "", "application/json")
+	if enrollmentResponse.Code != http.StatusCreated {
+		t.Fatalf("enrollment status = %d, body = %s", enrollmentResponse.Code, enrollmentResponse.Body.String())
+	}
+	var enrollment identity.TOTPEnrollment
+	if err := json.Unmarshal(enrollmentResponse.Body.Bytes(), &enrollment); err != nil {
+		t.Fatalf("decode enrollment: %v", err)
+	}
+	if !strings.HasPrefix(enrollment.ProvisioningURI, "otpauth://totp/") {
+		t.Fatalf("provisioning URI = %q", enrollment.ProvisioningURI)
+	}
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.Secret)
+	if err != nil {
+		t.Fatalf("decode secret: %v", err)
+	}
+	step := totp.Step(time.Now())
+	wrongCodeResponse := send(http.MethodPost, "/crate-api/identity/v1/second-factor/totp/confirm", pendingSession, pendingCSRF, formType, url.Values{"code": {"abcdef"}}.Encode(), "application/json")
+	assertProblemDetails(t, wrongCodeResponse, http.StatusBadRequest, "invalid-second-factor-code", "/crate-api/identity/v1/second-factor/totp/confirm")
+	confirmResponse := send(http.MethodPost, "/crate-api/identity/v1/second-factor/totp/confirm", pendingSession, pendingCSRF, formType, url.Values{"code": {totp.Code(secret, step)}}.Encode(), "application/json")
+	if confirmResponse.Code != http.StatusOK {
+		t.Fatalf("confirm status = %d, body = %s", confirmResponse.Code, confirmResponse.Body.String())
+	}
+	var confirmed struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+		Access        string   `json:"access"`
+	}
+	if err := json.Unmarshal(confirmResponse.Body.Bytes(), &confirmed); err != nil {
+		t.Fatalf("decode confirmation: %v", err)
+	}
+	if len(confirmed.RecoveryCodes) != 10 || confirmed.Access != "完整" {
+		t.Fatalf("confirmation = %#v", confirmed)
+	}
+	if response := send(http.MethodGet, "/crate-api/identity/v1/subjects", pendingSession, pendingCSRF, "", "", ""); response.Code != http.StatusOK {
+		t.Fatalf("subjects after enrollment status = %d", response.Code)
+	}
+
+	// 表单验证成功后进入登录后的默认页面。
+	verifySession, verifyCSRF := loginCookiesWithRedirect(t, mux, "admin", "correct horse battery staple", "/crate-api/identity/v1/second-factor")
+	wrongVerifyResponse := send(http.MethodPost, "/crate-api/identity/v1/second-factor/verification", verifySession, verifyCSRF, formType, url.Values{"code": {"abcdef"}}.Encode(), "application/json")
+	assertProblemDetails(t, wrongVerifyResponse, http.StatusBadRequest, "invalid-second-factor-code", "/crate-api/identity/v1/second-factor/verification")
+	verifyResponse := send(http.MethodPost, "/crate-api/identity/v1/second-factor/verification", verifySession, verifyCSRF, formType, url.Values{"code": {confirmed.RecoveryCodes[0]}}.Encode(), "")
+	if verifyResponse.Code != http.StatusSeeOther || verifyResponse.Header().Get("Location") != "/crate-api/identity/v1/dashboard" {
+		t.Fatalf("verify status = %d, location = %q", verifyResponse.Code, verifyResponse.Header().Get("Location"))
+	}
+	notPendingResponse := send(http.MethodPost, "/crate-api/identity/v1/second-factor/verification", verifySession, verifyCSRF, formType, url.Values{"code": {confirmed.RecoveryCodes[1]}}.Encode(), "application/json")
+	assertProblemDetails(t, notPendingResponse, http.StatusConflict, "second-factor-not-pending", "/crate-api/identity/v1/second-factor/verification")
+
+	statusResponse := send(http.MethodGet, "/crate-api/identity/v1/subjects/"+administratorID+"/second-factor", verifySession, verifyCSRF, "", "", "")
+	if statusResponse.Code != http.StatusOK {
+		t.Fatalf("status response = %d", statusResponse.Code)
+	}
+	var status identity.SecondFactorStatus
+	if err := json.Unmarshal(statusResponse.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if status.TOTP != "已启用" || !status.Required || status.RecoveryCodesRemaining != 9 {
+		t.Fatalf("status = %#v", status)
+	}
+
+	resetResponse := send(http.MethodDelete, "/crate-api/identity/v1/subjects/"+administratorID+"/second-factor", verifySession, verifyCSRF, "", "", "")
+	if resetResponse.Code != http.StatusNoContent {
+		t.Fatalf("reset status = %d, body = %s", resetResponse.Code, resetResponse.Body.String())
+	}
+	// 重置会撤销该主体的全部会话。
+	revokedResponse := send(http.MethodGet, "/crate-api/identity/v1/subjects/"+administratorID+"/second-factor", verifySession, verifyCSRF, "", "", "")
+	assertProblemDetails(t, revokedResponse, http.StatusUnauthorized, "not-authenticated", "/crate-api/identity/v1/subjects/"+administratorID+"/second-factor")
+	loginCookiesWithRedirect(t, mux, "admin", "correct horse battery staple", "/crate-api/identity/v1/second-factor")
+}
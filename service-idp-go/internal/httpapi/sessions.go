@@ -0,0 +1,130 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+func (handler Handler) listOwnSessions(responseWriter http.ResponseWriter, request *http.Request) {
+	session, ok := handler.requireFullSession(responseWriter, request)
+	if !ok {
+		return
+	}
+	sessions, err := identity.ListSubjectSessions(request.Context(), handler.database, session.SubjectID, session.ID)
+	if err != nil {
+		handler.writeSessionManagementError(responseWriter, request, err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, map[string]any{
+		"records": sessions,
+		"meta":    map[string]int{"total": len(sessions)},
+	})
+}
+
+// revokeOwnSession lets a subject sign out one of its other devices. Revoking
+// the requesting session also clears its cookies.
+func (handler Handler) revokeOwnSession(responseWriter http.ResponseWriter, request *http.Request) {
+	session, ok := handler.requireFullSession(responseWriter, request)
+	if !ok {
+		return
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	sessionID := request.PathValue("sessionID")
+	if err := identity.RevokeOwnSession(request.Context(), handler.database, session.SubjectID, sessionID); err != nil {
+		handler.writeSessionManagementError(responseWriter, request, err)
+		return
+	}
+	if sessionID == session.ID {
+		handler.clearSessionCookies(responseWriter)
+	}
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+func (handler Handler) listSubjectSessions(responseWriter http.ResponseWriter, request *http.Request) {
+	session, ok := handler.requireAdministrator(responseWriter, request)
+	if !ok {
+		return
+	}
+	sessions, err := identity.ListSubjectSessions(request.Context(), handler.database, request.PathValue("subjectID"), session.ID)
+	if err != nil {
+		handler.writeSessionManagementError(responseWriter, request, err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, map[string]any{
+		"records": sessions,
+		"meta":    map[string]int{"total": len(sessions)},
+	})
+}
+
+func (handler Handler) revokeSubjectSessions(responseWriter http.ResponseWriter, request *http.Request) {
+	session, ok := handler.requireAdministrator(responseWriter, request)
+	if !ok {
+		return
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	subjectID := request.PathValue("subjectID")
+	revoked, err := identity.RevokeSubjectSessions(request.Context(), handler.database, session.SubjectID, subjectID)
+	if err != nil {
+		handler.writeSessionManagementError(responseWriter, request, err)
+		return
+	}
+	if subjectID == session.SubjectID {
+		handler.clearSessionCookies(responseWriter)
+	}
+	writeJSON(responseWriter, http.StatusOK, map[string]int64{"revoked": revoked})
+}
+
+func (handler Handler) revokeSubjectSession(responseWriter http.ResponseWriter, request *http.Request) {
+	session, ok := handler.requireAdministrator(responseWriter, request)
+	if !ok {
+		return
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	sessionID := request.PathValue("sessionID")
+	if err := identity.RevokeSubjectSession(request.Context(), handler.database, session.SubjectID, request.PathValue("subjectID"), sessionID); err != nil {
+		handler.writeSessionManagementError(responseWriter, request, err)
+		return
+	}
+	if sessionID == session.ID {
+		handler.clearSessionCookies(responseWriter)
+	}
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+// requireFullSession accepts any subject with a 完整 session; a 仅改密
+// session may only change its password and sign out.
+func (handler Handler) requireFullSession(responseWriter http.ResponseWriter, request *http.Request) (identity.Session, bool) {
+	session, err := handler.currentSession(request)
+	if err != nil {
+		handler.clearSessionCookies(responseWriter)
+		writeProblem(responseWriter, request, http.StatusUnauthorized, "not-authenticated", "not authenticated")
+		return identity.Session{}, false
+	}
+	if session.Access != "完整" {
+		writeRestrictedSessionProblem(responseWriter, request, session)
+		return identity.Session{}, false
+	}
+	return session, true
+}
+
+func (handler Handler) writeSessionManagementError(responseWriter http.ResponseWriter, request *http.Request, err error) {
+	switch {
+	case errors.Is(err, identity.ErrSubjectNotFound):
+		writeProblem(responseWriter, request, http.StatusNotFound, "subject-not-found", "subject not found")
+	case errors.Is(err, identity.ErrSessionNotFound):
+		writeProblem(responseWriter, request, http.StatusNotFound, "session-not-found", "session not found")
+	default:
+		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not manage sessions")
+	}
+}
@@ -0,0 +1,127 @@
+package httpapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/db/migrations"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/httpapi"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+func TestSessionManagementAPI(t *testing.T) {
+	databaseConnection, err := database.OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "identityd.sqlite"))
+	if err != nil {
+		t.Fatalf("open SQLite database: %v", err)
+	}
+	t.Cleanup(func() {
+		databaseConnection.Close()
+	})
+	if _, err := database.Migrate(context.Background(), databaseConnection, migrations.Files); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	if _, err := identity.EnsureBootstrap(context.Background(), databaseConnection, identity.BootstrapInput{
+		Identifier: "admin",
+		Password:   "correct horse battery staple",
+	}); err != nil {
+		t.Fatalf("ensure bootstrap: %v", err)
+	}
+	var administratorID string
+	if err := databaseConnection.QueryRow(`
+		SELECT subject_id
+		FROM identity_identifiers
+		WHERE identifier_type = '账号' AND normalized_value = 'admin'
+	`).Scan(&administratorID); err != nil {
+		t.Fatalf("read administrator ID: %v", err)
+	}
+	created, err := identity.CreateSubject(context.Background(), databaseConnection, administratorID, identity.CreateSubjectInput{
+		DisplayName: "张三",
+		Identifier:  "zhangsan",
+		Password:    "a sufficiently long password",
+	}, identity.PasswordPolicy{})
+	if err != nil {
+		t.Fatalf("create subject: %v", err)
+	}
+
+	mux := httpapi.NewMux(databaseConnection, httpapi.Options{
+		SessionSettings: identity.SessionSettings{TTL: time.Hour, IdleTTL: 30 * time.Minute},
+		LoginThrottle:   testLoginThrottle,
+	})
+	adminSession, adminCSRF := loginCookies(t, mux, "admin", "correct horse battery staple")
+	laptopSession, laptopCSRF := loginCookies(t, mux, "zhangsan", "a sufficiently long password")
+	phoneSession, phoneCSRF := loginCookies(t, mux, "zhangsan", "a sufficiently long password")
+	send := func(method string, path string, session *http.Cookie, csrf *http.Cookie) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, nil)
+		request.AddCookie(session)
+		if csrf != nil {
+			request.AddCookie(csrf)
+			request.Header.Set("X-CSRF-Token", csrf.Value)
+		}
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		return response
+	}
+	var listed struct {
+		Records []identity.ActiveSession `json:"records"`
+		Meta    struct {
+			Total int `json:"total"`
+		} `json:"meta"`
+	}
+
+	ownResponse := send(http.MethodGet, "/crate-api/identity/v1/sessions", laptopSession, nil)
+	if err := json.Unmarshal(ownResponse.Body.Bytes(), &listed); err != nil || ownResponse.Code != http.StatusOK || listed.Meta.Total != 2 {
+		t.Fatalf("own sessions = %d %s", ownResponse.Code, ownResponse.Body.String())
+	}
+	var laptopID, phoneID string
+	for _, session := range listed.Records {
+		if session.Current {
+			laptopID = session.ID
+		} else {
+			phoneID = session.ID
+		}
+	}
+	if laptopID == "" || phoneID == "" {
+		t.Fatalf("own sessions did not mark the current session: %#v", listed.Records)
+	}
+
+	ownPath := "/crate-api/identity/v1/sessions/" + phoneID
+	if response := send(http.MethodDelete, ownPath, laptopSession, nil); response.Code != http.StatusForbidden {
+		t.Fatalf("revoke own session without CSRF status = %d", response.Code)
+	}
+	assertProblemDetails(t, send(http.MethodDelete, ownPath, adminSession, adminCSRF), http.StatusNotFound, "session-not-found", ownPath)
+	if response := send(http.MethodDelete, ownPath, laptopSession, laptopCSRF); response.Code != http.StatusNoContent {
+		t.Fatalf("revoke own session status = %d; body = %s", response.Code, response.Body.String())
+	}
+	if response := send(http.MethodGet, "/crate-api/identity/v1/sessions", phoneSession, phoneCSRF); response.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked phone session status = %d", response.Code)
+	}
+
+	subjectSessionsPath := "/crate-api/identity/v1/subjects/" + created.ID + "/sessions"
+	assertProblemDetails(t, send(http.MethodGet, subjectSessionsPath, laptopSession, nil), http.StatusForbidden, "not-authorized", subjectSessionsPath)
+	adminListResponse := send(http.MethodGet, subjectSessionsPath, adminSession, nil)
+	if err := json.Unmarshal(adminListResponse.Body.Bytes(), &listed); err != nil || adminListResponse.Code != http.StatusOK || listed.Meta.Total != 1 || listed.Records[0].ID != laptopID || listed.Records[0].Current {
+		t.Fatalf("subject sessions = %d %s", adminListResponse.Code, adminListResponse.Body.String())
+	}
+	revokeAllResponse := send(http.MethodDelete, subjectSessionsPath, adminSession, adminCSRF)
+	if revokeAllResponse.Code != http.StatusOK || revokeAllResponse.Body.String() != "{\"revoked\":1}\n" {
+		t.Fatalf("revoke all sessions = %d %s", revokeAllResponse.Code, revokeAllResponse.Body.String())
+	}
+	if response := send(http.MethodGet, "/crate-api/identity/v1/sessions", laptopSession, nil); response.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked laptop session status = %d", response.Code)
+	}
+	missingSessionPath := subjectSessionsPath + "/" + laptopID
+	assertProblemDetails(t, send(http.MethodDelete, missingSessionPath, adminSession, adminCSRF), http.StatusNotFound, "session-not-found", missingSessionPath)
+	missingSubjectPath := "/crate-api/identity/v1/subjects/01HZZZZZZZZZZZZZZZZZZZZZZZ/sessions"
+	assertProblemDetails(t, send(http.MethodGet, missingSubjectPath, adminSession, nil), http.StatusNotFound, "subject-not-found", missingSubjectPath)
+
+	var reason string
+	if err := databaseConnection.QueryRow(`SELECT revoked_reason FROM identity_sessions WHERE id = ?`, laptopID).Scan(&reason); err != nil || reason != "管理员撤销" {
+		t.Fatalf("laptop revoked reason = %q, err = %v", reason, err)
+	}
+}
@@ -0,0 +1,123 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+// maximumSubjectImportBodyBytes leaves room for MaximumSubjectImportRecords
+// records with several identifiers each.
+const maximumSubjectImportBodyBytes = 4 << 20
+
+// subjectImportProblem adds the per-row validation errors to the Problem
+// Details document.
+type subjectImportProblem struct {
+	problemDetails
+	Rows []identity.ImportSubjectRow `json:"rows"`
+}
+
+// importSubjects takes a JSON array or, with Content-Type text/csv, a CSV
+// file. With dry_run=true it answers 200 with the per-row report; otherwise
+// it answers 201 once every row is created, or 422 with the report and
+// nothing written.
+func (handler Handler) importSubjects(responseWriter http.ResponseWriter, request *http.Request) {
+	session, ok := handler.requireAdministrator(responseWriter, request)
+	if !ok {
+		return
+	}
+	if !requestHasValidCSRFToken(request, session) {
+		writeProblem(responseWriter, request, http.StatusForbidden, "invalid-csrf-token", "invalid CSRF token")
+		return
+	}
+	dryRun := false
+	if value := request.URL.Query().Get("dry_run"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "dry_run must be true or false")
+			return
+		}
+		dryRun = parsed
+	}
+	format := "json"
+	mediaType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+	case "text/csv":
+		format = "csv"
+	default:
+		writeProblem(responseWriter, request, http.StatusUnsupportedMediaType, "unsupported-media-type", "import must be application/json or text/csv")
+		return
+	}
+
+	records, err := identity.DecodeSubjectImport(format, http.MaxBytesReader(responseWriter, request.Body, maximumSubjectImportBodyBytes))
+	if err != nil {
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", err.Error())
+		return
+	}
+	result, err := identity.ImportSubjects(request.Context(), handler.database, session.SubjectID, identity.ImportSubjectsInput{
+		Records: records,
+		DryRun:  dryRun,
+	}, handler.passwordPolicy)
+	switch {
+	case errors.Is(err, identity.ErrInvalidSubjectImport) && len(result.Rows) > 0:
+		responseWriter.Header().Set("Content-Type", problemMediaType)
+		responseWriter.WriteHeader(http.StatusUnprocessableEntity)
+		_ = json.NewEncoder(responseWriter).Encode(subjectImportProblem{
+			problemDetails: problemDetails{
+				Type:     problemTypePrefix + "invalid-subject-import",
+				Title:    http.StatusText(http.StatusUnprocessableEntity),
+				Status:   http.StatusUnprocessableEntity,
+				Detail:   "one or more rows are invalid; nothing was imported",
+				Instance: request.URL.RequestURI(),
+			},
+			Rows: result.Rows,
+		})
+	case errors.Is(err, identity.ErrInvalidSubjectImport):
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", err.Error())
+	case err != nil:
+		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not import subjects")
+	case dryRun:
+		writeJSON(responseWriter, http.StatusOK, result)
+	default:
+		responseWriter.Header().Set("Cache-Control", "no-store")
+		writeJSON(responseWriter, http.StatusCreated, result)
+	}
+}
+
+// exportSubjects downloads every subject with its identifiers and roles as
+// format=json (the default) or format=csv. Password hashes and second
+// factor secrets are never part of it.
+func (handler Handler) exportSubjects(responseWriter http.ResponseWriter, request *http.Request) {
+	if _, ok := handler.requireAdministrator(responseWriter, request); !ok {
+		return
+	}
+	format := request.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if !slices.Contains(identity.SubjectTransferFormats, format) {
+		writeProblem(responseWriter, request, http.StatusBadRequest, "invalid-request", "format must be json or csv")
+		return
+	}
+	subjects, err := identity.ExportSubjects(request.Context(), handler.database)
+	if err != nil {
+		writeProblem(responseWriter, request, http.StatusInternalServerError, "internal-error", "could not export subjects")
+		return
+	}
+
+	contentType := "application/json; charset=utf-8"
+	if format == "csv" {
+		contentType = "text/csv; charset=utf-8"
+	}
+	responseWriter.Header().Set("Content-Type", contentType)
+	responseWriter.Header().Set("Content-Disposition", `attachment; filename="identity-subjects.`+format+`"`)
+	responseWriter.Header().Set("Cache-Control", "no-store")
+	responseWriter.WriteHeader(http.StatusOK)
+	_ = identity.EncodeSubjectExport(format, responseWriter, subjects)
+}
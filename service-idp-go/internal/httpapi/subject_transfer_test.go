@@ -0,0 +1,109 @@
+package httpapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/db/migrations"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/httpapi"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+func TestSubjectImportAndExportAPI(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection, err := database.OpenSQLite(ctx, filepath.Join(t.TempDir(), "identityd.sqlite"))
+	if err != nil {
+		t.Fatalf("open SQLite database: %v", err)
+	}
+	t.Cleanup(func() {
+		databaseConnection.Close()
+	})
+	if _, err := database.Migrate(ctx, databaseConnection, migrations.Files); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	if _, err := identity.EnsureBootstrap(ctx, databaseConnection, identity.BootstrapInput{
+		Identifier: "admin",
+		Password:   "correct horse battery staple",
+	}); err != nil {
+		t.Fatalf("ensure bootstrap: %v", err)
+	}
+	mux := httpapi.NewMux(databaseConnection, httpapi.Options{
+		SessionSettings: identity.SessionSettings{TTL: time.Hour, IdleTTL: 30 * time.Minute},
+		LoginThrottle:   testLoginThrottle,
+	})
+	sessionCookie, csrfCookie := loginCookies(t, mux, "admin", "correct horse battery staple")
+	send := func(method string, path string, contentType string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.AddCookie(sessionCookie)
+		request.AddCookie(csrfCookie)
+		request.Header.Set("X-CSRF-Token", csrfCookie.Value)
+		if contentType != "" {
+			request.Header.Set("Content-Type", contentType)
+		}
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		return response
+	}
+	const importPath = "/crate-api/identity/v1/subjects/import"
+	const validCSV = "display_name,account,email,roles,password\n" +
+		"张三,zhangsan,zhangsan@example.test,identity.audit.read,generate\n"
+
+	unsupportedResponse := send(http.MethodPost, importPath, "text/plain", validCSV)
+	assertProblemDetails(t, unsupportedResponse, http.StatusUnsupportedMediaType, "unsupported-media-type", importPath)
+
+	dryRunResponse := send(http.MethodPost, importPath+"?dry_run=true", "text/csv", validCSV)
+	if dryRunResponse.Code != http.StatusOK {
+		t.Fatalf("dry run status = %d, body = %s", dryRunResponse.Code, dryRunResponse.Body.String())
+	}
+	var dryRun identity.ImportSubjectsResult
+	if err := json.Unmarshal(dryRunResponse.Body.Bytes(), &dryRun); err != nil {
+		t.Fatalf("decode dry run: %v", err)
+	}
+	if !dryRun.DryRun || !dryRun.Valid || len(dryRun.Rows) != 1 || dryRun.Rows[0].Row != 2 {
+		t.Fatalf("dry run = %#v", dryRun)
+	}
+
+	// 任一行无效时整批拒绝，并在 Problem Details 中列出各行错误。
+	invalidBody := `[{"display_name":"李四","identifiers":[{"identifier_type":"账号","identifier_value":"admin","identifier_usage":"主登录"}],"password":"generate"}]`
+	invalidResponse := send(http.MethodPost, importPath, "application/json", invalidBody)
+	assertProblemDetails(t, invalidResponse, http.StatusUnprocessableEntity, "invalid-subject-import", importPath)
+	var problem struct {
+		Rows []identity.ImportSubjectRow `json:"rows"`
+	}
+	if err := json.Unmarshal(invalidResponse.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("decode import problem: %v", err)
+	}
+	if len(problem.Rows) != 1 || len(problem.Rows[0].Errors) != 1 || !strings.Contains(problem.Rows[0].Errors[0], "already in use") {
+		t.Fatalf("import problem rows = %#v", problem.Rows)
+	}
+
+	importResponse := send(http.MethodPost, importPath, "text/csv", validCSV)
+	if importResponse.Code != http.StatusCreated || importResponse.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("import status = %d, body = %s", importResponse.Code, importResponse.Body.String())
+	}
+	var imported identity.ImportSubjectsResult
+	if err := json.Unmarshal(importResponse.Body.Bytes(), &imported); err != nil {
+		t.Fatalf("decode import: %v", err)
+	}
+	if imported.Rows[0].SubjectID == "" || imported.Rows[0].GeneratedPassword == "" {
+		t.Fatalf("import = %#v", imported)
+	}
+	loginCookiesWithRedirect(t, mux, "zhangsan", imported.Rows[0].GeneratedPassword, "/crate-api/identity/v1/password")
+
+	exportResponse := send(http.MethodGet, "/crate-api/identity/v1/subjects/export?format=csv", "", "")
+	if exportResponse.Code != http.StatusOK || !strings.HasPrefix(exportResponse.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("export status = %d, content type = %q", exportResponse.Code, exportResponse.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(exportResponse.Body.String(), "zhangsan@example.test") || strings.Contains(exportResponse.Body.String(), "argon2id") {
+		t.Fatalf("export body = %s", exportResponse.Body.String())
+	}
+	invalidFormatResponse := send(http.MethodGet, "/crate-api/identity/v1/subjects/export?format=xml", "", "")
+	assertProblemDetails(t, invalidFormatResponse, http.StatusBadRequest, "invalid-request", "/crate-api/identity/v1/subjects/export?format=xml")
+}
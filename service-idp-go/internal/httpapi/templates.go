@@ -8,6 +8,7 @@ import (
 
 type loginPageData struct {
 	HasError bool
+	ReturnTo string
 }
 
 type passwordPageData struct {
@@ -16,6 +17,25 @@ type passwordPageData struct {
 	HasError         bool
 }
 
+type passwordResetPageData struct {
+	Token     string
+	Sent      bool
+	Throttled bool
+	HasError  bool
+}
+
+type secondFactorPageData struct {
+	CSRFToken     string
+	ReturnTo      string
+	Status        identity.SecondFactorStatus
+	Verify        bool
+	Enrollment    *identity.TOTPEnrollment
+	RecoveryCodes []string
+	Continue      string
+	Throttled     bool
+	HasError      bool
+}
+
 type dashboardPageData struct {
 	SubjectID string
 	CSRFToken string
@@ -33,20 +53,55 @@ type subjectRowData struct {
 	Subject   identity.Subject
 }
 
+type auditEventsPageData struct {
+	Filters   auditEventFilterData
+	Actions   []string
+	Rows      auditEventRowsData
+	CSVURL    string
+	NDJSONURL string
+	HasError  bool
+}
+
+type auditEventFilterData struct {
+	EventAction     string
+	Outcome         string
+	ActorSubjectID  string
+	TargetSubjectID string
+	From            string
+	Until           string
+}
+
+type auditEventRowsData struct {
+	Events  []identity.AuditEvent
+	NextURL string
+}
+
 var loginTemplate = template.Must(template.New("login").Parse(`<!doctype html>
 <html lang="zh-CN"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>登录 · identityd</title><link rel="stylesheet" href="/crate-api/identity/v1/assets/app.css"><script src="/crate-api/identity/v1/assets/htmx.min.js" defer></script></head>
-<body class="min-h-screen bg-slate-950 text-slate-100"><main class="mx-auto flex min-h-screen max-w-md items-center px-6"><section class="w-full rounded-2xl border border-slate-700 bg-slate-900 p-8 shadow-2xl shadow-slate-950/40"><p class="text-sm font-semibold tracking-[0.2em] text-cyan-300">IDENTITYD</p><h1 class="mt-3 text-3xl font-bold tracking-tight">本地身份控制台</h1><p class="mt-3 text-sm leading-6 text-slate-400">使用管理员账号登录以管理本部署的身份主体。</p>{{if .HasError}}<p class="mt-5 rounded-lg border border-rose-400/40 bg-rose-500/10 px-4 py-3 text-sm text-rose-200">账号标识或密码错误。</p>{{end}}<form class="mt-7 space-y-5" method="post" action="/crate-api/identity/v1/sessions"><label class="block text-sm font-medium text-slate-200">账号标识<input class="mt-2 block w-full rounded-lg border border-slate-600 bg-slate-950 px-3 py-2.5 text-base outline-none transition focus:border-cyan-400 focus:ring-2 focus:ring-cyan-400/30" name="identifier" autocomplete="username" required></label><label class="block text-sm font-medium text-slate-200">密码<input class="mt-2 block w-full rounded-lg border border-slate-600 bg-slate-950 px-3 py-2.5 text-base outline-none transition focus:border-cyan-400 focus:ring-2 focus:ring-cyan-400/30" type="password" name="password" autocomplete="current-password" required></label><button class="w-full rounded-lg bg-cyan-400 px-4 py-2.5 font-semibold text-slate-950 transition hover:bg-cyan-300 focus:outline-none focus:ring-2 focus:ring-cyan-300 focus:ring-offset-2 focus:ring-offset-slate-900">登录</button></form></section></main></body></html>`))
+<body class="min-h-screen bg-slate-950 text-slate-100"><main class="mx-auto flex min-h-screen max-w-md items-center px-6"><section class="w-full rounded-2xl border border-slate-700 bg-slate-900 p-8 shadow-2xl shadow-slate-950/40"><p class="text-sm font-semibold tracking-[0.2em] text-cyan-300">IDENTITYD</p><h1 class="mt-3 text-3xl font-bold tracking-tight">本地身份控制台</h1><p class="mt-3 text-sm leading-6 text-slate-400">使用管理员账号登录以管理本部署的身份主体。</p>{{if .HasError}}<p class="mt-5 rounded-lg border border-rose-400/40 bg-rose-500/10 px-4 py-3 text-sm text-rose-200">账号标识或密码错误。</p>{{end}}<form class="mt-7 space-y-5" method="post" action="/crate-api/identity/v1/sessions">{{if .ReturnTo}}<input type="hidden" name="return_to" value="{{.ReturnTo}}">{{end}}<label class="block text-sm font-medium text-slate-200">账号标识<input class="mt-2 block w-full rounded-lg border border-slate-600 bg-slate-950 px-3 py-2.5 text-base outline-none transition focus:border-cyan-400 focus:ring-2 focus:ring-cyan-400/30" name="identifier" autocomplete="username" required></label><label class="block text-sm font-medium text-slate-200">密码<input class="mt-2 block w-full rounded-lg border border-slate-600 bg-slate-950 px-3 py-2.5 text-base outline-none transition focus:border-cyan-400 focus:ring-2 focus:ring-cyan-400/30" type="password" name="password" autocomplete="current-password" required></label><button class="w-full rounded-lg bg-cyan-400 px-4 py-2.5 font-semibold text-slate-950 transition hover:bg-cyan-300 focus:outline-none focus:ring-2 focus:ring-cyan-300 focus:ring-offset-2 focus:ring-offset-slate-900">登录</button></form><p class="mt-5 text-center text-sm"><a class="text-cyan-300 hover:text-cyan-200" href="/crate-api/identity/v1/password-reset">忘记密码？</a></p></section></main></body></html>`))
 
 var passwordTemplate = template.Must(template.New("password").Parse(`<!doctype html>
 <html lang="zh-CN"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>修改密码 · identityd</title><link rel="stylesheet" href="/crate-api/identity/v1/assets/app.css"><script src="/crate-api/identity/v1/assets/htmx.min.js" defer></script></head>
 <body class="min-h-screen bg-slate-950 text-slate-100"><main class="mx-auto flex min-h-screen max-w-md items-center px-6"><section class="w-full rounded-lg border border-slate-700 bg-slate-900 p-8 shadow-xl shadow-slate-950/40"><p class="text-sm font-semibold tracking-[0.2em] text-cyan-300">IDENTITYD</p><h1 class="mt-3 text-3xl font-bold">修改密码</h1>{{if .PasswordRequired}}<p class="mt-3 text-sm leading-6 text-slate-300">请先设置新密码。</p>{{end}}{{if .HasError}}<p class="mt-5 rounded-lg border border-rose-400/40 bg-rose-500/10 px-4 py-3 text-sm text-rose-200">密码修改失败。</p>{{end}}<form class="mt-7 space-y-5" method="post" action="/crate-api/identity/v1/password" hx-patch="/crate-api/identity/v1/password"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><label class="block text-sm font-medium text-slate-200">当前密码<input class="mt-2 block w-full rounded-lg border border-slate-600 bg-slate-950 px-3 py-2.5 text-base outline-none transition focus:border-cyan-400 focus:ring-2 focus:ring-cyan-400/30" type="password" name="current_password" autocomplete="current-password" required></label><label class="block text-sm font-medium text-slate-200">新密码<input class="mt-2 block w-full rounded-lg border border-slate-600 bg-slate-950 px-3 py-2.5 text-base outline-none transition focus:border-cyan-400 focus:ring-2 focus:ring-cyan-400/30" type="password" name="new_password" minlength="12" autocomplete="new-password" required></label><button class="w-full rounded-lg bg-cyan-400 px-4 py-2.5 font-semibold text-slate-950 transition hover:bg-cyan-300 focus:outline-none focus:ring-2 focus:ring-cyan-300 focus:ring-offset-2 focus:ring-offset-slate-900">保存新密码</button></form></section></main></body></html>`))
 
+var passwordResetTemplate = template.Must(template.New("password-reset").Parse(`<!doctype html>
+<html lang="zh-CN"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>重置密码 · identityd</title><link rel="stylesheet" href="/crate-api/identity/v1/assets/app.css"></head>
+<body class="min-h-screen bg-slate-950 text-slate-100"><main class="mx-auto flex min-h-screen max-w-md items-center px-6"><section class="w-full rounded-lg border border-slate-700 bg-slate-900 p-8 shadow-xl shadow-slate-950/40"><p class="text-sm font-semibold tracking-[0.2em] text-cyan-300">IDENTITYD</p><h1 class="mt-3 text-3xl font-bold">重置密码</h1>{{if .Token}}<p class="mt-3 text-sm leading-6 text-slate-300">设置新密码后，该账号的所有会话都将失效。</p>{{if .HasError}}<p class="mt-5 rounded-lg border border-rose-400/40 bg-rose-500/10 px-4 py-3 text-sm text-rose-200">重置链接无效或已过期，或新密码不符合要求。</p>{{end}}<form class="mt-7 space-y-5" method="post" action="/crate-api/identity/v1/password-resets/confirm"><input type="hidden" name="token" value="{{.Token}}"><label class="block text-sm font-medium text-slate-200">新密码<input class="mt-2 block w-full rounded-lg border border-slate-600 bg-slate-950 px-3 py-2.5 text-base outline-none transition focus:border-cyan-400 focus:ring-2 focus:ring-cyan-400/30" type="password" name="new_password" minlength="12" autocomplete="new-password" required></label><button class="w-full rounded-lg bg-cyan-400 px-4 py-2.5 font-semibold text-slate-950 transition hover:bg-cyan-300 focus:outline-none focus:ring-2 focus:ring-cyan-300 focus:ring-offset-2 focus:ring-offset-slate-900">保存新密码</button></form>{{else}}<p class="mt-3 text-sm leading-6 text-slate-300">输入已验证的邮箱地址，我们会向其发送重置链接。</p>{{if .Sent}}<p class="mt-5 rounded-lg border border-emerald-400/40 bg-emerald-500/10 px-4 py-3 text-sm text-emerald-200">如果该邮箱属于某个账号，重置链接已发送，15 分钟内有效。</p>{{end}}{{if .Throttled}}<p class="mt-5 rounded-lg border border-rose-400/40 bg-rose-500/10 px-4 py-3 text-sm text-rose-200">请求过于频繁，请稍后再试。</p>{{end}}{{if .HasError}}<p class="mt-5 rounded-lg border border-rose-400/40 bg-rose-500/10 px-4 py-3 text-sm text-rose-200">暂时无法发送重置链接。</p>{{end}}<form class="mt-7 space-y-5" method="post" action="/crate-api/identity/v1/password-resets"><label class="block text-sm font-medium text-slate-200">邮箱<input class="mt-2 block w-full rounded-lg border border-slate-600 bg-slate-950 px-3 py-2.5 text-base outline-none transition focus:border-cyan-400 focus:ring-2 focus:ring-cyan-400/30" type="email" name="email" autocomplete="email" required></label><button class="w-full rounded-lg bg-cyan-400 px-4 py-2.5 font-semibold text-slate-950 transition hover:bg-cyan-300 focus:outline-none focus:ring-2 focus:ring-cyan-300 focus:ring-offset-2 focus:ring-offset-slate-900">发送重置链接</button></form>{{end}}<p class="mt-5 text-center text-sm"><a class="text-cyan-300 hover:text-cyan-200" href="/crate-api/identity/v1/login">返回登录</a></p></section></main></body></html>`))
+
+var secondFactorTemplate = template.Must(template.New("second-factor").Parse(`<!doctype html>
+<html lang="zh-CN"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>两步验证 · identityd</title><link rel="stylesheet" href="/crate-api/identity/v1/assets/app.css"></head>
+<body class="min-h-screen bg-slate-950 text-slate-100"><main class="mx-auto flex min-h-screen max-w-md items-center px-6"><section class="w-full rounded-lg border border-slate-700 bg-slate-900 p-8 shadow-xl shadow-slate-950/40"><p class="text-sm font-semibold tracking-[0.2em] text-cyan-300">IDENTITYD</p><h1 class="mt-3 text-3xl font-bold">两步验证</h1>{{if .RecoveryCodes}}<p class="mt-3 text-sm leading-6 text-slate-300">两步验证已启用。请妥善保存以下恢复码，每个只能使用一次，且不会再次显示。</p><ul class="mt-5 grid grid-cols-2 gap-2 rounded-lg border border-slate-700 bg-slate-950 p-4 font-mono text-sm text-slate-100">{{range .RecoveryCodes}}<li>{{.}}</li>{{end}}</ul><a class="mt-7 block text-center w-full rounded-lg bg-cyan-400 px-4 py-2.5 font-semibold text-slate-950 transition hover:bg-cyan-300 focus:outline-none focus:ring-2 focus:ring-cyan-300 focus:ring-offset-2 focus:ring-offset-slate-900" href="{{.Continue}}">我已保存，继续</a>{{else if .Enrollment}}<p class="mt-3 text-sm leading-6 text-slate-300">在验证器应用中手动输入密钥，或将下方的 otpauth 地址生成二维码后扫描，然后填写应用显示的六位验证码。</p><p class="mt-5 break-all rounded-lg border border-slate-700 bg-slate-950 px-4 py-3 font-mono text-sm text-slate-100">{{.Enrollment.Secret}}</p><p class="mt-3 break-all font-mono text-xs text-slate-400">{{.Enrollment.ProvisioningURI}}</p><form class="mt-7 space-y-5" method="post" action="/crate-api/identity/v1/second-factor/totp/confirm"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><input type="hidden" name="return_to" value="{{.ReturnTo}}"><label class="block text-sm font-medium text-slate-200">验证码<input class="mt-2 block w-full rounded-lg border border-slate-600 bg-slate-950 px-3 py-2.5 text-base outline-none transition focus:border-cyan-400 focus:ring-2 focus:ring-cyan-400/30 font-mono tracking-widest" type="text" name="code" inputmode="numeric" autocomplete="one-time-code" required></label><button class="w-full rounded-lg bg-cyan-400 px-4 py-2.5 font-semibold text-slate-950 transition hover:bg-cyan-300 focus:outline-none focus:ring-2 focus:ring-cyan-300 focus:ring-offset-2 focus:ring-offset-slate-900">启用两步验证</button></form>{{else if .Verify}}<p class="mt-3 text-sm leading-6 text-slate-300">输入验证器应用中的六位验证码，或一个未使用的恢复码。</p>{{if .Throttled}}<p class="mt-5 rounded-lg border border-rose-400/40 bg-rose-500/10 px-4 py-3 text-sm text-rose-200">尝试次数过多，请稍后再试。</p>{{end}}{{if .HasError}}<p class="mt-5 rounded-lg border border-rose-400/40 bg-rose-500/10 px-4 py-3 text-sm text-rose-200">验证码不正确或已使用。</p>{{end}}<form class="mt-7 space-y-5" method="post" action="/crate-api/identity/v1/second-factor/verification"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><input type="hidden" name="return_to" value="{{.ReturnTo}}"><label class="block text-sm font-medium text-slate-200">验证码<input class="mt-2 block w-full rounded-lg border border-slate-600 bg-slate-950 px-3 py-2.5 text-base outline-none transition focus:border-cyan-400 focus:ring-2 focus:ring-cyan-400/30 font-mono tracking-widest" type="text" name="code" autocomplete="one-time-code" autofocus required></label><button class="w-full rounded-lg bg-cyan-400 px-4 py-2.5 font-semibold text-slate-950 transition hover:bg-cyan-300 focus:outline-none focus:ring-2 focus:ring-cyan-300 focus:ring-offset-2 focus:ring-offset-slate-900">验证</button></form>{{else if eq .Status.TOTP "已启用"}}<p class="mt-5 rounded-lg border border-emerald-400/40 bg-emerald-500/10 px-4 py-3 text-sm text-emerald-200">两步验证已启用，剩余 {{.Status.RecoveryCodesRemaining}} 个恢复码。</p><p class="mt-3 text-sm leading-6 text-slate-300">更换设备或恢复码用尽时，请联系管理员重置。</p>{{else}}<p class="mt-3 text-sm leading-6 text-slate-300">{{if .Status.Required}}你的角色要求启用两步验证，完成设置后才能继续。{{else}}启用后，登录时除密码外还需要验证器应用中的验证码。{{end}}</p>{{if .HasError}}<p class="mt-5 rounded-lg border border-rose-400/40 bg-rose-500/10 px-4 py-3 text-sm text-rose-200">验证码不正确，请重试。</p>{{end}}{{if eq .Status.TOTP "待确认"}}<form class="mt-7 space-y-5" method="post" action="/crate-api/identity/v1/second-factor/totp/confirm"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><input type="hidden" name="return_to" value="{{.ReturnTo}}"><label class="block text-sm font-medium text-slate-200">验证码<input class="mt-2 block w-full rounded-lg border border-slate-600 bg-slate-950 px-3 py-2.5 text-base outline-none transition focus:border-cyan-400 focus:ring-2 focus:ring-cyan-400/30 font-mono tracking-widest" type="text" name="code" inputmode="numeric" autocomplete="one-time-code" required></label><button class="w-full rounded-lg bg-cyan-400 px-4 py-2.5 font-semibold text-slate-950 transition hover:bg-cyan-300 focus:outline-none focus:ring-2 focus:ring-cyan-300 focus:ring-offset-2 focus:ring-offset-slate-900">启用两步验证</button></form>{{end}}<form class="mt-5" method="post" action="/crate-api/identity/v1/second-factor/totp"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><input type="hidden" name="return_to" value="{{.ReturnTo}}"><button class="w-full rounded-lg bg-cyan-400 px-4 py-2.5 font-semibold text-slate-950 transition hover:bg-cyan-300 focus:outline-none focus:ring-2 focus:ring-cyan-300 focus:ring-offset-2 focus:ring-offset-slate-900">{{if eq .Status.TOTP "待确认"}}重新生成密钥{{else}}开始设置{{end}}</button></form>{{end}}</section></main></body></html>`))
+
 var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!doctype html>
 <html lang="zh-CN"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>控制台 · identityd</title><link rel="stylesheet" href="/crate-api/identity/v1/assets/app.css"><script src="/crate-api/identity/v1/assets/htmx.min.js" defer></script></head>
-<body class="min-h-screen bg-slate-100 text-slate-900"><header class="border-b border-slate-200 bg-white"><div class="mx-auto flex max-w-6xl items-center justify-between px-6 py-4"><a class="font-bold tracking-tight text-slate-950" href="/crate-api/identity/v1/dashboard">identityd</a><nav class="flex items-center gap-4 text-sm"><a class="font-medium text-slate-600 hover:text-cyan-700" href="/crate-api/identity/v1/subjects">主体管理</a><a class="font-medium text-slate-600 hover:text-cyan-700" href="/crate-api/identity/v1/password">修改密码</a><button class="rounded-md border border-slate-300 px-3 py-1.5 font-medium text-slate-700 hover:bg-slate-100" hx-delete="/crate-api/identity/v1/sessions/current" hx-headers='{"X-CSRF-Token":"{{.CSRFToken}}"}' hx-on::after-request="if(event.detail.successful) window.location='/crate-api/identity/v1/login'">退出登录</button></nav></div></header><main class="mx-auto max-w-6xl px-6 py-12"><p class="text-sm font-semibold tracking-[0.18em] text-cyan-700">CONTROL PLANE</p><h1 class="mt-2 text-4xl font-bold tracking-tight">身份控制台</h1><p class="mt-4 max-w-2xl text-slate-600">此服务仅管理本地部署的身份主体、浏览器会话和控制平面权限。</p><dl class="mt-9 grid gap-5 sm:grid-cols-2"><div class="rounded-xl border border-slate-200 bg-white p-5 shadow-sm"><dt class="text-sm font-medium text-slate-500">当前认证主体</dt><dd class="mt-2 break-all font-mono text-sm text-slate-900">{{.SubjectID}}</dd></div><div class="rounded-xl border border-slate-200 bg-white p-5 shadow-sm"><dt class="text-sm font-medium text-slate-500">可用管理操作</dt><dd class="mt-2 text-sm text-slate-900">创建、查看与禁用主体</dd></div></dl></main></body></html>`))
+<body class="min-h-screen bg-slate-100 text-slate-900"><header class="border-b border-slate-200 bg-white"><div class="mx-auto flex max-w-6xl items-center justify-between px-6 py-4"><a class="font-bold tracking-tight text-slate-950" href="/crate-api/identity/v1/dashboard">identityd</a><nav class="flex items-center gap-4 text-sm"><a class="font-medium text-slate-600 hover:text-cyan-700" href="/crate-api/identity/v1/subjects">主体管理</a><a class="font-medium text-slate-600 hover:text-cyan-700" href="/crate-api/identity/v1/audit-events">审计事件</a><a class="font-medium text-slate-600 hover:text-cyan-700" href="/crate-api/identity/v1/password">修改密码</a><button class="rounded-md border border-slate-300 px-3 py-1.5 font-medium text-slate-700 hover:bg-slate-100" hx-delete="/crate-api/identity/v1/sessions/current" hx-headers='{"X-CSRF-Token":"{{.CSRFToken}}"}' hx-on::after-request="if(event.detail.successful) window.location='/crate-api/identity/v1/login'">退出登录</button></nav></div></header><main class="mx-auto max-w-6xl px-6 py-12"><p class="text-sm font-semibold tracking-[0.18em] text-cyan-700">CONTROL PLANE</p><h1 class="mt-2 text-4xl font-bold tracking-tight">身份控制台</h1><p class="mt-4 max-w-2xl text-slate-600">此服务仅管理本地部署的身份主体、浏览器会话和控制平面权限。</p><dl class="mt-9 grid gap-5 sm:grid-cols-2"><div class="rounded-xl border border-slate-200 bg-white p-5 shadow-sm"><dt class="text-sm font-medium text-slate-500">当前认证主体</dt><dd class="mt-2 break-all font-mono text-sm text-slate-900">{{.SubjectID}}</dd></div><div class="rounded-xl border border-slate-200 bg-white p-5 shadow-sm"><dt class="text-sm font-medium text-slate-500">可用管理操作</dt><dd class="mt-2 text-sm text-slate-900">创建、查看与禁用主体</dd></div></dl></main></body></html>`))
 
 var subjectsTemplate = template.Must(template.New("subjects").Parse(`<!doctype html>
 <html lang="zh-CN"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>主体管理 · identityd</title><link rel="stylesheet" href="/crate-api/identity/v1/assets/app.css"><script src="/crate-api/identity/v1/assets/htmx.min.js" defer></script></head>
-<body class="min-h-screen bg-slate-100 text-slate-900"><header class="border-b border-slate-200 bg-white"><div class="mx-auto flex max-w-6xl items-center justify-between px-6 py-4"><a class="font-bold tracking-tight text-slate-950" href="/crate-api/identity/v1/dashboard">identityd</a><nav class="flex items-center gap-4 text-sm"><a class="font-medium text-cyan-700" href="/crate-api/identity/v1/subjects">主体管理</a><a class="font-medium text-slate-600 hover:text-slate-950" href="/crate-api/identity/v1/dashboard">控制台</a></nav></div></header><main class="mx-auto max-w-6xl px-6 py-10"><div class="flex flex-wrap items-end justify-between gap-4"><div><p class="text-sm font-semibold tracking-[0.18em] text-cyan-700">IDENTITIES</p><h1 class="mt-2 text-3xl font-bold tracking-tight">主体管理</h1><p class="mt-2 text-sm text-slate-600">共 {{.Total}} 个主体。创建的主体默认没有控制平面角色。</p></div></div>{{if .HasError}}<p class="mt-6 rounded-lg border border-rose-300 bg-rose-50 px-4 py-3 text-sm text-rose-800">提交失败。请检查输入或稍后重试。</p>{{end}}<section class="mt-8 rounded-xl border border-slate-200 bg-white p-6 shadow-sm"><h2 class="text-lg font-semibold">创建主体</h2><form class="mt-5 grid gap-4 md:grid-cols-2" method="post" action="/crate-api/identity/v1/subjects" hx-post="/crate-api/identity/v1/subjects" hx-target="#subjects-body" hx-swap="afterbegin"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><label class="block text-sm font-medium text-slate-700">显示名称<input class="mt-1.5 block w-full rounded-lg border border-slate-300 px-3 py-2 outline-none focus:border-cyan-500 focus:ring-2 focus:ring-cyan-500/20" name="display_name" required maxlength="120"></label><label class="block text-sm font-medium text-slate-700">账号标识<input class="mt-1.5 block w-full rounded-lg border border-slate-300 px-3 py-2 outline-none focus:border-cyan-500 focus:ring-2 focus:ring-cyan-500/20" name="identifier" required minlength="3" maxlength="64" autocomplete="username"></label><label class="block text-sm font-medium text-slate-700 md:col-span-2">初始密码<input class="mt-1.5 block w-full rounded-lg border border-slate-300 px-3 py-2 outline-none focus:border-cyan-500 focus:ring-2 focus:ring-cyan-500/20" type="password" name="password" required minlength="12" autocomplete="new-password"></label><div class="md:col-span-2"><button class="rounded-lg bg-cyan-700 px-4 py-2.5 text-sm font-semibold text-white transition hover:bg-cyan-800 focus:outline-none focus:ring-2 focus:ring-cyan-600 focus:ring-offset-2">创建主体</button></div></form></section><section class="mt-8 overflow-hidden rounded-xl border border-slate-200 bg-white shadow-sm"><div class="border-b border-slate-200 px-6 py-4"><h2 class="font-semibold">主体列表</h2></div><div class="overflow-x-auto"><table class="min-w-full divide-y divide-slate-200 text-left text-sm"><thead class="bg-slate-50 text-xs uppercase tracking-wide text-slate-500"><tr><th class="px-6 py-3 font-semibold">主体</th><th class="px-6 py-3 font-semibold">账号标识</th><th class="px-6 py-3 font-semibold">角色</th><th class="px-6 py-3 font-semibold">状态</th><th class="px-6 py-3 font-semibold"><span class="sr-only">操作</span></th></tr></thead><tbody id="subjects-body" class="divide-y divide-slate-100">{{range .Rows}}{{template "subject-row" .}}{{else}}<tr><td class="px-6 py-8 text-center text-slate-500" colspan="5">暂无主体。</td></tr>{{end}}</tbody></table></div></section></main></body></html>{{define "subject-row"}}<tr id="subject-{{.Subject.ID}}"><td class="px-6 py-4"><div class="font-medium text-slate-900">{{.Subject.DisplayName}}</div><div class="mt-1 font-mono text-xs text-slate-500">{{.Subject.ID}}</div></td><td class="px-6 py-4 font-mono text-slate-700">{{.Subject.Identifier}}</td><td class="px-6 py-4 text-slate-600">{{range .Subject.Roles}}<span class="mr-1 inline-flex rounded bg-slate-100 px-2 py-1 text-xs">{{.}}</span>{{else}}—{{end}}</td><td class="px-6 py-4">{{if eq .Subject.Status "启用"}}<span class="inline-flex rounded-full bg-emerald-50 px-2.5 py-1 text-xs font-medium text-emerald-700">启用</span>{{else}}<span class="inline-flex rounded-full bg-slate-200 px-2.5 py-1 text-xs font-medium text-slate-700">禁用</span>{{end}}</td><td class="px-6 py-4 text-right">{{if eq .Subject.Status "启用"}}<div class="inline-flex flex-wrap justify-end gap-2"><details class="text-left"><summary class="cursor-pointer rounded-md border border-amber-300 px-3 py-1.5 text-xs font-semibold text-amber-800 hover:bg-amber-50">临时密码</summary><form class="mt-2 flex items-center gap-2" hx-patch="/crate-api/identity/v1/subjects/{{.Subject.ID}}" hx-target="#subject-{{.Subject.ID}}" hx-swap="outerHTML" hx-confirm="确认设置临时密码？该主体的活动会话将立即失效。"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><input class="w-44 rounded-md border border-slate-300 px-2 py-1.5 text-xs" type="password" name="temporary_password" minlength="12" autocomplete="new-password" required><button class="rounded-md bg-amber-600 px-3 py-1.5 text-xs font-semibold text-white hover:bg-amber-700">设置</button></form></details><form class="inline" hx-patch="/crate-api/identity/v1/subjects/{{.Subject.ID}}" hx-target="#subject-{{.Subject.ID}}" hx-swap="outerHTML" hx-confirm="确认禁用该主体？其所有活动会话将立即失效。"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><input type="hidden" name="status" value="禁用"><button class="rounded-md border border-rose-300 px-3 py-1.5 text-xs font-semibold text-rose-700 hover:bg-rose-50">禁用</button></form></div>{{end}}</td></tr>{{end}}`))
+<body class="min-h-screen bg-slate-100 text-slate-900"><header class="border-b border-slate-200 bg-white"><div class="mx-auto flex max-w-6xl items-center justify-between px-6 py-4"><a class="font-bold tracking-tight text-slate-950" href="/crate-api/identity/v1/dashboard">identityd</a><nav class="flex items-center gap-4 text-sm"><a class="font-medium text-cyan-700" href="/crate-api/identity/v1/subjects">主体管理</a><a class="font-medium text-slate-600 hover:text-slate-950" href="/crate-api/identity/v1/dashboard">控制台</a></nav></div></header><main class="mx-auto max-w-6xl px-6 py-10"><div class="flex flex-wrap items-end justify-between gap-4"><div><p class="text-sm font-semibold tracking-[0.18em] text-cyan-700">IDENTITIES</p><h1 class="mt-2 text-3xl font-bold tracking-tight">主体管理</h1><p class="mt-2 text-sm text-slate-600">共 {{.Total}} 个主体。创建的主体默认没有控制平面角色。</p></div></div>{{if .HasError}}<p class="mt-6 rounded-lg border border-rose-300 bg-rose-50 px-4 py-3 text-sm text-rose-800">提交失败。请检查输入或稍后重试。</p>{{end}}<section class="mt-8 rounded-xl border border-slate-200 bg-white p-6 shadow-sm"><h2 class="text-lg font-semibold">创建主体</h2><form class="mt-5 grid gap-4 md:grid-cols-2" method="post" action="/crate-api/identity/v1/subjects" hx-post="/crate-api/identity/v1/subjects" hx-target="#subjects-body" hx-swap="afterbegin"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><label class="block text-sm font-medium text-slate-700">显示名称<input class="mt-1.5 block w-full rounded-lg border border-slate-300 px-3 py-2 outline-none focus:border-cyan-500 focus:ring-2 focus:ring-cyan-500/20" name="display_name" required maxlength="120"></label><label class="block text-sm font-medium text-slate-700">账号标识<input class="mt-1.5 block w-full rounded-lg border border-slate-300 px-3 py-2 outline-none focus:border-cyan-500 focus:ring-2 focus:ring-cyan-500/20" name="identifier" required minlength="3" maxlength="64" autocomplete="username"></label><label class="block text-sm font-medium text-slate-700 md:col-span-2">初始密码<input class="mt-1.5 block w-full rounded-lg border border-slate-300 px-3 py-2 outline-none focus:border-cyan-500 focus:ring-2 focus:ring-cyan-500/20" type="password" name="password" required minlength="12" autocomplete="new-password"></label><div class="md:col-span-2"><button class="rounded-lg bg-cyan-700 px-4 py-2.5 text-sm font-semibold text-white transition hover:bg-cyan-800 focus:outline-none focus:ring-2 focus:ring-cyan-600 focus:ring-offset-2">创建主体</button></div></form></section><section class="mt-8 overflow-hidden rounded-xl border border-slate-200 bg-white shadow-sm"><div class="border-b border-slate-200 px-6 py-4"><h2 class="font-semibold">主体列表</h2></div><div class="overflow-x-auto"><table class="min-w-full divide-y divide-slate-200 text-left text-sm"><thead class="bg-slate-50 text-xs uppercase tracking-wide text-slate-500"><tr><th class="px-6 py-3 font-semibold">主体</th><th class="px-6 py-3 font-semibold">账号标识</th><th class="px-6 py-3 font-semibold">角色</th><th class="px-6 py-3 font-semibold">状态</th><th class="px-6 py-3 font-semibold"><span class="sr-only">操作</span></th></tr></thead><tbody id="subjects-body" class="divide-y divide-slate-100">{{range .Rows}}{{template "subject-row" .}}{{else}}<tr><td class="px-6 py-8 text-center text-slate-500" colspan="5">暂无主体。</td></tr>{{end}}</tbody></table></div></section></main></body></html>{{define "subject-row"}}<tr id="subject-{{.Subject.ID}}"><td class="px-6 py-4"><div class="font-medium text-slate-900">{{.Subject.DisplayName}}</div><div class="mt-1 font-mono text-xs text-slate-500">{{.Subject.ID}}</div></td><td class="px-6 py-4 font-mono text-slate-700">{{.Subject.Identifier}}</td><td class="px-6 py-4 text-slate-600">{{range .Subject.Roles}}<span class="mr-1 inline-flex rounded bg-slate-100 px-2 py-1 text-xs">{{.}}</span>{{else}}—{{end}}</td><td class="px-6 py-4">{{if eq .Subject.Status "启用"}}<span class="inline-flex rounded-full bg-emerald-50 px-2.5 py-1 text-xs font-medium text-emerald-700">启用</span>{{else if eq .Subject.Status "已删除"}}<span class="inline-flex rounded-full bg-rose-50 px-2.5 py-1 text-xs font-medium text-rose-700">已删除</span>{{else}}<span class="inline-flex rounded-full bg-slate-200 px-2.5 py-1 text-xs font-medium text-slate-700">禁用</span>{{end}}</td><td class="px-6 py-4 text-right">{{if eq .Subject.Status "启用"}}<div class="inline-flex flex-wrap justify-end gap-2"><details class="text-left"><summary class="cursor-pointer rounded-md border border-amber-300 px-3 py-1.5 text-xs font-semibold text-amber-800 hover:bg-amber-50">临时密码</summary><form class="mt-2 flex items-center gap-2" hx-patch="/crate-api/identity/v1/subjects/{{.Subject.ID}}" hx-target="#subject-{{.Subject.ID}}" hx-swap="outerHTML" hx-confirm="确认设置临时密码？该主体的活动会话将立即失效。"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><input class="w-44 rounded-md border border-slate-300 px-2 py-1.5 text-xs" type="password" name="temporary_password" minlength="12" autocomplete="new-password" required><button class="rounded-md bg-amber-600 px-3 py-1.5 text-xs font-semibold text-white hover:bg-amber-700">设置</button></form></details><form class="inline" hx-patch="/crate-api/identity/v1/subjects/{{.Subject.ID}}" hx-target="#subject-{{.Subject.ID}}" hx-swap="outerHTML" hx-confirm="确认禁用该主体？其所有活动会话将立即失效。"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><input type="hidden" name="status" value="禁用"><button class="rounded-md border border-rose-300 px-3 py-1.5 text-xs font-semibold text-rose-700 hover:bg-rose-50">禁用</button></form><button class="rounded-md bg-rose-700 px-3 py-1.5 text-xs font-semibold text-white hover:bg-rose-800" hx-delete="/crate-api/identity/v1/subjects/{{.Subject.ID}}" hx-headers='{"X-CSRF-Token":"{{.CSRFToken}}"}' hx-target="#subject-{{.Subject.ID}}" hx-swap="outerHTML" hx-confirm="确认删除该主体？其个人资料和标识符将被匿名化，且无法恢复。">删除</button></div>{{else if eq .Subject.Status "禁用"}}<div class="inline-flex flex-wrap justify-end gap-2"><form class="inline" hx-patch="/crate-api/identity/v1/subjects/{{.Subject.ID}}" hx-target="#subject-{{.Subject.ID}}" hx-swap="outerHTML" hx-confirm="确认重新启用该主体？"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><input type="hidden" name="status" value="启用"><button class="rounded-md border border-emerald-300 px-3 py-1.5 text-xs font-semibold text-emerald-700 hover:bg-emerald-50">启用</button></form><button class="rounded-md bg-rose-700 px-3 py-1.5 text-xs font-semibold text-white hover:bg-rose-800" hx-delete="/crate-api/identity/v1/subjects/{{.Subject.ID}}" hx-headers='{"X-CSRF-Token":"{{.CSRFToken}}"}' hx-target="#subject-{{.Subject.ID}}" hx-swap="outerHTML" hx-confirm="确认删除该主体？其个人资料和标识符将被匿名化，且无法恢复。">删除</button></div>{{end}}</td></tr>{{end}}`))
+
+var subjectRowTemplate = template.Must(template.New("subject-row").Parse(`{{define "subject-row"}}<tr id="subject-{{.Subject.ID}}"><td class="px-6 py-4"><div class="font-medium text-slate-900">{{.Subject.DisplayName}}</div><div class="mt-1 font-mono text-xs text-slate-500">{{.Subject.ID}}</div></td><td class="px-6 py-4 font-mono text-slate-700">{{.Subject.Identifier}}</td><td class="px-6 py-4 text-slate-600">{{range .Subject.Roles}}<span class="mr-1 inline-flex rounded bg-slate-100 px-2 py-1 text-xs">{{.}}</span>{{else}}—{{end}}</td><td class="px-6 py-4">{{if eq .Subject.Status "启用"}}<span class="inline-flex rounded-full bg-emerald-50 px-2.5 py-1 text-xs font-medium text-emerald-700">启用</span>{{else if eq .Subject.Status "已删除"}}<span class="inline-flex rounded-full bg-rose-50 px-2.5 py-1 text-xs font-medium text-rose-700">已删除</span>{{else}}<span class="inline-flex rounded-full bg-slate-200 px-2.5 py-1 text-xs font-medium text-slate-700">禁用</span>{{end}}</td><td class="px-6 py-4 text-right">{{if eq .Subject.Status "启用"}}<div class="inline-flex flex-wrap justify-end gap-2"><details class="text-left"><summary class="cursor-pointer rounded-md border border-amber-300 px-3 py-1.5 text-xs font-semibold text-amber-800 hover:bg-amber-50">临时密码</summary><form class="mt-2 flex items-center gap-2" hx-patch="/crate-api/identity/v1/subjects/{{.Subject.ID}}" hx-target="#subject-{{.Subject.ID}}" hx-swap="outerHTML" hx-confirm="确认设置临时密码？该主体的活动会话将立即失效。"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><input class="w-44 rounded-md border border-slate-300 px-2 py-1.5 text-xs" type="password" name="temporary_password" minlength="12" autocomplete="new-password" required><button class="rounded-md bg-amber-600 px-3 py-1.5 text-xs font-semibold text-white hover:bg-amber-700">设置</button></form></details><form class="inline" hx-patch="/crate-api/identity/v1/subjects/{{.Subject.ID}}" hx-target="#subject-{{.Subject.ID}}" hx-swap="outerHTML" hx-confirm="确认禁用该主体？其所有活动会话将立即失效。"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><input type="hidden" name="status" value="禁用"><button class="rounded-md border border-rose-300 px-3 py-1.5 text-xs font-semibold text-rose-700 hover:bg-rose-50">禁用</button></form><button class="rounded-md bg-rose-700 px-3 py-1.5 text-xs font-semibold text-white hover:bg-rose-800" hx-delete="/crate-api/identity/v1/subjects/{{.Subject.ID}}" hx-headers='{"X-CSRF-Token":"{{.CSRFToken}}"}' hx-target="#subject-{{.Subject.ID}}" hx-swap="outerHTML" hx-confirm="确认删除该主体？其个人资料和标识符将被匿名化，且无法恢复。">删除</button></div>{{else if eq .Subject.Status "禁用"}}<div class="inline-flex flex-wrap justify-end gap-2"><form class="inline" hx-patch="/crate-api/identity/v1/subjects/{{.Subject.ID}}" hx-target="#subject-{{.Subject.ID}}" hx-swap="outerHTML" hx-confirm="确认重新启用该主体？"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><input type="hidden" name="status" value="启用"><button class="rounded-md border border-emerald-300 px-3 py-1.5 text-xs font-semibold text-emerald-700 hover:bg-emerald-50">启用</button></form><button class="rounded-md bg-rose-700 px-3 py-1.5 text-xs font-semibold text-white hover:bg-rose-800" hx-delete="/crate-api/identity/v1/subjects/{{.Subject.ID}}" hx-headers='{"X-CSRF-Token":"{{.CSRFToken}}"}' hx-target="#subject-{{.Subject.ID}}" hx-swap="outerHTML" hx-confirm="确认删除该主体？其个人资料和标识符将被匿名化，且无法恢复。">删除</button></div>{{end}}</td></tr>{{end}}`))
 
-var subjectRowTemplate = template.Must(template.New("subject-row").Parse(`{{define "subject-row"}}<tr id="subject-{{.Subject.ID}}"><td class="px-6 py-4"><div class="font-medium text-slate-900">{{.Subject.DisplayName}}</div><div class="mt-1 font-mono text-xs text-slate-500">{{.Subject.ID}}</div></td><td class="px-6 py-4 font-mono text-slate-700">{{.Subject.Identifier}}</td><td class="px-6 py-4 text-slate-600">{{range .Subject.Roles}}<span class="mr-1 inline-flex rounded bg-slate-100 px-2 py-1 text-xs">{{.}}</span>{{else}}—{{end}}</td><td class="px-6 py-4">{{if eq .Subject.Status "启用"}}<span class="inline-flex rounded-full bg-emerald-50 px-2.5 py-1 text-xs font-medium text-emerald-700">启用</span>{{else}}<span class="inline-flex rounded-full bg-slate-200 px-2.5 py-1 text-xs font-medium text-slate-700">禁用</span>{{end}}</td><td class="px-6 py-4 text-right">{{if eq .Subject.Status "启用"}}<div class="inline-flex flex-wrap justify-end gap-2"><details class="text-left"><summary class="cursor-pointer rounded-md border border-amber-300 px-3 py-1.5 text-xs font-semibold text-amber-800 hover:bg-amber-50">临时密码</summary><form class="mt-2 flex items-center gap-2" hx-patch="/crate-api/identity/v1/subjects/{{.Subject.ID}}" hx-target="#subject-{{.Subject.ID}}" hx-swap="outerHTML" hx-confirm="确认设置临时密码？该主体的活动会话将立即失效。"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><input class="w-44 rounded-md border border-slate-300 px-2 py-1.5 text-xs" type="password" name="temporary_password" minlength="12" autocomplete="new-password" required><button class="rounded-md bg-amber-600 px-3 py-1.5 text-xs font-semibold text-white hover:bg-amber-700">设置</button></form></details><form class="inline" hx-patch="/crate-api/identity/v1/subjects/{{.Subject.ID}}" hx-target="#subject-{{.Subject.ID}}" hx-swap="outerHTML" hx-confirm="确认禁用该主体？其所有活动会话将立即失效。"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><input type="hidden" name="status" value="禁用"><button class="rounded-md border border-rose-300 px-3 py-1.5 text-xs font-semibold text-rose-700 hover:bg-rose-50">禁用</button></form></div>{{end}}</td></tr>{{end}}`))
+var auditEventsTemplate = template.Must(template.New("audit-events").Parse(`<!doctype html>
+<html lang="zh-CN"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>审计事件 · identityd</title><link rel="stylesheet" href="/crate-api/identity/v1/assets/app.css"><script src="/crate-api/identity/v1/assets/htmx.min.js" defer></script></head>
+<body class="min-h-screen bg-slate-100 text-slate-900"><header class="border-b border-slate-200 bg-white"><div class="mx-auto flex max-w-6xl items-center justify-between px-6 py-4"><a class="font-bold tracking-tight text-slate-950" href="/crate-api/identity/v1/dashboard">identityd</a><nav class="flex items-center gap-4 text-sm"><a class="font-medium text-cyan-700" href="/crate-api/identity/v1/audit-events">审计事件</a><a class="font-medium text-slate-600 hover:text-slate-950" href="/crate-api/identity/v1/dashboard">控制台</a></nav></div></header><main class="mx-auto max-w-6xl px-6 py-10"><div class="flex flex-wrap items-end justify-between gap-4"><div><p class="text-sm font-semibold tracking-[0.18em] text-cyan-700">AUDIT</p><h1 class="mt-2 text-3xl font-bold tracking-tight">审计事件</h1><p class="mt-2 text-sm text-slate-600">审计事件不可修改，按发生时间倒序显示。</p></div><div class="flex gap-2 text-sm"><a class="rounded-lg border border-slate-300 bg-white px-3 py-2 font-medium text-slate-700 hover:bg-slate-50" href="{{.CSVURL}}">导出 CSV</a><a class="rounded-lg border border-slate-300 bg-white px-3 py-2 font-medium text-slate-700 hover:bg-slate-50" href="{{.NDJSONURL}}">导出 NDJSON</a></div></div>{{if .HasError}}<p class="mt-6 rounded-lg border border-rose-300 bg-rose-50 px-4 py-3 text-sm text-rose-800">筛选条件无效。时间须为 RFC 3339 格式，例如 2026-01-01T00:00:00Z。</p>{{end}}<section class="mt-8 rounded-xl border border-slate-200 bg-white p-6 shadow-sm"><h2 class="text-lg font-semibold">筛选</h2><form class="mt-5 grid gap-4 md:grid-cols-3" method="get" action="/crate-api/identity/v1/audit-events"><label class="block text-sm font-medium text-slate-700">事件<select class="mt-1.5 block w-full rounded-lg border border-slate-300 px-3 py-2 outline-none focus:border-cyan-500 focus:ring-2 focus:ring-cyan-500/20" name="event_action"><option value="">全部</option>{{$selected := .Filters.EventAction}}{{range .Actions}}<option value="{{.}}"{{if eq . $selected}} selected{{end}}>{{.}}</option>{{end}}</select></label><label class="block text-sm font-medium text-slate-700">结果<select class="mt-1.5 block w-full rounded-lg border border-slate-300 px-3 py-2 outline-none focus:border-cyan-500 focus:ring-2 focus:ring-cyan-500/20" name="outcome"><option value="">全部</option><option value="成功"{{if eq .Filters.Outcome "成功"}} selected{{end}}>成功</option><option value="失败"{{if eq .Filters.Outcome "失败"}} selected{{end}}>失败</option></select></label><label class="block text-sm font-medium text-slate-700">操作主体<input class="mt-1.5 block w-full rounded-lg border border-slate-300 px-3 py-2 font-mono outline-none focus:border-cyan-500 focus:ring-2 focus:ring-cyan-500/20" name="actor_subject_id" value="{{.Filters.ActorSubjectID}}" maxlength="26"></label><label class="block text-sm font-medium text-slate-700">目标主体<input class="mt-1.5 block w-full rounded-lg border border-slate-300 px-3 py-2 font-mono outline-none focus:border-cyan-500 focus:ring-2 focus:ring-cyan-500/20" name="target_subject_id" value="{{.Filters.TargetSubjectID}}" maxlength="26"></label><label class="block text-sm font-medium text-slate-700">起始时间<input class="mt-1.5 block w-full rounded-lg border border-slate-300 px-3 py-2 font-mono outline-none focus:border-cyan-500 focus:ring-2 focus:ring-cyan-500/20" name="from" value="{{.Filters.From}}" placeholder="2026-01-01T00:00:00Z"></label><label class="block text-sm font-medium text-slate-700">截止时间<input class="mt-1.5 block w-full rounded-lg border border-slate-300 px-3 py-2 font-mono outline-none focus:border-cyan-500 focus:ring-2 focus:ring-cyan-500/20" name="until" value="{{.Filters.Until}}" placeholder="2026-02-01T00:00:00Z"></label><div class="md:col-span-3"><button class="rounded-lg bg-cyan-700 px-4 py-2.5 text-sm font-semibold text-white transition hover:bg-cyan-800 focus:outline-none focus:ring-2 focus:ring-cyan-600 focus:ring-offset-2">查询</button></div></form></section><section class="mt-8 overflow-hidden rounded-xl border border-slate-200 bg-white shadow-sm"><div class="overflow-x-auto"><table class="min-w-full divide-y divide-slate-200 text-left text-sm"><thead class="bg-slate-50 text-xs uppercase tracking-wide text-slate-500"><tr><th class="px-6 py-3 font-semibold">时间 (UTC)</th><th class="px-6 py-3 font-semibold">事件</th><th class="px-6 py-3 font-semibold">结果</th><th class="px-6 py-3 font-semibold">操作主体</th><th class="px-6 py-3 font-semibold">目标主体</th><th class="px-6 py-3 font-semibold">详情</th></tr></thead><tbody id="audit-events-body" class="divide-y divide-slate-100">{{if .Rows.Events}}{{template "audit-event-rows" .Rows}}{{else}}<tr><td class="px-6 py-8 text-center text-slate-500" colspan="6">没有符合条件的审计事件。</td></tr>{{end}}</tbody></table></div></section></main></body></html>{{define "audit-event-rows"}}{{range .Events}}<tr id="audit-event-{{.ID}}"><td class="whitespace-nowrap px-6 py-4 font-mono text-xs text-slate-700">{{.CreatedAt.UTC.Format "2006-01-02 15:04:05"}}</td><td class="px-6 py-4 font-medium text-slate-900">{{.EventAction}}</td><td class="px-6 py-4">{{if eq .Outcome "失败"}}<span class="inline-flex rounded-full bg-rose-50 px-2.5 py-1 text-xs font-medium text-rose-700">失败</span>{{else}}<span class="inline-flex rounded-full bg-emerald-50 px-2.5 py-1 text-xs font-medium text-emerald-700">成功</span>{{end}}</td><td class="px-6 py-4 font-mono text-xs text-slate-600">{{with .ActorSubjectID}}{{.}}{{else}}—{{end}}</td><td class="px-6 py-4 font-mono text-xs text-slate-600">{{with .TargetSubjectID}}{{.}}{{else}}—{{end}}</td><td class="max-w-xs break-all px-6 py-4 font-mono text-xs text-slate-500">{{printf "%s" .Metadata}}</td></tr>{{end}}{{if .NextURL}}<tr id="audit-events-more"><td class="px-6 py-4 text-center" colspan="6"><button class="rounded-md border border-slate-300 px-3 py-1.5 text-xs font-semibold text-slate-700 hover:bg-slate-50" hx-get="{{.NextURL}}" hx-target="#audit-events-more" hx-swap="outerHTML">加载更多</button></td></tr>{{end}}{{end}}{{define "audit-event-error-row"}}<tr><td class="px-6 py-8 text-center text-rose-700" colspan="6">筛选条件无效。</td></tr>{{end}}`))
@@ -0,0 +1,187 @@
+package identity
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database/sqlc"
+)
+
+var ErrInvalidAuditQuery = errors.New("invalid audit event query")
+
+// AuditEventActions lists the event_action values accepted by
+// identity_audit_events, in the order the audit viewer offers them.
+var AuditEventActions = []string{
+	"登录",
+	"退出登录",
+	"主体创建",
+	"主体状态变更",
+	"标识符变更",
+	"凭据变更",
+	"二次验证变更",
+	"角色创建",
+	"角色授予",
+	"角色撤销",
+	"会话撤销",
+	"客户端变更",
+	"管理员恢复",
+	"维护清理",
+}
+
+const maximumAuditEventPageSize int64 = 500
+
+type AuditEvent struct {
+	ID              string          `json:"id"`
+	EventAction     string          `json:"event_action"`
+	Outcome         string          `json:"outcome"`
+	ActorSubjectID  *string         `json:"actor_subject_id"`
+	TargetSubjectID *string         `json:"target_subject_id"`
+	RequestID       *string         `json:"request_id"`
+	SourceHash      *string         `json:"source_hash"`
+	Metadata        json.RawMessage `json:"metadata"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+// ListAuditEventsInput filters audit events. Empty strings and zero times
+// leave a filter unset; CreatedFrom is inclusive and CreatedUntil exclusive.
+// Before is the keyset cursor: only events with a smaller ID are returned.
+type ListAuditEventsInput struct {
+	EventAction     string
+	Outcome         string
+	ActorSubjectID  string
+	TargetSubjectID string
+	CreatedFrom     time.Time
+	CreatedUntil    time.Time
+	Before          string
+	Limit           int64
+}
+
+// ListAuditEventsResult holds one page, newest first. NextCursor is empty on
+// the last page.
+type ListAuditEventsResult struct {
+	Events     []AuditEvent
+	NextCursor string
+}
+
+// ListAuditEvents pages through audit events by descending ULID. Keyset
+// pagination keeps pages stable while new events are appended, which offset
+// pagination over an append-only table cannot.
+func ListAuditEvents(ctx context.Context, database *sql.DB, input ListAuditEventsInput) (ListAuditEventsResult, error) {
+	if input.Limit < 1 || input.Limit > maximumAuditEventPageSize {
+		return ListAuditEventsResult{}, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidAuditQuery, maximumAuditEventPageSize)
+	}
+	if input.EventAction != "" && !slices.Contains(AuditEventActions, input.EventAction) {
+		return ListAuditEventsResult{}, fmt.Errorf("%w: unknown event_action", ErrInvalidAuditQuery)
+	}
+	if input.Outcome != "" && input.Outcome != "成功" && input.Outcome != "失败" {
+		return ListAuditEventsResult{}, fmt.Errorf("%w: outcome must be 成功 or 失败", ErrInvalidAuditQuery)
+	}
+	if input.Before != "" && len(input.Before) != 26 {
+		return ListAuditEventsResult{}, fmt.Errorf("%w: invalid cursor", ErrInvalidAuditQuery)
+	}
+	if !input.CreatedFrom.IsZero() && !input.CreatedUntil.IsZero() && !input.CreatedFrom.Before(input.CreatedUntil) {
+		return ListAuditEventsResult{}, fmt.Errorf("%w: from must be earlier than until", ErrInvalidAuditQuery)
+	}
+
+	rows, err := sqlc.New(database).ListAuditEvents(ctx, sqlc.ListAuditEventsParams{
+		EventAction:     optionalString(input.EventAction),
+		Outcome:         optionalString(input.Outcome),
+		ActorSubjectID:  optionalString(input.ActorSubjectID),
+		TargetSubjectID: optionalString(input.TargetSubjectID),
+		CreatedFrom:     optionalTime(input.CreatedFrom),
+		CreatedUntil:    optionalTime(input.CreatedUntil),
+		BeforeID:        optionalString(input.Before),
+		RowLimit:        input.Limit + 1,
+	})
+	if err != nil {
+		return ListAuditEventsResult{}, fmt.Errorf("list audit events: %w", err)
+	}
+
+	result := ListAuditEventsResult{Events: make([]AuditEvent, 0, min(int64(len(rows)), input.Limit))}
+	for index, row := range rows {
+		if int64(index) == input.Limit {
+			result.NextCursor = result.Events[index-1].ID
+			break
+		}
+		result.Events = append(result.Events, auditEventFromRecord(row))
+	}
+	return result, nil
+}
+
+func auditEventFromRecord(record sqlc.IdentityAuditEvent) AuditEvent {
+	event := AuditEvent{
+		ID:              record.ID,
+		EventAction:     record.EventAction,
+		Outcome:         record.Outcome,
+		ActorSubjectID:  nullableString(record.ActorSubjectID),
+		TargetSubjectID: nullableString(record.TargetSubjectID),
+		RequestID:       nullableString(record.RequestID),
+		Metadata:        json.RawMessage(record.Metadata),
+		CreatedAt:       record.CreatedAt,
+	}
+	if record.SourceHash != nil {
+		sourceHash := hex.EncodeToString(record.SourceHash)
+		event.SourceHash = &sourceHash
+	}
+	return event
+}
+
+// auditActor is whoever made a change. Neither a SCIM provisioning client
+// nor a directory has a subject row for actor_subject_id to reference, so
+// its client_id or directory name is recorded in the event metadata instead.
+type auditActor struct {
+	subjectID string
+	clientID  string
+	directory string
+}
+
+func subjectActor(subjectID string) auditActor {
+	return auditActor{subjectID: subjectID}
+}
+
+func provisioningActor(clientID string) auditActor {
+	return auditActor{clientID: clientID}
+}
+
+func directoryActor(name string) auditActor {
+	return auditActor{directory: name}
+}
+
+func (actor auditActor) column() sql.NullString {
+	return optionalString(actor.subjectID)
+}
+
+// annotate adds the provisioning client or directory, if any, to event
+// metadata.
+func (actor auditActor) annotate(metadata map[string]string) map[string]string {
+	if actor.clientID != "" {
+		metadata["source"] = "SCIM"
+		metadata["client_id"] = actor.clientID
+	}
+	if actor.directory != "" {
+		metadata["source"] = "LDAP"
+		metadata["directory"] = actor.directory
+	}
+	return metadata
+}
+
+func optionalString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func optionalTime(value time.Time) sql.NullTime {
+	return sql.NullTime{Time: value.UTC(), Valid: !value.IsZero()}
+}
+
+func nullableString(value sql.NullString) *string {
+	if !value.Valid {
+		return nil
+	}
+	return &value.String
+}
@@ -0,0 +1,129 @@
+package identity
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database/sqlc"
+)
+
+// AuditSink receives committed audit events in commit order. The
+// identity_audit_events table stays the system of record: every insert also
+// enters identity_audit_outbox in the same transaction, and
+// DispatchAuditEvents copies the outbox to each sink. Delivery is at least
+// once, so Write must accept an event it has already stored again: a crash
+// between Write and the cursor update repeats that event.
+type AuditSink interface {
+	// Name keys the sink's delivery cursor; renaming a sink restarts its
+	// delivery from the oldest event still in the outbox.
+	Name() string
+	Write(ctx context.Context, event AuditEvent) error
+}
+
+const (
+	auditDispatchBatchSize int64 = 100
+	auditSinkRetryBase           = 5 * time.Second
+	auditSinkRetryMaximum        = 10 * time.Minute
+	// auditSinkCursorRetention is how long a sink that stopped reporting still
+	// holds back the outbox purge, so a removed sink does not pin it forever.
+	auditSinkCursorRetention = 30 * 24 * time.Hour
+)
+
+// DispatchAuditEvents delivers the pending outbox events to every sink and
+// returns how many deliveries succeeded. A failing sink keeps its position,
+// backs off exponentially up to ten minutes and does not hold back the other
+// sinks; its error is returned after the others have been served.
+func DispatchAuditEvents(ctx context.Context, database *sql.DB, sinks []AuditSink) (int, error) {
+	queries := sqlc.New(database)
+	delivered := 0
+	var failures []error
+	for _, sink := range sinks {
+		count, err := dispatchAuditSink(ctx, queries, sink)
+		delivered += count
+		if err != nil {
+			failures = append(failures, fmt.Errorf("audit sink %s: %w", sink.Name(), err))
+		}
+	}
+	return delivered, errors.Join(failures...)
+}
+
+func dispatchAuditSink(ctx context.Context, queries *sqlc.Queries, sink AuditSink) (int, error) {
+	now := time.Now().UTC()
+	if err := queries.EnsureAuditSinkCursor(ctx, sqlc.EnsureAuditSinkCursorParams{Sink: sink.Name(), UpdatedAt: now}); err != nil {
+		return 0, fmt.Errorf("create delivery cursor: %w", err)
+	}
+	cursor, err := queries.GetAuditSinkCursor(ctx, sink.Name())
+	if err != nil {
+		return 0, fmt.Errorf("load delivery cursor: %w", err)
+	}
+	if cursor.NextAttemptAt.Valid && now.Before(cursor.NextAttemptAt.Time) {
+		return 0, nil
+	}
+
+	delivered := 0
+	for {
+		rows, err := queries.ListAuditOutboxEvents(ctx, sqlc.ListAuditOutboxEventsParams{
+			Sequence: cursor.DeliveredSequence,
+			Limit:    auditDispatchBatchSize,
+		})
+		if err != nil {
+			return delivered, fmt.Errorf("list outbox events: %w", err)
+		}
+		for _, row := range rows {
+			if err := sink.Write(ctx, auditEventFromOutbox(row)); err != nil {
+				failedAt := time.Now().UTC()
+				if recordErr := queries.RecordAuditSinkFailure(ctx, sqlc.RecordAuditSinkFailureParams{
+					NextAttemptAt: sql.NullTime{Time: failedAt.Add(auditSinkRetryDelay(cursor.Attempts)), Valid: true},
+					LastError:     sql.NullString{String: err.Error(), Valid: true},
+					UpdatedAt:     failedAt,
+					Sink:          sink.Name(),
+				}); recordErr != nil {
+					return delivered, fmt.Errorf("record delivery failure: %w", recordErr)
+				}
+				return delivered, fmt.Errorf("write event %s: %w", row.ID, err)
+			}
+			if err := queries.RecordAuditSinkDelivery(ctx, sqlc.RecordAuditSinkDeliveryParams{
+				DeliveredSequence: row.Sequence,
+				UpdatedAt:         time.Now().UTC(),
+				Sink:              sink.Name(),
+			}); err != nil {
+				return delivered, fmt.Errorf("advance delivery cursor: %w", err)
+			}
+			cursor.DeliveredSequence = row.Sequence
+			cursor.Attempts = 0
+			delivered++
+		}
+		if int64(len(rows)) < auditDispatchBatchSize {
+			return delivered, nil
+		}
+	}
+}
+
+// auditSinkRetryDelay doubles from five seconds per consecutive failure.
+func auditSinkRetryDelay(attempts int64) time.Duration {
+	delay := auditSinkRetryBase
+	for range attempts {
+		delay *= 2
+		if delay >= auditSinkRetryMaximum {
+			return auditSinkRetryMaximum
+		}
+	}
+	return delay
+}
+
+func auditEventFromOutbox(row sqlc.ListAuditOutboxEventsRow) AuditEvent {
+	return auditEventFromRecord(sqlc.IdentityAuditEvent{
+		ID:              row.ID,
+		EventAction:     row.EventAction,
+		Outcome:         row.Outcome,
+		ActorSubjectID:  row.ActorSubjectID,
+		TargetSubjectID: row.TargetSubjectID,
+		RequestID:       row.RequestID,
+		SourceHash:      row.SourceHash,
+		Metadata:        row.Metadata,
+		CreatedAt:       row.CreatedAt,
+	})
+}
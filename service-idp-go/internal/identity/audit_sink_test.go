@@ -0,0 +1,84 @@
+package identity_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+type recordingAuditSink struct {
+	name   string
+	fail   bool
+	events []identity.AuditEvent
+}
+
+func (sink *recordingAuditSink) Name() string {
+	return sink.name
+}
+
+func (sink *recordingAuditSink) Write(ctx context.Context, event identity.AuditEvent) error {
+	if sink.fail {
+		return errors.New("collector unavailable")
+	}
+	sink.events = append(sink.events, event)
+	return nil
+}
+
+func TestDispatchAuditEventsDeliversOutboxInOrder(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	bootstrapAdministrator(t, databaseConnection)
+	if _, err := loginWithSource(ctx, databaseConnection, "admin", "wrong password", "192.0.2.1"); !errors.Is(err, identity.ErrInvalidCredentials) {
+		t.Fatalf("failed login error = %v", err)
+	}
+
+	file := &recordingAuditSink{name: "file"}
+	webhook := &recordingAuditSink{name: "webhook", fail: true}
+	delivered, err := identity.DispatchAuditEvents(ctx, databaseConnection, []identity.AuditSink{file, webhook})
+	if delivered != 2 || err == nil {
+		t.Fatalf("first dispatch = %d, %v", delivered, err)
+	}
+	if file.events[0].EventAction != "主体创建" || file.events[1].EventAction != "登录" || file.events[1].Outcome != "失败" {
+		t.Fatalf("file sink events = %#v", file.events)
+	}
+
+	// 失败的 sink 进入退避，不会在下一轮立即重试；另一个 sink 不受影响。
+	webhook.fail = false
+	if _, err := loginWithSource(ctx, databaseConnection, "admin", "correct horse battery staple", "192.0.2.1"); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	delivered, err = identity.DispatchAuditEvents(ctx, databaseConnection, []identity.AuditSink{file, webhook})
+	if delivered != 1 || err != nil || len(webhook.events) != 0 {
+		t.Fatalf("dispatch during backoff = %d, %v, webhook events %d", delivered, err, len(webhook.events))
+	}
+	var attempts int
+	var lastError string
+	if err := databaseConnection.QueryRow(`SELECT attempts, last_error FROM identity_audit_sink_cursors WHERE sink = 'webhook'`).Scan(&attempts, &lastError); err != nil || attempts != 1 || lastError == "" {
+		t.Fatalf("webhook cursor = %d %q, %v", attempts, lastError, err)
+	}
+
+	// 退避结束后从原位置补投递全部事件，并清空失败记录。
+	if _, err := databaseConnection.Exec(`UPDATE identity_audit_sink_cursors SET next_attempt_at = ? WHERE sink = 'webhook'`, time.Now().UTC().Add(-time.Second)); err != nil {
+		t.Fatalf("end backoff: %v", err)
+	}
+	delivered, err = identity.DispatchAuditEvents(ctx, databaseConnection, []identity.AuditSink{file, webhook})
+	if delivered != 3 || err != nil || len(webhook.events) != 3 || webhook.events[2].ID != file.events[2].ID {
+		t.Fatalf("dispatch after backoff = %d, %v, %#v", delivered, err, webhook.events)
+	}
+	if err := databaseConnection.QueryRow(`SELECT attempts FROM identity_audit_sink_cursors WHERE sink = 'webhook'`).Scan(&attempts); err != nil || attempts != 0 {
+		t.Fatalf("webhook attempts after delivery = %d, %v", attempts, err)
+	}
+
+	// 所有 sink 都已收到的出站记录由 purge 删除，审计表本身保持不变。
+	result, err := identity.Purge(ctx, databaseConnection, testLoginThrottleSettings)
+	if err != nil || result.AuditOutboxEvents != 3 {
+		t.Fatalf("purge = %#v, %v", result, err)
+	}
+	var outbox, events int
+	if err := databaseConnection.QueryRow(`SELECT (SELECT COUNT(*) FROM identity_audit_outbox), (SELECT COUNT(*) FROM identity_audit_events)`).Scan(&outbox, &events); err != nil || outbox != 1 || events != 4 {
+		t.Fatalf("outbox = %d, events = %d, %v", outbox, events, err)
+	}
+}
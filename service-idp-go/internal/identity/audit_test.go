@@ -0,0 +1,99 @@
+package identity_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+func TestListAuditEventsFiltersAndPagesByKeyset(t *testing.T) {
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	for index := range 3 {
+		if _, err := identity.CreateSubject(context.Background(), databaseConnection, administrator.ID, identity.CreateSubjectInput{
+			DisplayName: fmt.Sprintf("成员%d", index),
+			Identifier:  fmt.Sprintf("member%d", index),
+			Password:    "a sufficiently long password",
+		}, testPasswordPolicy); err != nil {
+			t.Fatalf("create subject %d: %v", index, err)
+		}
+	}
+	if _, err := loginWithSource(context.Background(), databaseConnection, "member0", "wrong password value", "192.0.2.1"); err == nil {
+		t.Fatal("login with wrong password succeeded")
+	}
+
+	all, err := identity.ListAuditEvents(context.Background(), databaseConnection, identity.ListAuditEventsInput{Limit: 100})
+	if err != nil {
+		t.Fatalf("list all audit events: %v", err)
+	}
+	if len(all.Events) < 5 || all.NextCursor != "" {
+		t.Fatalf("all audit events = %d, next cursor = %q", len(all.Events), all.NextCursor)
+	}
+
+	// 按游标逐页读取，结果应与一次读取的顺序完全一致。
+	var paged []identity.AuditEvent
+	cursor := ""
+	for {
+		page, err := identity.ListAuditEvents(context.Background(), databaseConnection, identity.ListAuditEventsInput{Before: cursor, Limit: 2})
+		if err != nil {
+			t.Fatalf("list audit page: %v", err)
+		}
+		paged = append(paged, page.Events...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if len(paged) != len(all.Events) {
+		t.Fatalf("paged %d events, want %d", len(paged), len(all.Events))
+	}
+	for index := range paged {
+		if paged[index].ID != all.Events[index].ID {
+			t.Fatalf("paged event %d = %s, want %s", index, paged[index].ID, all.Events[index].ID)
+		}
+	}
+
+	created, err := identity.ListAuditEvents(context.Background(), databaseConnection, identity.ListAuditEventsInput{
+		EventAction:    "主体创建",
+		ActorSubjectID: administrator.ID,
+		Limit:          100,
+	})
+	if err != nil || len(created.Events) != 3 {
+		t.Fatalf("created-subject events = %#v, err = %v", created.Events, err)
+	}
+	failed, err := identity.ListAuditEvents(context.Background(), databaseConnection, identity.ListAuditEventsInput{Outcome: "失败", Limit: 100})
+	if err != nil || len(failed.Events) != 1 || failed.Events[0].EventAction != "登录" || failed.Events[0].SourceHash == nil {
+		t.Fatalf("failed events = %#v, err = %v", failed.Events, err)
+	}
+	past, err := identity.ListAuditEvents(context.Background(), databaseConnection, identity.ListAuditEventsInput{
+		CreatedUntil: time.Now().Add(-time.Hour),
+		Limit:        100,
+	})
+	if err != nil || len(past.Events) != 0 {
+		t.Fatalf("events before an hour ago = %#v, err = %v", past.Events, err)
+	}
+	recent, err := identity.ListAuditEvents(context.Background(), databaseConnection, identity.ListAuditEventsInput{
+		CreatedFrom: time.Now().Add(-time.Hour),
+		Limit:       100,
+	})
+	if err != nil || len(recent.Events) != len(all.Events) {
+		t.Fatalf("events since an hour ago = %d, err = %v", len(recent.Events), err)
+	}
+
+	for _, input := range []identity.ListAuditEventsInput{
+		{Limit: 0},
+		{Limit: 501},
+		{EventAction: "删除", Limit: 10},
+		{Outcome: "未知", Limit: 10},
+		{Before: "short", Limit: 10},
+		{CreatedFrom: time.Now(), CreatedUntil: time.Now().Add(-time.Minute), Limit: 10},
+	} {
+		if _, err := identity.ListAuditEvents(context.Background(), databaseConnection, input); !errors.Is(err, identity.ErrInvalidAuditQuery) {
+			t.Fatalf("list audit events %#v error = %v, want ErrInvalidAuditQuery", input, err)
+		}
+	}
+}
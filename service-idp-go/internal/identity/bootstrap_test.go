@@ -55,9 +55,9 @@ func TestEnsureBootstrapCreatesAdministratorAndAuditTrail(t *testing.T) {
 	if roleCode != "identity.admin" {
 		t.Fatalf("role code = %q", roleCode)
 	}
-	matched, err := password.Verify("correct horse battery staple", passwordHash)
-	if err != nil || !matched {
-		t.Fatalf("bootstrap password verification = %t, error = %v", matched, err)
+	verification, err := password.Verify("correct horse battery staple", passwordHash)
+	if err != nil || !verification.Matched {
+		t.Fatalf("bootstrap password verification = %t, error = %v", verification.Matched, err)
 	}
 
 	created, err = identity.EnsureBootstrap(context.Background(), databaseConnection, identity.BootstrapInput{
@@ -0,0 +1,391 @@
+package identity
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database/sqlc"
+)
+
+var ErrClientNotFound = errors.New("client not found")
+var ErrClientAlreadyExists = errors.New("client_id is already registered")
+var ErrInvalidClientInput = errors.New("invalid client input")
+
+const maximumClientRedirectURIs = 10
+
+// ClientScopes lists the scopes a first-party client may be registered for.
+var ClientScopes = []string{"openid", "profile"}
+
+// Client is a registered first-party OIDC client. Clients are public: they
+// hold no secret and prove possession of an authorization code with PKCE.
+type Client struct {
+	ID           string    `json:"id"`
+	ClientID     string    `json:"client_id"`
+	DisplayName  string    `json:"display_name"`
+	Status       string    `json:"status"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type ListClientsInput struct {
+	Limit  int64
+	Offset int64
+}
+
+type ListClientsResult struct {
+	Clients []Client
+	Total   int64
+}
+
+type CreateClientInput struct {
+	ClientID     string
+	DisplayName  string
+	RedirectURIs []string
+	Scopes       []string
+}
+
+// UpdateClientInput carries a partial update; nil fields keep their stored
+// value.
+type UpdateClientInput struct {
+	DisplayName  *string
+	Status       *string
+	RedirectURIs []string
+	Scopes       []string
+}
+
+func ListClients(ctx context.Context, database *sql.DB, input ListClientsInput) (ListClientsResult, error) {
+	if input.Limit <= 0 || input.Offset < 0 {
+		return ListClientsResult{}, fmt.Errorf("invalid client list pagination")
+	}
+
+	queries := sqlc.New(database)
+	total, err := queries.CountClients(ctx)
+	if err != nil {
+		return ListClientsResult{}, fmt.Errorf("count clients: %w", err)
+	}
+	rows, err := queries.ListClients(ctx, sqlc.ListClientsParams{Limit: input.Limit, Offset: input.Offset})
+	if err != nil {
+		return ListClientsResult{}, fmt.Errorf("list clients: %w", err)
+	}
+	clients := make([]Client, 0, len(rows))
+	for _, row := range rows {
+		client, err := clientFromRecord(ctx, queries, row)
+		if err != nil {
+			return ListClientsResult{}, err
+		}
+		clients = append(clients, client)
+	}
+	return ListClientsResult{Clients: clients, Total: total}, nil
+}
+
+func GetClient(ctx context.Context, database *sql.DB, clientID string) (Client, error) {
+	return getClient(ctx, sqlc.New(database), clientID)
+}
+
+func CreateClient(ctx context.Context, database *sql.DB, actorSubjectID string, input CreateClientInput) (Client, error) {
+	clientID, err := validateClientID(input.ClientID)
+	if err != nil {
+		return Client{}, fmt.Errorf("%w: %v", ErrInvalidClientInput, err)
+	}
+	displayName, err := validateDisplayName(input.DisplayName)
+	if err != nil {
+		return Client{}, fmt.Errorf("%w: %v", ErrInvalidClientInput, err)
+	}
+	redirectURIs, err := validateRedirectURIs(input.RedirectURIs)
+	if err != nil {
+		return Client{}, fmt.Errorf("%w: %v", ErrInvalidClientInput, err)
+	}
+	if input.Scopes == nil {
+		input.Scopes = []string{"openid"}
+	}
+	scopes, err := validateClientScopes(input.Scopes)
+	if err != nil {
+		return Client{}, fmt.Errorf("%w: %v", ErrInvalidClientInput, err)
+	}
+
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return Client{}, fmt.Errorf("begin create client transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	if err := checkClientIDAvailable(ctx, transactionQueries, clientID); err != nil {
+		return Client{}, err
+	}
+
+	now := time.Now().UTC()
+	id, err := NewULID(now)
+	if err != nil {
+		return Client{}, err
+	}
+	if err := transactionQueries.CreateClient(ctx, sqlc.CreateClientParams{
+		ID:          id,
+		ClientID:    clientID,
+		DisplayName: displayName,
+		Status:      "启用",
+		Metadata:    "{}",
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}); err != nil {
+		return Client{}, fmt.Errorf("create client: %w", err)
+	}
+	if err := replaceClientRegistration(ctx, transactionQueries, id, redirectURIs, scopes, now); err != nil {
+		return Client{}, err
+	}
+	if err := insertClientAuditEvent(ctx, transactionQueries, actorSubjectID, clientID, "创建", now); err != nil {
+		return Client{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return Client{}, fmt.Errorf("commit create client transaction: %w", err)
+	}
+
+	return Client{
+		ID:           id,
+		ClientID:     clientID,
+		DisplayName:  displayName,
+		Status:       "启用",
+		RedirectURIs: redirectURIs,
+		Scopes:       scopes,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}, nil
+}
+
+func UpdateClient(ctx context.Context, database *sql.DB, actorSubjectID string, clientID string, input UpdateClientInput) (Client, error) {
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return Client{}, fmt.Errorf("begin update client transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	client, err := getClient(ctx, transactionQueries, clientID)
+	if err != nil {
+		return Client{}, err
+	}
+	if input.DisplayName != nil {
+		client.DisplayName, err = validateDisplayName(*input.DisplayName)
+		if err != nil {
+			return Client{}, fmt.Errorf("%w: %v", ErrInvalidClientInput, err)
+		}
+	}
+	if input.Status != nil {
+		if *input.Status != "启用" && *input.Status != "禁用" {
+			return Client{}, fmt.Errorf("%w: status must be 启用 or 禁用", ErrInvalidClientInput)
+		}
+		client.Status = *input.Status
+	}
+	if input.RedirectURIs != nil {
+		client.RedirectURIs, err = validateRedirectURIs(input.RedirectURIs)
+		if err != nil {
+			return Client{}, fmt.Errorf("%w: %v", ErrInvalidClientInput, err)
+		}
+	}
+	if input.Scopes != nil {
+		client.Scopes, err = validateClientScopes(input.Scopes)
+		if err != nil {
+			return Client{}, fmt.Errorf("%w: %v", ErrInvalidClientInput, err)
+		}
+	}
+
+	now := time.Now().UTC()
+	updated, err := transactionQueries.UpdateClient(ctx, sqlc.UpdateClientParams{
+		DisplayName: client.DisplayName,
+		Status:      client.Status,
+		UpdatedAt:   now,
+		ID:          client.ID,
+	})
+	if err != nil {
+		return Client{}, fmt.Errorf("update client: %w", err)
+	}
+	if updated != 1 {
+		return Client{}, ErrClientNotFound
+	}
+	if input.RedirectURIs != nil || input.Scopes != nil {
+		if err := replaceClientRegistration(ctx, transactionQueries, client.ID, client.RedirectURIs, client.Scopes, now); err != nil {
+			return Client{}, err
+		}
+	}
+	if err := insertClientAuditEvent(ctx, transactionQueries, actorSubjectID, client.ClientID, "更新", now); err != nil {
+		return Client{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return Client{}, fmt.Errorf("commit update client transaction: %w", err)
+	}
+
+	client.UpdatedAt = now
+	return client, nil
+}
+
+func getClient(ctx context.Context, queries sqlc.Querier, clientID string) (Client, error) {
+	record, err := queries.GetClientByClientID(ctx, clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Client{}, ErrClientNotFound
+	}
+	if err != nil {
+		return Client{}, fmt.Errorf("get client: %w", err)
+	}
+	return clientFromRecord(ctx, queries, record)
+}
+
+func clientFromRecord(ctx context.Context, queries sqlc.Querier, record sqlc.OidcClient) (Client, error) {
+	redirectURIs, err := queries.ListClientRedirectURIs(ctx, record.ID)
+	if err != nil {
+		return Client{}, fmt.Errorf("list client redirect URIs: %w", err)
+	}
+	scopes, err := queries.ListClientScopes(ctx, record.ID)
+	if err != nil {
+		return Client{}, fmt.Errorf("list client scopes: %w", err)
+	}
+	if redirectURIs == nil {
+		redirectURIs = []string{}
+	}
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return Client{
+		ID:           record.ID,
+		ClientID:     record.ClientID,
+		DisplayName:  record.DisplayName,
+		Status:       record.Status,
+		RedirectURIs: redirectURIs,
+		Scopes:       scopes,
+		CreatedAt:    record.CreatedAt,
+		UpdatedAt:    record.UpdatedAt,
+	}, nil
+}
+
+func replaceClientRegistration(ctx context.Context, queries sqlc.Querier, id string, redirectURIs []string, scopes []string, now time.Time) error {
+	if err := queries.DeleteClientRedirectURIs(ctx, id); err != nil {
+		return fmt.Errorf("clear client redirect URIs: %w", err)
+	}
+	for _, redirectURI := range redirectURIs {
+		if err := queries.CreateClientRedirectURI(ctx, sqlc.CreateClientRedirectURIParams{
+			OidcClientID: id,
+			RedirectUri:  redirectURI,
+			CreatedAt:    now,
+		}); err != nil {
+			return fmt.Errorf("register client redirect URI: %w", err)
+		}
+	}
+	if err := queries.DeleteClientScopes(ctx, id); err != nil {
+		return fmt.Errorf("clear client scopes: %w", err)
+	}
+	for _, scope := range scopes {
+		if err := queries.CreateClientScope(ctx, sqlc.CreateClientScopeParams{
+			OidcClientID: id,
+			Scope:        scope,
+			CreatedAt:    now,
+		}); err != nil {
+			return fmt.Errorf("register client scope: %w", err)
+		}
+	}
+	return nil
+}
+
+func insertClientAuditEvent(ctx context.Context, queries sqlc.Querier, actorSubjectID string, clientID string, change string, now time.Time) error {
+	auditEventID, err := NewULID(now)
+	if err != nil {
+		return err
+	}
+	metadata, err := json.Marshal(map[string]string{"client_id": clientID, "change": change})
+	if err != nil {
+		return fmt.Errorf("encode client audit metadata: %w", err)
+	}
+	if err := queries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
+		ID:              auditEventID,
+		EventAction:     "客户端变更",
+		Outcome:         "成功",
+		ActorSubjectID:  sql.NullString{String: actorSubjectID, Valid: true},
+		TargetSubjectID: sql.NullString{},
+		RequestID:       sql.NullString{},
+		SourceHash:      nil,
+		Metadata:        string(metadata),
+		CreatedAt:       now,
+	}); err != nil {
+		return fmt.Errorf("write client audit event: %w", err)
+	}
+	return nil
+}
+
+func validateClientID(value string) (string, error) {
+	if len(value) < 3 || len(value) > 64 {
+		return "", fmt.Errorf("client_id must contain 3 to 64 characters")
+	}
+	for index, character := range value {
+		switch {
+		case character >= 'a' && character <= 'z', character >= '0' && character <= '9':
+		case index > 0 && (character == '-' || character == '_' || character == '.'):
+		default:
+			return "", fmt.Errorf("client_id may only contain lowercase letters, digits, '-', '_' and '.'")
+		}
+	}
+	return value, nil
+}
+
+// validateRedirectURIs requires exact, absolute HTTPS redirect URIs. Plain
+// HTTP is accepted only for loopback hosts used in local development.
+func validateRedirectURIs(values []string) ([]string, error) {
+	if len(values) == 0 || len(values) > maximumClientRedirectURIs {
+		return nil, fmt.Errorf("a client must register 1 to %d redirect URIs", maximumClientRedirectURIs)
+	}
+	redirectURIs := make([]string, 0, len(values))
+	for _, value := range values {
+		parsed, err := url.Parse(value)
+		if err != nil || !parsed.IsAbs() || parsed.Host == "" || parsed.User != nil || parsed.Fragment != "" || strings.Contains(value, "#") || len(value) > 2048 {
+			return nil, fmt.Errorf("redirect URI %q must be an absolute URI without credentials or fragment", value)
+		}
+		switch parsed.Scheme {
+		case "https":
+		case "http":
+			if !isLoopbackHost(parsed.Hostname()) {
+				return nil, fmt.Errorf("redirect URI %q must use https outside loopback development", value)
+			}
+		default:
+			return nil, fmt.Errorf("redirect URI %q must use https", value)
+		}
+		if !slices.Contains(redirectURIs, value) {
+			redirectURIs = append(redirectURIs, value)
+		}
+	}
+	slices.Sort(redirectURIs)
+	return redirectURIs, nil
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	address := net.ParseIP(host)
+	return address != nil && address.IsLoopback()
+}
+
+func validateClientScopes(values []string) ([]string, error) {
+	scopes := make([]string, 0, len(values))
+	for _, value := range values {
+		if !slices.Contains(ClientScopes, value) {
+			return nil, fmt.Errorf("unsupported scope %q", value)
+		}
+		if !slices.Contains(scopes, value) {
+			scopes = append(scopes, value)
+		}
+	}
+	if !slices.Contains(scopes, "openid") {
+		return nil, fmt.Errorf("scopes must include openid")
+	}
+	slices.Sort(scopes)
+	return scopes, nil
+}
@@ -0,0 +1,92 @@
+package identity_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+func TestCreateListAndUpdateClient(t *testing.T) {
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+
+	created, err := identity.CreateClient(context.Background(), databaseConnection, administrator.ID, identity.CreateClientInput{
+		ClientID:     "command-console",
+		DisplayName:  "指挥控制台",
+		RedirectURIs: []string{"https://console.example.test/callback", "http://127.0.0.1:4324/callback"},
+	})
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	// 未指定 scope 时默认只登记 openid，重定向地址按字典序保存。
+	if created.Status != "启用" || len(created.Scopes) != 1 || created.Scopes[0] != "openid" {
+		t.Fatalf("created client = %#v", created)
+	}
+	if len(created.RedirectURIs) != 2 || created.RedirectURIs[0] != "http://127.0.0.1:4324/callback" {
+		t.Fatalf("created redirect URIs = %v", created.RedirectURIs)
+	}
+
+	listed, err := identity.ListClients(context.Background(), databaseConnection, identity.ListClientsInput{Limit: 20})
+	if err != nil {
+		t.Fatalf("list clients: %v", err)
+	}
+	if listed.Total != 1 || len(listed.Clients) != 1 || listed.Clients[0].ClientID != "command-console" {
+		t.Fatalf("listed clients = %#v", listed)
+	}
+
+	disabled := "禁用"
+	updated, err := identity.UpdateClient(context.Background(), databaseConnection, administrator.ID, "command-console", identity.UpdateClientInput{
+		Status: &disabled,
+		Scopes: []string{"openid", "profile"},
+	})
+	if err != nil {
+		t.Fatalf("update client: %v", err)
+	}
+	if updated.Status != "禁用" || len(updated.Scopes) != 2 || len(updated.RedirectURIs) != 2 || updated.DisplayName != "指挥控制台" {
+		t.Fatalf("updated client = %#v", updated)
+	}
+
+	var auditCount int
+	if err := databaseConnection.QueryRow(`
+		SELECT COUNT(*)
+		FROM identity_audit_events
+		WHERE event_action = '客户端变更' AND outcome = '成功' AND actor_subject_id = ?
+	`, administrator.ID).Scan(&auditCount); err != nil {
+		t.Fatalf("count client audits: %v", err)
+	}
+	if auditCount != 2 {
+		t.Fatalf("client audit count = %d, want 2", auditCount)
+	}
+}
+
+func TestCreateClientRejectsInvalidRegistration(t *testing.T) {
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+
+	for name, input := range map[string]identity.CreateClientInput{
+		"client_id":       {ClientID: "Console!", DisplayName: "控制台", RedirectURIs: []string{"https://console.example.test/callback"}},
+		"plain http":      {ClientID: "console", DisplayName: "控制台", RedirectURIs: []string{"http://console.example.test/callback"}},
+		"fragment":        {ClientID: "console", DisplayName: "控制台", RedirectURIs: []string{"https://console.example.test/callback#top"}},
+		"no redirect":     {ClientID: "console", DisplayName: "控制台"},
+		"missing openid":  {ClientID: "console", DisplayName: "控制台", RedirectURIs: []string{"https://console.example.test/callback"}, Scopes: []string{"profile"}},
+		"unknown scope":   {ClientID: "console", DisplayName: "控制台", RedirectURIs: []string{"https://console.example.test/callback"}, Scopes: []string{"openid", "email"}},
+		"missing display": {ClientID: "console", RedirectURIs: []string{"https://console.example.test/callback"}},
+	} {
+		if _, err := identity.CreateClient(context.Background(), databaseConnection, administrator.ID, input); !errors.Is(err, identity.ErrInvalidClientInput) {
+			t.Fatalf("%s error = %v", name, err)
+		}
+	}
+
+	input := identity.CreateClientInput{ClientID: "console", DisplayName: "控制台", RedirectURIs: []string{"https://console.example.test/callback"}}
+	if _, err := identity.CreateClient(context.Background(), databaseConnection, administrator.ID, input); err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	if _, err := identity.CreateClient(context.Background(), databaseConnection, administrator.ID, input); !errors.Is(err, identity.ErrClientAlreadyExists) {
+		t.Fatalf("duplicate client error = %v", err)
+	}
+	if _, err := identity.GetClient(context.Background(), databaseConnection, "missing"); !errors.Is(err, identity.ErrClientNotFound) {
+		t.Fatalf("missing client error = %v", err)
+	}
+}
@@ -21,10 +21,12 @@ type ChangePasswordInput struct {
 	NewPassword     string
 }
 
-func ChangePassword(ctx context.Context, database *sql.DB, subjectID string, input ChangePasswordInput) error {
-	newPasswordHash, err := password.Hash(input.NewPassword)
+// ChangePassword replaces the subject's password after checking the current
+// one. The new password must satisfy policy, including its reuse history.
+func ChangePassword(ctx context.Context, database *sql.DB, subjectID string, input ChangePasswordInput, policy PasswordPolicy) error {
+	newPasswordHash, err := policy.hash(input.NewPassword)
 	if err != nil {
-		return fmt.Errorf("%w: %v", ErrInvalidPasswordInput, err)
+		return err
 	}
 
 	transaction, err := database.BeginTx(ctx, nil)
@@ -41,13 +43,16 @@ func ChangePassword(ctx context.Context, database *sql.DB, subjectID string, inp
 	if err != nil {
 		return fmt.Errorf("load password credential: %w", err)
 	}
-	matched, err := password.Verify(input.CurrentPassword, credential.PasswordHash)
+	verification, err := password.Verify(input.CurrentPassword, credential.PasswordHash)
 	if err != nil {
 		return fmt.Errorf("verify current password: %w", err)
 	}
-	if !matched {
+	if !verification.Matched {
 		return ErrIncorrectPassword
 	}
+	if err := checkPasswordReuse(ctx, queries, policy, subjectID, credential.PasswordHash, input.NewPassword); err != nil {
+		return err
+	}
 	if err := replacePassword(ctx, queries, subjectID, credential.PasswordRevision, newPasswordHash, "有效", subjectID); err != nil {
 		return err
 	}
@@ -57,10 +62,12 @@ func ChangePassword(ctx context.Context, database *sql.DB, subjectID string, inp
 	return nil
 }
 
-func SetTemporaryPassword(ctx context.Context, database *sql.DB, actorSubjectID string, subjectID string, temporaryPassword string) error {
-	temporaryPasswordHash, err := password.Hash(temporaryPassword)
+// SetTemporaryPassword applies the length and denylist rules of policy but
+// not its history, since the subject has to replace the password anyway.
+func SetTemporaryPassword(ctx context.Context, database *sql.DB, actorSubjectID string, subjectID string, temporaryPassword string, policy PasswordPolicy) error {
+	temporaryPasswordHash, err := policy.hash(temporaryPassword)
 	if err != nil {
-		return fmt.Errorf("%w: %v", ErrInvalidPasswordInput, err)
+		return err
 	}
 
 	transaction, err := database.BeginTx(ctx, nil)
@@ -77,6 +84,10 @@ func SetTemporaryPassword(ctx context.Context, database *sql.DB, actorSubjectID
 	if err != nil {
 		return fmt.Errorf("load password credential: %w", err)
 	}
+	// Only erasure voids a credential, and an erased subject stays locked out.
+	if credential.CredentialStatus == "已作废" {
+		return ErrSubjectErased
+	}
 	if err := replacePassword(ctx, queries, subjectID, credential.PasswordRevision, temporaryPasswordHash, "需更新", actorSubjectID); err != nil {
 		return err
 	}
@@ -86,8 +97,20 @@ func SetTemporaryPassword(ctx context.Context, database *sql.DB, actorSubjectID
 	return nil
 }
 
+// replacePassword moves the current hash into the password history before
+// overwriting it, as long as the credential is still at expectedRevision.
 func replacePassword(ctx context.Context, queries sqlc.Querier, subjectID string, expectedRevision int64, passwordHash string, credentialStatus string, actorSubjectID string) error {
 	now := time.Now().UTC()
+	previous, err := queries.GetPasswordCredentialBySubjectID(ctx, subjectID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrPasswordCredentialNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("load password credential: %w", err)
+	}
+	if previous.PasswordRevision != expectedRevision {
+		return ErrPasswordUpdateConflict
+	}
 	updated, err := queries.UpdatePasswordCredential(ctx, sqlc.UpdatePasswordCredentialParams{
 		PasswordHash:     passwordHash,
 		CredentialStatus: credentialStatus,
@@ -102,6 +125,9 @@ func replacePassword(ctx context.Context, queries sqlc.Querier, subjectID string
 	if updated != 1 {
 		return ErrPasswordUpdateConflict
 	}
+	if err := archivePassword(ctx, queries, subjectID, previous.PasswordHash, now); err != nil {
+		return err
+	}
 	updated, err = queries.IncrementEnabledSubjectSecurityVersion(ctx, sqlc.IncrementEnabledSubjectSecurityVersionParams{
 		UpdatedAt: now,
 		ID:        subjectID,
@@ -128,7 +154,7 @@ func replacePassword(ctx context.Context, queries sqlc.Querier, subjectID string
 		ID:              auditID,
 		EventAction:     "凭据变更",
 		Outcome:         "成功",
-		ActorSubjectID:  sql.NullString{String: actorSubjectID, Valid: true},
+		ActorSubjectID:  sql.NullString{String: actorSubjectID, Valid: actorSubjectID != ""},
 		TargetSubjectID: sql.NullString{String: subjectID, Valid: true},
 		RequestID:       sql.NullString{},
 		SourceHash:      nil,
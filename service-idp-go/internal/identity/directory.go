@@ -0,0 +1,342 @@
+package identity
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database/sqlc"
+)
+
+var ErrDirectoryUnavailable = errors.New("directory unavailable")
+
+// DirectoryAccount is what a directory reports about a user whose password
+// it accepted.
+type DirectoryAccount struct {
+	DisplayName string
+	Groups      []string
+}
+
+type DirectoryAuthenticator interface {
+	// Authenticate returns ErrInvalidCredentials when the directory rejects
+	// the username or password, and any other error when it cannot answer.
+	Authenticate(ctx context.Context, username string, password string) (DirectoryAccount, error)
+}
+
+type DirectoryGroupRole struct {
+	Group    string
+	RoleCode string
+}
+
+// Directory takes over password checks for the login identifiers in its
+// Domains, such as zhangsan@corp.example for the domain corp.example.
+type Directory struct {
+	Name          string
+	Domains       []string
+	Authenticator DirectoryAuthenticator
+	// GroupRoles grants each role to the members of its group. Every role
+	// named here is managed by the directory: a subject from this directory
+	// that is no longer in any of the role's groups loses it at the next
+	// login, even when an administrator granted it by hand.
+	GroupRoles []DirectoryGroupRole
+}
+
+// MatchDirectory returns the directory whose domain follows the @ in
+// identifier.
+func MatchDirectory(directories []Directory, identifier string) (Directory, bool) {
+	_, domain, found := strings.Cut(strings.TrimSpace(identifier), "@")
+	if !found || domain == "" {
+		return Directory{}, false
+	}
+	for _, directory := range directories {
+		for _, candidate := range directory.Domains {
+			if strings.EqualFold(candidate, domain) {
+				return directory, true
+			}
+		}
+	}
+	return Directory{}, false
+}
+
+// DirectoryLogin opens a browser session for a subject whose password the
+// directory accepts. The first login creates the subject with its 账号
+// identifier and display name and no local password; every login refreshes
+// the display name and the roles the directory manages. An account
+// identifier that already belongs to a local subject, or to one from another
+// directory, is rejected rather than taken over. The login throttle, second
+// factor and audit trail work as they do for Login.
+func DirectoryLogin(ctx context.Context, database *sql.DB, directory Directory, input LoginInput, settings SessionSettings, throttleSettings LoginThrottleSettings) (LoginResult, error) {
+	if settings.TTL <= 0 || settings.IdleTTL <= 0 || settings.IdleTTL > settings.TTL {
+		return LoginResult{}, fmt.Errorf("invalid session settings")
+	}
+	if err := throttleSettings.validate(); err != nil {
+		return LoginResult{}, fmt.Errorf("invalid login throttle settings: %w", err)
+	}
+
+	queries := sqlc.New(database)
+	now := time.Now().UTC()
+	throttleKey := newLoginThrottleKey(throttleSettings, input.Identifier, input.SourceAddress)
+	locked, err := loginThrottleLocked(ctx, queries, throttleKey, now)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	// A locked identifier never reaches the directory, which may have a
+	// lockout policy of its own.
+	if locked {
+		return LoginResult{}, rejectLogin(ctx, database, throttleSettings, throttleKey, now)
+	}
+	identifier, err := normalizeAccountIdentifier(input.Identifier)
+	if err != nil {
+		return LoginResult{}, rejectLogin(ctx, database, throttleSettings, throttleKey, now)
+	}
+	account, err := directory.Authenticator.Authenticate(ctx, strings.TrimSpace(input.Identifier), input.Password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		return LoginResult{}, rejectLogin(ctx, database, throttleSettings, throttleKey, now)
+	}
+	if err != nil {
+		return LoginResult{}, fmt.Errorf("%w: %v", ErrDirectoryUnavailable, err)
+	}
+	sessionMetadata, err := encodeSessionMetadata(input.UserAgent)
+	if err != nil {
+		return LoginResult{}, err
+	}
+
+	now = time.Now().UTC()
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return LoginResult{}, fmt.Errorf("begin directory login transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+	locked, err = loginThrottleLocked(ctx, transactionQueries, throttleKey, now)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	if locked {
+		if err := recordLoginFailureInTransaction(ctx, transactionQueries, throttleSettings, throttleKey, now); err != nil {
+			return LoginResult{}, err
+		}
+		if err := transaction.Commit(); err != nil {
+			return LoginResult{}, fmt.Errorf("commit throttled directory login transaction: %w", err)
+		}
+		return LoginResult{}, ErrInvalidCredentials
+	}
+	subject, err := provisionDirectorySubject(ctx, transactionQueries, directory, identifier, account, now)
+	if errors.Is(err, ErrInvalidCredentials) {
+		// The failure is recorded outside this transaction, which has to end
+		// first.
+		transaction.Rollback()
+		return LoginResult{}, rejectLogin(ctx, database, throttleSettings, throttleKey, now)
+	}
+	if err != nil {
+		return LoginResult{}, err
+	}
+	if err := transactionQueries.DeleteLoginThrottle(ctx, sqlc.DeleteLoginThrottleParams{
+		IdentifierHash: throttleKey.identifierHash,
+		SourceHash:     throttleKey.sourceHash,
+	}); err != nil {
+		return LoginResult{}, fmt.Errorf("clear login throttle: %w", err)
+	}
+	sessionAccess, err := loginSessionAccess(ctx, transactionQueries, subject.ID, "有效")
+	if err != nil {
+		return LoginResult{}, err
+	}
+	auditMetadata := map[string]string{"factor": "directory", "directory": directory.Name}
+	if sessionAccess == "待二次验证" {
+		auditMetadata["second_factor"] = "pending"
+	}
+	encodedAuditMetadata, err := json.Marshal(auditMetadata)
+	if err != nil {
+		return LoginResult{}, fmt.Errorf("encode directory login audit metadata: %w", err)
+	}
+	result, err := openLoginSession(ctx, transactionQueries, subject.ID, subject.SecurityVersion, sessionAccess, sessionMetadata, string(encodedAuditMetadata), throttleKey.sourceHash, settings, now)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return LoginResult{}, fmt.Errorf("commit directory login transaction: %w", err)
+	}
+	return result, nil
+}
+
+// provisionDirectorySubject finds or creates the subject for identifier and
+// brings its display name and managed roles in line with account. It
+// returns ErrInvalidCredentials when the subject may not log in through
+// directory.
+func provisionDirectorySubject(ctx context.Context, queries sqlc.Querier, directory Directory, identifier string, account DirectoryAccount, now time.Time) (Subject, error) {
+	actor := directoryActor(directory.Name)
+	displayName, err := validateDisplayName(account.DisplayName)
+	syncDisplayName := err == nil
+	if !syncDisplayName {
+		displayName = identifier
+	}
+
+	subjectID, err := queries.GetIdentifierSubjectID(ctx, sqlc.GetIdentifierSubjectIDParams{
+		IdentifierType:  "账号",
+		NormalizedValue: identifier,
+	})
+	var subject Subject
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = ensureLoginIdentifierAvailable(ctx, queries, identifier, "")
+		if errors.Is(err, ErrIdentifierAlreadyExists) {
+			return Subject{}, ErrInvalidCredentials
+		}
+		if err != nil {
+			return Subject{}, err
+		}
+		if subjectID, err = createDirectorySubject(ctx, queries, actor, identifier, displayName, now); err != nil {
+			return Subject{}, err
+		}
+		if subject, err = getSubject(ctx, queries, subjectID); err != nil {
+			return Subject{}, err
+		}
+	case err != nil:
+		return Subject{}, fmt.Errorf("find directory subject: %w", err)
+	default:
+		owner, err := subjectDirectory(ctx, queries, subjectID)
+		if err != nil {
+			return Subject{}, err
+		}
+		if owner != directory.Name {
+			return Subject{}, ErrInvalidCredentials
+		}
+		if subject, err = getSubject(ctx, queries, subjectID); err != nil {
+			return Subject{}, err
+		}
+		if subject.Status != "启用" {
+			return Subject{}, ErrInvalidCredentials
+		}
+		if syncDisplayName && subject.DisplayName != displayName {
+			if err := updateSubjectDisplayName(ctx, queries, actor, subjectID, displayName, now); err != nil {
+				return Subject{}, err
+			}
+			subject.DisplayName = displayName
+		}
+	}
+	return syncDirectoryRoles(ctx, queries, actor, directory, subject, account.Groups, now)
+}
+
+func createDirectorySubject(ctx context.Context, queries sqlc.Querier, actor auditActor, identifier string, displayName string, now time.Time) (string, error) {
+	subjectID, err := NewULID(now)
+	if err != nil {
+		return "", err
+	}
+	identifierID, err := NewULID(now)
+	if err != nil {
+		return "", err
+	}
+	auditEventID, err := NewULID(now)
+	if err != nil {
+		return "", err
+	}
+	subjectMetadata, err := json.Marshal(map[string]string{"directory": actor.directory})
+	if err != nil {
+		return "", fmt.Errorf("encode directory subject metadata: %w", err)
+	}
+	if err := queries.CreateSubject(ctx, sqlc.CreateSubjectParams{
+		ID:              subjectID,
+		Status:          "启用",
+		SecurityVersion: 1,
+		DisabledAt:      sql.NullTime{},
+		Metadata:        string(subjectMetadata),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}); err != nil {
+		return "", fmt.Errorf("create directory subject: %w", err)
+	}
+	if err := queries.CreateProfile(ctx, sqlc.CreateProfileParams{
+		SubjectID:   subjectID,
+		DisplayName: displayName,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}); err != nil {
+		return "", fmt.Errorf("create directory subject profile: %w", err)
+	}
+	if err := queries.CreateIdentifier(ctx, sqlc.CreateIdentifierParams{
+		ID:              identifierID,
+		SubjectID:       subjectID,
+		IdentifierType:  "账号",
+		IdentifierValue: identifier,
+		NormalizedValue: identifier,
+		IdentifierUsage: "主登录",
+		Status:          "启用",
+		VerifiedAt:      sql.NullTime{},
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}); err != nil {
+		return "", fmt.Errorf("create directory account identifier: %w", err)
+	}
+	auditMetadata, err := json.Marshal(actor.annotate(map[string]string{}))
+	if err != nil {
+		return "", fmt.Errorf("encode directory subject audit metadata: %w", err)
+	}
+	if err := queries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
+		ID:              auditEventID,
+		EventAction:     "主体创建",
+		Outcome:         "成功",
+		ActorSubjectID:  actor.column(),
+		TargetSubjectID: sql.NullString{String: subjectID, Valid: true},
+		RequestID:       sql.NullString{},
+		SourceHash:      nil,
+		Metadata:        string(auditMetadata),
+		CreatedAt:       now,
+	}); err != nil {
+		return "", fmt.Errorf("write directory subject audit event: %w", err)
+	}
+	return subjectID, nil
+}
+
+// syncDirectoryRoles grants the managed roles whose groups include the
+// subject and revokes the rest.
+func syncDirectoryRoles(ctx context.Context, queries sqlc.Querier, actor auditActor, directory Directory, subject Subject, groups []string, now time.Time) (Subject, error) {
+	managed := []string{}
+	wanted := map[string]bool{}
+	for _, mapping := range directory.GroupRoles {
+		if !slices.Contains(managed, mapping.RoleCode) {
+			managed = append(managed, mapping.RoleCode)
+		}
+		for _, group := range groups {
+			if strings.EqualFold(group, mapping.Group) {
+				wanted[mapping.RoleCode] = true
+			}
+		}
+	}
+	for _, roleCode := range managed {
+		role, err := queries.GetRoleByCode(ctx, roleCode)
+		if errors.Is(err, sql.ErrNoRows) {
+			return Subject{}, fmt.Errorf("%w: directory %s maps a group to %s", ErrRoleNotFound, directory.Name, roleCode)
+		}
+		if err != nil {
+			return Subject{}, fmt.Errorf("get directory role: %w", err)
+		}
+		if subject, err = applySubjectRoleChange(ctx, queries, actor, subject, role, wanted[roleCode], now); err != nil {
+			return Subject{}, err
+		}
+	}
+	return subject, nil
+}
+
+// subjectDirectory returns the name of the directory that provisioned the
+// subject, or an empty string for a local subject.
+func subjectDirectory(ctx context.Context, queries sqlc.Querier, subjectID string) (string, error) {
+	record, err := queries.GetSubjectByID(ctx, subjectID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrSubjectNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("get subject: %w", err)
+	}
+	var metadata struct {
+		Directory string `json:"directory"`
+	}
+	if err := json.Unmarshal([]byte(record.Metadata), &metadata); err != nil {
+		return "", fmt.Errorf("decode subject metadata: %w", err)
+	}
+	return metadata.Directory, nil
+}
@@ -0,0 +1,248 @@
+package identity_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/totp"
+)
+
+// fakeDirectory 按用户名保存目录密码和账户信息，并记录被调用的次数。
+type fakeDirectory struct {
+	passwords   map[string]string
+	accounts    map[string]identity.DirectoryAccount
+	unavailable bool
+	calls       int
+}
+
+func (directory *fakeDirectory) Authenticate(ctx context.Context, username string, password string) (identity.DirectoryAccount, error) {
+	directory.calls++
+	if directory.unavailable {
+		return identity.DirectoryAccount{}, errors.New("connection refused")
+	}
+	if want, found := directory.passwords[strings.ToLower(username)]; !found || want != password {
+		return identity.DirectoryAccount{}, identity.ErrInvalidCredentials
+	}
+	return directory.accounts[strings.ToLower(username)], nil
+}
+
+func testDirectory(authenticator *fakeDirectory) identity.Directory {
+	return identity.Directory{
+		Name:          "corp",
+		Domains:       []string{"corp.example.test"},
+		Authenticator: authenticator,
+		GroupRoles: []identity.DirectoryGroupRole{
+			{Group: "CN=CRM Users,OU=Groups,DC=corp", RoleCode: "crm.viewer"},
+			{Group: "CN=CRM Editors,OU=Groups,DC=corp", RoleCode: "crm.editor"},
+		},
+	}
+}
+
+func directoryLogin(ctx context.Context, databaseConnection *sql.DB, directory identity.Directory, identifierValue string, passwordValue string) (identity.LoginResult, error) {
+	return identity.DirectoryLogin(ctx, databaseConnection, directory, identity.LoginInput{
+		Identifier:    identifierValue,
+		Password:      passwordValue,
+		SourceAddress: "192.0.2.1",
+	}, testSessionSettings, testLoginThrottleSettings)
+}
+
+func TestMatchDirectoryUsesIdentifierDomain(t *testing.T) {
+	directories := []identity.Directory{testDirectory(&fakeDirectory{})}
+	if directory, found := identity.MatchDirectory(directories, " ZhangSan@Corp.Example.Test "); !found || directory.Name != "corp" {
+		t.Fatalf("match = %#v, %t", directory, found)
+	}
+	for _, identifier := range []string{"zhangsan", "zhangsan@example.test", "zhangsan@sub.corp.example.test"} {
+		if _, found := identity.MatchDirectory(directories, identifier); found {
+			t.Fatalf("%s matched a directory", identifier)
+		}
+	}
+}
+
+func TestDirectoryLoginProvisionsSubjectAndSyncsRoles(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	for _, roleCode := range []string{"crm.viewer", "crm.editor"} {
+		if _, err := identity.CreateRole(ctx, databaseConnection, administrator.ID, identity.CreateRoleInput{RoleCode: roleCode, DisplayName: roleCode}); err != nil {
+			t.Fatalf("create role %s: %v", roleCode, err)
+		}
+	}
+	authenticator := &fakeDirectory{
+		passwords: map[string]string{"zhangsan@corp.example.test": "directory password"},
+		accounts: map[string]identity.DirectoryAccount{"zhangsan@corp.example.test": {
+			DisplayName: "张三",
+			Groups:      []string{"cn=crm users,ou=groups,dc=corp"},
+		}},
+	}
+	directory := testDirectory(authenticator)
+
+	// 首次登录创建主体、账号标识和资料，并按组授予角色。
+	first, err := directoryLogin(ctx, databaseConnection, directory, "ZhangSan@corp.example.test", "directory password")
+	if err != nil || first.Access != "完整" {
+		t.Fatalf("first directory login = %#v, %v", first, err)
+	}
+	session, err := identity.CurrentSession(ctx, databaseConnection, first.SessionToken, testSessionSettings)
+	if err != nil {
+		t.Fatalf("current session: %v", err)
+	}
+	subject, err := identity.GetSubject(ctx, databaseConnection, session.SubjectID)
+	if err != nil || subject.DisplayName != "张三" || subject.Identifier != "zhangsan@corp.example.test" || !slices.Equal(subject.Roles, []string{"crm.viewer"}) {
+		t.Fatalf("provisioned subject = %#v, %v", subject, err)
+	}
+	// 目录主体没有本地密码，密码登录无法绕过目录。
+	if _, err := loginWithSource(ctx, databaseConnection, "zhangsan@corp.example.test", "directory password", "192.0.2.1"); !errors.Is(err, identity.ErrInvalidCredentials) {
+		t.Fatalf("local login for directory subject error = %v", err)
+	}
+
+	// 后续登录同步显示名称；不在组内的受管角色被撤销，包括管理员手工授予的。
+	if _, err := identity.GrantSubjectRole(ctx, databaseConnection, administrator.ID, subject.ID, "crm.editor"); err != nil {
+		t.Fatalf("grant managed role: %v", err)
+	}
+	authenticator.accounts["zhangsan@corp.example.test"] = identity.DirectoryAccount{
+		DisplayName: "张三丰",
+		Groups:      []string{"CN=Other,OU=Groups,DC=corp"},
+	}
+	second, err := directoryLogin(ctx, databaseConnection, directory, "zhangsan@corp.example.test", "directory password")
+	if err != nil {
+		t.Fatalf("second directory login: %v", err)
+	}
+	synced, err := identity.GetSubject(ctx, databaseConnection, subject.ID)
+	if err != nil || synced.DisplayName != "张三丰" || len(synced.Roles) != 0 {
+		t.Fatalf("synced subject = %#v, %v", synced, err)
+	}
+	if _, err := identity.CurrentSession(ctx, databaseConnection, first.SessionToken, testSessionSettings); !errors.Is(err, identity.ErrInvalidSession) {
+		t.Fatalf("session after role revocation error = %v", err)
+	}
+	if _, err := identity.CurrentSession(ctx, databaseConnection, second.SessionToken, testSessionSettings); err != nil {
+		t.Fatalf("session after sync: %v", err)
+	}
+
+	var provisioned, directoryRoleChanges, logins int
+	if err := databaseConnection.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM identity_audit_events WHERE event_action = '主体创建' AND target_subject_id = ? AND actor_subject_id IS NULL AND metadata = '{"directory":"corp","source":"LDAP"}'),
+			(SELECT COUNT(*) FROM identity_audit_events WHERE event_action IN ('角色授予', '角色撤销') AND target_subject_id = ? AND actor_subject_id IS NULL),
+			(SELECT COUNT(*) FROM identity_audit_events WHERE event_action = '登录' AND actor_subject_id = ? AND metadata = '{"directory":"corp","factor":"directory"}')
+	`, subject.ID, subject.ID, subject.ID).Scan(&provisioned, &directoryRoleChanges, &logins); err != nil {
+		t.Fatalf("count directory audit events: %v", err)
+	}
+	if provisioned != 1 || directoryRoleChanges != 3 || logins != 2 {
+		t.Fatalf("directory audit events = created:%d roles:%d logins:%d", provisioned, directoryRoleChanges, logins)
+	}
+
+	// 目录主体同样受 TOTP 约束，验证后获得完整会话。
+	secondSession, err := identity.CurrentSession(ctx, databaseConnection, second.SessionToken, testSessionSettings)
+	if err != nil {
+		t.Fatalf("second session: %v", err)
+	}
+	enrollment, err := identity.StartTOTPEnrollment(ctx, databaseConnection, subject.ID)
+	if err != nil {
+		t.Fatalf("start enrollment: %v", err)
+	}
+	secret := decodeTOTPSecret(t, enrollment.Secret)
+	step := totp.Step(time.Now())
+	if _, _, err := identity.ConfirmTOTPEnrollment(ctx, databaseConnection, secondSession, totp.Code(secret, step)); err != nil {
+		t.Fatalf("confirm enrollment: %v", err)
+	}
+	pending, err := directoryLogin(ctx, databaseConnection, directory, "zhangsan@corp.example.test", "directory password")
+	if err != nil || pending.Access != "待二次验证" {
+		t.Fatalf("directory login after enrollment = %#v, %v", pending, err)
+	}
+	pendingSession, err := identity.CurrentSession(ctx, databaseConnection, pending.SessionToken, testSessionSettings)
+	if err != nil {
+		t.Fatalf("pending session: %v", err)
+	}
+	access, err := identity.VerifySecondFactor(ctx, databaseConnection, pendingSession, testLoginThrottleSettings, identity.VerifySecondFactorInput{
+		Code:          totp.Code(secret, step+1),
+		SourceAddress: "192.0.2.1",
+	})
+	if err != nil || access != "完整" {
+		t.Fatalf("verify second factor = %q, %v", access, err)
+	}
+}
+
+func TestDirectoryLoginRefusesLocalSubjectsAndKeepsThrottle(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	if _, err := identity.CreateRole(ctx, databaseConnection, administrator.ID, identity.CreateRoleInput{RoleCode: "crm.viewer", DisplayName: "CRM 查看"}); err != nil {
+		t.Fatalf("create crm.viewer: %v", err)
+	}
+	if _, err := identity.CreateRole(ctx, databaseConnection, administrator.ID, identity.CreateRoleInput{RoleCode: "crm.editor", DisplayName: "CRM 编辑"}); err != nil {
+		t.Fatalf("create crm.editor: %v", err)
+	}
+	local, err := identity.CreateSubject(ctx, databaseConnection, administrator.ID, identity.CreateSubjectInput{
+		DisplayName: "李四",
+		Identifier:  "lisi@corp.example.test",
+		Password:    "a sufficiently long password",
+	}, testPasswordPolicy)
+	if err != nil {
+		t.Fatalf("create local subject: %v", err)
+	}
+	authenticator := &fakeDirectory{
+		passwords: map[string]string{
+			"lisi@corp.example.test":   "directory password",
+			"wangwu@corp.example.test": "directory password",
+		},
+		accounts: map[string]identity.DirectoryAccount{},
+	}
+	directory := testDirectory(authenticator)
+
+	// 目录不能接管同名的本地主体。
+	if _, err := directoryLogin(ctx, databaseConnection, directory, "lisi@corp.example.test", "directory password"); !errors.Is(err, identity.ErrInvalidCredentials) {
+		t.Fatalf("takeover login error = %v", err)
+	}
+	if unchanged, err := identity.GetSubject(ctx, databaseConnection, local.ID); err != nil || unchanged.DisplayName != "李四" {
+		t.Fatalf("local subject after refused takeover = %#v, %v", unchanged, err)
+	}
+
+	// 目录不可用不算登录失败；显示名称缺失时使用账号标识。
+	authenticator.unavailable = true
+	if _, err := directoryLogin(ctx, databaseConnection, directory, "wangwu@corp.example.test", "directory password"); !errors.Is(err, identity.ErrDirectoryUnavailable) {
+		t.Fatalf("unavailable directory error = %v", err)
+	}
+	authenticator.unavailable = false
+	login, err := directoryLogin(ctx, databaseConnection, directory, "wangwu@corp.example.test", "directory password")
+	if err != nil {
+		t.Fatalf("directory login: %v", err)
+	}
+	session, err := identity.CurrentSession(ctx, databaseConnection, login.SessionToken, testSessionSettings)
+	if err != nil {
+		t.Fatalf("current session: %v", err)
+	}
+	if subject, err := identity.GetSubject(ctx, databaseConnection, session.SubjectID); err != nil || subject.DisplayName != "wangwu@corp.example.test" {
+		t.Fatalf("subject without directory display name = %#v, %v", subject, err)
+	}
+
+	// 锁定后不再询问目录。
+	for attempt := 0; attempt < testLoginThrottleSettings.FailureLimit; attempt++ {
+		if _, err := directoryLogin(ctx, databaseConnection, directory, "wangwu@corp.example.test", "wrong password"); !errors.Is(err, identity.ErrInvalidCredentials) {
+			t.Fatalf("failed directory login %d error = %v", attempt+1, err)
+		}
+	}
+	calls := authenticator.calls
+	if _, err := directoryLogin(ctx, databaseConnection, directory, "wangwu@corp.example.test", "directory password"); !errors.Is(err, identity.ErrInvalidCredentials) {
+		t.Fatalf("locked directory login error = %v", err)
+	}
+	if authenticator.calls != calls {
+		t.Fatalf("locked login reached the directory")
+	}
+
+	// 被禁用的目录主体不能登录。
+	if _, err := identity.DisableSubject(ctx, databaseConnection, administrator.ID, session.SubjectID); err != nil {
+		t.Fatalf("disable directory subject: %v", err)
+	}
+	if _, err := identity.DirectoryLogin(ctx, databaseConnection, directory, identity.LoginInput{
+		Identifier:    "wangwu@corp.example.test",
+		Password:      "directory password",
+		SourceAddress: "198.51.100.2",
+	}, testSessionSettings, testLoginThrottleSettings); !errors.Is(err, identity.ErrInvalidCredentials) {
+		t.Fatalf("disabled directory subject login error = %v", err)
+	}
+}
@@ -0,0 +1,483 @@
+package identity
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database/sqlc"
+)
+
+var ErrIdentifierNotFound = errors.New("identifier not found")
+var ErrInvalidIdentifierInput = errors.New("invalid identifier input")
+var ErrPrimaryIdentifier = errors.New("the primary login identifier cannot be disabled, demoted or deleted")
+var ErrIdentifierNotVerified = errors.New("identifier must be verified before it can be used to sign in")
+
+// IdentifierTypes and IdentifierUsages list the values accepted by
+// identity_identifiers.
+var IdentifierTypes = []string{"账号", "邮箱", "手机号", "工号"}
+var IdentifierUsages = []string{"主登录", "辅助登录", "联系"}
+
+// Identifier is one of a subject's identifiers. Every subject has exactly one
+// 主登录 identifier; 辅助登录 identifiers also sign in, and 联系 identifiers
+// only receive messages.
+type Identifier struct {
+	ID              string     `json:"id"`
+	SubjectID       string     `json:"subject_id"`
+	Type            string     `json:"identifier_type"`
+	Value           string     `json:"identifier_value"`
+	NormalizedValue string     `json:"normalized_value"`
+	Usage           string     `json:"identifier_usage"`
+	Status          string     `json:"status"`
+	VerifiedAt      *time.Time `json:"verified_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type CreateIdentifierInput struct {
+	Type  string
+	Value string
+	Usage string
+}
+
+// UpdateIdentifierInput carries a partial update; nil fields keep their
+// stored value. Setting Usage to 主登录 demotes the previous primary
+// identifier to 辅助登录.
+type UpdateIdentifierInput struct {
+	Usage  *string
+	Status *string
+}
+
+func ListSubjectIdentifiers(ctx context.Context, database *sql.DB, subjectID string) ([]Identifier, error) {
+	queries := sqlc.New(database)
+	if _, err := getSubject(ctx, queries, subjectID); err != nil {
+		return nil, err
+	}
+	records, err := queries.ListSubjectIdentifiers(ctx, subjectID)
+	if err != nil {
+		return nil, fmt.Errorf("list subject identifiers: %w", err)
+	}
+	identifiers := make([]Identifier, 0, len(records))
+	for _, record := range records {
+		identifiers = append(identifiers, identifierFromRecord(record))
+	}
+	return identifiers, nil
+}
+
+func GetSubjectIdentifier(ctx context.Context, database *sql.DB, subjectID string, identifierID string) (Identifier, error) {
+	record, err := getSubjectIdentifier(ctx, sqlc.New(database), subjectID, identifierID)
+	if err != nil {
+		return Identifier{}, err
+	}
+	return identifierFromRecord(record), nil
+}
+
+// CreateSubjectIdentifier adds a 辅助登录 or 联系 identifier. A new 邮箱 or
+// 手机号 identifier is unverified, so it can only be added as 联系 and be
+// promoted after verification; the primary identifier is changed with
+// UpdateSubjectIdentifier.
+func CreateSubjectIdentifier(ctx context.Context, database *sql.DB, actorSubjectID string, subjectID string, input CreateIdentifierInput) (Identifier, error) {
+	if input.Usage != "辅助登录" && input.Usage != "联系" {
+		return Identifier{}, fmt.Errorf("%w: identifier_usage must be 辅助登录 or 联系", ErrInvalidIdentifierInput)
+	}
+	normalizedValue, err := normalizeIdentifier(input.Type, input.Value)
+	if err != nil {
+		return Identifier{}, fmt.Errorf("%w: %v", ErrInvalidIdentifierInput, err)
+	}
+	if input.Usage == "辅助登录" && identifierRequiresVerification(input.Type) {
+		return Identifier{}, ErrIdentifierNotVerified
+	}
+
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return Identifier{}, fmt.Errorf("begin create identifier transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	if subject, err := getSubject(ctx, transactionQueries, subjectID); err != nil {
+		return Identifier{}, err
+	} else if subject.Status == "已删除" {
+		return Identifier{}, ErrSubjectErased
+	}
+	_, err = transactionQueries.GetIdentifierSubjectID(ctx, sqlc.GetIdentifierSubjectIDParams{
+		IdentifierType:  input.Type,
+		NormalizedValue: normalizedValue,
+	})
+	if err == nil {
+		return Identifier{}, ErrIdentifierAlreadyExists
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return Identifier{}, fmt.Errorf("check identifier: %w", err)
+	}
+	if input.Usage == "辅助登录" {
+		if err := ensureLoginIdentifierAvailable(ctx, transactionQueries, normalizedValue, ""); err != nil {
+			return Identifier{}, err
+		}
+	}
+
+	now := time.Now().UTC()
+	identifierID, err := NewULID(now)
+	if err != nil {
+		return Identifier{}, err
+	}
+	record := sqlc.IdentityIdentifier{
+		ID:              identifierID,
+		SubjectID:       subjectID,
+		IdentifierType:  input.Type,
+		IdentifierValue: strings.TrimSpace(input.Value),
+		NormalizedValue: normalizedValue,
+		IdentifierUsage: input.Usage,
+		Status:          "启用",
+		VerifiedAt:      sql.NullTime{},
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := transactionQueries.CreateIdentifier(ctx, sqlc.CreateIdentifierParams{
+		ID:              record.ID,
+		SubjectID:       record.SubjectID,
+		IdentifierType:  record.IdentifierType,
+		IdentifierValue: record.IdentifierValue,
+		NormalizedValue: record.NormalizedValue,
+		IdentifierUsage: record.IdentifierUsage,
+		Status:          record.Status,
+		VerifiedAt:      record.VerifiedAt,
+		CreatedAt:       record.CreatedAt,
+		UpdatedAt:       record.UpdatedAt,
+	}); err != nil {
+		return Identifier{}, fmt.Errorf("create identifier: %w", err)
+	}
+	if err := insertIdentifierAuditEvent(ctx, transactionQueries, subjectActor(actorSubjectID), record, "创建", now); err != nil {
+		return Identifier{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return Identifier{}, fmt.Errorf("commit create identifier transaction: %w", err)
+	}
+	return identifierFromRecord(record), nil
+}
+
+// UpdateSubjectIdentifier changes the usage or status of an identifier while
+// keeping exactly one enabled 主登录 identifier per subject. Only 账号 and 邮箱
+// identifiers can become primary, and 邮箱 and 手机号 identifiers must be
+// verified before they can sign in.
+func UpdateSubjectIdentifier(ctx context.Context, database *sql.DB, actorSubjectID string, subjectID string, identifierID string, input UpdateIdentifierInput) (Identifier, error) {
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return Identifier{}, fmt.Errorf("begin update identifier transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	record, err := getSubjectIdentifier(ctx, transactionQueries, subjectID, identifierID)
+	if err != nil {
+		return Identifier{}, err
+	}
+	previous := record
+	if input.Usage != nil {
+		if !slices.Contains(IdentifierUsages, *input.Usage) {
+			return Identifier{}, fmt.Errorf("%w: identifier_usage must be 主登录, 辅助登录 or 联系", ErrInvalidIdentifierInput)
+		}
+		record.IdentifierUsage = *input.Usage
+	}
+	if input.Status != nil {
+		if *input.Status != "启用" && *input.Status != "禁用" {
+			return Identifier{}, fmt.Errorf("%w: status must be 启用 or 禁用", ErrInvalidIdentifierInput)
+		}
+		record.Status = *input.Status
+	}
+	if previous.IdentifierUsage == "主登录" && (record.IdentifierUsage != "主登录" || record.Status != "启用") {
+		return Identifier{}, ErrPrimaryIdentifier
+	}
+	if record.IdentifierUsage == "主登录" {
+		if record.IdentifierType != "账号" && record.IdentifierType != "邮箱" {
+			return Identifier{}, fmt.Errorf("%w: only 账号 and 邮箱 identifiers can be primary", ErrInvalidIdentifierInput)
+		}
+		if record.Status != "启用" {
+			return Identifier{}, fmt.Errorf("%w: a disabled identifier cannot be primary", ErrInvalidIdentifierInput)
+		}
+	}
+	loginUsage := record.IdentifierUsage == "主登录" || record.IdentifierUsage == "辅助登录"
+	if loginUsage && identifierRequiresVerification(record.IdentifierType) && !record.VerifiedAt.Valid {
+		return Identifier{}, ErrIdentifierNotVerified
+	}
+	if record == previous {
+		if err := transaction.Commit(); err != nil {
+			return Identifier{}, fmt.Errorf("commit unchanged identifier: %w", err)
+		}
+		return identifierFromRecord(record), nil
+	}
+	if loginUsage {
+		if err := ensureLoginIdentifierAvailable(ctx, transactionQueries, record.NormalizedValue, record.ID); err != nil {
+			return Identifier{}, err
+		}
+	}
+
+	now := time.Now().UTC()
+	if record.IdentifierUsage == "主登录" && previous.IdentifierUsage != "主登录" {
+		// The partial unique index allows one 主登录 row per subject, so the
+		// current primary is demoted before the new one is promoted.
+		if err := demotePrimaryIdentifier(ctx, transactionQueries, subjectID, now); err != nil {
+			return Identifier{}, err
+		}
+	}
+	updated, err := transactionQueries.UpdateIdentifierUsageAndStatus(ctx, sqlc.UpdateIdentifierUsageAndStatusParams{
+		IdentifierUsage: record.IdentifierUsage,
+		Status:          record.Status,
+		UpdatedAt:       now,
+		ID:              record.ID,
+		SubjectID:       subjectID,
+	})
+	if err != nil {
+		return Identifier{}, fmt.Errorf("update identifier: %w", err)
+	}
+	if updated != 1 {
+		return Identifier{}, ErrIdentifierNotFound
+	}
+	record.UpdatedAt = now
+	if err := insertIdentifierAuditEvent(ctx, transactionQueries, subjectActor(actorSubjectID), record, "更新", now); err != nil {
+		return Identifier{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return Identifier{}, fmt.Errorf("commit update identifier transaction: %w", err)
+	}
+	return identifierFromRecord(record), nil
+}
+
+// DeleteSubjectIdentifier removes any identifier except the primary one,
+// together with a pending verification code.
+func DeleteSubjectIdentifier(ctx context.Context, database *sql.DB, actorSubjectID string, subjectID string, identifierID string) error {
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin delete identifier transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	record, err := getSubjectIdentifier(ctx, transactionQueries, subjectID, identifierID)
+	if err != nil {
+		return err
+	}
+	if record.IdentifierUsage == "主登录" {
+		return ErrPrimaryIdentifier
+	}
+	deleted, err := transactionQueries.DeleteSubjectIdentifier(ctx, sqlc.DeleteSubjectIdentifierParams{
+		ID:              identifierID,
+		SubjectID:       subjectID,
+		IdentifierUsage: "主登录",
+	})
+	if err != nil {
+		return fmt.Errorf("delete identifier: %w", err)
+	}
+	if deleted != 1 {
+		return ErrIdentifierNotFound
+	}
+	now := time.Now().UTC()
+	if err := insertIdentifierAuditEvent(ctx, transactionQueries, subjectActor(actorSubjectID), record, "删除", now); err != nil {
+		return err
+	}
+	if err := transaction.Commit(); err != nil {
+		return fmt.Errorf("commit delete identifier transaction: %w", err)
+	}
+	return nil
+}
+
+func getSubjectIdentifier(ctx context.Context, queries sqlc.Querier, subjectID string, identifierID string) (sqlc.IdentityIdentifier, error) {
+	record, err := queries.GetSubjectIdentifier(ctx, sqlc.GetSubjectIdentifierParams{
+		ID:        identifierID,
+		SubjectID: subjectID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return sqlc.IdentityIdentifier{}, ErrIdentifierNotFound
+	}
+	if err != nil {
+		return sqlc.IdentityIdentifier{}, fmt.Errorf("get subject identifier: %w", err)
+	}
+	return record, nil
+}
+
+func demotePrimaryIdentifier(ctx context.Context, queries sqlc.Querier, subjectID string, now time.Time) error {
+	records, err := queries.ListSubjectIdentifiers(ctx, subjectID)
+	if err != nil {
+		return fmt.Errorf("list subject identifiers: %w", err)
+	}
+	for _, record := range records {
+		if record.IdentifierUsage != "主登录" {
+			continue
+		}
+		if _, err := queries.UpdateIdentifierUsageAndStatus(ctx, sqlc.UpdateIdentifierUsageAndStatusParams{
+			IdentifierUsage: "辅助登录",
+			Status:          record.Status,
+			UpdatedAt:       now,
+			ID:              record.ID,
+			SubjectID:       subjectID,
+		}); err != nil {
+			return fmt.Errorf("demote primary identifier: %w", err)
+		}
+	}
+	return nil
+}
+
+// ensureLoginIdentifierAvailable rejects a login identifier whose normalized
+// value another login identifier of any type already uses, because sign-in
+// looks identifiers up by normalized value alone.
+func ensureLoginIdentifierAvailable(ctx context.Context, queries sqlc.Querier, normalizedValue string, identifierID string) error {
+	count, err := queries.CountOtherLoginIdentifiersByNormalizedValue(ctx, sqlc.CountOtherLoginIdentifiersByNormalizedValueParams{
+		NormalizedValue:   normalizedValue,
+		IdentifierUsage:   "主登录",
+		IdentifierUsage_2: "辅助登录",
+		ID:                identifierID,
+	})
+	if err != nil {
+		return fmt.Errorf("check login identifier: %w", err)
+	}
+	if count > 0 {
+		return ErrIdentifierAlreadyExists
+	}
+	return nil
+}
+
+func insertIdentifierAuditEvent(ctx context.Context, queries sqlc.Querier, actor auditActor, record sqlc.IdentityIdentifier, change string, now time.Time) error {
+	auditEventID, err := NewULID(now)
+	if err != nil {
+		return err
+	}
+	// The identifier value is personal data and stays out of the audit log.
+	metadata, err := json.Marshal(actor.annotate(map[string]string{
+		"identifier_id":    record.ID,
+		"identifier_type":  record.IdentifierType,
+		"identifier_usage": record.IdentifierUsage,
+		"status":           record.Status,
+		"change":           change,
+	}))
+	if err != nil {
+		return fmt.Errorf("encode identifier audit metadata: %w", err)
+	}
+	if err := queries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
+		ID:              auditEventID,
+		EventAction:     "标识符变更",
+		Outcome:         "成功",
+		ActorSubjectID:  actor.column(),
+		TargetSubjectID: sql.NullString{String: record.SubjectID, Valid: true},
+		RequestID:       sql.NullString{},
+		SourceHash:      nil,
+		Metadata:        string(metadata),
+		CreatedAt:       now,
+	}); err != nil {
+		return fmt.Errorf("write identifier audit event: %w", err)
+	}
+	return nil
+}
+
+func identifierFromRecord(record sqlc.IdentityIdentifier) Identifier {
+	identifier := Identifier{
+		ID:              record.ID,
+		SubjectID:       record.SubjectID,
+		Type:            record.IdentifierType,
+		Value:           record.IdentifierValue,
+		NormalizedValue: record.NormalizedValue,
+		Usage:           record.IdentifierUsage,
+		Status:          record.Status,
+		CreatedAt:       record.CreatedAt,
+		UpdatedAt:       record.UpdatedAt,
+	}
+	if record.VerifiedAt.Valid {
+		verifiedAt := record.VerifiedAt.Time
+		identifier.VerifiedAt = &verifiedAt
+	}
+	return identifier
+}
+
+// identifierRequiresVerification reports whether the subject must prove
+// control of the identifier before it can sign in. 账号 and 工号 values are
+// assigned by an administrator instead.
+func identifierRequiresVerification(identifierType string) bool {
+	return identifierType == "邮箱" || identifierType == "手机号"
+}
+
+func normalizeIdentifier(identifierType string, value string) (string, error) {
+	switch identifierType {
+	case "账号":
+		return normalizeAccountIdentifier(value)
+	case "邮箱":
+		value = strings.ToLower(strings.TrimSpace(value))
+		if len(value) > 320 {
+			return "", fmt.Errorf("email identifier must contain at most 320 characters")
+		}
+		return normalizeEmailIdentifier(value)
+	case "手机号":
+		return normalizePhoneNumber(value)
+	case "工号":
+		return normalizeEmployeeNumber(value)
+	}
+	return "", fmt.Errorf("identifier_type must be 账号, 邮箱, 手机号 or 工号")
+}
+
+// normalizePhoneNumber returns the E.164 form of a phone number. Spaces,
+// hyphens, dots and parentheses are ignored, and an 11-digit number starting
+// with 1 is a mainland China mobile number.
+func normalizePhoneNumber(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	international := strings.HasPrefix(value, "+")
+	if international {
+		value = value[1:]
+	}
+	digits := make([]byte, 0, len(value))
+	for _, character := range value {
+		switch {
+		case character >= '0' && character <= '9':
+			digits = append(digits, byte(character))
+		case character == ' ' || character == '-' || character == '.' || character == '(' || character == ')':
+		default:
+			return "", fmt.Errorf("phone number contains an unsupported character")
+		}
+	}
+	if !international {
+		if len(digits) != 11 || digits[0] != '1' {
+			return "", fmt.Errorf("phone number must be in E.164 form or an 11-digit mainland number")
+		}
+		return "+86" + string(digits), nil
+	}
+	if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return "", fmt.Errorf("phone number must contain 8 to 15 digits after the country code prefix")
+	}
+	return "+" + string(digits), nil
+}
+
+func normalizeEmployeeNumber(value string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(value))
+	if len(normalized) < 1 || len(normalized) > 32 {
+		return "", fmt.Errorf("employee number must contain 1 to 32 characters")
+	}
+	for _, character := range normalized {
+		if (character >= 'a' && character <= 'z') ||
+			(character >= '0' && character <= '9') ||
+			character == '_' || character == '-' {
+			continue
+		}
+		return "", fmt.Errorf("employee number contains an unsupported character")
+	}
+	return normalized, nil
+}
+
+// loginIdentifierCandidates returns the distinct normalized forms an entered
+// sign-in identifier can take: an account or email address, a phone number,
+// or an employee number. Login uniqueness across types makes at most one of
+// them match.
+func loginIdentifierCandidates(value string) []string {
+	candidates := []string{}
+	for _, normalize := range []func(string) (string, error){normalizeAccountIdentifier, normalizePhoneNumber, normalizeEmployeeNumber} {
+		normalized, err := normalize(value)
+		if err == nil && !slices.Contains(candidates, normalized) {
+			candidates = append(candidates, normalized)
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,308 @@
+package identity_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+// capturingSender records every code it is handed. A non-nil err fails the
+// delivery after recording the code.
+type capturingSender struct {
+	codes []identity.VerificationCode
+	err   error
+}
+
+func (sender *capturingSender) SendVerificationCode(_ context.Context, code identity.VerificationCode) error {
+	sender.codes = append(sender.codes, code)
+	return sender.err
+}
+
+func TestManageSubjectIdentifiersKeepsSinglePrimaryAndLoginUniqueness(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	subject, err := identity.CreateSubject(ctx, databaseConnection, administrator.ID, identity.CreateSubjectInput{
+		DisplayName: "李四",
+		Identifier:  "lisi",
+		Password:    "a sufficiently long password",
+	}, testPasswordPolicy)
+	if err != nil {
+		t.Fatalf("create subject: %v", err)
+	}
+
+	employee, err := identity.CreateSubjectIdentifier(ctx, databaseConnection, administrator.ID, subject.ID, identity.CreateIdentifierInput{
+		Type:  "工号",
+		Value: " E-1024 ",
+		Usage: "辅助登录",
+	})
+	if err != nil {
+		t.Fatalf("create employee number: %v", err)
+	}
+	if employee.NormalizedValue != "e-1024" || employee.Value != "E-1024" {
+		t.Fatalf("employee number = %#v", employee)
+	}
+	phone, err := identity.CreateSubjectIdentifier(ctx, databaseConnection, administrator.ID, subject.ID, identity.CreateIdentifierInput{
+		Type:  "手机号",
+		Value: "138 0013-8000",
+		Usage: "联系",
+	})
+	if err != nil {
+		t.Fatalf("create phone number: %v", err)
+	}
+	if phone.NormalizedValue != "+8613800138000" || phone.VerifiedAt != nil {
+		t.Fatalf("phone number = %#v", phone)
+	}
+
+	// 同一手机号的另一种写法归一化后重复。
+	if _, err := identity.CreateSubjectIdentifier(ctx, databaseConnection, administrator.ID, administrator.ID, identity.CreateIdentifierInput{
+		Type:  "手机号",
+		Value: "+86 13800138000",
+		Usage: "联系",
+	}); !errors.Is(err, identity.ErrIdentifierAlreadyExists) {
+		t.Fatalf("duplicate phone error = %v", err)
+	}
+	// 登录标识符的归一化值跨类型唯一，工号不能与他人的账号相同。
+	if _, err := identity.CreateSubjectIdentifier(ctx, databaseConnection, administrator.ID, subject.ID, identity.CreateIdentifierInput{
+		Type:  "工号",
+		Value: "admin",
+		Usage: "辅助登录",
+	}); !errors.Is(err, identity.ErrIdentifierAlreadyExists) {
+		t.Fatalf("cross-type login identifier error = %v", err)
+	}
+	if _, err := identity.CreateSubjectIdentifier(ctx, databaseConnection, administrator.ID, subject.ID, identity.CreateIdentifierInput{
+		Type:  "手机号",
+		Value: "12345",
+		Usage: "联系",
+	}); !errors.Is(err, identity.ErrInvalidIdentifierInput) {
+		t.Fatalf("invalid phone error = %v", err)
+	}
+	if _, err := identity.CreateSubjectIdentifier(ctx, databaseConnection, administrator.ID, subject.ID, identity.CreateIdentifierInput{
+		Type:  "邮箱",
+		Value: "lisi@example.test",
+		Usage: "辅助登录",
+	}); !errors.Is(err, identity.ErrIdentifierNotVerified) {
+		t.Fatalf("unverified login email error = %v", err)
+	}
+
+	// 工号作为辅助登录标识符可以直接登录。
+	if _, err := identity.Login(ctx, databaseConnection, identity.LoginInput{
+		Identifier:    "E-1024",
+		Password:      "a sufficiently long password",
+		SourceAddress: "192.0.2.1",
+	}, testSessionSettings, testLoginThrottleSettings, testPasswordPolicy); err != nil {
+		t.Fatalf("login with employee number: %v", err)
+	}
+
+	identifiers, err := identity.ListSubjectIdentifiers(ctx, databaseConnection, subject.ID)
+	if err != nil {
+		t.Fatalf("list identifiers: %v", err)
+	}
+	if len(identifiers) != 3 || identifiers[0].Usage != "主登录" || identifiers[0].Type != "账号" {
+		t.Fatalf("identifiers = %#v", identifiers)
+	}
+	account := identifiers[0]
+
+	disabled := "禁用"
+	if _, err := identity.UpdateSubjectIdentifier(ctx, databaseConnection, administrator.ID, subject.ID, account.ID, identity.UpdateIdentifierInput{Status: &disabled}); !errors.Is(err, identity.ErrPrimaryIdentifier) {
+		t.Fatalf("disable primary error = %v", err)
+	}
+	if err := identity.DeleteSubjectIdentifier(ctx, databaseConnection, administrator.ID, subject.ID, account.ID); !errors.Is(err, identity.ErrPrimaryIdentifier) {
+		t.Fatalf("delete primary error = %v", err)
+	}
+	primary := "主登录"
+	if _, err := identity.UpdateSubjectIdentifier(ctx, databaseConnection, administrator.ID, subject.ID, employee.ID, identity.UpdateIdentifierInput{Usage: &primary}); !errors.Is(err, identity.ErrInvalidIdentifierInput) {
+		t.Fatalf("employee number as primary error = %v", err)
+	}
+
+	if _, err := identity.UpdateSubjectIdentifier(ctx, databaseConnection, administrator.ID, subject.ID, employee.ID, identity.UpdateIdentifierInput{Status: &disabled}); err != nil {
+		t.Fatalf("disable employee number: %v", err)
+	}
+	if _, err := identity.Login(ctx, databaseConnection, identity.LoginInput{
+		Identifier:    "E-1024",
+		Password:      "a sufficiently long password",
+		SourceAddress: "192.0.2.1",
+	}, testSessionSettings, testLoginThrottleSettings, testPasswordPolicy); !errors.Is(err, identity.ErrInvalidCredentials) {
+		t.Fatalf("login with disabled employee number error = %v", err)
+	}
+	if err := identity.DeleteSubjectIdentifier(ctx, databaseConnection, administrator.ID, subject.ID, employee.ID); err != nil {
+		t.Fatalf("delete employee number: %v", err)
+	}
+	if _, err := identity.GetSubjectIdentifier(ctx, databaseConnection, subject.ID, employee.ID); !errors.Is(err, identity.ErrIdentifierNotFound) {
+		t.Fatalf("deleted identifier error = %v", err)
+	}
+	if _, err := identity.ListSubjectIdentifiers(ctx, databaseConnection, "01HZZZZZZZZZZZZZZZZZZZZZZZ"); !errors.Is(err, identity.ErrSubjectNotFound) {
+		t.Fatalf("list identifiers of missing subject error = %v", err)
+	}
+
+	var auditEvents int
+	if err := databaseConnection.QueryRow(`
+		SELECT COUNT(*)
+		FROM identity_audit_events
+		WHERE event_action='标识符变更' AND target_subject_id=? AND metadata NOT LIKE '%1024%'
+	`, subject.ID).Scan(&auditEvents); err != nil {
+		t.Fatalf("count identifier audit events: %v", err)
+	}
+	// 创建两次、禁用一次、删除一次，审计元数据不包含标识符的值。
+	if auditEvents != 4 {
+		t.Fatalf("identifier audit events = %d, want 4", auditEvents)
+	}
+}
+
+func TestVerifyEmailIdentifierAndPromoteToPrimary(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	email, err := identity.CreateSubjectIdentifier(ctx, databaseConnection, administrator.ID, administrator.ID, identity.CreateIdentifierInput{
+		Type:  "邮箱",
+		Value: "Admin@Example.test",
+		Usage: "联系",
+	})
+	if err != nil {
+		t.Fatalf("create email: %v", err)
+	}
+	sender := &capturingSender{}
+	if _, err := identity.StartIdentifierVerification(ctx, databaseConnection, sender, administrator.ID, email.ID); err != nil {
+		t.Fatalf("start verification: %v", err)
+	}
+	if len(sender.codes) != 1 || sender.codes[0].Address != "admin@example.test" || len(sender.codes[0].Code) != 6 {
+		t.Fatalf("sent codes = %#v", sender.codes)
+	}
+	// 一分钟内不能重复发送。
+	if _, err := identity.StartIdentifierVerification(ctx, databaseConnection, sender, administrator.ID, email.ID); !errors.Is(err, identity.ErrVerificationTooFrequent) {
+		t.Fatalf("resend error = %v", err)
+	}
+
+	wrongCode := "000000"
+	if sender.codes[0].Code == wrongCode {
+		wrongCode = "111111"
+	}
+	if _, err := identity.ConfirmIdentifierVerification(ctx, databaseConnection, administrator.ID, administrator.ID, email.ID, wrongCode); !errors.Is(err, identity.ErrInvalidVerificationCode) {
+		t.Fatalf("wrong code error = %v", err)
+	}
+	verified, err := identity.ConfirmIdentifierVerification(ctx, databaseConnection, administrator.ID, administrator.ID, email.ID, sender.codes[0].Code)
+	if err != nil {
+		t.Fatalf("confirm verification: %v", err)
+	}
+	if verified.VerifiedAt == nil {
+		t.Fatalf("verified identifier = %#v", verified)
+	}
+	// 验证码只能使用一次。
+	if _, err := identity.ConfirmIdentifierVerification(ctx, databaseConnection, administrator.ID, administrator.ID, email.ID, sender.codes[0].Code); !errors.Is(err, identity.ErrInvalidVerificationCode) {
+		t.Fatalf("reused code error = %v", err)
+	}
+
+	primary := "主登录"
+	promoted, err := identity.UpdateSubjectIdentifier(ctx, databaseConnection, administrator.ID, administrator.ID, email.ID, identity.UpdateIdentifierInput{Usage: &primary})
+	if err != nil {
+		t.Fatalf("promote email: %v", err)
+	}
+	if promoted.Usage != "主登录" {
+		t.Fatalf("promoted identifier = %#v", promoted)
+	}
+	subject, err := identity.GetSubject(ctx, databaseConnection, administrator.ID)
+	if err != nil || subject.Identifier != "Admin@Example.test" {
+		t.Fatalf("subject identifier = %q, err = %v", subject.Identifier, err)
+	}
+	identifiers, err := identity.ListSubjectIdentifiers(ctx, databaseConnection, administrator.ID)
+	if err != nil {
+		t.Fatalf("list identifiers: %v", err)
+	}
+	if identifiers[0].Type != "账号" || identifiers[0].Usage != "辅助登录" {
+		t.Fatalf("previous primary = %#v", identifiers[0])
+	}
+	// 原主登录账号降为辅助登录后仍可登录。
+	for _, identifier := range []string{"admin", "ADMIN@example.test"} {
+		if _, err := identity.Login(ctx, databaseConnection, identity.LoginInput{
+			Identifier:    identifier,
+			Password:      "correct horse battery staple",
+			SourceAddress: "192.0.2.1",
+		}, testSessionSettings, testLoginThrottleSettings, testPasswordPolicy); err != nil {
+			t.Fatalf("login with %s: %v", identifier, err)
+		}
+	}
+}
+
+// 投递失败时删除已提交的验证码：返回错误、验证码不可用，且可立即重新申请。
+func TestUndeliveredVerificationCodeIsDiscarded(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	email, err := identity.CreateSubjectIdentifier(ctx, databaseConnection, administrator.ID, administrator.ID, identity.CreateIdentifierInput{
+		Type:  "邮箱",
+		Value: "admin@example.test",
+		Usage: "联系",
+	})
+	if err != nil {
+		t.Fatalf("create email: %v", err)
+	}
+	failing := &capturingSender{err: errors.New("mail relay unavailable")}
+	if _, err := identity.StartIdentifierVerification(ctx, databaseConnection, failing, administrator.ID, email.ID); err == nil {
+		t.Fatal("start verification with a failing sender succeeded")
+	}
+	var pending int
+	if err := databaseConnection.QueryRow(`SELECT COUNT(*) FROM identity_identifier_verifications WHERE identifier_id = ?`, email.ID).Scan(&pending); err != nil {
+		t.Fatalf("count pending verifications: %v", err)
+	}
+	if pending != 0 {
+		t.Fatalf("pending verifications after a failed delivery = %d", pending)
+	}
+	if _, err := identity.ConfirmIdentifierVerification(ctx, databaseConnection, administrator.ID, administrator.ID, email.ID, failing.codes[0].Code); !errors.Is(err, identity.ErrInvalidVerificationCode) {
+		t.Fatalf("undelivered code error = %v", err)
+	}
+
+	sender := &capturingSender{}
+	if _, err := identity.StartIdentifierVerification(ctx, databaseConnection, sender, administrator.ID, email.ID); err != nil {
+		t.Fatalf("retry verification: %v", err)
+	}
+	if _, err := identity.ConfirmIdentifierVerification(ctx, databaseConnection, administrator.ID, administrator.ID, email.ID, sender.codes[0].Code); err != nil {
+		t.Fatalf("confirm retried verification: %v", err)
+	}
+}
+
+func TestVerificationCodeIsDiscardedAfterTooManyAttempts(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	phone, err := identity.CreateSubjectIdentifier(ctx, databaseConnection, administrator.ID, administrator.ID, identity.CreateIdentifierInput{
+		Type:  "手机号",
+		Value: "+44 20 7946 0958",
+		Usage: "联系",
+	})
+	if err != nil {
+		t.Fatalf("create phone: %v", err)
+	}
+	account, err := identity.ListSubjectIdentifiers(ctx, databaseConnection, administrator.ID)
+	if err != nil {
+		t.Fatalf("list identifiers: %v", err)
+	}
+	if _, err := identity.StartIdentifierVerification(ctx, databaseConnection, &capturingSender{}, administrator.ID, account[0].ID); !errors.Is(err, identity.ErrVerificationNotSupported) {
+		t.Fatalf("verify account error = %v", err)
+	}
+
+	sender := &capturingSender{}
+	if _, err := identity.StartIdentifierVerification(ctx, databaseConnection, sender, administrator.ID, phone.ID); err != nil {
+		t.Fatalf("start verification: %v", err)
+	}
+	code := sender.codes[0].Code
+	wrongCode := "000000"
+	if code == wrongCode {
+		wrongCode = "111111"
+	}
+	for attempt := 0; attempt < 5; attempt++ {
+		if _, err := identity.ConfirmIdentifierVerification(ctx, databaseConnection, administrator.ID, administrator.ID, phone.ID, wrongCode); !errors.Is(err, identity.ErrInvalidVerificationCode) {
+			t.Fatalf("attempt %d error = %v", attempt, err)
+		}
+	}
+	// 五次错误后正确的验证码也已失效。
+	if _, err := identity.ConfirmIdentifierVerification(ctx, databaseConnection, administrator.ID, administrator.ID, phone.ID, code); !errors.Is(err, identity.ErrInvalidVerificationCode) {
+		t.Fatalf("exhausted code error = %v", err)
+	}
+
+	result, err := identity.Purge(ctx, databaseConnection, testLoginThrottleSettings)
+	if err != nil || result.IdentifierVerifications != 0 {
+		t.Fatalf("purge = %#v, err = %v", result, err)
+	}
+}
@@ -0,0 +1,193 @@
+package identity
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database/sqlc"
+)
+
+var ErrVerificationNotSupported = errors.New("only 邮箱 and 手机号 identifiers are verified")
+var ErrInvalidVerificationCode = errors.New("verification code is invalid or expired")
+var ErrVerificationTooFrequent = errors.New("a verification code was sent recently")
+
+const (
+	verificationCodeTTL         = 10 * time.Minute
+	verificationResendInterval  = time.Minute
+	maximumVerificationAttempts = 5
+)
+
+// VerificationCode is a one-time code to deliver to the address of an 邮箱 or
+// 手机号 identifier.
+type VerificationCode struct {
+	IdentifierType string
+	Address        string
+	Code           string
+	ExpiresAt      time.Time
+}
+
+// VerificationCodeSender delivers verification codes, for example by mail or
+// SMS. The code is only stored hashed, so a failed delivery cannot be
+// recovered and the subject must request a new code.
+type VerificationCodeSender interface {
+	SendVerificationCode(ctx context.Context, code VerificationCode) error
+}
+
+// StartIdentifierVerification stores a new six-digit code for an unverified
+// 邮箱 or 手机号 identifier, replacing any earlier one, and hands it to
+// sender. The code is committed before it is sent, so a slow sender never
+// holds the write transaction; when the delivery fails the pending code is
+// deleted again, which also lets the subject ask for a new one at once.
+// Otherwise a new code can be requested once per minute.
+func StartIdentifierVerification(ctx context.Context, database *sql.DB, sender VerificationCodeSender, subjectID string, identifierID string) (time.Time, error) {
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("begin identifier verification transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	record, err := getSubjectIdentifier(ctx, transactionQueries, subjectID, identifierID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !identifierRequiresVerification(record.IdentifierType) {
+		return time.Time{}, ErrVerificationNotSupported
+	}
+	if record.VerifiedAt.Valid {
+		return time.Time{}, fmt.Errorf("%w: identifier is already verified", ErrInvalidIdentifierInput)
+	}
+	if record.Status != "启用" {
+		return time.Time{}, fmt.Errorf("%w: a disabled identifier cannot be verified", ErrInvalidIdentifierInput)
+	}
+
+	now := time.Now().UTC()
+	pending, err := transactionQueries.GetIdentifierVerification(ctx, identifierID)
+	if err == nil && now.Before(pending.CreatedAt.Add(verificationResendInterval)) {
+		return time.Time{}, ErrVerificationTooFrequent
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, fmt.Errorf("load identifier verification: %w", err)
+	}
+
+	code, err := newVerificationCode()
+	if err != nil {
+		return time.Time{}, err
+	}
+	expiresAt := now.Add(verificationCodeTTL)
+	if err := transactionQueries.UpsertIdentifierVerification(ctx, sqlc.UpsertIdentifierVerificationParams{
+		IdentifierID: identifierID,
+		CodeHash:     verificationCodeHash(identifierID, code),
+		ExpiresAt:    expiresAt,
+		CreatedAt:    now,
+	}); err != nil {
+		return time.Time{}, fmt.Errorf("store identifier verification: %w", err)
+	}
+	if err := transaction.Commit(); err != nil {
+		return time.Time{}, fmt.Errorf("commit identifier verification transaction: %w", err)
+	}
+	if err := sender.SendVerificationCode(ctx, VerificationCode{
+		IdentifierType: record.IdentifierType,
+		Address:        record.NormalizedValue,
+		Code:           code,
+		ExpiresAt:      expiresAt,
+	}); err != nil {
+		err = fmt.Errorf("send verification code: %w", err)
+		if deleteErr := queries.DeleteIdentifierVerification(context.WithoutCancel(ctx), identifierID); deleteErr != nil {
+			err = errors.Join(err, fmt.Errorf("discard undelivered identifier verification: %w", deleteErr))
+		}
+		return time.Time{}, err
+	}
+	return expiresAt, nil
+}
+
+// ConfirmIdentifierVerification marks the identifier verified when code
+// matches the pending code. Every wrong guess counts; after five the code is
+// discarded and a new one must be requested.
+func ConfirmIdentifierVerification(ctx context.Context, database *sql.DB, actorSubjectID string, subjectID string, identifierID string, code string) (Identifier, error) {
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return Identifier{}, fmt.Errorf("begin identifier confirmation transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	record, err := getSubjectIdentifier(ctx, transactionQueries, subjectID, identifierID)
+	if err != nil {
+		return Identifier{}, err
+	}
+	pending, err := transactionQueries.GetIdentifierVerification(ctx, identifierID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Identifier{}, ErrInvalidVerificationCode
+	}
+	if err != nil {
+		return Identifier{}, fmt.Errorf("load identifier verification: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if !now.Before(pending.ExpiresAt) || pending.Attempts >= maximumVerificationAttempts {
+		if err := transactionQueries.DeleteIdentifierVerification(ctx, identifierID); err != nil {
+			return Identifier{}, fmt.Errorf("discard identifier verification: %w", err)
+		}
+		if err := transaction.Commit(); err != nil {
+			return Identifier{}, fmt.Errorf("commit discarded identifier verification: %w", err)
+		}
+		return Identifier{}, ErrInvalidVerificationCode
+	}
+	if subtle.ConstantTimeCompare(verificationCodeHash(identifierID, code), pending.CodeHash) != 1 {
+		if pending.Attempts+1 >= maximumVerificationAttempts {
+			err = transactionQueries.DeleteIdentifierVerification(ctx, identifierID)
+		} else {
+			_, err = transactionQueries.IncrementIdentifierVerificationAttempts(ctx, identifierID)
+		}
+		if err != nil {
+			return Identifier{}, fmt.Errorf("record verification attempt: %w", err)
+		}
+		if err := transaction.Commit(); err != nil {
+			return Identifier{}, fmt.Errorf("commit verification attempt: %w", err)
+		}
+		return Identifier{}, ErrInvalidVerificationCode
+	}
+
+	if err := transactionQueries.DeleteIdentifierVerification(ctx, identifierID); err != nil {
+		return Identifier{}, fmt.Errorf("consume identifier verification: %w", err)
+	}
+	if _, err := transactionQueries.MarkIdentifierVerified(ctx, sqlc.MarkIdentifierVerifiedParams{
+		VerifiedAt: sql.NullTime{Time: now, Valid: true},
+		UpdatedAt:  now,
+		ID:         identifierID,
+	}); err != nil {
+		return Identifier{}, fmt.Errorf("mark identifier verified: %w", err)
+	}
+	record.VerifiedAt = sql.NullTime{Time: now, Valid: true}
+	record.UpdatedAt = now
+	if err := insertIdentifierAuditEvent(ctx, transactionQueries, subjectActor(actorSubjectID), record, "验证", now); err != nil {
+		return Identifier{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return Identifier{}, fmt.Errorf("commit identifier confirmation transaction: %w", err)
+	}
+	return identifierFromRecord(record), nil
+}
+
+func newVerificationCode() (string, error) {
+	value, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", fmt.Errorf("generate verification code: %w", err)
+	}
+	return fmt.Sprintf("%06d", value.Int64()), nil
+}
+
+// verificationCodeHash binds the code to its identifier, so equal codes sent
+// to different identifiers never share a hash.
+func verificationCodeHash(identifierID string, code string) []byte {
+	return hashBytes([]byte(identifierID + ":" + code))
+}
@@ -0,0 +1,458 @@
+package identity
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database/sqlc"
+)
+
+var ErrMachineClientNotFound = errors.New("machine client not found")
+var ErrInvalidMachineClientInput = errors.New("invalid machine client input")
+
+// ErrInvalidClientCredentials rejects a client_credentials request whose
+// client is unknown or disabled, or whose secret is wrong or expired. The
+// causes are not distinguished.
+var ErrInvalidClientCredentials = errors.New("invalid client credentials")
+
+const (
+	maximumMachineClientScopes = 20
+	// DefaultClientSecretOverlap keeps the previous secret of a machine client
+	// valid after a rotation, so its deployments can switch without downtime.
+	DefaultClientSecretOverlap = 24 * time.Hour
+	MaximumClientSecretOverlap = 7 * 24 * time.Hour
+)
+
+// Scopes of identityd's own API that a machine client may be granted.
+const (
+	SubjectsReadScope = "identity.subjects.read"
+	// ProvisioningScope lets a machine client create and update subjects and
+	// role memberships through the SCIM endpoint.
+	ProvisioningScope = "identity.provisioning"
+)
+
+// MachineClient is a registered service that obtains access tokens with the
+// client_credentials grant. Unlike first-party OIDC clients it holds secrets,
+// stored only as SHA-256 hashes, and acts for itself rather than a subject.
+type MachineClient struct {
+	ID          string                `json:"id"`
+	ClientID    string                `json:"client_id"`
+	DisplayName string                `json:"display_name"`
+	Status      string                `json:"status"`
+	Scopes      []string              `json:"scopes"`
+	Secrets     []MachineClientSecret `json:"secrets"`
+	CreatedAt   time.Time             `json:"created_at"`
+	UpdatedAt   time.Time             `json:"updated_at"`
+}
+
+// MachineClientSecret describes a secret that is still accepted. ExpiresAt
+// is nil for the current secret and set for secrets replaced by a rotation.
+type MachineClientSecret struct {
+	ID        string     `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// MachineClientCredentials is returned when a secret is issued; the plain
+// secret is never stored and cannot be shown again.
+type MachineClientCredentials struct {
+	MachineClient
+	ClientSecret string `json:"client_secret"`
+}
+
+type ListMachineClientsInput struct {
+	Limit  int64
+	Offset int64
+}
+
+type ListMachineClientsResult struct {
+	Clients []MachineClient
+	Total   int64
+}
+
+type CreateMachineClientInput struct {
+	ClientID    string
+	DisplayName string
+	Scopes      []string
+}
+
+// UpdateMachineClientInput carries a partial update; nil fields keep their
+// stored value.
+type UpdateMachineClientInput struct {
+	DisplayName *string
+	Status      *string
+	Scopes      []string
+}
+
+func ListMachineClients(ctx context.Context, database *sql.DB, input ListMachineClientsInput) (ListMachineClientsResult, error) {
+	if input.Limit <= 0 || input.Offset < 0 {
+		return ListMachineClientsResult{}, fmt.Errorf("invalid machine client list pagination")
+	}
+
+	queries := sqlc.New(database)
+	total, err := queries.CountMachineClients(ctx)
+	if err != nil {
+		return ListMachineClientsResult{}, fmt.Errorf("count machine clients: %w", err)
+	}
+	rows, err := queries.ListMachineClients(ctx, sqlc.ListMachineClientsParams{Limit: input.Limit, Offset: input.Offset})
+	if err != nil {
+		return ListMachineClientsResult{}, fmt.Errorf("list machine clients: %w", err)
+	}
+	now := time.Now().UTC()
+	clients := make([]MachineClient, 0, len(rows))
+	for _, row := range rows {
+		client, err := machineClientFromRecord(ctx, queries, row, now)
+		if err != nil {
+			return ListMachineClientsResult{}, err
+		}
+		clients = append(clients, client)
+	}
+	return ListMachineClientsResult{Clients: clients, Total: total}, nil
+}
+
+func GetMachineClient(ctx context.Context, database *sql.DB, clientID string) (MachineClient, error) {
+	return getMachineClient(ctx, sqlc.New(database), clientID, time.Now().UTC())
+}
+
+// CreateMachineClient registers a machine client with its first secret.
+// client_id shares one namespace with first-party OIDC clients, so a token's
+// client_id claim always names exactly one registration.
+func CreateMachineClient(ctx context.Context, database *sql.DB, actorSubjectID string, input CreateMachineClientInput) (MachineClientCredentials, error) {
+	clientID, err := validateClientID(input.ClientID)
+	if err != nil {
+		return MachineClientCredentials{}, fmt.Errorf("%w: %v", ErrInvalidMachineClientInput, err)
+	}
+	displayName, err := validateDisplayName(input.DisplayName)
+	if err != nil {
+		return MachineClientCredentials{}, fmt.Errorf("%w: %v", ErrInvalidMachineClientInput, err)
+	}
+	scopes, err := validateMachineClientScopes(input.Scopes)
+	if err != nil {
+		return MachineClientCredentials{}, fmt.Errorf("%w: %v", ErrInvalidMachineClientInput, err)
+	}
+
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return MachineClientCredentials{}, fmt.Errorf("begin create machine client transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	if err := checkClientIDAvailable(ctx, transactionQueries, clientID); err != nil {
+		return MachineClientCredentials{}, err
+	}
+	now := time.Now().UTC()
+	id, err := NewULID(now)
+	if err != nil {
+		return MachineClientCredentials{}, err
+	}
+	if err := transactionQueries.CreateMachineClient(ctx, sqlc.CreateMachineClientParams{
+		ID:          id,
+		ClientID:    clientID,
+		DisplayName: displayName,
+		Status:      "启用",
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}); err != nil {
+		return MachineClientCredentials{}, fmt.Errorf("create machine client: %w", err)
+	}
+	if err := replaceMachineClientScopes(ctx, transactionQueries, id, scopes, now); err != nil {
+		return MachineClientCredentials{}, err
+	}
+	secret, secretRecord, err := createMachineClientSecret(ctx, transactionQueries, id, now)
+	if err != nil {
+		return MachineClientCredentials{}, err
+	}
+	if err := insertClientAuditEvent(ctx, transactionQueries, actorSubjectID, clientID, "机器客户端创建", now); err != nil {
+		return MachineClientCredentials{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return MachineClientCredentials{}, fmt.Errorf("commit create machine client transaction: %w", err)
+	}
+
+	return MachineClientCredentials{
+		MachineClient: MachineClient{
+			ID:          id,
+			ClientID:    clientID,
+			DisplayName: displayName,
+			Status:      "启用",
+			Scopes:      scopes,
+			Secrets:     []MachineClientSecret{secretRecord},
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		},
+		ClientSecret: secret,
+	}, nil
+}
+
+func UpdateMachineClient(ctx context.Context, database *sql.DB, actorSubjectID string, clientID string, input UpdateMachineClientInput) (MachineClient, error) {
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return MachineClient{}, fmt.Errorf("begin update machine client transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	now := time.Now().UTC()
+	client, err := getMachineClient(ctx, transactionQueries, clientID, now)
+	if err != nil {
+		return MachineClient{}, err
+	}
+	if input.DisplayName != nil {
+		client.DisplayName, err = validateDisplayName(*input.DisplayName)
+		if err != nil {
+			return MachineClient{}, fmt.Errorf("%w: %v", ErrInvalidMachineClientInput, err)
+		}
+	}
+	if input.Status != nil {
+		if *input.Status != "启用" && *input.Status != "禁用" {
+			return MachineClient{}, fmt.Errorf("%w: status must be 启用 or 禁用", ErrInvalidMachineClientInput)
+		}
+		client.Status = *input.Status
+	}
+	if input.Scopes != nil {
+		client.Scopes, err = validateMachineClientScopes(input.Scopes)
+		if err != nil {
+			return MachineClient{}, fmt.Errorf("%w: %v", ErrInvalidMachineClientInput, err)
+		}
+	}
+
+	updated, err := transactionQueries.UpdateMachineClient(ctx, sqlc.UpdateMachineClientParams{
+		DisplayName: client.DisplayName,
+		Status:      client.Status,
+		UpdatedAt:   now,
+		ID:          client.ID,
+	})
+	if err != nil {
+		return MachineClient{}, fmt.Errorf("update machine client: %w", err)
+	}
+	if updated != 1 {
+		return MachineClient{}, ErrMachineClientNotFound
+	}
+	if input.Scopes != nil {
+		if err := replaceMachineClientScopes(ctx, transactionQueries, client.ID, client.Scopes, now); err != nil {
+			return MachineClient{}, err
+		}
+	}
+	if err := insertClientAuditEvent(ctx, transactionQueries, actorSubjectID, client.ClientID, "机器客户端更新", now); err != nil {
+		return MachineClient{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return MachineClient{}, fmt.Errorf("commit update machine client transaction: %w", err)
+	}
+
+	client.UpdatedAt = now
+	return client, nil
+}
+
+// RotateMachineClientSecret issues a new secret. Secrets that are still
+// accepted stop being accepted after overlap; a zero overlap revokes them
+// immediately, for example after a leak. A shorter existing expiry is kept.
+func RotateMachineClientSecret(ctx context.Context, database *sql.DB, actorSubjectID string, clientID string, overlap time.Duration) (MachineClientCredentials, error) {
+	if overlap < 0 || overlap > MaximumClientSecretOverlap {
+		return MachineClientCredentials{}, fmt.Errorf("%w: overlap must be between 0 and %s", ErrInvalidMachineClientInput, MaximumClientSecretOverlap)
+	}
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return MachineClientCredentials{}, fmt.Errorf("begin rotate machine client secret transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	now := time.Now().UTC()
+	record, err := transactionQueries.GetMachineClientByClientID(ctx, clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return MachineClientCredentials{}, ErrMachineClientNotFound
+	}
+	if err != nil {
+		return MachineClientCredentials{}, fmt.Errorf("get machine client: %w", err)
+	}
+	previousExpiresAt := sql.NullTime{Time: now.Add(overlap), Valid: true}
+	if _, err := transactionQueries.ExpireMachineClientSecrets(ctx, sqlc.ExpireMachineClientSecretsParams{
+		ExpiresAt:           previousExpiresAt,
+		OidcMachineClientID: record.ID,
+		ExpiresAt_2:         previousExpiresAt,
+	}); err != nil {
+		return MachineClientCredentials{}, fmt.Errorf("expire previous machine client secrets: %w", err)
+	}
+	secret, _, err := createMachineClientSecret(ctx, transactionQueries, record.ID, now)
+	if err != nil {
+		return MachineClientCredentials{}, err
+	}
+	if err := insertClientAuditEvent(ctx, transactionQueries, actorSubjectID, record.ClientID, "密钥轮换", now); err != nil {
+		return MachineClientCredentials{}, err
+	}
+	client, err := machineClientFromRecord(ctx, transactionQueries, record, now)
+	if err != nil {
+		return MachineClientCredentials{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return MachineClientCredentials{}, fmt.Errorf("commit rotate machine client secret transaction: %w", err)
+	}
+	return MachineClientCredentials{MachineClient: client, ClientSecret: secret}, nil
+}
+
+// AuthenticateMachineClient checks a client_id and secret presented to the
+// token endpoint and returns the enabled client they belong to.
+func AuthenticateMachineClient(ctx context.Context, database *sql.DB, clientID string, secret string) (MachineClient, error) {
+	secretHash, err := hashSecret(secret)
+	if err != nil {
+		return MachineClient{}, ErrInvalidClientCredentials
+	}
+	queries := sqlc.New(database)
+	now := time.Now().UTC()
+	record, err := queries.GetMachineClientSecretByHash(ctx, secretHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return MachineClient{}, ErrInvalidClientCredentials
+	}
+	if err != nil {
+		return MachineClient{}, fmt.Errorf("get machine client secret: %w", err)
+	}
+	if record.ClientID != clientID || (record.ExpiresAt.Valid && !record.ExpiresAt.Time.After(now)) {
+		return MachineClient{}, ErrInvalidClientCredentials
+	}
+	client, err := getMachineClient(ctx, queries, clientID, now)
+	if errors.Is(err, ErrMachineClientNotFound) || (err == nil && client.Status != "启用") {
+		return MachineClient{}, ErrInvalidClientCredentials
+	}
+	if err != nil {
+		return MachineClient{}, err
+	}
+	return client, nil
+}
+
+func getMachineClient(ctx context.Context, queries sqlc.Querier, clientID string, now time.Time) (MachineClient, error) {
+	record, err := queries.GetMachineClientByClientID(ctx, clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return MachineClient{}, ErrMachineClientNotFound
+	}
+	if err != nil {
+		return MachineClient{}, fmt.Errorf("get machine client: %w", err)
+	}
+	return machineClientFromRecord(ctx, queries, record, now)
+}
+
+func machineClientFromRecord(ctx context.Context, queries sqlc.Querier, record sqlc.OidcMachineClient, now time.Time) (MachineClient, error) {
+	scopes, err := queries.ListMachineClientScopes(ctx, record.ID)
+	if err != nil {
+		return MachineClient{}, fmt.Errorf("list machine client scopes: %w", err)
+	}
+	if scopes == nil {
+		scopes = []string{}
+	}
+	rows, err := queries.ListMachineClientSecrets(ctx, sqlc.ListMachineClientSecretsParams{
+		OidcMachineClientID: record.ID,
+		ExpiresAt:           sql.NullTime{Time: now, Valid: true},
+	})
+	if err != nil {
+		return MachineClient{}, fmt.Errorf("list machine client secrets: %w", err)
+	}
+	secrets := make([]MachineClientSecret, 0, len(rows))
+	for _, row := range rows {
+		secret := MachineClientSecret{ID: row.ID, CreatedAt: row.CreatedAt}
+		if row.ExpiresAt.Valid {
+			expiresAt := row.ExpiresAt.Time
+			secret.ExpiresAt = &expiresAt
+		}
+		secrets = append(secrets, secret)
+	}
+	return MachineClient{
+		ID:          record.ID,
+		ClientID:    record.ClientID,
+		DisplayName: record.DisplayName,
+		Status:      record.Status,
+		Scopes:      scopes,
+		Secrets:     secrets,
+		CreatedAt:   record.CreatedAt,
+		UpdatedAt:   record.UpdatedAt,
+	}, nil
+}
+
+func createMachineClientSecret(ctx context.Context, queries sqlc.Querier, id string, now time.Time) (string, MachineClientSecret, error) {
+	secret, secretHash, err := newSecret()
+	if err != nil {
+		return "", MachineClientSecret{}, err
+	}
+	secretID, err := NewULID(now)
+	if err != nil {
+		return "", MachineClientSecret{}, err
+	}
+	if err := queries.CreateMachineClientSecret(ctx, sqlc.CreateMachineClientSecretParams{
+		ID:                  secretID,
+		OidcMachineClientID: id,
+		SecretHash:          secretHash,
+		ExpiresAt:           sql.NullTime{},
+		CreatedAt:           now,
+	}); err != nil {
+		return "", MachineClientSecret{}, fmt.Errorf("create machine client secret: %w", err)
+	}
+	return secret, MachineClientSecret{ID: secretID, CreatedAt: now}, nil
+}
+
+func replaceMachineClientScopes(ctx context.Context, queries sqlc.Querier, id string, scopes []string, now time.Time) error {
+	if err := queries.DeleteMachineClientScopes(ctx, id); err != nil {
+		return fmt.Errorf("clear machine client scopes: %w", err)
+	}
+	for _, scope := range scopes {
+		if err := queries.CreateMachineClientScope(ctx, sqlc.CreateMachineClientScopeParams{
+			OidcMachineClientID: id,
+			Scope:               scope,
+			CreatedAt:           now,
+		}); err != nil {
+			return fmt.Errorf("register machine client scope: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkClientIDAvailable rejects a client_id already registered by either
+// kind of client.
+func checkClientIDAvailable(ctx context.Context, queries sqlc.Querier, clientID string) error {
+	if _, err := queries.GetClientByClientID(ctx, clientID); err == nil {
+		return ErrClientAlreadyExists
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("check client_id: %w", err)
+	}
+	if _, err := queries.GetMachineClientByClientID(ctx, clientID); err == nil {
+		return ErrClientAlreadyExists
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("check client_id: %w", err)
+	}
+	return nil
+}
+
+// validateMachineClientScopes accepts 1 to 20 scopes of 3 to 128 lowercase
+// letters, digits, '.', '_', '-' and ':', such as identity.subjects.read or
+// prototype.drills:write. Scopes are defined by the resource services, so
+// identityd does not restrict them further.
+func validateMachineClientScopes(values []string) ([]string, error) {
+	scopes := make([]string, 0, len(values))
+	for _, value := range values {
+		if len(value) < 3 || len(value) > 128 {
+			return nil, fmt.Errorf("scope %q must contain 3 to 128 characters", value)
+		}
+		for _, character := range value {
+			switch {
+			case character >= 'a' && character <= 'z', character >= '0' && character <= '9':
+			case character == '.', character == '_', character == '-', character == ':':
+			default:
+				return nil, fmt.Errorf("scope %q may only contain lowercase letters, digits, '.', '_', '-' and ':'", value)
+			}
+		}
+		if !slices.Contains(scopes, value) {
+			scopes = append(scopes, value)
+		}
+	}
+	if len(scopes) == 0 || len(scopes) > maximumMachineClientScopes {
+		return nil, fmt.Errorf("a machine client must register 1 to %d scopes", maximumMachineClientScopes)
+	}
+	slices.Sort(scopes)
+	return scopes, nil
+}
@@ -0,0 +1,121 @@
+package identity_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+func TestMachineClientSecretRotationKeepsOverlap(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+
+	created, err := identity.CreateMachineClient(ctx, databaseConnection, administrator.ID, identity.CreateMachineClientInput{
+		ClientID:    "prototyped",
+		DisplayName: "演练原型服务",
+		Scopes:      []string{"identity.subjects.read", "prototype.drills:write", "identity.subjects.read"},
+	})
+	if err != nil {
+		t.Fatalf("create machine client: %v", err)
+	}
+	if created.ClientSecret == "" || len(created.Scopes) != 2 || len(created.Secrets) != 1 || created.Secrets[0].ExpiresAt != nil {
+		t.Fatalf("created machine client = %#v", created)
+	}
+	if _, err := identity.AuthenticateMachineClient(ctx, databaseConnection, "prototyped", created.ClientSecret); err != nil {
+		t.Fatalf("authenticate with first secret: %v", err)
+	}
+	if _, err := identity.AuthenticateMachineClient(ctx, databaseConnection, "prototyped", "not-a-secret"); !errors.Is(err, identity.ErrInvalidClientCredentials) {
+		t.Fatalf("authenticate with wrong secret: %v", err)
+	}
+
+	// 轮换后旧密钥在重叠窗口内仍然有效。
+	rotated, err := identity.RotateMachineClientSecret(ctx, databaseConnection, administrator.ID, "prototyped", time.Hour)
+	if err != nil {
+		t.Fatalf("rotate secret: %v", err)
+	}
+	if rotated.ClientSecret == created.ClientSecret || len(rotated.Secrets) != 2 || rotated.Secrets[1].ExpiresAt == nil {
+		t.Fatalf("rotated machine client = %#v", rotated)
+	}
+	for _, secret := range []string{created.ClientSecret, rotated.ClientSecret} {
+		if _, err := identity.AuthenticateMachineClient(ctx, databaseConnection, "prototyped", secret); err != nil {
+			t.Fatalf("authenticate during overlap: %v", err)
+		}
+	}
+
+	// 重叠时长为 0 时立即吊销此前的所有密钥。
+	revoked, err := identity.RotateMachineClientSecret(ctx, databaseConnection, administrator.ID, "prototyped", 0)
+	if err != nil {
+		t.Fatalf("rotate secret without overlap: %v", err)
+	}
+	if len(revoked.Secrets) != 1 {
+		t.Fatalf("secrets after revoking rotation = %#v", revoked.Secrets)
+	}
+	for _, secret := range []string{created.ClientSecret, rotated.ClientSecret} {
+		if _, err := identity.AuthenticateMachineClient(ctx, databaseConnection, "prototyped", secret); !errors.Is(err, identity.ErrInvalidClientCredentials) {
+			t.Fatalf("authenticate with revoked secret: %v", err)
+		}
+	}
+
+	disabled := "禁用"
+	if _, err := identity.UpdateMachineClient(ctx, databaseConnection, administrator.ID, "prototyped", identity.UpdateMachineClientInput{Status: &disabled}); err != nil {
+		t.Fatalf("disable machine client: %v", err)
+	}
+	if _, err := identity.AuthenticateMachineClient(ctx, databaseConnection, "prototyped", revoked.ClientSecret); !errors.Is(err, identity.ErrInvalidClientCredentials) {
+		t.Fatalf("authenticate disabled client: %v", err)
+	}
+	if _, err := identity.RotateMachineClientSecret(ctx, databaseConnection, administrator.ID, "prototyped", 8*24*time.Hour); !errors.Is(err, identity.ErrInvalidMachineClientInput) {
+		t.Fatalf("rotate with excessive overlap: %v", err)
+	}
+
+	result, err := identity.Purge(ctx, databaseConnection, identity.LoginThrottleSettings{Window: time.Minute, LockoutDuration: time.Minute})
+	if err != nil || result.MachineClientSecrets != 2 {
+		t.Fatalf("purge = %#v, %v", result, err)
+	}
+
+	var auditCount int
+	if err := databaseConnection.QueryRow(`
+		SELECT COUNT(*)
+		FROM identity_audit_events
+		WHERE event_action = '客户端变更' AND json_extract(metadata, '$.client_id') = 'prototyped'
+	`).Scan(&auditCount); err != nil {
+		t.Fatalf("count machine client audits: %v", err)
+	}
+	if auditCount != 4 {
+		t.Fatalf("machine client audit count = %d, want 4", auditCount)
+	}
+}
+
+func TestCreateMachineClientValidatesInput(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+
+	if _, err := identity.CreateClient(ctx, databaseConnection, administrator.ID, identity.CreateClientInput{
+		ClientID:     "command-console",
+		DisplayName:  "指挥控制台",
+		RedirectURIs: []string{"https://console.example.test/callback"},
+	}); err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	// client_id 在两类客户端之间不可重复。
+	if _, err := identity.CreateMachineClient(ctx, databaseConnection, administrator.ID, identity.CreateMachineClientInput{
+		ClientID:    "command-console",
+		DisplayName: "指挥控制台后端",
+		Scopes:      []string{"identity.subjects.read"},
+	}); !errors.Is(err, identity.ErrClientAlreadyExists) {
+		t.Fatalf("duplicate client_id error = %v", err)
+	}
+	for _, scopes := range [][]string{nil, {"Identity.Subjects"}, {"ab"}} {
+		if _, err := identity.CreateMachineClient(ctx, databaseConnection, administrator.ID, identity.CreateMachineClientInput{
+			ClientID:    "nexus",
+			DisplayName: "Nexus",
+			Scopes:      scopes,
+		}); !errors.Is(err, identity.ErrInvalidMachineClientInput) {
+			t.Fatalf("scopes %q error = %v", scopes, err)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package identity
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database/sqlc"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/password"
+)
+
+type RecoverAdministratorInput struct {
+	Identifier string
+	Password   string
+}
+
+// RecoveryResult reports what RecoverAdministrator had to repair besides the
+// credential, which is always replaced.
+type RecoveryResult struct {
+	Subject     Subject
+	Reenabled   bool
+	RoleGranted bool
+	// SecondFactorReset is set when a TOTP secret was removed, so that a lost
+	// device does not keep the recovered administrator out.
+	SecondFactorReset bool
+}
+
+type PurgeResult struct {
+	Sessions                int64 `json:"sessions"`
+	LoginThrottles          int64 `json:"login_throttles"`
+	AuthorizationCodes      int64 `json:"authorization_codes"`
+	IdentifierVerifications int64 `json:"identifier_verifications"`
+	PasswordResetTokens     int64 `json:"password_reset_tokens"`
+	MachineClientSecrets    int64 `json:"machine_client_secrets"`
+	AuditOutboxEvents       int64 `json:"audit_outbox_events"`
+}
+
+// RecoverAdministrator is the operator path back in when every
+// identity.admin holder is locked out. It re-enables the account named by
+// identifier, grants identity.admin, replaces the password with a 需更新
+// credential, removes its TOTP secret, revokes the subject's sessions and
+// clears its login throttles.
+// It runs without an actor: the 管理员恢复 audit event records the local
+// operator command instead of a subject.
+func RecoverAdministrator(ctx context.Context, database *sql.DB, throttleSettings LoginThrottleSettings, input RecoverAdministratorInput) (RecoveryResult, error) {
+	identifier, err := normalizeAccountIdentifier(input.Identifier)
+	if err != nil {
+		return RecoveryResult{}, fmt.Errorf("%w: %v", ErrInvalidSubjectInput, err)
+	}
+	passwordHash, err := password.Hash(input.Password)
+	if err != nil {
+		return RecoveryResult{}, fmt.Errorf("%w: %v", ErrInvalidPasswordInput, err)
+	}
+	if err := throttleSettings.validate(); err != nil {
+		return RecoveryResult{}, err
+	}
+
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return RecoveryResult{}, fmt.Errorf("begin administrator recovery transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	subjectID, err := transactionQueries.GetIdentifierSubjectID(ctx, sqlc.GetIdentifierSubjectIDParams{
+		IdentifierType:  "账号",
+		NormalizedValue: identifier,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return RecoveryResult{}, ErrSubjectNotFound
+	}
+	if err != nil {
+		return RecoveryResult{}, fmt.Errorf("find recovery subject: %w", err)
+	}
+	subject, err := getSubject(ctx, transactionQueries, subjectID)
+	if err != nil {
+		return RecoveryResult{}, err
+	}
+
+	now := time.Now().UTC()
+	result := RecoveryResult{}
+	if subject.Status != "启用" {
+		if _, err := transactionQueries.EnableSubject(ctx, sqlc.EnableSubjectParams{
+			Status:    "启用",
+			UpdatedAt: now,
+			ID:        subjectID,
+			Status_2:  subject.Status,
+		}); err != nil {
+			return RecoveryResult{}, fmt.Errorf("enable recovery subject: %w", err)
+		}
+		result.Reenabled = true
+	}
+	if !hasRole(subject.Roles, "identity.admin") {
+		roleID, err := transactionQueries.GetRoleIDByCode(ctx, "identity.admin")
+		if err != nil {
+			return RecoveryResult{}, fmt.Errorf("load administrator role: %w", err)
+		}
+		assignmentID, err := NewULID(now)
+		if err != nil {
+			return RecoveryResult{}, err
+		}
+		if err := transactionQueries.AssignSubjectRole(ctx, sqlc.AssignSubjectRoleParams{
+			ID:                 assignmentID,
+			SubjectID:          subjectID,
+			RoleID:             roleID,
+			GrantedBySubjectID: sql.NullString{},
+			CreatedAt:          now,
+		}); err != nil {
+			return RecoveryResult{}, fmt.Errorf("grant administrator role: %w", err)
+		}
+		result.RoleGranted = true
+	}
+
+	credential, err := transactionQueries.GetPasswordCredentialBySubjectID(ctx, subjectID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return RecoveryResult{}, ErrPasswordCredentialNotFound
+	}
+	if err != nil {
+		return RecoveryResult{}, fmt.Errorf("load password credential: %w", err)
+	}
+	if err := replacePassword(ctx, transactionQueries, subjectID, credential.PasswordRevision, passwordHash, "需更新", ""); err != nil {
+		return RecoveryResult{}, err
+	}
+	result.SecondFactorReset, err = resetSecondFactor(ctx, transactionQueries, subjectID, now)
+	if err != nil {
+		return RecoveryResult{}, err
+	}
+	if _, err := transactionQueries.DeleteLoginThrottlesByIdentifierHash(ctx, hmacSHA256(throttleSettings.Secret, identifier)); err != nil {
+		return RecoveryResult{}, fmt.Errorf("clear recovery login throttles: %w", err)
+	}
+
+	auditEventID, err := NewULID(now)
+	if err != nil {
+		return RecoveryResult{}, err
+	}
+	metadata, err := json.Marshal(map[string]any{
+		"source":              "cli",
+		"reenabled":           result.Reenabled,
+		"role_granted":        result.RoleGranted,
+		"second_factor_reset": result.SecondFactorReset,
+	})
+	if err != nil {
+		return RecoveryResult{}, fmt.Errorf("encode recovery audit metadata: %w", err)
+	}
+	if err := transactionQueries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
+		ID:              auditEventID,
+		EventAction:     "管理员恢复",
+		Outcome:         "成功",
+		ActorSubjectID:  sql.NullString{},
+		TargetSubjectID: sql.NullString{String: subjectID, Valid: true},
+		RequestID:       sql.NullString{},
+		SourceHash:      nil,
+		Metadata:        string(metadata),
+		CreatedAt:       now,
+	}); err != nil {
+		return RecoveryResult{}, fmt.Errorf("write administrator recovery audit event: %w", err)
+	}
+
+	result.Subject, err = getSubject(ctx, transactionQueries, subjectID)
+	if err != nil {
+		return RecoveryResult{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return RecoveryResult{}, fmt.Errorf("commit administrator recovery transaction: %w", err)
+	}
+	return result, nil
+}
+
+// Purge deletes revoked and expired sessions, login throttles whose window
+// and lockout have both passed, expired authorization and verification codes,
+// used or expired password reset tokens, machine client secrets whose
+// rotation overlap has ended and outbox entries every audit sink has
+// received, then writes one 维护清理 audit event with the deleted counts.
+func Purge(ctx context.Context, database *sql.DB, throttleSettings LoginThrottleSettings) (PurgeResult, error) {
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return PurgeResult{}, fmt.Errorf("begin purge transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	now := time.Now().UTC()
+	result := PurgeResult{}
+	result.Sessions, err = transactionQueries.DeleteExpiredSessions(ctx, sqlc.DeleteExpiredSessionsParams{
+		ExpiresAt:     now,
+		IdleExpiresAt: now,
+	})
+	if err != nil {
+		return PurgeResult{}, fmt.Errorf("delete expired sessions: %w", err)
+	}
+	result.LoginThrottles, err = transactionQueries.DeleteExpiredLoginThrottles(ctx, sqlc.DeleteExpiredLoginThrottlesParams{
+		WindowStartedAt: now.Add(-throttleSettings.Window),
+		LockedUntil:     sql.NullTime{Time: now, Valid: true},
+	})
+	if err != nil {
+		return PurgeResult{}, fmt.Errorf("delete expired login throttles: %w", err)
+	}
+	result.AuthorizationCodes, err = transactionQueries.DeleteExpiredAuthorizationCodes(ctx, now)
+	if err != nil {
+		return PurgeResult{}, fmt.Errorf("delete expired authorization codes: %w", err)
+	}
+	result.IdentifierVerifications, err = transactionQueries.DeleteExpiredIdentifierVerifications(ctx, now)
+	if err != nil {
+		return PurgeResult{}, fmt.Errorf("delete expired identifier verifications: %w", err)
+	}
+	result.PasswordResetTokens, err = transactionQueries.DeleteExpiredPasswordResetTokens(ctx, now)
+	if err != nil {
+		return PurgeResult{}, fmt.Errorf("delete expired password reset tokens: %w", err)
+	}
+	result.MachineClientSecrets, err = transactionQueries.DeleteExpiredMachineClientSecrets(ctx, sql.NullTime{Time: now, Valid: true})
+	if err != nil {
+		return PurgeResult{}, fmt.Errorf("delete expired machine client secrets: %w", err)
+	}
+	result.AuditOutboxEvents, err = transactionQueries.DeleteDeliveredAuditOutbox(ctx, now.Add(-auditSinkCursorRetention))
+	if err != nil {
+		return PurgeResult{}, fmt.Errorf("delete delivered audit outbox events: %w", err)
+	}
+
+	auditEventID, err := NewULID(now)
+	if err != nil {
+		return PurgeResult{}, err
+	}
+	metadata, err := json.Marshal(result)
+	if err != nil {
+		return PurgeResult{}, fmt.Errorf("encode purge audit metadata: %w", err)
+	}
+	if err := transactionQueries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
+		ID:              auditEventID,
+		EventAction:     "维护清理",
+		Outcome:         "成功",
+		ActorSubjectID:  sql.NullString{},
+		TargetSubjectID: sql.NullString{},
+		RequestID:       sql.NullString{},
+		SourceHash:      nil,
+		Metadata:        string(metadata),
+		CreatedAt:       now,
+	}); err != nil {
+		return PurgeResult{}, fmt.Errorf("write purge audit event: %w", err)
+	}
+	if err := transaction.Commit(); err != nil {
+		return PurgeResult{}, fmt.Errorf("commit purge transaction: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,138 @@
+package identity_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+func TestRecoverAdministratorRestoresLockedOutAdministrator(t *testing.T) {
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+
+	oldLogin, err := loginWithSource(context.Background(), databaseConnection, "admin", "correct horse battery staple", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("login before lockout: %v", err)
+	}
+	for range testLoginThrottleSettings.FailureLimit {
+		loginWithSource(context.Background(), databaseConnection, "admin", "wrong password", "192.0.2.1")
+	}
+	if _, err := loginWithSource(context.Background(), databaseConnection, "admin", "correct horse battery staple", "192.0.2.1"); !errors.Is(err, identity.ErrInvalidCredentials) {
+		t.Fatalf("locked login error = %v", err)
+	}
+
+	result, err := identity.RecoverAdministrator(context.Background(), databaseConnection, testLoginThrottleSettings, identity.RecoverAdministratorInput{
+		Identifier: "Admin",
+		Password:   "a recovered sufficiently long password",
+	})
+	if err != nil {
+		t.Fatalf("recover administrator: %v", err)
+	}
+	if result.Reenabled || result.RoleGranted || result.Subject.ID != administrator.ID || result.Subject.SecurityVersion != administrator.SecurityVersion+1 {
+		t.Fatalf("recovery result = %#v", result)
+	}
+	if _, err := identity.CurrentSession(context.Background(), databaseConnection, oldLogin.SessionToken, testSessionSettings); !errors.Is(err, identity.ErrInvalidSession) {
+		t.Fatalf("session after recovery error = %v", err)
+	}
+	// 恢复后节流已清除，新密码只能换取改密会话。
+	recovered, err := loginWithSource(context.Background(), databaseConnection, "admin", "a recovered sufficiently long password", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("login after recovery: %v", err)
+	}
+	if recovered.Access != "仅改密" {
+		t.Fatalf("recovered session access = %q", recovered.Access)
+	}
+
+	var recoveryAudits int
+	if err := databaseConnection.QueryRow(`
+		SELECT COUNT(*)
+		FROM identity_audit_events
+		WHERE event_action = '管理员恢复' AND actor_subject_id IS NULL AND target_subject_id = ?
+	`, administrator.ID).Scan(&recoveryAudits); err != nil {
+		t.Fatalf("count recovery audits: %v", err)
+	}
+	if recoveryAudits != 1 {
+		t.Fatalf("recovery audit count = %d", recoveryAudits)
+	}
+}
+
+func TestRecoverAdministratorReenablesAndPromotesSubject(t *testing.T) {
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	created, err := identity.CreateSubject(context.Background(), databaseConnection, administrator.ID, identity.CreateSubjectInput{
+		DisplayName: "张三",
+		Identifier:  "zhangsan",
+		Password:    "a sufficiently long password",
+	}, testPasswordPolicy)
+	if err != nil {
+		t.Fatalf("create subject: %v", err)
+	}
+	if _, err := identity.DisableSubject(context.Background(), databaseConnection, administrator.ID, created.ID); err != nil {
+		t.Fatalf("disable subject: %v", err)
+	}
+
+	result, err := identity.RecoverAdministrator(context.Background(), databaseConnection, testLoginThrottleSettings, identity.RecoverAdministratorInput{
+		Identifier: "zhangsan",
+		Password:   "a recovered sufficiently long password",
+	})
+	if err != nil {
+		t.Fatalf("recover administrator: %v", err)
+	}
+	if !result.Reenabled || !result.RoleGranted || result.Subject.Status != "启用" || len(result.Subject.Roles) != 1 || result.Subject.Roles[0] != "identity.admin" {
+		t.Fatalf("recovery result = %#v", result)
+	}
+
+	if _, err := identity.RecoverAdministrator(context.Background(), databaseConnection, testLoginThrottleSettings, identity.RecoverAdministratorInput{
+		Identifier: "missing",
+		Password:   "a recovered sufficiently long password",
+	}); !errors.Is(err, identity.ErrSubjectNotFound) {
+		t.Fatalf("missing subject recovery error = %v", err)
+	}
+}
+
+func TestPurgeDeletesExpiredSessionsAndThrottles(t *testing.T) {
+	databaseConnection := migratedDatabase(t)
+	bootstrapAdministrator(t, databaseConnection)
+
+	active, err := loginWithSource(context.Background(), databaseConnection, "admin", "correct horse battery staple", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("login active session: %v", err)
+	}
+	loggedOut, err := loginWithSource(context.Background(), databaseConnection, "admin", "correct horse battery staple", "192.0.2.2")
+	if err != nil {
+		t.Fatalf("login logged-out session: %v", err)
+	}
+	if err := identity.Logout(context.Background(), databaseConnection, loggedOut.SessionToken); err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+	loginWithSource(context.Background(), databaseConnection, "admin", "wrong password", "192.0.2.3")
+	// 让失败窗口过期，使该节流记录可被清理。
+	if _, err := databaseConnection.Exec(`UPDATE identity_login_throttles SET window_started_at = datetime('now', '-2 hours')`); err != nil {
+		t.Fatalf("age login throttle: %v", err)
+	}
+
+	result, err := identity.Purge(context.Background(), databaseConnection, testLoginThrottleSettings)
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if result.Sessions != 1 || result.LoginThrottles != 1 {
+		t.Fatalf("purge result = %#v", result)
+	}
+	if _, err := identity.CurrentSession(context.Background(), databaseConnection, active.SessionToken, testSessionSettings); err != nil {
+		t.Fatalf("active session after purge: %v", err)
+	}
+
+	var purgeAudits int
+	if err := databaseConnection.QueryRow(`
+		SELECT COUNT(*)
+		FROM identity_audit_events
+		WHERE event_action = '维护清理' AND json_extract(metadata, '$.sessions') = 1
+	`).Scan(&purgeAudits); err != nil {
+		t.Fatalf("count purge audits: %v", err)
+	}
+	if purgeAudits != 1 {
+		t.Fatalf("purge audit count = %d", purgeAudits)
+	}
+}
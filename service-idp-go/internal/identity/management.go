@@ -3,6 +3,7 @@ package identity
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
@@ -10,13 +11,13 @@ import (
 	"unicode/utf8"
 
 	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database/sqlc"
-	"github.com/ovaphlow/pitchfork/service-idp-go/internal/password"
 )
 
 var ErrSubjectNotFound = errors.New("subject not found")
 var ErrIdentifierAlreadyExists = errors.New("identifier is already in use")
-var ErrLastAdministrator = errors.New("cannot disable the last enabled administrator")
+var ErrLastAdministrator = errors.New("cannot remove the last enabled administrator")
 var ErrInvalidSubjectInput = errors.New("invalid subject input")
+var ErrSubjectErased = errors.New("subject has been erased")
 
 type Subject struct {
 	ID              string    `json:"id"`
@@ -76,6 +77,7 @@ func ListSubjects(ctx context.Context, database *sql.DB, input ListSubjectsInput
 			row.IdentifierValue,
 			row.CreatedAt,
 			row.UpdatedAt,
+			row.ErasedAt,
 		)
 		if err != nil {
 			return ListSubjectsResult{}, err
@@ -89,7 +91,7 @@ func GetSubject(ctx context.Context, database *sql.DB, subjectID string) (Subjec
 	return getSubject(ctx, sqlc.New(database), subjectID)
 }
 
-func CreateSubject(ctx context.Context, database *sql.DB, actorSubjectID string, input CreateSubjectInput) (Subject, error) {
+func CreateSubject(ctx context.Context, database *sql.DB, actorSubjectID string, input CreateSubjectInput, policy PasswordPolicy) (Subject, error) {
 	displayName, err := validateDisplayName(input.DisplayName)
 	if err != nil {
 		return Subject{}, fmt.Errorf("%w: %v", ErrInvalidSubjectInput, err)
@@ -98,10 +100,13 @@ func CreateSubject(ctx context.Context, database *sql.DB, actorSubjectID string,
 	if err != nil {
 		return Subject{}, fmt.Errorf("%w: validate account identifier: %v", ErrInvalidSubjectInput, err)
 	}
-	passwordHash, err := password.Hash(input.Password)
-	if err != nil {
+	passwordHash, err := policy.hash(input.Password)
+	if errors.Is(err, ErrInvalidPasswordInput) {
 		return Subject{}, fmt.Errorf("%w: %v", ErrInvalidSubjectInput, err)
 	}
+	if err != nil {
+		return Subject{}, err
+	}
 
 	queries := sqlc.New(database)
 	transaction, err := database.BeginTx(ctx, nil)
@@ -121,6 +126,9 @@ func CreateSubject(ctx context.Context, database *sql.DB, actorSubjectID string,
 	if !errors.Is(err, sql.ErrNoRows) {
 		return Subject{}, fmt.Errorf("check account identifier: %w", err)
 	}
+	if err := ensureLoginIdentifierAvailable(ctx, transactionQueries, identifier, ""); err != nil {
+		return Subject{}, err
+	}
 
 	now := time.Now().UTC()
 	subjectID, err := NewULID(now)
@@ -214,11 +222,24 @@ func CreateSubject(ctx context.Context, database *sql.DB, actorSubjectID string,
 	}, nil
 }
 
+// DisableSubject disables a subject and revokes its sessions. The final
+// enabled identity.admin holder cannot be disabled.
 func DisableSubject(ctx context.Context, database *sql.DB, actorSubjectID string, subjectID string) (Subject, error) {
+	return setSubjectStatus(ctx, database, actorSubjectID, subjectID, "禁用")
+}
+
+// EnableSubject enables a disabled subject again. Sessions and tokens revoked
+// when it was disabled stay invalid; the subject has to log in again.
+// Enabling an erased subject fails with ErrSubjectErased.
+func EnableSubject(ctx context.Context, database *sql.DB, actorSubjectID string, subjectID string) (Subject, error) {
+	return setSubjectStatus(ctx, database, actorSubjectID, subjectID, "启用")
+}
+
+func setSubjectStatus(ctx context.Context, database *sql.DB, actorSubjectID string, subjectID string, status string) (Subject, error) {
 	queries := sqlc.New(database)
 	transaction, err := database.BeginTx(ctx, nil)
 	if err != nil {
-		return Subject{}, fmt.Errorf("begin disable subject transaction: %w", err)
+		return Subject{}, fmt.Errorf("begin subject status transaction: %w", err)
 	}
 	defer transaction.Rollback()
 	transactionQueries := queries.WithTx(transaction)
@@ -227,73 +248,185 @@ func DisableSubject(ctx context.Context, database *sql.DB, actorSubjectID string
 	if err != nil {
 		return Subject{}, err
 	}
-	if subject.Status == "禁用" {
-		if err := transaction.Commit(); err != nil {
-			return Subject{}, fmt.Errorf("commit existing disabled subject: %w", err)
+	subject, err = changeSubjectStatus(ctx, transactionQueries, subjectActor(actorSubjectID), subject, status, time.Now().UTC())
+	if err != nil {
+		return Subject{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return Subject{}, fmt.Errorf("commit subject status transaction: %w", err)
+	}
+	return subject, nil
+}
+
+// UpdateSubjectDisplayName renames a subject. Sessions and issued tokens
+// stay valid; only updated_at changes on the subject.
+func UpdateSubjectDisplayName(ctx context.Context, database *sql.DB, actorSubjectID string, subjectID string, displayName string) (Subject, error) {
+	displayName, err := validateDisplayName(displayName)
+	if err != nil {
+		return Subject{}, fmt.Errorf("%w: %v", ErrInvalidSubjectInput, err)
+	}
+
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return Subject{}, fmt.Errorf("begin subject profile transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	subject, err := getSubject(ctx, transactionQueries, subjectID)
+	if err != nil {
+		return Subject{}, err
+	}
+	if subject.Status == "已删除" {
+		return Subject{}, ErrSubjectErased
+	}
+	if subject.DisplayName != displayName {
+		if err := updateSubjectDisplayName(ctx, transactionQueries, subjectActor(actorSubjectID), subjectID, displayName, time.Now().UTC()); err != nil {
+			return Subject{}, err
 		}
-		return subject, nil
+		if subject, err = getSubject(ctx, transactionQueries, subjectID); err != nil {
+			return Subject{}, err
+		}
+	}
+	if err := transaction.Commit(); err != nil {
+		return Subject{}, fmt.Errorf("commit subject profile transaction: %w", err)
 	}
+	return subject, nil
+}
 
-	if hasRole(subject.Roles, "identity.admin") {
-		remainingAdministrators, err := transactionQueries.CountEnabledSubjectsByRoleCodeExcludingSubjectID(ctx, sqlc.CountEnabledSubjectsByRoleCodeExcludingSubjectIDParams{
-			Status:   "启用",
-			RoleCode: "identity.admin",
-			ID:       subjectID,
+// changeSubjectStatus enables or disables subject within the caller's
+// transaction and writes a 主体状态变更 event. Disabling keeps the last
+// enabled administrator and revokes the subject's sessions. Both directions
+// increment the security version, so nothing issued before a subject was
+// disabled becomes valid again. A subject already in status is returned
+// unchanged; an erased subject fails with ErrSubjectErased.
+func changeSubjectStatus(ctx context.Context, queries sqlc.Querier, actor auditActor, subject Subject, status string, now time.Time) (Subject, error) {
+	if subject.Status == "已删除" {
+		return Subject{}, ErrSubjectErased
+	}
+	if subject.Status == status {
+		return subject, nil
+	}
+	subjectID := subject.ID
+	if status == "禁用" {
+		if hasRole(subject.Roles, "identity.admin") {
+			remainingAdministrators, err := queries.CountEnabledSubjectsByRoleCodeExcludingSubjectID(ctx, sqlc.CountEnabledSubjectsByRoleCodeExcludingSubjectIDParams{
+				Status:   "启用",
+				RoleCode: "identity.admin",
+				ID:       subjectID,
+			})
+			if err != nil {
+				return Subject{}, fmt.Errorf("count remaining administrators: %w", err)
+			}
+			if remainingAdministrators == 0 {
+				return Subject{}, ErrLastAdministrator
+			}
+		}
+		updated, err := queries.DisableSubject(ctx, sqlc.DisableSubjectParams{
+			Status:     "禁用",
+			DisabledAt: sql.NullTime{Time: now, Valid: true},
+			UpdatedAt:  now,
+			ID:         subjectID,
+			Status_2:   "启用",
 		})
 		if err != nil {
-			return Subject{}, fmt.Errorf("count remaining administrators: %w", err)
+			return Subject{}, fmt.Errorf("disable subject: %w", err)
 		}
-		if remainingAdministrators == 0 {
-			return Subject{}, ErrLastAdministrator
+		if updated != 1 {
+			return Subject{}, fmt.Errorf("disable subject: expected one enabled subject, updated %d", updated)
+		}
+		if _, err := queries.RevokeActiveSessionsBySubjectID(ctx, sqlc.RevokeActiveSessionsBySubjectIDParams{
+			RevokedAt:     sql.NullTime{Time: now, Valid: true},
+			RevokedReason: sql.NullString{String: "主体禁用", Valid: true},
+			SubjectID:     subjectID,
+		}); err != nil {
+			return Subject{}, fmt.Errorf("revoke subject sessions: %w", err)
+		}
+	} else {
+		updated, err := queries.EnableSubject(ctx, sqlc.EnableSubjectParams{
+			Status:    "启用",
+			UpdatedAt: now,
+			ID:        subjectID,
+			Status_2:  subject.Status,
+		})
+		if err != nil {
+			return Subject{}, fmt.Errorf("enable subject: %w", err)
+		}
+		if updated != 1 {
+			return Subject{}, fmt.Errorf("enable subject: expected one disabled subject, updated %d", updated)
 		}
 	}
 
-	now := time.Now().UTC()
-	updated, err := transactionQueries.DisableSubject(ctx, sqlc.DisableSubjectParams{
-		Status:     "禁用",
-		DisabledAt: sql.NullTime{Time: now, Valid: true},
-		UpdatedAt:  now,
-		ID:         subjectID,
-		Status_2:   "启用",
+	auditEventID, err := NewULID(now)
+	if err != nil {
+		return Subject{}, err
+	}
+	metadata, err := json.Marshal(actor.annotate(map[string]string{"status": status}))
+	if err != nil {
+		return Subject{}, fmt.Errorf("encode subject status audit metadata: %w", err)
+	}
+	if err := queries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
+		ID:              auditEventID,
+		EventAction:     "主体状态变更",
+		Outcome:         "成功",
+		ActorSubjectID:  actor.column(),
+		TargetSubjectID: sql.NullString{String: subjectID, Valid: true},
+		RequestID:       sql.NullString{},
+		SourceHash:      nil,
+		Metadata:        string(metadata),
+		CreatedAt:       now,
+	}); err != nil {
+		return Subject{}, fmt.Errorf("write subject status audit event: %w", err)
+	}
+
+	subject.Status = status
+	subject.SecurityVersion++
+	subject.UpdatedAt = now
+	return subject, nil
+}
+
+// updateSubjectDisplayName changes the profile display name within the
+// caller's transaction. Like other subject edits it bumps updated_at but not
+// the security version, so sessions survive a rename.
+func updateSubjectDisplayName(ctx context.Context, queries sqlc.Querier, actor auditActor, subjectID string, displayName string, now time.Time) error {
+	updated, err := queries.UpdateProfileDisplayName(ctx, sqlc.UpdateProfileDisplayNameParams{
+		DisplayName: displayName,
+		UpdatedAt:   now,
+		SubjectID:   subjectID,
 	})
 	if err != nil {
-		return Subject{}, fmt.Errorf("disable subject: %w", err)
+		return fmt.Errorf("update subject display name: %w", err)
 	}
 	if updated != 1 {
-		return Subject{}, fmt.Errorf("disable subject: expected one enabled subject, updated %d", updated)
+		return ErrSubjectNotFound
 	}
-	if _, err := transactionQueries.RevokeActiveSessionsBySubjectID(ctx, sqlc.RevokeActiveSessionsBySubjectIDParams{
-		RevokedAt:     sql.NullTime{Time: now, Valid: true},
-		RevokedReason: sql.NullString{String: "主体禁用", Valid: true},
-		SubjectID:     subjectID,
-	}); err != nil {
-		return Subject{}, fmt.Errorf("revoke subject sessions: %w", err)
+	if _, err := queries.TouchSubject(ctx, sqlc.TouchSubjectParams{UpdatedAt: now, ID: subjectID}); err != nil {
+		return fmt.Errorf("touch subject: %w", err)
 	}
 	auditEventID, err := NewULID(now)
 	if err != nil {
-		return Subject{}, err
+		return err
 	}
-	if err := transactionQueries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
+	// The display name is personal data and stays out of the audit log.
+	metadata, err := json.Marshal(actor.annotate(map[string]string{"change": "显示名称"}))
+	if err != nil {
+		return fmt.Errorf("encode subject profile audit metadata: %w", err)
+	}
+	if err := queries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
 		ID:              auditEventID,
 		EventAction:     "主体状态变更",
 		Outcome:         "成功",
-		ActorSubjectID:  sql.NullString{String: actorSubjectID, Valid: true},
+		ActorSubjectID:  actor.column(),
 		TargetSubjectID: sql.NullString{String: subjectID, Valid: true},
 		RequestID:       sql.NullString{},
 		SourceHash:      nil,
-		Metadata:        `{"status":"禁用"}`,
+		Metadata:        string(metadata),
 		CreatedAt:       now,
 	}); err != nil {
-		return Subject{}, fmt.Errorf("write subject disable audit event: %w", err)
-	}
-	if err := transaction.Commit(); err != nil {
-		return Subject{}, fmt.Errorf("commit disable subject transaction: %w", err)
+		return fmt.Errorf("write subject profile audit event: %w", err)
 	}
-
-	subject.Status = "禁用"
-	subject.SecurityVersion++
-	subject.UpdatedAt = now
-	return subject, nil
+	return nil
 }
 
 func getSubject(ctx context.Context, queries sqlc.Querier, subjectID string) (Subject, error) {
@@ -317,10 +450,11 @@ func getSubject(ctx context.Context, queries sqlc.Querier, subjectID string) (Su
 		row.IdentifierValue,
 		row.CreatedAt,
 		row.UpdatedAt,
+		row.ErasedAt,
 	)
 }
 
-func subjectFromManagementValues(ctx context.Context, queries sqlc.Querier, subjectID string, status string, securityVersion int64, displayName string, identifier string, createdAt time.Time, updatedAt time.Time) (Subject, error) {
+func subjectFromManagementValues(ctx context.Context, queries sqlc.Querier, subjectID string, status string, securityVersion int64, displayName string, identifier string, createdAt time.Time, updatedAt time.Time, erasedAt sql.NullTime) (Subject, error) {
 	roles, err := queries.ListRoleCodesBySubjectID(ctx, subjectID)
 	if err != nil {
 		return Subject{}, fmt.Errorf("list subject roles: %w", err)
@@ -328,6 +462,11 @@ func subjectFromManagementValues(ctx context.Context, queries sqlc.Querier, subj
 	if roles == nil {
 		roles = []string{}
 	}
+	// An erased subject stays 禁用 in identity_subjects; callers see it as
+	// 已删除 so that no status change can bring it back.
+	if erasedAt.Valid {
+		status = "已删除"
+	}
 	return Subject{
 		ID:              subjectID,
 		Status:          status,
@@ -17,7 +17,7 @@ func TestCreateListAndDisableSubject(t *testing.T) {
 		DisplayName: "张三",
 		Identifier:  "ZhangSan",
 		Password:    "a sufficiently long password",
-	})
+	}, testPasswordPolicy)
 	if err != nil {
 		t.Fatalf("create subject: %v", err)
 	}
@@ -72,6 +72,62 @@ func TestCreateListAndDisableSubject(t *testing.T) {
 	}
 }
 
+func TestEnableAndRenameSubject(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	created, err := identity.CreateSubject(ctx, databaseConnection, administrator.ID, identity.CreateSubjectInput{
+		DisplayName: "张三",
+		Identifier:  "zhangsan",
+		Password:    "a sufficiently long password",
+	}, testPasswordPolicy)
+	if err != nil {
+		t.Fatalf("create subject: %v", err)
+	}
+	if _, err := identity.DisableSubject(ctx, databaseConnection, administrator.ID, created.ID); err != nil {
+		t.Fatalf("disable subject: %v", err)
+	}
+	enabled, err := identity.EnableSubject(ctx, databaseConnection, administrator.ID, created.ID)
+	if err != nil {
+		t.Fatalf("enable subject: %v", err)
+	}
+	// 重新启用同样提升安全版本，禁用前签发的会话和令牌不会恢复。
+	if enabled.Status != "启用" || enabled.SecurityVersion != created.SecurityVersion+2 {
+		t.Fatalf("enabled subject = %#v", enabled)
+	}
+	login, err := loginWithSource(ctx, databaseConnection, "zhangsan", "a sufficiently long password", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("login re-enabled subject: %v", err)
+	}
+
+	renamed, err := identity.UpdateSubjectDisplayName(ctx, databaseConnection, administrator.ID, created.ID, "  张三丰 ")
+	if err != nil {
+		t.Fatalf("rename subject: %v", err)
+	}
+	if renamed.DisplayName != "张三丰" || renamed.SecurityVersion != enabled.SecurityVersion || !renamed.UpdatedAt.After(created.UpdatedAt) {
+		t.Fatalf("renamed subject = %#v", renamed)
+	}
+	// 改名不影响现有会话。
+	if _, err := identity.CurrentSession(ctx, databaseConnection, login.SessionToken, testSessionSettings); err != nil {
+		t.Fatalf("session after rename: %v", err)
+	}
+	if _, err := identity.UpdateSubjectDisplayName(ctx, databaseConnection, administrator.ID, created.ID, " "); !errors.Is(err, identity.ErrInvalidSubjectInput) {
+		t.Fatalf("blank display name error = %v", err)
+	}
+
+	var enableAudits, renameAudits int
+	if err := databaseConnection.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM identity_audit_events WHERE event_action = '主体状态变更' AND actor_subject_id = ? AND target_subject_id = ? AND metadata = '{"status":"启用"}'),
+			(SELECT COUNT(*) FROM identity_audit_events WHERE event_action = '主体状态变更' AND actor_subject_id = ? AND target_subject_id = ? AND metadata = '{"change":"显示名称"}')
+	`, administrator.ID, created.ID, administrator.ID, created.ID).Scan(&enableAudits, &renameAudits); err != nil {
+		t.Fatalf("count subject change audits: %v", err)
+	}
+	if enableAudits != 1 || renameAudits != 1 {
+		t.Fatalf("subject change audits = enable:%d rename:%d", enableAudits, renameAudits)
+	}
+}
+
 func TestCreateSubjectRejectsDuplicateAccountIdentifier(t *testing.T) {
 	databaseConnection := migratedDatabase(t)
 	administrator := bootstrapAdministrator(t, databaseConnection)
@@ -80,7 +136,7 @@ func TestCreateSubjectRejectsDuplicateAccountIdentifier(t *testing.T) {
 		DisplayName: "另一个管理员",
 		Identifier:  "ADMIN",
 		Password:    "a sufficiently long password",
-	})
+	}, testPasswordPolicy)
 	if !errors.Is(err, identity.ErrIdentifierAlreadyExists) {
 		t.Fatalf("duplicate account identifier error = %v", err)
 	}
@@ -122,7 +178,7 @@ func TestTemporaryPasswordRequiresChangeAndPasswordChangeRevokesSessions(t *test
 		DisplayName: "张三",
 		Identifier:  "zhangsan",
 		Password:    "original sufficiently long password",
-	})
+	}, testPasswordPolicy)
 	if err != nil {
 		t.Fatalf("create subject: %v", err)
 	}
@@ -131,7 +187,7 @@ func TestTemporaryPasswordRequiresChangeAndPasswordChangeRevokesSessions(t *test
 	if err != nil {
 		t.Fatalf("login with original password: %v", err)
 	}
-	if err := identity.SetTemporaryPassword(context.Background(), databaseConnection, administrator.ID, created.ID, "temporary sufficiently long password"); err != nil {
+	if err := identity.SetTemporaryPassword(context.Background(), databaseConnection, administrator.ID, created.ID, "temporary sufficiently long password", testPasswordPolicy); err != nil {
 		t.Fatalf("set temporary password: %v", err)
 	}
 	if _, err := identity.CurrentSession(context.Background(), databaseConnection, originalLogin.SessionToken, testSessionSettings); !errors.Is(err, identity.ErrInvalidSession) {
@@ -156,7 +212,7 @@ func TestTemporaryPasswordRequiresChangeAndPasswordChangeRevokesSessions(t *test
 	if err := identity.ChangePassword(context.Background(), databaseConnection, created.ID, identity.ChangePasswordInput{
 		CurrentPassword: "temporary sufficiently long password",
 		NewPassword:     "replacement sufficiently long password",
-	}); err != nil {
+	}, testPasswordPolicy); err != nil {
 		t.Fatalf("change password: %v", err)
 	}
 	if _, err := identity.CurrentSession(context.Background(), databaseConnection, temporaryLogin.SessionToken, testSessionSettings); !errors.Is(err, identity.ErrInvalidSession) {
@@ -207,7 +263,7 @@ func TestChangePasswordRejectsIncorrectCurrentPassword(t *testing.T) {
 		DisplayName: "张三",
 		Identifier:  "zhangsan",
 		Password:    "original sufficiently long password",
-	})
+	}, testPasswordPolicy)
 	if err != nil {
 		t.Fatalf("create subject: %v", err)
 	}
@@ -215,7 +271,7 @@ func TestChangePasswordRejectsIncorrectCurrentPassword(t *testing.T) {
 	err = identity.ChangePassword(context.Background(), databaseConnection, created.ID, identity.ChangePasswordInput{
 		CurrentPassword: "incorrect sufficiently long password",
 		NewPassword:     "replacement sufficiently long password",
-	})
+	}, testPasswordPolicy)
 	if !errors.Is(err, identity.ErrIncorrectPassword) {
 		t.Fatalf("change password error = %v", err)
 	}
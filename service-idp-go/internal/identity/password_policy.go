@@ -0,0 +1,123 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database/sqlc"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/password"
+)
+
+var ErrPasswordReused = errors.New("password was used recently")
+
+// MaximumPasswordHistorySize bounds PasswordPolicy.HistorySize. Replaced
+// hashes are kept up to this many per subject whatever the policy says, so
+// raising HistorySize later covers passwords that were already replaced.
+const MaximumPasswordHistorySize = 24
+
+// PasswordPolicy applies to passwords chosen by subjects and set by
+// administrators. Bootstrap and operator recovery passwords only meet the
+// password.MinimumLength floor. The zero value keeps that floor and nothing
+// else.
+type PasswordPolicy struct {
+	MinimumLength int
+	Denylist      password.Denylist
+	// HistorySize rejects the current password and the HistorySize-1
+	// passwords before it when a subject chooses a new one. Zero allows
+	// reuse.
+	HistorySize int
+	// MaximumAge moves a 有效 credential to 需更新 at the first login after
+	// it has elapsed. Zero disables expiry.
+	MaximumAge time.Duration
+}
+
+func (policy PasswordPolicy) validate() error {
+	switch {
+	case policy.MinimumLength != 0 && policy.MinimumLength < password.MinimumLength:
+		return fmt.Errorf("minimum length must be at least %d", password.MinimumLength)
+	case policy.HistorySize < 0 || policy.HistorySize > MaximumPasswordHistorySize:
+		return fmt.Errorf("history size must be between 0 and %d", MaximumPasswordHistorySize)
+	case policy.MaximumAge < 0:
+		return fmt.Errorf("maximum age must not be negative")
+	}
+	return nil
+}
+
+// hash checks value against the length and denylist rules and hashes it.
+// Rule violations wrap ErrInvalidPasswordInput.
+func (policy PasswordPolicy) hash(value string) (string, error) {
+	if err := policy.validate(); err != nil {
+		return "", fmt.Errorf("invalid password policy: %w", err)
+	}
+	if err := (password.Policy{MinimumLength: policy.MinimumLength, Denylist: policy.Denylist}).Check(value); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidPasswordInput, err)
+	}
+	passwordHash, err := password.Hash(value)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidPasswordInput, err)
+	}
+	return passwordHash, nil
+}
+
+// expired reports whether a 有效 credential last changed at changedAt has
+// outlived MaximumAge.
+func (policy PasswordPolicy) expired(credentialStatus string, changedAt time.Time, now time.Time) bool {
+	return policy.MaximumAge > 0 && credentialStatus == "有效" && !now.Before(changedAt.Add(policy.MaximumAge))
+}
+
+// checkPasswordReuse rejects value when it matches the current hash or one of
+// the HistorySize-1 hashes before it. Every comparison is a full Argon2id
+// verification, which is why the history is bounded.
+func checkPasswordReuse(ctx context.Context, queries sqlc.Querier, policy PasswordPolicy, subjectID string, currentHash string, value string) error {
+	if policy.HistorySize == 0 {
+		return nil
+	}
+	hashes := []string{currentHash}
+	if policy.HistorySize > 1 {
+		previous, err := queries.ListPasswordHistoryHashes(ctx, sqlc.ListPasswordHistoryHashesParams{
+			SubjectID: subjectID,
+			Limit:     int64(policy.HistorySize - 1),
+		})
+		if err != nil {
+			return fmt.Errorf("load password history: %w", err)
+		}
+		hashes = append(hashes, previous...)
+	}
+	for _, encoded := range hashes {
+		verification, err := password.Verify(value, encoded)
+		if err != nil {
+			return fmt.Errorf("compare password history: %w", err)
+		}
+		if verification.Matched {
+			return ErrPasswordReused
+		}
+	}
+	return nil
+}
+
+// archivePassword keeps the hash being replaced and drops the oldest entries
+// beyond MaximumPasswordHistorySize.
+func archivePassword(ctx context.Context, queries sqlc.Querier, subjectID string, passwordHash string, now time.Time) error {
+	historyID, err := NewULID(now)
+	if err != nil {
+		return err
+	}
+	if err := queries.CreatePasswordHistoryEntry(ctx, sqlc.CreatePasswordHistoryEntryParams{
+		ID:           historyID,
+		SubjectID:    subjectID,
+		PasswordHash: passwordHash,
+		CreatedAt:    now,
+	}); err != nil {
+		return fmt.Errorf("archive password hash: %w", err)
+	}
+	if _, err := queries.PrunePasswordHistory(ctx, sqlc.PrunePasswordHistoryParams{
+		SubjectID:   subjectID,
+		SubjectID_2: subjectID,
+		Limit:       MaximumPasswordHistorySize,
+	}); err != nil {
+		return fmt.Errorf("prune password history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package identity_test
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/password"
+)
+
+func TestLoginRehashesOutdatedPasswordHash(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+
+	// 模拟旧参数生成的哈希。
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		t.Fatalf("read salt: %v", err)
+	}
+	legacyHash := fmt.Sprintf(
+		"$argon2id$v=19$m=%d,t=%d,p=%d$%s$%s",
+		8*1024, 1, 1,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(argon2.IDKey([]byte("correct horse battery staple"), salt, 1, 8*1024, 1, 32)),
+	)
+	if _, err := databaseConnection.Exec(`UPDATE identity_password_credentials SET password_hash = ? WHERE subject_id = ?`, legacyHash, administrator.ID); err != nil {
+		t.Fatalf("store legacy hash: %v", err)
+	}
+	before := readPasswordVersions(t, databaseConnection, administrator.ID)
+
+	login, err := loginWithSource(ctx, databaseConnection, "admin", "correct horse battery staple", "192.0.2.1")
+	if err != nil || login.Access != "完整" {
+		t.Fatalf("login with legacy hash = %#v, %v", login, err)
+	}
+	var storedHash string
+	if err := databaseConnection.QueryRow(`SELECT password_hash FROM identity_password_credentials WHERE subject_id = ?`, administrator.ID).Scan(&storedHash); err != nil {
+		t.Fatalf("read password hash: %v", err)
+	}
+	verification, err := password.Verify("correct horse battery staple", storedHash)
+	if err != nil || !verification.Matched || verification.NeedsRehash {
+		t.Fatalf("upgraded hash verification = %#v, %v", verification, err)
+	}
+	// 重新哈希不改变密码版本和安全版本，既有会话继续有效。
+	if after := readPasswordVersions(t, databaseConnection, administrator.ID); after != before {
+		t.Fatalf("versions after rehash = %v, want %v", after, before)
+	}
+	if _, err := identity.CurrentSession(ctx, databaseConnection, login.SessionToken, testSessionSettings); err != nil {
+		t.Fatalf("current session after rehash: %v", err)
+	}
+}
+
+func TestPasswordPolicyRejectsCommonAndReusedPasswords(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	policy := identity.PasswordPolicy{
+		MinimumLength: 14,
+		Denylist:      password.CommonPasswords(),
+		HistorySize:   3,
+	}
+
+	if _, err := identity.CreateSubject(ctx, databaseConnection, administrator.ID, identity.CreateSubjectInput{
+		DisplayName: "张三",
+		Identifier:  "zhangsan",
+		Password:    "PasswordPassword",
+	}, policy); !errors.Is(err, identity.ErrInvalidSubjectInput) {
+		t.Fatalf("create subject with common password error = %v", err)
+	}
+	if err := identity.SetTemporaryPassword(ctx, databaseConnection, administrator.ID, administrator.ID, "thirteen byte", policy); !errors.Is(err, identity.ErrInvalidPasswordInput) {
+		t.Fatalf("temporary password below policy length error = %v", err)
+	}
+
+	change := func(current string, next string) error {
+		return identity.ChangePassword(ctx, databaseConnection, administrator.ID, identity.ChangePasswordInput{
+			CurrentPassword: current,
+			NewPassword:     next,
+		}, policy)
+	}
+	if err := change("correct horse battery staple", "correct horse battery staple"); !errors.Is(err, identity.ErrPasswordReused) {
+		t.Fatalf("reuse current password error = %v", err)
+	}
+	passwords := []string{"correct horse battery staple", "second password in history", "third password in history"}
+	for index := 1; index < len(passwords); index++ {
+		if err := change(passwords[index-1], passwords[index]); err != nil {
+			t.Fatalf("change to password %d: %v", index, err)
+		}
+	}
+	// 当前密码加前两个密码都在历史窗口内。
+	if err := change(passwords[2], passwords[0]); !errors.Is(err, identity.ErrPasswordReused) {
+		t.Fatalf("reuse oldest password error = %v", err)
+	}
+	if err := change(passwords[2], "fourth password in history"); err != nil {
+		t.Fatalf("change to fourth password: %v", err)
+	}
+	if err := change("fourth password in history", passwords[0]); err != nil {
+		t.Fatalf("reuse password outside history: %v", err)
+	}
+}
+
+func TestLoginExpiresPasswordPastMaximumAge(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	if _, err := databaseConnection.Exec(`UPDATE identity_password_credentials SET changed_at = ? WHERE subject_id = ?`, time.Now().UTC().Add(-2*time.Hour), administrator.ID); err != nil {
+		t.Fatalf("age password: %v", err)
+	}
+
+	login, err := identity.Login(ctx, databaseConnection, identity.LoginInput{
+		Identifier:    "admin",
+		Password:      "correct horse battery staple",
+		SourceAddress: "192.0.2.1",
+	}, testSessionSettings, testLoginThrottleSettings, identity.PasswordPolicy{MaximumAge: time.Hour})
+	if err != nil || login.Access != "仅改密" {
+		t.Fatalf("login with expired password = %#v, %v", login, err)
+	}
+	var credentialStatus string
+	if err := databaseConnection.QueryRow(`SELECT credential_status FROM identity_password_credentials WHERE subject_id = ?`, administrator.ID).Scan(&credentialStatus); err != nil {
+		t.Fatalf("read credential status: %v", err)
+	}
+	if credentialStatus != "需更新" {
+		t.Fatalf("credential status = %q", credentialStatus)
+	}
+	var metadata string
+	if err := databaseConnection.QueryRow(`
+		SELECT metadata
+		FROM identity_audit_events
+		WHERE event_action = '凭据变更' AND target_subject_id = ? AND actor_subject_id IS NULL
+	`, administrator.ID).Scan(&metadata); err != nil {
+		t.Fatalf("read expiry audit event: %v", err)
+	}
+	if !strings.Contains(metadata, "过期") {
+		t.Fatalf("expiry audit metadata = %s", metadata)
+	}
+}
+
+func readPasswordVersions(t *testing.T, databaseConnection *sql.DB, subjectID string) [2]int64 {
+	t.Helper()
+	var versions [2]int64
+	if err := databaseConnection.QueryRow(`
+		SELECT
+			(SELECT password_revision FROM identity_password_credentials WHERE subject_id = ?),
+			(SELECT security_version FROM identity_subjects WHERE id = ?)
+	`, subjectID, subjectID).Scan(&versions[0], &versions[1]); err != nil {
+		t.Fatalf("read password versions: %v", err)
+	}
+	return versions
+}
@@ -0,0 +1,258 @@
+package identity
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database/sqlc"
+)
+
+var ErrInvalidPasswordResetToken = errors.New("password reset token is invalid or expired")
+var ErrPasswordResetThrottled = errors.New("too many password reset requests")
+
+const passwordResetTokenTTL = 15 * time.Minute
+
+// passwordResetDeliveryTimeout bounds one delivery, which outlives the
+// request that triggered it.
+const passwordResetDeliveryTimeout = time.Minute
+
+// PasswordResetNotice carries a reset token to the verified 邮箱 identifier
+// it was requested for.
+type PasswordResetNotice struct {
+	Address   string
+	Token     string
+	ExpiresAt time.Time
+}
+
+// PasswordResetNotifier delivers reset tokens, usually as a link to the
+// password reset page. It is called on its own goroutine after the request
+// has been answered, so implementations must be safe for concurrent use.
+type PasswordResetNotifier interface {
+	SendPasswordReset(ctx context.Context, notice PasswordResetNotice) error
+}
+
+type RequestPasswordResetInput struct {
+	Email         string
+	SourceAddress string
+}
+
+type ResetPasswordInput struct {
+	Token       string
+	NewPassword string
+}
+
+// RequestPasswordReset issues a reset token for the subject whose verified,
+// enabled 邮箱 identifier matches input.Email and hands it to notifier. It
+// returns nil whether or not such a subject exists, so the response cannot
+// be used to enumerate addresses: the token is committed first and
+// delivered in the background, so neither the delivery time nor a delivery
+// failure reaches the caller. A failed delivery is recorded as a failed
+// 凭据变更 audit event instead. Every request counts against the login
+// throttle under a separate key, which bounds both enumeration and the mail
+// sent to one address; ErrPasswordResetThrottled is the only other outcome a
+// caller should surface.
+func RequestPasswordReset(ctx context.Context, database *sql.DB, notifier PasswordResetNotifier, throttleSettings LoginThrottleSettings, input RequestPasswordResetInput) error {
+	if err := throttleSettings.validate(); err != nil {
+		return fmt.Errorf("invalid login throttle settings: %w", err)
+	}
+
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin password reset request transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	now := time.Now().UTC()
+	throttleKey := newPasswordResetThrottleKey(throttleSettings, input.Email, input.SourceAddress)
+	locked, err := countLoginThrottleAttempt(ctx, transactionQueries, throttleSettings, throttleKey, now)
+	if err != nil {
+		return err
+	}
+	if locked {
+		return ErrPasswordResetThrottled
+	}
+	commit := func() error {
+		if err := transaction.Commit(); err != nil {
+			return fmt.Errorf("commit password reset request transaction: %w", err)
+		}
+		return nil
+	}
+
+	email, err := normalizeIdentifier("邮箱", input.Email)
+	if err != nil {
+		return commit()
+	}
+	target, err := transactionQueries.GetPasswordResetTarget(ctx, sqlc.GetPasswordResetTargetParams{
+		IdentifierType:   "邮箱",
+		NormalizedValue:  email,
+		Status:           "启用",
+		Status_2:         "启用",
+		CredentialStatus: "已作废",
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return commit()
+	}
+	if err != nil {
+		return fmt.Errorf("find password reset subject: %w", err)
+	}
+
+	token, tokenHash, err := newSecret()
+	if err != nil {
+		return err
+	}
+	tokenID, err := NewULID(now)
+	if err != nil {
+		return err
+	}
+	expiresAt := now.Add(passwordResetTokenTTL)
+	// Only the newest token of a subject is valid.
+	if err := transactionQueries.DeletePendingPasswordResetTokensBySubjectID(ctx, target.SubjectID); err != nil {
+		return fmt.Errorf("discard earlier password reset tokens: %w", err)
+	}
+	if err := transactionQueries.CreatePasswordResetToken(ctx, sqlc.CreatePasswordResetTokenParams{
+		ID:               tokenID,
+		SubjectID:        target.SubjectID,
+		IdentifierID:     target.ID,
+		TokenHash:        tokenHash,
+		PasswordRevision: target.PasswordRevision,
+		ExpiresAt:        expiresAt,
+		ConsumedAt:       sql.NullTime{},
+		CreatedAt:        now,
+	}); err != nil {
+		return fmt.Errorf("create password reset token: %w", err)
+	}
+	auditEventID, err := NewULID(now)
+	if err != nil {
+		return err
+	}
+	if err := transactionQueries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
+		ID:              auditEventID,
+		EventAction:     "凭据变更",
+		Outcome:         "成功",
+		ActorSubjectID:  sql.NullString{},
+		TargetSubjectID: sql.NullString{String: target.SubjectID, Valid: true},
+		RequestID:       sql.NullString{},
+		SourceHash:      throttleKey.sourceHash,
+		Metadata:        `{"change":"重置申请"}`,
+		CreatedAt:       now,
+	}); err != nil {
+		return fmt.Errorf("write password reset request audit event: %w", err)
+	}
+	if err := commit(); err != nil {
+		return err
+	}
+	go deliverPasswordReset(context.WithoutCancel(ctx), database, notifier, target.SubjectID, throttleKey.sourceHash, PasswordResetNotice{
+		Address:   target.NormalizedValue,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	})
+	return nil
+}
+
+// deliverPasswordReset hands a committed token to notifier and records a
+// failed delivery as an audit event, since nobody is left to return it to.
+// The token stays valid, so a retry by the subject supersedes it.
+func deliverPasswordReset(ctx context.Context, database *sql.DB, notifier PasswordResetNotifier, subjectID string, sourceHash []byte, notice PasswordResetNotice) {
+	deliveryContext, cancel := context.WithTimeout(ctx, passwordResetDeliveryTimeout)
+	defer cancel()
+	if notifier.SendPasswordReset(deliveryContext, notice) == nil {
+		return
+	}
+	now := time.Now().UTC()
+	auditEventID, err := NewULID(now)
+	if err != nil {
+		return
+	}
+	_ = sqlc.New(database).InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
+		ID:              auditEventID,
+		EventAction:     "凭据变更",
+		Outcome:         "失败",
+		ActorSubjectID:  sql.NullString{},
+		TargetSubjectID: sql.NullString{String: subjectID, Valid: true},
+		RequestID:       sql.NullString{},
+		SourceHash:      sourceHash,
+		Metadata:        `{"change":"重置投递"}`,
+		CreatedAt:       now,
+	})
+}
+
+// ResetPassword consumes a reset token and replaces the password. The token
+// is bound to the password revision it was issued for, so it stops working
+// as soon as the password changes by any other path. Like a password change,
+// the reset increments the security version and revokes every session. The
+// new password must satisfy policy, including its reuse history; a rejected
+// password leaves the token usable.
+func ResetPassword(ctx context.Context, database *sql.DB, input ResetPasswordInput, policy PasswordPolicy) error {
+	newPasswordHash, err := policy.hash(input.NewPassword)
+	if err != nil {
+		return err
+	}
+	tokenHash, err := hashSecret(input.Token)
+	if err != nil {
+		return ErrInvalidPasswordResetToken
+	}
+
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin password reset transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	token, err := transactionQueries.GetPasswordResetTokenByHash(ctx, tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidPasswordResetToken
+	}
+	if err != nil {
+		return fmt.Errorf("load password reset token: %w", err)
+	}
+	now := time.Now().UTC()
+	consumed, err := transactionQueries.ConsumePasswordResetToken(ctx, sqlc.ConsumePasswordResetTokenParams{
+		ConsumedAt: sql.NullTime{Time: now, Valid: true},
+		ID:         token.ID,
+		ExpiresAt:  now,
+	})
+	if err != nil {
+		return fmt.Errorf("consume password reset token: %w", err)
+	}
+	if consumed != 1 {
+		return ErrInvalidPasswordResetToken
+	}
+	credential, err := transactionQueries.GetPasswordCredentialBySubjectID(ctx, token.SubjectID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidPasswordResetToken
+	}
+	if err != nil {
+		return fmt.Errorf("load password credential: %w", err)
+	}
+	if err := checkPasswordReuse(ctx, transactionQueries, policy, token.SubjectID, credential.PasswordHash, input.NewPassword); err != nil {
+		return err
+	}
+	err = replacePassword(ctx, transactionQueries, token.SubjectID, token.PasswordRevision, newPasswordHash, "有效", token.SubjectID)
+	if errors.Is(err, ErrPasswordUpdateConflict) || errors.Is(err, ErrSubjectNotFound) || errors.Is(err, ErrPasswordCredentialNotFound) {
+		return ErrInvalidPasswordResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if err := transaction.Commit(); err != nil {
+		return fmt.Errorf("commit password reset transaction: %w", err)
+	}
+	return nil
+}
+
+// newPasswordResetThrottleKey shares the login throttle table under its own
+// identifier hash, so reset requests never lock out sign-in.
+func newPasswordResetThrottleKey(settings LoginThrottleSettings, email string, sourceAddress string) loginThrottleKey {
+	return loginThrottleKey{
+		identifierHash: hmacSHA256(settings.Secret, "password-reset\x00"+strings.ToLower(strings.TrimSpace(email))),
+		sourceHash:     hmacSHA256(settings.Secret, normalizedSourceAddress(sourceAddress)),
+	}
+}
@@ -0,0 +1,253 @@
+package identity_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+// capturingNotifier passes each delivered notice to the test, which waits
+// for it with next since delivery runs in the background. A non-nil err
+// fails every delivery.
+type capturingNotifier struct {
+	delivered chan identity.PasswordResetNotice
+	err       error
+}
+
+func newCapturingNotifier() *capturingNotifier {
+	return &capturingNotifier{delivered: make(chan identity.PasswordResetNotice, 16)}
+}
+
+func (notifier *capturingNotifier) SendPasswordReset(_ context.Context, notice identity.PasswordResetNotice) error {
+	notifier.delivered <- notice
+	return notifier.err
+}
+
+func (notifier *capturingNotifier) next(t *testing.T) identity.PasswordResetNotice {
+	t.Helper()
+	select {
+	case notice := <-notifier.delivered:
+		return notice
+	case <-time.After(5 * time.Second):
+		t.Fatal("password reset was not delivered")
+		return identity.PasswordResetNotice{}
+	}
+}
+
+func TestPasswordResetReplacesPasswordAndRevokesSessions(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	verifiedEmail(t, databaseConnection, administrator.ID, "Admin@Example.test")
+	active, err := loginWithSource(ctx, databaseConnection, "admin", "correct horse battery staple", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	notifier := newCapturingNotifier()
+	// 未知地址同样返回成功，但不发送任何内容。
+	if err := identity.RequestPasswordReset(ctx, databaseConnection, notifier, testLoginThrottleSettings, identity.RequestPasswordResetInput{
+		Email:         "nobody@example.test",
+		SourceAddress: "192.0.2.1",
+	}); err != nil {
+		t.Fatalf("request reset for unknown address: %v", err)
+	}
+	if err := identity.RequestPasswordReset(ctx, databaseConnection, notifier, testLoginThrottleSettings, identity.RequestPasswordResetInput{
+		Email:         " ADMIN@example.test ",
+		SourceAddress: "192.0.2.1",
+	}); err != nil {
+		t.Fatalf("request reset: %v", err)
+	}
+	notice := notifier.next(t)
+	if notice.Address != "admin@example.test" || notice.Token == "" || len(notifier.delivered) != 0 {
+		t.Fatalf("notice = %#v, %d more pending", notice, len(notifier.delivered))
+	}
+	token := notice.Token
+
+	if err := identity.ResetPassword(ctx, databaseConnection, identity.ResetPasswordInput{
+		Token:       token,
+		NewPassword: "short",
+	}, testPasswordPolicy); !errors.Is(err, identity.ErrInvalidPasswordInput) {
+		t.Fatalf("short password error = %v", err)
+	}
+	if err := identity.ResetPassword(ctx, databaseConnection, identity.ResetPasswordInput{
+		Token:       token,
+		NewPassword: "a brand new long password",
+	}, testPasswordPolicy); err != nil {
+		t.Fatalf("reset password: %v", err)
+	}
+	if _, err := identity.CurrentSession(ctx, databaseConnection, active.SessionToken, testSessionSettings); err == nil {
+		t.Fatal("session survived password reset")
+	}
+	if _, err := loginWithSource(ctx, databaseConnection, "admin", "a brand new long password", "192.0.2.1"); err != nil {
+		t.Fatalf("login with new password: %v", err)
+	}
+	// 令牌只能使用一次。
+	if err := identity.ResetPassword(ctx, databaseConnection, identity.ResetPasswordInput{
+		Token:       token,
+		NewPassword: "yet another long password",
+	}, testPasswordPolicy); !errors.Is(err, identity.ErrInvalidPasswordResetToken) {
+		t.Fatalf("reused token error = %v", err)
+	}
+
+	var resetAudits int
+	if err := databaseConnection.QueryRow(`
+		SELECT COUNT(*)
+		FROM identity_audit_events
+		WHERE event_action = '凭据变更' AND target_subject_id = ? AND json_extract(metadata, '$.change') = '重置申请'
+	`, administrator.ID).Scan(&resetAudits); err != nil {
+		t.Fatalf("count reset audits: %v", err)
+	}
+	if resetAudits != 1 {
+		t.Fatalf("reset request audit count = %d", resetAudits)
+	}
+}
+
+// 投递失败不影响申请结果：令牌已提交，失败记为审计事件。
+func TestPasswordResetDeliveryFailureIsAuditedNotReturned(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	verifiedEmail(t, databaseConnection, administrator.ID, "admin@example.test")
+
+	notifier := newCapturingNotifier()
+	notifier.err = errors.New("mail relay unavailable")
+	if err := identity.RequestPasswordReset(ctx, databaseConnection, notifier, testLoginThrottleSettings, identity.RequestPasswordResetInput{
+		Email:         "admin@example.test",
+		SourceAddress: "192.0.2.1",
+	}); err != nil {
+		t.Fatalf("request reset with failing delivery: %v", err)
+	}
+	notice := notifier.next(t)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var failures int
+		if err := databaseConnection.QueryRow(`
+			SELECT COUNT(*)
+			FROM identity_audit_events
+			WHERE event_action = '凭据变更' AND outcome = '失败' AND target_subject_id = ? AND json_extract(metadata, '$.change') = '重置投递'
+		`, administrator.ID).Scan(&failures); err != nil {
+			t.Fatalf("count delivery failures: %v", err)
+		}
+		if failures == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery failure audit count = %d", failures)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// 令牌在投递前已提交，仍然可用。
+	if err := identity.ResetPassword(ctx, databaseConnection, identity.ResetPasswordInput{
+		Token:       notice.Token,
+		NewPassword: "a brand new long password",
+	}, testPasswordPolicy); err != nil {
+		t.Fatalf("reset with the undelivered token: %v", err)
+	}
+}
+
+func TestPasswordResetTokenIsBoundToPasswordRevision(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	verifiedEmail(t, databaseConnection, administrator.ID, "admin@example.test")
+
+	notifier := newCapturingNotifier()
+	var notices []identity.PasswordResetNotice
+	for range 2 {
+		if err := identity.RequestPasswordReset(ctx, databaseConnection, notifier, testLoginThrottleSettings, identity.RequestPasswordResetInput{
+			Email:         "admin@example.test",
+			SourceAddress: "192.0.2.1",
+		}); err != nil {
+			t.Fatalf("request reset: %v", err)
+		}
+		notices = append(notices, notifier.next(t))
+	}
+	// 新令牌使旧令牌失效。
+	if err := identity.ResetPassword(ctx, databaseConnection, identity.ResetPasswordInput{
+		Token:       notices[0].Token,
+		NewPassword: "a brand new long password",
+	}, testPasswordPolicy); !errors.Is(err, identity.ErrInvalidPasswordResetToken) {
+		t.Fatalf("superseded token error = %v", err)
+	}
+	// 签发后密码经其他途径变更，令牌随之失效。
+	if err := identity.ChangePassword(ctx, databaseConnection, administrator.ID, identity.ChangePasswordInput{
+		CurrentPassword: "correct horse battery staple",
+		NewPassword:     "changed through the console",
+	}, testPasswordPolicy); err != nil {
+		t.Fatalf("change password: %v", err)
+	}
+	if err := identity.ResetPassword(ctx, databaseConnection, identity.ResetPasswordInput{
+		Token:       notices[1].Token,
+		NewPassword: "a brand new long password",
+	}, testPasswordPolicy); !errors.Is(err, identity.ErrInvalidPasswordResetToken) {
+		t.Fatalf("stale revision error = %v", err)
+	}
+	if _, err := loginWithSource(ctx, databaseConnection, "admin", "changed through the console", "192.0.2.1"); err != nil {
+		t.Fatalf("login after rejected reset: %v", err)
+	}
+}
+
+func TestPasswordResetRequestsAreThrottledAndRequireVerifiedEmail(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	if _, err := identity.CreateSubjectIdentifier(ctx, databaseConnection, administrator.ID, administrator.ID, identity.CreateIdentifierInput{
+		Type:  "邮箱",
+		Value: "admin@example.test",
+		Usage: "联系",
+	}); err != nil {
+		t.Fatalf("create email: %v", err)
+	}
+
+	notifier := newCapturingNotifier()
+	request := func() error {
+		return identity.RequestPasswordReset(ctx, databaseConnection, notifier, testLoginThrottleSettings, identity.RequestPasswordResetInput{
+			Email:         "admin@example.test",
+			SourceAddress: "192.0.2.1",
+		})
+	}
+	for attempt := 1; attempt <= testLoginThrottleSettings.FailureLimit; attempt++ {
+		if err := request(); err != nil {
+			t.Fatalf("request %d: %v", attempt, err)
+		}
+	}
+	// 未验证的邮箱不会收到重置令牌。
+	if len(notifier.delivered) != 0 {
+		t.Fatalf("%d notices for unverified email", len(notifier.delivered))
+	}
+	if err := request(); !errors.Is(err, identity.ErrPasswordResetThrottled) {
+		t.Fatalf("throttled request error = %v", err)
+	}
+	// 重置申请的节流不影响登录。
+	if _, err := loginWithSource(ctx, databaseConnection, "admin", "correct horse battery staple", "192.0.2.1"); err != nil {
+		t.Fatalf("login while reset is throttled: %v", err)
+	}
+}
+
+func verifiedEmail(t *testing.T, databaseConnection *sql.DB, subjectID string, address string) identity.Identifier {
+	t.Helper()
+	ctx := context.Background()
+	email, err := identity.CreateSubjectIdentifier(ctx, databaseConnection, subjectID, subjectID, identity.CreateIdentifierInput{
+		Type:  "邮箱",
+		Value: address,
+		Usage: "联系",
+	})
+	if err != nil {
+		t.Fatalf("create email: %v", err)
+	}
+	sender := &capturingSender{}
+	if _, err := identity.StartIdentifierVerification(ctx, databaseConnection, sender, subjectID, email.ID); err != nil {
+		t.Fatalf("start verification: %v", err)
+	}
+	verified, err := identity.ConfirmIdentifierVerification(ctx, databaseConnection, subjectID, subjectID, email.ID, sender.codes[0].Code)
+	if err != nil {
+		t.Fatalf("confirm verification: %v", err)
+	}
+	return verified
+}
@@ -0,0 +1,719 @@
+package identity
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database/sqlc"
+)
+
+var ErrVersionMismatch = errors.New("resource version does not match")
+var ErrReservedRole = errors.New("roles in the identity namespace cannot be provisioned")
+
+// MaximumProvisioningPageSize bounds one page of a provisioning list.
+const MaximumProvisioningPageSize int64 = 200
+
+// Attributes a provisioning list can be filtered on. Each filter is an exact
+// match; identifier values are compared after normalization.
+const (
+	ProvisioningFilterID             = "id"
+	ProvisioningFilterAccount        = "account"
+	ProvisioningFilterDisplayName    = "display_name"
+	ProvisioningFilterEmployeeNumber = "employee_number"
+	ProvisioningFilterEmail          = "email"
+	ProvisioningFilterPhoneNumber    = "phone_number"
+)
+
+// ProvisionedSubject is a subject as an external provisioning system sees
+// it: the 主登录 账号, the profile, the 工号, 邮箱 and 手机号 identifiers and
+// the roles held. Version changes whenever any of them or the security
+// version changes.
+type ProvisionedSubject struct {
+	ID             string
+	Account        string
+	DisplayName    string
+	Active         bool
+	EmployeeNumber string
+	Emails         []string
+	PhoneNumbers   []string
+	Roles          []ProvisionedRoleReference
+	Version        string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type ProvisionedRoleReference struct {
+	ID       string
+	RoleCode string
+}
+
+// ProvisionSubjectInput is the complete desired state of a subject. A
+// replace removes 工号, 邮箱 and 手机号 identifiers that are not listed and
+// adds the missing ones as unverified 联系 identifiers.
+type ProvisionSubjectInput struct {
+	// Account is the 主登录 账号. It is set on creation; a replace may repeat
+	// it but cannot change it.
+	Account        string
+	DisplayName    string
+	Active         bool
+	EmployeeNumber string
+	Emails         []string
+	PhoneNumbers   []string
+	// Password is only accepted on creation and, like an imported password,
+	// has to be changed at the first sign-in. Left empty, an unusable random
+	// password is stored and the subject signs in after a password reset.
+	Password string
+}
+
+type ProvisioningFilter struct {
+	Attribute string
+	Value     string
+}
+
+type ListProvisionedSubjectsInput struct {
+	Filter *ProvisioningFilter
+	Offset int64
+	Limit  int64
+}
+
+type ListProvisionedSubjectsResult struct {
+	Subjects []ProvisionedSubject
+	Total    int64
+}
+
+// ProvisionedRole is a role as a group of subjects. Roles have no security
+// version of their own, so Version digests the role and its members.
+type ProvisionedRole struct {
+	ID          string
+	RoleCode    string
+	DisplayName string
+	Members     []ProvisionedRoleMember
+	Version     string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type ProvisionedRoleMember struct {
+	SubjectID   string
+	DisplayName string
+}
+
+type ListProvisionedRolesInput struct {
+	Filter *ProvisioningFilter
+	Offset int64
+	Limit  int64
+}
+
+type ListProvisionedRolesResult struct {
+	Roles []ProvisionedRole
+	Total int64
+}
+
+// provisionedIdentifierTypes are the identifier types a provisioning client
+// owns. The 主登录 账号 is only written on creation.
+var provisionedIdentifierTypes = []string{"工号", "邮箱", "手机号"}
+
+func ListProvisionedSubjects(ctx context.Context, database *sql.DB, input ListProvisionedSubjectsInput) (ListProvisionedSubjectsResult, error) {
+	if input.Limit < 0 || input.Limit > MaximumProvisioningPageSize || input.Offset < 0 {
+		return ListProvisionedSubjectsResult{}, fmt.Errorf("invalid provisioning pagination")
+	}
+	queries := sqlc.New(database)
+	var subjectIDs []string
+	var total int64
+	if input.Filter == nil {
+		count, err := queries.CountSubjectsForManagement(ctx)
+		if err != nil {
+			return ListProvisionedSubjectsResult{}, fmt.Errorf("count provisioned subjects: %w", err)
+		}
+		total = count
+		if input.Limit > 0 {
+			rows, err := queries.ListSubjectsForManagement(ctx, sqlc.ListSubjectsForManagementParams{
+				IdentifierUsage: "主登录",
+				Limit:           input.Limit,
+				Offset:          input.Offset,
+			})
+			if err != nil {
+				return ListProvisionedSubjectsResult{}, fmt.Errorf("list provisioned subjects: %w", err)
+			}
+			for _, row := range rows {
+				subjectIDs = append(subjectIDs, row.ID)
+			}
+		}
+	} else {
+		matches, err := findProvisionedSubjectIDs(ctx, queries, *input.Filter)
+		if err != nil {
+			return ListProvisionedSubjectsResult{}, err
+		}
+		total = int64(len(matches))
+		subjectIDs = pageOf(matches, input.Offset, input.Limit)
+	}
+
+	subjects := make([]ProvisionedSubject, 0, len(subjectIDs))
+	for _, subjectID := range subjectIDs {
+		subject, err := getProvisionedSubject(ctx, queries, subjectID)
+		if err != nil {
+			return ListProvisionedSubjectsResult{}, err
+		}
+		subjects = append(subjects, subject)
+	}
+	return ListProvisionedSubjectsResult{Subjects: subjects, Total: total}, nil
+}
+
+func GetProvisionedSubject(ctx context.Context, database *sql.DB, subjectID string) (ProvisionedSubject, error) {
+	return getProvisionedSubject(ctx, sqlc.New(database), subjectID)
+}
+
+// CreateProvisionedSubject creates a subject with the same audit events as
+// an import, each marked with the provisioning client.
+func CreateProvisionedSubject(ctx context.Context, database *sql.DB, clientID string, input ProvisionSubjectInput, policy PasswordPolicy) (ProvisionedSubject, error) {
+	account, err := normalizeAccountIdentifier(input.Account)
+	if err != nil {
+		return ProvisionedSubject{}, fmt.Errorf("%w: validate account identifier: %v", ErrInvalidSubjectInput, err)
+	}
+	planned, err := planProvisionedSubject(input)
+	if err != nil {
+		return ProvisionedSubject{}, err
+	}
+	planned.identifiers = append([]plannedIdentifier{{
+		identifierType:  "账号",
+		value:           account,
+		normalizedValue: account,
+		usage:           "主登录",
+	}}, planned.identifiers...)
+	temporaryPassword := input.Password
+	if temporaryPassword == "" {
+		if temporaryPassword, err = generateTemporaryPassword(policy); err != nil {
+			return ProvisionedSubject{}, err
+		}
+	}
+	planned.passwordHash, err = policy.hash(temporaryPassword)
+	if errors.Is(err, ErrInvalidPasswordInput) {
+		return ProvisionedSubject{}, fmt.Errorf("%w: %v", ErrInvalidSubjectInput, err)
+	}
+	if err != nil {
+		return ProvisionedSubject{}, err
+	}
+
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return ProvisionedSubject{}, fmt.Errorf("begin provision subject transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	conflicts, err := checkSubjectImportConflicts(ctx, transactionQueries, planned, map[string]string{})
+	if err != nil {
+		return ProvisionedSubject{}, err
+	}
+	if len(conflicts) > 0 {
+		return ProvisionedSubject{}, fmt.Errorf("%w: %s", ErrIdentifierAlreadyExists, strings.Join(conflicts, "; "))
+	}
+	now := time.Now().UTC()
+	actor := provisioningActor(clientID)
+	subjectID, err := createImportedSubject(ctx, transactionQueries, actor, map[string]string{}, planned, nil, now)
+	if err != nil {
+		return ProvisionedSubject{}, err
+	}
+	if !input.Active {
+		subject, err := getSubject(ctx, transactionQueries, subjectID)
+		if err != nil {
+			return ProvisionedSubject{}, err
+		}
+		if _, err := changeSubjectStatus(ctx, transactionQueries, actor, subject, "禁用", now); err != nil {
+			return ProvisionedSubject{}, err
+		}
+	}
+	provisioned, err := getProvisionedSubject(ctx, transactionQueries, subjectID)
+	if err != nil {
+		return ProvisionedSubject{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return ProvisionedSubject{}, fmt.Errorf("commit provision subject transaction: %w", err)
+	}
+	return provisioned, nil
+}
+
+// ReplaceProvisionedSubject brings a subject to the state in input. When
+// expectedVersion is set and the subject has changed since the client read
+// it, nothing is written and ErrVersionMismatch is returned. Each change
+// writes the audit event of the matching administrator operation.
+func ReplaceProvisionedSubject(ctx context.Context, database *sql.DB, clientID string, subjectID string, expectedVersion string, input ProvisionSubjectInput) (ProvisionedSubject, error) {
+	if input.Password != "" {
+		return ProvisionedSubject{}, fmt.Errorf("%w: passwords can only be set when a subject is created", ErrInvalidSubjectInput)
+	}
+	planned, err := planProvisionedSubject(input)
+	if err != nil {
+		return ProvisionedSubject{}, err
+	}
+
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return ProvisionedSubject{}, fmt.Errorf("begin replace provisioned subject transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	if subject, err := getSubject(ctx, transactionQueries, subjectID); err != nil {
+		return ProvisionedSubject{}, err
+	} else if subject.Status == "已删除" {
+		return ProvisionedSubject{}, ErrSubjectErased
+	}
+	current, err := getProvisionedSubject(ctx, transactionQueries, subjectID)
+	if err != nil {
+		return ProvisionedSubject{}, err
+	}
+	if expectedVersion != "" && expectedVersion != current.Version {
+		return ProvisionedSubject{}, ErrVersionMismatch
+	}
+	if input.Account != "" {
+		account, err := normalizeAccountIdentifier(input.Account)
+		if err != nil || account != current.Account {
+			return ProvisionedSubject{}, fmt.Errorf("%w: the account identifier cannot be changed", ErrInvalidSubjectInput)
+		}
+	}
+
+	now := time.Now().UTC()
+	actor := provisioningActor(clientID)
+	if planned.displayName != current.DisplayName {
+		if err := updateSubjectDisplayName(ctx, transactionQueries, actor, subjectID, planned.displayName, now); err != nil {
+			return ProvisionedSubject{}, err
+		}
+	}
+	if err := replaceProvisionedIdentifiers(ctx, transactionQueries, actor, subjectID, planned.identifiers, now); err != nil {
+		return ProvisionedSubject{}, err
+	}
+	if current.Active != input.Active {
+		status := "禁用"
+		if input.Active {
+			status = "启用"
+		}
+		subject, err := getSubject(ctx, transactionQueries, subjectID)
+		if err != nil {
+			return ProvisionedSubject{}, err
+		}
+		if _, err := changeSubjectStatus(ctx, transactionQueries, actor, subject, status, now); err != nil {
+			return ProvisionedSubject{}, err
+		}
+	}
+	replaced, err := getProvisionedSubject(ctx, transactionQueries, subjectID)
+	if err != nil {
+		return ProvisionedSubject{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return ProvisionedSubject{}, fmt.Errorf("commit replace provisioned subject transaction: %w", err)
+	}
+	return replaced, nil
+}
+
+func ListProvisionedRoles(ctx context.Context, database *sql.DB, input ListProvisionedRolesInput) (ListProvisionedRolesResult, error) {
+	if input.Limit < 0 || input.Limit > MaximumProvisioningPageSize || input.Offset < 0 {
+		return ListProvisionedRolesResult{}, fmt.Errorf("invalid provisioning pagination")
+	}
+	queries := sqlc.New(database)
+	records, err := queries.ListRoles(ctx)
+	if err != nil {
+		return ListProvisionedRolesResult{}, fmt.Errorf("list provisioned roles: %w", err)
+	}
+	if input.Filter != nil {
+		records = slices.DeleteFunc(records, func(record sqlc.IdentityRole) bool {
+			switch input.Filter.Attribute {
+			case ProvisioningFilterID:
+				return record.ID != input.Filter.Value
+			case ProvisioningFilterDisplayName:
+				return record.RoleCode != input.Filter.Value
+			default:
+				return true
+			}
+		})
+	}
+
+	page := pageOf(records, input.Offset, input.Limit)
+	roles := make([]ProvisionedRole, 0, len(page))
+	for _, record := range page {
+		role, err := provisionedRoleFromRecord(ctx, queries, record)
+		if err != nil {
+			return ListProvisionedRolesResult{}, err
+		}
+		roles = append(roles, role)
+	}
+	return ListProvisionedRolesResult{Roles: roles, Total: int64(len(records))}, nil
+}
+
+func GetProvisionedRole(ctx context.Context, database *sql.DB, roleID string) (ProvisionedRole, error) {
+	return getProvisionedRole(ctx, sqlc.New(database), roleID)
+}
+
+// CreateProvisionedRole creates an application role named roleCode and
+// grants it to memberIDs. The role_code doubles as the display name.
+func CreateProvisionedRole(ctx context.Context, database *sql.DB, clientID string, roleCode string, memberIDs []string) (ProvisionedRole, error) {
+	if strings.HasPrefix(roleCode, reservedRoleNamespace) {
+		return ProvisionedRole{}, ErrReservedRole
+	}
+	roleCode, err := validateRoleCode(roleCode)
+	if err != nil {
+		return ProvisionedRole{}, fmt.Errorf("%w: %v", ErrInvalidRoleInput, err)
+	}
+
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return ProvisionedRole{}, fmt.Errorf("begin provision role transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	now := time.Now().UTC()
+	actor := provisioningActor(clientID)
+	role, err := createRole(ctx, transactionQueries, actor, roleCode, roleCode, "", now)
+	if err != nil {
+		return ProvisionedRole{}, err
+	}
+	record, err := transactionQueries.GetRoleByID(ctx, role.ID)
+	if err != nil {
+		return ProvisionedRole{}, fmt.Errorf("get provisioned role: %w", err)
+	}
+	if err := replaceRoleMembers(ctx, transactionQueries, actor, record, memberIDs, now); err != nil {
+		return ProvisionedRole{}, err
+	}
+	provisioned, err := provisionedRoleFromRecord(ctx, transactionQueries, record)
+	if err != nil {
+		return ProvisionedRole{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return ProvisionedRole{}, fmt.Errorf("commit provision role transaction: %w", err)
+	}
+	return provisioned, nil
+}
+
+// ReplaceProvisionedRoleMembers makes memberIDs the exact holders of a role.
+// Every grant and revocation is a regular role change: the subject's
+// sessions are revoked and the final enabled administrator is kept.
+// Memberships of the reserved identity roles stay with administrators.
+func ReplaceProvisionedRoleMembers(ctx context.Context, database *sql.DB, clientID string, roleID string, expectedVersion string, memberIDs []string) (ProvisionedRole, error) {
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return ProvisionedRole{}, fmt.Errorf("begin replace role members transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	current, err := getProvisionedRole(ctx, transactionQueries, roleID)
+	if err != nil {
+		return ProvisionedRole{}, err
+	}
+	if strings.HasPrefix(current.RoleCode, reservedRoleNamespace) {
+		return ProvisionedRole{}, ErrReservedRole
+	}
+	if expectedVersion != "" && expectedVersion != current.Version {
+		return ProvisionedRole{}, ErrVersionMismatch
+	}
+	record, err := transactionQueries.GetRoleByID(ctx, roleID)
+	if err != nil {
+		return ProvisionedRole{}, fmt.Errorf("get provisioned role: %w", err)
+	}
+	if err := replaceRoleMembers(ctx, transactionQueries, provisioningActor(clientID), record, memberIDs, time.Now().UTC()); err != nil {
+		return ProvisionedRole{}, err
+	}
+	replaced, err := provisionedRoleFromRecord(ctx, transactionQueries, record)
+	if err != nil {
+		return ProvisionedRole{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return ProvisionedRole{}, fmt.Errorf("commit replace role members transaction: %w", err)
+	}
+	return replaced, nil
+}
+
+// planProvisionedSubject validates the profile and the provisioned
+// identifiers, dropping repeated values.
+func planProvisionedSubject(input ProvisionSubjectInput) (plannedSubject, error) {
+	displayName, err := validateDisplayName(input.DisplayName)
+	if err != nil {
+		return plannedSubject{}, fmt.Errorf("%w: %v", ErrInvalidSubjectInput, err)
+	}
+	planned := plannedSubject{displayName: displayName}
+	values := map[string][]string{"邮箱": input.Emails, "手机号": input.PhoneNumbers}
+	if strings.TrimSpace(input.EmployeeNumber) != "" {
+		values["工号"] = []string{input.EmployeeNumber}
+	}
+	for _, identifierType := range provisionedIdentifierTypes {
+		for _, value := range values[identifierType] {
+			normalizedValue, err := normalizeIdentifier(identifierType, value)
+			if err != nil {
+				return plannedSubject{}, fmt.Errorf("%w: %v", ErrInvalidSubjectInput, err)
+			}
+			if slices.ContainsFunc(planned.identifiers, func(identifier plannedIdentifier) bool {
+				return identifier.identifierType == identifierType && identifier.normalizedValue == normalizedValue
+			}) {
+				continue
+			}
+			planned.identifiers = append(planned.identifiers, plannedIdentifier{
+				identifierType:  identifierType,
+				value:           strings.TrimSpace(value),
+				normalizedValue: normalizedValue,
+				usage:           "联系",
+			})
+		}
+	}
+	return planned, nil
+}
+
+// replaceProvisionedIdentifiers deletes the provisioned identifiers missing
+// from desired and creates the new ones. Identifiers that stay keep their
+// usage and verification.
+func replaceProvisionedIdentifiers(ctx context.Context, queries sqlc.Querier, actor auditActor, subjectID string, desired []plannedIdentifier, now time.Time) error {
+	records, err := queries.ListSubjectIdentifiers(ctx, subjectID)
+	if err != nil {
+		return fmt.Errorf("list subject identifiers: %w", err)
+	}
+	for _, record := range records {
+		if !slices.Contains(provisionedIdentifierTypes, record.IdentifierType) || record.IdentifierUsage == "主登录" {
+			continue
+		}
+		if slices.ContainsFunc(desired, func(identifier plannedIdentifier) bool {
+			return identifier.identifierType == record.IdentifierType && identifier.normalizedValue == record.NormalizedValue
+		}) {
+			continue
+		}
+		deleted, err := queries.DeleteSubjectIdentifier(ctx, sqlc.DeleteSubjectIdentifierParams{
+			ID:              record.ID,
+			SubjectID:       subjectID,
+			IdentifierUsage: "主登录",
+		})
+		if err != nil {
+			return fmt.Errorf("delete provisioned identifier: %w", err)
+		}
+		if deleted != 1 {
+			return ErrIdentifierNotFound
+		}
+		if err := insertIdentifierAuditEvent(ctx, queries, actor, record, "删除", now); err != nil {
+			return err
+		}
+	}
+
+	for _, identifier := range desired {
+		if slices.ContainsFunc(records, func(record sqlc.IdentityIdentifier) bool {
+			return identifier.identifierType == record.IdentifierType && identifier.normalizedValue == record.NormalizedValue
+		}) {
+			continue
+		}
+		_, err := queries.GetIdentifierSubjectID(ctx, sqlc.GetIdentifierSubjectIDParams{
+			IdentifierType:  identifier.identifierType,
+			NormalizedValue: identifier.normalizedValue,
+		})
+		if err == nil {
+			return fmt.Errorf("%w: %s identifier %s", ErrIdentifierAlreadyExists, identifier.identifierType, identifier.normalizedValue)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("check provisioned identifier: %w", err)
+		}
+		identifierID, err := NewULID(now)
+		if err != nil {
+			return err
+		}
+		record := sqlc.IdentityIdentifier{
+			ID:              identifierID,
+			SubjectID:       subjectID,
+			IdentifierType:  identifier.identifierType,
+			IdentifierValue: identifier.value,
+			NormalizedValue: identifier.normalizedValue,
+			IdentifierUsage: identifier.usage,
+			Status:          "启用",
+			VerifiedAt:      sql.NullTime{},
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		}
+		if err := queries.CreateIdentifier(ctx, sqlc.CreateIdentifierParams{
+			ID:              record.ID,
+			SubjectID:       record.SubjectID,
+			IdentifierType:  record.IdentifierType,
+			IdentifierValue: record.IdentifierValue,
+			NormalizedValue: record.NormalizedValue,
+			IdentifierUsage: record.IdentifierUsage,
+			Status:          record.Status,
+			VerifiedAt:      record.VerifiedAt,
+			CreatedAt:       record.CreatedAt,
+			UpdatedAt:       record.UpdatedAt,
+		}); err != nil {
+			return fmt.Errorf("create provisioned identifier: %w", err)
+		}
+		if err := insertIdentifierAuditEvent(ctx, queries, actor, record, "创建", now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceRoleMembers grants role to the listed subjects and revokes it from
+// everyone else.
+func replaceRoleMembers(ctx context.Context, queries sqlc.Querier, actor auditActor, role sqlc.IdentityRole, memberIDs []string, now time.Time) error {
+	members, err := queries.ListRoleMembers(ctx, role.ID)
+	if err != nil {
+		return fmt.Errorf("list role members: %w", err)
+	}
+	for _, member := range members {
+		if slices.Contains(memberIDs, member.SubjectID) {
+			continue
+		}
+		subject, err := getSubject(ctx, queries, member.SubjectID)
+		if err != nil {
+			return err
+		}
+		if _, err := applySubjectRoleChange(ctx, queries, actor, subject, role, false, now); err != nil {
+			return err
+		}
+	}
+	for _, subjectID := range memberIDs {
+		subject, err := getSubject(ctx, queries, subjectID)
+		if err != nil {
+			return err
+		}
+		if _, err := applySubjectRoleChange(ctx, queries, actor, subject, role, true, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findProvisionedSubjectIDs resolves an exact-match filter. A value that
+// cannot be normalized matches no subject rather than failing the query.
+func findProvisionedSubjectIDs(ctx context.Context, queries sqlc.Querier, filter ProvisioningFilter) ([]string, error) {
+	identifierTypes := map[string]string{
+		ProvisioningFilterAccount:        "账号",
+		ProvisioningFilterEmployeeNumber: "工号",
+		ProvisioningFilterEmail:          "邮箱",
+		ProvisioningFilterPhoneNumber:    "手机号",
+	}
+	switch filter.Attribute {
+	case ProvisioningFilterID:
+		if _, err := getSubject(ctx, queries, filter.Value); errors.Is(err, ErrSubjectNotFound) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		return []string{filter.Value}, nil
+	case ProvisioningFilterDisplayName:
+		subjectIDs, err := queries.ListSubjectIDsByDisplayName(ctx, filter.Value)
+		if err != nil {
+			return nil, fmt.Errorf("find subjects by display name: %w", err)
+		}
+		return subjectIDs, nil
+	}
+	identifierType, found := identifierTypes[filter.Attribute]
+	if !found {
+		return nil, fmt.Errorf("unsupported provisioning filter attribute %q", filter.Attribute)
+	}
+	normalizedValue, err := normalizeIdentifier(identifierType, filter.Value)
+	if err != nil {
+		return nil, nil
+	}
+	subjectID, err := queries.GetIdentifierSubjectID(ctx, sqlc.GetIdentifierSubjectIDParams{
+		IdentifierType:  identifierType,
+		NormalizedValue: normalizedValue,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find subject by identifier: %w", err)
+	}
+	return []string{subjectID}, nil
+}
+
+func getProvisionedSubject(ctx context.Context, queries sqlc.Querier, subjectID string) (ProvisionedSubject, error) {
+	subject, err := getSubject(ctx, queries, subjectID)
+	if err != nil {
+		return ProvisionedSubject{}, err
+	}
+	records, err := queries.ListSubjectIdentifiers(ctx, subjectID)
+	if err != nil {
+		return ProvisionedSubject{}, fmt.Errorf("list subject identifiers: %w", err)
+	}
+	roles, err := queries.ListSubjectRoles(ctx, subjectID)
+	if err != nil {
+		return ProvisionedSubject{}, fmt.Errorf("list subject roles: %w", err)
+	}
+	provisioned := ProvisionedSubject{
+		ID:           subject.ID,
+		Account:      subject.Identifier,
+		DisplayName:  subject.DisplayName,
+		Active:       subject.Status == "启用",
+		Emails:       []string{},
+		PhoneNumbers: []string{},
+		Roles:        make([]ProvisionedRoleReference, 0, len(roles)),
+		CreatedAt:    subject.CreatedAt,
+		UpdatedAt:    subject.UpdatedAt,
+	}
+	for _, record := range records {
+		switch record.IdentifierType {
+		case "工号":
+			provisioned.EmployeeNumber = record.IdentifierValue
+		case "邮箱":
+			provisioned.Emails = append(provisioned.Emails, record.IdentifierValue)
+		case "手机号":
+			provisioned.PhoneNumbers = append(provisioned.PhoneNumbers, record.IdentifierValue)
+		}
+	}
+	for _, role := range roles {
+		provisioned.Roles = append(provisioned.Roles, ProvisionedRoleReference{ID: role.ID, RoleCode: role.RoleCode})
+	}
+	provisioned.Version = fmt.Sprintf(`W/"%d-%s"`, subject.SecurityVersion, provisioningDigest(provisioned))
+	return provisioned, nil
+}
+
+func getProvisionedRole(ctx context.Context, queries sqlc.Querier, roleID string) (ProvisionedRole, error) {
+	record, err := queries.GetRoleByID(ctx, roleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ProvisionedRole{}, ErrRoleNotFound
+	}
+	if err != nil {
+		return ProvisionedRole{}, fmt.Errorf("get provisioned role: %w", err)
+	}
+	return provisionedRoleFromRecord(ctx, queries, record)
+}
+
+func provisionedRoleFromRecord(ctx context.Context, queries sqlc.Querier, record sqlc.IdentityRole) (ProvisionedRole, error) {
+	members, err := queries.ListRoleMembers(ctx, record.ID)
+	if err != nil {
+		return ProvisionedRole{}, fmt.Errorf("list role members: %w", err)
+	}
+	role := ProvisionedRole{
+		ID:          record.ID,
+		RoleCode:    record.RoleCode,
+		DisplayName: record.DisplayName,
+		Members:     make([]ProvisionedRoleMember, 0, len(members)),
+		CreatedAt:   record.CreatedAt,
+		UpdatedAt:   record.UpdatedAt,
+	}
+	for _, member := range members {
+		role.Members = append(role.Members, ProvisionedRoleMember{SubjectID: member.SubjectID, DisplayName: member.DisplayName})
+	}
+	role.Version = fmt.Sprintf(`W/"%s"`, provisioningDigest(role))
+	return role, nil
+}
+
+// provisioningDigest shortens a SHA-256 of the representation to twelve hex
+// digits; it only has to tell consecutive versions of one resource apart.
+func provisioningDigest(representation any) string {
+	encoded, _ := json.Marshal(representation)
+	digest := sha256.Sum256(encoded)
+	return hex.EncodeToString(digest[:6])
+}
+
+func pageOf[T any](items []T, offset int64, limit int64) []T {
+	if offset >= int64(len(items)) {
+		return nil
+	}
+	return items[offset:min(offset+limit, int64(len(items)))]
+}
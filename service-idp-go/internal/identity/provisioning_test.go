@@ -0,0 +1,139 @@
+package identity_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+func TestProvisionedSubjectReplaceKeepsVersionAndAudit(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	bootstrapAdministrator(t, databaseConnection)
+
+	created, err := identity.CreateProvisionedSubject(ctx, databaseConnection, "hr-sync", identity.ProvisionSubjectInput{
+		Account:        "ZhangSan",
+		DisplayName:    "张三",
+		Active:         true,
+		EmployeeNumber: "E-1001",
+		Emails:         []string{"zhangsan@example.test", "ZhangSan@Example.test"},
+		PhoneNumbers:   []string{"13800000001"},
+	}, testPasswordPolicy)
+	if err != nil {
+		t.Fatalf("create provisioned subject: %v", err)
+	}
+	if created.Account != "zhangsan" || !created.Active || len(created.Emails) != 1 || created.EmployeeNumber != "E-1001" || !strings.HasPrefix(created.Version, `W/"1-`) {
+		t.Fatalf("created = %#v", created)
+	}
+	if _, err := identity.CreateProvisionedSubject(ctx, databaseConnection, "hr-sync", identity.ProvisionSubjectInput{
+		Account:        "zhangsan2",
+		DisplayName:    "张三",
+		EmployeeNumber: "e-1001",
+	}, testPasswordPolicy); !errors.Is(err, identity.ErrIdentifierAlreadyExists) {
+		t.Fatalf("duplicate employee number error = %v", err)
+	}
+
+	// 按工号过滤时与大小写无关。
+	found, err := identity.ListProvisionedSubjects(ctx, databaseConnection, identity.ListProvisionedSubjectsInput{
+		Filter: &identity.ProvisioningFilter{Attribute: identity.ProvisioningFilterEmployeeNumber, Value: "e-1001"},
+		Limit:  10,
+	})
+	if err != nil || found.Total != 1 || found.Subjects[0].ID != created.ID {
+		t.Fatalf("filtered subjects = %#v, %v", found, err)
+	}
+
+	// 改名不提升安全版本，但版本号随内容变化。
+	renamed, err := identity.ReplaceProvisionedSubject(ctx, databaseConnection, "hr-sync", created.ID, created.Version, identity.ProvisionSubjectInput{
+		DisplayName:    "张三丰",
+		Active:         true,
+		EmployeeNumber: "E-1001",
+		Emails:         []string{"san.zhang@example.test"},
+	})
+	if err != nil {
+		t.Fatalf("replace provisioned subject: %v", err)
+	}
+	if renamed.DisplayName != "张三丰" || len(renamed.Emails) != 1 || renamed.Emails[0] != "san.zhang@example.test" || len(renamed.PhoneNumbers) != 0 || renamed.Version == created.Version || !strings.HasPrefix(renamed.Version, `W/"1-`) {
+		t.Fatalf("renamed = %#v", renamed)
+	}
+	if _, err := identity.ReplaceProvisionedSubject(ctx, databaseConnection, "hr-sync", created.ID, created.Version, identity.ProvisionSubjectInput{DisplayName: "张三"}); !errors.Is(err, identity.ErrVersionMismatch) {
+		t.Fatalf("stale replace error = %v", err)
+	}
+	for name, input := range map[string]identity.ProvisionSubjectInput{
+		"account":  {Account: "lisi", DisplayName: "张三丰", Active: true},
+		"password": {DisplayName: "张三丰", Active: true, Password: "a sufficiently long password"},
+	} {
+		if _, err := identity.ReplaceProvisionedSubject(ctx, databaseConnection, "hr-sync", created.ID, "", input); !errors.Is(err, identity.ErrInvalidSubjectInput) {
+			t.Fatalf("%s change error = %v", name, err)
+		}
+	}
+
+	disabled, err := identity.ReplaceProvisionedSubject(ctx, databaseConnection, "hr-sync", created.ID, renamed.Version, identity.ProvisionSubjectInput{
+		DisplayName:    "张三丰",
+		EmployeeNumber: "E-1001",
+		Emails:         []string{"san.zhang@example.test"},
+	})
+	if err != nil || disabled.Active || !strings.HasPrefix(disabled.Version, `W/"2-`) {
+		t.Fatalf("disabled = %#v, %v", disabled, err)
+	}
+
+	var provisioningEvents, actorEvents int
+	if err := databaseConnection.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM identity_audit_events WHERE target_subject_id = ? AND json_extract(metadata, '$.client_id') = 'hr-sync' AND json_extract(metadata, '$.source') = 'SCIM'),
+			(SELECT COUNT(*) FROM identity_audit_events WHERE target_subject_id = ? AND actor_subject_id IS NOT NULL)
+	`, created.ID, created.ID).Scan(&provisioningEvents, &actorEvents); err != nil {
+		t.Fatalf("count provisioning audit events: %v", err)
+	}
+	// 创建、工号、邮箱、手机号，改名、删除邮箱和手机号、新增邮箱，禁用。
+	if provisioningEvents != 9 || actorEvents != 0 {
+		t.Fatalf("provisioning audit events = %d, with a subject actor = %d", provisioningEvents, actorEvents)
+	}
+}
+
+func TestProvisionedRoleMembersUseRoleChanges(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	member, err := identity.CreateProvisionedSubject(ctx, databaseConnection, "hr-sync", identity.ProvisionSubjectInput{
+		Account:     "lisi",
+		DisplayName: "李四",
+		Active:      true,
+	}, testPasswordPolicy)
+	if err != nil {
+		t.Fatalf("create provisioned subject: %v", err)
+	}
+
+	role, err := identity.CreateProvisionedRole(ctx, databaseConnection, "hr-sync", "hr.engineering", []string{member.ID})
+	if err != nil || role.RoleCode != "hr.engineering" || len(role.Members) != 1 || role.Members[0].DisplayName != "李四" {
+		t.Fatalf("created role = %#v, %v", role, err)
+	}
+	subject, err := identity.GetProvisionedSubject(ctx, databaseConnection, member.ID)
+	if err != nil || len(subject.Roles) != 1 || subject.Roles[0].ID != role.ID || !strings.HasPrefix(subject.Version, `W/"2-`) {
+		t.Fatalf("member after grant = %#v, %v", subject, err)
+	}
+	if _, err := identity.ReplaceProvisionedRoleMembers(ctx, databaseConnection, "hr-sync", role.ID, role.Version, []string{"01JUNKNOWNSUBJECT000000000"}); !errors.Is(err, identity.ErrSubjectNotFound) {
+		t.Fatalf("unknown member error = %v", err)
+	}
+	emptied, err := identity.ReplaceProvisionedRoleMembers(ctx, databaseConnection, "hr-sync", role.ID, role.Version, nil)
+	if err != nil || len(emptied.Members) != 0 || emptied.Version == role.Version {
+		t.Fatalf("emptied role = %#v, %v", emptied, err)
+	}
+
+	// identity 命名空间中的角色不受 SCIM 管理。
+	if _, err := identity.CreateProvisionedRole(ctx, databaseConnection, "hr-sync", "identity.operators", nil); !errors.Is(err, identity.ErrReservedRole) {
+		t.Fatalf("reserved role creation error = %v", err)
+	}
+	roles, err := identity.ListProvisionedRoles(ctx, databaseConnection, identity.ListProvisionedRolesInput{
+		Filter: &identity.ProvisioningFilter{Attribute: identity.ProvisioningFilterDisplayName, Value: "identity.admin"},
+		Limit:  10,
+	})
+	if err != nil || roles.Total != 1 || roles.Roles[0].Members[0].SubjectID != administrator.ID {
+		t.Fatalf("administrator role = %#v, %v", roles, err)
+	}
+	if _, err := identity.ReplaceProvisionedRoleMembers(ctx, databaseConnection, "hr-sync", roles.Roles[0].ID, "", nil); !errors.Is(err, identity.ErrReservedRole) {
+		t.Fatalf("reserved role membership error = %v", err)
+	}
+}
@@ -0,0 +1,321 @@
+package identity
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database/sqlc"
+)
+
+var ErrRoleNotFound = errors.New("role not found")
+var ErrRoleAlreadyExists = errors.New("role_code is already defined")
+var ErrInvalidRoleInput = errors.New("invalid role input")
+
+// reservedRoleNamespace holds the control-plane roles seeded by bootstrap.
+// Application-defined roles live in their own namespace, for example
+// trainova.instructor.
+const reservedRoleNamespace = "identity."
+
+type Role struct {
+	ID          string `json:"id"`
+	RoleCode    string `json:"role_code"`
+	DisplayName string `json:"display_name"`
+	Description string `json:"description"`
+	// SecondFactor is 必需 when holders must sign in with TOTP.
+	SecondFactor string    `json:"second_factor"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type CreateRoleInput struct {
+	RoleCode    string
+	DisplayName string
+	Description string
+}
+
+func ListRoles(ctx context.Context, database *sql.DB) ([]Role, error) {
+	rows, err := sqlc.New(database).ListRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list roles: %w", err)
+	}
+	roles := make([]Role, 0, len(rows))
+	for _, row := range rows {
+		roles = append(roles, roleFromRecord(row))
+	}
+	return roles, nil
+}
+
+func CreateRole(ctx context.Context, database *sql.DB, actorSubjectID string, input CreateRoleInput) (Role, error) {
+	roleCode, err := validateRoleCode(input.RoleCode)
+	if err != nil {
+		return Role{}, fmt.Errorf("%w: %v", ErrInvalidRoleInput, err)
+	}
+	displayName, err := validateDisplayName(input.DisplayName)
+	if err != nil {
+		return Role{}, fmt.Errorf("%w: %v", ErrInvalidRoleInput, err)
+	}
+	description := strings.TrimSpace(input.Description)
+	if utf8.RuneCountInString(description) > 500 {
+		return Role{}, fmt.Errorf("%w: description must not exceed 500 characters", ErrInvalidRoleInput)
+	}
+
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return Role{}, fmt.Errorf("begin create role transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	role, err := createRole(ctx, transactionQueries, subjectActor(actorSubjectID), roleCode, displayName, description, time.Now().UTC())
+	if err != nil {
+		return Role{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return Role{}, fmt.Errorf("commit create role transaction: %w", err)
+	}
+	return role, nil
+}
+
+// createRole stores a validated role within the caller's transaction.
+func createRole(ctx context.Context, queries sqlc.Querier, actor auditActor, roleCode string, displayName string, description string, now time.Time) (Role, error) {
+	_, err := queries.GetRoleByCode(ctx, roleCode)
+	if err == nil {
+		return Role{}, ErrRoleAlreadyExists
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return Role{}, fmt.Errorf("check role_code: %w", err)
+	}
+
+	id, err := NewULID(now)
+	if err != nil {
+		return Role{}, err
+	}
+	if err := queries.CreateRole(ctx, sqlc.CreateRoleParams{
+		ID:          id,
+		RoleCode:    roleCode,
+		DisplayName: displayName,
+		Description: description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}); err != nil {
+		return Role{}, fmt.Errorf("create role: %w", err)
+	}
+	if err := insertRoleAuditEvent(ctx, queries, "角色创建", actor, "", roleCode, now); err != nil {
+		return Role{}, err
+	}
+	return Role{
+		ID:          id,
+		RoleCode:    roleCode,
+		DisplayName: displayName,
+		Description: description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}, nil
+}
+
+// GrantSubjectRole assigns a role to a subject. Like every role change it
+// increments the subject's security version and revokes its sessions, so
+// browser sessions and issued tokens never carry a stale role set. Granting a
+// role the subject already holds is a no-op.
+func GrantSubjectRole(ctx context.Context, database *sql.DB, actorSubjectID string, subjectID string, roleCode string) (Subject, error) {
+	return changeSubjectRole(ctx, database, actorSubjectID, subjectID, roleCode, true)
+}
+
+// RevokeSubjectRole removes a role from a subject. The final enabled
+// identity.admin holder cannot lose the role. Revoking a role the subject
+// does not hold is a no-op.
+func RevokeSubjectRole(ctx context.Context, database *sql.DB, actorSubjectID string, subjectID string, roleCode string) (Subject, error) {
+	return changeSubjectRole(ctx, database, actorSubjectID, subjectID, roleCode, false)
+}
+
+func changeSubjectRole(ctx context.Context, database *sql.DB, actorSubjectID string, subjectID string, roleCode string, grant bool) (Subject, error) {
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return Subject{}, fmt.Errorf("begin subject role transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	subject, err := getSubject(ctx, transactionQueries, subjectID)
+	if err != nil {
+		return Subject{}, err
+	}
+	role, err := transactionQueries.GetRoleByCode(ctx, roleCode)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Subject{}, ErrRoleNotFound
+	}
+	if err != nil {
+		return Subject{}, fmt.Errorf("get role: %w", err)
+	}
+	subject, err = applySubjectRoleChange(ctx, transactionQueries, subjectActor(actorSubjectID), subject, role, grant, time.Now().UTC())
+	if err != nil {
+		return Subject{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return Subject{}, fmt.Errorf("commit subject role transaction: %w", err)
+	}
+	return subject, nil
+}
+
+// applySubjectRoleChange grants or revokes role within the caller's
+// transaction and returns the updated subject. It does nothing when the
+// subject already has the requested assignment state.
+func applySubjectRoleChange(ctx context.Context, queries sqlc.Querier, actor auditActor, subject Subject, role sqlc.IdentityRole, grant bool, now time.Time) (Subject, error) {
+	roleCode := role.RoleCode
+	subjectID := subject.ID
+	if hasRole(subject.Roles, roleCode) == grant {
+		return subject, nil
+	}
+	if grant && subject.Status == "已删除" {
+		return Subject{}, ErrSubjectErased
+	}
+
+	eventAction := "角色授予"
+	if grant {
+		assignmentID, err := NewULID(now)
+		if err != nil {
+			return Subject{}, err
+		}
+		if err := queries.AssignSubjectRole(ctx, sqlc.AssignSubjectRoleParams{
+			ID:                 assignmentID,
+			SubjectID:          subjectID,
+			RoleID:             role.ID,
+			GrantedBySubjectID: actor.column(),
+			CreatedAt:          now,
+		}); err != nil {
+			return Subject{}, fmt.Errorf("assign subject role: %w", err)
+		}
+	} else {
+		eventAction = "角色撤销"
+		if roleCode == "identity.admin" && subject.Status == "启用" {
+			remainingAdministrators, err := queries.CountEnabledSubjectsByRoleCodeExcludingSubjectID(ctx, sqlc.CountEnabledSubjectsByRoleCodeExcludingSubjectIDParams{
+				Status:   "启用",
+				RoleCode: "identity.admin",
+				ID:       subjectID,
+			})
+			if err != nil {
+				return Subject{}, fmt.Errorf("count remaining administrators: %w", err)
+			}
+			if remainingAdministrators == 0 {
+				return Subject{}, ErrLastAdministrator
+			}
+		}
+		deleted, err := queries.DeleteSubjectRole(ctx, sqlc.DeleteSubjectRoleParams{
+			SubjectID: subjectID,
+			RoleID:    role.ID,
+		})
+		if err != nil {
+			return Subject{}, fmt.Errorf("delete subject role: %w", err)
+		}
+		if deleted != 1 {
+			return Subject{}, fmt.Errorf("delete subject role: expected one assignment, deleted %d", deleted)
+		}
+	}
+
+	if subject.Status == "启用" {
+		if _, err := queries.IncrementEnabledSubjectSecurityVersion(ctx, sqlc.IncrementEnabledSubjectSecurityVersionParams{
+			UpdatedAt: now,
+			ID:        subjectID,
+			Status:    "启用",
+		}); err != nil {
+			return Subject{}, fmt.Errorf("increment subject security version: %w", err)
+		}
+		subject.SecurityVersion++
+		subject.UpdatedAt = now
+	}
+	if _, err := queries.RevokeActiveSessionsBySubjectID(ctx, sqlc.RevokeActiveSessionsBySubjectIDParams{
+		RevokedAt:     sql.NullTime{Time: now, Valid: true},
+		RevokedReason: sql.NullString{String: "权限收回", Valid: true},
+		SubjectID:     subjectID,
+	}); err != nil {
+		return Subject{}, fmt.Errorf("revoke subject sessions after role change: %w", err)
+	}
+	if err := insertRoleAuditEvent(ctx, queries, eventAction, actor, subjectID, roleCode, now); err != nil {
+		return Subject{}, err
+	}
+	roles, err := queries.ListRoleCodesBySubjectID(ctx, subjectID)
+	if err != nil {
+		return Subject{}, fmt.Errorf("list subject roles: %w", err)
+	}
+
+	subject.Roles = roles
+	if subject.Roles == nil {
+		subject.Roles = []string{}
+	}
+	return subject, nil
+}
+
+func roleFromRecord(record sqlc.IdentityRole) Role {
+	return Role{
+		ID:           record.ID,
+		RoleCode:     record.RoleCode,
+		DisplayName:  record.DisplayName,
+		Description:  record.Description,
+		SecondFactor: record.SecondFactor,
+		CreatedAt:    record.CreatedAt,
+		UpdatedAt:    record.UpdatedAt,
+	}
+}
+
+func insertRoleAuditEvent(ctx context.Context, queries sqlc.Querier, eventAction string, actor auditActor, targetSubjectID string, roleCode string, now time.Time) error {
+	auditEventID, err := NewULID(now)
+	if err != nil {
+		return err
+	}
+	metadata, err := json.Marshal(actor.annotate(map[string]string{"role_code": roleCode}))
+	if err != nil {
+		return fmt.Errorf("encode role audit metadata: %w", err)
+	}
+	if err := queries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
+		ID:              auditEventID,
+		EventAction:     eventAction,
+		Outcome:         "成功",
+		ActorSubjectID:  actor.column(),
+		TargetSubjectID: optionalString(targetSubjectID),
+		RequestID:       sql.NullString{},
+		SourceHash:      nil,
+		Metadata:        string(metadata),
+		CreatedAt:       now,
+	}); err != nil {
+		return fmt.Errorf("write role audit event: %w", err)
+	}
+	return nil
+}
+
+// validateRoleCode accepts a dotted namespace such as trainova.instructor:
+// at least two segments of lowercase letters, digits, '-' and '_', each
+// starting with a letter. The identity namespace is reserved.
+func validateRoleCode(value string) (string, error) {
+	if len(value) < 3 || len(value) > 64 {
+		return "", fmt.Errorf("role_code must contain 3 to 64 characters")
+	}
+	if strings.HasPrefix(value, reservedRoleNamespace) {
+		return "", fmt.Errorf("role_code must not use the reserved identity namespace")
+	}
+	segments := strings.Split(value, ".")
+	if len(segments) < 2 {
+		return "", fmt.Errorf("role_code must be namespaced, for example trainova.instructor")
+	}
+	for _, segment := range segments {
+		if segment == "" || segment[0] < 'a' || segment[0] > 'z' {
+			return "", fmt.Errorf("role_code segments must start with a lowercase letter")
+		}
+		for _, character := range segment {
+			switch {
+			case character >= 'a' && character <= 'z', character >= '0' && character <= '9':
+			case character == '-', character == '_':
+			default:
+				return "", fmt.Errorf("role_code may contain only lowercase letters, digits, '.', '-' and '_'")
+			}
+		}
+	}
+	return value, nil
+}
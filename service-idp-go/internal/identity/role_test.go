@@ -0,0 +1,137 @@
+package identity_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+func TestCreateRoleAndGrantRevokeSubjectRole(t *testing.T) {
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+
+	role, err := identity.CreateRole(context.Background(), databaseConnection, administrator.ID, identity.CreateRoleInput{
+		RoleCode:    "trainova.instructor",
+		DisplayName: "培训讲师",
+		Description: "可以编排训练课程",
+	})
+	if err != nil {
+		t.Fatalf("create role: %v", err)
+	}
+	if role.RoleCode != "trainova.instructor" {
+		t.Fatalf("created role = %#v", role)
+	}
+	roles, err := identity.ListRoles(context.Background(), databaseConnection)
+	if err != nil {
+		t.Fatalf("list roles: %v", err)
+	}
+	if len(roles) != 3 || roles[2].RoleCode != "trainova.instructor" {
+		t.Fatalf("listed roles = %#v", roles)
+	}
+
+	created, err := identity.CreateSubject(context.Background(), databaseConnection, administrator.ID, identity.CreateSubjectInput{
+		DisplayName: "张三",
+		Identifier:  "zhangsan",
+		Password:    "a sufficiently long password",
+	}, testPasswordPolicy)
+	if err != nil {
+		t.Fatalf("create subject: %v", err)
+	}
+	login, err := loginWithSource(context.Background(), databaseConnection, "zhangsan", "a sufficiently long password", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("login subject: %v", err)
+	}
+
+	granted, err := identity.GrantSubjectRole(context.Background(), databaseConnection, administrator.ID, created.ID, "trainova.instructor")
+	if err != nil {
+		t.Fatalf("grant role: %v", err)
+	}
+	if len(granted.Roles) != 1 || granted.Roles[0] != "trainova.instructor" || granted.SecurityVersion != created.SecurityVersion+1 {
+		t.Fatalf("granted subject = %#v", granted)
+	}
+	// 角色变更后旧会话以“权限收回”撤销。
+	if _, err := identity.CurrentSession(context.Background(), databaseConnection, login.SessionToken, testSessionSettings); !errors.Is(err, identity.ErrInvalidSession) {
+		t.Fatalf("session after grant error = %v", err)
+	}
+	// 重复授予不产生新的审计事件，也不再提升安全版本。
+	regranted, err := identity.GrantSubjectRole(context.Background(), databaseConnection, administrator.ID, created.ID, "trainova.instructor")
+	if err != nil {
+		t.Fatalf("grant held role: %v", err)
+	}
+	if regranted.SecurityVersion != granted.SecurityVersion {
+		t.Fatalf("regranted security version = %d, want %d", regranted.SecurityVersion, granted.SecurityVersion)
+	}
+
+	revoked, err := identity.RevokeSubjectRole(context.Background(), databaseConnection, administrator.ID, created.ID, "trainova.instructor")
+	if err != nil {
+		t.Fatalf("revoke role: %v", err)
+	}
+	if len(revoked.Roles) != 0 || revoked.SecurityVersion != granted.SecurityVersion+1 {
+		t.Fatalf("revoked subject = %#v", revoked)
+	}
+
+	var createdAudits, grantAudits, revokeAudits, revokedSessions int
+	if err := databaseConnection.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM identity_audit_events WHERE event_action = '角色创建' AND actor_subject_id = ? AND json_extract(metadata, '$.role_code') = 'trainova.instructor'),
+			(SELECT COUNT(*) FROM identity_audit_events WHERE event_action = '角色授予' AND actor_subject_id = ? AND target_subject_id = ?),
+			(SELECT COUNT(*) FROM identity_audit_events WHERE event_action = '角色撤销' AND actor_subject_id = ? AND target_subject_id = ?),
+			(SELECT COUNT(*) FROM identity_sessions WHERE subject_id = ? AND revoked_reason = '权限收回')
+	`, administrator.ID, administrator.ID, created.ID, administrator.ID, created.ID, created.ID).Scan(&createdAudits, &grantAudits, &revokeAudits, &revokedSessions); err != nil {
+		t.Fatalf("read role change state: %v", err)
+	}
+	if createdAudits != 1 || grantAudits != 1 || revokeAudits != 1 || revokedSessions != 1 {
+		t.Fatalf("role change state = created:%d granted:%d revoked:%d sessions:%d", createdAudits, grantAudits, revokeAudits, revokedSessions)
+	}
+}
+
+func TestRevokeSubjectRoleProtectsLastEnabledAdministrator(t *testing.T) {
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+
+	if _, err := identity.RevokeSubjectRole(context.Background(), databaseConnection, administrator.ID, administrator.ID, "identity.admin"); !errors.Is(err, identity.ErrLastAdministrator) {
+		t.Fatalf("revoke last administrator error = %v", err)
+	}
+
+	second, err := identity.CreateSubject(context.Background(), databaseConnection, administrator.ID, identity.CreateSubjectInput{
+		DisplayName: "第二管理员",
+		Identifier:  "second-admin",
+		Password:    "a sufficiently long password",
+	}, testPasswordPolicy)
+	if err != nil {
+		t.Fatalf("create second administrator: %v", err)
+	}
+	if _, err := identity.GrantSubjectRole(context.Background(), databaseConnection, administrator.ID, second.ID, "identity.admin"); err != nil {
+		t.Fatalf("grant second administrator: %v", err)
+	}
+	if _, err := identity.RevokeSubjectRole(context.Background(), databaseConnection, second.ID, administrator.ID, "identity.admin"); err != nil {
+		t.Fatalf("revoke first administrator: %v", err)
+	}
+	if _, err := identity.RevokeSubjectRole(context.Background(), databaseConnection, second.ID, second.ID, "identity.admin"); !errors.Is(err, identity.ErrLastAdministrator) {
+		t.Fatalf("revoke remaining administrator error = %v", err)
+	}
+}
+
+func TestCreateRoleRejectsInvalidOrReservedCodes(t *testing.T) {
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+
+	for _, roleCode := range []string{"instructor", "identity.auditor", "Trainova.Instructor", "trainova..instructor", "trainova.1st"} {
+		_, err := identity.CreateRole(context.Background(), databaseConnection, administrator.ID, identity.CreateRoleInput{RoleCode: roleCode, DisplayName: "角色"})
+		if !errors.Is(err, identity.ErrInvalidRoleInput) {
+			t.Fatalf("role code %q error = %v", roleCode, err)
+		}
+	}
+	input := identity.CreateRoleInput{RoleCode: "aceso.nurse", DisplayName: "护士"}
+	if _, err := identity.CreateRole(context.Background(), databaseConnection, administrator.ID, input); err != nil {
+		t.Fatalf("create role: %v", err)
+	}
+	if _, err := identity.CreateRole(context.Background(), databaseConnection, administrator.ID, input); !errors.Is(err, identity.ErrRoleAlreadyExists) {
+		t.Fatalf("duplicate role error = %v", err)
+	}
+	if _, err := identity.GrantSubjectRole(context.Background(), databaseConnection, administrator.ID, administrator.ID, "aceso.missing"); !errors.Is(err, identity.ErrRoleNotFound) {
+		t.Fatalf("missing role error = %v", err)
+	}
+}
@@ -0,0 +1,565 @@
+package identity
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database/sqlc"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/totp"
+)
+
+var ErrSecondFactorAlreadyEnabled = errors.New("TOTP is already enabled")
+var ErrSecondFactorNotEnrolled = errors.New("TOTP is not enrolled")
+var ErrInvalidSecondFactorCode = errors.New("second factor code is invalid")
+var ErrSecondFactorThrottled = errors.New("too many second factor attempts")
+var ErrInvalidSecondFactorInput = errors.New("invalid second factor input")
+
+// SecondFactorRequirements lists the values of identity_roles.second_factor.
+// A subject holding any 必需 role must pass TOTP before its session is 完整.
+var SecondFactorRequirements = []string{"可选", "必需"}
+
+const (
+	totpIssuer        = "identityd"
+	recoveryCodeCount = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPEnrollment is shown once while enrolling: the secret for manual entry
+// and the otpauth URI to render as a QR code.
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type SecondFactorStatus struct {
+	// TOTP is 未登记, 待确认 or 已启用.
+	TOTP                   string `json:"totp"`
+	Required               bool   `json:"required"`
+	RecoveryCodesRemaining int64  `json:"recovery_codes_remaining"`
+}
+
+type VerifySecondFactorInput struct {
+	// Code is a current TOTP code or one unused recovery code.
+	Code          string
+	SourceAddress string
+}
+
+func GetSecondFactorStatus(ctx context.Context, database *sql.DB, subjectID string) (SecondFactorStatus, error) {
+	queries := sqlc.New(database)
+	if _, err := queries.GetSubjectByID(ctx, subjectID); errors.Is(err, sql.ErrNoRows) {
+		return SecondFactorStatus{}, ErrSubjectNotFound
+	} else if err != nil {
+		return SecondFactorStatus{}, fmt.Errorf("load subject: %w", err)
+	}
+	status := SecondFactorStatus{TOTP: "未登记"}
+	credential, err := queries.GetTOTPCredentialBySubjectID(ctx, subjectID)
+	if err == nil {
+		status.TOTP = credential.Status
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return SecondFactorStatus{}, fmt.Errorf("load TOTP credential: %w", err)
+	}
+	status.Required, err = secondFactorRequired(ctx, queries, subjectID)
+	if err != nil {
+		return SecondFactorStatus{}, err
+	}
+	status.RecoveryCodesRemaining, err = queries.CountUnusedRecoveryCodesBySubjectID(ctx, subjectID)
+	if err != nil {
+		return SecondFactorStatus{}, fmt.Errorf("count recovery codes: %w", err)
+	}
+	return status, nil
+}
+
+// StartTOTPEnrollment stores a new 待确认 secret for the subject, replacing
+// an unconfirmed one. It fails with ErrSecondFactorAlreadyEnabled once TOTP
+// is enabled; an administrator must reset it first.
+func StartTOTPEnrollment(ctx context.Context, database *sql.DB, subjectID string) (TOTPEnrollment, error) {
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return TOTPEnrollment{}, fmt.Errorf("begin TOTP enrollment transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	subject, err := getSubject(ctx, transactionQueries, subjectID)
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+	now := time.Now().UTC()
+	stored, err := transactionQueries.UpsertPendingTOTPCredential(ctx, sqlc.UpsertPendingTOTPCredentialParams{
+		SubjectID: subjectID,
+		Secret:    secret,
+		Status:    "待确认",
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return TOTPEnrollment{}, fmt.Errorf("store TOTP secret: %w", err)
+	}
+	if stored != 1 {
+		return TOTPEnrollment{}, ErrSecondFactorAlreadyEnabled
+	}
+	if err := insertSecondFactorAuditEvent(ctx, transactionQueries, subjectID, subjectID, map[string]string{"change": "登记", "factor": "totp"}, now); err != nil {
+		return TOTPEnrollment{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return TOTPEnrollment{}, fmt.Errorf("commit TOTP enrollment transaction: %w", err)
+	}
+	return TOTPEnrollment{
+		Secret:          totp.EncodeSecret(secret),
+		ProvisioningURI: totp.ProvisioningURI(totpIssuer, subject.Identifier, secret),
+	}, nil
+}
+
+// ConfirmTOTPEnrollment enables the pending secret when code matches it and
+// returns a fresh set of recovery codes, which are only stored hashed. When
+// session is waiting for its second factor, which happens when a role
+// requires TOTP before the subject enrolled, the session is upgraded too.
+// The returned access is the session's access afterwards.
+func ConfirmTOTPEnrollment(ctx context.Context, database *sql.DB, session Session, code string) ([]string, string, error) {
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("begin TOTP confirmation transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	credential, err := transactionQueries.GetTOTPCredentialBySubjectID(ctx, session.SubjectID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && credential.Status != "待确认") {
+		return nil, "", ErrSecondFactorNotEnrolled
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("load TOTP credential: %w", err)
+	}
+	now := time.Now().UTC()
+	step, matched := totp.Match(credential.Secret, strings.TrimSpace(code), now, 0)
+	if !matched {
+		return nil, "", ErrInvalidSecondFactorCode
+	}
+	enabled, err := transactionQueries.EnableTOTPCredential(ctx, sqlc.EnableTOTPCredentialParams{
+		Status:       "已启用",
+		LastUsedStep: step,
+		ConfirmedAt:  sql.NullTime{Time: now, Valid: true},
+		UpdatedAt:    now,
+		SubjectID:    session.SubjectID,
+		Status_2:     "待确认",
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("enable TOTP credential: %w", err)
+	}
+	if enabled != 1 {
+		return nil, "", ErrSecondFactorNotEnrolled
+	}
+	recoveryCodes, err := replaceRecoveryCodes(ctx, transactionQueries, session.SubjectID, now)
+	if err != nil {
+		return nil, "", err
+	}
+	access := session.Access
+	if access == "待二次验证" {
+		access, err = completeSecondFactor(ctx, transactionQueries, session)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	if err := insertSecondFactorAuditEvent(ctx, transactionQueries, session.SubjectID, session.SubjectID, map[string]string{"change": "启用", "factor": "totp"}, now); err != nil {
+		return nil, "", err
+	}
+	if err := transaction.Commit(); err != nil {
+		return nil, "", fmt.Errorf("commit TOTP confirmation transaction: %w", err)
+	}
+	return recoveryCodes, access, nil
+}
+
+// VerifySecondFactor completes the login of a 待二次验证 session with a TOTP
+// code or a recovery code and returns the session's new access: 仅改密 when
+// the password still has to be changed, otherwise 完整. Wrong codes count
+// against the login throttle for the subject and source address.
+func VerifySecondFactor(ctx context.Context, database *sql.DB, session Session, throttleSettings LoginThrottleSettings, input VerifySecondFactorInput) (string, error) {
+	if session.Access != "待二次验证" {
+		return "", ErrInvalidSession
+	}
+	if err := throttleSettings.validate(); err != nil {
+		return "", fmt.Errorf("invalid login throttle settings: %w", err)
+	}
+
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("begin second factor transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	now := time.Now().UTC()
+	throttleKey := loginThrottleKey{
+		identifierHash: hmacSHA256(throttleSettings.Secret, "second-factor\x00"+session.SubjectID),
+		sourceHash:     hmacSHA256(throttleSettings.Secret, normalizedSourceAddress(input.SourceAddress)),
+	}
+	locked, err := loginThrottleLocked(ctx, transactionQueries, throttleKey, now)
+	if err != nil {
+		return "", err
+	}
+	if locked {
+		return "", ErrSecondFactorThrottled
+	}
+	credential, err := transactionQueries.GetTOTPCredentialBySubjectID(ctx, session.SubjectID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && credential.Status != "已启用") {
+		return "", ErrSecondFactorNotEnrolled
+	}
+	if err != nil {
+		return "", fmt.Errorf("load TOTP credential: %w", err)
+	}
+
+	code := strings.TrimSpace(input.Code)
+	factor := ""
+	if step, matched := totp.Match(credential.Secret, code, now, credential.LastUsedStep); matched {
+		advanced, err := transactionQueries.AdvanceTOTPLastUsedStep(ctx, sqlc.AdvanceTOTPLastUsedStepParams{
+			LastUsedStep:   step,
+			UpdatedAt:      now,
+			SubjectID:      session.SubjectID,
+			Status:         "已启用",
+			LastUsedStep_2: step,
+		})
+		if err != nil {
+			return "", fmt.Errorf("record TOTP step: %w", err)
+		}
+		if advanced == 1 {
+			factor = "totp"
+		}
+	} else if len(code) > totp.Digits {
+		consumed, err := transactionQueries.ConsumeRecoveryCode(ctx, sqlc.ConsumeRecoveryCodeParams{
+			ConsumedAt: sql.NullTime{Time: now, Valid: true},
+			SubjectID:  session.SubjectID,
+			CodeHash:   recoveryCodeHash(session.SubjectID, code),
+		})
+		if err != nil {
+			return "", fmt.Errorf("consume recovery code: %w", err)
+		}
+		if consumed == 1 {
+			factor = "recovery_code"
+		}
+	}
+
+	auditEventID, err := NewULID(now)
+	if err != nil {
+		return "", err
+	}
+	if factor == "" {
+		locked, err := countLoginThrottleAttempt(ctx, transactionQueries, throttleSettings, throttleKey, now)
+		if err != nil {
+			return "", err
+		}
+		metadata := `{"reason":"invalid_second_factor"}`
+		if locked {
+			metadata = `{"reason":"throttled"}`
+		}
+		if err := transactionQueries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
+			ID:              auditEventID,
+			EventAction:     "登录",
+			Outcome:         "失败",
+			ActorSubjectID:  sql.NullString{},
+			TargetSubjectID: sql.NullString{String: session.SubjectID, Valid: true},
+			RequestID:       sql.NullString{},
+			SourceHash:      throttleKey.sourceHash,
+			Metadata:        metadata,
+			CreatedAt:       now,
+		}); err != nil {
+			return "", fmt.Errorf("write failed second factor audit event: %w", err)
+		}
+		if err := transaction.Commit(); err != nil {
+			return "", fmt.Errorf("commit failed second factor transaction: %w", err)
+		}
+		return "", ErrInvalidSecondFactorCode
+	}
+
+	if err := transactionQueries.DeleteLoginThrottle(ctx, sqlc.DeleteLoginThrottleParams{
+		IdentifierHash: throttleKey.identifierHash,
+		SourceHash:     throttleKey.sourceHash,
+	}); err != nil {
+		return "", fmt.Errorf("clear second factor throttle: %w", err)
+	}
+	access, err := completeSecondFactor(ctx, transactionQueries, session)
+	if err != nil {
+		return "", err
+	}
+	if err := transactionQueries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
+		ID:              auditEventID,
+		EventAction:     "登录",
+		Outcome:         "成功",
+		ActorSubjectID:  sql.NullString{String: session.SubjectID, Valid: true},
+		TargetSubjectID: sql.NullString{String: session.SubjectID, Valid: true},
+		RequestID:       sql.NullString{},
+		SourceHash:      throttleKey.sourceHash,
+		Metadata:        fmt.Sprintf(`{"factor":%q}`, factor),
+		CreatedAt:       now,
+	}); err != nil {
+		return "", fmt.Errorf("write second factor audit event: %w", err)
+	}
+	if err := transaction.Commit(); err != nil {
+		return "", fmt.Errorf("commit second factor transaction: %w", err)
+	}
+	return access, nil
+}
+
+// ResetSecondFactor removes the subject's TOTP secret and recovery codes,
+// for example after a lost device, and fails with ErrSecondFactorNotEnrolled
+// when there is nothing to remove. Like a password replacement it increments
+// the security version and revokes every session, so a subject whose role
+// requires TOTP enrolls again at the next login.
+func ResetSecondFactor(ctx context.Context, database *sql.DB, actorSubjectID string, subjectID string) error {
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin second factor reset transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	if _, err := transactionQueries.GetSubjectByID(ctx, subjectID); errors.Is(err, sql.ErrNoRows) {
+		return ErrSubjectNotFound
+	} else if err != nil {
+		return fmt.Errorf("load subject: %w", err)
+	}
+	now := time.Now().UTC()
+	reset, err := resetSecondFactor(ctx, transactionQueries, subjectID, now)
+	if err != nil {
+		return err
+	}
+	if !reset {
+		return ErrSecondFactorNotEnrolled
+	}
+	if err := insertSecondFactorAuditEvent(ctx, transactionQueries, actorSubjectID, subjectID, map[string]string{"change": "重置"}, now); err != nil {
+		return err
+	}
+	if err := transaction.Commit(); err != nil {
+		return fmt.Errorf("commit second factor reset transaction: %w", err)
+	}
+	return nil
+}
+
+// SetRoleSecondFactor makes TOTP 必需 or 可选 for holders of roleCode. The
+// requirement applies from each holder's next login.
+func SetRoleSecondFactor(ctx context.Context, database *sql.DB, actorSubjectID string, roleCode string, requirement string) (Role, error) {
+	if !slices.Contains(SecondFactorRequirements, requirement) {
+		return Role{}, fmt.Errorf("%w: second_factor must be 可选 or 必需", ErrInvalidSecondFactorInput)
+	}
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return Role{}, fmt.Errorf("begin role second factor transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	now := time.Now().UTC()
+	updated, err := transactionQueries.UpdateRoleSecondFactor(ctx, sqlc.UpdateRoleSecondFactorParams{
+		SecondFactor: requirement,
+		UpdatedAt:    now,
+		RoleCode:     roleCode,
+	})
+	if err != nil {
+		return Role{}, fmt.Errorf("update role second factor: %w", err)
+	}
+	if updated != 1 {
+		return Role{}, ErrRoleNotFound
+	}
+	record, err := transactionQueries.GetRoleByCode(ctx, roleCode)
+	if err != nil {
+		return Role{}, fmt.Errorf("load role: %w", err)
+	}
+	if err := insertSecondFactorAuditEvent(ctx, transactionQueries, actorSubjectID, "", map[string]string{
+		"change":        "策略",
+		"role_code":     roleCode,
+		"second_factor": requirement,
+	}, now); err != nil {
+		return Role{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return Role{}, fmt.Errorf("commit role second factor transaction: %w", err)
+	}
+	return roleFromRecord(record), nil
+}
+
+// loginSessionAccess is the access of a session right after the password
+// check. A subject with TOTP enabled, or holding a role that requires it,
+// starts at 待二次验证.
+func loginSessionAccess(ctx context.Context, queries sqlc.Querier, subjectID string, credentialStatus string) (string, error) {
+	credential, err := queries.GetTOTPCredentialBySubjectID(ctx, subjectID)
+	if err == nil && credential.Status == "已启用" {
+		return "待二次验证", nil
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("load TOTP credential: %w", err)
+	}
+	required, err := secondFactorRequired(ctx, queries, subjectID)
+	if err != nil {
+		return "", err
+	}
+	if required {
+		return "待二次验证", nil
+	}
+	return passwordSessionAccess(credentialStatus), nil
+}
+
+func passwordSessionAccess(credentialStatus string) string {
+	if credentialStatus == "需更新" {
+		return "仅改密"
+	}
+	return "完整"
+}
+
+func secondFactorRequired(ctx context.Context, queries sqlc.Querier, subjectID string) (bool, error) {
+	requiringRoles, err := queries.CountSubjectRolesBySecondFactor(ctx, sqlc.CountSubjectRolesBySecondFactorParams{
+		SubjectID:    subjectID,
+		SecondFactor: "必需",
+	})
+	if err != nil {
+		return false, fmt.Errorf("check second factor requirement: %w", err)
+	}
+	return requiringRoles > 0, nil
+}
+
+// completeSecondFactor upgrades a 待二次验证 session to the access its
+// password credential allows. A subject provisioned from a directory has no
+// local password credential, and its password is the directory's concern.
+func completeSecondFactor(ctx context.Context, queries sqlc.Querier, session Session) (string, error) {
+	credentialStatus := "有效"
+	credential, err := queries.GetPasswordCredentialBySubjectID(ctx, session.SubjectID)
+	if err == nil {
+		credentialStatus = credential.CredentialStatus
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("load password credential: %w", err)
+	} else if directory, err := subjectDirectory(ctx, queries, session.SubjectID); err != nil {
+		return "", err
+	} else if directory == "" {
+		return "", ErrPasswordCredentialNotFound
+	}
+	access := passwordSessionAccess(credentialStatus)
+	updated, err := queries.UpdateActiveSessionAccess(ctx, sqlc.UpdateActiveSessionAccessParams{
+		SessionAccess:   access,
+		ID:              session.ID,
+		SessionAccess_2: "待二次验证",
+	})
+	if err != nil {
+		return "", fmt.Errorf("upgrade session access: %w", err)
+	}
+	if updated != 1 {
+		return "", ErrInvalidSession
+	}
+	return access, nil
+}
+
+// resetSecondFactor reports whether the subject had a TOTP secret. Sessions
+// are only revoked when it did.
+func resetSecondFactor(ctx context.Context, queries sqlc.Querier, subjectID string, now time.Time) (bool, error) {
+	deleted, err := queries.DeleteTOTPCredentialBySubjectID(ctx, subjectID)
+	if err != nil {
+		return false, fmt.Errorf("delete TOTP credential: %w", err)
+	}
+	if _, err := queries.DeleteRecoveryCodesBySubjectID(ctx, subjectID); err != nil {
+		return false, fmt.Errorf("delete recovery codes: %w", err)
+	}
+	if deleted == 0 {
+		return false, nil
+	}
+	if _, err := queries.IncrementEnabledSubjectSecurityVersion(ctx, sqlc.IncrementEnabledSubjectSecurityVersionParams{
+		UpdatedAt: now,
+		ID:        subjectID,
+		Status:    "启用",
+	}); err != nil {
+		return false, fmt.Errorf("increment subject security version: %w", err)
+	}
+	if _, err := queries.RevokeActiveSessionsBySubjectID(ctx, sqlc.RevokeActiveSessionsBySubjectIDParams{
+		RevokedAt:     sql.NullTime{Time: now, Valid: true},
+		RevokedReason: sql.NullString{String: "凭据变更", Valid: true},
+		SubjectID:     subjectID,
+	}); err != nil {
+		return false, fmt.Errorf("revoke subject sessions after second factor reset: %w", err)
+	}
+	return true, nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, queries sqlc.Querier, subjectID string, now time.Time) ([]string, error) {
+	if _, err := queries.DeleteRecoveryCodesBySubjectID(ctx, subjectID); err != nil {
+		return nil, fmt.Errorf("delete recovery codes: %w", err)
+	}
+	codes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codeID, err := NewULID(now)
+		if err != nil {
+			return nil, err
+		}
+		if err := queries.CreateRecoveryCode(ctx, sqlc.CreateRecoveryCodeParams{
+			ID:         codeID,
+			SubjectID:  subjectID,
+			CodeHash:   recoveryCodeHash(subjectID, code),
+			ConsumedAt: sql.NullTime{},
+			CreatedAt:  now,
+		}); err != nil {
+			return nil, fmt.Errorf("store recovery code: %w", err)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// newRecoveryCode returns ten base32 characters, about 50 bits, grouped as
+// xxxxx-xxxxx for reading off paper.
+func newRecoveryCode() (string, error) {
+	value := make([]byte, 7)
+	if _, err := rand.Read(value); err != nil {
+		return "", fmt.Errorf("generate recovery code: %w", err)
+	}
+	encoded := strings.ToLower(recoveryCodeEncoding.EncodeToString(value))[:10]
+	return encoded[:5] + "-" + encoded[5:], nil
+}
+
+// recoveryCodeHash ignores case, spaces and dashes, and binds the code to its
+// subject so equal codes of different subjects never share a hash.
+func recoveryCodeHash(subjectID string, code string) []byte {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return hashBytes([]byte(subjectID + ":" + normalized))
+}
+
+func insertSecondFactorAuditEvent(ctx context.Context, queries sqlc.Querier, actorSubjectID string, targetSubjectID string, metadata map[string]string, now time.Time) error {
+	auditEventID, err := NewULID(now)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("encode second factor audit metadata: %w", err)
+	}
+	if err := queries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
+		ID:              auditEventID,
+		EventAction:     "二次验证变更",
+		Outcome:         "成功",
+		ActorSubjectID:  sql.NullString{String: actorSubjectID, Valid: actorSubjectID != ""},
+		TargetSubjectID: sql.NullString{String: targetSubjectID, Valid: targetSubjectID != ""},
+		RequestID:       sql.NullString{},
+		SourceHash:      nil,
+		Metadata:        string(encoded),
+		CreatedAt:       now,
+	}); err != nil {
+		return fmt.Errorf("write second factor audit event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package identity_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/totp"
+)
+
+func TestTOTPEnrollmentGatesLoginUntilSecondFactor(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	login, err := loginWithSource(ctx, databaseConnection, "admin", "correct horse battery staple", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	session, err := identity.CurrentSession(ctx, databaseConnection, login.SessionToken, testSessionSettings)
+	if err != nil {
+		t.Fatalf("current session: %v", err)
+	}
+
+	enrollment, err := identity.StartTOTPEnrollment(ctx, databaseConnection, administrator.ID)
+	if err != nil {
+		t.Fatalf("start enrollment: %v", err)
+	}
+	// 未确认前登录不受影响。
+	if pending, err := loginWithSource(ctx, databaseConnection, "admin", "correct horse battery staple", "192.0.2.1"); err != nil || pending.Access != "完整" {
+		t.Fatalf("login before confirmation = %#v, %v", pending, err)
+	}
+	secret := decodeTOTPSecret(t, enrollment.Secret)
+	if _, _, err := identity.ConfirmTOTPEnrollment(ctx, databaseConnection, session, "abcdef"); !errors.Is(err, identity.ErrInvalidSecondFactorCode) {
+		t.Fatalf("confirm with wrong code error = %v", err)
+	}
+	step := totp.Step(time.Now())
+	recoveryCodes, access, err := identity.ConfirmTOTPEnrollment(ctx, databaseConnection, session, totp.Code(secret, step))
+	if err != nil {
+		t.Fatalf("confirm enrollment: %v", err)
+	}
+	if len(recoveryCodes) != 10 || access != "完整" {
+		t.Fatalf("recovery codes = %d, access = %q", len(recoveryCodes), access)
+	}
+	if _, err := identity.StartTOTPEnrollment(ctx, databaseConnection, administrator.ID); !errors.Is(err, identity.ErrSecondFactorAlreadyEnabled) {
+		t.Fatalf("second enrollment error = %v", err)
+	}
+
+	pending, err := loginWithSource(ctx, databaseConnection, "admin", "correct horse battery staple", "192.0.2.1")
+	if err != nil || pending.Access != "待二次验证" {
+		t.Fatalf("login after enrollment = %#v, %v", pending, err)
+	}
+	pendingSession, err := identity.CurrentSession(ctx, databaseConnection, pending.SessionToken, testSessionSettings)
+	if err != nil {
+		t.Fatalf("pending session: %v", err)
+	}
+	// 确认时使用过的时间步不能再次通过。
+	if _, err := identity.VerifySecondFactor(ctx, databaseConnection, pendingSession, testLoginThrottleSettings, identity.VerifySecondFactorInput{
+		Code:          totp.Code(secret, step),
+		SourceAddress: "192.0.2.1",
+	}); !errors.Is(err, identity.ErrInvalidSecondFactorCode) {
+		t.Fatalf("replayed code error = %v", err)
+	}
+	access, err = identity.VerifySecondFactor(ctx, databaseConnection, pendingSession, testLoginThrottleSettings, identity.VerifySecondFactorInput{
+		Code:          totp.Code(secret, step+1),
+		SourceAddress: "192.0.2.1",
+	})
+	if err != nil || access != "完整" {
+		t.Fatalf("verify second factor = %q, %v", access, err)
+	}
+	upgraded, err := identity.CurrentSession(ctx, databaseConnection, pending.SessionToken, testSessionSettings)
+	if err != nil || upgraded.Access != "完整" {
+		t.Fatalf("upgraded session = %#v, %v", upgraded, err)
+	}
+
+	// 恢复码只能使用一次。
+	for attempt, want := range []error{nil, identity.ErrInvalidSecondFactorCode} {
+		pending, err := loginWithSource(ctx, databaseConnection, "admin", "correct horse battery staple", "192.0.2.1")
+		if err != nil {
+			t.Fatalf("login %d: %v", attempt, err)
+		}
+		pendingSession, err := identity.CurrentSession(ctx, databaseConnection, pending.SessionToken, testSessionSettings)
+		if err != nil {
+			t.Fatalf("pending session %d: %v", attempt, err)
+		}
+		if _, err := identity.VerifySecondFactor(ctx, databaseConnection, pendingSession, testLoginThrottleSettings, identity.VerifySecondFactorInput{
+			Code:          " " + recoveryCodes[0] + " ",
+			SourceAddress: "192.0.2.1",
+		}); !errors.Is(err, want) {
+			t.Fatalf("recovery code attempt %d error = %v, want %v", attempt, err, want)
+		}
+	}
+	status, err := identity.GetSecondFactorStatus(ctx, databaseConnection, administrator.ID)
+	if err != nil || status.TOTP != "已启用" || status.RecoveryCodesRemaining != 9 {
+		t.Fatalf("second factor status = %#v, %v", status, err)
+	}
+	assertSecondFactorAuditCount(t, databaseConnection, administrator.ID, 2)
+}
+
+func TestSecondFactorVerificationIsThrottled(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	secret := enrollTOTP(t, databaseConnection, administrator.ID)
+	pending, err := loginWithSource(ctx, databaseConnection, "admin", "correct horse battery staple", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	session, err := identity.CurrentSession(ctx, databaseConnection, pending.SessionToken, testSessionSettings)
+	if err != nil {
+		t.Fatalf("current session: %v", err)
+	}
+	for attempt := range testLoginThrottleSettings.FailureLimit {
+		if _, err := identity.VerifySecondFactor(ctx, databaseConnection, session, testLoginThrottleSettings, identity.VerifySecondFactorInput{
+			Code:          "not-a-code",
+			SourceAddress: "192.0.2.1",
+		}); !errors.Is(err, identity.ErrInvalidSecondFactorCode) {
+			t.Fatalf("attempt %d error = %v", attempt, err)
+		}
+	}
+	// 锁定后即使验证码正确也会被拒绝。
+	if _, err := identity.VerifySecondFactor(ctx, databaseConnection, session, testLoginThrottleSettings, identity.VerifySecondFactorInput{
+		Code:          totp.Code(secret, totp.Step(time.Now())+1),
+		SourceAddress: "192.0.2.1",
+	}); !errors.Is(err, identity.ErrSecondFactorThrottled) {
+		t.Fatalf("throttled error = %v", err)
+	}
+}
+
+func TestRoleRequirementForcesEnrollmentAndAdministratorReset(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	if _, err := identity.SetRoleSecondFactor(ctx, databaseConnection, administrator.ID, "identity.admin", "总是"); !errors.Is(err, identity.ErrInvalidSecondFactorInput) {
+		t.Fatalf("invalid requirement error = %v", err)
+	}
+	if _, err := identity.SetRoleSecondFactor(ctx, databaseConnection, administrator.ID, "missing.role", "必需"); !errors.Is(err, identity.ErrRoleNotFound) {
+		t.Fatalf("missing role error = %v", err)
+	}
+	role, err := identity.SetRoleSecondFactor(ctx, databaseConnection, administrator.ID, "identity.admin", "必需")
+	if err != nil || role.SecondFactor != "必需" {
+		t.Fatalf("set requirement = %#v, %v", role, err)
+	}
+
+	// 未登记时登录只能进入二次验证设置。
+	pending, err := loginWithSource(ctx, databaseConnection, "admin", "correct horse battery staple", "192.0.2.1")
+	if err != nil || pending.Access != "待二次验证" {
+		t.Fatalf("login = %#v, %v", pending, err)
+	}
+	session, err := identity.CurrentSession(ctx, databaseConnection, pending.SessionToken, testSessionSettings)
+	if err != nil {
+		t.Fatalf("current session: %v", err)
+	}
+	if _, err := identity.VerifySecondFactor(ctx, databaseConnection, session, testLoginThrottleSettings, identity.VerifySecondFactorInput{
+		Code:          "123456",
+		SourceAddress: "192.0.2.1",
+	}); !errors.Is(err, identity.ErrSecondFactorNotEnrolled) {
+		t.Fatalf("verify without enrollment error = %v", err)
+	}
+	enrollment, err := identity.StartTOTPEnrollment(ctx, databaseConnection, administrator.ID)
+	if err != nil {
+		t.Fatalf("start enrollment: %v", err)
+	}
+	secret := decodeTOTPSecret(t, enrollment.Secret)
+	_, access, err := identity.ConfirmTOTPEnrollment(ctx, databaseConnection, session, totp.Code(secret, totp.Step(time.Now())))
+	if err != nil || access != "完整" {
+		t.Fatalf("confirm enrollment = %q, %v", access, err)
+	}
+
+	if err := identity.ResetSecondFactor(ctx, databaseConnection, administrator.ID, administrator.ID); err != nil {
+		t.Fatalf("reset second factor: %v", err)
+	}
+	if _, err := identity.CurrentSession(ctx, databaseConnection, pending.SessionToken, testSessionSettings); err == nil {
+		t.Fatal("session survived second factor reset")
+	}
+	if err := identity.ResetSecondFactor(ctx, databaseConnection, administrator.ID, administrator.ID); !errors.Is(err, identity.ErrSecondFactorNotEnrolled) {
+		t.Fatalf("second reset error = %v", err)
+	}
+	status, err := identity.GetSecondFactorStatus(ctx, databaseConnection, administrator.ID)
+	if err != nil || status.TOTP != "未登记" || !status.Required || status.RecoveryCodesRemaining != 0 {
+		t.Fatalf("status after reset = %#v, %v", status, err)
+	}
+	// 登记、启用、重置和策略变更各写一条审计事件。
+	assertSecondFactorAuditCount(t, databaseConnection, administrator.ID, 4)
+}
+
+func enrollTOTP(t *testing.T, databaseConnection *sql.DB, subjectID string) []byte {
+	t.Helper()
+	ctx := context.Background()
+	enrollment, err := identity.StartTOTPEnrollment(ctx, databaseConnection, subjectID)
+	if err != nil {
+		t.Fatalf("start enrollment: %v", err)
+	}
+	secret := decodeTOTPSecret(t, enrollment.Secret)
+	if _, _, err := identity.ConfirmTOTPEnrollment(ctx, databaseConnection, identity.Session{SubjectID: subjectID, Access: "完整"}, totp.Code(secret, totp.Step(time.Now()))); err != nil {
+		t.Fatalf("confirm enrollment: %v", err)
+	}
+	return secret
+}
+
+func decodeTOTPSecret(t *testing.T, encoded string) []byte {
+	t.Helper()
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("decode TOTP secret: %v", err)
+	}
+	return secret
+}
+
+func assertSecondFactorAuditCount(t *testing.T, databaseConnection *sql.DB, actorSubjectID string, want int) {
+	t.Helper()
+	var count int
+	if err := databaseConnection.QueryRow(`
+		SELECT COUNT(*)
+		FROM identity_audit_events
+		WHERE event_action = '二次验证变更' AND actor_subject_id = ?
+	`, actorSubjectID).Scan(&count); err != nil {
+		t.Fatalf("count second factor audit events: %v", err)
+	}
+	if count != want {
+		t.Fatalf("second factor audit events = %d, want %d", count, want)
+	}
+}
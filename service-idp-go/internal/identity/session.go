@@ -34,6 +34,9 @@ type LoginInput struct {
 	Identifier    string
 	Password      string
 	SourceAddress string
+	// UserAgent is kept in the session metadata so subjects can tell their
+	// sessions apart.
+	UserAgent string
 }
 
 type Session struct {
@@ -43,13 +46,20 @@ type Session struct {
 	csrfTokenHash []byte
 }
 
-func Login(ctx context.Context, database *sql.DB, input LoginInput, settings SessionSettings, throttleSettings LoginThrottleSettings) (LoginResult, error) {
+// Login verifies a password and opens a browser session. Two credential
+// updates ride along with a successful login: a hash made with outdated
+// Argon2id parameters is replaced in place, and a 有效 credential older than
+// the policy's MaximumAge becomes 需更新, which yields a 仅改密 session.
+func Login(ctx context.Context, database *sql.DB, input LoginInput, settings SessionSettings, throttleSettings LoginThrottleSettings, policy PasswordPolicy) (LoginResult, error) {
 	if settings.TTL <= 0 || settings.IdleTTL <= 0 || settings.IdleTTL > settings.TTL {
 		return LoginResult{}, fmt.Errorf("invalid session settings")
 	}
 	if err := throttleSettings.validate(); err != nil {
 		return LoginResult{}, fmt.Errorf("invalid login throttle settings: %w", err)
 	}
+	if err := policy.validate(); err != nil {
+		return LoginResult{}, fmt.Errorf("invalid password policy: %w", err)
+	}
 
 	queries := sqlc.New(database)
 	now := time.Now().UTC()
@@ -62,17 +72,19 @@ func Login(ctx context.Context, database *sql.DB, input LoginInput, settings Ses
 		return LoginResult{}, rejectLogin(ctx, database, throttleSettings, throttleKey, now)
 	}
 
-	normalizedIdentifier, err := normalizeAccountIdentifier(input.Identifier)
-	if err != nil {
-		return LoginResult{}, rejectLogin(ctx, database, throttleSettings, throttleKey, now)
+	var credential sqlc.GetLoginCredentialByNormalizedIdentifierRow
+	err = sql.ErrNoRows
+	for _, normalizedIdentifier := range loginIdentifierCandidates(input.Identifier) {
+		credential, err = queries.GetLoginCredentialByNormalizedIdentifier(ctx, sqlc.GetLoginCredentialByNormalizedIdentifierParams{
+			NormalizedValue:   normalizedIdentifier,
+			Status:            "启用",
+			IdentifierUsage:   "主登录",
+			IdentifierUsage_2: "辅助登录",
+		})
+		if !errors.Is(err, sql.ErrNoRows) {
+			break
+		}
 	}
-
-	credential, err := queries.GetLoginCredentialByNormalizedIdentifier(ctx, sqlc.GetLoginCredentialByNormalizedIdentifierParams{
-		NormalizedValue:   normalizedIdentifier,
-		Status:            "启用",
-		IdentifierUsage:   "主登录",
-		IdentifierUsage_2: "辅助登录",
-	})
 	if errors.Is(err, sql.ErrNoRows) {
 		return LoginResult{}, rejectLogin(ctx, database, throttleSettings, throttleKey, now)
 	}
@@ -92,33 +104,31 @@ func Login(ctx context.Context, database *sql.DB, input LoginInput, settings Ses
 	if err != nil {
 		return LoginResult{}, fmt.Errorf("load login subject: %w", err)
 	}
-	matched, err := password.Verify(input.Password, credential.PasswordHash)
-	if err != nil || !matched {
+	verification, err := password.Verify(input.Password, credential.PasswordHash)
+	if err != nil || !verification.Matched {
 		return LoginResult{}, rejectLogin(ctx, database, throttleSettings, throttleKey, now)
 	}
+	// A hash that cannot be redone, such as one shorter than the current
+	// floor, is left alone rather than failing the login.
+	rehashedPassword := ""
+	if verification.NeedsRehash {
+		rehashedPassword, _ = password.Hash(input.Password)
+	}
 
 	now = time.Now().UTC()
-	sessionID, err := NewULID(now)
-	if err != nil {
-		return LoginResult{}, err
+	credentialStatus := credential.CredentialStatus
+	passwordExpired := policy.expired(credentialStatus, credential.ChangedAt, now)
+	if passwordExpired {
+		credentialStatus = "需更新"
 	}
-	sessionToken, sessionTokenHash, err := newSecret()
+	sessionAccess, err := loginSessionAccess(ctx, queries, credential.SubjectID, credentialStatus)
 	if err != nil {
 		return LoginResult{}, err
 	}
-	csrfToken, csrfTokenHash, err := newSecret()
+	sessionMetadata, err := encodeSessionMetadata(input.UserAgent)
 	if err != nil {
 		return LoginResult{}, err
 	}
-	expiresAt := now.Add(settings.TTL)
-	idleExpiresAt := now.Add(settings.IdleTTL)
-	if idleExpiresAt.After(expiresAt) {
-		idleExpiresAt = expiresAt
-	}
-	sessionAccess := "完整"
-	if credential.CredentialStatus == "需更新" {
-		sessionAccess = "仅改密"
-	}
 
 	transaction, err := database.BeginTx(ctx, nil)
 	if err != nil {
@@ -145,9 +155,56 @@ func Login(ctx context.Context, database *sql.DB, input LoginInput, settings Ses
 	}); err != nil {
 		return LoginResult{}, fmt.Errorf("clear login throttle: %w", err)
 	}
-	if err := transactionQueries.CreateSession(ctx, sqlc.CreateSessionParams{
+	if rehashedPassword != "" {
+		// Only the encoding changes, so neither the revision nor the security
+		// version moves and reset tokens and sessions stay valid.
+		if _, err := transactionQueries.RehashPasswordCredential(ctx, sqlc.RehashPasswordCredentialParams{
+			PasswordHash:     rehashedPassword,
+			UpdatedAt:        now,
+			SubjectID:        credential.SubjectID,
+			PasswordRevision: credential.PasswordRevision,
+		}); err != nil {
+			return LoginResult{}, fmt.Errorf("rehash password: %w", err)
+		}
+	}
+	if passwordExpired {
+		if err := expirePassword(ctx, transactionQueries, credential.SubjectID, credential.PasswordRevision, throttleKey.sourceHash, now); err != nil {
+			return LoginResult{}, err
+		}
+	}
+	result, err := openLoginSession(ctx, transactionQueries, credential.SubjectID, securityVersion, sessionAccess, sessionMetadata, loginAuditMetadata(sessionAccess), throttleKey.sourceHash, settings, now)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return LoginResult{}, fmt.Errorf("commit login transaction: %w", err)
+	}
+	return result, nil
+}
+
+// openLoginSession creates a browser session and its 登录 audit event within
+// the caller's transaction.
+func openLoginSession(ctx context.Context, queries sqlc.Querier, subjectID string, securityVersion int64, sessionAccess string, sessionMetadata string, auditMetadata string, sourceHash []byte, settings SessionSettings, now time.Time) (LoginResult, error) {
+	sessionID, err := NewULID(now)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	sessionToken, sessionTokenHash, err := newSecret()
+	if err != nil {
+		return LoginResult{}, err
+	}
+	csrfToken, csrfTokenHash, err := newSecret()
+	if err != nil {
+		return LoginResult{}, err
+	}
+	expiresAt := now.Add(settings.TTL)
+	idleExpiresAt := now.Add(settings.IdleTTL)
+	if idleExpiresAt.After(expiresAt) {
+		idleExpiresAt = expiresAt
+	}
+	if err := queries.CreateSession(ctx, sqlc.CreateSessionParams{
 		ID:                     sessionID,
-		SubjectID:              credential.SubjectID,
+		SubjectID:              subjectID,
 		SubjectSecurityVersion: securityVersion,
 		TokenHash:              sessionTokenHash,
 		CsrfTokenHash:          csrfTokenHash,
@@ -158,7 +215,7 @@ func Login(ctx context.Context, database *sql.DB, input LoginInput, settings Ses
 		IdleExpiresAt:          idleExpiresAt,
 		RevokedAt:              sql.NullTime{},
 		RevokedReason:          sql.NullString{},
-		Metadata:               "{}",
+		Metadata:               sessionMetadata,
 		CreatedAt:              now,
 	}); err != nil {
 		return LoginResult{}, fmt.Errorf("create browser session: %w", err)
@@ -167,24 +224,63 @@ func Login(ctx context.Context, database *sql.DB, input LoginInput, settings Ses
 	if err != nil {
 		return LoginResult{}, err
 	}
-	if err := transactionQueries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
+	if err := queries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
 		ID:              auditID,
 		EventAction:     "登录",
 		Outcome:         "成功",
-		ActorSubjectID:  sql.NullString{String: credential.SubjectID, Valid: true},
-		TargetSubjectID: sql.NullString{String: credential.SubjectID, Valid: true},
+		ActorSubjectID:  sql.NullString{String: subjectID, Valid: true},
+		TargetSubjectID: sql.NullString{String: subjectID, Valid: true},
 		RequestID:       sql.NullString{},
-		SourceHash:      throttleKey.sourceHash,
-		Metadata:        "{}",
+		SourceHash:      sourceHash,
+		Metadata:        auditMetadata,
 		CreatedAt:       now,
 	}); err != nil {
 		return LoginResult{}, fmt.Errorf("write login audit event: %w", err)
 	}
-	if err := transaction.Commit(); err != nil {
-		return LoginResult{}, fmt.Errorf("commit login transaction: %w", err)
+	return LoginResult{SessionToken: sessionToken, CSRFToken: csrfToken, ExpiresAt: expiresAt, Access: sessionAccess}, nil
+}
+
+// loginAuditMetadata marks a password login that still needs its second
+// factor; VerifySecondFactor records the completed login separately.
+func loginAuditMetadata(sessionAccess string) string {
+	if sessionAccess == "待二次验证" {
+		return `{"factor":"password","second_factor":"pending"}`
 	}
+	return "{}"
+}
 
-	return LoginResult{SessionToken: sessionToken, CSRFToken: csrfToken, ExpiresAt: expiresAt, Access: sessionAccess}, nil
+func expirePassword(ctx context.Context, queries sqlc.Querier, subjectID string, passwordRevision int64, sourceHash []byte, now time.Time) error {
+	expired, err := queries.ExpirePasswordCredential(ctx, sqlc.ExpirePasswordCredentialParams{
+		CredentialStatus:   "需更新",
+		UpdatedAt:          now,
+		SubjectID:          subjectID,
+		PasswordRevision:   passwordRevision,
+		CredentialStatus_2: "有效",
+	})
+	if err != nil {
+		return fmt.Errorf("expire password credential: %w", err)
+	}
+	if expired != 1 {
+		return nil
+	}
+	auditID, err := NewULID(now)
+	if err != nil {
+		return err
+	}
+	if err := queries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
+		ID:              auditID,
+		EventAction:     "凭据变更",
+		Outcome:         "成功",
+		ActorSubjectID:  sql.NullString{},
+		TargetSubjectID: sql.NullString{String: subjectID, Valid: true},
+		RequestID:       sql.NullString{},
+		SourceHash:      sourceHash,
+		Metadata:        `{"change":"过期"}`,
+		CreatedAt:       now,
+	}); err != nil {
+		return fmt.Errorf("write password expiry audit event: %w", err)
+	}
+	return nil
 }
 
 func rejectLogin(ctx context.Context, database *sql.DB, settings LoginThrottleSettings, key loginThrottleKey, now time.Time) error {
@@ -0,0 +1,192 @@
+package identity
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database/sqlc"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+const maximumSessionUserAgentLength = 256
+
+// ActiveSession describes a browser session without its token. Current marks
+// the session that made the request.
+type ActiveSession struct {
+	ID              string    `json:"id"`
+	Access          string    `json:"access"`
+	UserAgent       string    `json:"user_agent"`
+	AuthenticatedAt time.Time `json:"authenticated_at"`
+	LastSeenAt      time.Time `json:"last_seen_at"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	IdleExpiresAt   time.Time `json:"idle_expires_at"`
+	Current         bool      `json:"current"`
+}
+
+type sessionMetadata struct {
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+// ListSubjectSessions lists the subject's unrevoked, unexpired sessions, most
+// recently used first. currentSessionID may be empty.
+func ListSubjectSessions(ctx context.Context, database *sql.DB, subjectID string, currentSessionID string) ([]ActiveSession, error) {
+	queries := sqlc.New(database)
+	if _, err := getSubject(ctx, queries, subjectID); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	rows, err := queries.ListActiveSessionsBySubjectID(ctx, sqlc.ListActiveSessionsBySubjectIDParams{
+		SubjectID:     subjectID,
+		ExpiresAt:     now,
+		IdleExpiresAt: now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list subject sessions: %w", err)
+	}
+	sessions := make([]ActiveSession, 0, len(rows))
+	for _, row := range rows {
+		var metadata sessionMetadata
+		if err := json.Unmarshal([]byte(row.Metadata), &metadata); err != nil {
+			return nil, fmt.Errorf("decode session metadata: %w", err)
+		}
+		sessions = append(sessions, ActiveSession{
+			ID:              row.ID,
+			Access:          row.SessionAccess,
+			UserAgent:       metadata.UserAgent,
+			AuthenticatedAt: row.AuthenticatedAt,
+			LastSeenAt:      row.LastSeenAt,
+			ExpiresAt:       row.ExpiresAt,
+			IdleExpiresAt:   row.IdleExpiresAt,
+			Current:         row.ID == currentSessionID,
+		})
+	}
+	return sessions, nil
+}
+
+// RevokeOwnSession ends one of the subject's own sessions with 用户退出, the
+// same reason as signing out of it directly.
+func RevokeOwnSession(ctx context.Context, database *sql.DB, subjectID string, sessionID string) error {
+	return revokeSession(ctx, database, subjectID, subjectID, sessionID, "用户退出")
+}
+
+// RevokeSubjectSession ends one session of another subject with 管理员撤销.
+func RevokeSubjectSession(ctx context.Context, database *sql.DB, actorSubjectID string, subjectID string, sessionID string) error {
+	return revokeSession(ctx, database, actorSubjectID, subjectID, sessionID, "管理员撤销")
+}
+
+// RevokeSubjectSessions ends every active session of the subject with
+// 管理员撤销 and returns how many were revoked. It does not change the
+// subject's security version, so issued access tokens stay valid until they
+// expire.
+func RevokeSubjectSessions(ctx context.Context, database *sql.DB, actorSubjectID string, subjectID string) (int64, error) {
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin revoke sessions transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	if _, err := getSubject(ctx, transactionQueries, subjectID); err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC()
+	revoked, err := transactionQueries.RevokeActiveSessionsBySubjectID(ctx, sqlc.RevokeActiveSessionsBySubjectIDParams{
+		RevokedAt:     sql.NullTime{Time: now, Valid: true},
+		RevokedReason: sql.NullString{String: "管理员撤销", Valid: true},
+		SubjectID:     subjectID,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("revoke subject sessions: %w", err)
+	}
+	if err := insertSessionRevocationAuditEvent(ctx, transactionQueries, actorSubjectID, subjectID, map[string]any{
+		"revoked_reason": "管理员撤销",
+		"count":          revoked,
+	}, now); err != nil {
+		return 0, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return 0, fmt.Errorf("commit revoke sessions transaction: %w", err)
+	}
+	return revoked, nil
+}
+
+func revokeSession(ctx context.Context, database *sql.DB, actorSubjectID string, subjectID string, sessionID string, reason string) error {
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin revoke session transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	now := time.Now().UTC()
+	revoked, err := transactionQueries.RevokeActiveSessionByID(ctx, sqlc.RevokeActiveSessionByIDParams{
+		RevokedAt:     sql.NullTime{Time: now, Valid: true},
+		RevokedReason: sql.NullString{String: reason, Valid: true},
+		ID:            sessionID,
+		SubjectID:     subjectID,
+	})
+	if err != nil {
+		return fmt.Errorf("revoke session: %w", err)
+	}
+	if revoked != 1 {
+		return ErrSessionNotFound
+	}
+	if err := insertSessionRevocationAuditEvent(ctx, transactionQueries, actorSubjectID, subjectID, map[string]any{
+		"revoked_reason": reason,
+		"session_id":     sessionID,
+	}, now); err != nil {
+		return err
+	}
+	if err := transaction.Commit(); err != nil {
+		return fmt.Errorf("commit revoke session transaction: %w", err)
+	}
+	return nil
+}
+
+func insertSessionRevocationAuditEvent(ctx context.Context, queries sqlc.Querier, actorSubjectID string, subjectID string, metadata map[string]any, now time.Time) error {
+	auditEventID, err := NewULID(now)
+	if err != nil {
+		return err
+	}
+	encodedMetadata, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("encode session revocation audit metadata: %w", err)
+	}
+	if err := queries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
+		ID:              auditEventID,
+		EventAction:     "会话撤销",
+		Outcome:         "成功",
+		ActorSubjectID:  sql.NullString{String: actorSubjectID, Valid: true},
+		TargetSubjectID: sql.NullString{String: subjectID, Valid: true},
+		RequestID:       sql.NullString{},
+		SourceHash:      nil,
+		Metadata:        string(encodedMetadata),
+		CreatedAt:       now,
+	}); err != nil {
+		return fmt.Errorf("write session revocation audit event: %w", err)
+	}
+	return nil
+}
+
+// encodeSessionMetadata keeps at most 256 characters of the user agent; the
+// value is only displayed, never trusted.
+func encodeSessionMetadata(userAgent string) (string, error) {
+	userAgent = strings.ToValidUTF8(strings.TrimSpace(userAgent), "")
+	if utf8.RuneCountInString(userAgent) > maximumSessionUserAgentLength {
+		userAgent = string([]rune(userAgent)[:maximumSessionUserAgentLength])
+	}
+	metadata, err := json.Marshal(sessionMetadata{UserAgent: userAgent})
+	if err != nil {
+		return "", fmt.Errorf("encode session metadata: %w", err)
+	}
+	return string(metadata), nil
+}
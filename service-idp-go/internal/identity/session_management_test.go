@@ -0,0 +1,110 @@
+package identity_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+func TestListAndRevokeSubjectSessions(t *testing.T) {
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	created, err := identity.CreateSubject(context.Background(), databaseConnection, administrator.ID, identity.CreateSubjectInput{
+		DisplayName: "张三",
+		Identifier:  "zhangsan",
+		Password:    "a sufficiently long password",
+	}, testPasswordPolicy)
+	if err != nil {
+		t.Fatalf("create subject: %v", err)
+	}
+	logins := make([]identity.LoginResult, 0, 3)
+	for _, userAgent := range []string{"Laptop Browser", "Phone Browser", strings.Repeat("界", 300)} {
+		login, err := identity.Login(context.Background(), databaseConnection, identity.LoginInput{
+			Identifier:    "zhangsan",
+			Password:      "a sufficiently long password",
+			SourceAddress: "192.0.2.1",
+			UserAgent:     userAgent,
+		}, testSessionSettings, testLoginThrottleSettings, testPasswordPolicy)
+		if err != nil {
+			t.Fatalf("login subject: %v", err)
+		}
+		logins = append(logins, login)
+	}
+	current, err := identity.CurrentSession(context.Background(), databaseConnection, logins[0].SessionToken, testSessionSettings)
+	if err != nil {
+		t.Fatalf("load current session: %v", err)
+	}
+
+	sessions, err := identity.ListSubjectSessions(context.Background(), databaseConnection, created.ID, current.ID)
+	if err != nil {
+		t.Fatalf("list subject sessions: %v", err)
+	}
+	if len(sessions) != 3 || !sessions[0].Current || sessions[0].UserAgent != "Laptop Browser" {
+		t.Fatalf("sessions = %#v", sessions)
+	}
+	for _, session := range sessions {
+		if len([]rune(session.UserAgent)) > 256 {
+			t.Fatalf("session user agent was not truncated: %d runes", len([]rune(session.UserAgent)))
+		}
+	}
+
+	// 主体只能撤销自己的会话，其他主体的会话视为不存在。
+	if err := identity.RevokeOwnSession(context.Background(), databaseConnection, administrator.ID, sessions[1].ID); !errors.Is(err, identity.ErrSessionNotFound) {
+		t.Fatalf("revoke another subject's session error = %v", err)
+	}
+	if err := identity.RevokeOwnSession(context.Background(), databaseConnection, created.ID, sessions[1].ID); err != nil {
+		t.Fatalf("revoke own session: %v", err)
+	}
+	if err := identity.RevokeOwnSession(context.Background(), databaseConnection, created.ID, sessions[1].ID); !errors.Is(err, identity.ErrSessionNotFound) {
+		t.Fatalf("revoke session twice error = %v", err)
+	}
+	if err := identity.RevokeSubjectSession(context.Background(), databaseConnection, administrator.ID, created.ID, sessions[2].ID); err != nil {
+		t.Fatalf("administrator revoke session: %v", err)
+	}
+	revoked, err := identity.RevokeSubjectSessions(context.Background(), databaseConnection, administrator.ID, created.ID)
+	if err != nil || revoked != 1 {
+		t.Fatalf("revoke all sessions = %d, err = %v", revoked, err)
+	}
+	if _, err := identity.CurrentSession(context.Background(), databaseConnection, logins[0].SessionToken, testSessionSettings); !errors.Is(err, identity.ErrInvalidSession) {
+		t.Fatalf("revoked session remained valid: %v", err)
+	}
+	if _, err := identity.RevokeSubjectSessions(context.Background(), databaseConnection, administrator.ID, "01HZZZZZZZZZZZZZZZZZZZZZZZ"); !errors.Is(err, identity.ErrSubjectNotFound) {
+		t.Fatalf("revoke sessions of missing subject error = %v", err)
+	}
+
+	rows, err := databaseConnection.Query(`
+		SELECT revoked_reason
+		FROM identity_sessions
+		WHERE subject_id = ?
+		ORDER BY revoked_reason
+	`, created.ID)
+	if err != nil {
+		t.Fatalf("query revoked sessions: %v", err)
+	}
+	defer rows.Close()
+	var reasons []string
+	for rows.Next() {
+		var reason string
+		if err := rows.Scan(&reason); err != nil {
+			t.Fatalf("scan revoked reason: %v", err)
+		}
+		reasons = append(reasons, reason)
+	}
+	if strings.Join(reasons, ",") != "用户退出,管理员撤销,管理员撤销" {
+		t.Fatalf("revoked reasons = %q", reasons)
+	}
+	var auditCount int
+	if err := databaseConnection.QueryRow(`
+		SELECT COUNT(*)
+		FROM identity_audit_events
+		WHERE event_action = '会话撤销' AND target_subject_id = ?
+	`, created.ID).Scan(&auditCount); err != nil {
+		t.Fatalf("count session revocation audits: %v", err)
+	}
+	if auditCount != 3 {
+		t.Fatalf("session revocation audits = %d, want 3", auditCount)
+	}
+}
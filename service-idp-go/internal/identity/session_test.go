@@ -15,6 +15,9 @@ var testSessionSettings = identity.SessionSettings{
 	IdleTTL: 30 * time.Minute,
 }
 
+// testPasswordPolicy keeps only the length floor, as before the policy existed.
+var testPasswordPolicy = identity.PasswordPolicy{}
+
 var testLoginThrottleSettings = identity.LoginThrottleSettings{
 	Secret:          []byte("test-login-throttle-secret-with-at-least-32-bytes"),
 	FailureLimit:    3,
@@ -146,5 +149,5 @@ func loginWithSource(ctx context.Context, databaseConnection *sql.DB, identifier
 		Identifier:    identifierValue,
 		Password:      passwordValue,
 		SourceAddress: sourceAddress,
-	}, testSessionSettings, testLoginThrottleSettings)
+	}, testSessionSettings, testLoginThrottleSettings, testPasswordPolicy)
 }
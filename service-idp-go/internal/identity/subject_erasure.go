@@ -0,0 +1,136 @@
+package identity
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database/sqlc"
+)
+
+// ErasedDisplayName replaces the display name of an erased subject.
+const ErasedDisplayName = "已删除主体"
+
+// EraseSubject removes a subject's personal data without deleting its row,
+// so audit events and role grants made by the subject keep their references.
+// The subject is disabled and loses its roles, its profile and identifiers
+// are replaced with placeholders, its password credential becomes 已作废 and
+// its second factor, password history and reset tokens are deleted. An erased
+// subject reports the status 已删除 and can never be enabled again. The final
+// enabled identity.admin holder cannot be erased.
+func EraseSubject(ctx context.Context, database *sql.DB, actorSubjectID string, subjectID string) (Subject, error) {
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return Subject{}, fmt.Errorf("begin subject erasure transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	subject, err := getSubject(ctx, transactionQueries, subjectID)
+	if err != nil {
+		return Subject{}, err
+	}
+	now := time.Now().UTC()
+	actor := subjectActor(actorSubjectID)
+	// Disabling first applies the last-administrator check and revokes the
+	// sessions, and fails with ErrSubjectErased when there is nothing to do.
+	subject, err = changeSubjectStatus(ctx, transactionQueries, actor, subject, "禁用", now)
+	if err != nil {
+		return Subject{}, err
+	}
+	for _, roleCode := range subject.Roles {
+		role, err := transactionQueries.GetRoleByCode(ctx, roleCode)
+		if err != nil {
+			return Subject{}, fmt.Errorf("get role: %w", err)
+		}
+		if subject, err = applySubjectRoleChange(ctx, transactionQueries, actor, subject, role, false, now); err != nil {
+			return Subject{}, err
+		}
+	}
+
+	if updated, err := transactionQueries.UpdateProfileDisplayName(ctx, sqlc.UpdateProfileDisplayNameParams{
+		DisplayName: ErasedDisplayName,
+		UpdatedAt:   now,
+		SubjectID:   subjectID,
+	}); err != nil {
+		return Subject{}, fmt.Errorf("anonymize subject profile: %w", err)
+	} else if updated != 1 {
+		return Subject{}, ErrSubjectNotFound
+	}
+	if _, err := transactionQueries.DeleteIdentifierVerificationsBySubjectID(ctx, subjectID); err != nil {
+		return Subject{}, fmt.Errorf("delete identifier verifications: %w", err)
+	}
+	// The placeholders derive from the identifier IDs, so they stay unique
+	// and no longer match anything a person could log in with.
+	if _, err := transactionQueries.AnonymizeSubjectIdentifiers(ctx, sqlc.AnonymizeSubjectIdentifiersParams{
+		Status:    "禁用",
+		UpdatedAt: now,
+		SubjectID: subjectID,
+	}); err != nil {
+		return Subject{}, fmt.Errorf("anonymize subject identifiers: %w", err)
+	}
+	if _, err := transactionQueries.RevokePasswordCredential(ctx, sqlc.RevokePasswordCredentialParams{
+		CredentialStatus: "已作废",
+		ChangedAt:        now,
+		UpdatedAt:        now,
+		SubjectID:        subjectID,
+	}); err != nil {
+		return Subject{}, fmt.Errorf("revoke password credential: %w", err)
+	}
+	if _, err := transactionQueries.DeletePasswordHistoryBySubjectID(ctx, subjectID); err != nil {
+		return Subject{}, fmt.Errorf("delete password history: %w", err)
+	}
+	if _, err := transactionQueries.DeletePasswordResetTokensBySubjectID(ctx, subjectID); err != nil {
+		return Subject{}, fmt.Errorf("delete password reset tokens: %w", err)
+	}
+	if _, err := resetSecondFactor(ctx, transactionQueries, subjectID, now); err != nil {
+		return Subject{}, err
+	}
+	// Session metadata holds the user agent of each login.
+	if _, err := transactionQueries.ClearSessionMetadataBySubjectID(ctx, subjectID); err != nil {
+		return Subject{}, fmt.Errorf("clear session metadata: %w", err)
+	}
+	if err := transactionQueries.CreateSubjectErasure(ctx, sqlc.CreateSubjectErasureParams{
+		SubjectID: subjectID,
+		ErasedAt:  now,
+	}); err != nil {
+		return Subject{}, fmt.Errorf("record subject erasure: %w", err)
+	}
+	if _, err := transactionQueries.TouchSubject(ctx, sqlc.TouchSubjectParams{UpdatedAt: now, ID: subjectID}); err != nil {
+		return Subject{}, fmt.Errorf("touch subject: %w", err)
+	}
+
+	auditEventID, err := NewULID(now)
+	if err != nil {
+		return Subject{}, err
+	}
+	metadata, err := json.Marshal(actor.annotate(map[string]string{"status": "已删除"}))
+	if err != nil {
+		return Subject{}, fmt.Errorf("encode subject erasure audit metadata: %w", err)
+	}
+	if err := transactionQueries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
+		ID:              auditEventID,
+		EventAction:     "主体状态变更",
+		Outcome:         "成功",
+		ActorSubjectID:  actor.column(),
+		TargetSubjectID: sql.NullString{String: subjectID, Valid: true},
+		RequestID:       sql.NullString{},
+		SourceHash:      nil,
+		Metadata:        string(metadata),
+		CreatedAt:       now,
+	}); err != nil {
+		return Subject{}, fmt.Errorf("write subject erasure audit event: %w", err)
+	}
+
+	erased, err := getSubject(ctx, transactionQueries, subjectID)
+	if err != nil {
+		return Subject{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return Subject{}, fmt.Errorf("commit subject erasure transaction: %w", err)
+	}
+	return erased, nil
+}
@@ -0,0 +1,138 @@
+package identity_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+func TestEraseSubjectAnonymisesPersonalData(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	created, err := identity.CreateSubject(ctx, databaseConnection, administrator.ID, identity.CreateSubjectInput{
+		DisplayName: "张三",
+		Identifier:  "zhangsan",
+		Password:    "a sufficiently long password",
+	}, testPasswordPolicy)
+	if err != nil {
+		t.Fatalf("create subject: %v", err)
+	}
+	if _, err := identity.CreateSubjectIdentifier(ctx, databaseConnection, administrator.ID, created.ID, identity.CreateIdentifierInput{
+		Type:  "邮箱",
+		Value: "zhangsan@example.test",
+		Usage: "联系",
+	}); err != nil {
+		t.Fatalf("create email identifier: %v", err)
+	}
+	if _, err := identity.CreateRole(ctx, databaseConnection, administrator.ID, identity.CreateRoleInput{RoleCode: "crm.viewer", DisplayName: "CRM 查看"}); err != nil {
+		t.Fatalf("create role: %v", err)
+	}
+	if _, err := identity.GrantSubjectRole(ctx, databaseConnection, administrator.ID, created.ID, "crm.viewer"); err != nil {
+		t.Fatalf("grant role: %v", err)
+	}
+	if _, err := identity.StartTOTPEnrollment(ctx, databaseConnection, created.ID); err != nil {
+		t.Fatalf("start TOTP enrollment: %v", err)
+	}
+	login, err := loginWithSource(ctx, databaseConnection, "zhangsan", "a sufficiently long password", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("login subject: %v", err)
+	}
+
+	erased, err := identity.EraseSubject(ctx, databaseConnection, administrator.ID, created.ID)
+	if err != nil {
+		t.Fatalf("erase subject: %v", err)
+	}
+	if erased.Status != "已删除" || erased.DisplayName != identity.ErasedDisplayName || erased.Identifier == "zhangsan" || len(erased.Roles) != 0 {
+		t.Fatalf("erased subject = %#v", erased)
+	}
+	if _, err := identity.CurrentSession(ctx, databaseConnection, login.SessionToken, testSessionSettings); !errors.Is(err, identity.ErrInvalidSession) {
+		t.Fatalf("session after erasure error = %v", err)
+	}
+	if _, err := loginWithSource(ctx, databaseConnection, "zhangsan", "a sufficiently long password", "192.0.2.1"); !errors.Is(err, identity.ErrInvalidCredentials) {
+		t.Fatalf("login after erasure error = %v", err)
+	}
+
+	var subjectStatus, credentialStatus, passwordHash string
+	var identifiedValues, enabledIdentifiers, totpCredentials, passwordHistory, sessionsWithMetadata, auditEvents, erasureAudits int
+	if err := databaseConnection.QueryRow(`
+		SELECT
+			(SELECT status FROM identity_subjects WHERE id = ?),
+			(SELECT credential_status FROM identity_password_credentials WHERE subject_id = ?),
+			(SELECT password_hash FROM identity_password_credentials WHERE subject_id = ?),
+			(SELECT COUNT(*) FROM identity_identifiers WHERE subject_id = ? AND normalized_value NOT LIKE 'erased-%'),
+			(SELECT COUNT(*) FROM identity_identifiers WHERE subject_id = ? AND status = '启用'),
+			(SELECT COUNT(*) FROM identity_totp_credentials WHERE subject_id = ?),
+			(SELECT COUNT(*) FROM identity_password_history WHERE subject_id = ?),
+			(SELECT COUNT(*) FROM identity_sessions WHERE subject_id = ? AND metadata <> '{}'),
+			(SELECT COUNT(*) FROM identity_audit_events WHERE target_subject_id = ?),
+			(SELECT COUNT(*) FROM identity_audit_events WHERE target_subject_id = ? AND actor_subject_id = ? AND metadata = '{"status":"已删除"}')
+	`, created.ID, created.ID, created.ID, created.ID, created.ID, created.ID, created.ID, created.ID, created.ID, created.ID, administrator.ID).Scan(
+		&subjectStatus,
+		&credentialStatus,
+		&passwordHash,
+		&identifiedValues,
+		&enabledIdentifiers,
+		&totpCredentials,
+		&passwordHistory,
+		&sessionsWithMetadata,
+		&auditEvents,
+		&erasureAudits,
+	); err != nil {
+		t.Fatalf("read erased subject state: %v", err)
+	}
+	// 行仍然保留且为禁用，审计事件的引用不受影响。
+	if subjectStatus != "禁用" || credentialStatus != "已作废" || passwordHash != "" || identifiedValues != 0 || enabledIdentifiers != 0 || totpCredentials != 0 || passwordHistory != 0 || sessionsWithMetadata != 0 || auditEvents < 6 || erasureAudits != 1 {
+		t.Fatalf("erased state = status:%q credential:%q hash:%q values:%d enabled:%d totp:%d history:%d sessions:%d audits:%d erasure_audits:%d", subjectStatus, credentialStatus, passwordHash, identifiedValues, enabledIdentifiers, totpCredentials, passwordHistory, sessionsWithMetadata, auditEvents, erasureAudits)
+	}
+
+	// 已删除的主体不能恢复，也不能重新获得数据或权限。
+	if _, err := identity.EnableSubject(ctx, databaseConnection, administrator.ID, created.ID); !errors.Is(err, identity.ErrSubjectErased) {
+		t.Fatalf("enable erased subject error = %v", err)
+	}
+	if _, err := identity.EraseSubject(ctx, databaseConnection, administrator.ID, created.ID); !errors.Is(err, identity.ErrSubjectErased) {
+		t.Fatalf("erase erased subject error = %v", err)
+	}
+	if _, err := identity.UpdateSubjectDisplayName(ctx, databaseConnection, administrator.ID, created.ID, "张三"); !errors.Is(err, identity.ErrSubjectErased) {
+		t.Fatalf("rename erased subject error = %v", err)
+	}
+	if err := identity.SetTemporaryPassword(ctx, databaseConnection, administrator.ID, created.ID, "temporary sufficiently long password", testPasswordPolicy); !errors.Is(err, identity.ErrSubjectErased) {
+		t.Fatalf("temporary password for erased subject error = %v", err)
+	}
+	if _, err := identity.GrantSubjectRole(ctx, databaseConnection, administrator.ID, created.ID, "crm.viewer"); !errors.Is(err, identity.ErrSubjectErased) {
+		t.Fatalf("grant role to erased subject error = %v", err)
+	}
+
+	// 账号标识释放后可以分配给新的主体；导出不包含已删除的主体。
+	if _, err := identity.CreateSubject(ctx, databaseConnection, administrator.ID, identity.CreateSubjectInput{
+		DisplayName: "张三",
+		Identifier:  "zhangsan",
+		Password:    "another sufficiently long password",
+	}, testPasswordPolicy); err != nil {
+		t.Fatalf("reuse erased account identifier: %v", err)
+	}
+	exported, err := identity.ExportSubjects(ctx, databaseConnection)
+	if err != nil {
+		t.Fatalf("export subjects: %v", err)
+	}
+	for _, subject := range exported {
+		if subject.ID == created.ID {
+			t.Fatalf("export contains erased subject %#v", subject)
+		}
+	}
+}
+
+func TestEraseSubjectProtectsLastEnabledAdministrator(t *testing.T) {
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+
+	if _, err := identity.EraseSubject(context.Background(), databaseConnection, administrator.ID, administrator.ID); !errors.Is(err, identity.ErrLastAdministrator) {
+		t.Fatalf("erase last administrator error = %v", err)
+	}
+	subject, err := identity.GetSubject(context.Background(), databaseConnection, administrator.ID)
+	if err != nil || subject.Status != "启用" || subject.DisplayName == identity.ErasedDisplayName {
+		t.Fatalf("administrator after refused erasure = %#v, %v", subject, err)
+	}
+}
@@ -0,0 +1,696 @@
+package identity
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database/sqlc"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/password"
+)
+
+var ErrInvalidSubjectImport = errors.New("invalid subject import")
+
+// MaximumSubjectImportRecords bounds one batch, which is written in a single
+// transaction.
+const MaximumSubjectImportRecords = 1000
+
+// GeneratePassword in place of a temporary password asks ImportSubjects to
+// generate one and return it once in the result.
+const GeneratePassword = "generate"
+
+// SubjectTransferFormats lists the encodings accepted by
+// DecodeSubjectImport and EncodeSubjectExport.
+var SubjectTransferFormats = []string{"json", "csv"}
+
+// subjectExportCSVHeader flattens identifiers into the import columns, so an
+// exported file only lacks passwords to be imported elsewhere. Usage and
+// status survive only in the JSON export.
+var subjectExportCSVHeader = []string{"id", "status", "display_name", "account", "employee_number", "email", "phone", "roles", "created_at"}
+
+type ImportSubjectRecord struct {
+	// Row numbers the record in errors: the CSV line or the position in the
+	// JSON array, counting from 1.
+	Row         int                `json:"-"`
+	DisplayName string             `json:"display_name"`
+	Identifiers []ImportIdentifier `json:"identifiers"`
+	Roles       []string           `json:"roles"`
+	// Password is a temporary password or GeneratePassword. Either way the
+	// subject has to change it at the first sign-in.
+	Password string `json:"password"`
+}
+
+// ImportIdentifier uses the field names of Identifier, so a JSON export can
+// be imported again.
+type ImportIdentifier struct {
+	Type  string `json:"identifier_type"`
+	Value string `json:"identifier_value"`
+	Usage string `json:"identifier_usage"`
+}
+
+type ImportSubjectsInput struct {
+	Records []ImportSubjectRecord
+	// DryRun runs every check, including conflicts with stored identifiers
+	// and unknown roles, and writes nothing.
+	DryRun bool
+}
+
+type ImportSubjectsResult struct {
+	DryRun bool `json:"dry_run"`
+	Valid  bool `json:"valid"`
+	// BatchID is recorded in the 主体创建 audit event of every imported
+	// subject.
+	BatchID string             `json:"batch_id,omitempty"`
+	Rows    []ImportSubjectRow `json:"rows"`
+}
+
+type ImportSubjectRow struct {
+	Row        int    `json:"row"`
+	Identifier string `json:"identifier"`
+	SubjectID  string `json:"subject_id,omitempty"`
+	// GeneratedPassword is only returned here and is never stored in
+	// plain text.
+	GeneratedPassword string   `json:"generated_password,omitempty"`
+	Errors            []string `json:"errors"`
+}
+
+// ExportedSubject is a subject with its identifiers and role assignments.
+// Credentials are never exported.
+type ExportedSubject struct {
+	ID          string       `json:"id"`
+	Status      string       `json:"status"`
+	DisplayName string       `json:"display_name"`
+	Identifiers []Identifier `json:"identifiers"`
+	Roles       []string     `json:"roles"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+type plannedIdentifier struct {
+	identifierType  string
+	value           string
+	normalizedValue string
+	usage           string
+}
+
+type plannedSubject struct {
+	displayName  string
+	identifiers  []plannedIdentifier
+	roles        []string
+	password     string
+	passwordHash string
+}
+
+// ImportSubjects creates every record in one transaction or none of them.
+// Invalid rows are reported in the result together with
+// ErrInvalidSubjectImport; a dry run reports them without the error. Each
+// subject gets the same audit events as when it is created, granted roles
+// and given identifiers one at a time, and a temporary password in the
+// 需更新 state.
+func ImportSubjects(ctx context.Context, database *sql.DB, actorSubjectID string, input ImportSubjectsInput, policy PasswordPolicy) (ImportSubjectsResult, error) {
+	if err := policy.validate(); err != nil {
+		return ImportSubjectsResult{}, fmt.Errorf("invalid password policy: %w", err)
+	}
+	if len(input.Records) == 0 || len(input.Records) > MaximumSubjectImportRecords {
+		return ImportSubjectsResult{}, fmt.Errorf("%w: a batch must contain 1 to %d records", ErrInvalidSubjectImport, MaximumSubjectImportRecords)
+	}
+
+	result := ImportSubjectsResult{DryRun: input.DryRun, Rows: make([]ImportSubjectRow, len(input.Records))}
+	planned := make([]plannedSubject, len(input.Records))
+	seenIdentifiers := map[string]int{}
+	seenLoginValues := map[string]int{}
+	valid := true
+	for index, record := range input.Records {
+		row := &result.Rows[index]
+		row.Row = record.Row
+		if row.Row == 0 {
+			row.Row = index + 1
+		}
+		subject, errs := planSubjectImport(record, policy)
+		row.Identifier = primaryImportIdentifier(subject, record)
+		for _, identifier := range subject.identifiers {
+			key := identifier.identifierType + ":" + identifier.normalizedValue
+			if previous, found := seenIdentifiers[key]; found {
+				errs = append(errs, fmt.Sprintf("%s identifier %s repeats row %d", identifier.identifierType, identifier.normalizedValue, previous))
+				continue
+			}
+			seenIdentifiers[key] = row.Row
+			if identifier.usage == "联系" {
+				continue
+			}
+			if previous, found := seenLoginValues[identifier.normalizedValue]; found {
+				errs = append(errs, fmt.Sprintf("login identifier %s repeats row %d", identifier.normalizedValue, previous))
+				continue
+			}
+			seenLoginValues[identifier.normalizedValue] = row.Row
+		}
+		row.Errors = errs
+		planned[index] = subject
+		valid = valid && len(errs) == 0
+	}
+
+	// Hashing is slow, so it happens before the transaction and only when
+	// the batch can still be written.
+	if valid && !input.DryRun {
+		for index := range planned {
+			if planned[index].password == GeneratePassword {
+				generated, err := generateTemporaryPassword(policy)
+				if err != nil {
+					return ImportSubjectsResult{}, err
+				}
+				planned[index].password = generated
+				result.Rows[index].GeneratedPassword = generated
+			}
+			passwordHash, err := password.Hash(planned[index].password)
+			if err != nil {
+				return ImportSubjectsResult{}, fmt.Errorf("hash imported password: %w", err)
+			}
+			planned[index].passwordHash = passwordHash
+		}
+	}
+
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return ImportSubjectsResult{}, fmt.Errorf("begin subject import transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	roleIDs := map[string]string{}
+	for index, subject := range planned {
+		if len(result.Rows[index].Errors) > 0 {
+			continue
+		}
+		errs, err := checkSubjectImportConflicts(ctx, transactionQueries, subject, roleIDs)
+		if err != nil {
+			return ImportSubjectsResult{}, err
+		}
+		result.Rows[index].Errors = errs
+		valid = valid && len(errs) == 0
+	}
+	result.Valid = valid
+	if !valid {
+		for index := range result.Rows {
+			result.Rows[index].GeneratedPassword = ""
+		}
+		if input.DryRun {
+			return result, nil
+		}
+		return result, ErrInvalidSubjectImport
+	}
+	if input.DryRun {
+		return result, nil
+	}
+
+	now := time.Now().UTC()
+	batchID, err := NewULID(now)
+	if err != nil {
+		return ImportSubjectsResult{}, err
+	}
+	for index, subject := range planned {
+		creationMetadata := map[string]string{"source": "导入", "batch_id": batchID}
+		subjectID, err := createImportedSubject(ctx, transactionQueries, subjectActor(actorSubjectID), creationMetadata, subject, roleIDs, now)
+		if err != nil {
+			return ImportSubjectsResult{}, err
+		}
+		result.Rows[index].SubjectID = subjectID
+	}
+	if err := transaction.Commit(); err != nil {
+		return ImportSubjectsResult{}, fmt.Errorf("commit subject import transaction: %w", err)
+	}
+	result.BatchID = batchID
+	return result, nil
+}
+
+// planSubjectImport validates one record on its own and collects every
+// problem rather than stopping at the first.
+func planSubjectImport(record ImportSubjectRecord, policy PasswordPolicy) (plannedSubject, []string) {
+	errs := []string{}
+	subject := plannedSubject{}
+	displayName, err := validateDisplayName(record.DisplayName)
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+	subject.displayName = displayName
+
+	primaryCount := 0
+	for _, identifier := range record.Identifiers {
+		if !slices.Contains(IdentifierUsages, identifier.Usage) {
+			errs = append(errs, "identifier_usage must be 主登录, 辅助登录 or 联系")
+			continue
+		}
+		normalizedValue, err := normalizeIdentifier(identifier.Type, identifier.Value)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		value := strings.TrimSpace(identifier.Value)
+		switch {
+		case identifier.Usage == "主登录" && identifier.Type != "账号":
+			errs = append(errs, "the 主登录 identifier must be a 账号 identifier")
+			continue
+		case identifier.Usage == "主登录":
+			primaryCount++
+			value = normalizedValue
+		case identifier.Usage == "辅助登录" && identifierRequiresVerification(identifier.Type):
+			errs = append(errs, fmt.Sprintf("%s identifiers are imported unverified and can only be 联系", identifier.Type))
+			continue
+		}
+		subject.identifiers = append(subject.identifiers, plannedIdentifier{
+			identifierType:  identifier.Type,
+			value:           value,
+			normalizedValue: normalizedValue,
+			usage:           identifier.Usage,
+		})
+	}
+	if primaryCount != 1 {
+		errs = append(errs, "exactly one 主登录 账号 identifier is required")
+	}
+
+	for _, roleCode := range record.Roles {
+		roleCode = strings.TrimSpace(roleCode)
+		if roleCode == "" {
+			errs = append(errs, "role codes must not be empty")
+			continue
+		}
+		if !slices.Contains(subject.roles, roleCode) {
+			subject.roles = append(subject.roles, roleCode)
+		}
+	}
+
+	subject.password = record.Password
+	if record.Password != GeneratePassword {
+		if err := (password.Policy{MinimumLength: policy.MinimumLength, Denylist: policy.Denylist}).Check(record.Password); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	return subject, errs
+}
+
+// checkSubjectImportConflicts checks a valid record against stored
+// identifiers and roles, and records the IDs of the roles it finds.
+func checkSubjectImportConflicts(ctx context.Context, queries sqlc.Querier, subject plannedSubject, roleIDs map[string]string) ([]string, error) {
+	errs := []string{}
+	for _, identifier := range subject.identifiers {
+		_, err := queries.GetIdentifierSubjectID(ctx, sqlc.GetIdentifierSubjectIDParams{
+			IdentifierType:  identifier.identifierType,
+			NormalizedValue: identifier.normalizedValue,
+		})
+		if err == nil {
+			errs = append(errs, fmt.Sprintf("%s identifier %s is already in use", identifier.identifierType, identifier.normalizedValue))
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("check imported identifier: %w", err)
+		}
+		if identifier.usage == "联系" {
+			continue
+		}
+		err = ensureLoginIdentifierAvailable(ctx, queries, identifier.normalizedValue, "")
+		if errors.Is(err, ErrIdentifierAlreadyExists) {
+			errs = append(errs, fmt.Sprintf("login identifier %s is already in use", identifier.normalizedValue))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, roleCode := range subject.roles {
+		if _, found := roleIDs[roleCode]; found {
+			continue
+		}
+		role, err := queries.GetRoleByCode(ctx, roleCode)
+		if errors.Is(err, sql.ErrNoRows) {
+			errs = append(errs, fmt.Sprintf("role %s does not exist", roleCode))
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get imported role: %w", err)
+		}
+		roleIDs[roleCode] = role.ID
+	}
+	return errs, nil
+}
+
+// createImportedSubject writes a planned subject and its audit events; the
+// 主体创建 event carries creationMetadata.
+func createImportedSubject(ctx context.Context, queries sqlc.Querier, actor auditActor, creationMetadata map[string]string, subject plannedSubject, roleIDs map[string]string, now time.Time) (string, error) {
+	subjectID, err := NewULID(now)
+	if err != nil {
+		return "", err
+	}
+	if err := queries.CreateSubject(ctx, sqlc.CreateSubjectParams{
+		ID:              subjectID,
+		Status:          "启用",
+		SecurityVersion: 1,
+		DisabledAt:      sql.NullTime{},
+		Metadata:        "{}",
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}); err != nil {
+		return "", fmt.Errorf("create imported subject: %w", err)
+	}
+	if err := queries.CreateProfile(ctx, sqlc.CreateProfileParams{
+		SubjectID:   subjectID,
+		DisplayName: subject.displayName,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}); err != nil {
+		return "", fmt.Errorf("create imported subject profile: %w", err)
+	}
+	auditEventID, err := NewULID(now)
+	if err != nil {
+		return "", err
+	}
+	metadata, err := json.Marshal(actor.annotate(creationMetadata))
+	if err != nil {
+		return "", fmt.Errorf("encode subject import audit metadata: %w", err)
+	}
+	if err := queries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
+		ID:              auditEventID,
+		EventAction:     "主体创建",
+		Outcome:         "成功",
+		ActorSubjectID:  actor.column(),
+		TargetSubjectID: sql.NullString{String: subjectID, Valid: true},
+		RequestID:       sql.NullString{},
+		SourceHash:      nil,
+		Metadata:        string(metadata),
+		CreatedAt:       now,
+	}); err != nil {
+		return "", fmt.Errorf("write subject import audit event: %w", err)
+	}
+
+	for _, identifier := range subject.identifiers {
+		identifierID, err := NewULID(now)
+		if err != nil {
+			return "", err
+		}
+		record := sqlc.IdentityIdentifier{
+			ID:              identifierID,
+			SubjectID:       subjectID,
+			IdentifierType:  identifier.identifierType,
+			IdentifierValue: identifier.value,
+			NormalizedValue: identifier.normalizedValue,
+			IdentifierUsage: identifier.usage,
+			Status:          "启用",
+			VerifiedAt:      sql.NullTime{},
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		}
+		if err := queries.CreateIdentifier(ctx, sqlc.CreateIdentifierParams{
+			ID:              record.ID,
+			SubjectID:       record.SubjectID,
+			IdentifierType:  record.IdentifierType,
+			IdentifierValue: record.IdentifierValue,
+			NormalizedValue: record.NormalizedValue,
+			IdentifierUsage: record.IdentifierUsage,
+			Status:          record.Status,
+			VerifiedAt:      record.VerifiedAt,
+			CreatedAt:       record.CreatedAt,
+			UpdatedAt:       record.UpdatedAt,
+		}); err != nil {
+			return "", fmt.Errorf("create imported identifier: %w", err)
+		}
+		// As with CreateSubject, the primary account is covered by the
+		// 主体创建 event.
+		if identifier.usage == "主登录" {
+			continue
+		}
+		if err := insertIdentifierAuditEvent(ctx, queries, actor, record, "创建", now); err != nil {
+			return "", err
+		}
+	}
+
+	credentialID, err := NewULID(now)
+	if err != nil {
+		return "", err
+	}
+	if err := queries.CreatePasswordCredential(ctx, sqlc.CreatePasswordCredentialParams{
+		ID:               credentialID,
+		SubjectID:        subjectID,
+		PasswordHash:     subject.passwordHash,
+		PasswordRevision: 1,
+		CredentialStatus: "需更新",
+		ChangedAt:        now,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}); err != nil {
+		return "", fmt.Errorf("create imported password credential: %w", err)
+	}
+
+	for _, roleCode := range subject.roles {
+		assignmentID, err := NewULID(now)
+		if err != nil {
+			return "", err
+		}
+		if err := queries.AssignSubjectRole(ctx, sqlc.AssignSubjectRoleParams{
+			ID:                 assignmentID,
+			SubjectID:          subjectID,
+			RoleID:             roleIDs[roleCode],
+			GrantedBySubjectID: actor.column(),
+			CreatedAt:          now,
+		}); err != nil {
+			return "", fmt.Errorf("assign imported subject role: %w", err)
+		}
+		if err := insertRoleAuditEvent(ctx, queries, "角色授予", actor, subjectID, roleCode, now); err != nil {
+			return "", err
+		}
+	}
+	return subjectID, nil
+}
+
+// ExportSubjects reads every subject that has not been erased, newest first,
+// from one snapshot.
+func ExportSubjects(ctx context.Context, database *sql.DB) ([]ExportedSubject, error) {
+	const pageSize = 500
+	queries := sqlc.New(database)
+	transaction, err := database.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("begin subject export transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	subjects := []ExportedSubject{}
+	for offset := int64(0); ; offset += pageSize {
+		rows, err := transactionQueries.ListSubjectsForManagement(ctx, sqlc.ListSubjectsForManagementParams{
+			IdentifierUsage: "主登录",
+			Limit:           pageSize,
+			Offset:          offset,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list subjects for export: %w", err)
+		}
+		for _, row := range rows {
+			// Erased subjects carry only placeholders and cannot be imported.
+			if row.ErasedAt.Valid {
+				continue
+			}
+			records, err := transactionQueries.ListSubjectIdentifiers(ctx, row.ID)
+			if err != nil {
+				return nil, fmt.Errorf("list subject identifiers for export: %w", err)
+			}
+			identifiers := make([]Identifier, 0, len(records))
+			for _, record := range records {
+				identifiers = append(identifiers, identifierFromRecord(record))
+			}
+			roles, err := transactionQueries.ListRoleCodesBySubjectID(ctx, row.ID)
+			if err != nil {
+				return nil, fmt.Errorf("list subject roles for export: %w", err)
+			}
+			if roles == nil {
+				roles = []string{}
+			}
+			subjects = append(subjects, ExportedSubject{
+				ID:          row.ID,
+				Status:      row.Status,
+				DisplayName: row.DisplayName,
+				Identifiers: identifiers,
+				Roles:       roles,
+				CreatedAt:   row.CreatedAt,
+				UpdatedAt:   row.UpdatedAt,
+			})
+		}
+		if len(rows) < pageSize {
+			break
+		}
+	}
+	if err := transaction.Commit(); err != nil {
+		return nil, fmt.Errorf("commit subject export transaction: %w", err)
+	}
+	return subjects, nil
+}
+
+// DecodeSubjectImport reads a JSON array of ImportSubjectRecord values or a
+// CSV file with a header row. CSV columns outside the import set, such as
+// those of an export, are ignored, and a missing password column leaves
+// every row to fail validation.
+func DecodeSubjectImport(format string, reader io.Reader) ([]ImportSubjectRecord, error) {
+	switch format {
+	case "json":
+		var records []ImportSubjectRecord
+		decoder := json.NewDecoder(reader)
+		if err := decoder.Decode(&records); err != nil {
+			return nil, fmt.Errorf("%w: decode JSON: %v", ErrInvalidSubjectImport, err)
+		}
+		if decoder.More() {
+			return nil, fmt.Errorf("%w: the JSON body must contain one array", ErrInvalidSubjectImport)
+		}
+		for index := range records {
+			records[index].Row = index + 1
+		}
+		return records, nil
+	case "csv":
+		return decodeSubjectImportCSV(reader)
+	}
+	return nil, fmt.Errorf("%w: format must be json or csv", ErrInvalidSubjectImport)
+}
+
+// decodeSubjectImportCSV reads the columns display_name, account,
+// employee_number, email, phone, roles and password. Several values in one
+// cell are separated by ';'. The first account is the 主登录 identifier,
+// further accounts and employee numbers are 辅助登录, and email addresses and
+// phone numbers are 联系 because they are not verified yet.
+func decodeSubjectImportCSV(reader io.Reader) ([]ImportSubjectRecord, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: read CSV header: %v", ErrInvalidSubjectImport, err)
+	}
+	columns := map[string]int{}
+	for index, name := range header {
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = index
+	}
+	for _, required := range []string{"display_name", "account"} {
+		if _, found := columns[required]; !found {
+			return nil, fmt.Errorf("%w: CSV header must include %s", ErrInvalidSubjectImport, required)
+		}
+	}
+
+	records := []ImportSubjectRecord{}
+	for {
+		fields, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: read CSV: %v", ErrInvalidSubjectImport, err)
+		}
+		line, _ := csvReader.FieldPos(0)
+		cell := func(name string) string {
+			index, found := columns[name]
+			if !found || index >= len(fields) {
+				return ""
+			}
+			return fields[index]
+		}
+		record := ImportSubjectRecord{
+			Row:         line,
+			DisplayName: cell("display_name"),
+			Identifiers: []ImportIdentifier{},
+			Roles:       splitTransferCell(cell("roles")),
+			Password:    cell("password"),
+		}
+		for index, value := range splitTransferCell(cell("account")) {
+			usage := "辅助登录"
+			if index == 0 {
+				usage = "主登录"
+			}
+			record.Identifiers = append(record.Identifiers, ImportIdentifier{Type: "账号", Value: value, Usage: usage})
+		}
+		for _, column := range []struct{ name, identifierType, usage string }{
+			{"employee_number", "工号", "辅助登录"},
+			{"email", "邮箱", "联系"},
+			{"phone", "手机号", "联系"},
+		} {
+			for _, value := range splitTransferCell(cell(column.name)) {
+				record.Identifiers = append(record.Identifiers, ImportIdentifier{Type: column.identifierType, Value: value, Usage: column.usage})
+			}
+		}
+		records = append(records, record)
+	}
+}
+
+// EncodeSubjectExport writes subjects as a JSON array or as CSV with the
+// columns of subjectExportCSVHeader.
+func EncodeSubjectExport(format string, writer io.Writer, subjects []ExportedSubject) error {
+	switch format {
+	case "json":
+		return json.NewEncoder(writer).Encode(subjects)
+	case "csv":
+		csvWriter := csv.NewWriter(writer)
+		_ = csvWriter.Write(subjectExportCSVHeader)
+		for _, subject := range subjects {
+			values := map[string][]string{}
+			// The primary identifier comes first so that an account column
+			// imports with the same primary.
+			for _, primary := range []bool{true, false} {
+				for _, identifier := range subject.Identifiers {
+					if (identifier.Usage == "主登录") == primary {
+						values[identifier.Type] = append(values[identifier.Type], identifier.Value)
+					}
+				}
+			}
+			_ = csvWriter.Write([]string{
+				subject.ID,
+				subject.Status,
+				subject.DisplayName,
+				strings.Join(values["账号"], ";"),
+				strings.Join(values["工号"], ";"),
+				strings.Join(values["邮箱"], ";"),
+				strings.Join(values["手机号"], ";"),
+				strings.Join(subject.Roles, ";"),
+				subject.CreatedAt.UTC().Format(time.RFC3339Nano),
+			})
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+	return fmt.Errorf("format must be json or csv")
+}
+
+func splitTransferCell(value string) []string {
+	values := []string{}
+	for _, part := range strings.Split(value, ";") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+func primaryImportIdentifier(subject plannedSubject, record ImportSubjectRecord) string {
+	for _, identifier := range subject.identifiers {
+		if identifier.usage == "主登录" {
+			return identifier.value
+		}
+	}
+	for _, identifier := range record.Identifiers {
+		if identifier.Usage == "主登录" {
+			return strings.TrimSpace(identifier.Value)
+		}
+	}
+	return ""
+}
+
+// generateTemporaryPassword returns a random URL-safe password at least as
+// long as the policy requires.
+func generateTemporaryPassword(policy PasswordPolicy) (string, error) {
+	length := max(policy.MinimumLength, 24)
+	value := make([]byte, (length*3+3)/4)
+	if _, err := rand.Read(value); err != nil {
+		return "", fmt.Errorf("read generated password: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(value), nil
+}
@@ -0,0 +1,167 @@
+package identity_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/password"
+)
+
+const subjectImportCSV = `display_name,account,employee_number,email,phone,roles,password
+张三,zhangsan,E-1001,zhangsan@example.test,13800000001,identity.audit.read,a sufficiently long password
+李四,lisi;lisi.alt,,,,,generate
+`
+
+func TestImportSubjectsCreatesBatchWithAuditEvents(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	records, err := identity.DecodeSubjectImport("csv", strings.NewReader(subjectImportCSV))
+	if err != nil {
+		t.Fatalf("decode CSV: %v", err)
+	}
+	if len(records) != 2 || records[0].Row != 2 || len(records[0].Identifiers) != 4 || records[1].Identifiers[1].Usage != "辅助登录" {
+		t.Fatalf("records = %#v", records)
+	}
+
+	// 预检不写入任何数据，也不生成密码。
+	dryRun, err := identity.ImportSubjects(ctx, databaseConnection, administrator.ID, identity.ImportSubjectsInput{Records: records, DryRun: true}, testPasswordPolicy)
+	if err != nil || !dryRun.Valid || dryRun.Rows[1].GeneratedPassword != "" || dryRun.Rows[0].SubjectID != "" {
+		t.Fatalf("dry run = %#v, %v", dryRun, err)
+	}
+	if listed, _ := identity.ListSubjects(ctx, databaseConnection, identity.ListSubjectsInput{Limit: 10}); listed.Total != 1 {
+		t.Fatalf("subjects after dry run = %d", listed.Total)
+	}
+
+	result, err := identity.ImportSubjects(ctx, databaseConnection, administrator.ID, identity.ImportSubjectsInput{Records: records}, testPasswordPolicy)
+	if err != nil || !result.Valid || result.BatchID == "" {
+		t.Fatalf("import = %#v, %v", result, err)
+	}
+	generated := result.Rows[1].GeneratedPassword
+	if result.Rows[0].GeneratedPassword != "" || len(generated) < 24 {
+		t.Fatalf("generated passwords = %q, %q", result.Rows[0].GeneratedPassword, generated)
+	}
+	subject, err := identity.GetSubject(ctx, databaseConnection, result.Rows[0].SubjectID)
+	if err != nil || subject.Identifier != "zhangsan" || len(subject.Roles) != 1 || subject.Roles[0] != "identity.audit.read" {
+		t.Fatalf("imported subject = %#v, %v", subject, err)
+	}
+	// 导入的密码都是临时密码，辅助账号也能登录。
+	login, err := loginWithSource(ctx, databaseConnection, "lisi.alt", generated, "192.0.2.1")
+	if err != nil || login.Access != "仅改密" {
+		t.Fatalf("login with generated password = %#v, %v", login, err)
+	}
+	var creations, identifierChanges, roleGrants int
+	if err := databaseConnection.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM identity_audit_events WHERE event_action = '主体创建' AND metadata LIKE '%' || ? || '%'),
+			(SELECT COUNT(*) FROM identity_audit_events WHERE event_action = '标识符变更'),
+			(SELECT COUNT(*) FROM identity_audit_events WHERE event_action = '角色授予' AND target_subject_id = ?)
+	`, result.BatchID, result.Rows[0].SubjectID).Scan(&creations, &identifierChanges, &roleGrants); err != nil {
+		t.Fatalf("count audit events: %v", err)
+	}
+	if creations != 2 || identifierChanges != 4 || roleGrants != 1 {
+		t.Fatalf("audit events = %d creations, %d identifier changes, %d role grants", creations, identifierChanges, roleGrants)
+	}
+}
+
+func TestImportSubjectsReportsEveryInvalidRowAndWritesNothing(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	policy := identity.PasswordPolicy{Denylist: password.CommonPasswords()}
+	records := []identity.ImportSubjectRecord{
+		{
+			DisplayName: "王五",
+			Identifiers: []identity.ImportIdentifier{{Type: "账号", Value: "wangwu", Usage: "主登录"}},
+			Password:    identity.GeneratePassword,
+		},
+		{
+			DisplayName: "管理员",
+			Identifiers: []identity.ImportIdentifier{{Type: "账号", Value: "admin", Usage: "主登录"}},
+			Roles:       []string{"museum.guide"},
+			Password:    "passwordpassword",
+		},
+		{
+			DisplayName: "",
+			Identifiers: []identity.ImportIdentifier{
+				{Type: "账号", Value: "WangWu", Usage: "主登录"},
+				{Type: "邮箱", Value: "wangwu@example.test", Usage: "辅助登录"},
+			},
+			Password: identity.GeneratePassword,
+		},
+	}
+
+	result, err := identity.ImportSubjects(ctx, databaseConnection, administrator.ID, identity.ImportSubjectsInput{Records: records}, policy)
+	if !errors.Is(err, identity.ErrInvalidSubjectImport) || result.Valid {
+		t.Fatalf("import error = %v, result = %#v", err, result)
+	}
+	if len(result.Rows[0].Errors) != 0 || result.Rows[0].GeneratedPassword != "" {
+		t.Fatalf("valid row = %#v", result.Rows[0])
+	}
+	// 第二行：账号已存在、角色不存在；第三行：显示名为空、邮箱未验证、与第一行重复。
+	if len(result.Rows[1].Errors) != 1 || !strings.Contains(result.Rows[1].Errors[0], "too common") {
+		t.Fatalf("row 2 errors = %q", result.Rows[1].Errors)
+	}
+	if len(result.Rows[2].Errors) != 3 || !strings.Contains(strings.Join(result.Rows[2].Errors, "\n"), "repeats row 1") {
+		t.Fatalf("row 3 errors = %q", result.Rows[2].Errors)
+	}
+	if listed, _ := identity.ListSubjects(ctx, databaseConnection, identity.ListSubjectsInput{Limit: 10}); listed.Total != 1 {
+		t.Fatalf("subjects after rejected import = %d", listed.Total)
+	}
+
+	// 预检会继续检查数据库中的冲突。
+	records[1].Password = "a sufficiently long password"
+	dryRun, err := identity.ImportSubjects(ctx, databaseConnection, administrator.ID, identity.ImportSubjectsInput{Records: records[:2], DryRun: true}, policy)
+	if err != nil || dryRun.Valid || len(dryRun.Rows[1].Errors) != 2 {
+		t.Fatalf("dry run = %#v, %v", dryRun, err)
+	}
+	if _, err := identity.ImportSubjects(ctx, databaseConnection, administrator.ID, identity.ImportSubjectsInput{}, policy); !errors.Is(err, identity.ErrInvalidSubjectImport) {
+		t.Fatalf("empty import error = %v", err)
+	}
+}
+
+func TestExportSubjectsOmitsCredentials(t *testing.T) {
+	ctx := context.Background()
+	databaseConnection := migratedDatabase(t)
+	administrator := bootstrapAdministrator(t, databaseConnection)
+	records, err := identity.DecodeSubjectImport("csv", strings.NewReader(subjectImportCSV))
+	if err != nil {
+		t.Fatalf("decode CSV: %v", err)
+	}
+	if _, err := identity.ImportSubjects(ctx, databaseConnection, administrator.ID, identity.ImportSubjectsInput{Records: records}, testPasswordPolicy); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+
+	subjects, err := identity.ExportSubjects(ctx, databaseConnection)
+	if err != nil || len(subjects) != 3 {
+		t.Fatalf("export = %d subjects, %v", len(subjects), err)
+	}
+	var exported *identity.ExportedSubject
+	for index := range subjects {
+		if subjects[index].DisplayName == "张三" {
+			exported = &subjects[index]
+		}
+	}
+	if exported == nil || len(exported.Identifiers) != 4 || len(exported.Roles) != 1 {
+		t.Fatalf("exported subject = %#v", exported)
+	}
+
+	for _, format := range identity.SubjectTransferFormats {
+		var output bytes.Buffer
+		if err := identity.EncodeSubjectExport(format, &output, subjects); err != nil {
+			t.Fatalf("encode %s: %v", format, err)
+		}
+		if strings.Contains(output.String(), "argon2id") || !strings.Contains(output.String(), "zhangsan@example.test") {
+			t.Fatalf("%s export = %s", format, output.String())
+		}
+		// 导出文件可以再次解析为导入记录。
+		decoded, err := identity.DecodeSubjectImport(format, &output)
+		if err != nil || len(decoded) != 3 {
+			t.Fatalf("decode %s export = %d records, %v", format, len(decoded), err)
+		}
+	}
+}
@@ -96,12 +96,45 @@ func recordLoginFailure(ctx context.Context, database *sql.DB, settings LoginThr
 }
 
 func recordLoginFailureInTransaction(ctx context.Context, queries sqlc.Querier, settings LoginThrottleSettings, key loginThrottleKey, now time.Time) error {
+	locked, err := countLoginThrottleAttempt(ctx, queries, settings, key, now)
+	if err != nil {
+		return err
+	}
+
+	auditID, err := NewULID(now)
+	if err != nil {
+		return err
+	}
+	metadata := `{"reason":"invalid_credentials"}`
+	if locked {
+		metadata = `{"reason":"throttled"}`
+	}
+	if err := queries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
+		ID:              auditID,
+		EventAction:     "登录",
+		Outcome:         "失败",
+		ActorSubjectID:  sql.NullString{},
+		TargetSubjectID: sql.NullString{},
+		RequestID:       sql.NullString{},
+		SourceHash:      key.sourceHash,
+		Metadata:        metadata,
+		CreatedAt:       now,
+	}); err != nil {
+		return fmt.Errorf("write failed login audit event: %w", err)
+	}
+	return nil
+}
+
+// countLoginThrottleAttempt adds one attempt to the throttle window of key
+// and starts a lockout at the failure limit. It reports whether key was
+// already locked, in which case the attempt is not counted.
+func countLoginThrottleAttempt(ctx context.Context, queries sqlc.Querier, settings LoginThrottleSettings, key loginThrottleKey, now time.Time) (bool, error) {
 	throttle, err := queries.GetLoginThrottle(ctx, sqlc.GetLoginThrottleParams{
 		IdentifierHash: key.identifierHash,
 		SourceHash:     key.sourceHash,
 	})
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return fmt.Errorf("load login throttle for failure: %w", err)
+		return false, fmt.Errorf("load login throttle for failure: %w", err)
 	}
 
 	locked := err == nil && isLoginThrottleLocked(throttle, now)
@@ -119,7 +152,7 @@ func recordLoginFailureInTransaction(ctx context.Context, queries sqlc.Querier,
 		}
 		throttleID, err := NewULID(now)
 		if err != nil {
-			return err
+			return false, err
 		}
 		if err := queries.UpsertLoginThrottle(ctx, sqlc.UpsertLoginThrottleParams{
 			ID:              throttleID,
@@ -130,30 +163,8 @@ func recordLoginFailureInTransaction(ctx context.Context, queries sqlc.Querier,
 			LockedUntil:     lockedUntil,
 			UpdatedAt:       now,
 		}); err != nil {
-			return fmt.Errorf("upsert login throttle: %w", err)
+			return false, fmt.Errorf("upsert login throttle: %w", err)
 		}
 	}
-
-	auditID, err := NewULID(now)
-	if err != nil {
-		return err
-	}
-	metadata := `{"reason":"invalid_credentials"}`
-	if locked {
-		metadata = `{"reason":"throttled"}`
-	}
-	if err := queries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
-		ID:              auditID,
-		EventAction:     "登录",
-		Outcome:         "失败",
-		ActorSubjectID:  sql.NullString{},
-		TargetSubjectID: sql.NullString{},
-		RequestID:       sql.NullString{},
-		SourceHash:      key.sourceHash,
-		Metadata:        metadata,
-		CreatedAt:       now,
-	}); err != nil {
-		return fmt.Errorf("write failed login audit event: %w", err)
-	}
-	return nil
+	return locked, nil
 }
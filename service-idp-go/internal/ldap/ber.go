@@ -0,0 +1,152 @@
+package ldap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// The subset of BER that LDAPv3 messages need: definite lengths and
+// single-byte tags.
+const (
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x30
+	tagSet         = 0x31
+)
+
+// maximumMessageLength bounds a single message from the server so that a
+// broken or hostile directory cannot make identityd allocate without limit.
+const maximumMessageLength = 1 << 20
+
+type element struct {
+	tag      byte
+	contents []byte
+}
+
+func encodeElement(tag byte, contents ...[]byte) []byte {
+	length := 0
+	for _, content := range contents {
+		length += len(content)
+	}
+	encoded := append([]byte{tag}, encodeLength(length)...)
+	for _, content := range contents {
+		encoded = append(encoded, content...)
+	}
+	return encoded
+}
+
+func encodeLength(length int) []byte {
+	if length < 0x80 {
+		return []byte{byte(length)}
+	}
+	var octets []byte
+	for value := length; value > 0; value >>= 8 {
+		octets = append([]byte{byte(value)}, octets...)
+	}
+	return append([]byte{0x80 | byte(len(octets))}, octets...)
+}
+
+func encodeInteger(tag byte, value int) []byte {
+	octets := []byte{byte(value)}
+	for value >>= 8; value > 0; value >>= 8 {
+		octets = append([]byte{byte(value)}, octets...)
+	}
+	// A leading bit would make the two's complement value negative.
+	if octets[0]&0x80 != 0 {
+		octets = append([]byte{0}, octets...)
+	}
+	return encodeElement(tag, octets)
+}
+
+func encodeString(tag byte, value string) []byte {
+	return encodeElement(tag, []byte(value))
+}
+
+func encodeBoolean(value bool) []byte {
+	if value {
+		return encodeElement(tagBoolean, []byte{0xff})
+	}
+	return encodeElement(tagBoolean, []byte{0})
+}
+
+func readElement(reader *bufio.Reader) (element, error) {
+	tag, err := reader.ReadByte()
+	if err != nil {
+		return element{}, err
+	}
+	first, err := reader.ReadByte()
+	if err != nil {
+		return element{}, err
+	}
+	length := int(first)
+	if first&0x80 != 0 {
+		octets := int(first &^ 0x80)
+		if octets == 0 || octets > 4 {
+			return element{}, fmt.Errorf("unsupported BER length encoding")
+		}
+		length = 0
+		for range octets {
+			next, err := reader.ReadByte()
+			if err != nil {
+				return element{}, err
+			}
+			length = length<<8 | int(next)
+		}
+	}
+	if length > maximumMessageLength {
+		return element{}, fmt.Errorf("BER element of %d bytes exceeds the limit", length)
+	}
+	contents := make([]byte, length)
+	if _, err := io.ReadFull(reader, contents); err != nil {
+		return element{}, err
+	}
+	return element{tag: tag, contents: contents}, nil
+}
+
+// children splits constructed contents into their elements.
+func (parent element) children() ([]element, error) {
+	var elements []element
+	remaining := parent.contents
+	for len(remaining) > 0 {
+		if len(remaining) < 2 {
+			return nil, fmt.Errorf("truncated BER element")
+		}
+		tag := remaining[0]
+		length := int(remaining[1])
+		offset := 2
+		if remaining[1]&0x80 != 0 {
+			octets := int(remaining[1] &^ 0x80)
+			if octets == 0 || octets > 4 || len(remaining) < 2+octets {
+				return nil, fmt.Errorf("unsupported BER length encoding")
+			}
+			length = 0
+			for _, next := range remaining[2 : 2+octets] {
+				length = length<<8 | int(next)
+			}
+			offset += octets
+		}
+		if length < 0 || len(remaining) < offset+length {
+			return nil, fmt.Errorf("truncated BER element")
+		}
+		elements = append(elements, element{tag: tag, contents: remaining[offset : offset+length]})
+		remaining = remaining[offset+length:]
+	}
+	return elements, nil
+}
+
+func (parent element) integer() (int, error) {
+	if len(parent.contents) == 0 || len(parent.contents) > 4 {
+		return 0, fmt.Errorf("unsupported BER integer")
+	}
+	value := 0
+	if parent.contents[0]&0x80 != 0 {
+		value = -1
+	}
+	for _, octet := range parent.contents {
+		value = value<<8 | int(octet)
+	}
+	return value, nil
+}
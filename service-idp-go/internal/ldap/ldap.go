@@ -0,0 +1,459 @@
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// LDAPv3 protocol operations and the result codes identityd acts on.
+const (
+	applicationBindRequest      = 0x60
+	applicationBindResponse     = 0x61
+	applicationUnbindRequest    = 0x42
+	applicationSearchRequest    = 0x63
+	applicationSearchEntry      = 0x64
+	applicationSearchDone       = 0x65
+	applicationSearchReference  = 0x73
+	applicationExtendedRequest  = 0x77
+	applicationExtendedResponse = 0x78
+
+	contextSimpleAuthentication = 0x80
+	contextEqualityMatch        = 0xa3
+	contextExtendedRequestName  = 0x80
+
+	resultSuccess            = 0
+	resultSizeLimitExceeded  = 4
+	resultInvalidCredentials = 49
+)
+
+const (
+	protocolVersion   = 3
+	scopeWholeSubtree = 2
+	derefAliasesNever = 0
+	searchSizeLimit   = 2
+
+	defaultUserAttribute        = "userPrincipalName"
+	defaultDisplayNameAttribute = "displayName"
+	defaultGroupAttribute       = "memberOf"
+
+	diagnosticMaximumLength = 200
+
+	// startTLSOID names the StartTLS extended operation (RFC 4511 section
+	// 4.14).
+	startTLSOID = "1.3.6.1.4.1.1466.20037"
+)
+
+type Config struct {
+	// URL is ldap://host[:port] or ldaps://host[:port].
+	URL          string
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// UserAttribute is matched against the login name; it defaults to
+	// userPrincipalName, which is what Active Directory users type.
+	UserAttribute        string
+	DisplayNameAttribute string
+	GroupAttribute       string
+	Timeout              time.Duration
+	// TLSConfig is used for ldaps:// URLs and for StartTLS on ldap:// URLs;
+	// nil verifies the server against the system roots.
+	TLSConfig *tls.Config
+	// InsecurePlaintext skips StartTLS on ldap:// URLs, so the service
+	// account and user passwords cross the network in the clear. It is
+	// meant for test directories only.
+	InsecurePlaintext bool
+}
+
+// Authenticator checks passwords against an LDAP directory such as Active
+// Directory. Each call opens its own connection: a service account bind
+// finds the user's entry, then a bind as that entry checks the password.
+// The directory's answer is never cached.
+type Authenticator struct {
+	address  string
+	useTLS   bool
+	startTLS bool
+	config   Config
+}
+
+// New validates config and returns an authenticator for it.
+func New(config Config) (*Authenticator, error) {
+	parsed, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse LDAP URL: %w", err)
+	}
+	authenticator := &Authenticator{config: config}
+	switch parsed.Scheme {
+	case "ldap":
+		authenticator.address = hostPort(parsed.Host, "389")
+		authenticator.startTLS = !config.InsecurePlaintext
+	case "ldaps":
+		authenticator.address = hostPort(parsed.Host, "636")
+		authenticator.useTLS = true
+	default:
+		return nil, fmt.Errorf("LDAP URL must use ldap:// or ldaps://")
+	}
+	if parsed.Hostname() == "" {
+		return nil, fmt.Errorf("LDAP URL must include a host")
+	}
+	if config.BaseDN == "" {
+		return nil, fmt.Errorf("LDAP base DN is required")
+	}
+	if authenticator.config.UserAttribute == "" {
+		authenticator.config.UserAttribute = defaultUserAttribute
+	}
+	if authenticator.config.DisplayNameAttribute == "" {
+		authenticator.config.DisplayNameAttribute = defaultDisplayNameAttribute
+	}
+	if authenticator.config.GroupAttribute == "" {
+		authenticator.config.GroupAttribute = defaultGroupAttribute
+	}
+	if authenticator.config.Timeout <= 0 {
+		authenticator.config.Timeout = defaultTimeout
+	}
+	return authenticator, nil
+}
+
+func hostPort(host string, defaultPort string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), defaultPort)
+}
+
+// Authenticate returns identity.ErrInvalidCredentials when the directory
+// has no single entry for username or rejects password, and another error
+// when the directory cannot give an answer.
+func (authenticator *Authenticator) Authenticate(ctx context.Context, username string, password string) (identity.DirectoryAccount, error) {
+	// A simple bind with an empty password is an unauthenticated bind, which
+	// many servers accept for any DN.
+	if username == "" || password == "" {
+		return identity.DirectoryAccount{}, identity.ErrInvalidCredentials
+	}
+	session, err := authenticator.dial(ctx)
+	if err != nil {
+		return identity.DirectoryAccount{}, err
+	}
+	defer session.close()
+
+	if err := session.bind(authenticator.config.BindDN, authenticator.config.BindPassword); err != nil {
+		return identity.DirectoryAccount{}, fmt.Errorf("bind LDAP service account: %w", err)
+	}
+	entries, err := session.search(
+		authenticator.config.BaseDN,
+		authenticator.config.UserAttribute,
+		username,
+		[]string{authenticator.config.DisplayNameAttribute, authenticator.config.GroupAttribute},
+	)
+	if err != nil {
+		return identity.DirectoryAccount{}, fmt.Errorf("search LDAP user: %w", err)
+	}
+	if len(entries) == 0 {
+		return identity.DirectoryAccount{}, identity.ErrInvalidCredentials
+	}
+	if len(entries) > 1 {
+		return identity.DirectoryAccount{}, fmt.Errorf("search LDAP user: %s matches more than one entry", authenticator.config.UserAttribute)
+	}
+	entry := entries[0]
+	if err := session.bind(entry.dn, password); err != nil {
+		var result *resultError
+		if errors.As(err, &result) && result.code == resultInvalidCredentials {
+			return identity.DirectoryAccount{}, identity.ErrInvalidCredentials
+		}
+		return identity.DirectoryAccount{}, fmt.Errorf("bind LDAP user: %w", err)
+	}
+
+	account := identity.DirectoryAccount{Groups: []string{}}
+	for attribute, values := range entry.attributes {
+		switch {
+		case strings.EqualFold(attribute, authenticator.config.DisplayNameAttribute) && len(values) > 0:
+			account.DisplayName = values[0]
+		case strings.EqualFold(attribute, authenticator.config.GroupAttribute):
+			account.Groups = append(account.Groups, values...)
+		}
+	}
+	return account, nil
+}
+
+func (authenticator *Authenticator) dial(ctx context.Context) (*session, error) {
+	deadline := time.Now().Add(authenticator.config.Timeout)
+	if contextDeadline, ok := ctx.Deadline(); ok && contextDeadline.Before(deadline) {
+		deadline = contextDeadline
+	}
+	dialer := &net.Dialer{Deadline: deadline}
+	var conn net.Conn
+	var err error
+	if authenticator.useTLS {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: authenticator.config.TLSConfig}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", authenticator.address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", authenticator.address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connect to LDAP server: %w", err)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("set LDAP deadline: %w", err)
+	}
+	session := &session{conn: conn, reader: bufio.NewReader(conn)}
+	if authenticator.startTLS {
+		if err := session.startTLS(ctx, authenticator.tlsConfig()); err != nil {
+			session.conn.Close()
+			return nil, fmt.Errorf("start LDAP TLS: %w", err)
+		}
+	}
+	return session, nil
+}
+
+// tlsConfig returns the configuration for StartTLS, which unlike
+// tls.Dialer does not infer the server name from the address.
+func (authenticator *Authenticator) tlsConfig() *tls.Config {
+	config := &tls.Config{}
+	if authenticator.config.TLSConfig != nil {
+		config = authenticator.config.TLSConfig.Clone()
+	}
+	if config.ServerName == "" {
+		host, _, _ := net.SplitHostPort(authenticator.address)
+		config.ServerName = host
+	}
+	return config
+}
+
+type resultError struct {
+	code       int
+	diagnostic string
+}
+
+func (err *resultError) Error() string {
+	if err.diagnostic == "" {
+		return fmt.Sprintf("LDAP result code %d", err.code)
+	}
+	return fmt.Sprintf("LDAP result code %d: %s", err.code, err.diagnostic)
+}
+
+type searchEntry struct {
+	dn         string
+	attributes map[string][]string
+}
+
+type session struct {
+	conn      net.Conn
+	reader    *bufio.Reader
+	messageID int
+}
+
+func (session *session) close() {
+	session.messageID++
+	// The unbind is a courtesy; the connection closes either way.
+	_, _ = session.conn.Write(encodeElement(tagSequence, encodeInteger(tagInteger, session.messageID), encodeElement(applicationUnbindRequest)))
+	session.conn.Close()
+}
+
+func (session *session) send(operation []byte) (int, error) {
+	session.messageID++
+	if _, err := session.conn.Write(encodeElement(tagSequence, encodeInteger(tagInteger, session.messageID), operation)); err != nil {
+		return 0, fmt.Errorf("write LDAP request: %w", err)
+	}
+	return session.messageID, nil
+}
+
+// receive returns the protocol operation of the next message for messageID.
+func (session *session) receive(messageID int) (element, error) {
+	for {
+		message, err := readElement(session.reader)
+		if err != nil {
+			return element{}, fmt.Errorf("read LDAP response: %w", err)
+		}
+		if message.tag != tagSequence {
+			return element{}, fmt.Errorf("read LDAP response: unexpected tag %#x", message.tag)
+		}
+		parts, err := message.children()
+		if err != nil || len(parts) < 2 || parts[0].tag != tagInteger {
+			return element{}, fmt.Errorf("read LDAP response: malformed message")
+		}
+		responseID, err := parts[0].integer()
+		if err != nil {
+			return element{}, fmt.Errorf("read LDAP response: %w", err)
+		}
+		// Message ID 0 is an unsolicited notification, normally the server
+		// announcing that it is about to close the connection.
+		if responseID == 0 {
+			return element{}, fmt.Errorf("LDAP server sent an unsolicited notification")
+		}
+		if responseID == messageID {
+			return parts[1], nil
+		}
+	}
+}
+
+// startTLS upgrades the connection before anything else is sent on it, so
+// no bind ever travels in the clear. The deadline set by dial still bounds
+// the handshake.
+func (session *session) startTLS(ctx context.Context, config *tls.Config) error {
+	messageID, err := session.send(encodeElement(
+		applicationExtendedRequest,
+		encodeString(contextExtendedRequestName, startTLSOID),
+	))
+	if err != nil {
+		return err
+	}
+	response, err := session.receive(messageID)
+	if err != nil {
+		return err
+	}
+	if response.tag != applicationExtendedResponse {
+		return fmt.Errorf("unexpected LDAP extended response tag %#x", response.tag)
+	}
+	code, err := resultCode(response)
+	if err != nil {
+		return err
+	}
+	if code.code != resultSuccess {
+		return code
+	}
+	if session.reader.Buffered() > 0 {
+		return fmt.Errorf("LDAP server sent data before the TLS handshake")
+	}
+	conn := tls.Client(session.conn, config)
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return fmt.Errorf("TLS handshake: %w", err)
+	}
+	session.conn = conn
+	session.reader = bufio.NewReader(conn)
+	return nil
+}
+
+func (session *session) bind(dn string, password string) error {
+	messageID, err := session.send(encodeElement(
+		applicationBindRequest,
+		encodeInteger(tagInteger, protocolVersion),
+		encodeString(tagOctetString, dn),
+		encodeString(contextSimpleAuthentication, password),
+	))
+	if err != nil {
+		return err
+	}
+	response, err := session.receive(messageID)
+	if err != nil {
+		return err
+	}
+	if response.tag != applicationBindResponse {
+		return fmt.Errorf("unexpected LDAP bind response tag %#x", response.tag)
+	}
+	code, err := resultCode(response)
+	if err != nil {
+		return err
+	}
+	if code.code != resultSuccess {
+		return code
+	}
+	return nil
+}
+
+func (session *session) search(baseDN string, attribute string, value string, attributes []string) ([]searchEntry, error) {
+	var requested [][]byte
+	for _, name := range attributes {
+		requested = append(requested, encodeString(tagOctetString, name))
+	}
+	// The login name travels as an octet string in an equality filter, so
+	// it needs none of the escaping a textual filter would.
+	messageID, err := session.send(encodeElement(
+		applicationSearchRequest,
+		encodeString(tagOctetString, baseDN),
+		encodeInteger(tagEnumerated, scopeWholeSubtree),
+		encodeInteger(tagEnumerated, derefAliasesNever),
+		encodeInteger(tagInteger, searchSizeLimit),
+		encodeInteger(tagInteger, 0),
+		encodeBoolean(false),
+		encodeElement(contextEqualityMatch, encodeString(tagOctetString, attribute), encodeString(tagOctetString, value)),
+		encodeElement(tagSequence, requested...),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []searchEntry
+	for {
+		response, err := session.receive(messageID)
+		if err != nil {
+			return nil, err
+		}
+		switch response.tag {
+		case applicationSearchEntry:
+			entry, err := parseSearchEntry(response)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		case applicationSearchReference:
+			// Referrals to other servers are not followed.
+		case applicationSearchDone:
+			code, err := resultCode(response)
+			if err != nil {
+				return nil, err
+			}
+			// Hitting the size limit of two already proves the name is
+			// ambiguous, which the caller reports.
+			if code.code != resultSuccess && !(code.code == resultSizeLimitExceeded && len(entries) > 1) {
+				return nil, code
+			}
+			return entries, nil
+		default:
+			return nil, fmt.Errorf("unexpected LDAP search response tag %#x", response.tag)
+		}
+	}
+}
+
+func resultCode(response element) (*resultError, error) {
+	parts, err := response.children()
+	if err != nil || len(parts) < 3 || parts[0].tag != tagEnumerated {
+		return nil, fmt.Errorf("malformed LDAP result")
+	}
+	code, err := parts[0].integer()
+	if err != nil {
+		return nil, fmt.Errorf("malformed LDAP result: %w", err)
+	}
+	diagnostic := string(parts[2].contents)
+	if len(diagnostic) > diagnosticMaximumLength {
+		diagnostic = diagnostic[:diagnosticMaximumLength]
+	}
+	return &resultError{code: code, diagnostic: diagnostic}, nil
+}
+
+func parseSearchEntry(response element) (searchEntry, error) {
+	parts, err := response.children()
+	if err != nil || len(parts) != 2 || parts[0].tag != tagOctetString || parts[1].tag != tagSequence {
+		return searchEntry{}, fmt.Errorf("malformed LDAP search entry")
+	}
+	entry := searchEntry{dn: string(parts[0].contents), attributes: map[string][]string{}}
+	attributes, err := parts[1].children()
+	if err != nil {
+		return searchEntry{}, fmt.Errorf("malformed LDAP search entry: %w", err)
+	}
+	for _, attribute := range attributes {
+		fields, err := attribute.children()
+		if err != nil || len(fields) != 2 || fields[0].tag != tagOctetString || fields[1].tag != tagSet {
+			return searchEntry{}, fmt.Errorf("malformed LDAP attribute")
+		}
+		values, err := fields[1].children()
+		if err != nil {
+			return searchEntry{}, fmt.Errorf("malformed LDAP attribute: %w", err)
+		}
+		name := string(fields[0].contents)
+		for _, value := range values {
+			entry.attributes[name] = append(entry.attributes[name], string(value.contents))
+		}
+	}
+	return entry, nil
+}
@@ -0,0 +1,314 @@
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+const (
+	testServiceDN       = "CN=identityd,OU=Service,DC=corp,DC=example,DC=test"
+	testServicePassword = "service password"
+	testBaseDN          = "DC=corp,DC=example,DC=test"
+)
+
+type testEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// testServer 是进程内的最小 LDAP 目录，只实现 identityd 用到的 StartTLS、简单绑定和相等过滤搜索。
+// tlsConfig 为 nil 时目录拒绝 StartTLS 并接受明文绑定；否则明文绑定一律返回 confidentialityRequired。
+type testServer struct {
+	listener  net.Listener
+	entries   []testEntry
+	tlsConfig *tls.Config
+	roots     *x509.CertPool
+}
+
+const resultConfidentialityRequired = 13
+
+func startTestServer(t *testing.T, entries ...testEntry) *testServer {
+	t.Helper()
+	return startTestServerWithTLS(t, true, entries...)
+}
+
+func startTestServerWithTLS(t *testing.T, offerStartTLS bool, entries ...testEntry) *testServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := &testServer{listener: listener, entries: entries}
+	if offerStartTLS {
+		server.tlsConfig, server.roots = testCertificate(t)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (server *testServer) url() string {
+	return "ldap://" + server.listener.Addr().String()
+}
+
+// testCertificate 为 127.0.0.1 签发自签名证书，返回服务端配置和信任它的根证书池。
+func testCertificate(t *testing.T) (*tls.Config, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ldap test directory"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(certificate)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, roots
+}
+
+func (server *testServer) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	reader := bufio.NewReader(conn)
+	boundDN := ""
+	secure := false
+	for {
+		message, err := readElement(reader)
+		if err != nil {
+			return
+		}
+		parts, err := message.children()
+		if err != nil || len(parts) != 2 {
+			return
+		}
+		messageID, _ := parts[0].integer()
+		respond := func(operation []byte) {
+			conn.Write(encodeElement(tagSequence, encodeInteger(tagInteger, messageID), operation))
+		}
+		fields, _ := parts[1].children()
+		switch parts[1].tag {
+		case applicationExtendedRequest:
+			if server.tlsConfig == nil || secure || string(fields[0].contents) != startTLSOID {
+				respond(ldapResult(applicationExtendedResponse, 2))
+				continue
+			}
+			respond(ldapResult(applicationExtendedResponse, resultSuccess))
+			conn = tls.Server(conn, server.tlsConfig)
+			reader = bufio.NewReader(conn)
+			secure = true
+		case applicationBindRequest:
+			if server.tlsConfig != nil && !secure {
+				respond(ldapResult(applicationBindResponse, resultConfidentialityRequired))
+				continue
+			}
+			dn, password := string(fields[1].contents), string(fields[2].contents)
+			code := resultInvalidCredentials
+			if dn == testServiceDN && password == testServicePassword {
+				code = resultSuccess
+			}
+			for _, entry := range server.entries {
+				if entry.dn == dn && entry.password == password {
+					code = resultSuccess
+				}
+			}
+			if code == resultSuccess {
+				boundDN = dn
+			}
+			respond(ldapResult(applicationBindResponse, code))
+		case applicationSearchRequest:
+			if boundDN != testServiceDN {
+				respond(ldapResult(applicationSearchDone, 50))
+				continue
+			}
+			filter, _ := fields[6].children()
+			attribute, value := string(filter[0].contents), string(filter[1].contents)
+			for _, entry := range server.entries {
+				if !strings.EqualFold(firstValue(entry.attributes, attribute), value) {
+					continue
+				}
+				var attributes [][]byte
+				for name, values := range entry.attributes {
+					var encodedValues [][]byte
+					for _, value := range values {
+						encodedValues = append(encodedValues, encodeString(tagOctetString, value))
+					}
+					attributes = append(attributes, encodeElement(tagSequence, encodeString(tagOctetString, name), encodeElement(tagSet, encodedValues...)))
+				}
+				respond(encodeElement(applicationSearchEntry, encodeString(tagOctetString, entry.dn), encodeElement(tagSequence, attributes...)))
+			}
+			respond(ldapResult(applicationSearchDone, resultSuccess))
+		case applicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func ldapResult(tag byte, code int) []byte {
+	return encodeElement(tag, encodeInteger(tagEnumerated, code), encodeString(tagOctetString, ""), encodeString(tagOctetString, ""))
+}
+
+func firstValue(attributes map[string][]string, name string) string {
+	for attribute, values := range attributes {
+		if strings.EqualFold(attribute, name) && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+func newTestAuthenticator(t *testing.T, server *testServer) *Authenticator {
+	t.Helper()
+	authenticator, err := New(Config{
+		URL:          server.url(),
+		BindDN:       testServiceDN,
+		BindPassword: testServicePassword,
+		BaseDN:       testBaseDN,
+		Timeout:      2 * time.Second,
+		TLSConfig:    &tls.Config{RootCAs: server.roots},
+	})
+	if err != nil {
+		t.Fatalf("new authenticator: %v", err)
+	}
+	return authenticator
+}
+
+func TestAuthenticateBindsAsTheMatchingEntry(t *testing.T) {
+	// 组 DN 足够长，让消息使用 BER 长格式长度。
+	longGroup := "CN=" + strings.Repeat("Engineering ", 20) + ",OU=Groups,DC=corp,DC=example,DC=test"
+	server := startTestServer(t, testEntry{
+		dn:       "CN=Zhang San,OU=People,DC=corp,DC=example,DC=test",
+		password: "directory password",
+		attributes: map[string][]string{
+			"userPrincipalName": {"zhangsan@corp.example.test"},
+			"displayName":       {"张三"},
+			"memberOf":          {"CN=CRM Users,OU=Groups,DC=corp,DC=example,DC=test", longGroup},
+		},
+	})
+	authenticator := newTestAuthenticator(t, server)
+
+	account, err := authenticator.Authenticate(context.Background(), "ZhangSan@corp.example.test", "directory password")
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if account.DisplayName != "张三" || len(account.Groups) != 2 || account.Groups[1] != longGroup {
+		t.Fatalf("account = %#v", account)
+	}
+
+	for name, credentials := range map[string][2]string{
+		"wrong password": {"zhangsan@corp.example.test", "wrong password"},
+		"unknown user":   {"lisi@corp.example.test", "directory password"},
+		"empty password": {"zhangsan@corp.example.test", ""},
+	} {
+		if _, err := authenticator.Authenticate(context.Background(), credentials[0], credentials[1]); !errors.Is(err, identity.ErrInvalidCredentials) {
+			t.Fatalf("%s error = %v", name, err)
+		}
+	}
+}
+
+func TestAuthenticateReportsDirectoryFailures(t *testing.T) {
+	entry := func(dn string) testEntry {
+		return testEntry{dn: dn, password: "directory password", attributes: map[string][]string{"userPrincipalName": {"zhangsan@corp.example.test"}}}
+	}
+	server := startTestServer(t, entry("CN=Zhang San,OU=People,DC=corp"), entry("CN=Zhang San,OU=Former,DC=corp"))
+
+	// 重名条目和服务账号绑定失败都不是用户密码错误。
+	if _, err := newTestAuthenticator(t, server).Authenticate(context.Background(), "zhangsan@corp.example.test", "directory password"); err == nil || errors.Is(err, identity.ErrInvalidCredentials) {
+		t.Fatalf("ambiguous entry error = %v", err)
+	}
+	misconfigured, err := New(Config{URL: server.url(), BindDN: testServiceDN, BindPassword: "wrong", BaseDN: testBaseDN, TLSConfig: &tls.Config{RootCAs: server.roots}})
+	if err != nil {
+		t.Fatalf("new authenticator: %v", err)
+	}
+	if _, err := misconfigured.Authenticate(context.Background(), "zhangsan@corp.example.test", "directory password"); err == nil || errors.Is(err, identity.ErrInvalidCredentials) {
+		t.Fatalf("service bind error = %v", err)
+	}
+
+	server.listener.Close()
+	if _, err := newTestAuthenticator(t, server).Authenticate(context.Background(), "zhangsan@corp.example.test", "directory password"); err == nil || errors.Is(err, identity.ErrInvalidCredentials) {
+		t.Fatalf("unreachable directory error = %v", err)
+	}
+}
+
+func TestAuthenticateRequiresStartTLSOnLDAPURLs(t *testing.T) {
+	entry := testEntry{dn: "CN=Zhang San,OU=People,DC=corp", password: "directory password", attributes: map[string][]string{"userPrincipalName": {"zhangsan@corp.example.test"}}}
+
+	// 不支持 StartTLS 的目录不会收到任何明文绑定，除非显式允许明文。
+	plaintext := startTestServerWithTLS(t, false, entry)
+	if _, err := newTestAuthenticator(t, plaintext).Authenticate(context.Background(), "zhangsan@corp.example.test", "directory password"); err == nil || errors.Is(err, identity.ErrInvalidCredentials) || !strings.Contains(err.Error(), "start LDAP TLS") {
+		t.Fatalf("StartTLS refused error = %v", err)
+	}
+	insecure, err := New(Config{URL: plaintext.url(), BindDN: testServiceDN, BindPassword: testServicePassword, BaseDN: testBaseDN, InsecurePlaintext: true})
+	if err != nil {
+		t.Fatalf("new insecure authenticator: %v", err)
+	}
+	if _, err := insecure.Authenticate(context.Background(), "zhangsan@corp.example.test", "directory password"); err != nil {
+		t.Fatalf("insecure plaintext authenticate: %v", err)
+	}
+
+	// 证书不受信任时握手失败，同样不是用户密码错误。
+	untrusted, err := New(Config{URL: startTestServer(t, entry).url(), BindDN: testServiceDN, BindPassword: testServicePassword, BaseDN: testBaseDN})
+	if err != nil {
+		t.Fatalf("new authenticator: %v", err)
+	}
+	if _, err := untrusted.Authenticate(context.Background(), "zhangsan@corp.example.test", "directory password"); err == nil || errors.Is(err, identity.ErrInvalidCredentials) {
+		t.Fatalf("untrusted certificate error = %v", err)
+	}
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	for name, config := range map[string]Config{
+		"scheme":  {URL: "https://dc.corp.example.test", BaseDN: testBaseDN},
+		"host":    {URL: "ldap://", BaseDN: testBaseDN},
+		"base DN": {URL: "ldaps://dc.corp.example.test"},
+	} {
+		if _, err := New(config); err == nil {
+			t.Fatalf("%s: New succeeded", name)
+		}
+	}
+	authenticator, err := New(Config{URL: "ldaps://dc.corp.example.test", BaseDN: testBaseDN})
+	if err != nil || authenticator.address != "dc.corp.example.test:636" || !authenticator.useTLS || authenticator.config.UserAttribute != "userPrincipalName" {
+		t.Fatalf("ldaps authenticator = %#v, %v", authenticator, err)
+	}
+	authenticator, err = New(Config{URL: "ldap://dc.corp.example.test", BaseDN: testBaseDN})
+	if err != nil || authenticator.address != "dc.corp.example.test:389" || authenticator.useTLS || !authenticator.startTLS || authenticator.tlsConfig().ServerName != "dc.corp.example.test" {
+		t.Fatalf("ldap authenticator = %#v, %v", authenticator, err)
+	}
+}
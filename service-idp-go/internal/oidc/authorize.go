@@ -0,0 +1,195 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database/sqlc"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+// ErrInvalidClient and ErrInvalidRedirectURI are authorization request
+// failures that must not be redirected: the redirect URI cannot be trusted.
+var ErrInvalidClient = errors.New("unknown or disabled client")
+var ErrInvalidRedirectURI = errors.New("redirect_uri is not registered for the client")
+
+const maximumNonceLength = 255
+
+// AuthorizationRequest carries the query parameters of GET /authorize.
+type AuthorizationRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// Authorization is a validated authorization request. Once the client and
+// redirect URI are known it is returned even alongside an *Error, so the
+// error can be redirected back to the client.
+type Authorization struct {
+	client        identity.Client
+	redirectURI   string
+	scopes        []string
+	state         string
+	nonce         string
+	codeChallenge string
+}
+
+func (provider *Provider) ValidateAuthorizationRequest(ctx context.Context, request AuthorizationRequest) (Authorization, error) {
+	client, err := identity.GetClient(ctx, provider.database, request.ClientID)
+	if errors.Is(err, identity.ErrClientNotFound) {
+		return Authorization{}, ErrInvalidClient
+	}
+	if err != nil {
+		return Authorization{}, err
+	}
+	if client.Status != "启用" {
+		return Authorization{}, ErrInvalidClient
+	}
+	if !slices.Contains(client.RedirectURIs, request.RedirectURI) {
+		return Authorization{}, ErrInvalidRedirectURI
+	}
+
+	authorization := Authorization{client: client, redirectURI: request.RedirectURI, state: request.State}
+	if request.ResponseType != "code" {
+		return authorization, &Error{Code: "unsupported_response_type", Description: "only response_type=code is supported"}
+	}
+	scopes := strings.Fields(request.Scope)
+	if !slices.Contains(scopes, "openid") {
+		return authorization, &Error{Code: "invalid_scope", Description: "the openid scope is required"}
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			return authorization, &Error{Code: "invalid_scope", Description: "scope " + scope + " is not registered for the client"}
+		}
+	}
+	if request.CodeChallengeMethod != "S256" || !validPKCEValue(request.CodeChallenge, 43, 43) {
+		return authorization, &Error{Code: "invalid_request", Description: "an S256 PKCE code_challenge is required"}
+	}
+	if len(request.Nonce) > maximumNonceLength {
+		return authorization, &Error{Code: "invalid_request", Description: "nonce is too long"}
+	}
+	slices.Sort(scopes)
+	authorization.scopes = slices.Compact(scopes)
+	authorization.nonce = request.Nonce
+	authorization.codeChallenge = request.CodeChallenge
+	return authorization, nil
+}
+
+// IssueAuthorizationCode stores the hash of a one-time code bound to the
+// client, redirect URI, PKCE challenge and the subject's current security
+// version, and returns the raw code.
+func (provider *Provider) IssueAuthorizationCode(ctx context.Context, authorization Authorization, subjectID string) (string, error) {
+	queries := sqlc.New(provider.database)
+	securityVersion, err := queries.GetEnabledSubjectSecurityVersion(ctx, sqlc.GetEnabledSubjectSecurityVersionParams{
+		ID:     subjectID,
+		Status: "启用",
+	})
+	if err != nil {
+		return "", fmt.Errorf("load authorizing subject: %w", err)
+	}
+	code, codeHash, err := newCode()
+	if err != nil {
+		return "", err
+	}
+	now := provider.now().UTC()
+	id, err := identity.NewULID(now)
+	if err != nil {
+		return "", err
+	}
+	if _, err := queries.DeleteExpiredAuthorizationCodes(ctx, now); err != nil {
+		return "", fmt.Errorf("delete expired authorization codes: %w", err)
+	}
+	if err := queries.CreateAuthorizationCode(ctx, sqlc.CreateAuthorizationCodeParams{
+		ID:                     id,
+		CodeHash:               codeHash,
+		OidcClientID:           authorization.client.ID,
+		SubjectID:              subjectID,
+		SubjectSecurityVersion: securityVersion,
+		RedirectUri:            authorization.redirectURI,
+		Scope:                  strings.Join(authorization.scopes, " "),
+		Nonce:                  sql.NullString{String: authorization.nonce, Valid: authorization.nonce != ""},
+		CodeChallenge:          authorization.codeChallenge,
+		CodeChallengeMethod:    "S256",
+		ExpiresAt:              now.Add(provider.settings.AuthorizationCodeTTL),
+		ConsumedAt:             sql.NullTime{},
+		CreatedAt:              now,
+	}); err != nil {
+		return "", fmt.Errorf("create authorization code: %w", err)
+	}
+	return code, nil
+}
+
+// AuthorizationRedirect builds the successful authorization response.
+func (provider *Provider) AuthorizationRedirect(authorization Authorization, code string) string {
+	return provider.redirect(authorization, url.Values{"code": {code}})
+}
+
+// ErrorRedirect builds an authorization error response.
+func (provider *Provider) ErrorRedirect(authorization Authorization, protocolError *Error) string {
+	return provider.redirect(authorization, url.Values{
+		"error":             {protocolError.Code},
+		"error_description": {protocolError.Description},
+	})
+}
+
+func (provider *Provider) redirect(authorization Authorization, values url.Values) string {
+	location, _ := url.Parse(authorization.redirectURI)
+	query := location.Query()
+	for key, value := range values {
+		query[key] = value
+	}
+	if authorization.state != "" {
+		query.Set("state", authorization.state)
+	}
+	query.Set("iss", provider.settings.Issuer)
+	location.RawQuery = query.Encode()
+	return location.String()
+}
+
+func newCode() (string, []byte, error) {
+	value := make([]byte, 32)
+	if _, err := rand.Read(value); err != nil {
+		return "", nil, fmt.Errorf("read authorization code: %w", err)
+	}
+	hash := sha256.Sum256(value)
+	return base64.RawURLEncoding.EncodeToString(value), hash[:], nil
+}
+
+func hashCode(value string) ([]byte, bool) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(decoded) != 32 {
+		return nil, false
+	}
+	hash := sha256.Sum256(decoded)
+	return hash[:], true
+}
+
+// validPKCEValue reports whether value is a PKCE verifier or challenge of
+// the given length made of RFC 7636 unreserved characters.
+func validPKCEValue(value string, minimumLength int, maximumLength int) bool {
+	if len(value) < minimumLength || len(value) > maximumLength {
+		return false
+	}
+	for _, character := range value {
+		switch {
+		case character >= 'A' && character <= 'Z', character >= 'a' && character <= 'z', character >= '0' && character <= '9':
+		case character == '-', character == '.', character == '_', character == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
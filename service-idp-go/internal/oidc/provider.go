@@ -0,0 +1,210 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database/sqlc"
+	"github.com/ovaphlow/pitchfork/service-idp-go/pkg/accesstoken"
+)
+
+// Settings configures the OIDC provider. Issuer is the exact, externally
+// visible module URL (IDENTITYD_PUBLIC_URL); every endpoint advertised by
+// discovery lives directly beneath it.
+type Settings struct {
+	Issuer               string
+	Signer               *Signer
+	AccessTokenTTL       time.Duration
+	AuthorizationCodeTTL time.Duration
+}
+
+// Provider implements Authorization Code + PKCE for registered first-party
+// clients on top of the identityd browser session, and the client_credentials
+// grant for machine clients.
+type Provider struct {
+	database *sql.DB
+	settings Settings
+	now      func() time.Time
+	// accessTokens and clientTokens verify presented access tokens with the
+	// verifier the other services use, against the published keys; the
+	// client token verifier also requires the identity audience.
+	accessTokens *accesstoken.Verifier
+	clientTokens *accesstoken.Verifier
+}
+
+// Error is an OAuth 2.0 protocol error. Code is the registered error code
+// returned to the client, for example invalid_grant.
+type Error struct {
+	Code        string
+	Description string
+}
+
+func (err *Error) Error() string {
+	return err.Code + ": " + err.Description
+}
+
+// Discovery is the OpenID Provider metadata document.
+type Discovery struct {
+	Issuer                                     string   `json:"issuer"`
+	AuthorizationEndpoint                      string   `json:"authorization_endpoint"`
+	TokenEndpoint                              string   `json:"token_endpoint"`
+	UserinfoEndpoint                           string   `json:"userinfo_endpoint"`
+	JWKSURI                                    string   `json:"jwks_uri"`
+	ResponseTypesSupported                     []string `json:"response_types_supported"`
+	ResponseModesSupported                     []string `json:"response_modes_supported"`
+	GrantTypesSupported                        []string `json:"grant_types_supported"`
+	SubjectTypesSupported                      []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported           []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                            []string `json:"scopes_supported"`
+	TokenEndpointAuthMethodsSupported          []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported              []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                            []string `json:"claims_supported"`
+	AuthorizationResponseIssParameterSupported bool     `json:"authorization_response_iss_parameter_supported"`
+}
+
+// JWKS is the published key set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func NewProvider(database *sql.DB, settings Settings) (*Provider, error) {
+	issuer, err := url.Parse(settings.Issuer)
+	if err != nil || !issuer.IsAbs() || issuer.Host == "" || issuer.RawQuery != "" || issuer.Fragment != "" {
+		return nil, fmt.Errorf("OIDC issuer must be an absolute URL without query or fragment")
+	}
+	if settings.Signer == nil {
+		return nil, fmt.Errorf("OIDC provider requires a signing key")
+	}
+	if settings.AccessTokenTTL <= 0 || settings.AuthorizationCodeTTL <= 0 {
+		return nil, fmt.Errorf("invalid OIDC token lifetimes")
+	}
+	settings.Issuer = strings.TrimSuffix(settings.Issuer, "/")
+	provider := &Provider{database: database, settings: settings, now: time.Now}
+	provider.accessTokens, err = accesstoken.NewVerifier(accesstoken.Config{Issuer: settings.Issuer, Keys: provider.verificationKey})
+	if err != nil {
+		return nil, err
+	}
+	provider.clientTokens, err = accesstoken.NewVerifier(accesstoken.Config{Issuer: settings.Issuer, Audience: identityAudience, Keys: provider.verificationKey})
+	if err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+func (provider *Provider) Issuer() string {
+	return provider.settings.Issuer
+}
+
+func (provider *Provider) Discovery() Discovery {
+	issuer := provider.settings.Issuer
+	return Discovery{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/authorize",
+		TokenEndpoint:                     issuer + "/token",
+		UserinfoEndpoint:                  issuer + "/userinfo",
+		JWKSURI:                           issuer + "/jwks",
+		ResponseTypesSupported:            []string{"code"},
+		ResponseModesSupported:            []string{"query"},
+		GrantTypesSupported:               []string{"authorization_code", "client_credentials"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{signingAlgorithm},
+		ScopesSupported:                   []string{"openid", "profile"},
+		TokenEndpointAuthMethodsSupported: []string{"none", "client_secret_basic", "client_secret_post"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "nonce",
+			"security_version", "roles", "name", "preferred_username",
+		},
+		AuthorizationResponseIssParameterSupported: true,
+	}
+}
+
+// RegisterSigningKey publishes the configured signing key and retires every
+// other key. A retired key stays in the JWKS document for one access-token
+// lifetime, so tokens signed before a rotation keep verifying until they
+// expire. Startup calls it after migrations.
+func (provider *Provider) RegisterSigningKey(ctx context.Context) error {
+	publicJWK, err := json.Marshal(provider.settings.Signer.PublicJWK())
+	if err != nil {
+		return fmt.Errorf("encode signing key: %w", err)
+	}
+	queries := sqlc.New(provider.database)
+	transaction, err := provider.database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin signing key transaction: %w", err)
+	}
+	defer transaction.Rollback()
+	transactionQueries := queries.WithTx(transaction)
+
+	now := provider.now().UTC()
+	keyID := provider.settings.Signer.KeyID()
+	if err := transactionQueries.UpsertSigningKey(ctx, sqlc.UpsertSigningKeyParams{
+		KeyID:     keyID,
+		Algorithm: signingAlgorithm,
+		PublicJwk: string(publicJWK),
+		CreatedAt: now,
+	}); err != nil {
+		return fmt.Errorf("register signing key: %w", err)
+	}
+	if _, err := transactionQueries.RetireSigningKeysExcept(ctx, sqlc.RetireSigningKeysExceptParams{
+		RetiredAt: sql.NullTime{Time: now, Valid: true},
+		KeyID:     keyID,
+	}); err != nil {
+		return fmt.Errorf("retire previous signing keys: %w", err)
+	}
+	if err := transaction.Commit(); err != nil {
+		return fmt.Errorf("commit signing key transaction: %w", err)
+	}
+	return nil
+}
+
+// JWKS returns the current signing key followed by the retired keys that
+// may still verify unexpired tokens.
+func (provider *Provider) JWKS(ctx context.Context) (JWKS, error) {
+	current := provider.settings.Signer.PublicJWK()
+	rows, err := sqlc.New(provider.database).ListPublishedSigningKeys(ctx, sql.NullTime{
+		Time:  provider.now().UTC().Add(-provider.settings.AccessTokenTTL),
+		Valid: true,
+	})
+	if err != nil {
+		return JWKS{}, fmt.Errorf("list published signing keys: %w", err)
+	}
+	keys := []JWK{current}
+	for _, row := range rows {
+		if row.KeyID == current.KeyID {
+			continue
+		}
+		var jwk JWK
+		if err := json.Unmarshal([]byte(row.PublicJwk), &jwk); err != nil {
+			return JWKS{}, fmt.Errorf("decode published signing key %s: %w", row.KeyID, err)
+		}
+		keys = append(keys, jwk)
+	}
+	return JWKS{Keys: keys}, nil
+}
+
+// verificationKey returns the published key named keyID, the key source of
+// the provider's access token verifiers.
+func (provider *Provider) verificationKey(ctx context.Context, keyID string) (*rsa.PublicKey, error) {
+	keys, err := provider.JWKS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, jwk := range keys.Keys {
+		if jwk.KeyID != keyID {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", accesstoken.ErrInvalidToken, err)
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown signing key", accesstoken.ErrInvalidToken)
+}
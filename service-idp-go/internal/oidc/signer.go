@@ -0,0 +1,140 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+const signingAlgorithm = "RS256"
+
+const minimumSigningKeyBits = 2048
+
+// Signer holds the RSA private key that signs issued tokens. Its key ID is
+// the RFC 7638 thumbprint of the public key, so the same key material always
+// publishes under the same kid.
+type Signer struct {
+	key   *rsa.PrivateKey
+	keyID string
+}
+
+// JWK is the public half of a signing key as published in the JWKS document.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+func NewSigner(key *rsa.PrivateKey) (*Signer, error) {
+	if key == nil || key.N.BitLen() < minimumSigningKeyBits {
+		return nil, fmt.Errorf("signing key must be an RSA key of at least %d bits", minimumSigningKeyBits)
+	}
+	if err := key.Validate(); err != nil {
+		return nil, fmt.Errorf("validate signing key: %w", err)
+	}
+	jwk := publicJWK(&key.PublicKey, "")
+	thumbprint := sha256.Sum256([]byte(`{"e":"` + jwk.Exponent + `","kty":"RSA","n":"` + jwk.Modulus + `"}`))
+	return &Signer{key: key, keyID: base64.RawURLEncoding.EncodeToString(thumbprint[:])}, nil
+}
+
+// LoadSigner reads a PEM-encoded RSA private key in PKCS #8 or PKCS #1 form.
+func LoadSigner(path string) (*Signer, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read signing key: %w", err)
+	}
+	block, _ := pem.Decode(contents)
+	if block == nil {
+		return nil, fmt.Errorf("signing key file does not contain a PEM block")
+	}
+	var key *rsa.PrivateKey
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse PKCS #8 signing key: %w", err)
+		}
+		rsaKey, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("signing key must be an RSA key")
+		}
+		key = rsaKey
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse PKCS #1 signing key: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported signing key PEM block %q", block.Type)
+	}
+	return NewSigner(key)
+}
+
+func (signer *Signer) KeyID() string {
+	return signer.keyID
+}
+
+func (signer *Signer) PublicJWK() JWK {
+	return publicJWK(&signer.key.PublicKey, signer.keyID)
+}
+
+func publicJWK(key *rsa.PublicKey, keyID string) JWK {
+	return JWK{
+		KeyType:   "RSA",
+		KeyID:     keyID,
+		Use:       "sig",
+		Algorithm: signingAlgorithm,
+		Modulus:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func (jwk JWK) publicKey() (*rsa.PublicKey, error) {
+	if jwk.KeyType != "RSA" || jwk.Algorithm != signingAlgorithm {
+		return nil, fmt.Errorf("unsupported JWK %q", jwk.KeyID)
+	}
+	modulus, err := base64.RawURLEncoding.DecodeString(jwk.Modulus)
+	if err != nil {
+		return nil, fmt.Errorf("decode JWK modulus: %w", err)
+	}
+	exponent, err := base64.RawURLEncoding.DecodeString(jwk.Exponent)
+	if err != nil || len(exponent) == 0 || len(exponent) > 4 {
+		return nil, fmt.Errorf("decode JWK exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(new(big.Int).SetBytes(exponent).Int64())}, nil
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid"`
+}
+
+func (signer *Signer) sign(tokenType string, claims any) (string, error) {
+	header, err := json.Marshal(jwtHeader{Algorithm: signingAlgorithm, Type: tokenType, KeyID: signer.keyID})
+	if err != nil {
+		return "", fmt.Errorf("encode JWT header: %w", err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("encode JWT claims: %w", err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, signer.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("sign JWT: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
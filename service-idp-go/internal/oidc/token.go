@@ -0,0 +1,364 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/database/sqlc"
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+	"github.com/ovaphlow/pitchfork/service-idp-go/pkg/accesstoken"
+)
+
+// ErrInvalidToken rejects an access token presented to userinfo: bad
+// signature, wrong issuer, expired, disabled client or subject, or a
+// security version older than the subject's current one.
+var ErrInvalidToken = errors.New("invalid access token")
+
+const (
+	accessTokenType = "at+jwt"
+	idTokenType     = "JWT"
+)
+
+// TokenRequest carries the form parameters of POST /token. ClientSecret
+// comes from HTTP Basic authentication or the client_secret parameter and is
+// used only by the client_credentials grant.
+type TokenRequest struct {
+	GrantType    string
+	Code         string
+	RedirectURI  string
+	ClientID     string
+	ClientSecret string
+	CodeVerifier string
+	Scope        string
+}
+
+// TokenResponse carries no ID token for the client_credentials grant, which
+// involves no end user.
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	IDToken     string `json:"id_token,omitempty"`
+	Scope       string `json:"scope"`
+}
+
+// ClientAccessToken is a validated client_credentials access token.
+type ClientAccessToken struct {
+	ClientID string
+	Scopes   []string
+}
+
+// accessTokenClaims follow RFC 9068. security_version pins the token to the
+// subject's security version at authorization time; disabling the subject
+// or changing its password increments the version and invalidates it.
+type accessTokenClaims struct {
+	Issuer          string   `json:"iss"`
+	Subject         string   `json:"sub"`
+	Audience        string   `json:"aud"`
+	ClientID        string   `json:"client_id"`
+	ExpiresAt       int64    `json:"exp"`
+	IssuedAt        int64    `json:"iat"`
+	NotBefore       int64    `json:"nbf"`
+	TokenID         string   `json:"jti"`
+	Scope           string   `json:"scope"`
+	Roles           []string `json:"roles"`
+	SecurityVersion int64    `json:"security_version"`
+}
+
+// clientAccessTokenClaims follow RFC 9068 for a client acting on its own
+// behalf: sub and client_id are both the client_id, and there are no roles
+// or security version. aud names the resource services of the granted
+// scopes (see scopeAudiences).
+type clientAccessTokenClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  []string `json:"aud"`
+	ClientID  string   `json:"client_id"`
+	ExpiresAt int64    `json:"exp"`
+	IssuedAt  int64    `json:"iat"`
+	NotBefore int64    `json:"nbf"`
+	TokenID   string   `json:"jti"`
+	Scope     string   `json:"scope"`
+}
+
+// identityAudience is the aud of client tokens granted an identity.* scope,
+// the only ones identityd's own API accepts.
+const identityAudience = "identity"
+
+// scopeAudiences returns the resource services the scopes are defined by,
+// sorted and without duplicates. The resource of a scope is its text before
+// the first '.' or ':', so identity.subjects.read is for identity and
+// prototype.drills:write for prototype; each service verifies that its own
+// name is in aud.
+func scopeAudiences(scopes []string) []string {
+	audiences := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		resource := scope
+		if index := strings.IndexAny(scope, ".:"); index > 0 {
+			resource = scope[:index]
+		}
+		if !slices.Contains(audiences, resource) {
+			audiences = append(audiences, resource)
+		}
+	}
+	slices.Sort(audiences)
+	return audiences
+}
+
+type idTokenClaims struct {
+	Issuer            string `json:"iss"`
+	Subject           string `json:"sub"`
+	Audience          string `json:"aud"`
+	ExpiresAt         int64  `json:"exp"`
+	IssuedAt          int64  `json:"iat"`
+	Nonce             string `json:"nonce,omitempty"`
+	SecurityVersion   int64  `json:"security_version"`
+	Name              string `json:"name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+}
+
+// UserInfo is the userinfo response. Profile claims are present only when
+// the token was issued with the profile scope.
+type UserInfo struct {
+	Subject           string   `json:"sub"`
+	Name              string   `json:"name,omitempty"`
+	PreferredUsername string   `json:"preferred_username,omitempty"`
+	Roles             []string `json:"roles"`
+}
+
+// ExchangeAuthorizationCode redeems a one-time authorization code. The code
+// is consumed before any other check, so a code presented with a wrong
+// verifier or redirect URI cannot be retried.
+func (provider *Provider) ExchangeAuthorizationCode(ctx context.Context, request TokenRequest) (TokenResponse, error) {
+	if request.GrantType != "authorization_code" {
+		return TokenResponse{}, &Error{Code: "unsupported_grant_type", Description: "only the authorization_code and client_credentials grants are supported"}
+	}
+	if request.Code == "" || request.RedirectURI == "" || request.ClientID == "" || request.CodeVerifier == "" {
+		return TokenResponse{}, &Error{Code: "invalid_request", Description: "code, redirect_uri, client_id and code_verifier are required"}
+	}
+	client, err := identity.GetClient(ctx, provider.database, request.ClientID)
+	if errors.Is(err, identity.ErrClientNotFound) || (err == nil && client.Status != "启用") {
+		return TokenResponse{}, &Error{Code: "invalid_client", Description: "unknown or disabled client"}
+	}
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	invalidGrant := &Error{Code: "invalid_grant", Description: "the authorization code is invalid, expired or already used"}
+	codeHash, ok := hashCode(request.Code)
+	if !ok {
+		return TokenResponse{}, invalidGrant
+	}
+
+	queries := sqlc.New(provider.database)
+	now := provider.now().UTC()
+	record, err := queries.ConsumeAuthorizationCode(ctx, sqlc.ConsumeAuthorizationCodeParams{
+		ConsumedAt: sql.NullTime{Time: now, Valid: true},
+		CodeHash:   codeHash,
+		ExpiresAt:  now,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return TokenResponse{}, invalidGrant
+	}
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("consume authorization code: %w", err)
+	}
+	if record.OidcClientID != client.ID || record.RedirectUri != request.RedirectURI {
+		return TokenResponse{}, invalidGrant
+	}
+	if !validPKCEValue(request.CodeVerifier, 43, 128) || !pkceMatches(request.CodeVerifier, record.CodeChallenge) {
+		return TokenResponse{}, &Error{Code: "invalid_grant", Description: "code_verifier does not match the code_challenge"}
+	}
+	currentVersion, err := queries.GetEnabledSubjectSecurityVersion(ctx, sqlc.GetEnabledSubjectSecurityVersionParams{
+		ID:     record.SubjectID,
+		Status: "启用",
+	})
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && currentVersion != record.SubjectSecurityVersion) {
+		return TokenResponse{}, &Error{Code: "invalid_grant", Description: "the authorization is no longer valid"}
+	}
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("load token subject: %w", err)
+	}
+	subject, err := identity.GetSubject(ctx, provider.database, record.SubjectID)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	tokenID, err := identity.NewULID(now)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	expiresAt := now.Add(provider.settings.AccessTokenTTL)
+	accessToken, err := provider.settings.Signer.sign(accessTokenType, accessTokenClaims{
+		Issuer:          provider.settings.Issuer,
+		Subject:         subject.ID,
+		Audience:        client.ClientID,
+		ClientID:        client.ClientID,
+		ExpiresAt:       expiresAt.Unix(),
+		IssuedAt:        now.Unix(),
+		NotBefore:       now.Unix(),
+		TokenID:         tokenID,
+		Scope:           record.Scope,
+		Roles:           subject.Roles,
+		SecurityVersion: record.SubjectSecurityVersion,
+	})
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	idClaims := idTokenClaims{
+		Issuer:          provider.settings.Issuer,
+		Subject:         subject.ID,
+		Audience:        client.ClientID,
+		ExpiresAt:       expiresAt.Unix(),
+		IssuedAt:        now.Unix(),
+		Nonce:           record.Nonce.String,
+		SecurityVersion: record.SubjectSecurityVersion,
+	}
+	if slices.Contains(strings.Fields(record.Scope), "profile") {
+		idClaims.Name = subject.DisplayName
+		idClaims.PreferredUsername = subject.Identifier
+	}
+	idToken, err := provider.settings.Signer.sign(idTokenType, idClaims)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	return TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(provider.settings.AccessTokenTTL / time.Second),
+		IDToken:     idToken,
+		Scope:       record.Scope,
+	}, nil
+}
+
+// ExchangeClientCredentials issues an access token to a machine client
+// authenticated by its secret. An empty scope requests every scope the
+// client is registered for; otherwise each requested scope must be
+// registered. The token's aud lists the resource services of the granted
+// scopes, so a service accepts only tokens meant for it.
+func (provider *Provider) ExchangeClientCredentials(ctx context.Context, request TokenRequest) (TokenResponse, error) {
+	if request.GrantType != "client_credentials" {
+		return TokenResponse{}, &Error{Code: "unsupported_grant_type", Description: "only the authorization_code and client_credentials grants are supported"}
+	}
+	if request.ClientID == "" || request.ClientSecret == "" {
+		return TokenResponse{}, &Error{Code: "invalid_client", Description: "client authentication is required"}
+	}
+	client, err := identity.AuthenticateMachineClient(ctx, provider.database, request.ClientID, request.ClientSecret)
+	if errors.Is(err, identity.ErrInvalidClientCredentials) {
+		return TokenResponse{}, &Error{Code: "invalid_client", Description: "unknown or disabled client, or invalid secret"}
+	}
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	scopes := client.Scopes
+	if request.Scope != "" {
+		scopes = nil
+		for _, scope := range strings.Fields(request.Scope) {
+			if !slices.Contains(client.Scopes, scope) {
+				return TokenResponse{}, &Error{Code: "invalid_scope", Description: "scope " + scope + " is not registered for the client"}
+			}
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	scope := strings.Join(scopes, " ")
+
+	now := provider.now().UTC()
+	tokenID, err := identity.NewULID(now)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	accessToken, err := provider.settings.Signer.sign(accessTokenType, clientAccessTokenClaims{
+		Issuer:    provider.settings.Issuer,
+		Subject:   client.ClientID,
+		Audience:  scopeAudiences(scopes),
+		ClientID:  client.ClientID,
+		ExpiresAt: now.Add(provider.settings.AccessTokenTTL).Unix(),
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		TokenID:   tokenID,
+		Scope:     scope,
+	})
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	return TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(provider.settings.AccessTokenTTL / time.Second),
+		Scope:       scope,
+	}, nil
+}
+
+// AuthenticateClientToken validates a client_credentials access token
+// presented to identityd's own API. The token must be issued for the
+// identity audience, and like UserInfo it also checks that the machine client
+// is still enabled; a subject's token is rejected.
+func (provider *Provider) AuthenticateClientToken(ctx context.Context, accessToken string) (ClientAccessToken, error) {
+	claims, err := provider.clientTokens.Verify(ctx, accessToken)
+	if errors.Is(err, accesstoken.ErrInvalidToken) || (err == nil && !claims.IsClient()) {
+		return ClientAccessToken{}, ErrInvalidToken
+	}
+	if err != nil {
+		return ClientAccessToken{}, err
+	}
+	client, err := identity.GetMachineClient(ctx, provider.database, claims.ClientID)
+	if errors.Is(err, identity.ErrMachineClientNotFound) || (err == nil && client.Status != "启用") {
+		return ClientAccessToken{}, ErrInvalidToken
+	}
+	if err != nil {
+		return ClientAccessToken{}, err
+	}
+	return ClientAccessToken{ClientID: client.ClientID, Scopes: claims.Scopes}, nil
+}
+
+// UserInfo validates an access token issued by this provider with the same
+// checks as pkg/accesstoken and returns the claims of its subject. Unlike a
+// resource server verifying only the token itself, it also checks the client status and the subject's current
+// security version, so revoked authorizations are rejected immediately.
+func (provider *Provider) UserInfo(ctx context.Context, accessToken string) (UserInfo, error) {
+	claims, err := provider.accessTokens.Verify(ctx, accessToken)
+	if errors.Is(err, accesstoken.ErrInvalidToken) {
+		return UserInfo{}, ErrInvalidToken
+	}
+	if err != nil {
+		return UserInfo{}, err
+	}
+	client, err := identity.GetClient(ctx, provider.database, claims.ClientID)
+	if errors.Is(err, identity.ErrClientNotFound) || (err == nil && client.Status != "启用") {
+		return UserInfo{}, ErrInvalidToken
+	}
+	if err != nil {
+		return UserInfo{}, err
+	}
+	subject, err := identity.GetSubject(ctx, provider.database, claims.Subject)
+	if errors.Is(err, identity.ErrSubjectNotFound) {
+		return UserInfo{}, ErrInvalidToken
+	}
+	if err != nil {
+		return UserInfo{}, err
+	}
+	if subject.Status != "启用" || subject.SecurityVersion != claims.SecurityVersion {
+		return UserInfo{}, ErrInvalidToken
+	}
+	userInfo := UserInfo{Subject: subject.ID, Roles: subject.Roles}
+	if claims.HasScope("profile") {
+		userInfo.Name = subject.DisplayName
+		userInfo.PreferredUsername = subject.Identifier
+	}
+	return userInfo, nil
+}
+
+func pkceMatches(verifier string, challenge string) bool {
+	digest := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(digest[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
@@ -10,7 +10,9 @@ import (
 	"golang.org/x/crypto/argon2"
 )
 
-const minimumLength = 12
+// MinimumLength is the floor Hash enforces for every password, including
+// bootstrap and operator passwords that bypass the configured Policy.
+const MinimumLength = 12
 
 type Parameters struct {
 	Memory      uint32
@@ -28,33 +30,52 @@ var defaultParameters = Parameters{
 	KeyLength:   32,
 }
 
+// Verification is the result of Verify. NeedsRehash is only meaningful when
+// Matched is set: the caller then holds the plaintext and can store a hash
+// with the current parameters.
+type Verification struct {
+	Matched     bool
+	NeedsRehash bool
+}
+
 func Hash(value string) (string, error) {
-	if len(value) < minimumLength {
-		return "", fmt.Errorf("password must contain at least %d bytes", minimumLength)
+	if len(value) < MinimumLength {
+		return "", fmt.Errorf("password must contain at least %d bytes", MinimumLength)
 	}
+	return hashWithParameters(value, defaultParameters)
+}
 
-	salt := make([]byte, defaultParameters.SaltLength)
+func hashWithParameters(value string, parameters Parameters) (string, error) {
+	salt := make([]byte, parameters.SaltLength)
 	if _, err := rand.Read(salt); err != nil {
 		return "", fmt.Errorf("read password salt: %w", err)
 	}
-	hash := argon2.IDKey([]byte(value), salt, defaultParameters.Iterations, defaultParameters.Memory, defaultParameters.Parallelism, defaultParameters.KeyLength)
+	hash := argon2.IDKey([]byte(value), salt, parameters.Iterations, parameters.Memory, parameters.Parallelism, parameters.KeyLength)
 	return fmt.Sprintf(
 		"$argon2id$v=19$m=%d,t=%d,p=%d$%s$%s",
-		defaultParameters.Memory,
-		defaultParameters.Iterations,
-		defaultParameters.Parallelism,
+		parameters.Memory,
+		parameters.Iterations,
+		parameters.Parallelism,
 		base64.RawStdEncoding.EncodeToString(salt),
 		base64.RawStdEncoding.EncodeToString(hash),
 	), nil
 }
 
-func Verify(value string, encoded string) (bool, error) {
+// Verify checks value against an encoded Argon2id hash. A hash made with
+// parameters other than the current defaults, stronger or weaker, is reported
+// as needing a rehash so that tuning converges on one setting.
+func Verify(value string, encoded string) (Verification, error) {
 	parameters, salt, expectedHash, err := parse(encoded)
 	if err != nil {
-		return false, err
+		return Verification{}, err
 	}
 	actualHash := argon2.IDKey([]byte(value), salt, parameters.Iterations, parameters.Memory, parameters.Parallelism, uint32(len(expectedHash)))
-	return subtle.ConstantTimeCompare(actualHash, expectedHash) == 1, nil
+	if subtle.ConstantTimeCompare(actualHash, expectedHash) != 1 {
+		return Verification{}, nil
+	}
+	parameters.SaltLength = uint32(len(salt))
+	parameters.KeyLength = uint32(len(expectedHash))
+	return Verification{Matched: true, NeedsRehash: parameters != defaultParameters}, nil
 }
 
 func parse(encoded string) (Parameters, []byte, []byte, error) {
@@ -8,23 +8,49 @@ func TestHashAndVerify(t *testing.T) {
 		t.Fatalf("hash password: %v", err)
 	}
 
-	matched, err := Verify("correct horse battery staple", encoded)
+	verification, err := Verify("correct horse battery staple", encoded)
 	if err != nil {
 		t.Fatalf("verify password: %v", err)
 	}
-	if !matched {
+	if !verification.Matched {
 		t.Fatal("correct password did not match")
 	}
+	if verification.NeedsRehash {
+		t.Fatal("hash with default parameters needs rehash")
+	}
 
-	matched, err = Verify("incorrect password", encoded)
+	verification, err = Verify("incorrect password", encoded)
 	if err != nil {
 		t.Fatalf("verify incorrect password: %v", err)
 	}
-	if matched {
+	if verification.Matched {
 		t.Fatal("incorrect password matched")
 	}
 }
 
+func TestVerifyReportsOutdatedParameters(t *testing.T) {
+	legacyParameters := defaultParameters
+	legacyParameters.Memory = 8 * 1024
+	legacyParameters.Iterations = 1
+	encoded, err := hashWithParameters("correct horse battery staple", legacyParameters)
+	if err != nil {
+		t.Fatalf("hash with legacy parameters: %v", err)
+	}
+
+	verification, err := Verify("correct horse battery staple", encoded)
+	if err != nil {
+		t.Fatalf("verify password: %v", err)
+	}
+	if !verification.Matched || !verification.NeedsRehash {
+		t.Fatalf("verification = %#v", verification)
+	}
+	// 密码不匹配时不提示重新哈希。
+	verification, err = Verify("incorrect password", encoded)
+	if err != nil || verification.Matched || verification.NeedsRehash {
+		t.Fatalf("incorrect password verification = %#v, %v", verification, err)
+	}
+}
+
 func TestHashRejectsShortPassword(t *testing.T) {
 	if _, err := Hash("short"); err == nil {
 		t.Fatal("short password was accepted")
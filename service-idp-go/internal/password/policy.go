@@ -0,0 +1,81 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+)
+
+//go:embed common-passwords.txt
+var commonPasswords string
+
+// Policy holds the rules a chosen password must satisfy on top of the Hash
+// floor. The zero value only applies the floor.
+type Policy struct {
+	MinimumLength int
+	Denylist      Denylist
+}
+
+// Check reports the first rule value breaks. Denylisted passwords are
+// rejected without saying which list matched.
+func (policy Policy) Check(value string) error {
+	minimumLength := max(policy.MinimumLength, MinimumLength)
+	if len(value) < minimumLength {
+		return fmt.Errorf("password must contain at least %d bytes", minimumLength)
+	}
+	if policy.Denylist.Contains(value) {
+		return fmt.Errorf("password is too common")
+	}
+	return nil
+}
+
+// Denylist is a set of breached or common passwords, compared
+// case-insensitively after trimming surrounding space.
+type Denylist map[string]struct{}
+
+// CommonPasswords returns the embedded denylist. The file only lists entries
+// long enough to pass MinimumLength, since shorter ones are rejected anyway.
+func CommonPasswords() Denylist {
+	denylist, err := ParseDenylist(strings.NewReader(commonPasswords))
+	if err != nil {
+		panic("parse embedded common passwords: " + err.Error())
+	}
+	return denylist
+}
+
+// ParseDenylist reads one password per line. Blank lines and lines starting
+// with # are skipped.
+func ParseDenylist(reader io.Reader) (Denylist, error) {
+	denylist := Denylist{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		denylist[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read password denylist: %w", err)
+	}
+	return denylist, nil
+}
+
+// Merge adds the entries of other to denylist.
+func (denylist Denylist) Merge(other Denylist) Denylist {
+	merged := make(Denylist, len(denylist)+len(other))
+	for value := range denylist {
+		merged[value] = struct{}{}
+	}
+	for value := range other {
+		merged[value] = struct{}{}
+	}
+	return merged
+}
+
+func (denylist Denylist) Contains(value string) bool {
+	_, found := denylist[strings.ToLower(strings.TrimSpace(value))]
+	return found
+}
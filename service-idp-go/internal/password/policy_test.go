@@ -0,0 +1,33 @@
+package password
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	custom, err := ParseDenylist(strings.NewReader("# 本地补充\n\n  Pitchfork Autumn 2026  \n"))
+	if err != nil {
+		t.Fatalf("parse denylist: %v", err)
+	}
+	policy := Policy{MinimumLength: 16, Denylist: CommonPasswords().Merge(custom)}
+
+	cases := []struct {
+		value string
+		ok    bool
+	}{
+		{value: "a sufficiently long password", ok: true},
+		{value: "fifteen bytes!!", ok: false},
+		{value: "pitchfork autumn 2026", ok: false},
+		{value: " PASSWORDPASSWORD ", ok: false},
+	}
+	for _, testCase := range cases {
+		if err := policy.Check(testCase.value); (err == nil) != testCase.ok {
+			t.Fatalf("check %q error = %v", testCase.value, err)
+		}
+	}
+	// 零值策略仍然保留最小长度下限。
+	if err := (Policy{}).Check("short"); err == nil {
+		t.Fatal("zero policy accepted short password")
+	}
+}
@@ -0,0 +1,61 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 seed of the RFC 6238 appendix B test vectors.
+var rfc6238Secret = []byte("12345678901234567890")
+
+func TestCodeMatchesRFC6238Vectors(t *testing.T) {
+	// 附录 B 的八位结果取末六位。
+	for unixTime, want := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	} {
+		if got := Code(rfc6238Secret, Step(time.Unix(unixTime, 0))); got != want {
+			t.Errorf("code at %d = %s, want %s", unixTime, got, want)
+		}
+	}
+}
+
+func TestMatchAcceptsAdjacentStepsAndRejectsReplay(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+	for _, step := range []int64{current - 1, current, current + 1} {
+		matched, ok := Match(rfc6238Secret, Code(rfc6238Secret, step), now, 0)
+		if !ok || matched != step {
+			t.Fatalf("step %d matched = %d, %v", step, matched, ok)
+		}
+	}
+	if _, ok := Match(rfc6238Secret, Code(rfc6238Secret, current+2), now, 0); ok {
+		t.Fatal("code two steps ahead matched")
+	}
+	// 已使用过的时间步不能再次通过。
+	if _, ok := Match(rfc6238Secret, Code(rfc6238Secret, current), now, current); ok {
+		t.Fatal("replayed code matched")
+	}
+	if _, ok := Match(rfc6238Secret, "12345", now, 0); ok {
+		t.Fatal("short code matched")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(ProvisioningURI("identityd", "admin", rfc6238Secret))
+	if err != nil {
+		t.Fatalf("parse provisioning URI: %v", err)
+	}
+	query := uri.Query()
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/identityd:admin" {
+		t.Fatalf("provisioning URI = %s", uri)
+	}
+	if query.Get("secret") != "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" || query.Get("issuer") != "identityd" || query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Fatalf("provisioning query = %v", query)
+	}
+}
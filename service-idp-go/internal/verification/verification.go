@@ -0,0 +1,116 @@
+// Package verification provides development senders for identifier
+// verification codes and password reset tokens. Production deployments
+// deliver them by mail or SMS through their own identity.VerificationCodeSender
+// and identity.PasswordResetNotifier.
+package verification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+// Sender delivers both verification codes and password reset tokens, which
+// every sender in this package does.
+type Sender interface {
+	identity.VerificationCodeSender
+	identity.PasswordResetNotifier
+}
+
+// LogSender writes each code to a logger, which is enough to complete a
+// verification on a developer machine.
+type LogSender struct {
+	Logger *slog.Logger
+}
+
+func (sender LogSender) SendVerificationCode(ctx context.Context, code identity.VerificationCode) error {
+	sender.Logger.InfoContext(ctx, "verification code",
+		"identifier_type", code.IdentifierType,
+		"address", code.Address,
+		"code", code.Code,
+		"expires_at", code.ExpiresAt,
+	)
+	return nil
+}
+
+func (sender LogSender) SendPasswordReset(ctx context.Context, notice identity.PasswordResetNotice) error {
+	sender.Logger.InfoContext(ctx, "password reset",
+		"address", notice.Address,
+		"reset_token", notice.Token,
+		"expires_at", notice.ExpiresAt,
+	)
+	return nil
+}
+
+// FileSender appends each code as a JSON line to Path, so tests and local
+// tools can read the code without parsing log output.
+type FileSender struct {
+	Path  string
+	mutex sync.Mutex
+}
+
+type fileRecord struct {
+	IdentifierType string    `json:"identifier_type"`
+	Address        string    `json:"address"`
+	Code           string    `json:"code"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+type passwordResetRecord struct {
+	Address    string    `json:"address"`
+	ResetToken string    `json:"reset_token"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+func NewFileSender(path string) (*FileSender, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("create verification code directory: %w", err)
+	}
+	return &FileSender{Path: path}, nil
+}
+
+func (sender *FileSender) SendVerificationCode(_ context.Context, code identity.VerificationCode) error {
+	line, err := json.Marshal(fileRecord{
+		IdentifierType: code.IdentifierType,
+		Address:        code.Address,
+		Code:           code.Code,
+		ExpiresAt:      code.ExpiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("encode verification code: %w", err)
+	}
+	return sender.appendLine(line)
+}
+
+func (sender *FileSender) SendPasswordReset(_ context.Context, notice identity.PasswordResetNotice) error {
+	line, err := json.Marshal(passwordResetRecord{
+		Address:    notice.Address,
+		ResetToken: notice.Token,
+		ExpiresAt:  notice.ExpiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("encode password reset: %w", err)
+	}
+	return sender.appendLine(line)
+}
+
+func (sender *FileSender) appendLine(line []byte) error {
+	sender.mutex.Lock()
+	defer sender.mutex.Unlock()
+	file, err := os.OpenFile(sender.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open verification code file: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("write verification code file: %w", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,95 @@
+package verification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/internal/identity"
+)
+
+func TestFileSenderAppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "codes.jsonl")
+	sender, err := NewFileSender(path)
+	if err != nil {
+		t.Fatalf("create file sender: %v", err)
+	}
+	expiresAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, code := range []string{"012345", "678901"} {
+		if err := sender.SendVerificationCode(context.Background(), identity.VerificationCode{
+			IdentifierType: "邮箱",
+			Address:        "alice@example.test",
+			Code:           code,
+			ExpiresAt:      expiresAt,
+		}); err != nil {
+			t.Fatalf("send %s: %v", code, err)
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read codes: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("lines = %q", lines)
+	}
+	var record fileRecord
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatalf("decode record: %v", err)
+	}
+	// 后一次发送追加在文件末尾，而不是覆盖前一次。
+	if record.Code != "678901" || record.Address != "alice@example.test" || record.IdentifierType != "邮箱" || !record.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("record = %+v", record)
+	}
+}
+
+func TestLogSenderLogsCode(t *testing.T) {
+	var output bytes.Buffer
+	sender := LogSender{Logger: slog.New(slog.NewTextHandler(&output, nil))}
+	if err := sender.SendVerificationCode(context.Background(), identity.VerificationCode{
+		IdentifierType: "手机号",
+		Address:        "+8613800138000",
+		Code:           "424242",
+		ExpiresAt:      time.Now(),
+	}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if !strings.Contains(output.String(), "code=424242") || !strings.Contains(output.String(), "address=+8613800138000") {
+		t.Fatalf("log output = %q", output.String())
+	}
+}
+
+func TestFileSenderAppendsPasswordResets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "codes.jsonl")
+	sender, err := NewFileSender(path)
+	if err != nil {
+		t.Fatalf("create file sender: %v", err)
+	}
+	expiresAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := sender.SendPasswordReset(context.Background(), identity.PasswordResetNotice{
+		Address:   "alice@example.test",
+		Token:     "reset-token",
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		t.Fatalf("send password reset: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read password reset: %v", err)
+	}
+	var record passwordResetRecord
+	if err := json.Unmarshal(content, &record); err != nil {
+		t.Fatalf("decode record: %v", err)
+	}
+	if record.ResetToken != "reset-token" || record.Address != "alice@example.test" || !record.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("record = %+v", record)
+	}
+}
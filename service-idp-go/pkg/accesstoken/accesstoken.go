@@ -0,0 +1,308 @@
+// Package accesstoken verifies identityd access tokens offline, for the other
+// pitchfork Go services. Tokens are RS256 JWTs of type at+jwt (RFC 9068)
+// signed by identityd; the verifier fetches identityd's JWKS on first use and
+// again when a token names an unknown key, and checks iss, exp, nbf and
+// optionally aud without calling identityd per request.
+//
+// Offline verification cannot see revocations: a disabled subject or machine
+// client keeps a valid token until it expires, at most one access-token
+// lifetime (IDENTITYD_OIDC_ACCESS_TOKEN_TTL).
+package accesstoken
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidToken rejects a malformed, badly signed, expired or foreign
+// token.
+var ErrInvalidToken = errors.New("invalid access token")
+
+const (
+	tokenType         = "at+jwt"
+	signingAlgorithm  = "RS256"
+	defaultLeeway     = 30 * time.Second
+	defaultJWKSClient = 3 * time.Second
+	// jwksRefreshInterval is the minimum time between two JWKS fetches caused
+	// by an unknown kid, so forged kids cannot turn a service into a request
+	// amplifier against identityd.
+	jwksRefreshInterval = time.Minute
+)
+
+// Config configures a Verifier. Issuer is identityd's IDENTITYD_PUBLIC_URL
+// and is required. JWKSURL defaults to Issuer + "/jwks". A service must set
+// Audience to its resource name, the prefix of its scopes (prototype for
+// prototype.drills:write): identityd puts that name in the aud of machine
+// client tokens, and an empty Audience skips the check, accepting tokens
+// meant for any service. A nil Client uses one with a short timeout. Keys,
+// when set, replaces the JWKS fetch: identityd passes its own key store so
+// that its endpoints accept exactly the tokens the services accept.
+type Config struct {
+	Issuer   string
+	JWKSURL  string
+	Audience string
+	Leeway   time.Duration
+	Client   *http.Client
+	Keys     KeyFunc
+}
+
+// KeyFunc returns the public key named keyID. An error wrapping
+// ErrInvalidToken rejects the token; Verify returns any other error as is.
+type KeyFunc func(ctx context.Context, keyID string) (*rsa.PublicKey, error)
+
+// Claims are the verified claims of an access token. For a machine client
+// token (client_credentials) Subject equals ClientID and Roles is empty.
+type Claims struct {
+	Issuer          string
+	Subject         string
+	Audience        []string
+	ClientID        string
+	Scopes          []string
+	Roles           []string
+	SecurityVersion int64
+	TokenID         string
+	IssuedAt        time.Time
+	ExpiresAt       time.Time
+}
+
+// IsClient reports whether the token was issued to a machine client acting
+// for itself rather than to a subject.
+func (claims Claims) IsClient() bool {
+	return claims.Subject != "" && claims.Subject == claims.ClientID
+}
+
+func (claims Claims) HasScope(scope string) bool {
+	return slices.Contains(claims.Scopes, scope)
+}
+
+func (claims Claims) HasRole(role string) bool {
+	return slices.Contains(claims.Roles, role)
+}
+
+// Verifier validates access tokens against identityd's published keys. It is
+// safe for concurrent use.
+type Verifier struct {
+	config Config
+	now    func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+func NewVerifier(config Config) (*Verifier, error) {
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	if config.Issuer == "" {
+		return nil, fmt.Errorf("access token verifier requires the identityd issuer")
+	}
+	if config.JWKSURL == "" {
+		config.JWKSURL = config.Issuer + "/jwks"
+	}
+	if config.Leeway <= 0 {
+		config.Leeway = defaultLeeway
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: defaultJWKSClient}
+	}
+	return &Verifier{config: config, now: time.Now}, nil
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid"`
+}
+
+// jwtClaims mirrors the access token claims identityd issues. aud may be a
+// string or an array of strings (RFC 7519 section 4.1.3).
+type jwtClaims struct {
+	Issuer          string          `json:"iss"`
+	Subject         string          `json:"sub"`
+	Audience        json.RawMessage `json:"aud"`
+	ClientID        string          `json:"client_id"`
+	ExpiresAt       *int64          `json:"exp"`
+	IssuedAt        int64           `json:"iat"`
+	NotBefore       int64           `json:"nbf"`
+	TokenID         string          `json:"jti"`
+	Scope           string          `json:"scope"`
+	Roles           []string        `json:"roles"`
+	SecurityVersion int64           `json:"security_version"`
+}
+
+// Verify checks token and returns its claims. Errors wrapping
+// ErrInvalidToken reject the token; any other error means the JWKS could not
+// be fetched and the token could not be judged.
+func (verifier *Verifier) Verify(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, rejectToken("malformed token")
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Claims{}, rejectToken("malformed header")
+	}
+	if header.Algorithm != signingAlgorithm || strings.TrimPrefix(strings.ToLower(header.Type), "application/") != tokenType {
+		return Claims{}, rejectToken("unsupported token type or algorithm")
+	}
+	publicKey, err := verifier.key(ctx, header.KeyID)
+	if err != nil {
+		return Claims{}, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, rejectToken("malformed signature")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature); err != nil {
+		return Claims{}, rejectToken("bad signature")
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Claims{}, rejectToken("malformed claims")
+	}
+	now := verifier.now()
+	switch {
+	case claims.Issuer != verifier.config.Issuer:
+		return Claims{}, rejectToken("foreign issuer")
+	case claims.Subject == "":
+		return Claims{}, rejectToken("missing subject")
+	case claims.ExpiresAt == nil || !now.Before(time.Unix(*claims.ExpiresAt, 0).Add(verifier.config.Leeway)):
+		return Claims{}, rejectToken("expired")
+	case now.Add(verifier.config.Leeway).Before(time.Unix(claims.NotBefore, 0)):
+		return Claims{}, rejectToken("not yet valid")
+	}
+	audience, err := audienceOf(claims.Audience)
+	if err != nil {
+		return Claims{}, rejectToken("malformed audience")
+	}
+	if verifier.config.Audience != "" && !slices.Contains(audience, verifier.config.Audience) {
+		return Claims{}, rejectToken("foreign audience")
+	}
+	return Claims{
+		Issuer:          claims.Issuer,
+		Subject:         claims.Subject,
+		Audience:        audience,
+		ClientID:        claims.ClientID,
+		Scopes:          strings.Fields(claims.Scope),
+		Roles:           claims.Roles,
+		SecurityVersion: claims.SecurityVersion,
+		TokenID:         claims.TokenID,
+		IssuedAt:        time.Unix(claims.IssuedAt, 0),
+		ExpiresAt:       time.Unix(*claims.ExpiresAt, 0),
+	}, nil
+}
+
+// key returns the public key named kid from Config.Keys when set, otherwise
+// from the cached JWKS, refreshing it when the kid is unknown and the last
+// fetch is old enough.
+func (verifier *Verifier) key(ctx context.Context, keyID string) (*rsa.PublicKey, error) {
+	if verifier.config.Keys != nil {
+		return verifier.config.Keys(ctx, keyID)
+	}
+	verifier.mu.Lock()
+	defer verifier.mu.Unlock()
+	if key, ok := verifier.keys[keyID]; ok {
+		return key, nil
+	}
+	if verifier.keys != nil && verifier.now().Sub(verifier.fetchedAt) < jwksRefreshInterval {
+		return nil, rejectToken("unknown signing key")
+	}
+	keys, err := verifier.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	verifier.keys = keys
+	verifier.fetchedAt = verifier.now()
+	if key, ok := keys[keyID]; ok {
+		return key, nil
+	}
+	return nil, rejectToken("unknown signing key")
+}
+
+type jwks struct {
+	Keys []struct {
+		KeyType   string `json:"kty"`
+		KeyID     string `json:"kid"`
+		Use       string `json:"use"`
+		Algorithm string `json:"alg"`
+		Modulus   string `json:"n"`
+		Exponent  string `json:"e"`
+	} `json:"keys"`
+}
+
+func (verifier *Verifier) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, verifier.config.JWKSURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build JWKS request: %w", err)
+	}
+	request.Header.Set("Accept", "application/json")
+	response, err := verifier.config.Client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch JWKS: unexpected status %d", response.StatusCode)
+	}
+	var document jwks
+	if err := json.NewDecoder(response.Body).Decode(&document); err != nil {
+		return nil, fmt.Errorf("decode JWKS: %w", err)
+	}
+	keys := make(map[string]*rsa.PublicKey, len(document.Keys))
+	for _, jwk := range document.Keys {
+		if jwk.KeyType != "RSA" || jwk.KeyID == "" || (jwk.Use != "" && jwk.Use != "sig") || (jwk.Algorithm != "" && jwk.Algorithm != signingAlgorithm) {
+			continue
+		}
+		modulus, err := base64.RawURLEncoding.DecodeString(jwk.Modulus)
+		if err != nil || len(modulus) == 0 {
+			continue
+		}
+		exponent, err := base64.RawURLEncoding.DecodeString(jwk.Exponent)
+		if err != nil || len(exponent) == 0 || len(exponent) > 4 {
+			continue
+		}
+		keys[jwk.KeyID] = &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(new(big.Int).SetBytes(exponent).Int64())}
+	}
+	return keys, nil
+}
+
+func decodeSegment(segment string, destination any) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.NewDecoder(bytes.NewReader(decoded)).Decode(destination)
+}
+
+func audienceOf(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}, nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(raw, &multiple); err != nil {
+		return nil, err
+	}
+	return multiple, nil
+}
+
+func rejectToken(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidToken, reason)
+}
@@ -0,0 +1,213 @@
+package accesstoken
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testIssuer = "https://identity.example.test/crate-api/identity/v1"
+
+// jwksServer publishes key under kid and counts the fetches.
+func jwksServer(t *testing.T, key *rsa.PrivateKey, keyID string) (*httptest.Server, *int) {
+	t.Helper()
+	fetches := new(int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*fetches++
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	t.Cleanup(server.Close)
+	return server, fetches
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, keyID string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "at+jwt", "kid": keyID})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func clientClaims(now time.Time) map[string]any {
+	return map[string]any{
+		"iss":       testIssuer,
+		"sub":       "prototyped",
+		"aud":       "prototyped",
+		"client_id": "prototyped",
+		"exp":       now.Add(5 * time.Minute).Unix(),
+		"iat":       now.Unix(),
+		"nbf":       now.Unix(),
+		"jti":       "01JTOKEN",
+		"scope":     "identity.subjects.read prototype.drills:write",
+	}
+}
+
+// 有效令牌通过校验；过期、签发者不符、受众不符的令牌被拒绝。
+func TestVerifierChecksSignatureAndClaims(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	server, _ := jwksServer(t, key, "key-1")
+	verifier, err := NewVerifier(Config{Issuer: testIssuer + "/", JWKSURL: server.URL})
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	now := time.Now()
+
+	claims, err := verifier.Verify(context.Background(), signToken(t, key, "key-1", clientClaims(now)))
+	if err != nil || !claims.IsClient() || !claims.HasScope("prototype.drills:write") || claims.HasScope("identity.admin") {
+		t.Fatalf("claims = %#v, err = %v", claims, err)
+	}
+
+	expired := clientClaims(now)
+	expired["exp"] = now.Add(-time.Minute).Unix()
+	foreign := clientClaims(now)
+	foreign["iss"] = "https://elsewhere.example.test"
+	subject := clientClaims(now)
+	subject["sub"] = ""
+	for name, token := range map[string]string{
+		"expired":     signToken(t, key, "key-1", expired),
+		"foreign":     signToken(t, key, "key-1", foreign),
+		"no subject":  signToken(t, key, "key-1", subject),
+		"tampered":    signToken(t, key, "key-1", clientClaims(now))[:40] + "x",
+		"not a token": "not-a-token",
+	} {
+		if _, err := verifier.Verify(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("%s: err = %v, want ErrInvalidToken", name, err)
+		}
+	}
+
+	audienceVerifier, _ := NewVerifier(Config{Issuer: testIssuer, JWKSURL: server.URL, Audience: "nexus"})
+	if _, err := audienceVerifier.Verify(context.Background(), signToken(t, key, "key-1", clientClaims(now))); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("foreign audience: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+// 未知 kid 在刷新间隔内不会重复拉取 JWKS；identityd 不可达时返回非令牌错误。
+func TestVerifierLimitsJWKSRefreshAndReportsOutage(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	server, fetches := jwksServer(t, key, "key-1")
+	verifier, _ := NewVerifier(Config{Issuer: testIssuer, JWKSURL: server.URL})
+	now := time.Now()
+	for range 3 {
+		if _, err := verifier.Verify(context.Background(), signToken(t, key, "key-2", clientClaims(now))); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("unknown kid: err = %v, want ErrInvalidToken", err)
+		}
+	}
+	if *fetches != 1 {
+		t.Fatalf("JWKS fetches = %d, want 1", *fetches)
+	}
+	verifier.now = func() time.Time { return now.Add(2 * jwksRefreshInterval) }
+	verifier.Verify(context.Background(), signToken(t, key, "key-2", clientClaims(now.Add(2*jwksRefreshInterval))))
+	if *fetches != 2 {
+		t.Fatalf("JWKS fetches after the refresh interval = %d, want 2", *fetches)
+	}
+
+	server.Close()
+	unreachable, _ := NewVerifier(Config{Issuer: testIssuer, JWKSURL: server.URL})
+	if _, err := unreachable.Verify(context.Background(), signToken(t, key, "key-1", clientClaims(now))); err == nil || errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("unreachable JWKS: err = %v, want a transport error", err)
+	}
+}
+
+// 设置 Keys 时按 kid 向其取公钥而不拉取 JWKS；其错误原样返回。
+func TestVerifierLooksUpKeysWithKeyFunc(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	server, fetches := jwksServer(t, key, "key-1")
+	errKeyStore := errors.New("key store unavailable")
+	verifier, _ := NewVerifier(Config{Issuer: testIssuer, JWKSURL: server.URL, Keys: func(_ context.Context, keyID string) (*rsa.PublicKey, error) {
+		switch keyID {
+		case "key-1":
+			return &key.PublicKey, nil
+		case "broken":
+			return nil, errKeyStore
+		}
+		return nil, fmt.Errorf("%w: unknown signing key", ErrInvalidToken)
+	}})
+	now := time.Now()
+	if _, err := verifier.Verify(context.Background(), signToken(t, key, "key-1", clientClaims(now))); err != nil {
+		t.Fatalf("known kid: %v", err)
+	}
+	if _, err := verifier.Verify(context.Background(), signToken(t, key, "key-2", clientClaims(now))); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("unknown kid: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := verifier.Verify(context.Background(), signToken(t, key, "broken", clientClaims(now))); !errors.Is(err, errKeyStore) {
+		t.Fatalf("failing key store: err = %v, want %v", err, errKeyStore)
+	}
+	if *fetches != 0 {
+		t.Fatalf("JWKS fetches = %d, want 0", *fetches)
+	}
+}
+
+// 中间件：缺少令牌 401，缺少 scope 403，通过后处理器可从上下文取得声明。
+func TestMiddlewareRequiresScopes(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	server, _ := jwksServer(t, key, "key-1")
+	verifier, _ := NewVerifier(Config{Issuer: testIssuer, JWKSURL: server.URL})
+	token := signToken(t, key, "key-1", clientClaims(time.Now()))
+	protected := func(scopes ...string) http.Handler {
+		return verifier.Middleware(scopes...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := FromContext(r.Context())
+			if !ok {
+				t.Fatalf("claims missing from context")
+			}
+			w.Write([]byte(claims.ClientID))
+		}))
+	}
+	serve := func(handler http.Handler, authorization string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	if response := serve(protected("prototype.drills:write"), ""); response.Code != http.StatusUnauthorized || response.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Fatalf("without token = %d %q", response.Code, response.Header().Get("WWW-Authenticate"))
+	}
+	if response := serve(protected("prototype.drills:write"), "Bearer not-a-token"); response.Code != http.StatusUnauthorized {
+		t.Fatalf("invalid token = %d", response.Code)
+	}
+	if response := serve(protected("prototype.scenarios:write"), "Bearer "+token); response.Code != http.StatusForbidden || response.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("missing scope = %d %q", response.Code, response.Header().Get("Content-Type"))
+	}
+	if response := serve(protected("prototype.drills:write"), "bearer "+token); response.Code != http.StatusOK || response.Body.String() != "prototyped" {
+		t.Fatalf("valid token = %d %s", response.Code, response.Body.String())
+	}
+}
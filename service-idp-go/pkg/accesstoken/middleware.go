@@ -0,0 +1,71 @@
+package accesstoken
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+type claimsKey struct{}
+
+// WithClaims returns a copy of ctx carrying claims.
+func WithClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// FromContext returns the claims stored by Middleware; ok is false for a
+// request that did not pass through it.
+func FromContext(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(Claims)
+	return claims, ok
+}
+
+// Middleware requires an Authorization: Bearer access token holding every
+// scope in requiredScopes and stores its claims in the request context. It
+// answers 401 for a missing or invalid token, 403 for a missing scope and
+// 503 when the JWKS cannot be fetched, each with an RFC 6750
+// WWW-Authenticate header and an RFC 9457 Problem Details body.
+func (verifier *Verifier) Middleware(requiredScopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+			scheme, token, found := strings.Cut(request.Header.Get("Authorization"), " ")
+			if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+				responseWriter.Header().Set("WWW-Authenticate", `Bearer`)
+				writeProblem(responseWriter, http.StatusUnauthorized, "bearer access token required")
+				return
+			}
+			claims, err := verifier.Verify(request.Context(), strings.TrimSpace(token))
+			if errors.Is(err, ErrInvalidToken) {
+				responseWriter.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				writeProblem(responseWriter, http.StatusUnauthorized, "invalid access token")
+				return
+			}
+			if err != nil {
+				writeProblem(responseWriter, http.StatusServiceUnavailable, "access token could not be verified")
+				return
+			}
+			for _, scope := range requiredScopes {
+				if !claims.HasScope(scope) {
+					responseWriter.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+strings.Join(requiredScopes, " ")+`"`)
+					writeProblem(responseWriter, http.StatusForbidden, "the access token lacks scope "+scope)
+					return
+				}
+			}
+			next.ServeHTTP(responseWriter, request.WithContext(WithClaims(request.Context(), claims)))
+		})
+	}
+}
+
+func writeProblem(responseWriter http.ResponseWriter, status int, detail string) {
+	responseWriter.Header().Set("Content-Type", "application/problem+json")
+	responseWriter.Header().Set("Cache-Control", "no-store")
+	responseWriter.WriteHeader(status)
+	_ = json.NewEncoder(responseWriter).Encode(map[string]any{
+		"type":   "about:blank",
+		"title":  http.StatusText(status),
+		"status": status,
+		"detail": detail,
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/assignments"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/auth"
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/chapters"
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/config"
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/courses"
@@ -34,6 +36,9 @@ const databaseBootstrapTimeout = 5 * time.Second
 func main() {
 	runContext, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(runContext, os.Args[2:], os.Getenv, os.Stdout, os.Stderr, database.Open))
+	}
 	os.Exit(run(runContext, os.Getenv, os.Stdout, os.Stderr))
 }
 
@@ -53,42 +58,68 @@ func run(ctx context.Context, lookup func(string) string, stdout, stderr io.Writ
 	connector := database.New(configuration.DatabaseURL)
 	// An unavailable database must never prevent startup: the failure is
 	// logged and the service keeps serving without a database. A later
-	// Connect call (or restart) retries the migrations.
-	bootstrapDatabase(ctx, logger, connector)
+	// Connect call (or restart) retries the migrations. Migration drift is
+	// the exception: the schema no longer matches the code, so startup
+	// stops instead of falling back to the in-memory stores.
+	if err := bootstrapDatabase(ctx, logger, connector); err != nil {
+		logger.Error("database schema drifted; run `prototyped migrate status`", "error", err)
+		return 1
+	}
 	defer connector.Close()
 
+	// The stores are PostgreSQL-backed when the database came up and
+	// in-memory otherwise, so the service keeps serving either way.
+	stores := newStores(connector)
+	logger.Info("stores ready", "backend", stores.backend)
+
 	// The four built-in drill scenarios (with their steps and assessment
-	// points) are seeded on the shared in-memory store before the router
-	// is built, so the first request already sees them. The seed is
-	// idempotent (dedupe by scenario name) and a failure must never
+	// points) are seeded on the shared drill store before the router is
+	// built, so the first request already sees them. The seed is
+	// idempotent (dedupe by scenario name, so it is a no-op on a database
+	// already seeded by migration 000016) and a failure must never
 	// prevent startup: it is logged and the service keeps serving
 	// whatever the store holds, mirroring bootstrapDatabase.
-	drillStore := drills.NewInMemoryStore()
-	if err := drills.Seed(ctx, drills.NewService(drillStore)); err != nil {
+	if err := drills.Seed(ctx, drills.NewService(stores.drills)); err != nil {
 		logger.Warn("seed drill scenarios", "error", err)
 	}
 
 	// The fifteen built-in evaluation indicators (6 dimensions × 15
 	// indicators, demo flags separating the seven computable from the
-	// eight presentation rows) are seeded on the shared in-memory store
+	// eight presentation rows) are seeded on the shared indicator store
 	// before the router is built, so the first request already sees the
-	// dictionary. The seed is idempotent (dedupe by indicator title)
-	// and a failure must never prevent startup: it is logged and the
-	// service keeps serving whatever the store holds, mirroring the
-	// drill seed and bootstrapDatabase.
-	evaluationStore := evaluation.NewInMemoryStore()
-	if err := evaluation.Seed(ctx, evaluation.NewService(evaluationStore)); err != nil {
+	// dictionary. The seed is idempotent (dedupe by indicator title, a
+	// no-op after migration 000023) and a failure must never prevent
+	// startup: it is logged and the service keeps serving whatever the
+	// store holds, mirroring the drill seed and bootstrapDatabase.
+	if err := evaluation.Seed(ctx, evaluation.NewService(stores.evaluation)); err != nil {
 		logger.Warn("seed evaluation indicators", "error", err)
 	}
 
+	// The drill timeline service owns the timers of the run-scoped
+	// scheduler, so it is built here and handed to the router ready-made;
+	// it is stopped on the way out (the pending injects stay in the
+	// store and are re-armed by the next start). A firing that fails on
+	// the store is logged and retried with a backoff.
+	timeline := drills.NewTimelineService(stores.timeline, stores.drills)
+	timeline.SetLogger(logger)
+	defer timeline.Stop()
+
+	// Authentication is on as soon as identityd or a token issuer is
+	// configured; the bearer token is tried first, so an API client that
+	// also holds a stale browser cookie is judged by its token.
+	authenticator, err := newAuthenticator(configuration)
+	if err != nil {
+		logger.Error("configure authentication", "error", err)
+		return 1
+	}
+	if authenticator == nil {
+		logger.Warn("authentication disabled; every route is anonymous")
+	}
+
+	requestContext, stopRequests := context.WithCancel(context.Background())
+	defer stopRequests()
 	server := &http.Server{
 		Addr: configuration.Address(),
-		// The prototype runs courses, chapters, questions, assignments,
-		// learning progress, exam papers, online exam records, drill
-		// scenario templates, dispatch command sessions, the opinion
-		// event configurations and the evaluation indicator dictionary
-		// on in-memory stores; a database-backed store replaces this at
-		// the composition root once the slices land on a real backend.
 		// The drill store is shared with the startup seed above; the
 		// dispatch store backs the command session of each drill run;
 		// the opinion store backs the opinion event configuration of
@@ -97,19 +128,38 @@ func run(ctx context.Context, lookup func(string) string, stdout, stderr io.Writ
 		// store is shared with the indicator seed above.
 		Handler: httpapi.NewMux(
 			configuration.CORSAllowedOrigins,
-			courses.NewInMemoryStore(),
-			chapters.NewInMemoryStore(),
-			questions.NewInMemoryStore(),
-			assignments.NewInMemoryStore(),
-			progress.NewInMemoryStore(),
-			papers.NewInMemoryStore(),
-			examrecords.NewInMemoryStore(),
-			drillStore,
-			dispatch.NewInMemoryStore(),
-			opinion.NewInMemoryStore(),
-			evaluationStore,
+			stores.courses,
+			stores.chapters,
+			stores.questions,
+			stores.assignments,
+			stores.progress,
+			stores.papers,
+			stores.examRecords,
+			stores.drills,
+			stores.dispatch,
+			stores.opinion,
+			stores.evaluation,
+			stores.evaluationScores,
+			stores.evaluationReports,
+			timeline,
+			authenticator,
 		),
 		ReadHeaderTimeout: 5 * time.Second,
+		// Requests derive from requestContext, which is cancelled as
+		// soon as Shutdown starts: the SSE run streams never end on
+		// their own and would otherwise hold Shutdown until its
+		// timeout. Their clients reconnect with Last-Event-ID.
+		BaseContext: func(net.Listener) context.Context { return requestContext },
+	}
+	server.RegisterOnShutdown(stopRequests)
+
+	// The pending injects of the runs still 进行中 are re-armed once the
+	// router wired the run stream publisher, so a run survives a restart
+	// with its timeline intact (overdue injects fire at once). A failure
+	// must never prevent startup: it is logged and the live injects of
+	// the exercise controller keep working, mirroring the seeds above.
+	if err := timeline.Resume(ctx); err != nil {
+		logger.Warn("resume drill timeline", "error", err)
 	}
 
 	serverErrors := make(chan error, 1)
@@ -134,15 +184,109 @@ func run(ctx context.Context, lookup func(string) string, stdout, stderr io.Writ
 	return 0
 }
 
-// bootstrapDatabase attempts to connect and run the embedded migrations
+// newAuthenticator builds the authenticator chain of the configured
+// methods, or nil when none is configured.
+func newAuthenticator(configuration config.Config) (auth.Authenticator, error) {
+	if !configuration.AuthEnabled() {
+		return nil, nil
+	}
+	var chain auth.Chain
+	if configuration.JWKSURL != "" {
+		tokens, err := auth.NewTokenAuthenticator(auth.TokenConfig{
+			JWKSURL:  configuration.JWKSURL,
+			Issuer:   configuration.TokenIssuer,
+			Audience: configuration.TokenAudience,
+		})
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, tokens)
+	}
+	if configuration.IdentityBaseURL != "" {
+		chain = append(chain, auth.NewSessionAuthenticator(configuration.IdentityBaseURL, nil))
+	}
+	return chain, nil
+}
+
+// bootstrapDatabase attempts to connect and run the pending migrations
 // within a bounded window. Connection and migration failures are logged and
 // swallowed: the service starts and keeps serving even without a database.
-func bootstrapDatabase(ctx context.Context, logger *slog.Logger, connector *database.Connector) {
+// Only database.ErrMigrationDrift is returned, since an edited or missing
+// applied migration is not something a retry can fix.
+func bootstrapDatabase(ctx context.Context, logger *slog.Logger, connector *database.Connector) error {
 	connectContext, cancel := context.WithTimeout(ctx, databaseBootstrapTimeout)
 	defer cancel()
 	if err := connector.Connect(connectContext); err != nil {
+		if errors.Is(err, database.ErrMigrationDrift) {
+			return err
+		}
 		logger.Warn("database unavailable; continuing without database", "error", err)
-		return
+		return nil
 	}
 	logger.Info("database ready")
+	return nil
+}
+
+// stores bundles the store of every slice, all on the same backend.
+type stores struct {
+	backend           string
+	courses           courses.Store
+	chapters          chapters.Store
+	questions         questions.Store
+	assignments       assignments.Store
+	progress          progress.Store
+	papers            papers.Store
+	examRecords       examrecords.Store
+	drills            drills.Store
+	timeline          drills.TimelineStore
+	dispatch          dispatch.Store
+	opinion           opinion.Store
+	evaluation        evaluation.Store
+	evaluationScores  evaluation.ScoreStore
+	evaluationReports evaluation.ReportStore
+}
+
+// newStores returns the PostgreSQL-backed stores when the connector is
+// connected and the in-memory stores otherwise. The choice is made once
+// at startup: a database that comes up later is only picked up by a
+// restart, which keeps every slice on one backend for the lifetime of
+// the process.
+func newStores(connector *database.Connector) stores {
+	db, err := connector.Querier()
+	if err != nil {
+		return stores{
+			backend:           "memory",
+			courses:           courses.NewInMemoryStore(),
+			chapters:          chapters.NewInMemoryStore(),
+			questions:         questions.NewInMemoryStore(),
+			assignments:       assignments.NewInMemoryStore(),
+			progress:          progress.NewInMemoryStore(),
+			papers:            papers.NewInMemoryStore(),
+			examRecords:       examrecords.NewInMemoryStore(),
+			drills:            drills.NewInMemoryStore(),
+			timeline:          drills.NewInMemoryTimelineStore(),
+			dispatch:          dispatch.NewInMemoryStore(),
+			opinion:           opinion.NewInMemoryStore(),
+			evaluation:        evaluation.NewInMemoryStore(),
+			evaluationScores:  evaluation.NewInMemoryScoreStore(),
+			evaluationReports: evaluation.NewInMemoryReportStore(),
+		}
+	}
+	return stores{
+		backend:           "postgres",
+		courses:           courses.NewPostgresStore(db),
+		chapters:          chapters.NewPostgresStore(db),
+		questions:         questions.NewPostgresStore(db),
+		assignments:       assignments.NewPostgresStore(db),
+		progress:          progress.NewPostgresStore(db),
+		papers:            papers.NewPostgresStore(db),
+		examRecords:       examrecords.NewPostgresStore(db),
+		drills:            drills.NewPostgresStore(db),
+		timeline:          drills.NewPostgresTimelineStore(db),
+		dispatch:          dispatch.NewPostgresStore(db),
+		opinion:           opinion.NewPostgresStore(db),
+		evaluation:        evaluation.NewPostgresStore(db),
+		evaluationScores:  evaluation.NewPostgresScoreStore(db),
+		evaluationReports: evaluation.NewPostgresReportStore(db),
+	}
 }
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/config"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database"
+)
+
+// migrateUsage is printed for a missing or unknown migrate subcommand.
+const migrateUsage = "usage: prototyped migrate up|down|status|to VERSION"
+
+// runMigrate implements `prototyped migrate up|down|status|to VERSION`
+// against the configured database: up applies every pending migration,
+// down rolls back the latest one, to moves the schema to VERSION (0 rolls
+// back everything) and status lists every migration with its applied
+// state. Unlike the server, the command needs the database: a connection
+// failure exits 1. Usage errors exit 2. open is injectable so the command
+// is testable without a PostgreSQL server.
+func runMigrate(ctx context.Context, args []string, lookup func(string) string, stdout, stderr io.Writer, open database.Opener) int {
+	logger := slog.New(slog.NewTextHandler(stderr, nil))
+	command, version, ok := parseMigrateArgs(args)
+	if !ok {
+		fmt.Fprintln(stderr, migrateUsage)
+		return 2
+	}
+
+	configuration, err := config.LoadFromLookup(lookup)
+	if err != nil {
+		logger.Error("load configuration", "error", err)
+		return 1
+	}
+	conn, err := open(ctx, configuration.DatabaseURL)
+	if err != nil {
+		logger.Error("open database", "error", err)
+		return 1
+	}
+	defer conn.Close()
+
+	switch command {
+	case "up":
+		steps, err := database.MigrateUp(ctx, conn)
+		printMigrationSteps(stdout, steps)
+		if err != nil {
+			logger.Error("migrate up", "error", err)
+			return 1
+		}
+		if len(steps) == 0 {
+			fmt.Fprintln(stdout, "schema is up to date")
+		}
+	case "down":
+		step, err := database.MigrateDown(ctx, conn)
+		if err != nil {
+			logger.Error("migrate down", "error", err)
+			return 1
+		}
+		printMigrationSteps(stdout, []database.MigrationStep{step})
+	case "to":
+		steps, err := database.MigrateTo(ctx, conn, version)
+		printMigrationSteps(stdout, steps)
+		if err != nil {
+			logger.Error("migrate to", "version", version, "error", err)
+			return 1
+		}
+		if len(steps) == 0 {
+			fmt.Fprintf(stdout, "schema is already at %06d\n", version)
+		}
+	case "status":
+		statuses, err := database.MigrationStatuses(ctx, conn)
+		if err != nil {
+			logger.Error("migrate status", "error", err)
+			return 1
+		}
+		printMigrationStatuses(stdout, statuses)
+	}
+	return 0
+}
+
+// parseMigrateArgs validates the subcommand and, for `to`, its
+// non-negative version argument.
+func parseMigrateArgs(args []string) (command string, version int, ok bool) {
+	if len(args) == 0 {
+		return "", 0, false
+	}
+	switch args[0] {
+	case "up", "down", "status":
+		return args[0], 0, len(args) == 1
+	case "to":
+		if len(args) != 2 {
+			return "", 0, false
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return "", 0, false
+		}
+		return "to", version, true
+	}
+	return "", 0, false
+}
+
+// printMigrationSteps prints one line per executed step, e.g.
+// "up 000032_name".
+func printMigrationSteps(out io.Writer, steps []database.MigrationStep) {
+	for _, step := range steps {
+		fmt.Fprintf(out, "%s %06d_%s\n", step.Direction, step.Version, step.Name)
+	}
+}
+
+// printMigrationStatuses prints the status table: one row per migration
+// with its state (applied, pending or drifted), the applied time and
+// whether it ships a down file.
+func printMigrationStatuses(out io.Writer, statuses []database.MigrationStatus) {
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "VERSION\tNAME\tSTATE\tAPPLIED AT\tDOWN")
+	for _, status := range statuses {
+		state, appliedAt, down := "pending", "-", "no"
+		if status.Applied {
+			state = "applied"
+		}
+		if status.Drift {
+			state = "drifted"
+		}
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		if status.Reversible {
+			down = "yes"
+		}
+		fmt.Fprintf(table, "%06d\t%s\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt, down)
+	}
+	_ = table.Flush()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
+)
+
+// emptyDatabase is a Conn whose schema_migrations table is always empty:
+// every statement succeeds and every query returns no rows.
+type emptyDatabase struct{}
+
+func (emptyDatabase) Ping(ctx context.Context) error { return nil }
+
+func (emptyDatabase) Session(ctx context.Context, fn func(database.Session) error) error {
+	return fn(emptyDatabase{})
+}
+
+func (emptyDatabase) Close() error { return nil }
+
+func (emptyDatabase) Exec(ctx context.Context, sql string, args ...any) error { return nil }
+
+func (emptyDatabase) Query(ctx context.Context, sql string, scan func(row pgstore.Row) error, args ...any) error {
+	return nil
+}
+
+func openEmpty(ctx context.Context, dsn string) (database.Conn, error) {
+	return emptyDatabase{}, nil
+}
+
+var migrateLookup = valuesLookup(map[string]string{"PITCHFORK_DB_PASSWORD": "test-pw"})
+
+func TestRunMigrateRejectsInvalidUsage(t *testing.T) {
+	for _, args := range [][]string{nil, {"sideways"}, {"to"}, {"to", "-1"}, {"to", "x"}, {"up", "extra"}} {
+		var stderr bytes.Buffer
+		code := runMigrate(context.Background(), args, migrateLookup, &bytes.Buffer{}, &stderr, openEmpty)
+		if code != 2 || !strings.Contains(stderr.String(), "usage: prototyped migrate") {
+			t.Fatalf("args %q: code = %d stderr = %q, want the usage and exit 2", args, code, stderr.String())
+		}
+	}
+}
+
+func TestRunMigrateUpPrintsAppliedMigrations(t *testing.T) {
+	var stdout bytes.Buffer
+	code := runMigrate(context.Background(), []string{"up"}, migrateLookup, &stdout, &bytes.Buffer{}, openEmpty)
+	if code != 0 {
+		t.Fatalf("exit code = %d, want 0", code)
+	}
+	if !strings.HasPrefix(stdout.String(), "up 000001_create_service_meta\n") {
+		t.Fatalf("stdout %q does not list the applied migrations", stdout.String())
+	}
+}
+
+func TestRunMigrateStatusListsPendingMigrations(t *testing.T) {
+	var stdout bytes.Buffer
+	code := runMigrate(context.Background(), []string{"status"}, migrateLookup, &stdout, &bytes.Buffer{}, openEmpty)
+	if code != 0 {
+		t.Fatalf("exit code = %d, want 0", code)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], "VERSION") {
+		t.Fatalf("stdout %q is not a status table", stdout.String())
+	}
+	if fields := strings.Fields(lines[1]); len(fields) != 5 || fields[0] != "000001" || fields[2] != "pending" || fields[4] != "yes" {
+		t.Fatalf("first row = %q, want 000001 pending with a down file", lines[1])
+	}
+}
+
+func TestRunMigrateDownOnEmptySchemaFails(t *testing.T) {
+	var stderr bytes.Buffer
+	code := runMigrate(context.Background(), []string{"down"}, migrateLookup, &bytes.Buffer{}, &stderr, openEmpty)
+	if code != 1 || !strings.Contains(stderr.String(), "no applied migration") {
+		t.Fatalf("code = %d stderr = %q, want exit 1 with the reason", code, stderr.String())
+	}
+}
+
+func TestRunMigrateFailsWithoutDatabase(t *testing.T) {
+	unreachable := func(ctx context.Context, dsn string) (database.Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+	var stderr bytes.Buffer
+	code := runMigrate(context.Background(), []string{"status"}, migrateLookup, &bytes.Buffer{}, &stderr, unreachable)
+	if code != 1 || !strings.Contains(stderr.String(), "open database") {
+		t.Fatalf("code = %d stderr = %q, want exit 1 reporting the connection", code, stderr.String())
+	}
+}
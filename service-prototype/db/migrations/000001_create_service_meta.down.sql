@@ -0,0 +1,6 @@
+-- 000001_create_service_meta.down.sql
+-- Reverts 000001_create_service_meta.sql: drops the service_prototype_meta
+-- table. Later migrations are rolled back first, so no dependent table
+-- remains at this point.
+
+DROP TABLE IF EXISTS service_prototype_meta;
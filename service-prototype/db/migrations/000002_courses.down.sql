@@ -0,0 +1,5 @@
+-- 000002_courses.down.sql
+-- Reverts 000002_courses.sql: drops the courses table. Later migrations
+-- are rolled back first, so no dependent table remains at this point.
+
+DROP TABLE IF EXISTS courses;
@@ -0,0 +1,6 @@
+-- 000003_course_chapters.down.sql
+-- Reverts 000003_course_chapters.sql: drops the course_chapters table.
+-- Later migrations are rolled back first, so no dependent table remains at
+-- this point.
+
+DROP TABLE IF EXISTS course_chapters;
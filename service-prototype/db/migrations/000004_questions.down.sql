@@ -0,0 +1,6 @@
+-- 000004_questions.down.sql
+-- Reverts 000004_questions.sql: drops the questions table. Later
+-- migrations are rolled back first, so no dependent table remains at this
+-- point.
+
+DROP TABLE IF EXISTS questions;
@@ -0,0 +1,6 @@
+-- 000005_exam_papers.down.sql
+-- Reverts 000005_exam_papers.sql: drops the exam_papers table. Later
+-- migrations are rolled back first, so no dependent table remains at this
+-- point.
+
+DROP TABLE IF EXISTS exam_papers;
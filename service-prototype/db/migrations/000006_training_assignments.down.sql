@@ -0,0 +1,6 @@
+-- 000006_training_assignments.down.sql
+-- Reverts 000006_training_assignments.sql: drops the training_assignments
+-- table. Later migrations are rolled back first, so no dependent table
+-- remains at this point.
+
+DROP TABLE IF EXISTS training_assignments;
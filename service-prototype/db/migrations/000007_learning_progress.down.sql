@@ -0,0 +1,6 @@
+-- 000007_learning_progress.down.sql
+-- Reverts 000007_learning_progress.sql: drops the learning_progress table.
+-- Later migrations are rolled back first, so no dependent table remains at
+-- this point.
+
+DROP TABLE IF EXISTS learning_progress;
@@ -0,0 +1,6 @@
+-- 000008_exam_records.down.sql
+-- Reverts 000008_exam_records.sql: drops the exam_records table. Later
+-- migrations are rolled back first, so no dependent table remains at this
+-- point.
+
+DROP TABLE IF EXISTS exam_records;
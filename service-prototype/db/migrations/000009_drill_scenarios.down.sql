@@ -0,0 +1,6 @@
+-- 000009_drill_scenarios.down.sql
+-- Reverts 000009_drill_scenarios.sql: drops the drill_scenarios table.
+-- Later migrations are rolled back first, so no dependent table remains at
+-- this point.
+
+DROP TABLE IF EXISTS drill_scenarios;
@@ -0,0 +1,6 @@
+-- 000010_drill_scenario_steps.down.sql
+-- Reverts 000010_drill_scenario_steps.sql: drops the drill_scenario_steps
+-- table. Later migrations are rolled back first, so no dependent table
+-- remains at this point.
+
+DROP TABLE IF EXISTS drill_scenario_steps;
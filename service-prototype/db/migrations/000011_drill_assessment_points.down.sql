@@ -0,0 +1,6 @@
+-- 000011_drill_assessment_points.down.sql
+-- Reverts 000011_drill_assessment_points.sql: drops the
+-- drill_assessment_points table. Later migrations are rolled back first,
+-- so no dependent table remains at this point.
+
+DROP TABLE IF EXISTS drill_assessment_points;
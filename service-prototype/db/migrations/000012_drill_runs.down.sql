@@ -0,0 +1,6 @@
+-- 000012_drill_runs.down.sql
+-- Reverts 000012_drill_runs.sql: drops the drill_runs table. Later
+-- migrations are rolled back first, so no dependent table remains at this
+-- point.
+
+DROP TABLE IF EXISTS drill_runs;
@@ -0,0 +1,6 @@
+-- 000013_drill_step_records.down.sql
+-- Reverts 000013_drill_step_records.sql: drops the drill_step_records
+-- table. Later migrations are rolled back first, so no dependent table
+-- remains at this point.
+
+DROP TABLE IF EXISTS drill_step_records;
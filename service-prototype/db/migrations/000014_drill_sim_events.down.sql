@@ -0,0 +1,6 @@
+-- 000014_drill_sim_events.down.sql
+-- Reverts 000014_drill_sim_events.sql: drops the drill_sim_events table.
+-- Later migrations are rolled back first, so no dependent table remains at
+-- this point.
+
+DROP TABLE IF EXISTS drill_sim_events;
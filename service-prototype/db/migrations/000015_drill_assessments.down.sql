@@ -0,0 +1,6 @@
+-- 000015_drill_assessments.down.sql
+-- Reverts 000015_drill_assessments.sql: drops the drill_assessments table.
+-- Later migrations are rolled back first, so no dependent table remains at
+-- this point.
+
+DROP TABLE IF EXISTS drill_assessments;
@@ -0,0 +1,16 @@
+-- 000016_drill_seed.down.sql
+-- Reverts 000016_drill_seed.sql: removes the four built-in drill scenarios
+-- by their fixed ids; their seeded steps and assessment points go with
+-- them through the ON DELETE CASCADE foreign keys. Only rows still marked
+-- created_by='system' are removed. A seeded scenario still referenced by a
+-- drill run blocks the rollback (drill_runs.scenario_id has no cascade),
+-- so a rollback never discards run history silently.
+
+DELETE FROM drill_scenarios
+WHERE created_by = 'system'
+  AND id IN (
+    '06FZWKXC9XYMF52K97P0CDBHK0',
+    '06FZWKXC9WREAGPKXQPXCWY604',
+    '06FZWKXC9XCWPY4953NMC168FR',
+    '06FZWKXC9Z2R3Q019A0PA8PMVM'
+  );
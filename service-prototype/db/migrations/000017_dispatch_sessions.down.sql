@@ -0,0 +1,6 @@
+-- 000017_dispatch_sessions.down.sql
+-- Reverts 000017_dispatch_sessions.sql: drops the dispatch_sessions table.
+-- Later migrations are rolled back first, so no dependent table remains at
+-- this point.
+
+DROP TABLE IF EXISTS dispatch_sessions;
@@ -0,0 +1,6 @@
+-- 000018_dispatch_orders.down.sql
+-- Reverts 000018_dispatch_orders.sql: drops the dispatch_orders table.
+-- Later migrations are rolled back first, so no dependent table remains at
+-- this point.
+
+DROP TABLE IF EXISTS dispatch_orders;
@@ -0,0 +1,6 @@
+-- 000019_dispatch_department_reports.down.sql
+-- Reverts 000019_dispatch_department_reports.sql: drops the
+-- dispatch_department_reports table. Later migrations are rolled back
+-- first, so no dependent table remains at this point.
+
+DROP TABLE IF EXISTS dispatch_department_reports;
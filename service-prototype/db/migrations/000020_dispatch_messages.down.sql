@@ -0,0 +1,6 @@
+-- 000020_dispatch_messages.down.sql
+-- Reverts 000020_dispatch_messages.sql: drops the dispatch_messages table.
+-- Later migrations are rolled back first, so no dependent table remains at
+-- this point.
+
+DROP TABLE IF EXISTS dispatch_messages;
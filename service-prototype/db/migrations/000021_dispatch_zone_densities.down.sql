@@ -0,0 +1,6 @@
+-- 000021_dispatch_zone_densities.down.sql
+-- Reverts 000021_dispatch_zone_densities.sql: drops the
+-- dispatch_zone_densities table. Later migrations are rolled back first,
+-- so no dependent table remains at this point.
+
+DROP TABLE IF EXISTS dispatch_zone_densities;
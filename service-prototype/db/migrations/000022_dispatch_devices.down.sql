@@ -0,0 +1,6 @@
+-- 000022_dispatch_devices.down.sql
+-- Reverts 000022_dispatch_devices.sql: drops the dispatch_devices table.
+-- Later migrations are rolled back first, so no dependent table remains at
+-- this point.
+
+DROP TABLE IF EXISTS dispatch_devices;
@@ -0,0 +1,7 @@
+-- 000023_evaluation_indicators.down.sql
+-- Reverts 000023_evaluation_indicators.sql: drops the
+-- evaluation_indicators table. The seeded indicator dictionary goes with
+-- the table. Later migrations are rolled back first, so no dependent table
+-- remains at this point.
+
+DROP TABLE IF EXISTS evaluation_indicators;
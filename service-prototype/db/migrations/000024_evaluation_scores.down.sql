@@ -0,0 +1,6 @@
+-- 000024_evaluation_scores.down.sql
+-- Reverts 000024_evaluation_scores.sql: drops the evaluation_scores table.
+-- The expert-score unique index goes with the table. Later migrations are
+-- rolled back first, so no dependent table remains at this point.
+
+DROP TABLE IF EXISTS evaluation_scores;
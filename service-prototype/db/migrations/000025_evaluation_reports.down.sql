@@ -0,0 +1,6 @@
+-- 000025_evaluation_reports.down.sql
+-- Reverts 000025_evaluation_reports.sql: drops the evaluation_reports
+-- table. Later migrations are rolled back first, so no dependent table
+-- remains at this point.
+
+DROP TABLE IF EXISTS evaluation_reports;
@@ -0,0 +1,6 @@
+-- 000026_opinion_events.down.sql
+-- Reverts 000026_opinion_events.sql: drops the opinion_events table. Later
+-- migrations are rolled back first, so no dependent table remains at this
+-- point.
+
+DROP TABLE IF EXISTS opinion_events;
@@ -0,0 +1,6 @@
+-- 000027_opinion_posts.down.sql
+-- Reverts 000027_opinion_posts.sql: drops the opinion_posts table. Later
+-- migrations are rolled back first, so no dependent table remains at this
+-- point.
+
+DROP TABLE IF EXISTS opinion_posts;
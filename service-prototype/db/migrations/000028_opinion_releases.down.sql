@@ -0,0 +1,6 @@
+-- 000028_opinion_releases.down.sql
+-- Reverts 000028_opinion_releases.sql: drops the opinion_releases table.
+-- Later migrations are rolled back first, so no dependent table remains at
+-- this point.
+
+DROP TABLE IF EXISTS opinion_releases;
@@ -0,0 +1,6 @@
+-- 000029_opinion_complaints.down.sql
+-- Reverts 000029_opinion_complaints.sql: drops the opinion_complaints
+-- table. Later migrations are rolled back first, so no dependent table
+-- remains at this point.
+
+DROP TABLE IF EXISTS opinion_complaints;
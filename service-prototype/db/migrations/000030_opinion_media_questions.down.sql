@@ -0,0 +1,6 @@
+-- 000030_opinion_media_questions.down.sql
+-- Reverts 000030_opinion_media_questions.sql: drops the
+-- opinion_media_questions table. Later migrations are rolled back first,
+-- so no dependent table remains at this point.
+
+DROP TABLE IF EXISTS opinion_media_questions;
@@ -0,0 +1,6 @@
+-- 000031_opinion_reviews.down.sql
+-- Reverts 000031_opinion_reviews.sql: drops the opinion_reviews table.
+-- Later migrations are rolled back first, so no dependent table remains at
+-- this point.
+
+DROP TABLE IF EXISTS opinion_reviews;
@@ -0,0 +1,6 @@
+-- 000032_drill_timeline.down.sql
+-- Reverts 000032_drill_timeline.sql: drops the drill_run_injects and the
+-- drill_scenario_injects tables (their indexes go with them).
+
+DROP TABLE IF EXISTS drill_run_injects;
+DROP TABLE IF EXISTS drill_scenario_injects;
@@ -0,0 +1,51 @@
+-- 000032_drill_timeline.sql
+-- Scripted drill timeline (演练时间线) of the scenario-simulation-drill
+-- module. drill_scenario_injects holds the timeline of a scenario
+-- template: each inject raises a sim event of event_type offset_seconds
+-- after the run started, its payload rendered from payload_template
+-- (placeholders such as ${run_id} are substituted by the service). The
+-- injects are deleted with their scenario (ON DELETE CASCADE, mirroring
+-- drill_scenario_steps).
+--
+-- drill_run_injects holds the schedule of a run: starting the run copies
+-- the scenario timeline into it (scenario_inject_id names the template
+-- entry, empty for the injects the exercise controller adds while the
+-- run is live) with fire_at = started_at + offset_seconds. The status is
+-- 待触发 / 已触发 / 已取消; a fired inject records fired_at and the id of
+-- the sim event it raised (sim_event_id, empty until fired — no foreign
+-- key, the sim event may be deleted by hand afterwards). The rows are
+-- deleted with their run (ON DELETE CASCADE). The partial index serves
+-- the start-up scan of the scheduler over the pending injects.
+-- Timestamps follow the repository convention (created_at + updated_at).
+
+CREATE TABLE IF NOT EXISTS drill_scenario_injects (
+    id               TEXT PRIMARY KEY,
+    scenario_id      TEXT NOT NULL REFERENCES drill_scenarios(id) ON DELETE CASCADE,
+    offset_seconds   INTEGER NOT NULL CHECK (offset_seconds >= 0),
+    event_type       TEXT NOT NULL CHECK (event_type IN ('客流密度超阈值', '供配电异常报警', '烟感探测器触发', '气象预警接收', '其他')),
+    payload_template JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_by       TEXT NOT NULL DEFAULT '',
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS drill_scenario_injects_scenario_idx ON drill_scenario_injects (scenario_id, offset_seconds);
+
+CREATE TABLE IF NOT EXISTS drill_run_injects (
+    id                 TEXT PRIMARY KEY,
+    run_id             TEXT NOT NULL REFERENCES drill_runs(id) ON DELETE CASCADE,
+    scenario_inject_id TEXT NOT NULL DEFAULT '',
+    offset_seconds     INTEGER NOT NULL CHECK (offset_seconds >= 0),
+    event_type         TEXT NOT NULL CHECK (event_type IN ('客流密度超阈值', '供配电异常报警', '烟感探测器触发', '气象预警接收', '其他')),
+    payload_template   JSONB NOT NULL DEFAULT '{}'::jsonb,
+    status             TEXT NOT NULL DEFAULT '待触发' CHECK (status IN ('待触发', '已触发', '已取消')),
+    fire_at            TIMESTAMPTZ NOT NULL,
+    fired_at           TIMESTAMPTZ,
+    sim_event_id       TEXT NOT NULL DEFAULT '',
+    created_by         TEXT NOT NULL DEFAULT '',
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS drill_run_injects_run_idx ON drill_run_injects (run_id, fire_at);
+CREATE INDEX IF NOT EXISTS drill_run_injects_pending_idx ON drill_run_injects (fire_at) WHERE status = '待触发';
@@ -0,0 +1,7 @@
+-- 000033_dispatch_transitions.down.sql
+-- Reverts 000033_dispatch_transitions.sql: drops the
+-- dispatch_department_transitions and the dispatch_order_transitions
+-- tables (their indexes go with them).
+
+DROP TABLE IF EXISTS dispatch_department_transitions;
+DROP TABLE IF EXISTS dispatch_order_transitions;
@@ -0,0 +1,50 @@
+-- 000033_dispatch_transitions.sql
+-- Status history (状态流转记录) of the dispatch orders and the dispatch
+-- department reports of the command-and-dispatch training module
+-- (module 3). dispatch_orders and dispatch_department_reports only keep
+-- the current status, so the times an order was 已接收 or 执行中, or a
+-- department 已响应 or 处置中, are lost once the row moves on. These
+-- append-only logs keep one row per transition: from_status is the status
+-- before the change (empty for the row written when the order is issued
+-- or the report created), to_status the status after it, actor who made
+-- it and note the optional remark. Same-status updates append nothing.
+-- The service stamps a transition with the same instant as the
+-- updated_at of the row it changed (and the completed_at of an order it
+-- completes), so the log and the row agree to the microsecond.
+--
+-- Each row belongs to its order or report (ON DELETE CASCADE: deleting
+-- the order or report, or the run through them, removes its history —
+-- the in-memory dispatch store mirrors this in its delete methods) and
+-- also carries run_id so the history of a whole run is one index scan.
+-- department is kept next to report_id because the routes address a
+-- report by its department. The id is a server-generated ULID; rows are
+-- never updated, so there is no updated_at.
+
+CREATE TABLE IF NOT EXISTS dispatch_order_transitions (
+    id          TEXT PRIMARY KEY,
+    run_id      TEXT NOT NULL REFERENCES drill_runs(id) ON DELETE CASCADE,
+    order_id    TEXT NOT NULL REFERENCES dispatch_orders(id) ON DELETE CASCADE,
+    from_status TEXT NOT NULL DEFAULT '' CHECK (from_status IN ('', '待接收', '已接收', '执行中', '已完成')),
+    to_status   TEXT NOT NULL CHECK (to_status IN ('待接收', '已接收', '执行中', '已完成')),
+    actor       TEXT NOT NULL DEFAULT '',
+    note        TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS dispatch_order_transitions_run_idx ON dispatch_order_transitions (run_id, created_at, id);
+CREATE INDEX IF NOT EXISTS dispatch_order_transitions_order_idx ON dispatch_order_transitions (order_id, created_at, id);
+
+CREATE TABLE IF NOT EXISTS dispatch_department_transitions (
+    id          TEXT PRIMARY KEY,
+    run_id      TEXT NOT NULL REFERENCES drill_runs(id) ON DELETE CASCADE,
+    report_id   TEXT NOT NULL REFERENCES dispatch_department_reports(id) ON DELETE CASCADE,
+    department  TEXT NOT NULL CHECK (department IN ('消防', '公安', '卫健', '场馆应急组', '其他')),
+    from_status TEXT NOT NULL DEFAULT '' CHECK (from_status IN ('', '未响应', '已响应', '已到位', '处置中', '已完成')),
+    to_status   TEXT NOT NULL CHECK (to_status IN ('未响应', '已响应', '已到位', '处置中', '已完成')),
+    actor       TEXT NOT NULL DEFAULT '',
+    note        TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS dispatch_department_transitions_run_idx ON dispatch_department_transitions (run_id, created_at, id);
+CREATE INDEX IF NOT EXISTS dispatch_department_transitions_report_idx ON dispatch_department_transitions (report_id, created_at, id);
@@ -0,0 +1,5 @@
+-- 000034_evaluation_report_dispatch_latency.down.sql
+-- Reverts 000034_evaluation_report_dispatch_latency.sql: drops the
+-- dispatch_latency column of evaluation_reports.
+
+ALTER TABLE evaluation_reports DROP COLUMN IF EXISTS dispatch_latency;
@@ -0,0 +1,13 @@
+-- 000034_evaluation_report_dispatch_latency.sql
+-- Adds the dispatch latency block to the evaluation reports
+-- (evaluation_reports, 000025). With the status history of 000033 the
+-- report engine can measure how long dispatch orders took to be
+-- received (issued_at → 已接收) and to be executed (已接收 → 已完成);
+-- dispatch_latency is the JSONB snapshot of that block
+-- ({reception: {count, mean_seconds?, score?}, execution: {…}}). It is
+-- informational and does not feed overall_score or the indicator
+-- scores. Reports generated before this migration carry the empty
+-- object until they are generated again.
+
+ALTER TABLE evaluation_reports
+    ADD COLUMN IF NOT EXISTS dispatch_latency JSONB NOT NULL DEFAULT '{}'::jsonb;
@@ -1,11 +1,14 @@
 // Package migrations embeds the ordered SQL migrations of prototyped and
 // parses them into a version-ordered list. Files follow the naming
 // convention NUMBER_name.sql with zero-padded, strictly increasing version
-// numbers (000001_init.sql, 000002_...sql). Parsing is pure in-memory and
-// never touches a database.
+// numbers (000001_init.sql, 000002_...sql). A migration may ship an
+// optional NUMBER_name.down.sql file that reverts it. Parsing is pure
+// in-memory and never touches a database.
 package migrations
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io/fs"
 	"regexp"
@@ -14,11 +17,22 @@ import (
 	"strings"
 )
 
-// Migration is a single ordered database migration.
+// Migration is a single ordered database migration. DownSQL is empty when
+// the migration ships no down file, i.e. it cannot be rolled back.
+// Checksum is the hex SHA-256 of SQL; the migrator records it when the
+// migration is applied and compares it on every later run, so an edit to
+// an already applied file is detected instead of silently ignored.
 type Migration struct {
-	Version int
-	Name    string
-	SQL     string
+	Version  int
+	Name     string
+	SQL      string
+	DownSQL  string
+	Checksum string
+}
+
+// Reversible reports whether the migration ships a down file.
+func (m Migration) Reversible() bool {
+	return m.DownSQL != ""
 }
 
 // migrationNamePattern matches NUMBER_name.sql files. The number may be
@@ -26,11 +40,16 @@ type Migration struct {
 // (lexicographic) order equal to the version order.
 var migrationNamePattern = regexp.MustCompile(`^(\d+)_(.+)\.sql$`)
 
+// downSuffix marks the optional down file of a migration:
+// NUMBER_name.down.sql reverts NUMBER_name.sql.
+const downSuffix = ".down.sql"
+
 // Parse reads every *.sql file of the given filesystem, validates the
 // naming convention, and returns the migrations in strictly increasing
 // version order. Duplicate versions, lexicographic order disagreeing with
 // version order (inconsistent zero-padding), invalid names, and empty SQL
-// bodies are errors. Non-SQL entries are ignored.
+// bodies are errors, and so is a down file without the matching up file
+// (same number and name). Non-SQL entries are ignored.
 func Parse(files fs.FS) ([]Migration, error) {
 	entries, err := fs.ReadDir(files, ".")
 	if err != nil {
@@ -38,10 +57,15 @@ func Parse(files fs.FS) ([]Migration, error) {
 	}
 
 	var names []string
+	downNames := map[string]bool{}
 	for _, entry := range entries {
 		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
 			continue
 		}
+		if strings.HasSuffix(entry.Name(), downSuffix) {
+			downNames[entry.Name()] = true
+			continue
+		}
 		names = append(names, entry.Name())
 	}
 	// Sort explicitly by name: embed.FS lists entries in lexicographic
@@ -60,14 +84,27 @@ func Parse(files fs.FS) ([]Migration, error) {
 		if err != nil {
 			return nil, fmt.Errorf("migration %q: invalid version number", name)
 		}
-		body, err := fs.ReadFile(files, name)
+		body, err := readBody(files, name)
 		if err != nil {
-			return nil, fmt.Errorf("migration %q: read: %w", name, err)
+			return nil, err
+		}
+		migration := Migration{Version: version, Name: match[2], SQL: body, Checksum: Checksum(body)}
+		downName := strings.TrimSuffix(name, ".sql") + downSuffix
+		if downNames[downName] {
+			if migration.DownSQL, err = readBody(files, downName); err != nil {
+				return nil, err
+			}
+			delete(downNames, downName)
 		}
-		if strings.TrimSpace(string(body)) == "" {
-			return nil, fmt.Errorf("migration %q: SQL body is empty", name)
+		migrations = append(migrations, migration)
+	}
+	if len(downNames) > 0 {
+		orphans := make([]string, 0, len(downNames))
+		for downName := range downNames {
+			orphans = append(orphans, downName)
 		}
-		migrations = append(migrations, Migration{Version: version, Name: match[2], SQL: string(body)})
+		sort.Strings(orphans)
+		return nil, fmt.Errorf("migration %q: down file has no matching up file", orphans[0])
 	}
 
 	// Names are sorted above, so the traversal order is the execution
@@ -85,3 +122,22 @@ func Parse(files fs.FS) ([]Migration, error) {
 	}
 	return migrations, nil
 }
+
+// Checksum returns the hex SHA-256 of a migration body, the value the
+// migrator records for every applied migration.
+func Checksum(sql string) string {
+	sum := sha256.Sum256([]byte(sql))
+	return hex.EncodeToString(sum[:])
+}
+
+// readBody reads one migration file and rejects an empty SQL body.
+func readBody(files fs.FS, name string) (string, error) {
+	body, err := fs.ReadFile(files, name)
+	if err != nil {
+		return "", fmt.Errorf("migration %q: read: %w", name, err)
+	}
+	if strings.TrimSpace(string(body)) == "" {
+		return "", fmt.Errorf("migration %q: SQL body is empty", name)
+	}
+	return string(body), nil
+}
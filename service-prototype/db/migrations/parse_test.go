@@ -160,3 +160,62 @@ func sqlNames(fileSystem fs.FS) ([]string, error) {
 	}
 	return names, nil
 }
+
+func TestParsePairsDownFiles(t *testing.T) {
+	fileSystem := fstest.MapFS{
+		"000001_one.sql":      {Data: []byte("CREATE TABLE one ();")},
+		"000001_one.down.sql": {Data: []byte("DROP TABLE one;")},
+		"000002_two.sql":      {Data: []byte("CREATE TABLE two ();")},
+	}
+	list, err := Parse(fileSystem)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("len = %d, want 2 (down files are not migrations of their own)", len(list))
+	}
+	if list[0].DownSQL != "DROP TABLE one;" || !list[0].Reversible() {
+		t.Fatalf("first migration = %+v, want its down script", list[0])
+	}
+	if list[1].Reversible() {
+		t.Fatalf("second migration = %+v, want no down script", list[1])
+	}
+	if list[0].Checksum != Checksum("CREATE TABLE one ();") || list[0].Checksum == list[1].Checksum {
+		t.Fatalf("checksums = %q %q, want the SHA-256 of each up script", list[0].Checksum, list[1].Checksum)
+	}
+}
+
+func TestParseRejectsOrphanDownFile(t *testing.T) {
+	fileSystem := fstest.MapFS{
+		"000001_one.sql":        {Data: []byte("SELECT 1;")},
+		"000001_other.down.sql": {Data: []byte("SELECT 1;")},
+	}
+	_, err := Parse(fileSystem)
+	if err == nil || !strings.Contains(err.Error(), "no matching up file") {
+		t.Fatalf("err = %v, want an orphan down file error", err)
+	}
+}
+
+func TestParseRejectsEmptyDownFile(t *testing.T) {
+	fileSystem := fstest.MapFS{
+		"000001_one.sql":      {Data: []byte("SELECT 1;")},
+		"000001_one.down.sql": {Data: []byte("\n")},
+	}
+	if _, err := Parse(fileSystem); err == nil {
+		t.Fatal("expected an error for an empty down file")
+	}
+}
+
+// TestEmbeddedMigrationsAreReversible pins that every embedded migration
+// ships a down file, so `prototyped migrate to 0` can unwind the schema.
+func TestEmbeddedMigrationsAreReversible(t *testing.T) {
+	list, err := Parse(Files)
+	if err != nil {
+		t.Fatalf("parse embedded migrations: %v", err)
+	}
+	for _, migration := range list {
+		if !migration.Reversible() {
+			t.Errorf("migration %06d_%s has no down file", migration.Version, migration.Name)
+		}
+	}
+}
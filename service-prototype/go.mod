@@ -2,12 +2,20 @@ module github.com/ovaphlow/pitchfork/service-prototype
 
 go 1.24.0
 
+require (
+	github.com/jackc/pgx/v5 v5.7.2
+	github.com/ovaphlow/pitchfork/service-idp-go v0.0.0
+)
+
 require (
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
-	github.com/jackc/pgx/v5 v5.7.2 // indirect
 	github.com/jackc/puddle/v2 v2.2.2 // indirect
-	golang.org/x/crypto v0.31.0 // indirect
+	golang.org/x/crypto v0.42.0 // indirect
 	golang.org/x/sync v0.17.0 // indirect
 	golang.org/x/text v0.29.0 // indirect
 )
+
+// pkg/accesstoken of the sibling identityd module is the one access token
+// verifier of the monorepo; prototyped builds against the checked-out tree.
+replace github.com/ovaphlow/pitchfork/service-idp-go => ../service-idp-go
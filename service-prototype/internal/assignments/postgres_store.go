@@ -0,0 +1,92 @@
+package assignments
+
+import (
+	"context"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
+)
+
+// PostgresStore persists training assignments in the
+// training_assignments table (migration 000006). It implements Store
+// with the same semantics as the in-memory store: a missing id answers
+// ErrNotFound, listing is newest first (ties in creation order) and the
+// employee filter only matches 用户 assignments whose target_ids carry
+// the id.
+type PostgresStore struct {
+	db pgstore.Querier
+}
+
+// NewPostgresStore returns an assignment store over the given connection.
+func NewPostgresStore(db pgstore.Querier) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+const assignmentColumns = `id, course_id, assign_type, trigger_rule, deadline, target_type, target_ids,
+	created_by, created_at, updated_at`
+
+// Create inserts the assignment.
+func (s *PostgresStore) Create(ctx context.Context, assignment Assignment) error {
+	targetIDs := assignment.TargetIDs
+	if targetIDs == nil {
+		targetIDs = []string{}
+	}
+	_, err := s.db.Exec(ctx, `INSERT INTO training_assignments (`+assignmentColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		assignment.ID, assignment.CourseID, assignment.AssignType, assignment.TriggerRule,
+		assignment.Deadline, assignment.TargetType, targetIDs, assignment.CreatedBy,
+		assignment.CreatedAt, assignment.UpdatedAt)
+	return err
+}
+
+// List returns the assignments matching the filter newest first, the
+// total number of matches and the paginated page.
+func (s *PostgresStore) List(ctx context.Context, filter Filter) ([]Assignment, int, error) {
+	const where = ` WHERE ($1 = '' OR course_id = $1) AND ($2 = '' OR target_type = $2)
+		AND ($3 = '' OR (target_type = '` + string(TargetTypeUser) + `' AND target_ids ? $3))`
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM training_assignments`+where,
+		filter.CourseID, filter.TargetType, filter.EmployeeID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+assignmentColumns+` FROM training_assignments`+where+`
+		ORDER BY created_at DESC, id LIMIT $4 OFFSET $5`,
+		filter.CourseID, filter.TargetType, filter.EmployeeID, pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []Assignment{}
+	for rows.Next() {
+		assignment, err := scanAssignment(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, assignment)
+	}
+	return page, total, rows.Err()
+}
+
+// Get returns the assignment with the given id, or ErrNotFound.
+func (s *PostgresStore) Get(ctx context.Context, id string) (Assignment, error) {
+	assignment, err := scanAssignment(s.db.QueryRow(ctx,
+		`SELECT `+assignmentColumns+` FROM training_assignments WHERE id = $1`, id))
+	if pgstore.IsNoRows(err) {
+		return Assignment{}, ErrNotFound
+	}
+	return assignment, err
+}
+
+// Delete removes the assignment with the given id, or returns
+// ErrNotFound. The database cascades the deletion to its progress rows.
+func (s *PostgresStore) Delete(ctx context.Context, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM training_assignments WHERE id = $1`, id)
+	return pgstore.CheckAffected(tag, err, ErrNotFound)
+}
+
+func scanAssignment(row pgstore.Row) (Assignment, error) {
+	var assignment Assignment
+	err := row.Scan(&assignment.ID, &assignment.CourseID, &assignment.AssignType, &assignment.TriggerRule,
+		&assignment.Deadline, &assignment.TargetType, &assignment.TargetIDs, &assignment.CreatedBy,
+		&assignment.CreatedAt, &assignment.UpdatedAt)
+	return assignment, err
+}
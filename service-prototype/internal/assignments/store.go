@@ -6,9 +6,9 @@ import (
 	"sync"
 )
 
-// Store persists assignments. The prototype ships the in-memory
-// implementation; the interface keeps the routing and service layers
-// independent of the storage backend.
+// Store persists assignments. The prototype ships an in-memory and a
+// PostgreSQL implementation; the interface keeps the routing and service
+// layers independent of the storage backend.
 type Store interface {
 	Create(ctx context.Context, assignment Assignment) error
 	List(ctx context.Context, filter Filter) ([]Assignment, int, error)
@@ -20,8 +20,8 @@ type Store interface {
 // by a mutex. Listing filters a copy and sorts it by created_at
 // descending (ties keep insertion order), so the newest assignment comes
 // first regardless of insertion order. It implements Store for the
-// prototype and never touches a database; a database-backed store
-// arrives with a later slice.
+// prototype and never touches a database; PostgresStore is the
+// database-backed counterpart.
 type InMemoryStore struct {
 	mu    sync.Mutex
 	items []Assignment
@@ -0,0 +1,76 @@
+package assignments
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/databasetest"
+)
+
+func TestInMemoryStoreContract(t *testing.T) {
+	runStoreContract(t, NewInMemoryStore(), func(string) {})
+}
+
+func TestPostgresStoreContract(t *testing.T) {
+	db := databasetest.Open(t)
+	runStoreContract(t, NewPostgresStore(db), func(courseID string) {
+		databasetest.Exec(t, db, `INSERT INTO courses (id, title, topic, type) VALUES ($1, $1, '舆情应对', '线上授课')`, courseID)
+	})
+}
+
+// runStoreContract pins the Store semantics every backend shares. The
+// addCourse hook creates the parent course the database foreign key
+// requires; the in-memory store needs none.
+func runStoreContract(t *testing.T, store Store, addCourse func(courseID string)) {
+	ctx := context.Background()
+	addCourse("course-1")
+	addCourse("course-2")
+	base := databasetest.Time(2026, 8, 5, 9, 0, 0)
+	items := []Assignment{
+		{ID: "as-1", CourseID: "course-1", AssignType: AssignTypeManual, TriggerRule: map[string]any{}, TargetType: TargetTypeUser, TargetIDs: []string{"e1", "e2"}, CreatedBy: "u1", CreatedAt: base, UpdatedAt: base},
+		{ID: "as-2", CourseID: "course-1", AssignType: AssignTypeAuto, TriggerRule: map[string]any{"event": "入职"}, Deadline: "2026-09-01T00:00:00Z", TargetType: TargetTypeDept, TargetIDs: []string{"e1"}, CreatedAt: base.Add(time.Minute), UpdatedAt: base.Add(time.Minute)},
+		{ID: "as-3", CourseID: "course-2", AssignType: AssignTypeManual, TriggerRule: map[string]any{}, TargetType: TargetTypeUser, TargetIDs: []string{"e1"}, CreatedAt: base.Add(2 * time.Minute), UpdatedAt: base.Add(2 * time.Minute)},
+	}
+	for _, item := range items {
+		if err := store.Create(ctx, item); err != nil {
+			t.Fatalf("create %s: %v", item.ID, err)
+		}
+	}
+	got, err := store.Get(ctx, "as-2")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	databasetest.AssertSameJSON(t, got, items[1])
+
+	// The employee filter only expands 用户 assignments; the 部门
+	// assignment carrying the same id never matches.
+	page, total, err := store.List(ctx, Filter{EmployeeID: "e1", Limit: -1})
+	if err != nil {
+		t.Fatalf("list by employee: %v", err)
+	}
+	if total != 2 || len(page) != 2 || page[0].ID != "as-3" || page[1].ID != "as-1" {
+		t.Fatalf("employee e1 = %d %v, want as-3, as-1", total, page)
+	}
+	page, total, err = store.List(ctx, Filter{CourseID: "course-1", Limit: 1, Offset: 1})
+	if err != nil {
+		t.Fatalf("list by course: %v", err)
+	}
+	if total != 2 || len(page) != 1 || page[0].ID != "as-1" {
+		t.Fatalf("course-1 page = %d %v, want total 2 and as-1", total, page)
+	}
+	if _, total, _ := store.List(ctx, Filter{TargetType: TargetTypeDept, Limit: -1}); total != 1 {
+		t.Fatalf("target type filter total = %d, want 1", total)
+	}
+
+	if err := store.Delete(ctx, "as-1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "as-1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get deleted = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, "as-1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("delete twice = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,152 @@
+// Package auth resolves the authenticated principal of a prototyped
+// request. Two credentials are understood: the identityd browser session
+// (the identityd_session cookie, validated by forwarding the Cookie
+// header to identityd's /session endpoint, the same service-to-service
+// boundary Nexus uses) and a bearer JWT issued by an OIDC provider
+// (RS256, verified against the provider's JWKS). The package only
+// answers who the caller is and which prototype roles it holds; which
+// role may call which route is decided by the httpapi layer.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Role is a prototype role code as granted in identityd (or carried in
+// the roles claim of a token). Codes outside the four below may be
+// present on a principal; they grant nothing in prototyped.
+type Role string
+
+const (
+	// RoleDirector (演练导演) authors scenarios and training content,
+	// runs the drills and issues orders from the command center.
+	RoleDirector Role = "prototype.director"
+	// RoleResponder (现场处置人员) reports from the field: 现场人员
+	// messages, zone densities, devices, department status and order
+	// feedback.
+	RoleResponder Role = "prototype.responder"
+	// RoleEvaluator (评估专家) records assessments and evaluation scores
+	// and generates the run reports.
+	RoleEvaluator Role = "prototype.evaluator"
+	// RoleTrainee (受训学员) studies the assigned courses and takes the
+	// exams.
+	RoleTrainee Role = "prototype.trainee"
+)
+
+// rolePrefix starts every prototype role code.
+const rolePrefix = "prototype."
+
+// ErrNoCredentials reports a request that carries no credential the
+// authenticator understands; a Chain moves on to its next member.
+var ErrNoCredentials = errors.New("no credentials")
+
+// ErrUnauthenticated reports a credential that was presented and
+// rejected (unknown or expired session, bad signature, wrong issuer …).
+var ErrUnauthenticated = errors.New("not authenticated")
+
+// ErrInvalidCSRFToken reports a session-authenticated write whose
+// X-CSRF-Token header is missing or not the one of the session. The
+// session itself is valid, so the request is forbidden rather than
+// unauthenticated.
+var ErrInvalidCSRFToken = errors.New("invalid CSRF token")
+
+// Principal is the authenticated caller: the identityd subject id (the
+// sub claim of a token) and the role codes it holds.
+type Principal struct {
+	Subject string
+	Roles   []Role
+}
+
+// HasAnyRole reports whether the principal holds at least one of the
+// given roles.
+func (principal Principal) HasAnyRole(roles ...Role) bool {
+	for _, role := range roles {
+		if slices.Contains(principal.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPrototypeRole reports whether the principal holds any prototype
+// role code; a principal without one may not even read.
+func (principal Principal) HasPrototypeRole() bool {
+	for _, role := range principal.Roles {
+		if isPrototypeRole(string(role)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator resolves the principal of a request. It answers
+// ErrNoCredentials when the request carries none of its credentials,
+// ErrUnauthenticated (possibly wrapped) when the credential is rejected,
+// and any other error when the credential could not be checked (the
+// identity provider is unreachable).
+type Authenticator interface {
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+// Chain tries its authenticators in order and answers the first result
+// that is not ErrNoCredentials; a request none of them recognises
+// yields ErrNoCredentials. A presented but rejected credential stops the
+// chain, so a bad bearer token never falls back to the session cookie.
+type Chain []Authenticator
+
+// Authenticate implements Authenticator.
+func (chain Chain) Authenticate(r *http.Request) (Principal, error) {
+	for _, authenticator := range chain {
+		principal, err := authenticator.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return principal, err
+	}
+	return Principal{}, ErrNoCredentials
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFrom returns the principal stored by WithPrincipal; ok is
+// false for a context without one (a router running without
+// authentication, or a public route).
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// rolesOf converts role codes to roles, dropping empty codes.
+func rolesOf(codes []string) []Role {
+	roles := make([]Role, 0, len(codes))
+	for _, code := range codes {
+		if code != "" {
+			roles = append(roles, Role(code))
+		}
+	}
+	return roles
+}
+
+// prototypeRoles keeps the codes that name a prototype role.
+func prototypeRoles(codes []string) []Role {
+	var roles []Role
+	for _, code := range codes {
+		if isPrototypeRole(code) {
+			roles = append(roles, Role(code))
+		}
+	}
+	return roles
+}
+
+func isPrototypeRole(code string) bool {
+	return strings.HasPrefix(code, rolePrefix) && len(code) > len(rolePrefix)
+}
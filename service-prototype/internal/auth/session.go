@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SessionCookieName is the identityd browser session cookie. Its
+// presence is what makes a request a session request; the value itself
+// is opaque to prototyped.
+const SessionCookieName = "identityd_session"
+
+// CSRFHeader carries the identityd_csrf cookie value on a browser write.
+// The browser reads the cookie and echoes it; a cross-site form cannot.
+const CSRFHeader = "X-CSRF-Token"
+
+// sessionPath is the identityd current-session endpoint, relative to the
+// identityd base URL.
+const sessionPath = "/crate-api/identity/v1/session"
+
+// sessionAccessFull is the access level of a fully signed-in session;
+// a 仅改密 session (password change pending) is not accepted.
+const sessionAccessFull = "完整"
+
+// defaultSessionTimeout bounds one identityd round trip when the caller
+// supplies no client.
+const defaultSessionTimeout = 3 * time.Second
+
+// SessionAuthenticator validates identityd browser sessions. It never
+// interprets the session token: the Cookie header of the request is
+// forwarded to identityd's /session endpoint, which answers the subject
+// and its role codes for a live session and 401 otherwise. The cookie
+// rides along on cross-site requests too, so a write (any method but
+// GET and HEAD) must also carry the X-CSRF-Token header, which is
+// forwarded for identityd to check against the session.
+type SessionAuthenticator struct {
+	sessionURL string
+	client     *http.Client
+}
+
+// NewSessionAuthenticator returns an authenticator asking the identityd
+// instance at baseURL (scheme and host, e.g. http://127.0.0.1:8420). A
+// nil client uses one with a short timeout.
+func NewSessionAuthenticator(baseURL string, client *http.Client) *SessionAuthenticator {
+	if client == nil {
+		client = &http.Client{Timeout: defaultSessionTimeout}
+	}
+	return &SessionAuthenticator{
+		sessionURL: strings.TrimRight(baseURL, "/") + sessionPath,
+		client:     client,
+	}
+}
+
+// sessionResponse is the body identityd answers for a live session.
+type sessionResponse struct {
+	SubjectID string   `json:"subject_id"`
+	Access    string   `json:"access"`
+	Roles     []string `json:"roles"`
+	// CSRFVerified is set when identityd checked a forwarded X-CSRF-Token.
+	CSRFVerified bool `json:"csrf_verified"`
+}
+
+// Authenticate implements Authenticator. A request without the identityd
+// session cookie yields ErrNoCredentials; a 401 from identityd, or a
+// session that is not 完整, yields ErrUnauthenticated; a write without
+// the X-CSRF-Token of its session yields ErrInvalidCSRFToken; a transport
+// error or any other status is returned as is.
+func (authenticator *SessionAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	if _, err := r.Cookie(SessionCookieName); err != nil {
+		return Principal{}, ErrNoCredentials
+	}
+	write := r.Method != http.MethodGet && r.Method != http.MethodHead
+	csrfToken := r.Header.Get(CSRFHeader)
+	if write && csrfToken == "" {
+		return Principal{}, ErrInvalidCSRFToken
+	}
+	request, err := http.NewRequestWithContext(r.Context(), http.MethodGet, authenticator.sessionURL, nil)
+	if err != nil {
+		return Principal{}, fmt.Errorf("build identityd session request: %w", err)
+	}
+	request.Header.Set("Cookie", r.Header.Get("Cookie"))
+	request.Header.Set("Accept", "application/json")
+	if write {
+		request.Header.Set(CSRFHeader, csrfToken)
+	}
+	response, err := authenticator.client.Do(request)
+	if err != nil {
+		return Principal{}, fmt.Errorf("identityd session: %w", err)
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return Principal{}, ErrUnauthenticated
+	case http.StatusForbidden:
+		if write {
+			return Principal{}, ErrInvalidCSRFToken
+		}
+		return Principal{}, fmt.Errorf("identityd session: unexpected status %d", response.StatusCode)
+	default:
+		return Principal{}, fmt.Errorf("identityd session: unexpected status %d", response.StatusCode)
+	}
+	var session sessionResponse
+	if err := json.NewDecoder(response.Body).Decode(&session); err != nil {
+		return Principal{}, fmt.Errorf("decode identityd session: %w", err)
+	}
+	if session.SubjectID == "" || session.Access != sessionAccessFull {
+		return Principal{}, ErrUnauthenticated
+	}
+	// An identityd that ignored the header must not pass the write.
+	if write && !session.CSRFVerified {
+		return Principal{}, ErrInvalidCSRFToken
+	}
+	return Principal{Subject: session.SubjectID, Roles: rolesOf(session.Roles)}, nil
+}
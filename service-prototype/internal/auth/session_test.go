@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// identityStub answers /session like identityd: 200 with the subject for
+// the live cookie, 401 otherwise; a forwarded X-CSRF-Token must equal the
+// identityd_csrf cookie (403 otherwise) and is confirmed with
+// csrf_verified. It records the forwarded Cookie header.
+func identityStub(t *testing.T, access string) (*httptest.Server, *string) {
+	t.Helper()
+	forwarded := new(string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != sessionPath {
+			http.NotFound(w, r)
+			return
+		}
+		*forwarded = r.Header.Get("Cookie")
+		if cookie, err := r.Cookie(SessionCookieName); err != nil || cookie.Value != "live" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		verified := ""
+		if token := r.Header.Get(CSRFHeader); token != "" {
+			if cookie, err := r.Cookie("identityd_csrf"); err != nil || cookie.Value != token {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			verified = `,"csrf_verified":true`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"subject_id":"01SUBJECT","access":"` + access + `","roles":["prototype.director","identity.admin"]` + verified + `}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, forwarded
+}
+
+// 有效会话：原样转发 Cookie 头，返回主体与角色；无会话 Cookie 时为
+// ErrNoCredentials（不访问 identityd）；失效会话为 ErrUnauthenticated。
+func TestSessionAuthenticator(t *testing.T) {
+	server, forwarded := identityStub(t, sessionAccessFull)
+	authenticator := NewSessionAuthenticator(server.URL+"/", nil)
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Cookie", "theme=dark; identityd_session=live")
+	principal, err := authenticator.Authenticate(request)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if principal.Subject != "01SUBJECT" || !slices.Equal(principal.Roles, []Role{RoleDirector, "identity.admin"}) {
+		t.Fatalf("principal = %#v", principal)
+	}
+	if *forwarded != "theme=dark; identityd_session=live" {
+		t.Fatalf("forwarded Cookie = %q", *forwarded)
+	}
+
+	*forwarded = ""
+	request = httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Cookie", "theme=dark")
+	if _, err := authenticator.Authenticate(request); !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("without session cookie: err = %v, want ErrNoCredentials", err)
+	}
+	if *forwarded != "" {
+		t.Fatalf("identityd was called without a session cookie")
+	}
+
+	request = httptest.NewRequest(http.MethodGet, "/", nil)
+	request.AddCookie(&http.Cookie{Name: SessionCookieName, Value: "expired"})
+	if _, err := authenticator.Authenticate(request); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("expired session: err = %v, want ErrUnauthenticated", err)
+	}
+}
+
+// 写请求（GET/HEAD 以外）必须带 X-CSRF-Token 并由 identityd 按会话校验：
+// 缺失时不访问 identityd，不匹配或 identityd 未确认时均为
+// ErrInvalidCSRFToken；读请求不转发该请求头。
+func TestSessionAuthenticatorRequiresCSRFTokenOnWrites(t *testing.T) {
+	server, forwarded := identityStub(t, sessionAccessFull)
+	authenticator := NewSessionAuthenticator(server.URL, nil)
+	write := func(token string) *http.Request {
+		request := httptest.NewRequest(http.MethodPost, "/", nil)
+		request.Header.Set("Cookie", "identityd_session=live; identityd_csrf=csrf-1")
+		if token != "" {
+			request.Header.Set(CSRFHeader, token)
+		}
+		return request
+	}
+
+	if principal, err := authenticator.Authenticate(write("csrf-1")); err != nil || principal.Subject != "01SUBJECT" {
+		t.Fatalf("write with CSRF token: principal = %#v, err = %v", principal, err)
+	}
+	*forwarded = ""
+	if _, err := authenticator.Authenticate(write("")); !errors.Is(err, ErrInvalidCSRFToken) {
+		t.Fatalf("write without CSRF token: err = %v, want ErrInvalidCSRFToken", err)
+	}
+	if *forwarded != "" {
+		t.Fatalf("identityd was called for a write without CSRF token")
+	}
+	if _, err := authenticator.Authenticate(write("forged")); !errors.Is(err, ErrInvalidCSRFToken) {
+		t.Fatalf("write with forged CSRF token: err = %v, want ErrInvalidCSRFToken", err)
+	}
+
+	// 不认识该请求头的 identityd 不回 csrf_verified，写请求同样被拒。
+	legacy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"subject_id":"01SUBJECT","access":"完整","roles":["prototype.director"]}`))
+	}))
+	t.Cleanup(legacy.Close)
+	if _, err := NewSessionAuthenticator(legacy.URL, nil).Authenticate(write("csrf-1")); !errors.Is(err, ErrInvalidCSRFToken) {
+		t.Fatalf("write against an identityd without CSRF check: err = %v, want ErrInvalidCSRFToken", err)
+	}
+
+	read := httptest.NewRequest(http.MethodGet, "/", nil)
+	read.Header.Set("Cookie", "identityd_session=live; identityd_csrf=csrf-1")
+	read.Header.Set(CSRFHeader, "forged")
+	if _, err := authenticator.Authenticate(read); err != nil {
+		t.Fatalf("read with a stray CSRF header: err = %v", err)
+	}
+}
+
+// 仅改密会话不被接受；identityd 不可达时返回非认证类错误（中间件据此答
+// 503 而非 401）。
+func TestSessionAuthenticatorRejectsPartialSessionAndReportsOutage(t *testing.T) {
+	server, _ := identityStub(t, "仅改密")
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.AddCookie(&http.Cookie{Name: SessionCookieName, Value: "live"})
+	if _, err := NewSessionAuthenticator(server.URL, nil).Authenticate(request); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("仅改密 session: err = %v, want ErrUnauthenticated", err)
+	}
+
+	server.Close()
+	_, err := NewSessionAuthenticator(server.URL, nil).Authenticate(request)
+	if err == nil || errors.Is(err, ErrUnauthenticated) || errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("unreachable identityd: err = %v, want a transport error", err)
+	}
+}
+
+// Chain 跳过无凭据的成员，但被拒绝的凭据终止链路（坏 token 不回落到
+// Cookie）。
+func TestChainStopsAtRejectedCredential(t *testing.T) {
+	server, _ := identityStub(t, sessionAccessFull)
+	tokens, err := NewTokenAuthenticator(TokenConfig{JWKSURL: server.URL + "/jwks", Issuer: "https://idp.example", Audience: "prototyped"})
+	if err != nil {
+		t.Fatalf("new token authenticator: %v", err)
+	}
+	chain := Chain{tokens, NewSessionAuthenticator(server.URL, nil)}
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.AddCookie(&http.Cookie{Name: SessionCookieName, Value: "live"})
+	if principal, err := chain.Authenticate(request); err != nil || principal.Subject != "01SUBJECT" {
+		t.Fatalf("cookie only: principal = %#v, err = %v", principal, err)
+	}
+	request.Header.Set("Authorization", "Bearer not-a-jwt")
+	if _, err := chain.Authenticate(request); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("bad token next to a live cookie: err = %v, want ErrUnauthenticated", err)
+	}
+	if _, err := chain.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil)); !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("no credentials: err = %v, want ErrNoCredentials", err)
+	}
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ovaphlow/pitchfork/service-idp-go/pkg/accesstoken"
+)
+
+// TokenConfig configures a TokenAuthenticator. JWKSURL, Issuer and
+// Audience are required: without the aud check any token of the provider,
+// whichever service it was issued for, would be accepted. A nil Client
+// uses one with a short timeout.
+type TokenConfig struct {
+	JWKSURL  string
+	Issuer   string
+	Audience string
+	Client   *http.Client
+}
+
+// TokenAuthenticator validates bearer access tokens issued by identityd
+// with its shared verifier (pkg/accesstoken): the at+jwt type, the RS256
+// signature against the JWKS and the iss, aud, exp and nbf claims. The
+// roles claim (an array of role codes) becomes the roles of the
+// principal. A machine client token (sub equal to client_id) carries no
+// roles; its prototype scopes become its roles instead, and one without
+// any is rejected.
+type TokenAuthenticator struct {
+	verifier *accesstoken.Verifier
+}
+
+// NewTokenAuthenticator returns a token authenticator for the given
+// provider configuration.
+func NewTokenAuthenticator(config TokenConfig) (*TokenAuthenticator, error) {
+	if config.JWKSURL == "" || config.Issuer == "" || config.Audience == "" {
+		return nil, errors.New("token authentication requires a JWKS URL, an issuer and an audience")
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: defaultSessionTimeout}
+	}
+	verifier, err := accesstoken.NewVerifier(accesstoken.Config{
+		Issuer:   config.Issuer,
+		JWKSURL:  config.JWKSURL,
+		Audience: config.Audience,
+		Client:   config.Client,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TokenAuthenticator{verifier: verifier}, nil
+}
+
+// Authenticate implements Authenticator. A request without an
+// Authorization: Bearer header yields ErrNoCredentials; a malformed,
+// badly signed, expired or foreign token yields a wrapped
+// ErrUnauthenticated; a JWKS fetch failure is returned as is.
+func (authenticator *TokenAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return Principal{}, ErrNoCredentials
+	}
+	claims, err := authenticator.verifier.Verify(r.Context(), strings.TrimSpace(token))
+	if errors.Is(err, accesstoken.ErrInvalidToken) {
+		return Principal{}, fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	if claims.IsClient() {
+		roles := prototypeRoles(claims.Scopes)
+		if len(roles) == 0 {
+			return Principal{}, fmt.Errorf("%w: client token without a prototype scope", ErrUnauthenticated)
+		}
+		return Principal{Subject: claims.Subject, Roles: roles}, nil
+	}
+	return Principal{Subject: claims.Subject, Roles: rolesOf(claims.Roles)}, nil
+}
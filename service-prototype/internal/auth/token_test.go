@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testIssuer = "https://idp.example"
+
+// testProvider is an OIDC provider stub: it serves the JWKS of its key
+// and signs tokens with it. fetches counts the JWKS downloads.
+type testProvider struct {
+	key     *rsa.PrivateKey
+	kid     string
+	server  *httptest.Server
+	fetches atomic.Int32
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	provider := &testProvider{key: key, kid: "key-1"}
+	provider.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provider.fetches.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": provider.kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	t.Cleanup(provider.server.Close)
+	return provider
+}
+
+// sign builds an RS256 access token over the claims with the given kid.
+func (provider *testProvider) sign(t *testing.T, kid string, claims map[string]any) string {
+	t.Helper()
+	return provider.signTyped(t, "at+jwt", kid, claims)
+}
+
+// signTyped is sign with an explicit typ header.
+func (provider *testProvider) signTyped(t *testing.T, typ, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": typ, "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, provider.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (provider *testProvider) authenticator(t *testing.T) *TokenAuthenticator {
+	t.Helper()
+	authenticator, err := NewTokenAuthenticator(TokenConfig{JWKSURL: provider.server.URL, Issuer: testIssuer, Audience: "prototyped"})
+	if err != nil {
+		t.Fatalf("new token authenticator: %v", err)
+	}
+	return authenticator
+}
+
+func bearer(token string) *http.Request {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+	return request
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"iss":   testIssuer,
+		"sub":   "01SUBJECT",
+		"aud":   []string{"nexus", "prototyped"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nbf":   time.Now().Add(-time.Minute).Unix(),
+		"roles": []string{"prototype.evaluator"},
+	}
+}
+
+// 合法 token：签名、iss、aud（数组形式）、exp/nbf 均通过，主体与 roles
+// 声明进入 Principal；JWKS 只在首次使用时下载一次。
+func TestTokenAuthenticatorAcceptsValidToken(t *testing.T) {
+	provider := newTestProvider(t)
+	authenticator := provider.authenticator(t)
+	for range 2 {
+		principal, err := authenticator.Authenticate(bearer(provider.sign(t, provider.kid, validClaims())))
+		if err != nil {
+			t.Fatalf("authenticate: %v", err)
+		}
+		if principal.Subject != "01SUBJECT" || !slices.Equal(principal.Roles, []Role{RoleEvaluator}) {
+			t.Fatalf("principal = %#v", principal)
+		}
+	}
+	if fetches := provider.fetches.Load(); fetches != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", fetches)
+	}
+	if _, err := authenticator.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil)); !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("no Authorization header: err = %v, want ErrNoCredentials", err)
+	}
+}
+
+// 被拒绝的 token 均为 ErrUnauthenticated：过期、未生效、签发者或受众不
+// 符、缺少 sub、alg 非 RS256、typ 不是 at+jwt（ID token）、签名被篡改、
+// 未知 kid、不带 prototype scope 的机器客户端 token。
+func TestTokenAuthenticatorRejectsInvalidTokens(t *testing.T) {
+	provider := newTestProvider(t)
+	authenticator := provider.authenticator(t)
+	with := func(key string, value any) map[string]any {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+	valid := provider.sign(t, provider.kid, validClaims())
+	tampered := valid[:len(valid)-4] + "AAAA"
+	header, _ := json.Marshal(map[string]string{"alg": "none", "kid": provider.kid})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"x"}`)) + "."
+	for name, token := range map[string]string{
+		"expired":       provider.sign(t, provider.kid, with("exp", time.Now().Add(-time.Hour).Unix())),
+		"no exp":        provider.sign(t, provider.kid, with("exp", nil)),
+		"not yet valid": provider.sign(t, provider.kid, with("nbf", time.Now().Add(time.Hour).Unix())),
+		"issuer":        provider.sign(t, provider.kid, with("iss", "https://evil.example")),
+		"audience":      provider.sign(t, provider.kid, with("aud", "nexus")),
+		"no sub":        provider.sign(t, provider.kid, with("sub", nil)),
+		"alg none":      unsigned,
+		"tampered":      tampered,
+		"unknown kid":   provider.sign(t, "key-2", validClaims()),
+		"id token":      provider.signTyped(t, "JWT", provider.kid, validClaims()),
+		"no typ":        provider.signTyped(t, "", provider.kid, validClaims()),
+		"client token":  provider.sign(t, provider.kid, with("client_id", "01SUBJECT")),
+		"malformed":     "a.b",
+	} {
+		if _, err := authenticator.Authenticate(bearer(token)); !errors.Is(err, ErrUnauthenticated) {
+			t.Fatalf("%s: err = %v, want ErrUnauthenticated", name, err)
+		}
+	}
+}
+
+// 未知 kid 在刷新间隔内不会重复下载 JWKS（间隔过后的重新下载由
+// pkg/accesstoken 的测试覆盖）。
+func TestTokenAuthenticatorLimitsKeyRefresh(t *testing.T) {
+	provider := newTestProvider(t)
+	authenticator := provider.authenticator(t)
+	if _, err := authenticator.Authenticate(bearer(provider.sign(t, provider.kid, validClaims()))); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+
+	provider.kid = "key-2"
+	rotated := provider.sign(t, "key-2", validClaims())
+	if _, err := authenticator.Authenticate(bearer(rotated)); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("rotated key within the refresh interval: err = %v, want ErrUnauthenticated", err)
+	}
+	if fetches := provider.fetches.Load(); fetches != 1 {
+		t.Fatalf("JWKS fetched %d times within the refresh interval, want 1", fetches)
+	}
+}
+
+// 机器客户端 token（sub 等于 client_id）不带 roles，以其 prototype scope
+// 作为角色；其它 scope 不授予任何角色。缺少受众配置时拒绝构造。
+func TestTokenAuthenticatorMapsClientScopesToRoles(t *testing.T) {
+	provider := newTestProvider(t)
+	claims := validClaims()
+	claims["sub"], claims["client_id"] = "reporting", "reporting"
+	claims["scope"] = "reports.read prototype.evaluator"
+	delete(claims, "roles")
+	principal, err := provider.authenticator(t).Authenticate(bearer(provider.signTyped(t, "application/at+jwt", provider.kid, claims)))
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if principal.Subject != "reporting" || !slices.Equal(principal.Roles, []Role{RoleEvaluator}) {
+		t.Fatalf("principal = %#v", principal)
+	}
+	if _, err := NewTokenAuthenticator(TokenConfig{JWKSURL: provider.server.URL, Issuer: testIssuer}); err == nil {
+		t.Fatalf("NewTokenAuthenticator without an audience succeeded")
+	}
+}
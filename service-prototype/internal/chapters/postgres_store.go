@@ -0,0 +1,104 @@
+package chapters
+
+import (
+	"context"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
+)
+
+// PostgresStore persists chapters in the course_chapters table
+// (migration 000003). It implements Store with the same semantics as the
+// in-memory store: a missing id answers ErrNotFound and listing sorts by
+// sort_order, ties broken by created_at.
+type PostgresStore struct {
+	db pgstore.Querier
+}
+
+// NewPostgresStore returns a chapter store over the given connection.
+func NewPostgresStore(db pgstore.Querier) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+const chapterColumns = `id, course_id, sort_order, title, blocks, quiz_config, created_at, updated_at`
+
+// Create inserts the chapter.
+func (s *PostgresStore) Create(ctx context.Context, chapter Chapter) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO course_chapters (`+chapterColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		chapter.ID, chapter.CourseID, chapter.SortOrder, chapter.Title,
+		blocksArg(chapter.Blocks), chapter.QuizConfig, chapter.CreatedAt, chapter.UpdatedAt)
+	return err
+}
+
+// ListByCourse returns the chapters of the course in sort order, the
+// total number of chapters of the course and the paginated page.
+func (s *PostgresStore) ListByCourse(ctx context.Context, courseID string, filter Filter) ([]Chapter, int, error) {
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM course_chapters WHERE course_id = $1`,
+		courseID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+chapterColumns+` FROM course_chapters WHERE course_id = $1
+		ORDER BY sort_order, created_at, id LIMIT $2 OFFSET $3`,
+		courseID, pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []Chapter{}
+	for rows.Next() {
+		chapter, err := scanChapter(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, chapter)
+	}
+	return page, total, rows.Err()
+}
+
+// Get returns the chapter with the given id, or ErrNotFound.
+func (s *PostgresStore) Get(ctx context.Context, id string) (Chapter, error) {
+	chapter, err := scanChapter(s.db.QueryRow(ctx, `SELECT `+chapterColumns+` FROM course_chapters WHERE id = $1`, id))
+	if pgstore.IsNoRows(err) {
+		return Chapter{}, ErrNotFound
+	}
+	return chapter, err
+}
+
+// Update replaces the chapter with the same id, or returns ErrNotFound.
+func (s *PostgresStore) Update(ctx context.Context, chapter Chapter) error {
+	tag, err := s.db.Exec(ctx, `UPDATE course_chapters SET course_id = $2, sort_order = $3, title = $4,
+		blocks = $5, quiz_config = $6, created_at = $7, updated_at = $8 WHERE id = $1`,
+		chapter.ID, chapter.CourseID, chapter.SortOrder, chapter.Title,
+		blocksArg(chapter.Blocks), chapter.QuizConfig, chapter.CreatedAt, chapter.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrNotFound)
+}
+
+// Delete removes the chapter with the given id, or returns ErrNotFound.
+func (s *PostgresStore) Delete(ctx context.Context, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM course_chapters WHERE id = $1`, id)
+	return pgstore.CheckAffected(tag, err, ErrNotFound)
+}
+
+// DeleteByCourse removes every chapter of the given course. It is a
+// no-op when the course has no chapters.
+func (s *PostgresStore) DeleteByCourse(ctx context.Context, courseID string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM course_chapters WHERE course_id = $1`, courseID)
+	return err
+}
+
+// blocksArg stores a nil block list as the column default (an empty
+// array) instead of a JSON null.
+func blocksArg(blocks []map[string]any) []map[string]any {
+	if blocks == nil {
+		return []map[string]any{}
+	}
+	return blocks
+}
+
+func scanChapter(row pgstore.Row) (Chapter, error) {
+	var chapter Chapter
+	err := row.Scan(&chapter.ID, &chapter.CourseID, &chapter.SortOrder, &chapter.Title,
+		&chapter.Blocks, &chapter.QuizConfig, &chapter.CreatedAt, &chapter.UpdatedAt)
+	return chapter, err
+}
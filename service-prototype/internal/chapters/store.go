@@ -6,10 +6,10 @@ import (
 	"sync"
 )
 
-// Store persists chapters. The prototype ships the in-memory
-// implementation; the interface keeps the routing and service layers
-// independent of the storage backend. DeleteByCourse removes every
-// chapter of a course and backs the cascade delete of the courses
+// Store persists chapters. The prototype ships an in-memory and a
+// PostgreSQL implementation; the interface keeps the routing and service
+// layers independent of the storage backend. DeleteByCourse removes
+// every chapter of a course and backs the cascade delete of the courses
 // service (in the database the foreign key carries ON DELETE CASCADE).
 type Store interface {
 	Create(ctx context.Context, chapter Chapter) error
@@ -20,11 +20,11 @@ type Store interface {
 	DeleteByCourse(ctx context.Context, courseID string) error
 }
 
-// InMemoryStore keeps chapters in an insertion-ordered slice guarded by a
-// mutex. Listing sorts a copy by sort_order (ties broken by created_at),
-// so the store stays independent of insertion order. It implements Store
-// for the prototype and never touches a database; a database-backed store
-// arrives with a later slice.
+// InMemoryStore keeps chapters in an insertion-ordered slice guarded by
+// a mutex. Listing sorts a copy by sort_order (ties broken by
+// created_at), so the store stays independent of insertion order. It
+// implements Store for the prototype and never touches a database;
+// PostgresStore is the database-backed counterpart.
 type InMemoryStore struct {
 	mu    sync.Mutex
 	items []Chapter
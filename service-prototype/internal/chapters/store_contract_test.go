@@ -0,0 +1,90 @@
+package chapters
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/databasetest"
+)
+
+func TestInMemoryStoreContract(t *testing.T) {
+	runStoreContract(t, NewInMemoryStore(), func(string) {})
+}
+
+func TestPostgresStoreContract(t *testing.T) {
+	db := databasetest.Open(t)
+	runStoreContract(t, NewPostgresStore(db), func(courseID string) {
+		databasetest.Exec(t, db, `INSERT INTO courses (id, title, topic, type) VALUES ($1, $1, '舆情应对', '线上授课')`, courseID)
+	})
+}
+
+// runStoreContract pins the Store semantics every backend shares. The
+// addCourse hook creates the parent course the database foreign key
+// requires; the in-memory store needs none.
+func runStoreContract(t *testing.T, store Store, addCourse func(courseID string)) {
+	ctx := context.Background()
+	addCourse("course-1")
+	addCourse("course-2")
+	base := databasetest.Time(2026, 8, 2, 10, 0, 0)
+	items := []Chapter{
+		{ID: "ch-2", CourseID: "course-1", SortOrder: 2, Title: "乙", Blocks: []map[string]any{{"type": "视频", "url": "v.mp4"}}, CreatedAt: base, UpdatedAt: base},
+		{ID: "ch-0b", CourseID: "course-1", SortOrder: 0, Title: "甲后", Blocks: []map[string]any{}, CreatedAt: base.Add(2 * time.Minute), UpdatedAt: base.Add(2 * time.Minute)},
+		{ID: "ch-0a", CourseID: "course-1", SortOrder: 0, Title: "甲前", Blocks: []map[string]any{}, QuizConfig: map[string]any{"question": "?"}, CreatedAt: base.Add(time.Minute), UpdatedAt: base.Add(time.Minute)},
+		{ID: "ch-other", CourseID: "course-2", SortOrder: 0, Title: "他课", Blocks: []map[string]any{}, CreatedAt: base, UpdatedAt: base},
+	}
+	for _, item := range items {
+		if err := store.Create(ctx, item); err != nil {
+			t.Fatalf("create %s: %v", item.ID, err)
+		}
+	}
+
+	for _, want := range []Chapter{items[0], items[2]} {
+		got, err := store.Get(ctx, want.ID)
+		if err != nil {
+			t.Fatalf("get %s: %v", want.ID, err)
+		}
+		databasetest.AssertSameJSON(t, got, want)
+	}
+
+	page, total, err := store.ListByCourse(ctx, "course-1", Filter{Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if total != 3 || len(page) != 2 || page[0].ID != "ch-0b" || page[1].ID != "ch-2" {
+		t.Fatalf("list = %d %v, want total 3 and ch-0b, ch-2", total, page)
+	}
+
+	updated := items[0]
+	updated.Title = "乙（修订）"
+	updated.QuizConfig = nil
+	if err := store.Update(ctx, updated); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	got, err := store.Get(ctx, "ch-2")
+	if err != nil {
+		t.Fatalf("get updated: %v", err)
+	}
+	databasetest.AssertSameJSON(t, got, updated)
+
+	if err := store.Delete(ctx, "ch-2"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := store.Delete(ctx, "ch-2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("delete twice = %v, want ErrNotFound", err)
+	}
+	if err := store.Update(ctx, Chapter{ID: "missing", CourseID: "course-1", Title: "x"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("update missing = %v, want ErrNotFound", err)
+	}
+
+	if err := store.DeleteByCourse(ctx, "course-1"); err != nil {
+		t.Fatalf("delete by course: %v", err)
+	}
+	if _, total, _ := store.ListByCourse(ctx, "course-1", Filter{Limit: -1}); total != 0 {
+		t.Fatalf("course-1 chapters after cascade = %d, want 0", total)
+	}
+	if _, err := store.Get(ctx, "ch-other"); err != nil {
+		t.Fatalf("chapter of another course was removed: %v", err)
+	}
+}
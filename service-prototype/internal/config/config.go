@@ -31,6 +31,23 @@ type Config struct {
 	// CORSAllowedOrigins is the comma-separated CORS allow list from
 	// CORS_ALLOWED_ORIGINS. Empty when the variable is unset.
 	CORSAllowedOrigins []string
+	// IdentityBaseURL is the identityd instance whose browser sessions
+	// authenticate requests (PROTOTYPED_IDP_BASE_URL, e.g.
+	// http://127.0.0.1:8420). Empty disables session authentication.
+	IdentityBaseURL string
+	// JWKSURL, TokenIssuer and TokenAudience configure bearer-token
+	// authentication (PROTOTYPED_JWKS_URL, PROTOTYPED_TOKEN_ISSUER,
+	// PROTOTYPED_TOKEN_AUDIENCE). An empty JWKSURL disables it; the
+	// issuer and the audience are then both required.
+	JWKSURL       string
+	TokenIssuer   string
+	TokenAudience string
+}
+
+// AuthEnabled reports whether at least one authentication method is
+// configured. Without one the API stays anonymous.
+func (configuration Config) AuthEnabled() bool {
+	return configuration.IdentityBaseURL != "" || configuration.JWKSURL != ""
 }
 
 // Address returns the listen address for the HTTP server.
@@ -55,13 +72,48 @@ func LoadFromLookup(lookup func(string) string) (Config, error) {
 	if err != nil {
 		return Config{}, err
 	}
+	identityBaseURL, err := httpURLValue(lookup, "PROTOTYPED_IDP_BASE_URL")
+	if err != nil {
+		return Config{}, err
+	}
+	jwksURL, err := httpURLValue(lookup, "PROTOTYPED_JWKS_URL")
+	if err != nil {
+		return Config{}, err
+	}
+	tokenIssuer := strings.TrimSpace(lookup("PROTOTYPED_TOKEN_ISSUER"))
+	if jwksURL != "" && tokenIssuer == "" {
+		return Config{}, fmt.Errorf("PROTOTYPED_TOKEN_ISSUER must be set when PROTOTYPED_JWKS_URL is provided")
+	}
+	tokenAudience := strings.TrimSpace(lookup("PROTOTYPED_TOKEN_AUDIENCE"))
+	if jwksURL != "" && tokenAudience == "" {
+		return Config{}, fmt.Errorf("PROTOTYPED_TOKEN_AUDIENCE must be set when PROTOTYPED_JWKS_URL is provided")
+	}
 	return Config{
 		Port:               port,
 		DatabaseURL:        databaseURL,
 		CORSAllowedOrigins: splitCSV(lookup("CORS_ALLOWED_ORIGINS")),
+		IdentityBaseURL:    identityBaseURL,
+		JWKSURL:            jwksURL,
+		TokenIssuer:        tokenIssuer,
+		TokenAudience:      tokenAudience,
 	}, nil
 }
 
+// httpURLValue reads an optional absolute http(s) URL. Empty stays
+// empty; anything else that is not an http or https URL with a host is
+// an error.
+func httpURLValue(lookup func(string) string, key string) (string, error) {
+	value := strings.TrimSpace(lookup(key))
+	if value == "" {
+		return "", nil
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("%s must be an http or https URL", key)
+	}
+	return value, nil
+}
+
 // splitCSV splits a comma-separated list, trimming whitespace and dropping
 // empty entries. An empty input yields an empty (non-nil) slice.
 func splitCSV(value string) []string {
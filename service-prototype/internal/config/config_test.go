@@ -186,3 +186,47 @@ func TestLoadFromLookupCORSAllowedOriginsDefaultEmpty(t *testing.T) {
 		t.Fatalf("CORSAllowedOrigins = %#v, want empty slice", configuration.CORSAllowedOrigins)
 	}
 }
+
+// ─── 认证配置 ───────────────────────────────────────────────────────────
+
+// 未配置任何认证变量时认证关闭；PROTOTYPED_IDP_BASE_URL 或
+// PROTOTYPED_JWKS_URL 任一存在即开启，值原样保留。
+func TestLoadFromLookupAuthSettings(t *testing.T) {
+	configuration, err := LoadFromLookup(valuesLookup(map[string]string{"PITCHFORK_DB_PASSWORD": "pw"}))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if configuration.AuthEnabled() {
+		t.Fatalf("AuthEnabled = true without any auth variable")
+	}
+	configuration, err = LoadFromLookup(valuesLookup(map[string]string{
+		"PITCHFORK_DB_PASSWORD":     "pw",
+		"PROTOTYPED_IDP_BASE_URL":   "http://127.0.0.1:8420",
+		"PROTOTYPED_JWKS_URL":       "https://idp.example/jwks",
+		"PROTOTYPED_TOKEN_ISSUER":   "https://idp.example",
+		"PROTOTYPED_TOKEN_AUDIENCE": "prototyped",
+	}))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !configuration.AuthEnabled() || configuration.IdentityBaseURL != "http://127.0.0.1:8420" ||
+		configuration.JWKSURL != "https://idp.example/jwks" || configuration.TokenIssuer != "https://idp.example" ||
+		configuration.TokenAudience != "prototyped" {
+		t.Fatalf("auth settings = %#v", configuration)
+	}
+}
+
+// 非 http(s) 的 URL、以及配置了 JWKS 却缺少签发者或受众，均在启动时报错。
+func TestLoadFromLookupRejectsInvalidAuthSettings(t *testing.T) {
+	for _, values := range []map[string]string{
+		{"PROTOTYPED_IDP_BASE_URL": "127.0.0.1:8420"},
+		{"PROTOTYPED_JWKS_URL": "ftp://idp.example/jwks", "PROTOTYPED_TOKEN_ISSUER": "https://idp.example", "PROTOTYPED_TOKEN_AUDIENCE": "prototyped"},
+		{"PROTOTYPED_JWKS_URL": "https://idp.example/jwks", "PROTOTYPED_TOKEN_AUDIENCE": "prototyped"},
+		{"PROTOTYPED_JWKS_URL": "https://idp.example/jwks", "PROTOTYPED_TOKEN_ISSUER": "https://idp.example"},
+	} {
+		values["PITCHFORK_DB_PASSWORD"] = "pw"
+		if _, err := LoadFromLookup(valuesLookup(values)); err == nil {
+			t.Fatalf("values %v: expected an error", values)
+		}
+	}
+}
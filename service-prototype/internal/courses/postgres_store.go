@@ -0,0 +1,90 @@
+package courses
+
+import (
+	"context"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
+)
+
+// PostgresStore persists courses in the courses table (migration
+// 000002). It implements Store with the same semantics as the in-memory
+// store: a missing id answers ErrNotFound, listing keeps the creation
+// order (created_at, id) and a negative Limit means no limit.
+type PostgresStore struct {
+	db pgstore.Querier
+}
+
+// NewPostgresStore returns a course store over the given connection.
+func NewPostgresStore(db pgstore.Querier) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+const courseColumns = `id, title, topic, type, status, metadata, created_by, created_at, updated_at`
+
+// Create inserts the course.
+func (s *PostgresStore) Create(ctx context.Context, course Course) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO courses (`+courseColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		course.ID, course.Title, course.Topic, course.Type, course.Status,
+		course.Metadata, course.CreatedBy, course.CreatedAt, course.UpdatedAt)
+	return err
+}
+
+// List returns the courses matching the filter in creation order, the
+// total number of matches and the paginated page.
+func (s *PostgresStore) List(ctx context.Context, filter Filter) ([]Course, int, error) {
+	const where = ` WHERE ($1 = '' OR topic = $1) AND ($2 = '' OR type = $2) AND ($3 = '' OR status = $3)`
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM courses`+where,
+		filter.Topic, filter.Type, filter.Status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+courseColumns+` FROM courses`+where+`
+		ORDER BY created_at, id LIMIT $4 OFFSET $5`,
+		filter.Topic, filter.Type, filter.Status, pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []Course{}
+	for rows.Next() {
+		course, err := scanCourse(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, course)
+	}
+	return page, total, rows.Err()
+}
+
+// Get returns the course with the given id, or ErrNotFound.
+func (s *PostgresStore) Get(ctx context.Context, id string) (Course, error) {
+	course, err := scanCourse(s.db.QueryRow(ctx, `SELECT `+courseColumns+` FROM courses WHERE id = $1`, id))
+	if pgstore.IsNoRows(err) {
+		return Course{}, ErrNotFound
+	}
+	return course, err
+}
+
+// Update replaces the course with the same id, or returns ErrNotFound.
+func (s *PostgresStore) Update(ctx context.Context, course Course) error {
+	tag, err := s.db.Exec(ctx, `UPDATE courses SET title = $2, topic = $3, type = $4, status = $5,
+		metadata = $6, created_by = $7, created_at = $8, updated_at = $9 WHERE id = $1`,
+		course.ID, course.Title, course.Topic, course.Type, course.Status,
+		course.Metadata, course.CreatedBy, course.CreatedAt, course.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrNotFound)
+}
+
+// Delete removes the course with the given id, or returns ErrNotFound.
+// The database cascades the deletion to the chapters of the course.
+func (s *PostgresStore) Delete(ctx context.Context, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM courses WHERE id = $1`, id)
+	return pgstore.CheckAffected(tag, err, ErrNotFound)
+}
+
+func scanCourse(row pgstore.Row) (Course, error) {
+	var course Course
+	err := row.Scan(&course.ID, &course.Title, &course.Topic, &course.Type, &course.Status,
+		&course.Metadata, &course.CreatedBy, &course.CreatedAt, &course.UpdatedAt)
+	return course, err
+}
@@ -5,9 +5,9 @@ import (
 	"sync"
 )
 
-// Store persists courses. The prototype ships the in-memory
-// implementation; the interface keeps the routing and service layers
-// independent of the storage backend.
+// Store persists courses. The prototype ships an in-memory and a
+// PostgreSQL implementation; the interface keeps the routing and service
+// layers independent of the storage backend.
 type Store interface {
 	Create(ctx context.Context, course Course) error
 	List(ctx context.Context, filter Filter) ([]Course, int, error)
@@ -18,7 +18,7 @@ type Store interface {
 
 // InMemoryStore keeps courses in an insertion-ordered slice guarded by a
 // mutex. It implements Store for the prototype and never touches a
-// database; a database-backed store arrives with a later slice.
+// database; PostgresStore is the database-backed counterpart.
 type InMemoryStore struct {
 	mu    sync.Mutex
 	items []Course
@@ -0,0 +1,83 @@
+package courses
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/databasetest"
+)
+
+func TestInMemoryStoreContract(t *testing.T) {
+	runStoreContract(t, NewInMemoryStore())
+}
+
+func TestPostgresStoreContract(t *testing.T) {
+	runStoreContract(t, NewPostgresStore(databasetest.Open(t)))
+}
+
+// runStoreContract pins the Store semantics every backend shares: a
+// round trip keeps every field, listing filters, keeps the creation
+// order and paginates after counting, and a missing id answers
+// ErrNotFound on Get, Update and Delete.
+func runStoreContract(t *testing.T, store Store) {
+	ctx := context.Background()
+	base := databasetest.Time(2026, 8, 1, 9, 0, 0)
+	items := []Course{
+		{ID: "course-a", Title: "客流引导", Topic: TopicPassengerFlow, Type: DeliveryOnline, Status: StatusEnabled, Metadata: map[string]any{"hours": 2.0}, CreatedBy: "u1", CreatedAt: base, UpdatedAt: base},
+		{ID: "course-b", Title: "消防处置", Topic: TopicSafetyResponse, Type: DeliveryOffline, Status: StatusEnabled, Metadata: map[string]any{}, CreatedAt: base.Add(time.Minute), UpdatedAt: base.Add(time.Minute)},
+		{ID: "course-c", Title: "舆情", Topic: TopicPassengerFlow, Type: DeliveryOnline, Status: StatusDisabled, Metadata: map[string]any{}, CreatedAt: base.Add(2 * time.Minute), UpdatedAt: base.Add(2 * time.Minute)},
+	}
+	for _, item := range items {
+		if err := store.Create(ctx, item); err != nil {
+			t.Fatalf("create %s: %v", item.ID, err)
+		}
+	}
+
+	got, err := store.Get(ctx, "course-a")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	databasetest.AssertSameJSON(t, got, items[0])
+
+	page, total, err := store.List(ctx, Filter{Topic: TopicPassengerFlow, Limit: -1})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if total != 2 || len(page) != 2 || page[0].ID != "course-a" || page[1].ID != "course-c" {
+		t.Fatalf("list by topic = %d %v, want course-a, course-c", total, page)
+	}
+	page, total, err = store.List(ctx, Filter{Limit: 1, Offset: 1})
+	if err != nil {
+		t.Fatalf("list page: %v", err)
+	}
+	if total != 3 || len(page) != 1 || page[0].ID != "course-b" {
+		t.Fatalf("second page = %d %v, want total 3 and course-b", total, page)
+	}
+
+	updated := items[1]
+	updated.Title = "消防处置（修订）"
+	updated.Status = StatusDisabled
+	if err := store.Update(ctx, updated); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	got, err = store.Get(ctx, "course-b")
+	if err != nil {
+		t.Fatalf("get updated: %v", err)
+	}
+	databasetest.AssertSameJSON(t, got, updated)
+
+	if err := store.Delete(ctx, "course-b"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "course-b"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get deleted = %v, want ErrNotFound", err)
+	}
+	if err := store.Update(ctx, Course{ID: "missing", Title: "x", Topic: TopicPassengerFlow, Type: DeliveryOnline, Status: StatusEnabled, Metadata: map[string]any{}}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("update missing = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("delete missing = %v, want ErrNotFound", err)
+	}
+}
@@ -1,7 +1,8 @@
 // Package database provides the lazy PostgreSQL connection and migration
 // bootstrap of prototyped. Constructing a Connector never touches the
-// network; the first Connect call opens the connection and runs the
-// embedded migrations. Connection and migration failures are returned as
+// network; the first Connect call opens the connection and applies the
+// pending embedded migrations, tracked in schema_migrations (see
+// Migrate). Connection and migration failures are returned as
 // errors (never panics) so the caller decides how to react — prototyped
 // logs them and keeps serving without a database.
 package database
@@ -11,6 +12,8 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
 )
 
 // ErrNotConnected is returned by Ping before the first successful Connect.
@@ -19,18 +22,32 @@ var ErrNotConnected = errors.New("database: not connected")
 // Conn is the minimal database surface prototyped uses.
 type Conn interface {
 	Ping(ctx context.Context) error
-	// Exec runs a single SQL string. Implementations must accept strings
-	// containing multiple statements (migrations).
-	Exec(ctx context.Context, sql string) error
+	// Session runs fn on one dedicated connection held for the whole
+	// call, so session-scoped state (the migration advisory lock, an open
+	// transaction) spans every statement fn executes. When fn fails the
+	// connection is discarded rather than returned to a pool, so no lock
+	// or transaction outlives the call.
+	Session(ctx context.Context, fn func(Session) error) error
 	Close() error
 }
 
+// Session is one dedicated database connection handed out by
+// Conn.Session.
+type Session interface {
+	// Exec runs a single SQL string. Without args, implementations must
+	// accept strings containing multiple statements (migrations).
+	Exec(ctx context.Context, sql string, args ...any) error
+	// Query runs sql and calls scan once per result row, in order.
+	Query(ctx context.Context, sql string, scan func(row pgstore.Row) error, args ...any) error
+}
+
 // Opener opens a connection for the given DSN. It is injectable so tests
 // can stub connection failures without a real PostgreSQL server.
 type Opener func(ctx context.Context, dsn string) (Conn, error)
 
-// Migrator runs the pending migrations against an open connection. It is
-// injectable so tests can stub migration failures.
+// Migrator brings the schema of an open connection up to date. It is
+// injectable so tests can stub migration failures; the default is
+// Migrate.
 type Migrator func(ctx context.Context, conn Conn) error
 
 // Connector owns a lazily opened database connection. All methods are safe
@@ -6,26 +6,31 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
 )
 
 // stubConn is a scriptable Conn for tests: it records every executed SQL
-// string and can fail Ping, Exec, or both.
+// string, can fail Ping, Exec, or both (failOn fails just the one matching
+// statement), and keeps the schema_migrations
+// rows the migrator inserts and deletes, so versioned runs can be
+// exercised without a PostgreSQL server.
 type stubConn struct {
 	pingErr error
 	execErr error
+	failOn  string
 
 	mu       sync.Mutex
 	executed []string
+	applied  map[int]appliedMigration
 	closed   bool
 }
 
 func (s *stubConn) Ping(ctx context.Context) error { return s.pingErr }
 
-func (s *stubConn) Exec(ctx context.Context, sql string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.executed = append(s.executed, sql)
-	return s.execErr
+func (s *stubConn) Session(ctx context.Context, fn func(Session) error) error {
+	return fn(stubSession{conn: s})
 }
 
 func (s *stubConn) Close() error {
@@ -35,6 +40,61 @@ func (s *stubConn) Close() error {
 	return nil
 }
 
+// stubSession executes against its stubConn. Statements on
+// schema_migrations are applied to the stub's applied rows; everything
+// else is only recorded.
+type stubSession struct {
+	conn *stubConn
+}
+
+func (s stubSession) Exec(ctx context.Context, sql string, args ...any) error {
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+	s.conn.executed = append(s.conn.executed, sql)
+	if s.conn.execErr != nil {
+		return s.conn.execErr
+	}
+	if s.conn.failOn != "" && sql == s.conn.failOn {
+		return errors.New("stub: statement failed")
+	}
+	if s.conn.applied == nil {
+		s.conn.applied = map[int]appliedMigration{}
+	}
+	switch {
+	case strings.HasPrefix(sql, "INSERT INTO schema_migrations"):
+		version := args[0].(int)
+		s.conn.applied[version] = appliedMigration{Version: version, Name: args[1].(string), Checksum: args[2].(string), AppliedAt: time.Now()}
+	case strings.HasPrefix(sql, "DELETE FROM schema_migrations"):
+		delete(s.conn.applied, args[0].(int))
+	}
+	return nil
+}
+
+func (s stubSession) Query(ctx context.Context, sql string, scan func(row pgstore.Row) error, args ...any) error {
+	s.conn.mu.Lock()
+	rows := sortedApplied(s.conn.applied)
+	s.conn.mu.Unlock()
+	for _, row := range rows {
+		if err := scan(stubRow{row}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stubRow scans one schema_migrations row.
+type stubRow struct {
+	row appliedMigration
+}
+
+func (r stubRow) Scan(dest ...any) error {
+	*dest[0].(*int) = r.row.Version
+	*dest[1].(*string) = r.row.Name
+	*dest[2].(*string) = r.row.Checksum
+	*dest[3].(*time.Time) = r.row.AppliedAt
+	return nil
+}
+
 func (s *stubConn) sqlLog() []string {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -96,8 +156,11 @@ func TestConnectOpensAndMigratesOnce(t *testing.T) {
 	if len(executed) == 0 {
 		t.Fatal("no SQL was executed; migrations did not run")
 	}
-	if executed[0] != "BEGIN" || executed[len(executed)-1] != "COMMIT" {
-		t.Fatalf("expected a transaction around the migration, got %v", executed)
+	if !strings.Contains(executed[0], "pg_advisory_lock") || !strings.Contains(executed[len(executed)-1], "pg_advisory_unlock") {
+		t.Fatalf("expected the migrations to run under the advisory lock, got %v", executed)
+	}
+	if !containsSQL(executed, "BEGIN") || !containsSQL(executed, "COMMIT") {
+		t.Fatalf("expected a transaction around each migration, got %v", executed)
 	}
 
 	// A second Connect reuses the open connection without reopening.
@@ -0,0 +1,158 @@
+// Package databasetest opens throwaway PostgreSQL schemas for the store
+// contract tests. The PostgreSQL half of every contract suite runs only
+// when PROTOTYPED_TEST_DATABASE_URL names a reachable server; otherwise
+// the tests skip and the in-memory half still runs, so `go test ./...`
+// never needs a database.
+package databasetest
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/ulid"
+)
+
+// EnvDatabaseURL names the environment variable carrying the DSN of the
+// test server. The DSN must allow CREATE SCHEMA.
+const EnvDatabaseURL = "PROTOTYPED_TEST_DATABASE_URL"
+
+// Open creates a fresh schema on the test server, runs every embedded
+// migration inside it and returns a Querier bound to it. Each call gets
+// its own schema (dropped on cleanup), so packages tested in parallel
+// never see each other's rows. Open skips the test when the environment
+// variable is unset.
+func Open(t *testing.T) pgstore.Querier {
+	t.Helper()
+	dsn := lookupDSN(t)
+	ctx := context.Background()
+
+	admin, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect test database: %v", err)
+	}
+	schema := "prototyped_test_" + strings.ToLower(ulid.New())
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		_ = admin.Close(ctx)
+		t.Fatalf("create test schema: %v", err)
+	}
+
+	connector := database.New(withSearchPath(t, dsn, schema))
+	t.Cleanup(func() {
+		_ = connector.Close()
+		_, _ = admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+		_ = admin.Close(context.Background())
+	})
+	if err := connector.Connect(ctx); err != nil {
+		t.Fatalf("migrate test schema: %v", err)
+	}
+	db, err := connector.Querier()
+	if err != nil {
+		t.Fatalf("test querier: %v", err)
+	}
+	return db
+}
+
+// Exec runs a fixture statement (typically inserting the parent rows a
+// foreign key requires) and fails the test on error.
+func Exec(t *testing.T, db pgstore.Querier, sql string, arguments ...any) {
+	t.Helper()
+	if _, err := db.Exec(context.Background(), sql, arguments...); err != nil {
+		t.Fatalf("fixture %q: %v", sql, err)
+	}
+}
+
+// InsertRun inserts a drill scenario and a drill run with the given id,
+// the parent every run-scoped table references. It is a no-op when the
+// run already exists.
+func InsertRun(t *testing.T, db pgstore.Querier, runID string) {
+	t.Helper()
+	Exec(t, db, `INSERT INTO drill_scenarios (id, name, category, background) VALUES ($1, $1, '火灾', '')
+		ON CONFLICT (id) DO NOTHING`, "scenario-"+runID)
+	Exec(t, db, `INSERT INTO drill_runs (id, scenario_id, title) VALUES ($1, $2, $1)
+		ON CONFLICT (id) DO NOTHING`, runID, "scenario-"+runID)
+}
+
+// Time returns a UTC timestamp at microsecond precision, the resolution
+// of TIMESTAMPTZ, so values survive a database round trip unchanged.
+func Time(year int, month time.Month, day, hour, minute, second int) time.Time {
+	return time.Date(year, month, day, hour, minute, second, 0, time.UTC)
+}
+
+// AssertSameJSON fails the test when got and want differ once both are
+// encoded as JSON. Timestamps are compared as instants (the database
+// returns them in the local zone), and JSON numbers decode to float64 on
+// both sides, so the comparison is independent of the backend.
+func AssertSameJSON(t *testing.T, got, want any) {
+	t.Helper()
+	gotValue, wantValue := normalizedJSON(t, got), normalizedJSON(t, want)
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		gotText, _ := json.Marshal(gotValue)
+		wantText, _ := json.Marshal(wantValue)
+		t.Fatalf("mismatch:\n got  %s\n want %s", gotText, wantText)
+	}
+}
+
+func normalizedJSON(t *testing.T, value any) any {
+	t.Helper()
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("encode %T: %v", value, err)
+	}
+	var decoded any
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("decode %T: %v", value, err)
+	}
+	return normalizeTimes(decoded)
+}
+
+// normalizeTimes rewrites every RFC 3339 string to its UTC form.
+func normalizeTimes(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		for key, item := range typed {
+			typed[key] = normalizeTimes(item)
+		}
+	case []any:
+		for i, item := range typed {
+			typed[i] = normalizeTimes(item)
+		}
+	case string:
+		if parsed, err := time.Parse(time.RFC3339Nano, typed); err == nil {
+			return parsed.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return value
+}
+
+func lookupDSN(t *testing.T) string {
+	t.Helper()
+	dsn := strings.TrimSpace(os.Getenv(EnvDatabaseURL))
+	if dsn == "" {
+		t.Skipf("%s not set; skipping the PostgreSQL store contract", EnvDatabaseURL)
+	}
+	return dsn
+}
+
+// withSearchPath pins every pooled connection to the test schema through
+// the search_path runtime parameter, so the unqualified table names of
+// the migrations and the stores resolve inside it.
+func withSearchPath(t *testing.T, dsn, schema string) string {
+	t.Helper()
+	parsed, err := url.Parse(dsn)
+	if err != nil || parsed.Scheme == "" {
+		t.Fatalf("%s must be a postgres:// URL", EnvDatabaseURL)
+	}
+	query := parsed.Query()
+	query.Set("search_path", schema)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
@@ -2,39 +2,359 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/ovaphlow/pitchfork/service-prototype/db/migrations"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
 )
 
-// Migrate runs every embedded migration against the connection, in strictly
-// increasing version order. Each migration executes inside a transaction so
-// a failure leaves no partial schema behind; the error is returned to the
-// caller, which decides whether to keep going without a database.
+// migrationLockKey is the pg_advisory_lock key every migrator takes before
+// it reads or changes schema_migrations, so two replicas starting at the
+// same time migrate one after the other instead of racing on the same
+// DDL. The value spells "protomig" in ASCII.
+const migrationLockKey int64 = 0x70726f746f6d6967
+
+// createSchemaMigrations creates the bookkeeping table: one row per
+// applied migration with the checksum of the file that was applied.
+const createSchemaMigrations = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    BIGINT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    checksum   TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+
+var (
+	// ErrMigrationDrift is returned when schema_migrations records a
+	// migration whose embedded file changed since it was applied, or which
+	// the binary no longer embeds. The schema no longer matches the code,
+	// so nothing is applied or rolled back.
+	ErrMigrationDrift = errors.New("database: applied migrations drifted from the embedded files")
+	// ErrIrreversibleMigration is returned when a rollback reaches a
+	// migration that ships no down file.
+	ErrIrreversibleMigration = errors.New("database: migration has no down file")
+	// ErrUnknownMigration is returned by MigrateTo for a target version
+	// that is neither 0 nor an embedded migration.
+	ErrUnknownMigration = errors.New("database: unknown migration version")
+	// ErrNoAppliedMigration is returned by MigrateDown when there is
+	// nothing to roll back.
+	ErrNoAppliedMigration = errors.New("database: no applied migration")
+)
+
+// Direction tells whether a MigrationStep applied or reverted a migration.
+type Direction string
+
+const (
+	DirectionUp   Direction = "up"
+	DirectionDown Direction = "down"
+)
+
+// MigrationStep is one migration executed by the migrator, in execution
+// order.
+type MigrationStep struct {
+	Version   int
+	Name      string
+	Direction Direction
+}
+
+// MigrationStatus describes one migration for `prototyped migrate status`:
+// every embedded migration, plus any applied version the binary no longer
+// embeds (reported with Drift set). AppliedAt is nil for a pending
+// migration.
+type MigrationStatus struct {
+	Version    int
+	Name       string
+	Applied    bool
+	AppliedAt  *time.Time
+	Reversible bool
+	Drift      bool
+}
+
+// appliedMigration is one row of schema_migrations.
+type appliedMigration struct {
+	Version   int
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Migrate brings the schema up to the latest embedded migration. It is the
+// Migrator of every Connector built by New, so Connect applies only the
+// pending migrations and fails with ErrMigrationDrift when an applied file
+// changed.
 func Migrate(ctx context.Context, conn Conn) error {
+	_, err := MigrateUp(ctx, conn)
+	return err
+}
+
+// MigrateUp applies every pending embedded migration in version order and
+// returns the steps it executed (none when the schema is up to date).
+func MigrateUp(ctx context.Context, conn Conn) ([]MigrationStep, error) {
 	list, err := migrations.Parse(migrations.Files)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return migrateUp(ctx, conn, list)
+}
+
+// MigrateDown rolls back the most recently applied migration. It returns
+// ErrNoAppliedMigration on an empty schema and ErrIrreversibleMigration
+// when that migration ships no down file.
+func MigrateDown(ctx context.Context, conn Conn) (MigrationStep, error) {
+	list, err := migrations.Parse(migrations.Files)
+	if err != nil {
+		return MigrationStep{}, err
+	}
+	return migrateDown(ctx, conn, list)
+}
+
+// MigrateTo moves the schema to the given version: applied migrations
+// above it are rolled back newest first, pending migrations up to it are
+// applied oldest first. Version 0 rolls back everything. Every rollback is
+// checked for a down file before anything runs, so an irreversible
+// migration stops the command without a partial rollback.
+func MigrateTo(ctx context.Context, conn Conn, version int) ([]MigrationStep, error) {
+	list, err := migrations.Parse(migrations.Files)
+	if err != nil {
+		return nil, err
+	}
+	return migrateTo(ctx, conn, list, version)
+}
+
+// MigrationStatuses reports every embedded migration with its applied
+// state. Drift is reported per row instead of failing, so the command can
+// show what changed.
+func MigrationStatuses(ctx context.Context, conn Conn) ([]MigrationStatus, error) {
+	list, err := migrations.Parse(migrations.Files)
+	if err != nil {
+		return nil, err
+	}
+	return migrationStatuses(ctx, conn, list)
+}
+
+func migrateUp(ctx context.Context, conn Conn, list []migrations.Migration) ([]MigrationStep, error) {
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return migrateTo(ctx, conn, list, list[len(list)-1].Version)
+}
+
+func migrateDown(ctx context.Context, conn Conn, list []migrations.Migration) (MigrationStep, error) {
+	var step MigrationStep
+	err := withMigrationLock(ctx, conn, func(session Session, applied map[int]appliedMigration) error {
+		if err := checkDrift(list, applied); err != nil {
+			return err
+		}
+		for i := len(list) - 1; i >= 0; i-- {
+			if _, ok := applied[list[i].Version]; !ok {
+				continue
+			}
+			if !list[i].Reversible() {
+				return fmt.Errorf("%w: %s", ErrIrreversibleMigration, migrationLabel(list[i]))
+			}
+			step = MigrationStep{Version: list[i].Version, Name: list[i].Name, Direction: DirectionDown}
+			return revertMigration(ctx, session, list[i])
+		}
+		return ErrNoAppliedMigration
+	})
+	return step, err
+}
+
+func migrateTo(ctx context.Context, conn Conn, list []migrations.Migration, version int) ([]MigrationStep, error) {
+	if version != 0 && !hasVersion(list, version) {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownMigration, version)
+	}
+	var steps []MigrationStep
+	err := withMigrationLock(ctx, conn, func(session Session, applied map[int]appliedMigration) error {
+		if err := checkDrift(list, applied); err != nil {
+			return err
+		}
+		var down, up []migrations.Migration
+		for i := len(list) - 1; i >= 0; i-- {
+			if _, ok := applied[list[i].Version]; ok && list[i].Version > version {
+				if !list[i].Reversible() {
+					return fmt.Errorf("%w: %s", ErrIrreversibleMigration, migrationLabel(list[i]))
+				}
+				down = append(down, list[i])
+			}
+		}
+		for _, migration := range list {
+			if _, ok := applied[migration.Version]; !ok && migration.Version <= version {
+				up = append(up, migration)
+			}
+		}
+		for _, migration := range down {
+			if err := revertMigration(ctx, session, migration); err != nil {
+				return err
+			}
+			steps = append(steps, MigrationStep{Version: migration.Version, Name: migration.Name, Direction: DirectionDown})
+		}
+		for _, migration := range up {
+			if err := applyMigration(ctx, session, migration); err != nil {
+				return err
+			}
+			steps = append(steps, MigrationStep{Version: migration.Version, Name: migration.Name, Direction: DirectionUp})
+		}
+		return nil
+	})
+	return steps, err
+}
+
+func migrationStatuses(ctx context.Context, conn Conn, list []migrations.Migration) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := withMigrationLock(ctx, conn, func(session Session, applied map[int]appliedMigration) error {
+		embedded := map[int]bool{}
+		for _, migration := range list {
+			embedded[migration.Version] = true
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name, Reversible: migration.Reversible()}
+			if row, ok := applied[migration.Version]; ok {
+				appliedAt := row.AppliedAt
+				status.Applied = true
+				status.AppliedAt = &appliedAt
+				status.Drift = row.Checksum != migration.Checksum
+			}
+			statuses = append(statuses, status)
+		}
+		for version, row := range applied {
+			if !embedded[version] {
+				appliedAt := row.AppliedAt
+				statuses = append(statuses, MigrationStatus{Version: version, Name: row.Name, Applied: true, AppliedAt: &appliedAt, Drift: true})
+			}
+		}
+		return nil
+	})
+	sortStatuses(statuses)
+	return statuses, err
+}
+
+// withMigrationLock runs fn on one session holding the migration advisory
+// lock, with schema_migrations created and loaded. The lock is released
+// before the session ends; if fn fails, Conn.Session discards the
+// connection, which releases the lock on the server as well.
+func withMigrationLock(ctx context.Context, conn Conn, fn func(session Session, applied map[int]appliedMigration) error) error {
+	return conn.Session(ctx, func(session Session) error {
+		if err := session.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		err := func() error {
+			if err := session.Exec(ctx, createSchemaMigrations); err != nil {
+				return fmt.Errorf("create schema_migrations: %w", err)
+			}
+			applied, err := loadApplied(ctx, session)
+			if err != nil {
+				return fmt.Errorf("read schema_migrations: %w", err)
+			}
+			return fn(session, applied)
+		}()
+		if unlockErr := session.Exec(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey); unlockErr != nil && err == nil {
+			err = fmt.Errorf("release migration lock: %w", unlockErr)
+		}
+		return err
+	})
+}
+
+func loadApplied(ctx context.Context, session Session) (map[int]appliedMigration, error) {
+	applied := map[int]appliedMigration{}
+	err := session.Query(ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version", func(row pgstore.Row) error {
+		var item appliedMigration
+		if err := row.Scan(&item.Version, &item.Name, &item.Checksum, &item.AppliedAt); err != nil {
+			return err
+		}
+		applied[item.Version] = item
+		return nil
+	})
+	return applied, err
+}
+
+// checkDrift fails when an applied migration is missing from the binary or
+// its embedded file no longer matches the recorded checksum.
+func checkDrift(list []migrations.Migration, applied map[int]appliedMigration) error {
+	byVersion := map[int]migrations.Migration{}
 	for _, migration := range list {
-		if err := runMigration(ctx, conn, migration); err != nil {
-			return fmt.Errorf("migration %06d_%s: %w", migration.Version, migration.Name, err)
+		byVersion[migration.Version] = migration
+	}
+	for _, status := range sortedApplied(applied) {
+		migration, ok := byVersion[status.Version]
+		if !ok {
+			return fmt.Errorf("%w: %06d_%s is applied but not embedded", ErrMigrationDrift, status.Version, status.Name)
+		}
+		if migration.Checksum != status.Checksum {
+			return fmt.Errorf("%w: %s changed after it was applied", ErrMigrationDrift, migrationLabel(migration))
 		}
 	}
 	return nil
 }
 
-func runMigration(ctx context.Context, conn Conn, migration migrations.Migration) error {
-	if err := conn.Exec(ctx, "BEGIN"); err != nil {
+// applyMigration runs the up script and records it in one transaction, so
+// a failure leaves neither a partial schema nor a bookkeeping row behind.
+func applyMigration(ctx context.Context, session Session, migration migrations.Migration) error {
+	err := inTransaction(ctx, session, func() error {
+		if err := session.Exec(ctx, migration.SQL); err != nil {
+			return err
+		}
+		return session.Exec(ctx, "INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)",
+			migration.Version, migration.Name, migration.Checksum)
+	})
+	if err != nil {
+		return fmt.Errorf("migration %s: %w", migrationLabel(migration), err)
+	}
+	return nil
+}
+
+// revertMigration runs the down script and forgets the migration in one
+// transaction.
+func revertMigration(ctx context.Context, session Session, migration migrations.Migration) error {
+	err := inTransaction(ctx, session, func() error {
+		if err := session.Exec(ctx, migration.DownSQL); err != nil {
+			return err
+		}
+		return session.Exec(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+	})
+	if err != nil {
+		return fmt.Errorf("rollback %s: %w", migrationLabel(migration), err)
+	}
+	return nil
+}
+
+func inTransaction(ctx context.Context, session Session, fn func() error) error {
+	if err := session.Exec(ctx, "BEGIN"); err != nil {
 		return err
 	}
-	if err := conn.Exec(ctx, migration.SQL); err != nil {
-		_ = conn.Exec(ctx, "ROLLBACK")
+	if err := fn(); err != nil {
+		_ = session.Exec(ctx, "ROLLBACK")
 		return err
 	}
-	if err := conn.Exec(ctx, "COMMIT"); err != nil {
-		_ = conn.Exec(ctx, "ROLLBACK")
+	if err := session.Exec(ctx, "COMMIT"); err != nil {
+		_ = session.Exec(ctx, "ROLLBACK")
 		return err
 	}
 	return nil
 }
+
+func hasVersion(list []migrations.Migration, version int) bool {
+	for _, migration := range list {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+func migrationLabel(migration migrations.Migration) string {
+	return fmt.Sprintf("%06d_%s", migration.Version, migration.Name)
+}
+
+func sortedApplied(applied map[int]appliedMigration) []appliedMigration {
+	rows := make([]appliedMigration, 0, len(applied))
+	for _, row := range applied {
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Version < rows[j].Version })
+	return rows
+}
+
+func sortStatuses(statuses []MigrationStatus) {
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+}
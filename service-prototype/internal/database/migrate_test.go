@@ -0,0 +1,198 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/db/migrations"
+)
+
+func containsSQL(executed []string, sql string) bool {
+	for _, statement := range executed {
+		if statement == sql {
+			return true
+		}
+	}
+	return false
+}
+
+// testMigrations parses a three-step migration set: 1 and 2 ship down
+// files, 3 does not.
+func testMigrations(t *testing.T, files fstest.MapFS) []migrations.Migration {
+	t.Helper()
+	if files == nil {
+		files = fstest.MapFS{
+			"000001_one.sql":      {Data: []byte("CREATE TABLE one ();")},
+			"000001_one.down.sql": {Data: []byte("DROP TABLE one;")},
+			"000002_two.sql":      {Data: []byte("CREATE TABLE two ();")},
+			"000002_two.down.sql": {Data: []byte("DROP TABLE two;")},
+			"000003_three.sql":    {Data: []byte("CREATE TABLE three ();")},
+		}
+	}
+	list, err := migrations.Parse(files)
+	if err != nil {
+		t.Fatalf("parse test migrations: %v", err)
+	}
+	return list
+}
+
+func appliedVersions(conn *stubConn) []int {
+	var versions []int
+	for _, row := range sortedApplied(conn.applied) {
+		versions = append(versions, row.Version)
+	}
+	return versions
+}
+
+func TestMigrateUpAppliesOnlyPendingMigrations(t *testing.T) {
+	ctx := context.Background()
+	conn := &stubConn{}
+	list := testMigrations(t, nil)
+
+	steps, err := migrateTo(ctx, conn, list, 2)
+	if err != nil || len(steps) != 2 || steps[1].Version != 2 || steps[1].Direction != DirectionUp {
+		t.Fatalf("migrate to 2 = %v %v, want 1 and 2 applied", steps, err)
+	}
+	steps, err = migrateUp(ctx, conn, list)
+	if err != nil || len(steps) != 1 || steps[0].Version != 3 {
+		t.Fatalf("migrate up = %v %v, want only 3 applied", steps, err)
+	}
+	before := len(conn.sqlLog())
+	steps, err = migrateUp(ctx, conn, list)
+	if err != nil || len(steps) != 0 {
+		t.Fatalf("second migrate up = %v %v, want nothing to do", steps, err)
+	}
+	for _, statement := range conn.sqlLog()[before:] {
+		if statement == "CREATE TABLE one ();" {
+			t.Fatal("an applied migration was executed again")
+		}
+	}
+}
+
+func TestMigrateFailsOnChecksumDrift(t *testing.T) {
+	ctx := context.Background()
+	conn := &stubConn{}
+	if _, err := migrateUp(ctx, conn, testMigrations(t, nil)); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	edited := testMigrations(t, fstest.MapFS{
+		"000001_one.sql":   {Data: []byte("CREATE TABLE one (id TEXT);")},
+		"000002_two.sql":   {Data: []byte("CREATE TABLE two ();")},
+		"000003_three.sql": {Data: []byte("CREATE TABLE three ();")},
+		"000004_four.sql":  {Data: []byte("CREATE TABLE four ();")},
+	})
+	if _, err := migrateUp(ctx, conn, edited); !errors.Is(err, ErrMigrationDrift) {
+		t.Fatalf("migrate up after editing 000001 = %v, want ErrMigrationDrift", err)
+	}
+	if versions := appliedVersions(conn); len(versions) != 3 {
+		t.Fatalf("applied = %v, want 000004 held back by the drift", versions)
+	}
+
+	statuses, err := migrationStatuses(ctx, conn, edited)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if len(statuses) != 4 || !statuses[0].Drift || statuses[1].Drift || statuses[3].Applied {
+		t.Fatalf("statuses = %+v, want drift on 1 and 4 pending", statuses)
+	}
+}
+
+func TestMigrateFailsWhenAppliedMigrationIsNotEmbedded(t *testing.T) {
+	ctx := context.Background()
+	conn := &stubConn{}
+	if _, err := migrateUp(ctx, conn, testMigrations(t, nil)); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	older := testMigrations(t, fstest.MapFS{
+		"000001_one.sql": {Data: []byte("CREATE TABLE one ();")},
+		"000002_two.sql": {Data: []byte("CREATE TABLE two ();")},
+	})
+	if _, err := migrateUp(ctx, conn, older); !errors.Is(err, ErrMigrationDrift) {
+		t.Fatalf("migrate up with an older binary = %v, want ErrMigrationDrift", err)
+	}
+}
+
+func TestMigrateDownRevertsLatestMigration(t *testing.T) {
+	ctx := context.Background()
+	conn := &stubConn{}
+	list := testMigrations(t, nil)
+	if _, err := migrateTo(ctx, conn, list, 2); err != nil {
+		t.Fatalf("migrate to 2: %v", err)
+	}
+	step, err := migrateDown(ctx, conn, list)
+	if err != nil || step.Version != 2 || step.Direction != DirectionDown {
+		t.Fatalf("down = %+v %v, want 2 reverted", step, err)
+	}
+	if !containsSQL(conn.sqlLog(), "DROP TABLE two;") {
+		t.Fatal("the down script of 000002 did not run")
+	}
+	if _, err := migrateDown(ctx, conn, list); err != nil {
+		t.Fatalf("second down: %v", err)
+	}
+	if _, err := migrateDown(ctx, conn, list); !errors.Is(err, ErrNoAppliedMigration) {
+		t.Fatalf("down on an empty schema = %v, want ErrNoAppliedMigration", err)
+	}
+}
+
+func TestMigrateToRefusesIrreversibleRollback(t *testing.T) {
+	ctx := context.Background()
+	conn := &stubConn{}
+	list := testMigrations(t, nil)
+	if _, err := migrateUp(ctx, conn, list); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	if _, err := migrateTo(ctx, conn, list, 1); !errors.Is(err, ErrIrreversibleMigration) {
+		t.Fatalf("migrate to 1 across 000003 = %v, want ErrIrreversibleMigration", err)
+	}
+	if versions := appliedVersions(conn); len(versions) != 3 {
+		t.Fatalf("applied = %v, want no partial rollback", versions)
+	}
+	if _, err := migrateTo(ctx, conn, list, 7); !errors.Is(err, ErrUnknownMigration) {
+		t.Fatalf("migrate to 7 = %v, want ErrUnknownMigration", err)
+	}
+}
+
+func TestMigrateToZeroRevertsEverything(t *testing.T) {
+	ctx := context.Background()
+	conn := &stubConn{}
+	list := testMigrations(t, nil)
+	if _, err := migrateTo(ctx, conn, list, 2); err != nil {
+		t.Fatalf("migrate to 2: %v", err)
+	}
+	steps, err := migrateTo(ctx, conn, list, 0)
+	if err != nil || len(steps) != 2 || steps[0].Version != 2 || steps[1].Version != 1 {
+		t.Fatalf("migrate to 0 = %v %v, want 2 then 1 reverted", steps, err)
+	}
+	if versions := appliedVersions(conn); len(versions) != 0 {
+		t.Fatalf("applied = %v, want none", versions)
+	}
+}
+
+func TestFailedMigrationIsNotRecorded(t *testing.T) {
+	ctx := context.Background()
+	conn := &stubConn{failOn: "CREATE TABLE two ();"}
+	if _, err := migrateUp(ctx, conn, testMigrations(t, nil)); err == nil {
+		t.Fatal("expected the failing migration to surface")
+	}
+	if versions := appliedVersions(conn); len(versions) != 1 || versions[0] != 1 {
+		t.Fatalf("applied = %v, want only 000001 recorded", versions)
+	}
+	if !containsSQL(conn.sqlLog(), "ROLLBACK") {
+		t.Fatal("the failed migration was not rolled back")
+	}
+}
+
+func TestEmbeddedMigrationsApplyAndRevertCleanly(t *testing.T) {
+	ctx := context.Background()
+	conn := &stubConn{}
+	applied, err := MigrateUp(ctx, conn)
+	if err != nil || len(applied) == 0 {
+		t.Fatalf("migrate up = %d steps, %v", len(applied), err)
+	}
+	reverted, err := MigrateTo(ctx, conn, 0)
+	if err != nil || len(reverted) != len(applied) {
+		t.Fatalf("migrate to 0 = %d steps, %v, want %d", len(reverted), err, len(applied))
+	}
+}
@@ -5,6 +5,8 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
 )
 
 // openPG opens a lazy pgx connection pool for the given DSN. Creating the
@@ -23,6 +25,13 @@ func openPG(ctx context.Context, dsn string) (Conn, error) {
 	return &pgConn{pool: pool}, nil
 }
 
+// Open opens a pgx-backed connection without running any migration. The
+// migrate command uses it to drive MigrateUp, MigrateDown, MigrateTo and
+// MigrationStatuses explicitly instead of through Connect.
+func Open(ctx context.Context, dsn string) (Conn, error) {
+	return openPG(ctx, dsn)
+}
+
 // pgConn is the pgx-backed implementation of Conn.
 type pgConn struct {
 	pool *pgxpool.Pool
@@ -32,19 +41,58 @@ func (c *pgConn) Ping(ctx context.Context) error {
 	return c.pool.Ping(ctx)
 }
 
-// Exec runs a single SQL string. Migrations may contain multiple
-// statements, so the simple protocol is used, which executes the whole
-// string server-side.
-func (c *pgConn) Exec(ctx context.Context, sql string) error {
+// Session acquires one pooled connection for fn. A failed fn may leave
+// session state behind (a held advisory lock, an aborted transaction), so
+// the connection is then closed instead of being released to the pool.
+func (c *pgConn) Session(ctx context.Context, fn func(Session) error) error {
 	conn, err := c.pool.Acquire(ctx)
 	if err != nil {
 		return err
 	}
 	defer conn.Release()
-	_, err = conn.Exec(ctx, sql, pgx.QueryExecModeSimpleProtocol)
+	if err := fn(pgSession{conn: conn.Conn()}); err != nil {
+		_ = conn.Conn().Close(context.Background())
+		return err
+	}
+	return nil
+}
+
+// pgSession is the pgx-backed implementation of Session.
+type pgSession struct {
+	conn *pgx.Conn
+}
+
+// Exec runs a single SQL string. Migrations may contain multiple
+// statements, so a string without arguments uses the simple protocol,
+// which executes the whole string server-side.
+func (s pgSession) Exec(ctx context.Context, sql string, args ...any) error {
+	if len(args) == 0 {
+		_, err := s.conn.Exec(ctx, sql, pgx.QueryExecModeSimpleProtocol)
+		return err
+	}
+	_, err := s.conn.Exec(ctx, sql, args...)
 	return err
 }
 
+func (s pgSession) Query(ctx context.Context, sql string, scan func(row pgstore.Row) error, args ...any) error {
+	rows, err := s.conn.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Querier exposes the pool to the PostgreSQL-backed stores.
+func (c *pgConn) Querier() pgstore.Querier {
+	return c.pool
+}
+
 func (c *pgConn) Close() error {
 	c.pool.Close()
 	return nil
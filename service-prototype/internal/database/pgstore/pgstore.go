@@ -0,0 +1,83 @@
+// Package pgstore holds the query surface shared by the PostgreSQL-backed
+// stores of every slice: the Querier interface satisfied by the pgx pool,
+// the Row scan surface and the helpers translating pgx outcomes into the
+// store semantics (pagination limits, not-found and unique-violation
+// errors). It depends on pgx only, so any store package can import it
+// without pulling in the connection and migration bootstrap.
+package pgstore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Querier is the query surface of the PostgreSQL-backed stores. Both the
+// pgx pool and a pgx transaction satisfy it, so a store can run against
+// either without knowing which one it holds.
+type Querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, arguments ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, arguments ...any) pgx.Row
+}
+
+// Row is the scan surface shared by pgx.Row and pgx.Rows, so one scan
+// helper per store serves both single-row reads and listings.
+type Row interface {
+	Scan(dest ...any) error
+}
+
+// LimitArg converts a store Limit into a LIMIT argument. The in-memory
+// stores treat a negative limit as "no limit"; LIMIT NULL has the same
+// meaning in PostgreSQL.
+func LimitArg(limit int) any {
+	if limit < 0 {
+		return nil
+	}
+	return limit
+}
+
+// IsNoRows reports whether err is the pgx "no rows in result set" error,
+// which the stores translate into their package ErrNotFound.
+func IsNoRows(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows)
+}
+
+// CheckAffected translates the outcome of an UPDATE or DELETE by primary
+// key: a failed statement returns its error, a statement that touched no
+// row returns notFound (the store package's ErrNotFound).
+func CheckAffected(tag pgconn.CommandTag, err error, notFound error) error {
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return notFound
+	}
+	return nil
+}
+
+// IsUniqueViolation reports whether err is a PostgreSQL unique_violation
+// (SQLSTATE 23505), which the stores translate into their package
+// "already exists" error where the in-memory store has one.
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// InTx runs fn against a transaction begun on db and commits it when fn
+// returns nil, so a store can write several rows atomically. The pgx
+// pool begins a transaction and a pgx transaction a savepoint; any other
+// Querier cannot begin one and fails.
+func InTx(ctx context.Context, db Querier, fn func(tx Querier) error) error {
+	beginner, ok := db.(interface {
+		Begin(ctx context.Context) (pgx.Tx, error)
+	})
+	if !ok {
+		return errors.New("pgstore: the querier cannot begin a transaction")
+	}
+	return pgx.BeginFunc(ctx, beginner, func(tx pgx.Tx) error {
+		return fn(tx)
+	})
+}
@@ -0,0 +1,34 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
+)
+
+// ErrNoQuerier is returned by Connector.Querier when the open connection
+// does not expose a query surface (e.g. a stub connection in tests).
+var ErrNoQuerier = errors.New("database: connection does not support queries")
+
+// querierSource is implemented by connections that expose a Querier.
+// Only the pgx-backed connection does; stub connections in tests do not.
+type querierSource interface {
+	Querier() pgstore.Querier
+}
+
+// Querier returns the query surface of the open connection for the
+// PostgreSQL-backed stores. It returns ErrNotConnected before the first
+// successful Connect and ErrNoQuerier when the connection cannot run
+// queries.
+func (c *Connector) Querier() (pgstore.Querier, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil, ErrNotConnected
+	}
+	source, ok := c.conn.(querierSource)
+	if !ok {
+		return nil, ErrNoQuerier
+	}
+	return source.Querier(), nil
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/drills"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/runstream"
 )
 
 // ErrDepartmentNotFound is returned when the run exists but the
@@ -131,12 +132,23 @@ type DepartmentReport struct {
 // current value and an explicit one must be an adjacent forward
 // transition); note defaults to an empty string; arrived_at is optional
 // (null when omitted); created_by passes through (the prototype has no
-// auth context).
+// auth context). Actor names who made the status change recorded in the
+// DepartmentTransition; empty, it falls back to created_by.
 type DepartmentReportInput struct {
 	Status    DepartmentStatus
 	Note      string
 	ArrivedAt *time.Time
 	CreatedBy string
+	Actor     string
+}
+
+// actor returns who the transition appended by this input is attributed
+// to: the explicit actor, else created_by.
+func (input DepartmentReportInput) actor() string {
+	if input.Actor != "" {
+		return input.Actor
+	}
+	return input.CreatedBy
 }
 
 // DepartmentFilter selects department reports for listing. Empty enum
@@ -196,7 +208,8 @@ func departmentWritableRun(status drills.RunStatus) bool {
 // (same-status no-ops are legal), and note/arrived_at/created_by follow
 // full replacement semantics (omitted fields reset to their defaults).
 // The run must be 进行中 (400 otherwise); a missing run is
-// ErrRunNotFound (404).
+// ErrRunNotFound (404). The creation and every status change append one
+// DepartmentTransition carrying the actor and the note of the input.
 func (s *Service) UpsertDepartment(ctx context.Context, runID string, department Department, input DepartmentReportInput) (DepartmentReport, error) {
 	run, err := s.source.GetRun(ctx, runID)
 	if err != nil {
@@ -222,9 +235,10 @@ func (s *Service) UpsertDepartment(ctx context.Context, runID string, department
 				Message: "status must be 未响应 at creation",
 			}
 		}
-		if err := s.store.UpsertDepartment(ctx, report); err != nil {
+		if err := s.store.TransitionDepartment(ctx, report, s.departmentTransition(report, "", input.actor(), input.Note, now)); err != nil {
 			return DepartmentReport{}, err
 		}
+		s.publish(runID, runstream.KindDepartment, runstream.ActionCreated, string(department), report)
 		return report, nil
 	}
 	report, err := normalizeDepartmentReport(runID, department, input, now, existing.ID)
@@ -242,9 +256,15 @@ func (s *Service) UpsertDepartment(ctx context.Context, runID string, department
 		}
 	}
 	report.CreatedAt = existing.CreatedAt
-	if err := s.store.UpsertDepartment(ctx, report); err != nil {
+	if report.Status != existing.Status {
+		err = s.store.TransitionDepartment(ctx, report, s.departmentTransition(report, existing.Status, input.actor(), input.Note, now))
+	} else {
+		err = s.store.UpsertDepartment(ctx, report)
+	}
+	if err != nil {
 		return DepartmentReport{}, err
 	}
+	s.publish(runID, runstream.KindDepartment, runstream.ActionUpdated, string(department), report)
 	return report, nil
 }
 
@@ -281,7 +301,11 @@ func (s *Service) DeleteDepartment(ctx context.Context, runID string, department
 			Message: "run status " + string(run.Status) + " does not allow this operation",
 		}
 	}
-	return s.store.DeleteDepartment(ctx, runID, department)
+	if err := s.store.DeleteDepartment(ctx, runID, department); err != nil {
+		return err
+	}
+	s.publish(runID, runstream.KindDepartment, runstream.ActionDeleted, string(department), nil)
+	return nil
 }
 
 // ─── In-memory store ─────────────────────────────────────────────────
@@ -291,14 +315,19 @@ func (s *Service) DeleteDepartment(ctx context.Context, runID string, department
 func (s *InMemoryStore) UpsertDepartment(_ context.Context, report DepartmentReport) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.upsertDepartment(report)
+	return nil
+}
+
+// upsertDepartment is UpsertDepartment for a caller holding the lock.
+func (s *InMemoryStore) upsertDepartment(report DepartmentReport) {
 	for i, item := range s.departments {
 		if item.RunID == report.RunID && item.Department == report.Department {
 			s.departments[i] = cloneDepartmentReport(report)
-			return nil
+			return
 		}
 	}
 	s.departments = append(s.departments, cloneDepartmentReport(report))
-	return nil
 }
 
 // ListDepartments returns the reports of the run matching the filter
@@ -356,7 +385,11 @@ func (s *InMemoryStore) DeleteDepartment(_ context.Context, runID string, depart
 	if index < 0 {
 		return ErrDepartmentNotFound
 	}
+	reportID := s.departments[index].ID
 	s.departments = append(s.departments[:index], s.departments[index+1:]...)
+	s.dropDepartmentTransitions(func(item DepartmentTransition) bool {
+		return item.RunID == runID && item.ReportID == reportID
+	})
 	return nil
 }
 
@@ -374,6 +407,7 @@ func (s *InMemoryStore) DeleteDepartmentsByRun(_ context.Context, runID string)
 		}
 	}
 	s.departments = kept
+	s.dropDepartmentTransitions(func(item DepartmentTransition) bool { return item.RunID == runID })
 	return nil
 }
 
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/drills"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/runstream"
 )
 
 // ErrDeviceNotFound is returned when the run exists but the device
@@ -194,6 +195,7 @@ func (s *Service) CreateDevice(ctx context.Context, runID string, input DeviceIn
 	if err := s.store.CreateDevice(ctx, device); err != nil {
 		return Device{}, err
 	}
+	s.publish(runID, runstream.KindDevice, runstream.ActionCreated, device.ID, device)
 	return device, nil
 }
 
@@ -251,6 +253,7 @@ func (s *Service) UpdateDevice(ctx context.Context, runID, id string, input Devi
 	if err := s.store.UpdateDevice(ctx, updated); err != nil {
 		return Device{}, err
 	}
+	s.publish(runID, runstream.KindDevice, runstream.ActionUpdated, updated.ID, updated)
 	return updated, nil
 }
 
@@ -268,7 +271,11 @@ func (s *Service) DeleteDevice(ctx context.Context, runID, id string) error {
 			Message: "run status " + string(run.Status) + " does not allow this operation",
 		}
 	}
-	return s.store.DeleteDevice(ctx, runID, id)
+	if err := s.store.DeleteDevice(ctx, runID, id); err != nil {
+		return err
+	}
+	s.publish(runID, runstream.KindDevice, runstream.ActionDeleted, id, nil)
+	return nil
 }
 
 // ─── In-memory store ─────────────────────────────────────────────────
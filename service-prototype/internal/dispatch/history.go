@@ -0,0 +1,228 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// OrderTransition is one entry of the status history (状态流转记录) of a
+// dispatch order: the status before (from, empty for the creation
+// entry) and after (to) the change, who made it (actor), the optional
+// note and when it happened. The service appends one entry when an
+// order is issued and one per status change; same-status updates and
+// edits of the other fields append nothing. The log is append-only:
+// entries are never updated, they only vanish together with their
+// order or run.
+type OrderTransition struct {
+	ID        string      `json:"id"`
+	RunID     string      `json:"run_id"`
+	OrderID   string      `json:"order_id"`
+	From      OrderStatus `json:"from"`
+	To        OrderStatus `json:"to"`
+	Actor     string      `json:"actor"`
+	Note      string      `json:"note"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// DepartmentTransition is one entry of the status history of a
+// department report, with the same shape and rules as OrderTransition:
+// one entry when the report is created (from empty to 未响应) and one
+// per status change. ReportID is the id of the report; the routes
+// address a report by its department, so both are kept.
+type DepartmentTransition struct {
+	ID         string           `json:"id"`
+	RunID      string           `json:"run_id"`
+	ReportID   string           `json:"report_id"`
+	Department Department       `json:"department"`
+	From       DepartmentStatus `json:"from"`
+	To         DepartmentStatus `json:"to"`
+	Actor      string           `json:"actor"`
+	Note       string           `json:"note"`
+	CreatedAt  time.Time        `json:"created_at"`
+}
+
+// orderTransition builds the history entry of the order reaching its
+// current status from from.
+func (s *Service) orderTransition(order Order, from OrderStatus, actor, note string, at time.Time) OrderTransition {
+	return OrderTransition{
+		ID:        s.newID(),
+		RunID:     order.RunID,
+		OrderID:   order.ID,
+		From:      from,
+		To:        order.Status,
+		Actor:     actor,
+		Note:      note,
+		CreatedAt: at,
+	}
+}
+
+// departmentTransition builds the history entry of the department
+// report reaching its current status from from.
+func (s *Service) departmentTransition(report DepartmentReport, from DepartmentStatus, actor, note string, at time.Time) DepartmentTransition {
+	return DepartmentTransition{
+		ID:         s.newID(),
+		RunID:      report.RunID,
+		ReportID:   report.ID,
+		Department: report.Department,
+		From:       from,
+		To:         report.Status,
+		Actor:      actor,
+		Note:       note,
+		CreatedAt:  at,
+	}
+}
+
+// OrderHistory returns the status history of the order, oldest first.
+// A missing run is ErrRunNotFound and a missing order ErrOrderNotFound
+// (both 404); GET is not subject to the write gate.
+func (s *Service) OrderHistory(ctx context.Context, runID, id string) ([]OrderTransition, error) {
+	if _, err := s.GetOrder(ctx, runID, id); err != nil {
+		return nil, err
+	}
+	return s.store.ListOrderTransitions(ctx, runID, id)
+}
+
+// DepartmentHistory returns the status history of the department report
+// of the run, oldest first. A missing run is ErrRunNotFound, a
+// department without a report ErrDepartmentNotFound and an unknown
+// department a ValidationError.
+func (s *Service) DepartmentHistory(ctx context.Context, runID string, department Department) ([]DepartmentTransition, error) {
+	if _, err := s.source.GetRun(ctx, runID); err != nil {
+		return nil, err
+	}
+	if !department.Valid() {
+		return nil, &ValidationError{Message: fmt.Sprintf("invalid department: %q", department)}
+	}
+	if _, err := s.store.GetDepartment(ctx, runID, department); err != nil {
+		return nil, err
+	}
+	return s.store.ListDepartmentTransitions(ctx, runID, department)
+}
+
+// ─── In-memory store ─────────────────────────────────────────────────
+
+// TransitionOrder writes the order and appends the entry to its history
+// under one lock. The creation entry (empty From) inserts the order;
+// any other entry replaces it, or returns ErrOrderNotFound and appends
+// nothing.
+func (s *InMemoryStore) TransitionOrder(_ context.Context, order Order, transition OrderTransition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if transition.From == "" {
+		s.orders = append(s.orders, cloneOrder(order))
+	} else {
+		index := s.indexOfOrder(order.RunID, order.ID)
+		if index < 0 {
+			return ErrOrderNotFound
+		}
+		s.orders[index] = cloneOrder(order)
+	}
+	s.orderTransitions = append(s.orderTransitions, transition)
+	return nil
+}
+
+// ListOrderTransitions returns the history of the order within the run
+// ordered by created_at ASC, id ASC.
+func (s *InMemoryStore) ListOrderTransitions(_ context.Context, runID, orderID string) ([]OrderTransition, error) {
+	return s.orderTransitionsWhere(func(item OrderTransition) bool {
+		return item.RunID == runID && item.OrderID == orderID
+	}), nil
+}
+
+// ListOrderTransitionsByRun returns the history of every order of the
+// run ordered by created_at ASC, id ASC.
+func (s *InMemoryStore) ListOrderTransitionsByRun(_ context.Context, runID string) ([]OrderTransition, error) {
+	return s.orderTransitionsWhere(func(item OrderTransition) bool {
+		return item.RunID == runID
+	}), nil
+}
+
+func (s *InMemoryStore) orderTransitionsWhere(keep func(OrderTransition) bool) []OrderTransition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	matched := []OrderTransition{}
+	for _, item := range s.orderTransitions {
+		if keep(item) {
+			matched = append(matched, item)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].ID < matched[j].ID
+		}
+		return matched[i].CreatedAt.Before(matched[j].CreatedAt)
+	})
+	return matched
+}
+
+// dropOrderTransitions removes the history entries matching drop (the
+// in-memory counterpart of the order foreign key's ON DELETE CASCADE).
+// The caller holds the lock.
+func (s *InMemoryStore) dropOrderTransitions(drop func(OrderTransition) bool) {
+	kept := s.orderTransitions[:0]
+	for _, item := range s.orderTransitions {
+		if !drop(item) {
+			kept = append(kept, item)
+		}
+	}
+	s.orderTransitions = kept
+}
+
+// TransitionDepartment upserts the report like UpsertDepartment and
+// appends the entry to its history under one lock.
+func (s *InMemoryStore) TransitionDepartment(_ context.Context, report DepartmentReport, transition DepartmentTransition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.upsertDepartment(report)
+	s.departmentTransitions = append(s.departmentTransitions, transition)
+	return nil
+}
+
+// ListDepartmentTransitions returns the history of the department
+// report within the run ordered by created_at ASC, id ASC.
+func (s *InMemoryStore) ListDepartmentTransitions(_ context.Context, runID string, department Department) ([]DepartmentTransition, error) {
+	return s.departmentTransitionsWhere(func(item DepartmentTransition) bool {
+		return item.RunID == runID && item.Department == department
+	}), nil
+}
+
+// ListDepartmentTransitionsByRun returns the history of every
+// department report of the run ordered by created_at ASC, id ASC.
+func (s *InMemoryStore) ListDepartmentTransitionsByRun(_ context.Context, runID string) ([]DepartmentTransition, error) {
+	return s.departmentTransitionsWhere(func(item DepartmentTransition) bool {
+		return item.RunID == runID
+	}), nil
+}
+
+func (s *InMemoryStore) departmentTransitionsWhere(keep func(DepartmentTransition) bool) []DepartmentTransition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	matched := []DepartmentTransition{}
+	for _, item := range s.departmentTransitions {
+		if keep(item) {
+			matched = append(matched, item)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].ID < matched[j].ID
+		}
+		return matched[i].CreatedAt.Before(matched[j].CreatedAt)
+	})
+	return matched
+}
+
+// dropDepartmentTransitions removes the history entries matching drop
+// (the in-memory counterpart of the report foreign key's ON DELETE
+// CASCADE). The caller holds the lock.
+func (s *InMemoryStore) dropDepartmentTransitions(drop func(DepartmentTransition) bool) {
+	kept := s.departmentTransitions[:0]
+	for _, item := range s.departmentTransitions {
+		if !drop(item) {
+			kept = append(kept, item)
+		}
+	}
+	s.departmentTransitions = kept
+}
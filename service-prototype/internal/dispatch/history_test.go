@@ -0,0 +1,164 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/drills"
+)
+
+// ─── OrderHistory ────────────────────────────────────────────────────
+
+// 指令流转记录：下达时一条（空→待接收，actor 为 created_by，时刻等于
+// issued_at）；每次状态变化一条（携带 actor/note，时刻等于 updated_at，
+// 完成时等于 completed_at）；只改其他字段、同状态 no-op、被拒绝的迁移
+// 都不追加。
+func TestOrderHistoryRecordsEveryTransition(t *testing.T) {
+	service, _ := newTestService(run("run-1", drills.RunStatusInProgress))
+	ctx := context.Background()
+	created, err := service.CreateOrder(ctx, "run-1", OrderInput{
+		Title: "一号", Content: "内容", TargetType: TargetTypeDepartment, TargetName: "疏散组", CreatedBy: "指挥长",
+	})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	title := "一号（改）"
+	if _, err := service.UpdateOrder(ctx, "run-1", created.ID, OrderUpdate{Title: &title}); err != nil {
+		t.Fatalf("title update: %v", err)
+	}
+	var last Order
+	for _, step := range []struct {
+		status OrderStatus
+		actor  string
+		note   string
+	}{
+		{OrderStatusReceived, "疏散组", "收到"},
+		{OrderStatusReceived, "疏散组", "重复提交"},
+		{OrderStatusExecuting, "疏散组", ""},
+		{OrderStatusCompleted, "疏散组", "东区已清空"},
+	} {
+		status := step.status
+		if last, err = service.UpdateOrder(ctx, "run-1", created.ID, OrderUpdate{Status: &status, Actor: step.actor, Note: step.note}); err != nil {
+			t.Fatalf("→%s: %v", step.status, err)
+		}
+	}
+	back := OrderStatusExecuting
+	if _, err := service.UpdateOrder(ctx, "run-1", created.ID, OrderUpdate{Status: &back}); !errors.As(err, &validationErrorType) {
+		t.Fatalf("已完成→执行中: err = %v, want ValidationError", err)
+	}
+
+	history, err := service.OrderHistory(ctx, "run-1", created.ID)
+	if err != nil {
+		t.Fatalf("OrderHistory: %v", err)
+	}
+	want := []struct {
+		from, to    OrderStatus
+		actor, note string
+	}{
+		{"", OrderStatusPending, "指挥长", ""},
+		{OrderStatusPending, OrderStatusReceived, "疏散组", "收到"},
+		{OrderStatusReceived, OrderStatusExecuting, "疏散组", ""},
+		{OrderStatusExecuting, OrderStatusCompleted, "疏散组", "东区已清空"},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("history = %+v, want %d entries", history, len(want))
+	}
+	for i, entry := range history {
+		if entry.From != want[i].from || entry.To != want[i].to || entry.Actor != want[i].actor || entry.Note != want[i].note {
+			t.Errorf("entry %d = %+v, want %+v", i, entry, want[i])
+		}
+		if entry.RunID != "run-1" || entry.OrderID != created.ID || !crockford26.MatchString(entry.ID) {
+			t.Errorf("entry %d ids = %q/%q/%q", i, entry.ID, entry.RunID, entry.OrderID)
+		}
+	}
+	if !history[0].CreatedAt.Equal(*created.IssuedAt) {
+		t.Fatalf("creation entry at %v, want issued_at %v", history[0].CreatedAt, *created.IssuedAt)
+	}
+	if final := history[3].CreatedAt; !final.Equal(*last.CompletedAt) || !final.Equal(last.UpdatedAt) {
+		t.Fatalf("completion entry at %v, want completed_at %v and updated_at %v", final, *last.CompletedAt, last.UpdatedAt)
+	}
+}
+
+// 查询失败路径：run 不存在 → ErrRunNotFound；指令不存在 →
+// ErrOrderNotFound；已删除指令的历史随之消失。
+func TestOrderHistoryNotFound(t *testing.T) {
+	service, store := newTestService(run("run-1", drills.RunStatusInProgress))
+	ctx := context.Background()
+	if _, err := service.OrderHistory(ctx, "run-missing", "ord-1"); !errors.Is(err, ErrRunNotFound) {
+		t.Fatalf("missing run: err = %v, want ErrRunNotFound", err)
+	}
+	if _, err := service.OrderHistory(ctx, "run-1", "ord-missing"); !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("missing order: err = %v, want ErrOrderNotFound", err)
+	}
+	created, err := service.CreateOrder(ctx, "run-1", OrderInput{
+		Title: "一号", Content: "内容", TargetType: TargetTypeDepartment, TargetName: "疏散组",
+	})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	if err := service.DeleteOrder(ctx, "run-1", created.ID); err != nil {
+		t.Fatalf("DeleteOrder: %v", err)
+	}
+	if history, _ := store.ListOrderTransitionsByRun(ctx, "run-1"); len(history) != 0 {
+		t.Fatalf("history after delete = %+v, want none", history)
+	}
+}
+
+// ─── DepartmentHistory ───────────────────────────────────────────────
+
+// 部门流转记录：创建一条（空→未响应）；状态变化各一条，actor 缺省回落
+// 到 created_by；只改 note 不追加；删除后重建从新记录重新开始。
+func TestDepartmentHistoryRecordsEveryTransition(t *testing.T) {
+	service, _ := newTestService(run("run-1", drills.RunStatusInProgress))
+	ctx := context.Background()
+	for _, input := range []DepartmentReportInput{
+		{CreatedBy: "值班员"},
+		{Status: DepartmentStatusResponded, Note: "已出警", CreatedBy: "值班员", Actor: "消防指挥"},
+		{Note: "途中", CreatedBy: "值班员"},
+		{Status: DepartmentStatusArrived, Note: "已到场", CreatedBy: "值班员"},
+	} {
+		if _, err := service.UpsertDepartment(ctx, "run-1", DepartmentFire, input); err != nil {
+			t.Fatalf("UpsertDepartment %+v: %v", input, err)
+		}
+	}
+	history, err := service.DepartmentHistory(ctx, "run-1", DepartmentFire)
+	if err != nil {
+		t.Fatalf("DepartmentHistory: %v", err)
+	}
+	want := []struct {
+		from, to    DepartmentStatus
+		actor, note string
+	}{
+		{"", DepartmentStatusNotResponded, "值班员", ""},
+		{DepartmentStatusNotResponded, DepartmentStatusResponded, "消防指挥", "已出警"},
+		{DepartmentStatusResponded, DepartmentStatusArrived, "值班员", "已到场"},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("history = %+v, want %d entries", history, len(want))
+	}
+	for i, entry := range history {
+		if entry.From != want[i].from || entry.To != want[i].to || entry.Actor != want[i].actor || entry.Note != want[i].note || entry.Department != DepartmentFire {
+			t.Errorf("entry %d = %+v, want %+v", i, entry, want[i])
+		}
+	}
+
+	if err := service.DeleteDepartment(ctx, "run-1", DepartmentFire); err != nil {
+		t.Fatalf("DeleteDepartment: %v", err)
+	}
+	if _, err := service.DepartmentHistory(ctx, "run-1", DepartmentFire); !errors.Is(err, ErrDepartmentNotFound) {
+		t.Fatalf("deleted report: err = %v, want ErrDepartmentNotFound", err)
+	}
+	if _, err := service.UpsertDepartment(ctx, "run-1", DepartmentFire, DepartmentReportInput{}); err != nil {
+		t.Fatalf("recreate: %v", err)
+	}
+	if history, err := service.DepartmentHistory(ctx, "run-1", DepartmentFire); err != nil || len(history) != 1 {
+		t.Fatalf("history after recreate = %+v %v, want the creation entry only", history, err)
+	}
+	if _, err := service.DepartmentHistory(ctx, "run-1", Department("交警")); !errors.As(err, &validationErrorType) {
+		t.Fatalf("unknown department: err = %v, want ValidationError", err)
+	}
+	if _, err := service.DepartmentHistory(ctx, "run-missing", DepartmentFire); !errors.Is(err, ErrRunNotFound) {
+		t.Fatalf("missing run: err = %v, want ErrRunNotFound", err)
+	}
+}
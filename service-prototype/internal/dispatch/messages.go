@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/drills"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/runstream"
 )
 
 // ErrMessageNotFound is returned when the run exists but the dispatch
@@ -138,6 +139,7 @@ func (s *Service) CreateMessage(ctx context.Context, runID string, input Message
 	if err := s.store.CreateMessage(ctx, message); err != nil {
 		return Message{}, err
 	}
+	s.publish(runID, runstream.KindMessage, runstream.ActionCreated, message.ID, message)
 	return message, nil
 }
 
@@ -177,7 +179,11 @@ func (s *Service) DeleteMessage(ctx context.Context, runID, id string) error {
 			Message: "run status " + string(run.Status) + " does not allow this operation",
 		}
 	}
-	return s.store.DeleteMessage(ctx, runID, id)
+	if err := s.store.DeleteMessage(ctx, runID, id); err != nil {
+		return err
+	}
+	s.publish(runID, runstream.KindMessage, runstream.ActionDeleted, id, nil)
+	return nil
 }
 
 // ─── In-memory store ─────────────────────────────────────────────────
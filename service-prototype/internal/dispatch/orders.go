@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/drills"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/runstream"
 )
 
 // ErrOrderNotFound is returned when the run exists but the dispatch
@@ -159,7 +160,9 @@ type OrderInput struct {
 // OrderUpdate carries the client-supplied fields of an order update
 // (partial update: nil means "keep the current value"; HasDeadline
 // tells an omitted deadline from an explicit null that clears it).
-// issued_at/created_at/created_by are never updatable.
+// issued_at/created_at/created_by are never updatable. Actor and Note
+// are not order fields: they describe a status change and only end up
+// in the OrderTransition it appends.
 type OrderUpdate struct {
 	Title       *string
 	Content     *string
@@ -170,6 +173,8 @@ type OrderUpdate struct {
 	Feedback    *string
 	HasDeadline bool
 	Deadline    *time.Time
+	Actor       string
+	Note        string
 }
 
 // OrderFilter selects orders for listing. Empty enum values match
@@ -256,7 +261,9 @@ func orderWritableRun(status drills.RunStatus) bool {
 
 // CreateOrder issues a dispatch order within the run and returns the
 // created row. The run must be 进行中 (400 otherwise); a missing run is
-// ErrRunNotFound (404). issued_at is set by the service at creation.
+// ErrRunNotFound (404). issued_at is set by the service at creation,
+// and the first OrderTransition (from empty to 待接收, by created_by) is
+// stamped with the same instant.
 func (s *Service) CreateOrder(ctx context.Context, runID string, input OrderInput) (Order, error) {
 	run, err := s.source.GetRun(ctx, runID)
 	if err != nil {
@@ -272,9 +279,10 @@ func (s *Service) CreateOrder(ctx context.Context, runID string, input OrderInpu
 	if err != nil {
 		return Order{}, err
 	}
-	if err := s.store.CreateOrder(ctx, order); err != nil {
+	if err := s.store.TransitionOrder(ctx, order, s.orderTransition(order, "", order.CreatedBy, "", now)); err != nil {
 		return Order{}, err
 	}
+	s.publish(runID, runstream.KindOrder, runstream.ActionCreated, order.ID, order)
 	return order, nil
 }
 
@@ -307,7 +315,10 @@ func (s *Service) ListOrders(ctx context.Context, runID string, filter OrderFilt
 // status state machine only allows adjacent forward transitions
 // 待接收→已接收→执行中→已完成 (same-status no-ops are legal); the
 // transition to 已完成 sets completed_at and a change away from it
-// clears it. issued_at/created_at/created_by are preserved.
+// clears it. issued_at/created_at/created_by are preserved. A status
+// change appends one OrderTransition carrying update.Actor and
+// update.Note, stamped with the same instant as updated_at (and
+// completed_at when it completes the order).
 func (s *Service) UpdateOrder(ctx context.Context, runID, id string, update OrderUpdate) (Order, error) {
 	run, err := s.source.GetRun(ctx, runID)
 	if err != nil {
@@ -361,6 +372,8 @@ func (s *Service) UpdateOrder(ctx context.Context, runID, id string, update Orde
 	if update.HasDeadline {
 		order.Deadline = update.Deadline
 	}
+	now := s.now()
+	from := order.Status
 	if update.Status != nil {
 		if !update.Status.Valid() {
 			return Order{}, &ValidationError{Message: fmt.Sprintf("invalid status: %q", *update.Status)}
@@ -371,7 +384,6 @@ func (s *Service) UpdateOrder(ctx context.Context, runID, id string, update Orde
 					Message: fmt.Sprintf("invalid status transition: %q -> %q", order.Status, *update.Status),
 				}
 			}
-			now := s.now()
 			if *update.Status == OrderStatusCompleted {
 				if order.CompletedAt == nil {
 					order.CompletedAt = &now
@@ -382,10 +394,16 @@ func (s *Service) UpdateOrder(ctx context.Context, runID, id string, update Orde
 			order.Status = *update.Status
 		}
 	}
-	order.UpdatedAt = s.now()
-	if err := s.store.UpdateOrder(ctx, order); err != nil {
+	order.UpdatedAt = now
+	if order.Status != from {
+		err = s.store.TransitionOrder(ctx, order, s.orderTransition(order, from, update.Actor, update.Note, now))
+	} else {
+		err = s.store.UpdateOrder(ctx, order)
+	}
+	if err != nil {
 		return Order{}, err
 	}
+	s.publish(runID, runstream.KindOrder, runstream.ActionUpdated, order.ID, order)
 	return order, nil
 }
 
@@ -401,7 +419,11 @@ func (s *Service) DeleteOrder(ctx context.Context, runID, id string) error {
 			Message: "run status " + string(run.Status) + " does not allow this operation",
 		}
 	}
-	return s.store.DeleteOrder(ctx, runID, id)
+	if err := s.store.DeleteOrder(ctx, runID, id); err != nil {
+		return err
+	}
+	s.publish(runID, runstream.KindOrder, runstream.ActionDeleted, id, nil)
+	return nil
 }
 
 // paginate computes the page bounds for a list of total items: the page
@@ -501,6 +523,9 @@ func (s *InMemoryStore) DeleteOrder(_ context.Context, runID, id string) error {
 		return ErrOrderNotFound
 	}
 	s.orders = append(s.orders[:index], s.orders[index+1:]...)
+	s.dropOrderTransitions(func(item OrderTransition) bool {
+		return item.RunID == runID && item.OrderID == id
+	})
 	return nil
 }
 
@@ -518,6 +543,7 @@ func (s *InMemoryStore) DeleteOrdersByRun(_ context.Context, runID string) error
 		}
 	}
 	s.orders = kept
+	s.dropOrderTransitions(func(item OrderTransition) bool { return item.RunID == runID })
 	return nil
 }
 
@@ -0,0 +1,582 @@
+package dispatch
+
+import (
+	"context"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
+)
+
+// PostgresStore persists the dispatch rows in the dispatch_* tables
+// (migrations 000017 to 000022, the status history 000033). It implements Store with the same
+// semantics as the in-memory store: the session is upserted on its run,
+// department reports on (run_id, department), every other row is
+// addressed by (run_id, id) so a row of another run is not found, and
+// the listings keep the sort orders documented on the in-memory methods.
+// The database cascades a run deletion on its own; the DeleteXByRun
+// methods stay explicit so the drills cleanup hook behaves identically
+// on both backends.
+type PostgresStore struct {
+	db pgstore.Querier
+}
+
+// NewPostgresStore returns a dispatch store over the given connection.
+func NewPostgresStore(db pgstore.Querier) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+const (
+	sessionColumns     = `id, run_id, mode, main_venue, joint_venues, metadata, created_by, created_at, updated_at`
+	orderColumns       = `id, run_id, title, content, priority, target_type, target_name, status, feedback, deadline, issued_at, completed_at, created_by, created_at, updated_at`
+	departmentColumns  = `id, run_id, department, status, note, arrived_at, created_by, created_at, updated_at`
+	messageColumns     = `id, run_id, sender_type, sender_name, content, sent_at, created_by, created_at, updated_at`
+	zoneDensityColumns = `id, run_id, zone_name, people_count, reported_at, created_by, created_at, updated_at`
+	deviceColumns      = `id, run_id, device_name, device_type, status, note, created_by, created_at, updated_at`
+
+	orderTransitionColumns      = `id, run_id, order_id, from_status, to_status, actor, note, created_at`
+	departmentTransitionColumns = `id, run_id, report_id, department, from_status, to_status, actor, note, created_at`
+)
+
+// UpsertSession inserts the session or replaces the session of the same
+// run.
+func (s *PostgresStore) UpsertSession(ctx context.Context, session Session) error {
+	venues := session.JointVenues
+	if venues == nil {
+		venues = []string{}
+	}
+	_, err := s.db.Exec(ctx, `INSERT INTO dispatch_sessions (`+sessionColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (run_id) DO UPDATE SET id = EXCLUDED.id, mode = EXCLUDED.mode,
+			main_venue = EXCLUDED.main_venue, joint_venues = EXCLUDED.joint_venues,
+			metadata = EXCLUDED.metadata, created_by = EXCLUDED.created_by,
+			created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at`,
+		session.ID, session.RunID, session.Mode, session.MainVenue, venues,
+		session.Metadata, session.CreatedBy, session.CreatedAt, session.UpdatedAt)
+	return err
+}
+
+// GetSession returns the session of the run, or ErrSessionNotFound.
+func (s *PostgresStore) GetSession(ctx context.Context, runID string) (Session, error) {
+	var session Session
+	err := s.db.QueryRow(ctx, `SELECT `+sessionColumns+` FROM dispatch_sessions WHERE run_id = $1`, runID).
+		Scan(&session.ID, &session.RunID, &session.Mode, &session.MainVenue, &session.JointVenues,
+			&session.Metadata, &session.CreatedBy, &session.CreatedAt, &session.UpdatedAt)
+	if pgstore.IsNoRows(err) {
+		return Session{}, ErrSessionNotFound
+	}
+	return session, err
+}
+
+// DeleteSession removes the session of the run, or ErrSessionNotFound.
+func (s *PostgresStore) DeleteSession(ctx context.Context, runID string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM dispatch_sessions WHERE run_id = $1`, runID)
+	return pgstore.CheckAffected(tag, err, ErrSessionNotFound)
+}
+
+// DeleteSessionsByRun removes the session of the run. Removing no
+// session is not an error.
+func (s *PostgresStore) DeleteSessionsByRun(ctx context.Context, runID string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM dispatch_sessions WHERE run_id = $1`, runID)
+	return err
+}
+
+// CreateOrder inserts the order.
+func (s *PostgresStore) CreateOrder(ctx context.Context, order Order) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO dispatch_orders (`+orderColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`,
+		order.ID, order.RunID, order.Title, order.Content, order.Priority, order.TargetType,
+		order.TargetName, order.Status, order.Feedback, order.Deadline, order.IssuedAt,
+		order.CompletedAt, order.CreatedBy, order.CreatedAt, order.UpdatedAt)
+	return err
+}
+
+// GetOrder returns the order with the given id within the run, or
+// ErrOrderNotFound.
+func (s *PostgresStore) GetOrder(ctx context.Context, runID, id string) (Order, error) {
+	order, err := scanOrder(s.db.QueryRow(ctx, `SELECT `+orderColumns+` FROM dispatch_orders
+		WHERE run_id = $1 AND id = $2`, runID, id))
+	if pgstore.IsNoRows(err) {
+		return Order{}, ErrOrderNotFound
+	}
+	return order, err
+}
+
+// ListOrders returns the orders of the run matching the filter ordered by
+// created_at DESC, id DESC, the total number of matches and the page.
+func (s *PostgresStore) ListOrders(ctx context.Context, runID string, filter OrderFilter) ([]Order, int, error) {
+	const where = ` WHERE run_id = $1 AND ($2 = '' OR status = $2) AND ($3 = '' OR priority = $3)
+		AND ($4 = '' OR target_type = $4)`
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM dispatch_orders`+where,
+		runID, filter.Status, filter.Priority, filter.TargetType).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+orderColumns+` FROM dispatch_orders`+where+`
+		ORDER BY created_at DESC, id DESC LIMIT $5 OFFSET $6`,
+		runID, filter.Status, filter.Priority, filter.TargetType,
+		pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []Order{}
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, order)
+	}
+	return page, total, rows.Err()
+}
+
+// UpdateOrder replaces the order with the same run and id, or returns
+// ErrOrderNotFound.
+func (s *PostgresStore) UpdateOrder(ctx context.Context, order Order) error {
+	tag, err := s.db.Exec(ctx, `UPDATE dispatch_orders SET title = $3, content = $4, priority = $5,
+		target_type = $6, target_name = $7, status = $8, feedback = $9, deadline = $10,
+		issued_at = $11, completed_at = $12, created_by = $13, created_at = $14, updated_at = $15
+		WHERE run_id = $2 AND id = $1`,
+		order.ID, order.RunID, order.Title, order.Content, order.Priority, order.TargetType,
+		order.TargetName, order.Status, order.Feedback, order.Deadline, order.IssuedAt,
+		order.CompletedAt, order.CreatedBy, order.CreatedAt, order.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrOrderNotFound)
+}
+
+// DeleteOrder removes the order with the given id within the run, or
+// returns ErrOrderNotFound.
+func (s *PostgresStore) DeleteOrder(ctx context.Context, runID, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM dispatch_orders WHERE run_id = $1 AND id = $2`, runID, id)
+	return pgstore.CheckAffected(tag, err, ErrOrderNotFound)
+}
+
+// DeleteOrdersByRun removes every order of the run. Removing no orders
+// is not an error.
+func (s *PostgresStore) DeleteOrdersByRun(ctx context.Context, runID string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM dispatch_orders WHERE run_id = $1`, runID)
+	return err
+}
+
+// UpsertDepartment inserts the report or replaces the report of the same
+// (run_id, department) pair.
+func (s *PostgresStore) UpsertDepartment(ctx context.Context, report DepartmentReport) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO dispatch_department_reports (`+departmentColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (run_id, department) DO UPDATE SET id = EXCLUDED.id, status = EXCLUDED.status,
+			note = EXCLUDED.note, arrived_at = EXCLUDED.arrived_at, created_by = EXCLUDED.created_by,
+			created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at`,
+		report.ID, report.RunID, report.Department, report.Status, report.Note, report.ArrivedAt,
+		report.CreatedBy, report.CreatedAt, report.UpdatedAt)
+	return err
+}
+
+// GetDepartment returns the report of the department within the run, or
+// ErrDepartmentNotFound.
+func (s *PostgresStore) GetDepartment(ctx context.Context, runID string, department Department) (DepartmentReport, error) {
+	report, err := scanDepartment(s.db.QueryRow(ctx, `SELECT `+departmentColumns+` FROM dispatch_department_reports
+		WHERE run_id = $1 AND department = $2`, runID, department))
+	if pgstore.IsNoRows(err) {
+		return DepartmentReport{}, ErrDepartmentNotFound
+	}
+	return report, err
+}
+
+// ListDepartments returns the reports of the run matching the filter
+// ordered by created_at ASC, id ASC, the total number of matches and the
+// paginated page.
+func (s *PostgresStore) ListDepartments(ctx context.Context, runID string, filter DepartmentFilter) ([]DepartmentReport, int, error) {
+	const where = ` WHERE run_id = $1 AND ($2 = '' OR department = $2) AND ($3 = '' OR status = $3)`
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM dispatch_department_reports`+where,
+		runID, filter.Department, filter.Status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+departmentColumns+` FROM dispatch_department_reports`+where+`
+		ORDER BY created_at, id LIMIT $4 OFFSET $5`,
+		runID, filter.Department, filter.Status, pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []DepartmentReport{}
+	for rows.Next() {
+		report, err := scanDepartment(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, report)
+	}
+	return page, total, rows.Err()
+}
+
+// DeleteDepartment removes the report of the department within the run,
+// or returns ErrDepartmentNotFound.
+func (s *PostgresStore) DeleteDepartment(ctx context.Context, runID string, department Department) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM dispatch_department_reports WHERE run_id = $1 AND department = $2`,
+		runID, department)
+	return pgstore.CheckAffected(tag, err, ErrDepartmentNotFound)
+}
+
+// DeleteDepartmentsByRun removes every report of the run. Removing no
+// reports is not an error.
+func (s *PostgresStore) DeleteDepartmentsByRun(ctx context.Context, runID string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM dispatch_department_reports WHERE run_id = $1`, runID)
+	return err
+}
+
+// CreateMessage inserts the message.
+func (s *PostgresStore) CreateMessage(ctx context.Context, message Message) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO dispatch_messages (`+messageColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		message.ID, message.RunID, message.SenderType, message.SenderName, message.Content,
+		message.SentAt, message.CreatedBy, message.CreatedAt, message.UpdatedAt)
+	return err
+}
+
+// GetMessage returns the message with the given id within the run, or
+// ErrMessageNotFound.
+func (s *PostgresStore) GetMessage(ctx context.Context, runID, id string) (Message, error) {
+	message, err := scanMessage(s.db.QueryRow(ctx, `SELECT `+messageColumns+` FROM dispatch_messages
+		WHERE run_id = $1 AND id = $2`, runID, id))
+	if pgstore.IsNoRows(err) {
+		return Message{}, ErrMessageNotFound
+	}
+	return message, err
+}
+
+// ListMessages returns the messages of the run matching the filter in
+// chat order (created_at ASC, id ASC), the total number of matches and
+// the paginated page.
+func (s *PostgresStore) ListMessages(ctx context.Context, runID string, filter MessageFilter) ([]Message, int, error) {
+	const where = ` WHERE run_id = $1 AND ($2 = '' OR sender_type = $2)`
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM dispatch_messages`+where,
+		runID, filter.SenderType).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+messageColumns+` FROM dispatch_messages`+where+`
+		ORDER BY created_at, id LIMIT $3 OFFSET $4`,
+		runID, filter.SenderType, pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []Message{}
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, message)
+	}
+	return page, total, rows.Err()
+}
+
+// DeleteMessage removes the message with the given id within the run, or
+// returns ErrMessageNotFound.
+func (s *PostgresStore) DeleteMessage(ctx context.Context, runID, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM dispatch_messages WHERE run_id = $1 AND id = $2`, runID, id)
+	return pgstore.CheckAffected(tag, err, ErrMessageNotFound)
+}
+
+// DeleteMessagesByRun removes every message of the run. Removing no
+// messages is not an error.
+func (s *PostgresStore) DeleteMessagesByRun(ctx context.Context, runID string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM dispatch_messages WHERE run_id = $1`, runID)
+	return err
+}
+
+// CreateZoneDensity inserts the zone-density report.
+func (s *PostgresStore) CreateZoneDensity(ctx context.Context, density ZoneDensity) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO dispatch_zone_densities (`+zoneDensityColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		density.ID, density.RunID, density.ZoneName, density.PeopleCount, density.ReportedAt,
+		density.CreatedBy, density.CreatedAt, density.UpdatedAt)
+	return err
+}
+
+// GetZoneDensity returns the report with the given id within the run, or
+// ErrZoneDensityNotFound.
+func (s *PostgresStore) GetZoneDensity(ctx context.Context, runID, id string) (ZoneDensity, error) {
+	density, err := scanZoneDensity(s.db.QueryRow(ctx, `SELECT `+zoneDensityColumns+` FROM dispatch_zone_densities
+		WHERE run_id = $1 AND id = $2`, runID, id))
+	if pgstore.IsNoRows(err) {
+		return ZoneDensity{}, ErrZoneDensityNotFound
+	}
+	return density, err
+}
+
+// ListZoneDensities returns the reports of the run matching the filter
+// newest first (reported_at DESC, id DESC; a null reported_at sorts as
+// the oldest), the total number of matches and the paginated page.
+func (s *PostgresStore) ListZoneDensities(ctx context.Context, runID string, filter ZoneDensityFilter) ([]ZoneDensity, int, error) {
+	const where = ` WHERE run_id = $1 AND ($2 = '' OR zone_name = $2)`
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM dispatch_zone_densities`+where,
+		runID, filter.ZoneName).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+zoneDensityColumns+` FROM dispatch_zone_densities`+where+`
+		ORDER BY reported_at DESC NULLS LAST, id DESC LIMIT $3 OFFSET $4`,
+		runID, filter.ZoneName, pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []ZoneDensity{}
+	for rows.Next() {
+		density, err := scanZoneDensity(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, density)
+	}
+	return page, total, rows.Err()
+}
+
+// UpdateZoneDensity replaces the report with the same run and id, or
+// returns ErrZoneDensityNotFound.
+func (s *PostgresStore) UpdateZoneDensity(ctx context.Context, density ZoneDensity) error {
+	tag, err := s.db.Exec(ctx, `UPDATE dispatch_zone_densities SET zone_name = $3, people_count = $4,
+		reported_at = $5, created_by = $6, created_at = $7, updated_at = $8 WHERE run_id = $2 AND id = $1`,
+		density.ID, density.RunID, density.ZoneName, density.PeopleCount, density.ReportedAt,
+		density.CreatedBy, density.CreatedAt, density.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrZoneDensityNotFound)
+}
+
+// DeleteZoneDensity removes the report with the given id within the run,
+// or returns ErrZoneDensityNotFound.
+func (s *PostgresStore) DeleteZoneDensity(ctx context.Context, runID, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM dispatch_zone_densities WHERE run_id = $1 AND id = $2`, runID, id)
+	return pgstore.CheckAffected(tag, err, ErrZoneDensityNotFound)
+}
+
+// DeleteZoneDensitiesByRun removes every report of the run. Removing no
+// reports is not an error.
+func (s *PostgresStore) DeleteZoneDensitiesByRun(ctx context.Context, runID string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM dispatch_zone_densities WHERE run_id = $1`, runID)
+	return err
+}
+
+// CreateDevice inserts the device status report.
+func (s *PostgresStore) CreateDevice(ctx context.Context, device Device) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO dispatch_devices (`+deviceColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		device.ID, device.RunID, device.DeviceName, device.DeviceType, device.Status, device.Note,
+		device.CreatedBy, device.CreatedAt, device.UpdatedAt)
+	return err
+}
+
+// GetDevice returns the report with the given id within the run, or
+// ErrDeviceNotFound.
+func (s *PostgresStore) GetDevice(ctx context.Context, runID, id string) (Device, error) {
+	device, err := scanDevice(s.db.QueryRow(ctx, `SELECT `+deviceColumns+` FROM dispatch_devices
+		WHERE run_id = $1 AND id = $2`, runID, id))
+	if pgstore.IsNoRows(err) {
+		return Device{}, ErrDeviceNotFound
+	}
+	return device, err
+}
+
+// ListDevices returns the reports of the run matching the filter ordered
+// by created_at ASC, id ASC, the total number of matches and the page.
+func (s *PostgresStore) ListDevices(ctx context.Context, runID string, filter DeviceFilter) ([]Device, int, error) {
+	const where = ` WHERE run_id = $1 AND ($2 = '' OR device_type = $2) AND ($3 = '' OR status = $3)`
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM dispatch_devices`+where,
+		runID, filter.DeviceType, filter.Status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+deviceColumns+` FROM dispatch_devices`+where+`
+		ORDER BY created_at, id LIMIT $4 OFFSET $5`,
+		runID, filter.DeviceType, filter.Status, pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []Device{}
+	for rows.Next() {
+		device, err := scanDevice(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, device)
+	}
+	return page, total, rows.Err()
+}
+
+// UpdateDevice replaces the report with the same run and id, or returns
+// ErrDeviceNotFound.
+func (s *PostgresStore) UpdateDevice(ctx context.Context, device Device) error {
+	tag, err := s.db.Exec(ctx, `UPDATE dispatch_devices SET device_name = $3, device_type = $4,
+		status = $5, note = $6, created_by = $7, created_at = $8, updated_at = $9 WHERE run_id = $2 AND id = $1`,
+		device.ID, device.RunID, device.DeviceName, device.DeviceType, device.Status, device.Note,
+		device.CreatedBy, device.CreatedAt, device.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrDeviceNotFound)
+}
+
+// DeleteDevice removes the report with the given id within the run, or
+// returns ErrDeviceNotFound.
+func (s *PostgresStore) DeleteDevice(ctx context.Context, runID, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM dispatch_devices WHERE run_id = $1 AND id = $2`, runID, id)
+	return pgstore.CheckAffected(tag, err, ErrDeviceNotFound)
+}
+
+// DeleteDevicesByRun removes every report of the run. Removing no
+// reports is not an error.
+func (s *PostgresStore) DeleteDevicesByRun(ctx context.Context, runID string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM dispatch_devices WHERE run_id = $1`, runID)
+	return err
+}
+
+// TransitionOrder writes the order and appends the entry to its history
+// in one transaction. The creation entry (empty From) inserts the
+// order; any other entry updates it, and a missing order rolls back
+// with ErrOrderNotFound.
+func (s *PostgresStore) TransitionOrder(ctx context.Context, order Order, transition OrderTransition) error {
+	return pgstore.InTx(ctx, s.db, func(tx pgstore.Querier) error {
+		store := &PostgresStore{db: tx}
+		var err error
+		if transition.From == "" {
+			err = store.CreateOrder(ctx, order)
+		} else {
+			err = store.UpdateOrder(ctx, order)
+		}
+		if err != nil {
+			return err
+		}
+		return store.appendOrderTransition(ctx, transition)
+	})
+}
+
+// appendOrderTransition inserts the order history entry; TransitionOrder
+// calls it in the transaction that writes the order.
+func (s *PostgresStore) appendOrderTransition(ctx context.Context, transition OrderTransition) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO dispatch_order_transitions (`+orderTransitionColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		transition.ID, transition.RunID, transition.OrderID, transition.From, transition.To,
+		transition.Actor, transition.Note, transition.CreatedAt)
+	return err
+}
+
+// ListOrderTransitions returns the history of the order within the run
+// ordered by created_at ASC, id ASC.
+func (s *PostgresStore) ListOrderTransitions(ctx context.Context, runID, orderID string) ([]OrderTransition, error) {
+	return s.queryOrderTransitions(ctx, `SELECT `+orderTransitionColumns+` FROM dispatch_order_transitions
+		WHERE run_id = $1 AND order_id = $2 ORDER BY created_at, id`, runID, orderID)
+}
+
+// ListOrderTransitionsByRun returns the history of every order of the
+// run ordered by created_at ASC, id ASC.
+func (s *PostgresStore) ListOrderTransitionsByRun(ctx context.Context, runID string) ([]OrderTransition, error) {
+	return s.queryOrderTransitions(ctx, `SELECT `+orderTransitionColumns+` FROM dispatch_order_transitions
+		WHERE run_id = $1 ORDER BY created_at, id`, runID)
+}
+
+func (s *PostgresStore) queryOrderTransitions(ctx context.Context, query string, args ...any) ([]OrderTransition, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	transitions := []OrderTransition{}
+	for rows.Next() {
+		var transition OrderTransition
+		if err := rows.Scan(&transition.ID, &transition.RunID, &transition.OrderID, &transition.From,
+			&transition.To, &transition.Actor, &transition.Note, &transition.CreatedAt); err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, transition)
+	}
+	return transitions, rows.Err()
+}
+
+// TransitionDepartment upserts the report and appends the entry to its
+// history in one transaction.
+func (s *PostgresStore) TransitionDepartment(ctx context.Context, report DepartmentReport, transition DepartmentTransition) error {
+	return pgstore.InTx(ctx, s.db, func(tx pgstore.Querier) error {
+		store := &PostgresStore{db: tx}
+		if err := store.UpsertDepartment(ctx, report); err != nil {
+			return err
+		}
+		return store.appendDepartmentTransition(ctx, transition)
+	})
+}
+
+// appendDepartmentTransition inserts the department-report history
+// entry; TransitionDepartment calls it in the transaction that writes the
+// report.
+func (s *PostgresStore) appendDepartmentTransition(ctx context.Context, transition DepartmentTransition) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO dispatch_department_transitions (`+departmentTransitionColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		transition.ID, transition.RunID, transition.ReportID, transition.Department, transition.From,
+		transition.To, transition.Actor, transition.Note, transition.CreatedAt)
+	return err
+}
+
+// ListDepartmentTransitions returns the history of the department
+// report within the run ordered by created_at ASC, id ASC.
+func (s *PostgresStore) ListDepartmentTransitions(ctx context.Context, runID string, department Department) ([]DepartmentTransition, error) {
+	return s.queryDepartmentTransitions(ctx, `SELECT `+departmentTransitionColumns+` FROM dispatch_department_transitions
+		WHERE run_id = $1 AND department = $2 ORDER BY created_at, id`, runID, department)
+}
+
+// ListDepartmentTransitionsByRun returns the history of every
+// department report of the run ordered by created_at ASC, id ASC.
+func (s *PostgresStore) ListDepartmentTransitionsByRun(ctx context.Context, runID string) ([]DepartmentTransition, error) {
+	return s.queryDepartmentTransitions(ctx, `SELECT `+departmentTransitionColumns+` FROM dispatch_department_transitions
+		WHERE run_id = $1 ORDER BY created_at, id`, runID)
+}
+
+func (s *PostgresStore) queryDepartmentTransitions(ctx context.Context, query string, args ...any) ([]DepartmentTransition, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	transitions := []DepartmentTransition{}
+	for rows.Next() {
+		var transition DepartmentTransition
+		if err := rows.Scan(&transition.ID, &transition.RunID, &transition.ReportID, &transition.Department,
+			&transition.From, &transition.To, &transition.Actor, &transition.Note, &transition.CreatedAt); err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, transition)
+	}
+	return transitions, rows.Err()
+}
+
+func scanOrder(row pgstore.Row) (Order, error) {
+	var order Order
+	err := row.Scan(&order.ID, &order.RunID, &order.Title, &order.Content, &order.Priority,
+		&order.TargetType, &order.TargetName, &order.Status, &order.Feedback, &order.Deadline,
+		&order.IssuedAt, &order.CompletedAt, &order.CreatedBy, &order.CreatedAt, &order.UpdatedAt)
+	return order, err
+}
+
+func scanDepartment(row pgstore.Row) (DepartmentReport, error) {
+	var report DepartmentReport
+	err := row.Scan(&report.ID, &report.RunID, &report.Department, &report.Status, &report.Note,
+		&report.ArrivedAt, &report.CreatedBy, &report.CreatedAt, &report.UpdatedAt)
+	return report, err
+}
+
+func scanMessage(row pgstore.Row) (Message, error) {
+	var message Message
+	err := row.Scan(&message.ID, &message.RunID, &message.SenderType, &message.SenderName,
+		&message.Content, &message.SentAt, &message.CreatedBy, &message.CreatedAt, &message.UpdatedAt)
+	return message, err
+}
+
+func scanZoneDensity(row pgstore.Row) (ZoneDensity, error) {
+	var density ZoneDensity
+	err := row.Scan(&density.ID, &density.RunID, &density.ZoneName, &density.PeopleCount,
+		&density.ReportedAt, &density.CreatedBy, &density.CreatedAt, &density.UpdatedAt)
+	return density, err
+}
+
+func scanDevice(row pgstore.Row) (Device, error) {
+	var device Device
+	err := row.Scan(&device.ID, &device.RunID, &device.DeviceName, &device.DeviceType, &device.Status,
+		&device.Note, &device.CreatedBy, &device.CreatedAt, &device.UpdatedAt)
+	return device, err
+}
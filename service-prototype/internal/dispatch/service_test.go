@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/drills"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/runstream"
 )
 
 // fixedTime is a deterministic clock for the normalize tests.
@@ -275,3 +276,77 @@ func TestStoreDeleteSessionsByRun(t *testing.T) {
 		t.Fatalf("DeleteSessionsByRun on empty: %v", err)
 	}
 }
+
+// ─── Run stream publisher ────────────────────────────────────────────
+
+// recordingPublisher is a runstream.Publisher test double recording the
+// published events as "kind.action objectID".
+type recordingPublisher struct {
+	events []string
+}
+
+func (p *recordingPublisher) Publish(runID string, kind runstream.Kind, action runstream.Action, objectID string, _ any) {
+	p.events = append(p.events, runID+" "+string(kind)+"."+string(action)+" "+objectID)
+}
+
+// 接入 publisher 后，指令/部门/消息/热力/设备的每次成功写入各发布一条变更
+// 事件（run、kind.action、对象 id）；被写入门禁拒绝的写入不发布。
+func TestServicePublishesCommittedWrites(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestService(run("run-1", drills.RunStatusInProgress), run("run-2", drills.RunStatusCompleted))
+	publisher := &recordingPublisher{}
+	service.SetPublisher(publisher)
+
+	order, err := service.CreateOrder(ctx, "run-1", OrderInput{
+		Title: "疏散东区游客", Content: "引导东区游客经 3 号出口疏散", TargetType: TargetTypeDepartment, TargetName: "场馆应急组",
+	})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	received := OrderStatusReceived
+	if _, err := service.UpdateOrder(ctx, "run-1", order.ID, OrderUpdate{Status: &received}); err != nil {
+		t.Fatalf("UpdateOrder: %v", err)
+	}
+	if err := service.DeleteOrder(ctx, "run-1", order.ID); err != nil {
+		t.Fatalf("DeleteOrder: %v", err)
+	}
+	if _, err := service.UpsertDepartment(ctx, "run-1", DepartmentFire, DepartmentReportInput{}); err != nil {
+		t.Fatalf("UpsertDepartment: %v", err)
+	}
+	message, err := service.CreateMessage(ctx, "run-1", MessageInput{SenderType: SenderTypeField, SenderName: "张三", Content: "东门已封控"})
+	if err != nil {
+		t.Fatalf("CreateMessage: %v", err)
+	}
+	count := 420
+	zone, err := service.CreateZoneDensity(ctx, "run-1", ZoneDensityInput{ZoneName: "A区", PeopleCount: &count})
+	if err != nil {
+		t.Fatalf("CreateZoneDensity: %v", err)
+	}
+	device, err := service.CreateDevice(ctx, "run-1", DeviceInput{DeviceName: "一号供配电柜", DeviceType: DeviceTypePowerSupply})
+	if err != nil {
+		t.Fatalf("CreateDevice: %v", err)
+	}
+	if _, err := service.CreateOrder(ctx, "run-2", OrderInput{
+		Title: "补发指令", Content: "已完成的演练不可写", TargetType: TargetTypePerson, TargetName: "李四",
+	}); err == nil {
+		t.Fatal("CreateOrder on a completed run: want the write gate error")
+	}
+
+	want := []string{
+		"run-1 order.created " + order.ID,
+		"run-1 order.updated " + order.ID,
+		"run-1 order.deleted " + order.ID,
+		"run-1 department.created 消防",
+		"run-1 message.created " + message.ID,
+		"run-1 zone_density.created " + zone.ID,
+		"run-1 device.created " + device.ID,
+	}
+	if len(publisher.events) != len(want) {
+		t.Fatalf("published %q, want %q", publisher.events, want)
+	}
+	for i := range want {
+		if publisher.events[i] != want[i] {
+			t.Fatalf("event %d = %q, want %q", i, publisher.events[i], want[i])
+		}
+	}
+}
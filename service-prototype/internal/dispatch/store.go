@@ -5,18 +5,23 @@ import (
 	"sync"
 )
 
-// Store persists the dispatch command sessions, the dispatch orders,
-// the dispatch department reports, the dispatch messages, the
-// dispatch zone densities and the dispatch device status reports
-// (module 3 of the command-and-dispatch training). The prototype ships
-// the in-memory implementation; the interface keeps the service layer
+// Store persists the dispatch command sessions, the dispatch orders, the
+// dispatch department reports, the dispatch messages, the dispatch zone
+// densities and the dispatch device status reports (module 3 of the
+// command-and-dispatch training). The prototype ships an in-memory and a
+// PostgreSQL implementation; the interface keeps the service layer
 // independent of the storage backend. The cascade rules of the database
 // (sessions, orders, department reports, messages, zone densities and
 // devices vanish when their run is deleted) are implemented by
 // DeleteSessionsByRun, DeleteOrdersByRun, DeleteDepartmentsByRun,
 // DeleteMessagesByRun, DeleteZoneDensitiesByRun and DeleteDevicesByRun,
 // the uniform cleanup entries the drills service calls through its
-// run-session cleaner hook.
+// run-session cleaner hook. The status history of orders and department
+// reports (OrderTransition, DepartmentTransition) is append-only and
+// vanishes with its order or report, which the delete methods of those
+// rows take care of. TransitionOrder and TransitionDepartment write a
+// status change together with its history entry, so neither is stored
+// without the other.
 type Store interface {
 	UpsertSession(ctx context.Context, session Session) error
 	GetSession(ctx context.Context, runID string) (Session, error)
@@ -33,6 +38,12 @@ type Store interface {
 	ListDepartments(ctx context.Context, runID string, filter DepartmentFilter) ([]DepartmentReport, int, error)
 	DeleteDepartment(ctx context.Context, runID string, department Department) error
 	DeleteDepartmentsByRun(ctx context.Context, runID string) error
+	TransitionOrder(ctx context.Context, order Order, transition OrderTransition) error
+	ListOrderTransitions(ctx context.Context, runID, orderID string) ([]OrderTransition, error)
+	ListOrderTransitionsByRun(ctx context.Context, runID string) ([]OrderTransition, error)
+	TransitionDepartment(ctx context.Context, report DepartmentReport, transition DepartmentTransition) error
+	ListDepartmentTransitions(ctx context.Context, runID string, department Department) ([]DepartmentTransition, error)
+	ListDepartmentTransitionsByRun(ctx context.Context, runID string) ([]DepartmentTransition, error)
 	CreateMessage(ctx context.Context, message Message) error
 	GetMessage(ctx context.Context, runID, id string) (Message, error)
 	ListMessages(ctx context.Context, runID string, filter MessageFilter) ([]Message, int, error)
@@ -53,22 +64,24 @@ type Store interface {
 }
 
 // InMemoryStore keeps the dispatch sessions, the dispatch orders, the
-// dispatch department reports, the dispatch messages, the dispatch
-// zone densities and the dispatch device status reports in
-// insertion-ordered slices guarded by a mutex. It implements Store for
-// the prototype and never touches a database; a database-backed store
-// arrives with a later slice. At most one session row exists per run
-// (the service upserts by run_id); orders are keyed by (run_id, id);
-// department reports by (run_id, department); messages, zone densities
-// and devices by (run_id, id).
+// dispatch department reports, the dispatch messages, the dispatch zone
+// densities and the dispatch device status reports in insertion-ordered
+// slices guarded by a mutex. It implements Store for the prototype and
+// never touches a database; PostgresStore is the database-backed
+// counterpart. At most one session row exists per run (the service
+// upserts by run_id); orders are keyed by (run_id, id); department
+// reports by (run_id, department); messages, zone densities and devices
+// by (run_id, id).
 type InMemoryStore struct {
-	mu            sync.Mutex
-	sessions      []Session
-	orders        []Order
-	departments   []DepartmentReport
-	messages      []Message
-	zoneDensities []ZoneDensity
-	devices       []Device
+	mu                    sync.Mutex
+	sessions              []Session
+	orders                []Order
+	departments           []DepartmentReport
+	messages              []Message
+	zoneDensities         []ZoneDensity
+	devices               []Device
+	orderTransitions      []OrderTransition
+	departmentTransitions []DepartmentTransition
 }
 
 // NewInMemoryStore returns an empty in-memory dispatch store.
@@ -142,7 +155,7 @@ func (s *InMemoryStore) indexOfSession(runID string) int {
 
 func cloneSession(session Session) Session {
 	cloned := session
-	cloned.JointVenues = append([]string(nil), session.JointVenues...)
+	cloned.JointVenues = append([]string{}, session.JointVenues...)
 	cloned.Metadata = cloneMap(session.Metadata)
 	return cloned
 }
@@ -0,0 +1,256 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/databasetest"
+)
+
+func TestInMemoryStoreContract(t *testing.T) {
+	runStoreContract(t, NewInMemoryStore(), func(string) {})
+}
+
+func TestPostgresStoreContract(t *testing.T) {
+	db := databasetest.Open(t)
+	runStoreContract(t, NewPostgresStore(db), func(runID string) { databasetest.InsertRun(t, db, runID) })
+}
+
+// runStoreContract pins the Store semantics every backend shares: the
+// session and department upserts replace in place, rows are addressed
+// within their run, the listings keep their documented orders and the
+// by-run cleanups leave other runs untouched; the status history is
+// listed oldest first and goes with its order or report. addRun creates
+// the drill run a backend with foreign keys needs.
+func runStoreContract(t *testing.T, store Store, addRun func(runID string)) {
+	ctx := context.Background()
+	base := databasetest.Time(2026, 8, 1, 9, 0, 0)
+	addRun("run-a")
+	addRun("run-b")
+
+	session := Session{ID: "ses-1", RunID: "run-a", Mode: ModeLive, MainVenue: "主馆", JointVenues: []string{}, Metadata: map[string]any{}, CreatedAt: base, UpdatedAt: base}
+	if err := store.UpsertSession(ctx, session); err != nil {
+		t.Fatalf("insert session: %v", err)
+	}
+	session.JointVenues = []string{"分馆"}
+	session.UpdatedAt = base.Add(time.Minute)
+	if err := store.UpsertSession(ctx, session); err != nil {
+		t.Fatalf("update session: %v", err)
+	}
+	gotSession, err := store.GetSession(ctx, "run-a")
+	if err != nil {
+		t.Fatalf("get session: %v", err)
+	}
+	databasetest.AssertSameJSON(t, gotSession, session)
+
+	deadline := base.Add(time.Hour)
+	orders := []Order{
+		{ID: "ord-1", RunID: "run-a", Title: "疏散", Content: "引导观众", Priority: PriorityUrgent, TargetType: TargetTypeDepartment, TargetName: "场馆应急组", Status: OrderStatusPending, Deadline: &deadline, IssuedAt: &base, CreatedAt: base, UpdatedAt: base},
+		{ID: "ord-2", RunID: "run-a", Title: "警戒", Content: "设置警戒线", Priority: PriorityNormal, TargetType: TargetTypeGroup, TargetName: "安保组", Status: OrderStatusPending, CreatedAt: base.Add(time.Minute), UpdatedAt: base.Add(time.Minute)},
+	}
+	for _, item := range orders {
+		if err := store.CreateOrder(ctx, item); err != nil {
+			t.Fatalf("create order %s: %v", item.ID, err)
+		}
+	}
+	gotOrder, err := store.GetOrder(ctx, "run-a", "ord-1")
+	if err != nil {
+		t.Fatalf("get order: %v", err)
+	}
+	databasetest.AssertSameJSON(t, gotOrder, orders[0])
+	listed, total, err := store.ListOrders(ctx, "run-a", OrderFilter{Limit: -1})
+	if err != nil || total != 2 || listed[0].ID != "ord-2" {
+		t.Fatalf("orders = %d %v %v, want newest ord-2 first", total, listed, err)
+	}
+	if _, total, _ := store.ListOrders(ctx, "run-a", OrderFilter{Priority: PriorityUrgent, Limit: -1}); total != 1 {
+		t.Fatalf("urgent orders = %d, want 1", total)
+	}
+	updated := orders[1]
+	updated.Status = OrderStatusReceived
+	if err := store.UpdateOrder(ctx, updated); err != nil {
+		t.Fatalf("update order: %v", err)
+	}
+	if _, err := store.GetOrder(ctx, "run-b", "ord-1"); !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("order of another run = %v, want ErrOrderNotFound", err)
+	}
+
+	report := DepartmentReport{ID: "dep-1", RunID: "run-a", Department: DepartmentFire, Status: DepartmentStatusNotResponded, CreatedAt: base, UpdatedAt: base}
+	if err := store.UpsertDepartment(ctx, report); err != nil {
+		t.Fatalf("insert department: %v", err)
+	}
+	report.Status = DepartmentStatusResponded
+	report.Note = "已出警"
+	if err := store.UpsertDepartment(ctx, report); err != nil {
+		t.Fatalf("update department: %v", err)
+	}
+	reports, total, err := store.ListDepartments(ctx, "run-a", DepartmentFilter{Limit: -1})
+	if err != nil || total != 1 {
+		t.Fatalf("departments = %d %v %v, want one upserted report", total, reports, err)
+	}
+	databasetest.AssertSameJSON(t, reports[0], report)
+
+	// 状态与流转记录一起写入：创建条目（From 为空）插入指令，其余条目更新指令；
+	// 指令不存在时 ErrOrderNotFound，且不留下流转记录。历史按 created_at、id
+	// 升序，随指令一起删除。
+	issued := []Order{
+		{ID: "ord-3", RunID: "run-a", Title: "增援", Content: "调派增援", Priority: PriorityNormal, TargetType: TargetTypeGroup, TargetName: "安保组", Status: OrderStatusPending, CreatedAt: base.Add(time.Minute), UpdatedAt: base.Add(time.Minute)},
+		{ID: "ord-4", RunID: "run-a", Title: "广播", Content: "播放疏散广播", Priority: PriorityNormal, TargetType: TargetTypeGroup, TargetName: "广播组", Status: OrderStatusPending, CreatedAt: base, UpdatedAt: base},
+	}
+	orderHistory := []OrderTransition{
+		{ID: "otr-2", RunID: "run-a", OrderID: "ord-3", To: OrderStatusPending, CreatedAt: base.Add(time.Minute)},
+		{ID: "otr-1", RunID: "run-a", OrderID: "ord-4", To: OrderStatusPending, CreatedAt: base},
+	}
+	for i, item := range issued {
+		if err := store.TransitionOrder(ctx, item, orderHistory[i]); err != nil {
+			t.Fatalf("issue order %s with its creation entry: %v", item.ID, err)
+		}
+	}
+	received := issued[0]
+	received.Status = OrderStatusReceived
+	received.UpdatedAt = base.Add(time.Minute)
+	receipt := OrderTransition{ID: "otr-3", RunID: "run-a", OrderID: "ord-3", From: OrderStatusPending, To: OrderStatusReceived, Actor: "安保组", Note: "收到", CreatedAt: base.Add(time.Minute)}
+	if err := store.TransitionOrder(ctx, received, receipt); err != nil {
+		t.Fatalf("transition order: %v", err)
+	}
+	if got, err := store.GetOrder(ctx, "run-a", "ord-3"); err != nil {
+		t.Fatalf("get transitioned order: %v", err)
+	} else {
+		databasetest.AssertSameJSON(t, got, received)
+	}
+	gotHistory, err := store.ListOrderTransitions(ctx, "run-a", "ord-3")
+	if err != nil || len(gotHistory) != 2 || gotHistory[0].ID != "otr-2" {
+		t.Fatalf("history of ord-3 = %v %v, want otr-2 then otr-3", gotHistory, err)
+	}
+	databasetest.AssertSameJSON(t, gotHistory[1], receipt)
+	if byRun, err := store.ListOrderTransitionsByRun(ctx, "run-a"); err != nil || len(byRun) != 3 || byRun[0].ID != "otr-1" {
+		t.Fatalf("order history of run-a = %v %v, want otr-1 first", byRun, err)
+	}
+	missing := received
+	missing.ID = "ord-missing"
+	if err := store.TransitionOrder(ctx, missing, OrderTransition{ID: "otr-4", RunID: "run-a", OrderID: "ord-missing", From: OrderStatusPending, To: OrderStatusReceived, CreatedAt: received.UpdatedAt}); !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("transition a missing order = %v, want ErrOrderNotFound", err)
+	}
+	if history, _ := store.ListOrderTransitions(ctx, "run-a", "ord-missing"); len(history) != 0 {
+		t.Fatalf("history of a missing order = %v, want none", history)
+	}
+	if err := store.DeleteOrder(ctx, "run-a", "ord-4"); err != nil {
+		t.Fatalf("delete order: %v", err)
+	}
+	if byRun, _ := store.ListOrderTransitionsByRun(ctx, "run-a"); len(byRun) != 2 {
+		t.Fatalf("order history after deleting ord-4 = %v, want its entry gone", byRun)
+	}
+
+	// 部门回报的流转同样与回报一起写入（按 department upsert）。
+	police := DepartmentReport{ID: "dep-2", RunID: "run-a", Department: DepartmentPolice, Status: DepartmentStatusNotResponded, CreatedAt: base, UpdatedAt: base}
+	departmentHistory := []DepartmentTransition{
+		{ID: "dtr-1", RunID: "run-a", ReportID: "dep-2", Department: DepartmentPolice, To: DepartmentStatusNotResponded, CreatedAt: base},
+		{ID: "dtr-2", RunID: "run-a", ReportID: "dep-2", Department: DepartmentPolice, From: DepartmentStatusNotResponded, To: DepartmentStatusResponded, Note: "已出警", CreatedAt: base.Add(time.Minute)},
+	}
+	if err := store.TransitionDepartment(ctx, police, departmentHistory[0]); err != nil {
+		t.Fatalf("report department with its creation entry: %v", err)
+	}
+	police.Status = DepartmentStatusResponded
+	police.Note = "已出警"
+	police.UpdatedAt = base.Add(time.Minute)
+	if err := store.TransitionDepartment(ctx, police, departmentHistory[1]); err != nil {
+		t.Fatalf("transition department: %v", err)
+	}
+	if got, err := store.GetDepartment(ctx, "run-a", DepartmentPolice); err != nil {
+		t.Fatalf("get transitioned department: %v", err)
+	} else {
+		databasetest.AssertSameJSON(t, got, police)
+	}
+	gotDepartmentHistory, err := store.ListDepartmentTransitions(ctx, "run-a", DepartmentPolice)
+	if err != nil || len(gotDepartmentHistory) != 2 {
+		t.Fatalf("history of 公安 = %v %v, want two entries", gotDepartmentHistory, err)
+	}
+	databasetest.AssertSameJSON(t, gotDepartmentHistory[1], departmentHistory[1])
+
+	messages := []Message{
+		{ID: "msg-1", RunID: "run-a", SenderType: SenderTypeCommand, Content: "开始", SentAt: &base, CreatedAt: base, UpdatedAt: base},
+		{ID: "msg-2", RunID: "run-a", SenderType: SenderTypeField, SenderName: "张三", Content: "收到", CreatedAt: base.Add(time.Minute), UpdatedAt: base.Add(time.Minute)},
+	}
+	for _, item := range messages {
+		if err := store.CreateMessage(ctx, item); err != nil {
+			t.Fatalf("create message %s: %v", item.ID, err)
+		}
+	}
+	chat, total, err := store.ListMessages(ctx, "run-a", MessageFilter{Limit: 1, Offset: 1})
+	if err != nil || total != 2 || len(chat) != 1 || chat[0].ID != "msg-2" {
+		t.Fatalf("second message page = %d %v %v, want msg-2", total, chat, err)
+	}
+
+	earlier, later := base, base.Add(time.Minute)
+	densities := []ZoneDensity{
+		{ID: "zone-1", RunID: "run-a", ZoneName: "东门", PeopleCount: 120, ReportedAt: &earlier, CreatedAt: base, UpdatedAt: base},
+		{ID: "zone-2", RunID: "run-a", ZoneName: "东门", PeopleCount: 80, ReportedAt: &later, CreatedAt: base, UpdatedAt: base},
+		{ID: "zone-3", RunID: "run-a", ZoneName: "西门", PeopleCount: 0, CreatedAt: base, UpdatedAt: base},
+	}
+	for _, item := range densities {
+		if err := store.CreateZoneDensity(ctx, item); err != nil {
+			t.Fatalf("create density %s: %v", item.ID, err)
+		}
+	}
+	zones, total, err := store.ListZoneDensities(ctx, "run-a", ZoneDensityFilter{Limit: -1})
+	if err != nil || total != 3 || zones[0].ID != "zone-2" || zones[2].ID != "zone-3" {
+		t.Fatalf("densities = %d %v %v, want zone-2 first and the unreported zone-3 last", total, zones, err)
+	}
+
+	device := Device{ID: "dev-1", RunID: "run-a", DeviceName: "1号配电柜", DeviceType: DeviceTypePowerSupply, Status: DeviceStatusNormal, CreatedAt: base, UpdatedAt: base}
+	if err := store.CreateDevice(ctx, device); err != nil {
+		t.Fatalf("create device: %v", err)
+	}
+	device.Status = DeviceStatusWarning
+	if err := store.UpdateDevice(ctx, device); err != nil {
+		t.Fatalf("update device: %v", err)
+	}
+	gotDevice, err := store.GetDevice(ctx, "run-a", "dev-1")
+	if err != nil {
+		t.Fatalf("get device: %v", err)
+	}
+	databasetest.AssertSameJSON(t, gotDevice, device)
+
+	if err := store.CreateMessage(ctx, Message{ID: "msg-b", RunID: "run-b", SenderType: SenderTypeCommand, Content: "保留", CreatedAt: base, UpdatedAt: base}); err != nil {
+		t.Fatalf("create message of run-b: %v", err)
+	}
+	for name, remove := range map[string]func(context.Context, string) error{
+		"sessions":    store.DeleteSessionsByRun,
+		"orders":      store.DeleteOrdersByRun,
+		"departments": store.DeleteDepartmentsByRun,
+		"messages":    store.DeleteMessagesByRun,
+		"densities":   store.DeleteZoneDensitiesByRun,
+		"devices":     store.DeleteDevicesByRun,
+	} {
+		if err := remove(ctx, "run-a"); err != nil {
+			t.Fatalf("delete %s by run: %v", name, err)
+		}
+	}
+	if _, total, _ := store.ListMessages(ctx, "run-b", MessageFilter{Limit: -1}); total != 1 {
+		t.Fatalf("messages of run-b = %d, want 1 after cleaning run-a", total)
+	}
+	if history, _ := store.ListOrderTransitionsByRun(ctx, "run-a"); len(history) != 0 {
+		t.Fatalf("order history after cleanup = %v, want none", history)
+	}
+	if history, _ := store.ListDepartmentTransitionsByRun(ctx, "run-a"); len(history) != 0 {
+		t.Fatalf("department history after cleanup = %v, want none", history)
+	}
+
+	notFound := map[string]struct {
+		err  error
+		want error
+	}{
+		"session":    {store.DeleteSession(ctx, "run-a"), ErrSessionNotFound},
+		"order":      {store.UpdateOrder(ctx, updated), ErrOrderNotFound},
+		"department": {store.DeleteDepartment(ctx, "run-a", DepartmentFire), ErrDepartmentNotFound},
+		"message":    {store.DeleteMessage(ctx, "run-a", "msg-1"), ErrMessageNotFound},
+		"density":    {store.UpdateZoneDensity(ctx, densities[0]), ErrZoneDensityNotFound},
+		"device":     {store.DeleteDevice(ctx, "run-a", "dev-1"), ErrDeviceNotFound},
+	}
+	for name, check := range notFound {
+		if !errors.Is(check.err, check.want) {
+			t.Errorf("%s after cleanup = %v, want %v", name, check.err, check.want)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/drills"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/runstream"
 )
 
 // ErrZoneDensityNotFound is returned when the run exists but the
@@ -117,6 +118,7 @@ func (s *Service) CreateZoneDensity(ctx context.Context, runID string, input Zon
 	if err := s.store.CreateZoneDensity(ctx, density); err != nil {
 		return ZoneDensity{}, err
 	}
+	s.publish(runID, runstream.KindZoneDensity, runstream.ActionCreated, density.ID, density)
 	return density, nil
 }
 
@@ -173,6 +175,7 @@ func (s *Service) UpdateZoneDensity(ctx context.Context, runID, id string, input
 	if err := s.store.UpdateZoneDensity(ctx, updated); err != nil {
 		return ZoneDensity{}, err
 	}
+	s.publish(runID, runstream.KindZoneDensity, runstream.ActionUpdated, updated.ID, updated)
 	return updated, nil
 }
 
@@ -190,7 +193,11 @@ func (s *Service) DeleteZoneDensity(ctx context.Context, runID, id string) error
 			Message: "run status " + string(run.Status) + " does not allow this operation",
 		}
 	}
-	return s.store.DeleteZoneDensity(ctx, runID, id)
+	if err := s.store.DeleteZoneDensity(ctx, runID, id); err != nil {
+		return err
+	}
+	s.publish(runID, runstream.KindZoneDensity, runstream.ActionDeleted, id, nil)
+	return nil
 }
 
 // ─── In-memory store ─────────────────────────────────────────────────
@@ -0,0 +1,524 @@
+package drills
+
+import (
+	"context"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
+)
+
+// PostgresStore persists the drill rows in the drill_* tables
+// (migrations 000009 to 000015). It implements Store with the same
+// semantics as the in-memory store: every entity answers its own
+// not-found error, the listings keep the sort orders documented on the
+// in-memory methods, step records and assessments are upserted on their
+// (run, step) and (run, point) unique keys, and sim events are always
+// addressed within their run. The database cascades run and scenario
+// deletions on its own; the DeleteXByY methods stay explicit so the
+// service cleanup hooks behave identically on both backends.
+type PostgresStore struct {
+	db pgstore.Querier
+}
+
+// NewPostgresStore returns a drill store over the given connection.
+func NewPostgresStore(db pgstore.Querier) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+const (
+	scenarioColumns   = `id, name, category, background, status, metadata, created_by, created_at, updated_at`
+	stepColumns       = `id, scenario_id, sort_order, title, description, created_by, created_at, updated_at`
+	pointColumns      = `id, scenario_id, title, description, created_by, created_at, updated_at`
+	runColumns        = `id, scenario_id, title, status, started_at, completed_at, metadata, created_by, created_at, updated_at`
+	stepRecordColumns = `id, run_id, step_id, status, action_note, performed_by, performed_at, created_by, created_at, updated_at`
+	simEventColumns   = `id, run_id, event_type, payload, status, triggered_at, handled_at, created_by, created_at, updated_at`
+	assessmentColumns = `id, run_id, point_id, score, comment, created_by, created_at, updated_at`
+)
+
+// CreateScenario inserts the scenario.
+func (s *PostgresStore) CreateScenario(ctx context.Context, scenario Scenario) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO drill_scenarios (`+scenarioColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		scenario.ID, scenario.Name, scenario.Category, scenario.Background, scenario.Status,
+		scenario.Metadata, scenario.CreatedBy, scenario.CreatedAt, scenario.UpdatedAt)
+	return err
+}
+
+// ListScenarios returns the scenarios matching the filter ordered by
+// created_at ASC, id ASC, the total number of matches and the page.
+func (s *PostgresStore) ListScenarios(ctx context.Context, filter ScenarioFilter) ([]Scenario, int, error) {
+	const where = ` WHERE ($1 = '' OR category = $1) AND ($2 = '' OR status = $2)`
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM drill_scenarios`+where,
+		filter.Category, filter.Status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+scenarioColumns+` FROM drill_scenarios`+where+`
+		ORDER BY created_at, id LIMIT $3 OFFSET $4`,
+		filter.Category, filter.Status, pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []Scenario{}
+	for rows.Next() {
+		scenario, err := scanScenario(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, scenario)
+	}
+	return page, total, rows.Err()
+}
+
+// GetScenario returns the scenario with the given id, or ErrScenarioNotFound.
+func (s *PostgresStore) GetScenario(ctx context.Context, id string) (Scenario, error) {
+	scenario, err := scanScenario(s.db.QueryRow(ctx, `SELECT `+scenarioColumns+` FROM drill_scenarios WHERE id = $1`, id))
+	if pgstore.IsNoRows(err) {
+		return Scenario{}, ErrScenarioNotFound
+	}
+	return scenario, err
+}
+
+// UpdateScenario replaces the scenario with the same id, or returns
+// ErrScenarioNotFound.
+func (s *PostgresStore) UpdateScenario(ctx context.Context, scenario Scenario) error {
+	tag, err := s.db.Exec(ctx, `UPDATE drill_scenarios SET name = $2, category = $3, background = $4,
+		status = $5, metadata = $6, created_by = $7, created_at = $8, updated_at = $9 WHERE id = $1`,
+		scenario.ID, scenario.Name, scenario.Category, scenario.Background, scenario.Status,
+		scenario.Metadata, scenario.CreatedBy, scenario.CreatedAt, scenario.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrScenarioNotFound)
+}
+
+// DeleteScenario removes the scenario with the given id, or returns
+// ErrScenarioNotFound. The database cascades the deletion to the steps
+// and assessment points; runs reference the scenario without a cascade,
+// which is why the service removes them through its cleanup hook first.
+func (s *PostgresStore) DeleteScenario(ctx context.Context, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM drill_scenarios WHERE id = $1`, id)
+	return pgstore.CheckAffected(tag, err, ErrScenarioNotFound)
+}
+
+// CreateStep inserts the step.
+func (s *PostgresStore) CreateStep(ctx context.Context, step ScenarioStep) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO drill_scenario_steps (`+stepColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		step.ID, step.ScenarioID, step.SortOrder, step.Title, step.Description,
+		step.CreatedBy, step.CreatedAt, step.UpdatedAt)
+	return err
+}
+
+// ListStepsByScenario returns the steps of the scenario ordered by
+// sort_order ASC, created_at ASC.
+func (s *PostgresStore) ListStepsByScenario(ctx context.Context, scenarioID string) ([]ScenarioStep, error) {
+	rows, err := s.db.Query(ctx, `SELECT `+stepColumns+` FROM drill_scenario_steps
+		WHERE scenario_id = $1 ORDER BY sort_order, created_at, id`, scenarioID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	steps := []ScenarioStep{}
+	for rows.Next() {
+		step, err := scanStep(rows)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	return steps, rows.Err()
+}
+
+// GetStep returns the step with the given id, or ErrStepNotFound.
+func (s *PostgresStore) GetStep(ctx context.Context, id string) (ScenarioStep, error) {
+	step, err := scanStep(s.db.QueryRow(ctx, `SELECT `+stepColumns+` FROM drill_scenario_steps WHERE id = $1`, id))
+	if pgstore.IsNoRows(err) {
+		return ScenarioStep{}, ErrStepNotFound
+	}
+	return step, err
+}
+
+// UpdateStep replaces the step with the same id, or returns
+// ErrStepNotFound.
+func (s *PostgresStore) UpdateStep(ctx context.Context, step ScenarioStep) error {
+	tag, err := s.db.Exec(ctx, `UPDATE drill_scenario_steps SET scenario_id = $2, sort_order = $3,
+		title = $4, description = $5, created_by = $6, created_at = $7, updated_at = $8 WHERE id = $1`,
+		step.ID, step.ScenarioID, step.SortOrder, step.Title, step.Description,
+		step.CreatedBy, step.CreatedAt, step.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrStepNotFound)
+}
+
+// DeleteStep removes the step with the given id, or returns
+// ErrStepNotFound.
+func (s *PostgresStore) DeleteStep(ctx context.Context, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM drill_scenario_steps WHERE id = $1`, id)
+	return pgstore.CheckAffected(tag, err, ErrStepNotFound)
+}
+
+// DeleteStepsByScenario removes every step of the scenario. Removing no
+// steps is not an error.
+func (s *PostgresStore) DeleteStepsByScenario(ctx context.Context, scenarioID string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM drill_scenario_steps WHERE scenario_id = $1`, scenarioID)
+	return err
+}
+
+// CreatePoint inserts the assessment point.
+func (s *PostgresStore) CreatePoint(ctx context.Context, point AssessmentPoint) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO drill_assessment_points (`+pointColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		point.ID, point.ScenarioID, point.Title, point.Description,
+		point.CreatedBy, point.CreatedAt, point.UpdatedAt)
+	return err
+}
+
+// ListPointsByScenario returns the points of the scenario ordered by
+// created_at ASC.
+func (s *PostgresStore) ListPointsByScenario(ctx context.Context, scenarioID string) ([]AssessmentPoint, error) {
+	rows, err := s.db.Query(ctx, `SELECT `+pointColumns+` FROM drill_assessment_points
+		WHERE scenario_id = $1 ORDER BY created_at, id`, scenarioID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	points := []AssessmentPoint{}
+	for rows.Next() {
+		point, err := scanPoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+// GetPoint returns the point with the given id, or ErrPointNotFound.
+func (s *PostgresStore) GetPoint(ctx context.Context, id string) (AssessmentPoint, error) {
+	point, err := scanPoint(s.db.QueryRow(ctx, `SELECT `+pointColumns+` FROM drill_assessment_points WHERE id = $1`, id))
+	if pgstore.IsNoRows(err) {
+		return AssessmentPoint{}, ErrPointNotFound
+	}
+	return point, err
+}
+
+// UpdatePoint replaces the point with the same id, or returns
+// ErrPointNotFound.
+func (s *PostgresStore) UpdatePoint(ctx context.Context, point AssessmentPoint) error {
+	tag, err := s.db.Exec(ctx, `UPDATE drill_assessment_points SET scenario_id = $2, title = $3,
+		description = $4, created_by = $5, created_at = $6, updated_at = $7 WHERE id = $1`,
+		point.ID, point.ScenarioID, point.Title, point.Description,
+		point.CreatedBy, point.CreatedAt, point.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrPointNotFound)
+}
+
+// DeletePoint removes the point with the given id, or returns
+// ErrPointNotFound.
+func (s *PostgresStore) DeletePoint(ctx context.Context, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM drill_assessment_points WHERE id = $1`, id)
+	return pgstore.CheckAffected(tag, err, ErrPointNotFound)
+}
+
+// DeletePointsByScenario removes every point of the scenario. Removing
+// no points is not an error.
+func (s *PostgresStore) DeletePointsByScenario(ctx context.Context, scenarioID string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM drill_assessment_points WHERE scenario_id = $1`, scenarioID)
+	return err
+}
+
+// CreateRun inserts the run.
+func (s *PostgresStore) CreateRun(ctx context.Context, run Run) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO drill_runs (`+runColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		run.ID, run.ScenarioID, run.Title, run.Status, run.StartedAt, run.CompletedAt,
+		run.Metadata, run.CreatedBy, run.CreatedAt, run.UpdatedAt)
+	return err
+}
+
+// ListRuns returns the runs matching the filter ordered by created_at
+// DESC, the total number of matches and the paginated page.
+func (s *PostgresStore) ListRuns(ctx context.Context, filter RunFilter) ([]Run, int, error) {
+	const where = ` WHERE ($1 = '' OR status = $1) AND ($2 = '' OR scenario_id = $2)`
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM drill_runs`+where,
+		filter.Status, filter.ScenarioID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+runColumns+` FROM drill_runs`+where+`
+		ORDER BY created_at DESC, id DESC LIMIT $3 OFFSET $4`,
+		filter.Status, filter.ScenarioID, pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []Run{}
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, run)
+	}
+	return page, total, rows.Err()
+}
+
+// GetRun returns the run with the given id, or ErrRunNotFound.
+func (s *PostgresStore) GetRun(ctx context.Context, id string) (Run, error) {
+	run, err := scanRun(s.db.QueryRow(ctx, `SELECT `+runColumns+` FROM drill_runs WHERE id = $1`, id))
+	if pgstore.IsNoRows(err) {
+		return Run{}, ErrRunNotFound
+	}
+	return run, err
+}
+
+// UpdateRun replaces the run with the same id, or returns ErrRunNotFound.
+func (s *PostgresStore) UpdateRun(ctx context.Context, run Run) error {
+	tag, err := s.db.Exec(ctx, `UPDATE drill_runs SET scenario_id = $2, title = $3, status = $4,
+		started_at = $5, completed_at = $6, metadata = $7, created_by = $8, created_at = $9,
+		updated_at = $10 WHERE id = $1`,
+		run.ID, run.ScenarioID, run.Title, run.Status, run.StartedAt, run.CompletedAt,
+		run.Metadata, run.CreatedBy, run.CreatedAt, run.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrRunNotFound)
+}
+
+// DeleteRun removes the run with the given id, or returns
+// ErrRunNotFound. The database cascades the deletion to the step
+// records, sim events and assessments of the run.
+func (s *PostgresStore) DeleteRun(ctx context.Context, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM drill_runs WHERE id = $1`, id)
+	return pgstore.CheckAffected(tag, err, ErrRunNotFound)
+}
+
+// UpsertStepRecord inserts the record, or replaces the record of the same
+// (run, step) pair when one exists.
+func (s *PostgresStore) UpsertStepRecord(ctx context.Context, record StepRecord) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO drill_step_records (`+stepRecordColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (run_id, step_id) DO UPDATE SET id = EXCLUDED.id, status = EXCLUDED.status,
+			action_note = EXCLUDED.action_note, performed_by = EXCLUDED.performed_by,
+			performed_at = EXCLUDED.performed_at, created_by = EXCLUDED.created_by,
+			created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at`,
+		record.ID, record.RunID, record.StepID, record.Status, record.ActionNote,
+		record.PerformedBy, record.PerformedAt, record.CreatedBy, record.CreatedAt, record.UpdatedAt)
+	return err
+}
+
+// GetStepRecord returns the record of the (run, step) pair, or
+// ErrStepRecordNotFound.
+func (s *PostgresStore) GetStepRecord(ctx context.Context, runID, stepID string) (StepRecord, error) {
+	record, err := scanStepRecord(s.db.QueryRow(ctx, `SELECT `+stepRecordColumns+` FROM drill_step_records
+		WHERE run_id = $1 AND step_id = $2`, runID, stepID))
+	if pgstore.IsNoRows(err) {
+		return StepRecord{}, ErrStepRecordNotFound
+	}
+	return record, err
+}
+
+// ListStepRecordsByRun returns the records of the run ordered by
+// created_at ASC, id ASC.
+func (s *PostgresStore) ListStepRecordsByRun(ctx context.Context, runID string) ([]StepRecord, error) {
+	rows, err := s.db.Query(ctx, `SELECT `+stepRecordColumns+` FROM drill_step_records
+		WHERE run_id = $1 ORDER BY created_at, id`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	records := []StepRecord{}
+	for rows.Next() {
+		record, err := scanStepRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// DeleteStepRecord removes the record of the (run, step) pair, or
+// returns ErrStepRecordNotFound.
+func (s *PostgresStore) DeleteStepRecord(ctx context.Context, runID, stepID string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM drill_step_records WHERE run_id = $1 AND step_id = $2`, runID, stepID)
+	return pgstore.CheckAffected(tag, err, ErrStepRecordNotFound)
+}
+
+// DeleteStepRecordsByRun removes every record of the run. Removing no
+// records is not an error.
+func (s *PostgresStore) DeleteStepRecordsByRun(ctx context.Context, runID string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM drill_step_records WHERE run_id = $1`, runID)
+	return err
+}
+
+// CreateSimEvent inserts the event.
+func (s *PostgresStore) CreateSimEvent(ctx context.Context, event SimEvent) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO drill_sim_events (`+simEventColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		event.ID, event.RunID, event.EventType, event.Payload, event.Status, event.TriggeredAt,
+		event.HandledAt, event.CreatedBy, event.CreatedAt, event.UpdatedAt)
+	return err
+}
+
+// ListSimEvents returns the events of the run matching the filter ordered
+// by created_at ASC, the total number of matches and the paginated page.
+func (s *PostgresStore) ListSimEvents(ctx context.Context, runID string, filter SimEventFilter) ([]SimEvent, int, error) {
+	const where = ` WHERE run_id = $1 AND ($2 = '' OR event_type = $2) AND ($3 = '' OR status = $3)`
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM drill_sim_events`+where,
+		runID, filter.EventType, filter.Status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+simEventColumns+` FROM drill_sim_events`+where+`
+		ORDER BY created_at, id LIMIT $4 OFFSET $5`,
+		runID, filter.EventType, filter.Status, pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []SimEvent{}
+	for rows.Next() {
+		event, err := scanSimEvent(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, event)
+	}
+	return page, total, rows.Err()
+}
+
+// GetSimEvent returns the event with the given id within the run, or
+// ErrSimEventNotFound (an event of another run is not found as well).
+func (s *PostgresStore) GetSimEvent(ctx context.Context, runID, id string) (SimEvent, error) {
+	event, err := scanSimEvent(s.db.QueryRow(ctx, `SELECT `+simEventColumns+` FROM drill_sim_events
+		WHERE run_id = $1 AND id = $2`, runID, id))
+	if pgstore.IsNoRows(err) {
+		return SimEvent{}, ErrSimEventNotFound
+	}
+	return event, err
+}
+
+// UpdateSimEvent replaces the event with the same run and id, or returns
+// ErrSimEventNotFound.
+func (s *PostgresStore) UpdateSimEvent(ctx context.Context, event SimEvent) error {
+	tag, err := s.db.Exec(ctx, `UPDATE drill_sim_events SET event_type = $3, payload = $4, status = $5,
+		triggered_at = $6, handled_at = $7, created_by = $8, created_at = $9, updated_at = $10
+		WHERE run_id = $2 AND id = $1`,
+		event.ID, event.RunID, event.EventType, event.Payload, event.Status, event.TriggeredAt,
+		event.HandledAt, event.CreatedBy, event.CreatedAt, event.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrSimEventNotFound)
+}
+
+// DeleteSimEvent removes the event with the given id within the run, or
+// returns ErrSimEventNotFound.
+func (s *PostgresStore) DeleteSimEvent(ctx context.Context, runID, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM drill_sim_events WHERE run_id = $1 AND id = $2`, runID, id)
+	return pgstore.CheckAffected(tag, err, ErrSimEventNotFound)
+}
+
+// DeleteSimEventsByRun removes every event of the run. Removing no
+// events is not an error.
+func (s *PostgresStore) DeleteSimEventsByRun(ctx context.Context, runID string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM drill_sim_events WHERE run_id = $1`, runID)
+	return err
+}
+
+// UpsertAssessment inserts the assessment, or replaces the assessment of
+// the same (run, point) pair when one exists.
+func (s *PostgresStore) UpsertAssessment(ctx context.Context, assessment Assessment) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO drill_assessments (`+assessmentColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (run_id, point_id) DO UPDATE SET id = EXCLUDED.id, score = EXCLUDED.score,
+			comment = EXCLUDED.comment, created_by = EXCLUDED.created_by,
+			created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at`,
+		assessment.ID, assessment.RunID, assessment.PointID, assessment.Score, assessment.Comment,
+		assessment.CreatedBy, assessment.CreatedAt, assessment.UpdatedAt)
+	return err
+}
+
+// GetAssessment returns the assessment of the (run, point) pair, or
+// ErrAssessmentNotFound.
+func (s *PostgresStore) GetAssessment(ctx context.Context, runID, pointID string) (Assessment, error) {
+	assessment, err := scanAssessment(s.db.QueryRow(ctx, `SELECT `+assessmentColumns+` FROM drill_assessments
+		WHERE run_id = $1 AND point_id = $2`, runID, pointID))
+	if pgstore.IsNoRows(err) {
+		return Assessment{}, ErrAssessmentNotFound
+	}
+	return assessment, err
+}
+
+// ListAssessmentsByRun returns the assessments of the run ordered by
+// created_at ASC.
+func (s *PostgresStore) ListAssessmentsByRun(ctx context.Context, runID string) ([]Assessment, error) {
+	rows, err := s.db.Query(ctx, `SELECT `+assessmentColumns+` FROM drill_assessments
+		WHERE run_id = $1 ORDER BY created_at, id`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	assessments := []Assessment{}
+	for rows.Next() {
+		assessment, err := scanAssessment(rows)
+		if err != nil {
+			return nil, err
+		}
+		assessments = append(assessments, assessment)
+	}
+	return assessments, rows.Err()
+}
+
+// DeleteAssessment removes the assessment of the (run, point) pair, or
+// returns ErrAssessmentNotFound.
+func (s *PostgresStore) DeleteAssessment(ctx context.Context, runID, pointID string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM drill_assessments WHERE run_id = $1 AND point_id = $2`, runID, pointID)
+	return pgstore.CheckAffected(tag, err, ErrAssessmentNotFound)
+}
+
+// DeleteAssessmentsByRun removes every assessment of the run. Removing
+// no assessments is not an error.
+func (s *PostgresStore) DeleteAssessmentsByRun(ctx context.Context, runID string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM drill_assessments WHERE run_id = $1`, runID)
+	return err
+}
+
+func scanScenario(row pgstore.Row) (Scenario, error) {
+	var scenario Scenario
+	err := row.Scan(&scenario.ID, &scenario.Name, &scenario.Category, &scenario.Background,
+		&scenario.Status, &scenario.Metadata, &scenario.CreatedBy, &scenario.CreatedAt, &scenario.UpdatedAt)
+	return scenario, err
+}
+
+func scanStep(row pgstore.Row) (ScenarioStep, error) {
+	var step ScenarioStep
+	err := row.Scan(&step.ID, &step.ScenarioID, &step.SortOrder, &step.Title, &step.Description,
+		&step.CreatedBy, &step.CreatedAt, &step.UpdatedAt)
+	return step, err
+}
+
+func scanPoint(row pgstore.Row) (AssessmentPoint, error) {
+	var point AssessmentPoint
+	err := row.Scan(&point.ID, &point.ScenarioID, &point.Title, &point.Description,
+		&point.CreatedBy, &point.CreatedAt, &point.UpdatedAt)
+	return point, err
+}
+
+func scanRun(row pgstore.Row) (Run, error) {
+	var run Run
+	err := row.Scan(&run.ID, &run.ScenarioID, &run.Title, &run.Status, &run.StartedAt, &run.CompletedAt,
+		&run.Metadata, &run.CreatedBy, &run.CreatedAt, &run.UpdatedAt)
+	return run, err
+}
+
+func scanStepRecord(row pgstore.Row) (StepRecord, error) {
+	var record StepRecord
+	err := row.Scan(&record.ID, &record.RunID, &record.StepID, &record.Status, &record.ActionNote,
+		&record.PerformedBy, &record.PerformedAt, &record.CreatedBy, &record.CreatedAt, &record.UpdatedAt)
+	return record, err
+}
+
+func scanSimEvent(row pgstore.Row) (SimEvent, error) {
+	var event SimEvent
+	err := row.Scan(&event.ID, &event.RunID, &event.EventType, &event.Payload, &event.Status,
+		&event.TriggeredAt, &event.HandledAt, &event.CreatedBy, &event.CreatedAt, &event.UpdatedAt)
+	return event, err
+}
+
+func scanAssessment(row pgstore.Row) (Assessment, error) {
+	var assessment Assessment
+	err := row.Scan(&assessment.ID, &assessment.RunID, &assessment.PointID, &assessment.Score,
+		&assessment.Comment, &assessment.CreatedBy, &assessment.CreatedAt, &assessment.UpdatedAt)
+	return assessment, err
+}
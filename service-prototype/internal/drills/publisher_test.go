@@ -0,0 +1,60 @@
+package drills
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/runstream"
+)
+
+// recordingPublisher is the test fixture behind the runstream.Publisher
+// interface: it records the published events as "kind.action objectID"
+// (the broker is wired at the composition root; the fixture stands in
+// for it at the service level).
+type recordingPublisher struct{ events []string }
+
+func (p *recordingPublisher) Publish(_ string, kind runstream.Kind, action runstream.Action, objectID string, _ any) {
+	p.events = append(p.events, string(kind)+"."+string(action)+" "+objectID)
+}
+
+// 接线 publisher 后，run 的状态迁移、模拟事件的增改删与 run 的删除各发布
+// 一条变更事件；被拒绝的写入（非法迁移）不发布；未接线时写入行为不变。
+func TestServicePublishesRunChanges(t *testing.T) {
+	ctx := context.Background()
+	service := NewService(NewInMemoryStore())
+	scenario := mustCreateScenario(t, service, simEventScenarioInput("大客流疏散演练", CategoryPassengerFlow))
+	run := mustCreateRun(t, service, scenario.ID, runInput)
+
+	publisher := &recordingPublisher{}
+	service.SetPublisher(publisher)
+	mustStartRun(t, service, run.ID)
+	if _, err := service.StartRun(ctx, run.ID); err == nil {
+		t.Fatal("second StartRun: want the illegal transition error")
+	}
+	event := mustCreateSimEvent(t, service, run.ID, SimEventInput{EventType: SimEventFlowOverflow})
+	if _, err := service.UpdateSimEvent(ctx, run.ID, event.ID, SimEventUpdate{Status: SimEventHandled}); err != nil {
+		t.Fatalf("UpdateSimEvent: %v", err)
+	}
+	if err := service.DeleteSimEvent(ctx, run.ID, event.ID); err != nil {
+		t.Fatalf("DeleteSimEvent: %v", err)
+	}
+	if err := service.DeleteRun(ctx, run.ID); err != nil {
+		t.Fatalf("DeleteRun: %v", err)
+	}
+
+	want := []string{
+		"run.updated " + run.ID,
+		"sim_event.created " + event.ID,
+		"sim_event.updated " + event.ID,
+		"sim_event.deleted " + event.ID,
+		"run.deleted " + run.ID,
+	}
+	if len(publisher.events) != len(want) {
+		t.Fatalf("published %q, want %q", publisher.events, want)
+	}
+	for i := range want {
+		if publisher.events[i] != want[i] {
+			t.Fatalf("event %d = %q, want %q", i, publisher.events[i], want[i])
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/runstream"
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/ulid"
 )
 
@@ -20,6 +21,8 @@ type Service struct {
 	evaluationScores  EvaluationScoreCleaner  // nil until wired: cascade deletion of evaluation scores
 	evaluationReports EvaluationReportCleaner // nil until wired: cascade deletion of evaluation reports
 	opinionChildren   RunOpinionCleaner       // nil until wired: cascade deletion of opinion objects
+	publisher         runstream.Publisher     // nil until wired: change events of the run stream
+	timeline          RunTimeline             // nil until wired: the scripted timeline of the runs
 	now               func() time.Time
 	newID             func() string
 }
@@ -150,6 +153,50 @@ func (s *Service) SetOpinionCleaner(cleaner RunOpinionCleaner) {
 	s.opinionChildren = cleaner
 }
 
+// SetPublisher wires the run stream: from then on every committed
+// create, update and delete of a sim event or a step record, and every
+// update, status transition and deletion of the run itself, is published
+// as a change event of the run. Calling it is optional; without a publisher the
+// writes behave exactly as before.
+func (s *Service) SetPublisher(publisher runstream.Publisher) {
+	s.publisher = publisher
+}
+
+// RunTimeline schedules the scripted timeline (演练时间线) of the runs:
+// it arms the injects of a run when the run moves to 进行中 and cancels
+// the pending ones when it moves to 已完成 or 已终止. The start is split
+// around the commit of the run status: PrepareRunStart stores the
+// injects before it and the arm function it returns only schedules them
+// in memory after it, so a stored 进行中 run never misses its timeline
+// and a failed start leaves no armed inject behind. It also owns the
+// timeline rows, so the deletion of a scenario or a run cascades to its
+// injects through it (the database carries ON DELETE CASCADE; the
+// in-memory timeline store implements the same rule here). The
+// production implementation is TimelineService; wired at the
+// composition root, never by the routing layer.
+type RunTimeline interface {
+	PrepareRunStart(ctx context.Context, run Run) (arm func(), err error)
+	RunEnded(ctx context.Context, run Run)
+	DeleteRunInjectsByRun(ctx context.Context, runID string) error
+	DeleteInjectsByScenario(ctx context.Context, scenarioID string) error
+}
+
+// SetTimeline wires the scripted timeline: from then on StartRun arms
+// the injects of the run, CompleteRun and TerminateRun cancel the
+// pending ones, and DeleteRun and DeleteScenario remove the injects
+// together with their owner. Calling it is optional; without a timeline
+// the state machine and the deletions behave exactly as before.
+func (s *Service) SetTimeline(timeline RunTimeline) {
+	s.timeline = timeline
+}
+
+// publish hands a committed change to the wired publisher, if any.
+func (s *Service) publish(runID string, kind runstream.Kind, action runstream.Action, objectID string, data any) {
+	if s.publisher != nil {
+		s.publisher.Publish(runID, kind, action, objectID, data)
+	}
+}
+
 // ─── Scenarios ───────────────────────────────────────────────────────
 
 // CreateScenario validates the input, assigns a server-generated id and
@@ -200,7 +247,8 @@ func (s *Service) UpdateScenario(ctx context.Context, id string, input ScenarioI
 // DeleteScenario removes the scenario with the given id, or returns
 // ErrScenarioNotFound. When a scenario child cleaner is wired, the steps
 // and the assessment points of the scenario are removed together with
-// the scenario (cascade delete). The children are removed first so a
+// the scenario (cascade delete), and so is its timeline when a timeline
+// is wired. The children are removed first so a
 // failing cleanup cannot leave the scenario deleted while its steps and
 // points survive.
 func (s *Service) DeleteScenario(ctx context.Context, id string) error {
@@ -217,6 +265,11 @@ func (s *Service) DeleteScenario(ctx context.Context, id string) error {
 			return err
 		}
 	}
+	if s.timeline != nil {
+		if err := s.timeline.DeleteInjectsByScenario(ctx, id); err != nil {
+			return err
+		}
+	}
 	return s.store.DeleteScenario(ctx, id)
 }
 
@@ -402,6 +455,7 @@ func (s *Service) UpdateRun(ctx context.Context, id string, input RunInput) (Run
 	if err := s.store.UpdateRun(ctx, updated); err != nil {
 		return Run{}, err
 	}
+	s.publish(id, runstream.KindRun, runstream.ActionUpdated, id, updated)
 	return updated, nil
 }
 
@@ -416,7 +470,8 @@ func (s *Service) UpdateRun(ctx context.Context, id string, input RunInput) (Run
 // when an evaluation-report cleaner is wired, the evaluation report of
 // the run is removed too; when an opinion cleaner is wired, the
 // opinion objects of the run (the opinion event configuration) are
-// removed as well. The children are removed first so a failing cleanup
+// removed as well; when a timeline is wired, the timers of the run are
+// stopped and its scheduled injects removed. The children are removed first so a failing cleanup
 // cannot leave the run deleted while its children survive.
 func (s *Service) DeleteRun(ctx context.Context, id string) error {
 	// Verify the run exists first: a missing run must still answer
@@ -470,7 +525,16 @@ func (s *Service) DeleteRun(ctx context.Context, id string) error {
 			return err
 		}
 	}
-	return s.store.DeleteRun(ctx, id)
+	if s.timeline != nil {
+		if err := s.timeline.DeleteRunInjectsByRun(ctx, id); err != nil {
+			return err
+		}
+	}
+	if err := s.store.DeleteRun(ctx, id); err != nil {
+		return err
+	}
+	s.publish(id, runstream.KindRun, runstream.ActionDeleted, id, nil)
+	return nil
 }
 
 // StartRun moves the run from 未开始 to 进行中 and records started_at.
@@ -494,7 +558,10 @@ func (s *Service) TerminateRun(ctx context.Context, id string) (Run, error) {
 // transition applies one step of the run state machine
 // (未开始 -> 进行中 -> 已完成/已终止) and updates the server-managed
 // timestamps. A transition that does not match the machine is a
-// ValidationError (400); a missing run is ErrRunNotFound (404).
+// ValidationError (400); a missing run is ErrRunNotFound (404). When a
+// timeline is wired, the start stores the injects of the run before the
+// new status and arms them once it is stored; the end cancels the
+// pending ones after the new status is stored.
 func (s *Service) transition(ctx context.Context, id string, target RunStatus) (Run, error) {
 	run, err := s.store.GetRun(ctx, id)
 	if err != nil {
@@ -517,9 +584,31 @@ func (s *Service) transition(ctx context.Context, id string, target RunStatus) (
 		}
 	}
 	run.UpdatedAt = s.now()
+	arm := func() {}
+	if s.timeline != nil && run.Status == RunStatusInProgress {
+		if arm, err = s.timeline.PrepareRunStart(ctx, run); err != nil {
+			return Run{}, err
+		}
+	}
 	if err := s.store.UpdateRun(ctx, run); err != nil {
+		if s.timeline != nil && run.Status == RunStatusInProgress {
+			// A leftover pending inject of a run that is not 进行中 is
+			// cancelled by the next Resume; the failed cleanup is still
+			// reported along with the failed start.
+			if cleanupErr := s.timeline.DeleteRunInjectsByRun(ctx, run.ID); cleanupErr != nil {
+				err = errors.Join(err, fmt.Errorf("remove injects of run %s: %w", run.ID, cleanupErr))
+			}
+		}
 		return Run{}, err
 	}
+	s.publish(run.ID, runstream.KindRun, runstream.ActionUpdated, run.ID, run)
+	arm()
+	if s.timeline != nil && run.Status != RunStatusInProgress {
+		// The run has ended whatever happens here; the timeline logs a
+		// failed cancellation rather than report it to a caller that
+		// could only retry an illegal transition.
+		s.timeline.RunEnded(ctx, run)
+	}
 	return run, nil
 }
 
@@ -555,6 +644,7 @@ func (s *Service) UpsertStepRecord(ctx context.Context, runID, stepID string, in
 		if err := s.store.UpsertStepRecord(ctx, record); err != nil {
 			return StepRecord{}, err
 		}
+		s.publish(runID, runstream.KindStepRecord, runstream.ActionCreated, stepID, record)
 		return record, nil
 	}
 	record, err := normalizeStepRecord(runID, stepID, input, now, existing.ID)
@@ -565,6 +655,7 @@ func (s *Service) UpsertStepRecord(ctx context.Context, runID, stepID string, in
 	if err := s.store.UpsertStepRecord(ctx, record); err != nil {
 		return StepRecord{}, err
 	}
+	s.publish(runID, runstream.KindStepRecord, runstream.ActionUpdated, stepID, record)
 	return record, nil
 }
 
@@ -599,7 +690,11 @@ func (s *Service) DeleteStepRecord(ctx context.Context, runID, stepID string) er
 	if _, err := s.requireWritableRun(ctx, runID, []RunStatus{RunStatusInProgress}); err != nil {
 		return err
 	}
-	return s.store.DeleteStepRecord(ctx, runID, stepID)
+	if err := s.store.DeleteStepRecord(ctx, runID, stepID); err != nil {
+		return err
+	}
+	s.publish(runID, runstream.KindStepRecord, runstream.ActionDeleted, stepID, nil)
+	return nil
 }
 
 // ─── Sim events ──────────────────────────────────────────────────────
@@ -610,6 +705,13 @@ func (s *Service) DeleteStepRecord(ctx context.Context, runID, stepID string) er
 // of the run (or be 其他), otherwise 400. triggered_at is set by the
 // service; a status of 已处置 at creation also records handled_at.
 func (s *Service) CreateSimEvent(ctx context.Context, runID string, input SimEventInput) (SimEvent, error) {
+	return s.createSimEvent(ctx, runID, s.newID(), input)
+}
+
+// createSimEvent is CreateSimEvent with the id chosen by the caller: the
+// timeline reserves the id of the event an inject raises before raising
+// it, so a retried firing finds the event instead of raising another.
+func (s *Service) createSimEvent(ctx context.Context, runID, id string, input SimEventInput) (SimEvent, error) {
 	run, err := s.requireWritableRun(ctx, runID, []RunStatus{RunStatusInProgress})
 	if err != nil {
 		return SimEvent{}, err
@@ -620,7 +722,7 @@ func (s *Service) CreateSimEvent(ctx context.Context, runID string, input SimEve
 		}
 	}
 	now := s.now()
-	event, err := normalizeSimEvent(runID, input, now, s.newID())
+	event, err := normalizeSimEvent(runID, input, now, id)
 	if err != nil {
 		return SimEvent{}, err
 	}
@@ -630,6 +732,7 @@ func (s *Service) CreateSimEvent(ctx context.Context, runID string, input SimEve
 	if err := s.store.CreateSimEvent(ctx, event); err != nil {
 		return SimEvent{}, err
 	}
+	s.publish(runID, runstream.KindSimEvent, runstream.ActionCreated, event.ID, event)
 	return event, nil
 }
 
@@ -696,6 +799,7 @@ func (s *Service) UpdateSimEvent(ctx context.Context, runID, id string, update S
 	if err := s.store.UpdateSimEvent(ctx, event); err != nil {
 		return SimEvent{}, err
 	}
+	s.publish(runID, runstream.KindSimEvent, runstream.ActionUpdated, event.ID, event)
 	return event, nil
 }
 
@@ -705,7 +809,11 @@ func (s *Service) DeleteSimEvent(ctx context.Context, runID, id string) error {
 	if _, err := s.requireWritableRun(ctx, runID, []RunStatus{RunStatusInProgress}); err != nil {
 		return err
 	}
-	return s.store.DeleteSimEvent(ctx, runID, id)
+	if err := s.store.DeleteSimEvent(ctx, runID, id); err != nil {
+		return err
+	}
+	s.publish(runID, runstream.KindSimEvent, runstream.ActionDeleted, id, nil)
+	return nil
 }
 
 // ─── Assessments ─────────────────────────────────────────────────────
@@ -6,12 +6,13 @@ import (
 	"sync"
 )
 
-// Store persists the drill objects. The prototype ships the in-memory
-// implementation; the interface keeps the service layer independent of
-// the storage backend. The cascade rules of the database (steps and
-// assessment points on scenario deletion, step records / sim events /
-// assessments on run deletion) are implemented at the service layer
-// through the cleaner hooks; the store itself only manages the rows.
+// Store persists the drill objects. The prototype ships an in-memory and
+// a PostgreSQL implementation; the interface keeps the service layer
+// independent of the storage backend. The cascade rules of the database
+// (steps and assessment points on scenario deletion, step records / sim
+// events / assessments on run deletion) are implemented at the service
+// layer through the cleaner hooks; the store itself only manages the
+// rows.
 type Store interface {
 	// Scenarios
 	CreateScenario(ctx context.Context, scenario Scenario) error
@@ -62,7 +63,7 @@ type Store interface {
 
 // InMemoryStore keeps the drill rows in insertion-ordered slices guarded
 // by a mutex. It implements Store for the prototype and never touches a
-// database; a database-backed store arrives with a later slice. The
+// database; PostgresStore is the database-backed counterpart. The
 // listing methods return the rows in the repository sort order (see the
 // individual list comments) and the paginated page.
 type InMemoryStore struct {
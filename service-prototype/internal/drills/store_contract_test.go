@@ -0,0 +1,331 @@
+package drills
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/databasetest"
+)
+
+func TestInMemoryStoreContract(t *testing.T) {
+	runStoreContract(t, NewInMemoryStore())
+}
+
+func TestPostgresStoreContract(t *testing.T) {
+	runStoreContract(t, NewPostgresStore(databasetest.Open(t)))
+}
+
+// runStoreContract pins the Store semantics every backend shares. The
+// PostgreSQL schema carries the seeded scenarios of migration 000016, so
+// scenario counts are taken relative to the rows present before the
+// contract starts, and the contract rows are dated before any seed row.
+func runStoreContract(t *testing.T, store Store) {
+	ctx := context.Background()
+	base := databasetest.Time(2020, 1, 1, 9, 0, 0)
+	_, baseline, err := store.ListScenarios(ctx, ScenarioFilter{Limit: -1})
+	if err != nil {
+		t.Fatalf("baseline: %v", err)
+	}
+
+	scenarios := []Scenario{
+		{ID: "scn-a", Name: "火灾疏散", Category: CategoryFire, Background: "展厅起火", Status: ScenarioStatusEnabled, Metadata: map[string]any{"level": "一级"}, CreatedBy: "u1", CreatedAt: base, UpdatedAt: base},
+		{ID: "scn-b", Name: "停电", Category: CategoryPowerOutage, Background: "配电故障", Status: ScenarioStatusDisabled, Metadata: map[string]any{}, CreatedAt: base.Add(time.Minute), UpdatedAt: base.Add(time.Minute)},
+	}
+	for _, item := range scenarios {
+		if err := store.CreateScenario(ctx, item); err != nil {
+			t.Fatalf("create scenario %s: %v", item.ID, err)
+		}
+	}
+	got, err := store.GetScenario(ctx, "scn-a")
+	if err != nil {
+		t.Fatalf("get scenario: %v", err)
+	}
+	databasetest.AssertSameJSON(t, got, scenarios[0])
+	page, total, err := store.ListScenarios(ctx, ScenarioFilter{Limit: 1, Offset: 1})
+	if err != nil {
+		t.Fatalf("list scenarios: %v", err)
+	}
+	if total != baseline+2 || len(page) != 1 || page[0].ID != "scn-b" {
+		t.Fatalf("second scenario page = %d %v, want total %d and scn-b", total, page, baseline+2)
+	}
+	if page, _, err := store.ListScenarios(ctx, ScenarioFilter{Status: ScenarioStatusDisabled, Limit: -1}); err != nil || len(page) != 1 || page[0].ID != "scn-b" {
+		t.Fatalf("disabled scenarios = %v %v, want scn-b", page, err)
+	}
+
+	steps := []ScenarioStep{
+		{ID: "step-2", ScenarioID: "scn-a", SortOrder: 2, Title: "疏散", CreatedAt: base, UpdatedAt: base},
+		{ID: "step-1", ScenarioID: "scn-a", SortOrder: 1, Title: "报警", CreatedAt: base.Add(time.Minute), UpdatedAt: base.Add(time.Minute)},
+	}
+	for _, item := range steps {
+		if err := store.CreateStep(ctx, item); err != nil {
+			t.Fatalf("create step %s: %v", item.ID, err)
+		}
+	}
+	listedSteps, err := store.ListStepsByScenario(ctx, "scn-a")
+	if err != nil || len(listedSteps) != 2 || listedSteps[0].ID != "step-1" {
+		t.Fatalf("steps = %v %v, want step-1 first", listedSteps, err)
+	}
+	point := AssessmentPoint{ID: "point-1", ScenarioID: "scn-a", Title: "响应时间", CreatedAt: base, UpdatedAt: base}
+	if err := store.CreatePoint(ctx, point); err != nil {
+		t.Fatalf("create point: %v", err)
+	}
+	gotPoint, err := store.GetPoint(ctx, "point-1")
+	if err != nil {
+		t.Fatalf("get point: %v", err)
+	}
+	databasetest.AssertSameJSON(t, gotPoint, point)
+
+	started := base.Add(time.Hour)
+	runs := []Run{
+		{ID: "run-a", ScenarioID: "scn-a", Title: "一月演练", Status: RunStatusInProgress, StartedAt: &started, Metadata: map[string]any{}, CreatedAt: base, UpdatedAt: base},
+		{ID: "run-b", ScenarioID: "scn-a", Title: "二月演练", Status: RunStatusNotStarted, Metadata: map[string]any{}, CreatedAt: base.Add(time.Minute), UpdatedAt: base.Add(time.Minute)},
+	}
+	for _, item := range runs {
+		if err := store.CreateRun(ctx, item); err != nil {
+			t.Fatalf("create run %s: %v", item.ID, err)
+		}
+	}
+	gotRun, err := store.GetRun(ctx, "run-a")
+	if err != nil {
+		t.Fatalf("get run: %v", err)
+	}
+	databasetest.AssertSameJSON(t, gotRun, runs[0])
+	listedRuns, total, err := store.ListRuns(ctx, RunFilter{ScenarioID: "scn-a", Limit: -1})
+	if err != nil || total != 2 || listedRuns[0].ID != "run-b" {
+		t.Fatalf("runs = %d %v %v, want newest run-b first", total, listedRuns, err)
+	}
+
+	record := StepRecord{ID: "rec-1", RunID: "run-a", StepID: "step-1", Status: StepRecordPending, CreatedAt: base, UpdatedAt: base}
+	if err := store.UpsertStepRecord(ctx, record); err != nil {
+		t.Fatalf("insert record: %v", err)
+	}
+	record.Status = StepRecordExecuted
+	record.ActionNote = "已报警"
+	record.PerformedAt = &started
+	if err := store.UpsertStepRecord(ctx, record); err != nil {
+		t.Fatalf("update record: %v", err)
+	}
+	records, err := store.ListStepRecordsByRun(ctx, "run-a")
+	if err != nil || len(records) != 1 {
+		t.Fatalf("records = %v %v, want one upserted record", records, err)
+	}
+	databasetest.AssertSameJSON(t, records[0], record)
+
+	events := []SimEvent{
+		{ID: "evt-1", RunID: "run-a", EventType: SimEventSmokeAlarm, Payload: map[string]any{"zone": "A"}, Status: SimEventTriggered, TriggeredAt: &started, CreatedAt: base, UpdatedAt: base},
+		{ID: "evt-2", RunID: "run-a", EventType: SimEventOther, Payload: map[string]any{}, Status: SimEventHandled, CreatedAt: base.Add(time.Minute), UpdatedAt: base.Add(time.Minute)},
+	}
+	for _, item := range events {
+		if err := store.CreateSimEvent(ctx, item); err != nil {
+			t.Fatalf("create event %s: %v", item.ID, err)
+		}
+	}
+	listedEvents, total, err := store.ListSimEvents(ctx, "run-a", SimEventFilter{Status: SimEventTriggered, Limit: -1})
+	if err != nil || total != 1 || listedEvents[0].ID != "evt-1" {
+		t.Fatalf("triggered events = %d %v %v, want evt-1", total, listedEvents, err)
+	}
+	databasetest.AssertSameJSON(t, listedEvents[0], events[0])
+	if _, err := store.GetSimEvent(ctx, "run-b", "evt-1"); !errors.Is(err, ErrSimEventNotFound) {
+		t.Fatalf("event of another run = %v, want ErrSimEventNotFound", err)
+	}
+	foreign := events[1]
+	foreign.RunID = "run-b"
+	if err := store.UpdateSimEvent(ctx, foreign); !errors.Is(err, ErrSimEventNotFound) {
+		t.Fatalf("update event of another run = %v, want ErrSimEventNotFound", err)
+	}
+
+	assessment := Assessment{ID: "as-1", RunID: "run-a", PointID: "point-1", Score: 60, CreatedAt: base, UpdatedAt: base}
+	if err := store.UpsertAssessment(ctx, assessment); err != nil {
+		t.Fatalf("insert assessment: %v", err)
+	}
+	assessment.Score = 90
+	assessment.Comment = "响应及时"
+	if err := store.UpsertAssessment(ctx, assessment); err != nil {
+		t.Fatalf("update assessment: %v", err)
+	}
+	gotAssessment, err := store.GetAssessment(ctx, "run-a", "point-1")
+	if err != nil {
+		t.Fatalf("get assessment: %v", err)
+	}
+	databasetest.AssertSameJSON(t, gotAssessment, assessment)
+
+	for name, remove := range map[string]func() error{
+		"step records": func() error { return store.DeleteStepRecordsByRun(ctx, "run-a") },
+		"sim events":   func() error { return store.DeleteSimEventsByRun(ctx, "run-a") },
+		"assessments":  func() error { return store.DeleteAssessmentsByRun(ctx, "run-a") },
+	} {
+		if err := remove(); err != nil {
+			t.Fatalf("delete %s by run: %v", name, err)
+		}
+	}
+	if err := store.DeleteRun(ctx, "run-a"); err != nil {
+		t.Fatalf("delete run: %v", err)
+	}
+	if err := store.DeleteRun(ctx, "run-b"); err != nil {
+		t.Fatalf("delete run: %v", err)
+	}
+	if err := store.DeleteStepsByScenario(ctx, "scn-a"); err != nil {
+		t.Fatalf("delete steps: %v", err)
+	}
+	if err := store.DeletePointsByScenario(ctx, "scn-a"); err != nil {
+		t.Fatalf("delete points: %v", err)
+	}
+	if err := store.DeleteScenario(ctx, "scn-a"); err != nil {
+		t.Fatalf("delete scenario: %v", err)
+	}
+
+	notFound := map[string]struct {
+		err  error
+		want error
+	}{
+		"scenario":    {func() error { _, err := store.GetScenario(ctx, "scn-a"); return err }(), ErrScenarioNotFound},
+		"step":        {store.DeleteStep(ctx, "step-1"), ErrStepNotFound},
+		"point":       {store.UpdatePoint(ctx, point), ErrPointNotFound},
+		"run":         {store.UpdateRun(ctx, runs[0]), ErrRunNotFound},
+		"step record": {store.DeleteStepRecord(ctx, "run-a", "step-1"), ErrStepRecordNotFound},
+		"sim event":   {store.DeleteSimEvent(ctx, "run-a", "evt-1"), ErrSimEventNotFound},
+		"assessment":  {store.DeleteAssessment(ctx, "run-a", "point-1"), ErrAssessmentNotFound},
+	}
+	for name, check := range notFound {
+		if !errors.Is(check.err, check.want) {
+			t.Errorf("%s after delete = %v, want %v", name, check.err, check.want)
+		}
+	}
+}
+
+func TestInMemoryTimelineStoreContract(t *testing.T) {
+	runTimelineStoreContract(t, NewInMemoryTimelineStore(), func(Scenario, Run) {})
+}
+
+func TestPostgresTimelineStoreContract(t *testing.T) {
+	db := databasetest.Open(t)
+	drillStore := NewPostgresStore(db)
+	runTimelineStoreContract(t, NewPostgresTimelineStore(db), func(scenario Scenario, run Run) {
+		if err := drillStore.CreateScenario(context.Background(), scenario); err != nil {
+			t.Fatalf("create owning scenario: %v", err)
+		}
+		if err := drillStore.CreateRun(context.Background(), run); err != nil {
+			t.Fatalf("create owning run: %v", err)
+		}
+	})
+}
+
+// runTimelineStoreContract pins the TimelineStore semantics every
+// backend shares. addOwners creates the scenario and the run the rows
+// reference (the PostgreSQL tables carry foreign keys; the in-memory
+// store has nothing to add).
+func runTimelineStoreContract(t *testing.T, store TimelineStore, addOwners func(Scenario, Run)) {
+	ctx := context.Background()
+	base := databasetest.Time(2020, 1, 1, 9, 0, 0)
+	started := base.Add(time.Hour)
+	addOwners(
+		Scenario{ID: "scn-t", Name: "火灾疏散", Category: CategoryFire, Background: "展厅起火", Status: ScenarioStatusEnabled, Metadata: map[string]any{}, CreatedAt: base, UpdatedAt: base},
+		Run{ID: "run-t", ScenarioID: "scn-t", Title: "一月演练", Status: RunStatusInProgress, StartedAt: &started, Metadata: map[string]any{}, CreatedAt: base, UpdatedAt: base},
+	)
+
+	injects := []Inject{
+		{ID: "inj-b", ScenarioID: "scn-t", OffsetSeconds: 600, EventType: SimEventOther, PayloadTemplate: map[string]any{}, CreatedAt: base, UpdatedAt: base},
+		{ID: "inj-a", ScenarioID: "scn-t", OffsetSeconds: 60, EventType: SimEventSmokeAlarm, PayloadTemplate: map[string]any{"zone": "${run_title}"}, CreatedBy: "u1", CreatedAt: base, UpdatedAt: base},
+	}
+	for _, item := range injects {
+		if err := store.CreateInject(ctx, item); err != nil {
+			t.Fatalf("create inject %s: %v", item.ID, err)
+		}
+	}
+	listed, err := store.ListInjectsByScenario(ctx, "scn-t")
+	if err != nil || len(listed) != 2 || listed[0].ID != "inj-a" {
+		t.Fatalf("injects = %v %v, want inj-a (60s) first", listed, err)
+	}
+	databasetest.AssertSameJSON(t, listed[0], injects[1])
+	injects[0].OffsetSeconds = 900
+	if err := store.UpdateInject(ctx, injects[0]); err != nil {
+		t.Fatalf("update inject: %v", err)
+	}
+	got, err := store.GetInject(ctx, "inj-b")
+	if err != nil {
+		t.Fatalf("get inject: %v", err)
+	}
+	databasetest.AssertSameJSON(t, got, injects[0])
+
+	runInjects := []RunInject{
+		{ID: "ri-2", RunID: "run-t", ScenarioInjectID: "inj-b", OffsetSeconds: 900, EventType: SimEventOther, PayloadTemplate: map[string]any{}, Status: RunInjectPending, FireAt: started.Add(15 * time.Minute), CreatedAt: started, UpdatedAt: started},
+		{ID: "ri-1", RunID: "run-t", ScenarioInjectID: "inj-a", OffsetSeconds: 60, EventType: SimEventSmokeAlarm, PayloadTemplate: map[string]any{"zone": "${run_title}"}, Status: RunInjectPending, FireAt: started.Add(time.Minute), CreatedAt: started, UpdatedAt: started},
+	}
+	for _, item := range runInjects {
+		if err := store.CreateRunInject(ctx, item); err != nil {
+			t.Fatalf("create run inject %s: %v", item.ID, err)
+		}
+	}
+	fired := runInjects[1]
+	firedAt := started.Add(time.Minute)
+	fired.Status = RunInjectFired
+	fired.FiredAt = &firedAt
+	fired.SimEventID = "evt-1"
+	if err := store.UpdateRunInject(ctx, fired); err != nil {
+		t.Fatalf("update run inject: %v", err)
+	}
+	schedule, err := store.ListRunInjectsByRun(ctx, "run-t")
+	if err != nil || len(schedule) != 2 || schedule[0].ID != "ri-1" {
+		t.Fatalf("schedule = %v %v, want ri-1 (earliest fire_at) first", schedule, err)
+	}
+	databasetest.AssertSameJSON(t, schedule[0], fired)
+	pending, err := store.ListPendingRunInjects(ctx)
+	if err != nil || len(pending) != 1 || pending[0].ID != "ri-2" {
+		t.Fatalf("pending = %v %v, want ri-2 only", pending, err)
+	}
+	if _, err := store.GetRunInject(ctx, "run-other", "ri-1"); !errors.Is(err, ErrRunInjectNotFound) {
+		t.Fatalf("inject of another run = %v, want ErrRunInjectNotFound", err)
+	}
+
+	// 认领是条件更新：只有待触发且未预留 sim_event_id 的 inject 能被认领一次。
+	claim := pending[0]
+	claim.SimEventID = "evt-2"
+	claim.UpdatedAt = started.Add(15 * time.Minute)
+	if err := store.ClaimRunInject(ctx, claim); err != nil {
+		t.Fatalf("claim run inject: %v", err)
+	}
+	claimed, err := store.GetRunInject(ctx, "run-t", "ri-2")
+	if err != nil {
+		t.Fatalf("get claimed run inject: %v", err)
+	}
+	databasetest.AssertSameJSON(t, claimed, claim)
+	again := claim
+	again.SimEventID = "evt-3"
+	other := claim
+	other.RunID = "run-other"
+	refused := map[string]RunInject{"claimed": again, "fired": fired, "of another run": other}
+	for name, item := range refused {
+		if err := store.ClaimRunInject(ctx, item); !errors.Is(err, ErrRunInjectClaimed) {
+			t.Errorf("claim %s inject = %v, want ErrRunInjectClaimed", name, err)
+		}
+	}
+	if got, _ := store.GetRunInject(ctx, "run-t", "ri-2"); got.SimEventID != "evt-2" {
+		t.Fatalf("sim_event_id after a refused claim = %q, want evt-2", got.SimEventID)
+	}
+
+	if err := store.DeleteRunInjectsByRun(ctx, "run-t"); err != nil {
+		t.Fatalf("delete run injects: %v", err)
+	}
+	if err := store.DeleteInject(ctx, "inj-a"); err != nil {
+		t.Fatalf("delete inject: %v", err)
+	}
+	if err := store.DeleteInjectsByScenario(ctx, "scn-t"); err != nil {
+		t.Fatalf("delete injects by scenario: %v", err)
+	}
+	notFound := map[string]struct {
+		err  error
+		want error
+	}{
+		"inject":     {store.DeleteInject(ctx, "inj-b"), ErrInjectNotFound},
+		"updated":    {store.UpdateInject(ctx, injects[1]), ErrInjectNotFound},
+		"run inject": {store.UpdateRunInject(ctx, fired), ErrRunInjectNotFound},
+		"fetched":    {func() error { _, err := store.GetRunInject(ctx, "run-t", "ri-2"); return err }(), ErrRunInjectNotFound},
+	}
+	for name, check := range notFound {
+		if !errors.Is(check.err, check.want) {
+			t.Errorf("%s after delete = %v, want %v", name, check.err, check.want)
+		}
+	}
+}
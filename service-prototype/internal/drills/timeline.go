@@ -0,0 +1,209 @@
+package drills
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInjectNotFound is returned when a scenario timeline inject id does
+// not exist. It maps to HTTP 404 in the routing layer.
+var ErrInjectNotFound = errors.New("inject not found")
+
+// ErrRunInjectNotFound is returned when a scheduled inject does not
+// exist (or does not belong to the run in the route path). It maps to
+// HTTP 404 in the routing layer.
+var ErrRunInjectNotFound = errors.New("run inject not found")
+
+// ErrRunInjectClaimed is returned by ClaimRunInject when the inject is no
+// longer pending or a firing already reserved its sim event: another
+// scheduler (a second replica, or a Resume racing a timer) owns it.
+var ErrRunInjectClaimed = errors.New("run inject already claimed")
+
+// InjectCreatedBy is the created_by of the sim events raised by the
+// timeline scheduler, telling them apart from the events the exercise
+// controller raised by hand.
+const InjectCreatedBy = "timeline"
+
+// RunInjectStatus is the scheduling state of an inject within a run.
+type RunInjectStatus string
+
+const (
+	RunInjectPending   RunInjectStatus = "待触发"
+	RunInjectFired     RunInjectStatus = "已触发"
+	RunInjectCancelled RunInjectStatus = "已取消"
+)
+
+var validRunInjectStatuses = []RunInjectStatus{RunInjectPending, RunInjectFired, RunInjectCancelled}
+
+// Valid reports whether status is one of the allowed status values.
+func (status RunInjectStatus) Valid() bool {
+	for _, candidate := range validRunInjectStatuses {
+		if status == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// Inject is one entry of the scripted timeline (演练时间线) of a scenario
+// template: a sim event of the given type raised offset_seconds after the
+// run started, its payload rendered from the payload template (see
+// renderPayload). The event type must match the scenario category (or be
+// 其他), exactly like a sim event raised by hand.
+type Inject struct {
+	ID              string         `json:"id"`
+	ScenarioID      string         `json:"scenario_id"`
+	OffsetSeconds   int            `json:"offset_seconds"`
+	EventType       SimEventType   `json:"event_type"`
+	PayloadTemplate map[string]any `json:"payload_template"`
+	CreatedBy       string         `json:"created_by"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
+
+// InjectInput carries the client-supplied fields shared by inject create
+// and update, and by the live injects of a running drill. The owning
+// scenario (or run) comes from the route path.
+type InjectInput struct {
+	OffsetSeconds   int
+	EventType       SimEventType
+	PayloadTemplate map[string]any
+	CreatedBy       string
+}
+
+// RunInject is one scheduled inject of a drill run. Starting a run
+// copies the timeline of its scenario into the run (scenario_inject_id
+// names the template entry); the exercise controller may add more while
+// the run is 进行中 (scenario_inject_id is empty for those). fire_at is
+// started_at + offset_seconds; once the scheduler fired the inject,
+// fired_at and sim_event_id point at the raised event (sim_event_id is
+// reserved just before the event is raised, so a pending inject may
+// already carry it while a failed firing is retried). Completing or
+// terminating the run cancels the injects still pending.
+type RunInject struct {
+	ID               string          `json:"id"`
+	RunID            string          `json:"run_id"`
+	ScenarioInjectID string          `json:"scenario_inject_id"`
+	OffsetSeconds    int             `json:"offset_seconds"`
+	EventType        SimEventType    `json:"event_type"`
+	PayloadTemplate  map[string]any  `json:"payload_template"`
+	Status           RunInjectStatus `json:"status"`
+	FireAt           time.Time       `json:"fire_at"`
+	FiredAt          *time.Time      `json:"fired_at"`
+	SimEventID       string          `json:"sim_event_id"`
+	CreatedBy        string          `json:"created_by"`
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+}
+
+// normalizeInject validates client input and produces a complete
+// scenario inject. offset_seconds must not be negative; the event type
+// must be one of the allowed values and match the scenario category (or
+// be 其他); payload_template defaults to an empty object. The owning
+// scenario and the timestamps come from the caller.
+func normalizeInject(scenario Scenario, input InjectInput, now time.Time, id string) (Inject, error) {
+	if err := validateInjectInput(scenario.Category, input); err != nil {
+		return Inject{}, err
+	}
+	template := input.PayloadTemplate
+	if template == nil {
+		template = map[string]any{}
+	}
+	return Inject{
+		ID:              id,
+		ScenarioID:      scenario.ID,
+		OffsetSeconds:   input.OffsetSeconds,
+		EventType:       input.EventType,
+		PayloadTemplate: template,
+		CreatedBy:       input.CreatedBy,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}, nil
+}
+
+// validateInjectInput applies the inject rules shared by the scenario
+// timeline and the live injects of a run.
+func validateInjectInput(category Category, input InjectInput) error {
+	if input.OffsetSeconds < 0 {
+		return &ValidationError{Message: "offset_seconds must not be negative"}
+	}
+	if !input.EventType.Valid() {
+		return &ValidationError{Message: fmt.Sprintf("invalid event_type: %q", input.EventType)}
+	}
+	if !validEventTypeForCategory(category, input.EventType) {
+		return &ValidationError{
+			Message: fmt.Sprintf("invalid event_type %q for scenario category %q", input.EventType, category),
+		}
+	}
+	return nil
+}
+
+// scheduleInject produces the pending run inject of the given inject
+// definition: fire_at is the started_at of the run plus the offset.
+func scheduleInject(run Run, scenarioInjectID string, input InjectInput, now time.Time, id string) RunInject {
+	template := input.PayloadTemplate
+	if template == nil {
+		template = map[string]any{}
+	}
+	startedAt := now
+	if run.StartedAt != nil {
+		startedAt = *run.StartedAt
+	}
+	return RunInject{
+		ID:               id,
+		RunID:            run.ID,
+		ScenarioInjectID: scenarioInjectID,
+		OffsetSeconds:    input.OffsetSeconds,
+		EventType:        input.EventType,
+		PayloadTemplate:  cloneMap(template),
+		Status:           RunInjectPending,
+		FireAt:           startedAt.Add(time.Duration(input.OffsetSeconds) * time.Second),
+		CreatedBy:        input.CreatedBy,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+}
+
+// renderPayload produces the payload of the sim event an inject raises.
+// Every string value of the template (nested objects and arrays
+// included) has its placeholders replaced: ${run_id}, ${run_title},
+// ${scenario_id}, ${offset_seconds} and ${fired_at} (RFC 3339). Unknown
+// placeholders are kept verbatim; non-string values are copied as they
+// are.
+func renderPayload(template map[string]any, run Run, inject RunInject, firedAt time.Time) map[string]any {
+	replacer := strings.NewReplacer(
+		"${run_id}", run.ID,
+		"${run_title}", run.Title,
+		"${scenario_id}", run.ScenarioID,
+		"${offset_seconds}", strconv.Itoa(inject.OffsetSeconds),
+		"${fired_at}", firedAt.UTC().Format(time.RFC3339),
+	)
+	payload, _ := renderValue(template, replacer).(map[string]any)
+	if payload == nil {
+		payload = map[string]any{}
+	}
+	return payload
+}
+
+func renderValue(value any, replacer *strings.Replacer) any {
+	switch typed := value.(type) {
+	case string:
+		return replacer.Replace(typed)
+	case map[string]any:
+		rendered := make(map[string]any, len(typed))
+		for key, item := range typed {
+			rendered[key] = renderValue(item, replacer)
+		}
+		return rendered
+	case []any:
+		rendered := make([]any, len(typed))
+		for i, item := range typed {
+			rendered[i] = renderValue(item, replacer)
+		}
+		return rendered
+	}
+	return value
+}
@@ -0,0 +1,191 @@
+package drills
+
+import (
+	"context"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
+)
+
+// PostgresTimelineStore persists the scripted drill timeline in the
+// drill_scenario_injects and drill_run_injects tables (migration
+// 000032). It implements TimelineStore with the same semantics as the
+// in-memory timeline store: the listings are ordered by the time on the
+// timeline and run injects are always addressed within their run. The
+// database cascades scenario and run deletions on its own; the
+// DeleteXByY methods stay explicit so the service cleanup hook behaves
+// identically on both backends.
+type PostgresTimelineStore struct {
+	db pgstore.Querier
+}
+
+// NewPostgresTimelineStore returns a timeline store over the given
+// connection.
+func NewPostgresTimelineStore(db pgstore.Querier) *PostgresTimelineStore {
+	return &PostgresTimelineStore{db: db}
+}
+
+const (
+	injectColumns    = `id, scenario_id, offset_seconds, event_type, payload_template, created_by, created_at, updated_at`
+	runInjectColumns = `id, run_id, scenario_inject_id, offset_seconds, event_type, payload_template, status, fire_at, fired_at, sim_event_id, created_by, created_at, updated_at`
+)
+
+// CreateInject inserts the scenario inject.
+func (s *PostgresTimelineStore) CreateInject(ctx context.Context, inject Inject) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO drill_scenario_injects (`+injectColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		inject.ID, inject.ScenarioID, inject.OffsetSeconds, inject.EventType, inject.PayloadTemplate,
+		inject.CreatedBy, inject.CreatedAt, inject.UpdatedAt)
+	return err
+}
+
+// ListInjectsByScenario returns the injects of the scenario ordered by
+// offset_seconds ASC, id ASC.
+func (s *PostgresTimelineStore) ListInjectsByScenario(ctx context.Context, scenarioID string) ([]Inject, error) {
+	rows, err := s.db.Query(ctx, `SELECT `+injectColumns+` FROM drill_scenario_injects
+		WHERE scenario_id = $1 ORDER BY offset_seconds, id`, scenarioID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	injects := []Inject{}
+	for rows.Next() {
+		inject, err := scanInject(rows)
+		if err != nil {
+			return nil, err
+		}
+		injects = append(injects, inject)
+	}
+	return injects, rows.Err()
+}
+
+// GetInject returns the scenario inject with the given id, or
+// ErrInjectNotFound.
+func (s *PostgresTimelineStore) GetInject(ctx context.Context, id string) (Inject, error) {
+	inject, err := scanInject(s.db.QueryRow(ctx, `SELECT `+injectColumns+` FROM drill_scenario_injects WHERE id = $1`, id))
+	if pgstore.IsNoRows(err) {
+		return Inject{}, ErrInjectNotFound
+	}
+	return inject, err
+}
+
+// UpdateInject replaces the scenario inject with the same id, or returns
+// ErrInjectNotFound.
+func (s *PostgresTimelineStore) UpdateInject(ctx context.Context, inject Inject) error {
+	tag, err := s.db.Exec(ctx, `UPDATE drill_scenario_injects SET scenario_id = $2, offset_seconds = $3,
+		event_type = $4, payload_template = $5, created_by = $6, created_at = $7, updated_at = $8
+		WHERE id = $1`,
+		inject.ID, inject.ScenarioID, inject.OffsetSeconds, inject.EventType, inject.PayloadTemplate,
+		inject.CreatedBy, inject.CreatedAt, inject.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrInjectNotFound)
+}
+
+// DeleteInject removes the scenario inject with the given id, or returns
+// ErrInjectNotFound.
+func (s *PostgresTimelineStore) DeleteInject(ctx context.Context, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM drill_scenario_injects WHERE id = $1`, id)
+	return pgstore.CheckAffected(tag, err, ErrInjectNotFound)
+}
+
+// DeleteInjectsByScenario removes every inject of the scenario. Removing
+// no injects is not an error.
+func (s *PostgresTimelineStore) DeleteInjectsByScenario(ctx context.Context, scenarioID string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM drill_scenario_injects WHERE scenario_id = $1`, scenarioID)
+	return err
+}
+
+// CreateRunInject inserts the run inject.
+func (s *PostgresTimelineStore) CreateRunInject(ctx context.Context, inject RunInject) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO drill_run_injects (`+runInjectColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		inject.ID, inject.RunID, inject.ScenarioInjectID, inject.OffsetSeconds, inject.EventType,
+		inject.PayloadTemplate, inject.Status, inject.FireAt, inject.FiredAt, inject.SimEventID,
+		inject.CreatedBy, inject.CreatedAt, inject.UpdatedAt)
+	return err
+}
+
+// ListRunInjectsByRun returns the injects of the run ordered by fire_at
+// ASC, id ASC.
+func (s *PostgresTimelineStore) ListRunInjectsByRun(ctx context.Context, runID string) ([]RunInject, error) {
+	return s.listRunInjects(ctx, `WHERE run_id = $1`, runID)
+}
+
+// ListPendingRunInjects returns the 待触发 injects of every run ordered by
+// fire_at ASC, id ASC.
+func (s *PostgresTimelineStore) ListPendingRunInjects(ctx context.Context) ([]RunInject, error) {
+	return s.listRunInjects(ctx, `WHERE status = $1`, RunInjectPending)
+}
+
+// GetRunInject returns the inject with the given id within the run, or
+// ErrRunInjectNotFound (an inject of another run is not found as well).
+func (s *PostgresTimelineStore) GetRunInject(ctx context.Context, runID, id string) (RunInject, error) {
+	inject, err := scanRunInject(s.db.QueryRow(ctx, `SELECT `+runInjectColumns+` FROM drill_run_injects
+		WHERE run_id = $1 AND id = $2`, runID, id))
+	if pgstore.IsNoRows(err) {
+		return RunInject{}, ErrRunInjectNotFound
+	}
+	return inject, err
+}
+
+// UpdateRunInject replaces the inject with the same run and id, or
+// returns ErrRunInjectNotFound.
+func (s *PostgresTimelineStore) UpdateRunInject(ctx context.Context, inject RunInject) error {
+	tag, err := s.db.Exec(ctx, `UPDATE drill_run_injects SET scenario_inject_id = $3, offset_seconds = $4,
+		event_type = $5, payload_template = $6, status = $7, fire_at = $8, fired_at = $9,
+		sim_event_id = $10, created_by = $11, created_at = $12, updated_at = $13
+		WHERE run_id = $2 AND id = $1`,
+		inject.ID, inject.RunID, inject.ScenarioInjectID, inject.OffsetSeconds, inject.EventType,
+		inject.PayloadTemplate, inject.Status, inject.FireAt, inject.FiredAt, inject.SimEventID,
+		inject.CreatedBy, inject.CreatedAt, inject.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrRunInjectNotFound)
+}
+
+// ClaimRunInject stores the sim_event_id and updated_at of the inject
+// with a conditional update, so of two schedulers firing the same inject
+// only one reserves its sim event; the other gets ErrRunInjectClaimed
+// (as does a missing inject).
+func (s *PostgresTimelineStore) ClaimRunInject(ctx context.Context, inject RunInject) error {
+	tag, err := s.db.Exec(ctx, `UPDATE drill_run_injects SET sim_event_id = $3, updated_at = $4
+		WHERE run_id = $2 AND id = $1 AND status = $5 AND sim_event_id = ''`,
+		inject.ID, inject.RunID, inject.SimEventID, inject.UpdatedAt, RunInjectPending)
+	return pgstore.CheckAffected(tag, err, ErrRunInjectClaimed)
+}
+
+// DeleteRunInjectsByRun removes every inject of the run. Removing no
+// injects is not an error.
+func (s *PostgresTimelineStore) DeleteRunInjectsByRun(ctx context.Context, runID string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM drill_run_injects WHERE run_id = $1`, runID)
+	return err
+}
+
+func (s *PostgresTimelineStore) listRunInjects(ctx context.Context, where string, arg any) ([]RunInject, error) {
+	rows, err := s.db.Query(ctx, `SELECT `+runInjectColumns+` FROM drill_run_injects `+where+`
+		ORDER BY fire_at, id`, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	injects := []RunInject{}
+	for rows.Next() {
+		inject, err := scanRunInject(rows)
+		if err != nil {
+			return nil, err
+		}
+		injects = append(injects, inject)
+	}
+	return injects, rows.Err()
+}
+
+func scanInject(row pgstore.Row) (Inject, error) {
+	var inject Inject
+	err := row.Scan(&inject.ID, &inject.ScenarioID, &inject.OffsetSeconds, &inject.EventType,
+		&inject.PayloadTemplate, &inject.CreatedBy, &inject.CreatedAt, &inject.UpdatedAt)
+	return inject, err
+}
+
+func scanRunInject(row pgstore.Row) (RunInject, error) {
+	var inject RunInject
+	err := row.Scan(&inject.ID, &inject.RunID, &inject.ScenarioInjectID, &inject.OffsetSeconds,
+		&inject.EventType, &inject.PayloadTemplate, &inject.Status, &inject.FireAt, &inject.FiredAt,
+		&inject.SimEventID, &inject.CreatedBy, &inject.CreatedAt, &inject.UpdatedAt)
+	return inject, err
+}
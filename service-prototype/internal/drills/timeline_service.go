@@ -0,0 +1,514 @@
+package drills
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/runstream"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/ulid"
+)
+
+// The backoff of a firing that failed on the store: the first retry
+// after fireRetryDelay, doubling up to fireRetryMaxDelay, for as long as
+// the inject is pending.
+const (
+	fireRetryDelay    = time.Second
+	fireRetryMaxDelay = time.Minute
+)
+
+// timer is the handle of an armed inject: *time.Timer in production, a
+// fake in the tests.
+type timer interface {
+	Stop() bool
+}
+
+// TimelineService applies the scripted drill timeline rules on top of
+// the timeline store and runs the run-scoped scheduler. The scenario
+// injects are the template: starting a run copies them into the run as
+// pending injects (fire_at = started_at + offset_seconds) and arms one
+// timer per inject; the exercise controller may add injects while the
+// run is 进行中. A firing inject raises a sim event of the run through the
+// drills service (same validation, same publication as a sim event
+// raised by hand, created_by "timeline"); completing or terminating the
+// run cancels the pending ones. The timers live in the process: Resume
+// re-arms the pending injects after a restart and Stop releases them on
+// shutdown.
+type TimelineService struct {
+	store     TimelineStore
+	runs      *Service // raises the sim events and reads the scenarios and runs
+	logger    *slog.Logger
+	mu        sync.Mutex
+	timers    map[string]timer    // armed injects by run inject id, guarded by mu
+	firing    map[string]struct{} // injects whose timer is firing now, guarded by mu
+	now       func() time.Time
+	newID     func() string
+	afterFunc func(d time.Duration, f func()) timer
+}
+
+// NewTimelineService builds the timeline service over the timeline
+// store and the drill store. The server-generated id is a 26-character
+// Crockford Base32 ULID.
+func NewTimelineService(store TimelineStore, drillStore Store) *TimelineService {
+	return &TimelineService{
+		store:  store,
+		runs:   NewService(drillStore),
+		logger: slog.New(slog.DiscardHandler),
+		timers: map[string]timer{},
+		firing: map[string]struct{}{},
+		now:    time.Now,
+		newID:  ulid.New,
+		afterFunc: func(d time.Duration, f func()) timer {
+			return time.AfterFunc(d, f)
+		},
+	}
+}
+
+// SetPublisher wires the run stream: from then on the sim events raised
+// by the scheduler and every scheduling change of a run inject (created,
+// fired, cancelled) are published as change events of the run. Calling
+// it is optional; without a publisher the timeline behaves exactly as
+// before.
+func (t *TimelineService) SetPublisher(publisher runstream.Publisher) {
+	t.runs.SetPublisher(publisher)
+}
+
+// SetLogger wires the logger of the scheduler: a firing that fails on the
+// store is logged before it is retried, and so is a failed cancellation
+// at the end of a run. Without one nothing is logged.
+func (t *TimelineService) SetLogger(logger *slog.Logger) {
+	t.logger = logger
+}
+
+// ─── Scenario injects ────────────────────────────────────────────────
+
+// CreateInject adds an inject to the timeline of the scenario with the
+// given id. A missing scenario is a 404; the event type must match the
+// scenario category (or be 其他), otherwise 400. Runs already started
+// keep the timeline they copied at their start.
+func (t *TimelineService) CreateInject(ctx context.Context, scenarioID string, input InjectInput) (Inject, error) {
+	scenario, err := t.runs.GetScenario(ctx, scenarioID)
+	if err != nil {
+		return Inject{}, err
+	}
+	inject, err := normalizeInject(scenario, input, t.now(), t.newID())
+	if err != nil {
+		return Inject{}, err
+	}
+	if err := t.store.CreateInject(ctx, inject); err != nil {
+		return Inject{}, err
+	}
+	return inject, nil
+}
+
+// ListInjects returns the timeline of the scenario (ordered by
+// offset_seconds ASC, id ASC) and the total number of injects. A missing
+// scenario is a 404.
+func (t *TimelineService) ListInjects(ctx context.Context, scenarioID string, filter ListFilter) ([]Inject, int, error) {
+	if _, err := t.runs.GetScenario(ctx, scenarioID); err != nil {
+		return nil, 0, err
+	}
+	all, err := t.store.ListInjectsByScenario(ctx, scenarioID)
+	if err != nil {
+		return nil, 0, err
+	}
+	start, end := paginate(len(all), filter.Limit, filter.Offset)
+	return all[start:end], len(all), nil
+}
+
+// GetInject returns the scenario inject with the given id, or
+// ErrInjectNotFound.
+func (t *TimelineService) GetInject(ctx context.Context, id string) (Inject, error) {
+	return t.store.GetInject(ctx, id)
+}
+
+// UpdateInject validates the input with the same rules as CreateInject
+// and replaces the inject with the given id. The owning scenario and the
+// original creation timestamp are preserved; the update timestamp is
+// refreshed.
+func (t *TimelineService) UpdateInject(ctx context.Context, id string, input InjectInput) (Inject, error) {
+	existing, err := t.store.GetInject(ctx, id)
+	if err != nil {
+		return Inject{}, err
+	}
+	scenario, err := t.runs.GetScenario(ctx, existing.ScenarioID)
+	if err != nil {
+		return Inject{}, err
+	}
+	updated, err := normalizeInject(scenario, input, t.now(), id)
+	if err != nil {
+		return Inject{}, err
+	}
+	updated.CreatedAt = existing.CreatedAt
+	if err := t.store.UpdateInject(ctx, updated); err != nil {
+		return Inject{}, err
+	}
+	return updated, nil
+}
+
+// DeleteInject removes the scenario inject with the given id, or returns
+// ErrInjectNotFound. The injects already copied into started runs are
+// not affected.
+func (t *TimelineService) DeleteInject(ctx context.Context, id string) error {
+	return t.store.DeleteInject(ctx, id)
+}
+
+// ─── Run injects ─────────────────────────────────────────────────────
+
+// AddRunInject schedules a live inject within the run: the exercise
+// controller's ad-hoc addition to the scripted timeline. The run must be
+// 进行中 (400 otherwise); the offset counts from the started_at of the
+// run like the scripted injects, so an offset already in the past fires
+// at once.
+func (t *TimelineService) AddRunInject(ctx context.Context, runID string, input InjectInput) (RunInject, error) {
+	run, err := t.runs.requireWritableRun(ctx, runID, []RunStatus{RunStatusInProgress})
+	if err != nil {
+		return RunInject{}, err
+	}
+	if err := validateInjectInput(t.runs.categoryOf(ctx, run), input); err != nil {
+		return RunInject{}, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	inject := scheduleInject(run, "", input, t.now(), t.newID())
+	if err := t.store.CreateRunInject(ctx, inject); err != nil {
+		return RunInject{}, err
+	}
+	t.arm(inject)
+	t.runs.publish(runID, runstream.KindInject, runstream.ActionCreated, inject.ID, inject)
+	return inject, nil
+}
+
+// ListRunInjects returns the schedule of the run (ordered by fire_at
+// ASC, id ASC) and the total number of injects. A missing run is a 404.
+func (t *TimelineService) ListRunInjects(ctx context.Context, runID string, filter ListFilter) ([]RunInject, int, error) {
+	if _, err := t.runs.GetRun(ctx, runID); err != nil {
+		return nil, 0, err
+	}
+	all, err := t.store.ListRunInjectsByRun(ctx, runID)
+	if err != nil {
+		return nil, 0, err
+	}
+	start, end := paginate(len(all), filter.Limit, filter.Offset)
+	return all[start:end], len(all), nil
+}
+
+// GetRunInject returns the inject with the given id within the run. A
+// missing run is ErrRunNotFound; a missing inject is
+// ErrRunInjectNotFound.
+func (t *TimelineService) GetRunInject(ctx context.Context, runID, id string) (RunInject, error) {
+	if _, err := t.runs.GetRun(ctx, runID); err != nil {
+		return RunInject{}, err
+	}
+	return t.store.GetRunInject(ctx, runID, id)
+}
+
+// CancelRunInject cancels one pending inject of the run: its timer is
+// stopped and it never fires. An inject already fired or cancelled, or
+// one that is firing right now, is a ValidationError (400).
+func (t *TimelineService) CancelRunInject(ctx context.Context, runID, id string) (RunInject, error) {
+	if _, err := t.runs.GetRun(ctx, runID); err != nil {
+		return RunInject{}, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	inject, err := t.store.GetRunInject(ctx, runID, id)
+	if err != nil {
+		return RunInject{}, err
+	}
+	if inject.Status != RunInjectPending {
+		return RunInject{}, &ValidationError{
+			Message: "inject status " + string(inject.Status) + " does not allow this operation",
+		}
+	}
+	if _, firing := t.firing[id]; firing {
+		return RunInject{}, &ValidationError{Message: "inject is firing and can no longer be cancelled"}
+	}
+	return t.cancel(ctx, inject)
+}
+
+// ─── Run lifecycle (the drills service's timeline hook) ──────────────
+
+// PrepareRunStart copies the timeline of the scenario into the run that
+// is about to move to 进行中, as pending injects that are not armed yet.
+// The returned function arms and publishes them; the drills service calls
+// it once the new run status is stored. If the copy fails midway, the
+// injects already copied are removed again.
+func (t *TimelineService) PrepareRunStart(ctx context.Context, run Run) (func(), error) {
+	templates, err := t.store.ListInjectsByScenario(ctx, run.ScenarioID)
+	if err != nil {
+		return nil, err
+	}
+	now := t.now()
+	injects := make([]RunInject, 0, len(templates))
+	for _, template := range templates {
+		inject := scheduleInject(run, template.ID, InjectInput{
+			OffsetSeconds:   template.OffsetSeconds,
+			EventType:       template.EventType,
+			PayloadTemplate: template.PayloadTemplate,
+			CreatedBy:       template.CreatedBy,
+		}, now, t.newID())
+		if err := t.store.CreateRunInject(ctx, inject); err != nil {
+			_ = t.store.DeleteRunInjectsByRun(ctx, run.ID)
+			return nil, err
+		}
+		injects = append(injects, inject)
+	}
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		for _, inject := range injects {
+			t.arm(inject)
+			t.runs.publish(run.ID, runstream.KindInject, runstream.ActionCreated, inject.ID, inject)
+		}
+	}, nil
+}
+
+// RunEnded cancels the pending injects of the run that just moved to
+// 已完成 or 已终止; the fired ones keep their record. An inject firing
+// right now is left to its firing, which the ended run refuses, so it
+// cancels the inject itself. The run has ended whatever happens here, so
+// a failure is logged with the run id rather than returned: a pending
+// inject the cancellation missed is cancelled when its timer fires or by
+// the next Resume.
+func (t *TimelineService) RunEnded(ctx context.Context, run Run) {
+	if err := t.cancelPending(ctx, run.ID); err != nil {
+		t.logger.Warn("cancel pending drill injects", "run_id", run.ID, "error", err)
+	}
+}
+
+func (t *TimelineService) cancelPending(ctx context.Context, runID string) error {
+	injects, err := t.store.ListRunInjectsByRun(ctx, runID)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, inject := range injects {
+		if _, firing := t.firing[inject.ID]; firing || inject.Status != RunInjectPending {
+			continue
+		}
+		if _, err := t.cancel(ctx, inject); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteRunInjectsByRun stops the timers of the run and removes its
+// injects: the cascade of a run deletion.
+func (t *TimelineService) DeleteRunInjectsByRun(ctx context.Context, runID string) error {
+	injects, err := t.store.ListRunInjectsByRun(ctx, runID)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, inject := range injects {
+		t.disarm(inject.ID)
+	}
+	return t.store.DeleteRunInjectsByRun(ctx, runID)
+}
+
+// DeleteInjectsByScenario removes the timeline of the scenario: the
+// cascade of a scenario deletion.
+func (t *TimelineService) DeleteInjectsByScenario(ctx context.Context, scenarioID string) error {
+	return t.store.DeleteInjectsByScenario(ctx, scenarioID)
+}
+
+// ─── Scheduler ───────────────────────────────────────────────────────
+
+// Resume re-arms the pending injects after a restart: the injects of a
+// run still 进行中 are armed again (an inject whose fire_at passed while
+// the process was down fires at once); the pending injects of a run that
+// ended meanwhile are cancelled. The composition root calls it once the
+// publisher is wired. Other replicas may resume the same injects: the
+// firing claims each inject, so only one of them raises its sim event.
+func (t *TimelineService) Resume(ctx context.Context) error {
+	injects, err := t.store.ListPendingRunInjects(ctx)
+	if err != nil {
+		return err
+	}
+	for _, inject := range injects {
+		run, err := t.runs.GetRun(ctx, inject.RunID)
+		if errors.Is(err, ErrRunNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if run.Status == RunStatusInProgress {
+			t.mu.Lock()
+			if _, firing := t.firing[inject.ID]; !firing {
+				t.arm(inject)
+			}
+			t.mu.Unlock()
+			continue
+		}
+		if _, err := t.markCancelled(ctx, inject); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop releases every armed timer. The injects stay pending in the store
+// and are re-armed by Resume at the next start; a firing in flight
+// completes but is not retried.
+func (t *TimelineService) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id := range t.timers {
+		t.disarm(id)
+	}
+	clear(t.firing)
+}
+
+// arm schedules the inject at its fire_at. The caller holds mu.
+func (t *TimelineService) arm(inject RunInject) {
+	delay := inject.FireAt.Sub(t.now())
+	if delay < 0 {
+		delay = 0
+	}
+	t.armAfter(inject.RunID, inject.ID, delay, 0)
+}
+
+// armAfter schedules the given attempt at firing the inject after delay.
+// The caller holds mu.
+func (t *TimelineService) armAfter(runID, id string, delay time.Duration, attempt int) {
+	t.disarm(id)
+	t.timers[id] = t.afterFunc(delay, func() { t.fire(runID, id, attempt) })
+}
+
+// retry re-arms an inject whose firing failed on the store, with an
+// exponential backoff. The caller holds mu.
+func (t *TimelineService) retry(runID, id string, attempt int, err error) {
+	delay := fireRetryMaxDelay
+	if attempt < 6 {
+		delay = min(fireRetryDelay<<attempt, fireRetryMaxDelay)
+	}
+	t.logger.Warn("fire drill inject; retrying", "run_id", runID, "inject_id", id, "attempt", attempt+1, "retry_in", delay, "error", err)
+	t.armAfter(runID, id, delay, attempt+1)
+}
+
+// disarm stops the timer of the inject, if any. The caller holds mu.
+func (t *TimelineService) disarm(id string) {
+	if armed, ok := t.timers[id]; ok {
+		armed.Stop()
+		delete(t.timers, id)
+	}
+}
+
+// cancel marks the pending inject 已取消 and stops its timer. The caller
+// holds mu.
+func (t *TimelineService) cancel(ctx context.Context, inject RunInject) (RunInject, error) {
+	t.disarm(inject.ID)
+	return t.markCancelled(ctx, inject)
+}
+
+// markCancelled stores and publishes the inject as 已取消. It touches no
+// timer, so the caller need not hold mu.
+func (t *TimelineService) markCancelled(ctx context.Context, inject RunInject) (RunInject, error) {
+	inject.Status = RunInjectCancelled
+	inject.UpdatedAt = t.now()
+	if err := t.store.UpdateRunInject(ctx, inject); err != nil {
+		return RunInject{}, err
+	}
+	t.runs.publish(inject.RunID, runstream.KindInject, runstream.ActionUpdated, inject.ID, inject)
+	return inject, nil
+}
+
+// fire raises the sim event of a due inject. It runs on the timer
+// goroutine. Under mu it only takes the timer and marks the inject as
+// firing, so a concurrent cancel either stops the timer first or finds
+// the inject firing and leaves it alone; the store, the drills service
+// and the publisher are called without mu. A firing that fails is logged
+// and the inject re-armed with a backoff, unless Stop ran meanwhile.
+func (t *TimelineService) fire(runID, id string, attempt int) {
+	t.mu.Lock()
+	if _, armed := t.timers[id]; !armed {
+		t.mu.Unlock()
+		return
+	}
+	delete(t.timers, id)
+	t.firing[id] = struct{}{}
+	t.mu.Unlock()
+
+	err := t.raise(context.Background(), runID, id)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, firing := t.firing[id]; !firing {
+		return
+	}
+	delete(t.firing, id)
+	if err != nil {
+		t.retry(runID, id, attempt, err)
+	}
+}
+
+// raise performs one firing of the inject. An inject the run refuses (the
+// run is no longer 进行中) is cancelled. The id of the sim event is
+// claimed on the inject with a conditional update before the event is
+// raised: of two schedulers firing the same inject (a second replica, or
+// a Resume after a restart) only one wins the claim and the other stops.
+// An attempt that raised the event but failed to record the firing finds
+// its claim the next time and only records it, so the event is never
+// raised twice. The returned error is retried.
+func (t *TimelineService) raise(ctx context.Context, runID, id string) error {
+	inject, err := t.store.GetRunInject(ctx, runID, id)
+	if errors.Is(err, ErrRunInjectNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if inject.Status != RunInjectPending {
+		return nil
+	}
+	run, err := t.runs.GetRun(ctx, runID)
+	if errors.Is(err, ErrRunNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	firedAt := t.now()
+	if inject.SimEventID == "" {
+		inject.SimEventID = t.newID()
+		inject.UpdatedAt = firedAt
+		err := t.store.ClaimRunInject(ctx, inject)
+		if errors.Is(err, ErrRunInjectClaimed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err = t.runs.GetSimEvent(ctx, runID, inject.SimEventID)
+	if errors.Is(err, ErrSimEventNotFound) {
+		_, err = t.runs.createSimEvent(ctx, runID, inject.SimEventID, SimEventInput{
+			EventType: inject.EventType,
+			Payload:   renderPayload(inject.PayloadTemplate, run, inject, firedAt),
+			CreatedBy: InjectCreatedBy,
+		})
+	}
+	var validation *ValidationError
+	switch {
+	case errors.As(err, &validation):
+		inject.SimEventID = ""
+		_, err := t.markCancelled(ctx, inject)
+		return err
+	case err != nil:
+		return err
+	}
+	inject.Status = RunInjectFired
+	inject.FiredAt = &firedAt
+	inject.UpdatedAt = firedAt
+	if err := t.store.UpdateRunInject(ctx, inject); err != nil {
+		return err
+	}
+	t.runs.publish(runID, runstream.KindInject, runstream.ActionUpdated, inject.ID, inject)
+	return nil
+}
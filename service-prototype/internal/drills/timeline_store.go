@@ -0,0 +1,260 @@
+package drills
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// TimelineStore persists the scripted drill timeline: the injects of the
+// scenario templates and the scheduled injects of the runs. The
+// prototype ships an in-memory and a PostgreSQL implementation; the
+// interface keeps the timeline service and the scheduler independent of
+// the storage backend. The cascade rules of the database (scenario
+// injects vanish with their scenario, run injects with their run) are
+// implemented by DeleteInjectsByScenario and DeleteRunInjectsByRun, the
+// cleanup entries the drills service calls through its timeline hook.
+type TimelineStore interface {
+	// Scenario injects
+	CreateInject(ctx context.Context, inject Inject) error
+	ListInjectsByScenario(ctx context.Context, scenarioID string) ([]Inject, error)
+	GetInject(ctx context.Context, id string) (Inject, error)
+	UpdateInject(ctx context.Context, inject Inject) error
+	DeleteInject(ctx context.Context, id string) error
+	DeleteInjectsByScenario(ctx context.Context, scenarioID string) error
+	// Run injects
+	CreateRunInject(ctx context.Context, inject RunInject) error
+	ListRunInjectsByRun(ctx context.Context, runID string) ([]RunInject, error)
+	ListPendingRunInjects(ctx context.Context) ([]RunInject, error)
+	GetRunInject(ctx context.Context, runID, id string) (RunInject, error)
+	UpdateRunInject(ctx context.Context, inject RunInject) error
+	ClaimRunInject(ctx context.Context, inject RunInject) error
+	DeleteRunInjectsByRun(ctx context.Context, runID string) error
+}
+
+// InMemoryTimelineStore keeps the timeline rows in insertion-ordered
+// slices guarded by a mutex. It implements TimelineStore for the
+// prototype and never touches a database; PostgresTimelineStore is the
+// database-backed counterpart. Both listings are ordered by the time on
+// the timeline: offset_seconds ASC for the scenario injects, fire_at ASC
+// for the run injects, id ASC as the deterministic tie-break.
+type InMemoryTimelineStore struct {
+	mu         sync.Mutex
+	injects    []Inject
+	runInjects []RunInject
+}
+
+// NewInMemoryTimelineStore returns an empty in-memory timeline store.
+func NewInMemoryTimelineStore() *InMemoryTimelineStore {
+	return &InMemoryTimelineStore{}
+}
+
+// CreateInject appends the scenario inject to the store.
+func (s *InMemoryTimelineStore) CreateInject(_ context.Context, inject Inject) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.injects = append(s.injects, cloneInject(inject))
+	return nil
+}
+
+// ListInjectsByScenario returns the injects of the scenario ordered by
+// offset_seconds ASC, id ASC.
+func (s *InMemoryTimelineStore) ListInjectsByScenario(_ context.Context, scenarioID string) ([]Inject, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	matched := make([]Inject, 0, len(s.injects))
+	for _, item := range s.injects {
+		if item.ScenarioID == scenarioID {
+			matched = append(matched, cloneInject(item))
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].OffsetSeconds == matched[j].OffsetSeconds {
+			return matched[i].ID < matched[j].ID
+		}
+		return matched[i].OffsetSeconds < matched[j].OffsetSeconds
+	})
+	return matched, nil
+}
+
+// GetInject returns the scenario inject with the given id, or
+// ErrInjectNotFound.
+func (s *InMemoryTimelineStore) GetInject(_ context.Context, id string) (Inject, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := s.indexOfInject(id)
+	if index < 0 {
+		return Inject{}, ErrInjectNotFound
+	}
+	return cloneInject(s.injects[index]), nil
+}
+
+// UpdateInject replaces the scenario inject with the same id, or returns
+// ErrInjectNotFound.
+func (s *InMemoryTimelineStore) UpdateInject(_ context.Context, inject Inject) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := s.indexOfInject(inject.ID)
+	if index < 0 {
+		return ErrInjectNotFound
+	}
+	s.injects[index] = cloneInject(inject)
+	return nil
+}
+
+// DeleteInject removes the scenario inject with the given id, or returns
+// ErrInjectNotFound.
+func (s *InMemoryTimelineStore) DeleteInject(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := s.indexOfInject(id)
+	if index < 0 {
+		return ErrInjectNotFound
+	}
+	s.injects = append(s.injects[:index], s.injects[index+1:]...)
+	return nil
+}
+
+// DeleteInjectsByScenario removes every inject of the scenario. Removing
+// no injects is not an error.
+func (s *InMemoryTimelineStore) DeleteInjectsByScenario(_ context.Context, scenarioID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.injects[:0]
+	for _, item := range s.injects {
+		if item.ScenarioID != scenarioID {
+			kept = append(kept, item)
+		}
+	}
+	s.injects = kept
+	return nil
+}
+
+// CreateRunInject appends the run inject to the store.
+func (s *InMemoryTimelineStore) CreateRunInject(_ context.Context, inject RunInject) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runInjects = append(s.runInjects, cloneRunInject(inject))
+	return nil
+}
+
+// ListRunInjectsByRun returns the injects of the run ordered by fire_at
+// ASC, id ASC.
+func (s *InMemoryTimelineStore) ListRunInjectsByRun(_ context.Context, runID string) ([]RunInject, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sortedRunInjects(func(item RunInject) bool { return item.RunID == runID }), nil
+}
+
+// ListPendingRunInjects returns the 待触发 injects of every run ordered by
+// fire_at ASC, id ASC: the schedule the scheduler re-arms on start-up.
+func (s *InMemoryTimelineStore) ListPendingRunInjects(_ context.Context) ([]RunInject, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sortedRunInjects(func(item RunInject) bool { return item.Status == RunInjectPending }), nil
+}
+
+// GetRunInject returns the inject with the given id within the run, or
+// ErrRunInjectNotFound.
+func (s *InMemoryTimelineStore) GetRunInject(_ context.Context, runID, id string) (RunInject, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := s.indexOfRunInject(runID, id)
+	if index < 0 {
+		return RunInject{}, ErrRunInjectNotFound
+	}
+	return cloneRunInject(s.runInjects[index]), nil
+}
+
+// UpdateRunInject replaces the inject with the same run and id, or
+// returns ErrRunInjectNotFound.
+func (s *InMemoryTimelineStore) UpdateRunInject(_ context.Context, inject RunInject) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := s.indexOfRunInject(inject.RunID, inject.ID)
+	if index < 0 {
+		return ErrRunInjectNotFound
+	}
+	s.runInjects[index] = cloneRunInject(inject)
+	return nil
+}
+
+// ClaimRunInject stores the sim_event_id and updated_at of the inject,
+// provided it is still pending without a reserved sim event; otherwise
+// (or if it is missing) it returns ErrRunInjectClaimed.
+func (s *InMemoryTimelineStore) ClaimRunInject(_ context.Context, inject RunInject) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := s.indexOfRunInject(inject.RunID, inject.ID)
+	if index < 0 {
+		return ErrRunInjectClaimed
+	}
+	stored := &s.runInjects[index]
+	if stored.Status != RunInjectPending || stored.SimEventID != "" {
+		return ErrRunInjectClaimed
+	}
+	stored.SimEventID = inject.SimEventID
+	stored.UpdatedAt = inject.UpdatedAt
+	return nil
+}
+
+// DeleteRunInjectsByRun removes every inject of the run. Removing no
+// injects is not an error.
+func (s *InMemoryTimelineStore) DeleteRunInjectsByRun(_ context.Context, runID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.runInjects[:0]
+	for _, item := range s.runInjects {
+		if item.RunID != runID {
+			kept = append(kept, item)
+		}
+	}
+	s.runInjects = kept
+	return nil
+}
+
+func (s *InMemoryTimelineStore) sortedRunInjects(match func(RunInject) bool) []RunInject {
+	matched := make([]RunInject, 0, len(s.runInjects))
+	for _, item := range s.runInjects {
+		if match(item) {
+			matched = append(matched, cloneRunInject(item))
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].FireAt.Equal(matched[j].FireAt) {
+			return matched[i].ID < matched[j].ID
+		}
+		return matched[i].FireAt.Before(matched[j].FireAt)
+	})
+	return matched
+}
+
+func (s *InMemoryTimelineStore) indexOfInject(id string) int {
+	for i, item := range s.injects {
+		if item.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *InMemoryTimelineStore) indexOfRunInject(runID, id string) int {
+	for i, item := range s.runInjects {
+		if item.RunID == runID && item.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func cloneInject(inject Inject) Inject {
+	cloned := inject
+	cloned.PayloadTemplate = cloneMap(inject.PayloadTemplate)
+	return cloned
+}
+
+func cloneRunInject(inject RunInject) RunInject {
+	cloned := inject
+	cloned.PayloadTemplate = cloneMap(inject.PayloadTemplate)
+	return cloned
+}
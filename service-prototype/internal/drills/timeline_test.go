@@ -0,0 +1,493 @@
+// Service unit tests for the scripted drill timeline (演练时间线): the
+// scenario inject validation, the copy of the timeline into a run at
+// StartRun, the firing of a due inject (rendered payload, sim event,
+// fired record), the cancellation on CompleteRun / TerminateRun and by
+// hand, the live injects of the exercise controller, the restart resume
+// and the cascades. The timers are faked: every armed inject is
+// recorded with its delay and fired by the test, so the schedule is
+// deterministic and no test sleeps.
+package drills
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTimer is an armed inject of the fake scheduler clock.
+type fakeTimer struct {
+	delay   time.Duration
+	fire    func()
+	stopped bool
+}
+
+func (f *fakeTimer) Stop() bool {
+	wasArmed := !f.stopped
+	f.stopped = true
+	return wasArmed
+}
+
+// timelineFixture wires a drills service to a timeline service over
+// in-memory stores, with a fixed clock and recorded timers.
+type timelineFixture struct {
+	service  *Service
+	timeline *TimelineService
+	timers   []*fakeTimer
+	now      time.Time
+}
+
+func newTimelineFixture(store Store, timelineStore TimelineStore) *timelineFixture {
+	fixture := &timelineFixture{now: time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)}
+	clock := func() time.Time { return fixture.now }
+	fixture.service = NewService(store)
+	fixture.service.now = clock
+	fixture.timeline = NewTimelineService(timelineStore, store)
+	fixture.timeline.now = clock
+	fixture.timeline.runs.now = clock
+	fixture.timeline.afterFunc = func(d time.Duration, f func()) timer {
+		armed := &fakeTimer{delay: d, fire: f}
+		fixture.timers = append(fixture.timers, armed)
+		return armed
+	}
+	fixture.service.SetTimeline(fixture.timeline)
+	return fixture
+}
+
+// startedRun creates a 大客流聚集 scenario with the given timeline, a run
+// of it, and starts the run.
+func (f *timelineFixture) startedRun(t *testing.T, injects ...InjectInput) (Scenario, Run) {
+	t.Helper()
+	scenario := mustCreateScenario(t, f.service, simEventScenarioInput("大客流疏散演练", CategoryPassengerFlow))
+	for _, input := range injects {
+		if _, err := f.timeline.CreateInject(context.Background(), scenario.ID, input); err != nil {
+			t.Fatalf("CreateInject: %v", err)
+		}
+	}
+	run := mustCreateRun(t, f.service, scenario.ID, runInput)
+	return scenario, mustStartRun(t, f.service, run.ID)
+}
+
+func (f *timelineFixture) runInjects(t *testing.T, runID string) []RunInject {
+	t.Helper()
+	injects, _, err := f.timeline.ListRunInjects(context.Background(), runID, ListFilter{Limit: -1})
+	if err != nil {
+		t.Fatalf("ListRunInjects: %v", err)
+	}
+	return injects
+}
+
+// ─── Scenario injects ────────────────────────────────────────────────
+
+// 负 offset、非法 event_type、与场景分类不匹配的类型 → 400；场景不存在 → 404；
+// 列表按 offset_seconds 升序。
+func TestScenarioInjectValidationAndOrder(t *testing.T) {
+	ctx := context.Background()
+	fixture := newTimelineFixture(NewInMemoryStore(), NewInMemoryTimelineStore())
+	scenario := mustCreateScenario(t, fixture.service, simEventScenarioInput("大客流疏散演练", CategoryPassengerFlow))
+
+	for name, input := range map[string]InjectInput{
+		"negative offset": {OffsetSeconds: -1, EventType: SimEventFlowOverflow},
+		"invalid type":    {EventType: "地震"},
+		"wrong category":  {EventType: SimEventSmokeAlarm},
+	} {
+		var validationError *ValidationError
+		if _, err := fixture.timeline.CreateInject(ctx, scenario.ID, input); !errors.As(err, &validationError) {
+			t.Errorf("%s: err = %v, want ValidationError", name, err)
+		}
+	}
+	if _, err := fixture.timeline.CreateInject(ctx, "scn-missing", InjectInput{EventType: SimEventOther}); !errors.Is(err, ErrScenarioNotFound) {
+		t.Fatalf("missing scenario: err = %v, want ErrScenarioNotFound", err)
+	}
+
+	late, _ := fixture.timeline.CreateInject(ctx, scenario.ID, InjectInput{OffsetSeconds: 900, EventType: SimEventOther})
+	early, _ := fixture.timeline.CreateInject(ctx, scenario.ID, InjectInput{OffsetSeconds: 60, EventType: SimEventFlowOverflow})
+	injects, total, err := fixture.timeline.ListInjects(ctx, scenario.ID, ListFilter{Limit: -1})
+	if err != nil || total != 2 || injects[0].ID != early.ID || injects[1].ID != late.ID {
+		t.Fatalf("timeline = %d %+v %v, want the 60s inject before the 900s one", total, injects, err)
+	}
+	if injects[0].PayloadTemplate == nil {
+		t.Fatal("omitted payload_template must default to an empty object")
+	}
+}
+
+// ─── Scheduling ──────────────────────────────────────────────────────
+
+// StartRun 把场景时间线复制进 run 并按 started_at + offset 布置定时器；
+// 到点触发时以渲染后的 payload 生成模拟事件（created_by=timeline），
+// inject 记录 fired_at 与 sim_event_id。
+func TestStartRunArmsAndFiresTheTimeline(t *testing.T) {
+	ctx := context.Background()
+	fixture := newTimelineFixture(NewInMemoryStore(), NewInMemoryTimelineStore())
+	_, run := fixture.startedRun(t,
+		InjectInput{OffsetSeconds: 120, EventType: SimEventFlowOverflow, PayloadTemplate: map[string]any{
+			"zone":   "A区东侧展厅",
+			"note":   "${run_title} 第 ${offset_seconds} 秒",
+			"detail": map[string]any{"run": "${run_id}"},
+			"count":  float64(850),
+		}},
+		InjectInput{OffsetSeconds: 600, EventType: SimEventOther},
+	)
+
+	injects := fixture.runInjects(t, run.ID)
+	if len(injects) != 2 || len(fixture.timers) != 2 {
+		t.Fatalf("run injects = %d, timers = %d; want 2 and 2", len(injects), len(fixture.timers))
+	}
+	if injects[0].Status != RunInjectPending || !injects[0].FireAt.Equal(run.StartedAt.Add(120*time.Second)) {
+		t.Fatalf("first inject = %+v, want 待触发 at started_at+120s", injects[0])
+	}
+	if fixture.timers[0].delay != 120*time.Second || fixture.timers[1].delay != 600*time.Second {
+		t.Fatalf("timer delays = %v, %v; want 2m and 10m", fixture.timers[0].delay, fixture.timers[1].delay)
+	}
+
+	fixture.now = fixture.now.Add(120 * time.Second)
+	fixture.timers[0].fire()
+	fired, err := fixture.timeline.GetRunInject(ctx, run.ID, injects[0].ID)
+	if err != nil || fired.Status != RunInjectFired || fired.FiredAt == nil || fired.SimEventID == "" {
+		t.Fatalf("fired inject = %+v %v, want 已触发 with fired_at and sim_event_id", fired, err)
+	}
+	event, err := fixture.service.GetSimEvent(ctx, run.ID, fired.SimEventID)
+	if err != nil {
+		t.Fatalf("GetSimEvent: %v", err)
+	}
+	if event.EventType != SimEventFlowOverflow || event.CreatedBy != InjectCreatedBy {
+		t.Fatalf("sim event = %+v, want 客流密度超阈值 raised by the timeline", event)
+	}
+	if event.Payload["note"] != run.Title+" 第 120 秒" || event.Payload["count"] != float64(850) {
+		t.Fatalf("payload = %v, want the rendered template", event.Payload)
+	}
+	if detail, _ := event.Payload["detail"].(map[string]any); detail["run"] != run.ID {
+		t.Fatalf("nested payload = %v, want ${run_id} rendered", event.Payload["detail"])
+	}
+
+	// A timer firing twice (or after its inject was handled) is a no-op.
+	fixture.timers[0].fire()
+	if _, total, _ := fixture.service.ListSimEvents(ctx, run.ID, SimEventFilter{Limit: -1}); total != 1 {
+		t.Fatalf("sim events = %d, want the inject to fire once", total)
+	}
+}
+
+// CompleteRun / TerminateRun 取消仍待触发的 inject 并停止定时器；已触发的保留记录。
+func TestRunEndCancelsPendingInjects(t *testing.T) {
+	ctx := context.Background()
+	for _, end := range []func(*Service, string) (Run, error){
+		func(s *Service, id string) (Run, error) { return s.CompleteRun(ctx, id) },
+		func(s *Service, id string) (Run, error) { return s.TerminateRun(ctx, id) },
+	} {
+		fixture := newTimelineFixture(NewInMemoryStore(), NewInMemoryTimelineStore())
+		_, run := fixture.startedRun(t,
+			InjectInput{OffsetSeconds: 0, EventType: SimEventOther},
+			InjectInput{OffsetSeconds: 300, EventType: SimEventFlowOverflow},
+		)
+		fixture.timers[0].fire()
+		if _, err := end(fixture.service, run.ID); err != nil {
+			t.Fatalf("end run: %v", err)
+		}
+		injects := fixture.runInjects(t, run.ID)
+		if injects[0].Status != RunInjectFired || injects[1].Status != RunInjectCancelled {
+			t.Fatalf("statuses = %s, %s; want 已触发 and 已取消", injects[0].Status, injects[1].Status)
+		}
+		if !fixture.timers[1].stopped {
+			t.Fatal("the timer of the cancelled inject is still armed")
+		}
+		// A stale timer that raced the cancellation does not fire.
+		fixture.timers[1].fire()
+		if _, total, _ := fixture.service.ListSimEvents(ctx, run.ID, SimEventFilter{Limit: -1}); total != 1 {
+			t.Fatalf("sim events = %d, want only the fired inject", total)
+		}
+	}
+}
+
+// failingRunStore fails UpdateRun on demand.
+type failingRunStore struct {
+	Store
+	failUpdate bool
+}
+
+func (s *failingRunStore) UpdateRun(ctx context.Context, run Run) error {
+	if s.failUpdate {
+		return errors.New("update run failed")
+	}
+	return s.Store.UpdateRun(ctx, run)
+}
+
+// failingRunInjectStore fails ListRunInjectsByRun, GetRunInject and
+// DeleteRunInjectsByRun on demand and the given number of next
+// UpdateRunInject calls; onClaim runs before each ClaimRunInject.
+type failingRunInjectStore struct {
+	TimelineStore
+	failList    bool
+	failGet     bool
+	failDelete  bool
+	failUpdates int
+	onClaim     func()
+}
+
+func (s *failingRunInjectStore) GetRunInject(ctx context.Context, runID, id string) (RunInject, error) {
+	if s.failGet {
+		return RunInject{}, errors.New("get run inject failed")
+	}
+	return s.TimelineStore.GetRunInject(ctx, runID, id)
+}
+
+func (s *failingRunInjectStore) UpdateRunInject(ctx context.Context, inject RunInject) error {
+	if s.failUpdates > 0 {
+		s.failUpdates--
+		return errors.New("update run inject failed")
+	}
+	return s.TimelineStore.UpdateRunInject(ctx, inject)
+}
+
+func (s *failingRunInjectStore) ClaimRunInject(ctx context.Context, inject RunInject) error {
+	if s.onClaim != nil {
+		s.onClaim()
+	}
+	return s.TimelineStore.ClaimRunInject(ctx, inject)
+}
+
+func (s *failingRunInjectStore) DeleteRunInjectsByRun(ctx context.Context, runID string) error {
+	if s.failDelete {
+		return errors.New("delete run injects failed")
+	}
+	return s.TimelineStore.DeleteRunInjectsByRun(ctx, runID)
+}
+
+func (s *failingRunInjectStore) ListRunInjectsByRun(ctx context.Context, runID string) ([]RunInject, error) {
+	if s.failList {
+		return nil, errors.New("list run injects failed")
+	}
+	return s.TimelineStore.ListRunInjectsByRun(ctx, runID)
+}
+
+// 状态写入失败的启动不留下 inject 也不启动定时器，可直接重试（清理 inject
+// 也失败时两个错误一并返回）；结束时取消 inject 失败不影响已写入的结束状态，
+// 失败带 run_id 记入日志，遗留的定时器触发时 inject 被取消。
+func TestRunTransitionSurvivesTimelineAndStoreFailures(t *testing.T) {
+	ctx := context.Background()
+	store := &failingRunStore{Store: NewInMemoryStore()}
+	timelineStore := &failingRunInjectStore{TimelineStore: NewInMemoryTimelineStore()}
+	fixture := newTimelineFixture(store, timelineStore)
+	var logs bytes.Buffer
+	fixture.timeline.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+	scenario := mustCreateScenario(t, fixture.service, simEventScenarioInput("大客流疏散演练", CategoryPassengerFlow))
+	if _, err := fixture.timeline.CreateInject(ctx, scenario.ID, InjectInput{OffsetSeconds: 300, EventType: SimEventFlowOverflow}); err != nil {
+		t.Fatalf("CreateInject: %v", err)
+	}
+	run := mustCreateRun(t, fixture.service, scenario.ID, runInput)
+
+	store.failUpdate, timelineStore.failDelete = true, true
+	if _, err := fixture.service.StartRun(ctx, run.ID); err == nil || !strings.Contains(err.Error(), "update run failed") ||
+		!strings.Contains(err.Error(), "remove injects of run "+run.ID+": delete run injects failed") {
+		t.Fatalf("StartRun with a failing store and cleanup: err = %v, want both failures", err)
+	}
+	timelineStore.failDelete = false
+	if _, err := fixture.service.StartRun(ctx, run.ID); err == nil {
+		t.Fatal("StartRun succeeded with a failing store")
+	}
+	if injects := fixture.runInjects(t, run.ID); len(injects) != 0 || len(fixture.timers) != 0 {
+		t.Fatalf("failed start left %d injects and %d timers", len(injects), len(fixture.timers))
+	}
+	store.failUpdate = false
+	mustStartRun(t, fixture.service, run.ID)
+	if injects := fixture.runInjects(t, run.ID); len(injects) != 1 || len(fixture.timers) != 1 {
+		t.Fatalf("retried start: %d injects and %d timers, want 1 and 1", len(injects), len(fixture.timers))
+	}
+
+	timelineStore.failList = true
+	if ended, err := fixture.service.CompleteRun(ctx, run.ID); err != nil || ended.Status != RunStatusCompleted {
+		t.Fatalf("CompleteRun = %s, %v; want 已完成 despite the timeline failure", ended.Status, err)
+	}
+	if !strings.Contains(logs.String(), "run_id="+run.ID) || !strings.Contains(logs.String(), "list run injects failed") {
+		t.Fatalf("log = %q, want the failed cancellation with the run id", logs.String())
+	}
+	timelineStore.failList = false
+	fixture.timers[0].fire()
+	if injects := fixture.runInjects(t, run.ID); injects[0].Status != RunInjectCancelled {
+		t.Fatalf("leftover inject status = %s, want 已取消", injects[0].Status)
+	}
+}
+
+// 触发时存储失败：inject 以指数退避重新布置而不是被遗忘；模拟事件已生成但
+// 记录触发失败时，重试沿用预留的 sim_event_id，只记录触发，不重复生成事件。
+func TestFireRetriesStoreFailuresWithoutDuplicateEvents(t *testing.T) {
+	ctx := context.Background()
+	timelineStore := &failingRunInjectStore{TimelineStore: NewInMemoryTimelineStore()}
+	fixture := newTimelineFixture(NewInMemoryStore(), timelineStore)
+	_, run := fixture.startedRun(t, InjectInput{OffsetSeconds: 0, EventType: SimEventFlowOverflow})
+
+	timelineStore.failGet = true
+	fixture.timers[0].fire()
+	fixture.timers[1].fire()
+	if len(fixture.timers) != 3 || fixture.timers[1].delay != fireRetryDelay || fixture.timers[2].delay != 2*fireRetryDelay {
+		t.Fatalf("retry timers = %d, want the inject re-armed after 1s then 2s", len(fixture.timers))
+	}
+	timelineStore.failGet = false
+
+	// The claim of the event id passes, recording the firing fails.
+	timelineStore.failUpdates = 1
+	fixture.timers[2].fire()
+	pending := fixture.runInjects(t, run.ID)[0]
+	if pending.Status != RunInjectPending || pending.SimEventID == "" || len(fixture.timers) != 4 {
+		t.Fatalf("inject after a failed record = %+v, timers = %d; want pending with the reserved event id and re-armed", pending, len(fixture.timers))
+	}
+	fixture.timers[3].fire()
+	fired := fixture.runInjects(t, run.ID)[0]
+	if fired.Status != RunInjectFired || fired.SimEventID != pending.SimEventID {
+		t.Fatalf("retried inject = %+v, want 已触发 with the reserved event id %s", fired, pending.SimEventID)
+	}
+	if _, total, _ := fixture.service.ListSimEvents(ctx, run.ID, SimEventFilter{Limit: -1}); total != 1 {
+		t.Fatalf("sim events = %d, want the retry to raise no second event", total)
+	}
+}
+
+// 两个副本都恢复了同一 inject：条件认领只让一个副本生成模拟事件，
+// 另一个副本不重试也不重复记录。
+func TestReplicasFireAnInjectOnce(t *testing.T) {
+	ctx := context.Background()
+	store, timelineStore := NewInMemoryStore(), NewInMemoryTimelineStore()
+	before := newTimelineFixture(store, timelineStore)
+	_, run := before.startedRun(t, InjectInput{OffsetSeconds: 60, EventType: SimEventOther})
+
+	first, second := newTimelineFixture(store, timelineStore), newTimelineFixture(store, timelineStore)
+	for _, replica := range []*timelineFixture{first, second} {
+		replica.now = before.now.Add(5 * time.Minute)
+		if err := replica.timeline.Resume(ctx); err != nil {
+			t.Fatalf("Resume: %v", err)
+		}
+	}
+	first.timers[0].fire()
+	second.timers[0].fire()
+	if _, total, _ := before.service.ListSimEvents(ctx, run.ID, SimEventFilter{Limit: -1}); total != 1 {
+		t.Fatalf("sim events = %d, want one for both replicas", total)
+	}
+	if len(second.timers) != 1 {
+		t.Fatalf("losing replica armed %d timers, want no retry", len(second.timers))
+	}
+	if injects := before.runInjects(t, run.ID); injects[0].Status != RunInjectFired {
+		t.Fatalf("inject = %s, want 已触发", injects[0].Status)
+	}
+}
+
+// 触发期间不持有调度锁：同一 inject 的手动取消不会阻塞，而是得到 400；
+// 其他 inject 仍可取消。
+func TestFireDoesNotHoldTheSchedulerLock(t *testing.T) {
+	ctx := context.Background()
+	timelineStore := &failingRunInjectStore{TimelineStore: NewInMemoryTimelineStore()}
+	fixture := newTimelineFixture(NewInMemoryStore(), timelineStore)
+	_, run := fixture.startedRun(t, InjectInput{OffsetSeconds: 0, EventType: SimEventFlowOverflow}, InjectInput{OffsetSeconds: 600, EventType: SimEventOther})
+	injects := fixture.runInjects(t, run.ID)
+
+	var firingErr, otherErr error
+	timelineStore.onClaim = func() {
+		timelineStore.onClaim = nil
+		_, firingErr = fixture.timeline.CancelRunInject(ctx, run.ID, injects[0].ID)
+		_, otherErr = fixture.timeline.CancelRunInject(ctx, run.ID, injects[1].ID)
+	}
+	fixture.timers[0].fire()
+	var validationError *ValidationError
+	if !errors.As(firingErr, &validationError) || otherErr != nil {
+		t.Fatalf("cancel during the firing = %v, other inject = %v; want ValidationError and nil", firingErr, otherErr)
+	}
+	after := fixture.runInjects(t, run.ID)
+	if after[0].Status != RunInjectFired || after[1].Status != RunInjectCancelled {
+		t.Fatalf("statuses = %s, %s; want 已触发 and 已取消", after[0].Status, after[1].Status)
+	}
+}
+
+// 演练控制人员在进行中追加 inject：未开始/已结束的 run → 400，类型不匹配 → 400；
+// 已过期的 offset 立即触发；待触发的可单独取消，重复取消 → 400。
+func TestLiveInjectsAndCancel(t *testing.T) {
+	ctx := context.Background()
+	fixture := newTimelineFixture(NewInMemoryStore(), NewInMemoryTimelineStore())
+	scenario := mustCreateScenario(t, fixture.service, simEventScenarioInput("大客流疏散演练", CategoryPassengerFlow))
+	pending := mustCreateRun(t, fixture.service, scenario.ID, runInput)
+	var validationError *ValidationError
+	if _, err := fixture.timeline.AddRunInject(ctx, pending.ID, InjectInput{EventType: SimEventOther}); !errors.As(err, &validationError) {
+		t.Fatalf("inject into a 未开始 run: err = %v, want ValidationError", err)
+	}
+	if _, err := fixture.timeline.AddRunInject(ctx, "run-missing", InjectInput{EventType: SimEventOther}); !errors.Is(err, ErrRunNotFound) {
+		t.Fatalf("inject into a missing run: err = %v, want ErrRunNotFound", err)
+	}
+
+	run := mustStartRun(t, fixture.service, pending.ID)
+	if _, err := fixture.timeline.AddRunInject(ctx, run.ID, InjectInput{EventType: SimEventPowerAlarm}); !errors.As(err, &validationError) {
+		t.Fatalf("wrong category: err = %v, want ValidationError", err)
+	}
+	fixture.now = fixture.now.Add(10 * time.Minute)
+	overdue, err := fixture.timeline.AddRunInject(ctx, run.ID, InjectInput{OffsetSeconds: 60, EventType: SimEventFlowOverflow, CreatedBy: "导演组"})
+	if err != nil {
+		t.Fatalf("AddRunInject: %v", err)
+	}
+	if overdue.ScenarioInjectID != "" || fixture.timers[0].delay != 0 {
+		t.Fatalf("live inject = %+v, delay %v; want no template and an immediate timer", overdue, fixture.timers[0].delay)
+	}
+	later, err := fixture.timeline.AddRunInject(ctx, run.ID, InjectInput{OffsetSeconds: 3600, EventType: SimEventOther})
+	if err != nil {
+		t.Fatalf("AddRunInject: %v", err)
+	}
+	cancelled, err := fixture.timeline.CancelRunInject(ctx, run.ID, later.ID)
+	if err != nil || cancelled.Status != RunInjectCancelled || !fixture.timers[1].stopped {
+		t.Fatalf("cancel = %+v %v, want 已取消 with the timer stopped", cancelled, err)
+	}
+	if _, err := fixture.timeline.CancelRunInject(ctx, run.ID, later.ID); !errors.As(err, &validationError) {
+		t.Fatalf("second cancel: err = %v, want ValidationError", err)
+	}
+	if _, err := fixture.timeline.CancelRunInject(ctx, run.ID, "inj-missing"); !errors.Is(err, ErrRunInjectNotFound) {
+		t.Fatalf("missing inject: err = %v, want ErrRunInjectNotFound", err)
+	}
+}
+
+// 进程重启后 Resume 重新布置进行中 run 的待触发 inject（已过期的立即触发），
+// 期间已结束的 run 的待触发 inject 被取消。
+func TestResumeRearmsPendingInjects(t *testing.T) {
+	ctx := context.Background()
+	store, timelineStore := NewInMemoryStore(), NewInMemoryTimelineStore()
+	before := newTimelineFixture(store, timelineStore)
+	_, live := before.startedRun(t, InjectInput{OffsetSeconds: 60, EventType: SimEventOther}, InjectInput{OffsetSeconds: 3600, EventType: SimEventOther})
+	_, ended := before.startedRun(t, InjectInput{OffsetSeconds: 60, EventType: SimEventOther})
+	// The process stops: the timers vanish, the run ends behind its back.
+	endedRun, _ := store.GetRun(ctx, ended.ID)
+	endedRun.Status = RunStatusTerminated
+	if err := store.UpdateRun(ctx, endedRun); err != nil {
+		t.Fatalf("UpdateRun: %v", err)
+	}
+
+	after := newTimelineFixture(store, timelineStore)
+	after.now = before.now.Add(5 * time.Minute)
+	if err := after.timeline.Resume(ctx); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	if len(after.timers) != 2 || after.timers[0].delay != 0 || after.timers[1].delay != 55*time.Minute {
+		t.Fatalf("re-armed %d timers, want the overdue one at once and the other in 55m", len(after.timers))
+	}
+	if injects := after.runInjects(t, ended.ID); injects[0].Status != RunInjectCancelled {
+		t.Fatalf("inject of the ended run = %s, want 已取消", injects[0].Status)
+	}
+	after.timers[0].fire()
+	if injects := after.runInjects(t, live.ID); injects[0].Status != RunInjectFired {
+		t.Fatalf("overdue inject = %s, want 已触发 after resume", injects[0].Status)
+	}
+}
+
+// 删除 run 停止其定时器并移除 inject；删除场景移除其时间线。
+func TestTimelineCascades(t *testing.T) {
+	ctx := context.Background()
+	timelineStore := NewInMemoryTimelineStore()
+	fixture := newTimelineFixture(NewInMemoryStore(), timelineStore)
+	scenario, run := fixture.startedRun(t, InjectInput{OffsetSeconds: 60, EventType: SimEventOther})
+	if err := fixture.service.DeleteRun(ctx, run.ID); err != nil {
+		t.Fatalf("DeleteRun: %v", err)
+	}
+	if injects, _ := timelineStore.ListRunInjectsByRun(ctx, run.ID); len(injects) != 0 || !fixture.timers[0].stopped {
+		t.Fatalf("after DeleteRun: %d injects, timer stopped %v; want none and stopped", len(injects), fixture.timers[0].stopped)
+	}
+	if err := fixture.service.DeleteScenario(ctx, scenario.ID); err != nil {
+		t.Fatalf("DeleteScenario: %v", err)
+	}
+	if injects, _ := timelineStore.ListInjectsByScenario(ctx, scenario.ID); len(injects) != 0 {
+		t.Fatalf("after DeleteScenario: %d scenario injects, want none", len(injects))
+	}
+}
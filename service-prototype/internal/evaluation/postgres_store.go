@@ -0,0 +1,98 @@
+package evaluation
+
+import (
+	"context"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
+)
+
+// PostgresStore persists the evaluation indicators in the
+// evaluation_indicators table (migration 000023, which also seeds the
+// built-in indicators). It implements Store with the same semantics as
+// the in-memory store: a missing id answers ErrIndicatorNotFound and the
+// listing keeps the repository sort order. The dimension is compared
+// with the "C" collation so the order matches the byte order of the
+// in-memory sort whatever the database locale.
+type PostgresStore struct {
+	db pgstore.Querier
+}
+
+// NewPostgresStore returns an indicator store over the given connection.
+func NewPostgresStore(db pgstore.Querier) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+const indicatorColumns = `id, dimension, title, weight, demo, sort_order, description, created_by, created_at, updated_at`
+
+// CreateIndicator inserts the indicator.
+func (s *PostgresStore) CreateIndicator(ctx context.Context, indicator Indicator) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO evaluation_indicators (`+indicatorColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		indicator.ID, indicator.Dimension, indicator.Title, indicator.Weight, indicator.Demo,
+		indicator.SortOrder, indicator.Description, indicator.CreatedBy, indicator.CreatedAt, indicator.UpdatedAt)
+	return err
+}
+
+// ListIndicators returns the indicators matching the filter ordered by
+// dimension, sort_order, created_at and id, the total number of matches
+// and the paginated page.
+func (s *PostgresStore) ListIndicators(ctx context.Context, filter IndicatorFilter) ([]Indicator, int, error) {
+	const where = ` WHERE ($1 = '' OR dimension = $1)`
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM evaluation_indicators`+where,
+		filter.Dimension).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+indicatorColumns+` FROM evaluation_indicators`+where+`
+		ORDER BY dimension COLLATE "C", sort_order, created_at, id LIMIT $2 OFFSET $3`,
+		filter.Dimension, pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []Indicator{}
+	for rows.Next() {
+		indicator, err := scanIndicator(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, indicator)
+	}
+	return page, total, rows.Err()
+}
+
+// GetIndicator returns the indicator with the given id, or
+// ErrIndicatorNotFound.
+func (s *PostgresStore) GetIndicator(ctx context.Context, id string) (Indicator, error) {
+	indicator, err := scanIndicator(s.db.QueryRow(ctx, `SELECT `+indicatorColumns+` FROM evaluation_indicators WHERE id = $1`, id))
+	if pgstore.IsNoRows(err) {
+		return Indicator{}, ErrIndicatorNotFound
+	}
+	return indicator, err
+}
+
+// UpdateIndicator replaces the indicator with the same id, or returns
+// ErrIndicatorNotFound.
+func (s *PostgresStore) UpdateIndicator(ctx context.Context, indicator Indicator) error {
+	tag, err := s.db.Exec(ctx, `UPDATE evaluation_indicators SET dimension = $2, title = $3, weight = $4,
+		demo = $5, sort_order = $6, description = $7, created_by = $8, created_at = $9, updated_at = $10
+		WHERE id = $1`,
+		indicator.ID, indicator.Dimension, indicator.Title, indicator.Weight, indicator.Demo,
+		indicator.SortOrder, indicator.Description, indicator.CreatedBy, indicator.CreatedAt, indicator.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrIndicatorNotFound)
+}
+
+// DeleteIndicator removes the indicator with the given id, or returns
+// ErrIndicatorNotFound. The score foreign key carries no cascade, so a
+// referenced indicator fails here even without the service-layer check.
+func (s *PostgresStore) DeleteIndicator(ctx context.Context, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM evaluation_indicators WHERE id = $1`, id)
+	return pgstore.CheckAffected(tag, err, ErrIndicatorNotFound)
+}
+
+func scanIndicator(row pgstore.Row) (Indicator, error) {
+	var indicator Indicator
+	err := row.Scan(&indicator.ID, &indicator.Dimension, &indicator.Title, &indicator.Weight, &indicator.Demo,
+		&indicator.SortOrder, &indicator.Description, &indicator.CreatedBy, &indicator.CreatedAt, &indicator.UpdatedAt)
+	return indicator, err
+}
@@ -31,6 +31,9 @@ const reportRunStatusCompleted = "已完成"
 // timestamps are maintained by the service. At most one report exists
 // per run (run_id UNIQUE): generating again overwrites the snapshot in
 // place, preserving id and created_at and refreshing updated_at.
+// dispatch_latency is the reception and execution latency of the
+// dispatch orders; it is reported next to the scores and never feeds
+// them.
 type Report struct {
 	ID              string                       `json:"id"`
 	RunID           string                       `json:"run_id"`
@@ -38,6 +41,7 @@ type Report struct {
 	DimensionScores map[Dimension]DimensionScore `json:"dimension_scores"`
 	IndicatorScores map[string]IndicatorScore    `json:"indicator_scores"`
 	Suggestions     []Suggestion                 `json:"suggestions"`
+	DispatchLatency DispatchLatency              `json:"dispatch_latency"`
 	CreatedBy       string                       `json:"created_by"`
 	CreatedAt       time.Time                    `json:"created_at"`
 	UpdatedAt       time.Time                    `json:"updated_at"`
@@ -71,6 +75,28 @@ type IndicatorScore struct {
 	Demo     *float64 `json:"demo,omitempty"`
 }
 
+// DispatchLatency is the order-handling latency block of a report:
+// reception is the time from issuing an order to its 已接收 transition,
+// execution the time from 已接收 to 已完成, both averaged over the
+// orders that reached the later milestone. It is informational: the
+// seven pinned formulas of the indicator scores do not read it.
+type DispatchLatency struct {
+	Reception LatencyStat `json:"reception"`
+	Execution LatencyStat `json:"execution"`
+}
+
+// LatencyStat is one latency measure of DispatchLatency: the number of
+// measured orders, their mean duration in seconds (rounded to 1
+// decimal) and the latency score of that mean (the generic
+// max(0, min(100, 100 − seconds ÷ 5)) formula of the engine). Without
+// any measured order count is 0 and the mean and the score are absent
+// from the JSON (omitempty), like an indicator source without a value.
+type LatencyStat struct {
+	Count       int      `json:"count"`
+	MeanSeconds *float64 `json:"mean_seconds,omitempty"`
+	Score       *float64 `json:"score,omitempty"`
+}
+
 // Suggestion is one rule-based improvement suggestion (改进建议) of a
 // report: the owning dimension, the level (严重/关注) and the fixed
 // template text. The single 演练数据不足 notice carries an empty
@@ -116,8 +142,13 @@ type ReportStepRecord struct {
 	Status string
 }
 
+// ReportOrder carries the issue time of an order and, from its status
+// history, the time it was received (已接收) and completed (已完成); a
+// milestone the order never reached is nil.
 type ReportOrder struct {
-	IssuedAt *time.Time
+	IssuedAt    *time.Time
+	ReceivedAt  *time.Time
+	CompletedAt *time.Time
 }
 
 type ReportMessage struct {
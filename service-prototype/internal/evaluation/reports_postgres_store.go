@@ -0,0 +1,120 @@
+package evaluation
+
+import (
+	"context"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
+)
+
+// PostgresReportStore persists the evaluation reports in the
+// evaluation_reports table (migration 000025, the dispatch latency
+// column 000034). It implements ReportStore
+// with the same semantics as the in-memory report store: one report per
+// run (a second creation answers ErrReportExists through the UNIQUE
+// run_id constraint), updates replace the whole snapshot, and the
+// listing is ordered newest first. The JSONB snapshots round-trip
+// through encoding/json, so the returned values never share state with
+// the caller.
+type PostgresReportStore struct {
+	db pgstore.Querier
+}
+
+// NewPostgresReportStore returns a report store over the given
+// connection.
+func NewPostgresReportStore(db pgstore.Querier) *PostgresReportStore {
+	return &PostgresReportStore{db: db}
+}
+
+const reportColumns = `id, run_id, overall_score, dimension_scores, indicator_scores, suggestions, dispatch_latency, created_by, created_at, updated_at`
+
+// CreateReport inserts the report, or returns ErrReportExists when the
+// run already has one.
+func (s *PostgresReportStore) CreateReport(ctx context.Context, report Report) error {
+	report = reportArgs(report)
+	_, err := s.db.Exec(ctx, `INSERT INTO evaluation_reports (`+reportColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		report.ID, report.RunID, report.OverallScore, report.DimensionScores, report.IndicatorScores,
+		report.Suggestions, report.DispatchLatency, report.CreatedBy, report.CreatedAt, report.UpdatedAt)
+	if pgstore.IsUniqueViolation(err) {
+		return ErrReportExists
+	}
+	return err
+}
+
+// UpdateReport replaces the report of the same run, or returns
+// ErrReportNotFound.
+func (s *PostgresReportStore) UpdateReport(ctx context.Context, report Report) error {
+	report = reportArgs(report)
+	tag, err := s.db.Exec(ctx, `UPDATE evaluation_reports SET id = $1, overall_score = $3,
+		dimension_scores = $4, indicator_scores = $5, suggestions = $6, dispatch_latency = $7,
+		created_by = $8, created_at = $9, updated_at = $10 WHERE run_id = $2`,
+		report.ID, report.RunID, report.OverallScore, report.DimensionScores, report.IndicatorScores,
+		report.Suggestions, report.DispatchLatency, report.CreatedBy, report.CreatedAt, report.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrReportNotFound)
+}
+
+// GetReportByRun returns the report of the run, or ErrReportNotFound.
+func (s *PostgresReportStore) GetReportByRun(ctx context.Context, runID string) (Report, error) {
+	report, err := scanReport(s.db.QueryRow(ctx, `SELECT `+reportColumns+` FROM evaluation_reports WHERE run_id = $1`, runID))
+	if pgstore.IsNoRows(err) {
+		return Report{}, ErrReportNotFound
+	}
+	return report, err
+}
+
+// ListReports returns the reports matching the filter ordered by
+// created_at DESC, id DESC, the total number of matches and the page.
+func (s *PostgresReportStore) ListReports(ctx context.Context, filter ReportFilter) ([]Report, int, error) {
+	const where = ` WHERE ($1 = '' OR run_id = $1)`
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM evaluation_reports`+where,
+		filter.RunID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+reportColumns+` FROM evaluation_reports`+where+`
+		ORDER BY created_at DESC, id DESC LIMIT $2 OFFSET $3`,
+		filter.RunID, pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []Report{}
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, report)
+	}
+	return page, total, rows.Err()
+}
+
+// DeleteReportsByRun removes the report of the run. Removing no report
+// is not an error.
+func (s *PostgresReportStore) DeleteReportsByRun(ctx context.Context, runID string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM evaluation_reports WHERE run_id = $1`, runID)
+	return err
+}
+
+// reportArgs replaces nil snapshots with their empty values, so the NOT
+// NULL JSONB columns receive {} and [] rather than a null.
+func reportArgs(report Report) Report {
+	if report.DimensionScores == nil {
+		report.DimensionScores = map[Dimension]DimensionScore{}
+	}
+	if report.IndicatorScores == nil {
+		report.IndicatorScores = map[string]IndicatorScore{}
+	}
+	if report.Suggestions == nil {
+		report.Suggestions = []Suggestion{}
+	}
+	return report
+}
+
+func scanReport(row pgstore.Row) (Report, error) {
+	var report Report
+	err := row.Scan(&report.ID, &report.RunID, &report.OverallScore, &report.DimensionScores,
+		&report.IndicatorScores, &report.Suggestions, &report.DispatchLatency, &report.CreatedBy,
+		&report.CreatedAt, &report.UpdatedAt)
+	return report, err
+}
@@ -103,6 +103,7 @@ func (s *ReportService) GenerateReport(ctx context.Context, runID string) (Repor
 			DimensionScores: content.DimensionScores,
 			IndicatorScores: content.IndicatorScores,
 			Suggestions:     content.Suggestions,
+			DispatchLatency: content.DispatchLatency,
 			CreatedAt:       now,
 			UpdatedAt:       now,
 		}
@@ -120,6 +121,7 @@ func (s *ReportService) GenerateReport(ctx context.Context, runID string) (Repor
 			DimensionScores: content.DimensionScores,
 			IndicatorScores: content.IndicatorScores,
 			Suggestions:     content.Suggestions,
+			DispatchLatency: content.DispatchLatency,
 			CreatedBy:       existing.CreatedBy,
 			CreatedAt:       existing.CreatedAt,
 			UpdatedAt:       now,
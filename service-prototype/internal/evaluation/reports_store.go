@@ -6,14 +6,14 @@ import (
 	"sync"
 )
 
-// ReportStore persists the evaluation reports. The prototype ships the
-// in-memory implementation; the interface keeps the service layer
-// independent of the storage backend. At most one report exists per run
-// (the database carries the UNIQUE run_id; the in-memory store enforces
-// the same rule in CreateReport); the cascade rule of the database
-// (reports vanish with their run) is implemented by DeleteReportsByRun,
-// the uniform cleanup entry the drills service calls through its
-// run-report cleaner hook.
+// ReportStore persists the evaluation reports. The prototype ships an
+// in-memory and a PostgreSQL implementation; the interface keeps the
+// service layer independent of the storage backend. At most one report
+// exists per run (the database carries the UNIQUE run_id; the in-memory
+// store enforces the same rule in CreateReport); the cascade rule of the
+// database (reports vanish with their run) is implemented by
+// DeleteReportsByRun, the uniform cleanup entry the drills service calls
+// through its run-report cleaner hook.
 type ReportStore interface {
 	CreateReport(ctx context.Context, report Report) error
 	UpdateReport(ctx context.Context, report Report) error
@@ -24,8 +24,8 @@ type ReportStore interface {
 
 // InMemoryReportStore keeps the evaluation report rows in an
 // insertion-ordered slice guarded by a mutex. It implements ReportStore
-// for the prototype and never touches a database; a database-backed
-// store arrives with a later slice. The listing method returns the rows
+// for the prototype and never touches a database; PostgresReportStore is
+// the database-backed counterpart. The listing method returns the rows
 // in the repository sort order (created_at DESC, id DESC as the
 // deterministic tie-break — the newest report first) and the paginated
 // page. Every accessor returns a deep copy, so callers can never mutate
@@ -150,6 +150,6 @@ func cloneReport(report Report) Report {
 	for id, score := range report.IndicatorScores {
 		clone.IndicatorScores[id] = score
 	}
-	clone.Suggestions = append([]Suggestion(nil), report.Suggestions...)
+	clone.Suggestions = append([]Suggestion{}, report.Suggestions...)
 	return clone
 }
@@ -0,0 +1,130 @@
+package evaluation
+
+import (
+	"context"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
+)
+
+// PostgresScoreStore persists the evaluation scores in the
+// evaluation_scores table (migration 000024). It implements ScoreStore
+// and ScoreRefChecker with the same semantics as the in-memory score
+// store: scores are addressed within their run, the listing is ordered
+// by created_at ASC, id ASC, and a second 专家评分 of one (run,
+// indicator) pair, which the partial unique index rejects, answers
+// ErrExpertScoreExists.
+type PostgresScoreStore struct {
+	db pgstore.Querier
+}
+
+// NewPostgresScoreStore returns a score store over the given connection.
+func NewPostgresScoreStore(db pgstore.Querier) *PostgresScoreStore {
+	return &PostgresScoreStore{db: db}
+}
+
+const scoreColumns = `id, run_id, indicator_id, score_type, rater, target, score, comment, created_by, created_at, updated_at`
+
+// CreateScore inserts the score.
+func (s *PostgresScoreStore) CreateScore(ctx context.Context, score Score) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO evaluation_scores (`+scoreColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		score.ID, score.RunID, score.IndicatorID, score.ScoreType, score.Rater, score.Target,
+		score.Score, score.Comment, score.CreatedBy, score.CreatedAt, score.UpdatedAt)
+	if pgstore.IsUniqueViolation(err) {
+		return ErrExpertScoreExists
+	}
+	return err
+}
+
+// ListScoresByRun returns the scores of the run matching the filter
+// ordered by created_at ASC, id ASC, the total number of matches and the
+// paginated page.
+func (s *PostgresScoreStore) ListScoresByRun(ctx context.Context, runID string, filter ScoreFilter) ([]Score, int, error) {
+	const where = ` WHERE run_id = $1 AND ($2 = '' OR score_type = $2) AND ($3 = '' OR indicator_id = $3)`
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM evaluation_scores`+where,
+		runID, filter.ScoreType, filter.IndicatorID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+scoreColumns+` FROM evaluation_scores`+where+`
+		ORDER BY created_at, id LIMIT $4 OFFSET $5`,
+		runID, filter.ScoreType, filter.IndicatorID, pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []Score{}
+	for rows.Next() {
+		score, err := scanScore(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, score)
+	}
+	return page, total, rows.Err()
+}
+
+// GetScore returns the score with the given id within the run, or
+// ErrScoreNotFound.
+func (s *PostgresScoreStore) GetScore(ctx context.Context, runID, id string) (Score, error) {
+	score, err := scanScore(s.db.QueryRow(ctx, `SELECT `+scoreColumns+` FROM evaluation_scores
+		WHERE run_id = $1 AND id = $2`, runID, id))
+	if pgstore.IsNoRows(err) {
+		return Score{}, ErrScoreNotFound
+	}
+	return score, err
+}
+
+// UpdateScore replaces the score with the same run and id, or returns
+// ErrScoreNotFound.
+func (s *PostgresScoreStore) UpdateScore(ctx context.Context, score Score) error {
+	tag, err := s.db.Exec(ctx, `UPDATE evaluation_scores SET indicator_id = $3, score_type = $4, rater = $5,
+		target = $6, score = $7, comment = $8, created_by = $9, created_at = $10, updated_at = $11
+		WHERE run_id = $2 AND id = $1`,
+		score.ID, score.RunID, score.IndicatorID, score.ScoreType, score.Rater, score.Target,
+		score.Score, score.Comment, score.CreatedBy, score.CreatedAt, score.UpdatedAt)
+	if pgstore.IsUniqueViolation(err) {
+		return ErrExpertScoreExists
+	}
+	return pgstore.CheckAffected(tag, err, ErrScoreNotFound)
+}
+
+// DeleteScore removes the score with the given id within the run, or
+// returns ErrScoreNotFound.
+func (s *PostgresScoreStore) DeleteScore(ctx context.Context, runID, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM evaluation_scores WHERE run_id = $1 AND id = $2`, runID, id)
+	return pgstore.CheckAffected(tag, err, ErrScoreNotFound)
+}
+
+// DeleteScoresByRun removes every score of the run. Removing no scores
+// is not an error.
+func (s *PostgresScoreStore) DeleteScoresByRun(ctx context.Context, runID string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM evaluation_scores WHERE run_id = $1`, runID)
+	return err
+}
+
+// CountExpertScores returns the number of 专家评分 records of the (run,
+// indicator) pair, excluding the record excludeID (an empty excludeID
+// excludes nothing).
+func (s *PostgresScoreStore) CountExpertScores(ctx context.Context, runID, indicatorID, excludeID string) (int, error) {
+	var count int
+	err := s.db.QueryRow(ctx, `SELECT count(*) FROM evaluation_scores
+		WHERE run_id = $1 AND indicator_id = $2 AND score_type = $3 AND id <> $4`,
+		runID, indicatorID, ScoreTypeExpert, excludeID).Scan(&count)
+	return count, err
+}
+
+// CountScoresByIndicator returns the number of scores referencing the
+// indicator (the ScoreRefChecker of the indicator service).
+func (s *PostgresScoreStore) CountScoresByIndicator(ctx context.Context, indicatorID string) (int, error) {
+	var count int
+	err := s.db.QueryRow(ctx, `SELECT count(*) FROM evaluation_scores WHERE indicator_id = $1`, indicatorID).Scan(&count)
+	return count, err
+}
+
+func scanScore(row pgstore.Row) (Score, error) {
+	var score Score
+	err := row.Scan(&score.ID, &score.RunID, &score.IndicatorID, &score.ScoreType, &score.Rater, &score.Target,
+		&score.Score, &score.Comment, &score.CreatedBy, &score.CreatedAt, &score.UpdatedAt)
+	return score, err
+}
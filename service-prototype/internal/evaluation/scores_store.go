@@ -7,15 +7,15 @@ import (
 )
 
 // ScoreStore persists the evaluation score records. The prototype ships
-// the in-memory implementation; the interface keeps the service layer
-// independent of the storage backend. The cascade rule of the database
-// (scores are removed with their run) is implemented through the drills
-// service's evaluation-score cleaner hook, which calls
-// DeleteScoresByRun; the expert-score uniqueness rule is enforced by
-// the score service through CountExpertScores; the indicator reference
-// rule is enforced by the indicator service through
-// CountScoresByIndicator (the ScoreRefChecker of the evaluation
-// package — this store is its real reference source).
+// an in-memory and a PostgreSQL implementation; the interface keeps the
+// service layer independent of the storage backend. The cascade rule of
+// the database (scores are removed with their run) is implemented
+// through the drills service's evaluation-score cleaner hook, which
+// calls DeleteScoresByRun; the expert-score uniqueness rule is enforced
+// by the score service through CountExpertScores; the indicator
+// reference rule is enforced by the indicator service through
+// CountScoresByIndicator (the ScoreRefChecker of the evaluation package
+// — this store is its real reference source).
 type ScoreStore interface {
 	CreateScore(ctx context.Context, score Score) error
 	ListScoresByRun(ctx context.Context, runID string, filter ScoreFilter) ([]Score, int, error)
@@ -28,8 +28,8 @@ type ScoreStore interface {
 
 // InMemoryScoreStore keeps the evaluation score rows in an
 // insertion-ordered slice guarded by a mutex. It implements ScoreStore
-// for the prototype and never touches a database; a database-backed
-// store arrives with a later slice. The listing method returns the rows
+// for the prototype and never touches a database; PostgresScoreStore is
+// the database-backed counterpart. The listing method returns the rows
 // in the repository sort order (created_at ASC, id ASC as the
 // deterministic tie-break) and the paginated page. It also implements
 // the evaluation ScoreRefChecker interface (CountScoresByIndicator), so
@@ -26,12 +26,14 @@ type ScoringInput struct {
 // ReportContent is the engine output: the weighted overall score, the
 // per-dimension scores with their breakdowns, the per-indicator final
 // scores with their source values and the rule-based suggestions. It
-// becomes the JSONB snapshot fields of a Report.
+// becomes the JSONB snapshot fields of a Report, together with the
+// dispatch latency block.
 type ReportContent struct {
 	OverallScore    float64
 	DimensionScores map[Dimension]DimensionScore
 	IndicatorScores map[string]IndicatorScore
 	Suggestions     []Suggestion
+	DispatchLatency DispatchLatency
 }
 
 // Suggestion levels of the report rule (改进建议 level 枚举): a
@@ -222,6 +224,39 @@ func earliestOrderIssued(orders []ReportOrder) *time.Time {
 	return &first
 }
 
+// dispatchLatency measures the reception (issued_at → 已接收) and
+// execution (已接收 → 已完成) latency of the orders. An order only counts
+// towards a measure when both of its milestones exist; a negative span
+// (clock skew between writers) counts as zero.
+func dispatchLatency(orders []ReportOrder) DispatchLatency {
+	var reception, execution []float64
+	for _, order := range orders {
+		if order.IssuedAt != nil && order.ReceivedAt != nil {
+			reception = append(reception, math.Max(0, order.ReceivedAt.Sub(*order.IssuedAt).Seconds()))
+		}
+		if order.ReceivedAt != nil && order.CompletedAt != nil {
+			execution = append(execution, math.Max(0, order.CompletedAt.Sub(*order.ReceivedAt).Seconds()))
+		}
+	}
+	return DispatchLatency{Reception: latencyStat(reception), Execution: latencyStat(execution)}
+}
+
+// latencyStat averages the measured durations (in seconds) into a
+// LatencyStat; no durations yield the bare zero count.
+func latencyStat(seconds []float64) LatencyStat {
+	if len(seconds) == 0 {
+		return LatencyStat{}
+	}
+	total := 0.0
+	for _, value := range seconds {
+		total += value
+	}
+	mean := total / float64(len(seconds))
+	rounded := round1(mean)
+	score := latencyScore(mean)
+	return LatencyStat{Count: len(seconds), MeanSeconds: &rounded, Score: &score}
+}
+
 func earliestMessageSent(messages []ReportMessage, senderType string) *time.Time {
 	var values []*time.Time
 	for _, message := range messages {
@@ -439,10 +474,11 @@ func buildSuggestions(dimensionScores map[Dimension]DimensionScore, departmentRe
 // returns the full report content: the per-indicator final scores with
 // their source values, the per-dimension weighted averages with their
 // breakdowns, the weighted overall score and the rule-based
-// suggestions. The engine is deterministic and pure (no store access);
-// the service wraps the content into a Report with the id and the
-// timestamps. Maps are always non-nil, so the empty 演练数据不足 report
-// serializes as {} / [] and never as null.
+// suggestions, plus the dispatch latency block. The engine is
+// deterministic and pure (no store access); the service wraps the
+// content into a Report with the id and the timestamps. Maps are always
+// non-nil, so the empty 演练数据不足 report serializes as {} / [] and
+// never as null.
 func ComposeReport(input ScoringInput) ReportContent {
 	content := ReportContent{
 		DimensionScores: make(map[Dimension]DimensionScore),
@@ -507,5 +543,6 @@ func ComposeReport(input ScoringInput) ReportContent {
 		content.OverallScore = weightedMean(scores, weights)
 	}
 	content.Suggestions = buildSuggestions(content.DimensionScores, input.DepartmentReports)
+	content.DispatchLatency = dispatchLatency(input.Orders)
 	return content
 }
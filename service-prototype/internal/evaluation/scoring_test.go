@@ -130,6 +130,40 @@ func TestAutoScoreLatencyMetrics(t *testing.T) {
 	}
 }
 
+// TestDispatchLatency pins the dispatch latency block: reception =
+// issued_at → 已接收, execution = 已接收 → 已完成, each averaged over the
+// orders with both milestones (mean rounded to 1 decimal, score =
+// latencyScore(mean)); an order missing a milestone is skipped and a
+// measure without orders carries count 0 only. The block never changes
+// the indicator scores (预案启动时间 still reads issued_at alone).
+func TestDispatchLatency(t *testing.T) {
+	base := time.Date(2026, 8, 14, 10, 0, 0, 0, time.UTC)
+	orders := []ReportOrder{
+		{IssuedAt: timestamp(base, 35), ReceivedAt: timestamp(base, 45), CompletedAt: timestamp(base, 145)}, // 接收 10s，执行 100s
+		{IssuedAt: timestamp(base, 40), ReceivedAt: timestamp(base, 61)},                                    // 接收 21s，未完成
+		{IssuedAt: timestamp(base, 50)}, // 未接收
+	}
+	content := ComposeReport(ScoringInput{
+		Run:        ReportRun{ID: "run-1", Status: reportRunStatusCompleted, StartedAt: timestamp(base, 0)},
+		Indicators: fixtureIndicators(),
+		SimEvents:  []ReportSimEvent{{TriggeredAt: timestamp(base, 10), CreatedAt: base}},
+		Orders:     orders,
+	})
+	reception, execution := content.DispatchLatency.Reception, content.DispatchLatency.Execution
+	if reception.Count != 2 || reception.MeanSeconds == nil || *reception.MeanSeconds != 15.5 || *reception.Score != 96.9 {
+		t.Errorf("reception = %+v, want count 2, mean 15.5s, score 96.9", reception)
+	}
+	if execution.Count != 1 || execution.MeanSeconds == nil || *execution.MeanSeconds != 100 || *execution.Score != 80 {
+		t.Errorf("execution = %+v, want count 1, mean 100s, score 80", execution)
+	}
+	assertAutoScore(t, content, idCommandResponse, 95, "预案启动时间: latency(25s)")
+
+	empty := ComposeReport(ScoringInput{Run: ReportRun{ID: "run-2", Status: reportRunStatusCompleted}})
+	if empty.DispatchLatency != (DispatchLatency{}) {
+		t.Errorf("latency without orders = %+v, want the zero block", empty.DispatchLatency)
+	}
+}
+
 // TestAutoScoreCompletenessConformityLinkage asserts the three ratio
 // formulas: 流程执行完整度 = 已执行 ÷ 步骤总数 × 100 (including 待执行/
 // 跳过), 操作标准符合度 = 已执行 ÷ (已执行+跳过) × 100 and 部门联动顺畅
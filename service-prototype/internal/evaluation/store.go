@@ -6,12 +6,12 @@ import (
 	"sync"
 )
 
-// Store persists the evaluation objects. The prototype ships the
-// in-memory implementation; the interface keeps the service layer
-// independent of the storage backend. The reference rule of the database
-// (an indicator referenced by evaluation_scores cannot be deleted) is
-// implemented at the service layer through the injected score-ref
-// checker; the store itself only manages the indicator rows.
+// Store persists the evaluation objects. The prototype ships an
+// in-memory and a PostgreSQL implementation; the interface keeps the
+// service layer independent of the storage backend. The reference rule
+// of the database (an indicator referenced by evaluation_scores cannot
+// be deleted) is implemented at the service layer through the injected
+// score-ref checker; the store itself only manages the indicator rows.
 type Store interface {
 	CreateIndicator(ctx context.Context, indicator Indicator) error
 	ListIndicators(ctx context.Context, filter IndicatorFilter) ([]Indicator, int, error)
@@ -22,8 +22,8 @@ type Store interface {
 
 // InMemoryStore keeps the evaluation indicator rows in an insertion-
 // ordered slice guarded by a mutex. It implements Store for the
-// prototype and never touches a database; a database-backed store
-// arrives with a later slice. The listing method returns the rows in
+// prototype and never touches a database; PostgresStore is the
+// database-backed counterpart. The listing method returns the rows in
 // the repository sort order (dimension, sort_order, created_at
 // ascending, id as the deterministic tie-break) and the paginated page.
 type InMemoryStore struct {
@@ -0,0 +1,187 @@
+package evaluation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/databasetest"
+)
+
+func TestInMemoryStoreContract(t *testing.T) {
+	runIndicatorContract(t, NewInMemoryStore())
+	runScoreContract(t, NewInMemoryStore(), NewInMemoryScoreStore(), func(string) {})
+	runReportContract(t, NewInMemoryReportStore(), func(string) {})
+}
+
+func TestPostgresStoreContract(t *testing.T) {
+	db := databasetest.Open(t)
+	addRun := func(runID string) { databasetest.InsertRun(t, db, runID) }
+	runIndicatorContract(t, NewPostgresStore(db))
+	runScoreContract(t, NewPostgresStore(db), NewPostgresScoreStore(db), addRun)
+	runReportContract(t, NewPostgresReportStore(db), addRun)
+}
+
+// runIndicatorContract pins the indicator Store semantics. The
+// PostgreSQL schema carries the seeded indicators of migration 000023,
+// so counts are taken relative to the rows present beforehand.
+func runIndicatorContract(t *testing.T, store Store) {
+	ctx := context.Background()
+	base := databasetest.Time(2026, 8, 1, 9, 0, 0)
+	_, baseline, err := store.ListIndicators(ctx, IndicatorFilter{Dimension: DimensionRelicSafety, Limit: -1})
+	if err != nil {
+		t.Fatalf("baseline: %v", err)
+	}
+	items := []Indicator{
+		{ID: "ind-b", Dimension: DimensionRelicSafety, Title: "文物转移时长", Weight: 2, SortOrder: -1, CreatedAt: base.Add(time.Minute), UpdatedAt: base.Add(time.Minute)},
+		{ID: "ind-a", Dimension: DimensionRelicSafety, Title: "文物清点", Weight: 1, Demo: true, SortOrder: -1, Description: "演示", CreatedBy: "u1", CreatedAt: base, UpdatedAt: base},
+	}
+	for _, item := range items {
+		if err := store.CreateIndicator(ctx, item); err != nil {
+			t.Fatalf("create %s: %v", item.ID, err)
+		}
+	}
+	got, err := store.GetIndicator(ctx, "ind-a")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	databasetest.AssertSameJSON(t, got, items[1])
+	page, total, err := store.ListIndicators(ctx, IndicatorFilter{Dimension: DimensionRelicSafety, Limit: 2})
+	if err != nil || total != baseline+2 || len(page) != 2 || page[0].ID != "ind-a" || page[1].ID != "ind-b" {
+		t.Fatalf("indicators = %d %v %v, want ind-a, ind-b first", total, page, err)
+	}
+	updated := items[0]
+	updated.Weight = 3
+	if err := store.UpdateIndicator(ctx, updated); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := store.DeleteIndicator(ctx, "ind-b"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := store.UpdateIndicator(ctx, updated); !errors.Is(err, ErrIndicatorNotFound) {
+		t.Fatalf("update deleted = %v, want ErrIndicatorNotFound", err)
+	}
+	if err := store.DeleteIndicator(ctx, "ind-b"); !errors.Is(err, ErrIndicatorNotFound) {
+		t.Fatalf("delete deleted = %v, want ErrIndicatorNotFound", err)
+	}
+}
+
+// runScoreContract pins the ScoreStore semantics: scores are addressed
+// within their run, expert scores are counted per (run, indicator) pair
+// and the indicator reference count spans every run.
+func runScoreContract(t *testing.T, indicators Store, store interface {
+	ScoreStore
+	ScoreRefChecker
+}, addRun func(runID string)) {
+	ctx := context.Background()
+	base := databasetest.Time(2026, 8, 1, 9, 0, 0)
+	addRun("run-a")
+	addRun("run-b")
+	if err := indicators.CreateIndicator(ctx, Indicator{ID: "ind-score", Dimension: DimensionCoordination, Title: "协同评分", Weight: 1, CreatedAt: base, UpdatedAt: base}); err != nil {
+		t.Fatalf("create indicator: %v", err)
+	}
+	scores := []Score{
+		{ID: "sc-1", RunID: "run-a", IndicatorID: "ind-score", ScoreType: ScoreTypeExpert, Rater: "专家甲", Score: 80, CreatedAt: base, UpdatedAt: base},
+		{ID: "sc-2", RunID: "run-a", IndicatorID: "ind-score", ScoreType: ScoreTypeSelf, Rater: "学员", Target: "一组", Score: 70, Comment: "自评", CreatedAt: base.Add(time.Minute), UpdatedAt: base.Add(time.Minute)},
+		{ID: "sc-3", RunID: "run-b", IndicatorID: "ind-score", ScoreType: ScoreTypePeer, Rater: "二组", Target: "一组", Score: 60, CreatedAt: base, UpdatedAt: base},
+	}
+	for _, item := range scores {
+		if err := store.CreateScore(ctx, item); err != nil {
+			t.Fatalf("create %s: %v", item.ID, err)
+		}
+	}
+	got, err := store.GetScore(ctx, "run-a", "sc-2")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	databasetest.AssertSameJSON(t, got, scores[1])
+	page, total, err := store.ListScoresByRun(ctx, "run-a", ScoreFilter{Limit: -1})
+	if err != nil || total != 2 || page[0].ID != "sc-1" {
+		t.Fatalf("scores of run-a = %d %v %v, want sc-1 first", total, page, err)
+	}
+	if count, err := store.CountExpertScores(ctx, "run-a", "ind-score", ""); err != nil || count != 1 {
+		t.Fatalf("expert count = %d %v, want 1", count, err)
+	}
+	if count, err := store.CountExpertScores(ctx, "run-a", "ind-score", "sc-1"); err != nil || count != 0 {
+		t.Fatalf("expert count excluding sc-1 = %d %v, want 0", count, err)
+	}
+	if count, err := store.CountScoresByIndicator(ctx, "ind-score"); err != nil || count != 3 {
+		t.Fatalf("indicator references = %d %v, want 3", count, err)
+	}
+	if _, err := store.GetScore(ctx, "run-b", "sc-1"); !errors.Is(err, ErrScoreNotFound) {
+		t.Fatalf("score of another run = %v, want ErrScoreNotFound", err)
+	}
+	if err := store.DeleteScoresByRun(ctx, "run-a"); err != nil {
+		t.Fatalf("delete by run: %v", err)
+	}
+	if err := store.UpdateScore(ctx, scores[0]); !errors.Is(err, ErrScoreNotFound) {
+		t.Fatalf("update deleted = %v, want ErrScoreNotFound", err)
+	}
+	if err := store.DeleteScore(ctx, "run-b", "sc-3"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := store.DeleteScore(ctx, "run-b", "sc-3"); !errors.Is(err, ErrScoreNotFound) {
+		t.Fatalf("delete deleted = %v, want ErrScoreNotFound", err)
+	}
+}
+
+// runReportContract pins the ReportStore semantics: one report per run,
+// whole-snapshot updates keyed by run and a newest-first listing.
+func runReportContract(t *testing.T, store ReportStore, addRun func(runID string)) {
+	ctx := context.Background()
+	base := databasetest.Time(2026, 8, 1, 9, 0, 0)
+	addRun("run-r1")
+	addRun("run-r2")
+	auto := 75.0
+	meanSeconds, latency := 42.5, 91.5
+	report := Report{
+		ID: "rep-1", RunID: "run-r1", OverallScore: 75.5,
+		DimensionScores: map[Dimension]DimensionScore{DimensionResponseSpeed: {Score: 75.5, Breakdown: map[string]float64{"ind-x": 75.5}}},
+		IndicatorScores: map[string]IndicatorScore{"ind-x": {Score: 75.5, Auto: &auto}},
+		Suggestions:     []Suggestion{{Dimension: DimensionResponseSpeed, Level: "关注", Text: "加快响应"}},
+		DispatchLatency: DispatchLatency{Reception: LatencyStat{Count: 2, MeanSeconds: &meanSeconds, Score: &latency}},
+		CreatedAt:       base, UpdatedAt: base,
+	}
+	if err := store.CreateReport(ctx, report); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := store.CreateReport(ctx, Report{ID: "rep-dup", RunID: "run-r1", CreatedAt: base, UpdatedAt: base}); !errors.Is(err, ErrReportExists) {
+		t.Fatalf("second report of run = %v, want ErrReportExists", err)
+	}
+	got, err := store.GetReportByRun(ctx, "run-r1")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	databasetest.AssertSameJSON(t, got, report)
+
+	report.OverallScore = 80
+	report.Suggestions = []Suggestion{}
+	report.UpdatedAt = base.Add(time.Hour)
+	if err := store.UpdateReport(ctx, report); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	got, err = store.GetReportByRun(ctx, "run-r1")
+	if err != nil {
+		t.Fatalf("get updated: %v", err)
+	}
+	databasetest.AssertSameJSON(t, got, report)
+
+	second := Report{ID: "rep-2", RunID: "run-r2", DimensionScores: map[Dimension]DimensionScore{}, IndicatorScores: map[string]IndicatorScore{}, Suggestions: []Suggestion{}, CreatedAt: base.Add(time.Minute), UpdatedAt: base.Add(time.Minute)}
+	if err := store.CreateReport(ctx, second); err != nil {
+		t.Fatalf("create second: %v", err)
+	}
+	page, total, err := store.ListReports(ctx, ReportFilter{Limit: 1})
+	if err != nil || total != 2 || len(page) != 1 || page[0].ID != "rep-2" {
+		t.Fatalf("first report page = %d %v %v, want newest rep-2", total, page, err)
+	}
+	if err := store.DeleteReportsByRun(ctx, "run-r1"); err != nil {
+		t.Fatalf("delete by run: %v", err)
+	}
+	if _, err := store.GetReportByRun(ctx, "run-r1"); !errors.Is(err, ErrReportNotFound) {
+		t.Fatalf("get deleted = %v, want ErrReportNotFound", err)
+	}
+	if err := store.UpdateReport(ctx, report); !errors.Is(err, ErrReportNotFound) {
+		t.Fatalf("update deleted = %v, want ErrReportNotFound", err)
+	}
+}
@@ -0,0 +1,91 @@
+package examrecords
+
+import (
+	"context"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
+)
+
+// PostgresStore persists exam records in the exam_records table
+// (migration 000008); the exam snapshot is the answers_snapshot JSONB
+// column. It implements Store with the same semantics as the in-memory
+// store: a missing id answers ErrNotFound and listing orders by
+// created_at DESC, id DESC.
+type PostgresStore struct {
+	db pgstore.Querier
+}
+
+// NewPostgresStore returns an exam-record store over the given
+// connection.
+func NewPostgresStore(db pgstore.Querier) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+const recordColumns = `id, employee_id, paper_id, start_time, end_time, score, passed, answers_snapshot,
+	metadata, created_by, created_at, updated_at`
+
+// Create inserts the record.
+func (s *PostgresStore) Create(ctx context.Context, record Record) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO exam_records (`+recordColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		record.ID, record.EmployeeID, record.PaperID, record.StartTime, record.EndTime, record.Score,
+		record.Passed, record.AnswersSnapshot, record.Metadata, record.CreatedBy, record.CreatedAt,
+		record.UpdatedAt)
+	return err
+}
+
+// List returns the records matching the filter newest first, the total
+// number of matches and the paginated page.
+func (s *PostgresStore) List(ctx context.Context, filter Filter) ([]Record, int, error) {
+	const where = ` WHERE ($1 = '' OR employee_id = $1) AND ($2 = '' OR paper_id = $2)`
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM exam_records`+where,
+		filter.EmployeeID, filter.PaperID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+recordColumns+` FROM exam_records`+where+`
+		ORDER BY created_at DESC, id DESC LIMIT $3 OFFSET $4`,
+		filter.EmployeeID, filter.PaperID, pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []Record{}
+	for rows.Next() {
+		record, err := scanRecord(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, record)
+	}
+	return page, total, rows.Err()
+}
+
+// Get returns the record with the given id, or ErrNotFound.
+func (s *PostgresStore) Get(ctx context.Context, id string) (Record, error) {
+	record, err := scanRecord(s.db.QueryRow(ctx, `SELECT `+recordColumns+` FROM exam_records WHERE id = $1`, id))
+	if pgstore.IsNoRows(err) {
+		return Record{}, ErrNotFound
+	}
+	return record, err
+}
+
+// Update replaces the record with the same id (used by submission to
+// write end_time/score/passed), or returns ErrNotFound.
+func (s *PostgresStore) Update(ctx context.Context, record Record) error {
+	tag, err := s.db.Exec(ctx, `UPDATE exam_records SET employee_id = $2, paper_id = $3, start_time = $4,
+		end_time = $5, score = $6, passed = $7, answers_snapshot = $8, metadata = $9, created_by = $10,
+		created_at = $11, updated_at = $12 WHERE id = $1`,
+		record.ID, record.EmployeeID, record.PaperID, record.StartTime, record.EndTime, record.Score,
+		record.Passed, record.AnswersSnapshot, record.Metadata, record.CreatedBy, record.CreatedAt,
+		record.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrNotFound)
+}
+
+func scanRecord(row pgstore.Row) (Record, error) {
+	var record Record
+	err := row.Scan(&record.ID, &record.EmployeeID, &record.PaperID, &record.StartTime, &record.EndTime,
+		&record.Score, &record.Passed, &record.AnswersSnapshot, &record.Metadata, &record.CreatedBy,
+		&record.CreatedAt, &record.UpdatedAt)
+	return record, err
+}
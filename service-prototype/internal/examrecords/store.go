@@ -6,12 +6,12 @@ import (
 	"sync"
 )
 
-// Store persists exam records. The prototype ships the in-memory
-// implementation; the interface keeps the routing and service layers
-// independent of the storage backend. Submission mutates a record in
-// place through Update (end_time/score/passed), so a record is created
-// once by Create and replaced by Update; there is no delete in the card
-// scope.
+// Store persists exam records. The prototype ships an in-memory and a
+// PostgreSQL implementation; the interface keeps the routing and service
+// layers independent of the storage backend. Submission mutates a record
+// in place through Update (end_time/score/passed), so a record is
+// created once by Create and replaced by Update; there is no delete in
+// the card scope.
 type Store interface {
 	Create(ctx context.Context, record Record) error
 	List(ctx context.Context, filter Filter) ([]Record, int, error)
@@ -20,8 +20,8 @@ type Store interface {
 }
 
 // InMemoryStore keeps exam records in a slice guarded by a mutex. It
-// implements Store for the prototype and never touches a database; a
-// database-backed store arrives with a later slice.
+// implements Store for the prototype and never touches a database;
+// PostgresStore is the database-backed counterpart.
 type InMemoryStore struct {
 	mu    sync.Mutex
 	items []Record
@@ -0,0 +1,86 @@
+package examrecords
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/databasetest"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/questions"
+)
+
+func TestInMemoryStoreContract(t *testing.T) {
+	runStoreContract(t, NewInMemoryStore(), func(string) {})
+}
+
+func TestPostgresStoreContract(t *testing.T) {
+	db := databasetest.Open(t)
+	runStoreContract(t, NewPostgresStore(db), func(paperID string) {
+		databasetest.Exec(t, db, `INSERT INTO exam_papers (id, title, duration_minutes, pass_score, generation_strategy)
+			VALUES ($1, $1, 30, 60, '{"单选":1}')`, paperID)
+	})
+}
+
+// runStoreContract pins the Store semantics every backend shares: the
+// snapshot with string and array answers survives a round trip, the
+// submission update writes the nullable result fields, listing filters
+// and is newest first, and a missing id answers ErrNotFound. The
+// addPaper hook creates the parent paper the database foreign key
+// requires; the in-memory store needs none.
+func runStoreContract(t *testing.T, store Store, addPaper func(paperID string)) {
+	ctx := context.Background()
+	addPaper("paper-1")
+	addPaper("paper-2")
+	base := databasetest.Time(2026, 8, 7, 9, 0, 0)
+	snapshot := Snapshot{PaperID: "paper-1", PassScore: 1, Questions: []QuestionSnapshot{
+		{ID: "q1", Type: questions.QuestionTypeSingle, Difficulty: 1, Content: "单选", Options: []string{"A", "B"}, Answer: "B"},
+		{ID: "q2", Type: questions.QuestionTypeMultiple, Difficulty: 2, Content: "多选", Options: []string{"A", "B", "C"}, Answer: []any{"A", "C"}},
+	}}
+	items := []Record{
+		{ID: "r-1", EmployeeID: "e1", PaperID: "paper-1", StartTime: base, AnswersSnapshot: snapshot, Metadata: map[string]any{}, CreatedBy: "u1", CreatedAt: base, UpdatedAt: base},
+		{ID: "r-2", EmployeeID: "e2", PaperID: "paper-1", StartTime: base, AnswersSnapshot: snapshot, Metadata: map[string]any{}, CreatedAt: base.Add(time.Minute), UpdatedAt: base.Add(time.Minute)},
+		{ID: "r-3", EmployeeID: "e1", PaperID: "paper-2", StartTime: base, AnswersSnapshot: Snapshot{PaperID: "paper-2", Questions: []QuestionSnapshot{}}, Metadata: map[string]any{"room": "A"}, CreatedAt: base.Add(2 * time.Minute), UpdatedAt: base.Add(2 * time.Minute)},
+	}
+	for _, item := range items {
+		if err := store.Create(ctx, item); err != nil {
+			t.Fatalf("create %s: %v", item.ID, err)
+		}
+	}
+
+	submitted := items[0]
+	endTime := base.Add(20 * time.Minute)
+	score, passed := 2, true
+	submitted.EndTime, submitted.Score, submitted.Passed = &endTime, &score, &passed
+	submitted.UpdatedAt = endTime
+	if err := store.Update(ctx, submitted); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	got, err := store.Get(ctx, "r-1")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	databasetest.AssertSameJSON(t, got, submitted)
+
+	page, total, err := store.List(ctx, Filter{EmployeeID: "e1", Limit: -1})
+	if err != nil {
+		t.Fatalf("list by employee: %v", err)
+	}
+	if total != 2 || len(page) != 2 || page[0].ID != "r-3" || page[1].ID != "r-1" {
+		t.Fatalf("e1 records = %d %v, want r-3, r-1", total, page)
+	}
+	page, total, err = store.List(ctx, Filter{PaperID: "paper-1", Limit: 1, Offset: 1})
+	if err != nil {
+		t.Fatalf("list by paper: %v", err)
+	}
+	if total != 2 || len(page) != 1 || page[0].ID != "r-1" {
+		t.Fatalf("paper-1 page = %d %v, want total 2 and r-1", total, page)
+	}
+
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get missing = %v, want ErrNotFound", err)
+	}
+	if err := store.Update(ctx, Record{ID: "missing", PaperID: "paper-1", Metadata: map[string]any{}}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("update missing = %v, want ErrNotFound", err)
+	}
+}
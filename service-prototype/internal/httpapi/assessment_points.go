@@ -54,9 +54,10 @@ func (h *assessmentPointsHandler) handleItem(w http.ResponseWriter, r *http.Requ
 }
 
 // pointBody mirrors the client-supplied fields of the request body.
-// scenario_id is never part of the body: it is decided by the route
-// path. description is optional (default ”); created_by is optional
-// (empty when omitted) because the prototype has no auth context.
+// scenario_id is never part of the body: it is decided by the route path.
+// description is optional (default ”); created_by is optional (empty when
+// omitted; the authenticated subject replaces it when the router
+// authenticates).
 type pointBody struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
@@ -83,7 +84,7 @@ func (h *assessmentPointsHandler) create(w http.ResponseWriter, r *http.Request)
 	point, err := h.service.CreatePoint(r.Context(), r.PathValue("sid"), drills.PointInput{
 		Title:       body.Title,
 		Description: body.Description,
-		CreatedBy:   body.CreatedBy,
+		CreatedBy:   requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeDrillChildError(w, err)
@@ -126,10 +127,18 @@ func (h *assessmentPointsHandler) update(w http.ResponseWriter, r *http.Request)
 	if !ok {
 		return
 	}
+	createdBy, err := storedCreatedBy(r, body.CreatedBy, func() (string, error) {
+		stored, err := h.service.GetPoint(r.Context(), r.PathValue("id"))
+		return stored.CreatedBy, err
+	})
+	if err != nil {
+		writeDrillChildError(w, err)
+		return
+	}
 	point, err := h.service.UpdatePoint(r.Context(), r.PathValue("id"), drills.PointInput{
 		Title:       body.Title,
 		Description: body.Description,
-		CreatedBy:   body.CreatedBy,
+		CreatedBy:   createdBy,
 	})
 	if err != nil {
 		writeDrillChildError(w, err)
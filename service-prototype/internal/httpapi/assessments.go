@@ -101,7 +101,7 @@ func (h *assessmentHandler) upsert(w http.ResponseWriter, r *http.Request) {
 	assessment, err := h.service.UpsertAssessment(r.Context(), r.PathValue("rid"), r.PathValue("pointId"), drills.AssessmentInput{
 		Score:     *body.Score,
 		Comment:   body.Comment,
-		CreatedBy: body.CreatedBy,
+		CreatedBy: requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeAssessmentError(w, err)
@@ -52,9 +52,9 @@ func (h *assignmentsHandler) handleItem(w http.ResponseWriter, r *http.Request)
 
 // assignmentBody mirrors the client-supplied fields of the request body.
 // trigger_rule is captured raw so an omitted field (default {}) can be
-// told apart from an explicit JSON null (rejected); created_by is
-// optional (empty when omitted) because the prototype has no auth
-// context.
+// told apart from an explicit JSON null (rejected); created_by is optional
+// (empty when omitted; the authenticated subject replaces it when the
+// router authenticates).
 type assignmentBody struct {
 	CourseID    string          `json:"course_id"`
 	AssignType  string          `json:"assign_type"`
@@ -114,7 +114,7 @@ func (h *assignmentsHandler) create(w http.ResponseWriter, r *http.Request) {
 		Deadline:    body.Deadline,
 		TargetType:  assignments.TargetType(body.TargetType),
 		TargetIDs:   body.TargetIDs,
-		CreatedBy:   body.CreatedBy,
+		CreatedBy:   requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeAssignmentError(w, err)
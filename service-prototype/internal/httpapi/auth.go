@@ -0,0 +1,221 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/auth"
+)
+
+// routeRule is the role policy of one route pattern: any principal
+// holding a prototype role may read (GET/HEAD), only the writers may call
+// the other methods.
+type routeRule struct {
+	writers []auth.Role
+}
+
+// Role groups of the policy.
+var (
+	directorOnly        = []auth.Role{auth.RoleDirector}
+	directorOrResponder = []auth.Role{auth.RoleDirector, auth.RoleResponder}
+	directorOrTrainee   = []auth.Role{auth.RoleDirector, auth.RoleTrainee}
+	evaluatorOnly       = []auth.Role{auth.RoleEvaluator}
+	evaluatorOrDirector = []auth.Role{auth.RoleEvaluator, auth.RoleDirector}
+)
+
+// routePolicy maps the write routes of the API to the roles allowed to
+// call them. The patterns are the route patterns of NewMux (without
+// their method), matched by a ServeMux of their own so a request resolves
+// to the same route in both. Routes without a rule (the read-only ones)
+// may only be read; any other method on them answers 403.
+//
+//   - training content, drill scenarios and their timeline, the runs,
+//     their scripted injects, sim events, step records, command session,
+//     orders and the opinion exercise: 演练导演 (director);
+//   - learning progress and exams: 受训学员 (trainee) or director;
+//   - field reports (departments, zone densities, devices, messages) and
+//     order feedback: 现场处置人员 (responder) or director; which of the
+//     two may send a message further depends on its sender_type (see
+//     messagesHandler.create);
+//   - assessments and evaluation scores: 评估专家 (evaluator); report
+//     generation: evaluator or director.
+func routePolicy() map[string]routeRule {
+	rules := map[string][]auth.Role{
+		coursesBase:                          directorOnly,
+		coursesBase + "/{id}":                directorOnly,
+		coursesBase + "/{courseId}/chapters": directorOnly,
+		chaptersBase + "/{id}":               directorOnly,
+		questionsBase:                        directorOnly,
+		questionsBase + "/{id}":              directorOnly,
+		questionsBase + "/import":            directorOnly,
+		assignmentsBase:                      directorOnly,
+		assignmentsBase + "/{id}":            directorOnly,
+		papersBase:                           directorOnly,
+		papersBase + "/{id}":                 directorOnly,
+		papersBase + "/{id}/generate":        directorOnly,
+		assignmentsBase + "/{aid}/employees/{eid}/progress/chapters/{cid}": directorOrTrainee,
+		assignmentsBase + "/{aid}/employees/{eid}/complete":                directorOrTrainee,
+		examRecordsBase:                            directorOrTrainee,
+		examRecordsBase + "/{id}":                  directorOrTrainee,
+		examRecordsBase + "/{id}/submit":           directorOrTrainee,
+		scenariosBase:                              directorOnly,
+		scenariosBase + "/{id}":                    directorOnly,
+		scenariosBase + "/{sid}/steps":             directorOnly,
+		stepsBase + "/{id}":                        directorOnly,
+		scenariosBase + "/{sid}/assessment-points": directorOnly,
+		assessmentPointsBase + "/{id}":             directorOnly,
+		scenariosBase + "/{sid}/injects":           directorOnly,
+		injectsBase + "/{id}":                      directorOnly,
+		runsBase:                                   directorOnly,
+		runsBase + "/{id}":                         directorOnly,
+		runsBase + "/{id}/start":                   directorOnly,
+		runsBase + "/{id}/complete":                directorOnly,
+		runsBase + "/{id}/terminate":               directorOnly,
+		runInjectsBase:                             directorOnly,
+		runInjectsBase + "/{iid}":                  directorOnly,
+		runInjectsBase + "/{iid}/cancel":           directorOnly,
+		stepRecordsBase:                            directorOnly,
+		stepRecordsBase + "/{stepId}":              directorOnly,
+		simEventsBase:                              directorOnly,
+		simEventsBase + "/{eid}":                   directorOnly,
+		commandSessionBase:                         directorOnly,
+		opinionEventBase:                           directorOnly,
+		opinionPostsBase:                           directorOnly,
+		opinionPostsBase + "/{pid}":                directorOnly,
+		opinionReleasesBase:                        directorOnly,
+		opinionReleasesBase + "/{lid}":             directorOnly,
+		opinionMediaQuestionsBase:                  directorOnly,
+		opinionMediaQuestionsBase + "/{mqid}":      directorOnly,
+		opinionComplaintsBase:                      directorOnly,
+		opinionComplaintsBase + "/{cid}":           directorOnly,
+		opinionReviewBase:                          directorOnly,
+		ordersBase:                                 directorOnly,
+		ordersBase + "/{oid}":                      directorOrResponder,
+		departmentsBase:                            directorOrResponder,
+		departmentsBase + "/{department}":          directorOrResponder,
+		messagesBase:                               directorOrResponder,
+		messagesBase + "/{mid}":                    directorOnly,
+		zonesBase:                                  directorOrResponder,
+		zonesBase + "/{zid}":                       directorOrResponder,
+		devicesBase:                                directorOrResponder,
+		devicesBase + "/{did}":                     directorOrResponder,
+		assessmentsBase:                            evaluatorOnly,
+		assessmentsBase + "/{pointId}":             evaluatorOnly,
+		indicatorsBase:                             directorOnly,
+		indicatorsBase + "/{id}":                   directorOnly,
+		scoresBase:                                 evaluatorOnly,
+		scoresBase + "/{sid}":                      evaluatorOnly,
+		reportsGenerateBase:                        evaluatorOrDirector,
+	}
+	policy := make(map[string]routeRule, len(rules))
+	for pattern, writers := range rules {
+		policy[pattern] = routeRule{writers: writers}
+	}
+	return policy
+}
+
+// authMiddleware authenticates every request except the public ones
+// (CORS preflights, the health endpoint and the embedded static assets)
+// and enforces routePolicy. The principal is stored in the request
+// context, where the handlers read it through requestActor and
+// requireRole. A request without credentials, or with a rejected one,
+// answers 401; a principal holding no prototype role at all, lacking
+// the roles of a write route, or writing through a browser session
+// without its CSRF token, answers 403; an identity provider that cannot
+// be reached answers 503.
+func authMiddleware(next http.Handler, authenticator auth.Authenticator) http.Handler {
+	policy := routePolicy()
+	matcher := http.NewServeMux()
+	for pattern := range policy {
+		matcher.HandleFunc(pattern, http.NotFound)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isPublicRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		principal, err := authenticator.Authenticate(r)
+		switch {
+		case errors.Is(err, auth.ErrNoCredentials), errors.Is(err, auth.ErrUnauthenticated):
+			writeError(w, http.StatusUnauthorized, "not authenticated")
+			return
+		case errors.Is(err, auth.ErrInvalidCSRFToken):
+			writeError(w, http.StatusForbidden, "invalid CSRF token")
+			return
+		case err != nil:
+			writeError(w, http.StatusServiceUnavailable, "identity provider unavailable")
+			return
+		}
+		if !principal.HasPrototypeRole() {
+			writeError(w, http.StatusForbidden, "forbidden")
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			// A write to a route without a rule is denied, so a route
+			// added to NewMux but forgotten here fails closed.
+			_, pattern := matcher.Handler(r)
+			if pattern == "" || !principal.HasAnyRole(policy[pattern].writers...) {
+				writeError(w, http.StatusForbidden, "forbidden")
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// isPublicRequest reports whether the request is served without
+// authentication: OPTIONS (the CORS preflights carry no credentials),
+// the health endpoint (probed by the orchestrator) and the embedded
+// static assets the pages load.
+func isPublicRequest(r *http.Request) bool {
+	return r.Method == http.MethodOptions ||
+		r.URL.Path == prototypePrefix+"/healthz" ||
+		strings.HasPrefix(r.URL.Path, "/static/")
+}
+
+// requestActor returns the subject of the authenticated principal of
+// the request, or fallback (the value of the request body) on a router
+// running without authentication. It fills created_by, performed_by,
+// rater and the status-history actor, so an authenticated client can
+// no longer write them in someone else's name.
+func requestActor(r *http.Request, fallback string) string {
+	if principal, ok := auth.PrincipalFrom(r.Context()); ok {
+		return principal.Subject
+	}
+	return fallback
+}
+
+// storedCreatedBy returns the created_by a full-replacement update
+// writes back: the body value on a router running without
+// authentication, otherwise the value already stored (read through
+// stored), so a PUT never reassigns who created the object.
+func storedCreatedBy(r *http.Request, fallback string, stored func() (string, error)) (string, error) {
+	if _, ok := auth.PrincipalFrom(r.Context()); !ok {
+		return fallback, nil
+	}
+	return stored()
+}
+
+// requireRole answers 403 and returns false when the request carries a
+// principal holding none of the roles. It covers the checks that depend
+// on the request body and so cannot live in routePolicy; a router
+// running without authentication passes every check.
+func requireRole(w http.ResponseWriter, r *http.Request, roles ...auth.Role) bool {
+	principal, ok := auth.PrincipalFrom(r.Context())
+	if !ok || principal.HasAnyRole(roles...) {
+		return true
+	}
+	writeError(w, http.StatusForbidden, "forbidden")
+	return false
+}
+
+// patchCreatedBy returns the created_by of a partial update: the body
+// value on a router running without authentication, otherwise empty,
+// which keeps the stored value.
+func patchCreatedBy(r *http.Request, value string) string {
+	if _, ok := auth.PrincipalFrom(r.Context()); ok {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,204 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/assignments"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/auth"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/chapters"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/courses"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/dispatch"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/drills"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/evaluation"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/examrecords"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/opinion"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/papers"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/progress"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/questions"
+)
+
+// tokenTable is a stub authenticator: the bearer token names the
+// principal directly, an unknown token is rejected and a request without
+// one carries no credentials. The token "no-csrf" stands for a browser
+// session writing without its CSRF token.
+type tokenTable map[string]auth.Principal
+
+func (table tokenTable) Authenticate(r *http.Request) (auth.Principal, error) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return auth.Principal{}, auth.ErrNoCredentials
+	}
+	if token == "no-csrf" {
+		return auth.Principal{}, auth.ErrInvalidCSRFToken
+	}
+	principal, ok := table[token]
+	if !ok {
+		return auth.Principal{}, auth.ErrUnauthenticated
+	}
+	return principal, nil
+}
+
+// testTokens holds one principal per prototype role.
+var testTokens = tokenTable{
+	"director":  {Subject: "subject-director", Roles: []auth.Role{auth.RoleDirector}},
+	"responder": {Subject: "subject-responder", Roles: []auth.Role{auth.RoleResponder}},
+	"evaluator": {Subject: "subject-evaluator", Roles: []auth.Role{auth.RoleEvaluator}},
+	"trainee":   {Subject: "subject-trainee", Roles: []auth.Role{auth.RoleTrainee}},
+	"outsider":  {Subject: "subject-outsider", Roles: []auth.Role{"crm.viewer"}},
+}
+
+// testAuthMux is testMux with the stub authenticator wired in.
+func testAuthMux() http.Handler {
+	drillStore := drills.NewInMemoryStore()
+	return NewMux(nil, courses.NewInMemoryStore(), chapters.NewInMemoryStore(), questions.NewInMemoryStore(), assignments.NewInMemoryStore(), progress.NewInMemoryStore(), papers.NewInMemoryStore(), examrecords.NewInMemoryStore(), drillStore, dispatch.NewInMemoryStore(), opinion.NewInMemoryStore(), evaluation.NewInMemoryStore(), evaluation.NewInMemoryScoreStore(), evaluation.NewInMemoryReportStore(), drills.NewTimelineService(drills.NewInMemoryTimelineStore(), drillStore), testTokens)
+}
+
+// as sends every request through handler with the bearer token of the
+// given test principal, so the request helpers of the other tests run
+// under that role.
+func as(handler http.Handler, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set("Authorization", "Bearer "+token)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// 认证开启后：healthz、静态资源与 CORS 预检无需凭据；其余路由（含读接口
+// 与演示页）无凭据或凭据被拒绝时 401 JSON；读接口需要任一 prototype 角色。
+func TestAuthRequiresCredentialsExceptPublicRoutes(t *testing.T) {
+	handler := testAuthMux()
+	if recorder := get(handler, "/crate-api/prototype/v1/healthz", nil); recorder.Code != http.StatusOK {
+		t.Fatalf("healthz: status = %d, want 200", recorder.Code)
+	}
+	preflight := httptest.NewRequest(http.MethodOptions, runsBase, nil)
+	preflight.Header.Set("Origin", "https://a.example")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, preflight)
+	if recorder.Code == http.StatusUnauthorized {
+		t.Fatalf("preflight: status = 401, want it to bypass authentication")
+	}
+	for _, target := range []string{runsBase, scenariosBase, "/demo/command"} {
+		recorder := get(handler, target, nil)
+		if recorder.Code != http.StatusUnauthorized {
+			t.Fatalf("GET %s without credentials: status = %d, want 401", target, recorder.Code)
+		}
+		if message := decodeError(t, recorder); message != "not authenticated" {
+			t.Fatalf("GET %s: error = %q", target, message)
+		}
+		recorder = get(handler, target, map[string]string{"Authorization": "Bearer forged"})
+		if recorder.Code != http.StatusUnauthorized {
+			t.Fatalf("GET %s with a rejected token: status = %d, want 401", target, recorder.Code)
+		}
+	}
+	if recorder := get(as(handler, "trainee"), runsBase, nil); recorder.Code != http.StatusOK {
+		t.Fatalf("GET runs as trainee: status = %d, want 200 (reads need any prototype role)", recorder.Code)
+	}
+	if recorder := get(as(handler, "outsider"), runsBase, nil); recorder.Code != http.StatusForbidden {
+		t.Fatalf("GET runs without a prototype role: status = %d, want 403", recorder.Code)
+	}
+}
+
+// 写接口按角色组放行：演练导演创建场景与演练；学员/评估专家创建演练 403；
+// created_by 取认证主体（请求体中的值被覆盖），PUT 全量更新不改写创建者。
+func TestAuthDirectorWritesAndCreatedByFromSubject(t *testing.T) {
+	handler := testAuthMux()
+	director := as(handler, "director")
+	scenario := createScenario(t, director, validScenarioBody)
+	for _, token := range []string{"trainee", "evaluator", "responder"} {
+		recorder := do(as(handler, token), http.MethodPost, runsBase, fmt.Sprintf(`{"scenario_id":%q,"title":"演练"}`, scenario.ID))
+		if recorder.Code != http.StatusForbidden {
+			t.Fatalf("POST run as %s: status = %d, want 403", token, recorder.Code)
+		}
+	}
+	run := createRun(t, director, scenario.ID, fmt.Sprintf(`{"scenario_id":%q,"title":"演练","created_by":"冒名者"}`, scenario.ID))
+	if run.CreatedBy != "subject-director" {
+		t.Fatalf("created_by = %q, want the authenticated subject", run.CreatedBy)
+	}
+	recorder := do(director, http.MethodPut, runsBase+"/"+run.ID, fmt.Sprintf(`{"scenario_id":%q,"title":"改名","created_by":"冒名者"}`, scenario.ID))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("PUT run: status = %d, body = %s", recorder.Code, recorder.Body.String())
+	}
+	if updated := decodeRun(t, recorder); updated.CreatedBy != "subject-director" || updated.Title != "改名" {
+		t.Fatalf("PUT run: title = %q, created_by = %q; want the creator kept", updated.Title, updated.CreatedBy)
+	}
+}
+
+// 没有规则的路由只能读：导演对它们的写请求也是 403；缺少 CSRF 令牌的
+// 会话写请求是 403 而不是 401。
+func TestAuthDeniesWritesToRoutesWithoutRule(t *testing.T) {
+	handler := testAuthMux()
+	director := as(handler, "director")
+	for _, target := range []string{prototypePrefix + "/demo-fragment", "/demo/command", "/no-such-route"} {
+		for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+			if recorder := do(director, method, target, `{}`); recorder.Code != http.StatusForbidden {
+				t.Fatalf("%s %s as director: status = %d, want 403", method, target, recorder.Code)
+			}
+		}
+	}
+	if recorder := get(director, "/demo/command", nil); recorder.Code != http.StatusOK {
+		t.Fatalf("GET /demo/command as director: status = %d, want 200", recorder.Code)
+	}
+	recorder := do(as(handler, "no-csrf"), http.MethodPost, scenariosBase, validScenarioBody)
+	if recorder.Code != http.StatusForbidden || decodeError(t, recorder) != "invalid CSRF token" {
+		t.Fatalf("POST scenario without CSRF token: status = %d, body = %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+// 仅评估专家可写评分：PUT/POST /evaluation/runs/{rid}/scores 对导演与学员
+// 403；评估专家写入时 rater 与 created_by 取认证主体。
+func TestAuthOnlyEvaluatorsWriteScores(t *testing.T) {
+	handler := testAuthMux()
+	runID, indicatorID := scoreFixture(t, as(handler, "director"))
+	for _, token := range []string{"director", "trainee", "responder"} {
+		if recorder := do(as(handler, token), http.MethodPost, scoresPath(runID), expertScoreBody(indicatorID)); recorder.Code != http.StatusForbidden {
+			t.Fatalf("POST score as %s: status = %d, want 403", token, recorder.Code)
+		}
+	}
+	score := postScore(t, as(handler, "evaluator"), runID, expertScoreBody(indicatorID))
+	if score.Rater != "subject-evaluator" || score.CreatedBy != "subject-evaluator" {
+		t.Fatalf("rater = %q, created_by = %q; want the authenticated subject", score.Rater, score.CreatedBy)
+	}
+	update := `{"score_type":"专家评分","score":80,"comment":"复核"}`
+	if recorder := do(as(handler, "director"), http.MethodPut, scoreItemPath(runID, score.ID), update); recorder.Code != http.StatusForbidden {
+		t.Fatalf("PUT score as director: status = %d, want 403", recorder.Code)
+	}
+	recorder := do(as(handler, "evaluator"), http.MethodPut, scoreItemPath(runID, score.ID), update)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("PUT score as evaluator: status = %d, body = %s", recorder.Code, recorder.Body.String())
+	}
+	if updated := decodeScore(t, recorder); updated.Score != 80 || updated.CreatedBy != "subject-evaluator" {
+		t.Fatalf("PUT score: score = %d, created_by = %q", updated.Score, updated.CreatedBy)
+	}
+}
+
+// 消息按 sender_type 区分发送方：现场人员消息仅现场处置人员可发，指挥中心
+// 消息仅演练导演可发；评估专家两者皆不可（路由组 403）。
+func TestAuthMessageSenderTypeNeedsMatchingRole(t *testing.T) {
+	handler := testAuthMux()
+	run := mustCreateInProgressRun(t, as(handler, "director"), validScenarioBody)
+	field := `{"sender_type":"现场人员","sender_name":"南门岗","content":"收到"}`
+	command := `{"sender_type":"指挥中心","content":"增开安检通道"}`
+	for _, tc := range []struct {
+		token, body string
+		want        int
+	}{
+		{"responder", field, http.StatusCreated},
+		{"director", field, http.StatusForbidden},
+		{"director", command, http.StatusCreated},
+		{"responder", command, http.StatusForbidden},
+		{"evaluator", field, http.StatusForbidden},
+		{"responder", `{"sender_type":"路人","content":"x"}`, http.StatusBadRequest},
+	} {
+		recorder := do(as(handler, tc.token), http.MethodPost, messagesPath(run.ID), tc.body)
+		if recorder.Code != tc.want {
+			t.Fatalf("POST %s as %s: status = %d, want %d; body = %s", tc.body, tc.token, recorder.Code, tc.want, recorder.Body.String())
+		}
+		if tc.want == http.StatusCreated && !strings.Contains(recorder.Body.String(), `"created_by":"subject-`+tc.token+`"`) {
+			t.Fatalf("POST as %s: body %s lacks the subject as created_by", tc.token, recorder.Body.String())
+		}
+	}
+}
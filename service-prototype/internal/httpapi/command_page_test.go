@@ -4,77 +4,101 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"regexp"
 	"strings"
 	"testing"
 )
 
 // ─── #54 指挥中心大屏页 ──────────────────────────────────────
 
-// commandPageULIDPattern is the 26-character Crockford Base32 ULID shape
-// every id rendered by the page must carry (the run id anchoring the
-// hx-get refresh URLs). The page itself never constructs ids.
-var commandPageULIDPattern = regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`)
+// seedDispatchPageRun starts a run and writes one object of each
+// dispatch kind through the JSON API: a 远程协同 session, a high-heat
+// zone, a 告警 device, a 消防 department report, a field message and an
+// order. Both dispatch pages render exactly these rows.
+func seedDispatchPageRun(t *testing.T, handler http.Handler) runJSON {
+	t.Helper()
+	run := mustCreateInProgressRun(t, handler, validScenarioBody)
+	putSession(t, handler, run.ID, `{"mode":"远程协同","main_venue":"主馆一层大厅","joint_venues":["东区联训馆","西区联训馆"]}`)
+	reportZoneDensity(t, handler, run.ID, `{"zone_name":"C区南门通道","people_count":960}`)
+	reportDevice(t, handler, run.ID, `{"device_name":"东区烟感探测器","device_type":"消防","status":"告警","note":"烟雾浓度超限"}`)
+	putDepartment(t, handler, run.ID, "消防", `{}`)
+	putDepartment(t, handler, run.ID, "消防", `{"status":"已响应","note":"消防增援分队出发"}`)
+	sendMessage(t, handler, run.ID, `{"sender_type":"现场人员","sender_name":"南门岗","content":"收到，已增开一条安检通道"}`)
+	createOrder(t, handler, run.ID, `{"title":"增开安检通道","content":"南门增开一条安检通道，缓解排队","priority":"特急","target_type":"部门","target_name":"安保部","deadline":"2026-08-03T14:30:00Z"}`)
+	return run
+}
 
-// GET /demo/command 返回 200 且 Content-Type 为 text/html；页面含「指挥
-// 中心大屏」标题、六大监控区块（会话配置、区域热力、设备状态、部门联动、
-// 消息流、指令列表）与三维场馆地图静态示意区，内容由 handler 注入的内存
-// 演示数据渲染（实训方式/设备三态/指令四态/部门状态都在，空数据或错数据
-// 不得通过）。
-func TestCommandPageServesHTML(t *testing.T) {
-	recorder := get(testMux(nil), "/demo/command", nil)
+// GET /demo/command?run={rid} 返回 200 text/html；页面含「指挥中心大屏」
+// 标题、六大监控区块与三维场馆地图静态示意区，内容来自该 run 经 API 写入
+// 的会话、热力、设备、部门、消息与指令（不再是内置演示数据）；演练选择器
+// 列出该 run 且处于选中状态，页面订阅该 run 的 SSE 流。
+func TestCommandPageRendersSelectedRun(t *testing.T) {
+	handler := testMux(nil)
+	run := seedDispatchPageRun(t, handler)
+	recorder := get(handler, "/demo/command?run="+run.ID, nil)
 	if recorder.Code != http.StatusOK {
-		t.Fatalf("status = %d, want 200", recorder.Code)
+		t.Fatalf("status = %d, want 200; body = %s", recorder.Code, recorder.Body.String())
 	}
 	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
 		t.Fatalf("Content-Type = %q, want text/html", contentType)
 	}
 	body := recorder.Body.String()
 	for _, text := range []string{
-		"指挥中心大屏", "三维场馆地图", "会话配置", "区域热力", "设备状态", "部门联动", "消息流", "指令列表",
-		"远程协同", "主馆一层大厅", "东区联训馆", "西区联训馆",
-		"A区东侧展厅", "B区中央大厅", "C区南门通道",
-		"正常", "告警", "离线", "烟雾浓度超限",
-		"未响应", "已响应", "已到位", "处置中",
-		"待接收", "已接收", "执行中", "已完成",
+		"指挥中心大屏", "演练选择", "三维场馆地图", "会话配置", "区域热力", "设备状态", "部门联动", "消息流", "指令列表",
+		"远程协同", "主馆一层大厅", "东区联训馆、西区联训馆",
+		"C区南门通道", "zone-high",
+		"东区烟感探测器", "device-warning", "烟雾浓度超限",
+		"消防", "已响应", "消防增援分队出发",
+		"现场人员 · 南门岗", "收到，已增开一条安检通道",
+		"增开安检通道", "特急", "部门 · 安保部", "待接收",
+		`<option value="` + run.ID + `" selected>大客流疏散演练执行（进行中）</option>`,
+		`data-run-stream="/crate-api/prototype/v1/drills/` + run.ID + `/stream"`,
+		`hx-get="/demo/command?run=` + run.ID + `"`,
 	} {
 		if !strings.Contains(body, text) {
-			t.Fatalf("page body does not contain %q (demo data not injected)", text)
+			t.Fatalf("page body does not contain %q", text)
 		}
 	}
+	if strings.Contains(body, "请选择演练") || strings.Contains(body, "A区东侧展厅") {
+		t.Fatalf("page of a selected run renders the picker hint or the former demo data")
+	}
 }
 
-// 每次渲染服务端铸造新的 26 位 Crockford Base32 ULID（hx-get 刷新动作的
-// rid 锚点）：两次渲染的 rid 都符合正则且互不相同；页面出现的所有 id 均
-// 为 26 位 ULID，且页面无任何 id 构造输入（无 name="id" 输入项）。
-func TestCommandPageMintsFreshULIDPerRender(t *testing.T) {
+// 未带 run 参数：200，仅渲染演练选择器（列出全部 run）与「请选择演练」提
+// 示，不订阅任何 SSE 流；run 不存在：404 text/html，选择器 + 「演练不存
+// 在」提示；未配置会话的 run 也能渲染（空会话、空列表）。
+func TestCommandPageRunPickerAndUnknownRun(t *testing.T) {
 	handler := testMux(nil)
-	rids := make([]string, 0, 2)
-	for i := 0; i < 2; i++ {
-		recorder := get(handler, "/demo/command", nil)
-		if recorder.Code != http.StatusOK {
-			t.Fatalf("render %d: status = %d, want 200", i, recorder.Code)
-		}
-		body := recorder.Body.String()
-		idSegment := regexp.MustCompile(`drills/([^/"']+)/`)
-		matches := idSegment.FindAllStringSubmatch(body, -1)
-		if len(matches) == 0 {
-			t.Fatalf("render %d: page body does not reference any run id", i)
-		}
-		for _, match := range matches {
-			if !commandPageULIDPattern.MatchString(match[1]) {
-				t.Fatalf("render %d: run id %q is not a 26-character Crockford Base32 ULID", i, match[1])
-			}
-			rids = append(rids, match[1])
-		}
-		if strings.Contains(body, `name="id"`) {
-			t.Fatalf("render %d: page must not construct ids (no name=\"id\" input)", i)
+	seeded := seedDispatchPageRun(t, handler)
+	bare := newSessionRun(t, handler)
+
+	recorder := get(handler, "/demo/command", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("no run: status = %d, want 200", recorder.Code)
+	}
+	body := recorder.Body.String()
+	for _, text := range []string{"请选择演练", `<option value="` + seeded.ID + `"`, `<option value="` + bare.ID + `"`} {
+		if !strings.Contains(body, text) {
+			t.Fatalf("no run: page body does not contain %q", text)
 		}
 	}
-	// 同一 handler 的两次渲染铸造不同的 rid（每次渲染都铸造新 ULID）；同
-	// 一次渲染内所有 hx-get 刷新动作共享同一个 rid 锚点。
-	if rids[0] == rids[len(rids)/2] {
-		t.Fatalf("two renders minted the same run id %q, want fresh per render", rids[0])
+	if strings.Contains(body, `data-run-stream="`) || strings.Contains(body, "区域热力") {
+		t.Fatalf("no run: page renders the board without a selected run")
+	}
+
+	recorder = get(handler, "/demo/command?run=06G00JAJ2197VBH6390A1BX79C", nil)
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("unknown run: status = %d, want 404", recorder.Code)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
+		t.Fatalf("unknown run: Content-Type = %q, want text/html", contentType)
+	}
+	if body := recorder.Body.String(); !strings.Contains(body, "演练不存在") || strings.Contains(body, `data-run-stream="`) {
+		t.Fatalf("unknown run: page body lacks the 演练不存在 hint or renders a board")
+	}
+
+	recorder = get(handler, "/demo/command?run="+bare.ID, nil)
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "会话配置") {
+		t.Fatalf("run without dispatch data: status = %d, want the empty board", recorder.Code)
 	}
 }
 
@@ -96,7 +96,7 @@ func (h *commandSessionHandler) upsert(w http.ResponseWriter, r *http.Request) {
 		MainVenue:   body.MainVenue,
 		JointVenues: body.JointVenues,
 		Metadata:    body.Metadata,
-		CreatedBy:   body.CreatedBy,
+		CreatedBy:   requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeCommandSessionError(w, err)
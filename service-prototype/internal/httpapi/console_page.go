@@ -1,107 +1,137 @@
 package httpapi
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/dispatch"
-	"github.com/ovaphlow/pitchfork/service-prototype/internal/ulid"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/drills"
 	"github.com/ovaphlow/pitchfork/service-prototype/web"
 )
 
 // consolePagePath is the server-rendered command console and field
-// terminal page (htmx SSR, no shared client, no database).
+// terminal page (htmx SSR, the write side of one drill run).
 const consolePagePath = "/demo/console"
 
-// exampleConsoleRunID is the fixed 26-character Crockford Base32 ULID of
-// the demo drill run the console page anchors its seven forms on. The
-// run is "existing" demo data (like the example runs of the drills
-// page), so its id stays stable across renders; the demo rows (orders,
-// messages, zone reports, device reports, department reports) carry no
-// ids and are minted fresh per render, exactly like the backing API
-// mints ids at creation. The page itself never constructs ids.
-const exampleConsoleRunID = "06G00NC5ZWA3K5G194PSBJ8WNR"
+// consoleDeadlineLayout is the datetime-local layout the order rows
+// display their deadline in (the value format of the deadline input).
+const consoleDeadlineLayout = "2006-01-02T15:04"
 
 // handleConsolePage renders the command console and field terminal page.
 // Non-GET requests on the page path yield the repository-standard 405
 // JSON with Allow: GET (same convention as the API resource routes).
-// The display data is injected in memory from the built-in demo data
-// below — no database, no API call. The seven forms of the page (会话
-// 配置/下达指令/部门联动 on the commander block, 发送消息/上报热力/上报设
-// 备/指令反馈 on the field-terminal block) target the sixth-section API
-// contract endpoints under /crate-api/prototype/v1/drills/{rid}/...,
-// with the rid and the feedback form's oid carried by the demo data;
-// the department form's {department} path segment is the business enum
-// value of the first demo department.
-func handleConsolePage(w http.ResponseWriter, r *http.Request) {
+// The run selection follows the command page (see dispatchPagesHandler).
+// The seven forms of the page (会话配置/下达指令/部门联动 on the
+// commander block, 发送消息/上报热力/上报设备/指令反馈 on the
+// field-terminal block) post JSON to the dispatch API contract endpoints
+// under /crate-api/prototype/v1/drills/{rid}/...; the writes reach the
+// lists of the page through the run stream, which re-requests the page
+// for the same run.
+func (h *dispatchPagesHandler) handleConsolePage(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.Header().Set("Allow", "GET")
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
+	runs, err := h.runOptions(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	data := web.ConsolePageData{Runs: runs}
+	status := http.StatusOK
+	runID := r.URL.Query().Get("run")
+	if runID == "" {
+		data.Notice = dispatchPageNoticeSelect
+	} else {
+		board, err := h.load(r.Context(), runID)
+		switch {
+		case errors.Is(err, drills.ErrRunNotFound), errors.Is(err, dispatch.ErrRunNotFound):
+			data.Notice = dispatchPageNoticeMissing
+			status = http.StatusNotFound
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, "internal error")
+			return
+		default:
+			data = consolePageData(runID, board)
+			data.Runs = runs
+		}
+	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := web.RenderConsole(w, consolePageData()); err != nil {
+	w.WriteHeader(status)
+	if err := web.RenderConsole(w, data); err != nil {
 		writeError(w, http.StatusInternalServerError, "render page failed")
 	}
 }
 
-// consolePageData builds the display payload of the command console and
-// field terminal page from the built-in demo data: one dispatch session
-// (远程协同 mode with the main venue and two joint venues), two dispatch
-// orders (the 执行中 order anchors the field-terminal feedback form),
-// two department linkage reports (消防/场馆应急组, the first anchoring the
-// commander's linkage form), two dispatch messages (指挥中心/现场人员),
-// three zone-density reports and three device reports covering
-// 正常/告警/离线. The field values follow the dispatch contracts word
-// for word (Chinese enum values included); the demo rows carry no ids,
-// so each render mints fresh server-side ids for them, while the run id
-// stays fixed (exampleConsoleRunID).
-func consolePageData() web.ConsolePageData {
-	return web.ConsolePageData{
-		RunID: exampleConsoleRunID,
-		Session: web.ConsoleSessionView{
-			Mode:        string(dispatch.ModeRemote),
-			MainVenue:   "主馆一层大厅",
-			JointVenues: []string{"东区联训馆", "西区联训馆"},
-		},
-		Orders: []web.ConsoleOrderView{
-			{
-				ID:         ulid.New(),
-				Title:      "增开安检通道",
-				Content:    "南门增开一条安检通道，缓解排队",
-				Priority:   string(dispatch.PriorityNormal),
-				TargetType: string(dispatch.TargetTypeDepartment),
-				TargetName: "安保部",
-				Deadline:   "2026-08-03T14:30",
-				Status:     string(dispatch.OrderStatusExecuting),
-			},
-			{
-				ID:         ulid.New(),
-				Title:      "加强A区客流疏导",
-				Content:    "A区东侧展厅人流密度持续上升，请立即加强现场疏导",
-				Priority:   string(dispatch.PriorityCritical),
-				TargetType: string(dispatch.TargetTypeGroup),
-				TargetName: "安保二组",
-				Deadline:   "2026-08-03T15:00",
-				Status:     string(dispatch.OrderStatusPending),
-			},
-		},
-		Departments: []web.ConsoleDepartmentView{
-			{ID: ulid.New(), Department: string(dispatch.DepartmentFire), Status: string(dispatch.DepartmentStatusArrived), Note: "消防增援分队已就位"},
-			{ID: ulid.New(), Department: string(dispatch.DepartmentVenue), Status: string(dispatch.DepartmentStatusHandling)},
-		},
-		Messages: []web.ConsoleMessageView{
-			{ID: ulid.New(), SenderType: string(dispatch.SenderTypeCommand), SenderName: "总指挥", Content: "各点位注意，A区客流已接近阈值，请加强疏导"},
-			{ID: ulid.New(), SenderType: string(dispatch.SenderTypeField), SenderName: "南门岗", Content: "收到，已增开一条安检通道"},
-		},
-		Zones: []web.ConsoleZoneView{
-			{ID: ulid.New(), ZoneName: "A区东侧展厅", PeopleCount: 180},
-			{ID: ulid.New(), ZoneName: "B区中央大厅", PeopleCount: 520},
-			{ID: ulid.New(), ZoneName: "C区南门通道", PeopleCount: 960},
-		},
-		Devices: []web.ConsoleDeviceView{
-			{ID: ulid.New(), DeviceName: "一号供配电柜", DeviceType: string(dispatch.DeviceTypePowerSupply), Status: string(dispatch.DeviceStatusNormal)},
-			{ID: ulid.New(), DeviceName: "东区烟感探测器", DeviceType: string(dispatch.DeviceTypeFire), Status: string(dispatch.DeviceStatusWarning), Note: "烟雾浓度超限"},
-			{ID: ulid.New(), DeviceName: "B区客流摄像头", DeviceType: string(dispatch.DeviceTypeSecurity), Status: string(dispatch.DeviceStatusOffline)},
-		},
+// consolePageData maps the dispatch data of the run to the console page
+// data: the session (empty without one) and the stored rows with their
+// ids, in the order the API lists them. The department-linkage form
+// targets the department of the first report, or 消防 (the first
+// department of the enum) while the run has none.
+func consolePageData(runID string, board dispatchBoard) web.ConsolePageData {
+	data := web.ConsolePageData{
+		RunID:             runID,
+		LinkageDepartment: string(dispatch.DepartmentFire),
+		Orders:            make([]web.ConsoleOrderView, 0, len(board.orders)),
+		Departments:       make([]web.ConsoleDepartmentView, 0, len(board.departments)),
+		Messages:          make([]web.ConsoleMessageView, 0, len(board.messages)),
+		Zones:             make([]web.ConsoleZoneView, 0, len(board.zones)),
+		Devices:           make([]web.ConsoleDeviceView, 0, len(board.devices)),
+	}
+	data.Session.JointVenues = []string{}
+	if board.session != nil {
+		data.Session = web.ConsoleSessionView{
+			Mode:        string(board.session.Mode),
+			MainVenue:   board.session.MainVenue,
+			JointVenues: board.session.JointVenues,
+		}
+	}
+	for _, order := range board.orders {
+		view := web.ConsoleOrderView{
+			ID:         order.ID,
+			Title:      order.Title,
+			Content:    order.Content,
+			Priority:   string(order.Priority),
+			TargetType: string(order.TargetType),
+			TargetName: order.TargetName,
+			Status:     string(order.Status),
+		}
+		if order.Deadline != nil {
+			view.Deadline = order.Deadline.Format(consoleDeadlineLayout)
+		}
+		data.Orders = append(data.Orders, view)
+	}
+	if len(board.departments) > 0 {
+		data.LinkageDepartment = string(board.departments[0].Department)
+	}
+	for _, report := range board.departments {
+		data.Departments = append(data.Departments, web.ConsoleDepartmentView{
+			ID:         report.ID,
+			Department: string(report.Department),
+			Status:     string(report.Status),
+			Note:       report.Note,
+		})
+	}
+	for _, message := range board.messages {
+		data.Messages = append(data.Messages, web.ConsoleMessageView{
+			ID:         message.ID,
+			SenderType: string(message.SenderType),
+			SenderName: message.SenderName,
+			Content:    message.Content,
+		})
+	}
+	for _, zone := range board.zones {
+		data.Zones = append(data.Zones, web.ConsoleZoneView{ID: zone.ID, ZoneName: zone.ZoneName, PeopleCount: zone.PeopleCount})
+	}
+	for _, device := range board.devices {
+		data.Devices = append(data.Devices, web.ConsoleDeviceView{
+			ID:         device.ID,
+			DeviceName: device.DeviceName,
+			DeviceType: string(device.DeviceType),
+			Status:     string(device.Status),
+			Note:       device.Note,
+		})
 	}
+	return data
 }
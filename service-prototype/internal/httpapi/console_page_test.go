@@ -14,112 +14,100 @@ import (
 // ─── #54 指挥调度操作与现场终端页 ──────────────────────────────────
 
 // consolePageULIDPattern is the 26-character Crockford Base32 ULID shape
-// every server id rendered by the page must carry (the form-URL rid and
-// oid plus the ids of the demo list rows). The page itself never
-// constructs ids.
+// of every stored id the page renders (the list-row ids). The page
+// itself never constructs ids.
 var consolePageULIDPattern = regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`)
 
-// GET /demo/console 返回 200 且 Content-Type 为 text/html；页面含「指挥调
-// 度操作与现场终端」标题与「指挥员操作」「现场终端」两大区块，内容由 handler
-// 注入的内存演示数据渲染（会话配置/指令/部门联动/消息/热力/设备六类对象各至
-// 少一条、dispatch 枚举中文值都在，空数据或错数据不得通过）。
-func TestConsolePageServesHTML(t *testing.T) {
-	recorder := get(testMux(nil), "/demo/console", nil)
+// GET /demo/console?run={rid} 返回 200 text/html；页面含「指挥调度操作与
+// 现场终端」标题与「指挥员操作」「现场终端」两大区块，列表行来自该 run 经
+// API 写入的指令/部门/消息/热力/设备并带出各自的 id；七个表单的 hx 动作
+// 锚定该 run（部门联动表单锚定第一条部门记录的枚举值，指令反馈表单锚定
+// 执行中的指令），且都以 json-form 扩展提交 JSON。
+func TestConsolePageRendersSelectedRun(t *testing.T) {
+	handler := testMux(nil)
+	run := seedDispatchPageRun(t, handler)
+	orders := decodeOrderList(t, get(handler, ordersPath(run.ID), nil)).Records
+	for _, next := range []string{`{"status":"已接收"}`, `{"status":"执行中"}`} {
+		if recorder := do(handler, http.MethodPut, orderItemPath(run.ID, orders[0].ID), next); recorder.Code != http.StatusOK {
+			t.Fatalf("PUT %s: status = %d", next, recorder.Code)
+		}
+	}
+
+	recorder := get(handler, "/demo/console?run="+run.ID, nil)
 	if recorder.Code != http.StatusOK {
-		t.Fatalf("status = %d, want 200", recorder.Code)
+		t.Fatalf("status = %d, want 200; body = %s", recorder.Code, recorder.Body.String())
 	}
 	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
 		t.Fatalf("Content-Type = %q, want text/html", contentType)
 	}
 	body := recorder.Body.String()
+	base := "/crate-api/prototype/v1/drills/" + run.ID
 	for _, text := range []string{
-		"指挥调度操作与现场终端", "指挥员操作", "现场终端",
-		"远程协同", "主馆一层大厅", "东区联训馆", "西区联训馆",
-		"增开安检通道", "加强A区客流疏导", "特急", "执行中", "待接收",
-		"消防", "场馆应急组", "已到位", "处置中",
-		"指挥中心 · 总指挥", "现场人员 · 南门岗",
-		"A区东侧展厅", "B区中央大厅", "C区南门通道",
-		"一号供配电柜", "东区烟感探测器", "B区客流摄像头", "告警", "离线", "烟雾浓度超限",
+		"指挥调度操作与现场终端", "指挥员操作", "现场终端", "演练选择",
+		"远程协同", "主馆一层大厅", "东区联训馆、西区联训馆",
+		`<td class="order-id">` + orders[0].ID + `</td>`, "增开安检通道", "2026-08-03T14:30", "执行中",
+		"消防增援分队出发", "现场人员 · 南门岗", "C区南门通道", "东区烟感探测器", "烟雾浓度超限",
+		`hx-put="` + base + `/command-session" hx-ext="json-form"`,
+		`hx-post="` + base + `/orders" hx-ext="json-form"`,
+		`hx-put="` + base + `/departments/消防" hx-ext="json-form"`,
+		`hx-post="` + base + `/messages" hx-ext="json-form"`,
+		`hx-post="` + base + `/zone-densities" hx-ext="json-form"`,
+		`hx-post="` + base + `/devices" hx-ext="json-form"`,
+		`hx-put="` + base + `/orders/` + orders[0].ID + `" hx-ext="json-form"`,
+		`htmx.defineExtension("json-form"`,
+		`data-run-stream="` + base + `/stream"`,
 	} {
 		if !strings.Contains(body, text) {
-			t.Fatalf("page body does not contain %q (demo data not injected)", text)
+			t.Fatalf("page body does not contain %q", text)
+		}
+	}
+	for _, rowID := range regexp.MustCompile(`class="(?:order|message|zone|device|department)-id">([^<]+)<`).FindAllStringSubmatch(body, -1) {
+		if !consolePageULIDPattern.MatchString(rowID[1]) {
+			t.Fatalf("list-row id %q is not a 26-character Crockford Base32 ULID", rowID[1])
 		}
 	}
+	if strings.Contains(body, `name="id"`) {
+		t.Fatalf("page must not construct ids (no name=\"id\" input)")
+	}
 }
 
-// 每次渲染的 ID 铸造行为：表单 URL 中的 rid 始终是数据带出的固定 26 位
-// Crockford Base32 ULID（示例 run id，仿 drills 页 exampleRun*ID 模式）；
-// oid（反馈表单 URL）与各列表行 id 由服务端铸造，连续两次渲染互不相同（验
-// 证每次渲染服务端重新铸造）；页面出现的全部服务端 id 均匹配
-// ^[0-9A-HJKMNP-TV-Z]{26}$（部门联动表单的 {department} 路径段为业务枚举
-// 值，不参与该断言）；页面无任何 id 构造输入（无 name="id" 输入项）。
-func TestConsolePageMintsFreshULIDPerRender(t *testing.T) {
+// 表单经 JSON API 回写：以 json-form 扩展会提交的 JSON body 直接调用表单
+// 的 hx 动作（下达指令、发送消息），页面重取后新行出现；无 run 参数 200 +
+// 「请选择演练」且不渲染表单；run 不存在 404 +「演练不存在」；没有部门记录
+// 的 run，部门联动表单锚定 消防。
+func TestConsolePageFormsPostThroughAPI(t *testing.T) {
 	handler := testMux(nil)
-	renders := make([][]string, 0, 2)
-	for i := 0; i < 2; i++ {
-		recorder := get(handler, "/demo/console", nil)
-		if recorder.Code != http.StatusOK {
-			t.Fatalf("render %d: status = %d, want 200", i, recorder.Code)
-		}
-		body := recorder.Body.String()
-
-		// 表单 URL 中的 rid：全部为固定示例 ULID。
-		ridSegment := regexp.MustCompile(`drills/([^/"']+)/`)
-		ridMatches := ridSegment.FindAllStringSubmatch(body, -1)
-		if len(ridMatches) == 0 {
-			t.Fatalf("render %d: page body does not reference any run id", i)
-		}
-		for _, match := range ridMatches {
-			if match[1] != exampleConsoleRunID {
-				t.Fatalf("render %d: run id %q in a form URL, want the fixed %q", i, match[1], exampleConsoleRunID)
-			}
-			if !consolePageULIDPattern.MatchString(match[1]) {
-				t.Fatalf("render %d: run id %q is not a 26-character Crockford Base32 ULID", i, match[1])
-			}
-		}
-
-		// 反馈表单 URL 中的 oid：恰一个，为铸造的 ULID。
-		oidSegment := regexp.MustCompile(`drills/` + exampleConsoleRunID + `/orders/([^/"']+)`)
-		oidMatches := oidSegment.FindAllStringSubmatch(body, -1)
-		if len(oidMatches) != 1 {
-			t.Fatalf("render %d: feedback form must carry exactly one order id, got %d", i, len(oidMatches))
-		}
-		if !consolePageULIDPattern.MatchString(oidMatches[0][1]) {
-			t.Fatalf("render %d: order id %q is not a 26-character Crockford Base32 ULID", i, oidMatches[0][1])
-		}
-
-		// 部门联动表单的 {department} 路径段为业务枚举值（消防），不参与 ULID 断言。
-		departmentSegment := regexp.MustCompile(`drills/` + exampleConsoleRunID + `/departments/([^/"']+)`)
-		departmentMatches := departmentSegment.FindAllStringSubmatch(body, -1)
-		if len(departmentMatches) != 1 || departmentMatches[0][1] != string(dispatch.DepartmentFire) {
-			t.Fatalf("render %d: department form must carry exactly one business enum segment, got %v", i, departmentMatches)
-		}
-
-		// 各列表行 id 均为 26 位 ULID。
-		rowIDPattern := regexp.MustCompile(`class="(?:order|message|zone|device|department)-id">([^<]+)<`)
-		rowMatches := rowIDPattern.FindAllStringSubmatch(body, -1)
-		if len(rowMatches) == 0 {
-			t.Fatalf("render %d: page body does not render any list-row id", i)
+	run := mustCreateInProgressRun(t, handler, validScenarioBody)
+	body := get(handler, "/demo/console?run="+run.ID, nil).Body.String()
+	if !strings.Contains(body, "/departments/"+string(dispatch.DepartmentFire)+`"`) {
+		t.Fatalf("linkage form of a run without reports does not target 消防")
+	}
+	base := "/crate-api/prototype/v1/drills/" + run.ID
+	for _, write := range []struct{ action, body string }{
+		{base + "/orders", `{"title":"启动应急广播","content":"发布限流提示","priority":"紧急","target_type":"部门","target_name":"客服部","deadline":"2026-08-03T15:00:00.000Z"}`},
+		{base + "/messages", `{"sender_type":"指挥中心","sender_name":"总指挥","content":"各点位注意"}`},
+	} {
+		if !strings.Contains(body, `hx-post="`+write.action+`"`) {
+			t.Fatalf("page has no form posting to %s", write.action)
 		}
-		minted := make([]string, 0, len(rowMatches)+1)
-		minted = append(minted, oidMatches[0][1])
-		for _, match := range rowMatches {
-			if !consolePageULIDPattern.MatchString(match[1]) {
-				t.Fatalf("render %d: list-row id %q is not a 26-character Crockford Base32 ULID", i, match[1])
-			}
-			minted = append(minted, match[1])
+		if recorder := do(handler, http.MethodPost, write.action, write.body); recorder.Code != http.StatusCreated {
+			t.Fatalf("POST %s: status = %d; body = %s", write.action, recorder.Code, recorder.Body.String())
 		}
-
-		// 页面不构造 ID：没有任何 id 输入项。
-		if strings.Contains(body, `name="id"`) {
-			t.Fatalf("render %d: page must not construct ids (no name=\"id\" input)", i)
+	}
+	body = get(handler, "/demo/console?run="+run.ID, nil).Body.String()
+	for _, text := range []string{"启动应急广播", "2026-08-03T15:00", "指挥中心 · 总指挥", "各点位注意"} {
+		if !strings.Contains(body, text) {
+			t.Fatalf("page after the writes does not contain %q", text)
 		}
+	}
 
-		renders = append(renders, minted)
+	recorder := get(handler, "/demo/console", nil)
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "请选择演练") || strings.Contains(recorder.Body.String(), "hx-post") {
+		t.Fatalf("no run: status = %d, want 200 with the picker hint and no forms", recorder.Code)
 	}
-	// 两次渲染铸造的 id（oid + 列表行 id）互不相同：每次渲染服务端重新铸造。
-	if strings.Join(renders[0], ",") == strings.Join(renders[1], ",") {
-		t.Fatalf("two renders minted the same id set %q, want fresh per render", renders[0])
+	recorder = get(handler, "/demo/console?run=run-missing", nil)
+	if recorder.Code != http.StatusNotFound || !strings.Contains(recorder.Body.String(), "演练不存在") {
+		t.Fatalf("unknown run: status = %d, want 404 with the 演练不存在 hint", recorder.Code)
 	}
 }
 
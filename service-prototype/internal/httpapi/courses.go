@@ -54,8 +54,9 @@ func (h *coursesHandler) handleItem(w http.ResponseWriter, r *http.Request) {
 }
 
 // courseBody mirrors the client-supplied fields of the request body.
-// created_by is optional (empty when omitted) because the prototype has
-// no auth context; metadata is an optional JSON object.
+// created_by is optional (empty when omitted; the authenticated subject
+// replaces it when the router authenticates); metadata is an optional JSON
+// object.
 type courseBody struct {
 	Title     string         `json:"title"`
 	Topic     string         `json:"topic"`
@@ -88,7 +89,7 @@ func (h *coursesHandler) create(w http.ResponseWriter, r *http.Request) {
 		Type:      courses.DeliveryType(body.Type),
 		Status:    courses.Status(body.Status),
 		Metadata:  body.Metadata,
-		CreatedBy: body.CreatedBy,
+		CreatedBy: requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeCourseError(w, err)
@@ -185,13 +186,21 @@ func (h *coursesHandler) update(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
+	createdBy, err := storedCreatedBy(r, body.CreatedBy, func() (string, error) {
+		stored, err := h.service.Get(r.Context(), r.PathValue("id"))
+		return stored.CreatedBy, err
+	})
+	if err != nil {
+		writeCourseError(w, err)
+		return
+	}
 	course, err := h.service.Update(r.Context(), r.PathValue("id"), courses.Input{
 		Title:     body.Title,
 		Topic:     courses.Topic(body.Topic),
 		Type:      courses.DeliveryType(body.Type),
 		Status:    courses.Status(body.Status),
 		Metadata:  body.Metadata,
-		CreatedBy: body.CreatedBy,
+		CreatedBy: createdBy,
 	})
 	if err != nil {
 		writeCourseError(w, err)
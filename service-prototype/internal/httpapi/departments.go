@@ -24,11 +24,12 @@ import (
 const departmentsBase = prototypePrefix + "/drills/{rid}/departments"
 
 // departmentsHandler adapts the dispatch service to the HTTP routing
-// layer. It serves the per-run collection (GET list only) and the item
-// routes (PUT upsert / DELETE by department); other methods yield a
-// JSON 405 with Allow. The owning run and the linkage department come
-// from the route path: writes require the run to be 进行中 (400
-// otherwise) and a missing run is a 404 on every route.
+// layer. It serves the per-run collection (GET list only), the item
+// routes (PUT upsert / DELETE by department) and the read-only status
+// history of a report (GET …/departments/{department}/history); other
+// methods yield a JSON 405 with Allow. The owning run and the linkage
+// department come from the route path: writes require the run to be
+// 进行中 (400 otherwise) and a missing run is a 404 on every route.
 type departmentsHandler struct {
 	service *dispatch.Service
 }
@@ -62,22 +63,33 @@ func (h *departmentsHandler) handleItem(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+func (h *departmentsHandler) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	h.history(w, r)
+}
+
 // departmentReportBody mirrors the client-supplied fields of a
 // department-report upsert. id, run_id and department are never part of
 // the body: they are decided by the route path and the service (a body
 // that carries them has them ignored). There are no required fields:
-// status defaults to 未响应 on create and keeps the current value on
-// update (an explicit value must be one of the five statuses and an
-// adjacent forward transition on update); note defaults to an empty
-// string and must be a JSON string; arrived_at is an optional RFC3339
-// instant (explicit null clears it; non-string values are rejected);
-// created_by passes through (empty when omitted) because the prototype
-// has no auth context.
+// status defaults to 未响应 on create and keeps the current value on update
+// (an explicit value must be one of the five statuses and an adjacent
+// forward transition on update); note defaults to an empty string and must
+// be a JSON string; arrived_at is an optional RFC3339 instant (explicit
+// null clears it; non-string values are rejected); created_by passes
+// through (empty when omitted; the authenticated subject replaces it when
+// the router authenticates). actor names who made a status change for its
+// history entry and defaults to created_by.
 type departmentReportBody struct {
 	Status    string          `json:"status"`
 	Note      string          `json:"note"`
 	ArrivedAt json.RawMessage `json:"arrived_at"`
 	CreatedBy string          `json:"created_by"`
+	Actor     string          `json:"actor"`
 }
 
 // parseDepartmentArrivedAt converts the raw arrived_at field. An
@@ -118,7 +130,8 @@ func (h *departmentsHandler) upsert(w http.ResponseWriter, r *http.Request) {
 			Status:    dispatch.DepartmentStatus(body.Status),
 			Note:      body.Note,
 			ArrivedAt: arrivedAt,
-			CreatedBy: body.CreatedBy,
+			CreatedBy: requestActor(r, body.CreatedBy),
+			Actor:     requestActor(r, body.Actor),
 		})
 	if err != nil {
 		writeDepartmentError(w, err)
@@ -199,6 +212,24 @@ func (h *departmentsHandler) delete(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// departmentHistoryResponse follows the repository list convention:
+// { "records": [...], "meta": { "total": N } }. The history is never
+// paginated: a report has at most five entries.
+type departmentHistoryResponse struct {
+	Records []dispatch.DepartmentTransition `json:"records"`
+	Meta    metaResponse                    `json:"meta"`
+}
+
+func (h *departmentsHandler) history(w http.ResponseWriter, r *http.Request) {
+	records, err := h.service.DepartmentHistory(r.Context(), r.PathValue("rid"),
+		dispatch.Department(r.PathValue("department")))
+	if err != nil {
+		writeDepartmentError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, departmentHistoryResponse{Records: records, Meta: metaResponse{Total: len(records)}})
+}
+
 // writeDepartmentError maps the dispatch service errors of the
 // department-report resource to JSON error responses: validation errors
 // become 400, unknown runs or reports 404, everything else 500.
@@ -63,15 +63,15 @@ func (h *devicesHandler) handleItem(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// deviceBody mirrors the client-supplied fields of a device status
-// report. id, run_id and the timestamps are never part of the body: id
-// is server-generated, run_id comes from the route path (a body that
-// carries run_id has it ignored). device_name and device_type are
-// required; status defaults to 正常 (an explicit value must be one of
-// 正常/告警/离线); note defaults to an empty string; created_by passes
-// through at creation (empty when omitted, the prototype has no auth
-// context) and is preserved on update (a body that carries created_by
-// on PUT has it ignored).
+// deviceBody mirrors the client-supplied fields of a device status report.
+// id, run_id and the timestamps are never part of the body: id is
+// server-generated, run_id comes from the route path (a body that carries
+// run_id has it ignored). device_name and device_type are required; status
+// defaults to 正常 (an explicit value must be one of 正常/告警/离线); note
+// defaults to an empty string; created_by passes through at creation
+// (empty when omitted; the authenticated subject when the router
+// authenticates) and is preserved on update (a body that carries
+// created_by on PUT has it ignored).
 type deviceBody struct {
 	DeviceName string `json:"device_name"`
 	DeviceType string `json:"device_type"`
@@ -90,7 +90,7 @@ func (h *devicesHandler) create(w http.ResponseWriter, r *http.Request) {
 		DeviceType: dispatch.DeviceType(body.DeviceType),
 		Status:     dispatch.DeviceStatus(body.Status),
 		Note:       body.Note,
-		CreatedBy:  body.CreatedBy,
+		CreatedBy:  requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeDeviceError(w, err)
@@ -76,11 +76,11 @@ func (h *examRecordsHandler) handleSubmit(w http.ResponseWriter, r *http.Request
 }
 
 // examRecordBody mirrors the client-supplied fields of the exam-start
-// request body. metadata is captured raw so an omitted field (default
-// {}) can be told apart from an explicit JSON null (rejected);
-// created_by is optional (empty when omitted) because the prototype has
-// no auth context. id, the timestamps and answers_snapshot are never
-// accepted from the client (a client-supplied field is ignored).
+// request body. metadata is captured raw so an omitted field (default {})
+// can be told apart from an explicit JSON null (rejected); created_by is
+// optional (empty when omitted; the authenticated subject replaces it when
+// the router authenticates). id, the timestamps and answers_snapshot are
+// never accepted from the client (a client-supplied field is ignored).
 type examRecordBody struct {
 	EmployeeID string          `json:"employee_id"`
 	PaperID    string          `json:"paper_id"`
@@ -134,7 +134,7 @@ func (h *examRecordsHandler) create(w http.ResponseWriter, r *http.Request) {
 		EmployeeID: body.EmployeeID,
 		PaperID:    body.PaperID,
 		Metadata:   metadata,
-		CreatedBy:  body.CreatedBy,
+		CreatedBy:  requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeExamRecordError(w, err)
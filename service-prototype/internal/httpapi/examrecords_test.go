@@ -59,7 +59,8 @@ func examMux(allowedOrigins []string) http.Handler {
 	paperStore := papers.NewInMemoryStore()
 	seedExamPaper(paperStore, paperID)
 	seedExamPaper(paperStore, paperID2)
-	return NewMux(allowedOrigins, courses.NewInMemoryStore(), chapters.NewInMemoryStore(), questions.NewInMemoryStore(), assignments.NewInMemoryStore(), progress.NewInMemoryStore(), paperStore, examrecords.NewInMemoryStore(), drills.NewInMemoryStore(), dispatch.NewInMemoryStore(), opinion.NewInMemoryStore(), evaluation.NewInMemoryStore())
+	drillStore := drills.NewInMemoryStore()
+	return NewMux(allowedOrigins, courses.NewInMemoryStore(), chapters.NewInMemoryStore(), questions.NewInMemoryStore(), assignments.NewInMemoryStore(), progress.NewInMemoryStore(), paperStore, examrecords.NewInMemoryStore(), drillStore, dispatch.NewInMemoryStore(), opinion.NewInMemoryStore(), evaluation.NewInMemoryStore(), evaluation.NewInMemoryScoreStore(), evaluation.NewInMemoryReportStore(), drills.NewTimelineService(drills.NewInMemoryTimelineStore(), drillStore), nil)
 }
 
 // examRecordJSON mirrors the exam-record response for assertions.
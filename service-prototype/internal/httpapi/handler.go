@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/assignments"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/auth"
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/chapters"
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/courses"
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/dispatch"
@@ -19,6 +20,7 @@ import (
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/papers"
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/progress"
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/questions"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/runstream"
 	"github.com/ovaphlow/pitchfork/service-prototype/web"
 )
 
@@ -39,8 +41,10 @@ type healthResponse struct {
 // resource routes. The
 // literal paths take precedence over the {resource} wildcard for the same
 // prefix. The course, chapter, question, assignment, progress, paper,
-// exam-record, drill, dispatch, opinion and evaluation stores are
-// injected so the routing layer stays free of database access; the
+// exam-record, drill, dispatch, opinion, evaluation indicator, score and
+// report stores are injected (in-memory or PostgreSQL-backed, chosen at
+// the composition root) so the routing layer stays free of database
+// access; the
 // chapter store is also
 // wired into the course service so deleting a course cascades to its
 // chapters, the question store backs automatic paper generation (the
@@ -50,7 +54,18 @@ type healthResponse struct {
 // service's run-session cleaner (deleting a run cascades to its
 // sessions); the opinion store backs the opinion-event handler and the
 // drills service's run-opinion cleaner (deleting a run cascades to its
-// opinion event).
+// opinion event). One in-memory run stream broker is shared by the
+// services behind the drill, dispatch and opinion-post handlers (their
+// publisher hook) and the per-run SSE endpoint, so every committed write
+// reaches the terminals watching the run. The drill timeline service
+// (the scripted injects and their run-scoped scheduler) is injected
+// ready-built, because the composition root owns its timers. The
+// after-action timeline and replay routes read the drill, dispatch and
+// opinion stores directly. A non-nil authenticator puts every route
+// except the health endpoint and the static assets behind
+// authentication (identityd session or bearer token) and the write
+// routes behind the prototype roles (see routePolicy); nil keeps the
+// router anonymous.
 // Routes:
 //
 //	GET/POST /crate-api/prototype/v1/courses      -> list / create courses
@@ -84,6 +99,11 @@ type healthResponse struct {
 //	GET/PUT/DELETE /crate-api/prototype/v1/drills/{rid}/steps/{stepId} -> step record by step
 //	GET/POST /crate-api/prototype/v1/drills/{rid}/sim-events -> list / create simulated events
 //	GET/PUT/DELETE /crate-api/prototype/v1/drills/{rid}/sim-events/{eid} -> simulated event by id
+//	GET/POST /crate-api/prototype/v1/scenarios/{sid}/injects -> list / create scenario timeline injects
+//	GET/PUT/DELETE /crate-api/prototype/v1/injects/{id} -> timeline inject by id
+//	GET/POST /crate-api/prototype/v1/drills/{rid}/injects -> run schedule / add a live inject
+//	GET  /crate-api/prototype/v1/drills/{rid}/injects/{iid} -> scheduled inject by id
+//	POST /crate-api/prototype/v1/drills/{rid}/injects/{iid}/cancel -> cancel a pending inject
 //	GET  /crate-api/prototype/v1/drills/{rid}/assessments -> list drill assessments
 //	GET/PUT/DELETE /crate-api/prototype/v1/drills/{rid}/assessments/{pointId} -> assessment by point
 //	GET/PUT/DELETE /crate-api/prototype/v1/drills/{rid}/command-session -> dispatch command session by run
@@ -99,14 +119,19 @@ type healthResponse struct {
 //	GET/PUT/DELETE /crate-api/prototype/v1/drills/{rid}/review -> opinion review by run
 //	GET/POST /crate-api/prototype/v1/drills/{rid}/orders -> list / create dispatch orders
 //	GET/PUT/DELETE /crate-api/prototype/v1/drills/{rid}/orders/{oid} -> dispatch order by id
+//	GET  /crate-api/prototype/v1/drills/{rid}/orders/{oid}/history -> status history of the order
 //	GET  /crate-api/prototype/v1/drills/{rid}/departments -> list dispatch department reports
 //	PUT/DELETE /crate-api/prototype/v1/drills/{rid}/departments/{department} -> department report by department
+//	GET  /crate-api/prototype/v1/drills/{rid}/departments/{department}/history -> status history of the report
 //	GET/POST /crate-api/prototype/v1/drills/{rid}/messages -> list / send dispatch messages
 //	GET/DELETE /crate-api/prototype/v1/drills/{rid}/messages/{mid} -> dispatch message by id
 //	GET/POST /crate-api/prototype/v1/drills/{rid}/zone-densities -> list / report zone crowd densities
 //	GET/PUT/DELETE /crate-api/prototype/v1/drills/{rid}/zone-densities/{zid} -> zone density report by id
 //	GET/POST /crate-api/prototype/v1/drills/{rid}/devices -> list / report device running status
 //	GET/PUT/DELETE /crate-api/prototype/v1/drills/{rid}/devices/{did} -> device report by id
+//	GET  /crate-api/prototype/v1/drills/{rid}/stream -> SSE stream of the run changes (Last-Event-ID resume)
+//	GET  /crate-api/prototype/v1/drills/{rid}/timeline -> merged after-action timeline (source, from/to, pagination)
+//	GET  /crate-api/prototype/v1/drills/{rid}/replay -> command-board state at a moment (at)
 //	GET/POST /crate-api/prototype/v1/evaluation/indicators -> list / create evaluation indicators
 //	GET/PUT/DELETE /crate-api/prototype/v1/evaluation/indicators/{id} -> indicator by id
 //	GET/POST /crate-api/prototype/v1/evaluation/runs/{rid}/scores -> list / create evaluation scores
@@ -119,8 +144,8 @@ type healthResponse struct {
 //	GET  /demo                -> server-rendered demo page
 //	GET  /demo/scenarios      -> server-rendered drill scenario template management page
 //	GET  /demo/drills         -> server-rendered drill execution and assessment page
-//	GET  /demo/command        -> server-rendered command-center big screen page
-//	GET  /demo/console        -> server-rendered command console and field terminal page
+//	GET  /demo/command?run={rid} -> server-rendered command-center big screen page of a run (run picker)
+//	GET  /demo/console?run={rid} -> server-rendered command console and field terminal page of a run (run picker)
 //	GET  /demo/opinion        -> server-rendered public-opinion monitoring and handling workbench page
 //	GET  /demo/opinion/review  -> server-rendered media communication and after-action review page
 //	GET  /demo/evaluation/indicators -> server-rendered evaluation indicator configuration page
@@ -128,8 +153,9 @@ type healthResponse struct {
 //	GET  /static/{file}       -> embedded static asset (htmx)
 //	any  other path                               -> 404 JSON
 //	any  non-GET on a known resource path         -> 405 JSON with Allow
-func NewMux(allowedOrigins []string, courseStore courses.Store, chapterStore chapters.Store, questionStore questions.Store, assignmentStore assignments.Store, progressStore progress.Store, paperStore papers.Store, examRecordStore examrecords.Store, drillStore drills.Store, dispatchStore dispatch.Store, opinionStore opinion.Store, evaluationStore evaluation.Store) http.Handler {
+func NewMux(allowedOrigins []string, courseStore courses.Store, chapterStore chapters.Store, questionStore questions.Store, assignmentStore assignments.Store, progressStore progress.Store, paperStore papers.Store, examRecordStore examrecords.Store, drillStore drills.Store, dispatchStore dispatch.Store, opinionStore opinion.Store, evaluationStore evaluation.Store, evaluationScoreStore evaluation.ScoreStore, evaluationReportStore evaluation.ReportStore, timeline *drills.TimelineService, authenticator auth.Authenticator) http.Handler {
 	mux := http.NewServeMux()
+	broker := runstream.NewBroker(0)
 	mux.HandleFunc(prototypePrefix+"/{resource}", handleResource)
 	courseHandler := newCoursesHandler(courseStore)
 	mux.HandleFunc(coursesBase, courseHandler.handleCollection)
@@ -191,6 +217,7 @@ func NewMux(allowedOrigins []string, courseStore courses.Store, chapterStore cha
 	mux.HandleFunc("POST "+runsBase+"/{id}/start", runHandler.handleStart)
 	mux.HandleFunc("POST "+runsBase+"/{id}/complete", runHandler.handleComplete)
 	mux.HandleFunc("POST "+runsBase+"/{id}/terminate", runHandler.handleTerminate)
+	runHandler.service.SetPublisher(broker)
 	// Deleting a run cascades to its step records, sim events and
 	// assessments at the service layer (the in-memory drill store
 	// implements the DB's ON DELETE CASCADE).
@@ -206,7 +233,6 @@ func NewMux(allowedOrigins []string, courseStore courses.Store, chapterStore cha
 	// DELETE CASCADE). The same store is shared by the evaluation
 	// score routes and the indicator service's score-ref checker
 	// further below.
-	evaluationScoreStore := evaluation.NewInMemoryScoreStore()
 	runHandler.service.SetEvaluationScoreCleaner(evaluationScoreStore)
 	// The step-record routes nest under the runs prefix with literal
 	// segments (…/steps…), so they are more specific than the
@@ -217,6 +243,7 @@ func NewMux(allowedOrigins []string, courseStore courses.Store, chapterStore cha
 	stepRecordHandler := newStepRecordHandler(drillStore)
 	mux.HandleFunc(stepRecordsBase, stepRecordHandler.handleCollection)
 	mux.HandleFunc(stepRecordsBase+"/{stepId}", stepRecordHandler.handleItem)
+	stepRecordHandler.service.SetPublisher(broker)
 	// The sim-event routes nest under the runs prefix with the literal
 	// sim-events segment (…/sim-events…), so they are more specific than
 	// the /drills/{id} item route and never collide with it (same pattern
@@ -224,6 +251,29 @@ func NewMux(allowedOrigins []string, courseStore courses.Store, chapterStore cha
 	simEventHandler := newSimEventsHandler(drillStore)
 	mux.HandleFunc(simEventsBase, simEventHandler.handleCollection)
 	mux.HandleFunc(simEventsBase+"/{eid}", simEventHandler.handleItem)
+	simEventHandler.service.SetPublisher(broker)
+	// The timeline routes follow the layout of the steps (the scenario
+	// timeline at /scenarios/{sid}/injects and /injects/{id}) and of the
+	// sim events (the schedule of a run under the literal injects
+	// segment, more specific than the /drills/{id} item route; the
+	// literal cancel segment is more specific than the inject item).
+	// The timeline service is injected rather than built here: it owns
+	// the timers of the run-scoped scheduler, whose lifecycle (Resume at
+	// start-up, Stop at shutdown) belongs to the composition root. It is
+	// wired into the services behind the scenario and run handlers, so
+	// starting a run arms its injects, completing or terminating it
+	// cancels the pending ones and deleting a scenario or a run removes
+	// their injects; the sim events it raises reach the run stream
+	// through the shared broker.
+	timeline.SetPublisher(broker)
+	scenarioHandler.service.SetTimeline(timeline)
+	runHandler.service.SetTimeline(timeline)
+	injectHandler := newInjectsHandler(timeline)
+	mux.HandleFunc(scenariosBase+"/{sid}/injects", injectHandler.handleScenarioInjects)
+	mux.HandleFunc(injectsBase+"/{id}", injectHandler.handleItem)
+	mux.HandleFunc(runInjectsBase, injectHandler.handleRunInjects)
+	mux.HandleFunc(runInjectsBase+"/{iid}", injectHandler.handleRunItem)
+	mux.HandleFunc("POST "+runInjectsBase+"/{iid}/cancel", injectHandler.handleRunCancel)
 	// The assessment routes nest under the runs prefix with the literal
 	// assessments segment (…/assessments…), so they are more specific
 	// than the /drills/{id} item route and never collide with it (same
@@ -272,6 +322,7 @@ func NewMux(allowedOrigins []string, courseStore courses.Store, chapterStore cha
 	opinionPostHandler := newOpinionPostHandler(drillStore, opinionStore)
 	mux.HandleFunc(opinionPostsBase, opinionPostHandler.handleCollection)
 	mux.HandleFunc(opinionPostsBase+"/{pid}", opinionPostHandler.handleItem)
+	opinionPostHandler.service.SetPublisher(broker)
 	// The opinion release routes nest under the runs prefix with the
 	// literal releases segment (…/releases…), so they are more specific
 	// than the /drills/{id} item route and never collide with it (same
@@ -342,20 +393,27 @@ func NewMux(allowedOrigins []string, courseStore courses.Store, chapterStore cha
 	// literal orders segment (…/orders…), so they are more specific than
 	// the /drills/{id} item route and never collide with it (same pattern
 	// as the step-record, sim-event, assessment and command-session routes
-	// above). Orders are issued with POST and updated in place with PUT.
+	// above). Orders are issued with POST and updated in place with PUT;
+	// every status change lands in the append-only history served
+	// read-only under …/orders/{oid}/history.
 	orderHandler := newOrdersHandler(drillStore, dispatchStore)
 	mux.HandleFunc(ordersBase, orderHandler.handleCollection)
 	mux.HandleFunc(ordersBase+"/{oid}", orderHandler.handleItem)
+	mux.HandleFunc(ordersBase+"/{oid}/history", orderHandler.handleHistory)
+	orderHandler.service.SetPublisher(broker)
 	// The dispatch department-report routes nest under the runs prefix
 	// with the literal departments segment (…/departments…), so they are
 	// more specific than the /drills/{id} item route and never collide
 	// with it (same pattern as the step-record, sim-event, assessment,
 	// command-session and orders routes above). The report of a
 	// (run, department) pair is upserted with PUT and removed with
-	// DELETE, so the collection only serves GET.
+	// DELETE, so the collection only serves GET; its status history is
+	// read-only under …/departments/{department}/history.
 	departmentHandler := newDepartmentsHandler(drillStore, dispatchStore)
 	mux.HandleFunc(departmentsBase, departmentHandler.handleCollection)
 	mux.HandleFunc(departmentsBase+"/{department}", departmentHandler.handleItem)
+	mux.HandleFunc(departmentsBase+"/{department}/history", departmentHandler.handleHistory)
+	departmentHandler.service.SetPublisher(broker)
 	// The dispatch message routes nest under the runs prefix with the
 	// literal messages segment (…/messages…), so they are more specific
 	// than the /drills/{id} item route and never collide with it (same
@@ -365,6 +423,7 @@ func NewMux(allowedOrigins []string, courseStore courses.Store, chapterStore cha
 	messageHandler := newMessagesHandler(drillStore, dispatchStore)
 	mux.HandleFunc(messagesBase, messageHandler.handleCollection)
 	mux.HandleFunc(messagesBase+"/{mid}", messageHandler.handleItem)
+	messageHandler.service.SetPublisher(broker)
 	// The dispatch zone-density routes nest under the runs prefix with
 	// the literal zone-densities segment (…/zone-densities…), so they are
 	// more specific than the /drills/{id} item route and never collide
@@ -375,6 +434,7 @@ func NewMux(allowedOrigins []string, courseStore courses.Store, chapterStore cha
 	zoneHandler := newZonesHandler(drillStore, dispatchStore)
 	mux.HandleFunc(zonesBase, zoneHandler.handleCollection)
 	mux.HandleFunc(zonesBase+"/{zid}", zoneHandler.handleItem)
+	zoneHandler.service.SetPublisher(broker)
 	// The dispatch device routes nest under the runs prefix with the
 	// literal devices segment (…/devices…), so they are more specific
 	// than the /drills/{id} item route and never collide with it (same
@@ -385,6 +445,27 @@ func NewMux(allowedOrigins []string, courseStore courses.Store, chapterStore cha
 	deviceHandler := newDevicesHandler(drillStore, dispatchStore)
 	mux.HandleFunc(devicesBase, deviceHandler.handleCollection)
 	mux.HandleFunc(devicesBase+"/{did}", deviceHandler.handleItem)
+	deviceHandler.service.SetPublisher(broker)
+	// The run stream route nests under the runs prefix with the literal
+	// stream segment (…/stream), so it is more specific than the
+	// /drills/{id} item route and never collides with it (same pattern
+	// as the step-record, sim-event, orders and devices routes above).
+	// It replaces the polling of the demo pages: every write the
+	// services above commit is published to the shared broker (their
+	// SetPublisher hooks) and pushed to the subscribers of the run as a
+	// typed SSE event, with Last-Event-ID replay after a reconnect.
+	runStreamHandler := newRunStreamHandler(drillStore, broker)
+	mux.HandleFunc(runStreamBase, runStreamHandler.handleStream)
+	// The after-action routes nest under the runs prefix with the
+	// literal timeline and replay segments, so they are more specific
+	// than the /drills/{id} item route and never collide with it (same
+	// pattern as the run stream route above). They only read: the
+	// replay service merges the drill, dispatch and opinion rows of the
+	// run into one timeline and rebuilds the command board at a past
+	// moment from the same stores.
+	replayHandler := newReplayHandler(drillStore, dispatchStore, opinionStore)
+	mux.HandleFunc(runTimelineBase, replayHandler.handleTimeline)
+	mux.HandleFunc(runReplayBase, replayHandler.handleReplay)
 	// The evaluation indicator dictionary routes live under the literal
 	// evaluation/indicators segment, so they are more specific than the
 	// unified /{resource} wildcard and never collide with it. The
@@ -396,7 +477,11 @@ func NewMux(allowedOrigins []string, courseStore courses.Store, chapterStore cha
 	indicatorHandler := newIndicatorsHandler(evaluationStore)
 	mux.HandleFunc(indicatorsBase, indicatorHandler.handleCollection)
 	mux.HandleFunc(indicatorsBase+"/{id}", indicatorHandler.handleItem)
-	indicatorHandler.service.SetScoreRefChecker(evaluationScoreStore)
+	// Both score stores (in-memory and PostgreSQL) implement the
+	// checker; a store without it leaves the check to the database.
+	if checker, ok := evaluationScoreStore.(evaluation.ScoreRefChecker); ok {
+		indicatorHandler.service.SetScoreRefChecker(checker)
+	}
 	// The evaluation score routes live under the literal
 	// evaluation/runs/{rid}/scores segment, so they are more specific
 	// than the unified /{resource} wildcard and never collide with it
@@ -417,7 +502,6 @@ func NewMux(allowedOrigins []string, courseStore courses.Store, chapterStore cha
 	// store backs the drills service's run-report cleaner hook
 	// (deleting a run cascades to its report, the in-memory counterpart
 	// of the DB's ON DELETE CASCADE).
-	evaluationReportStore := evaluation.NewInMemoryReportStore()
 	runHandler.service.SetEvaluationReportCleaner(evaluationReportStore)
 	reportHandler := newReportsHandler(
 		evaluationReportStore,
@@ -435,12 +519,14 @@ func NewMux(allowedOrigins []string, courseStore courses.Store, chapterStore cha
 	// The drill execution and assessment page renders from the in-memory
 	// example-runs fixture and drills.SeedData — no database, no API call.
 	mux.HandleFunc("GET "+drillsPagePath, handleDrillsPage)
-	// The command-center big screen page renders from the built-in
-	// in-memory demo data — no database, no API call.
-	mux.HandleFunc(commandPagePath, handleCommandPage)
-	// The command console and field terminal page renders from the
-	// built-in in-memory demo data — no database, no API call.
-	mux.HandleFunc(consolePagePath, handleConsolePage)
+	// The command-center big screen and the command console pages
+	// render the drill run selected by their run query parameter from
+	// the shared drill and dispatch stores (the same services as the
+	// JSON API above); the console forms post back through the dispatch
+	// API routes.
+	dispatchPagesHandler := newDispatchPagesHandler(drillStore, dispatchStore)
+	mux.HandleFunc(commandPagePath, dispatchPagesHandler.handleCommandPage)
+	mux.HandleFunc(consolePagePath, dispatchPagesHandler.handleConsolePage)
 	// The public-opinion monitoring and handling workbench page renders
 	// from the built-in in-memory demo data — no database, no API call.
 	mux.HandleFunc(opinionPagePath, handleOpinionPage)
@@ -461,7 +547,14 @@ func NewMux(allowedOrigins []string, courseStore courses.Store, chapterStore cha
 	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
 		writeError(w, http.StatusNotFound, "not found")
 	})
-	return corsMiddleware(mux, allowedOrigins)
+	// The authentication middleware sits inside the CORS middleware, so
+	// its 401/403 answers carry the CORS headers a browser needs to read
+	// them. Without an authenticator every route stays anonymous.
+	var handler http.Handler = mux
+	if authenticator != nil {
+		handler = authMiddleware(mux, authenticator)
+	}
+	return corsMiddleware(handler, allowedOrigins)
 }
 
 // handleDemoPage renders the server-rendered demo page.
@@ -545,7 +638,7 @@ func corsMiddleware(next http.Handler, allowedOrigins []string) http.Handler {
 			// The courses slice adds write methods, so preflights must
 			// advertise them; otherwise browsers would block the writes.
 			header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			header.Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			header.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-CSRF-Token")
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
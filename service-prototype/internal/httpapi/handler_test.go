@@ -22,9 +22,11 @@ import (
 
 // testMux builds a mux with fresh in-memory course, chapter, question,
 // assignment, progress, paper, exam-record, drill, dispatch, opinion
-// and evaluation stores so every test starts from an empty dataset.
+// and evaluation stores (and a drill timeline over the same drill
+// store) so every test starts from an empty dataset.
 func testMux(allowedOrigins []string) http.Handler {
-	return NewMux(allowedOrigins, courses.NewInMemoryStore(), chapters.NewInMemoryStore(), questions.NewInMemoryStore(), assignments.NewInMemoryStore(), progress.NewInMemoryStore(), papers.NewInMemoryStore(), examrecords.NewInMemoryStore(), drills.NewInMemoryStore(), dispatch.NewInMemoryStore(), opinion.NewInMemoryStore(), evaluation.NewInMemoryStore())
+	drillStore := drills.NewInMemoryStore()
+	return NewMux(allowedOrigins, courses.NewInMemoryStore(), chapters.NewInMemoryStore(), questions.NewInMemoryStore(), assignments.NewInMemoryStore(), progress.NewInMemoryStore(), papers.NewInMemoryStore(), examrecords.NewInMemoryStore(), drillStore, dispatch.NewInMemoryStore(), opinion.NewInMemoryStore(), evaluation.NewInMemoryStore(), evaluation.NewInMemoryScoreStore(), evaluation.NewInMemoryReportStore(), drills.NewTimelineService(drills.NewInMemoryTimelineStore(), drillStore), nil)
 }
 
 func get(handler http.Handler, target string, header map[string]string) *httptest.ResponseRecorder {
@@ -0,0 +1,123 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// ─── /orders/{oid}/history + /departments/{department}/history ───────
+
+// transitionListJSON mirrors the history response for assertions.
+type transitionListJSON struct {
+	Records []struct {
+		ID        string `json:"id"`
+		From      string `json:"from"`
+		To        string `json:"to"`
+		Actor     string `json:"actor"`
+		Note      string `json:"note"`
+		CreatedAt string `json:"created_at"`
+	} `json:"records"`
+	Meta struct {
+		Total int `json:"total"`
+	} `json:"meta"`
+}
+
+func decodeTransitions(t *testing.T, recorder *httptest.ResponseRecorder) transitionListJSON {
+	t.Helper()
+	var list transitionListJSON
+	if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+		t.Fatalf("body %q is not a history JSON: %v", recorder.Body.String(), err)
+	}
+	return list
+}
+
+// 指令历史：下达一条（空→待接收）；PUT 的 actor/note 记录在状态变化的
+// 条目上，只改 feedback 不追加；最后一条时刻等于 completed_at。指令或
+// run 不存在 404；非 GET 405。
+func TestOrderHistoryRoute(t *testing.T) {
+	handler := testMux(nil)
+	run := mustCreateInProgressRun(t, handler, validScenarioBody)
+	order := createOrder(t, handler, run.ID, `{"title":"疏散","content":"引导","target_type":"部门","target_name":"疏散组","created_by":"指挥长"}`)
+	item := orderItemPath(run.ID, order.ID)
+	var completed orderJSON
+	for _, body := range []string{
+		`{"status":"已接收","actor":"疏散组","note":"收到"}`,
+		`{"feedback":"进行中"}`,
+		`{"status":"执行中","actor":"疏散组"}`,
+		`{"status":"已完成","actor":"疏散组","note":"已清空"}`,
+	} {
+		recorder := do(handler, http.MethodPut, item, body)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("PUT %s: status = %d; body = %s", body, recorder.Code, recorder.Body.String())
+		}
+		completed = decodeOrder(t, recorder)
+	}
+
+	recorder := get(handler, item+"/history", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("GET status = %d; body = %s", recorder.Code, recorder.Body.String())
+	}
+	list := decodeTransitions(t, recorder)
+	var got []string
+	for _, record := range list.Records {
+		got = append(got, record.From+"→"+record.To+"/"+record.Actor+"/"+record.Note)
+	}
+	want := []string{"→待接收/指挥长/", "待接收→已接收/疏散组/收到", "已接收→执行中/疏散组/", "执行中→已完成/疏散组/已清空"}
+	if list.Meta.Total != 4 || strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("history = %v (total %d), want %v", got, list.Meta.Total, want)
+	}
+	if list.Records[3].CreatedAt != *completed.CompletedAt {
+		t.Fatalf("completion entry at %s, want completed_at %s", list.Records[3].CreatedAt, *completed.CompletedAt)
+	}
+
+	for path, want := range map[string]int{
+		orderItemPath(run.ID, "ord-missing") + "/history":   http.StatusNotFound,
+		orderItemPath("run-missing", order.ID) + "/history": http.StatusNotFound,
+	} {
+		if recorder := get(handler, path, nil); recorder.Code != want {
+			t.Errorf("GET %s: status = %d, want %d", path, recorder.Code, want)
+		}
+	}
+	if recorder := do(handler, http.MethodPost, item+"/history", "{}"); recorder.Code != http.StatusMethodNotAllowed || recorder.Header().Get("Allow") != "GET" {
+		t.Fatalf("POST: status = %d, Allow = %q", recorder.Code, recorder.Header().Get("Allow"))
+	}
+}
+
+// 部门历史：创建一条、状态变化各一条，actor 缺省回落到 created_by；
+// 部门无记录 404，非法部门 400。
+func TestDepartmentHistoryRoute(t *testing.T) {
+	handler := testMux(nil)
+	run := newDepartmentRun(t, handler)
+	putDepartment(t, handler, run.ID, "消防", `{"created_by":"值班员"}`)
+	putDepartment(t, handler, run.ID, "消防", `{"status":"已响应","note":"已出警","created_by":"值班员","actor":"消防指挥"}`)
+	putDepartment(t, handler, run.ID, "消防", `{"status":"已到位","created_by":"值班员"}`)
+
+	recorder := get(handler, departmentItemPath(run.ID, "消防")+"/history", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("GET status = %d; body = %s", recorder.Code, recorder.Body.String())
+	}
+	list := decodeTransitions(t, recorder)
+	var got []string
+	for _, record := range list.Records {
+		got = append(got, record.From+"→"+record.To+"/"+record.Actor)
+	}
+	want := []string{"→未响应/值班员", "未响应→已响应/消防指挥", "已响应→已到位/值班员"}
+	if list.Meta.Total != 3 || strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("history = %v (total %d), want %v", got, list.Meta.Total, want)
+	}
+
+	for department, want := range map[string]int{
+		"公安": http.StatusNotFound,
+		"交警": http.StatusBadRequest,
+	} {
+		if recorder := get(handler, departmentItemPath(run.ID, department)+"/history", nil); recorder.Code != want {
+			t.Errorf("GET %s history: status = %d, want %d", department, recorder.Code, want)
+		}
+	}
+	if recorder := do(handler, http.MethodDelete, departmentItemPath(run.ID, "消防")+"/history", ""); recorder.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("DELETE: status = %d, want 405", recorder.Code)
+	}
+}
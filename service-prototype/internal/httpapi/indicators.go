@@ -57,14 +57,14 @@ func (h *indicatorsHandler) handleItem(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// indicatorBody mirrors the client-supplied fields of the request body.
-// id and the timestamps are never accepted from the client (the id is a
+// indicatorBody mirrors the client-supplied fields of the request body. id
+// and the timestamps are never accepted from the client (the id is a
 // server-generated ULID, the timestamps are server-maintained). weight,
 // demo and sort_order are pointers so a missing field can be told apart
 // from an explicit zero: an omitted weight is replaced by the default 1,
 // while an explicit 0 (or any value below 1) is a 400. description and
-// created_by are optional (empty when omitted) because the prototype has
-// no auth context.
+// created_by are optional (empty when omitted; the authenticated subject
+// replaces it when the router authenticates).
 type indicatorBody struct {
 	Dimension   evaluation.Dimension `json:"dimension"`
 	Title       string               `json:"title"`
@@ -75,7 +75,7 @@ type indicatorBody struct {
 	CreatedBy   string               `json:"created_by"`
 }
 
-func (body indicatorBody) input() evaluation.IndicatorInput {
+func (body indicatorBody) input(createdBy string) evaluation.IndicatorInput {
 	return evaluation.IndicatorInput{
 		Dimension:   body.Dimension,
 		Title:       body.Title,
@@ -83,7 +83,7 @@ func (body indicatorBody) input() evaluation.IndicatorInput {
 		Demo:        body.Demo,
 		SortOrder:   body.SortOrder,
 		Description: body.Description,
-		CreatedBy:   body.CreatedBy,
+		CreatedBy:   createdBy,
 	}
 }
 
@@ -104,7 +104,7 @@ func (h *indicatorsHandler) create(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	indicator, err := h.service.CreateIndicator(r.Context(), body.input())
+	indicator, err := h.service.CreateIndicator(r.Context(), body.input(requestActor(r, body.CreatedBy)))
 	if err != nil {
 		writeIndicatorError(w, err)
 		return
@@ -146,7 +146,15 @@ func (h *indicatorsHandler) update(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	indicator, err := h.service.UpdateIndicator(r.Context(), r.PathValue("id"), body.input())
+	createdBy, err := storedCreatedBy(r, body.CreatedBy, func() (string, error) {
+		stored, err := h.service.GetIndicator(r.Context(), r.PathValue("id"))
+		return stored.CreatedBy, err
+	})
+	if err != nil {
+		writeIndicatorError(w, err)
+		return
+	}
+	indicator, err := h.service.UpdateIndicator(r.Context(), r.PathValue("id"), body.input(createdBy))
 	if err != nil {
 		writeIndicatorError(w, err)
 		return
@@ -0,0 +1,258 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/drills"
+)
+
+// injectsBase is the unified resource path of the scenario timeline
+// injects (演练时间线). The collection lives under the owning scenario
+// (/scenarios/{sid}/injects); the item routes live at /injects/{id}
+// (same layout as the steps and assessment points).
+const injectsBase = prototypePrefix + "/injects"
+
+// runInjectsBase is the unified resource path of the scheduled injects
+// of a drill run. The collection and the item routes live under the
+// owning run (/drills/{rid}/injects and /drills/{rid}/injects/{iid});
+// the literal injects segment is more specific than the /drills/{id}
+// item route and never collides with it (same pattern as the sim-event
+// routes).
+const runInjectsBase = prototypePrefix + "/drills/{rid}/injects"
+
+// injectsHandler adapts the timeline service to the HTTP routing layer.
+// It serves the per-scenario timeline (GET list / POST create, item GET
+// / PUT / DELETE by id) and the schedule of a run (GET list / POST a
+// live inject, item GET, POST …/cancel); other methods yield a JSON 405
+// with Allow. Live injects require the run to be 进行中 (400 otherwise);
+// a missing scenario or run is a 404 on every route.
+type injectsHandler struct {
+	service *drills.TimelineService
+}
+
+func newInjectsHandler(timeline *drills.TimelineService) *injectsHandler {
+	return &injectsHandler{service: timeline}
+}
+
+func (h *injectsHandler) handleScenarioInjects(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.list(w, r)
+	case http.MethodPost:
+		h.create(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *injectsHandler) handleItem(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.get(w, r)
+	case http.MethodPut:
+		h.update(w, r)
+	case http.MethodDelete:
+		h.delete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *injectsHandler) handleRunInjects(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listRun(w, r)
+	case http.MethodPost:
+		h.addRun(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *injectsHandler) handleRunItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	inject, err := h.service.GetRunInject(r.Context(), r.PathValue("rid"), r.PathValue("iid"))
+	if err != nil {
+		writeInjectError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, inject)
+}
+
+func (h *injectsHandler) handleRunCancel(w http.ResponseWriter, r *http.Request) {
+	inject, err := h.service.CancelRunInject(r.Context(), r.PathValue("rid"), r.PathValue("iid"))
+	if err != nil {
+		writeInjectError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, inject)
+}
+
+// injectBody mirrors the client-supplied fields of the request body. The
+// owning scenario or run is never part of the body: it is decided by the
+// route path. offset_seconds counts from the started_at of the run
+// (default 0, must not be negative); event_type is required and must match
+// the scenario category (or be 其他); payload_template is an optional JSON
+// object whose string values may carry the ${run_id}, ${run_title},
+// ${scenario_id}, ${offset_seconds} and ${fired_at} placeholders;
+// created_by is optional (empty when omitted; the authenticated subject
+// replaces it when the router authenticates). payload_template is kept as
+// raw JSON so the handler can reject every non-object value.
+type injectBody struct {
+	OffsetSeconds   int             `json:"offset_seconds"`
+	EventType       string          `json:"event_type"`
+	PayloadTemplate json.RawMessage `json:"payload_template"`
+	CreatedBy       string          `json:"created_by"`
+}
+
+// decodeInjectInput reads a single JSON object from the request body and
+// converts it to the service input; a malformed or empty body, or a
+// payload_template that is not a JSON object, yields a 400
+// { "error": ... } response.
+func decodeInjectInput(w http.ResponseWriter, r *http.Request) (drills.InjectInput, bool) {
+	var body injectBody
+	decoder := json.NewDecoder(io.LimitReader(r.Body, 1<<20))
+	if err := decoder.Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return drills.InjectInput{}, false
+	}
+	var template map[string]any
+	if body.PayloadTemplate != nil {
+		trimmed := strings.TrimSpace(string(body.PayloadTemplate))
+		if trimmed == "null" || json.Unmarshal(body.PayloadTemplate, &template) != nil {
+			writeError(w, http.StatusBadRequest, "payload_template must be a JSON object")
+			return drills.InjectInput{}, false
+		}
+	}
+	return drills.InjectInput{
+		OffsetSeconds:   body.OffsetSeconds,
+		EventType:       drills.SimEventType(body.EventType),
+		PayloadTemplate: template,
+		CreatedBy:       requestActor(r, body.CreatedBy),
+	}, true
+}
+
+func (h *injectsHandler) create(w http.ResponseWriter, r *http.Request) {
+	input, ok := decodeInjectInput(w, r)
+	if !ok {
+		return
+	}
+	inject, err := h.service.CreateInject(r.Context(), r.PathValue("sid"), input)
+	if err != nil {
+		writeInjectError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, inject)
+}
+
+// injectListResponse follows the repository list convention:
+// { "records": [...], "meta": { "total": N } }.
+type injectListResponse struct {
+	Records []drills.Inject `json:"records"`
+	Meta    metaResponse    `json:"meta"`
+}
+
+func (h *injectsHandler) list(w http.ResponseWriter, r *http.Request) {
+	filter, ok := parseDrillChildListFilter(w, r)
+	if !ok {
+		return
+	}
+	records, total, err := h.service.ListInjects(r.Context(), r.PathValue("sid"), filter)
+	if err != nil {
+		writeInjectError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, injectListResponse{Records: records, Meta: metaResponse{Total: total}})
+}
+
+func (h *injectsHandler) get(w http.ResponseWriter, r *http.Request) {
+	inject, err := h.service.GetInject(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeInjectError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, inject)
+}
+
+func (h *injectsHandler) update(w http.ResponseWriter, r *http.Request) {
+	input, ok := decodeInjectInput(w, r)
+	if !ok {
+		return
+	}
+	inject, err := h.service.UpdateInject(r.Context(), r.PathValue("id"), input)
+	if err != nil {
+		writeInjectError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, inject)
+}
+
+func (h *injectsHandler) delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.DeleteInject(r.Context(), r.PathValue("id")); err != nil {
+		writeInjectError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *injectsHandler) addRun(w http.ResponseWriter, r *http.Request) {
+	input, ok := decodeInjectInput(w, r)
+	if !ok {
+		return
+	}
+	inject, err := h.service.AddRunInject(r.Context(), r.PathValue("rid"), input)
+	if err != nil {
+		writeInjectError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, inject)
+}
+
+// runInjectListResponse follows the repository list convention:
+// { "records": [...], "meta": { "total": N } }.
+type runInjectListResponse struct {
+	Records []drills.RunInject `json:"records"`
+	Meta    metaResponse       `json:"meta"`
+}
+
+func (h *injectsHandler) listRun(w http.ResponseWriter, r *http.Request) {
+	filter, ok := parseDrillChildListFilter(w, r)
+	if !ok {
+		return
+	}
+	records, total, err := h.service.ListRunInjects(r.Context(), r.PathValue("rid"), filter)
+	if err != nil {
+		writeInjectError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, runInjectListResponse{Records: records, Meta: metaResponse{Total: total}})
+}
+
+// writeInjectError maps the timeline service errors to JSON error
+// responses: validation errors become 400, unknown scenarios, runs or
+// injects 404, everything else 500.
+func writeInjectError(w http.ResponseWriter, err error) {
+	var validationError *drills.ValidationError
+	switch {
+	case errors.As(err, &validationError):
+		writeError(w, http.StatusBadRequest, validationError.Message)
+	case errors.Is(err, drills.ErrScenarioNotFound),
+		errors.Is(err, drills.ErrRunNotFound),
+		errors.Is(err, drills.ErrInjectNotFound),
+		errors.Is(err, drills.ErrRunInjectNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, "internal error")
+	}
+}
@@ -0,0 +1,199 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// ─── 测试辅助 ────────────────────────────────────────────────────────
+
+// injectsPathPrefix is the item path prefix of the scenario injects.
+const injectsPathPrefix = "/crate-api/prototype/v1/injects/"
+
+// runInjectJSON mirrors the scheduled inject response for assertions.
+type runInjectJSON struct {
+	ID               string  `json:"id"`
+	RunID            string  `json:"run_id"`
+	ScenarioInjectID string  `json:"scenario_inject_id"`
+	OffsetSeconds    int     `json:"offset_seconds"`
+	EventType        string  `json:"event_type"`
+	Status           string  `json:"status"`
+	FireAt           string  `json:"fire_at"`
+	FiredAt          *string `json:"fired_at"`
+	SimEventID       string  `json:"sim_event_id"`
+}
+
+type runInjectListJSON struct {
+	Records []runInjectJSON `json:"records"`
+	Meta    struct {
+		Total int `json:"total"`
+	} `json:"meta"`
+}
+
+func decodeRunInject(t *testing.T, recorder *httptest.ResponseRecorder) runInjectJSON {
+	t.Helper()
+	var inject runInjectJSON
+	if err := json.Unmarshal(recorder.Body.Bytes(), &inject); err != nil {
+		t.Fatalf("body %q is not an inject JSON: %v", recorder.Body.String(), err)
+	}
+	return inject
+}
+
+// waitForRunInjects polls the schedule of the run until every inject
+// left 待触发 or two seconds passed, and returns the last listing.
+func waitForRunInjects(t *testing.T, handler http.Handler, runID string) runInjectListJSON {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		recorder := get(handler, runsPath+"/"+runID+"/injects", nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("GET injects status = %d; body = %s", recorder.Code, recorder.Body.String())
+		}
+		var list runInjectListJSON
+		if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+			t.Fatalf("body %q is not a list JSON: %v", recorder.Body.String(), err)
+		}
+		settled := true
+		for _, inject := range list.Records {
+			if inject.Status == "待触发" {
+				settled = false
+			}
+		}
+		if settled || time.Now().After(deadline) {
+			return list
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// ─── /scenarios/{sid}/injects + /injects/{id} ────────────────────────
+
+// 场景时间线的增查改删：201 回显 offset/类型/模板；类型与场景分类不符 400；
+// 模板非对象 400；场景不存在 404；删除后 404。
+func TestScenarioInjectRoutes(t *testing.T) {
+	handler := testMux(nil)
+	scenario := createScenario(t, handler, validScenarioBody)
+	collection := scenariosPath + "/" + scenario.ID + "/injects"
+
+	recorder := do(handler, http.MethodPost, collection, `{"offset_seconds":120,"event_type":"客流密度超阈值","payload_template":{"zone":"${run_title}"}}`)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("POST status = %d, want 201; body = %s", recorder.Code, recorder.Body.String())
+	}
+	var created struct {
+		ID              string         `json:"id"`
+		ScenarioID      string         `json:"scenario_id"`
+		OffsetSeconds   int            `json:"offset_seconds"`
+		PayloadTemplate map[string]any `json:"payload_template"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &created); err != nil || !ulidPattern.MatchString(created.ID) {
+		t.Fatalf("created = %+v (%v), want a ULID id", created, err)
+	}
+	if created.ScenarioID != scenario.ID || created.OffsetSeconds != 120 || created.PayloadTemplate["zone"] != "${run_title}" {
+		t.Fatalf("created = %+v, want the echoed inject", created)
+	}
+
+	for body, want := range map[string]int{
+		`{"event_type":"烟感探测器触发"}`:                     http.StatusBadRequest,
+		`{"event_type":"其他","offset_seconds":-5}`:      http.StatusBadRequest,
+		`{"event_type":"其他","payload_template":[1,2]}`: http.StatusBadRequest,
+		`{"event_type":"其他","payload_template":null}`:  http.StatusBadRequest,
+		`not json`: http.StatusBadRequest,
+	} {
+		if recorder := do(handler, http.MethodPost, collection, body); recorder.Code != want {
+			t.Errorf("POST %s: status = %d, want %d", body, recorder.Code, want)
+		}
+	}
+	if recorder := do(handler, http.MethodPost, scenariosPath+"/scn-missing/injects", `{"event_type":"其他"}`); recorder.Code != http.StatusNotFound {
+		t.Fatalf("missing scenario: status = %d, want 404", recorder.Code)
+	}
+
+	item := injectsPathPrefix + created.ID
+	if recorder := do(handler, http.MethodPut, item, `{"offset_seconds":300,"event_type":"其他"}`); recorder.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d; body = %s", recorder.Code, recorder.Body.String())
+	}
+	recorder = get(handler, collection, nil)
+	if recorder.Code != http.StatusOK || !json.Valid(recorder.Body.Bytes()) {
+		t.Fatalf("GET collection status = %d", recorder.Code)
+	}
+	if recorder := do(handler, http.MethodDelete, item, ""); recorder.Code != http.StatusNoContent {
+		t.Fatalf("DELETE status = %d, want 204", recorder.Code)
+	}
+	if recorder := get(handler, item, nil); recorder.Code != http.StatusNotFound {
+		t.Fatalf("GET after delete = %d, want 404", recorder.Code)
+	}
+	if recorder := do(handler, http.MethodPatch, item, ""); recorder.Code != http.StatusMethodNotAllowed || recorder.Header().Get("Allow") != "GET, PUT, DELETE" {
+		t.Fatalf("PATCH: status = %d, Allow = %q", recorder.Code, recorder.Header().Get("Allow"))
+	}
+}
+
+// ─── /drills/{rid}/injects ───────────────────────────────────────────
+
+// 开始演练后场景时间线被复制进 run；offset 0 的 inject 立即触发并生成
+// created_by=timeline 的模拟事件，payload 中的占位符被替换。
+func TestStartedRunFiresTheScenarioTimeline(t *testing.T) {
+	handler := testMux(nil)
+	scenario := createScenario(t, handler, validScenarioBody)
+	recorder := do(handler, http.MethodPost, scenariosPath+"/"+scenario.ID+"/injects",
+		`{"offset_seconds":0,"event_type":"客流密度超阈值","payload_template":{"run":"${run_id}","people_count":850}}`)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("POST inject status = %d; body = %s", recorder.Code, recorder.Body.String())
+	}
+	run := createRun(t, handler, scenario.ID, "")
+	startRun(t, handler, run.ID)
+
+	list := waitForRunInjects(t, handler, run.ID)
+	if list.Meta.Total != 1 || list.Records[0].Status != "已触发" || list.Records[0].SimEventID == "" {
+		t.Fatalf("schedule = %+v, want the inject fired", list)
+	}
+	event := decodeSimEvent(t, get(handler, simEventItemPath(run.ID, list.Records[0].SimEventID), nil))
+	if event.CreatedBy != "timeline" || event.Payload["run"] != run.ID || event.Payload["people_count"] != float64(850) {
+		t.Fatalf("sim event = %+v, want the rendered timeline event", event)
+	}
+}
+
+// 进行中追加 inject（201，待触发），单条取消（200，已取消），重复取消 400；
+// 结束演练后追加 400；run 或 inject 不存在 404。
+func TestLiveRunInjectRoutes(t *testing.T) {
+	handler := testMux(nil)
+	run := mustCreateInProgressRun(t, handler, validScenarioBody)
+	collection := runsPath + "/" + run.ID + "/injects"
+
+	recorder := do(handler, http.MethodPost, collection, `{"offset_seconds":3600,"event_type":"其他","created_by":"导演组"}`)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("POST status = %d, want 201; body = %s", recorder.Code, recorder.Body.String())
+	}
+	inject := decodeRunInject(t, recorder)
+	if inject.Status != "待触发" || inject.ScenarioInjectID != "" || inject.FireAt == "" {
+		t.Fatalf("live inject = %+v, want a pending inject without a template", inject)
+	}
+	if got := decodeRunInject(t, get(handler, collection+"/"+inject.ID, nil)); got.ID != inject.ID {
+		t.Fatalf("GET item = %+v, want %s", got, inject.ID)
+	}
+
+	recorder = do(handler, http.MethodPost, collection+"/"+inject.ID+"/cancel", "")
+	if recorder.Code != http.StatusOK || decodeRunInject(t, recorder).Status != "已取消" {
+		t.Fatalf("cancel: status = %d; body = %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := do(handler, http.MethodPost, collection+"/"+inject.ID+"/cancel", ""); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("second cancel: status = %d, want 400", recorder.Code)
+	}
+	if recorder := do(handler, http.MethodPost, collection+"/inj-missing/cancel", ""); recorder.Code != http.StatusNotFound {
+		t.Fatalf("missing inject: status = %d, want 404", recorder.Code)
+	}
+	if recorder := get(handler, runsPath+"/run-missing/injects", nil); recorder.Code != http.StatusNotFound {
+		t.Fatalf("missing run: status = %d, want 404", recorder.Code)
+	}
+	if recorder := do(handler, http.MethodPut, collection+"/"+inject.ID, `{}`); recorder.Code != http.StatusMethodNotAllowed || recorder.Header().Get("Allow") != "GET" {
+		t.Fatalf("PUT item: status = %d, Allow = %q", recorder.Code, recorder.Header().Get("Allow"))
+	}
+
+	if recorder := do(handler, http.MethodPost, runsPath+"/"+run.ID+"/complete", ""); recorder.Code != http.StatusOK {
+		t.Fatalf("complete status = %d", recorder.Code)
+	}
+	if recorder := do(handler, http.MethodPost, collection, `{"event_type":"其他"}`); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("inject after complete: status = %d, want 400", recorder.Code)
+	}
+}
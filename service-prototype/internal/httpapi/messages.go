@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/auth"
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/dispatch"
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/drills"
 )
@@ -62,14 +63,14 @@ func (h *messagesHandler) handleItem(w http.ResponseWriter, r *http.Request) {
 }
 
 // messageBody mirrors the client-supplied fields of a message creation.
-// id, run_id, sent_at and the timestamps are never part of the body:
-// id is server-generated, run_id comes from the route path (a body that
-// carries run_id has it ignored), sent_at is set by the service at
-// creation (a message is sent the moment it is created, so a body that
-// carries sent_at has it ignored). sender_type and content are
-// required; sender_name defaults to an empty string; created_by passes
-// through (empty when omitted) because the prototype has no auth
-// context.
+// id, run_id, sent_at and the timestamps are never part of the body: id is
+// server-generated, run_id comes from the route path (a body that carries
+// run_id has it ignored), sent_at is set by the service at creation (a
+// message is sent the moment it is created, so a body that carries sent_at
+// has it ignored). sender_type and content are required; sender_name
+// defaults to an empty string; created_by passes through (empty when
+// omitted; the authenticated subject replaces it when the router
+// authenticates).
 type messageBody struct {
 	SenderType string `json:"sender_type"`
 	SenderName string `json:"sender_name"`
@@ -77,16 +78,30 @@ type messageBody struct {
 	CreatedBy  string `json:"created_by"`
 }
 
+// messageSenderRoles maps each sender_type to the role allowed to send
+// in its name on an authenticating router.
+var messageSenderRoles = map[dispatch.SenderType]auth.Role{
+	dispatch.SenderTypeCommand: auth.RoleDirector,
+	dispatch.SenderTypeField:   auth.RoleResponder,
+}
+
 func (h *messagesHandler) create(w http.ResponseWriter, r *http.Request) {
 	var body messageBody
 	if !decodeOrderJSON(w, r, &body) {
 		return
 	}
+	// The route admits both the command center and the field (see
+	// routePolicy); the sender_type decides which one speaks, so a
+	// 现场人员 message needs the responder role and a 指挥中心 message the
+	// director role. An invalid sender_type is left to the service (400).
+	if role, ok := messageSenderRoles[dispatch.SenderType(body.SenderType)]; ok && !requireRole(w, r, role) {
+		return
+	}
 	message, err := h.service.CreateMessage(r.Context(), r.PathValue("rid"), dispatch.MessageInput{
 		SenderType: dispatch.SenderType(body.SenderType),
 		SenderName: body.SenderName,
 		Content:    body.Content,
-		CreatedBy:  body.CreatedBy,
+		CreatedBy:  requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeMessageError(w, err)
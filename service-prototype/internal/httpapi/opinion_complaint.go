@@ -70,22 +70,21 @@ func (h *opinionComplaintHandler) handleItem(w http.ResponseWriter, r *http.Requ
 	}
 }
 
-// opinionComplaintBody mirrors the client-supplied fields of the
-// request body. run_id, id and the timestamps are never part of the
-// body: run_id comes from the route path, id is server-generated and
-// the timestamps are server-managed (a body that carries them has them
-// ignored). complainant and content are required on both create and
-// update; channel defaults to 现场 (create) or keeps its current value
-// (update) and must be one of the allowed values; complaint_type
-// defaults to 入馆受阻 (create) or keeps its current value (update) and
-// must be one of the allowed values; status defaults to 待受理 on
-// create (a new complaint only accepts 待受理) or keeps its current
-// value on update; handling / handler pass through (” when omitted);
-// metadata is kept as raw JSON so the handler can tell an omitted field
-// from an explicit null and reject every non-object value; created_by
-// passes through (empty when omitted) because the prototype has no auth
-// context. closed_at is never part of the body: it is managed by the
-// service.
+// opinionComplaintBody mirrors the client-supplied fields of the request
+// body. run_id, id and the timestamps are never part of the body: run_id
+// comes from the route path, id is server-generated and the timestamps are
+// server-managed (a body that carries them has them ignored). complainant
+// and content are required on both create and update; channel defaults to
+// 现场 (create) or keeps its current value (update) and must be one of the
+// allowed values; complaint_type defaults to 入馆受阻 (create) or keeps its
+// current value (update) and must be one of the allowed values; status
+// defaults to 待受理 on create (a new complaint only accepts 待受理) or keeps
+// its current value on update; handling / handler pass through (” when
+// omitted); metadata is kept as raw JSON so the handler can tell an
+// omitted field from an explicit null and reject every non-object value;
+// created_by passes through (empty when omitted; the authenticated subject
+// replaces it when the router authenticates). closed_at is never part of
+// the body: it is managed by the service.
 type opinionComplaintBody struct {
 	Complainant   string                   `json:"complainant"`
 	Channel       opinion.ComplaintChannel `json:"channel"`
@@ -159,7 +158,7 @@ func (h *opinionComplaintHandler) create(w http.ResponseWriter, r *http.Request)
 		Handling:      body.Handling,
 		Handler:       body.Handler,
 		Metadata:      metadata,
-		CreatedBy:     body.CreatedBy,
+		CreatedBy:     requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeOpinionComplaintError(w, err)
@@ -268,7 +267,7 @@ func (h *opinionComplaintHandler) update(w http.ResponseWriter, r *http.Request)
 		Handler:       body.Handler,
 		Metadata:      metadata,
 		HasMetadata:   hasMetadata,
-		CreatedBy:     body.CreatedBy,
+		CreatedBy:     patchCreatedBy(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeOpinionComplaintError(w, err)
@@ -136,7 +136,7 @@ func (h *opinionEventHandler) upsert(w http.ResponseWriter, r *http.Request) {
 		Level:      body.Level,
 		Status:     body.Status,
 		Metadata:   body.Metadata,
-		CreatedBy:  body.CreatedBy,
+		CreatedBy:  requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeOpinionEventError(w, err)
@@ -71,17 +71,17 @@ func (h *opinionMediaQuestionHandler) handleItem(w http.ResponseWriter, r *http.
 }
 
 // opinionMediaQuestionBody mirrors the client-supplied fields of the
-// request body. run_id, id and the timestamps are never part of the
-// body: run_id comes from the route path, id is server-generated and
-// the timestamps are server-managed (a body that carries them has them
+// request body. run_id, id and the timestamps are never part of the body:
+// run_id comes from the route path, id is server-generated and the
+// timestamps are server-managed (a body that carries them has them
 // ignored). media_name and question are required on create; reporter
-// defaults to ''; question_type defaults to 事实类 and must be one of
-// the allowed values; answer defaults to '' (editable at any time on
-// update); status defaults to 未回答 and a new question only accepts
-// 未回答; metadata is kept as raw JSON so the handler can tell an
-// omitted field from an explicit null and reject every non-object
-// value; created_by passes through (empty when omitted) because the
-// prototype has no auth context.
+// defaults to ''; question_type defaults to 事实类 and must be one of the
+// allowed values; answer defaults to '' (editable at any time on update);
+// status defaults to 未回答 and a new question only accepts 未回答; metadata is
+// kept as raw JSON so the handler can tell an omitted field from an
+// explicit null and reject every non-object value; created_by passes
+// through (empty when omitted; the authenticated subject replaces it when
+// the router authenticates).
 type opinionMediaQuestionBody struct {
 	MediaName    string               `json:"media_name"`
 	Reporter     string               `json:"reporter"`
@@ -152,7 +152,7 @@ func (h *opinionMediaQuestionHandler) create(w http.ResponseWriter, r *http.Requ
 		Answer:       body.Answer,
 		Status:       body.Status,
 		Metadata:     metadata,
-		CreatedBy:    body.CreatedBy,
+		CreatedBy:    requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeOpinionMediaQuestionError(w, err)
@@ -252,7 +252,7 @@ func (h *opinionMediaQuestionHandler) update(w http.ResponseWriter, r *http.Requ
 		Status:       body.Status,
 		Metadata:     metadata,
 		HasMetadata:  hasMetadata,
-		CreatedBy:    body.CreatedBy,
+		CreatedBy:    patchCreatedBy(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeOpinionMediaQuestionError(w, err)
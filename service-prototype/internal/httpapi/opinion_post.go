@@ -68,18 +68,17 @@ func (h *opinionPostHandler) handleItem(w http.ResponseWriter, r *http.Request)
 	}
 }
 
-// opinionPostBody mirrors the client-supplied fields of the request
-// body. run_id, id and the timestamps are never part of the body:
-// run_id comes from the route path, id is server-generated and the
-// timestamps are server-managed (a body that carries them has them
-// ignored). content is required on create; source defaults to 微博,
-// sentiment to 负面, warn_status to 未预警 (a new post only accepts
-// 未预警); heat is kept as raw JSON so the handler can tell an omitted
-// field from an explicit value and reject every non-integer; metadata
-// is kept as raw JSON so the handler can tell an omitted field from an
-// explicit null and reject every non-object value; created_by passes
-// through (empty when omitted) because the prototype has no auth
-// context.
+// opinionPostBody mirrors the client-supplied fields of the request body.
+// run_id, id and the timestamps are never part of the body: run_id comes
+// from the route path, id is server-generated and the timestamps are
+// server-managed (a body that carries them has them ignored). content is
+// required on create; source defaults to 微博, sentiment to 负面, warn_status
+// to 未预警 (a new post only accepts 未预警); heat is kept as raw JSON so the
+// handler can tell an omitted field from an explicit value and reject
+// every non-integer; metadata is kept as raw JSON so the handler can tell
+// an omitted field from an explicit null and reject every non-object
+// value; created_by passes through (empty when omitted; the authenticated
+// subject replaces it when the router authenticates).
 type opinionPostBody struct {
 	Content    string             `json:"content"`
 	Source     opinion.Source     `json:"source"`
@@ -185,7 +184,7 @@ func (h *opinionPostHandler) create(w http.ResponseWriter, r *http.Request) {
 		Heat:       heat,
 		WarnStatus: body.WarnStatus,
 		Metadata:   metadata,
-		CreatedBy:  body.CreatedBy,
+		CreatedBy:  requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeOpinionPostError(w, err)
@@ -296,7 +295,7 @@ func (h *opinionPostHandler) update(w http.ResponseWriter, r *http.Request) {
 		WarnStatus:  body.WarnStatus,
 		Metadata:    metadata,
 		HasMetadata: hasMetadata,
-		CreatedBy:   body.CreatedBy,
+		CreatedBy:   patchCreatedBy(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeOpinionPostError(w, err)
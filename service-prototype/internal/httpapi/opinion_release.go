@@ -70,19 +70,19 @@ func (h *opinionReleaseHandler) handleItem(w http.ResponseWriter, r *http.Reques
 }
 
 // opinionReleaseBody mirrors the client-supplied fields of the request
-// body. run_id, id and the timestamps are never part of the body:
-// run_id comes from the route path, id is server-generated and the
-// timestamps are server-managed (a body that carries them has them
-// ignored). title and content are required on both create and update;
-// channel defaults to 官网公告 (create) or keeps its current value
-// (update) and must be one of the allowed values; media_name passes
-// through ('' when omitted, not coupled to the channel value);
-// status defaults to 草稿 on create (a new release only accepts 草稿)
-// or keeps its current value on update; metadata is kept as raw JSON so
-// the handler can tell an omitted field from an explicit null and
-// reject every non-object value; created_by passes through (empty when
-// omitted) because the prototype has no auth context. published_at is
-// never part of the body: it is managed by the service.
+// body. run_id, id and the timestamps are never part of the body: run_id
+// comes from the route path, id is server-generated and the timestamps are
+// server-managed (a body that carries them has them ignored). title and
+// content are required on both create and update; channel defaults to 官网公告
+// (create) or keeps its current value (update) and must be one of the
+// allowed values; media_name passes through ('' when omitted, not coupled
+// to the channel value); status defaults to 草稿 on create (a new release
+// only accepts 草稿) or keeps its current value on update; metadata is kept
+// as raw JSON so the handler can tell an omitted field from an explicit
+// null and reject every non-object value; created_by passes through (empty
+// when omitted; the authenticated subject replaces it when the router
+// authenticates). published_at is never part of the body: it is managed by
+// the service.
 type opinionReleaseBody struct {
 	Channel   opinion.Channel       `json:"channel"`
 	Title     string                `json:"title"`
@@ -152,7 +152,7 @@ func (h *opinionReleaseHandler) create(w http.ResponseWriter, r *http.Request) {
 		MediaName: body.MediaName,
 		Status:    body.Status,
 		Metadata:  metadata,
-		CreatedBy: body.CreatedBy,
+		CreatedBy: requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeOpinionReleaseError(w, err)
@@ -250,7 +250,7 @@ func (h *opinionReleaseHandler) update(w http.ResponseWriter, r *http.Request) {
 		Status:      body.Status,
 		Metadata:    metadata,
 		HasMetadata: hasMetadata,
-		CreatedBy:   body.CreatedBy,
+		CreatedBy:   patchCreatedBy(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeOpinionReleaseError(w, err)
@@ -103,7 +103,7 @@ func (h *opinionReviewHandler) upsert(w http.ResponseWriter, r *http.Request) {
 		Lessons:     body.Lessons,
 		Suggestions: body.Suggestions,
 		Metadata:    body.Metadata,
-		CreatedBy:   body.CreatedBy,
+		CreatedBy:   requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeOpinionReviewError(w, err)
@@ -22,8 +22,9 @@ import (
 const ordersBase = prototypePrefix + "/drills/{rid}/orders"
 
 // ordersHandler adapts the dispatch service to the HTTP routing layer.
-// It serves the per-run order collection (GET list / POST create) and
-// the item routes (GET / PUT / DELETE by order id); other methods yield
+// It serves the per-run order collection (GET list / POST create), the
+// item routes (GET / PUT / DELETE by order id) and the read-only status
+// history of an order (GET …/orders/{oid}/history); other methods yield
 // a JSON 405 with Allow. The owning run comes from the route path:
 // writes require the run to be 进行中 (400 otherwise) and a missing run
 // is a 404 on every route.
@@ -64,15 +65,24 @@ func (h *ordersHandler) handleItem(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// orderBody mirrors the client-supplied fields of an order creation.
-// id, run_id and the timestamps are never part of the body: id is
-// server-generated, run_id comes from the route path (a body that
-// carries run_id has it ignored), issued_at is set by the service at
-// creation. title/content/target_type/target_name are required;
-// priority defaults to 普通; status defaults to 待接收 (an explicit value
-// must be exactly 待接收); feedback defaults to an empty string;
-// deadline is an optional RFC3339 instant; created_by passes through
-// (empty when omitted) because the prototype has no auth context.
+func (h *ordersHandler) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	h.history(w, r)
+}
+
+// orderBody mirrors the client-supplied fields of an order creation. id,
+// run_id and the timestamps are never part of the body: id is
+// server-generated, run_id comes from the route path (a body that carries
+// run_id has it ignored), issued_at is set by the service at creation.
+// title/content/target_type/target_name are required; priority defaults to
+// 普通; status defaults to 待接收 (an explicit value must be exactly 待接收);
+// feedback defaults to an empty string; deadline is an optional RFC3339
+// instant; created_by passes through (empty when omitted; the
+// authenticated subject replaces it when the router authenticates).
 // deadline is kept as raw JSON so the handler can tell an omitted field
 // from an explicit null.
 type orderBody struct {
@@ -93,7 +103,8 @@ type orderBody struct {
 // rejects for the required fields and accepts for feedback); deadline
 // is kept as raw JSON so an omitted field keeps the current value while
 // an explicit null clears it. run_id, issued_at and created_by are
-// never updatable.
+// never updatable. actor and note are not order fields: they describe
+// a status change and are recorded in the history entry it appends.
 type orderUpdateBody struct {
 	Title      *string         `json:"title"`
 	Content    *string         `json:"content"`
@@ -103,6 +114,8 @@ type orderUpdateBody struct {
 	Status     *string         `json:"status"`
 	Feedback   *string         `json:"feedback"`
 	Deadline   json.RawMessage `json:"deadline"`
+	Actor      string          `json:"actor"`
+	Note       string          `json:"note"`
 }
 
 // decodeOrderJSON reads a single non-null JSON object from the request
@@ -168,7 +181,7 @@ func (h *ordersHandler) create(w http.ResponseWriter, r *http.Request) {
 		Status:     dispatch.OrderStatus(body.Status),
 		Feedback:   body.Feedback,
 		Deadline:   deadline,
-		CreatedBy:  body.CreatedBy,
+		CreatedBy:  requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeOrderError(w, err)
@@ -273,6 +286,8 @@ func (h *ordersHandler) update(w http.ResponseWriter, r *http.Request) {
 		Feedback:    body.Feedback,
 		HasDeadline: hasDeadline,
 		Deadline:    deadline,
+		Actor:       requestActor(r, body.Actor),
+		Note:        body.Note,
 	}
 	if body.Priority != nil {
 		priority := dispatch.Priority(*body.Priority)
@@ -302,6 +317,23 @@ func (h *ordersHandler) delete(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// orderHistoryResponse follows the repository list convention:
+// { "records": [...], "meta": { "total": N } }. The history is never
+// paginated: an order has at most four entries.
+type orderHistoryResponse struct {
+	Records []dispatch.OrderTransition `json:"records"`
+	Meta    metaResponse               `json:"meta"`
+}
+
+func (h *ordersHandler) history(w http.ResponseWriter, r *http.Request) {
+	records, err := h.service.OrderHistory(r.Context(), r.PathValue("rid"), r.PathValue("oid"))
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, orderHistoryResponse{Records: records, Meta: metaResponse{Total: len(records)}})
+}
+
 // writeOrderError maps the dispatch service errors of the order
 // resource to JSON error responses: validation errors become 400,
 // unknown runs or orders 404, everything else 500.
@@ -68,14 +68,14 @@ func (h *papersHandler) handleGenerate(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, paper)
 }
 
-// paperBody mirrors the client-supplied fields of the request body.
-// id, questions and the timestamps are never accepted from the client:
-// id and the timestamps are server-generated and questions is written
-// only by generation (a client-supplied questions field is ignored).
+// paperBody mirrors the client-supplied fields of the request body. id,
+// questions and the timestamps are never accepted from the client: id and
+// the timestamps are server-generated and questions is written only by
+// generation (a client-supplied questions field is ignored).
 // duration_minutes and pass_score are pointers so a missing field can be
-// told apart from an explicit zero (pass_score 0 is legal). created_by
-// is optional (empty when omitted) because the prototype has no auth
-// context.
+// told apart from an explicit zero (pass_score 0 is legal). created_by is
+// optional (empty when omitted; the authenticated subject replaces it when
+// the router authenticates).
 type paperBody struct {
 	Title              string         `json:"title"`
 	DurationMinutes    *int           `json:"duration_minutes"`
@@ -84,13 +84,13 @@ type paperBody struct {
 	CreatedBy          string         `json:"created_by"`
 }
 
-func (body paperBody) input() papers.Input {
+func (body paperBody) input(createdBy string) papers.Input {
 	return papers.Input{
 		Title:              body.Title,
 		DurationMinutes:    body.DurationMinutes,
 		PassScore:          body.PassScore,
 		GenerationStrategy: body.GenerationStrategy,
-		CreatedBy:          body.CreatedBy,
+		CreatedBy:          createdBy,
 	}
 }
 
@@ -111,7 +111,7 @@ func (h *papersHandler) create(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	paper, err := h.service.Create(r.Context(), body.input())
+	paper, err := h.service.Create(r.Context(), body.input(requestActor(r, body.CreatedBy)))
 	if err != nil {
 		writePaperError(w, err)
 		return
@@ -179,7 +179,15 @@ func (h *papersHandler) update(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	paper, err := h.service.Update(r.Context(), r.PathValue("id"), body.input())
+	createdBy, err := storedCreatedBy(r, body.CreatedBy, func() (string, error) {
+		stored, err := h.service.Get(r.Context(), r.PathValue("id"))
+		return stored.CreatedBy, err
+	})
+	if err != nil {
+		writePaperError(w, err)
+		return
+	}
+	paper, err := h.service.Update(r.Context(), r.PathValue("id"), body.input(createdBy))
 	if err != nil {
 		writePaperError(w, err)
 		return
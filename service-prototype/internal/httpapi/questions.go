@@ -65,7 +65,7 @@ func (h *questionsHandler) handleImport(w http.ResponseWriter, r *http.Request)
 	}
 	inputs := make([]questions.Input, len(bodies))
 	for i, body := range bodies {
-		inputs[i] = body.input()
+		inputs[i] = body.input(requestActor(r, body.CreatedBy))
 	}
 	imported, err := h.service.Import(r.Context(), inputs)
 	if err != nil {
@@ -75,11 +75,11 @@ func (h *questionsHandler) handleImport(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, http.StatusCreated, importResponse{Imported: len(imported), Records: imported})
 }
 
-// questionBody mirrors the client-supplied fields of the request body.
-// id and the timestamps are never accepted from the client: they are
-// server-generated. created_by is optional (empty when omitted) because
-// the prototype has no auth context; tags/explanation/metadata are
-// optional with defaults []/""/{}.
+// questionBody mirrors the client-supplied fields of the request body. id
+// and the timestamps are never accepted from the client: they are
+// server-generated. created_by is optional (empty when omitted; the
+// authenticated subject replaces it when the router authenticates);
+// tags/explanation/metadata are optional with defaults []/""/{}.
 type questionBody struct {
 	Type        string         `json:"type"`
 	Difficulty  int            `json:"difficulty"`
@@ -92,7 +92,7 @@ type questionBody struct {
 	CreatedBy   string         `json:"created_by"`
 }
 
-func (body questionBody) input() questions.Input {
+func (body questionBody) input(createdBy string) questions.Input {
 	return questions.Input{
 		Type:        questions.QuestionType(body.Type),
 		Difficulty:  body.Difficulty,
@@ -102,7 +102,7 @@ func (body questionBody) input() questions.Input {
 		Answer:      body.Answer,
 		Explanation: body.Explanation,
 		Metadata:    body.Metadata,
-		CreatedBy:   body.CreatedBy,
+		CreatedBy:   createdBy,
 	}
 }
 
@@ -123,7 +123,7 @@ func (h *questionsHandler) create(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	question, err := h.service.Create(r.Context(), body.input())
+	question, err := h.service.Create(r.Context(), body.input(requestActor(r, body.CreatedBy)))
 	if err != nil {
 		writeQuestionError(w, err)
 		return
@@ -216,7 +216,15 @@ func (h *questionsHandler) update(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	question, err := h.service.Update(r.Context(), r.PathValue("id"), body.input())
+	createdBy, err := storedCreatedBy(r, body.CreatedBy, func() (string, error) {
+		stored, err := h.service.Get(r.Context(), r.PathValue("id"))
+		return stored.CreatedBy, err
+	})
+	if err != nil {
+		writeQuestionError(w, err)
+		return
+	}
+	question, err := h.service.Update(r.Context(), r.PathValue("id"), body.input(createdBy))
 	if err != nil {
 		writeQuestionError(w, err)
 		return
@@ -0,0 +1,202 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/dispatch"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/drills"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/opinion"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/replay"
+	"github.com/ovaphlow/pitchfork/service-prototype/web"
+)
+
+// The after-action routes of a drill run (演练复盘). Both live under the
+// owning run (/drills/{rid}/timeline and /drills/{rid}/replay); the
+// literal segments are more specific than the /drills/{id} item route
+// and never collide with it (same pattern as the sim-event and run
+// stream routes).
+const (
+	runTimelineBase = prototypePrefix + "/drills/{rid}/timeline"
+	runReplayBase   = prototypePrefix + "/drills/{rid}/replay"
+)
+
+// replayHandler adapts the replay service to the HTTP routing layer.
+// The timeline route answers the merged milestones of the run with the
+// source / from / to / limit / offset filters; the replay route answers
+// the command-board state of the run at the moment of the at parameter
+// in the shape of the command-center page data. Both only serve GET
+// (other methods yield a JSON 405 with Allow); a missing run is a 404,
+// an invalid filter a 400.
+type replayHandler struct {
+	service *replay.Service
+}
+
+func newReplayHandler(drillStore drills.Store, dispatchStore dispatch.Store, opinionStore opinion.Store) *replayHandler {
+	return &replayHandler{service: replay.NewService(drillStore, dispatchStore, opinionStore)}
+}
+
+// timelineResponse follows the repository list convention:
+// { "records": [...], "meta": { "total": N } }.
+type timelineResponse struct {
+	Records []replay.Entry `json:"records"`
+	Meta    metaResponse   `json:"meta"`
+}
+
+// handleTimeline serves GET /drills/{rid}/timeline.
+func (h *replayHandler) handleTimeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	filter, ok := parseTimelineFilter(w, r)
+	if !ok {
+		return
+	}
+	records, total, err := h.service.Timeline(r.Context(), r.PathValue("rid"), filter)
+	if err != nil {
+		writeReplayError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, timelineResponse{Records: records, Meta: metaResponse{Total: total}})
+}
+
+// handleReplay serves GET /drills/{rid}/replay. The at parameter is an
+// RFC 3339 instant; omitted, the board is the current one.
+func (h *replayHandler) handleReplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	at, ok := parseQueryTime(w, r, "at")
+	if !ok {
+		return
+	}
+	board, err := h.service.Board(r.Context(), r.PathValue("rid"), at)
+	if err != nil {
+		writeReplayError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, commandPageDataFromBoard(board))
+}
+
+// parseTimelineFilter reads the source / from / to / limit / offset
+// query parameters. source may repeat or list several sources separated
+// by commas (the service rejects unknown ones); from and to are RFC 3339
+// instants (400 "invalid from" / "invalid to" otherwise); limit/offset
+// follow the drill child-list rules (default limit 50).
+func parseTimelineFilter(w http.ResponseWriter, r *http.Request) (replay.Filter, bool) {
+	page, ok := parseDrillChildListFilter(w, r)
+	if !ok {
+		return replay.Filter{}, false
+	}
+	filter := replay.Filter{Limit: page.Limit, Offset: page.Offset}
+	for _, raw := range r.URL.Query()["source"] {
+		for _, source := range strings.Split(raw, ",") {
+			if source = strings.TrimSpace(source); source != "" {
+				filter.Sources = append(filter.Sources, replay.Source(source))
+			}
+		}
+	}
+	if filter.From, ok = parseQueryTime(w, r, "from"); !ok {
+		return replay.Filter{}, false
+	}
+	if filter.To, ok = parseQueryTime(w, r, "to"); !ok {
+		return replay.Filter{}, false
+	}
+	return filter, true
+}
+
+// parseQueryTime reads an optional RFC 3339 query parameter: nil when
+// it is absent, a 400 "invalid <name>" when it does not parse.
+func parseQueryTime(w http.ResponseWriter, r *http.Request, name string) (*time.Time, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return nil, true
+	}
+	parsed, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid "+name)
+		return nil, false
+	}
+	return &parsed, true
+}
+
+// commandPageDataFromBoard maps a board to the command-center page
+// data: the dispatch rows become the display views of the six blocks
+// and the run id anchors the page. A board without a session yields an
+// empty session view.
+func commandPageDataFromBoard(board replay.Board) web.CommandPageData {
+	data := web.CommandPageData{
+		RunID:       board.RunID,
+		Zones:       make([]web.ZoneView, 0, len(board.Zones)),
+		Devices:     make([]web.DeviceView, 0, len(board.Devices)),
+		Departments: make([]web.DepartmentView, 0, len(board.Departments)),
+		Messages:    make([]web.MessageView, 0, len(board.Messages)),
+		Orders:      make([]web.OrderView, 0, len(board.Orders)),
+	}
+	data.Session.JointVenues = []string{}
+	if board.Session != nil {
+		data.Session = web.SessionView{
+			Mode:        string(board.Session.Mode),
+			MainVenue:   board.Session.MainVenue,
+			JointVenues: board.Session.JointVenues,
+		}
+	}
+	for _, zone := range board.Zones {
+		data.Zones = append(data.Zones, web.ZoneView{ZoneName: zone.ZoneName, PeopleCount: zone.PeopleCount})
+	}
+	for _, device := range board.Devices {
+		data.Devices = append(data.Devices, web.DeviceView{
+			DeviceName: device.DeviceName,
+			DeviceType: string(device.DeviceType),
+			Status:     string(device.Status),
+			Note:       device.Note,
+		})
+	}
+	for _, report := range board.Departments {
+		data.Departments = append(data.Departments, web.DepartmentView{
+			Department: string(report.Department),
+			Status:     string(report.Status),
+			Note:       report.Note,
+		})
+	}
+	for _, message := range board.Messages {
+		data.Messages = append(data.Messages, web.MessageView{
+			SenderType: string(message.SenderType),
+			SenderName: message.SenderName,
+			Content:    message.Content,
+		})
+	}
+	for _, order := range board.Orders {
+		data.Orders = append(data.Orders, web.OrderView{
+			Title:      order.Title,
+			Content:    order.Content,
+			Priority:   string(order.Priority),
+			TargetType: string(order.TargetType),
+			TargetName: order.TargetName,
+			Status:     string(order.Status),
+			Feedback:   order.Feedback,
+		})
+	}
+	return data
+}
+
+// writeReplayError maps the replay service errors to JSON error
+// responses: validation errors become 400, an unknown run 404,
+// everything else 500.
+func writeReplayError(w http.ResponseWriter, err error) {
+	var validationError *replay.ValidationError
+	switch {
+	case errors.As(err, &validationError):
+		writeError(w, http.StatusBadRequest, validationError.Message)
+	case errors.Is(err, drills.ErrRunNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, "internal error")
+	}
+}
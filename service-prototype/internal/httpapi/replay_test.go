@@ -0,0 +1,133 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// ─── /drills/{rid}/timeline + /drills/{rid}/replay ───────────────────
+
+// timelineListJSON mirrors the timeline response for assertions.
+type timelineListJSON struct {
+	Records []struct {
+		At       string          `json:"at"`
+		Source   string          `json:"source"`
+		Type     string          `json:"type"`
+		ObjectID string          `json:"object_id"`
+		Data     json.RawMessage `json:"data"`
+	} `json:"records"`
+	Meta struct {
+		Total int `json:"total"`
+	} `json:"meta"`
+}
+
+// 时间线按时间合并 run、消息、指令；source 过滤（可重复、逗号分隔）与
+// 时间窗；未知来源、非法 from、from 晚于 to 400；run 不存在 404；非 GET 405。
+func TestRunTimelineRoute(t *testing.T) {
+	handler := testMux(nil)
+	run := mustCreateInProgressRun(t, handler, validScenarioBody)
+	sendMessage(t, handler, run.ID, "")
+	if recorder := do(handler, http.MethodPost, ordersPath(run.ID), validOrderBody); recorder.Code != http.StatusCreated {
+		t.Fatalf("POST order status = %d; body = %s", recorder.Code, recorder.Body.String())
+	}
+	path := runsPath + "/" + run.ID + "/timeline"
+
+	recorder := get(handler, path, nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("GET status = %d; body = %s", recorder.Code, recorder.Body.String())
+	}
+	var list timelineListJSON
+	if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+		t.Fatalf("body %q is not a timeline JSON: %v", recorder.Body.String(), err)
+	}
+	var got []string
+	for _, record := range list.Records {
+		got = append(got, record.Source+"."+record.Type)
+	}
+	if want := []string{"run.created", "run.started", "message.sent", "order.issued"}; list.Meta.Total != 4 || strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("timeline = %v (total %d), want %v", got, list.Meta.Total, want)
+	}
+
+	recorder = get(handler, path+"?source=order,run&source=device", nil)
+	if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil || list.Meta.Total != 3 {
+		t.Fatalf("filtered timeline = %s, want 3 entries", recorder.Body.String())
+	}
+	recorder = get(handler, path+"?to=2021-01-01T00:00:00Z", nil)
+	if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil || list.Meta.Total != 0 {
+		t.Fatalf("windowed timeline = %s, want no entries", recorder.Body.String())
+	}
+
+	for query, want := range map[string]int{
+		"?source=bogus":   http.StatusBadRequest,
+		"?from=yesterday": http.StatusBadRequest,
+		"?from=2021-01-01T00:00:00Z&to=2020-01-01T00:00:00Z": http.StatusBadRequest,
+		"?limit=-1": http.StatusBadRequest,
+	} {
+		if recorder := get(handler, path+query, nil); recorder.Code != want {
+			t.Errorf("GET %s: status = %d, want %d", query, recorder.Code, want)
+		}
+	}
+	if recorder := get(handler, runsPath+"/run-missing/timeline", nil); recorder.Code != http.StatusNotFound {
+		t.Fatalf("missing run: status = %d, want 404", recorder.Code)
+	}
+	if recorder := do(handler, http.MethodPost, path, ""); recorder.Code != http.StatusMethodNotAllowed || recorder.Header().Get("Allow") != "GET" {
+		t.Fatalf("POST: status = %d, Allow = %q", recorder.Code, recorder.Header().Get("Allow"))
+	}
+}
+
+// 复盘看板与指挥大屏同构：run 开始前的看板为空（数组非 null）；at 取
+// 消息发送时刻时只含该消息；缺省 at 为当前；非法 at 400；run 不存在 404。
+func TestRunReplayRoute(t *testing.T) {
+	handler := testMux(nil)
+	run := mustCreateInProgressRun(t, handler, validScenarioBody)
+	message := sendMessage(t, handler, run.ID, `{"sender_type":"现场人员","sender_name":"南门岗","content":"收到"}`)
+	if recorder := do(handler, http.MethodPost, ordersPath(run.ID), validOrderBody); recorder.Code != http.StatusCreated {
+		t.Fatalf("POST order status = %d; body = %s", recorder.Code, recorder.Body.String())
+	}
+	path := runsPath + "/" + run.ID + "/replay"
+
+	type boardJSON struct {
+		RunID   string `json:"run_id"`
+		Session struct {
+			JointVenues []string `json:"joint_venues"`
+		} `json:"session"`
+		Messages []struct {
+			SenderName string `json:"sender_name"`
+		} `json:"messages"`
+		Orders []struct {
+			Title  string `json:"title"`
+			Status string `json:"status"`
+		} `json:"orders"`
+		Zones []any `json:"zones"`
+	}
+	var board boardJSON
+	recorder := get(handler, path+"?at=2021-01-01T00:00:00Z", nil)
+	if recorder.Code != http.StatusOK || json.Unmarshal(recorder.Body.Bytes(), &board) != nil {
+		t.Fatalf("GET status = %d; body = %s", recorder.Code, recorder.Body.String())
+	}
+	if board.RunID != run.ID || len(board.Messages) != 0 || len(board.Orders) != 0 || board.Zones == nil || board.Session.JointVenues == nil {
+		t.Fatalf("board = %s, want an empty board with empty arrays", recorder.Body.String())
+	}
+
+	board = boardJSON{}
+	recorder = get(handler, path+"?at="+url.QueryEscape(*message.SentAt), nil)
+	if err := json.Unmarshal(recorder.Body.Bytes(), &board); err != nil || len(board.Messages) != 1 || board.Messages[0].SenderName != "南门岗" || len(board.Orders) != 0 {
+		t.Fatalf("board at sent_at = %s, want the message only", recorder.Body.String())
+	}
+
+	board = boardJSON{}
+	recorder = get(handler, path, nil)
+	if err := json.Unmarshal(recorder.Body.Bytes(), &board); err != nil || len(board.Orders) != 1 || board.Orders[0].Status != "待接收" {
+		t.Fatalf("current board = %s, want the issued order", recorder.Body.String())
+	}
+
+	if recorder := get(handler, path+"?at=now", nil); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("invalid at: status = %d, want 400", recorder.Code)
+	}
+	if recorder := get(handler, runsPath+"/run-missing/replay", nil); recorder.Code != http.StatusNotFound {
+		t.Fatalf("missing run: status = %d, want 404", recorder.Code)
+	}
+}
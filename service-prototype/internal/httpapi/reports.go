@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/dispatch"
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/drills"
@@ -97,9 +98,26 @@ func (s drillsReportSource) ListOrders(ctx context.Context, runID string) ([]eva
 	if err != nil {
 		return nil, err
 	}
+	// The reception time is not on the order row: it comes from the
+	// status history (the entry that moved the order to 已接收).
+	history, err := s.dispatchStore.ListOrderTransitionsByRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	receivedAt := make(map[string]*time.Time, len(orders))
+	for _, transition := range history {
+		if transition.To == dispatch.OrderStatusReceived && receivedAt[transition.OrderID] == nil {
+			at := transition.CreatedAt
+			receivedAt[transition.OrderID] = &at
+		}
+	}
 	converted := make([]evaluation.ReportOrder, 0, len(orders))
 	for _, order := range orders {
-		converted = append(converted, evaluation.ReportOrder{IssuedAt: order.IssuedAt})
+		converted = append(converted, evaluation.ReportOrder{
+			IssuedAt:    order.IssuedAt,
+			ReceivedAt:  receivedAt[order.ID],
+			CompletedAt: order.CompletedAt,
+		})
 	}
 	return converted, nil
 }
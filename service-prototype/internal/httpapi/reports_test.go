@@ -41,11 +41,21 @@ type reportJSON struct {
 		Level     string `json:"level"`
 		Text      string `json:"text"`
 	} `json:"suggestions"`
+	DispatchLatency struct {
+		Reception latencyStatJSON `json:"reception"`
+		Execution latencyStatJSON `json:"execution"`
+	} `json:"dispatch_latency"`
 	CreatedBy string `json:"created_by"`
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
 }
 
+type latencyStatJSON struct {
+	Count       int      `json:"count"`
+	MeanSeconds *float64 `json:"mean_seconds"`
+	Score       *float64 `json:"score"`
+}
+
 type reportListJSON struct {
 	Records []reportJSON `json:"records"`
 	Meta    struct {
@@ -434,6 +444,36 @@ func TestReportGenerateContent(t *testing.T) {
 	}
 }
 
+// TestReportDispatchLatency drives one order through 已接收 and 已完成
+// over the API: the report's dispatch_latency block measures it from the
+// status history (count 1 with a mean and a score for both reception
+// and execution), while a run without orders carries count 0 and no
+// mean.
+func TestReportDispatchLatency(t *testing.T) {
+	handler := testMux(nil)
+	run := mustCreateInProgressRun(t, handler, validScenarioBody)
+	order := createOrder(t, handler, run.ID, "")
+	for _, status := range []string{"已接收", "执行中", "已完成"} {
+		if recorder := do(handler, http.MethodPut, orderItemPath(run.ID, order.ID), `{"status":"`+status+`"}`); recorder.Code != http.StatusOK {
+			t.Fatalf("PUT %s status = %d; body = %s", status, recorder.Code, recorder.Body.String())
+		}
+	}
+	if recorder := do(handler, http.MethodPost, runsPath+"/"+run.ID+"/complete", ""); recorder.Code != http.StatusOK {
+		t.Fatalf("complete status = %d; body = %s", recorder.Code, recorder.Body.String())
+	}
+	latency := generateReport(t, handler, run.ID, http.StatusCreated).DispatchLatency
+	for name, stat := range map[string]latencyStatJSON{"reception": latency.Reception, "execution": latency.Execution} {
+		if stat.Count != 1 || stat.MeanSeconds == nil || stat.Score == nil {
+			t.Errorf("%s = %+v, want one measured order with a mean and a score", name, stat)
+		}
+	}
+
+	empty := generateReport(t, handler, completedRun(t, handler).ID, http.StatusCreated).DispatchLatency
+	if empty.Reception.Count != 0 || empty.Reception.MeanSeconds != nil || empty.Execution.Count != 0 {
+		t.Errorf("latency without orders = %+v, want zero counts", empty)
+	}
+}
+
 func assertIndicatorEntry(t *testing.T, report reportJSON, id string, score float64, auto, expert, selfPeer, demo *float64) {
 	t.Helper()
 	entry, ok := report.IndicatorScores[id]
@@ -0,0 +1,149 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/drills"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/runstream"
+)
+
+// runStreamBase is the Server-Sent Events endpoint of a drill run (演练
+// 实时推送). The route lives under the owning run (/drills/{rid}/stream);
+// the literal stream segment is more specific than the /drills/{id}
+// item route and never collides with it (same pattern as the
+// step-record, sim-event and orders routes).
+const runStreamBase = prototypePrefix + "/drills/{rid}/stream"
+
+// defaultStreamHeartbeat is the interval of the SSE comment lines that
+// keep idle connections open through proxies.
+const defaultStreamHeartbeat = 15 * time.Second
+
+// runStreamHandler adapts the run stream broker to the HTTP routing
+// layer. It serves GET only; other methods yield a JSON 405 with Allow.
+// A missing run is a JSON 404 before the stream starts. The stream
+// carries one SSE event per committed change of the run:
+//
+//	id: <sequence>
+//	event: <kind>.<action>      (e.g. order.created, zone_density.updated)
+//	data: <runstream.Event as JSON>
+//
+// A reconnecting client presents the id of the last event it received
+// in the Last-Event-ID header (the EventSource default) or the
+// last_event_id query parameter; the retained events after it are
+// replayed before the live ones. A stream.reset event asks the client to
+// reload the run state because the events it missed are gone. A
+// run.deleted event is the last event of the stream.
+type runStreamHandler struct {
+	service   *drills.Service
+	broker    *runstream.Broker
+	heartbeat time.Duration
+}
+
+// newRunStreamHandler builds the handler over the drill store (the run
+// existence check) and the broker the services publish to.
+func newRunStreamHandler(drillStore drills.Store, broker *runstream.Broker) *runStreamHandler {
+	return &runStreamHandler{service: drills.NewService(drillStore), broker: broker, heartbeat: defaultStreamHeartbeat}
+}
+
+func (h *runStreamHandler) handleStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	runID := r.PathValue("rid")
+	if _, err := h.service.GetRun(r.Context(), runID); err != nil {
+		writeRunError(w, err)
+		return
+	}
+	lastEventID, ok := parseLastEventID(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid Last-Event-ID")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	subscription, replay := h.broker.Subscribe(runID, lastEventID)
+	defer subscription.Close()
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	// The retry hint makes EventSource reconnect quickly after the
+	// server dropped a stalled stream.
+	if _, err := fmt.Fprint(w, "retry: 2000\n\n"); err != nil {
+		return
+	}
+	for _, event := range replay {
+		if err := writeStreamEvent(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, open := <-subscription.Events():
+			if !open {
+				// Dropped by the broker (stalled consumer or run
+				// deleted): ending the response lets the client
+				// reconnect with its Last-Event-ID.
+				return
+			}
+			if err := writeStreamEvent(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// parseLastEventID reads the resume point of the client: the
+// Last-Event-ID header, or the last_event_id query parameter for clients
+// that cannot set headers. Absent means a fresh connection (0); a value
+// that is not a non-negative integer is rejected.
+func parseLastEventID(r *http.Request) (uint64, bool) {
+	raw := strings.TrimSpace(r.Header.Get("Last-Event-ID"))
+	if raw == "" {
+		raw = strings.TrimSpace(r.URL.Query().Get("last_event_id"))
+	}
+	if raw == "" {
+		return 0, true
+	}
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// writeStreamEvent writes one event in the SSE wire format. The JSON
+// encoding never contains a raw newline, so the data fits one line.
+func writeStreamEvent(w http.ResponseWriter, event runstream.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type(), data)
+	return err
+}
@@ -0,0 +1,177 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ─── 测试辅助 ────────────────────────────────────────────────────────
+
+// streamEvent is one parsed SSE event of the run stream.
+type streamEvent struct {
+	ID    string
+	Event string
+	Data  struct {
+		RunID    string          `json:"run_id"`
+		Kind     string          `json:"kind"`
+		Action   string          `json:"action"`
+		ObjectID string          `json:"object_id"`
+		Data     json.RawMessage `json:"data"`
+	}
+}
+
+// openRunStream connects to the stream of the run on the test server,
+// presenting lastEventID when non-empty. The stream is closed when the
+// test ends.
+func openRunStream(t *testing.T, server *httptest.Server, runID, lastEventID string) *bufio.Reader {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+runsPath+"/"+runID+"/stream", nil)
+	if err != nil {
+		t.Fatalf("build stream request: %v", err)
+	}
+	if lastEventID != "" {
+		request.Header.Set("Last-Event-ID", lastEventID)
+	}
+	response, err := server.Client().Do(request)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	t.Cleanup(func() { response.Body.Close() })
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("stream status = %d, want 200", response.StatusCode)
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", contentType)
+	}
+	return bufio.NewReader(response.Body)
+}
+
+// nextStreamEvent reads the next event of the stream, skipping the
+// retry hint and the keepalive comments; it fails after two seconds.
+func nextStreamEvent(t *testing.T, reader *bufio.Reader) streamEvent {
+	t.Helper()
+	result := make(chan streamEvent, 1)
+	failure := make(chan error, 1)
+	go func() {
+		var event streamEvent
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				failure <- err
+				return
+			}
+			line = strings.TrimRight(line, "\n")
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				event.ID = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				event.Event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.Data); err != nil {
+					failure <- err
+					return
+				}
+			case line == "" && event.Event != "":
+				result <- event
+				return
+			}
+		}
+	}()
+	select {
+	case event := <-result:
+		return event
+	case err := <-failure:
+		t.Fatalf("read stream: %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no stream event within 2s")
+	}
+	return streamEvent{}
+}
+
+// ─── GET /drills/{rid}/stream ────────────────────────────────────────
+
+// 订阅后，经 JSON API 的每次写入都以类型化 SSE 事件推送给订阅端：
+// event 为 <kind>.<action>，data 携带 run_id/object_id 与写入后的对象。
+func TestRunStreamPushesCommittedWrites(t *testing.T) {
+	handler := testMux(nil)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	run := mustCreateInProgressRun(t, handler, validScenarioBody)
+	stream := openRunStream(t, server, run.ID, "")
+
+	order := createOrder(t, handler, run.ID, "")
+	event := nextStreamEvent(t, stream)
+	if event.Event != "order.created" || event.Data.RunID != run.ID || event.Data.ObjectID != order.ID {
+		t.Fatalf("event = %s %+v, want order.created of %s", event.Event, event.Data, order.ID)
+	}
+	var pushed orderJSON
+	if err := json.Unmarshal(event.Data.Data, &pushed); err != nil || pushed.Title != order.Title {
+		t.Fatalf("pushed order = %+v (%v), want the created order", pushed, err)
+	}
+
+	recorder := do(handler, http.MethodPost, zonesPath(run.ID), `{"zone_name":"A区东侧展厅","people_count":420}`)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("POST zone-densities status = %d; body = %s", recorder.Code, recorder.Body.String())
+	}
+	if event := nextStreamEvent(t, stream); event.Event != "zone_density.created" {
+		t.Fatalf("event = %s, want zone_density.created", event.Event)
+	}
+
+	recorder = do(handler, http.MethodDelete, orderItemPath(run.ID, order.ID), "")
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("DELETE order status = %d", recorder.Code)
+	}
+	if event := nextStreamEvent(t, stream); event.Event != "order.deleted" || event.Data.ObjectID != order.ID {
+		t.Fatalf("event = %s %+v, want order.deleted of %s", event.Event, event.Data, order.ID)
+	}
+}
+
+// 断线重连携带 Last-Event-ID：先重放断线期间错过的事件，再接收实时事件。
+func TestRunStreamResumesFromLastEventID(t *testing.T) {
+	handler := testMux(nil)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	run := mustCreateInProgressRun(t, handler, validScenarioBody)
+
+	first := openRunStream(t, server, run.ID, "")
+	createOrder(t, handler, run.ID, "")
+	seen := nextStreamEvent(t, first)
+	missed := createOrder(t, handler, run.ID, "")
+
+	resumed := openRunStream(t, server, run.ID, seen.ID)
+	event := nextStreamEvent(t, resumed)
+	if event.Event != "order.created" || event.Data.ObjectID != missed.ID {
+		t.Fatalf("replayed event = %s %+v, want the missed order %s", event.Event, event.Data, missed.ID)
+	}
+	if event.ID <= seen.ID {
+		t.Fatalf("replayed id %s is not after the resume point %s", event.ID, seen.ID)
+	}
+}
+
+// 失败路径：run 不存在 404 JSON；非 GET 405 且 Allow 为 GET；
+// Last-Event-ID 非法 400。
+func TestRunStreamFailures(t *testing.T) {
+	handler := testMux(nil)
+	recorder := get(handler, runsPath+"/run-missing/stream", nil)
+	if recorder.Code != http.StatusNotFound || recorder.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("missing run: status = %d, Content-Type = %q; want a JSON 404", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+
+	run := mustCreateInProgressRun(t, handler, validScenarioBody)
+	recorder = do(handler, http.MethodPost, runsPath+"/"+run.ID+"/stream", "")
+	if recorder.Code != http.StatusMethodNotAllowed || recorder.Header().Get("Allow") != "GET" {
+		t.Fatalf("POST: status = %d, Allow = %q; want 405 with GET", recorder.Code, recorder.Header().Get("Allow"))
+	}
+	recorder = get(handler, runsPath+"/"+run.ID+"/stream", map[string]string{"Last-Event-ID": "not-a-number"})
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("invalid Last-Event-ID: status = %d, want 400", recorder.Code)
+	}
+}
@@ -52,12 +52,12 @@ func (h *runsHandler) handleItem(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// runBody mirrors the client-supplied fields of the request body.
-// status, started_at and completed_at are server-managed and never part
-// of the input: a request body that carries them has them ignored by the
-// decoder. created_by is optional (empty when omitted) because the
-// prototype has no auth context; metadata is an optional JSON object
-// echoed verbatim.
+// runBody mirrors the client-supplied fields of the request body. status,
+// started_at and completed_at are server-managed and never part of the
+// input: a request body that carries them has them ignored by the decoder.
+// created_by is optional (empty when omitted; the authenticated subject
+// replaces it when the router authenticates); metadata is an optional JSON
+// object echoed verbatim.
 type runBody struct {
 	ScenarioID string         `json:"scenario_id"`
 	Title      string         `json:"title"`
@@ -86,7 +86,7 @@ func (h *runsHandler) create(w http.ResponseWriter, r *http.Request) {
 		ScenarioID: body.ScenarioID,
 		Title:      body.Title,
 		Metadata:   body.Metadata,
-		CreatedBy:  body.CreatedBy,
+		CreatedBy:  requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeRunError(w, err)
@@ -167,11 +167,19 @@ func (h *runsHandler) update(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
+	createdBy, err := storedCreatedBy(r, body.CreatedBy, func() (string, error) {
+		stored, err := h.service.GetRun(r.Context(), r.PathValue("id"))
+		return stored.CreatedBy, err
+	})
+	if err != nil {
+		writeRunError(w, err)
+		return
+	}
 	run, err := h.service.UpdateRun(r.Context(), r.PathValue("id"), drills.RunInput{
 		ScenarioID: body.ScenarioID,
 		Title:      body.Title,
 		Metadata:   body.Metadata,
-		CreatedBy:  body.CreatedBy,
+		CreatedBy:  createdBy,
 	})
 	if err != nil {
 		writeRunError(w, err)
@@ -52,8 +52,9 @@ func (h *scenariosHandler) handleItem(w http.ResponseWriter, r *http.Request) {
 }
 
 // scenarioBody mirrors the client-supplied fields of the request body.
-// created_by is optional (empty when omitted) because the prototype has
-// no auth context; metadata is an optional JSON object echoed verbatim.
+// created_by is optional (empty when omitted; the authenticated subject
+// replaces it when the router authenticates); metadata is an optional JSON
+// object echoed verbatim.
 type scenarioBody struct {
 	Name       string         `json:"name"`
 	Category   string         `json:"category"`
@@ -86,7 +87,7 @@ func (h *scenariosHandler) create(w http.ResponseWriter, r *http.Request) {
 		Background: body.Background,
 		Status:     drills.ScenarioStatus(body.Status),
 		Metadata:   body.Metadata,
-		CreatedBy:  body.CreatedBy,
+		CreatedBy:  requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeScenarioError(w, err)
@@ -171,13 +172,21 @@ func (h *scenariosHandler) update(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
+	createdBy, err := storedCreatedBy(r, body.CreatedBy, func() (string, error) {
+		stored, err := h.service.GetScenario(r.Context(), r.PathValue("id"))
+		return stored.CreatedBy, err
+	})
+	if err != nil {
+		writeScenarioError(w, err)
+		return
+	}
 	scenario, err := h.service.UpdateScenario(r.Context(), r.PathValue("id"), drills.ScenarioInput{
 		Name:       body.Name,
 		Category:   drills.Category(body.Category),
 		Background: body.Background,
 		Status:     drills.ScenarioStatus(body.Status),
 		Metadata:   body.Metadata,
-		CreatedBy:  body.CreatedBy,
+		CreatedBy:  createdBy,
 	})
 	if err != nil {
 		writeScenarioError(w, err)
@@ -124,11 +124,11 @@ func (h *scoresHandler) create(w http.ResponseWriter, r *http.Request) {
 	score, err := h.service.CreateScore(r.Context(), r.PathValue("rid"), evaluation.ScoreInput{
 		IndicatorID: body.IndicatorID,
 		ScoreType:   body.ScoreType,
-		Rater:       body.Rater,
+		Rater:       requestActor(r, body.Rater),
 		Target:      body.Target,
 		Score:       body.Score,
 		Comment:     body.Comment,
-		CreatedBy:   body.CreatedBy,
+		CreatedBy:   requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeScoreError(w, err)
@@ -171,16 +171,24 @@ func (h *scoresHandler) update(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
+	createdBy, err := storedCreatedBy(r, body.CreatedBy, func() (string, error) {
+		stored, err := h.service.GetScore(r.Context(), r.PathValue("rid"), r.PathValue("sid"))
+		return stored.CreatedBy, err
+	})
+	if err != nil {
+		writeScoreError(w, err)
+		return
+	}
 	// run_id and indicator_id are never modified by a PUT: the route
 	// path and the existing record decide them (a body that carries
 	// indicator_id has it ignored here).
 	score, err := h.service.UpdateScore(r.Context(), r.PathValue("rid"), r.PathValue("sid"), evaluation.ScoreInput{
 		ScoreType: body.ScoreType,
-		Rater:     body.Rater,
+		Rater:     requestActor(r, body.Rater),
 		Target:    body.Target,
 		Score:     body.Score,
 		Comment:   body.Comment,
-		CreatedBy: body.CreatedBy,
+		CreatedBy: createdBy,
 	})
 	if err != nil {
 		writeScoreError(w, err)
@@ -60,15 +60,15 @@ func (h *simEventsHandler) handleItem(w http.ResponseWriter, r *http.Request) {
 }
 
 // simEventBody mirrors the client-supplied fields of the request body.
-// run_id and the timestamps are never part of the body: run_id comes
-// from the route path, triggered_at is server-managed (set at creation)
-// and handled_at is managed by the service together with the status.
-// payload is an optional JSON object (an omitted field is replaced by {}
-// on create and kept on update); event_type is required on create;
-// status defaults to 已触发 on create; created_by is optional (empty when
-// omitted) because the prototype has no auth context. payload is kept as
-// raw JSON so the handler can tell an omitted field from an explicit
-// null and reject every non-object value.
+// run_id and the timestamps are never part of the body: run_id comes from
+// the route path, triggered_at is server-managed (set at creation) and
+// handled_at is managed by the service together with the status. payload
+// is an optional JSON object (an omitted field is replaced by {} on create
+// and kept on update); event_type is required on create; status defaults
+// to 已触发 on create; created_by is optional (empty when omitted; the
+// authenticated subject replaces it when the router authenticates).
+// payload is kept as raw JSON so the handler can tell an omitted field
+// from an explicit null and reject every non-object value.
 type simEventBody struct {
 	EventType string          `json:"event_type"`
 	Payload   json.RawMessage `json:"payload"`
@@ -123,7 +123,7 @@ func (h *simEventsHandler) create(w http.ResponseWriter, r *http.Request) {
 		EventType: drills.SimEventType(body.EventType),
 		Payload:   payload,
 		Status:    drills.SimEventStatus(body.Status),
-		CreatedBy: body.CreatedBy,
+		CreatedBy: requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeSimEventError(w, err)
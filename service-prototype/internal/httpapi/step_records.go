@@ -116,9 +116,9 @@ func (h *stepRecordHandler) upsert(w http.ResponseWriter, r *http.Request) {
 	record, err := h.service.UpsertStepRecord(r.Context(), r.PathValue("rid"), r.PathValue("stepId"), drills.StepRecordInput{
 		Status:      drills.StepRecordStatus(body.Status),
 		ActionNote:  body.ActionNote,
-		PerformedBy: body.PerformedBy,
+		PerformedBy: requestActor(r, body.PerformedBy),
 		PerformedAt: performedAt,
-		CreatedBy:   body.CreatedBy,
+		CreatedBy:   requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeStepRecordError(w, err)
@@ -55,10 +55,10 @@ func (h *stepsHandler) handleItem(w http.ResponseWriter, r *http.Request) {
 }
 
 // stepBody mirrors the client-supplied fields of the request body.
-// scenario_id is never part of the body: it is decided by the route
-// path. sort_order and description are optional (defaults 0 and ”);
-// created_by is optional (empty when omitted) because the prototype has
-// no auth context.
+// scenario_id is never part of the body: it is decided by the route path.
+// sort_order and description are optional (defaults 0 and ”); created_by
+// is optional (empty when omitted; the authenticated subject replaces it
+// when the router authenticates).
 type stepBody struct {
 	SortOrder   int    `json:"sort_order"`
 	Title       string `json:"title"`
@@ -87,7 +87,7 @@ func (h *stepsHandler) create(w http.ResponseWriter, r *http.Request) {
 		SortOrder:   body.SortOrder,
 		Title:       body.Title,
 		Description: body.Description,
-		CreatedBy:   body.CreatedBy,
+		CreatedBy:   requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeDrillChildError(w, err)
@@ -157,11 +157,19 @@ func (h *stepsHandler) update(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
+	createdBy, err := storedCreatedBy(r, body.CreatedBy, func() (string, error) {
+		stored, err := h.service.GetStep(r.Context(), r.PathValue("id"))
+		return stored.CreatedBy, err
+	})
+	if err != nil {
+		writeDrillChildError(w, err)
+		return
+	}
 	step, err := h.service.UpdateStep(r.Context(), r.PathValue("id"), drills.StepInput{
 		SortOrder:   body.SortOrder,
 		Title:       body.Title,
 		Description: body.Description,
-		CreatedBy:   body.CreatedBy,
+		CreatedBy:   createdBy,
 	})
 	if err != nil {
 		writeDrillChildError(w, err)
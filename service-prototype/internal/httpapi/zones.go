@@ -64,15 +64,15 @@ func (h *zonesHandler) handleItem(w http.ResponseWriter, r *http.Request) {
 }
 
 // zoneDensityBody mirrors the client-supplied fields of a zone-density
-// report. id, run_id, reported_at and the timestamps are never part of
-// the body: id is server-generated, run_id comes from the route path (a
-// body that carries run_id has it ignored), reported_at is set by the
-// service at creation and refreshed on update. zone_name and
-// people_count are required (people_count must be a non-negative
-// integer; a non-number, non-integer or negative value is a 400);
-// created_by passes through at creation (empty when omitted, the
-// prototype has no auth context) and is preserved on update (a body
-// that carries created_by on PUT has it ignored).
+// report. id, run_id, reported_at and the timestamps are never part of the
+// body: id is server-generated, run_id comes from the route path (a body
+// that carries run_id has it ignored), reported_at is set by the service
+// at creation and refreshed on update. zone_name and people_count are
+// required (people_count must be a non-negative integer; a non-number,
+// non-integer or negative value is a 400); created_by passes through at
+// creation (empty when omitted; the authenticated subject when the router
+// authenticates) and is preserved on update (a body that carries
+// created_by on PUT has it ignored).
 type zoneDensityBody struct {
 	ZoneName    string `json:"zone_name"`
 	PeopleCount *int   `json:"people_count"`
@@ -87,7 +87,7 @@ func (h *zonesHandler) create(w http.ResponseWriter, r *http.Request) {
 	density, err := h.service.CreateZoneDensity(r.Context(), r.PathValue("rid"), dispatch.ZoneDensityInput{
 		ZoneName:    body.ZoneName,
 		PeopleCount: body.PeopleCount,
-		CreatedBy:   body.CreatedBy,
+		CreatedBy:   requestActor(r, body.CreatedBy),
 	})
 	if err != nil {
 		writeZoneDensityError(w, err)
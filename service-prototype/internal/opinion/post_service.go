@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/drills"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/runstream"
 )
 
 // postWritableRun reports whether a run in the given status may receive
@@ -43,6 +44,7 @@ func (s *Service) CreatePost(ctx context.Context, runID string, input PostInput)
 	if err := s.store.CreatePost(ctx, post); err != nil {
 		return Post{}, err
 	}
+	s.publish(runID, runstream.KindOpinionPost, runstream.ActionCreated, post.ID, post)
 	return post, nil
 }
 
@@ -138,6 +140,7 @@ func (s *Service) UpdatePost(ctx context.Context, runID, id string, update PostU
 	if err := s.store.UpdatePost(ctx, post); err != nil {
 		return Post{}, err
 	}
+	s.publish(runID, runstream.KindOpinionPost, runstream.ActionUpdated, post.ID, post)
 	return post, nil
 }
 
@@ -152,5 +155,9 @@ func (s *Service) DeletePost(ctx context.Context, runID, id string) error {
 	if !postWritableRun(run.Status) {
 		return postWriteGateError(run.Status)
 	}
-	return s.store.DeletePost(ctx, runID, id)
+	if err := s.store.DeletePost(ctx, runID, id); err != nil {
+		return err
+	}
+	s.publish(runID, runstream.KindOpinionPost, runstream.ActionDeleted, id, nil)
+	return nil
 }
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/drills"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/runstream"
 )
 
 // inProgressPostService returns a service over a store and a single
@@ -382,3 +383,46 @@ func TestDeletePost(t *testing.T) {
 		}
 	}
 }
+
+// ─── Run stream publisher ────────────────────────────────────────────
+
+// recordingPublisher is a runstream.Publisher test double recording the
+// published events as "kind.action objectID".
+type recordingPublisher struct{ events []string }
+
+func (p *recordingPublisher) Publish(_ string, kind runstream.Kind, action runstream.Action, objectID string, _ any) {
+	p.events = append(p.events, string(kind)+"."+string(action)+" "+objectID)
+}
+
+// 接线 publisher 后，舆情帖子的增改删各发布一条变更事件；删除不存在的帖
+// 子（404）不发布。
+func TestPostWritesArePublished(t *testing.T) {
+	ctx := context.Background()
+	service, _ := inProgressPostService()
+	publisher := &recordingPublisher{}
+	service.SetPublisher(publisher)
+
+	post, err := service.CreatePost(ctx, "run-1", PostInput{Content: "展厅入口聚集大量游客"})
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	if _, err := service.UpdatePost(ctx, "run-1", post.ID, PostUpdate{Heat: 80, HasHeat: true}); err != nil {
+		t.Fatalf("UpdatePost: %v", err)
+	}
+	if err := service.DeletePost(ctx, "run-1", post.ID); err != nil {
+		t.Fatalf("DeletePost: %v", err)
+	}
+	if err := service.DeletePost(ctx, "run-1", post.ID); !errors.Is(err, ErrPostNotFound) {
+		t.Fatalf("second DeletePost: err = %v, want ErrPostNotFound", err)
+	}
+
+	want := []string{"opinion_post.created " + post.ID, "opinion_post.updated " + post.ID, "opinion_post.deleted " + post.ID}
+	if len(publisher.events) != len(want) {
+		t.Fatalf("published %q, want %q", publisher.events, want)
+	}
+	for i := range want {
+		if publisher.events[i] != want[i] {
+			t.Fatalf("event %d = %q, want %q", i, publisher.events[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package opinion
+
+import (
+	"context"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
+)
+
+// PostgresStore persists the opinion rows in the opinion_* tables
+// (migrations 000026 to 000031). It implements Store and the drills
+// RunOpinionCleaner with the same semantics as the in-memory store: the
+// event and the review are upserted on their run, every other row is
+// addressed by (run_id, id) so a row of another run is not found, and
+// the listings keep the sort orders documented on the in-memory methods.
+type PostgresStore struct {
+	db pgstore.Querier
+}
+
+// NewPostgresStore returns an opinion store over the given connection.
+func NewPostgresStore(db pgstore.Querier) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+const (
+	eventColumns         = `id, run_id, event_name, subject, summary, occurred_at, level, status, metadata, created_by, created_at, updated_at`
+	postColumns          = `id, run_id, source, content, sentiment, heat, warn_status, warned_at, metadata, created_by, created_at, updated_at`
+	releaseColumns       = `id, run_id, channel, title, content, media_name, status, published_at, metadata, created_by, created_at, updated_at`
+	mediaQuestionColumns = `id, run_id, media_name, reporter, question, question_type, answer, status, answered_at, metadata, created_by, created_at, updated_at`
+	complaintColumns     = `id, run_id, complainant, channel, complaint_type, content, status, handling, handler, closed_at, metadata, created_by, created_at, updated_at`
+	reviewColumns        = `id, run_id, case_summary, highlights, problems, lessons, suggestions, metadata, created_by, created_at, updated_at`
+)
+
+// UpsertEvent inserts the event or replaces the event of the same run.
+func (s *PostgresStore) UpsertEvent(ctx context.Context, event Event) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO opinion_events (`+eventColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (run_id) DO UPDATE SET id = EXCLUDED.id, event_name = EXCLUDED.event_name,
+			subject = EXCLUDED.subject, summary = EXCLUDED.summary, occurred_at = EXCLUDED.occurred_at,
+			level = EXCLUDED.level, status = EXCLUDED.status, metadata = EXCLUDED.metadata,
+			created_by = EXCLUDED.created_by, created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at`,
+		event.ID, event.RunID, event.EventName, event.Subject, event.Summary, event.OccurredAt,
+		event.Level, event.Status, event.Metadata, event.CreatedBy, event.CreatedAt, event.UpdatedAt)
+	return err
+}
+
+// GetEvent returns the event of the run, or ErrEventNotFound.
+func (s *PostgresStore) GetEvent(ctx context.Context, runID string) (Event, error) {
+	var event Event
+	err := s.db.QueryRow(ctx, `SELECT `+eventColumns+` FROM opinion_events WHERE run_id = $1`, runID).
+		Scan(&event.ID, &event.RunID, &event.EventName, &event.Subject, &event.Summary, &event.OccurredAt,
+			&event.Level, &event.Status, &event.Metadata, &event.CreatedBy, &event.CreatedAt, &event.UpdatedAt)
+	if pgstore.IsNoRows(err) {
+		return Event{}, ErrEventNotFound
+	}
+	return event, err
+}
+
+// DeleteEvent removes the event of the run, or returns ErrEventNotFound.
+func (s *PostgresStore) DeleteEvent(ctx context.Context, runID string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM opinion_events WHERE run_id = $1`, runID)
+	return pgstore.CheckAffected(tag, err, ErrEventNotFound)
+}
+
+// DeleteByRun removes every opinion object of the run in one statement,
+// so the cleanup is atomic. Removing no objects is not an error.
+func (s *PostgresStore) DeleteByRun(ctx context.Context, runID string) error {
+	_, err := s.db.Exec(ctx, `WITH
+		posts AS (DELETE FROM opinion_posts WHERE run_id = $1),
+		releases AS (DELETE FROM opinion_releases WHERE run_id = $1),
+		questions AS (DELETE FROM opinion_media_questions WHERE run_id = $1),
+		complaints AS (DELETE FROM opinion_complaints WHERE run_id = $1),
+		reviews AS (DELETE FROM opinion_reviews WHERE run_id = $1)
+		DELETE FROM opinion_events WHERE run_id = $1`, runID)
+	return err
+}
+
+// CreatePost inserts the post.
+func (s *PostgresStore) CreatePost(ctx context.Context, post Post) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO opinion_posts (`+postColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		post.ID, post.RunID, post.Source, post.Content, post.Sentiment, post.Heat, post.WarnStatus,
+		post.WarnedAt, post.Metadata, post.CreatedBy, post.CreatedAt, post.UpdatedAt)
+	return err
+}
+
+// ListPosts returns the posts of the run matching the filter ordered by
+// created_at DESC, id DESC, the total number of matches and the page.
+func (s *PostgresStore) ListPosts(ctx context.Context, runID string, filter PostFilter) ([]Post, int, error) {
+	const where = ` WHERE run_id = $1 AND ($2 = '' OR source = $2) AND ($3 = '' OR sentiment = $3)
+		AND ($4 = '' OR warn_status = $4)`
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM opinion_posts`+where,
+		runID, filter.Source, filter.Sentiment, filter.WarnStatus).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+postColumns+` FROM opinion_posts`+where+`
+		ORDER BY created_at DESC, id DESC LIMIT $5 OFFSET $6`,
+		runID, filter.Source, filter.Sentiment, filter.WarnStatus, pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []Post{}
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, post)
+	}
+	return page, total, rows.Err()
+}
+
+// GetPost returns the post with the given id within the run, or
+// ErrPostNotFound.
+func (s *PostgresStore) GetPost(ctx context.Context, runID, id string) (Post, error) {
+	post, err := scanPost(s.db.QueryRow(ctx, `SELECT `+postColumns+` FROM opinion_posts
+		WHERE run_id = $1 AND id = $2`, runID, id))
+	if pgstore.IsNoRows(err) {
+		return Post{}, ErrPostNotFound
+	}
+	return post, err
+}
+
+// UpdatePost replaces the post with the same run and id, or returns
+// ErrPostNotFound.
+func (s *PostgresStore) UpdatePost(ctx context.Context, post Post) error {
+	tag, err := s.db.Exec(ctx, `UPDATE opinion_posts SET source = $3, content = $4, sentiment = $5,
+		heat = $6, warn_status = $7, warned_at = $8, metadata = $9, created_by = $10, created_at = $11,
+		updated_at = $12 WHERE run_id = $2 AND id = $1`,
+		post.ID, post.RunID, post.Source, post.Content, post.Sentiment, post.Heat, post.WarnStatus,
+		post.WarnedAt, post.Metadata, post.CreatedBy, post.CreatedAt, post.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrPostNotFound)
+}
+
+// DeletePost removes the post with the given id within the run, or
+// returns ErrPostNotFound.
+func (s *PostgresStore) DeletePost(ctx context.Context, runID, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM opinion_posts WHERE run_id = $1 AND id = $2`, runID, id)
+	return pgstore.CheckAffected(tag, err, ErrPostNotFound)
+}
+
+// CreateRelease inserts the release.
+func (s *PostgresStore) CreateRelease(ctx context.Context, release Release) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO opinion_releases (`+releaseColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		release.ID, release.RunID, release.Channel, release.Title, release.Content, release.MediaName,
+		release.Status, release.PublishedAt, release.Metadata, release.CreatedBy, release.CreatedAt, release.UpdatedAt)
+	return err
+}
+
+// ListReleases returns the releases of the run matching the filter
+// ordered by created_at DESC, id DESC, the total number of matches and
+// the paginated page.
+func (s *PostgresStore) ListReleases(ctx context.Context, runID string, filter ReleaseFilter) ([]Release, int, error) {
+	const where = ` WHERE run_id = $1 AND ($2 = '' OR channel = $2) AND ($3 = '' OR status = $3)`
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM opinion_releases`+where,
+		runID, filter.Channel, filter.Status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+releaseColumns+` FROM opinion_releases`+where+`
+		ORDER BY created_at DESC, id DESC LIMIT $4 OFFSET $5`,
+		runID, filter.Channel, filter.Status, pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []Release{}
+	for rows.Next() {
+		release, err := scanRelease(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, release)
+	}
+	return page, total, rows.Err()
+}
+
+// GetRelease returns the release with the given id within the run, or
+// ErrReleaseNotFound.
+func (s *PostgresStore) GetRelease(ctx context.Context, runID, id string) (Release, error) {
+	release, err := scanRelease(s.db.QueryRow(ctx, `SELECT `+releaseColumns+` FROM opinion_releases
+		WHERE run_id = $1 AND id = $2`, runID, id))
+	if pgstore.IsNoRows(err) {
+		return Release{}, ErrReleaseNotFound
+	}
+	return release, err
+}
+
+// UpdateRelease replaces the release with the same run and id, or
+// returns ErrReleaseNotFound.
+func (s *PostgresStore) UpdateRelease(ctx context.Context, release Release) error {
+	tag, err := s.db.Exec(ctx, `UPDATE opinion_releases SET channel = $3, title = $4, content = $5,
+		media_name = $6, status = $7, published_at = $8, metadata = $9, created_by = $10, created_at = $11,
+		updated_at = $12 WHERE run_id = $2 AND id = $1`,
+		release.ID, release.RunID, release.Channel, release.Title, release.Content, release.MediaName,
+		release.Status, release.PublishedAt, release.Metadata, release.CreatedBy, release.CreatedAt, release.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrReleaseNotFound)
+}
+
+// DeleteRelease removes the release with the given id within the run,
+// or returns ErrReleaseNotFound.
+func (s *PostgresStore) DeleteRelease(ctx context.Context, runID, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM opinion_releases WHERE run_id = $1 AND id = $2`, runID, id)
+	return pgstore.CheckAffected(tag, err, ErrReleaseNotFound)
+}
+
+// CreateMediaQuestion inserts the media question.
+func (s *PostgresStore) CreateMediaQuestion(ctx context.Context, question MediaQuestion) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO opinion_media_questions (`+mediaQuestionColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		question.ID, question.RunID, question.MediaName, question.Reporter, question.Question,
+		question.QuestionType, question.Answer, question.Status, question.AnsweredAt, question.Metadata,
+		question.CreatedBy, question.CreatedAt, question.UpdatedAt)
+	return err
+}
+
+// ListMediaQuestions returns the media questions of the run matching the
+// filter in question order (created_at ASC, id ASC), the total number of
+// matches and the paginated page.
+func (s *PostgresStore) ListMediaQuestions(ctx context.Context, runID string, filter MediaQuestionFilter) ([]MediaQuestion, int, error) {
+	const where = ` WHERE run_id = $1 AND ($2 = '' OR question_type = $2) AND ($3 = '' OR status = $3)`
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM opinion_media_questions`+where,
+		runID, filter.QuestionType, filter.Status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+mediaQuestionColumns+` FROM opinion_media_questions`+where+`
+		ORDER BY created_at, id LIMIT $4 OFFSET $5`,
+		runID, filter.QuestionType, filter.Status, pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []MediaQuestion{}
+	for rows.Next() {
+		question, err := scanMediaQuestion(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, question)
+	}
+	return page, total, rows.Err()
+}
+
+// GetMediaQuestion returns the media question with the given id within
+// the run, or ErrMediaQuestionNotFound.
+func (s *PostgresStore) GetMediaQuestion(ctx context.Context, runID, id string) (MediaQuestion, error) {
+	question, err := scanMediaQuestion(s.db.QueryRow(ctx, `SELECT `+mediaQuestionColumns+` FROM opinion_media_questions
+		WHERE run_id = $1 AND id = $2`, runID, id))
+	if pgstore.IsNoRows(err) {
+		return MediaQuestion{}, ErrMediaQuestionNotFound
+	}
+	return question, err
+}
+
+// UpdateMediaQuestion replaces the media question with the same run and
+// id, or returns ErrMediaQuestionNotFound.
+func (s *PostgresStore) UpdateMediaQuestion(ctx context.Context, question MediaQuestion) error {
+	tag, err := s.db.Exec(ctx, `UPDATE opinion_media_questions SET media_name = $3, reporter = $4,
+		question = $5, question_type = $6, answer = $7, status = $8, answered_at = $9, metadata = $10,
+		created_by = $11, created_at = $12, updated_at = $13 WHERE run_id = $2 AND id = $1`,
+		question.ID, question.RunID, question.MediaName, question.Reporter, question.Question,
+		question.QuestionType, question.Answer, question.Status, question.AnsweredAt, question.Metadata,
+		question.CreatedBy, question.CreatedAt, question.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrMediaQuestionNotFound)
+}
+
+// DeleteMediaQuestion removes the media question with the given id
+// within the run, or returns ErrMediaQuestionNotFound.
+func (s *PostgresStore) DeleteMediaQuestion(ctx context.Context, runID, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM opinion_media_questions WHERE run_id = $1 AND id = $2`, runID, id)
+	return pgstore.CheckAffected(tag, err, ErrMediaQuestionNotFound)
+}
+
+// CreateComplaint inserts the complaint.
+func (s *PostgresStore) CreateComplaint(ctx context.Context, complaint Complaint) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO opinion_complaints (`+complaintColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
+		complaint.ID, complaint.RunID, complaint.Complainant, complaint.Channel, complaint.ComplaintType,
+		complaint.Content, complaint.Status, complaint.Handling, complaint.Handler, complaint.ClosedAt,
+		complaint.Metadata, complaint.CreatedBy, complaint.CreatedAt, complaint.UpdatedAt)
+	return err
+}
+
+// ListComplaints returns the complaints of the run matching the filter
+// in intake order (created_at ASC, id ASC), the total number of matches
+// and the paginated page.
+func (s *PostgresStore) ListComplaints(ctx context.Context, runID string, filter ComplaintFilter) ([]Complaint, int, error) {
+	const where = ` WHERE run_id = $1 AND ($2 = '' OR channel = $2) AND ($3 = '' OR complaint_type = $3)
+		AND ($4 = '' OR status = $4)`
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM opinion_complaints`+where,
+		runID, filter.Channel, filter.ComplaintType, filter.Status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+complaintColumns+` FROM opinion_complaints`+where+`
+		ORDER BY created_at, id LIMIT $5 OFFSET $6`,
+		runID, filter.Channel, filter.ComplaintType, filter.Status, pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []Complaint{}
+	for rows.Next() {
+		complaint, err := scanComplaint(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, complaint)
+	}
+	return page, total, rows.Err()
+}
+
+// GetComplaint returns the complaint with the given id within the run,
+// or ErrComplaintNotFound.
+func (s *PostgresStore) GetComplaint(ctx context.Context, runID, id string) (Complaint, error) {
+	complaint, err := scanComplaint(s.db.QueryRow(ctx, `SELECT `+complaintColumns+` FROM opinion_complaints
+		WHERE run_id = $1 AND id = $2`, runID, id))
+	if pgstore.IsNoRows(err) {
+		return Complaint{}, ErrComplaintNotFound
+	}
+	return complaint, err
+}
+
+// UpdateComplaint replaces the complaint with the same run and id, or
+// returns ErrComplaintNotFound.
+func (s *PostgresStore) UpdateComplaint(ctx context.Context, complaint Complaint) error {
+	tag, err := s.db.Exec(ctx, `UPDATE opinion_complaints SET complainant = $3, channel = $4,
+		complaint_type = $5, content = $6, status = $7, handling = $8, handler = $9, closed_at = $10,
+		metadata = $11, created_by = $12, created_at = $13, updated_at = $14 WHERE run_id = $2 AND id = $1`,
+		complaint.ID, complaint.RunID, complaint.Complainant, complaint.Channel, complaint.ComplaintType,
+		complaint.Content, complaint.Status, complaint.Handling, complaint.Handler, complaint.ClosedAt,
+		complaint.Metadata, complaint.CreatedBy, complaint.CreatedAt, complaint.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrComplaintNotFound)
+}
+
+// DeleteComplaint removes the complaint with the given id within the
+// run, or returns ErrComplaintNotFound.
+func (s *PostgresStore) DeleteComplaint(ctx context.Context, runID, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM opinion_complaints WHERE run_id = $1 AND id = $2`, runID, id)
+	return pgstore.CheckAffected(tag, err, ErrComplaintNotFound)
+}
+
+// UpsertReview inserts the review or replaces the review of the same run.
+func (s *PostgresStore) UpsertReview(ctx context.Context, review Review) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO opinion_reviews (`+reviewColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (run_id) DO UPDATE SET id = EXCLUDED.id, case_summary = EXCLUDED.case_summary,
+			highlights = EXCLUDED.highlights, problems = EXCLUDED.problems, lessons = EXCLUDED.lessons,
+			suggestions = EXCLUDED.suggestions, metadata = EXCLUDED.metadata, created_by = EXCLUDED.created_by,
+			created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at`,
+		review.ID, review.RunID, review.CaseSummary, review.Highlights, review.Problems, review.Lessons,
+		review.Suggestions, review.Metadata, review.CreatedBy, review.CreatedAt, review.UpdatedAt)
+	return err
+}
+
+// GetReview returns the review of the run, or ErrReviewNotFound.
+func (s *PostgresStore) GetReview(ctx context.Context, runID string) (Review, error) {
+	var review Review
+	err := s.db.QueryRow(ctx, `SELECT `+reviewColumns+` FROM opinion_reviews WHERE run_id = $1`, runID).
+		Scan(&review.ID, &review.RunID, &review.CaseSummary, &review.Highlights, &review.Problems, &review.Lessons,
+			&review.Suggestions, &review.Metadata, &review.CreatedBy, &review.CreatedAt, &review.UpdatedAt)
+	if pgstore.IsNoRows(err) {
+		return Review{}, ErrReviewNotFound
+	}
+	return review, err
+}
+
+// DeleteReview removes the review of the run, or returns
+// ErrReviewNotFound.
+func (s *PostgresStore) DeleteReview(ctx context.Context, runID string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM opinion_reviews WHERE run_id = $1`, runID)
+	return pgstore.CheckAffected(tag, err, ErrReviewNotFound)
+}
+
+func scanPost(row pgstore.Row) (Post, error) {
+	var post Post
+	err := row.Scan(&post.ID, &post.RunID, &post.Source, &post.Content, &post.Sentiment, &post.Heat,
+		&post.WarnStatus, &post.WarnedAt, &post.Metadata, &post.CreatedBy, &post.CreatedAt, &post.UpdatedAt)
+	return post, err
+}
+
+func scanRelease(row pgstore.Row) (Release, error) {
+	var release Release
+	err := row.Scan(&release.ID, &release.RunID, &release.Channel, &release.Title, &release.Content,
+		&release.MediaName, &release.Status, &release.PublishedAt, &release.Metadata, &release.CreatedBy,
+		&release.CreatedAt, &release.UpdatedAt)
+	return release, err
+}
+
+func scanMediaQuestion(row pgstore.Row) (MediaQuestion, error) {
+	var question MediaQuestion
+	err := row.Scan(&question.ID, &question.RunID, &question.MediaName, &question.Reporter, &question.Question,
+		&question.QuestionType, &question.Answer, &question.Status, &question.AnsweredAt, &question.Metadata,
+		&question.CreatedBy, &question.CreatedAt, &question.UpdatedAt)
+	return question, err
+}
+
+func scanComplaint(row pgstore.Row) (Complaint, error) {
+	var complaint Complaint
+	err := row.Scan(&complaint.ID, &complaint.RunID, &complaint.Complainant, &complaint.Channel,
+		&complaint.ComplaintType, &complaint.Content, &complaint.Status, &complaint.Handling, &complaint.Handler,
+		&complaint.ClosedAt, &complaint.Metadata, &complaint.CreatedBy, &complaint.CreatedAt, &complaint.UpdatedAt)
+	return complaint, err
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/drills"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/runstream"
 	"github.com/ovaphlow/pitchfork/service-prototype/internal/ulid"
 )
 
@@ -47,10 +48,11 @@ func (a *runSourceAdapter) GetRun(ctx context.Context, id string) (drills.Run, e
 // checks and server-generated ids and timestamps) on top of the store
 // and the injected run source.
 type Service struct {
-	store  Store
-	source RunSource
-	now    func() time.Time
-	newID  func() string
+	store     Store
+	source    RunSource
+	publisher runstream.Publisher // nil until wired: change events of the run stream
+	now       func() time.Time
+	newID     func() string
 }
 
 // NewService builds a service over the given store and run source. The
@@ -59,6 +61,21 @@ func NewService(store Store, source RunSource) *Service {
 	return &Service{store: store, source: source, now: time.Now, newID: ulid.New}
 }
 
+// SetPublisher wires the run stream: from then on every committed
+// create, update and delete of an opinion post is published as a change
+// event of its run. Calling it is optional; without a publisher the
+// writes behave exactly as before.
+func (s *Service) SetPublisher(publisher runstream.Publisher) {
+	s.publisher = publisher
+}
+
+// publish hands a committed change to the wired publisher, if any.
+func (s *Service) publish(runID string, kind runstream.Kind, action runstream.Action, objectID string, data any) {
+	if s.publisher != nil {
+		s.publisher.Publish(runID, kind, action, objectID, data)
+	}
+}
+
 // writableRun reports whether a run in the given status may be
 // configured: 未开始 and 进行中 are writable, 已完成 and 已终止 are not.
 func writableRun(status drills.RunStatus) bool {
@@ -6,14 +6,14 @@ import (
 )
 
 // Store persists the opinion event configurations (module 5 of the
-// public-opinion-response training). The prototype ships the in-memory
-// implementation; the interface keeps the service layer independent of
-// the storage backend. The cascade rule of the database (the event
-// vanishes when its run is deleted) is implemented by DeleteByRun, the
-// uniform cleanup entry the drills service calls through its
-// run-opinion cleaner hook. The releases, the media questions, the
-// complaints and the reviews of a run cascade the same way: DeleteByRun
-// appends the cleanup of every opinion object kind.
+// public-opinion-response training). The prototype ships an in-memory
+// and a PostgreSQL implementation; the interface keeps the service layer
+// independent of the storage backend. The cascade rule of the database
+// (the event vanishes when its run is deleted) is implemented by
+// DeleteByRun, the uniform cleanup entry the drills service calls
+// through its run-opinion cleaner hook. The releases, the media
+// questions, the complaints and the reviews of a run cascade the same
+// way: DeleteByRun appends the cleanup of every opinion object kind.
 type Store interface {
 	UpsertEvent(ctx context.Context, event Event) error
 	GetEvent(ctx context.Context, runID string) (Event, error)
@@ -66,11 +66,11 @@ type Store interface {
 
 // InMemoryStore keeps the opinion event rows in an insertion-ordered
 // slice guarded by a mutex. It implements Store for the prototype and
-// never touches a database; a database-backed store arrives with a
-// later slice. At most one event row exists per run (the service
-// upserts by run_id). It also implements the drills.RunOpinionCleaner
-// interface (DeleteByRun), so the run-deletion cascade works against
-// the real event data.
+// never touches a database; PostgresStore is the database-backed
+// counterpart. At most one event row exists per run (the service upserts
+// by run_id). It also implements the drills.RunOpinionCleaner interface
+// (DeleteByRun), so the run-deletion cascade works against the real
+// event data.
 type InMemoryStore struct {
 	mu             sync.Mutex
 	events         []Event
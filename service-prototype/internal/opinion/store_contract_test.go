@@ -0,0 +1,158 @@
+package opinion
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/databasetest"
+)
+
+func TestInMemoryStoreContract(t *testing.T) {
+	runStoreContract(t, NewInMemoryStore(), func(string) {})
+}
+
+func TestPostgresStoreContract(t *testing.T) {
+	db := databasetest.Open(t)
+	runStoreContract(t, NewPostgresStore(db), func(runID string) { databasetest.InsertRun(t, db, runID) })
+}
+
+// runStoreContract pins the Store semantics every backend shares: the
+// event and review upserts replace in place, rows are addressed within
+// their run, the listings keep their documented orders and DeleteByRun
+// clears every opinion object of one run only. addRun creates the drill
+// run a backend with foreign keys needs.
+func runStoreContract(t *testing.T, store interface {
+	Store
+	DeleteByRun(ctx context.Context, runID string) error
+}, addRun func(runID string)) {
+	ctx := context.Background()
+	base := databasetest.Time(2026, 8, 1, 9, 0, 0)
+	later := base.Add(time.Minute)
+	addRun("run-a")
+	addRun("run-b")
+
+	event := Event{ID: "evt-1", RunID: "run-a", EventName: "排队冲突", Level: LevelMedium, Status: StatusMonitoring, OccurredAt: &base, Metadata: map[string]any{}, CreatedAt: base, UpdatedAt: base}
+	if err := store.UpsertEvent(ctx, event); err != nil {
+		t.Fatalf("insert event: %v", err)
+	}
+	event.Level = LevelHigh
+	event.Status = StatusWarning
+	if err := store.UpsertEvent(ctx, event); err != nil {
+		t.Fatalf("update event: %v", err)
+	}
+	gotEvent, err := store.GetEvent(ctx, "run-a")
+	if err != nil {
+		t.Fatalf("get event: %v", err)
+	}
+	databasetest.AssertSameJSON(t, gotEvent, event)
+
+	posts := []Post{
+		{ID: "post-1", RunID: "run-a", Source: SourceWeibo, Content: "现场排长队", Sentiment: SentimentNegative, Heat: 80, WarnStatus: WarnStatusWarned, WarnedAt: &later, Metadata: map[string]any{"url": "x"}, CreatedAt: base, UpdatedAt: base},
+		{ID: "post-2", RunID: "run-a", Source: SourceNews, Content: "馆方回应", Sentiment: SentimentNeutral, Heat: 20, WarnStatus: WarnStatusPending, Metadata: map[string]any{}, CreatedAt: later, UpdatedAt: later},
+	}
+	for _, item := range posts {
+		if err := store.CreatePost(ctx, item); err != nil {
+			t.Fatalf("create post %s: %v", item.ID, err)
+		}
+	}
+	gotPost, err := store.GetPost(ctx, "run-a", "post-1")
+	if err != nil {
+		t.Fatalf("get post: %v", err)
+	}
+	databasetest.AssertSameJSON(t, gotPost, posts[0])
+	listedPosts, total, err := store.ListPosts(ctx, "run-a", PostFilter{Limit: -1})
+	if err != nil || total != 2 || listedPosts[0].ID != "post-2" {
+		t.Fatalf("posts = %d %v %v, want newest post-2 first", total, listedPosts, err)
+	}
+	if _, err := store.GetPost(ctx, "run-b", "post-1"); !errors.Is(err, ErrPostNotFound) {
+		t.Fatalf("post of another run = %v, want ErrPostNotFound", err)
+	}
+
+	release := Release{ID: "rel-1", RunID: "run-a", Channel: ChannelWechat, Title: "情况通报", Content: "正文", Status: ReleaseStatusDraft, Metadata: map[string]any{}, CreatedAt: base, UpdatedAt: base}
+	if err := store.CreateRelease(ctx, release); err != nil {
+		t.Fatalf("create release: %v", err)
+	}
+	release.Status = ReleaseStatusPublished
+	release.PublishedAt = &later
+	if err := store.UpdateRelease(ctx, release); err != nil {
+		t.Fatalf("update release: %v", err)
+	}
+	releases, total, err := store.ListReleases(ctx, "run-a", ReleaseFilter{Status: ReleaseStatusPublished, Limit: -1})
+	if err != nil || total != 1 {
+		t.Fatalf("published releases = %d %v %v, want 1", total, releases, err)
+	}
+	databasetest.AssertSameJSON(t, releases[0], release)
+
+	questions := []MediaQuestion{
+		{ID: "mq-2", RunID: "run-a", MediaName: "晚报", Question: "伤亡情况？", QuestionType: QuestionTypeFactual, Status: AnswerStatusPending, Metadata: map[string]any{}, CreatedAt: later, UpdatedAt: later},
+		{ID: "mq-1", RunID: "run-a", MediaName: "电视台", Reporter: "李四", Question: "为何限流？", QuestionType: QuestionTypeChallenging, Status: AnswerStatusPending, Metadata: map[string]any{}, CreatedAt: base, UpdatedAt: base},
+	}
+	for _, item := range questions {
+		if err := store.CreateMediaQuestion(ctx, item); err != nil {
+			t.Fatalf("create question %s: %v", item.ID, err)
+		}
+	}
+	listedQuestions, total, err := store.ListMediaQuestions(ctx, "run-a", MediaQuestionFilter{Limit: 1})
+	if err != nil || total != 2 || len(listedQuestions) != 1 || listedQuestions[0].ID != "mq-1" {
+		t.Fatalf("first question page = %d %v %v, want mq-1", total, listedQuestions, err)
+	}
+
+	complaint := Complaint{ID: "cp-1", RunID: "run-a", Complainant: "王五", Channel: ComplaintChannelTransfer, ComplaintType: ComplaintTypeEntryBlocked, Content: "无法入馆", Status: ComplaintStatusPending, Metadata: map[string]any{}, CreatedAt: base, UpdatedAt: base}
+	if err := store.CreateComplaint(ctx, complaint); err != nil {
+		t.Fatalf("create complaint: %v", err)
+	}
+	complaint.Status = ComplaintStatusClosed
+	complaint.Handling = "已致歉"
+	complaint.ClosedAt = &later
+	if err := store.UpdateComplaint(ctx, complaint); err != nil {
+		t.Fatalf("update complaint: %v", err)
+	}
+	gotComplaint, err := store.GetComplaint(ctx, "run-a", "cp-1")
+	if err != nil {
+		t.Fatalf("get complaint: %v", err)
+	}
+	databasetest.AssertSameJSON(t, gotComplaint, complaint)
+
+	review := Review{ID: "rv-1", RunID: "run-a", CaseSummary: "概述", Metadata: map[string]any{}, CreatedAt: base, UpdatedAt: base}
+	if err := store.UpsertReview(ctx, review); err != nil {
+		t.Fatalf("insert review: %v", err)
+	}
+	review.Lessons = "及时发布"
+	if err := store.UpsertReview(ctx, review); err != nil {
+		t.Fatalf("update review: %v", err)
+	}
+	gotReview, err := store.GetReview(ctx, "run-a")
+	if err != nil {
+		t.Fatalf("get review: %v", err)
+	}
+	databasetest.AssertSameJSON(t, gotReview, review)
+
+	if err := store.CreatePost(ctx, Post{ID: "post-b", RunID: "run-b", Source: SourceWeibo, Content: "保留", Sentiment: SentimentPositive, WarnStatus: WarnStatusPending, Metadata: map[string]any{}, CreatedAt: base, UpdatedAt: base}); err != nil {
+		t.Fatalf("create post of run-b: %v", err)
+	}
+	if err := store.DeleteByRun(ctx, "run-a"); err != nil {
+		t.Fatalf("delete by run: %v", err)
+	}
+	if _, total, _ := store.ListPosts(ctx, "run-b", PostFilter{Limit: -1}); total != 1 {
+		t.Fatalf("posts of run-b = %d, want 1 after cleaning run-a", total)
+	}
+
+	notFound := map[string]struct {
+		err  error
+		want error
+	}{
+		"event":          {store.DeleteEvent(ctx, "run-a"), ErrEventNotFound},
+		"post":           {store.UpdatePost(ctx, posts[0]), ErrPostNotFound},
+		"release":        {store.DeleteRelease(ctx, "run-a", "rel-1"), ErrReleaseNotFound},
+		"media question": {store.UpdateMediaQuestion(ctx, questions[0]), ErrMediaQuestionNotFound},
+		"complaint":      {store.DeleteComplaint(ctx, "run-a", "cp-1"), ErrComplaintNotFound},
+		"review":         {store.DeleteReview(ctx, "run-a"), ErrReviewNotFound},
+	}
+	for name, check := range notFound {
+		if !errors.Is(check.err, check.want) {
+			t.Errorf("%s after cleanup = %v, want %v", name, check.err, check.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package papers
+
+import (
+	"context"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
+)
+
+// PostgresStore persists exam papers in the exam_papers table (migration
+// 000005); the generation strategy and the question snapshot are JSONB
+// columns. It implements Store with the same semantics as the in-memory
+// store: a missing id answers ErrNotFound and listing orders by
+// created_at DESC, id DESC.
+type PostgresStore struct {
+	db pgstore.Querier
+}
+
+// NewPostgresStore returns a paper store over the given connection.
+func NewPostgresStore(db pgstore.Querier) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+const paperColumns = `id, title, duration_minutes, pass_score, generation_strategy, questions,
+	created_by, created_at, updated_at`
+
+// Create inserts the paper.
+func (s *PostgresStore) Create(ctx context.Context, paper Paper) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO exam_papers (`+paperColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		paper.ID, paper.Title, paper.DurationMinutes, paper.PassScore, paper.GenerationStrategy,
+		snapshotsArg(paper.Questions), paper.CreatedBy, paper.CreatedAt, paper.UpdatedAt)
+	return err
+}
+
+// List returns the papers newest first, the total number of papers and
+// the paginated page.
+func (s *PostgresStore) List(ctx context.Context, filter Filter) ([]Paper, int, error) {
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM exam_papers`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+paperColumns+` FROM exam_papers
+		ORDER BY created_at DESC, id DESC LIMIT $1 OFFSET $2`,
+		pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []Paper{}
+	for rows.Next() {
+		paper, err := scanPaper(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, paper)
+	}
+	return page, total, rows.Err()
+}
+
+// Get returns the paper with the given id, or ErrNotFound.
+func (s *PostgresStore) Get(ctx context.Context, id string) (Paper, error) {
+	paper, err := scanPaper(s.db.QueryRow(ctx, `SELECT `+paperColumns+` FROM exam_papers WHERE id = $1`, id))
+	if pgstore.IsNoRows(err) {
+		return Paper{}, ErrNotFound
+	}
+	return paper, err
+}
+
+// Update replaces the paper with the same id (including the generated
+// question snapshot), or returns ErrNotFound.
+func (s *PostgresStore) Update(ctx context.Context, paper Paper) error {
+	tag, err := s.db.Exec(ctx, `UPDATE exam_papers SET title = $2, duration_minutes = $3, pass_score = $4,
+		generation_strategy = $5, questions = $6, created_by = $7, created_at = $8, updated_at = $9
+		WHERE id = $1`,
+		paper.ID, paper.Title, paper.DurationMinutes, paper.PassScore, paper.GenerationStrategy,
+		snapshotsArg(paper.Questions), paper.CreatedBy, paper.CreatedAt, paper.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrNotFound)
+}
+
+// Delete removes the paper with the given id, or returns ErrNotFound.
+func (s *PostgresStore) Delete(ctx context.Context, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM exam_papers WHERE id = $1`, id)
+	return pgstore.CheckAffected(tag, err, ErrNotFound)
+}
+
+// snapshotsArg stores a nil snapshot list as an empty JSON array.
+func snapshotsArg(snapshots []QuestionSnapshot) []QuestionSnapshot {
+	if snapshots == nil {
+		return []QuestionSnapshot{}
+	}
+	return snapshots
+}
+
+func scanPaper(row pgstore.Row) (Paper, error) {
+	var paper Paper
+	err := row.Scan(&paper.ID, &paper.Title, &paper.DurationMinutes, &paper.PassScore,
+		&paper.GenerationStrategy, &paper.Questions, &paper.CreatedBy, &paper.CreatedAt, &paper.UpdatedAt)
+	return paper, err
+}
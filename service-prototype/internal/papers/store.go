@@ -6,9 +6,9 @@ import (
 	"sync"
 )
 
-// Store persists papers. The prototype ships the in-memory
-// implementation; the interface keeps the routing and service layers
-// independent of the storage backend.
+// Store persists papers. The prototype ships an in-memory and a
+// PostgreSQL implementation; the interface keeps the routing and service
+// layers independent of the storage backend.
 type Store interface {
 	Create(ctx context.Context, paper Paper) error
 	List(ctx context.Context, filter Filter) ([]Paper, int, error)
@@ -18,8 +18,8 @@ type Store interface {
 }
 
 // InMemoryStore keeps papers in a slice guarded by a mutex. It
-// implements Store for the prototype and never touches a database; a
-// database-backed store arrives with a later slice.
+// implements Store for the prototype and never touches a database;
+// PostgresStore is the database-backed counterpart.
 type InMemoryStore struct {
 	mu    sync.Mutex
 	items []Paper
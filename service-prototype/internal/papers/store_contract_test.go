@@ -0,0 +1,74 @@
+package papers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/databasetest"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/questions"
+)
+
+func TestInMemoryStoreContract(t *testing.T) {
+	runStoreContract(t, NewInMemoryStore())
+}
+
+func TestPostgresStoreContract(t *testing.T) {
+	runStoreContract(t, NewPostgresStore(databasetest.Open(t)))
+}
+
+// runStoreContract pins the Store semantics every backend shares: the
+// strategy and the generated snapshot survive a round trip, listing is
+// newest first (ties by id descending) and paginates after counting, and
+// a missing id answers ErrNotFound.
+func runStoreContract(t *testing.T, store Store) {
+	ctx := context.Background()
+	base := databasetest.Time(2026, 8, 4, 9, 0, 0)
+	items := []Paper{
+		{ID: "paper-a", Title: "消防基础", DurationMinutes: 30, PassScore: 60, GenerationStrategy: map[string]int{"单选": 2}, Questions: []QuestionSnapshot{}, CreatedBy: "u1", CreatedAt: base, UpdatedAt: base},
+		{ID: "paper-b", Title: "疏散演练", DurationMinutes: 45, PassScore: 0, GenerationStrategy: map[string]int{"判断": 1, "填空": 0}, Questions: []QuestionSnapshot{}, CreatedAt: base.Add(time.Minute), UpdatedAt: base.Add(time.Minute)},
+		{ID: "paper-c", Title: "同刻", DurationMinutes: 10, PassScore: 100, GenerationStrategy: map[string]int{"多选": 1}, Questions: []QuestionSnapshot{}, CreatedAt: base.Add(time.Minute), UpdatedAt: base.Add(time.Minute)},
+	}
+	for _, item := range items {
+		if err := store.Create(ctx, item); err != nil {
+			t.Fatalf("create %s: %v", item.ID, err)
+		}
+	}
+
+	page, total, err := store.List(ctx, Filter{Limit: 2})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if total != 3 || len(page) != 2 || page[0].ID != "paper-c" || page[1].ID != "paper-b" {
+		t.Fatalf("list = %d %v, want total 3 and paper-c, paper-b", total, page)
+	}
+
+	generated := items[0]
+	generated.Questions = []QuestionSnapshot{
+		{ID: "q1", Type: questions.QuestionTypeSingle, Difficulty: 2, Content: "题干", Options: []string{"A", "B"}, Answer: "A"},
+		{ID: "q2", Type: questions.QuestionTypeMultiple, Difficulty: 3, Content: "多选", Options: []string{"A", "B", "C"}, Answer: []any{"A", "B"}},
+	}
+	generated.UpdatedAt = base.Add(time.Hour)
+	if err := store.Update(ctx, generated); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	got, err := store.Get(ctx, "paper-a")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	databasetest.AssertSameJSON(t, got, generated)
+
+	if err := store.Delete(ctx, "paper-a"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "paper-a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get deleted = %v, want ErrNotFound", err)
+	}
+	if err := store.Update(ctx, generated); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("update deleted = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, "paper-a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("delete twice = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,80 @@
+package progress
+
+import (
+	"context"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
+)
+
+// PostgresStore persists learning progress in the learning_progress
+// table (migration 000007). Upsert relies on the table's unique key
+// (assignment_id, employee_id, chapter_id), so a second report of the
+// same chapter replaces the row in place exactly like the in-memory
+// store.
+type PostgresStore struct {
+	db pgstore.Querier
+}
+
+// NewPostgresStore returns a progress store over the given connection.
+func NewPostgresStore(db pgstore.Querier) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+const progressColumns = `id, assignment_id, employee_id, chapter_id, progress_percent, status, detail,
+	started_at, completed_at, created_at, updated_at`
+
+// Upsert creates the row, or replaces the row with the same
+// (assignment_id, employee_id, chapter_id) key.
+func (s *PostgresStore) Upsert(ctx context.Context, progress Progress) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO learning_progress (`+progressColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (assignment_id, employee_id, chapter_id) DO UPDATE SET
+			id = EXCLUDED.id, progress_percent = EXCLUDED.progress_percent, status = EXCLUDED.status,
+			detail = EXCLUDED.detail, started_at = EXCLUDED.started_at, completed_at = EXCLUDED.completed_at,
+			created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at`,
+		progress.ID, progress.AssignmentID, progress.EmployeeID, progress.ChapterID,
+		progress.ProgressPercent, progress.Status, progress.Detail, progress.StartedAt,
+		progress.CompletedAt, progress.CreatedAt, progress.UpdatedAt)
+	return err
+}
+
+// GetByKey returns the row of one (assignment, employee, chapter)
+// triple, or ErrNotFound.
+func (s *PostgresStore) GetByKey(ctx context.Context, assignmentID, employeeID, chapterID string) (Progress, error) {
+	progress, err := scanProgress(s.db.QueryRow(ctx, `SELECT `+progressColumns+` FROM learning_progress
+		WHERE assignment_id = $1 AND employee_id = $2 AND chapter_id = $3`,
+		assignmentID, employeeID, chapterID))
+	if pgstore.IsNoRows(err) {
+		return Progress{}, ErrNotFound
+	}
+	return progress, err
+}
+
+// ListByAssignment returns every row of one (assignment, employee) pair
+// in creation order.
+func (s *PostgresStore) ListByAssignment(ctx context.Context, assignmentID, employeeID string) ([]Progress, error) {
+	rows, err := s.db.Query(ctx, `SELECT `+progressColumns+` FROM learning_progress
+		WHERE assignment_id = $1 AND employee_id = $2 ORDER BY created_at, id`,
+		assignmentID, employeeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []Progress
+	for rows.Next() {
+		progress, err := scanProgress(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, progress)
+	}
+	return list, rows.Err()
+}
+
+func scanProgress(row pgstore.Row) (Progress, error) {
+	var progress Progress
+	err := row.Scan(&progress.ID, &progress.AssignmentID, &progress.EmployeeID, &progress.ChapterID,
+		&progress.ProgressPercent, &progress.Status, &progress.Detail, &progress.StartedAt,
+		&progress.CompletedAt, &progress.CreatedAt, &progress.UpdatedAt)
+	return progress, err
+}
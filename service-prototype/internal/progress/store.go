@@ -5,12 +5,12 @@ import (
 	"sync"
 )
 
-// Store persists learning-progress rows. The prototype ships the
-// in-memory implementation; the interface keeps the routing and service
-// layers independent of the storage backend. Upsert creates the row on
-// the first report of a chapter and replaces it on later reports, keyed
-// by (assignment_id, employee_id, chapter_id) — the unique key of the
-// table.
+// Store persists learning-progress rows. The prototype ships an
+// in-memory and a PostgreSQL implementation; the interface keeps the
+// routing and service layers independent of the storage backend. Upsert
+// creates the row on the first report of a chapter and replaces it on
+// later reports, keyed by (assignment_id, employee_id, chapter_id) — the
+// unique key of the table.
 type Store interface {
 	Upsert(ctx context.Context, progress Progress) error
 	GetByKey(ctx context.Context, assignmentID, employeeID, chapterID string) (Progress, error)
@@ -20,8 +20,8 @@ type Store interface {
 // InMemoryStore keeps progress rows in an insertion-ordered slice
 // guarded by a mutex. Upsert deduplicates on the unique key, so a second
 // report of the same chapter never grows the store. It implements Store
-// for the prototype and never touches a database; a database-backed
-// store arrives with a later slice.
+// for the prototype and never touches a database; PostgresStore is the
+// database-backed counterpart.
 type InMemoryStore struct {
 	mu    sync.Mutex
 	items []Progress
@@ -0,0 +1,70 @@
+package progress
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/databasetest"
+)
+
+func TestInMemoryStoreContract(t *testing.T) {
+	runStoreContract(t, NewInMemoryStore(), func(string, string) {})
+}
+
+func TestPostgresStoreContract(t *testing.T) {
+	db := databasetest.Open(t)
+	databasetest.Exec(t, db, `INSERT INTO courses (id, title, topic, type) VALUES ('course-1', '课程', '舆情应对', '线上授课')`)
+	databasetest.Exec(t, db, `INSERT INTO training_assignments (id, course_id, assign_type, target_type, target_ids)
+		VALUES ('as-1', 'course-1', '手动指派', '用户', '["e1","e2"]')`)
+	runStoreContract(t, NewPostgresStore(db), func(assignmentID, chapterID string) {
+		databasetest.Exec(t, db, `INSERT INTO course_chapters (id, course_id, title) VALUES ($1, 'course-1', $1)`, chapterID)
+	})
+}
+
+// runStoreContract pins the Store semantics every backend shares: the
+// first report creates the row, a second report of the same key replaces
+// it in place, and listing is scoped to one (assignment, employee) pair.
+// The addChapter hook creates the parent rows the database foreign keys
+// require; the in-memory store needs none.
+func runStoreContract(t *testing.T, store Store, addChapter func(assignmentID, chapterID string)) {
+	ctx := context.Background()
+	addChapter("as-1", "ch-1")
+	addChapter("as-1", "ch-2")
+	base := databasetest.Time(2026, 8, 6, 9, 0, 0)
+	started := base
+	first := Progress{ID: "p-1", AssignmentID: "as-1", EmployeeID: "e1", ChapterID: "ch-1", ProgressPercent: 40, Status: StatusLearning, Detail: map[string]any{"position": 12.0}, StartedAt: &started, CreatedAt: base, UpdatedAt: base}
+	if err := store.Upsert(ctx, first); err != nil {
+		t.Fatalf("first report: %v", err)
+	}
+	completed := base.Add(time.Hour)
+	second := first
+	second.ProgressPercent = 100
+	second.Status = StatusCompleted
+	second.CompletedAt = &completed
+	second.UpdatedAt = completed
+	if err := store.Upsert(ctx, second); err != nil {
+		t.Fatalf("second report: %v", err)
+	}
+	got, err := store.GetByKey(ctx, "as-1", "e1", "ch-1")
+	if err != nil {
+		t.Fatalf("get by key: %v", err)
+	}
+	databasetest.AssertSameJSON(t, got, second)
+
+	other := Progress{ID: "p-2", AssignmentID: "as-1", EmployeeID: "e2", ChapterID: "ch-2", ProgressPercent: 10, Status: StatusLearning, Detail: map[string]any{}, CreatedAt: base.Add(time.Minute), UpdatedAt: base.Add(time.Minute)}
+	if err := store.Upsert(ctx, other); err != nil {
+		t.Fatalf("other employee: %v", err)
+	}
+	list, err := store.ListByAssignment(ctx, "as-1", "e1")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != "p-1" {
+		t.Fatalf("e1 rows = %v, want only p-1 (the second report must not duplicate it)", list)
+	}
+	if _, err := store.GetByKey(ctx, "as-1", "e1", "ch-2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing key = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,108 @@
+package questions
+
+import (
+	"context"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/pgstore"
+)
+
+// PostgresStore persists the question bank in the questions table
+// (migration 000004). content and explanation are JSONB strings, so they
+// are written with to_jsonb and read back as text. It implements Store
+// with the same semantics as the in-memory store: a missing id answers
+// ErrNotFound, listing keeps the creation order and tags match with AND
+// semantics.
+type PostgresStore struct {
+	db pgstore.Querier
+}
+
+// NewPostgresStore returns a question store over the given connection.
+func NewPostgresStore(db pgstore.Querier) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+const questionColumns = `id, type, difficulty, tags, content #>> '{}', options, answer,
+	explanation #>> '{}', metadata, created_by, created_at, updated_at`
+
+// Create inserts the question.
+func (s *PostgresStore) Create(ctx context.Context, question Question) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO questions (id, type, difficulty, tags, content, options, answer,
+		explanation, metadata, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, to_jsonb($5::text), $6, $7, to_jsonb($8::text), $9, $10, $11, $12)`,
+		question.ID, question.Type, question.Difficulty, stringsArg(question.Tags), question.Content,
+		stringsArg(question.Options), question.Answer, question.Explanation, question.Metadata,
+		question.CreatedBy, question.CreatedAt, question.UpdatedAt)
+	return err
+}
+
+// List returns the questions matching the filter in creation order, the
+// total number of matches and the paginated page. The tag filter uses
+// JSONB containment, which is the AND match of the in-memory store.
+func (s *PostgresStore) List(ctx context.Context, filter Filter) ([]Question, int, error) {
+	const where = ` WHERE ($1 = '' OR type = $1) AND ($2 = 0 OR difficulty = $2) AND tags @> $3`
+	tags := stringsArg(filter.Tags)
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) FROM questions`+where,
+		filter.Type, filter.Difficulty, tags).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+questionColumns+` FROM questions`+where+`
+		ORDER BY created_at, id LIMIT $4 OFFSET $5`,
+		filter.Type, filter.Difficulty, tags, pgstore.LimitArg(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	page := []Question{}
+	for rows.Next() {
+		question, err := scanQuestion(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page, question)
+	}
+	return page, total, rows.Err()
+}
+
+// Get returns the question with the given id, or ErrNotFound.
+func (s *PostgresStore) Get(ctx context.Context, id string) (Question, error) {
+	question, err := scanQuestion(s.db.QueryRow(ctx, `SELECT `+questionColumns+` FROM questions WHERE id = $1`, id))
+	if pgstore.IsNoRows(err) {
+		return Question{}, ErrNotFound
+	}
+	return question, err
+}
+
+// Update replaces the question with the same id, or returns ErrNotFound.
+func (s *PostgresStore) Update(ctx context.Context, question Question) error {
+	tag, err := s.db.Exec(ctx, `UPDATE questions SET type = $2, difficulty = $3, tags = $4,
+		content = to_jsonb($5::text), options = $6, answer = $7, explanation = to_jsonb($8::text),
+		metadata = $9, created_by = $10, created_at = $11, updated_at = $12 WHERE id = $1`,
+		question.ID, question.Type, question.Difficulty, stringsArg(question.Tags), question.Content,
+		stringsArg(question.Options), question.Answer, question.Explanation, question.Metadata,
+		question.CreatedBy, question.CreatedAt, question.UpdatedAt)
+	return pgstore.CheckAffected(tag, err, ErrNotFound)
+}
+
+// Delete removes the question with the given id, or returns ErrNotFound.
+func (s *PostgresStore) Delete(ctx context.Context, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM questions WHERE id = $1`, id)
+	return pgstore.CheckAffected(tag, err, ErrNotFound)
+}
+
+// stringsArg stores a nil string list as an empty JSON array instead of
+// a JSON null.
+func stringsArg(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+func scanQuestion(row pgstore.Row) (Question, error) {
+	var question Question
+	err := row.Scan(&question.ID, &question.Type, &question.Difficulty, &question.Tags,
+		&question.Content, &question.Options, &question.Answer, &question.Explanation,
+		&question.Metadata, &question.CreatedBy, &question.CreatedAt, &question.UpdatedAt)
+	return question, err
+}
@@ -5,9 +5,9 @@ import (
 	"sync"
 )
 
-// Store persists questions. The prototype ships the in-memory
-// implementation; the interface keeps the routing and service layers
-// independent of the storage backend.
+// Store persists questions. The prototype ships an in-memory and a
+// PostgreSQL implementation; the interface keeps the routing and service
+// layers independent of the storage backend.
 type Store interface {
 	Create(ctx context.Context, question Question) error
 	List(ctx context.Context, filter Filter) ([]Question, int, error)
@@ -18,7 +18,7 @@ type Store interface {
 
 // InMemoryStore keeps questions in an insertion-ordered slice guarded by
 // a mutex. It implements Store for the prototype and never touches a
-// database; a database-backed store arrives with a later slice.
+// database; PostgresStore is the database-backed counterpart.
 type InMemoryStore struct {
 	mu    sync.Mutex
 	items []Question
@@ -128,11 +128,12 @@ func containsAllTags(have, want []string) bool {
 
 // cloneQuestion copies a question so the caller never aliases the stored
 // value; the slices, the answer array and the metadata map are copied as
-// well.
+// well. Empty tags and options stay empty arrays (never null), matching
+// what the database store reads back.
 func cloneQuestion(question Question) Question {
 	cloned := question
-	cloned.Tags = append([]string(nil), question.Tags...)
-	cloned.Options = append([]string(nil), question.Options...)
+	cloned.Tags = append([]string{}, question.Tags...)
+	cloned.Options = append([]string{}, question.Options...)
 	cloned.Metadata = make(map[string]any, len(question.Metadata))
 	for key, value := range question.Metadata {
 		cloned.Metadata[key] = value
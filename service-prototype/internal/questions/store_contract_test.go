@@ -0,0 +1,87 @@
+package questions
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/database/databasetest"
+)
+
+func TestInMemoryStoreContract(t *testing.T) {
+	runStoreContract(t, NewInMemoryStore())
+}
+
+func TestPostgresStoreContract(t *testing.T) {
+	runStoreContract(t, NewPostgresStore(databasetest.Open(t)))
+}
+
+// runStoreContract pins the Store semantics every backend shares: the
+// string and array answers survive a round trip, type / difficulty /
+// tag filters combine, listing keeps the creation order and a missing
+// id answers ErrNotFound.
+func runStoreContract(t *testing.T, store Store) {
+	ctx := context.Background()
+	base := databasetest.Time(2026, 8, 3, 8, 0, 0)
+	items := []Question{
+		{ID: "q-single", Type: QuestionTypeSingle, Difficulty: 2, Tags: []string{"消防", "基础"}, Content: "灭火器类型？", Options: []string{"A", "B"}, Answer: "A", Explanation: "常识", Metadata: map[string]any{}, CreatedBy: "u1", CreatedAt: base, UpdatedAt: base},
+		{ID: "q-multi", Type: QuestionTypeMultiple, Difficulty: 3, Tags: []string{"消防"}, Content: "疏散要点？", Options: []string{"A", "B", "C"}, Answer: []any{"A", "C"}, Metadata: map[string]any{"source": "题库"}, CreatedAt: base.Add(time.Minute), UpdatedAt: base.Add(time.Minute)},
+		{ID: "q-fill", Type: QuestionTypeFill, Difficulty: 2, Tags: []string{"基础"}, Content: "报警电话____", Options: []string{}, Answer: "119", Metadata: map[string]any{}, CreatedAt: base.Add(2 * time.Minute), UpdatedAt: base.Add(2 * time.Minute)},
+	}
+	for _, item := range items {
+		if err := store.Create(ctx, item); err != nil {
+			t.Fatalf("create %s: %v", item.ID, err)
+		}
+	}
+	for _, want := range items {
+		got, err := store.Get(ctx, want.ID)
+		if err != nil {
+			t.Fatalf("get %s: %v", want.ID, err)
+		}
+		databasetest.AssertSameJSON(t, got, want)
+	}
+
+	page, total, err := store.List(ctx, Filter{Tags: []string{"消防", "基础"}, Limit: -1})
+	if err != nil {
+		t.Fatalf("list by tags: %v", err)
+	}
+	if total != 1 || len(page) != 1 || page[0].ID != "q-single" {
+		t.Fatalf("tags AND match = %d %v, want q-single only", total, page)
+	}
+	page, total, err = store.List(ctx, Filter{Difficulty: 2, Limit: 1, Offset: 1})
+	if err != nil {
+		t.Fatalf("list by difficulty: %v", err)
+	}
+	if total != 2 || len(page) != 1 || page[0].ID != "q-fill" {
+		t.Fatalf("difficulty page = %d %v, want total 2 and q-fill", total, page)
+	}
+	if _, total, _ := store.List(ctx, Filter{Type: QuestionTypeMultiple, Limit: -1}); total != 1 {
+		t.Fatalf("type filter total = %d, want 1", total)
+	}
+
+	updated := items[2]
+	updated.Answer = "110"
+	updated.Explanation = "更正"
+	if err := store.Update(ctx, updated); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	got, err := store.Get(ctx, "q-fill")
+	if err != nil {
+		t.Fatalf("get updated: %v", err)
+	}
+	databasetest.AssertSameJSON(t, got, updated)
+
+	if err := store.Delete(ctx, "q-fill"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "q-fill"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get deleted = %v, want ErrNotFound", err)
+	}
+	if err := store.Update(ctx, updated); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("update deleted = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, "q-fill"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("delete twice = %v, want ErrNotFound", err)
+	}
+}
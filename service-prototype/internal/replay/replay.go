@@ -0,0 +1,427 @@
+// Package replay implements the after-action view of a drill run
+// (演练复盘) for prototyped: it merges the step records, sim events,
+// dispatch orders, messages, department reports, zone densities, device
+// reports and opinion posts and releases of a run into one
+// chronologically ordered, typed timeline, and rebuilds the
+// command-board state of the run as it was at any given moment. The
+// package only reads: it owns no store and never writes, it reads the
+// drills, dispatch and opinion stores injected at the composition root.
+// Every object contributes the milestones its own timestamps record
+// (created, issued, handled, completed, …); the stores keep the current
+// row only, so a milestone carries the object as it is now, not as it
+// was then.
+package replay
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/dispatch"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/drills"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/opinion"
+)
+
+// ValidationError describes a timeline or replay request that violates
+// the replay rules (an unknown source, an inverted time window). It maps
+// to HTTP 400 in the routing layer.
+type ValidationError struct{ Message string }
+
+func (e *ValidationError) Error() string { return e.Message }
+
+// Source names the object kind a timeline entry comes from. The names
+// follow the kinds of the run stream, plus run and opinion_release,
+// which the stream does not carry.
+type Source string
+
+const (
+	SourceRun            Source = "run"
+	SourceStepRecord     Source = "step_record"
+	SourceSimEvent       Source = "sim_event"
+	SourceOrder          Source = "order"
+	SourceMessage        Source = "message"
+	SourceDepartment     Source = "department"
+	SourceZoneDensity    Source = "zone_density"
+	SourceDevice         Source = "device"
+	SourceOpinionPost    Source = "opinion_post"
+	SourceOpinionRelease Source = "opinion_release"
+)
+
+// validSources lists every source in its tie-break order: entries with
+// the same instant are ordered by their source in this order.
+var validSources = []Source{
+	SourceRun,
+	SourceStepRecord,
+	SourceSimEvent,
+	SourceOrder,
+	SourceMessage,
+	SourceDepartment,
+	SourceZoneDensity,
+	SourceDevice,
+	SourceOpinionPost,
+	SourceOpinionRelease,
+}
+
+// Valid reports whether source is one of the timeline sources.
+func (source Source) Valid() bool {
+	return source.rank() >= 0
+}
+
+func (source Source) rank() int {
+	for i, candidate := range validSources {
+		if source == candidate {
+			return i
+		}
+	}
+	return -1
+}
+
+// The milestone types of the timeline entries. Which types a source
+// yields is fixed: a run is created, started and completed/terminated;
+// a step record is recorded; a sim event is triggered and handled; an
+// order is issued, transitioned, updated and completed; a message is
+// sent; a department report is reported, transitioned, arrived and
+// updated; a zone density is reported and updated; a device report is
+// reported and updated; an opinion post is posted and warned; an
+// opinion release is drafted and published. updated marks the last
+// write of an object that changed after its creation; transitioned is
+// one status change from the status history of an order or department
+// report and carries the history entry (from, to, actor, note) as data
+// instead of the row.
+const (
+	TypeCreated      = "created"
+	TypeStarted      = "started"
+	TypeCompleted    = "completed"
+	TypeTerminated   = "terminated"
+	TypeRecorded     = "recorded"
+	TypeTriggered    = "triggered"
+	TypeHandled      = "handled"
+	TypeIssued       = "issued"
+	TypeTransitioned = "transitioned"
+	TypeUpdated      = "updated"
+	TypeSent         = "sent"
+	TypeReported     = "reported"
+	TypeArrived      = "arrived"
+	TypePosted       = "posted"
+	TypeWarned       = "warned"
+	TypeDrafted      = "drafted"
+	TypePublished    = "published"
+)
+
+// Entry is one milestone of the run timeline: when it happened, the
+// source object kind, the milestone type, the id of the object and the
+// object itself (the full current row, the same JSON the object's own
+// endpoint returns). Department reports are keyed by their department,
+// like on their own routes and in the run stream.
+type Entry struct {
+	At       time.Time `json:"at"`
+	Source   Source    `json:"source"`
+	Type     string    `json:"type"`
+	ObjectID string    `json:"object_id"`
+	Data     any       `json:"data"`
+}
+
+// Filter selects timeline entries. Sources keeps the entries of the
+// listed sources (empty matches every source); From and To bound the
+// time window, both inclusive (nil leaves the side open); Limit and
+// Offset paginate the matching set (a negative limit means no limit).
+type Filter struct {
+	Sources []Source
+	From    *time.Time
+	To      *time.Time
+	Limit   int
+	Offset  int
+}
+
+// validateFilter rejects unknown sources and a window whose start lies
+// after its end.
+func validateFilter(filter Filter) error {
+	for _, source := range filter.Sources {
+		if !source.Valid() {
+			return &ValidationError{Message: fmt.Sprintf("invalid source: %q", source)}
+		}
+	}
+	if filter.From != nil && filter.To != nil && filter.From.After(*filter.To) {
+		return &ValidationError{Message: "from must not be after to"}
+	}
+	return nil
+}
+
+// matches reports whether the entry passes the source and window
+// filters.
+func (filter Filter) matches(entry Entry) bool {
+	if len(filter.Sources) > 0 {
+		found := false
+		for _, source := range filter.Sources {
+			if entry.Source == source {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if filter.From != nil && entry.At.Before(*filter.From) {
+		return false
+	}
+	if filter.To != nil && entry.At.After(*filter.To) {
+		return false
+	}
+	return true
+}
+
+// sortEntries orders the timeline chronologically; entries of the same
+// instant are ordered by source (validSources order), then by object
+// id, then by type, so the order is deterministic across reads.
+func sortEntries(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if !a.At.Equal(b.At) {
+			return a.At.Before(b.At)
+		}
+		if a.Source != b.Source {
+			return a.Source.rank() < b.Source.rank()
+		}
+		if a.ObjectID != b.ObjectID {
+			return a.ObjectID < b.ObjectID
+		}
+		return a.Type < b.Type
+	})
+}
+
+// The run data the timeline and the board are derived from, read from
+// the stores by the service.
+type runData struct {
+	run         drills.Run
+	session     *dispatch.Session
+	stepRecords []drills.StepRecord
+	simEvents   []drills.SimEvent
+	orders      []dispatch.Order
+	messages    []dispatch.Message
+	departments []dispatch.DepartmentReport
+	// The status history, oldest first, keyed by order id and by
+	// department-report id. Rows written before the history existed
+	// have none.
+	orderHistory      map[string][]dispatch.OrderTransition
+	departmentHistory map[string][]dispatch.DepartmentTransition
+	zones             []dispatch.ZoneDensity
+	devices           []dispatch.Device
+	posts             []opinion.Post
+	releases          []opinion.Release
+}
+
+// orElse returns *value when set and fallback otherwise: the business
+// time of an object (issued_at, sent_at, …) when the client supplied
+// it, its created_at otherwise.
+func orElse(value *time.Time, fallback time.Time) time.Time {
+	if value != nil {
+		return *value
+	}
+	return fallback
+}
+
+// changedAfter reports whether the object was written again after its
+// creation.
+func changedAfter(createdAt, updatedAt time.Time) bool {
+	return updatedAt.After(createdAt)
+}
+
+// buildEntries derives every milestone of the run data, unsorted.
+func buildEntries(data runData) []Entry {
+	var entries []Entry
+	add := func(at time.Time, source Source, entryType, objectID string, object any) {
+		entries = append(entries, Entry{At: at, Source: source, Type: entryType, ObjectID: objectID, Data: object})
+	}
+	run := data.run
+	add(run.CreatedAt, SourceRun, TypeCreated, run.ID, run)
+	if run.StartedAt != nil {
+		add(*run.StartedAt, SourceRun, TypeStarted, run.ID, run)
+	}
+	if run.CompletedAt != nil {
+		entryType := TypeCompleted
+		if run.Status == drills.RunStatusTerminated {
+			entryType = TypeTerminated
+		}
+		add(*run.CompletedAt, SourceRun, entryType, run.ID, run)
+	}
+	for _, record := range data.stepRecords {
+		add(orElse(record.PerformedAt, record.UpdatedAt), SourceStepRecord, TypeRecorded, record.StepID, record)
+	}
+	for _, event := range data.simEvents {
+		add(orElse(event.TriggeredAt, event.CreatedAt), SourceSimEvent, TypeTriggered, event.ID, event)
+		if event.HandledAt != nil {
+			add(*event.HandledAt, SourceSimEvent, TypeHandled, event.ID, event)
+		}
+	}
+	for _, order := range data.orders {
+		add(orElse(order.IssuedAt, order.CreatedAt), SourceOrder, TypeIssued, order.ID, order)
+		if order.CompletedAt != nil {
+			add(*order.CompletedAt, SourceOrder, TypeCompleted, order.ID, order)
+		}
+		if changedAfter(order.CreatedAt, order.UpdatedAt) && (order.CompletedAt == nil || !order.CompletedAt.Equal(order.UpdatedAt)) {
+			add(order.UpdatedAt, SourceOrder, TypeUpdated, order.ID, order)
+		}
+		for _, transition := range data.orderHistory[order.ID] {
+			if transition.From != "" {
+				add(transition.CreatedAt, SourceOrder, TypeTransitioned, order.ID, transition)
+			}
+		}
+	}
+	for _, message := range data.messages {
+		add(orElse(message.SentAt, message.CreatedAt), SourceMessage, TypeSent, message.ID, message)
+	}
+	for _, report := range data.departments {
+		add(report.CreatedAt, SourceDepartment, TypeReported, string(report.Department), report)
+		if report.ArrivedAt != nil {
+			add(*report.ArrivedAt, SourceDepartment, TypeArrived, string(report.Department), report)
+		}
+		if changedAfter(report.CreatedAt, report.UpdatedAt) {
+			add(report.UpdatedAt, SourceDepartment, TypeUpdated, string(report.Department), report)
+		}
+		for _, transition := range data.departmentHistory[report.ID] {
+			if transition.From != "" {
+				add(transition.CreatedAt, SourceDepartment, TypeTransitioned, string(report.Department), transition)
+			}
+		}
+	}
+	for _, density := range data.zones {
+		add(orElse(density.ReportedAt, density.CreatedAt), SourceZoneDensity, TypeReported, density.ID, density)
+		if changedAfter(density.CreatedAt, density.UpdatedAt) {
+			add(density.UpdatedAt, SourceZoneDensity, TypeUpdated, density.ID, density)
+		}
+	}
+	for _, device := range data.devices {
+		add(device.CreatedAt, SourceDevice, TypeReported, device.ID, device)
+		if changedAfter(device.CreatedAt, device.UpdatedAt) {
+			add(device.UpdatedAt, SourceDevice, TypeUpdated, device.ID, device)
+		}
+	}
+	for _, post := range data.posts {
+		add(post.CreatedAt, SourceOpinionPost, TypePosted, post.ID, post)
+		if post.WarnedAt != nil {
+			add(*post.WarnedAt, SourceOpinionPost, TypeWarned, post.ID, post)
+		}
+	}
+	for _, release := range data.releases {
+		add(release.CreatedAt, SourceOpinionRelease, TypeDrafted, release.ID, release)
+		if release.PublishedAt != nil {
+			add(*release.PublishedAt, SourceOpinionRelease, TypePublished, release.ID, release)
+		}
+	}
+	return entries
+}
+
+// Board is the command-board state of a run at one moment: the same
+// blocks as the command-center big screen (会话配置、区域热力、设备状态、
+// 部门联动、消息流、指令列表), holding the rows that existed at At.
+// Session is nil when the run had no configured session yet. Zones
+// holds the latest density report of every zone; the other blocks hold
+// every row in creation order.
+type Board struct {
+	RunID       string
+	At          time.Time
+	Session     *dispatch.Session
+	Zones       []dispatch.ZoneDensity
+	Devices     []dispatch.Device
+	Departments []dispatch.DepartmentReport
+	Messages    []dispatch.Message
+	Orders      []dispatch.Order
+}
+
+// buildBoard rebuilds the board of the run data at the given moment.
+// A row belongs to the board once its business time (created_at, or
+// issued_at / sent_at / reported_at when set) is not after the moment.
+// The stores keep the current row only, so a row written again after
+// the moment is rolled back: the status of an order or a department
+// report is the target of its last status-history entry not after the
+// moment (an order not 已完成 by then loses its completed_at, a report
+// its arrived_at when that is after the moment). A row without history
+// (written before the history existed) is rolled back as far as its
+// timestamps allow: an order falls back to 已完成 when its completed_at
+// is not after the moment and to 待接收 (its creation status)
+// otherwise, a department report falls back to 未响应. Every other
+// field keeps its current value.
+func buildBoard(data runData, at time.Time) Board {
+	board := Board{
+		RunID:       data.run.ID,
+		At:          at,
+		Zones:       []dispatch.ZoneDensity{},
+		Devices:     []dispatch.Device{},
+		Departments: []dispatch.DepartmentReport{},
+		Messages:    []dispatch.Message{},
+		Orders:      []dispatch.Order{},
+	}
+	if data.session != nil && !data.session.CreatedAt.After(at) {
+		session := *data.session
+		board.Session = &session
+	}
+	latest := map[string]int{}
+	for _, density := range data.zones {
+		reportedAt := orElse(density.ReportedAt, density.CreatedAt)
+		if reportedAt.After(at) {
+			continue
+		}
+		index, seen := latest[density.ZoneName]
+		if !seen {
+			latest[density.ZoneName] = len(board.Zones)
+			board.Zones = append(board.Zones, density)
+			continue
+		}
+		if !reportedAt.Before(orElse(board.Zones[index].ReportedAt, board.Zones[index].CreatedAt)) {
+			board.Zones[index] = density
+		}
+	}
+	for _, device := range data.devices {
+		if !device.CreatedAt.After(at) {
+			board.Devices = append(board.Devices, device)
+		}
+	}
+	for _, report := range data.departments {
+		if report.CreatedAt.After(at) {
+			continue
+		}
+		if report.UpdatedAt.After(at) {
+			report.Status = dispatch.DefaultDepartmentStatus
+			for _, transition := range data.departmentHistory[report.ID] {
+				if !transition.CreatedAt.After(at) {
+					report.Status = transition.To
+				}
+			}
+			if report.ArrivedAt != nil && report.ArrivedAt.After(at) {
+				report.ArrivedAt = nil
+			}
+		}
+		board.Departments = append(board.Departments, report)
+	}
+	for _, message := range data.messages {
+		if !orElse(message.SentAt, message.CreatedAt).After(at) {
+			board.Messages = append(board.Messages, message)
+		}
+	}
+	for _, order := range data.orders {
+		if orElse(order.IssuedAt, order.CreatedAt).After(at) {
+			continue
+		}
+		if order.UpdatedAt.After(at) {
+			if history := data.orderHistory[order.ID]; len(history) > 0 {
+				order.Status = dispatch.DefaultOrderStatus
+				for _, transition := range history {
+					if !transition.CreatedAt.After(at) {
+						order.Status = transition.To
+					}
+				}
+				if order.Status != dispatch.OrderStatusCompleted {
+					order.CompletedAt = nil
+				}
+			} else if order.CompletedAt != nil && !order.CompletedAt.After(at) {
+				order.Status = dispatch.OrderStatusCompleted
+			} else {
+				order.Status = dispatch.DefaultOrderStatus
+				order.CompletedAt = nil
+			}
+		}
+		board.Orders = append(board.Orders, order)
+	}
+	return board
+}
@@ -0,0 +1,341 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/dispatch"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/drills"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/opinion"
+)
+
+// start is the started_at of the fixture run.
+var start = time.Date(2026, 9, 1, 9, 0, 0, 0, time.UTC)
+
+// minute returns the instant n minutes after the start of the run.
+func minute(n float64) time.Time {
+	return start.Add(time.Duration(n * float64(time.Minute)))
+}
+
+func at(n float64) *time.Time {
+	moment := minute(n)
+	return &moment
+}
+
+// newFixture builds a replay service over in-memory stores holding one
+// started run "run-1" with one object of every source, written with
+// explicit timestamps:
+//
+//	0    run started
+//	0.5  sim event triggered (handled at 6)
+//	1    order issued (completed at 5), department 安保部 reported
+//	     (arrived at 3, last written at 4), zone A区 reported, device
+//	     reported
+//	1.5  step record performed
+//	2    message sent, opinion post (warned at 7)
+//	4    second report of zone A区
+//	8    opinion release published (drafted at 2.5)
+func newFixture(t *testing.T) *Service {
+	t.Helper()
+	ctx := context.Background()
+	drillStore := drills.NewInMemoryStore()
+	dispatchStore := dispatch.NewInMemoryStore()
+	opinionStore := opinion.NewInMemoryStore()
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+	must(drillStore.CreateRun(ctx, drills.Run{
+		ID: "run-1", ScenarioID: "scn-1", Title: "复盘演练", Status: drills.RunStatusInProgress,
+		StartedAt: at(0), Metadata: map[string]any{}, CreatedAt: minute(-10), UpdatedAt: minute(0),
+	}))
+	must(drillStore.CreateSimEvent(ctx, drills.SimEvent{
+		ID: "evt-1", RunID: "run-1", EventType: drills.SimEventFlowOverflow, Payload: map[string]any{},
+		Status: drills.SimEventHandled, TriggeredAt: at(0.5), HandledAt: at(6), CreatedAt: minute(0.5), UpdatedAt: minute(6),
+	}))
+	must(drillStore.UpsertStepRecord(ctx, drills.StepRecord{
+		ID: "rec-1", RunID: "run-1", StepID: "step-1", Status: drills.StepRecordExecuted,
+		PerformedAt: at(1.5), CreatedAt: minute(1.5), UpdatedAt: minute(1.5),
+	}))
+	must(dispatchStore.UpsertSession(ctx, dispatch.Session{
+		ID: "ses-1", RunID: "run-1", Mode: dispatch.ModeRemote, MainVenue: "主馆", JointVenues: []string{"东馆"},
+		Metadata: map[string]any{}, CreatedAt: minute(-5), UpdatedAt: minute(-5),
+	}))
+	must(dispatchStore.CreateOrder(ctx, dispatch.Order{
+		ID: "ord-1", RunID: "run-1", Title: "疏导", Content: "加强疏导", Priority: dispatch.PriorityNormal,
+		TargetType: dispatch.TargetTypeDepartment, TargetName: "安保部", Status: dispatch.OrderStatusCompleted,
+		Feedback: "已完成", IssuedAt: at(1), CompletedAt: at(5), CreatedAt: minute(1), UpdatedAt: minute(5),
+	}))
+	must(dispatchStore.UpsertDepartment(ctx, dispatch.DepartmentReport{
+		ID: "dep-1", RunID: "run-1", Department: "安保部", Status: dispatch.DepartmentStatusHandling,
+		ArrivedAt: at(3), CreatedAt: minute(1), UpdatedAt: minute(4),
+	}))
+	must(dispatchStore.CreateZoneDensity(ctx, dispatch.ZoneDensity{
+		ID: "zone-1", RunID: "run-1", ZoneName: "A区", PeopleCount: 200, ReportedAt: at(1), CreatedAt: minute(1), UpdatedAt: minute(1),
+	}))
+	must(dispatchStore.CreateZoneDensity(ctx, dispatch.ZoneDensity{
+		ID: "zone-2", RunID: "run-1", ZoneName: "A区", PeopleCount: 900, ReportedAt: at(4), CreatedAt: minute(4), UpdatedAt: minute(4),
+	}))
+	must(dispatchStore.CreateDevice(ctx, dispatch.Device{
+		ID: "dev-1", RunID: "run-1", DeviceName: "烟感", DeviceType: dispatch.DeviceTypeFire, Status: dispatch.DeviceStatusNormal,
+		CreatedAt: minute(1), UpdatedAt: minute(1),
+	}))
+	must(dispatchStore.CreateMessage(ctx, dispatch.Message{
+		ID: "msg-1", RunID: "run-1", SenderType: dispatch.SenderTypeCommand, SenderName: "总指挥", Content: "注意客流",
+		SentAt: at(2), CreatedAt: minute(2), UpdatedAt: minute(2),
+	}))
+	must(opinionStore.CreatePost(ctx, opinion.Post{
+		ID: "post-1", RunID: "run-1", Source: opinion.SourceWeibo, Content: "排队太久", Sentiment: opinion.SentimentNegative,
+		WarnStatus: opinion.WarnStatusWarned, WarnedAt: at(7), Metadata: map[string]any{}, CreatedAt: minute(2), UpdatedAt: minute(7),
+	}))
+	must(opinionStore.CreateRelease(ctx, opinion.Release{
+		ID: "rel-1", RunID: "run-1", Channel: opinion.ChannelWechat, Title: "限流公告", Content: "限流",
+		Status: opinion.ReleaseStatusPublished, PublishedAt: at(8), Metadata: map[string]any{}, CreatedAt: minute(2.5), UpdatedAt: minute(8),
+	}))
+	return NewService(drillStore, dispatchStore, opinionStore)
+}
+
+// milestone is the comparable part of an entry.
+type milestone struct {
+	Source   Source
+	Type     string
+	ObjectID string
+}
+
+func milestones(entries []Entry) []milestone {
+	result := make([]milestone, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, milestone{entry.Source, entry.Type, entry.ObjectID})
+	}
+	return result
+}
+
+// 全部来源按时间合并；同一时刻按来源顺序、对象 id 排序；每个对象只贡献
+// 自身时间戳记录的节点（订单完成与最后一次写入同刻时不重复出 updated）。
+func TestTimelineMergesEverySourceChronologically(t *testing.T) {
+	service := newFixture(t)
+
+	entries, total, err := service.Timeline(context.Background(), "run-1", Filter{Limit: -1})
+	if err != nil {
+		t.Fatalf("Timeline: %v", err)
+	}
+	want := []milestone{
+		{SourceRun, TypeCreated, "run-1"},
+		{SourceRun, TypeStarted, "run-1"},
+		{SourceSimEvent, TypeTriggered, "evt-1"},
+		{SourceOrder, TypeIssued, "ord-1"},
+		{SourceDepartment, TypeReported, "安保部"},
+		{SourceZoneDensity, TypeReported, "zone-1"},
+		{SourceDevice, TypeReported, "dev-1"},
+		{SourceStepRecord, TypeRecorded, "step-1"},
+		{SourceMessage, TypeSent, "msg-1"},
+		{SourceOpinionPost, TypePosted, "post-1"},
+		{SourceOpinionRelease, TypeDrafted, "rel-1"},
+		{SourceDepartment, TypeArrived, "安保部"},
+		{SourceDepartment, TypeUpdated, "安保部"},
+		{SourceZoneDensity, TypeReported, "zone-2"},
+		{SourceOrder, TypeCompleted, "ord-1"},
+		{SourceSimEvent, TypeHandled, "evt-1"},
+		{SourceOpinionPost, TypeWarned, "post-1"},
+		{SourceOpinionRelease, TypePublished, "rel-1"},
+	}
+	if got := milestones(entries); !reflect.DeepEqual(got, want) || total != len(want) {
+		t.Fatalf("timeline = %v (total %d), want %v", got, total, want)
+	}
+	if order, ok := entries[3].Data.(dispatch.Order); !ok || order.ID != "ord-1" {
+		t.Fatalf("order entry data = %#v, want the order row", entries[3].Data)
+	}
+}
+
+// 来源过滤、时间窗（两端闭区间）与分页；未知来源、from 晚于 to 为
+// ValidationError；run 不存在为 drills.ErrRunNotFound。
+func TestTimelineFilters(t *testing.T) {
+	service := newFixture(t)
+	ctx := context.Background()
+
+	entries, total, err := service.Timeline(ctx, "run-1", Filter{
+		Sources: []Source{SourceOrder, SourceDepartment},
+		From:    at(1),
+		To:      at(4),
+		Limit:   -1,
+	})
+	if err != nil {
+		t.Fatalf("Timeline: %v", err)
+	}
+	want := []milestone{
+		{SourceOrder, TypeIssued, "ord-1"},
+		{SourceDepartment, TypeReported, "安保部"},
+		{SourceDepartment, TypeArrived, "安保部"},
+		{SourceDepartment, TypeUpdated, "安保部"},
+	}
+	if got := milestones(entries); !reflect.DeepEqual(got, want) || total != 4 {
+		t.Fatalf("filtered = %v (total %d), want %v", got, total, want)
+	}
+
+	page, total, err := service.Timeline(ctx, "run-1", Filter{Limit: 2, Offset: 1})
+	if err != nil || total != 18 || len(page) != 2 || page[0].Type != TypeStarted {
+		t.Fatalf("page = %v, total %d, err %v; want 2 entries from started of 18", milestones(page), total, err)
+	}
+
+	var validationError *ValidationError
+	if _, _, err := service.Timeline(ctx, "run-1", Filter{Sources: []Source{"assessment"}}); !errors.As(err, &validationError) {
+		t.Fatalf("unknown source: err = %v, want ValidationError", err)
+	}
+	if _, _, err := service.Timeline(ctx, "run-1", Filter{From: at(5), To: at(1)}); !errors.As(err, &validationError) {
+		t.Fatalf("inverted window: err = %v, want ValidationError", err)
+	}
+	if _, _, err := service.Timeline(ctx, "run-missing", Filter{}); !errors.Is(err, drills.ErrRunNotFound) {
+		t.Fatalf("missing run: err = %v, want ErrRunNotFound", err)
+	}
+}
+
+// 复盘看板：只含当时已存在的行；之后被改写的订单/部门回退到时间戳
+// 可推出的状态；同一区域取当时最新的一条；nil 表示当前。
+func TestBoardRebuildsTheMoment(t *testing.T) {
+	service := newFixture(t)
+	ctx := context.Background()
+
+	board, err := service.Board(ctx, "run-1", at(3))
+	if err != nil {
+		t.Fatalf("Board: %v", err)
+	}
+	if board.RunID != "run-1" || !board.At.Equal(minute(3)) || board.Session == nil || board.Session.MainVenue != "主馆" {
+		t.Fatalf("board header = %+v, want run-1 at minute 3 with the session", board)
+	}
+	if len(board.Orders) != 1 || board.Orders[0].Status != dispatch.OrderStatusPending || board.Orders[0].CompletedAt != nil {
+		t.Fatalf("orders = %+v, want the order rolled back to 待接收", board.Orders)
+	}
+	if len(board.Departments) != 1 || board.Departments[0].Status != dispatch.DepartmentStatusNotResponded || board.Departments[0].ArrivedAt == nil {
+		t.Fatalf("departments = %+v, want 未响应 with arrived_at kept", board.Departments)
+	}
+	if len(board.Zones) != 1 || board.Zones[0].PeopleCount != 200 {
+		t.Fatalf("zones = %+v, want the first report of A区", board.Zones)
+	}
+	if len(board.Messages) != 1 || len(board.Devices) != 1 {
+		t.Fatalf("messages = %d, devices = %d, want 1 each", len(board.Messages), len(board.Devices))
+	}
+
+	board, err = service.Board(ctx, "run-1", at(0.5))
+	if err != nil || len(board.Orders) != 0 || len(board.Messages) != 0 || len(board.Zones) != 0 || board.Session == nil {
+		t.Fatalf("board at 0.5 = %+v (%v), want the session only", board, err)
+	}
+
+	board, err = service.Board(ctx, "run-1", nil)
+	if err != nil {
+		t.Fatalf("Board(now): %v", err)
+	}
+	if board.Orders[0].Status != dispatch.OrderStatusCompleted || board.Departments[0].Status != dispatch.DepartmentStatusHandling || board.Zones[0].PeopleCount != 900 {
+		t.Fatalf("current board = %+v, want the current rows", board)
+	}
+	if _, err := service.Board(ctx, "run-missing", nil); !errors.Is(err, drills.ErrRunNotFound) {
+		t.Fatalf("missing run: err = %v, want ErrRunNotFound", err)
+	}
+}
+
+// 有状态流转记录时：时间线为每次状态变化补一条 transitioned（data 为
+// 流转记录，创建记录不重复出现）；看板按当时最后一条流转记录还原
+// 中间状态（已接收、执行中、已响应），不再只能回退到初始状态。
+func TestHistoryRestoresIntermediateStatuses(t *testing.T) {
+	ctx := context.Background()
+	drillStore := drills.NewInMemoryStore()
+	dispatchStore := dispatch.NewInMemoryStore()
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+	must(drillStore.CreateRun(ctx, drills.Run{
+		ID: "run-1", ScenarioID: "scn-1", Title: "复盘演练", Status: drills.RunStatusInProgress,
+		StartedAt: at(0), Metadata: map[string]any{}, CreatedAt: minute(0), UpdatedAt: minute(0),
+	}))
+	order := dispatch.Order{
+		ID: "ord-1", RunID: "run-1", Title: "疏导", Content: "加强疏导", Priority: dispatch.PriorityNormal,
+		TargetType: dispatch.TargetTypeDepartment, TargetName: "公安", Status: dispatch.OrderStatusCompleted,
+		IssuedAt: at(1), CompletedAt: at(5), CreatedAt: minute(1), UpdatedAt: minute(5),
+	}
+	for i, step := range []struct {
+		from, to dispatch.OrderStatus
+		at       float64
+	}{
+		{"", dispatch.OrderStatusPending, 1},
+		{dispatch.OrderStatusPending, dispatch.OrderStatusReceived, 2},
+		{dispatch.OrderStatusReceived, dispatch.OrderStatusExecuting, 3},
+		{dispatch.OrderStatusExecuting, dispatch.OrderStatusCompleted, 5},
+	} {
+		order.Status, order.UpdatedAt = step.to, minute(step.at)
+		must(dispatchStore.TransitionOrder(ctx, order, dispatch.OrderTransition{
+			ID: "otr-" + string(rune('1'+i)), RunID: "run-1", OrderID: "ord-1", From: step.from, To: step.to, CreatedAt: minute(step.at),
+		}))
+	}
+	report := dispatch.DepartmentReport{
+		ID: "dep-1", RunID: "run-1", Department: dispatch.DepartmentPolice, Status: dispatch.DepartmentStatusArrived,
+		ArrivedAt: at(3), CreatedAt: minute(1), UpdatedAt: minute(3),
+	}
+	for i, step := range []struct {
+		from, to dispatch.DepartmentStatus
+		at       float64
+	}{
+		{"", dispatch.DepartmentStatusNotResponded, 1},
+		{dispatch.DepartmentStatusNotResponded, dispatch.DepartmentStatusResponded, 2},
+		{dispatch.DepartmentStatusResponded, dispatch.DepartmentStatusArrived, 3},
+	} {
+		report.Status, report.UpdatedAt = step.to, minute(step.at)
+		must(dispatchStore.TransitionDepartment(ctx, report, dispatch.DepartmentTransition{
+			ID: "dtr-" + string(rune('1'+i)), RunID: "run-1", ReportID: "dep-1", Department: dispatch.DepartmentPolice,
+			From: step.from, To: step.to, CreatedAt: minute(step.at),
+		}))
+	}
+	service := NewService(drillStore, dispatchStore, opinion.NewInMemoryStore())
+
+	entries, _, err := service.Timeline(ctx, "run-1", Filter{Sources: []Source{SourceOrder, SourceDepartment}, Limit: -1})
+	if err != nil {
+		t.Fatalf("Timeline: %v", err)
+	}
+	want := []milestone{
+		{SourceOrder, TypeIssued, "ord-1"},
+		{SourceDepartment, TypeReported, "公安"},
+		{SourceOrder, TypeTransitioned, "ord-1"},
+		{SourceDepartment, TypeTransitioned, "公安"},
+		{SourceOrder, TypeTransitioned, "ord-1"},
+		{SourceDepartment, TypeArrived, "公安"},
+		{SourceDepartment, TypeTransitioned, "公安"},
+		{SourceDepartment, TypeUpdated, "公安"},
+		{SourceOrder, TypeCompleted, "ord-1"},
+		{SourceOrder, TypeTransitioned, "ord-1"},
+	}
+	if got := milestones(entries); !reflect.DeepEqual(got, want) {
+		t.Fatalf("timeline = %v, want %v", got, want)
+	}
+	if transition, ok := entries[2].Data.(dispatch.OrderTransition); !ok || transition.To != dispatch.OrderStatusReceived {
+		t.Fatalf("transition entry data = %#v, want the 已接收 history entry", entries[2].Data)
+	}
+
+	for _, tc := range []struct {
+		at         float64
+		order      dispatch.OrderStatus
+		department dispatch.DepartmentStatus
+		arrived    bool
+	}{
+		{1.5, dispatch.OrderStatusPending, dispatch.DepartmentStatusNotResponded, false},
+		{2.5, dispatch.OrderStatusReceived, dispatch.DepartmentStatusResponded, false},
+		{4, dispatch.OrderStatusExecuting, dispatch.DepartmentStatusArrived, true},
+	} {
+		board, err := service.Board(ctx, "run-1", at(tc.at))
+		if err != nil {
+			t.Fatalf("Board(%v): %v", tc.at, err)
+		}
+		order, report := board.Orders[0], board.Departments[0]
+		if order.Status != tc.order || order.CompletedAt != nil {
+			t.Errorf("order at %v = %s (completed_at %v), want %s", tc.at, order.Status, order.CompletedAt, tc.order)
+		}
+		if report.Status != tc.department || (report.ArrivedAt != nil) != tc.arrived {
+			t.Errorf("department at %v = %s (arrived_at %v), want %s", tc.at, report.Status, report.ArrivedAt, tc.department)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/dispatch"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/drills"
+	"github.com/ovaphlow/pitchfork/service-prototype/internal/opinion"
+)
+
+// Service reads the run data of the drills, dispatch and opinion stores
+// and derives the timeline and the board from it. It holds no state of
+// its own: every call reads the stores afresh, so the timeline of a run
+// still in progress grows with the run.
+type Service struct {
+	drills   drills.Store
+	dispatch dispatch.Store
+	opinion  opinion.Store
+	now      func() time.Time
+}
+
+// NewService builds a replay service over the given stores.
+func NewService(drillStore drills.Store, dispatchStore dispatch.Store, opinionStore opinion.Store) *Service {
+	return &Service{drills: drillStore, dispatch: dispatchStore, opinion: opinionStore, now: time.Now}
+}
+
+// Timeline returns the milestones of the run matching the filter in
+// chronological order, and the total number of matches. A missing run
+// is drills.ErrRunNotFound (404); an unknown source or a window whose
+// from lies after its to is a ValidationError (400).
+func (s *Service) Timeline(ctx context.Context, runID string, filter Filter) ([]Entry, int, error) {
+	if err := validateFilter(filter); err != nil {
+		return nil, 0, err
+	}
+	data, err := s.load(ctx, runID)
+	if err != nil {
+		return nil, 0, err
+	}
+	entries := buildEntries(data)
+	sortEntries(entries)
+	matched := []Entry{}
+	for _, entry := range entries {
+		if filter.matches(entry) {
+			matched = append(matched, entry)
+		}
+	}
+	total := len(matched)
+	start := filter.Offset
+	if start > total {
+		start = total
+	}
+	end := start + filter.Limit
+	if filter.Limit < 0 || end > total {
+		end = total
+	}
+	return matched[start:end], total, nil
+}
+
+// Board rebuilds the command-board state of the run at the given moment
+// (see buildBoard for what the stores allow to roll back). A nil moment
+// means now, which yields the current board. A missing run is
+// drills.ErrRunNotFound (404).
+func (s *Service) Board(ctx context.Context, runID string, at *time.Time) (Board, error) {
+	data, err := s.load(ctx, runID)
+	if err != nil {
+		return Board{}, err
+	}
+	moment := s.now()
+	if at != nil {
+		moment = *at
+	}
+	return buildBoard(data, moment), nil
+}
+
+// load reads every object of the run from the stores. The run is read
+// first, so a missing run answers ErrRunNotFound before any listing.
+func (s *Service) load(ctx context.Context, runID string) (runData, error) {
+	run, err := s.drills.GetRun(ctx, runID)
+	if err != nil {
+		return runData{}, err
+	}
+	data := runData{run: run}
+	session, err := s.dispatch.GetSession(ctx, runID)
+	switch {
+	case err == nil:
+		data.session = &session
+	case !errors.Is(err, dispatch.ErrSessionNotFound):
+		return runData{}, err
+	}
+	if data.stepRecords, err = s.drills.ListStepRecordsByRun(ctx, runID); err != nil {
+		return runData{}, err
+	}
+	if data.simEvents, _, err = s.drills.ListSimEvents(ctx, runID, drills.SimEventFilter{Limit: -1}); err != nil {
+		return runData{}, err
+	}
+	if data.orders, _, err = s.dispatch.ListOrders(ctx, runID, dispatch.OrderFilter{Limit: -1}); err != nil {
+		return runData{}, err
+	}
+	if data.messages, _, err = s.dispatch.ListMessages(ctx, runID, dispatch.MessageFilter{Limit: -1}); err != nil {
+		return runData{}, err
+	}
+	if data.departments, _, err = s.dispatch.ListDepartments(ctx, runID, dispatch.DepartmentFilter{Limit: -1}); err != nil {
+		return runData{}, err
+	}
+	orderHistory, err := s.dispatch.ListOrderTransitionsByRun(ctx, runID)
+	if err != nil {
+		return runData{}, err
+	}
+	data.orderHistory = map[string][]dispatch.OrderTransition{}
+	for _, transition := range orderHistory {
+		data.orderHistory[transition.OrderID] = append(data.orderHistory[transition.OrderID], transition)
+	}
+	departmentHistory, err := s.dispatch.ListDepartmentTransitionsByRun(ctx, runID)
+	if err != nil {
+		return runData{}, err
+	}
+	data.departmentHistory = map[string][]dispatch.DepartmentTransition{}
+	for _, transition := range departmentHistory {
+		data.departmentHistory[transition.ReportID] = append(data.departmentHistory[transition.ReportID], transition)
+	}
+	if data.zones, _, err = s.dispatch.ListZoneDensities(ctx, runID, dispatch.ZoneDensityFilter{Limit: -1}); err != nil {
+		return runData{}, err
+	}
+	if data.devices, _, err = s.dispatch.ListDevices(ctx, runID, dispatch.DeviceFilter{Limit: -1}); err != nil {
+		return runData{}, err
+	}
+	if data.posts, _, err = s.opinion.ListPosts(ctx, runID, opinion.PostFilter{Limit: -1}); err != nil {
+		return runData{}, err
+	}
+	if data.releases, _, err = s.opinion.ListReleases(ctx, runID, opinion.ReleaseFilter{Limit: -1}); err != nil {
+		return runData{}, err
+	}
+	return data, nil
+}
@@ -0,0 +1,251 @@
+// Package runstream fans the committed writes of a drill run out to the
+// terminals watching it. The dispatch, drills and opinion services
+// publish one typed Event per create, update and delete through the
+// Publisher hook; the SSE endpoint of the routing layer subscribes per
+// run. Every run keeps a bounded backlog of its latest events, so a
+// client reconnecting with Last-Event-ID replays what it missed instead
+// of polling. The broker is process-local and in-memory: it never
+// touches a database, and a restart loses the backlog. The backlog of a
+// run nobody watches is dropped once the run has been idle for a while,
+// so the finished runs of a long-lived process do not pile up. The event
+// sequence of a process starts at its start time in microseconds, so
+// the IDs of a restarted process are above every ID a client saw
+// before and a Last-Event-ID from an earlier process is answered with a
+// reset instead of a silent gap.
+package runstream
+
+import (
+	"sync"
+	"time"
+)
+
+// Kind names the object a change event is about.
+type Kind string
+
+const (
+	KindRun         Kind = "run"
+	KindOrder       Kind = "order"
+	KindMessage     Kind = "message"
+	KindZoneDensity Kind = "zone_density"
+	KindDevice      Kind = "device"
+	KindDepartment  Kind = "department"
+	KindSimEvent    Kind = "sim_event"
+	KindStepRecord  Kind = "step_record"
+	KindOpinionPost Kind = "opinion_post"
+	KindInject      Kind = "inject"
+	// KindStream marks events about the stream itself (ActionReset).
+	KindStream Kind = "stream"
+)
+
+const (
+	// defaultBacklog is the number of events retained per run when
+	// NewBroker is given no positive backlog.
+	defaultBacklog = 256
+	// subscriberBuffer is the number of undelivered events a
+	// subscription may queue before it counts as a stalled consumer.
+	subscriberBuffer = 64
+	// idleTTL is how long the backlog of a run without subscriptions is
+	// kept after its last event or its last subscription closed.
+	idleTTL = 30 * time.Minute
+	// sweepInterval spaces the scans for idle runs, which piggyback on
+	// Publish and Subscribe.
+	sweepInterval = time.Minute
+)
+
+// Action names what happened to the object.
+type Action string
+
+const (
+	ActionCreated Action = "created"
+	ActionUpdated Action = "updated"
+	ActionDeleted Action = "deleted"
+	// ActionReset tells a resuming client that the events it missed are
+	// no longer in the backlog, so it must reload the full run state.
+	ActionReset Action = "reset"
+)
+
+// Event is one change of one run. ID is a process-wide, strictly
+// increasing sequence number (the SSE id). Data carries the object after
+// the change (nil for a delete, whose ObjectID still names the object).
+type Event struct {
+	ID       uint64    `json:"-"`
+	RunID    string    `json:"run_id"`
+	Kind     Kind      `json:"kind"`
+	Action   Action    `json:"action"`
+	ObjectID string    `json:"object_id"`
+	Data     any       `json:"data,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// Type is the SSE event name of the event, "<kind>.<action>" (e.g.
+// order.updated), so a client can listen to exactly the changes it
+// renders.
+func (e Event) Type() string {
+	return string(e.Kind) + "." + string(e.Action)
+}
+
+// Publisher receives the committed writes of the services. Publishing
+// must never fail or block the write that triggered it.
+type Publisher interface {
+	Publish(runID string, kind Kind, action Action, objectID string, data any)
+}
+
+// Broker is the in-memory Publisher: it keeps the backlog of every run
+// and delivers new events to the live subscriptions of the run. All
+// methods are safe for concurrent use.
+type Broker struct {
+	backlog int
+	now     func() time.Time
+
+	mu     sync.Mutex
+	origin uint64 // the sequence value before the first event of this process
+	seq    uint64
+	runs   map[string]*runLog
+	swept  time.Time // the last scan for idle runs
+}
+
+// runLog is the retained backlog and the live subscriptions of one run.
+// trimmed is the ID of the newest event the backlog no longer holds
+// (dropped from it, or published before the log was created, e.g. before
+// an idle log was evicted): a client resuming from an older ID has missed
+// events that can no longer be replayed. active is the time of the last
+// event or of the last subscription closing.
+type runLog struct {
+	events      []Event
+	trimmed     uint64
+	subscribers map[*Subscription]struct{}
+	active      time.Time
+}
+
+// NewBroker returns a broker retaining the latest backlog events per
+// run for Last-Event-ID resumption; a non-positive backlog uses the
+// default of 256.
+func NewBroker(backlog int) *Broker {
+	if backlog <= 0 {
+		backlog = defaultBacklog
+	}
+	origin := uint64(time.Now().UnixMicro())
+	return &Broker{backlog: backlog, now: time.Now, origin: origin, seq: origin, runs: map[string]*runLog{}}
+}
+
+// Publish records the event in the run backlog and hands it to every
+// live subscription of the run. A subscription whose buffer is full is a
+// consumer that stopped reading: it is closed rather than allowed to
+// block the publishing write, and its client resumes through
+// Last-Event-ID on reconnect. The deletion of the run itself (KindRun,
+// ActionDeleted) is delivered and then ends the stream: the
+// subscriptions are closed and the backlog is dropped.
+func (b *Broker) Publish(runID string, kind Kind, action Action, objectID string, data any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sweep()
+	log := b.run(runID)
+	b.seq++
+	event := Event{ID: b.seq, RunID: runID, Kind: kind, Action: action, ObjectID: objectID, Data: data, At: b.now()}
+	log.active = event.At
+	log.events = append(log.events, event)
+	if len(log.events) > b.backlog {
+		drop := len(log.events) - b.backlog
+		log.trimmed = log.events[drop-1].ID
+		log.events = append([]Event(nil), log.events[drop:]...)
+	}
+	for subscription := range log.subscribers {
+		select {
+		case subscription.events <- event:
+		default:
+			delete(log.subscribers, subscription)
+			close(subscription.events)
+		}
+	}
+	if kind == KindRun && action == ActionDeleted {
+		for subscription := range log.subscribers {
+			close(subscription.events)
+		}
+		delete(b.runs, runID)
+	}
+}
+
+// Subscribe opens a live subscription to the run. lastEventID is the
+// Last-Event-ID the client presented (0 for a fresh connection): the
+// retained events after it are returned for replay, oldest first. When
+// the client is further behind than the backlog reaches, or its ID was
+// issued by another process, the replay is a single reset event instead,
+// asking it to reload the run state. The caller must Close the
+// subscription when the client goes away.
+func (b *Broker) Subscribe(runID string, lastEventID uint64) (*Subscription, []Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sweep()
+	log := b.run(runID)
+	subscription := &Subscription{broker: b, runID: runID, events: make(chan Event, subscriberBuffer)}
+	log.subscribers[subscription] = struct{}{}
+	if lastEventID == 0 {
+		return subscription, nil
+	}
+	if lastEventID < b.origin || lastEventID > b.seq || lastEventID < log.trimmed {
+		return subscription, []Event{{ID: b.seq, RunID: runID, Kind: KindStream, Action: ActionReset, At: b.now()}}
+	}
+	var replay []Event
+	for _, event := range log.events {
+		if event.ID > lastEventID {
+			replay = append(replay, event)
+		}
+	}
+	return subscription, replay
+}
+
+// run returns the log of the run, creating it on first use. A new log
+// holds none of the events published so far. The caller holds b.mu.
+func (b *Broker) run(runID string) *runLog {
+	log, ok := b.runs[runID]
+	if !ok {
+		log = &runLog{trimmed: b.seq, subscribers: map[*Subscription]struct{}{}, active: b.now()}
+		b.runs[runID] = log
+	}
+	return log
+}
+
+// sweep drops the logs of the runs that have had no subscription and no
+// event for idleTTL, at most once per sweepInterval. A client resuming
+// such a run later is answered with a reset. The caller holds b.mu.
+func (b *Broker) sweep() {
+	now := b.now()
+	if now.Sub(b.swept) < sweepInterval {
+		return
+	}
+	b.swept = now
+	for runID, log := range b.runs {
+		if len(log.subscribers) == 0 && now.Sub(log.active) >= idleTTL {
+			delete(b.runs, runID)
+		}
+	}
+}
+
+// Subscription is one live client of a run stream.
+type Subscription struct {
+	broker *Broker
+	runID  string
+	events chan Event
+}
+
+// Events delivers the new events of the run. The channel is closed when
+// the subscription is dropped (stalled consumer, run deleted) or closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close ends the subscription. Closing twice, or after the broker
+// dropped it, is a no-op.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	log, ok := s.broker.runs[s.runID]
+	if !ok {
+		return
+	}
+	if _, live := log.subscribers[s]; live {
+		delete(log.subscribers, s)
+		close(s.events)
+		log.active = s.broker.now()
+	}
+}
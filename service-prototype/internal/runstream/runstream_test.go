@@ -0,0 +1,176 @@
+package runstream
+
+import (
+	"testing"
+	"time"
+)
+
+// receive reads the next event of the subscription or fails after a
+// second.
+func receive(t *testing.T, subscription *Subscription) Event {
+	t.Helper()
+	select {
+	case event, open := <-subscription.Events():
+		if !open {
+			t.Fatal("subscription closed, want an event")
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no event delivered")
+	}
+	return Event{}
+}
+
+func TestPublishDeliversToSubscribersOfTheRun(t *testing.T) {
+	broker := NewBroker(0)
+	first, replay := broker.Subscribe("run-1", 0)
+	defer first.Close()
+	if len(replay) != 0 {
+		t.Fatalf("fresh subscription replayed %d events, want none", len(replay))
+	}
+	second, _ := broker.Subscribe("run-1", 0)
+	defer second.Close()
+	other, _ := broker.Subscribe("run-2", 0)
+	defer other.Close()
+
+	broker.Publish("run-1", KindOrder, ActionCreated, "order-1", map[string]string{"title": "疏散"})
+	for _, subscription := range []*Subscription{first, second} {
+		event := receive(t, subscription)
+		if event.Type() != "order.created" || event.RunID != "run-1" || event.ObjectID != "order-1" {
+			t.Fatalf("event = %+v, want order.created of order-1", event)
+		}
+	}
+	select {
+	case event := <-other.Events():
+		t.Fatalf("subscriber of another run received %+v", event)
+	default:
+	}
+}
+
+func TestSubscribeReplaysEventsAfterLastEventID(t *testing.T) {
+	broker := NewBroker(0)
+	broker.Publish("run-1", KindMessage, ActionCreated, "m1", nil)
+	broker.Publish("run-1", KindMessage, ActionCreated, "m2", nil)
+	broker.Publish("run-1", KindMessage, ActionDeleted, "m1", nil)
+	seen, _ := broker.Subscribe("run-1", 0)
+	seen.Close()
+
+	subscription, replay := broker.Subscribe("run-1", broker.origin+1)
+	defer subscription.Close()
+	if len(replay) != 2 || replay[0].ObjectID != "m2" || replay[1].Type() != "message.deleted" {
+		t.Fatalf("replay = %+v, want the two events after the first", replay)
+	}
+	if replay[0].ID >= replay[1].ID {
+		t.Fatalf("replay is not in sequence order: %d then %d", replay[0].ID, replay[1].ID)
+	}
+}
+
+func TestSubscribeResetsWhenTheBacklogNoLongerReaches(t *testing.T) {
+	broker := NewBroker(2)
+	for _, id := range []string{"d1", "d2", "d3", "d4"} {
+		broker.Publish("run-1", KindDevice, ActionCreated, id, nil)
+	}
+	subscription, replay := broker.Subscribe("run-1", broker.origin+1)
+	defer subscription.Close()
+	if len(replay) != 1 || replay[0].Type() != "stream.reset" {
+		t.Fatalf("replay = %+v, want a single stream.reset", replay)
+	}
+
+	resumed, replay := broker.Subscribe("run-1", broker.origin+2)
+	defer resumed.Close()
+	if len(replay) != 2 || replay[0].ObjectID != "d3" || replay[1].ObjectID != "d4" {
+		t.Fatalf("replay = %+v, want the two retained events", replay)
+	}
+}
+
+func TestSubscribeResetsForAnIDOfAnotherProcess(t *testing.T) {
+	broker := NewBroker(0)
+	broker.Publish("run-1", KindZoneDensity, ActionCreated, "z1", nil)
+	for _, lastEventID := range []uint64{broker.origin - 5, broker.seq + 10} {
+		subscription, replay := broker.Subscribe("run-1", lastEventID)
+		subscription.Close()
+		if len(replay) != 1 || replay[0].Type() != "stream.reset" {
+			t.Fatalf("Last-Event-ID %d: replay = %+v, want a single stream.reset", lastEventID, replay)
+		}
+	}
+}
+
+func TestStalledSubscriberIsDroppedWithoutBlockingPublish(t *testing.T) {
+	broker := NewBroker(0)
+	stalled, _ := broker.Subscribe("run-1", 0)
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < subscriberBuffer+1; i++ {
+			broker.Publish("run-1", KindSimEvent, ActionCreated, "e", nil)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a subscriber that stopped reading")
+	}
+	received := 0
+	for range stalled.Events() {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Fatalf("received %d events before the drop, want %d", received, subscriberBuffer)
+	}
+	stalled.Close()
+}
+
+func TestRunDeletionEndsTheStream(t *testing.T) {
+	broker := NewBroker(0)
+	subscription, _ := broker.Subscribe("run-1", 0)
+	broker.Publish("run-1", KindOrder, ActionCreated, "o1", nil)
+	broker.Publish("run-1", KindRun, ActionDeleted, "run-1", nil)
+	if event := receive(t, subscription); event.Type() != "order.created" {
+		t.Fatalf("first event = %s, want order.created", event.Type())
+	}
+	if event := receive(t, subscription); event.Type() != "run.deleted" {
+		t.Fatalf("second event = %s, want run.deleted", event.Type())
+	}
+	if _, open := <-subscription.Events(); open {
+		t.Fatal("subscription still open after the run was deleted")
+	}
+	subscription.Close()
+	if _, exists := broker.runs["run-1"]; exists {
+		t.Fatal("the backlog of the deleted run was kept")
+	}
+}
+
+func TestIdleRunsAreEvictedAndResumeWithAReset(t *testing.T) {
+	broker := NewBroker(0)
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	broker.now = func() time.Time { return now }
+
+	broker.Publish("run-idle", KindOrder, ActionCreated, "o1", nil)
+	lastSeen := broker.seq
+	watched, _ := broker.Subscribe("run-watched", 0)
+	defer watched.Close()
+	broker.Publish("run-watched", KindOrder, ActionCreated, "o2", nil)
+
+	// A run is kept until it has been idle for the whole TTL.
+	now = now.Add(idleTTL - time.Second)
+	broker.Publish("run-other", KindOrder, ActionCreated, "o3", nil)
+	if _, exists := broker.runs["run-idle"]; !exists {
+		t.Fatal("the backlog was dropped before the idle TTL")
+	}
+	now = now.Add(sweepInterval)
+	broker.Publish("run-other", KindOrder, ActionCreated, "o4", nil)
+	if _, exists := broker.runs["run-idle"]; exists {
+		t.Fatal("the backlog of the idle run was kept")
+	}
+	if _, exists := broker.runs["run-watched"]; !exists {
+		t.Fatal("the backlog of a watched run was dropped")
+	}
+
+	// A client of the evicted run that missed its last event cannot be
+	// replayed, so it reloads instead of resuming with a silent gap.
+	subscription, replay := broker.Subscribe("run-idle", lastSeen-1)
+	subscription.Close()
+	if len(replay) != 1 || replay[0].Type() != "stream.reset" {
+		t.Fatalf("replay after eviction = %+v, want a single stream.reset", replay)
+	}
+}
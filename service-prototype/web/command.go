@@ -6,33 +6,44 @@ import (
 )
 
 // CommandPageData carries the display data of the command-center big
-// screen page (指挥中心大屏). The page renders purely from this in-memory
-// payload — no database access, no API call — so the caller builds it
-// from the built-in demo data and mints the server-side run id. The
-// page is a read-only monitoring view: the six blocks (会话配置、区域
-// 热力、设备状态、部门联动、消息流、指令列表) only display data, and the
-// hx-get refresh actions of the message-flow, zone-heat and device
-// blocks anchor on the run id carried by the data.
+// screen page (指挥中心大屏). The page renders purely from this payload —
+// no database access, no API call from the template — so the caller
+// loads the selected drill run and its dispatch rows and passes them
+// in. The page is a read-only monitoring view: the run picker (演练选
+// 择) selects the run, and the six blocks (会话配置、区域热力、设备状态、
+// 部门联动、消息流、指令列表) only display data. The page subscribes to
+// the SSE stream of the selected run, and the zone-heat, device,
+// department, message-flow and order blocks re-fetch themselves when
+// the stream reports a change of their kind. Without a selected run
+// only the picker and the notice render. The JSON tags give the replay
+// endpoint of the API the same shape: it answers the board of a run at
+// a past moment as this payload (the picker fields are page-only and
+// never part of it).
 type CommandPageData struct {
-	// RunID is the 26-character server-minted ULID of the demo drill
-	// run. It anchors the hx-get refresh endpoints; the page itself
-	// never constructs ids.
-	RunID       string
-	Session     SessionView
-	Zones       []ZoneView
-	Devices     []DeviceView
-	Departments []DepartmentView
-	Messages    []MessageView
-	Orders      []OrderView
+	// Runs are the options of the run picker.
+	Runs []RunOptionView `json:"-"`
+	// Notice is the page hint: "" while a run renders, 请选择演练
+	// without a selection, 演练不存在 for an unknown run.
+	Notice string `json:"-"`
+	// RunID is the 26-character ULID of the selected drill run ("" when
+	// none is selected). It anchors the run stream subscription and the
+	// block refreshes; the page itself never constructs ids.
+	RunID       string           `json:"run_id"`
+	Session     SessionView      `json:"session"`
+	Zones       []ZoneView       `json:"zones"`
+	Devices     []DeviceView     `json:"devices"`
+	Departments []DepartmentView `json:"departments"`
+	Messages    []MessageView    `json:"messages"`
+	Orders      []OrderView      `json:"orders"`
 }
 
 // SessionView is the dispatch command session configuration (指挥调度
 // 会话配置) of the run: the training mode (实训方式), the main venue
 // (主场馆) and the joint venues (联训场馆).
 type SessionView struct {
-	Mode        string
-	MainVenue   string
-	JointVenues []string
+	Mode        string   `json:"mode"`
+	MainVenue   string   `json:"main_venue"`
+	JointVenues []string `json:"joint_venues"`
 }
 
 // ZoneView is one zone crowd-density report (区域人流热力上报) of the
@@ -41,8 +52,8 @@ type SessionView struct {
 // low, 300–800 medium, > 800 high), so the thresholds stay visible in
 // the markup.
 type ZoneView struct {
-	ZoneName    string
-	PeopleCount int
+	ZoneName    string `json:"zone_name"`
+	PeopleCount int    `json:"people_count"`
 }
 
 // DeviceView is one device running-status report (设备运行状态上报) of
@@ -50,52 +61,53 @@ type ZoneView struct {
 // with the optional fault note. 告警/离线 devices are highlighted by
 // the template with a striking style class.
 type DeviceView struct {
-	DeviceName string
-	DeviceType string
-	Status     string
-	Note       string
+	DeviceName string `json:"device_name"`
+	DeviceType string `json:"device_type"`
+	Status     string `json:"status"`
+	Note       string `json:"note"`
 }
 
 // DepartmentView is one department linkage-disposal report (部门联动处
 // 置记录) of the page: the department name, the linkage status and the
 // optional note.
 type DepartmentView struct {
-	Department string
-	Status     string
-	Note       string
+	Department string `json:"department"`
+	Status     string `json:"status"`
+	Note       string `json:"note"`
 }
 
 // MessageView is one dispatch message (即时通讯消息) of the page: the
 // sender side (指挥中心/现场人员), the sender display name and the
 // content.
 type MessageView struct {
-	SenderType string
-	SenderName string
-	Content    string
+	SenderType string `json:"sender_type"`
+	SenderName string `json:"sender_name"`
+	Content    string `json:"content"`
 }
 
 // OrderView is one dispatch order (调度指令) of the page: the title,
 // content, priority, receiver, execution status and the feedback trail.
 type OrderView struct {
-	Title      string
-	Content    string
-	Priority   string
-	TargetType string
-	TargetName string
-	Status     string
-	Feedback   string
+	Title      string `json:"title"`
+	Content    string `json:"content"`
+	Priority   string `json:"priority"`
+	TargetType string `json:"target_type"`
+	TargetName string `json:"target_name"`
+	Status     string `json:"status"`
+	Feedback   string `json:"feedback"`
 }
 
 // commandTemplate is the parsed template collection of the
-// command-center big screen page (layout + command page). It lives in
+// command-center big screen page (layout + command page + the run
+// stream bridge script shared with the console page). It lives in
 // its own template set because the layout's content/title hooks are
 // page-specific: every page defines its own content block, and one
 // shared parse set would let the alphabetically last page win for every
 // page (same pattern as the scenarios and drills pages).
-var commandTemplate = template.Must(template.ParseFS(templateFiles, "templates/layout.html", "templates/command.html"))
+var commandTemplate = template.Must(template.ParseFS(templateFiles, "templates/layout.html", "templates/command.html", "templates/runstream.html"))
 
 // RenderCommand renders the command-center big screen page (layout +
-// command content) with the given in-memory display data. All
+// command content) with the given display data. All
 // user-controlled input is HTML-escaped by html/template.
 func RenderCommand(w io.Writer, data CommandPageData) error {
 	return commandTemplate.ExecuteTemplate(w, "layout.html", data)
@@ -152,21 +152,38 @@ func TestRenderCommandZoneLevelsAndDeviceStyles(t *testing.T) {
 	}
 }
 
-// htmx 刷新动作：消息流/热力/设备区块的 hx-get 指向完整 API 端点
-// /crate-api/prototype/v1/drills/{rid}/messages、…/zone-densities、
-// …/devices（rid 为数据带出的固定 26 位 ULID，页面不构造 id）；只读页
-// 面没有写方法动作（无 hx-post/hx-put/hx-delete）。
-func TestRenderCommandHtmxRefreshActions(t *testing.T) {
+// 实时推送：页面订阅 /crate-api/prototype/v1/drills/{rid}/stream（rid 为
+// 数据带出的固定 26 位 ULID，页面不构造 id），不再有手动刷新按钮；热力/
+// 设备/部门/消息/指令区块在收到同类变更事件（run:<kind>）时自行重取；只
+// 读页面没有写方法动作（无 hx-post/hx-put/hx-delete）。
+func TestRenderCommandLiveBlocks(t *testing.T) {
 	var output strings.Builder
 	if err := RenderCommand(&output, commandFixture()); err != nil {
 		t.Fatalf("RenderCommand: %v", err)
 	}
 	rendered := output.String()
 	runID := "06G00JAJ2197VBH6390A1BX79C"
-	for _, resource := range []string{"messages", "zone-densities", "devices"} {
-		target := `/crate-api/prototype/v1/drills/` + runID + `/` + resource
-		if !strings.Contains(rendered, `hx-get="`+target+`"`) {
-			t.Fatalf("refresh action does not target GET %s", target)
+	if !strings.Contains(rendered, `data-run-stream="/crate-api/prototype/v1/drills/`+runID+`/stream"`) {
+		t.Fatalf("page does not subscribe to the stream of run %s", runID)
+	}
+	if !strings.Contains(rendered, "new EventSource(") {
+		t.Fatalf("page does not carry the run stream bridge script")
+	}
+	for _, block := range []struct{ id, kind string }{
+		{"command-zones", "zone_density"},
+		{"command-devices", "device"},
+		{"command-departments", "department"},
+		{"command-messages", "message"},
+		{"command-orders", "order"},
+	} {
+		want := `<section id="` + block.id + `" hx-get="/demo/command?run=` + runID + `" hx-trigger="run:` + block.kind + ` from:body" hx-select="#` + block.id + `"`
+		if !strings.Contains(rendered, want) {
+			t.Fatalf("block %s does not refresh on run:%s", block.id, block.kind)
+		}
+	}
+	for _, label := range []string{"刷新热力", "刷新设备", "刷新消息"} {
+		if strings.Contains(rendered, label) {
+			t.Fatalf("manual refresh button %q is still rendered", label)
 		}
 	}
 	// 方法为 GET：hx-get 是 GET 动词，页面不含任何写方法动作。
@@ -199,3 +216,29 @@ func TestRenderCommandIDsAreULIDsAndNoIDInput(t *testing.T) {
 		t.Fatalf("page must not construct ids (no name=\"id\" input)")
 	}
 }
+
+// 演练选择器：选项来自数据（选中项随 RunID）；未选择演练时只渲染选择器与
+// 提示，不渲染六大区块、不订阅 SSE 流。
+func TestRenderCommandRunPicker(t *testing.T) {
+	data := commandFixture()
+	data.Runs = []RunOptionView{{ID: data.RunID, Title: "大客流疏散演练（进行中）"}, {ID: "06G00JAJ2197VBH6390A1BX79D", Title: "市电中断演练（未开始）"}}
+	var output strings.Builder
+	if err := RenderCommand(&output, data); err != nil {
+		t.Fatalf("RenderCommand: %v", err)
+	}
+	if !strings.Contains(output.String(), `<option value="`+data.RunID+`" selected>大客流疏散演练（进行中）</option>`) {
+		t.Fatalf("picker does not select the run of the page")
+	}
+
+	output.Reset()
+	if err := RenderCommand(&output, CommandPageData{Runs: data.Runs, Notice: "请选择演练"}); err != nil {
+		t.Fatalf("RenderCommand: %v", err)
+	}
+	rendered := output.String()
+	if !strings.Contains(rendered, `<p class="notice">请选择演练</p>`) || !strings.Contains(rendered, "市电中断演练（未开始）") {
+		t.Fatalf("picker without a run lacks the notice or the options")
+	}
+	if strings.Contains(rendered, `data-run-stream="`) || strings.Contains(rendered, `id="command-zones"`) {
+		t.Fatalf("page without a run renders the board")
+	}
+}
@@ -7,25 +7,35 @@ import (
 
 // ConsolePageData carries the display data of the command console and
 // field terminal page (指挥调度操作与现场终端). The page renders purely
-// from this in-memory payload — no database access, no API call — so
-// the caller builds it from the built-in demo data and mints the
-// server-side ids. The page is a write-oriented demo: the commander
-// block (会话配置、下达指令、部门联动) and the field-terminal block (发送
-// 消息、上报热力、上报设备、指令反馈) carry seven forms whose hx actions
-// anchor on the run id of the demo drill run, and the list rows of the
-// orders/messages/zones/devices/departments demo data carry their
-// server-minted ids.
+// from this payload — no database access from the template — so the
+// caller loads the selected drill run and its dispatch rows and passes
+// them in. The page is write-oriented: the commander block (会话配置、
+// 下达指令、部门联动) and the field-terminal block (发送消息、上报热力、上
+// 报设备、指令反馈) carry seven forms whose hx actions anchor on the id
+// of the selected run and post JSON to the dispatch API (the json-form
+// htmx extension), and the list rows carry the ids of the stored
+// orders/messages/zones/devices/departments. Without a selected run
+// only the run picker (演练选择) and the notice render.
 type ConsolePageData struct {
-	// RunID is the 26-character ULID of the demo drill run the forms
-	// anchor on (the {rid} path segment). The page itself never
-	// constructs ids.
-	RunID       string
-	Session     ConsoleSessionView
-	Orders      []ConsoleOrderView
-	Departments []ConsoleDepartmentView
-	Messages    []ConsoleMessageView
-	Zones       []ConsoleZoneView
-	Devices     []ConsoleDeviceView
+	// Runs are the options of the run picker.
+	Runs []RunOptionView
+	// Notice is the page hint: "" while a run renders, 请选择演练
+	// without a selection, 演练不存在 for an unknown run.
+	Notice string
+	// RunID is the 26-character ULID of the selected drill run the
+	// forms anchor on (the {rid} path segment; "" when none is
+	// selected). The page itself never constructs ids.
+	RunID string
+	// LinkageDepartment is the department business enum the
+	// commander's department-linkage form targets (the {department}
+	// path segment).
+	LinkageDepartment string
+	Session           ConsoleSessionView
+	Orders            []ConsoleOrderView
+	Departments       []ConsoleDepartmentView
+	Messages          []ConsoleMessageView
+	Zones             []ConsoleZoneView
+	Devices           []ConsoleDeviceView
 }
 
 // ConsoleSessionView is the dispatch command session configuration (指
@@ -39,9 +49,9 @@ type ConsoleSessionView struct {
 
 // ConsoleOrderView is one dispatch order (调度指令) of the page: the
 // server-minted order id, the title, content, priority, receiver
-// (target_type/target_name), the deadline and the execution status. The
-// 执行中 order of the demo data is also the anchor of the field-terminal
-// feedback form (its id becomes the {oid} path segment).
+// (target_type/target_name), the deadline and the execution status.
+// Every 执行中 order is also the anchor of a field-terminal feedback
+// form (its id becomes the {oid} path segment).
 type ConsoleOrderView struct {
 	ID         string
 	Title      string
@@ -56,9 +66,7 @@ type ConsoleOrderView struct {
 // ConsoleDepartmentView is one linkage department (联动部门) of the
 // page: the server-minted report id, the department business enum
 // (消防/公安/卫健/场馆应急组/其他), the linkage status and the optional
-// note. The first department of the demo data is also the target of the
-// commander's department-linkage form (its enum value becomes the
-// {department} path segment).
+// note.
 type ConsoleDepartmentView struct {
 	ID         string
 	Department string
@@ -97,15 +105,17 @@ type ConsoleDeviceView struct {
 }
 
 // consoleTemplate is the parsed template collection of the command
-// console and field terminal page (layout + console page). It lives in
-// its own template set because the layout's content/title hooks are
-// page-specific: every page defines its own content block, and one
-// shared parse set would let the alphabetically last page win for every
-// page (same pattern as the scenarios, drills and command pages).
-var consoleTemplate = template.Must(template.ParseFS(templateFiles, "templates/layout.html", "templates/console.html"))
+// console and field terminal page (layout + console page + the run
+// stream bridge script shared with the command page + the json-form
+// htmx extension of its forms). It lives in its own template set
+// because the layout's content/title hooks are page-specific: every
+// page defines its own content block, and one shared parse set would
+// let the alphabetically last page win for every page (same pattern as
+// the scenarios, drills and command pages).
+var consoleTemplate = template.Must(template.ParseFS(templateFiles, "templates/layout.html", "templates/console.html", "templates/runstream.html", "templates/jsonform.html"))
 
 // RenderConsole renders the command console and field terminal page
-// (layout + console content) with the given in-memory display data. All
+// (layout + console content) with the given display data. All
 // user-controlled input is HTML-escaped by html/template.
 func RenderConsole(w io.Writer, data ConsolePageData) error {
 	return consoleTemplate.ExecuteTemplate(w, "layout.html", data)
@@ -22,13 +22,14 @@ var consoleULIDPattern = regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`)
 // by the data (the page never constructs ids). The fixture covers one
 // dispatch session (实训方式/主场馆/联训场馆), two dispatch orders (the
 // 执行中 one anchoring the feedback form), two department linkage
-// reports (消防 first, anchoring the linkage form), two dispatch
+// reports (the linkage form targets 消防), two dispatch
 // messages (发送方 + 内容), three zone-density reports and three device
 // reports covering 正常/告警/离线 — each of the six object kinds at
 // least one entry, matching the dispatch contract Chinese values.
 func consoleFixture() ConsolePageData {
 	return ConsolePageData{
-		RunID: "06G00NC5ZWA3K5G194PSBJ8WNR",
+		RunID:             "06G00NC5ZWA3K5G194PSBJ8WNR",
+		LinkageDepartment: "消防",
 		Session: ConsoleSessionView{
 			Mode:        "远程协同",
 			MainVenue:   "主馆一层大厅",
@@ -230,6 +231,32 @@ func TestRenderConsoleFieldForms(t *testing.T) {
 	}
 }
 
+// 实时推送：页面订阅 /drills/{rid}/stream，指令/部门/消息/热力/设备列表
+// 在收到同类变更事件（run:<kind>）时自行重取，表单不受影响。
+func TestRenderConsoleLiveLists(t *testing.T) {
+	var output strings.Builder
+	if err := RenderConsole(&output, consoleFixture()); err != nil {
+		t.Fatalf("RenderConsole: %v", err)
+	}
+	rendered := output.String()
+	runID := "06G00NC5ZWA3K5G194PSBJ8WNR"
+	if !strings.Contains(rendered, `data-run-stream="/crate-api/prototype/v1/drills/`+runID+`/stream"`) {
+		t.Fatalf("page does not subscribe to the stream of run %s", runID)
+	}
+	for _, list := range []struct{ id, kind string }{
+		{"console-order-rows", "order"},
+		{"console-department-rows", "department"},
+		{"console-message-list", "message"},
+		{"console-zone-list", "zone_density"},
+		{"console-device-list", "device"},
+	} {
+		want := `id="` + list.id + `" hx-get="/demo/console?run=` + runID + `" hx-trigger="run:` + list.kind + ` from:body" hx-select="#` + list.id + `"`
+		if !strings.Contains(rendered, want) {
+			t.Fatalf("list %s does not refresh on run:%s", list.id, list.kind)
+		}
+	}
+}
+
 // 跨切面：页面出现的全部服务端 id（表单 URL 中的 rid/oid 与各列表行的 id）
 // 均匹配 26 位 Crockford Base32 正则 ^[0-9A-HJKMNP-TV-Z]{26}$；部门联动表
 // 单的 {department} 路径段为业务枚举（消防），不参与该断言；页面无任何 id
@@ -287,3 +314,29 @@ func TestRenderConsoleIDsAreULIDsAndNoIDInput(t *testing.T) {
 		t.Fatalf("forms must not carry an id input (the page never constructs ids)")
 	}
 }
+
+// 写表单经 json-form 扩展以 JSON 提交（dispatch API 只接受 JSON），页面带
+// 出扩展脚本，联训场馆按顿号拆分为数组；未选择演练时只渲染选择器与提示，
+// 不渲染任何表单。
+func TestRenderConsoleJSONFormsAndRunPicker(t *testing.T) {
+	var output strings.Builder
+	if err := RenderConsole(&output, consoleFixture()); err != nil {
+		t.Fatalf("RenderConsole: %v", err)
+	}
+	rendered := output.String()
+	if forms, extended := strings.Count(rendered, "<form hx-"), strings.Count(rendered, `hx-ext="json-form"`); forms != 7 || extended != forms {
+		t.Fatalf("%d of %d forms use the json-form extension, want all 7", extended, forms)
+	}
+	if !strings.Contains(rendered, `htmx.defineExtension("json-form"`) || !strings.Contains(rendered, `name="joint_venues" data-json-list="、"`) {
+		t.Fatalf("page does not carry the json-form extension or the joint_venues list separator")
+	}
+
+	output.Reset()
+	if err := RenderConsole(&output, ConsolePageData{Runs: []RunOptionView{{ID: "06G00NC5ZWA3K5G194PSBJ8WNR", Title: "演练"}}, Notice: "演练不存在"}); err != nil {
+		t.Fatalf("RenderConsole: %v", err)
+	}
+	rendered = output.String()
+	if !strings.Contains(rendered, `<p class="notice">演练不存在</p>`) || strings.Contains(rendered, "<form hx-") {
+		t.Fatalf("picker without a run lacks the notice or renders the forms")
+	}
+}